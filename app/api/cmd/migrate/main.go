@@ -2,14 +2,45 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"text/tabwriter"
 
 	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
+const usage = `Usage: migrate [flags] [command]
+
+Commands:
+  status          list applied and pending migrations with their checksums
+  up              apply pending migrations (the default)
+  down N          revert the last N migrations
+  force VERSION   record the schema as being at VERSION without running any
+                  migration, accepting this build's checksums (recovery only)
+
+Flags:
+`
+
 func main() {
+	restorePath := flag.String("restore", "", "restore a full backup archive before migrating (stop Roostr and the relay first)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command, args := "up", flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	if *restorePath != "" && command != "up" {
+		log.Fatalf("-restore can only be used with up")
+	}
+
 	log.Println("Roostr Database Migration Tool")
 	log.Println("===============================")
 
@@ -24,6 +55,19 @@ func main() {
 		relayDBPath = "data/nostr.db"
 	}
 
+	// Restore a backup before opening the databases, so it is migrated too
+	if *restorePath != "" {
+		configPath := os.Getenv("CONFIG_PATH")
+		if configPath == "" {
+			configPath = "data/config.toml"
+		}
+		restoreBackup(*restorePath, services.BackupRestoreTargets{
+			AppDBPath:   appDBPath,
+			RelayDBPath: relayDBPath,
+			ConfigPath:  configPath,
+		})
+	}
+
 	// Initialize database (this will create and apply schema if new)
 	log.Printf("App database: %s", appDBPath)
 	database, err := db.New(relayDBPath, appDBPath)
@@ -32,6 +76,83 @@ func main() {
 	}
 	defer database.Close()
 
+	ctx := context.Background()
+	switch command {
+	case "status":
+		status(ctx, database)
+	case "up":
+		up(ctx, database)
+	case "down":
+		down(ctx, database, intArg(args, "down N"))
+	case "force":
+		force(ctx, database, intArg(args, "force VERSION"))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// intArg returns the single integer argument of a command.
+func intArg(args []string, form string) int {
+	if len(args) != 1 {
+		log.Fatalf("Usage: migrate %s", form)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("Usage: migrate %s: %q is not a number", form, args[0])
+	}
+	return n
+}
+
+func status(ctx context.Context, database *db.DB) {
+	states, err := database.MigrationStatus(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read migration status: %v", err)
+	}
+
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT\tCHECKSUM\tDOWN")
+	mismatched := 0
+	for _, st := range states {
+		state, appliedAt := "pending", "-"
+		if st.Applied {
+			state = "applied"
+			appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case st.Mismatch:
+			state = "MISMATCH"
+			mismatched++
+		case st.Unknown:
+			state = "unknown"
+		}
+		checksum := st.Checksum
+		if checksum == "" {
+			checksum = st.AppliedChecksum
+		}
+		if len(checksum) > 12 {
+			checksum = checksum[:12]
+		}
+		if checksum == "" {
+			checksum = "-"
+		}
+		reversible := "no"
+		if st.Reversible {
+			reversible = "yes"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt, checksum, reversible)
+	}
+	tw.Flush()
+	fmt.Println()
+
+	if mismatched > 0 {
+		log.Printf("%d applied migration(s) do not match this build; fix the schema, then run force to accept it", mismatched)
+		os.Exit(1)
+	}
+}
+
+func up(ctx context.Context, database *db.DB) {
 	// Get current schema version
 	version, err := database.GetSchemaVersion()
 	if err != nil {
@@ -49,12 +170,11 @@ func main() {
 		log.Println("No pending migrations")
 	} else {
 		log.Printf("Found %d pending migration(s)", len(pending))
+	}
 
-		// Run migrations
-		ctx := context.Background()
-		if err := database.Migrate(ctx); err != nil {
-			log.Fatalf("Migration failed: %v", err)
-		}
+	// Run migrations; this also verifies the applied ones
+	if err := database.Migrate(ctx); err != nil {
+		log.Fatalf("Migration failed: %v", err)
 	}
 
 	// Show final status
@@ -63,3 +183,43 @@ func main() {
 	log.Printf("Database is now at schema version %d", newVersion)
 	log.Println("Migration complete!")
 }
+
+func down(ctx context.Context, database *db.DB, n int) {
+	reverted, err := database.MigrateDown(ctx, n)
+	for _, m := range reverted {
+		log.Printf("Reverted %d (%s)", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatalf("Rollback failed: %v", err)
+	}
+
+	version, _ := database.GetSchemaVersion()
+	log.Printf("Database is now at schema version %d", version)
+}
+
+func force(ctx context.Context, database *db.DB, version int) {
+	if err := database.ForceVersion(ctx, version); err != nil {
+		log.Fatalf("Failed to force version: %v", err)
+	}
+	log.Printf("Schema version forced to %d; no migration SQL was run", version)
+}
+
+// restoreBackup restores the archive at path. Encrypted archives take their
+// passphrase from BACKUP_PASSPHRASE.
+func restoreBackup(path string, targets services.BackupRestoreTargets) {
+	log.Printf("Restoring backup: %s", path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open backup: %v", err)
+	}
+	defer f.Close()
+
+	manifest, err := services.RestoreBackup(f, os.Getenv("BACKUP_PASSPHRASE"), targets)
+	if err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+	log.Printf("Restored %v from a backup taken %s (schema version %d)",
+		manifest.Files, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"), manifest.SchemaVersion)
+	log.Println("Replaced files were kept with a .pre-restore suffix")
+}
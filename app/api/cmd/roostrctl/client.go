@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/handlers"
+)
+
+// apiClient is the backend that calls the Roostr API.
+type apiClient struct {
+	base string
+	http *http.Client
+}
+
+func newAPIClient(base string) *apiClient {
+	// No timeout: cleanups and exports run as long as they need, and
+	// Ctrl-C cancels them through the context
+	return &apiClient{base: strings.TrimRight(base, "/"), http: &http.Client{}}
+}
+
+// request sends a request with body encoded as JSON and returns the
+// response, or the API's error message if the status is not 2xx.
+func (c *apiClient) request(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr handlers.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&apiErr) != nil || apiErr.Error == "" {
+			return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return nil, fmt.Errorf("%s (%s)", apiErr.Error, apiErr.Code)
+	}
+	return resp, nil
+}
+
+// call sends a request and decodes the JSON response into out.
+func (c *apiClient) call(ctx context.Context, method, path string, body, out interface{}) error {
+	resp, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *apiClient) Status(ctx context.Context) (map[string]interface{}, error) {
+	var relay, stats map[string]interface{}
+	if err := c.call(ctx, http.MethodGet, "/api/v1/relay/status", nil, &relay); err != nil {
+		return nil, err
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/v1/stats/summary", nil, &stats); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"relay": relay, "stats": stats}, nil
+}
+
+func (c *apiClient) Whitelist(ctx context.Context) ([]whitelistEntry, error) {
+	var resp struct {
+		Entries []whitelistEntry `json:"entries"`
+	}
+	err := c.call(ctx, http.MethodGet, "/api/v1/access/whitelist", nil, &resp)
+	return resp.Entries, err
+}
+
+func (c *apiClient) AddWhitelist(ctx context.Context, pubkey, nickname string) error {
+	return c.call(ctx, http.MethodPost, "/api/v1/access/whitelist", handlers.AddToWhitelistRequest{
+		Pubkey:   pubkey,
+		Nickname: nickname,
+	}, nil)
+}
+
+func (c *apiClient) RemoveWhitelist(ctx context.Context, pubkey string) error {
+	return c.call(ctx, http.MethodDelete, "/api/v1/access/whitelist/"+url.PathEscape(pubkey), nil, nil)
+}
+
+func (c *apiClient) StartSync(ctx context.Context, req handlers.StartSyncRequest) (map[string]interface{}, error) {
+	var resp map[string]interface{}
+	err := c.call(ctx, http.MethodPost, "/api/v1/sync/start", req, &resp)
+	return resp, err
+}
+
+func (c *apiClient) SyncStatus(ctx context.Context) (map[string]interface{}, error) {
+	var resp map[string]interface{}
+	err := c.call(ctx, http.MethodGet, "/api/v1/sync/status", nil, &resp)
+	return resp, err
+}
+
+func (c *apiClient) Cleanup(ctx context.Context, req handlers.CleanupRequest, dryRun bool) (map[string]interface{}, error) {
+	var resp map[string]interface{}
+	if dryRun {
+		q := url.Values{}
+		q.Set("before_date", req.BeforeDate)
+		q.Set("apply_exceptions", strconv.FormatBool(req.ApplyExceptions))
+		err := c.call(ctx, http.MethodGet, "/api/v1/storage/estimate?"+q.Encode(), nil, &resp)
+		return resp, err
+	}
+	err := c.call(ctx, http.MethodPost, "/api/v1/storage/cleanup", req, &resp)
+	return resp, err
+}
+
+func (c *apiClient) Export(ctx context.Context, w io.Writer, opts exportOptions) error {
+	q := url.Values{}
+	q.Set("format", opts.Format)
+	if len(opts.Kinds) > 0 {
+		kinds := make([]string, len(opts.Kinds))
+		for i, k := range opts.Kinds {
+			kinds[i] = strconv.Itoa(k)
+		}
+		q.Set("kinds", strings.Join(kinds, ","))
+	}
+	if opts.Since > 0 {
+		q.Set("since", strconv.FormatInt(opts.Since, 10))
+	}
+	if opts.Until > 0 {
+		q.Set("until", strconv.FormatInt(opts.Until, 10))
+	}
+
+	resp, err := c.request(ctx, http.MethodGet, "/api/v1/events/export?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *apiClient) Close() error {
+	return nil
+}
@@ -0,0 +1,335 @@
+// Command roostrctl runs common operator tasks from a shell. It talks to the
+// Roostr API, or with -offline reads and writes the databases directly for
+// when the API is not running.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/handlers"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+const usage = `Usage: roostrctl [flags] command [arguments]
+
+Commands:
+  status                              show relay and API status
+  whitelist list                      list whitelisted pubkeys
+  whitelist add PUBKEY [-nickname N]  whitelist a hex pubkey or npub
+  whitelist remove PUBKEY             remove a pubkey from the whitelist
+  sync start PUBKEY... [-relays URLS] [-kinds KINDS] [-since UNIX]
+                                      sync events for pubkeys from public relays
+  sync status                         show the current or last sync job
+  cleanup -before DATE [-exceptions] [-dry-run]
+                                      delete events created before DATE
+  export [-format ndjson|json] [-kinds KINDS] [-since UNIX] [-until UNIX] [-o FILE]
+                                      export events, to stdout by default
+
+Flags:
+`
+
+// errNeedsAPI is returned by the offline backend for tasks that need the
+// running services.
+var errNeedsAPI = errors.New("this command needs the Roostr API; run it without -offline")
+
+// backend carries out commands, either through the API or on the databases.
+type backend interface {
+	Status(ctx context.Context) (map[string]interface{}, error)
+	Whitelist(ctx context.Context) ([]whitelistEntry, error)
+	AddWhitelist(ctx context.Context, pubkey, nickname string) error
+	RemoveWhitelist(ctx context.Context, pubkey string) error
+	StartSync(ctx context.Context, req handlers.StartSyncRequest) (map[string]interface{}, error)
+	SyncStatus(ctx context.Context) (map[string]interface{}, error)
+	Cleanup(ctx context.Context, req handlers.CleanupRequest, dryRun bool) (map[string]interface{}, error)
+	Export(ctx context.Context, w io.Writer, opts exportOptions) error
+	Close() error
+}
+
+// whitelistEntry is a whitelisted pubkey as listed by the API.
+type whitelistEntry struct {
+	Pubkey     string    `json:"pubkey"`
+	Npub       string    `json:"npub"`
+	Nickname   string    `json:"nickname,omitempty"`
+	IsOperator bool      `json:"is_operator"`
+	AddedAt    time.Time `json:"added_at"`
+	EventCount int64     `json:"event_count"`
+}
+
+// exportOptions are the filters and format of an event export.
+type exportOptions struct {
+	Format string
+	Kinds  []int
+	Since  int64
+	Until  int64
+}
+
+func main() {
+	apiURL := flag.String("url", envOr("ROOSTR_URL", "http://localhost:"+envOr("PORT", "3001")), "Roostr API URL (ROOSTR_URL)")
+	offline := flag.Bool("offline", false, "use the databases directly instead of the API (APP_DB_PATH, RELAY_DB_PATH, CONFIG_PATH)")
+	jsonOut := flag.Bool("json", false, "print JSON instead of tables")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var b backend
+	if *offline {
+		var err error
+		if b, err = newOfflineBackend(); err != nil {
+			fatal(err)
+		}
+	} else {
+		b = newAPIClient(*apiURL)
+	}
+	defer b.Close()
+
+	out := &printer{w: os.Stdout, json: *jsonOut}
+	if err := run(ctx, b, out, flag.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			flag.Usage()
+			os.Exit(2)
+		}
+		b.Close()
+		fatal(err)
+	}
+}
+
+// errUsage is returned for a command line that does not name a command.
+var errUsage = errors.New("usage")
+
+// run carries out the command in args.
+func run(ctx context.Context, b backend, out *printer, args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "status":
+		status, err := b.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return out.object(status)
+
+	case len(args) == 2 && args[0] == "whitelist" && args[1] == "list":
+		entries, err := b.Whitelist(ctx)
+		if err != nil {
+			return err
+		}
+		return out.whitelist(entries)
+
+	case len(args) >= 2 && args[0] == "whitelist" && args[1] == "add":
+		fs := flag.NewFlagSet("whitelist add", flag.ContinueOnError)
+		nickname := fs.String("nickname", "", "nickname for the pubkey")
+		positional, err := parseInterspersed(fs, args[2:])
+		if err != nil {
+			return err
+		}
+		if len(positional) != 1 {
+			return errUsage
+		}
+		pubkey, npub, err := nostr.ValidatePubkey(positional[0])
+		if err != nil {
+			return fmt.Errorf("invalid pubkey %q", positional[0])
+		}
+		if err := b.AddWhitelist(ctx, pubkey, *nickname); err != nil {
+			return err
+		}
+		return out.message("Added %s to the whitelist", npub)
+
+	case len(args) == 3 && args[0] == "whitelist" && args[1] == "remove":
+		pubkey, npub, err := nostr.ValidatePubkey(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid pubkey %q", args[2])
+		}
+		if err := b.RemoveWhitelist(ctx, pubkey); err != nil {
+			return err
+		}
+		return out.message("Removed %s from the whitelist", npub)
+
+	case len(args) >= 2 && args[0] == "sync" && args[1] == "start":
+		req, err := parseSyncStart(args[2:])
+		if err != nil {
+			return err
+		}
+		job, err := b.StartSync(ctx, req)
+		if err != nil {
+			return err
+		}
+		return out.object(job)
+
+	case len(args) == 2 && args[0] == "sync" && args[1] == "status":
+		status, err := b.SyncStatus(ctx)
+		if err != nil {
+			return err
+		}
+		return out.object(status)
+
+	case len(args) >= 1 && args[0] == "cleanup":
+		fs := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+		before := fs.String("before", "", "delete events created before this date (YYYY-MM-DD or RFC 3339)")
+		exceptions := fs.Bool("exceptions", true, "keep the events the retention policy exempts")
+		dryRun := fs.Bool("dry-run", false, "only estimate how many events would be deleted")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		beforeDate, err := parseDate(*before)
+		if err != nil {
+			return err
+		}
+		result, err := b.Cleanup(ctx, handlers.CleanupRequest{
+			BeforeDate:      beforeDate.Format(time.RFC3339),
+			ApplyExceptions: *exceptions,
+		}, *dryRun)
+		if err != nil {
+			return err
+		}
+		return out.object(result)
+
+	case len(args) >= 1 && args[0] == "export":
+		return export(ctx, b, args[1:])
+	}
+	return errUsage
+}
+
+func parseSyncStart(args []string) (handlers.StartSyncRequest, error) {
+	var req handlers.StartSyncRequest
+	fs := flag.NewFlagSet("sync start", flag.ContinueOnError)
+	relays := fs.String("relays", "", "comma-separated relays to sync from (default: the configured sync relays)")
+	kinds := fs.String("kinds", "", "comma-separated event kinds (default: all)")
+	since := fs.Int64("since", 0, "only sync events created after this Unix time")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return req, err
+	}
+	if len(positional) == 0 {
+		return req, errUsage
+	}
+	for _, arg := range positional {
+		pubkey, _, err := nostr.ValidatePubkey(arg)
+		if err != nil {
+			return req, fmt.Errorf("invalid pubkey %q", arg)
+		}
+		req.Pubkeys = append(req.Pubkeys, pubkey)
+	}
+
+	req.Relays = splitList(*relays)
+	if req.EventKinds, err = parseKinds(*kinds); err != nil {
+		return req, err
+	}
+	if *since > 0 {
+		req.SinceTimestamp = since
+	}
+	return req, nil
+}
+
+func export(ctx context.Context, b backend, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "ndjson", "ndjson or json")
+	kinds := fs.String("kinds", "", "comma-separated event kinds (default: all)")
+	since := fs.Int64("since", 0, "only export events created at or after this Unix time")
+	until := fs.Int64("until", 0, "only export events created at or before this Unix time")
+	output := fs.String("o", "", "write to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "ndjson" && *format != "json" {
+		return fmt.Errorf("format must be ndjson or json")
+	}
+	opts := exportOptions{Format: *format, Since: *since, Until: *until}
+	var err error
+	if opts.Kinds, err = parseKinds(*kinds); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return b.Export(ctx, os.Stdout, opts)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := b.Export(ctx, f, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parseInterspersed parses fs from args, allowing flags after the positional
+// arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// parseDate parses a date as YYYY-MM-DD (midnight UTC) or RFC 3339.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("-before is required")
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, nil
+}
+
+func parseKinds(s string) ([]int, error) {
+	var kinds []int
+	for _, k := range splitList(s) {
+		kind, err := strconv.Atoi(k)
+		if err != nil || kind < 0 {
+			return nil, fmt.Errorf("invalid event kind %q", k)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "roostrctl:", err)
+	os.Exit(1)
+}
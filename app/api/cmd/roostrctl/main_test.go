@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+const testPubkey = "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+
+// fakeAPI records the requests it gets and answers them from responses,
+// keyed by "METHOD /path".
+func fakeAPI(t *testing.T, responses map[string]string) (*apiClient, *[]string) {
+	t.Helper()
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		body.ReadFrom(r.Body)
+		requests = append(requests, strings.TrimSpace(r.Method+" "+r.URL.RequestURI()+" "+body.String()))
+
+		resp, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"Not found","code":"NOT_FOUND"}`))
+			return
+		}
+		w.Write([]byte(resp))
+	}))
+	t.Cleanup(srv.Close)
+	return newAPIClient(srv.URL + "/"), &requests
+}
+
+func TestRun_API(t *testing.T) {
+	client, requests := fakeAPI(t, map[string]string{
+		"GET /api/v1/access/whitelist":                  `{"entries":[{"pubkey":"` + testPubkey + `","npub":"npub1abc","nickname":"jack","event_count":42,"added_at":"2026-01-02T00:00:00Z"}]}`,
+		"POST /api/v1/access/whitelist":                 `{"success":true}`,
+		"DELETE /api/v1/access/whitelist/" + testPubkey: `{"success":true}`,
+		"POST /api/v1/sync/start":                       `{"job_id":7,"status":"running"}`,
+		"GET /api/v1/storage/estimate":                  `{"event_count":12,"estimated_space":4096}`,
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		args    []string
+		json    bool
+		request string
+		output  string
+	}{
+		{[]string{"whitelist", "list"}, false, "GET /api/v1/access/whitelist", "npub1abc  jack      42      2026-01-02"},
+		{[]string{"whitelist", "add", testPubkey, "-nickname", "jack"}, false,
+			`POST /api/v1/access/whitelist {"pubkey":"` + testPubkey + `","npub":"","nickname":"jack"}`, "Added npub1"},
+		{[]string{"whitelist", "remove", testPubkey}, true, "DELETE /api/v1/access/whitelist/" + testPubkey, `"message": "Removed npub1`},
+		{[]string{"sync", "start", testPubkey, "-kinds", "1,30023"}, false,
+			`POST /api/v1/sync/start {"pubkeys":["` + testPubkey + `"],"event_kinds":[1,30023]}`, "job_id  7"},
+		{[]string{"cleanup", "-before", "2026-01-01", "-dry-run"}, false,
+			"GET /api/v1/storage/estimate?apply_exceptions=true&before_date=2026-01-01T00%3A00%3A00Z", "event_count      12"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			*requests = nil
+			var out bytes.Buffer
+			if err := run(ctx, client, &printer{w: &out, json: tt.json}, tt.args); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			if len(*requests) != 1 || (*requests)[0] != tt.request {
+				t.Errorf("expected request %q, got %q", tt.request, *requests)
+			}
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("expected output containing %q, got:\n%s", tt.output, out.String())
+			}
+		})
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	client, _ := fakeAPI(t, nil)
+	ctx := context.Background()
+	var out bytes.Buffer
+	p := &printer{w: &out}
+
+	if err := run(ctx, client, p, []string{"sync", "status"}); err == nil || err.Error() != "Not found (NOT_FOUND)" {
+		t.Errorf("expected the API error, got %v", err)
+	}
+	if err := run(ctx, client, p, []string{"whitelist", "add", "nope"}); err == nil || !strings.Contains(err.Error(), "invalid pubkey") {
+		t.Errorf("expected an invalid pubkey error, got %v", err)
+	}
+	if err := run(ctx, client, p, []string{"whitelist"}); err != errUsage {
+		t.Errorf("expected errUsage, got %v", err)
+	}
+	if err := run(ctx, client, p, []string{"cleanup"}); err == nil || !strings.Contains(err.Error(), "-before") {
+		t.Errorf("expected a missing date error, got %v", err)
+	}
+}
+
+func TestPrinter_Object(t *testing.T) {
+	var out bytes.Buffer
+	var status map[string]interface{}
+	json.Unmarshal([]byte(`{"relay":{"status":"running","pid":12},"stats":{"total_events":1500,"events_by_kind":{"1":3}},"note":null}`), &status)
+
+	if err := (&printer{w: &out}).object(status); err != nil {
+		t.Fatal(err)
+	}
+	want := "note                  -\nrelay.pid             12\nrelay.status          running\nstats.events_by_kind.1  3\nstats.total_events    1500\n"
+	if got := out.String(); strings.ReplaceAll(got, " ", "") != strings.ReplaceAll(want, " ", "") {
+		t.Errorf("unexpected table:\n%s", got)
+	}
+}
+
+func TestRun_Offline(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APP_DB_PATH", dir+"/roostr.db")
+	t.Setenv("RELAY_DB_PATH", "")
+	t.Setenv("CONFIG_PATH", dir+"/config.toml")
+	os.WriteFile(dir+"/config.toml", []byte("[authorization]\npubkey_whitelist = []\n"), 0o600)
+
+	// Offline mode expects a migrated database
+	database, err := db.New("", dir+"/roostr.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Migrate(context.Background())
+	database.Close()
+
+	b, err := newOfflineBackend()
+	if err != nil {
+		t.Fatalf("newOfflineBackend failed: %v", err)
+	}
+	defer b.Close()
+	ctx := context.Background()
+	var out bytes.Buffer
+	p := &printer{w: &out, json: true}
+
+	if err := run(ctx, b, p, []string{"whitelist", "add", testPubkey}); err != nil {
+		t.Fatalf("whitelist add failed: %v", err)
+	}
+	entries, _ := b.Whitelist(ctx)
+	if len(entries) != 1 || entries[0].Pubkey != testPubkey || !strings.HasPrefix(entries[0].Npub, "npub1") {
+		t.Errorf("unexpected whitelist: %+v", entries)
+	}
+	if config, _ := os.ReadFile(dir + "/config.toml"); !strings.Contains(string(config), testPubkey) {
+		t.Errorf("expected the pubkey in config.toml, got:\n%s", config)
+	}
+
+	if err := run(ctx, b, p, []string{"sync", "status"}); err != errNeedsAPI {
+		t.Errorf("expected errNeedsAPI, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/handlers"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// offlineBackend is the backend that works on the databases directly. It is
+// meant for when the API is down: whitelist changes are written to the
+// relay's config.toml, but the relay has to be restarted to pick them up.
+type offlineBackend struct {
+	db         *db.DB
+	configPath string
+}
+
+func newOfflineBackend() (*offlineBackend, error) {
+	database, err := db.New(envOr("RELAY_DB_PATH", "data/nostr.db"), envOr("APP_DB_PATH", "data/roostr.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if pending, err := database.GetPendingMigrations(); err == nil && len(pending) > 0 {
+		database.Close()
+		return nil, fmt.Errorf("the app database has %d pending migration(s); run migrate first", len(pending))
+	}
+	return &offlineBackend{db: database, configPath: envOr("CONFIG_PATH", "data/config.toml")}, nil
+}
+
+func (o *offlineBackend) Status(ctx context.Context) (map[string]interface{}, error) {
+	version, err := o.db.GetSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	whitelistCount, err := o.db.GetWhitelistCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accessMode, _ := o.db.GetAccessMode(ctx)
+
+	status := map[string]interface{}{
+		"schema_version":     version,
+		"access_mode":        accessMode,
+		"whitelisted_count":  whitelistCount,
+		"database_connected": o.db.IsRelayDBConnected(),
+	}
+	if o.db.IsRelayDBConnected() {
+		if total, err := o.db.CountEvents(ctx, db.EventFilter{}); err == nil {
+			status["total_events"] = total
+		}
+		if size, err := o.db.GetRelayDatabaseSize(); err == nil {
+			status["storage_bytes"] = size
+		}
+	}
+
+	// Round-trip through JSON so the table output matches the API's
+	data, _ := json.Marshal(status)
+	var out map[string]interface{}
+	return out, json.Unmarshal(data, &out)
+}
+
+func (o *offlineBackend) Whitelist(ctx context.Context) ([]whitelistEntry, error) {
+	entries, err := o.db.GetWhitelistMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts map[string]int64
+	if o.db.IsRelayDBConnected() {
+		pubkeys := make([]string, len(entries))
+		for i, e := range entries {
+			pubkeys[i] = e.Pubkey
+		}
+		counts, _ = o.db.CountEventsByPubkeyCached(ctx, pubkeys)
+	}
+
+	result := make([]whitelistEntry, len(entries))
+	for i, e := range entries {
+		result[i] = whitelistEntry{
+			Pubkey:     e.Pubkey,
+			Npub:       e.Npub,
+			Nickname:   e.Nickname,
+			IsOperator: e.IsOperator,
+			AddedAt:    e.AddedAt,
+			EventCount: counts[e.Pubkey],
+		}
+	}
+	return result, nil
+}
+
+func (o *offlineBackend) AddWhitelist(ctx context.Context, pubkey, nickname string) error {
+	npub, err := nostr.EncodeNpub(pubkey)
+	if err != nil {
+		return err
+	}
+	if err := o.db.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: pubkey, Npub: npub, Nickname: nickname}); err != nil {
+		return err
+	}
+	o.db.AddAuditLog(ctx, "whitelist_add", map[string]string{
+		"pubkey":   pubkey,
+		"nickname": nickname,
+		"source":   "roostrctl",
+	}, "")
+	return o.writeAccessLists(ctx)
+}
+
+func (o *offlineBackend) RemoveWhitelist(ctx context.Context, pubkey string) error {
+	if err := o.db.RemoveWhitelistEntry(ctx, pubkey); err != nil {
+		return err
+	}
+	o.db.AddAuditLog(ctx, "whitelist_remove", map[string]string{
+		"pubkey": pubkey,
+		"source": "roostrctl",
+	}, "")
+	return o.writeAccessLists(ctx)
+}
+
+// writeAccessLists writes the access lists to config.toml, unless the relay
+// delegates access to Roostr's admission server, which reads them from the
+// database.
+func (o *offlineBackend) writeAccessLists(ctx context.Context) error {
+	if _, err := os.Stat(o.configPath); err != nil {
+		return nil
+	}
+
+	configMgr := relay.NewConfigManager(o.configPath)
+	cfg, err := configMgr.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", o.configPath, err)
+	}
+	if cfg.GRPC.EventAdmissionServer != "" {
+		return nil
+	}
+
+	whitelist, blacklist, err := services.AccessLists(ctx, o.db)
+	if err != nil {
+		return err
+	}
+	cfg.Authorization.PubkeyWhitelist = whitelist
+	cfg.Authorization.PubkeyBlacklist = blacklist
+	if err := configMgr.WriteAs(cfg, "roostrctl", "Access lists changed offline"); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Updated %s; restart the relay to apply it\n", o.configPath)
+	return nil
+}
+
+func (o *offlineBackend) StartSync(context.Context, handlers.StartSyncRequest) (map[string]interface{}, error) {
+	return nil, errNeedsAPI
+}
+
+func (o *offlineBackend) SyncStatus(context.Context) (map[string]interface{}, error) {
+	return nil, errNeedsAPI
+}
+
+func (o *offlineBackend) Cleanup(context.Context, handlers.CleanupRequest, bool) (map[string]interface{}, error) {
+	return nil, errNeedsAPI
+}
+
+func (o *offlineBackend) Export(ctx context.Context, w io.Writer, opts exportOptions) error {
+	filter := db.EventFilter{Kinds: opts.Kinds}
+	if opts.Since > 0 {
+		filter.Since = time.Unix(opts.Since, 0)
+	}
+	if opts.Until > 0 {
+		filter.Until = time.Unix(opts.Until, 0)
+	}
+
+	// Honor DM privacy mode as the API does
+	privacy, err := o.db.GetPrivacySettings(ctx)
+	redact := err != nil || privacy.RedactDMs
+
+	bw := bufio.NewWriter(w)
+	if opts.Format == "json" {
+		bw.WriteString("[\n")
+	}
+	first := true
+	err = o.db.StreamEvents(ctx, filter, func(event db.ExportEvent) error {
+		if redact {
+			event.RedactDM()
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		if opts.Format == "json" && !first {
+			bw.WriteString(",\n")
+		}
+		first = false
+		bw.Write(data)
+		if opts.Format == "ndjson" {
+			bw.WriteByte('\n')
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if opts.Format == "json" {
+		bw.WriteString("\n]")
+	}
+	return bw.Flush()
+}
+
+func (o *offlineBackend) Close() error {
+	return o.db.Close()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// printer writes command results as tables, or as JSON with -json.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p *printer) writeJSON(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// message prints a confirmation, as {"message": ...} with -json.
+func (p *printer) message(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if p.json {
+		return p.writeJSON(map[string]string{"message": msg})
+	}
+	_, err := fmt.Fprintln(p.w, msg)
+	return err
+}
+
+// object prints a JSON object as KEY/VALUE rows, with nested objects
+// flattened to dotted keys.
+func (p *printer) object(v map[string]interface{}) error {
+	if p.json {
+		return p.writeJSON(v)
+	}
+
+	rows := map[string]string{}
+	flatten("", v, rows)
+	keys := make([]string, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%s\n", k, rows[k])
+	}
+	return tw.Flush()
+}
+
+func flatten(prefix string, v interface{}, rows map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			flatten(k, child, rows)
+		}
+	case nil:
+		rows[prefix] = "-"
+	case string:
+		rows[prefix] = v
+	case float64:
+		rows[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		data, _ := json.Marshal(v)
+		rows[prefix] = string(data)
+	}
+}
+
+func (p *printer) whitelist(entries []whitelistEntry) error {
+	if p.json {
+		if entries == nil {
+			entries = []whitelistEntry{}
+		}
+		return p.writeJSON(entries)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NPUB\tNICKNAME\tEVENTS\tADDED")
+	for _, e := range entries {
+		nickname := e.Nickname
+		if e.IsOperator {
+			nickname += " (operator)"
+		}
+		if nickname == "" {
+			nickname = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", e.Npub, nickname, e.EventCount, e.AddedAt.Format("2006-01-02"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/testutil"
+)
+
+const usage = `Usage: seed [flags]
+
+Generates a relay database of realistic events (profiles, follow lists,
+notes and reply threads, reactions, reposts, DMs, zaps, long-form articles,
+reports and deletions) for benchmarks, integration tests and trying out the
+dashboard. The output must not exist: seed never overwrites a database.
+
+Flags:
+`
+
+func main() {
+	out := flag.String("out", os.Getenv("RELAY_DB_PATH"), "relay database to create (default $RELAY_DB_PATH, or data/nostr.db)")
+	authors := flag.Int("authors", 100, "number of authors")
+	events := flag.Int("events", 10000, "number of events")
+	days := flag.Int("days", 30, "days back from now the events are spread over")
+	seed := flag.Int64("seed", 1, "generator seed; the same flags give the same database")
+	sign := flag.Bool("sign", false, "sign events with the authors' keys (slower; signatures are random otherwise)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *out == "" {
+		*out = "data/nostr.db"
+	}
+	if *authors < 1 || *events < 1 || *days < 1 {
+		log.Fatalf("-authors, -events and -days must be at least 1")
+	}
+
+	log.Printf("Generating %d events from %d authors in %s", *events, *authors, *out)
+	started := time.Now()
+	fixture, err := testutil.WriteRelayDB(context.Background(), *out, testutil.RelayOptions{
+		Authors: *authors,
+		Events:  *events,
+		Days:    *days,
+		Seed:    *seed,
+		Sign:    *sign,
+	})
+	if err != nil {
+		log.Fatalf("Failed to generate relay database: %v", err)
+	}
+	log.Printf("Wrote %d events and %d tags in %s", fixture.Events, fixture.Tags, time.Since(started).Round(time.Millisecond))
+
+	kinds := make([]int, 0, len(fixture.Kinds))
+	for kind := range fixture.Kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Ints(kinds)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tEVENTS")
+	for _, kind := range kinds {
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", kind, nostr.LookupKind(kind).Name, fixture.Kinds[kind])
+	}
+	tw.Flush()
+	fmt.Printf("\n%d replies; most active author %s with %d events\n",
+		fixture.Replies, fixture.Authors[0], fixture.ByAuthor[fixture.Authors[0]])
+}
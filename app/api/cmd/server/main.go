@@ -2,65 +2,166 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/config"
 	"github.com/roostr/roostr/app/api/internal/db"
 	"github.com/roostr/roostr/app/api/internal/handlers"
+	"github.com/roostr/roostr/app/api/internal/logging"
 	"github.com/roostr/roostr/app/api/internal/relay"
 	"github.com/roostr/roostr/app/api/internal/services"
 )
 
 func main() {
-	log.Println("Starting Roostr API server...")
-
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Set up structured logging before anything else logs
+	if _, err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.LogFormat); err != nil {
+		slog.Error("Invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Starting Roostr API server...")
+	if cfg.File != "" {
+		slog.Info("Config file loaded", "path", cfg.File)
 	}
 
 	// Initialize database
 	database, err := db.New(cfg.RelayDBPath, cfg.AppDBPath)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		slog.Error("Failed to initialize database", "error", err)
+		os.Exit(1)
 	}
 	defer database.Close()
 
+	// Read nostr-rs-relay's PostgreSQL database instead of the SQLite file
+	if cfg.RelayDBDSN != "" {
+		if err := database.UsePostgresRelay(cfg.RelayDBDSN); err != nil {
+			slog.Warn("Could not connect to PostgreSQL relay database", "error", err)
+		} else {
+			slog.Info("Using PostgreSQL relay database")
+		}
+	}
+
+	// Read strfry's events through an index at RELAY_DB_PATH
+	if cfg.RelayType == db.RelayTypeStrfry {
+		database.SetRelayBackend(db.NewStrfryBackend(cfg.StrfryBinary, cfg.StrfryConfig))
+		slog.Info("Using strfry relay backend", "binary", cfg.StrfryBinary, "index", cfg.RelayDBPath)
+	}
+
+	// Attach the relay database once it appears, and again after a restore
+	database.WatchRelayDB(5 * time.Second)
+
 	// Run any pending migrations
 	ctx := context.Background()
 	if err := database.Migrate(ctx); err != nil {
-		log.Printf("Warning: Migration failed: %v", err)
+		slog.Warn("Migration failed", "error", err)
+	}
+
+	// Load the vault for stored credentials, unlocking it when a passphrase
+	// file is configured
+	if err := database.LoadSecrets(ctx); err != nil {
+		slog.Warn("Failed to load secrets settings", "error", err)
+	}
+	if cfg.SecretsPassphraseFile != "" {
+		if err := services.UnlockSecretsFromFile(ctx, database, cfg.SecretsPassphraseFile); err != nil {
+			slog.Warn("Secrets not unlocked", "error", err)
+		} else {
+			slog.Info("Secrets unlocked", "passphrase_file", cfg.SecretsPassphraseFile)
+		}
+	} else if database.Secrets().Locked() {
+		slog.Warn("Stored secrets are locked; unlock them from the Settings page to use Lightning")
+	}
+
+	// Repair npubs written before they were derived server-side
+	if _, err := services.CheckNpubs(ctx, database, false); err != nil {
+		slog.Warn("Npub consistency check failed", "error", err)
 	}
 
 	// Initialize config manager for relay config.toml
 	var configMgr *relay.ConfigManager
 	if cfg.ConfigPath != "" {
 		configMgr = relay.NewConfigManager(cfg.ConfigPath)
-		log.Printf("Config manager initialized for: %s", cfg.ConfigPath)
+		slog.Info("Config manager initialized", "path", cfg.ConfigPath)
+
+		// Keep every version of config.toml for rollback, starting with the
+		// file as found, which may include edits made while Roostr was down
+		configMgr.SetRecorder(func(content []byte, actor, reason string) {
+			if err := database.AddConfigVersion(context.Background(), string(content), actor, reason); err != nil {
+				slog.Warn("Failed to save config history", "error", err)
+			}
+		})
+		if content, err := configMgr.ReadRaw(); err == nil {
+			if err := database.AddConfigVersion(ctx, string(content), "roostr", "Config at startup"); err != nil {
+				slog.Warn("Failed to save config history", "error", err)
+			}
+		}
+	}
+
+	// Let nostr-rs-relay ask the admission server about each event instead
+	// of reading the access lists from config.toml, or undo that
+	admissionChanged := false
+	if configMgr != nil && cfg.RelayType == "nostr-rs-relay" {
+		url := ""
+		if cfg.AdmissionListen != "" {
+			url = services.AdmissionURL(cfg.AdmissionListen)
+		}
+		changed, err := services.ConfigureAdmission(ctx, database, configMgr, url)
+		if err != nil {
+			slog.Warn("Failed to configure event admission", "error", err)
+		}
+		admissionChanged = changed
 	}
 
 	// Initialize relay manager
 	var relayMgr *relay.Relay
 	if cfg.RelayBinary != "" {
 		relayMgr = relay.New(cfg.RelayBinary, cfg.ConfigPath)
-		if relayMgr.IsRunning() {
-			log.Println("Relay process detected as running")
+		if cfg.RelaySupervise {
+			if err := relayMgr.Supervise(); err != nil {
+				slog.Warn("Relay not supervised", "error", err)
+			} else {
+				slog.Info("Relay supervisor started", "binary", cfg.RelayBinary)
+			}
+		} else if relayMgr.IsRunning() {
+			slog.Info("Relay process detected as running")
+			if admissionChanged {
+				if err := relayMgr.Restart(); err != nil {
+					slog.Warn("Failed to restart relay", "error", err)
+				}
+			}
 		} else {
-			log.Println("Relay process not detected (will sync config but not reload)")
+			slog.Info("Relay process not detected (will sync config but not reload)")
 		}
 	}
 
 	// Initialize services (pass configMgr and relayMgr for invoice monitor to sync whitelist)
 	svc := services.New(database, configMgr, relayMgr)
+	svc.Hardware.Configure(services.DetectHardware(filepath.Dir(cfg.RelayDBPath)))
+	svc.Bandwidth.Configure(cfg.BandwidthProxyListen, "127.0.0.1:"+cfg.RelayPort)
+	svc.Uptime.Configure("ws://127.0.0.1:" + cfg.RelayPort)
+	svc.Admission.Configure(cfg.AdmissionListen)
+	if err := svc.ApplyConfig(ctx, cfg); err != nil {
+		slog.Warn("Some config file settings were not applied", "error", err)
+	}
+	if cfg.LightningMock != "" {
+		svc.Lightning.UseMock(cfg.LightningMock)
+		slog.Warn("Using mock Lightning backend (LIGHTNING_MOCK is set)")
+	}
 	svc.Start()
 	defer svc.Stop()
-	log.Println("Background services started")
+	slog.Info("Background services started")
 
 	// Create handler with dependencies
 	h := handlers.New(database, cfg, configMgr, relayMgr, svc)
@@ -71,9 +172,13 @@ func main() {
 
 	// Apply middleware
 	handler := handlers.Chain(mux,
+		handlers.RequestID,
 		handlers.Recover,
-		handlers.CORS,
+		h.PublicCORS,
+		handlers.CORS(database.GetAdminAllowedOrigins),
 		handlers.Logging,
+		h.RateLimit,
+		handlers.CSRF(database.GetCSRFSecret),
 	)
 
 	// Create server
@@ -87,21 +192,31 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Roostr API listening on http://localhost:%s", cfg.Port)
+		slog.Info("Roostr API listening", "addr", "http://localhost:"+cfg.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+			slog.Error("Server error", "error", err)
+			os.Exit(1)
 		}
 	}()
 
+	// Reload the config file on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func(current *config.Config) {
+		for range hup {
+			current = reloadConfig(current, svc, h)
+		}
+	}(cfg)
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server...")
 
 	// Stop background services first
-	log.Println("Stopping background services...")
+	slog.Info("Stopping background services...")
 	svc.Stop()
 
 	// Graceful shutdown with timeout
@@ -109,8 +224,42 @@ func main() {
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		slog.Error("Server forced to shutdown", "error", err)
+		os.Exit(1)
+	}
+
+	// Stop the relay last so in-flight requests could still reach it
+	if relayMgr != nil && relayMgr.IsSupervised() {
+		slog.Info("Stopping relay...")
+		relayMgr.Shutdown()
+	}
+
+	slog.Info("Server stopped")
+}
+
+// reloadConfig reads the config file and environment again and applies what
+// can change while running. Settings that need a restart keep their current
+// values. If the config cannot be read, the current config stays in use.
+func reloadConfig(cfg *config.Config, svc *services.Services, h *handlers.Handler) *config.Config {
+	next, err := config.Load()
+	if err != nil {
+		slog.Error("Config reload failed; keeping the current config", "error", err)
+		return cfg
 	}
+	next, restart := cfg.Reload(next)
 
-	log.Println("Server stopped")
+	if _, err := logging.Setup(os.Stderr, next.LogLevel, next.LogFormat); err != nil {
+		slog.Error("Invalid logging configuration; keeping the current logging", "error", err)
+		next.LogLevel, next.LogFormat = cfg.LogLevel, cfg.LogFormat
+	}
+	if err := svc.ApplyConfig(context.Background(), next); err != nil {
+		slog.Warn("Some config file settings were not applied", "error", err)
+	}
+	h.SetConfig(next)
+
+	if len(restart) > 0 {
+		slog.Warn("Config changes need a restart to take effect", "settings", restart)
+	}
+	slog.Info("Config reloaded", "path", next.File)
+	return next
 }
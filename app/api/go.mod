@@ -4,7 +4,11 @@ go 1.22
 
 require github.com/mattn/go-sqlite3 v1.14.22
 
-require github.com/BurntSushi/toml v1.5.0
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/lib/pq v1.10.9
+	google.golang.org/grpc v1.66.3
+)
 
 require (
 	github.com/btcsuite/btcd/btcec/v2 v2.3.6
@@ -14,4 +18,9 @@ require (
 require (
 	github.com/btcsuite/btcd/chaincfg/chainhash v1.0.1 // indirect
 	github.com/decred/dcrd/crypto/blake256 v1.0.0 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240604185151-ef581f913117 // indirect
+	google.golang.org/protobuf v1.34.1 // indirect
 )
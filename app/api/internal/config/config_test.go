@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_File(t *testing.T) {
+	path := writeConfigFile(t, "roostr.toml", `
+[server]
+port = 8080
+trusted_proxies = ["10.21.0.0/16"]
+
+[database]
+relay_path = "/data/nostr.db"
+relay_dsn = "postgres://roostr:hunter2@db/nostr"
+
+[smtp]
+host = "smtp.example.com"
+password = "secret"
+
+[notifications]
+relays = ["wss://notify.example.com"]
+
+[backups]
+dir = "/data/backups"
+
+[backups.schedule]
+enabled = true
+frequency = "weekly"
+hour = 3
+
+[rate_limits]
+enabled = true
+signup = { per_minute = 6, burst = 3 }
+
+[[webhooks]]
+url = "https://hooks.example.com/roostr"
+events = ["invoice.paid"]
+secret = "whsec"
+`)
+	t.Setenv("ROOSTR_CONFIG", path)
+	t.Setenv("RELAY_DB_PATH", "/env/nostr.db")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.File != path || cfg.Port != "8080" || cfg.SMTPHost != "smtp.example.com" || cfg.BackupDir != "/data/backups" {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if cfg.RelayDBPath != "/env/nostr.db" {
+		t.Errorf("expected the environment to override the file, got %q", cfg.RelayDBPath)
+	}
+	if cfg.AppDBPath != "data/roostr.db" || cfg.SMTPPort != "587" {
+		t.Errorf("expected defaults for unset settings, got %q and %q", cfg.AppDBPath, cfg.SMTPPort)
+	}
+	if len(cfg.TrustedProxies) != 1 || cfg.TrustedProxies[0].String() != "10.21.0.0/16" {
+		t.Errorf("unexpected trusted proxies: %v", cfg.TrustedProxies)
+	}
+	if len(cfg.NotificationRelays) != 1 || cfg.BackupSchedule == nil || cfg.BackupSchedule.Frequency != "weekly" ||
+		cfg.RateLimits == nil || cfg.RateLimits.Signup.PerMinute != 6 || len(cfg.Webhooks) != 1 {
+		t.Errorf("subsystem settings not applied: %+v", cfg)
+	}
+
+	red := cfg.Redacted()
+	if red.SMTPPassword != redacted || red.Webhooks[0].Secret != redacted || strings.Contains(red.RelayDBDSN, "hunter2") {
+		t.Errorf("secrets not redacted: %+v", red)
+	}
+	if cfg.SMTPPassword != "secret" || cfg.Webhooks[0].Secret != "whsec" {
+		t.Error("Redacted changed the original config")
+	}
+}
+
+func TestLoad_InvalidFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"unknown key", "roostr.toml", "[server]\nprot = 80\n", "server.prot"},
+		{"yaml", "roostr.yaml", "server:\n  port: 80\n", "YAML"},
+		{"syntax", "roostr.toml", "[server\n", "roostr.toml"},
+		{"notification relay", "roostr.toml", "[notifications]\nrelays = [\"https://x\"]\n", "notification relay"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ROOSTR_CONFIG", writeConfigFile(t, tt.file, tt.content))
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestConfig_Reload(t *testing.T) {
+	current := &Config{Port: "3001", RelayDBPath: "a.db", LogLevel: "info", RelaySupervise: true}
+	next := &Config{Port: "8080", RelayDBPath: "a.db", LogLevel: "debug"}
+
+	reloaded, restart := current.Reload(next)
+	if reloaded.Port != "3001" || !reloaded.RelaySupervise {
+		t.Errorf("expected restart-only settings to be kept, got %+v", reloaded)
+	}
+	if reloaded.LogLevel != "debug" {
+		t.Errorf("expected the new log level, got %q", reloaded.LogLevel)
+	}
+	if strings.Join(restart, ",") != "port,relay_supervise" {
+		t.Errorf("unexpected restart settings: %v", restart)
+	}
+}
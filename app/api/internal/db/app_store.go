@@ -2,10 +2,19 @@ package db
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/roostr/roostr/app/api/internal/secrets"
 )
 
 // ============================================================================
@@ -96,6 +105,23 @@ func (d *DB) SetTimezone(ctx context.Context, timezone string) error {
 	return d.SetAppState(ctx, "timezone", timezone)
 }
 
+// GetLowPowerMode returns the low-power mode setting (defaults to "auto").
+func (d *DB) GetLowPowerMode(ctx context.Context) (string, error) {
+	mode, err := d.GetAppState(ctx, "low_power_mode")
+	if err != nil {
+		return "", err
+	}
+	if mode == "" {
+		return "auto", nil
+	}
+	return mode, nil
+}
+
+// SetLowPowerMode sets the low-power mode setting: auto, on or off.
+func (d *DB) SetLowPowerMode(ctx context.Context, mode string) error {
+	return d.SetAppState(ctx, "low_power_mode", mode)
+}
+
 // ============================================================================
 // Whitelist Metadata
 // ============================================================================
@@ -279,6 +305,13 @@ func (d *DB) RemoveBlacklistEntry(ctx context.Context, pubkey string) error {
 	return err
 }
 
+// IsBlacklisted reports whether a pubkey is on the blacklist.
+func (d *DB) IsBlacklisted(ctx context.Context, pubkey string) (bool, error) {
+	var n int
+	err := d.AppDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM blacklist WHERE pubkey = ?", pubkey).Scan(&n)
+	return n > 0, err
+}
+
 // ============================================================================
 // Paid Users
 // ============================================================================
@@ -290,7 +323,7 @@ type PaidUser struct {
 	Npub          string    `json:"npub"`
 	Tier          string    `json:"tier"`
 	AmountSats    int64     `json:"amount_sats"`
-	Status        string    `json:"status"`
+	Status        string    `json:"status"` // active, grace, expired or revoked
 	CreatedAt     time.Time `json:"created_at"`
 	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
 	LastPaymentAt time.Time `json:"last_payment_at"`
@@ -386,12 +419,13 @@ func (d *DB) UpdatePaidUserStatus(ctx context.Context, pubkey, status string) er
 	return err
 }
 
-// GetExpiredPaidUsers returns paid users whose access has expired.
+// GetExpiredPaidUsers returns paid users whose subscription has expired but
+// who still have access, either active or in their grace period.
 func (d *DB) GetExpiredPaidUsers(ctx context.Context) ([]PaidUser, error) {
 	rows, err := d.AppDB.QueryContext(ctx, `
 		SELECT id, pubkey, npub, tier, amount_sats, status, created_at, expires_at, last_payment_at
 		FROM paid_users
-		WHERE status = 'active' AND expires_at IS NOT NULL AND expires_at < strftime('%s', 'now')
+		WHERE status IN ('active', 'grace') AND expires_at IS NOT NULL AND expires_at < strftime('%s', 'now')
 	`)
 	if err != nil {
 		return nil, err
@@ -424,12 +458,91 @@ func (d *DB) GetExpiredPaidUsers(ctx context.Context) ([]PaidUser, error) {
 	return users, rows.Err()
 }
 
+// RenewPaidUser records a payment by an existing paid user, reactivating
+// them and replacing their tier, amount and expiry.
+func (d *DB) RenewPaidUser(ctx context.Context, user PaidUser) error {
+	var expiresAt interface{}
+	if user.ExpiresAt != nil {
+		expiresAt = user.ExpiresAt.Unix()
+	}
+
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE paid_users
+		SET tier = ?, amount_sats = ?, status = ?, expires_at = ?, last_payment_at = strftime('%s', 'now')
+		WHERE pubkey = ?
+	`, user.Tier, user.AmountSats, user.Status, expiresAt, user.Pubkey)
+	return err
+}
+
+// GetPaidUsersDueForRenewal returns active or in-grace paid users expiring
+// before the given time who have not been sent a renewal invoice for that expiry.
+func (d *DB) GetPaidUsersDueForRenewal(ctx context.Context, before time.Time) ([]PaidUser, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT p.id, p.pubkey, p.npub, p.tier, p.amount_sats, p.status, p.created_at, p.expires_at, p.last_payment_at
+		FROM paid_users p
+		WHERE p.status IN ('active', 'grace') AND p.expires_at IS NOT NULL AND p.expires_at <= ?
+			AND NOT EXISTS (
+				SELECT 1 FROM renewal_reminders r
+				WHERE r.pubkey = p.pubkey AND r.expires_at = p.expires_at
+			)
+		ORDER BY p.expires_at
+	`, before.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []PaidUser
+	for rows.Next() {
+		var u PaidUser
+		var createdAt, expiresAt, lastPaymentAt sql.NullInt64
+
+		err := rows.Scan(&u.ID, &u.Pubkey, &u.Npub, &u.Tier, &u.AmountSats, &u.Status, &createdAt, &expiresAt, &lastPaymentAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if createdAt.Valid {
+			u.CreatedAt = time.Unix(createdAt.Int64, 0)
+		}
+		if expiresAt.Valid {
+			t := time.Unix(expiresAt.Int64, 0)
+			u.ExpiresAt = &t
+		}
+		if lastPaymentAt.Valid {
+			u.LastPaymentAt = time.Unix(lastPaymentAt.Int64, 0)
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// AddRenewalReminder records that a renewal invoice was sent for a paid
+// user's current expiry, so the user is reminded once per period.
+func (d *DB) AddRenewalReminder(ctx context.Context, pubkey string, expiresAt time.Time, paymentHash string, dmSent bool) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO renewal_reminders (pubkey, expires_at, payment_hash, dm_sent)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(pubkey, expires_at) DO UPDATE SET
+			payment_hash = excluded.payment_hash,
+			dm_sent = excluded.dm_sent
+	`, pubkey, expiresAt.Unix(), paymentHash, dmSent)
+	return err
+}
+
+// Payment types recorded in the payment history.
+const (
+	PaymentTypeLightning = "lightning"
+	PaymentTypeCashu     = "cashu" // Cashu token melted to pay the invoice
+)
+
 // AddPaymentHistory records a payment in the payment history table.
-func (d *DB) AddPaymentHistory(ctx context.Context, pubkey, paymentHash, tier string, amountSats int64, invoice string) error {
+func (d *DB) AddPaymentHistory(ctx context.Context, pubkey, paymentHash, tier string, amountSats int64, invoice, paymentType string) error {
 	_, err := d.AppDB.ExecContext(ctx, `
-		INSERT INTO payment_history (pubkey, payment_hash, tier, amount_sats, invoice)
-		VALUES (?, ?, ?, ?, ?)
-	`, pubkey, paymentHash, tier, amountSats, invoice)
+		INSERT INTO payment_history (pubkey, payment_hash, tier, amount_sats, invoice, payment_type)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, pubkey, paymentHash, tier, amountSats, invoice, paymentType)
 	return err
 }
 
@@ -639,6 +752,8 @@ type SyncJob struct {
 	EventsFetched int64     `json:"events_fetched"`
 	EventsStored  int64     `json:"events_stored"`
 	EventsSkipped int64     `json:"events_skipped"`
+	EventsRejected int64    `json:"events_rejected"` // Failed ID or signature verification
+	SkipVerification bool   `json:"skip_verification"` // Relays trusted; events stored unverified
 	ErrorMessage  string    `json:"error_message,omitempty"`
 }
 
@@ -662,17 +777,36 @@ func (d *DB) CreateSyncJob(ctx context.Context, job SyncJob) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
 
-	return result.LastInsertId()
+	_, err = d.AppDB.ExecContext(ctx, `
+		INSERT INTO sync_job_verification (sync_job_id, skip_verification) VALUES (?, ?)
+	`, id, job.SkipVerification)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
 }
 
 // UpdateSyncJobProgress updates the progress of a sync job.
-func (d *DB) UpdateSyncJobProgress(ctx context.Context, id int64, fetched, stored, skipped int64) error {
+func (d *DB) UpdateSyncJobProgress(ctx context.Context, id int64, fetched, stored, skipped, rejected int64) error {
 	_, err := d.AppDB.ExecContext(ctx, `
 		UPDATE sync_jobs
 		SET events_fetched = ?, events_stored = ?, events_skipped = ?
 		WHERE id = ?
 	`, fetched, stored, skipped, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.AppDB.ExecContext(ctx, `
+		INSERT INTO sync_job_verification (sync_job_id, events_rejected) VALUES (?, ?)
+		ON CONFLICT(sync_job_id) DO UPDATE SET events_rejected = excluded.events_rejected
+	`, id, rejected)
 	return err
 }
 
@@ -696,10 +830,13 @@ func (d *DB) GetSyncJob(ctx context.Context, id int64) (*SyncJob, error) {
 
 	err := d.AppDB.QueryRowContext(ctx, `
 		SELECT id, status, pubkeys, relays, event_kinds, since_timestamp, started_at, completed_at,
-		       events_fetched, events_stored, events_skipped, error_message
-		FROM sync_jobs WHERE id = ?
+		       events_fetched, events_stored, events_skipped,
+		       COALESCE(v.events_rejected, 0), COALESCE(v.skip_verification, 0), error_message
+		FROM sync_jobs
+		LEFT JOIN sync_job_verification v ON v.sync_job_id = sync_jobs.id
+		WHERE id = ?
 	`, id).Scan(&job.ID, &job.Status, &pubkeysJSON, &relaysJSON, &kindsJSON, &sinceTimestamp,
-		&startedAt, &completedAt, &job.EventsFetched, &job.EventsStored, &job.EventsSkipped, &errorMsg)
+		&startedAt, &completedAt, &job.EventsFetched, &job.EventsStored, &job.EventsSkipped, &job.EventsRejected, &job.SkipVerification, &errorMsg)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -741,8 +878,10 @@ func (d *DB) GetSyncJobs(ctx context.Context, status string, limit, offset int)
 
 	query := `
 		SELECT id, status, pubkeys, relays, event_kinds, since_timestamp, started_at, completed_at,
-		       events_fetched, events_stored, events_skipped, error_message
+		       events_fetched, events_stored, events_skipped,
+		       COALESCE(v.events_rejected, 0), COALESCE(v.skip_verification, 0), error_message
 		FROM sync_jobs
+		LEFT JOIN sync_job_verification v ON v.sync_job_id = sync_jobs.id
 	`
 	args := []interface{}{}
 
@@ -769,7 +908,7 @@ func (d *DB) GetSyncJobs(ctx context.Context, status string, limit, offset int)
 		var errorMsg sql.NullString
 
 		err := rows.Scan(&job.ID, &job.Status, &pubkeysJSON, &relaysJSON, &kindsJSON, &sinceTimestamp,
-			&startedAt, &completedAt, &job.EventsFetched, &job.EventsStored, &job.EventsSkipped, &errorMsg)
+			&startedAt, &completedAt, &job.EventsFetched, &job.EventsStored, &job.EventsSkipped, &job.EventsRejected, &job.SkipVerification, &errorMsg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan sync job: %w", err)
 		}
@@ -824,6 +963,93 @@ func (d *DB) CancelSyncJob(ctx context.Context, id int64) error {
 	return err
 }
 
+// PauseSyncJob marks a sync job as paused so it can be resumed later.
+func (d *DB) PauseSyncJob(ctx context.Context, id int64, errorMsg string) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE sync_jobs SET status = 'paused', error_message = ? WHERE id = ?
+	`, nullString(errorMsg), id)
+	return err
+}
+
+// ResumeSyncJob marks a paused sync job as running again.
+func (d *DB) ResumeSyncJob(ctx context.Context, id int64) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE sync_jobs SET status = 'running', error_message = NULL
+		WHERE id = ? AND status = 'paused'
+	`, id)
+	return err
+}
+
+// PauseInterruptedSyncJobs marks sync jobs left running by a previous process
+// as paused, so they can be resumed.
+func (d *DB) PauseInterruptedSyncJobs(ctx context.Context) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, `
+		UPDATE sync_jobs SET status = 'paused', error_message = 'interrupted by server restart'
+		WHERE status = 'running'
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SyncCursor records how far back a sync has fetched one pubkey's events
+// from one relay.
+type SyncCursor struct {
+	Relay  string `json:"relay"`
+	Pubkey string `json:"pubkey"`
+	Until  *int64 `json:"until,omitempty"` // oldest created_at fetched so far
+	Done   bool   `json:"done"`
+}
+
+// SaveSyncCursor inserts or updates a sync cursor.
+func (d *DB) SaveSyncCursor(ctx context.Context, syncJobID int64, c SyncCursor) error {
+	var until interface{}
+	if c.Until != nil {
+		until = *c.Until
+	}
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO sync_cursors (sync_job_id, relay, pubkey, until_ts, done)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(sync_job_id, relay, pubkey) DO UPDATE SET
+			until_ts = excluded.until_ts,
+			done = excluded.done
+	`, syncJobID, c.Relay, c.Pubkey, until, c.Done)
+	return err
+}
+
+// GetSyncCursors returns a sync's cursors.
+func (d *DB) GetSyncCursors(ctx context.Context, syncJobID int64) ([]SyncCursor, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT relay, pubkey, until_ts, done FROM sync_cursors
+		WHERE sync_job_id = ? ORDER BY relay, pubkey
+	`, syncJobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cursors := []SyncCursor{}
+	for rows.Next() {
+		var c SyncCursor
+		var until sql.NullInt64
+		if err := rows.Scan(&c.Relay, &c.Pubkey, &until, &c.Done); err != nil {
+			return nil, err
+		}
+		if until.Valid {
+			c.Until = &until.Int64
+		}
+		cursors = append(cursors, c)
+	}
+	return cursors, rows.Err()
+}
+
+// DeleteSyncCursors removes a sync's cursors once it can no longer resume.
+func (d *DB) DeleteSyncCursors(ctx context.Context, syncJobID int64) error {
+	_, err := d.AppDB.ExecContext(ctx, `DELETE FROM sync_cursors WHERE sync_job_id = ?`, syncJobID)
+	return err
+}
+
 // ============================================================================
 // Sync Configuration
 // ============================================================================
@@ -1116,10 +1342,17 @@ func (d *DB) UpdateDeletionRequestStatus(ctx context.Context, id int64, status s
 type RetentionPolicy struct {
 	RetentionDays int64    `json:"retention_days"` // 0 = keep forever
 	Exceptions    []string `json:"exceptions"`     // e.g., ["kind:0", "kind:3", "pubkey:operator"]
+	KindRules     []RetentionRule `json:"kind_rules"` // Override retention_days for these kinds
 	HonorNIP09    bool     `json:"honor_nip09"`
 	LastRun       *time.Time `json:"last_run,omitempty"`
 }
 
+// RetentionRule keeps events of one kind for its own number of days.
+type RetentionRule struct {
+	Kind          int   `json:"kind"`
+	RetentionDays int64 `json:"retention_days"` // 0 = keep forever
+}
+
 // GetRetentionPolicy retrieves the current retention policy settings.
 func (d *DB) GetRetentionPolicy(ctx context.Context) (*RetentionPolicy, error) {
 	policy := &RetentionPolicy{}
@@ -1142,6 +1375,15 @@ func (d *DB) GetRetentionPolicy(ctx context.Context) (*RetentionPolicy, error) {
 		json.Unmarshal([]byte(exceptionsStr), &policy.Exceptions)
 	}
 
+	// Get retention_kind_rules
+	rulesStr, err := d.GetAppState(ctx, "retention_kind_rules")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention_kind_rules: %w", err)
+	}
+	if rulesStr != "" {
+		json.Unmarshal([]byte(rulesStr), &policy.KindRules)
+	}
+
 	// Get honor_nip09
 	honorStr, err := d.GetAppState(ctx, "honor_nip09")
 	if err != nil {
@@ -1179,6 +1421,12 @@ func (d *DB) SetRetentionPolicy(ctx context.Context, policy *RetentionPolicy) er
 		return fmt.Errorf("failed to set retention_exceptions: %w", err)
 	}
 
+	// Set retention_kind_rules
+	rulesJSON, _ := json.Marshal(policy.KindRules)
+	if err := d.SetAppState(ctx, "retention_kind_rules", string(rulesJSON)); err != nil {
+		return fmt.Errorf("failed to set retention_kind_rules: %w", err)
+	}
+
 	// Set honor_nip09
 	honorStr := "true"
 	if !policy.HonorNIP09 {
@@ -1261,155 +1509,653 @@ func (d *DB) RunAppIntegrityCheck(ctx context.Context) (bool, string, error) {
 	return result == "ok", result, nil
 }
 
-// ============================================================================
-// Audit Log
-// ============================================================================
-
-// AddAuditLog adds an entry to the audit log.
-func (d *DB) AddAuditLog(ctx context.Context, action string, details interface{}, performedBy string) error {
-	var detailsJSON []byte
-	if details != nil {
-		detailsJSON, _ = json.Marshal(details)
-	}
+// StorageMaintenanceSchedule controls the automatic VACUUM and integrity
+// checks. Tasks run when due and the local time is inside the quiet window.
+type StorageMaintenanceSchedule struct {
+	Enabled               bool `json:"enabled"`
+	VacuumIntervalDays    int  `json:"vacuum_interval_days"`    // 0 = never
+	IntegrityIntervalDays int  `json:"integrity_interval_days"` // 0 = never
+	WindowStartHour       int  `json:"window_start_hour"`       // 0-23, local time
+	WindowEndHour         int  `json:"window_end_hour"`         // 0-23; before the start wraps past midnight
+}
 
-	_, err := d.AppDB.ExecContext(ctx, `
-		INSERT INTO audit_log (action, details, performed_by) VALUES (?, ?, ?)
-	`, action, nullString(string(detailsJSON)), nullString(performedBy))
-	return err
+// DefaultStorageMaintenanceSchedule runs both tasks weekly between 03:00
+// and 05:00.
+var DefaultStorageMaintenanceSchedule = StorageMaintenanceSchedule{
+	Enabled:               true,
+	VacuumIntervalDays:    7,
+	IntegrityIntervalDays: 7,
+	WindowStartHour:       3,
+	WindowEndHour:         5,
 }
 
-// ============================================================================
-// Pending Invoices
-// ============================================================================
+// GetStorageMaintenanceSchedule returns the maintenance schedule, or the
+// default if none is saved.
+func (d *DB) GetStorageMaintenanceSchedule(ctx context.Context) (*StorageMaintenanceSchedule, error) {
+	schedule := DefaultStorageMaintenanceSchedule
 
-// PendingInvoice represents an unpaid Lightning invoice for relay access.
-type PendingInvoice struct {
-	ID             int64      `json:"id"`
-	PaymentHash    string     `json:"payment_hash"`
-	Pubkey         string     `json:"pubkey"`
-	Npub           string     `json:"npub"`
-	TierID         string     `json:"tier_id"`
-	AmountSats     int64      `json:"amount_sats"`
-	PaymentRequest string     `json:"payment_request"`
-	Memo           string     `json:"memo,omitempty"`
-	Status         string     `json:"status"`
-	CreatedAt      time.Time  `json:"created_at"`
-	ExpiresAt      time.Time  `json:"expires_at"`
-	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	value, err := d.GetAppState(ctx, "storage_maintenance_schedule")
+	if err != nil || value == "" {
+		return &schedule, err
+	}
+	if err := json.Unmarshal([]byte(value), &schedule); err != nil {
+		return nil, fmt.Errorf("failed to parse storage_maintenance_schedule: %w", err)
+	}
+	return &schedule, nil
 }
 
-// CreatePendingInvoice creates a new pending invoice.
-func (d *DB) CreatePendingInvoice(ctx context.Context, invoice *PendingInvoice) error {
-	_, err := d.AppDB.ExecContext(ctx, `
-		INSERT INTO pending_invoices (payment_hash, pubkey, npub, tier_id, amount_sats, payment_request, memo, status, expires_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, 'pending', ?)
-	`, invoice.PaymentHash, invoice.Pubkey, invoice.Npub, invoice.TierID, invoice.AmountSats, invoice.PaymentRequest, nullString(invoice.Memo), invoice.ExpiresAt.Unix())
-	return err
+// SetStorageMaintenanceSchedule saves the maintenance schedule.
+func (d *DB) SetStorageMaintenanceSchedule(ctx context.Context, schedule *StorageMaintenanceSchedule) error {
+	scheduleJSON, _ := json.Marshal(schedule)
+	return d.SetAppState(ctx, "storage_maintenance_schedule", string(scheduleJSON))
 }
 
-// GetPendingInvoice retrieves a pending invoice by payment hash.
-func (d *DB) GetPendingInvoice(ctx context.Context, paymentHash string) (*PendingInvoice, error) {
-	var inv PendingInvoice
-	var memo sql.NullString
-	var createdAt, expiresAt int64
-	var paidAt sql.NullInt64
-
-	err := d.AppDB.QueryRowContext(ctx, `
-		SELECT id, payment_hash, pubkey, npub, tier_id, amount_sats, payment_request, memo, status, created_at, expires_at, paid_at
-		FROM pending_invoices WHERE payment_hash = ?
-	`, paymentHash).Scan(&inv.ID, &inv.PaymentHash, &inv.Pubkey, &inv.Npub, &inv.TierID, &inv.AmountSats, &inv.PaymentRequest, &memo, &inv.Status, &createdAt, &expiresAt, &paidAt)
+// MaintenanceRun is one VACUUM or integrity check of one database.
+type MaintenanceRun struct {
+	ID             int64     `json:"id"`
+	Task           string    `json:"task"`     // vacuum, integrity_check
+	Database       string    `json:"database"` // app, relay
+	Trigger        string    `json:"trigger"`  // scheduled, manual
+	Status         string    `json:"status"`   // ok, failed
+	Result         string    `json:"result,omitempty"`
+	SpaceReclaimed int64     `json:"space_reclaimed"`
+	DurationMs     int64     `json:"duration_ms"`
+	StartedAt      time.Time `json:"started_at"`
+	CompletedAt    time.Time `json:"completed_at"`
+}
 
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+// AddMaintenanceRun records a maintenance run and sets its ID.
+func (d *DB) AddMaintenanceRun(ctx context.Context, run *MaintenanceRun) error {
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO maintenance_history (task, database, trigger, status, result, space_reclaimed, duration_ms, started_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.Task, run.Database, run.Trigger, run.Status, nullString(run.Result), run.SpaceReclaimed, run.DurationMs,
+		run.StartedAt.Unix(), run.CompletedAt.Unix())
 	if err != nil {
-		return nil, err
+		return err
 	}
+	run.ID, err = result.LastInsertId()
+	return err
+}
 
-	inv.Memo = memo.String
-	inv.CreatedAt = time.Unix(createdAt, 0)
-	inv.ExpiresAt = time.Unix(expiresAt, 0)
-	if paidAt.Valid {
-		t := time.Unix(paidAt.Int64, 0)
-		inv.PaidAt = &t
+// GetMaintenanceHistory returns maintenance runs, newest first, optionally
+// of one task, and the total count.
+func (d *DB) GetMaintenanceHistory(ctx context.Context, task string, limit, offset int) ([]MaintenanceRun, int64, error) {
+	where := ""
+	args := []interface{}{}
+	if task != "" {
+		where = " WHERE task = ?"
+		args = append(args, task)
 	}
 
-	return &inv, nil
-}
+	var total int64
+	if err := d.AppDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM maintenance_history`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
 
-// GetPendingInvoicesByPubkey retrieves all pending invoices for a pubkey.
-func (d *DB) GetPendingInvoicesByPubkey(ctx context.Context, pubkey string) ([]PendingInvoice, error) {
 	rows, err := d.AppDB.QueryContext(ctx, `
-		SELECT id, payment_hash, pubkey, npub, tier_id, amount_sats, payment_request, memo, status, created_at, expires_at, paid_at
-		FROM pending_invoices WHERE pubkey = ? ORDER BY created_at DESC
-	`, pubkey)
+		SELECT id, task, database, trigger, status, result, space_reclaimed, duration_ms, started_at, completed_at
+		FROM maintenance_history`+where+`
+		ORDER BY started_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var invoices []PendingInvoice
+	runs := []MaintenanceRun{}
 	for rows.Next() {
-		var inv PendingInvoice
-		var memo sql.NullString
-		var createdAt, expiresAt int64
-		var paidAt sql.NullInt64
-
-		err := rows.Scan(&inv.ID, &inv.PaymentHash, &inv.Pubkey, &inv.Npub, &inv.TierID, &inv.AmountSats, &inv.PaymentRequest, &memo, &inv.Status, &createdAt, &expiresAt, &paidAt)
-		if err != nil {
-			return nil, err
+		var run MaintenanceRun
+		var result sql.NullString
+		var startedAt, completedAt int64
+		if err := rows.Scan(&run.ID, &run.Task, &run.Database, &run.Trigger, &run.Status, &result,
+			&run.SpaceReclaimed, &run.DurationMs, &startedAt, &completedAt); err != nil {
+			return nil, 0, err
 		}
-
-		inv.Memo = memo.String
-		inv.CreatedAt = time.Unix(createdAt, 0)
-		inv.ExpiresAt = time.Unix(expiresAt, 0)
-		if paidAt.Valid {
-			t := time.Unix(paidAt.Int64, 0)
-			inv.PaidAt = &t
-		}
-		invoices = append(invoices, inv)
+		run.Result = result.String
+		run.StartedAt = time.Unix(startedAt, 0)
+		run.CompletedAt = time.Unix(completedAt, 0)
+		runs = append(runs, run)
 	}
+	return runs, total, rows.Err()
+}
 
-	return invoices, rows.Err()
+// StoragePressurePolicy is what Roostr does by itself as the disk fills up.
+// Thresholds are disk usage percentages; 0 turns an action off.
+type StoragePressurePolicy struct {
+	Enabled          bool    `json:"enabled"`
+	RetentionPercent float64 `json:"retention_percent"` // run retention with a tighter window
+	RetentionDays    int64   `json:"retention_days"`    // the tighter window
+	PausePercent     float64 `json:"pause_percent"`     // pause syncs and reject imports
 }
 
-// UpdatePendingInvoiceStatus updates the status of a pending invoice.
-func (d *DB) UpdatePendingInvoiceStatus(ctx context.Context, paymentHash, status string) error {
-	var paidAt interface{}
-	if status == "paid" {
-		paidAt = time.Now().Unix()
+// DefaultStoragePressurePolicy is the policy until the operator saves one:
+// off, with the "low" and "critical" storage levels as thresholds.
+func DefaultStoragePressurePolicy() StoragePressurePolicy {
+	return StoragePressurePolicy{
+		RetentionPercent: 90,
+		RetentionDays:    30,
+		PausePercent:     95,
 	}
+}
 
-	_, err := d.AppDB.ExecContext(ctx, `
-		UPDATE pending_invoices SET status = ?, paid_at = ? WHERE payment_hash = ?
-	`, status, paidAt, paymentHash)
-	return err
+// GetStoragePressurePolicy returns the storage pressure policy.
+func (d *DB) GetStoragePressurePolicy(ctx context.Context) (*StoragePressurePolicy, error) {
+	policy := DefaultStoragePressurePolicy()
+
+	value, err := d.GetAppState(ctx, "storage_pressure_policy")
+	if err != nil || value == "" {
+		return &policy, err
+	}
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse storage_pressure_policy: %w", err)
+	}
+	return &policy, nil
 }
 
-// GetPendingInvoicesAwaitingPayment retrieves all invoices that are still pending and not expired.
-func (d *DB) GetPendingInvoicesAwaitingPayment(ctx context.Context) ([]PendingInvoice, error) {
-	rows, err := d.AppDB.QueryContext(ctx, `
-		SELECT id, payment_hash, pubkey, npub, tier_id, amount_sats, payment_request, memo, status, created_at, expires_at, paid_at
-		FROM pending_invoices
-		WHERE status = 'pending' AND expires_at > strftime('%s', 'now')
-		ORDER BY created_at DESC
-	`)
-	if err != nil {
+// SetStoragePressurePolicy saves the storage pressure policy.
+func (d *DB) SetStoragePressurePolicy(ctx context.Context, policy *StoragePressurePolicy) error {
+	policyJSON, _ := json.Marshal(policy)
+	return d.SetAppState(ctx, "storage_pressure_policy", string(policyJSON))
+}
+
+// GetCachedStorageBreakdown returns the last saved storage breakdown, or nil if
+// none has been computed.
+func (d *DB) GetCachedStorageBreakdown(ctx context.Context) (*StorageBreakdown, error) {
+	value, err := d.GetAppState(ctx, "storage_breakdown")
+	if err != nil || value == "" {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var invoices []PendingInvoice
-	for rows.Next() {
-		var inv PendingInvoice
-		var memo sql.NullString
-		var createdAt, expiresAt int64
-		var paidAt sql.NullInt64
+	var breakdown StorageBreakdown
+	if err := json.Unmarshal([]byte(value), &breakdown); err != nil {
+		return nil, fmt.Errorf("failed to parse storage_breakdown: %w", err)
+	}
+	return &breakdown, nil
+}
 
-		err := rows.Scan(&inv.ID, &inv.PaymentHash, &inv.Pubkey, &inv.Npub, &inv.TierID, &inv.AmountSats, &inv.PaymentRequest, &memo, &inv.Status, &createdAt, &expiresAt, &paidAt)
-		if err != nil {
-			return nil, err
-		}
+// SetCachedStorageBreakdown saves a storage breakdown for later requests.
+func (d *DB) SetCachedStorageBreakdown(ctx context.Context, breakdown *StorageBreakdown) error {
+	breakdownJSON, _ := json.Marshal(breakdown)
+	return d.SetAppState(ctx, "storage_breakdown", string(breakdownJSON))
+}
+
+// ============================================================================
+// Relay Config Baseline
+// ============================================================================
+
+// ConfigBaseline is the last config.toml Roostr wrote or accepted. Changes to
+// the file since are manual edits.
+type ConfigBaseline struct {
+	SHA256  string    `json:"sha256"`
+	Content string    `json:"content"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// GetConfigBaseline returns the config baseline, or nil if none is saved.
+func (d *DB) GetConfigBaseline(ctx context.Context) (*ConfigBaseline, error) {
+	value, err := d.GetAppState(ctx, "config_baseline")
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var baseline ConfigBaseline
+	if err := json.Unmarshal([]byte(value), &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse config_baseline: %w", err)
+	}
+	return &baseline, nil
+}
+
+// SetConfigBaseline saves the config baseline.
+func (d *DB) SetConfigBaseline(ctx context.Context, baseline *ConfigBaseline) error {
+	baselineJSON, _ := json.Marshal(baseline)
+	return d.SetAppState(ctx, "config_baseline", string(baselineJSON))
+}
+
+// ============================================================================
+// Config History
+// ============================================================================
+
+// maxConfigVersions is how many config versions are kept.
+const maxConfigVersions = 100
+
+// ConfigVersion is a config.toml Roostr wrote.
+type ConfigVersion struct {
+	ID        int64     `json:"id"`
+	SHA256    string    `json:"sha256"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason,omitempty"`
+	Size      int64     `json:"size"`
+	Content   string    `json:"content,omitempty"` // only for a single version
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddConfigVersion records a config write, unless it is the same as the
+// latest version. Only the newest maxConfigVersions are kept.
+func (d *DB) AddConfigVersion(ctx context.Context, content, actor, reason string) error {
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+
+	var latest string
+	err := d.AppDB.QueryRowContext(ctx, "SELECT sha256 FROM config_history ORDER BY id DESC LIMIT 1").Scan(&latest)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if latest == hash {
+		return nil
+	}
+
+	if _, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO config_history (content, sha256, actor, reason) VALUES (?, ?, ?, ?)
+	`, content, hash, actor, nullString(reason)); err != nil {
+		return err
+	}
+	_, err = d.AppDB.ExecContext(ctx, `
+		DELETE FROM config_history WHERE id NOT IN (
+			SELECT id FROM config_history ORDER BY id DESC LIMIT ?
+		)
+	`, maxConfigVersions)
+	return err
+}
+
+// GetConfigVersions returns config versions, newest first, without their
+// content, and the total number of versions.
+func (d *DB) GetConfigVersions(ctx context.Context, limit, offset int) ([]ConfigVersion, int64, error) {
+	var total int64
+	if err := d.AppDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM config_history").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT id, sha256, actor, reason, LENGTH(content), created_at
+		FROM config_history ORDER BY id DESC LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	versions := []ConfigVersion{}
+	for rows.Next() {
+		var v ConfigVersion
+		var reason sql.NullString
+		var createdAt int64
+		if err := rows.Scan(&v.ID, &v.SHA256, &v.Actor, &reason, &v.Size, &createdAt); err != nil {
+			return nil, 0, err
+		}
+		v.Reason = reason.String
+		v.CreatedAt = time.Unix(createdAt, 0)
+		versions = append(versions, v)
+	}
+	return versions, total, rows.Err()
+}
+
+// GetConfigVersion returns a config version with its content, or nil if it
+// does not exist.
+func (d *DB) GetConfigVersion(ctx context.Context, id int64) (*ConfigVersion, error) {
+	var v ConfigVersion
+	var reason sql.NullString
+	var createdAt int64
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT id, sha256, actor, reason, LENGTH(content), content, created_at
+		FROM config_history WHERE id = ?
+	`, id).Scan(&v.ID, &v.SHA256, &v.Actor, &reason, &v.Size, &v.Content, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	v.Reason = reason.String
+	v.CreatedAt = time.Unix(createdAt, 0)
+	return &v, nil
+}
+
+// ============================================================================
+// Audit Log
+// ============================================================================
+
+// AddAuditLog adds an entry to the audit log.
+func (d *DB) AddAuditLog(ctx context.Context, action string, details interface{}, performedBy string) error {
+	var detailsJSON []byte
+	if details != nil {
+		detailsJSON, _ = json.Marshal(details)
+	}
+
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO audit_log (action, details, performed_by) VALUES (?, ?, ?)
+	`, action, nullString(string(detailsJSON)), nullString(performedBy))
+	return err
+}
+
+// AuditEntry is an entry in the audit log.
+type AuditEntry struct {
+	ID          int64           `json:"id"`
+	Action      string          `json:"action"`
+	Details     json.RawMessage `json:"details,omitempty"`
+	PerformedBy string          `json:"performed_by,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AuditLogFilter selects audit log entries. Zero fields match everything.
+type AuditLogFilter struct {
+	Action      string
+	PerformedBy string
+	Query       string   // substring of the details
+	Pubkeys     []string // forms of one pubkey, e.g. hex and npub; matches entries it performed or appears in
+	Since       time.Time
+	Until       time.Time
+	Limit       int
+	Offset      int
+}
+
+// GetAuditLog returns audit log entries, newest first, and how many match
+// the filter in total. A negative limit returns every match.
+func (d *DB) GetAuditLog(ctx context.Context, filter AuditLogFilter) ([]AuditEntry, int64, error) {
+	where := "WHERE 1=1"
+	var args []interface{}
+	if filter.Action != "" {
+		where += " AND action = ?"
+		args = append(args, filter.Action)
+	}
+	if filter.PerformedBy != "" {
+		where += " AND performed_by = ?"
+		args = append(args, filter.PerformedBy)
+	}
+	if filter.Query != "" {
+		where += " AND instr(details, ?) > 0"
+		args = append(args, filter.Query)
+	}
+	if len(filter.Pubkeys) > 0 {
+		var conds []string
+		for _, pk := range filter.Pubkeys {
+			conds = append(conds, "performed_by = ?", "instr(details, ?) > 0")
+			args = append(args, pk, pk)
+		}
+		where += " AND (" + strings.Join(conds, " OR ") + ")"
+	}
+	if !filter.Since.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, filter.Since.Unix())
+	}
+	if !filter.Until.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, filter.Until.Unix())
+	}
+
+	var total int64
+	if err := d.AppDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = 50
+	}
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT id, action, details, performed_by, created_at
+		FROM audit_log `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var details, performedBy sql.NullString
+		var createdAt int64
+		if err := rows.Scan(&e.ID, &e.Action, &details, &performedBy, &createdAt); err != nil {
+			return nil, 0, err
+		}
+		if details.Valid && json.Valid([]byte(details.String)) {
+			e.Details = json.RawMessage(details.String)
+		}
+		e.PerformedBy = performedBy.String
+		e.CreatedAt = time.Unix(createdAt, 0)
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// DeleteAuditLogBefore removes entries recorded before cutoff and returns
+// how many were removed.
+func (d *DB) DeleteAuditLogBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, `DELETE FROM audit_log WHERE created_at < ?`, cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// AuditSettings controls how long the audit log is kept.
+type AuditSettings struct {
+	RetentionDays int `json:"retention_days"` // 0 keeps entries forever
+}
+
+// DefaultAuditSettings returns the audit settings used until they are saved.
+func DefaultAuditSettings() AuditSettings {
+	return AuditSettings{RetentionDays: 365}
+}
+
+// GetAuditSettings returns the audit settings.
+func (d *DB) GetAuditSettings(ctx context.Context) (*AuditSettings, error) {
+	settings := DefaultAuditSettings()
+
+	value, err := d.GetAppState(ctx, "audit_settings")
+	if err != nil || value == "" {
+		return &settings, err
+	}
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse audit_settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetAuditSettings saves the audit settings.
+func (d *DB) SetAuditSettings(ctx context.Context, settings *AuditSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "audit_settings", string(settingsJSON))
+}
+
+// PrivacySettings controls what the admin API shows of members' events.
+type PrivacySettings struct {
+	// RedactDMs withholds the content of encrypted DMs (EncryptedDMKinds)
+	// from event listings, search and exports
+	RedactDMs bool `json:"redact_dms"`
+}
+
+// GetPrivacySettings returns the privacy settings.
+func (d *DB) GetPrivacySettings(ctx context.Context) (*PrivacySettings, error) {
+	settings := &PrivacySettings{}
+
+	value, err := d.GetAppState(ctx, "privacy_settings")
+	if err != nil || value == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(value), settings); err != nil {
+		return nil, fmt.Errorf("failed to parse privacy_settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SetPrivacySettings saves the privacy settings.
+func (d *DB) SetPrivacySettings(ctx context.Context, settings *PrivacySettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "privacy_settings", string(settingsJSON))
+}
+
+// ExpirationStats records the deletion of events past their NIP-40
+// expiration, and the ephemeral and expired events writes refused.
+type ExpirationStats struct {
+	LastRunAt        *time.Time `json:"last_run_at"`
+	LastDeleted      int64      `json:"last_deleted"`
+	TotalDeleted     int64      `json:"total_deleted"`
+	RefusedEphemeral int64      `json:"refused_ephemeral"` // Ephemeral events syncs, imports and migrations did not store
+	RefusedExpired   int64      `json:"refused_expired"`   // Events already expired when they were written
+}
+
+// GetExpirationStats returns the expired event deletion counts.
+func (d *DB) GetExpirationStats(ctx context.Context) (*ExpirationStats, error) {
+	stats := &ExpirationStats{}
+
+	value, err := d.GetAppState(ctx, "expiration_stats")
+	if err != nil || value == "" {
+		return stats, err
+	}
+	if err := json.Unmarshal([]byte(value), stats); err != nil {
+		return nil, fmt.Errorf("failed to parse expiration_stats: %w", err)
+	}
+	return stats, nil
+}
+
+// RecordExpirationRun adds a run that deleted n expired events to the
+// counts.
+func (d *DB) RecordExpirationRun(ctx context.Context, at time.Time, n int64) error {
+	d.expirationStatsMu.Lock()
+	defer d.expirationStatsMu.Unlock()
+
+	stats, err := d.GetExpirationStats(ctx)
+	if err != nil {
+		stats = &ExpirationStats{}
+	}
+	at = at.UTC()
+	stats.LastRunAt = &at
+	stats.LastDeleted = n
+	stats.TotalDeleted += n
+
+	statsJSON, _ := json.Marshal(stats)
+	return d.SetAppState(ctx, "expiration_stats", string(statsJSON))
+}
+
+// RecordRefusedEvents adds ephemeral and expired events a relay writer
+// refused to the counts.
+func (d *DB) RecordRefusedEvents(ctx context.Context, ephemeral, expired int64) error {
+	d.expirationStatsMu.Lock()
+	defer d.expirationStatsMu.Unlock()
+
+	stats, err := d.GetExpirationStats(ctx)
+	if err != nil {
+		stats = &ExpirationStats{}
+	}
+	stats.RefusedEphemeral += ephemeral
+	stats.RefusedExpired += expired
+
+	statsJSON, _ := json.Marshal(stats)
+	return d.SetAppState(ctx, "expiration_stats", string(statsJSON))
+}
+
+// ============================================================================
+// Pending Invoices
+// ============================================================================
+
+// PendingInvoice represents an unpaid Lightning invoice for relay access.
+type PendingInvoice struct {
+	ID             int64      `json:"id"`
+	PaymentHash    string     `json:"payment_hash"`
+	Pubkey         string     `json:"pubkey"`
+	Npub           string     `json:"npub"`
+	TierID         string     `json:"tier_id"`
+	AmountSats     int64      `json:"amount_sats"`
+	PaymentRequest string     `json:"payment_request"`
+	Memo           string     `json:"memo,omitempty"`
+	Status         string     `json:"status"`
+	Source         string     `json:"source"` // signup or admin
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+}
+
+// Pending invoice sources.
+const (
+	InvoiceSourceSignup = "signup"
+	InvoiceSourceAdmin  = "admin"
+)
+
+// pendingInvoiceColumns selects a pending invoice with its source, which is
+// only recorded for invoices that did not come from a signup.
+const pendingInvoiceColumns = `id, payment_hash, pubkey, npub, tier_id, amount_sats, payment_request, memo, status,
+		COALESCE((SELECT source FROM pending_invoice_sources s WHERE s.payment_hash = pending_invoices.payment_hash), 'signup'),
+		created_at, expires_at, paid_at`
+
+// CreatePendingInvoice creates a new pending invoice.
+func (d *DB) CreatePendingInvoice(ctx context.Context, invoice *PendingInvoice) error {
+	tx, err := d.AppDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO pending_invoices (payment_hash, pubkey, npub, tier_id, amount_sats, payment_request, memo, status, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 'pending', ?)
+	`, invoice.PaymentHash, invoice.Pubkey, invoice.Npub, invoice.TierID, invoice.AmountSats, invoice.PaymentRequest, nullString(invoice.Memo), invoice.ExpiresAt.Unix()); err != nil {
+		return err
+	}
+	if invoice.Source != "" && invoice.Source != InvoiceSourceSignup {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO pending_invoice_sources (payment_hash, source) VALUES (?, ?)
+		`, invoice.PaymentHash, invoice.Source); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetPendingInvoice retrieves a pending invoice by payment hash.
+func (d *DB) GetPendingInvoice(ctx context.Context, paymentHash string) (*PendingInvoice, error) {
+	var inv PendingInvoice
+	var memo sql.NullString
+	var createdAt, expiresAt int64
+	var paidAt sql.NullInt64
+
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT `+pendingInvoiceColumns+`
+		FROM pending_invoices WHERE payment_hash = ?
+	`, paymentHash).Scan(&inv.ID, &inv.PaymentHash, &inv.Pubkey, &inv.Npub, &inv.TierID, &inv.AmountSats, &inv.PaymentRequest, &memo, &inv.Status, &inv.Source, &createdAt, &expiresAt, &paidAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inv.Memo = memo.String
+	inv.CreatedAt = time.Unix(createdAt, 0)
+	inv.ExpiresAt = time.Unix(expiresAt, 0)
+	if paidAt.Valid {
+		t := time.Unix(paidAt.Int64, 0)
+		inv.PaidAt = &t
+	}
+
+	return &inv, nil
+}
+
+// GetPendingInvoicesByPubkey retrieves all pending invoices for a pubkey.
+func (d *DB) GetPendingInvoicesByPubkey(ctx context.Context, pubkey string) ([]PendingInvoice, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+pendingInvoiceColumns+`
+		FROM pending_invoices WHERE pubkey = ? ORDER BY created_at DESC
+	`, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []PendingInvoice
+	for rows.Next() {
+		var inv PendingInvoice
+		var memo sql.NullString
+		var createdAt, expiresAt int64
+		var paidAt sql.NullInt64
+
+		err := rows.Scan(&inv.ID, &inv.PaymentHash, &inv.Pubkey, &inv.Npub, &inv.TierID, &inv.AmountSats, &inv.PaymentRequest, &memo, &inv.Status, &inv.Source, &createdAt, &expiresAt, &paidAt)
+		if err != nil {
+			return nil, err
+		}
 
 		inv.Memo = memo.String
 		inv.CreatedAt = time.Unix(createdAt, 0)
@@ -1418,122 +2164,4743 @@ func (d *DB) GetPendingInvoicesAwaitingPayment(ctx context.Context) ([]PendingIn
 			t := time.Unix(paidAt.Int64, 0)
 			inv.PaidAt = &t
 		}
-		invoices = append(invoices, inv)
+		invoices = append(invoices, inv)
+	}
+
+	return invoices, rows.Err()
+}
+
+// GetOpenPendingInvoice returns the newest unpaid, unexpired invoice for a
+// pubkey and tier, or nil if there is none.
+func (d *DB) GetOpenPendingInvoice(ctx context.Context, pubkey, tierID string) (*PendingInvoice, error) {
+	var paymentHash string
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT payment_hash FROM pending_invoices
+		WHERE pubkey = ? AND tier_id = ? AND status = 'pending' AND expires_at > strftime('%s', 'now')
+		ORDER BY created_at DESC, id DESC LIMIT 1
+	`, pubkey, tierID).Scan(&paymentHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.GetPendingInvoice(ctx, paymentHash)
+}
+
+// UpdatePendingInvoiceStatus updates the status of a pending invoice.
+func (d *DB) UpdatePendingInvoiceStatus(ctx context.Context, paymentHash, status string) error {
+	var paidAt interface{}
+	if status == "paid" {
+		paidAt = time.Now().Unix()
+	}
+
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE pending_invoices SET status = ?, paid_at = ? WHERE payment_hash = ?
+	`, status, paidAt, paymentHash)
+	return err
+}
+
+// CancelPendingInvoice marks an invoice cancelled if it is still pending. It
+// reports whether it was.
+func (d *DB) CancelPendingInvoice(ctx context.Context, paymentHash string) (bool, error) {
+	result, err := d.AppDB.ExecContext(ctx, `
+		UPDATE pending_invoices SET status = 'cancelled' WHERE payment_hash = ? AND status = 'pending'
+	`, paymentHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetPendingInvoicesAwaitingPayment retrieves all invoices that are still pending and not expired.
+func (d *DB) GetPendingInvoicesAwaitingPayment(ctx context.Context) ([]PendingInvoice, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+pendingInvoiceColumns+`
+		FROM pending_invoices
+		WHERE status = 'pending' AND expires_at > strftime('%s', 'now')
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []PendingInvoice
+	for rows.Next() {
+		var inv PendingInvoice
+		var memo sql.NullString
+		var createdAt, expiresAt int64
+		var paidAt sql.NullInt64
+
+		err := rows.Scan(&inv.ID, &inv.PaymentHash, &inv.Pubkey, &inv.Npub, &inv.TierID, &inv.AmountSats, &inv.PaymentRequest, &memo, &inv.Status, &inv.Source, &createdAt, &expiresAt, &paidAt)
+		if err != nil {
+			return nil, err
+		}
+
+		inv.Memo = memo.String
+		inv.CreatedAt = time.Unix(createdAt, 0)
+		inv.ExpiresAt = time.Unix(expiresAt, 0)
+		if paidAt.Valid {
+			t := time.Unix(paidAt.Int64, 0)
+			inv.PaidAt = &t
+		}
+		invoices = append(invoices, inv)
+	}
+
+	return invoices, rows.Err()
+}
+
+// ExpirePendingInvoices marks expired invoices as expired and returns them.
+func (d *DB) ExpirePendingInvoices(ctx context.Context) ([]PendingInvoice, error) {
+	tx, err := d.AppDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+pendingInvoiceColumns+`
+		FROM pending_invoices
+		WHERE status = 'pending' AND expires_at < ?
+		ORDER BY created_at
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	var invoices []PendingInvoice
+	for rows.Next() {
+		var inv PendingInvoice
+		var memo sql.NullString
+		var createdAt, expiresAt int64
+		var paidAt sql.NullInt64
+
+		if err := rows.Scan(&inv.ID, &inv.PaymentHash, &inv.Pubkey, &inv.Npub, &inv.TierID, &inv.AmountSats, &inv.PaymentRequest, &memo, &inv.Status, &inv.Source, &createdAt, &expiresAt, &paidAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		inv.Memo = memo.String
+		inv.Status = "expired"
+		inv.CreatedAt = time.Unix(createdAt, 0)
+		inv.ExpiresAt = time.Unix(expiresAt, 0)
+		invoices = append(invoices, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE pending_invoices SET status = 'expired'
+		WHERE status = 'pending' AND expires_at < ?
+	`, now); err != nil {
+		return nil, err
+	}
+	return invoices, tx.Commit()
+}
+
+// ============================================================================
+// Lightning Configuration
+// ============================================================================
+
+// LightningConfig represents the Lightning node configuration.
+type LightningConfig struct {
+	NodeType       string     `json:"node_type"`        // 'lnd' or 'cln'
+	Endpoint       string     `json:"endpoint"`         // e.g., "umbrel.local:8080"
+	Macaroon       string     `json:"macaroon"`         // hex-encoded macaroon
+	Cert           string     `json:"cert,omitempty"`   // optional TLS cert
+	Enabled        bool       `json:"enabled"`
+	LastVerifiedAt *time.Time `json:"last_verified_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// GetLightningConfig retrieves the Lightning node configuration.
+func (d *DB) GetLightningConfig(ctx context.Context) (*LightningConfig, error) {
+	var cfg LightningConfig
+	var nodeType, endpoint, macaroon, cert sql.NullString
+	var enabled int
+	var lastVerifiedAt, updatedAt sql.NullInt64
+
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT node_type, endpoint, macaroon, cert, enabled, last_verified_at, updated_at
+		FROM lightning_config WHERE id = 1
+	`).Scan(&nodeType, &endpoint, &macaroon, &cert, &enabled, &lastVerifiedAt, &updatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.NodeType = nodeType.String
+	cfg.Endpoint = endpoint.String
+	if cfg.Macaroon, err = d.vault.Open(secretLightningMacaroon, macaroon.String); err != nil {
+		return nil, err
+	}
+	if cfg.Cert, err = d.vault.Open(secretLightningCert, cert.String); err != nil {
+		return nil, err
+	}
+	cfg.Enabled = enabled == 1
+	if lastVerifiedAt.Valid {
+		t := time.Unix(lastVerifiedAt.Int64, 0)
+		cfg.LastVerifiedAt = &t
+	}
+	if updatedAt.Valid {
+		cfg.UpdatedAt = time.Unix(updatedAt.Int64, 0)
+	}
+
+	return &cfg, nil
+}
+
+// SaveLightningConfig saves or updates the Lightning node configuration.
+// The macaroon and cert are encrypted once a secrets passphrase is set.
+func (d *DB) SaveLightningConfig(ctx context.Context, cfg *LightningConfig) error {
+	macaroon, err := d.vault.Seal(secretLightningMacaroon, cfg.Macaroon)
+	if err != nil {
+		return err
+	}
+	cert, err := d.vault.Seal(secretLightningCert, cfg.Cert)
+	if err != nil {
+		return err
+	}
+
+	var lastVerifiedAt interface{}
+	if cfg.LastVerifiedAt != nil {
+		lastVerifiedAt = cfg.LastVerifiedAt.Unix()
+	}
+
+	enabled := 0
+	if cfg.Enabled {
+		enabled = 1
+	}
+
+	_, err = d.AppDB.ExecContext(ctx, `
+		INSERT INTO lightning_config (id, node_type, endpoint, macaroon, cert, enabled, last_verified_at, updated_at)
+		VALUES (1, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'))
+		ON CONFLICT(id) DO UPDATE SET
+			node_type = excluded.node_type,
+			endpoint = excluded.endpoint,
+			macaroon = excluded.macaroon,
+			cert = excluded.cert,
+			enabled = excluded.enabled,
+			last_verified_at = excluded.last_verified_at,
+			updated_at = excluded.updated_at
+	`, cfg.NodeType, nullString(cfg.Endpoint), nullString(macaroon), nullString(cert), enabled, lastVerifiedAt)
+	return err
+}
+
+// SetLightningEnabled enables or disables the Lightning integration.
+func (d *DB) SetLightningEnabled(ctx context.Context, enabled bool) error {
+	enabledInt := 0
+	if enabled {
+		enabledInt = 1
+	}
+
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE lightning_config SET enabled = ?, updated_at = strftime('%s', 'now') WHERE id = 1
+	`, enabledInt)
+	return err
+}
+
+// SetLightningVerified updates the last verified timestamp.
+func (d *DB) SetLightningVerified(ctx context.Context) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE lightning_config SET last_verified_at = strftime('%s', 'now'), updated_at = strftime('%s', 'now') WHERE id = 1
+	`)
+	return err
+}
+
+// ============================================================================
+// Secrets
+// ============================================================================
+
+// Stored credentials encrypted by the secrets vault, named by table and column.
+const (
+	secretLightningMacaroon = "lightning_config.macaroon"
+	secretLightningCert     = "lightning_config.cert"
+	secretOperatorKey       = "app_state.operator_signing_key"
+	secretRemoteSigner      = "app_state.remote_signer"
+	secretSMTPSettings      = "app_state.smtp_settings"
+	secretNotificationKey   = "app_state.notification_key"
+	secretBackupDestination = "backup_targets.destination"
+	secretExportDestination = "export_schedules.destination"
+)
+
+// Secrets returns the vault that encrypts stored credentials.
+func (d *DB) Secrets() *secrets.Vault {
+	return d.vault
+}
+
+// LoadSecrets reads the saved key derivation parameters into the vault,
+// leaving it locked.
+func (d *DB) LoadSecrets(ctx context.Context) error {
+	value, err := d.GetAppState(ctx, "secrets_kdf")
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		d.vault.Load(nil)
+		return nil
+	}
+
+	var params secrets.Params
+	if err := json.Unmarshal([]byte(value), &params); err != nil {
+		return fmt.Errorf("failed to parse secrets_kdf: %w", err)
+	}
+	d.vault.Load(&params)
+	return nil
+}
+
+// SetupSecrets sets the secrets passphrase, unlocks the vault and encrypts
+// the credentials already stored. It returns how many values were encrypted.
+func (d *DB) SetupSecrets(ctx context.Context, passphrase string) (int, error) {
+	params, err := d.vault.Setup(passphrase)
+	if err != nil {
+		return 0, err
+	}
+	paramsJSON, _ := json.Marshal(params)
+	if err := d.SetAppState(ctx, "secrets_kdf", string(paramsJSON)); err != nil {
+		d.vault.Load(nil)
+		return 0, err
+	}
+	return d.EncryptStoredSecrets(ctx)
+}
+
+// EncryptStoredSecrets encrypts credentials still stored in plaintext, such
+// as those saved before the passphrase was set or restored from an old
+// backup. It returns how many values were encrypted.
+func (d *DB) EncryptStoredSecrets(ctx context.Context) (int, error) {
+	stored, err := d.plaintextSecrets(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	encrypted := 0
+	for _, s := range stored {
+		sealed, err := d.vault.Seal(s.field, s.value)
+		if err != nil {
+			return encrypted, err
+		}
+		if !secrets.IsEncrypted(sealed) {
+			continue // No passphrase set
+		}
+		if _, err := d.AppDB.ExecContext(ctx, s.update, sealed); err != nil {
+			return encrypted, err
+		}
+		encrypted++
+	}
+	return encrypted, nil
+}
+
+// CountPlaintextSecrets returns how many stored credentials are not
+// encrypted.
+func (d *DB) CountPlaintextSecrets(ctx context.Context) (int, error) {
+	stored, err := d.plaintextSecrets(ctx)
+	return len(stored), err
+}
+
+// storedSecret is a stored credential value and the statement that
+// replaces it.
+type storedSecret struct {
+	update string
+	field  string
+	value  string
+}
+
+// plaintextSecrets returns the stored credentials that are not encrypted.
+func (d *DB) plaintextSecrets(ctx context.Context) ([]storedSecret, error) {
+	var macaroon, cert sql.NullString
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT macaroon, cert FROM lightning_config WHERE id = 1
+	`).Scan(&macaroon, &cert)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	operatorKey, err := d.GetAppState(ctx, "operator_signing_key")
+	if err != nil {
+		return nil, err
+	}
+	remoteSigner, err := d.GetAppState(ctx, "remote_signer")
+	if err != nil {
+		return nil, err
+	}
+	smtpSettings, err := d.GetAppState(ctx, "smtp_settings")
+	if err != nil {
+		return nil, err
+	}
+	notificationKey, err := d.GetAppState(ctx, "notification_key")
+	if err != nil {
+		return nil, err
+	}
+
+	stored := []storedSecret{
+		{"UPDATE lightning_config SET macaroon = ? WHERE id = 1", secretLightningMacaroon, macaroon.String},
+		{"UPDATE lightning_config SET cert = ? WHERE id = 1", secretLightningCert, cert.String},
+		{"UPDATE app_state SET value = ? WHERE key = 'operator_signing_key'", secretOperatorKey, operatorKey},
+		{"UPDATE app_state SET value = ? WHERE key = 'remote_signer'", secretRemoteSigner, remoteSigner},
+		{"UPDATE app_state SET value = ? WHERE key = 'smtp_settings'", secretSMTPSettings, smtpSettings},
+		{"UPDATE app_state SET value = ? WHERE key = 'notification_key'", secretNotificationKey, notificationKey},
+	}
+
+	for _, table := range []struct{ name, field string }{
+		{"backup_targets", secretBackupDestination},
+		{"export_schedules", secretExportDestination},
+	} {
+		rows, err := d.AppDB.QueryContext(ctx, `SELECT id, destination FROM `+table.name)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var destination string
+			if err := rows.Scan(&id, &destination); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			stored = append(stored, storedSecret{
+				fmt.Sprintf("UPDATE %s SET destination = ? WHERE id = %d", table.name, id), table.field, destination,
+			})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var plain []storedSecret
+	for _, s := range stored {
+		if s.value != "" && !secrets.IsEncrypted(s.value) {
+			plain = append(plain, s)
+		}
+	}
+	return plain, nil
+}
+
+// ============================================================================
+// Signup Widget
+// ============================================================================
+
+// GetSignupAllowedOrigins returns the origins allowed to call /public/* endpoints
+// cross-origin. An empty list means any origin is allowed.
+func (d *DB) GetSignupAllowedOrigins(ctx context.Context) ([]string, error) {
+	value, err := d.GetAppState(ctx, "signup_allowed_origins")
+	if err != nil {
+		return nil, err
+	}
+
+	origins := []string{}
+	if value != "" {
+		if err := json.Unmarshal([]byte(value), &origins); err != nil {
+			return nil, fmt.Errorf("failed to parse signup_allowed_origins: %w", err)
+		}
+	}
+	return origins, nil
+}
+
+// SetSignupAllowedOrigins saves the origins allowed to call /public/* endpoints.
+func (d *DB) SetSignupAllowedOrigins(ctx context.Context, origins []string) error {
+	if origins == nil {
+		origins = []string{}
+	}
+	originsJSON, _ := json.Marshal(origins)
+	return d.SetAppState(ctx, "signup_allowed_origins", string(originsJSON))
+}
+
+// ============================================================================
+// Admin CORS and CSRF
+// ============================================================================
+
+// GetAdminAllowedOrigins returns the origins allowed to call /api/* endpoints
+// cross-origin. An empty list allows none; the UI is served same-origin.
+func (d *DB) GetAdminAllowedOrigins(ctx context.Context) ([]string, error) {
+	value, err := d.GetAppState(ctx, "admin_allowed_origins")
+	if err != nil {
+		return nil, err
+	}
+
+	origins := []string{}
+	if value != "" {
+		if err := json.Unmarshal([]byte(value), &origins); err != nil {
+			return nil, fmt.Errorf("failed to parse admin_allowed_origins: %w", err)
+		}
+	}
+	return origins, nil
+}
+
+// SetAdminAllowedOrigins saves the origins allowed to call /api/* endpoints.
+func (d *DB) SetAdminAllowedOrigins(ctx context.Context, origins []string) error {
+	if origins == nil {
+		origins = []string{}
+	}
+	originsJSON, _ := json.Marshal(origins)
+	return d.SetAppState(ctx, "admin_allowed_origins", string(originsJSON))
+}
+
+// GetCSRFSecret returns the key CSRF tokens are signed with, generating it
+// on first use.
+func (d *DB) GetCSRFSecret(ctx context.Context) ([]byte, error) {
+	value, err := d.GetAppState(ctx, "csrf_secret")
+	if err != nil {
+		return nil, err
+	}
+	if secret, err := hex.DecodeString(value); err == nil && len(secret) == 32 {
+		return secret, nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	// Keep the first secret saved if another request raced this one
+	if _, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO app_state (key, value) VALUES ('csrf_secret', ?)
+		ON CONFLICT(key) DO NOTHING
+	`, hex.EncodeToString(secret)); err != nil {
+		return nil, err
+	}
+	value, err = d.GetAppState(ctx, "csrf_secret")
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(value)
+}
+
+// ============================================================================
+// Relay Information
+// ============================================================================
+
+// RelayInfoSettings holds the NIP-11 fields that have no place in
+// config.toml. Name, description, pubkey, contact and icon live in its [info]
+// section.
+type RelayInfoSettings struct {
+	Banner         string   `json:"banner"`
+	PaymentsURL    string   `json:"payments_url"`
+	PostingPolicy  string   `json:"posting_policy"`
+	PrivacyPolicy  string   `json:"privacy_policy"`
+	TermsOfService string   `json:"terms_of_service"`
+	RelayCountries []string `json:"relay_countries"`
+	LanguageTags   []string `json:"language_tags"`
+	Tags           []string `json:"tags"`
+	SupportedNIPs  []int    `json:"supported_nips"` // Overrides the relay's own list if not empty
+}
+
+// GetRelayInfoSettings returns the extra NIP-11 fields, all empty if none are
+// saved.
+func (d *DB) GetRelayInfoSettings(ctx context.Context) (*RelayInfoSettings, error) {
+	settings := &RelayInfoSettings{
+		RelayCountries: []string{},
+		LanguageTags:   []string{},
+		Tags:           []string{},
+		SupportedNIPs:  []int{},
+	}
+
+	value, err := d.GetAppState(ctx, "relay_info")
+	if err != nil || value == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(value), settings); err != nil {
+		return nil, fmt.Errorf("failed to parse relay_info: %w", err)
+	}
+	return settings, nil
+}
+
+// SetRelayInfoSettings saves the extra NIP-11 fields.
+func (d *DB) SetRelayInfoSettings(ctx context.Context, settings *RelayInfoSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "relay_info", string(settingsJSON))
+}
+
+// ============================================================================
+// Data Residency
+// ============================================================================
+
+// ResidencyPurge records the last time excluded kinds were purged from the relay DB.
+type ResidencyPurge struct {
+	At      time.Time     `json:"at"`
+	Deleted int64         `json:"deleted"`
+	ByKind  map[int]int64 `json:"by_kind,omitempty"`
+}
+
+// GetExcludedKinds returns the event kinds that must never be stored on this relay.
+func (d *DB) GetExcludedKinds(ctx context.Context) ([]int, error) {
+	value, err := d.GetAppState(ctx, "excluded_kinds")
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := []int{}
+	if value != "" {
+		if err := json.Unmarshal([]byte(value), &kinds); err != nil {
+			return nil, fmt.Errorf("failed to parse excluded_kinds: %w", err)
+		}
+	}
+	return kinds, nil
+}
+
+// SetExcludedKinds saves the event kinds that must never be stored.
+func (d *DB) SetExcludedKinds(ctx context.Context, kinds []int) error {
+	if kinds == nil {
+		kinds = []int{}
+	}
+	kindsJSON, _ := json.Marshal(kinds)
+	return d.SetAppState(ctx, "excluded_kinds", string(kindsJSON))
+}
+
+// GetLastResidencyPurge returns the most recent purge, or nil if none has run.
+func (d *DB) GetLastResidencyPurge(ctx context.Context) (*ResidencyPurge, error) {
+	value, err := d.GetAppState(ctx, "residency_last_purge")
+	if err != nil || value == "" {
+		return nil, err
+	}
+
+	var purge ResidencyPurge
+	if err := json.Unmarshal([]byte(value), &purge); err != nil {
+		return nil, fmt.Errorf("failed to parse residency_last_purge: %w", err)
+	}
+	return &purge, nil
+}
+
+// SetLastResidencyPurge records a purge of excluded kinds.
+func (d *DB) SetLastResidencyPurge(ctx context.Context, purge ResidencyPurge) error {
+	purgeJSON, _ := json.Marshal(purge)
+	return d.SetAppState(ctx, "residency_last_purge", string(purgeJSON))
+}
+
+// ============================================================================
+// Backups
+// ============================================================================
+
+// BackupSchedule controls automatic full backups to the backup directory.
+type BackupSchedule struct {
+	Enabled   bool   `json:"enabled"`
+	Frequency string `json:"frequency"` // daily, weekly
+	Hour      int    `json:"hour"`      // 0-23, local time
+	Weekday   int    `json:"weekday"`   // 0 (Sunday) - 6, for weekly backups
+	Retain    int    `json:"retain"`    // Saved backups to keep; older ones are deleted
+	Encrypt   bool   `json:"encrypt"`   // Encrypt with the backup passphrase
+}
+
+// BackupRunRecord describes the last scheduled backup.
+type BackupRunRecord struct {
+	At             time.Time `json:"at"`
+	Status         string    `json:"status"` // ok, partial (saved, but an upload failed), failed
+	Name           string    `json:"name,omitempty"`
+	Size           int64     `json:"size,omitempty"`
+	Deleted        int       `json:"deleted"`         // Old backups removed by rotation
+	Uploaded       int       `json:"uploaded"`        // Backup targets it was uploaded to
+	UploadFailures int       `json:"upload_failures"` // Backup targets the upload failed for
+	Error          string    `json:"error,omitempty"`
+}
+
+// GetBackupSchedule returns the backup schedule, disabled with daily backups
+// at 02:00 and seven kept if none is saved.
+func (d *DB) GetBackupSchedule(ctx context.Context) (*BackupSchedule, error) {
+	schedule := &BackupSchedule{Frequency: "daily", Hour: 2, Retain: 7}
+
+	value, err := d.GetAppState(ctx, "backup_schedule")
+	if err != nil || value == "" {
+		return schedule, err
+	}
+	if err := json.Unmarshal([]byte(value), schedule); err != nil {
+		return nil, fmt.Errorf("failed to parse backup_schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// SetBackupSchedule saves the backup schedule.
+func (d *DB) SetBackupSchedule(ctx context.Context, schedule *BackupSchedule) error {
+	scheduleJSON, _ := json.Marshal(schedule)
+	return d.SetAppState(ctx, "backup_schedule", string(scheduleJSON))
+}
+
+// GetBackupPassphrase returns the passphrase scheduled backups are encrypted
+// with, or "".
+func (d *DB) GetBackupPassphrase(ctx context.Context) (string, error) {
+	return d.GetAppState(ctx, "backup_passphrase")
+}
+
+// SetBackupPassphrase saves the passphrase scheduled backups are encrypted
+// with.
+func (d *DB) SetBackupPassphrase(ctx context.Context, passphrase string) error {
+	return d.SetAppState(ctx, "backup_passphrase", passphrase)
+}
+
+// GetLastScheduledBackup returns the last scheduled backup, or nil if none.
+func (d *DB) GetLastScheduledBackup(ctx context.Context) (*BackupRunRecord, error) {
+	value, err := d.GetAppState(ctx, "backup_last_scheduled")
+	if err != nil || value == "" {
+		return nil, err
+	}
+
+	var record BackupRunRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, fmt.Errorf("failed to parse backup_last_scheduled: %w", err)
+	}
+	return &record, nil
+}
+
+// SetLastScheduledBackup records a scheduled backup.
+func (d *DB) SetLastScheduledBackup(ctx context.Context, record BackupRunRecord) error {
+	recordJSON, _ := json.Marshal(record)
+	return d.SetAppState(ctx, "backup_last_scheduled", string(recordJSON))
+}
+
+// BackupTarget is remote storage full backups are uploaded to.
+type BackupTarget struct {
+	ID           int64             `json:"id"`
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Destination  ExportDestination `json:"destination"`
+	Retain       int               `json:"retain"` // Uploaded backups to keep; 0 keeps all
+	Enabled      bool              `json:"enabled"`
+	LastUploadAt *time.Time        `json:"last_upload_at,omitempty"`
+	LastStatus   string            `json:"last_status,omitempty"`
+	LastError    string            `json:"last_error,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// BackupUpload records one backup uploaded to a target.
+type BackupUpload struct {
+	ID        int64     `json:"id"`
+	TargetID  int64     `json:"target_id"`
+	Name      string    `json:"name"`
+	Location  string    `json:"location,omitempty"`
+	Size      int64     `json:"size"`
+	Status    string    `json:"status"` // completed, failed, pruned
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const backupTargetColumns = `id, name, type, destination, retain, enabled, last_upload_at, last_status, last_error,
+		created_at, updated_at`
+
+const backupUploadColumns = `id, target_id, name, location, size, status, error, created_at`
+
+// CreateBackupTarget inserts a target and sets its ID. The destination holds
+// the target's credentials and is encrypted when a secrets passphrase is set.
+func (d *DB) CreateBackupTarget(ctx context.Context, t *BackupTarget) error {
+	destJSON, err := d.sealBackupDestination(t.Destination)
+	if err != nil {
+		return err
+	}
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO backup_targets (name, type, destination, retain, enabled)
+		VALUES (?, ?, ?, ?, ?)
+	`, t.Name, t.Type, destJSON, t.Retain, t.Enabled)
+	if err != nil {
+		return err
+	}
+	t.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetBackupTargets returns all backup targets.
+func (d *DB) GetBackupTargets(ctx context.Context) ([]BackupTarget, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+backupTargetColumns+` FROM backup_targets ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return d.scanBackupTargets(rows)
+}
+
+// GetBackupTarget returns a target by ID, or nil if it does not exist.
+func (d *DB) GetBackupTarget(ctx context.Context, id int64) (*BackupTarget, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+backupTargetColumns+` FROM backup_targets WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets, err := d.scanBackupTargets(rows)
+	if err != nil || len(targets) == 0 {
+		return nil, err
+	}
+	return &targets[0], nil
+}
+
+// UpdateBackupTarget saves the target's configuration fields.
+// Upload state is only changed by AddBackupUpload.
+func (d *DB) UpdateBackupTarget(ctx context.Context, t *BackupTarget) error {
+	destJSON, err := d.sealBackupDestination(t.Destination)
+	if err != nil {
+		return err
+	}
+	_, err = d.AppDB.ExecContext(ctx, `
+		UPDATE backup_targets
+		SET name = ?, type = ?, destination = ?, retain = ?, enabled = ?, updated_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, t.Name, t.Type, destJSON, t.Retain, t.Enabled, t.ID)
+	return err
+}
+
+// DeleteBackupTarget removes a target and its upload records.
+// Backups already uploaded are left in place.
+func (d *DB) DeleteBackupTarget(ctx context.Context, id int64) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM backup_uploads WHERE target_id = ?`, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM backup_targets WHERE id = ?`, id)
+		return err
+	})
+}
+
+// AddBackupUpload records an upload, sets its ID and stores its outcome as
+// the target's last upload.
+func (d *DB) AddBackupUpload(ctx context.Context, u *BackupUpload) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO backup_uploads (target_id, name, location, size, status, error)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, u.TargetID, u.Name, nullString(u.Location), u.Size, u.Status, nullString(u.Error))
+		if err != nil {
+			return err
+		}
+		if u.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE backup_targets
+			SET last_upload_at = strftime('%s', 'now'), last_status = ?, last_error = ?
+			WHERE id = ?
+		`, u.Status, nullString(u.Error), u.TargetID)
+		return err
+	})
+}
+
+// GetBackupUploads returns the most recent uploads to a target.
+func (d *DB) GetBackupUploads(ctx context.Context, targetID int64, limit int) ([]BackupUpload, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+backupUploadColumns+`
+		FROM backup_uploads
+		WHERE target_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, targetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanBackupUploads(rows)
+}
+
+// GetPrunableBackupUploads returns completed uploads older than the newest keep.
+func (d *DB) GetPrunableBackupUploads(ctx context.Context, targetID int64, keep int) ([]BackupUpload, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+backupUploadColumns+`
+		FROM backup_uploads
+		WHERE target_id = ? AND status = 'completed'
+		ORDER BY id DESC
+		LIMIT -1 OFFSET ?
+	`, targetID, keep)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanBackupUploads(rows)
+}
+
+// MarkBackupUploadPruned records that an uploaded backup was deleted.
+func (d *DB) MarkBackupUploadPruned(ctx context.Context, id int64) error {
+	_, err := d.AppDB.ExecContext(ctx, `UPDATE backup_uploads SET status = 'pruned' WHERE id = ?`, id)
+	return err
+}
+
+// sealBackupDestination encodes a target's destination for storage.
+func (d *DB) sealBackupDestination(dest ExportDestination) (string, error) {
+	return d.sealDestination(secretBackupDestination, dest)
+}
+
+// sealDestination encodes a destination for storage in the given field,
+// encrypting its credentials along with the rest of the settings.
+func (d *DB) sealDestination(field string, dest ExportDestination) (string, error) {
+	destJSON, _ := json.Marshal(dest)
+	return d.vault.Seal(field, string(destJSON))
+}
+
+func (d *DB) scanBackupTargets(rows *sql.Rows) ([]BackupTarget, error) {
+	targets := []BackupTarget{}
+	for rows.Next() {
+		var t BackupTarget
+		var destJSON string
+		var createdAt, updatedAt int64
+		var lastUpload sql.NullInt64
+		var lastStatus, lastError sql.NullString
+
+		if err := rows.Scan(&t.ID, &t.Name, &t.Type, &destJSON, &t.Retain, &t.Enabled, &lastUpload,
+			&lastStatus, &lastError, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+
+		destJSON, err := d.vault.Open(secretBackupDestination, destJSON)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(destJSON), &t.Destination)
+		if lastUpload.Valid {
+			at := time.Unix(lastUpload.Int64, 0)
+			t.LastUploadAt = &at
+		}
+		t.LastStatus = lastStatus.String
+		t.LastError = lastError.String
+		t.CreatedAt = time.Unix(createdAt, 0)
+		t.UpdatedAt = time.Unix(updatedAt, 0)
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+func scanBackupUploads(rows *sql.Rows) ([]BackupUpload, error) {
+	uploads := []BackupUpload{}
+	for rows.Next() {
+		var u BackupUpload
+		var location, errMsg sql.NullString
+		var createdAt int64
+
+		if err := rows.Scan(&u.ID, &u.TargetID, &u.Name, &location, &u.Size, &u.Status, &errMsg, &createdAt); err != nil {
+			return nil, err
+		}
+
+		u.Location = location.String
+		u.Error = errMsg.String
+		u.CreatedAt = time.Unix(createdAt, 0)
+		uploads = append(uploads, u)
+	}
+	return uploads, rows.Err()
+}
+
+// ============================================================================
+// Nostr Backup
+// ============================================================================
+
+// NostrBackupSettings controls publishing encrypted backups to Nostr relays.
+type NostrBackupSettings struct {
+	Enabled       bool     `json:"enabled"`
+	Relays        []string `json:"relays"`         // Empty means the sync relays
+	IntervalHours int      `json:"interval_hours"` // How often to check for changes
+}
+
+// NostrBackupRelayResult is the outcome of publishing a backup to one relay.
+type NostrBackupRelayResult struct {
+	URL   string `json:"url"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// NostrBackupRecord describes the last published backup.
+type NostrBackupRecord struct {
+	At      time.Time                `json:"at"`
+	EventID string                   `json:"event_id"`
+	Hash    string                   `json:"hash"` // SHA-256 of the backup contents
+	Members int                      `json:"members"`
+	Relays  []NostrBackupRelayResult `json:"relays"`
+}
+
+// GetNostrBackupSettings returns the backup settings, disabled with a daily
+// interval if none are saved.
+func (d *DB) GetNostrBackupSettings(ctx context.Context) (*NostrBackupSettings, error) {
+	settings := &NostrBackupSettings{Relays: []string{}, IntervalHours: 24}
+
+	value, err := d.GetAppState(ctx, "nostr_backup_settings")
+	if err != nil || value == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(value), settings); err != nil {
+		return nil, fmt.Errorf("failed to parse nostr_backup_settings: %w", err)
+	}
+	if settings.Relays == nil {
+		settings.Relays = []string{}
+	}
+	return settings, nil
+}
+
+// SetNostrBackupSettings saves the backup settings.
+func (d *DB) SetNostrBackupSettings(ctx context.Context, settings *NostrBackupSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "nostr_backup_settings", string(settingsJSON))
+}
+
+// GetNostrBackupKey returns the secret key backups are signed with, or "".
+func (d *DB) GetNostrBackupKey(ctx context.Context) (string, error) {
+	return d.GetAppState(ctx, "nostr_backup_key")
+}
+
+// SetNostrBackupKey saves the secret key backups are signed with.
+func (d *DB) SetNostrBackupKey(ctx context.Context, secretHex string) error {
+	return d.SetAppState(ctx, "nostr_backup_key", secretHex)
+}
+
+// GetLastNostrBackup returns the last published backup, or nil if none.
+func (d *DB) GetLastNostrBackup(ctx context.Context) (*NostrBackupRecord, error) {
+	value, err := d.GetAppState(ctx, "nostr_backup_last")
+	if err != nil || value == "" {
+		return nil, err
+	}
+
+	var record NostrBackupRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, fmt.Errorf("failed to parse nostr_backup_last: %w", err)
+	}
+	return &record, nil
+}
+
+// SetLastNostrBackup records a published backup.
+func (d *DB) SetLastNostrBackup(ctx context.Context, record NostrBackupRecord) error {
+	recordJSON, _ := json.Marshal(record)
+	return d.SetAppState(ctx, "nostr_backup_last", string(recordJSON))
+}
+
+// ============================================================================
+// Relay Announcement
+// ============================================================================
+
+// RelayAnnouncementSettings controls publishing the relay's discovery
+// listing and adding it to the operator's relay list.
+type RelayAnnouncementSettings struct {
+	Enabled       bool     `json:"enabled"`
+	RelayURL      string   `json:"relay_url"`      // Empty means relay_url from config.toml
+	Relays        []string `json:"relays"`         // Where to publish; empty means the sync relays
+	Geohash       string   `json:"geohash"`        // Location of the relay, for discovery by region
+	RelayList     bool     `json:"relay_list"`     // Add the relay to the operator's kind 10002 list
+	IntervalHours int      `json:"interval_hours"` // How often to refresh the listing
+}
+
+// RelayAnnouncementResult is the outcome of publishing to one relay.
+type RelayAnnouncementResult struct {
+	URL   string `json:"url"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// RelayAnnouncementRecord describes the last published announcement.
+type RelayAnnouncementRecord struct {
+	At             time.Time                 `json:"at"`
+	RelayURL       string                    `json:"relay_url"`
+	AnnouncementID string                    `json:"announcement_id"`
+	RelayListID    string                    `json:"relay_list_id,omitempty"` // Empty if the list was not changed
+	RelayListError string                    `json:"relay_list_error,omitempty"`
+	Hash           string                    `json:"hash"` // SHA-256 of the announcement contents
+	Relays         []RelayAnnouncementResult `json:"relays"`
+}
+
+// GetRelayAnnouncementSettings returns the announcement settings, disabled
+// with a daily refresh if none are saved.
+func (d *DB) GetRelayAnnouncementSettings(ctx context.Context) (*RelayAnnouncementSettings, error) {
+	settings := &RelayAnnouncementSettings{Relays: []string{}, RelayList: true, IntervalHours: 24}
+
+	value, err := d.GetAppState(ctx, "relay_announcement_settings")
+	if err != nil || value == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(value), settings); err != nil {
+		return nil, fmt.Errorf("failed to parse relay_announcement_settings: %w", err)
+	}
+	if settings.Relays == nil {
+		settings.Relays = []string{}
+	}
+	return settings, nil
+}
+
+// SetRelayAnnouncementSettings saves the announcement settings.
+func (d *DB) SetRelayAnnouncementSettings(ctx context.Context, settings *RelayAnnouncementSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "relay_announcement_settings", string(settingsJSON))
+}
+
+// GetLastRelayAnnouncement returns the last published announcement, or nil
+// if none.
+func (d *DB) GetLastRelayAnnouncement(ctx context.Context) (*RelayAnnouncementRecord, error) {
+	value, err := d.GetAppState(ctx, "relay_announcement_last")
+	if err != nil || value == "" {
+		return nil, err
+	}
+
+	var record RelayAnnouncementRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, fmt.Errorf("failed to parse relay_announcement_last: %w", err)
+	}
+	return &record, nil
+}
+
+// SetLastRelayAnnouncement records a published announcement.
+func (d *DB) SetLastRelayAnnouncement(ctx context.Context, record RelayAnnouncementRecord) error {
+	recordJSON, _ := json.Marshal(record)
+	return d.SetAppState(ctx, "relay_announcement_last", string(recordJSON))
+}
+
+// ============================================================================
+// Mention Notifications
+// ============================================================================
+
+// MentionSettings controls the mention notification service.
+type MentionSettings struct {
+	Enabled bool `json:"enabled"`
+}
+
+// MentionSubscription is a member's mention notification preferences.
+type MentionSubscription struct {
+	Pubkey         string     `json:"pubkey"`
+	Enabled        bool       `json:"enabled"`
+	Channel        string     `json:"channel"` // dm, email
+	Email          string     `json:"email,omitempty"`
+	QuietStart     string     `json:"quiet_start,omitempty"` // HH:MM
+	QuietEnd       string     `json:"quiet_end,omitempty"`   // HH:MM
+	Timezone       string     `json:"timezone"`
+	BatchMinutes   int        `json:"batch_minutes"`
+	LastNotifiedAt *time.Time `json:"last_notified_at,omitempty"`
+	Pending        int64      `json:"pending"` // Queued mentions not yet sent
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// QueuedMention is a stored event that mentions a subscribed member.
+type QueuedMention struct {
+	Recipient string    `json:"recipient"`
+	EventID   string    `json:"event_id"`
+	Author    string    `json:"author"`
+	Kind      int       `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetMentionSettings returns the mention notification settings, disabled if
+// none are saved.
+func (d *DB) GetMentionSettings(ctx context.Context) (*MentionSettings, error) {
+	settings := &MentionSettings{}
+
+	value, err := d.GetAppState(ctx, "mention_settings")
+	if err != nil || value == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(value), settings); err != nil {
+		return nil, fmt.Errorf("failed to parse mention_settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SetMentionSettings saves the mention notification settings.
+func (d *DB) SetMentionSettings(ctx context.Context, settings *MentionSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "mention_settings", string(settingsJSON))
+}
+
+// GetMentionCursor returns the last relay event row ID scanned for mentions,
+// or 0 if scanning has not started.
+func (d *DB) GetMentionCursor(ctx context.Context) (int64, error) {
+	value, err := d.GetAppState(ctx, "mention_cursor")
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// SetMentionCursor saves the last relay event row ID scanned for mentions.
+func (d *DB) SetMentionCursor(ctx context.Context, rowID int64) error {
+	return d.SetAppState(ctx, "mention_cursor", strconv.FormatInt(rowID, 10))
+}
+
+// GetNotificationKey returns the secret key notifications are signed with, or "".
+func (d *DB) GetNotificationKey(ctx context.Context) (string, error) {
+	value, err := d.GetAppState(ctx, "notification_key")
+	if err != nil || value == "" {
+		return "", err
+	}
+	return d.vault.Open(secretNotificationKey, value)
+}
+
+// SetNotificationKey saves the secret key notifications are signed with,
+// encrypted when a secrets passphrase is set.
+func (d *DB) SetNotificationKey(ctx context.Context, secretHex string) error {
+	sealed, err := d.vault.Seal(secretNotificationKey, secretHex)
+	if err != nil {
+		return err
+	}
+	return d.SetAppState(ctx, "notification_key", sealed)
+}
+
+const mentionSubscriptionColumns = `s.pubkey, s.enabled, s.channel, s.email, s.quiet_start, s.quiet_end,
+		s.timezone, s.batch_minutes, s.last_notified_at, s.created_at, s.updated_at,
+		(SELECT COUNT(*) FROM mention_queue q WHERE q.recipient = s.pubkey)`
+
+// GetMentionSubscriptions returns all mention subscriptions.
+func (d *DB) GetMentionSubscriptions(ctx context.Context) ([]MentionSubscription, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+mentionSubscriptionColumns+`
+		FROM mention_subscriptions s
+		ORDER BY s.created_at, s.pubkey
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []MentionSubscription{}
+	for rows.Next() {
+		sub, err := scanMentionSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+// GetMentionSubscription returns a member's subscription, or nil if none.
+func (d *DB) GetMentionSubscription(ctx context.Context, pubkey string) (*MentionSubscription, error) {
+	row := d.AppDB.QueryRowContext(ctx, `
+		SELECT `+mentionSubscriptionColumns+`
+		FROM mention_subscriptions s
+		WHERE s.pubkey = ?
+	`, pubkey)
+	sub, err := scanMentionSubscription(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// SaveMentionSubscription creates or replaces a member's preferences.
+func (d *DB) SaveMentionSubscription(ctx context.Context, sub *MentionSubscription) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO mention_subscriptions (pubkey, enabled, channel, email, quiet_start, quiet_end, timezone, batch_minutes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(pubkey) DO UPDATE SET
+			enabled = excluded.enabled,
+			channel = excluded.channel,
+			email = excluded.email,
+			quiet_start = excluded.quiet_start,
+			quiet_end = excluded.quiet_end,
+			timezone = excluded.timezone,
+			batch_minutes = excluded.batch_minutes,
+			updated_at = strftime('%s', 'now')
+	`, sub.Pubkey, sub.Enabled, sub.Channel, nullString(sub.Email), nullString(sub.QuietStart),
+		nullString(sub.QuietEnd), sub.Timezone, sub.BatchMinutes)
+	return err
+}
+
+// DeleteMentionSubscription removes a member's subscription and queued mentions.
+func (d *DB) DeleteMentionSubscription(ctx context.Context, pubkey string) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM mention_queue WHERE recipient = ?`, pubkey); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM mention_subscriptions WHERE pubkey = ?`, pubkey)
+		return err
+	})
+}
+
+// SetMentionNotified records when a member was last sent a notification.
+func (d *DB) SetMentionNotified(ctx context.Context, pubkey string, at time.Time) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE mention_subscriptions SET last_notified_at = ? WHERE pubkey = ?
+	`, at.Unix(), pubkey)
+	return err
+}
+
+// QueueMention stores a mention for the next summary. It reports false if the
+// event was already queued for the member.
+func (d *DB) QueueMention(ctx context.Context, m QueuedMention) (bool, error) {
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT OR IGNORE INTO mention_queue (recipient, event_id, author, kind, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, m.Recipient, m.EventID, m.Author, m.Kind, m.CreatedAt.Unix())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// GetQueuedMentions returns a member's queued mentions, oldest first.
+func (d *DB) GetQueuedMentions(ctx context.Context, recipient string) ([]QueuedMention, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT recipient, event_id, author, kind, created_at
+		FROM mention_queue
+		WHERE recipient = ?
+		ORDER BY created_at, event_id
+	`, recipient)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []QueuedMention{}
+	for rows.Next() {
+		var m QueuedMention
+		var createdAt int64
+		if err := rows.Scan(&m.Recipient, &m.EventID, &m.Author, &m.Kind, &createdAt); err != nil {
+			return nil, err
+		}
+		m.CreatedAt = time.Unix(createdAt, 0)
+		mentions = append(mentions, m)
+	}
+	return mentions, rows.Err()
+}
+
+// DeleteQueuedMentions removes delivered mentions from a member's queue.
+func (d *DB) DeleteQueuedMentions(ctx context.Context, recipient string, eventIDs []string) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		for _, id := range eventIDs {
+			if _, err := tx.ExecContext(ctx, `
+				DELETE FROM mention_queue WHERE recipient = ? AND event_id = ?
+			`, recipient, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func scanMentionSubscription(row interface{ Scan(...interface{}) error }) (*MentionSubscription, error) {
+	var sub MentionSubscription
+	var email, quietStart, quietEnd sql.NullString
+	var lastNotified sql.NullInt64
+	var createdAt, updatedAt int64
+
+	if err := row.Scan(&sub.Pubkey, &sub.Enabled, &sub.Channel, &email, &quietStart, &quietEnd,
+		&sub.Timezone, &sub.BatchMinutes, &lastNotified, &createdAt, &updatedAt, &sub.Pending); err != nil {
+		return nil, err
+	}
+
+	sub.Email = email.String
+	sub.QuietStart = quietStart.String
+	sub.QuietEnd = quietEnd.String
+	if lastNotified.Valid {
+		t := time.Unix(lastNotified.Int64, 0)
+		sub.LastNotifiedAt = &t
+	}
+	sub.CreatedAt = time.Unix(createdAt, 0)
+	sub.UpdatedAt = time.Unix(updatedAt, 0)
+	return &sub, nil
+}
+
+// ============================================================================
+// Maintenance
+// ============================================================================
+
+// RelayStatusOverride is an operational state set by the operator that
+// applies until it is changed back to "ok".
+type RelayStatusOverride struct {
+	State   string    `json:"state"` // ok, maintenance, read-only
+	Message string    `json:"message,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+// MaintenanceWindow is a scheduled period of maintenance or read-only operation.
+type MaintenanceWindow struct {
+	ID          int64      `json:"id"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	Mode        string     `json:"mode"` // maintenance, read-only
+	Message     string     `json:"message,omitempty"`
+	AnnouncedAt *time.Time `json:"announced_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// GetRelayStatusOverride returns the operator-set state, "ok" if none is saved.
+func (d *DB) GetRelayStatusOverride(ctx context.Context) (*RelayStatusOverride, error) {
+	status := &RelayStatusOverride{State: "ok"}
+
+	value, err := d.GetAppState(ctx, "relay_status")
+	if err != nil || value == "" {
+		return status, err
+	}
+	if err := json.Unmarshal([]byte(value), status); err != nil {
+		return nil, fmt.Errorf("failed to parse relay_status: %w", err)
+	}
+	return status, nil
+}
+
+// SetRelayStatusOverride saves the operator-set state.
+func (d *DB) SetRelayStatusOverride(ctx context.Context, status *RelayStatusOverride) error {
+	statusJSON, _ := json.Marshal(status)
+	return d.SetAppState(ctx, "relay_status", string(statusJSON))
+}
+
+const maintenanceWindowColumns = `id, starts_at, ends_at, mode, message, announced_at, created_at`
+
+// CreateMaintenanceWindow inserts a maintenance window and sets its ID.
+func (d *DB) CreateMaintenanceWindow(ctx context.Context, mw *MaintenanceWindow) error {
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO maintenance_windows (starts_at, ends_at, mode, message)
+		VALUES (?, ?, ?, ?)
+	`, mw.StartsAt.Unix(), mw.EndsAt.Unix(), mw.Mode, nullString(mw.Message))
+	if err != nil {
+		return err
+	}
+	mw.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetMaintenanceWindows returns the windows that end after the given time,
+// soonest first.
+func (d *DB) GetMaintenanceWindows(ctx context.Context, endingAfter time.Time) ([]MaintenanceWindow, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+maintenanceWindowColumns+`
+		FROM maintenance_windows
+		WHERE ends_at > ?
+		ORDER BY starts_at, id
+	`, endingAfter.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		mw, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, *mw)
+	}
+	return windows, rows.Err()
+}
+
+// GetMaintenanceWindow returns a window by ID, or nil if it does not exist.
+func (d *DB) GetMaintenanceWindow(ctx context.Context, id int64) (*MaintenanceWindow, error) {
+	row := d.AppDB.QueryRowContext(ctx, `SELECT `+maintenanceWindowColumns+` FROM maintenance_windows WHERE id = ?`, id)
+	mw, err := scanMaintenanceWindow(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return mw, err
+}
+
+// DeleteMaintenanceWindow removes a maintenance window.
+func (d *DB) DeleteMaintenanceWindow(ctx context.Context, id int64) error {
+	_, err := d.AppDB.ExecContext(ctx, `DELETE FROM maintenance_windows WHERE id = ?`, id)
+	return err
+}
+
+// SetMaintenanceAnnounced records when a window was announced.
+func (d *DB) SetMaintenanceAnnounced(ctx context.Context, id int64, at time.Time) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE maintenance_windows SET announced_at = ? WHERE id = ?
+	`, at.Unix(), id)
+	return err
+}
+
+func scanMaintenanceWindow(row interface{ Scan(...interface{}) error }) (*MaintenanceWindow, error) {
+	var mw MaintenanceWindow
+	var startsAt, endsAt, createdAt int64
+	var message sql.NullString
+	var announcedAt sql.NullInt64
+
+	if err := row.Scan(&mw.ID, &startsAt, &endsAt, &mw.Mode, &message, &announcedAt, &createdAt); err != nil {
+		return nil, err
+	}
+
+	mw.StartsAt = time.Unix(startsAt, 0)
+	mw.EndsAt = time.Unix(endsAt, 0)
+	mw.Message = message.String
+	if announcedAt.Valid {
+		t := time.Unix(announcedAt.Int64, 0)
+		mw.AnnouncedAt = &t
+	}
+	mw.CreatedAt = time.Unix(createdAt, 0)
+	return &mw, nil
+}
+
+// ============================================================================
+// Profiles
+// ============================================================================
+
+// Profile is cached kind-0 metadata for a pubkey. A profile with no Source
+// records a lookup that found nothing.
+type Profile struct {
+	Pubkey         string     `json:"pubkey"`
+	Name           string     `json:"name,omitempty"`
+	DisplayName    string     `json:"display_name,omitempty"`
+	Picture        string     `json:"picture,omitempty"`
+	Nip05          string     `json:"nip05,omitempty"`
+	Source         string     `json:"source,omitempty"` // local, remote
+	EventCreatedAt *time.Time `json:"event_created_at,omitempty"`
+	FetchedAt      time.Time  `json:"fetched_at"`
+}
+
+const profileColumns = `pubkey, name, display_name, picture, nip05, source, event_created_at, fetched_at`
+
+// GetProfile returns the cached profile for a pubkey, or nil if none.
+func (d *DB) GetProfile(ctx context.Context, pubkey string) (*Profile, error) {
+	row := d.AppDB.QueryRowContext(ctx, `SELECT `+profileColumns+` FROM profiles WHERE pubkey = ?`, pubkey)
+	p, err := scanProfile(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// GetProfiles returns every cached profile keyed by pubkey.
+func (d *DB) GetProfiles(ctx context.Context) (map[string]*Profile, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+profileColumns+` FROM profiles`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := make(map[string]*Profile)
+	for rows.Next() {
+		p, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles[p.Pubkey] = p
+	}
+	return profiles, rows.Err()
+}
+
+// SaveProfile inserts or replaces a cached profile.
+func (d *DB) SaveProfile(ctx context.Context, p *Profile) error {
+	var eventCreatedAt interface{}
+	if p.EventCreatedAt != nil {
+		eventCreatedAt = p.EventCreatedAt.Unix()
+	}
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO profiles (pubkey, name, display_name, picture, nip05, source, event_created_at, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(pubkey) DO UPDATE SET
+			name = excluded.name,
+			display_name = excluded.display_name,
+			picture = excluded.picture,
+			nip05 = excluded.nip05,
+			source = excluded.source,
+			event_created_at = excluded.event_created_at,
+			fetched_at = excluded.fetched_at
+	`, p.Pubkey, nullString(p.Name), nullString(p.DisplayName), nullString(p.Picture), nullString(p.Nip05),
+		nullString(p.Source), eventCreatedAt, p.FetchedAt.Unix())
+	return err
+}
+
+func scanProfile(row interface{ Scan(...interface{}) error }) (*Profile, error) {
+	var p Profile
+	var name, displayName, picture, nip05, source sql.NullString
+	var eventCreatedAt sql.NullInt64
+	var fetchedAt int64
+
+	if err := row.Scan(&p.Pubkey, &name, &displayName, &picture, &nip05, &source, &eventCreatedAt, &fetchedAt); err != nil {
+		return nil, err
+	}
+
+	p.Name = name.String
+	p.DisplayName = displayName.String
+	p.Picture = picture.String
+	p.Nip05 = nip05.String
+	p.Source = source.String
+	if eventCreatedAt.Valid {
+		t := time.Unix(eventCreatedAt.Int64, 0)
+		p.EventCreatedAt = &t
+	}
+	p.FetchedAt = time.Unix(fetchedAt, 0)
+	return &p, nil
+}
+
+// ============================================================================
+// Mirror Coverage
+// ============================================================================
+
+// MirrorCoverage compares a mirrored member's recent events on public relays
+// with the events stored locally.
+type MirrorCoverage struct {
+	Pubkey     string         `json:"pubkey"`
+	Sampled    int            `json:"sampled"`
+	Present    int            `json:"present"`
+	Coverage   *float64       `json:"coverage"` // percent; nil if nothing was sampled
+	Relays     map[string]int `json:"relays"`
+	MissingIDs []string       `json:"missing_ids"`
+	Since      time.Time      `json:"since"`
+	CheckedAt  time.Time      `json:"checked_at"`
+}
+
+const mirrorCoverageColumns = `pubkey, sampled, present, relays, missing_ids, since, checked_at`
+
+// GetMirrorCoverage returns the last coverage check for a pubkey, or nil if
+// none.
+func (d *DB) GetMirrorCoverage(ctx context.Context, pubkey string) (*MirrorCoverage, error) {
+	row := d.AppDB.QueryRowContext(ctx, `SELECT `+mirrorCoverageColumns+` FROM mirror_coverage WHERE pubkey = ?`, pubkey)
+	c, err := scanMirrorCoverage(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// GetMirrorCoverages returns the last coverage check for every pubkey keyed
+// by pubkey.
+func (d *DB) GetMirrorCoverages(ctx context.Context) (map[string]*MirrorCoverage, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+mirrorCoverageColumns+` FROM mirror_coverage`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coverages := make(map[string]*MirrorCoverage)
+	for rows.Next() {
+		c, err := scanMirrorCoverage(rows)
+		if err != nil {
+			return nil, err
+		}
+		coverages[c.Pubkey] = c
+	}
+	return coverages, rows.Err()
+}
+
+// SaveMirrorCoverage inserts or replaces a pubkey's coverage check.
+func (d *DB) SaveMirrorCoverage(ctx context.Context, c *MirrorCoverage) error {
+	relays, err := json.Marshal(c.Relays)
+	if err != nil {
+		return err
+	}
+	missing, err := json.Marshal(c.MissingIDs)
+	if err != nil {
+		return err
+	}
+	_, err = d.AppDB.ExecContext(ctx, `
+		INSERT INTO mirror_coverage (pubkey, sampled, present, relays, missing_ids, since, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(pubkey) DO UPDATE SET
+			sampled = excluded.sampled,
+			present = excluded.present,
+			relays = excluded.relays,
+			missing_ids = excluded.missing_ids,
+			since = excluded.since,
+			checked_at = excluded.checked_at
+	`, c.Pubkey, c.Sampled, c.Present, string(relays), string(missing), c.Since.Unix(), c.CheckedAt.Unix())
+	return err
+}
+
+// DeleteMirrorCoverage removes a pubkey's coverage check, e.g. when it is no
+// longer mirrored.
+func (d *DB) DeleteMirrorCoverage(ctx context.Context, pubkey string) error {
+	_, err := d.AppDB.ExecContext(ctx, `DELETE FROM mirror_coverage WHERE pubkey = ?`, pubkey)
+	return err
+}
+
+func scanMirrorCoverage(row interface{ Scan(...interface{}) error }) (*MirrorCoverage, error) {
+	var c MirrorCoverage
+	var relays, missing sql.NullString
+	var since, checkedAt int64
+
+	if err := row.Scan(&c.Pubkey, &c.Sampled, &c.Present, &relays, &missing, &since, &checkedAt); err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal([]byte(relays.String), &c.Relays)
+	if c.Relays == nil {
+		c.Relays = map[string]int{}
+	}
+	json.Unmarshal([]byte(missing.String), &c.MissingIDs)
+	if c.MissingIDs == nil {
+		c.MissingIDs = []string{}
+	}
+	c.Since = time.Unix(since, 0)
+	c.CheckedAt = time.Unix(checkedAt, 0)
+	c.SetCoverage()
+	return &c, nil
+}
+
+// SetCoverage computes Coverage from Sampled and Present.
+func (c *MirrorCoverage) SetCoverage() {
+	c.Coverage = nil
+	if c.Sampled > 0 {
+		pct := float64(c.Present) * 100 / float64(c.Sampled)
+		c.Coverage = &pct
+	}
+}
+
+// ============================================================================
+// Broadcast Jobs
+// ============================================================================
+
+// BroadcastRelayResult counts a relay's responses to a broadcast.
+type BroadcastRelayResult struct {
+	OK        int64  `json:"ok"`
+	Duplicate int64  `json:"duplicate"`
+	Error     int64  `json:"error"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// BroadcastJob is a push of local events to remote relays.
+type BroadcastJob struct {
+	ID           int64                            `json:"id"`
+	JobID        int64                            `json:"job_id"`
+	Status       string                           `json:"status"`
+	Authors      []string                         `json:"authors"`
+	Kinds        []int                            `json:"kinds"`
+	Since        *time.Time                       `json:"since,omitempty"`
+	Until        *time.Time                       `json:"until,omitempty"`
+	Relays       []string                         `json:"relays"`
+	EventsTotal  int64                            `json:"events_total"`
+	EventsSent   int64                            `json:"events_sent"`
+	RelayResults map[string]*BroadcastRelayResult `json:"relay_results"`
+	ErrorMessage string                           `json:"error_message,omitempty"`
+	StartedAt    time.Time                        `json:"started_at"`
+	CompletedAt  *time.Time                       `json:"completed_at,omitempty"`
+}
+
+const broadcastJobColumns = `id, job_id, status, authors, kinds, since, until, relays, events_total, events_sent,
+	relay_results, error_message, started_at, completed_at`
+
+// CreateBroadcastJob inserts a running broadcast and sets its ID.
+func (d *DB) CreateBroadcastJob(ctx context.Context, b *BroadcastJob) error {
+	authors, _ := json.Marshal(b.Authors)
+	kinds, _ := json.Marshal(b.Kinds)
+	relays, _ := json.Marshal(b.Relays)
+	var since, until interface{}
+	if b.Since != nil {
+		since = b.Since.Unix()
+	}
+	if b.Until != nil {
+		until = b.Until.Unix()
+	}
+
+	now := time.Now()
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO broadcast_jobs (status, authors, kinds, since, until, relays, started_at)
+		VALUES ('running', ?, ?, ?, ?, ?, ?)
+	`, string(authors), string(kinds), since, until, string(relays), now.Unix())
+	if err != nil {
+		return err
+	}
+	if b.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	b.Status = "running"
+	b.StartedAt = time.Unix(now.Unix(), 0)
+	return nil
+}
+
+// SetBroadcastJobID links a broadcast to the job that runs it.
+func (d *DB) SetBroadcastJobID(ctx context.Context, id, jobID int64) error {
+	_, err := d.AppDB.ExecContext(ctx, `UPDATE broadcast_jobs SET job_id = ? WHERE id = ?`, jobID, id)
+	return err
+}
+
+// UpdateBroadcastProgress saves a broadcast's event counts and relay results.
+func (d *DB) UpdateBroadcastProgress(ctx context.Context, b *BroadcastJob) error {
+	results, _ := json.Marshal(b.RelayResults)
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE broadcast_jobs SET events_total = ?, events_sent = ?, relay_results = ?
+		WHERE id = ?
+	`, b.EventsTotal, b.EventsSent, string(results), b.ID)
+	return err
+}
+
+// CompleteBroadcastJob saves a broadcast's final counts and status.
+func (d *DB) CompleteBroadcastJob(ctx context.Context, b *BroadcastJob, status, errorMsg string) error {
+	results, _ := json.Marshal(b.RelayResults)
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE broadcast_jobs
+		SET status = ?, events_total = ?, events_sent = ?, relay_results = ?, error_message = ?,
+		    completed_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, status, b.EventsTotal, b.EventsSent, string(results), nullString(errorMsg), b.ID)
+	return err
+}
+
+// GetBroadcastJob returns a broadcast by ID, or nil if it does not exist.
+func (d *DB) GetBroadcastJob(ctx context.Context, id int64) (*BroadcastJob, error) {
+	row := d.AppDB.QueryRowContext(ctx, `SELECT `+broadcastJobColumns+` FROM broadcast_jobs WHERE id = ?`, id)
+	b, err := scanBroadcastJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+// GetBroadcastJobs returns broadcasts, newest first, and the total count.
+func (d *DB) GetBroadcastJobs(ctx context.Context, limit, offset int) ([]BroadcastJob, int64, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var total int64
+	if err := d.AppDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM broadcast_jobs`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+broadcastJobColumns+` FROM broadcast_jobs ORDER BY id DESC LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs := []BroadcastJob{}
+	for rows.Next() {
+		b, err := scanBroadcastJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, *b)
+	}
+	return jobs, total, rows.Err()
+}
+
+// FailInterruptedBroadcastJobs marks broadcasts left running by a previous
+// process as failed.
+func (d *DB) FailInterruptedBroadcastJobs(ctx context.Context) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, `
+		UPDATE broadcast_jobs
+		SET status = 'failed', error_message = 'interrupted by server restart', completed_at = strftime('%s', 'now')
+		WHERE status = 'running'
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanBroadcastJob(row interface{ Scan(...interface{}) error }) (*BroadcastJob, error) {
+	var b BroadcastJob
+	var jobID, since, until, completedAt sql.NullInt64
+	var authors, kinds, results, errorMsg sql.NullString
+	var relays string
+	var startedAt int64
+
+	if err := row.Scan(&b.ID, &jobID, &b.Status, &authors, &kinds, &since, &until, &relays, &b.EventsTotal,
+		&b.EventsSent, &results, &errorMsg, &startedAt, &completedAt); err != nil {
+		return nil, err
+	}
+
+	b.JobID = jobID.Int64
+	json.Unmarshal([]byte(authors.String), &b.Authors)
+	if b.Authors == nil {
+		b.Authors = []string{}
+	}
+	json.Unmarshal([]byte(kinds.String), &b.Kinds)
+	if b.Kinds == nil {
+		b.Kinds = []int{}
+	}
+	json.Unmarshal([]byte(relays), &b.Relays)
+	json.Unmarshal([]byte(results.String), &b.RelayResults)
+	if b.RelayResults == nil {
+		b.RelayResults = map[string]*BroadcastRelayResult{}
+	}
+	if since.Valid {
+		t := time.Unix(since.Int64, 0)
+		b.Since = &t
+	}
+	if until.Valid {
+		t := time.Unix(until.Int64, 0)
+		b.Until = &t
+	}
+	b.ErrorMessage = errorMsg.String
+	b.StartedAt = time.Unix(startedAt, 0)
+	if completedAt.Valid {
+		t := time.Unix(completedAt.Int64, 0)
+		b.CompletedAt = &t
+	}
+	return &b, nil
+}
+
+// ============================================================================
+// Npub Consistency
+// ============================================================================
+
+// NpubTables are the app tables that store a pubkey next to its npub.
+var NpubTables = []string{"whitelist_meta", "blacklist", "paid_users", "sync_pubkeys", "pending_invoices"}
+
+// PubkeyNpub is a stored pubkey and the npub stored with it.
+type PubkeyNpub struct {
+	Pubkey string
+	Npub   string
+}
+
+func isNpubTable(table string) bool {
+	for _, t := range NpubTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPubkeyNpubs returns the distinct pubkey/npub pairs stored in a table.
+func (d *DB) GetPubkeyNpubs(ctx context.Context, table string) ([]PubkeyNpub, error) {
+	if !isNpubTable(table) {
+		return nil, fmt.Errorf("table %q has no npub column", table)
+	}
+
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT DISTINCT pubkey, COALESCE(npub, '') FROM `+table+` ORDER BY pubkey`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs []PubkeyNpub
+	for rows.Next() {
+		var p PubkeyNpub
+		if err := rows.Scan(&p.Pubkey, &p.Npub); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
+
+// SetNpub sets the npub of every row in a table with the given pubkey and
+// returns the number of rows changed.
+func (d *DB) SetNpub(ctx context.Context, table, pubkey, npub string) (int64, error) {
+	if !isNpubTable(table) {
+		return 0, fmt.Errorf("table %q has no npub column", table)
+	}
+
+	result, err := d.AppDB.ExecContext(ctx, `UPDATE `+table+` SET npub = ? WHERE pubkey = ? AND npub IS NOT ?`, npub, pubkey, npub)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ============================================================================
+// Jobs
+// ============================================================================
+
+// Job states.
+const (
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Job is a long-running operation with progress and a result.
+type Job struct {
+	ID              int64           `json:"id"`
+	Type            string          `json:"type"`
+	Status          string          `json:"status"`
+	Params          json.RawMessage `json:"params,omitempty"`
+	ProgressCurrent int64           `json:"progress_current"`
+	ProgressTotal   int64           `json:"progress_total"` // 0 if unknown
+	Message         string          `json:"message,omitempty"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	CompletedAt     *time.Time      `json:"completed_at,omitempty"`
+}
+
+// Done reports whether the job has finished.
+func (j *Job) Done() bool {
+	return j.Status != JobStatusRunning
+}
+
+// JobFilter selects jobs for GetJobs.
+type JobFilter struct {
+	Type   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+const jobColumns = `id, type, status, params, progress_current, progress_total, message, result, error, created_at, completed_at`
+
+// CreateJob inserts a running job.
+func (d *DB) CreateJob(ctx context.Context, jobType string, params interface{}) (*Job, error) {
+	var paramsJSON []byte
+	if params != nil {
+		var err error
+		if paramsJSON, err = json.Marshal(params); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO jobs (type, status, params, created_at)
+		VALUES (?, ?, ?, ?)
+	`, jobType, JobStatusRunning, nullString(string(paramsJSON)), now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{
+		ID:        id,
+		Type:      jobType,
+		Status:    JobStatusRunning,
+		Params:    paramsJSON,
+		CreatedAt: time.Unix(now.Unix(), 0),
+	}, nil
+}
+
+// UpdateJobProgress saves a running job's progress.
+func (d *DB) UpdateJobProgress(ctx context.Context, id, current, total int64, message string) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE jobs SET progress_current = ?, progress_total = ?, message = ?
+		WHERE id = ?
+	`, current, total, nullString(message), id)
+	return err
+}
+
+// CompleteJob saves a job's final state.
+func (d *DB) CompleteJob(ctx context.Context, job *Job) error {
+	var completedAt interface{}
+	if job.CompletedAt != nil {
+		completedAt = job.CompletedAt.Unix()
+	}
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = ?, progress_current = ?, progress_total = ?, message = ?, result = ?, error = ?, completed_at = ?
+		WHERE id = ?
+	`, job.Status, job.ProgressCurrent, job.ProgressTotal, nullString(job.Message),
+		nullString(string(job.Result)), nullString(job.Error), completedAt, job.ID)
+	return err
+}
+
+// GetJob returns a job by ID, or nil if it does not exist.
+func (d *DB) GetJob(ctx context.Context, id int64) (*Job, error) {
+	row := d.AppDB.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id)
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// GetJobs returns jobs matching the filter, newest first, and the total
+// number of matches.
+func (d *DB) GetJobs(ctx context.Context, filter JobFilter) ([]Job, int64, error) {
+	where := ` WHERE 1=1`
+	var args []interface{}
+	if filter.Type != "" {
+		where += ` AND type = ?`
+		args = append(args, filter.Type)
+	}
+	if filter.Status != "" {
+		where += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+
+	var total int64
+	if err := d.AppDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM jobs`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+jobColumns+` FROM jobs`+where+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, total, rows.Err()
+}
+
+// FailInterruptedJobs marks jobs left running by a previous process as failed.
+func (d *DB) FailInterruptedJobs(ctx context.Context) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, `
+		UPDATE jobs SET status = ?, error = 'interrupted by server restart', completed_at = strftime('%s', 'now')
+		WHERE status = ?
+	`, JobStatusFailed, JobStatusRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanJob(row interface{ Scan(...interface{}) error }) (*Job, error) {
+	var job Job
+	var params, message, result, errorMsg sql.NullString
+	var createdAt int64
+	var completedAt sql.NullInt64
+
+	if err := row.Scan(&job.ID, &job.Type, &job.Status, &params, &job.ProgressCurrent, &job.ProgressTotal,
+		&message, &result, &errorMsg, &createdAt, &completedAt); err != nil {
+		return nil, err
+	}
+
+	if params.Valid {
+		job.Params = json.RawMessage(params.String)
+	}
+	job.Message = message.String
+	if result.Valid {
+		job.Result = json.RawMessage(result.String)
+	}
+	job.Error = errorMsg.String
+	job.CreatedAt = time.Unix(createdAt, 0)
+	if completedAt.Valid {
+		t := time.Unix(completedAt.Int64, 0)
+		job.CompletedAt = &t
+	}
+	return &job, nil
+}
+
+// ============================================================================
+// Bandwidth
+// ============================================================================
+
+// BandwidthUsage is the relay traffic for one day and client IP class.
+type BandwidthUsage struct {
+	Date        string `json:"date"`
+	IPClass     string `json:"ip_class"`
+	BytesIn     int64  `json:"bytes_in"`
+	BytesOut    int64  `json:"bytes_out"`
+	Connections int64  `json:"connections"`
+}
+
+// AddBandwidthUsage adds traffic to the day/class totals.
+func (d *DB) AddBandwidthUsage(ctx context.Context, u BandwidthUsage) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO bandwidth_daily (date, ip_class, bytes_in, bytes_out, connections)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(date, ip_class) DO UPDATE SET
+			bytes_in = bytes_in + excluded.bytes_in,
+			bytes_out = bytes_out + excluded.bytes_out,
+			connections = connections + excluded.connections
+	`, u.Date, u.IPClass, u.BytesIn, u.BytesOut, u.Connections)
+	return err
+}
+
+// GetBandwidthUsage returns daily traffic on or after sinceDate (YYYY-MM-DD),
+// ordered by date and IP class.
+func (d *DB) GetBandwidthUsage(ctx context.Context, sinceDate string) ([]BandwidthUsage, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT date, ip_class, bytes_in, bytes_out, connections
+		FROM bandwidth_daily
+		WHERE date >= ?
+		ORDER BY date, ip_class
+	`, sinceDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []BandwidthUsage{}
+	for rows.Next() {
+		var u BandwidthUsage
+		if err := rows.Scan(&u.Date, &u.IPClass, &u.BytesIn, &u.BytesOut, &u.Connections); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+
+	return usage, rows.Err()
+}
+
+// ============================================================================
+// Uptime
+// ============================================================================
+
+// UptimeCheck is one check of the relay's WebSocket.
+type UptimeCheck struct {
+	CheckedAt   time.Time `json:"checked_at"`
+	Up          bool      `json:"up"`
+	LatencyMs   *int64    `json:"latency_ms,omitempty"`  // REQ round-trip, when up
+	Connections *int64    `json:"connections,omitempty"` // Active client connections, when known
+	Error       string    `json:"error,omitempty"`
+}
+
+// UptimeSummary totals the checks over a period.
+type UptimeSummary struct {
+	Checks       int64    `json:"checks"`
+	Up           int64    `json:"up"`
+	Availability *float64 `json:"availability"`   // Percent of checks up; nil without checks
+	AvgLatencyMs *float64 `json:"avg_latency_ms"` // nil without successful checks
+}
+
+// setAvailability computes Availability from the check counts.
+func (s *UptimeSummary) setAvailability() {
+	if s.Checks > 0 {
+		percent := 100 * float64(s.Up) / float64(s.Checks)
+		s.Availability = &percent
+	}
+}
+
+// UptimeDay totals the checks of one UTC day.
+type UptimeDay struct {
+	Date string `json:"date"`
+	UptimeSummary
+}
+
+// AddUptimeCheck records a check.
+func (d *DB) AddUptimeCheck(ctx context.Context, c UptimeCheck) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO relay_uptime (checked_at, up, latency_ms, connections, error)
+		VALUES (?, ?, ?, ?, ?)
+	`, c.CheckedAt.Unix(), c.Up, c.LatencyMs, c.Connections, nullString(c.Error))
+	return err
+}
+
+// GetLatestUptimeCheck returns the most recent check, or nil if there is none.
+func (d *DB) GetLatestUptimeCheck(ctx context.Context) (*UptimeCheck, error) {
+	var c UptimeCheck
+	var checkedAt int64
+	var latency, connections sql.NullInt64
+	var errorMsg sql.NullString
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT checked_at, up, latency_ms, connections, error
+		FROM relay_uptime
+		ORDER BY checked_at DESC, id DESC
+		LIMIT 1
+	`).Scan(&checkedAt, &c.Up, &latency, &connections, &errorMsg)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.CheckedAt = time.Unix(checkedAt, 0)
+	if latency.Valid {
+		c.LatencyMs = &latency.Int64
+	}
+	if connections.Valid {
+		c.Connections = &connections.Int64
+	}
+	c.Error = errorMsg.String
+	return &c, nil
+}
+
+// GetUptimeSummary totals the checks made at or after since.
+func (d *DB) GetUptimeSummary(ctx context.Context, since time.Time) (UptimeSummary, error) {
+	var s UptimeSummary
+	var latency sql.NullFloat64
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(up), 0), AVG(CASE WHEN up = 1 THEN latency_ms END)
+		FROM relay_uptime
+		WHERE checked_at >= ?
+	`, since.Unix()).Scan(&s.Checks, &s.Up, &latency)
+	if err != nil {
+		return s, err
+	}
+	if latency.Valid {
+		s.AvgLatencyMs = &latency.Float64
+	}
+	s.setAvailability()
+	return s, nil
+}
+
+// GetUptimeDaily totals the checks per UTC day, from since onwards.
+func (d *DB) GetUptimeDaily(ctx context.Context, since time.Time) ([]UptimeDay, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT date(checked_at, 'unixepoch') AS day, COUNT(*), SUM(up),
+			AVG(CASE WHEN up = 1 THEN latency_ms END)
+		FROM relay_uptime
+		WHERE checked_at >= ?
+		GROUP BY day
+		ORDER BY day
+	`, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []UptimeDay{}
+	for rows.Next() {
+		var day UptimeDay
+		var latency sql.NullFloat64
+		if err := rows.Scan(&day.Date, &day.Checks, &day.Up, &latency); err != nil {
+			return nil, err
+		}
+		if latency.Valid {
+			day.AvgLatencyMs = &latency.Float64
+		}
+		day.setAvailability()
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+// DeleteUptimeChecksBefore removes checks made before the cutoff.
+func (d *DB) DeleteUptimeChecksBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, "DELETE FROM relay_uptime WHERE checked_at < ?", before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ============================================================================
+// Tombstones
+// ============================================================================
+
+// Tombstone records an event the deletion worker removed, so syncs and
+// imports do not bring it back.
+type Tombstone struct {
+	EventID         string    `json:"event_id"`
+	Author          string    `json:"author"`
+	DeletedAt       time.Time `json:"deleted_at"`
+	Reason          string    `json:"reason,omitempty"`
+	DeletionEventID string    `json:"deletion_event_id,omitempty"` // Kind 5 event published for it
+}
+
+// TombstonePublishing controls publishing deletions to other relays as
+// NIP-09 kind 5 events signed with the operator's key. Without relays the
+// sync relays are used.
+type TombstonePublishing struct {
+	Enabled bool     `json:"enabled"`
+	Relays  []string `json:"relays"`
+}
+
+// AddTombstones records deleted events. An event already tombstoned keeps
+// its first record.
+func (d *DB) AddTombstones(ctx context.Context, tombstones []Tombstone) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		for _, t := range tombstones {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT OR IGNORE INTO event_tombstones (event_id, author, deleted_at, reason)
+				VALUES (?, ?, ?, ?)
+			`, t.EventID, t.Author, t.DeletedAt.Unix(), nullString(t.Reason)); err != nil {
+				return fmt.Errorf("failed to add tombstone: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// IsTombstoned reports whether an event was removed by the deletion worker.
+func (d *DB) IsTombstoned(ctx context.Context, eventID string) (bool, error) {
+	var exists bool
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM event_tombstones WHERE event_id = ?)
+	`, eventID).Scan(&exists)
+	return exists, err
+}
+
+// GetTombstones returns a page of tombstones, newest first, and how many
+// there are.
+func (d *DB) GetTombstones(ctx context.Context, limit, offset int) ([]Tombstone, int64, error) {
+	var total int64
+	if err := d.AppDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM event_tombstones").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT event_id, author, deleted_at, reason, deletion_event_id
+		FROM event_tombstones
+		ORDER BY deleted_at DESC, event_id
+		LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tombstones := []Tombstone{}
+	for rows.Next() {
+		var t Tombstone
+		var deletedAt int64
+		var reason, deletionEventID sql.NullString
+		if err := rows.Scan(&t.EventID, &t.Author, &deletedAt, &reason, &deletionEventID); err != nil {
+			return nil, 0, err
+		}
+		t.DeletedAt = time.Unix(deletedAt, 0)
+		t.Reason = reason.String
+		t.DeletionEventID = deletionEventID.String
+		tombstones = append(tombstones, t)
+	}
+	return tombstones, total, rows.Err()
+}
+
+// DeleteTombstone removes an event's tombstone, so it can be synced or
+// imported again. It reports whether there was one.
+func (d *DB) DeleteTombstone(ctx context.Context, eventID string) (bool, error) {
+	result, err := d.AppDB.ExecContext(ctx, "DELETE FROM event_tombstones WHERE event_id = ?", eventID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// SetTombstonesPublished records the kind 5 event published for events.
+func (d *DB) SetTombstonesPublished(ctx context.Context, eventIDs []string, deletionEventID string) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		for _, id := range eventIDs {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE event_tombstones SET deletion_event_id = ? WHERE event_id = ?
+			`, deletionEventID, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetTombstonePublishing returns the deletion publishing settings.
+func (d *DB) GetTombstonePublishing(ctx context.Context) (*TombstonePublishing, error) {
+	settings := &TombstonePublishing{Relays: []string{}}
+	value, err := d.GetAppState(ctx, "tombstone_publishing")
+	if err != nil || value == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(value), settings); err != nil {
+		return nil, fmt.Errorf("failed to parse tombstone_publishing: %w", err)
+	}
+	if settings.Relays == nil {
+		settings.Relays = []string{}
+	}
+	return settings, nil
+}
+
+// SetTombstonePublishing saves the deletion publishing settings.
+func (d *DB) SetTombstonePublishing(ctx context.Context, settings TombstonePublishing) error {
+	if settings.Relays == nil {
+		settings.Relays = []string{}
+	}
+	value, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "tombstone_publishing", string(value))
+}
+
+// GetOperatorSigningKey returns the operator's secret key (hex) if the
+// operator delegated it to Roostr, or "" if not.
+func (d *DB) GetOperatorSigningKey(ctx context.Context) (string, error) {
+	value, err := d.GetAppState(ctx, "operator_signing_key")
+	if err != nil || value == "" {
+		return "", err
+	}
+	return d.vault.Open(secretOperatorKey, value)
+}
+
+// SetOperatorSigningKey saves the operator's secret key (hex), encrypted
+// when a secrets passphrase is set. An empty key removes it.
+func (d *DB) SetOperatorSigningKey(ctx context.Context, secretHex string) error {
+	if secretHex == "" {
+		_, err := d.AppDB.ExecContext(ctx, "DELETE FROM app_state WHERE key = 'operator_signing_key'")
+		return err
+	}
+	sealed, err := d.vault.Seal(secretOperatorKey, secretHex)
+	if err != nil {
+		return err
+	}
+	return d.SetAppState(ctx, "operator_signing_key", sealed)
+}
+
+// RemoteSigner is a pairing with the operator's NIP-46 remote signer.
+type RemoteSigner struct {
+	SignerPubkey string    `json:"signer_pubkey"`
+	Pubkey       string    `json:"pubkey"` // The operator's pubkey the signer signs as
+	Relays       []string  `json:"relays"`
+	ClientSecret string    `json:"client_secret"` // Roostr's key for talking to the signer
+	PairedAt     time.Time `json:"paired_at"`
+}
+
+// GetRemoteSigner returns the paired remote signer, or nil if there is none.
+func (d *DB) GetRemoteSigner(ctx context.Context) (*RemoteSigner, error) {
+	value, err := d.GetAppState(ctx, "remote_signer")
+	if err != nil || value == "" {
+		return nil, err
+	}
+	value, err = d.vault.Open(secretRemoteSigner, value)
+	if err != nil {
+		return nil, err
+	}
+	var signer RemoteSigner
+	if err := json.Unmarshal([]byte(value), &signer); err != nil {
+		return nil, fmt.Errorf("failed to parse remote_signer: %w", err)
+	}
+	return &signer, nil
+}
+
+// SetRemoteSigner saves the remote signer pairing, encrypted when a secrets
+// passphrase is set. A nil signer removes it.
+func (d *DB) SetRemoteSigner(ctx context.Context, signer *RemoteSigner) error {
+	if signer == nil {
+		_, err := d.AppDB.ExecContext(ctx, "DELETE FROM app_state WHERE key = 'remote_signer'")
+		return err
+	}
+	value, _ := json.Marshal(signer)
+	sealed, err := d.vault.Seal(secretRemoteSigner, string(value))
+	if err != nil {
+		return err
+	}
+	return d.SetAppState(ctx, "remote_signer", sealed)
+}
+
+// ============================================================================
+// Follow Access
+// ============================================================================
+
+// FollowAccessSettings extends whitelist access to pubkeys followed by
+// enough whitelisted members.
+type FollowAccessSettings struct {
+	Enabled      bool `json:"enabled"`
+	MinFollowers int  `json:"min_followers"` // Members who must follow a pubkey
+}
+
+// FollowGrant is a pubkey allowed to post because members follow it.
+type FollowGrant struct {
+	Pubkey    string    `json:"pubkey"`
+	Followers int       `json:"followers"`
+	Via       []string  `json:"via"` // Pubkeys of the members following it
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+// GetFollowAccessSettings returns the follow access settings, disabled with
+// a threshold of 2 if none are saved.
+func (d *DB) GetFollowAccessSettings(ctx context.Context) (*FollowAccessSettings, error) {
+	settings := &FollowAccessSettings{MinFollowers: 2}
+	value, err := d.GetAppState(ctx, "follow_access_settings")
+	if err != nil || value == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(value), settings); err != nil {
+		return nil, fmt.Errorf("failed to parse follow_access_settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SetFollowAccessSettings saves the follow access settings.
+func (d *DB) SetFollowAccessSettings(ctx context.Context, settings *FollowAccessSettings) error {
+	value, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "follow_access_settings", string(value))
+}
+
+// GetFollowGrants returns the follow grants, most followed first.
+func (d *DB) GetFollowGrants(ctx context.Context) ([]FollowGrant, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT pubkey, followers, via, granted_at
+		FROM follow_grants
+		ORDER BY followers DESC, pubkey
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []FollowGrant{}
+	for rows.Next() {
+		var g FollowGrant
+		var via string
+		var grantedAt int64
+		if err := rows.Scan(&g.Pubkey, &g.Followers, &via, &grantedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(via), &g.Via)
+		g.GrantedAt = time.Unix(grantedAt, 0)
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// ReplaceFollowGrants makes the follow grants match grants, keeping when
+// each existing grant was first made. It returns the pubkeys granted and
+// revoked.
+func (d *DB) ReplaceFollowGrants(ctx context.Context, grants []FollowGrant) (added, removed []string, err error) {
+	err = d.Transaction(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT pubkey FROM follow_grants")
+		if err != nil {
+			return err
+		}
+		existing := map[string]bool{}
+		for rows.Next() {
+			var pubkey string
+			if err := rows.Scan(&pubkey); err != nil {
+				rows.Close()
+				return err
+			}
+			existing[pubkey] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		keep := make(map[string]bool, len(grants))
+		for _, g := range grants {
+			keep[g.Pubkey] = true
+			via, _ := json.Marshal(g.Via)
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO follow_grants (pubkey, followers, via) VALUES (?, ?, ?)
+				ON CONFLICT(pubkey) DO UPDATE SET followers = excluded.followers, via = excluded.via
+			`, g.Pubkey, g.Followers, string(via)); err != nil {
+				return err
+			}
+			if !existing[g.Pubkey] {
+				added = append(added, g.Pubkey)
+			}
+		}
+		for pubkey := range existing {
+			if keep[pubkey] {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM follow_grants WHERE pubkey = ?", pubkey); err != nil {
+				return err
+			}
+			removed = append(removed, pubkey)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	slices.Sort(removed)
+	return added, removed, nil
+}
+
+// GetEffectiveWhitelist returns the pubkeys allowed to post in whitelist
+// mode: the whitelist, then the follow grants, less blacklisted pubkeys.
+func (d *DB) GetEffectiveWhitelist(ctx context.Context) ([]string, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT pubkey FROM (
+			SELECT pubkey, 0 AS granted, is_operator, added_at FROM whitelist_meta
+			UNION ALL
+			SELECT pubkey, 1, 0, granted_at FROM follow_grants
+			WHERE pubkey NOT IN (SELECT pubkey FROM whitelist_meta)
+		)
+		WHERE pubkey NOT IN (SELECT pubkey FROM blacklist)
+		ORDER BY granted, is_operator DESC, added_at ASC, pubkey
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pubkeys := []string{}
+	for rows.Next() {
+		var pubkey string
+		if err := rows.Scan(&pubkey); err != nil {
+			return nil, err
+		}
+		pubkeys = append(pubkeys, pubkey)
+	}
+	return pubkeys, rows.Err()
+}
+
+// ============================================================================
+// Media
+// ============================================================================
+
+// MediaSettings controls accounting for media on the operator's Blossom
+// server.
+type MediaSettings struct {
+	Enabled             bool   `json:"enabled"`
+	ServerURL           string `json:"server_url"`            // e.g. https://blossom.example.com
+	DeleteWithRetention bool   `json:"delete_with_retention"` // Delete blobs no stored event links to after retention runs
+}
+
+// MediaRef is a link from a relay event to a blob on the Blossom server.
+type MediaRef struct {
+	SHA256  string
+	EventID string
+	Owner   string // Author of the event
+	URL     string
+}
+
+// MediaBlob is a blob on the Blossom server that relay events link to.
+type MediaBlob struct {
+	SHA256    string     `json:"sha256"`
+	Owner     string     `json:"owner"`
+	URL       string     `json:"url"`
+	Size      *int64     `json:"size"` // nil until checked on the server
+	MimeType  string     `json:"mime_type,omitempty"`
+	FirstSeen time.Time  `json:"first_seen"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Missing   bool       `json:"missing"`
+}
+
+// MediaUsage is the media a pubkey's events link to.
+type MediaUsage struct {
+	Pubkey    string `json:"pubkey,omitempty"`
+	Blobs     int64  `json:"blobs"`
+	Bytes     int64  `json:"bytes"`
+	Unchecked int64  `json:"unchecked"` // Blobs whose size is not known yet
+}
+
+// GetMediaSettings returns the media settings, disabled if none are saved.
+func (d *DB) GetMediaSettings(ctx context.Context) (*MediaSettings, error) {
+	settings := &MediaSettings{}
+	value, err := d.GetAppState(ctx, "media_settings")
+	if err != nil || value == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(value), settings); err != nil {
+		return nil, fmt.Errorf("failed to parse media_settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SetMediaSettings saves the media settings.
+func (d *DB) SetMediaSettings(ctx context.Context, settings *MediaSettings) error {
+	value, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "media_settings", string(value))
+}
+
+// GetMediaCursor returns the last relay event row ID scanned for media
+// links, or 0 if none.
+func (d *DB) GetMediaCursor(ctx context.Context) (int64, error) {
+	value, err := d.GetAppState(ctx, "media_cursor")
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// SetMediaCursor saves the last relay event row ID scanned for media links.
+func (d *DB) SetMediaCursor(ctx context.Context, rowID int64) error {
+	return d.SetAppState(ctx, "media_cursor", strconv.FormatInt(rowID, 10))
+}
+
+// AddMediaRefs records links from events to blobs. A blob keeps the owner
+// and URL it was first seen with.
+func (d *DB) AddMediaRefs(ctx context.Context, refs []MediaRef) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		for _, ref := range refs {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT OR IGNORE INTO media_blobs (sha256, owner, url) VALUES (?, ?, ?)
+			`, ref.SHA256, ref.Owner, ref.URL); err != nil {
+				return fmt.Errorf("failed to add media blob: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT OR IGNORE INTO media_refs (sha256, event_id) VALUES (?, ?)
+			`, ref.SHA256, ref.EventID); err != nil {
+				return fmt.Errorf("failed to add media link: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetUncheckedMediaBlobs returns up to limit blobs whose size has not been
+// checked on the server, oldest first.
+func (d *DB) GetUncheckedMediaBlobs(ctx context.Context, limit int) ([]MediaBlob, error) {
+	return d.queryMediaBlobs(ctx, `
+		WHERE size IS NULL AND missing = 0
+		ORDER BY first_seen, sha256
+		LIMIT ?
+	`, limit)
+}
+
+// GetMediaBlobsAfter returns up to limit blobs with a hash after the given
+// one, in hash order, with the events linking to each.
+func (d *DB) GetMediaBlobsAfter(ctx context.Context, after string, limit int) ([]MediaBlob, map[string][]string, error) {
+	blobs, err := d.queryMediaBlobs(ctx, `
+		WHERE sha256 > ?
+		ORDER BY sha256
+		LIMIT ?
+	`, after, limit)
+	if err != nil || len(blobs) == 0 {
+		return blobs, nil, err
+	}
+
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT sha256, event_id FROM media_refs WHERE sha256 >= ? AND sha256 <= ?
+	`, blobs[0].SHA256, blobs[len(blobs)-1].SHA256)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	refs := make(map[string][]string, len(blobs))
+	for rows.Next() {
+		var sha256, eventID string
+		if err := rows.Scan(&sha256, &eventID); err != nil {
+			return nil, nil, err
+		}
+		refs[sha256] = append(refs[sha256], eventID)
+	}
+	return blobs, refs, rows.Err()
+}
+
+// queryMediaBlobs returns the media blobs selected by the query's WHERE,
+// ORDER BY and LIMIT clauses.
+func (d *DB) queryMediaBlobs(ctx context.Context, clauses string, args ...interface{}) ([]MediaBlob, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT sha256, owner, url, size, mime_type, first_seen, checked_at, missing
+		FROM media_blobs
+	`+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blobs := []MediaBlob{}
+	for rows.Next() {
+		var b MediaBlob
+		var size, checkedAt sql.NullInt64
+		var mimeType sql.NullString
+		var firstSeen int64
+		if err := rows.Scan(&b.SHA256, &b.Owner, &b.URL, &size, &mimeType, &firstSeen, &checkedAt, &b.Missing); err != nil {
+			return nil, err
+		}
+		if size.Valid {
+			b.Size = &size.Int64
+		}
+		b.MimeType = mimeType.String
+		b.FirstSeen = time.Unix(firstSeen, 0)
+		if checkedAt.Valid {
+			t := time.Unix(checkedAt.Int64, 0)
+			b.CheckedAt = &t
+		}
+		blobs = append(blobs, b)
+	}
+	return blobs, rows.Err()
+}
+
+// SetMediaBlobChecked records a blob's size and type as reported by the
+// server, or that the server no longer has it.
+func (d *DB) SetMediaBlobChecked(ctx context.Context, sha256 string, size int64, mimeType string, missing bool) error {
+	var sizeArg interface{} = size
+	if missing {
+		sizeArg = nil
+	}
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE media_blobs SET size = ?, mime_type = ?, missing = ?, checked_at = strftime('%s', 'now')
+		WHERE sha256 = ?
+	`, sizeArg, nullString(mimeType), missing, sha256)
+	return err
+}
+
+// DeleteMediaBlob forgets a blob and the links to it.
+func (d *DB) DeleteMediaBlob(ctx context.Context, sha256 string) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM media_refs WHERE sha256 = ?", sha256); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM media_blobs WHERE sha256 = ?", sha256)
+		return err
+	})
+}
+
+// GetMediaUsage returns the media owned by pubkey. Blobs the server no
+// longer has are not counted.
+func (d *DB) GetMediaUsage(ctx context.Context, pubkey string) (*MediaUsage, error) {
+	usage := &MediaUsage{Pubkey: pubkey}
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(size), 0), COUNT(*) - COUNT(size)
+		FROM media_blobs WHERE owner = ? AND missing = 0
+	`, pubkey).Scan(&usage.Blobs, &usage.Bytes, &usage.Unchecked)
+	return usage, err
+}
+
+// GetMediaUsageByOwner returns the total media and the limit owners with
+// the most media bytes.
+func (d *DB) GetMediaUsageByOwner(ctx context.Context, limit int) (*MediaUsage, []MediaUsage, error) {
+	total := &MediaUsage{}
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(size), 0), COUNT(*) - COUNT(size)
+		FROM media_blobs WHERE missing = 0
+	`).Scan(&total.Blobs, &total.Bytes, &total.Unchecked)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT owner, COUNT(*), COALESCE(SUM(size), 0), COUNT(*) - COUNT(size)
+		FROM media_blobs WHERE missing = 0
+		GROUP BY owner
+		ORDER BY 3 DESC, owner
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	owners := []MediaUsage{}
+	for rows.Next() {
+		var u MediaUsage
+		if err := rows.Scan(&u.Pubkey, &u.Blobs, &u.Bytes, &u.Unchecked); err != nil {
+			return nil, nil, err
+		}
+		owners = append(owners, u)
+	}
+	return total, owners, rows.Err()
+}
+
+// ============================================================================
+// Webhooks
+// ============================================================================
+
+// Webhook is an outgoing webhook endpoint.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is a queued or attempted webhook delivery.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int64      `json:"webhook_id"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"` // pending, delivered, failed
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+const webhookColumns = `id, url, secret, events, description, enabled, created_at, updated_at`
+
+// CreateWebhook inserts a webhook and sets its ID.
+func (d *DB) CreateWebhook(ctx context.Context, wh *Webhook) error {
+	eventsJSON, _ := json.Marshal(wh.Events)
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO webhooks (url, secret, events, description, enabled)
+		VALUES (?, ?, ?, ?, ?)
+	`, wh.URL, wh.Secret, string(eventsJSON), nullString(wh.Description), wh.Enabled)
+	if err != nil {
+		return err
+	}
+	wh.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetWebhooks returns all webhooks.
+func (d *DB) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *wh)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhook returns a webhook by ID, or nil if it does not exist.
+func (d *DB) GetWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	row := d.AppDB.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id)
+	wh, err := scanWebhook(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return wh, err
+}
+
+// UpdateWebhook saves all mutable webhook fields.
+func (d *DB) UpdateWebhook(ctx context.Context, wh *Webhook) error {
+	eventsJSON, _ := json.Marshal(wh.Events)
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE webhooks
+		SET url = ?, secret = ?, events = ?, description = ?, enabled = ?, updated_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, wh.URL, wh.Secret, string(eventsJSON), nullString(wh.Description), wh.Enabled, wh.ID)
+	return err
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+func (d *DB) DeleteWebhook(ctx context.Context, id int64) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+		return err
+	})
+}
+
+// CreateWebhookDelivery queues a delivery for immediate attempt.
+func (d *DB) CreateWebhookDelivery(ctx context.Context, webhookID int64, event, payload string) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		VALUES (?, ?, ?)
+	`, webhookID, event, payload)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetDueWebhookDeliveries returns pending deliveries whose next attempt is due.
+func (d *DB) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?
+	`, now.Unix(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanWebhookDeliveries(rows)
+}
+
+// GetWebhookDeliveries returns the most recent deliveries for a webhook.
+func (d *DB) GetWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanWebhookDeliveries(rows)
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt. status is
+// "delivered", "failed" (no more retries) or "pending" with nextAttempt set.
+func (d *DB) RecordWebhookAttempt(ctx context.Context, id int64, status string, statusCode int, errMsg string, nextAttempt time.Time) error {
+	var deliveredAt interface{}
+	if status == "delivered" {
+		deliveredAt = time.Now().Unix()
+	}
+	var code interface{}
+	if statusCode > 0 {
+		code = statusCode
+	}
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, last_status_code = ?, last_error = ?,
+			next_attempt_at = ?, delivered_at = ?
+		WHERE id = ?
+	`, status, code, nullString(errMsg), nextAttempt.Unix(), deliveredAt, id)
+	return err
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at,
+		last_status_code, last_error, created_at, delivered_at`
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (*Webhook, error) {
+	var wh Webhook
+	var eventsJSON string
+	var description sql.NullString
+	var createdAt, updatedAt int64
+
+	if err := row.Scan(&wh.ID, &wh.URL, &wh.Secret, &eventsJSON, &description, &wh.Enabled, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+
+	wh.Events = []string{}
+	json.Unmarshal([]byte(eventsJSON), &wh.Events)
+	wh.Description = description.String
+	wh.CreatedAt = time.Unix(createdAt, 0)
+	wh.UpdatedAt = time.Unix(updatedAt, 0)
+	return &wh, nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var del WebhookDelivery
+		var nextAttempt, createdAt int64
+		var statusCode, deliveredAt sql.NullInt64
+		var lastError sql.NullString
+
+		if err := rows.Scan(&del.ID, &del.WebhookID, &del.Event, &del.Payload, &del.Status, &del.Attempts,
+			&nextAttempt, &statusCode, &lastError, &createdAt, &deliveredAt); err != nil {
+			return nil, err
+		}
+
+		del.NextAttemptAt = time.Unix(nextAttempt, 0)
+		del.LastStatusCode = int(statusCode.Int64)
+		del.LastError = lastError.String
+		del.CreatedAt = time.Unix(createdAt, 0)
+		if deliveredAt.Valid {
+			t := time.Unix(deliveredAt.Int64, 0)
+			del.DeliveredAt = &t
+		}
+		deliveries = append(deliveries, del)
+	}
+	return deliveries, rows.Err()
+}
+
+// ============================================================================
+// Export Schedules
+// ============================================================================
+
+// ExportDestination holds the settings for where scheduled exports are sent.
+// Which fields apply depends on the schedule's destination type.
+type ExportDestination struct {
+	Path      string `json:"path,omitempty"`       // local directory, or remote directory for sftp
+	Endpoint  string `json:"endpoint,omitempty"`   // s3: e.g. https://s3.us-east-1.amazonaws.com
+	Region    string `json:"region,omitempty"`     // s3
+	Bucket    string `json:"bucket,omitempty"`     // s3
+	Prefix    string `json:"prefix,omitempty"`     // s3 key prefix
+	AccessKey string `json:"access_key,omitempty"` // s3
+	SecretKey string `json:"secret_key,omitempty"` // s3
+	Host      string `json:"host,omitempty"`       // sftp
+	Port      int    `json:"port,omitempty"`       // sftp
+	User      string `json:"user,omitempty"`       // sftp, webdav
+	KeyPath   string `json:"key_path,omitempty"`   // sftp private key file
+	RelayURL  string `json:"relay_url,omitempty"`  // relay
+	URL       string `json:"url,omitempty"`        // webdav collection, e.g. https://cloud.example.com/remote.php/dav/files/alice/roostr
+	Password  string `json:"password,omitempty"`   // webdav
+}
+
+// ExportSchedule is a recurring incremental export of new relay events.
+type ExportSchedule struct {
+	ID              int64             `json:"id"`
+	Name            string            `json:"name"`
+	DestinationType string            `json:"destination_type"`
+	Destination     ExportDestination `json:"destination"`
+	Kinds           []int             `json:"kinds"`
+	IntervalHours   int               `json:"interval_hours"`
+	RetentionCount  int               `json:"retention_count"`
+	Enabled         bool              `json:"enabled"`
+	Cursor          int64             `json:"cursor"`
+	NextRunAt       time.Time         `json:"next_run_at"`
+	LastRunAt       *time.Time        `json:"last_run_at,omitempty"`
+	LastStatus      string            `json:"last_status,omitempty"`
+	LastError       string            `json:"last_error,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// ExportManifest records one exported artifact.
+type ExportManifest struct {
+	ID         int64     `json:"id"`
+	ScheduleID int64     `json:"schedule_id"`
+	Filename   string    `json:"filename"`
+	Location   string    `json:"location,omitempty"`
+	FromCursor int64     `json:"from_cursor"`
+	ToCursor   int64     `json:"to_cursor"`
+	EventCount int64     `json:"event_count"`
+	Bytes      int64     `json:"bytes"`
+	SHA256     string    `json:"sha256,omitempty"`
+	Status     string    `json:"status"` // completed, failed, pruned
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const exportScheduleColumns = `id, name, destination_type, destination, kinds, interval_hours, retention_count,
+		enabled, cursor, next_run_at, last_run_at, last_status, last_error, created_at, updated_at`
+
+// CreateExportSchedule inserts a schedule and sets its ID. The first run is due immediately.
+func (d *DB) CreateExportSchedule(ctx context.Context, s *ExportSchedule) error {
+	destJSON, err := d.sealDestination(secretExportDestination, s.Destination)
+	if err != nil {
+		return err
+	}
+	kindsJSON, _ := json.Marshal(s.Kinds)
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO export_schedules (name, destination_type, destination, kinds, interval_hours, retention_count, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, s.Name, s.DestinationType, destJSON, string(kindsJSON), s.IntervalHours, s.RetentionCount, s.Enabled)
+	if err != nil {
+		return err
+	}
+	s.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetExportSchedules returns all export schedules.
+func (d *DB) GetExportSchedules(ctx context.Context) ([]ExportSchedule, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+exportScheduleColumns+` FROM export_schedules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return d.scanExportSchedules(rows)
+}
+
+// GetDueExportSchedules returns enabled schedules whose next run is due.
+func (d *DB) GetDueExportSchedules(ctx context.Context, now time.Time) ([]ExportSchedule, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+exportScheduleColumns+`
+		FROM export_schedules
+		WHERE enabled = 1 AND next_run_at <= ?
+		ORDER BY next_run_at, id
+	`, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return d.scanExportSchedules(rows)
+}
+
+// GetExportSchedule returns a schedule by ID, or nil if it does not exist.
+func (d *DB) GetExportSchedule(ctx context.Context, id int64) (*ExportSchedule, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+exportScheduleColumns+` FROM export_schedules WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules, err := d.scanExportSchedules(rows)
+	if err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+	return &schedules[0], nil
+}
+
+// UpdateExportSchedule saves the schedule's configuration fields.
+// Run state (cursor, last run) is only changed by RecordExportRun.
+func (d *DB) UpdateExportSchedule(ctx context.Context, s *ExportSchedule) error {
+	destJSON, err := d.sealDestination(secretExportDestination, s.Destination)
+	if err != nil {
+		return err
+	}
+	kindsJSON, _ := json.Marshal(s.Kinds)
+	_, err = d.AppDB.ExecContext(ctx, `
+		UPDATE export_schedules
+		SET name = ?, destination_type = ?, destination = ?, kinds = ?, interval_hours = ?,
+			retention_count = ?, enabled = ?, next_run_at = ?, updated_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, s.Name, s.DestinationType, destJSON, string(kindsJSON), s.IntervalHours,
+		s.RetentionCount, s.Enabled, s.NextRunAt.Unix(), s.ID)
+	return err
+}
+
+// DeleteExportSchedule removes a schedule and its manifests.
+// Artifacts already shipped to the destination are left in place.
+func (d *DB) DeleteExportSchedule(ctx context.Context, id int64) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM export_manifests WHERE schedule_id = ?`, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM export_schedules WHERE id = ?`, id)
+		return err
+	})
+}
+
+// RecordExportRun stores the outcome of a run. The cursor only advances on success.
+func (d *DB) RecordExportRun(ctx context.Context, id int64, status, errMsg string, cursor int64, nextRun time.Time) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE export_schedules
+		SET last_status = ?, last_error = ?, last_run_at = strftime('%s', 'now'),
+			cursor = CASE WHEN ? = 'completed' THEN ? ELSE cursor END, next_run_at = ?
+		WHERE id = ?
+	`, status, nullString(errMsg), status, cursor, nextRun.Unix(), id)
+	return err
+}
+
+// CreateExportManifest inserts a manifest and sets its ID.
+func (d *DB) CreateExportManifest(ctx context.Context, m *ExportManifest) error {
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO export_manifests (schedule_id, filename, location, from_cursor, to_cursor, event_count, bytes, sha256, status, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, m.ScheduleID, m.Filename, nullString(m.Location), m.FromCursor, m.ToCursor, m.EventCount, m.Bytes,
+		nullString(m.SHA256), m.Status, nullString(m.Error))
+	if err != nil {
+		return err
+	}
+	m.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetExportManifests returns the most recent manifests for a schedule.
+func (d *DB) GetExportManifests(ctx context.Context, scheduleID int64, limit int) ([]ExportManifest, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+exportManifestColumns+`
+		FROM export_manifests
+		WHERE schedule_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, scheduleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanExportManifests(rows)
+}
+
+// GetPrunableExportManifests returns completed manifests older than the newest keep.
+func (d *DB) GetPrunableExportManifests(ctx context.Context, scheduleID int64, keep int) ([]ExportManifest, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT `+exportManifestColumns+`
+		FROM export_manifests
+		WHERE schedule_id = ? AND status = 'completed'
+		ORDER BY id DESC
+		LIMIT -1 OFFSET ?
+	`, scheduleID, keep)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanExportManifests(rows)
+}
+
+// MarkExportManifestPruned records that a manifest's artifact was deleted.
+func (d *DB) MarkExportManifestPruned(ctx context.Context, id int64) error {
+	_, err := d.AppDB.ExecContext(ctx, `UPDATE export_manifests SET status = 'pruned' WHERE id = ?`, id)
+	return err
+}
+
+const exportManifestColumns = `id, schedule_id, filename, location, from_cursor, to_cursor, event_count, bytes,
+		sha256, status, error, created_at`
+
+func (d *DB) scanExportSchedules(rows *sql.Rows) ([]ExportSchedule, error) {
+	schedules := []ExportSchedule{}
+	for rows.Next() {
+		var s ExportSchedule
+		var destJSON, kindsJSON string
+		var nextRun, createdAt, updatedAt int64
+		var lastRun sql.NullInt64
+		var lastStatus, lastError sql.NullString
+
+		if err := rows.Scan(&s.ID, &s.Name, &s.DestinationType, &destJSON, &kindsJSON, &s.IntervalHours,
+			&s.RetentionCount, &s.Enabled, &s.Cursor, &nextRun, &lastRun, &lastStatus, &lastError,
+			&createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+
+		destJSON, err := d.vault.Open(secretExportDestination, destJSON)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(destJSON), &s.Destination)
+		s.Kinds = []int{}
+		json.Unmarshal([]byte(kindsJSON), &s.Kinds)
+		s.NextRunAt = time.Unix(nextRun, 0)
+		if lastRun.Valid {
+			t := time.Unix(lastRun.Int64, 0)
+			s.LastRunAt = &t
+		}
+		s.LastStatus = lastStatus.String
+		s.LastError = lastError.String
+		s.CreatedAt = time.Unix(createdAt, 0)
+		s.UpdatedAt = time.Unix(updatedAt, 0)
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func scanExportManifests(rows *sql.Rows) ([]ExportManifest, error) {
+	manifests := []ExportManifest{}
+	for rows.Next() {
+		var m ExportManifest
+		var location, sha, errMsg sql.NullString
+		var createdAt int64
+
+		if err := rows.Scan(&m.ID, &m.ScheduleID, &m.Filename, &location, &m.FromCursor, &m.ToCursor,
+			&m.EventCount, &m.Bytes, &sha, &m.Status, &errMsg, &createdAt); err != nil {
+			return nil, err
+		}
+
+		m.Location = location.String
+		m.SHA256 = sha.String
+		m.Error = errMsg.String
+		m.CreatedAt = time.Unix(createdAt, 0)
+		manifests = append(manifests, m)
+	}
+	return manifests, rows.Err()
+}
+
+// ============================================================================
+// Moderation
+// ============================================================================
+
+// ModerationSettings controls auto-moderation. An event's score is the sum
+// of the scores of the rules it matches; each threshold is off when 0.
+type ModerationSettings struct {
+	Enabled        bool `json:"enabled"`
+	FlagScore      int  `json:"flag_score"`      // Record the event in the action log
+	DeleteScore    int  `json:"delete_score"`    // Queue the event for deletion
+	BlacklistScore int  `json:"blacklist_score"` // Blacklist the author and delete the event
+	ExemptMembers  bool `json:"exempt_members"`  // Skip events by whitelisted members
+}
+
+// ModerationRule is a spam rule scored against new events.
+type ModerationRule struct {
+	ID        int64                `json:"id"`
+	Name      string               `json:"name"`
+	Type      string               `json:"type"` // duplicate_content, link_density, banned_words, burst_rate
+	Config    ModerationRuleConfig `json:"config"`
+	Score     int                  `json:"score"`
+	Enabled   bool                 `json:"enabled"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// ModerationRuleConfig holds the settings of every rule type; each type
+// reads its own.
+type ModerationRuleConfig struct {
+	Kinds         []int    `json:"kinds,omitempty"`          // Kinds the rule applies to; all if empty
+	WindowSeconds int      `json:"window_seconds,omitempty"` // duplicate_content, burst_rate
+	MaxCount      int      `json:"max_count,omitempty"`      // Copies or events allowed in the window
+	MaxLinks      int      `json:"max_links,omitempty"`      // link_density
+	MaxLinkRatio  float64  `json:"max_link_ratio,omitempty"` // link_density: share of content that is links
+	Patterns      []string `json:"patterns,omitempty"`       // banned_words: regular expressions
+}
+
+// ModerationAction records what auto-moderation did to an event.
+type ModerationAction struct {
+	ID        int64     `json:"id"`
+	EventID   string    `json:"event_id"`
+	Pubkey    string    `json:"pubkey"`
+	Kind      int       `json:"kind"`
+	Score     int       `json:"score"`
+	Rules     []string  `json:"rules"`
+	Action    string    `json:"action"` // flag, delete, blacklist
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModerationActionFilter narrows GetModerationActions.
+type ModerationActionFilter struct {
+	Pubkey string
+	Action string
+	Limit  int
+	Offset int
+}
+
+// DefaultModerationSettings are used until settings are saved: disabled,
+// flagging anything that matches a rule, exempting members.
+var DefaultModerationSettings = ModerationSettings{
+	FlagScore:     1,
+	ExemptMembers: true,
+}
+
+// GetModerationSettings returns the auto-moderation settings.
+func (d *DB) GetModerationSettings(ctx context.Context) (*ModerationSettings, error) {
+	settings := DefaultModerationSettings
+
+	value, err := d.GetAppState(ctx, "moderation_settings")
+	if err != nil || value == "" {
+		return &settings, err
+	}
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse moderation_settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetModerationSettings saves the auto-moderation settings.
+func (d *DB) SetModerationSettings(ctx context.Context, settings *ModerationSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "moderation_settings", string(settingsJSON))
+}
+
+// GetModerationCursor returns the last relay event row ID moderated, or 0
+// if moderation has not started.
+func (d *DB) GetModerationCursor(ctx context.Context) (int64, error) {
+	value, err := d.GetAppState(ctx, "moderation_cursor")
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// SetModerationCursor saves the last relay event row ID moderated.
+func (d *DB) SetModerationCursor(ctx context.Context, rowID int64) error {
+	return d.SetAppState(ctx, "moderation_cursor", strconv.FormatInt(rowID, 10))
+}
+
+const moderationRuleColumns = `id, name, type, config, score, enabled, created_at, updated_at`
+
+// CreateModerationRule inserts a rule and sets its ID.
+func (d *DB) CreateModerationRule(ctx context.Context, rule *ModerationRule) error {
+	configJSON, _ := json.Marshal(rule.Config)
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO moderation_rules (name, type, config, score, enabled)
+		VALUES (?, ?, ?, ?, ?)
+	`, rule.Name, rule.Type, string(configJSON), rule.Score, rule.Enabled)
+	if err != nil {
+		return err
+	}
+	rule.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetModerationRules returns all moderation rules.
+func (d *DB) GetModerationRules(ctx context.Context) ([]ModerationRule, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+moderationRuleColumns+` FROM moderation_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanModerationRules(rows)
+}
+
+// GetModerationRule returns a rule by ID, or nil if it does not exist.
+func (d *DB) GetModerationRule(ctx context.Context, id int64) (*ModerationRule, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+moderationRuleColumns+` FROM moderation_rules WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules, err := scanModerationRules(rows)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	return &rules[0], nil
+}
+
+// UpdateModerationRule saves all mutable rule fields.
+func (d *DB) UpdateModerationRule(ctx context.Context, rule *ModerationRule) error {
+	configJSON, _ := json.Marshal(rule.Config)
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE moderation_rules
+		SET name = ?, type = ?, config = ?, score = ?, enabled = ?, updated_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, rule.Name, rule.Type, string(configJSON), rule.Score, rule.Enabled, rule.ID)
+	return err
+}
+
+// DeleteModerationRule removes a rule. Actions it contributed to are kept.
+func (d *DB) DeleteModerationRule(ctx context.Context, id int64) error {
+	_, err := d.AppDB.ExecContext(ctx, `DELETE FROM moderation_rules WHERE id = ?`, id)
+	return err
+}
+
+// AddModerationAction records an auto-moderation action and sets its ID.
+func (d *DB) AddModerationAction(ctx context.Context, action *ModerationAction) error {
+	rulesJSON, _ := json.Marshal(action.Rules)
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO moderation_actions (event_id, pubkey, kind, score, rules, action)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, action.EventID, action.Pubkey, action.Kind, action.Score, string(rulesJSON), action.Action)
+	if err != nil {
+		return err
+	}
+	action.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetModerationActions returns recorded actions, newest first, and how many
+// match the filter in total.
+func (d *DB) GetModerationActions(ctx context.Context, filter ModerationActionFilter) ([]ModerationAction, int64, error) {
+	where := "WHERE 1=1"
+	var args []interface{}
+	if filter.Pubkey != "" {
+		where += " AND pubkey = ?"
+		args = append(args, filter.Pubkey)
+	}
+	if filter.Action != "" {
+		where += " AND action = ?"
+		args = append(args, filter.Action)
+	}
+
+	var total int64
+	if err := d.AppDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM moderation_actions `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT id, event_id, pubkey, kind, score, rules, action, created_at
+		FROM moderation_actions `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	actions := []ModerationAction{}
+	for rows.Next() {
+		var a ModerationAction
+		var rulesJSON string
+		var createdAt int64
+		if err := rows.Scan(&a.ID, &a.EventID, &a.Pubkey, &a.Kind, &a.Score, &rulesJSON, &a.Action, &createdAt); err != nil {
+			return nil, 0, err
+		}
+		json.Unmarshal([]byte(rulesJSON), &a.Rules)
+		if a.Rules == nil {
+			a.Rules = []string{}
+		}
+		a.CreatedAt = time.Unix(createdAt, 0)
+		actions = append(actions, a)
+	}
+	return actions, total, rows.Err()
+}
+
+// DeleteModerationActionsBefore removes actions recorded before cutoff and
+// returns how many were removed.
+func (d *DB) DeleteModerationActionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, `DELETE FROM moderation_actions WHERE created_at < ?`, cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanModerationRules(rows *sql.Rows) ([]ModerationRule, error) {
+	rules := []ModerationRule{}
+	for rows.Next() {
+		var rule ModerationRule
+		var configJSON string
+		var createdAt, updatedAt int64
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.Type, &configJSON, &rule.Score, &rule.Enabled,
+			&createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(configJSON), &rule.Config)
+		rule.CreatedAt = time.Unix(createdAt, 0)
+		rule.UpdatedAt = time.Unix(updatedAt, 0)
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// ============================================================================
+// IP Bans and Rate Limits
+// ============================================================================
+
+// RateLimit is a token bucket: PerMinute requests refill the bucket each
+// minute and up to Burst can be made at once. PerMinute 0 disables it.
+type RateLimit struct {
+	PerMinute int `json:"per_minute"`
+	Burst     int `json:"burst"`
+}
+
+// RateLimitSettings holds the per-IP rate limits for each route group and
+// when clients that keep hitting the public limits are banned.
+type RateLimitSettings struct {
+	Enabled           bool      `json:"enabled"`
+	Signup            RateLimit `json:"signup"`              // POST /public/create-invoice and /public/renew/
+	Public            RateLimit `json:"public"`              // Other /public/ routes
+	API               RateLimit `json:"api"`                 // /api/ routes
+	Events            RateLimit `json:"events"`              // Events per author, when the relay asks the admission server
+	AutoBanViolations int       `json:"auto_ban_violations"` // Rejected public requests in a minute before a ban; 0 disables
+	AutoBanMinutes    int       `json:"auto_ban_minutes"`    // Length of automatic bans
+}
+
+// DefaultRateLimitSettings are used until settings are saved.
+var DefaultRateLimitSettings = RateLimitSettings{
+	Enabled:           true,
+	Signup:            RateLimit{PerMinute: 6, Burst: 3},
+	Public:            RateLimit{PerMinute: 120, Burst: 60},
+	API:               RateLimit{PerMinute: 600, Burst: 200},
+	Events:            RateLimit{PerMinute: 60, Burst: 30},
+	AutoBanViolations: 60,
+	AutoBanMinutes:    60,
+}
+
+// IPBan blocks an address or CIDR range from the API.
+type IPBan struct {
+	IP        string     `json:"ip"` // Address or CIDR prefix
+	Reason    string     `json:"reason,omitempty"`
+	Source    string     `json:"source"` // manual or auto
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil for a permanent ban
+}
+
+// GetRateLimitSettings returns the rate limit settings.
+func (d *DB) GetRateLimitSettings(ctx context.Context) (*RateLimitSettings, error) {
+	settings := DefaultRateLimitSettings
+
+	value, err := d.GetAppState(ctx, "rate_limit_settings")
+	if err != nil || value == "" {
+		return &settings, err
+	}
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse rate_limit_settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetRateLimitSettings saves the rate limit settings.
+func (d *DB) SetRateLimitSettings(ctx context.Context, settings *RateLimitSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "rate_limit_settings", string(settingsJSON))
+}
+
+// AddIPBan bans an address or range, replacing any ban on the same one.
+func (d *DB) AddIPBan(ctx context.Context, ban *IPBan) error {
+	var expiresAt interface{}
+	if ban.ExpiresAt != nil {
+		expiresAt = ban.ExpiresAt.Unix()
+	}
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO ip_bans (ip, reason, source, created_at, expires_at)
+		VALUES (?, ?, ?, strftime('%s', 'now'), ?)
+		ON CONFLICT(ip) DO UPDATE SET
+			reason = excluded.reason,
+			source = excluded.source,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+	`, ban.IP, nullString(ban.Reason), ban.Source, expiresAt)
+	return err
+}
+
+// RemoveIPBan lifts a ban, reporting whether there was one.
+func (d *DB) RemoveIPBan(ctx context.Context, ip string) (bool, error) {
+	result, err := d.AppDB.ExecContext(ctx, `DELETE FROM ip_bans WHERE ip = ?`, ip)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// GetIPBans returns the bans in effect at now, newest first.
+func (d *DB) GetIPBans(ctx context.Context, now time.Time) ([]IPBan, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT ip, reason, source, created_at, expires_at
+		FROM ip_bans
+		WHERE expires_at IS NULL OR expires_at > ?
+		ORDER BY created_at DESC, ip
+	`, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []IPBan{}
+	for rows.Next() {
+		var ban IPBan
+		var reason sql.NullString
+		var createdAt int64
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&ban.IP, &reason, &ban.Source, &createdAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		ban.Reason = reason.String
+		ban.CreatedAt = time.Unix(createdAt, 0)
+		if expiresAt.Valid {
+			t := time.Unix(expiresAt.Int64, 0)
+			ban.ExpiresAt = &t
+		}
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
+
+// DeleteExpiredIPBans removes bans that expired before now and returns how
+// many were removed.
+func (d *DB) DeleteExpiredIPBans(ctx context.Context, now time.Time) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, `DELETE FROM ip_bans WHERE expires_at IS NOT NULL AND expires_at <= ?`, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ============================================================================
+// Subscription Renewals and Expiry
+// ============================================================================
+
+// RenewalSettings controls renewal invoices for paid users nearing expiry.
+type RenewalSettings struct {
+	Enabled    bool   `json:"enabled"`
+	DaysBefore int    `json:"days_before"` // Days before expiry to create the invoice
+	SendDM     bool   `json:"send_dm"`     // Send the invoice by Nostr DM
+	RenewURL   string `json:"renew_url"`   // Public base URL for renewal links in DMs
+}
+
+// DefaultRenewalSettings are used until settings are saved.
+var DefaultRenewalSettings = RenewalSettings{
+	Enabled:    true,
+	DaysBefore: 7,
+}
+
+// GetRenewalSettings returns the subscription renewal settings.
+func (d *DB) GetRenewalSettings(ctx context.Context) (*RenewalSettings, error) {
+	settings := DefaultRenewalSettings
+
+	value, err := d.GetAppState(ctx, "renewal_settings")
+	if err != nil || value == "" {
+		return &settings, err
+	}
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse renewal_settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetRenewalSettings saves the subscription renewal settings.
+func (d *DB) SetRenewalSettings(ctx context.Context, settings *RenewalSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "renewal_settings", string(settingsJSON))
+}
+
+// ExpirySettings controls what happens when a paid subscription expires.
+type ExpirySettings struct {
+	GraceDays int  `json:"grace_days"` // Days an expired user keeps access with status 'grace'; 0 cuts off at expiry
+	NotifyDM  bool `json:"notify_dm"`  // Tell the user by Nostr DM when grace starts and access ends
+
+	// Tell users by Nostr DM when their signup invoice expired unpaid
+	NotifyInvoiceDM bool `json:"notify_invoice_dm"`
+}
+
+// GetExpirySettings returns the subscription expiry settings.
+func (d *DB) GetExpirySettings(ctx context.Context) (*ExpirySettings, error) {
+	var settings ExpirySettings
+
+	value, err := d.GetAppState(ctx, "expiry_settings")
+	if err != nil || value == "" {
+		return &settings, err
+	}
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse expiry_settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetExpirySettings saves the subscription expiry settings.
+func (d *DB) SetExpirySettings(ctx context.Context, settings *ExpirySettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "expiry_settings", string(settingsJSON))
+}
+
+// ============================================================================
+// Coupons
+// ============================================================================
+
+// Coupon kinds.
+const (
+	CouponPercent = "percent" // Value percent off the tier price
+	CouponFixed   = "fixed"   // Value sats off the tier price
+	CouponFree    = "free"    // Access without payment
+)
+
+// Coupon is a code that discounts paid access or grants it for free.
+type Coupon struct {
+	Code      string     `json:"code"`
+	Kind      string     `json:"kind"`
+	Value     int64      `json:"value"`
+	TierID    string     `json:"tier_id,omitempty"`  // Only valid for this tier; empty for any
+	MaxUses   *int       `json:"max_uses,omitempty"` // nil for unlimited
+	Uses      int        `json:"uses"`               // Redeemed plus held by open invoices
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Enabled   bool       `json:"enabled"`
+	Note      string     `json:"note,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CouponRedemption is a pubkey's use of a coupon. A pending redemption holds
+// a use until its invoice is paid or expires.
+type CouponRedemption struct {
+	ID           int64      `json:"id"`
+	Code         string     `json:"code"`
+	Pubkey       string     `json:"pubkey"`
+	PaymentHash  string     `json:"payment_hash,omitempty"` // empty for free access
+	TierID       string     `json:"tier_id"`
+	AmountSats   int64      `json:"amount_sats"`
+	DiscountSats int64      `json:"discount_sats"`
+	Status       string     `json:"status"` // pending or redeemed
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RedeemedAt   *time.Time `json:"redeemed_at,omitempty"`
+}
+
+const couponColumns = `
+	c.code, c.kind, c.value, c.tier_id, c.max_uses, c.expires_at, c.enabled, c.note, c.created_at,
+	(SELECT COUNT(DISTINCT r.pubkey) FROM coupon_redemptions r
+		WHERE r.code = c.code AND (r.status = 'redeemed' OR r.expires_at > strftime('%s', 'now')))
+`
+
+func scanCoupon(row interface{ Scan(...interface{}) error }) (*Coupon, error) {
+	var c Coupon
+	var tierID, note sql.NullString
+	var maxUses, expiresAt sql.NullInt64
+	var createdAt int64
+
+	if err := row.Scan(&c.Code, &c.Kind, &c.Value, &tierID, &maxUses, &expiresAt, &c.Enabled, &note, &createdAt, &c.Uses); err != nil {
+		return nil, err
+	}
+
+	c.TierID = tierID.String
+	c.Note = note.String
+	c.CreatedAt = time.Unix(createdAt, 0)
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		c.MaxUses = &n
+	}
+	if expiresAt.Valid {
+		t := time.Unix(expiresAt.Int64, 0)
+		c.ExpiresAt = &t
+	}
+	return &c, nil
+}
+
+// GetCoupons returns all coupons, newest first.
+func (d *DB) GetCoupons(ctx context.Context) ([]Coupon, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `SELECT `+couponColumns+` FROM coupons c ORDER BY c.created_at DESC, c.code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := []Coupon{}
+	for rows.Next() {
+		c, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, *c)
+	}
+	return coupons, rows.Err()
+}
+
+// GetCoupon returns a coupon by code, or nil if there is none.
+func (d *DB) GetCoupon(ctx context.Context, code string) (*Coupon, error) {
+	c, err := scanCoupon(d.AppDB.QueryRowContext(ctx, `SELECT `+couponColumns+` FROM coupons c WHERE c.code = ?`, code))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// couponArgs returns the nullable columns of a coupon.
+func couponArgs(c *Coupon) (maxUses, expiresAt interface{}) {
+	if c.MaxUses != nil {
+		maxUses = *c.MaxUses
+	}
+	if c.ExpiresAt != nil {
+		expiresAt = c.ExpiresAt.Unix()
+	}
+	return maxUses, expiresAt
+}
+
+// CreateCoupon adds a coupon, reporting false if the code is taken.
+func (d *DB) CreateCoupon(ctx context.Context, c *Coupon) (bool, error) {
+	maxUses, expiresAt := couponArgs(c)
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO coupons (code, kind, value, tier_id, max_uses, expires_at, enabled, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(code) DO NOTHING
+	`, c.Code, c.Kind, c.Value, nullString(c.TierID), maxUses, expiresAt, c.Enabled, nullString(c.Note))
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// UpdateCoupon saves a coupon's terms, reporting whether it exists.
+func (d *DB) UpdateCoupon(ctx context.Context, c *Coupon) (bool, error) {
+	maxUses, expiresAt := couponArgs(c)
+	result, err := d.AppDB.ExecContext(ctx, `
+		UPDATE coupons
+		SET kind = ?, value = ?, tier_id = ?, max_uses = ?, expires_at = ?, enabled = ?, note = ?
+		WHERE code = ?
+	`, c.Kind, c.Value, nullString(c.TierID), maxUses, expiresAt, c.Enabled, nullString(c.Note), c.Code)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteCoupon removes a coupon, reporting whether there was one. Its
+// redemptions are kept.
+func (d *DB) DeleteCoupon(ctx context.Context, code string) (bool, error) {
+	result, err := d.AppDB.ExecContext(ctx, `DELETE FROM coupons WHERE code = ?`, code)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ErrCouponUsedUp is returned by AddCouponRedemption when the coupon's
+// max_uses are taken by other pubkeys.
+var ErrCouponUsedUp = errors.New("coupon code has no uses left")
+
+// couponUsedByOthers counts the pubkeys other than the second argument
+// holding a use of the code given first: a redemption, or a reservation
+// whose invoice is still open.
+const couponUsedByOthers = `(SELECT COUNT(DISTINCT pubkey) FROM coupon_redemptions
+	WHERE code = ? AND pubkey != ? AND (status = 'redeemed' OR expires_at > strftime('%s', 'now')))`
+
+// AddCouponRedemption records a pubkey's use of a coupon. Pending
+// redemptions are keyed by their invoice's payment hash, so each open
+// invoice keeps its own. A completed redemption replaces the pubkey's
+// pending ones and is never replaced itself. The coupon's max_uses is
+// checked in the insert, so concurrent signups cannot exceed it; a pubkey
+// that already holds a use may replace it.
+func (d *DB) AddCouponRedemption(ctx context.Context, r *CouponRedemption) error {
+	var expiresAt, redeemedAt interface{}
+	if r.ExpiresAt != nil {
+		expiresAt = r.ExpiresAt.Unix()
+	}
+	if r.Status == "redeemed" {
+		redeemedAt = time.Now().Unix()
+	}
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO coupon_redemptions (code, pubkey, payment_hash, tier_id, amount_sats, discount_sats, status, expires_at, redeemed_at)
+			SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?
+			FROM coupons c
+			WHERE c.code = ? AND (c.max_uses IS NULL OR `+couponUsedByOthers+` < c.max_uses)
+			ON CONFLICT DO NOTHING
+		`, r.Code, r.Pubkey, nullString(r.PaymentHash), r.TierID, r.AmountSats, r.DiscountSats, r.Status, expiresAt, redeemedAt,
+			r.Code, r.Code, r.Pubkey)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			// Nothing inserted: either the use is taken, or the redemption
+			// conflicts with one already recorded
+			var usedUp bool
+			if err := tx.QueryRowContext(ctx, `
+				SELECT COUNT(*) > 0 FROM coupons c
+				WHERE c.code = ? AND c.max_uses IS NOT NULL AND `+couponUsedByOthers+` >= c.max_uses
+			`, r.Code, r.Code, r.Pubkey).Scan(&usedUp); err != nil {
+				return err
+			}
+			if usedUp {
+				return ErrCouponUsedUp
+			}
+			return nil
+		}
+		if r.Status != "redeemed" {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM coupon_redemptions WHERE code = ? AND pubkey = ? AND status = 'pending'
+		`, r.Code, r.Pubkey)
+		return err
+	})
+}
+
+// ReassignCouponReservation moves the pending redemption reserved for an
+// invoice to the invoice that replaced it.
+func (d *DB) ReassignCouponReservation(ctx context.Context, paymentHash, newPaymentHash string, expiresAt time.Time) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE coupon_redemptions SET payment_hash = ?, expires_at = ?
+		WHERE payment_hash = ? AND status = 'pending'
+	`, newPaymentHash, expiresAt.Unix(), paymentHash)
+	return err
+}
+
+// ReleaseCouponReservation removes the pending redemption reserved for a
+// cancelled invoice, freeing the use of the coupon.
+func (d *DB) ReleaseCouponReservation(ctx context.Context, paymentHash string) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		DELETE FROM coupon_redemptions WHERE payment_hash = ? AND status = 'pending'
+	`, paymentHash)
+	return err
+}
+
+// RedeemCouponPayment completes the pending redemption paid by an invoice,
+// if there is one, and drops the pubkey's other pending redemptions of the
+// code. If the pubkey already redeemed the code, the payment uses nothing.
+func (d *DB) RedeemCouponPayment(ctx context.Context, paymentHash string) error {
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		var code, pubkey string
+		err := tx.QueryRowContext(ctx, `
+			SELECT code, pubkey FROM coupon_redemptions WHERE payment_hash = ? AND status = 'pending'
+		`, paymentHash).Scan(&code, &pubkey)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE coupon_redemptions
+			SET status = 'redeemed', redeemed_at = strftime('%s', 'now'), expires_at = NULL
+			WHERE payment_hash = ? AND NOT EXISTS (
+				SELECT 1 FROM coupon_redemptions WHERE code = ? AND pubkey = ? AND status = 'redeemed'
+			)
+		`, paymentHash, code, pubkey)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM coupon_redemptions WHERE code = ? AND pubkey = ? AND status = 'pending'
+		`, code, pubkey)
+		return err
+	})
+}
+
+// GetCouponRedemption returns a pubkey's redemption of a coupon, or nil if
+// there is none. A completed redemption is returned ahead of pending ones,
+// then the pending one whose invoice expires last.
+func (d *DB) GetCouponRedemption(ctx context.Context, code, pubkey string) (*CouponRedemption, error) {
+	var r CouponRedemption
+	var paymentHash sql.NullString
+	var createdAt int64
+	var expiresAt, redeemedAt sql.NullInt64
+
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT id, code, pubkey, payment_hash, tier_id, amount_sats, discount_sats, status, created_at, expires_at, redeemed_at
+		FROM coupon_redemptions WHERE code = ? AND pubkey = ?
+		ORDER BY status = 'redeemed' DESC, expires_at DESC, id DESC
+		LIMIT 1
+	`, code, pubkey).Scan(&r.ID, &r.Code, &r.Pubkey, &paymentHash, &r.TierID, &r.AmountSats, &r.DiscountSats, &r.Status, &createdAt, &expiresAt, &redeemedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.PaymentHash = paymentHash.String
+	r.CreatedAt = time.Unix(createdAt, 0)
+	if expiresAt.Valid {
+		t := time.Unix(expiresAt.Int64, 0)
+		r.ExpiresAt = &t
+	}
+	if redeemedAt.Valid {
+		t := time.Unix(redeemedAt.Int64, 0)
+		r.RedeemedAt = &t
+	}
+	return &r, nil
+}
+
+// GetCouponRedemptions returns a coupon's redemptions, newest first. Pending
+// redemptions whose invoice expired are left out.
+func (d *DB) GetCouponRedemptions(ctx context.Context, code string) ([]CouponRedemption, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT id, code, pubkey, payment_hash, tier_id, amount_sats, discount_sats, status, created_at, expires_at, redeemed_at
+		FROM coupon_redemptions
+		WHERE code = ? AND (status = 'redeemed' OR expires_at > strftime('%s', 'now'))
+		ORDER BY created_at DESC, id DESC
+	`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	redemptions := []CouponRedemption{}
+	for rows.Next() {
+		var r CouponRedemption
+		var paymentHash sql.NullString
+		var createdAt int64
+		var expiresAt, redeemedAt sql.NullInt64
+
+		err := rows.Scan(&r.ID, &r.Code, &r.Pubkey, &paymentHash, &r.TierID, &r.AmountSats, &r.DiscountSats, &r.Status, &createdAt, &expiresAt, &redeemedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		r.PaymentHash = paymentHash.String
+		r.CreatedAt = time.Unix(createdAt, 0)
+		if expiresAt.Valid {
+			t := time.Unix(expiresAt.Int64, 0)
+			r.ExpiresAt = &t
+		}
+		if redeemedAt.Valid {
+			t := time.Unix(redeemedAt.Int64, 0)
+			r.RedeemedAt = &t
+		}
+		redemptions = append(redemptions, r)
 	}
+	return redemptions, rows.Err()
+}
 
-	return invoices, rows.Err()
+// ============================================================================
+// Zaps
+// ============================================================================
+
+// ZapSettings controls paying for access by zapping the relay's Lightning
+// address (NIP-57).
+type ZapSettings struct {
+	Enabled  bool   `json:"enabled"`
+	Username string `json:"username"` // Name part of the Lightning address, name@host
 }
 
-// ExpirePendingInvoices marks expired invoices as expired.
-func (d *DB) ExpirePendingInvoices(ctx context.Context) (int64, error) {
-	result, err := d.AppDB.ExecContext(ctx, `
-		UPDATE pending_invoices SET status = 'expired'
-		WHERE status = 'pending' AND expires_at < strftime('%s', 'now')
-	`)
+// DefaultZapSettings are used until settings are saved.
+var DefaultZapSettings = ZapSettings{
+	Username: "relay",
+}
+
+// GetZapSettings returns the zap payment settings.
+func (d *DB) GetZapSettings(ctx context.Context) (*ZapSettings, error) {
+	settings := DefaultZapSettings
+
+	value, err := d.GetAppState(ctx, "zap_settings")
+	if err != nil || value == "" {
+		return &settings, err
+	}
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse zap_settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetZapSettings saves the zap payment settings.
+func (d *DB) SetZapSettings(ctx context.Context, settings *ZapSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "zap_settings", string(settingsJSON))
+}
+
+// ZapRequest is a zap request whose invoice pays for access. ZapRequest is
+// the event JSON exactly as the invoice's description hash commits to it.
+type ZapRequest struct {
+	PaymentHash    string     `json:"payment_hash"`
+	Pubkey         string     `json:"pubkey"`
+	ZapRequest     string     `json:"zap_request"`
+	PaymentRequest string     `json:"payment_request"`
+	ReceiptID      string     `json:"receipt_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ReceiptAt      *time.Time `json:"receipt_at,omitempty"`
+}
+
+// AddZapRequest stores the zap request behind an invoice.
+func (d *DB) AddZapRequest(ctx context.Context, z *ZapRequest) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO zap_requests (payment_hash, pubkey, zap_request, payment_request)
+		VALUES (?, ?, ?, ?)
+	`, z.PaymentHash, z.Pubkey, z.ZapRequest, z.PaymentRequest)
+	return err
+}
+
+// GetZapRequest returns the zap request behind an invoice, or nil if the
+// invoice was not a zap.
+func (d *DB) GetZapRequest(ctx context.Context, paymentHash string) (*ZapRequest, error) {
+	var z ZapRequest
+	var receiptID sql.NullString
+	var createdAt int64
+	var receiptAt sql.NullInt64
+
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT payment_hash, pubkey, zap_request, payment_request, receipt_id, created_at, receipt_at
+		FROM zap_requests WHERE payment_hash = ?
+	`, paymentHash).Scan(&z.PaymentHash, &z.Pubkey, &z.ZapRequest, &z.PaymentRequest, &receiptID, &createdAt, &receiptAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return result.RowsAffected()
+
+	z.ReceiptID = receiptID.String
+	z.CreatedAt = time.Unix(createdAt, 0)
+	if receiptAt.Valid {
+		t := time.Unix(receiptAt.Int64, 0)
+		z.ReceiptAt = &t
+	}
+	return &z, nil
+}
+
+// SetZapReceipt records the zap receipt published for an invoice.
+func (d *DB) SetZapReceipt(ctx context.Context, paymentHash, receiptID string) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE zap_requests SET receipt_id = ?, receipt_at = strftime('%s', 'now')
+		WHERE payment_hash = ?
+	`, receiptID, paymentHash)
+	return err
 }
 
 // ============================================================================
-// Lightning Configuration
+// Cashu
 // ============================================================================
 
-// LightningConfig represents the Lightning node configuration.
-type LightningConfig struct {
-	NodeType       string     `json:"node_type"`        // 'lnd' or 'cln'
-	Endpoint       string     `json:"endpoint"`         // e.g., "umbrel.local:8080"
-	Macaroon       string     `json:"macaroon"`         // hex-encoded macaroon
-	Cert           string     `json:"cert,omitempty"`   // optional TLS cert
-	Enabled        bool       `json:"enabled"`
-	LastVerifiedAt *time.Time `json:"last_verified_at,omitempty"`
-	UpdatedAt      time.Time  `json:"updated_at"`
+// CashuSettings controls paying for access with Cashu ecash tokens.
+type CashuSettings struct {
+	Enabled bool     `json:"enabled"`
+	Mints   []string `json:"mints"` // Mint URLs whose tokens are accepted
 }
 
-// GetLightningConfig retrieves the Lightning node configuration.
-func (d *DB) GetLightningConfig(ctx context.Context) (*LightningConfig, error) {
-	var cfg LightningConfig
-	var nodeType, endpoint, macaroon, cert sql.NullString
-	var enabled int
-	var lastVerifiedAt, updatedAt sql.NullInt64
+// GetCashuSettings returns the Cashu payment settings.
+func (d *DB) GetCashuSettings(ctx context.Context) (*CashuSettings, error) {
+	settings := CashuSettings{Mints: []string{}}
 
-	err := d.AppDB.QueryRowContext(ctx, `
-		SELECT node_type, endpoint, macaroon, cert, enabled, last_verified_at, updated_at
-		FROM lightning_config WHERE id = 1
-	`).Scan(&nodeType, &endpoint, &macaroon, &cert, &enabled, &lastVerifiedAt, &updatedAt)
+	value, err := d.GetAppState(ctx, "cashu_settings")
+	if err != nil || value == "" {
+		return &settings, err
+	}
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse cashu_settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetCashuSettings saves the Cashu payment settings.
+func (d *DB) SetCashuSettings(ctx context.Context, settings *CashuSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "cashu_settings", string(settingsJSON))
+}
+
+// CashuPayment is a Cashu token melted at a mint to pay an access invoice.
+// The token itself is not stored.
+type CashuPayment struct {
+	PaymentHash    string    `json:"payment_hash"`
+	Pubkey         string    `json:"pubkey"`
+	Mint           string    `json:"mint"`
+	TokenSats      int64     `json:"token_sats"`
+	FeeReserveSats int64     `json:"fee_reserve_sats"`
+	Status         string    `json:"status"` // pending, paid or failed
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AddCashuPayment records a token about to be melted for an invoice.
+func (d *DB) AddCashuPayment(ctx context.Context, p *CashuPayment) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		INSERT INTO cashu_payments (payment_hash, pubkey, mint, token_sats, fee_reserve_sats, status)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, p.PaymentHash, p.Pubkey, p.Mint, p.TokenSats, p.FeeReserveSats, p.Status)
+	return err
+}
+
+// UpdateCashuPaymentStatus sets the status of a Cashu payment.
+func (d *DB) UpdateCashuPaymentStatus(ctx context.Context, paymentHash, status string) error {
+	_, err := d.AppDB.ExecContext(ctx, `
+		UPDATE cashu_payments SET status = ? WHERE payment_hash = ?
+	`, status, paymentHash)
+	return err
+}
+
+// GetCashuPayment returns the Cashu payment for an invoice, or nil if the
+// invoice was not paid with Cashu.
+func (d *DB) GetCashuPayment(ctx context.Context, paymentHash string) (*CashuPayment, error) {
+	var p CashuPayment
+	var createdAt int64
 
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT payment_hash, pubkey, mint, token_sats, fee_reserve_sats, status, created_at
+		FROM cashu_payments WHERE payment_hash = ?
+	`, paymentHash).Scan(&p.PaymentHash, &p.Pubkey, &p.Mint, &p.TokenSats, &p.FeeReserveSats, &p.Status, &createdAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	p.CreatedAt = time.Unix(createdAt, 0)
+	return &p, nil
+}
 
-	cfg.NodeType = nodeType.String
-	cfg.Endpoint = endpoint.String
-	cfg.Macaroon = macaroon.String
-	cfg.Cert = cert.String
-	cfg.Enabled = enabled == 1
-	if lastVerifiedAt.Valid {
-		t := time.Unix(lastVerifiedAt.Int64, 0)
-		cfg.LastVerifiedAt = &t
+// ============================================================================
+// Access Status
+// ============================================================================
+
+// AccessStatusSettings controls the public lookup users make of their own
+// access.
+type AccessStatusSettings struct {
+	Enabled bool `json:"enabled"`
+}
+
+// DefaultAccessStatusSettings are used until settings are saved.
+var DefaultAccessStatusSettings = AccessStatusSettings{
+	Enabled: true,
+}
+
+// GetAccessStatusSettings returns the public access status settings.
+func (d *DB) GetAccessStatusSettings(ctx context.Context) (*AccessStatusSettings, error) {
+	settings := DefaultAccessStatusSettings
+
+	value, err := d.GetAppState(ctx, "access_status_settings")
+	if err != nil || value == "" {
+		return &settings, err
 	}
-	if updatedAt.Valid {
-		cfg.UpdatedAt = time.Unix(updatedAt.Int64, 0)
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse access_status_settings: %w", err)
 	}
+	return &settings, nil
+}
 
-	return &cfg, nil
+// SetAccessStatusSettings saves the public access status settings.
+func (d *DB) SetAccessStatusSettings(ctx context.Context, settings *AccessStatusSettings) error {
+	settingsJSON, _ := json.Marshal(settings)
+	return d.SetAppState(ctx, "access_status_settings", string(settingsJSON))
 }
 
-// SaveLightningConfig saves or updates the Lightning node configuration.
-func (d *DB) SaveLightningConfig(ctx context.Context, cfg *LightningConfig) error {
-	var lastVerifiedAt interface{}
-	if cfg.LastVerifiedAt != nil {
-		lastVerifiedAt = cfg.LastVerifiedAt.Unix()
+// ============================================================================
+// Kind Policies
+// ============================================================================
+
+// KindPolicyTierWhitelist is the tier of whitelisted members without paid
+// access. Paid members are in the tier of their pricing tier ID.
+const KindPolicyTierWhitelist = "whitelist"
+
+// KindPolicy limits the event kinds the members of an access tier may post.
+type KindPolicy struct {
+	Tier       string `json:"tier"`
+	AllowKinds []int  `json:"allow_kinds"` // empty allows every kind not denied
+	DenyKinds  []int  `json:"deny_kinds"`
+}
+
+// Permits reports whether the policy lets members post events of kind.
+func (p *KindPolicy) Permits(kind int) bool {
+	if slices.Contains(p.DenyKinds, kind) {
+		return false
 	}
+	return len(p.AllowKinds) == 0 || slices.Contains(p.AllowKinds, kind)
+}
 
-	enabled := 0
-	if cfg.Enabled {
-		enabled = 1
+// GetKindPolicies returns the kind policies, one per tier at most.
+func (d *DB) GetKindPolicies(ctx context.Context) ([]KindPolicy, error) {
+	policies := []KindPolicy{}
+
+	value, err := d.GetAppState(ctx, "kind_policies")
+	if err != nil || value == "" {
+		return policies, err
 	}
+	if err := json.Unmarshal([]byte(value), &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse kind_policies: %w", err)
+	}
+	return policies, nil
+}
 
-	_, err := d.AppDB.ExecContext(ctx, `
-		INSERT INTO lightning_config (id, node_type, endpoint, macaroon, cert, enabled, last_verified_at, updated_at)
-		VALUES (1, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'))
-		ON CONFLICT(id) DO UPDATE SET
-			node_type = excluded.node_type,
-			endpoint = excluded.endpoint,
-			macaroon = excluded.macaroon,
-			cert = excluded.cert,
-			enabled = excluded.enabled,
-			last_verified_at = excluded.last_verified_at,
-			updated_at = excluded.updated_at
-	`, cfg.NodeType, nullString(cfg.Endpoint), nullString(cfg.Macaroon), nullString(cfg.Cert), enabled, lastVerifiedAt)
-	return err
+// SetKindPolicies saves the kind policies.
+func (d *DB) SetKindPolicies(ctx context.Context, policies []KindPolicy) error {
+	policiesJSON, _ := json.Marshal(policies)
+	return d.SetAppState(ctx, "kind_policies", string(policiesJSON))
 }
 
-// SetLightningEnabled enables or disables the Lightning integration.
-func (d *DB) SetLightningEnabled(ctx context.Context, enabled bool) error {
-	enabledInt := 0
-	if enabled {
-		enabledInt = 1
+// GetKindPolicyCursor returns the last relay event row ID checked against
+// the kind policies, or 0 if none has been.
+func (d *DB) GetKindPolicyCursor(ctx context.Context) (int64, error) {
+	value, err := d.GetAppState(ctx, "kind_policy_cursor")
+	if err != nil || value == "" {
+		return 0, err
 	}
+	return strconv.ParseInt(value, 10, 64)
+}
 
-	_, err := d.AppDB.ExecContext(ctx, `
-		UPDATE lightning_config SET enabled = ?, updated_at = strftime('%s', 'now') WHERE id = 1
-	`, enabledInt)
-	return err
+// SetKindPolicyCursor saves the last relay event row ID checked against the
+// kind policies.
+func (d *DB) SetKindPolicyCursor(ctx context.Context, rowID int64) error {
+	return d.SetAppState(ctx, "kind_policy_cursor", strconv.FormatInt(rowID, 10))
 }
 
-// SetLightningVerified updates the last verified timestamp.
-func (d *DB) SetLightningVerified(ctx context.Context) error {
-	_, err := d.AppDB.ExecContext(ctx, `
-		UPDATE lightning_config SET last_verified_at = strftime('%s', 'now'), updated_at = strftime('%s', 'now') WHERE id = 1
-	`)
+// ============================================================================
+// Quotas
+// ============================================================================
+
+// Quotas a policy can set.
+const (
+	QuotaEventsPerDay = "events_per_day"
+	QuotaStorage      = "storage"
+)
+
+// What happens when a member goes over a quota. Members are always told.
+const (
+	QuotaActionNotify    = "notify"
+	QuotaActionBlacklist = "blacklist"
+)
+
+// QuotaPolicy limits how much the members of an access tier may post. Tiers
+// are those of kind policies: KindPolicyTierWhitelist or a pricing tier ID.
+type QuotaPolicy struct {
+	Tier            string `json:"tier"`
+	EventsPerDay    int64  `json:"events_per_day"`    // 0 for no limit
+	MaxStorageBytes int64  `json:"max_storage_bytes"` // 0 for no limit
+	Action          string `json:"action"`
+}
+
+// QuotaBreach records a member going over a quota.
+type QuotaBreach struct {
+	Pubkey    string    `json:"pubkey"`
+	Quota     string    `json:"quota"`
+	Period    string    `json:"period,omitempty"` // UTC day for daily quotas
+	Usage     int64     `json:"usage"`
+	Limit     int64     `json:"limit"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetQuotaPolicies returns the quota policies, one per tier at most.
+func (d *DB) GetQuotaPolicies(ctx context.Context) ([]QuotaPolicy, error) {
+	policies := []QuotaPolicy{}
+
+	value, err := d.GetAppState(ctx, "quota_policies")
+	if err != nil || value == "" {
+		return policies, err
+	}
+	if err := json.Unmarshal([]byte(value), &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse quota_policies: %w", err)
+	}
+	return policies, nil
+}
+
+// SetQuotaPolicies saves the quota policies.
+func (d *DB) SetQuotaPolicies(ctx context.Context, policies []QuotaPolicy) error {
+	policiesJSON, _ := json.Marshal(policies)
+	return d.SetAppState(ctx, "quota_policies", string(policiesJSON))
+}
+
+// RecordQuotaBreach records a breach, reporting whether it is new, i.e. the
+// member had not gone over the quota in the same period yet.
+func (d *DB) RecordQuotaBreach(ctx context.Context, breach QuotaBreach) (bool, error) {
+	result, err := d.AppDB.ExecContext(ctx, `
+		INSERT OR IGNORE INTO quota_breaches (pubkey, quota, period, usage, quota_limit, action)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, breach.Pubkey, breach.Quota, breach.Period, breach.Usage, breach.Limit, breach.Action)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetQuotaBreaches returns a pubkey's breaches, newest first.
+func (d *DB) GetQuotaBreaches(ctx context.Context, pubkey string, limit int) ([]QuotaBreach, error) {
+	rows, err := d.AppDB.QueryContext(ctx, `
+		SELECT pubkey, quota, period, usage, quota_limit, action, created_at
+		FROM quota_breaches
+		WHERE pubkey = ?
+		ORDER BY created_at DESC, quota
+		LIMIT ?
+	`, pubkey, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breaches := []QuotaBreach{}
+	for rows.Next() {
+		var b QuotaBreach
+		var createdAt int64
+		if err := rows.Scan(&b.Pubkey, &b.Quota, &b.Period, &b.Usage, &b.Limit, &b.Action, &createdAt); err != nil {
+			return nil, err
+		}
+		b.CreatedAt = time.Unix(createdAt, 0)
+		breaches = append(breaches, b)
+	}
+	return breaches, rows.Err()
+}
+
+// ClearQuotaBreach removes a pubkey's breach of a quota in a period, so it
+// is reported again if it happens again, e.g. after lifting a blacklisting.
+func (d *DB) ClearQuotaBreach(ctx context.Context, pubkey, quota, period string) error {
+	_, err := d.AppDB.ExecContext(ctx, "DELETE FROM quota_breaches WHERE pubkey = ? AND quota = ? AND period = ?", pubkey, quota, period)
 	return err
 }
 
+// DeleteQuotaBreachesBefore drops breaches recorded before the cutoff.
+func (d *DB) DeleteQuotaBreachesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := d.AppDB.ExecContext(ctx, "DELETE FROM quota_breaches WHERE created_at < ?", before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ============================================================================
+// Scheduled Reports
+// ============================================================================
+
+// ReportSchedule controls the operator's periodic summary report.
+type ReportSchedule struct {
+	Enabled   bool   `json:"enabled"`
+	Frequency string `json:"frequency"` // weekly, monthly
+	Channel   string `json:"channel"`   // dm, email
+	Email     string `json:"email,omitempty"`
+}
+
+// ReportRecord describes the last report sent.
+type ReportRecord struct {
+	At           time.Time `json:"at"`
+	PeriodStart  time.Time `json:"period_start"`
+	PeriodEnd    time.Time `json:"period_end"`
+	Channel      string    `json:"channel"`
+	DatabaseSize int64     `json:"database_size"` // Relay database size when sent, for the next report's growth
+}
+
+// GetReportSchedule returns the report schedule, a disabled weekly DM if
+// none is saved.
+func (d *DB) GetReportSchedule(ctx context.Context) (*ReportSchedule, error) {
+	schedule := &ReportSchedule{Frequency: "weekly", Channel: "dm"}
+
+	value, err := d.GetAppState(ctx, "report_schedule")
+	if err != nil || value == "" {
+		return schedule, err
+	}
+	if err := json.Unmarshal([]byte(value), schedule); err != nil {
+		return nil, fmt.Errorf("failed to parse report_schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// SetReportSchedule saves the report schedule.
+func (d *DB) SetReportSchedule(ctx context.Context, schedule *ReportSchedule) error {
+	scheduleJSON, _ := json.Marshal(schedule)
+	return d.SetAppState(ctx, "report_schedule", string(scheduleJSON))
+}
+
+// GetLastReport returns the last report sent, or nil if none.
+func (d *DB) GetLastReport(ctx context.Context) (*ReportRecord, error) {
+	value, err := d.GetAppState(ctx, "report_last")
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var record ReportRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, fmt.Errorf("failed to parse report_last: %w", err)
+	}
+	return &record, nil
+}
+
+// SetLastReport saves the last report sent.
+func (d *DB) SetLastReport(ctx context.Context, record ReportRecord) error {
+	recordJSON, _ := json.Marshal(record)
+	return d.SetAppState(ctx, "report_last", string(recordJSON))
+}
+
+// CountNewMembers returns how many pubkeys were whitelisted, and how many
+// paid users signed up, in [since, until).
+func (d *DB) CountNewMembers(ctx context.Context, since, until time.Time) (whitelisted, paid int64, err error) {
+	err = d.AppDB.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM whitelist_meta WHERE added_at >= ? AND added_at < ?),
+			(SELECT COUNT(*) FROM paid_users WHERE created_at >= ? AND created_at < ?)
+	`, since.Unix(), until.Unix(), since.Unix(), until.Unix()).Scan(&whitelisted, &paid)
+	return whitelisted, paid, err
+}
+
+// MemberCounts are the pubkeys with access to the relay, by how they got
+// it. Each pubkey is counted once: the operator first, then paid users,
+// then other whitelist entries, then follow grants.
+type MemberCounts struct {
+	Operator    int64 `json:"operator"`
+	Paid        int64 `json:"paid"`        // Active or in their grace period
+	Whitelisted int64 `json:"whitelisted"` // Added by hand or by an import
+	Follow      int64 `json:"follow"`      // Granted by follow access
+	Total       int64 `json:"total"`
+	Blacklisted int64 `json:"blacklisted"`
+}
+
+// CountMembers returns how many pubkeys have access, by category.
+func (d *DB) CountMembers(ctx context.Context) (*MemberCounts, error) {
+	var c MemberCounts
+	err := d.AppDB.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM whitelist_meta WHERE is_operator = 1),
+			(SELECT COUNT(*) FROM paid_users p
+				WHERE p.status IN ('active', 'grace')
+				AND NOT EXISTS (SELECT 1 FROM whitelist_meta w WHERE w.pubkey = p.pubkey AND w.is_operator = 1)),
+			(SELECT COUNT(*) FROM whitelist_meta w
+				WHERE w.is_operator = 0
+				AND NOT EXISTS (SELECT 1 FROM paid_users p WHERE p.pubkey = w.pubkey AND p.status IN ('active', 'grace'))),
+			(SELECT COUNT(*) FROM follow_grants f
+				WHERE NOT EXISTS (SELECT 1 FROM whitelist_meta w WHERE w.pubkey = f.pubkey)
+				AND NOT EXISTS (SELECT 1 FROM paid_users p WHERE p.pubkey = f.pubkey AND p.status IN ('active', 'grace'))),
+			(SELECT COUNT(*) FROM blacklist)
+	`).Scan(&c.Operator, &c.Paid, &c.Whitelisted, &c.Follow, &c.Blacklisted)
+	if err != nil {
+		return nil, err
+	}
+	c.Total = c.Operator + c.Paid + c.Whitelisted + c.Follow
+	return &c, nil
+}
+
+// AuthorAccess is what the app knows about an author: the name to show for
+// it and the access it has.
+type AuthorAccess struct {
+	Nickname    string // Whitelist nickname
+	Name        string // Cached profile display name, else name
+	Nip05       string
+	Picture     string
+	Operator    bool
+	Whitelisted bool
+	PaidStatus  string // Paid user status; empty if never paid
+	Follow      bool   // Has a follow grant
+	Blacklisted bool
+}
+
+// GetAuthorAccess returns the names and access of a set of pubkeys, keyed
+// by pubkey, in one query per chunk rather than a lookup per pubkey.
+func (d *DB) GetAuthorAccess(ctx context.Context, pubkeys []string) (map[string]AuthorAccess, error) {
+	access := make(map[string]AuthorAccess, len(pubkeys))
+	for start := 0; start < len(pubkeys); start += authorCountChunk {
+		chunk := pubkeys[start:min(start+authorCountChunk, len(pubkeys))]
+		values := strings.TrimSuffix(strings.Repeat("(?),", len(chunk)), ",")
+		args := make([]interface{}, len(chunk))
+		for i, pk := range chunk {
+			args[i] = pk
+		}
+
+		rows, err := d.AppDB.QueryContext(ctx, `
+			WITH k(pubkey) AS (VALUES `+values+`)
+			SELECT k.pubkey, COALESCE(w.nickname, ''), COALESCE(NULLIF(pr.display_name, ''), pr.name, ''),
+				COALESCE(pr.nip05, ''), COALESCE(pr.picture, ''), COALESCE(w.is_operator, 0),
+				w.pubkey IS NOT NULL, COALESCE(p.status, ''), f.pubkey IS NOT NULL, b.pubkey IS NOT NULL
+			FROM k
+			LEFT JOIN whitelist_meta w ON w.pubkey = k.pubkey
+			LEFT JOIN paid_users p ON p.pubkey = k.pubkey
+			LEFT JOIN follow_grants f ON f.pubkey = k.pubkey
+			LEFT JOIN blacklist b ON b.pubkey = k.pubkey
+			LEFT JOIN profiles pr ON pr.pubkey = k.pubkey
+		`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var pubkey string
+			var a AuthorAccess
+			if err := rows.Scan(&pubkey, &a.Nickname, &a.Name, &a.Nip05, &a.Picture, &a.Operator,
+				&a.Whitelisted, &a.PaidStatus, &a.Follow, &a.Blacklisted); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			access[pubkey] = a
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return access, nil
+}
+
+// GetRevenueInRange returns the satoshis received and the number of
+// payments in [since, until).
+func (d *DB) GetRevenueInRange(ctx context.Context, since, until time.Time) (sats, payments int64, err error) {
+	err = d.AppDB.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount_sats), 0), COUNT(*)
+		FROM payment_history
+		WHERE paid_at >= ? AND paid_at < ?
+	`, since.Unix(), until.Unix()).Scan(&sats, &payments)
+	return sats, payments, err
+}
+
+// ============================================================================
+// Email
+// ============================================================================
+
+// SMTPSettings is the outgoing mail server saved from the UI, which takes
+// the place of the one in the config file.
+type SMTPSettings struct {
+	Host       string `json:"host"`
+	Port       string `json:"port"`
+	TLS        string `json:"tls"` // starttls, tls
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	From       string `json:"from"`
+	AlertEmail string `json:"alert_email"` // Where operator alerts are emailed; empty for DMs only
+}
+
+// GetSMTPSettings returns the saved mail server settings, or nil if none
+// are saved.
+func (d *DB) GetSMTPSettings(ctx context.Context) (*SMTPSettings, error) {
+	value, err := d.GetAppState(ctx, "smtp_settings")
+	if err != nil || value == "" {
+		return nil, err
+	}
+	value, err = d.vault.Open(secretSMTPSettings, value)
+	if err != nil {
+		return nil, err
+	}
+	var settings SMTPSettings
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse smtp_settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetSMTPSettings saves the mail server settings, encrypted when a secrets
+// passphrase is set. Nil settings remove them.
+func (d *DB) SetSMTPSettings(ctx context.Context, settings *SMTPSettings) error {
+	if settings == nil {
+		_, err := d.AppDB.ExecContext(ctx, "DELETE FROM app_state WHERE key = 'smtp_settings'")
+		return err
+	}
+	value, _ := json.Marshal(settings)
+	sealed, err := d.vault.Seal(secretSMTPSettings, string(value))
+	if err != nil {
+		return err
+	}
+	return d.SetAppState(ctx, "smtp_settings", sealed)
+}
+
 // ============================================================================
 // Helpers
 // ============================================================================
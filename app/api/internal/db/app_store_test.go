@@ -1,12 +1,19 @@
 package db
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/roostr/roostr/app/api/internal/secrets"
 )
 
 // setupTestDB creates a temporary SQLite database for testing
@@ -604,7 +611,7 @@ func TestSyncJobs(t *testing.T) {
 		job := SyncJob{Pubkeys: []string{"p"}, Relays: []string{"r"}}
 		id, _ := db.CreateSyncJob(ctx, job)
 
-		err := db.UpdateSyncJobProgress(ctx, id, 100, 90, 10)
+		err := db.UpdateSyncJobProgress(ctx, id, 100, 90, 10, 0)
 		if err != nil {
 			t.Fatalf("failed to update progress: %v", err)
 		}
@@ -673,6 +680,66 @@ func TestSyncJobs(t *testing.T) {
 			t.Errorf("expected status 'cancelled', got %q", retrieved.Status)
 		}
 	})
+
+	t.Run("PauseAndResumeSyncJob", func(t *testing.T) {
+		job := SyncJob{Pubkeys: []string{"pause"}, Relays: []string{"r"}}
+		id, _ := db.CreateSyncJob(ctx, job)
+
+		n, err := db.PauseInterruptedSyncJobs(ctx)
+		if err != nil || n == 0 {
+			t.Fatalf("expected interrupted jobs to be paused, got %d, err %v", n, err)
+		}
+		retrieved, _ := db.GetSyncJob(ctx, id)
+		if retrieved.Status != "paused" || retrieved.ErrorMessage == "" {
+			t.Errorf("expected a paused job with a reason, got %+v", retrieved)
+		}
+
+		if err := db.ResumeSyncJob(ctx, id); err != nil {
+			t.Fatalf("failed to resume job: %v", err)
+		}
+		retrieved, _ = db.GetSyncJob(ctx, id)
+		if retrieved.Status != "running" || retrieved.ErrorMessage != "" {
+			t.Errorf("expected a running job, got %+v", retrieved)
+		}
+
+		db.PauseSyncJob(ctx, id, "")
+		if retrieved, _ = db.GetSyncJob(ctx, id); retrieved.Status != "paused" {
+			t.Errorf("expected status 'paused', got %q", retrieved.Status)
+		}
+	})
+
+	t.Run("SyncCursors", func(t *testing.T) {
+		job := SyncJob{Pubkeys: []string{"a", "b"}, Relays: []string{"r"}}
+		id, _ := db.CreateSyncJob(ctx, job)
+
+		until := int64(1700000000)
+		if err := db.SaveSyncCursor(ctx, id, SyncCursor{Relay: "r", Pubkey: "a", Until: &until}); err != nil {
+			t.Fatalf("failed to save cursor: %v", err)
+		}
+		db.SaveSyncCursor(ctx, id, SyncCursor{Relay: "r", Pubkey: "b"})
+		db.SaveSyncCursor(ctx, id, SyncCursor{Relay: "r", Pubkey: "b", Done: true})
+
+		cursors, err := db.GetSyncCursors(ctx, id)
+		if err != nil {
+			t.Fatalf("failed to get cursors: %v", err)
+		}
+		if len(cursors) != 2 {
+			t.Fatalf("expected 2 cursors, got %d", len(cursors))
+		}
+		if cursors[0].Until == nil || *cursors[0].Until != until || cursors[0].Done {
+			t.Errorf("unexpected cursor for a: %+v", cursors[0])
+		}
+		if cursors[1].Until != nil || !cursors[1].Done {
+			t.Errorf("expected cursor for b to be done, got %+v", cursors[1])
+		}
+
+		if err := db.DeleteSyncCursors(ctx, id); err != nil {
+			t.Fatalf("failed to delete cursors: %v", err)
+		}
+		if cursors, _ := db.GetSyncCursors(ctx, id); len(cursors) != 0 {
+			t.Errorf("expected no cursors, got %d", len(cursors))
+		}
+	})
 }
 
 // ============================================================================
@@ -765,6 +832,7 @@ func TestRetentionPolicy(t *testing.T) {
 		policy := &RetentionPolicy{
 			RetentionDays: 30,
 			Exceptions:    []string{"kind:0", "kind:3"},
+			KindRules:     []RetentionRule{{Kind: 1, RetentionDays: 90}, {Kind: 10002}},
 			HonorNIP09:    false,
 		}
 		err := db.SetRetentionPolicy(ctx, policy)
@@ -779,6 +847,9 @@ func TestRetentionPolicy(t *testing.T) {
 		if len(retrieved.Exceptions) != 2 {
 			t.Errorf("expected 2 exceptions, got %d", len(retrieved.Exceptions))
 		}
+		if len(retrieved.KindRules) != 2 || retrieved.KindRules[0].RetentionDays != 90 || retrieved.KindRules[1].Kind != 10002 {
+			t.Errorf("unexpected kind rules: %+v", retrieved.KindRules)
+		}
 		if retrieved.HonorNIP09 {
 			t.Error("expected honor_nip09 to be false")
 		}
@@ -798,6 +869,179 @@ func TestRetentionPolicy(t *testing.T) {
 	})
 }
 
+func TestBackupSchedule(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	t.Run("schedule_defaults", func(t *testing.T) {
+		schedule, err := db.GetBackupSchedule(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if schedule.Enabled || schedule.Frequency != "daily" || schedule.Hour != 2 || schedule.Retain != 7 {
+			t.Errorf("expected the default schedule, got %+v", schedule)
+		}
+		if last, err := db.GetLastScheduledBackup(ctx); err != nil || last != nil {
+			t.Errorf("expected no last backup, got %+v (%v)", last, err)
+		}
+	})
+
+	t.Run("SetBackupSchedule", func(t *testing.T) {
+		schedule := &BackupSchedule{Enabled: true, Frequency: "weekly", Hour: 4, Weekday: 0, Retain: 4, Encrypt: true}
+		if err := db.SetBackupSchedule(ctx, schedule); err != nil {
+			t.Fatalf("failed to set schedule: %v", err)
+		}
+		retrieved, err := db.GetBackupSchedule(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *retrieved != *schedule {
+			t.Errorf("expected %+v, got %+v", schedule, retrieved)
+		}
+	})
+
+	t.Run("last_scheduled_backup", func(t *testing.T) {
+		record := BackupRunRecord{At: time.Now().UTC().Truncate(time.Second), Status: "ok", Name: "roostr-backup-20260301-020000.tar.gz", Size: 1024, Deleted: 2}
+		if err := db.SetLastScheduledBackup(ctx, record); err != nil {
+			t.Fatalf("failed to record backup: %v", err)
+		}
+		last, err := db.GetLastScheduledBackup(ctx)
+		if err != nil || last == nil {
+			t.Fatalf("expected a last backup, got %v", err)
+		}
+		if !last.At.Equal(record.At) || last.Name != record.Name || last.Deleted != 2 {
+			t.Errorf("expected %+v, got %+v", record, last)
+		}
+	})
+}
+
+func TestBackupTargets(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	target := &BackupTarget{
+		Name:        "nextcloud",
+		Type:        "webdav",
+		Destination: ExportDestination{URL: "https://cloud.example.com/dav", User: "alice", Password: "app-password"},
+		Retain:      2,
+		Enabled:     true,
+	}
+	if err := db.CreateBackupTarget(ctx, target); err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+
+	got, err := db.GetBackupTarget(ctx, target.ID)
+	if err != nil || got == nil {
+		t.Fatalf("expected the target, got %v", err)
+	}
+	if got.Destination != target.Destination || got.Retain != 2 || !got.Enabled || got.LastUploadAt != nil {
+		t.Errorf("unexpected target %+v", got)
+	}
+
+	got.Enabled = false
+	if err := db.UpdateBackupTarget(ctx, got); err != nil {
+		t.Fatalf("failed to update target: %v", err)
+	}
+
+	for i, status := range []string{"completed", "failed", "completed", "completed"} {
+		upload := &BackupUpload{TargetID: target.ID, Name: fmt.Sprintf("backup-%d", i), Status: status}
+		if err := db.AddBackupUpload(ctx, upload); err != nil {
+			t.Fatalf("failed to add upload: %v", err)
+		}
+	}
+
+	targets, _ := db.GetBackupTargets(ctx)
+	if len(targets) != 1 || targets[0].Enabled || targets[0].LastStatus != "completed" || targets[0].LastUploadAt == nil {
+		t.Errorf("expected the update and last upload recorded, got %+v", targets)
+	}
+
+	prunable, err := db.GetPrunableBackupUploads(ctx, target.ID, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prunable) != 1 || prunable[0].Name != "backup-0" {
+		t.Errorf("expected only the oldest completed upload, got %+v", prunable)
+	}
+	db.MarkBackupUploadPruned(ctx, prunable[0].ID)
+	if prunable, _ := db.GetPrunableBackupUploads(ctx, target.ID, 2); len(prunable) != 0 {
+		t.Errorf("expected nothing left to prune, got %+v", prunable)
+	}
+
+	if err := db.DeleteBackupTarget(ctx, target.ID); err != nil {
+		t.Fatalf("failed to delete target: %v", err)
+	}
+	if uploads, _ := db.GetBackupUploads(ctx, target.ID, 10); len(uploads) != 0 {
+		t.Errorf("expected uploads deleted with the target, got %d", len(uploads))
+	}
+}
+
+func TestStorageMaintenance(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	t.Run("schedule_defaults", func(t *testing.T) {
+		schedule, err := db.GetStorageMaintenanceSchedule(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *schedule != DefaultStorageMaintenanceSchedule {
+			t.Errorf("expected the default schedule, got %+v", schedule)
+		}
+	})
+
+	t.Run("SetStorageMaintenanceSchedule", func(t *testing.T) {
+		schedule := &StorageMaintenanceSchedule{VacuumIntervalDays: 30, IntegrityIntervalDays: 1, WindowStartHour: 23, WindowEndHour: 2}
+		if err := db.SetStorageMaintenanceSchedule(ctx, schedule); err != nil {
+			t.Fatalf("failed to set schedule: %v", err)
+		}
+		retrieved, err := db.GetStorageMaintenanceSchedule(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *retrieved != *schedule {
+			t.Errorf("expected %+v, got %+v", schedule, retrieved)
+		}
+	})
+
+	t.Run("maintenance_history", func(t *testing.T) {
+		base := time.Now().Add(-time.Hour)
+		runs := []*MaintenanceRun{
+			{Task: "vacuum", Database: "app", Trigger: "scheduled", Status: "ok", SpaceReclaimed: 4096, StartedAt: base, CompletedAt: base},
+			{Task: "integrity_check", Database: "relay", Trigger: "manual", Status: "failed", Result: "row 3 missing from index", StartedAt: base.Add(time.Minute), CompletedAt: base.Add(time.Minute)},
+		}
+		for _, run := range runs {
+			if err := db.AddMaintenanceRun(ctx, run); err != nil {
+				t.Fatalf("failed to add run: %v", err)
+			}
+			if run.ID == 0 {
+				t.Error("expected the run ID to be set")
+			}
+		}
+
+		history, total, err := db.GetMaintenanceHistory(ctx, "", 10, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if total != 2 || len(history) != 2 {
+			t.Fatalf("expected 2 runs, got %d of %d", len(history), total)
+		}
+		if history[0].Task != "integrity_check" || history[0].Result != "row 3 missing from index" {
+			t.Errorf("expected the newest run first, got %+v", history[0])
+		}
+		if history[1].SpaceReclaimed != 4096 || history[1].Result != "" {
+			t.Errorf("unexpected vacuum run %+v", history[1])
+		}
+
+		history, total, err = db.GetMaintenanceHistory(ctx, "vacuum", 10, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if total != 1 || len(history) != 1 || history[0].Task != "vacuum" {
+			t.Errorf("expected only the vacuum run, got %d of %d", len(history), total)
+		}
+	})
+}
+
 // ============================================================================
 // Pending Invoices Tests
 // ============================================================================
@@ -970,6 +1214,147 @@ func TestLightningConfig(t *testing.T) {
 	})
 }
 
+func TestLightningSecrets(t *testing.T) {
+	old := secrets.KDFIterations
+	secrets.KDFIterations = 1000
+	t.Cleanup(func() { secrets.KDFIterations = old })
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	// Saved before a passphrase is set, so stored in plaintext
+	if err := db.SaveLightningConfig(ctx, &LightningConfig{
+		NodeType: "lnd",
+		Endpoint: "localhost:8080",
+		Macaroon: "hexmacaroon",
+		Enabled:  true,
+	}); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	if n, _ := db.CountPlaintextSecrets(ctx); n != 1 {
+		t.Errorf("expected 1 plaintext secret, got %d", n)
+	}
+
+	encrypted, err := db.SetupSecrets(ctx, "correct horse battery")
+	if err != nil {
+		t.Fatalf("failed to set up secrets: %v", err)
+	}
+	if encrypted != 1 {
+		t.Errorf("expected 1 value encrypted, got %d", encrypted)
+	}
+
+	var stored string
+	db.AppDB.QueryRowContext(ctx, "SELECT macaroon FROM lightning_config WHERE id = 1").Scan(&stored)
+	if !secrets.IsEncrypted(stored) || strings.Contains(stored, "hexmacaroon") {
+		t.Errorf("expected macaroon encrypted at rest, got %q", stored)
+	}
+	if n, _ := db.CountPlaintextSecrets(ctx); n != 0 {
+		t.Errorf("expected no plaintext secrets, got %d", n)
+	}
+
+	cfg, err := db.GetLightningConfig(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Macaroon != "hexmacaroon" {
+		t.Errorf("expected decrypted macaroon, got %q", cfg.Macaroon)
+	}
+
+	// After a restart the secrets stay locked until unlocked
+	if err := db.LoadSecrets(ctx); err != nil {
+		t.Fatalf("failed to load secrets: %v", err)
+	}
+	if _, err := db.GetLightningConfig(ctx); !errors.Is(err, secrets.ErrLocked) {
+		t.Errorf("expected ErrLocked reading config, got %v", err)
+	}
+	if err := db.SaveLightningConfig(ctx, cfg); !errors.Is(err, secrets.ErrLocked) {
+		t.Errorf("expected ErrLocked saving config, got %v", err)
+	}
+
+	if err := db.Secrets().Unlock("correct horse battery"); err != nil {
+		t.Fatalf("failed to unlock: %v", err)
+	}
+	cfg, err = db.GetLightningConfig(ctx)
+	if err != nil || cfg.Macaroon != "hexmacaroon" {
+		t.Errorf("expected decrypted macaroon after unlock, got %+v, %v", cfg, err)
+	}
+}
+
+func TestStoredSecretsEncrypted(t *testing.T) {
+	old := secrets.KDFIterations
+	secrets.KDFIterations = 1000
+	t.Cleanup(func() { secrets.KDFIterations = old })
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	// Saved before a passphrase is set, so stored in plaintext
+	if err := db.SetNotificationKey(ctx, "notificationsecret"); err != nil {
+		t.Fatalf("failed to save notification key: %v", err)
+	}
+	target := &BackupTarget{Name: "offsite", Type: "s3", Destination: ExportDestination{Bucket: "b", AccessKey: "ak", SecretKey: "s3secret"}}
+	if err := db.CreateBackupTarget(ctx, target); err != nil {
+		t.Fatalf("failed to create backup target: %v", err)
+	}
+	schedule := &ExportSchedule{Name: "nightly", DestinationType: "webdav", IntervalHours: 24,
+		Destination: ExportDestination{URL: "https://cloud.example.com/dav", User: "alice", Password: "davsecret"}}
+	if err := db.CreateExportSchedule(ctx, schedule); err != nil {
+		t.Fatalf("failed to create export schedule: %v", err)
+	}
+	if n, _ := db.CountPlaintextSecrets(ctx); n != 3 {
+		t.Errorf("expected 3 plaintext secrets, got %d", n)
+	}
+
+	if encrypted, err := db.SetupSecrets(ctx, "correct horse battery"); err != nil || encrypted != 3 {
+		t.Fatalf("expected 3 values encrypted, got %d, %v", encrypted, err)
+	}
+
+	var key, dest, exportDest string
+	db.AppDB.QueryRowContext(ctx, "SELECT value FROM app_state WHERE key = 'notification_key'").Scan(&key)
+	db.AppDB.QueryRowContext(ctx, "SELECT destination FROM backup_targets WHERE id = ?", target.ID).Scan(&dest)
+	if !secrets.IsEncrypted(key) || !secrets.IsEncrypted(dest) || strings.Contains(dest, "s3secret") {
+		t.Errorf("expected secrets encrypted at rest, got %q and %q", key, dest)
+	}
+	db.AppDB.QueryRowContext(ctx, "SELECT destination FROM export_schedules WHERE id = ?", schedule.ID).Scan(&exportDest)
+	if !strings.HasPrefix(exportDest, "enc:v1:") || strings.Contains(exportDest, "davsecret") {
+		t.Errorf("expected the export destination encrypted at rest, got %q", exportDest)
+	}
+	if got, err := db.GetExportSchedule(ctx, schedule.ID); err != nil || got.Destination.Password != "davsecret" {
+		t.Errorf("expected decrypted export destination, got %+v, %v", got, err)
+	}
+
+	if got, err := db.GetNotificationKey(ctx); err != nil || got != "notificationsecret" {
+		t.Errorf("expected decrypted notification key, got %q, %v", got, err)
+	}
+	got, err := db.GetBackupTarget(ctx, target.ID)
+	if err != nil || got.Destination.SecretKey != "s3secret" {
+		t.Fatalf("expected decrypted backup destination, got %+v, %v", got, err)
+	}
+
+	// Targets saved with a passphrase set are encrypted straight away
+	got.Destination.SecretKey = "rotated"
+	if err := db.UpdateBackupTarget(ctx, got); err != nil {
+		t.Fatalf("failed to update backup target: %v", err)
+	}
+	db.AppDB.QueryRowContext(ctx, "SELECT destination FROM backup_targets WHERE id = ?", target.ID).Scan(&dest)
+	if !secrets.IsEncrypted(dest) {
+		t.Errorf("expected updated destination encrypted, got %q", dest)
+	}
+
+	if err := db.LoadSecrets(ctx); err != nil {
+		t.Fatalf("failed to load secrets: %v", err)
+	}
+	if _, err := db.GetNotificationKey(ctx); !errors.Is(err, secrets.ErrLocked) {
+		t.Errorf("expected ErrLocked reading notification key, got %v", err)
+	}
+	if _, err := db.GetBackupTargets(ctx); !errors.Is(err, secrets.ErrLocked) {
+		t.Errorf("expected ErrLocked reading backup targets, got %v", err)
+	}
+	if _, err := db.GetExportSchedules(ctx); !errors.Is(err, secrets.ErrLocked) {
+		t.Errorf("expected ErrLocked reading export schedules, got %v", err)
+	}
+}
+
 // ============================================================================
 // Audit Log Tests
 // ============================================================================
@@ -1053,3 +1438,928 @@ func TestStorageManagement(t *testing.T) {
 		}
 	})
 }
+
+// ============================================================================
+// Signup Widget Tests
+// ============================================================================
+
+func TestSignupAllowedOrigins(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	origins, err := db.GetSignupAllowedOrigins(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(origins) != 0 {
+		t.Errorf("expected no origins by default, got %v", origins)
+	}
+
+	want := []string{"https://example.com", "http://localhost:5173"}
+	if err := db.SetSignupAllowedOrigins(ctx, want); err != nil {
+		t.Fatalf("failed to set origins: %v", err)
+	}
+
+	origins, err = db.GetSignupAllowedOrigins(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(origins) != 2 || origins[0] != want[0] || origins[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, origins)
+	}
+}
+
+func TestCSRFSecret(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	first, err := db.GetCSRFSecret(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first) != 32 {
+		t.Fatalf("expected a 32-byte secret, got %d bytes", len(first))
+	}
+
+	second, err := db.GetCSRFSecret(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("expected the secret to be kept between calls")
+	}
+}
+
+// ============================================================================
+// Bandwidth Tests
+// ============================================================================
+
+func TestBandwidthUsage(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	rows := []BandwidthUsage{
+		{Date: "2024-01-01", IPClass: "lan", BytesIn: 100, BytesOut: 1000, Connections: 1},
+		{Date: "2024-01-02", IPClass: "lan", BytesIn: 10, BytesOut: 20, Connections: 1},
+		{Date: "2024-01-02", IPClass: "lan", BytesIn: 5, BytesOut: 5, Connections: 0},
+		{Date: "2024-01-02", IPClass: "public_ipv4", BytesIn: 1, BytesOut: 2, Connections: 3},
+	}
+	for _, u := range rows {
+		if err := db.AddBandwidthUsage(ctx, u); err != nil {
+			t.Fatalf("AddBandwidthUsage failed: %v", err)
+		}
+	}
+
+	usage, err := db.GetBandwidthUsage(ctx, "2024-01-02")
+	if err != nil {
+		t.Fatalf("GetBandwidthUsage failed: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("expected 2 rows since 2024-01-02, got %d", len(usage))
+	}
+
+	lan := usage[0]
+	if lan.IPClass != "lan" || lan.BytesIn != 15 || lan.BytesOut != 25 || lan.Connections != 1 {
+		t.Errorf("expected accumulated lan usage, got %+v", lan)
+	}
+	if usage[1].IPClass != "public_ipv4" || usage[1].Connections != 3 {
+		t.Errorf("unexpected public usage: %+v", usage[1])
+	}
+}
+
+// ============================================================================
+// Webhook Tests
+// ============================================================================
+
+func TestWebhooks(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	wh := &Webhook{URL: "https://example.com/hook", Secret: "s3cret", Events: []string{"invoice.paid"}, Enabled: true}
+	if err := db.CreateWebhook(ctx, wh); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	if wh.ID == 0 {
+		t.Fatal("expected webhook ID to be set")
+	}
+
+	got, err := db.GetWebhook(ctx, wh.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetWebhook failed: %v", err)
+	}
+	if got.Secret != "s3cret" || len(got.Events) != 1 || got.Events[0] != "invoice.paid" || !got.Enabled {
+		t.Errorf("unexpected webhook: %+v", got)
+	}
+
+	got.Enabled = false
+	got.Events = []string{"*"}
+	if err := db.UpdateWebhook(ctx, got); err != nil {
+		t.Fatalf("UpdateWebhook failed: %v", err)
+	}
+	webhooks, err := db.GetWebhooks(ctx)
+	if err != nil || len(webhooks) != 1 || webhooks[0].Enabled || webhooks[0].Events[0] != "*" {
+		t.Errorf("unexpected webhooks after update: %+v (%v)", webhooks, err)
+	}
+
+	t.Run("deliveries", func(t *testing.T) {
+		id, err := db.CreateWebhookDelivery(ctx, wh.ID, "invoice.paid", `{"event":"invoice.paid"}`)
+		if err != nil {
+			t.Fatalf("CreateWebhookDelivery failed: %v", err)
+		}
+
+		due, err := db.GetDueWebhookDeliveries(ctx, time.Now().Add(time.Second), 10)
+		if err != nil || len(due) != 1 || due[0].ID != id {
+			t.Fatalf("expected delivery to be due, got %+v (%v)", due, err)
+		}
+
+		next := time.Now().Add(time.Hour)
+		if err := db.RecordWebhookAttempt(ctx, id, "pending", 500, "endpoint returned 500", next); err != nil {
+			t.Fatalf("RecordWebhookAttempt failed: %v", err)
+		}
+		due, _ = db.GetDueWebhookDeliveries(ctx, time.Now(), 10)
+		if len(due) != 0 {
+			t.Errorf("expected retry to be scheduled later, got %d due", len(due))
+		}
+
+		if err := db.RecordWebhookAttempt(ctx, id, "delivered", 200, "", time.Now()); err != nil {
+			t.Fatalf("RecordWebhookAttempt failed: %v", err)
+		}
+		log, err := db.GetWebhookDeliveries(ctx, wh.ID, 10)
+		if err != nil || len(log) != 1 {
+			t.Fatalf("GetWebhookDeliveries failed: %v", err)
+		}
+		if log[0].Status != "delivered" || log[0].Attempts != 2 || log[0].LastStatusCode != 200 || log[0].DeliveredAt == nil {
+			t.Errorf("unexpected delivery: %+v", log[0])
+		}
+	})
+
+	if err := db.DeleteWebhook(ctx, wh.ID); err != nil {
+		t.Fatalf("DeleteWebhook failed: %v", err)
+	}
+	if got, _ := db.GetWebhook(ctx, wh.ID); got != nil {
+		t.Error("expected webhook to be deleted")
+	}
+	if log, _ := db.GetWebhookDeliveries(ctx, wh.ID, 10); len(log) != 0 {
+		t.Error("expected deliveries to be deleted with the webhook")
+	}
+}
+
+// ============================================================================
+// Export Schedule Tests
+// ============================================================================
+
+func TestExportSchedules(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	s := &ExportSchedule{
+		Name:            "Nightly",
+		DestinationType: "s3",
+		Destination:     ExportDestination{Bucket: "backups", Region: "us-east-1", AccessKey: "AK", SecretKey: "SK"},
+		Kinds:           []int{1, 7},
+		IntervalHours:   24,
+		RetentionCount:  2,
+		Enabled:         true,
+	}
+	if err := db.CreateExportSchedule(ctx, s); err != nil {
+		t.Fatalf("CreateExportSchedule failed: %v", err)
+	}
+
+	got, err := db.GetExportSchedule(ctx, s.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetExportSchedule failed: %v", err)
+	}
+	if got.Destination.SecretKey != "SK" || len(got.Kinds) != 2 || got.Cursor != 0 || got.LastRunAt != nil {
+		t.Errorf("unexpected schedule: %+v", got)
+	}
+
+	due, err := db.GetDueExportSchedules(ctx, time.Now().Add(time.Second))
+	if err != nil || len(due) != 1 {
+		t.Fatalf("expected new schedule to be due, got %d (%v)", len(due), err)
+	}
+
+	t.Run("cursor_only_advances_on_success", func(t *testing.T) {
+		db.RecordExportRun(ctx, s.ID, "failed", "upload failed", 50, time.Now().Add(time.Hour))
+		got, _ := db.GetExportSchedule(ctx, s.ID)
+		if got.Cursor != 0 || got.LastStatus != "failed" || got.LastError != "upload failed" || got.LastRunAt == nil {
+			t.Errorf("unexpected schedule after failure: %+v", got)
+		}
+
+		db.RecordExportRun(ctx, s.ID, "completed", "", 50, time.Now().Add(time.Hour))
+		got, _ = db.GetExportSchedule(ctx, s.ID)
+		if got.Cursor != 50 || got.LastStatus != "completed" || got.LastError != "" {
+			t.Errorf("unexpected schedule after success: %+v", got)
+		}
+
+		if due, _ := db.GetDueExportSchedules(ctx, time.Now()); len(due) != 0 {
+			t.Error("expected schedule not to be due until next run")
+		}
+	})
+
+	t.Run("manifests_and_pruning", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			m := &ExportManifest{ScheduleID: s.ID, Filename: fmt.Sprintf("export-%d.ndjson", i), Location: fmt.Sprintf("s3://backups/export-%d.ndjson", i), Status: "completed"}
+			if err := db.CreateExportManifest(ctx, m); err != nil {
+				t.Fatalf("CreateExportManifest failed: %v", err)
+			}
+		}
+
+		prunable, err := db.GetPrunableExportManifests(ctx, s.ID, 2)
+		if err != nil || len(prunable) != 2 {
+			t.Fatalf("expected 2 prunable manifests, got %d (%v)", len(prunable), err)
+		}
+		if prunable[0].Filename != "export-1.ndjson" || prunable[1].Filename != "export-0.ndjson" {
+			t.Errorf("expected oldest manifests to be prunable, got %s and %s", prunable[0].Filename, prunable[1].Filename)
+		}
+
+		for _, m := range prunable {
+			db.MarkExportManifestPruned(ctx, m.ID)
+		}
+		if prunable, _ := db.GetPrunableExportManifests(ctx, s.ID, 2); len(prunable) != 0 {
+			t.Errorf("expected nothing left to prune, got %d", len(prunable))
+		}
+
+		manifests, _ := db.GetExportManifests(ctx, s.ID, 10)
+		if len(manifests) != 4 || manifests[0].Filename != "export-3.ndjson" || manifests[3].Status != "pruned" {
+			t.Errorf("unexpected manifests: %+v", manifests)
+		}
+	})
+
+	if err := db.DeleteExportSchedule(ctx, s.ID); err != nil {
+		t.Fatalf("DeleteExportSchedule failed: %v", err)
+	}
+	if got, _ := db.GetExportSchedule(ctx, s.ID); got != nil {
+		t.Error("expected schedule to be deleted")
+	}
+	if manifests, _ := db.GetExportManifests(ctx, s.ID, 10); len(manifests) != 0 {
+		t.Error("expected manifests to be deleted with the schedule")
+	}
+}
+
+// ============================================================================
+// Data Residency Tests
+// ============================================================================
+
+func TestExcludedKinds(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	kinds, err := db.GetExcludedKinds(ctx)
+	if err != nil || len(kinds) != 0 {
+		t.Fatalf("expected no excluded kinds by default, got %v (%v)", kinds, err)
+	}
+
+	if err := db.SetExcludedKinds(ctx, []int{4, 1059}); err != nil {
+		t.Fatalf("SetExcludedKinds failed: %v", err)
+	}
+	kinds, _ = db.GetExcludedKinds(ctx)
+	if len(kinds) != 2 || kinds[1] != 1059 {
+		t.Errorf("unexpected excluded kinds: %v", kinds)
+	}
+
+	if purge, _ := db.GetLastResidencyPurge(ctx); purge != nil {
+		t.Error("expected no purge recorded yet")
+	}
+	db.SetLastResidencyPurge(ctx, ResidencyPurge{At: time.Now(), Deleted: 3, ByKind: map[int]int64{4: 3}})
+	purge, err := db.GetLastResidencyPurge(ctx)
+	if err != nil || purge == nil || purge.Deleted != 3 || purge.ByKind[4] != 3 {
+		t.Errorf("unexpected purge: %+v (%v)", purge, err)
+	}
+}
+
+// ============================================================================
+// Mention Notification Tests
+// ============================================================================
+
+func TestMentionQueue(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	member := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	err := db.SaveMentionSubscription(ctx, &MentionSubscription{
+		Pubkey: member, Enabled: true, Channel: "dm", QuietStart: "22:00", QuietEnd: "07:00",
+		Timezone: "UTC", BatchMinutes: 30,
+	})
+	if err != nil {
+		t.Fatalf("SaveMentionSubscription failed: %v", err)
+	}
+
+	mention := QueuedMention{Recipient: member, EventID: "e1", Author: "bob", Kind: 1, CreatedAt: time.Unix(100, 0)}
+	if added, err := db.QueueMention(ctx, mention); err != nil || !added {
+		t.Fatalf("expected mention to be queued, got %v (%v)", added, err)
+	}
+	if added, _ := db.QueueMention(ctx, mention); added {
+		t.Error("expected duplicate mention to be ignored")
+	}
+	mention.EventID = "e2"
+	db.QueueMention(ctx, mention)
+
+	sub, err := db.GetMentionSubscription(ctx, member)
+	if err != nil || sub == nil {
+		t.Fatalf("GetMentionSubscription failed: %v", err)
+	}
+	if sub.Pending != 2 || sub.QuietStart != "22:00" || sub.BatchMinutes != 30 || sub.LastNotifiedAt != nil {
+		t.Errorf("unexpected subscription: %+v", sub)
+	}
+
+	if err := db.DeleteQueuedMentions(ctx, member, []string{"e1"}); err != nil {
+		t.Fatalf("DeleteQueuedMentions failed: %v", err)
+	}
+	queued, _ := db.GetQueuedMentions(ctx, member)
+	if len(queued) != 1 || queued[0].EventID != "e2" {
+		t.Errorf("unexpected queue: %+v", queued)
+	}
+
+	db.SetMentionNotified(ctx, member, time.Unix(200, 0))
+	if sub, _ := db.GetMentionSubscription(ctx, member); sub.LastNotifiedAt == nil || sub.LastNotifiedAt.Unix() != 200 {
+		t.Errorf("expected last notified at 200, got %+v", sub.LastNotifiedAt)
+	}
+
+	if err := db.DeleteMentionSubscription(ctx, member); err != nil {
+		t.Fatalf("DeleteMentionSubscription failed: %v", err)
+	}
+	if sub, _ := db.GetMentionSubscription(ctx, member); sub != nil {
+		t.Error("expected subscription to be deleted")
+	}
+	if queued, _ := db.GetQueuedMentions(ctx, member); len(queued) != 0 {
+		t.Errorf("expected queue to be cleared, got %d", len(queued))
+	}
+}
+
+// ============================================================================
+// Maintenance Tests
+// ============================================================================
+
+func TestMaintenanceWindows(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	if status, err := db.GetRelayStatusOverride(ctx); err != nil || status.State != "ok" {
+		t.Fatalf("expected ok by default, got %+v (%v)", status, err)
+	}
+	db.SetRelayStatusOverride(ctx, &RelayStatusOverride{State: "read-only", Message: "Disk full", Since: time.Unix(100, 0)})
+	if status, _ := db.GetRelayStatusOverride(ctx); status.State != "read-only" || status.Message != "Disk full" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	now := time.Now()
+	past := &MaintenanceWindow{StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour), Mode: "maintenance"}
+	later := &MaintenanceWindow{StartsAt: now.Add(48 * time.Hour), EndsAt: now.Add(49 * time.Hour), Mode: "maintenance"}
+	soon := &MaintenanceWindow{StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour), Mode: "read-only", Message: "Upgrade"}
+	for _, mw := range []*MaintenanceWindow{past, later, soon} {
+		if err := db.CreateMaintenanceWindow(ctx, mw); err != nil {
+			t.Fatalf("CreateMaintenanceWindow failed: %v", err)
+		}
+	}
+
+	windows, err := db.GetMaintenanceWindows(ctx, now)
+	if err != nil {
+		t.Fatalf("GetMaintenanceWindows failed: %v", err)
+	}
+	if len(windows) != 2 || windows[0].ID != soon.ID || windows[0].Message != "Upgrade" || windows[1].ID != later.ID {
+		t.Errorf("expected the two future windows soonest first, got %+v", windows)
+	}
+
+	db.SetMaintenanceAnnounced(ctx, soon.ID, time.Unix(500, 0))
+	if got, _ := db.GetMaintenanceWindow(ctx, soon.ID); got == nil || got.AnnouncedAt == nil || got.AnnouncedAt.Unix() != 500 {
+		t.Errorf("expected announced_at to be set, got %+v", got)
+	}
+
+	db.DeleteMaintenanceWindow(ctx, soon.ID)
+	if got, _ := db.GetMaintenanceWindow(ctx, soon.ID); got != nil {
+		t.Error("expected window to be deleted")
+	}
+}
+
+// ============================================================================
+// Profile Tests
+// ============================================================================
+
+func TestProfiles(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	pubkey := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+	if p, err := db.GetProfile(ctx, pubkey); err != nil || p != nil {
+		t.Fatalf("expected no profile, got %+v (%v)", p, err)
+	}
+
+	// A lookup that found nothing
+	db.SaveProfile(ctx, &Profile{Pubkey: pubkey, FetchedAt: time.Unix(100, 0)})
+	if p, _ := db.GetProfile(ctx, pubkey); p == nil || p.Source != "" || p.EventCreatedAt != nil {
+		t.Errorf("expected an empty profile, got %+v", p)
+	}
+
+	created := time.Unix(50, 0)
+	db.SaveProfile(ctx, &Profile{
+		Pubkey: pubkey, Name: "alice", Picture: "https://example.com/a.png", Nip05: "alice@example.com",
+		Source: "local", EventCreatedAt: &created, FetchedAt: time.Unix(200, 0),
+	})
+	profiles, err := db.GetProfiles(ctx)
+	if err != nil {
+		t.Fatalf("GetProfiles failed: %v", err)
+	}
+	p := profiles[pubkey]
+	if len(profiles) != 1 || p.Name != "alice" || p.Nip05 != "alice@example.com" || p.Source != "local" ||
+		p.EventCreatedAt == nil || p.EventCreatedAt.Unix() != 50 || p.FetchedAt.Unix() != 200 {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+}
+
+// ============================================================================
+// Job Tests
+// ============================================================================
+
+func TestJobs(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	vacuum, err := db.CreateJob(ctx, "vacuum", nil)
+	if err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	cleanup, err := db.CreateJob(ctx, "cleanup", map[string]string{"before_date": "2024-01-01T00:00:00Z"})
+	if err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+
+	db.UpdateJobProgress(ctx, cleanup.ID, 5, 10, "halfway")
+	got, err := db.GetJob(ctx, cleanup.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if got.Status != JobStatusRunning || got.ProgressCurrent != 5 || got.ProgressTotal != 10 || got.Message != "halfway" ||
+		string(got.Params) != `{"before_date":"2024-01-01T00:00:00Z"}` {
+		t.Errorf("unexpected job: %+v", got)
+	}
+
+	completedAt := time.Unix(1000, 0)
+	got.Status = JobStatusCompleted
+	got.Result = json.RawMessage(`{"deleted_count":3}`)
+	got.CompletedAt = &completedAt
+	if err := db.CompleteJob(ctx, got); err != nil {
+		t.Fatalf("CompleteJob failed: %v", err)
+	}
+	got, _ = db.GetJob(ctx, cleanup.ID)
+	if !got.Done() || string(got.Result) != `{"deleted_count":3}` || got.CompletedAt == nil || got.CompletedAt.Unix() != 1000 {
+		t.Errorf("unexpected completed job: %+v", got)
+	}
+
+	// The vacuum job is still running when the process "restarts"
+	if n, err := db.FailInterruptedJobs(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 interrupted job, got %d (%v)", n, err)
+	}
+	got, _ = db.GetJob(ctx, vacuum.ID)
+	if got.Status != JobStatusFailed || got.Error == "" || got.CompletedAt == nil {
+		t.Errorf("expected interrupted job to be failed, got %+v", got)
+	}
+
+	jobs, total, err := db.GetJobs(ctx, JobFilter{})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	if total != 2 || len(jobs) != 2 || jobs[0].ID != cleanup.ID {
+		t.Errorf("expected both jobs newest first, got %d: %+v", total, jobs)
+	}
+	jobs, total, _ = db.GetJobs(ctx, JobFilter{Type: "vacuum", Status: JobStatusFailed})
+	if total != 1 || len(jobs) != 1 || jobs[0].ID != vacuum.ID {
+		t.Errorf("expected the vacuum job, got %d: %+v", total, jobs)
+	}
+
+	if got, err := db.GetJob(ctx, 999); err != nil || got != nil {
+		t.Errorf("expected no job, got %+v (%v)", got, err)
+	}
+}
+
+// ============================================================================
+// Broadcast Job Tests
+// ============================================================================
+
+func TestBroadcastJobs(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	since := time.Unix(1700000000, 0)
+	b := &BroadcastJob{
+		Authors:      []string{"aa"},
+		Kinds:        []int{1, 30023},
+		Since:        &since,
+		Relays:       []string{"wss://one", "wss://two"},
+		RelayResults: map[string]*BroadcastRelayResult{"wss://one": {}, "wss://two": {}},
+	}
+	if err := db.CreateBroadcastJob(ctx, b); err != nil {
+		t.Fatalf("CreateBroadcastJob failed: %v", err)
+	}
+	if b.ID == 0 || b.Status != "running" {
+		t.Fatalf("unexpected created broadcast: %+v", b)
+	}
+	db.SetBroadcastJobID(ctx, b.ID, 42)
+
+	b.EventsTotal, b.EventsSent = 10, 4
+	b.RelayResults["wss://one"].OK = 3
+	b.RelayResults["wss://one"].Duplicate = 1
+	b.RelayResults["wss://two"].Error = 4
+	b.RelayResults["wss://two"].LastError = "rate-limited"
+	if err := db.UpdateBroadcastProgress(ctx, b); err != nil {
+		t.Fatalf("UpdateBroadcastProgress failed: %v", err)
+	}
+
+	got, err := db.GetBroadcastJob(ctx, b.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetBroadcastJob failed: %v", err)
+	}
+	if got.JobID != 42 || got.EventsTotal != 10 || got.EventsSent != 4 || len(got.Kinds) != 2 ||
+		got.Since == nil || !got.Since.Equal(since) || got.Until != nil || len(got.Relays) != 2 {
+		t.Errorf("unexpected broadcast: %+v", got)
+	}
+	if one := got.RelayResults["wss://one"]; one == nil || one.OK != 3 || one.Duplicate != 1 {
+		t.Errorf("unexpected results for wss://one: %+v", one)
+	}
+	if two := got.RelayResults["wss://two"]; two == nil || two.Error != 4 || two.LastError != "rate-limited" {
+		t.Errorf("unexpected results for wss://two: %+v", two)
+	}
+
+	if err := db.CompleteBroadcastJob(ctx, b, "completed", ""); err != nil {
+		t.Fatalf("CompleteBroadcastJob failed: %v", err)
+	}
+	got, _ = db.GetBroadcastJob(ctx, b.ID)
+	if got.Status != "completed" || got.CompletedAt == nil || got.ErrorMessage != "" {
+		t.Errorf("unexpected completed broadcast: %+v", got)
+	}
+
+	// A second broadcast is still running when the process "restarts"
+	running := &BroadcastJob{Relays: []string{"wss://one"}}
+	db.CreateBroadcastJob(ctx, running)
+	if n, err := db.FailInterruptedBroadcastJobs(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 interrupted broadcast, got %d (%v)", n, err)
+	}
+	got, _ = db.GetBroadcastJob(ctx, running.ID)
+	if got.Status != "failed" || got.ErrorMessage == "" || len(got.Authors) != 0 || got.Authors == nil {
+		t.Errorf("expected interrupted broadcast to be failed, got %+v", got)
+	}
+
+	broadcasts, total, err := db.GetBroadcastJobs(ctx, 1, 0)
+	if err != nil {
+		t.Fatalf("GetBroadcastJobs failed: %v", err)
+	}
+	if total != 2 || len(broadcasts) != 1 || broadcasts[0].ID != running.ID {
+		t.Errorf("expected the newest broadcast of 2, got %d: %+v", total, broadcasts)
+	}
+
+	if got, err := db.GetBroadcastJob(ctx, 999); err != nil || got != nil {
+		t.Errorf("expected no broadcast, got %+v (%v)", got, err)
+	}
+}
+
+// ============================================================================
+// Moderation Tests
+// ============================================================================
+
+func TestModeration(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	settings, err := db.GetModerationSettings(ctx)
+	if err != nil || settings.Enabled || settings.FlagScore != 1 || !settings.ExemptMembers {
+		t.Fatalf("expected default settings, got %+v (%v)", settings, err)
+	}
+
+	rule := &ModerationRule{Name: "scam", Type: "banned_words", Score: 5, Enabled: true,
+		Config: ModerationRuleConfig{Patterns: []string{"free bitcoin"}, Kinds: []int{1}}}
+	if err := db.CreateModerationRule(ctx, rule); err != nil {
+		t.Fatalf("CreateModerationRule failed: %v", err)
+	}
+	rule.Score = 8
+	rule.Enabled = false
+	if err := db.UpdateModerationRule(ctx, rule); err != nil {
+		t.Fatalf("UpdateModerationRule failed: %v", err)
+	}
+	got, err := db.GetModerationRule(ctx, rule.ID)
+	if err != nil || got == nil || got.Score != 8 || got.Enabled || len(got.Config.Patterns) != 1 || got.Config.Kinds[0] != 1 {
+		t.Fatalf("unexpected rule: %+v (%v)", got, err)
+	}
+
+	pubkey := strings.Repeat("aa", 32)
+	db.AddModerationAction(ctx, &ModerationAction{EventID: "e1", Pubkey: pubkey, Kind: 1, Score: 1, Rules: []string{"links"}, Action: "flag"})
+	db.AddModerationAction(ctx, &ModerationAction{EventID: "e2", Pubkey: pubkey, Kind: 1, Score: 5, Rules: []string{"scam"}, Action: "delete"})
+	db.AddModerationAction(ctx, &ModerationAction{EventID: "e3", Pubkey: strings.Repeat("bb", 32), Kind: 1, Score: 5, Action: "delete"})
+
+	actions, total, err := db.GetModerationActions(ctx, ModerationActionFilter{Pubkey: pubkey, Action: "delete", Limit: 10})
+	if err != nil || total != 1 || len(actions) != 1 || actions[0].EventID != "e2" || actions[0].Rules[0] != "scam" {
+		t.Errorf("unexpected filtered actions: %+v, total %d (%v)", actions, total, err)
+	}
+	if _, total, _ := db.GetModerationActions(ctx, ModerationActionFilter{Limit: 1}); total != 3 {
+		t.Errorf("expected 3 actions in total, got %d", total)
+	}
+
+	if n, err := db.DeleteModerationActionsBefore(ctx, time.Now().Add(time.Hour)); err != nil || n != 3 {
+		t.Errorf("expected 3 actions pruned, got %d (%v)", n, err)
+	}
+
+	db.DeleteModerationRule(ctx, rule.ID)
+	if got, _ := db.GetModerationRule(ctx, rule.ID); got != nil {
+		t.Error("expected rule to be deleted")
+	}
+}
+
+// ============================================================================
+// IP Ban Tests
+// ============================================================================
+
+func TestIPBans(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	if settings, err := db.GetRateLimitSettings(ctx); err != nil || !settings.Enabled || settings.Signup.PerMinute != 6 {
+		t.Fatalf("expected default settings, got %+v (%v)", settings, err)
+	}
+
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+	db.AddIPBan(ctx, &IPBan{IP: "203.0.113.7", Reason: "spam", Source: "manual"})
+	db.AddIPBan(ctx, &IPBan{IP: "198.51.100.0/24", Source: "auto", ExpiresAt: &later})
+	db.AddIPBan(ctx, &IPBan{IP: "192.0.2.1", Source: "auto", ExpiresAt: &expired})
+
+	bans, err := db.GetIPBans(ctx, now)
+	if err != nil || len(bans) != 2 {
+		t.Fatalf("expected 2 bans in effect, got %+v (%v)", bans, err)
+	}
+	for _, ban := range bans {
+		if ban.IP == "203.0.113.7" && (ban.Reason != "spam" || ban.ExpiresAt != nil) {
+			t.Errorf("unexpected permanent ban: %+v", ban)
+		}
+		if ban.IP == "198.51.100.0/24" && (ban.ExpiresAt == nil || ban.ExpiresAt.Unix() != later.Unix()) {
+			t.Errorf("unexpected expiring ban: %+v", ban)
+		}
+	}
+
+	if n, err := db.DeleteExpiredIPBans(ctx, now); err != nil || n != 1 {
+		t.Errorf("expected 1 expired ban deleted, got %d (%v)", n, err)
+	}
+	if removed, _ := db.RemoveIPBan(ctx, "203.0.113.7"); !removed {
+		t.Error("expected the ban to be removed")
+	}
+	if removed, _ := db.RemoveIPBan(ctx, "203.0.113.7"); removed {
+		t.Error("expected no ban left to remove")
+	}
+}
+
+func TestConfigHistory(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	db.AddConfigVersion(ctx, "[limits]\nmessages_per_sec = 5\n", "setup", "Initial setup")
+	db.AddConfigVersion(ctx, "[limits]\nmessages_per_sec = 5\n", "roostr", "Whitelist synced")
+	db.AddConfigVersion(ctx, "[limits]\nmessages_per_sec = 50\n", "admin", "Updated limits")
+
+	versions, total, err := db.GetConfigVersions(ctx, 20, 0)
+	if err != nil || total != 2 || len(versions) != 2 {
+		t.Fatalf("expected 2 versions, the duplicate skipped, got %+v (%d, %v)", versions, total, err)
+	}
+	if v := versions[0]; v.Actor != "admin" || v.Reason != "Updated limits" || v.Size != 31 || v.Content != "" {
+		t.Errorf("unexpected newest version %+v", v)
+	}
+
+	v, err := db.GetConfigVersion(ctx, versions[1].ID)
+	if err != nil || v == nil || v.Content != "[limits]\nmessages_per_sec = 5\n" || v.Actor != "setup" {
+		t.Errorf("unexpected version %+v (%v)", v, err)
+	}
+	if v, _ := db.GetConfigVersion(ctx, 999); v != nil {
+		t.Errorf("expected no version, got %+v", v)
+	}
+
+	for i := 0; i < maxConfigVersions+5; i++ {
+		db.AddConfigVersion(ctx, fmt.Sprintf("# %d\n", i), "roostr", "")
+	}
+	if _, total, _ := db.GetConfigVersions(ctx, 1, 0); total != maxConfigVersions {
+		t.Errorf("expected %d versions kept, got %d", maxConfigVersions, total)
+	}
+}
+
+func TestAuditLogQuery(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	const operator = "aa11"
+	db.AddAuditLog(ctx, "whitelist_add", map[string]string{"pubkey": "npub1alice"}, "")
+	db.AddAuditLog(ctx, "whitelist_remove", map[string]string{"pubkey": "bb22"}, "")
+	db.AddAuditLog(ctx, "relay_info_updated", map[string]string{"name": "Relay"}, operator)
+	db.AppDB.Exec("UPDATE audit_log SET created_at = ? WHERE action = 'whitelist_add'", time.Now().AddDate(0, 0, -400).Unix())
+
+	entries, total, err := db.GetAuditLog(ctx, AuditLogFilter{})
+	if err != nil || total != 3 || len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v (%d, %v)", entries, total, err)
+	}
+	if e := entries[0]; e.Action != "relay_info_updated" || e.PerformedBy != operator || string(e.Details) != `{"name":"Relay"}` {
+		t.Errorf("unexpected newest entry %+v", e)
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditLogFilter
+		want   int64
+	}{
+		{"action", AuditLogFilter{Action: "whitelist_remove"}, 1},
+		{"performed by", AuditLogFilter{PerformedBy: operator}, 1},
+		{"pubkey in details", AuditLogFilter{Pubkeys: []string{"bb22", "npub1bob"}}, 1},
+		{"pubkey as performer", AuditLogFilter{Pubkeys: []string{operator}}, 1},
+		{"details", AuditLogFilter{Query: "npub1alice"}, 1},
+		{"since", AuditLogFilter{Since: time.Now().AddDate(0, 0, -1)}, 2},
+		{"until", AuditLogFilter{Until: time.Now().AddDate(0, 0, -1)}, 1},
+	}
+	for _, tt := range tests {
+		if _, total, err := db.GetAuditLog(ctx, tt.filter); err != nil || total != tt.want {
+			t.Errorf("%s: got %d entries (%v), want %d", tt.name, total, err, tt.want)
+		}
+	}
+
+	if entries, _, _ := db.GetAuditLog(ctx, AuditLogFilter{Limit: 1, Offset: 1}); len(entries) != 1 || entries[0].Action != "whitelist_remove" {
+		t.Errorf("unexpected page %+v", entries)
+	}
+
+	if n, err := db.DeleteAuditLogBefore(ctx, time.Now().AddDate(0, 0, -365)); err != nil || n != 1 {
+		t.Errorf("expected 1 entry pruned, got %d (%v)", n, err)
+	}
+}
+
+// ============================================================================
+// Tombstone Tests
+// ============================================================================
+
+func TestTombstones(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	first := time.Unix(1700000000, 0)
+	err := db.AddTombstones(ctx, []Tombstone{
+		{EventID: testEventID1, Author: testPubkey1, DeletedAt: first, Reason: "spam"},
+		{EventID: testEventID2, Author: testPubkey2, DeletedAt: first.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("AddTombstones failed: %v", err)
+	}
+	// A second deletion of the same event keeps the first record
+	db.AddTombstones(ctx, []Tombstone{{EventID: testEventID1, Author: testPubkey1, DeletedAt: first.Add(2 * time.Hour)}})
+
+	tombstones, total, err := db.GetTombstones(ctx, 10, 0)
+	if err != nil || total != 2 || len(tombstones) != 2 {
+		t.Fatalf("expected 2 tombstones, got %+v, %d (%v)", tombstones, total, err)
+	}
+	if tombstones[0].EventID != testEventID2 || tombstones[1].Reason != "spam" || !tombstones[1].DeletedAt.Equal(first) {
+		t.Errorf("unexpected tombstones: %+v", tombstones)
+	}
+
+	db.SetTombstonesPublished(ctx, []string{testEventID1}, testEventID5)
+	if tombstones, _, _ := db.GetTombstones(ctx, 1, 1); tombstones[0].DeletionEventID != testEventID5 {
+		t.Errorf("expected the deletion event to be recorded, got %+v", tombstones[0])
+	}
+
+	if deleted, _ := db.DeleteTombstone(ctx, testEventID1); !deleted {
+		t.Error("expected the tombstone to be deleted")
+	}
+	if tombstoned, _ := db.IsTombstoned(ctx, testEventID1); tombstoned {
+		t.Error("expected the event not to be tombstoned after deletion")
+	}
+	if deleted, _ := db.DeleteTombstone(ctx, testEventID1); deleted {
+		t.Error("expected no tombstone left to delete")
+	}
+}
+
+func TestOperatorSigningKey(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	key := strings.Repeat("5", 64)
+
+	if err := db.SetOperatorSigningKey(ctx, key); err != nil {
+		t.Fatalf("SetOperatorSigningKey failed: %v", err)
+	}
+	if got, err := db.GetOperatorSigningKey(ctx); err != nil || got != key {
+		t.Errorf("GetOperatorSigningKey = %q, %v", got, err)
+	}
+
+	db.SetOperatorSigningKey(ctx, "")
+	if got, _ := db.GetOperatorSigningKey(ctx); got != "" {
+		t.Errorf("expected the key to be removed, got %q", got)
+	}
+}
+
+func TestPrivacySettings(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	settings, err := db.GetPrivacySettings(ctx)
+	if err != nil || settings.RedactDMs {
+		t.Fatalf("expected DM privacy mode off by default, got %+v, %v", settings, err)
+	}
+	if err := db.SetPrivacySettings(ctx, &PrivacySettings{RedactDMs: true}); err != nil {
+		t.Fatalf("SetPrivacySettings failed: %v", err)
+	}
+	if settings, _ = db.GetPrivacySettings(ctx); !settings.RedactDMs {
+		t.Error("expected DM privacy mode on")
+	}
+
+	events := []Event{
+		{ID: "dm", Kind: 4, Content: "ciphertext?iv=abc", Tags: [][]string{{"p", testPubkey2}}},
+		{ID: "wrap", Kind: 1059, Content: "ciphertext"},
+		{ID: "note", Kind: 1, Content: "hello"},
+	}
+	RedactDMs(events)
+	for _, e := range events[:2] {
+		if e.Content != "" || !e.Redacted {
+			t.Errorf("expected %s redacted, got %+v", e.ID, e)
+		}
+	}
+	if len(events[0].Tags) != 1 {
+		t.Errorf("expected the DM's tags kept, got %+v", events[0].Tags)
+	}
+	if events[2].Content != "hello" || events[2].Redacted {
+		t.Errorf("expected the note left alone, got %+v", events[2])
+	}
+
+	export := ExportEvent{Kind: 1060, Content: "ciphertext", Sig: "sig"}
+	export.RedactDM()
+	if export.Content != "" || export.Sig != "sig" {
+		t.Errorf("expected the export redacted with its metadata kept, got %+v", export)
+	}
+}
+
+func TestCountMembers(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	for _, e := range []WhitelistEntry{
+		{Pubkey: "operator", Npub: "npub1op", IsOperator: true},
+		{Pubkey: "friend", Npub: "npub1friend"},
+		{Pubkey: "subscriber", Npub: "npub1sub"},
+		{Pubkey: "lapsed", Npub: "npub1lapsed"},
+	} {
+		if err := db.AddWhitelistEntry(ctx, e); err != nil {
+			t.Fatalf("AddWhitelistEntry failed: %v", err)
+		}
+	}
+	for _, u := range []PaidUser{
+		{Pubkey: "subscriber", Npub: "npub1sub", Tier: "monthly", Status: "active"},
+		{Pubkey: "grace", Npub: "npub1grace", Tier: "monthly", Status: "grace"},
+		{Pubkey: "lapsed", Npub: "npub1lapsed", Tier: "monthly", Status: "expired"},
+	} {
+		if err := db.AddPaidUser(ctx, u); err != nil {
+			t.Fatalf("AddPaidUser failed: %v", err)
+		}
+	}
+	if _, _, err := db.ReplaceFollowGrants(ctx, []FollowGrant{{Pubkey: "followed", Followers: 2}, {Pubkey: "friend", Followers: 3}}); err != nil {
+		t.Fatalf("ReplaceFollowGrants failed: %v", err)
+	}
+	if err := db.AddBlacklistEntry(ctx, BlacklistEntry{Pubkey: "spammer", Npub: "npub1spam"}); err != nil {
+		t.Fatalf("AddBlacklistEntry failed: %v", err)
+	}
+
+	counts, err := db.CountMembers(ctx)
+	if err != nil {
+		t.Fatalf("CountMembers failed: %v", err)
+	}
+	// The lapsed subscriber is still whitelisted; the friend also followed
+	// is counted once
+	want := MemberCounts{Operator: 1, Paid: 2, Whitelisted: 2, Follow: 1, Total: 6, Blacklisted: 1}
+	if *counts != want {
+		t.Errorf("expected %+v, got %+v", want, *counts)
+	}
+}
+
+func TestGetAuthorAccess(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	if err := db.AddWhitelistEntry(ctx, WhitelistEntry{Pubkey: "alice", Npub: "npub1alice", Nickname: "Alice"}); err != nil {
+		t.Fatalf("AddWhitelistEntry failed: %v", err)
+	}
+	if err := db.AddPaidUser(ctx, PaidUser{Pubkey: "bob", Npub: "npub1bob", Tier: "monthly", Status: "grace"}); err != nil {
+		t.Fatalf("AddPaidUser failed: %v", err)
+	}
+	if err := db.SaveProfile(ctx, &Profile{Pubkey: "bob", Name: "bob", DisplayName: "Bob", Nip05: "bob@example.com", Source: "local"}); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+	if err := db.AddBlacklistEntry(ctx, BlacklistEntry{Pubkey: "spammer", Npub: "npub1spam"}); err != nil {
+		t.Fatalf("AddBlacklistEntry failed: %v", err)
+	}
+
+	access, err := db.GetAuthorAccess(ctx, []string{"alice", "bob", "spammer", "stranger"})
+	if err != nil {
+		t.Fatalf("GetAuthorAccess failed: %v", err)
+	}
+	if len(access) != 4 {
+		t.Fatalf("expected 4 authors, got %v", access)
+	}
+	if a := access["alice"]; !a.Whitelisted || a.Nickname != "Alice" || a.PaidStatus != "" {
+		t.Errorf("unexpected access for alice: %+v", a)
+	}
+	if b := access["bob"]; b.Whitelisted || b.PaidStatus != "grace" || b.Name != "Bob" || b.Nip05 != "bob@example.com" {
+		t.Errorf("unexpected access for bob: %+v", b)
+	}
+	if s := access["spammer"]; !s.Blacklisted {
+		t.Errorf("expected spammer blacklisted, got %+v", s)
+	}
+	if s := access["stranger"]; s != (AuthorAccess{}) {
+		t.Errorf("expected no access for a stranger, got %+v", s)
+	}
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// backupRetryDelay is how long a backup waits when the source is busy.
+const backupRetryDelay = 100 * time.Millisecond
+
+// ErrRelayDBMissing is returned when backing up a relay database that has
+// not been created yet.
+var ErrRelayDBMissing = errors.New("relay database does not exist")
+
+// BackupAppDB writes a consistent copy of the app database to dest using
+// SQLite's online backup API. dest must not exist.
+func (d *DB) BackupAppDB(ctx context.Context, dest string) error {
+	d.mu.RLock()
+	appPath := d.appPath
+	d.mu.RUnlock()
+
+	return backupSQLite(ctx, fmt.Sprintf("file:%s?mode=ro&_busy_timeout=5000", appPath), dest)
+}
+
+// BackupRelayDB writes a consistent copy of the relay database to dest using
+// SQLite's online backup API, while the relay keeps writing. dest must not
+// exist.
+func (d *DB) BackupRelayDB(ctx context.Context, dest string) error {
+	d.mu.RLock()
+	relayPath := d.relayPath
+	d.mu.RUnlock()
+
+	if relayPath == "" {
+		return ErrRelayDBMissing
+	}
+	if _, err := os.Stat(relayPath); os.IsNotExist(err) {
+		return ErrRelayDBMissing
+	}
+	return backupSQLite(ctx, fmt.Sprintf("file:%s?mode=ro&_busy_timeout=10000", relayPath), dest)
+}
+
+// backupSQLite copies the database at srcDSN into a new database file at
+// dest. The copy runs in one step, so it is a snapshot of a single read
+// transaction; in WAL mode writers are not blocked meanwhile.
+func backupSQLite(ctx context.Context, srcDSN, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup destination already exists: %s", dest)
+	}
+
+	src, err := sql.Open("sqlite3", srcDSN)
+	if err != nil {
+		return fmt.Errorf("failed to open source database: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return fmt.Errorf("failed to open backup database: %w", err)
+	}
+	defer dst.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to source database: %w", err)
+	}
+	defer srcConn.Close()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create backup database: %w", err)
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dstDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			dstSQLite, ok := dstDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", dstDriver)
+			}
+			srcSQLite, ok := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", srcDriver)
+			}
+
+			backup, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+			// Step reports busy or locked sources as not done, so retry
+			for {
+				if err := ctx.Err(); err != nil {
+					backup.Close()
+					return err
+				}
+				done, err := backup.Step(-1)
+				if err != nil {
+					backup.Close()
+					return fmt.Errorf("backup failed: %w", err)
+				}
+				if done {
+					break
+				}
+				time.Sleep(backupRetryDelay)
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
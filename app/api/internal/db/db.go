@@ -1,7 +1,7 @@
 // Package db provides database access for Roostr.
-// It manages two SQLite databases:
-// - Relay DB (read-only): The nostr-rs-relay database
-// - App DB (read-write): Roostr's own database for settings and metadata
+// It manages two databases:
+// - Relay DB (read-only): The nostr-rs-relay database, SQLite or PostgreSQL
+// - App DB (read-write): Roostr's own SQLite database for settings and metadata
 package db
 
 import (
@@ -9,25 +9,68 @@ import (
 	"database/sql"
 	_ "embed"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/roostr/roostr/app/api/internal/secrets"
 )
 
 //go:embed schema.sql
 var schema string
 
+// Limits bound the resources database access uses, so they can be tuned to
+// the hardware Roostr runs on.
+type Limits struct {
+	MaxQueryLimit int // Most events one relay database query returns
+	CacheSizeKB   int // SQLite page cache per connection
+	RelayReaders  int // Open read connections to the relay database
+}
+
+// DefaultLimits are the limits used unless SetLimits is called.
+var DefaultLimits = Limits{
+	MaxQueryLimit: 1000,
+	CacheSizeKB:   8192,
+	RelayReaders:  3,
+}
+
+// Changes to the relay database file reported to OnRelayDBChange listeners.
+const (
+	RelayDBAttached   = "attached"   // The file appeared and was opened
+	RelayDBReattached = "reattached" // The file was replaced, e.g. by a restore, and reopened
+	RelayDBDetached   = "detached"   // The file disappeared and was closed
+)
+
 // DB holds database connections.
 type DB struct {
-	RelayDB *sql.DB // Read-only access to relay database
-	AppDB   *sql.DB // Read-write access to app database
-
-	relayPath string
-	appPath   string
-	mu        sync.RWMutex
+	AppDB *sql.DB // Read-write access to app database
+
+	relayDB      atomic.Pointer[sql.DB] // Read-only access to relay database
+	relayPath    string
+	relayDSN     string // PostgreSQL relay database; empty for SQLite at relayPath
+	appPath      string
+	limits       Limits
+	dialect      relayDialect // nil for SQLite
+	backend      RelayBackend // nil when the relay database is nostr-rs-relay's own
+	searchDB     *sql.DB      // Full-text search index, opened on first use
+	searchEngine string
+	vault        *secrets.Vault // Encrypts credentials such as the Lightning macaroon
+	relayWriter  *sql.DB        // Serialized read-write relay connection, opened on first write
+	relayFile    os.FileInfo    // The relay database file relayDB has open
+	listeners    []func(change string)
+	watchStop    chan struct{}
+	mu           sync.RWMutex
+
+	authorCounts   map[string]authorCount // Event counts reused by CountEventsByPubkeyCached
+	authorCountsMu sync.Mutex
+
+	expirationStatsMu sync.Mutex // Serializes updates to expiration_stats
 }
 
 // New creates a new DB instance and initializes connections.
@@ -35,6 +78,8 @@ func New(relayDBPath, appDBPath string) (*DB, error) {
 	db := &DB{
 		relayPath: relayDBPath,
 		appPath:   appDBPath,
+		limits:    DefaultLimits,
+		vault:     secrets.NewVault(),
 	}
 
 	// Initialize app database (required)
@@ -46,7 +91,7 @@ func New(relayDBPath, appDBPath string) (*DB, error) {
 	if relayDBPath != "" {
 		if err := db.connectRelayDB(); err != nil {
 			// Log warning but don't fail - relay DB may not exist yet
-			fmt.Printf("Warning: Could not connect to relay database: %v\n", err)
+			slog.Warn("Could not connect to relay database", "error", err)
 		}
 	}
 
@@ -78,6 +123,10 @@ func (d *DB) initAppDB() error {
 		return fmt.Errorf("failed to ping app database: %w", err)
 	}
 
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA cache_size = -%d", d.limits.CacheSizeKB)); err != nil {
+		slog.Warn("Failed to set app database cache size", "error", err)
+	}
+
 	// Apply schema
 	if _, err := db.Exec(schema); err != nil {
 		db.Close()
@@ -90,20 +139,26 @@ func (d *DB) initAppDB() error {
 
 // connectRelayDB connects to the relay database in read-only mode.
 func (d *DB) connectRelayDB() error {
+	if d.relayDSN != "" {
+		return d.connectPostgresRelay()
+	}
+
 	// Check if file exists
-	if _, err := os.Stat(d.relayPath); os.IsNotExist(err) {
+	info, err := os.Stat(d.relayPath)
+	if os.IsNotExist(err) {
 		return fmt.Errorf("relay database does not exist: %s", d.relayPath)
 	}
 
-	// Open in read-only mode
-	dsn := fmt.Sprintf("file:%s?mode=ro&_busy_timeout=5000", d.relayPath)
+	// Open read-only. The relay writes to the file while it is read, so it
+	// is not opened immutable; the busy timeout covers its checkpoints
+	dsn := fmt.Sprintf("file:%s?mode=ro&_query_only=true&_busy_timeout=5000&_cache_size=-%d", d.relayPath, d.limits.CacheSizeKB)
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open relay database: %w", err)
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(3) // Allow multiple readers
+	db.SetMaxOpenConns(d.limits.RelayReaders) // Allow multiple readers
 	db.SetMaxIdleConns(1)
 
 	// Test connection
@@ -112,22 +167,52 @@ func (d *DB) connectRelayDB() error {
 		return fmt.Errorf("failed to ping relay database: %w", err)
 	}
 
-	d.RelayDB = db
+	d.relayDB.Store(db)
+	d.relayFile = info
 	return nil
 }
 
+// RelayDB returns the read-only relay database connection, or nil if it is
+// not connected. CheckRelayDB can replace the connection at any time, so
+// callers fetch it once and use it for the whole operation.
+func (d *DB) RelayDB() *sql.DB {
+	return d.relayDB.Load()
+}
+
+// relayDrainDelay is how long a replaced relay database connection stays
+// open for queries that fetched it just before it was replaced.
+const relayDrainDelay = 10 * time.Second
+
+// retireRelayDB disconnects the relay database and closes the old
+// connection once the queries using it are done. Close waits for queries
+// already running; the delay covers those about to start.
+func (d *DB) retireRelayDB() {
+	if old := d.relayDB.Swap(nil); old != nil {
+		time.AfterFunc(relayDrainDelay, func() { old.Close() })
+	}
+}
+
 // Close closes all database connections.
 func (d *DB) Close() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	if d.watchStop != nil {
+		close(d.watchStop)
+		d.watchStop = nil
+	}
+
 	var errs []error
 
-	if d.RelayDB != nil {
-		if err := d.RelayDB.Close(); err != nil {
+	d.closeRelayWriteDB()
+	if relay := d.relayDB.Swap(nil); relay != nil {
+		if err := relay.Close(); err != nil {
 			errs = append(errs, fmt.Errorf("failed to close relay database: %w", err))
 		}
-		d.RelayDB = nil
+	}
+
+	if err := d.closeSearchIndex(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close search index: %w", err))
 	}
 
 	if d.AppDB != nil {
@@ -145,9 +230,7 @@ func (d *DB) Close() error {
 
 // IsRelayDBConnected returns true if the relay database is connected.
 func (d *DB) IsRelayDBConnected() bool {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-	return d.RelayDB != nil
+	return d.RelayDB() != nil
 }
 
 // ReconnectRelayDB attempts to reconnect to the relay database.
@@ -156,14 +239,149 @@ func (d *DB) ReconnectRelayDB() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if d.RelayDB != nil {
-		d.RelayDB.Close()
-		d.RelayDB = nil
+	d.closeRelayWriteDB()
+	d.retireRelayDB()
+
+	return d.connectRelayDB()
+}
+
+// OnRelayDBChange registers fn to be called with RelayDBAttached,
+// RelayDBReattached or RelayDBDetached when CheckRelayDB changes the relay
+// database connection.
+func (d *DB) OnRelayDBChange(fn func(change string)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, fn)
+}
+
+// WatchRelayDB calls CheckRelayDB every interval until the DB is closed, so
+// a relay installed after Roostr, or a database restored from a backup, is
+// picked up without a restart.
+func (d *DB) WatchRelayDB(interval time.Duration) {
+	d.mu.Lock()
+	if d.watchStop != nil {
+		d.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	d.watchStop = stop
+	d.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := d.CheckRelayDB(); err != nil {
+					slog.Warn("Could not attach relay database", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// CheckRelayDB brings the relay database connection in line with the file
+// on disk: it opens a file that has appeared, reopens one that has been
+// replaced and closes one that has gone. It returns the change made, or ""
+// if there was none. A PostgreSQL relay database is left alone.
+func (d *DB) CheckRelayDB() (string, error) {
+	d.mu.Lock()
+	if d.relayPath == "" || d.relayDSN != "" {
+		d.mu.Unlock()
+		return "", nil
+	}
+
+	info, statErr := os.Stat(d.relayPath)
+	var change string
+	var err error
+	connected := d.RelayDB() != nil
+	switch {
+	case !connected && statErr == nil:
+		if err = d.connectRelayDB(); err == nil {
+			change = RelayDBAttached
+		}
+	case connected && os.IsNotExist(statErr):
+		d.retireRelayDB()
+		d.relayFile = nil
+		change = RelayDBDetached
+	case connected && statErr == nil && d.relayFile != nil && !os.SameFile(d.relayFile, info):
+		d.retireRelayDB()
+		if err = d.connectRelayDB(); err == nil {
+			change = RelayDBReattached
+		} else {
+			change = RelayDBDetached
+		}
+	}
+	if change != "" {
+		d.closeRelayWriteDB()
+	}
+	listeners := d.listeners
+	d.mu.Unlock()
+
+	if change == "" {
+		return "", err
+	}
+	d.clearAuthorCounts()
+	slog.Info("Relay database "+change, "path", d.GetRelayPath())
+	for _, fn := range listeners {
+		fn(change)
+	}
+	return change, err
+}
+
+// Limits returns the current resource limits.
+func (d *DB) Limits() Limits {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.limits
+}
+
+// SetLimits changes the resource limits. Query limits and the number of
+// relay readers apply at once; a new cache size reopens the relay database.
+func (d *DB) SetLimits(limits Limits) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if limits.MaxQueryLimit <= 0 {
+		limits.MaxQueryLimit = DefaultLimits.MaxQueryLimit
+	}
+	if limits.CacheSizeKB <= 0 {
+		limits.CacheSizeKB = DefaultLimits.CacheSizeKB
 	}
+	if limits.RelayReaders <= 0 {
+		limits.RelayReaders = DefaultLimits.RelayReaders
+	}
+
+	cacheChanged := limits.CacheSizeKB != d.limits.CacheSizeKB
+	d.limits = limits
 
+	if cacheChanged && d.AppDB != nil {
+		if _, err := d.AppDB.Exec(fmt.Sprintf("PRAGMA cache_size = -%d", limits.CacheSizeKB)); err != nil {
+			return fmt.Errorf("failed to set app database cache size: %w", err)
+		}
+	}
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil
+	}
+	if !cacheChanged || d.relayPath == "" || d.relayDSN != "" {
+		relay.SetMaxOpenConns(limits.RelayReaders)
+		return nil
+	}
+	d.retireRelayDB()
 	return d.connectRelayDB()
 }
 
+// maxQueryLimit returns the most events one relay database query returns.
+func (d *DB) maxQueryLimit() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.limits.MaxQueryLimit
+}
+
 // Transaction executes a function within a database transaction on the app database.
 func (d *DB) Transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
 	tx, err := d.AppDB.BeginTx(ctx, nil)
@@ -195,21 +413,9 @@ func (d *DB) GetSchemaVersion() (int, error) {
 	return version, nil
 }
 
-// GetRelayDatabaseSize returns the size of the relay database file in bytes.
+// GetRelayDatabaseSize returns the size of the relay database in bytes.
 func (d *DB) GetRelayDatabaseSize() (int64, error) {
-	if d.relayPath == "" {
-		return 0, nil
-	}
-
-	info, err := os.Stat(d.relayPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return 0, nil
-		}
-		return 0, fmt.Errorf("failed to stat relay database: %w", err)
-	}
-
-	return info.Size(), nil
+	return d.relayDialect().size(context.Background(), d.RelayDB(), d.relayPath)
 }
 
 // GetAppDatabaseSize returns the size of the app database file in bytes.
@@ -229,43 +435,81 @@ func (d *DB) GetAppDatabaseSize() (int64, error) {
 	return info.Size(), nil
 }
 
-// OpenRelayDBForWrite opens a temporary read-write connection to the relay database.
-// The caller is responsible for closing the connection when done.
-// This should only be used for maintenance operations like cleanup and vacuum.
-func (d *DB) OpenRelayDBForWrite() (*sql.DB, error) {
-	d.mu.RLock()
-	relayPath := d.relayPath
-	d.mu.RUnlock()
-
-	if relayPath == "" {
-		return nil, fmt.Errorf("relay database path not configured")
+// GetWALSizes returns the sizes of the relay and app databases' SQLite
+// write-ahead logs in bytes. A missing log, or a PostgreSQL relay database,
+// counts as 0.
+func (d *DB) GetWALSizes() (relay, app int64) {
+	walSize := func(path string) int64 {
+		if path == "" {
+			return 0
+		}
+		info, err := os.Stat(path + "-wal")
+		if err != nil {
+			return 0
+		}
+		return info.Size()
 	}
-
-	// Check if file exists
-	if _, err := os.Stat(relayPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("relay database does not exist: %s", relayPath)
+	if d.relayDSN == "" {
+		relay = walSize(d.relayPath)
 	}
+	return relay, walSize(d.appPath)
+}
 
-	// Open in read-write mode with WAL and busy timeout
-	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=10000", relayPath)
-	db, err := sql.Open("sqlite3", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open relay database for write: %w", err)
+// relayWriteDB returns the connection all writes to the relay database go
+// through, opening it on first use. It has a single connection, so writes
+// from retention, imports, syncs and maintenance are serialized rather than
+// contending with each other for the relay's write lock.
+func (d *DB) relayWriteDB() (*sql.DB, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.relayWriter != nil {
+		return d.relayWriter, nil
 	}
 
-	// Use single connection for write operations
+	var db *sql.DB
+	var err error
+	if d.relayDSN != "" {
+		if db, err = sql.Open("postgres", d.relayDSN); err != nil {
+			return nil, fmt.Errorf("failed to open relay database for write: %w", err)
+		}
+	} else {
+		if d.relayPath == "" {
+			return nil, fmt.Errorf("relay database path not configured")
+		}
+		if _, err := os.Stat(d.relayPath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("relay database does not exist: %s", d.relayPath)
+		}
+
+		// Transactions take the write lock when they begin, so one that
+		// reads first cannot deadlock with the relay and fail without
+		// waiting out the busy timeout
+		dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=10000&_txlock=immediate", d.relayPath)
+		if db, err = sql.Open("sqlite3", dsn); err != nil {
+			return nil, fmt.Errorf("failed to open relay database for write: %w", err)
+		}
+	}
 	db.SetMaxOpenConns(1)
 	db.SetMaxIdleConns(1)
 
-	// Test connection
 	if err := db.Ping(); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to ping relay database: %w", err)
+		return nil, fmt.Errorf("failed to connect to relay database for write: %w", err)
 	}
 
+	d.relayWriter = db
 	return db, nil
 }
 
+// closeRelayWriteDB closes the relay write connection, so the next write
+// opens the relay database as it is now. The caller must hold d.mu.
+func (d *DB) closeRelayWriteDB() {
+	if d.relayWriter != nil {
+		d.relayWriter.Close()
+		d.relayWriter = nil
+	}
+}
+
 // GetRelayPath returns the path to the relay database file.
 func (d *DB) GetRelayPath() string {
 	d.mu.RLock()
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// createRelayFile writes a SQLite database holding n events at path.
+func createRelayFile(t *testing.T, path string, n int) {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE event (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := conn.Exec("INSERT INTO event DEFAULT VALUES"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckRelayDB(t *testing.T) {
+	dir := t.TempDir()
+	relayPath := filepath.Join(dir, "nostr.db")
+
+	database, err := New(relayPath, filepath.Join(dir, "roostr.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	var changes []string
+	database.OnRelayDBChange(func(change string) { changes = append(changes, change) })
+
+	countEvents := func() int {
+		var n int
+		if err := database.RelayDB().QueryRow("SELECT COUNT(*) FROM event").Scan(&n); err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		return n
+	}
+	check := func(want string) {
+		t.Helper()
+		change, err := database.CheckRelayDB()
+		if err != nil || change != want {
+			t.Fatalf("CheckRelayDB = %q, %v; want %q", change, err, want)
+		}
+	}
+
+	// The relay has not created its database yet
+	if database.IsRelayDBConnected() {
+		t.Fatal("expected no relay database before the file exists")
+	}
+	check("")
+
+	createRelayFile(t, relayPath, 1)
+	check(RelayDBAttached)
+	if !database.IsRelayDBConnected() || countEvents() != 1 {
+		t.Fatal("expected the new relay database attached")
+	}
+	check("")
+
+	// A restore moves another database into place
+	restored := filepath.Join(dir, "restored.db")
+	createRelayFile(t, restored, 3)
+	if err := os.Rename(restored, relayPath); err != nil {
+		t.Fatal(err)
+	}
+	check(RelayDBReattached)
+	if countEvents() != 3 {
+		t.Error("expected the restored database to be read")
+	}
+
+	os.Remove(relayPath)
+	check(RelayDBDetached)
+	if database.IsRelayDBConnected() {
+		t.Error("expected the relay database detached once the file is gone")
+	}
+
+	if len(changes) != 3 || changes[0] != RelayDBAttached || changes[2] != RelayDBDetached {
+		t.Errorf("unexpected changes reported: %v", changes)
+	}
+}
+
+func TestCheckRelayDBKeepsOldConnectionForReaders(t *testing.T) {
+	dir := t.TempDir()
+	relayPath := filepath.Join(dir, "nostr.db")
+	createRelayFile(t, relayPath, 1)
+
+	database, err := New(relayPath, filepath.Join(dir, "roostr.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	// A query that fetched the connection before a restore replaced the
+	// file still runs on it
+	old := database.RelayDB()
+	restored := filepath.Join(dir, "restored.db")
+	createRelayFile(t, restored, 3)
+	if err := os.Rename(restored, relayPath); err != nil {
+		t.Fatal(err)
+	}
+	if change, err := database.CheckRelayDB(); err != nil || change != RelayDBReattached {
+		t.Fatalf("CheckRelayDB = %q, %v; want %q", change, err, RelayDBReattached)
+	}
+
+	var n int
+	if err := old.QueryRow("SELECT COUNT(*) FROM event").Scan(&n); err != nil || n != 1 {
+		t.Errorf("expected the old connection still open, got %d, %v", n, err)
+	}
+	if err := database.RelayDB().QueryRow("SELECT COUNT(*) FROM event").Scan(&n); err != nil || n != 3 {
+		t.Errorf("expected the restored database read, got %d, %v", n, err)
+	}
+}
+
+func TestRelayWriteConnection(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	relayPath := filepath.Join(dir, "nostr.db")
+	conn, err := sql.Open("sqlite3", relayPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(relayIndexSchema); err != nil {
+		t.Fatalf("failed to create relay schema: %v", err)
+	}
+	conn.Close()
+
+	database, err := New(relayPath, filepath.Join(dir, "roostr.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	// The read handle refuses writes
+	if _, err := database.RelayDB().Exec("DELETE FROM event"); err == nil {
+		t.Error("expected the read-only relay handle to refuse a write")
+	}
+
+	// Writers share one connection, which outlives each of them
+	first, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter failed: %v", err)
+	}
+	second, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter failed: %v", err)
+	}
+	if first.db != second.db || first.db.Stats().MaxOpenConnections != 1 {
+		t.Fatal("expected writers to share a single write connection")
+	}
+	first.Close()
+
+	event := &Event{ID: strings.Repeat("1", 64), Pubkey: strings.Repeat("a", 64), Kind: 1, CreatedAt: time.Now()}
+	if inserted, err := second.InsertEvent(ctx, event); err != nil || !inserted {
+		t.Fatalf("InsertEvent after another writer closed = %v, %v", inserted, err)
+	}
+	second.Close()
+
+	// Reattaching opens a new write connection on the new file
+	restored := filepath.Join(dir, "restored.db")
+	createRelayFile(t, restored, 0)
+	if err := os.Rename(restored, relayPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CheckRelayDB(); err != nil {
+		t.Fatalf("CheckRelayDB failed: %v", err)
+	}
+	third, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter failed: %v", err)
+	}
+	defer third.Close()
+	if third.db == first.db {
+		t.Error("expected a new write connection after the file was replaced")
+	}
+}
+
+func TestRetryBusy(t *testing.T) {
+	ctx := context.Background()
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+
+	attempts := 0
+	err := retryBusy(ctx, func() error {
+		attempts++
+		if attempts < 3 {
+			return busy
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("expected success on the third attempt, got %v after %d", err, attempts)
+	}
+
+	// Other errors are not retried
+	attempts = 0
+	other := errors.New("no such table: event")
+	if err := retryBusy(ctx, func() error { attempts++; return other }); err != other || attempts != 1 {
+		t.Errorf("expected one attempt for a non-busy error, got %v after %d", err, attempts)
+	}
+
+	// A cancelled context stops the retries
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	attempts = 0
+	if err := retryBusy(cancelled, func() error { attempts++; return busy }); !isBusy(err) || attempts != 1 {
+		t.Errorf("expected to give up once cancelled, got %v after %d", err, attempts)
+	}
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/testutil"
+)
+
+// openFixture generates a relay database and opens it with a fresh app
+// database. Set ROOSTR_FIXTURE_EVENTS to run against a larger one.
+func openFixture(tb testing.TB) (*DB, *testutil.RelayFixture) {
+	tb.Helper()
+
+	fixture := testutil.NewRelayDB(tb, testutil.RelayOptions{Authors: 200})
+	database, err := New(fixture.Path, filepath.Join(tb.TempDir(), "roostr.db"))
+	if err != nil {
+		tb.Fatalf("failed to open fixture: %v", err)
+	}
+	tb.Cleanup(func() { database.Close() })
+	return database, fixture
+}
+
+// TestRelayReaderFixture checks the reader's totals against what the
+// generator wrote.
+func TestRelayReaderFixture(t *testing.T) {
+	ctx := context.Background()
+	database, fixture := openFixture(t)
+
+	stats, err := database.GetRelayStats(ctx)
+	if err != nil {
+		t.Fatalf("GetRelayStats failed: %v", err)
+	}
+	if stats.TotalEvents != int64(fixture.Events) || stats.TotalPubkeys != int64(len(fixture.ByAuthor)) {
+		t.Errorf("stats = %d events from %d pubkeys, want %d from %d", stats.TotalEvents, stats.TotalPubkeys, fixture.Events, len(fixture.ByAuthor))
+	}
+	for kind, count := range fixture.Kinds {
+		if stats.EventsByKind[kind] != int64(count) {
+			t.Errorf("kind %d: %d events, want %d", kind, stats.EventsByKind[kind], count)
+		}
+	}
+
+	top, err := database.GetTopAuthors(ctx, 1)
+	if err != nil || len(top) != 1 {
+		t.Fatalf("GetTopAuthors = %v, %v", top, err)
+	}
+	if top[0].EventCount != int64(fixture.ByAuthor[top[0].Pubkey]) {
+		t.Errorf("top author has %d events, fixture says %d", top[0].EventCount, fixture.ByAuthor[top[0].Pubkey])
+	}
+
+	// Paging visits every event once
+	seen := make(map[string]bool, fixture.Events)
+	filter := EventFilter{Limit: 500}
+	for {
+		events, next, err := database.GetEventsPage(ctx, filter)
+		if err != nil {
+			t.Fatalf("GetEventsPage failed: %v", err)
+		}
+		for _, e := range events {
+			if seen[e.ID] {
+				t.Fatalf("event %s returned twice", e.ID)
+			}
+			seen[e.ID] = true
+		}
+		if next == nil {
+			break
+		}
+		filter.After = next
+	}
+	if len(seen) != fixture.Events {
+		t.Errorf("paged through %d events, want %d", len(seen), fixture.Events)
+	}
+
+	// Replies resolve to their thread
+	var replyID string
+	err = database.RelayDB().QueryRowContext(ctx, `
+		SELECT lower(hex(event_hash)) FROM event
+		WHERE kind = 1 AND id IN (SELECT event_id FROM tag WHERE name = 'e')
+		ORDER BY id DESC LIMIT 1
+	`).Scan(&replyID)
+	if err != nil {
+		t.Fatalf("failed to find a reply: %v", err)
+	}
+	thread, err := database.GetEventThread(ctx, replyID, 100)
+	if err != nil || thread == nil || len(thread.Parents) == 0 {
+		t.Errorf("expected the reply's thread to have parents, got %+v, %v", thread, err)
+	}
+}
+
+func BenchmarkRelayReader(b *testing.B) {
+	ctx := context.Background()
+	database, fixture := openFixture(b)
+	since, until := fixture.Oldest, fixture.Newest.Add(time.Second)
+	author := fixture.Authors[0]
+
+	// The cursor of a page deep into the event list
+	deep := EventFilter{Limit: 50}
+	for i := 0; i < 20; i++ {
+		_, next, err := database.GetEventsPage(ctx, deep)
+		if err != nil || next == nil {
+			break
+		}
+		deep.After = next
+	}
+
+	benchmarks := []struct {
+		name string
+		fn   func() error
+	}{
+		{"GetRelayStats", func() error { _, err := database.GetRelayStats(ctx); return err }},
+		{"GetEventsPage", func() error { _, _, err := database.GetEventsPage(ctx, EventFilter{Limit: 50}); return err }},
+		{"GetEventsPageDeep", func() error { _, _, err := database.GetEventsPage(ctx, deep); return err }},
+		{"GetEventsByTag", func() error {
+			_, _, err := database.GetEventsPage(ctx, EventFilter{Limit: 50, Tags: map[string][]string{"p": {author}}})
+			return err
+		}},
+		{"GetEventsByKindInRange", func() error { _, err := database.GetEventsByKindInRange(ctx, since, until); return err }},
+		{"GetEventsOverTime", func() error { _, err := database.GetEventsOverTime(ctx, since, until, BucketDay, time.UTC); return err }},
+		{"GetTopAuthorsInRange", func() error { _, err := database.GetTopAuthorsInRange(ctx, 10, since, until); return err }},
+		{"GetPubkeyActivity", func() error { _, err := database.GetPubkeyActivity(ctx, author, since, until, time.UTC); return err }},
+		{"CountEventsByPubkey", func() error { _, err := database.CountEventsByPubkey(ctx, fixture.Authors); return err }},
+		{"SearchEvents", func() error { _, err := database.SearchEvents(ctx, "lightning", EventFilter{Limit: 50}); return err }},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := bm.fn(); err != nil {
+					b.Fatalf("%s failed: %v", bm.name, err)
+				}
+			}
+		})
+	}
+}
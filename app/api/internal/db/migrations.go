@@ -2,8 +2,14 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 )
 
 // Migration represents a database migration.
@@ -11,6 +17,44 @@ type Migration struct {
 	Version int
 	Name    string
 	Up      string // SQL to apply migration
+	Down    string // SQL to revert migration; empty if it cannot be reverted
+}
+
+// Checksum returns the SHA-256 of the migration's Up SQL. It is recorded
+// when the migration is applied, so a later edit to an applied migration
+// is caught instead of leaving databases with diverging schemas.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// Reversible reports whether the migration has a down script.
+func (m Migration) Reversible() bool {
+	return m.Down != ""
+}
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration differs
+	// from the migration of the same version in this build.
+	ErrChecksumMismatch = errors.New("applied migrations do not match this build")
+	// ErrIrreversible is returned when a migration to revert has no down
+	// script, or is not known to this build.
+	ErrIrreversible = errors.New("migration cannot be reverted")
+	// ErrUnknownMigration is returned for a version this build does not have.
+	ErrUnknownMigration = errors.New("unknown migration version")
+)
+
+// MigrationState describes a migration and whether it has been applied.
+type MigrationState struct {
+	Version         int        `json:"version"`
+	Name            string     `json:"name"`
+	Applied         bool       `json:"applied"`
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+	Checksum        string     `json:"checksum,omitempty"`         // of the migration in this build
+	AppliedChecksum string     `json:"applied_checksum,omitempty"` // recorded when it was applied
+	Mismatch        bool       `json:"mismatch"`
+	Reversible      bool       `json:"reversible"`
+	Unknown         bool       `json:"unknown"` // applied, but not part of this build
 }
 
 // Migrations is the list of all migrations.
@@ -42,12 +86,734 @@ CREATE INDEX IF NOT EXISTS idx_pending_invoices_pubkey ON pending_invoices(pubke
 CREATE INDEX IF NOT EXISTS idx_pending_invoices_status ON pending_invoices(status);
 CREATE INDEX IF NOT EXISTS idx_pending_invoices_payment_hash ON pending_invoices(payment_hash);
 CREATE INDEX IF NOT EXISTS idx_pending_invoices_expires ON pending_invoices(expires_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS pending_invoices;
+`,
+	},
+	{
+		Version: 3,
+		Name:    "add_bandwidth_daily",
+		Up: `
+-- Relay bytes transferred per UTC day and client IP class (bandwidth proxy)
+CREATE TABLE IF NOT EXISTS bandwidth_daily (
+    date TEXT NOT NULL,                   -- YYYY-MM-DD (UTC)
+    ip_class TEXT NOT NULL,               -- local, lan, public_ipv4, public_ipv6
+    bytes_in INTEGER NOT NULL DEFAULT 0,  -- client -> relay
+    bytes_out INTEGER NOT NULL DEFAULT 0, -- relay -> client
+    connections INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (date, ip_class)
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS bandwidth_daily;
+`,
+	},
+	{
+		Version: 4,
+		Name:    "add_webhooks",
+		Up: `
+-- Outgoing webhook endpoints
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,                 -- HMAC-SHA256 signing secret
+    events TEXT NOT NULL DEFAULT '[]',    -- JSON array of event names, "*" for all
+    description TEXT,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    updated_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+
+-- Delivery log and retry queue
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,                -- JSON body as sent
+    status TEXT NOT NULL DEFAULT 'pending',  -- pending, delivered, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    delivered_at INTEGER,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+`,
+	},
+	{
+		Version: 5,
+		Name:    "add_export_schedules",
+		Up: `
+-- Scheduled incremental exports shipped to a remote destination
+CREATE TABLE IF NOT EXISTS export_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    destination_type TEXT NOT NULL,       -- local, s3, sftp, relay
+    destination TEXT NOT NULL DEFAULT '{}',  -- JSON destination settings
+    kinds TEXT NOT NULL DEFAULT '[]',     -- JSON array of kinds, empty for all
+    interval_hours INTEGER NOT NULL DEFAULT 24,
+    retention_count INTEGER NOT NULL DEFAULT 7,  -- artifacts to keep, 0 keeps all
+    enabled INTEGER NOT NULL DEFAULT 1,
+    cursor INTEGER NOT NULL DEFAULT 0,    -- last exported relay event row id
+    next_run_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    last_run_at INTEGER,
+    last_status TEXT,                     -- completed, failed
+    last_error TEXT,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    updated_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+
+-- One row per exported artifact
+CREATE TABLE IF NOT EXISTS export_manifests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    location TEXT,                        -- where the destination stored it
+    from_cursor INTEGER NOT NULL,
+    to_cursor INTEGER NOT NULL,
+    event_count INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0,
+    sha256 TEXT,
+    status TEXT NOT NULL,                 -- completed, failed, pruned
+    error TEXT,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    FOREIGN KEY (schedule_id) REFERENCES export_schedules(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_manifests_schedule ON export_manifests(schedule_id, created_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS export_manifests;
+DROP TABLE IF EXISTS export_schedules;
+`,
+	},
+	{
+		Version: 6,
+		Name:    "add_mention_notifications",
+		Up: `
+-- Members who opted in to mention notifications
+CREATE TABLE IF NOT EXISTS mention_subscriptions (
+    pubkey TEXT PRIMARY KEY,              -- hex format of the member's pubkey
+    enabled INTEGER NOT NULL DEFAULT 1,
+    channel TEXT NOT NULL DEFAULT 'dm',   -- dm, email
+    email TEXT,                           -- required for the email channel
+    quiet_start TEXT,                     -- HH:MM in the member's timezone
+    quiet_end TEXT,                       -- HH:MM in the member's timezone
+    timezone TEXT NOT NULL DEFAULT 'UTC', -- IANA timezone for quiet hours
+    batch_minutes INTEGER NOT NULL DEFAULT 60,  -- at most one notification per window
+    last_notified_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    updated_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+
+-- Mentions waiting to be summarized, one row per member and event
+CREATE TABLE IF NOT EXISTS mention_queue (
+    recipient TEXT NOT NULL,              -- mentioned member (hex)
+    event_id TEXT NOT NULL,
+    author TEXT NOT NULL,                 -- hex format of the mentioning pubkey
+    kind INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,          -- event created_at
+    queued_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    PRIMARY KEY (recipient, event_id)
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS mention_queue;
+DROP TABLE IF EXISTS mention_subscriptions;
+`,
+	},
+	{
+		Version: 7,
+		Name:    "add_maintenance_windows",
+		Up: `
+-- Scheduled maintenance, advertised on /public/status
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    starts_at INTEGER NOT NULL,
+    ends_at INTEGER NOT NULL,
+    mode TEXT NOT NULL DEFAULT 'maintenance',  -- maintenance, read-only
+    message TEXT,
+    announced_at INTEGER,                 -- when the announcement note was published
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_ends ON maintenance_windows(ends_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS maintenance_windows;
+`,
+	},
+	{
+		Version: 8,
+		Name:    "add_profiles",
+		Up: `
+-- Cached kind-0 metadata for whitelisted, blacklisted and paid pubkeys
+CREATE TABLE IF NOT EXISTS profiles (
+    pubkey TEXT PRIMARY KEY,              -- hex format
+    name TEXT,
+    display_name TEXT,
+    picture TEXT,
+    nip05 TEXT,
+    source TEXT,                          -- local, remote; NULL if no profile was found
+    event_created_at INTEGER,             -- created_at of the kind-0 event
+    fetched_at INTEGER NOT NULL           -- last lookup, successful or not
+);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_fetched ON profiles(fetched_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS profiles;
+`,
+	},
+	{
+		Version: 9,
+		Name:    "add_jobs",
+		Up: `
+-- Long-running operations (vacuum, cleanup, sync, import, ...) started by the operator
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,                   -- vacuum, integrity_check, cleanup, retention, sync, import, export
+    status TEXT NOT NULL DEFAULT 'running',  -- running, completed, failed, cancelled
+    params TEXT,                          -- JSON
+    progress_current INTEGER NOT NULL DEFAULT 0,
+    progress_total INTEGER NOT NULL DEFAULT 0,  -- 0 if unknown
+    message TEXT,                         -- latest progress message
+    result TEXT,                          -- JSON, set when completed
+    error TEXT,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    completed_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_created ON jobs(created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+`,
+		Down: `
+DROP TABLE IF EXISTS jobs;
+`,
+	},
+	{
+		Version: 10,
+		Name:    "add_mirror_coverage",
+		Up: `
+-- How many of a mirrored member's recent events on public relays are stored locally
+CREATE TABLE IF NOT EXISTS mirror_coverage (
+    pubkey TEXT PRIMARY KEY,
+    sampled INTEGER NOT NULL DEFAULT 0,   -- distinct events found on public relays
+    present INTEGER NOT NULL DEFAULT 0,   -- of those, stored on this relay
+    relays TEXT,                          -- JSON: relay URL -> events found there
+    missing_ids TEXT,                     -- JSON: sample of missing event IDs
+    since INTEGER NOT NULL,               -- start of the sampled window
+    checked_at INTEGER NOT NULL
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS mirror_coverage;
+`,
+	},
+	{
+		Version: 11,
+		Name:    "add_broadcast_jobs",
+		Up: `
+-- Pushes of local events to remote relays
+CREATE TABLE IF NOT EXISTS broadcast_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER,                       -- jobs.id of the run
+    status TEXT NOT NULL DEFAULT 'running',  -- running, completed, failed, cancelled
+    authors TEXT,                         -- JSON array of hex pubkeys; all authors if empty
+    kinds TEXT,                           -- JSON array; all kinds if empty
+    since INTEGER,
+    until INTEGER,
+    relays TEXT NOT NULL,                 -- JSON array of relay URLs
+    events_total INTEGER NOT NULL DEFAULT 0,
+    events_sent INTEGER NOT NULL DEFAULT 0,
+    relay_results TEXT,                   -- JSON: relay URL -> ok/duplicate/error counts
+    error_message TEXT,
+    started_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    completed_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcast_jobs_started ON broadcast_jobs(started_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS broadcast_jobs;
+`,
+	},
+	{
+		Version: 12,
+		Name:    "add_sync_cursors",
+		Up: `
+-- How far back each relay/pubkey pair of a sync has fetched, so a paused
+-- sync resumes where it left off
+CREATE TABLE IF NOT EXISTS sync_cursors (
+    sync_job_id INTEGER NOT NULL,
+    relay TEXT NOT NULL,
+    pubkey TEXT NOT NULL,
+    until_ts INTEGER,                     -- oldest created_at fetched so far
+    done INTEGER NOT NULL DEFAULT 0,      -- 1 once the relay has no older events
+    PRIMARY KEY (sync_job_id, relay, pubkey)
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS sync_cursors;
+`,
+	},
+	{
+		Version: 13,
+		Name:    "add_maintenance_history",
+		Up: `
+-- Results of VACUUM and integrity checks, scheduled or run by hand
+CREATE TABLE IF NOT EXISTS maintenance_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task TEXT NOT NULL,                   -- vacuum, integrity_check
+    database TEXT NOT NULL,               -- app, relay
+    trigger TEXT NOT NULL,                -- scheduled, manual
+    status TEXT NOT NULL,                 -- ok, failed
+    result TEXT,                          -- integrity_check output or error
+    space_reclaimed INTEGER NOT NULL DEFAULT 0,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    started_at INTEGER NOT NULL,
+    completed_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_maintenance_history_started ON maintenance_history(started_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS maintenance_history;
+`,
+	},
+	{
+		Version: 14,
+		Name:    "add_backup_targets",
+		Up: `
+-- Remote storage full backups are uploaded to
+CREATE TABLE IF NOT EXISTS backup_targets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,                   -- s3, webdav, sftp
+    destination TEXT NOT NULL DEFAULT '{}',  -- JSON destination settings
+    retain INTEGER NOT NULL DEFAULT 0,    -- uploaded backups to keep, 0 keeps all
+    enabled INTEGER NOT NULL DEFAULT 1,
+    last_upload_at INTEGER,
+    last_status TEXT,                     -- completed, failed
+    last_error TEXT,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    updated_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+
+-- One row per backup uploaded to a target
+CREATE TABLE IF NOT EXISTS backup_uploads (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_id INTEGER NOT NULL,
+    name TEXT NOT NULL,                   -- backup file name
+    location TEXT,                        -- where the target stored it
+    size INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,                 -- completed, failed, pruned
+    error TEXT,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    FOREIGN KEY (target_id) REFERENCES backup_targets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_backup_uploads_target ON backup_uploads(target_id, created_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS backup_uploads;
+DROP TABLE IF EXISTS backup_targets;
+`,
+	},
+	{
+		Version: 15,
+		Name:    "add_moderation",
+		Up: `
+-- Auto-moderation rules scored against new events
+CREATE TABLE IF NOT EXISTS moderation_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,                   -- duplicate_content, link_density, banned_words, burst_rate
+    config TEXT NOT NULL DEFAULT '{}',    -- JSON rule settings
+    score INTEGER NOT NULL DEFAULT 1,     -- added to an event's score when the rule matches
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    updated_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+
+-- What auto-moderation did, one row per event acted on
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id TEXT NOT NULL,
+    pubkey TEXT NOT NULL,
+    kind INTEGER NOT NULL,
+    score INTEGER NOT NULL,
+    rules TEXT NOT NULL DEFAULT '[]',     -- JSON array of matched rule names
+    action TEXT NOT NULL,                 -- flag, delete, blacklist
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_created ON moderation_actions(created_at);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_pubkey ON moderation_actions(pubkey);
+`,
+		Down: `
+DROP TABLE IF EXISTS moderation_actions;
+DROP TABLE IF EXISTS moderation_rules;
+`,
+	},
+	{
+		Version: 16,
+		Name:    "add_ip_bans",
+		Up: `
+-- Addresses and CIDR ranges blocked from the API
+CREATE TABLE IF NOT EXISTS ip_bans (
+    ip TEXT PRIMARY KEY,                  -- address or CIDR prefix
+    reason TEXT,
+    source TEXT NOT NULL DEFAULT 'manual', -- manual or auto (rate limit abuse)
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    expires_at INTEGER                    -- NULL for permanent bans
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS ip_bans;
+`,
+	},
+	{
+		Version: 17,
+		Name:    "add_renewal_reminders",
+		Up: `
+-- Renewal invoices offered to paid users, one per subscription period
+CREATE TABLE IF NOT EXISTS renewal_reminders (
+    pubkey TEXT NOT NULL,
+    expires_at INTEGER NOT NULL,          -- expiry the reminder was sent for
+    payment_hash TEXT NOT NULL,
+    dm_sent INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    PRIMARY KEY (pubkey, expires_at)
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS renewal_reminders;
+`,
+	},
+	{
+		Version: 18,
+		Name:    "add_coupons",
+		Up: `
+-- Coupon and invite codes for discounted or free paid access
+CREATE TABLE IF NOT EXISTS coupons (
+    code TEXT PRIMARY KEY,                -- upper case
+    kind TEXT NOT NULL,                   -- percent, fixed or free
+    value INTEGER NOT NULL DEFAULT 0,     -- percent off, or sats off
+    tier_id TEXT,                         -- NULL for any tier
+    max_uses INTEGER,                     -- NULL for unlimited
+    expires_at INTEGER,                   -- NULL for no expiry
+    enabled INTEGER NOT NULL DEFAULT 1,
+    note TEXT,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+
+-- A code can be redeemed once per pubkey. Pending redemptions hold a use
+-- until their invoice is paid or expires.
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL,
+    pubkey TEXT NOT NULL,
+    payment_hash TEXT,                    -- NULL for free access
+    tier_id TEXT NOT NULL,
+    amount_sats INTEGER NOT NULL,         -- price paid
+    discount_sats INTEGER NOT NULL,
+    status TEXT NOT NULL,                 -- pending or redeemed
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    expires_at INTEGER,                   -- invoice expiry while pending
+    redeemed_at INTEGER,
+    UNIQUE (code, pubkey)
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_payment_hash ON coupon_redemptions(payment_hash);
+`,
+		Down: `
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP TABLE IF EXISTS coupons;
+`,
+	},
+	{
+		Version: 19,
+		Name:    "add_zap_requests",
+		Up: `
+-- Zap requests (NIP-57) for access paid by zapping the relay's Lightning address
+CREATE TABLE IF NOT EXISTS zap_requests (
+    payment_hash TEXT PRIMARY KEY,
+    pubkey TEXT NOT NULL,                 -- zap sender
+    zap_request TEXT NOT NULL,            -- kind 9734 event JSON, as hashed in the invoice
+    payment_request TEXT NOT NULL,
+    receipt_id TEXT,                      -- kind 9735 receipt once published
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    receipt_at INTEGER
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS zap_requests;
+`,
+	},
+	{
+		Version: 20,
+		Name:    "add_cashu_payments",
+		Up: `
+-- How each payment was made: lightning or cashu
+ALTER TABLE payment_history ADD COLUMN payment_type TEXT NOT NULL DEFAULT 'lightning';
+
+-- Cashu tokens melted at a mint to pay an access invoice
+CREATE TABLE IF NOT EXISTS cashu_payments (
+    payment_hash TEXT PRIMARY KEY,        -- access invoice the mint paid
+    pubkey TEXT NOT NULL,
+    mint TEXT NOT NULL,
+    token_sats INTEGER NOT NULL,          -- value of the token's proofs
+    fee_reserve_sats INTEGER NOT NULL,    -- mint's Lightning fee reserve
+    status TEXT NOT NULL DEFAULT 'pending',  -- pending, paid or failed
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS cashu_payments;
+ALTER TABLE payment_history DROP COLUMN payment_type;
+`,
+	},
+	{
+		Version: 21,
+		Name:    "add_config_history",
+		Up: `
+-- Every config.toml Roostr writes, for rollback
+CREATE TABLE IF NOT EXISTS config_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    actor TEXT NOT NULL,                  -- admin, setup, roostr, or the operator's pubkey for NIP-86
+    reason TEXT,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS config_history;
+`,
+	},
+	{
+		Version: 22,
+		Name:    "add_relay_uptime",
+		Up: `
+-- Periodic checks of the relay's own WebSocket, for availability figures
+CREATE TABLE IF NOT EXISTS relay_uptime (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    checked_at INTEGER NOT NULL,
+    up INTEGER NOT NULL,                  -- 1 if a REQ was answered with EOSE
+    latency_ms INTEGER,                   -- REQ round-trip, when up
+    connections INTEGER,                  -- active client connections, when known
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_relay_uptime_checked ON relay_uptime(checked_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS relay_uptime;
+`,
+	},
+	{
+		Version: 23,
+		Name:    "add_event_tombstones",
+		Up: `
+-- Events the deletion worker removed, kept out of the relay on sync and import
+CREATE TABLE IF NOT EXISTS event_tombstones (
+    event_id TEXT PRIMARY KEY,
+    author TEXT NOT NULL,
+    deleted_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    reason TEXT,
+    deletion_event_id TEXT                -- kind 5 event published for it, if any
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_tombstones_deleted ON event_tombstones(deleted_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS event_tombstones;
 `,
 	},
+	{
+		Version: 24,
+		Name:    "add_media_blobs",
+		Up: `
+-- Blobs on the operator's Blossom server that relay events link to
+CREATE TABLE IF NOT EXISTS media_blobs (
+    sha256 TEXT PRIMARY KEY,
+    owner TEXT NOT NULL,                  -- author of the first event linking to it
+    url TEXT NOT NULL,
+    size INTEGER,                         -- bytes, NULL until checked on the server
+    mime_type TEXT,
+    first_seen INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    checked_at INTEGER,
+    missing INTEGER NOT NULL DEFAULT 0    -- 1 if the server no longer has it
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_blobs_owner ON media_blobs(owner);
+
+-- Events linking to each blob, to find blobs no stored event uses
+CREATE TABLE IF NOT EXISTS media_refs (
+    sha256 TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    PRIMARY KEY (sha256, event_id)
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS media_refs;
+DROP TABLE IF EXISTS media_blobs;
+`,
+	},
+	{
+		Version: 25,
+		Name:    "add_follow_grants",
+		Up: `
+-- Pubkeys allowed to post because enough members follow them
+CREATE TABLE IF NOT EXISTS follow_grants (
+    pubkey TEXT PRIMARY KEY,
+    followers INTEGER NOT NULL,           -- members whose contact list includes it
+    via TEXT NOT NULL,                    -- JSON array of those members' pubkeys
+    granted_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS follow_grants;
+`,
+	},
+	{
+		Version: 26,
+		Name:    "add_quota_breaches",
+		Up: `
+-- Quotas members went over, one row per quota and period
+CREATE TABLE IF NOT EXISTS quota_breaches (
+    pubkey TEXT NOT NULL,
+    quota TEXT NOT NULL,                  -- 'events_per_day' or 'storage'
+    period TEXT NOT NULL,                 -- UTC day (YYYY-MM-DD) for daily quotas, '' otherwise
+    usage INTEGER NOT NULL,
+    quota_limit INTEGER NOT NULL,
+    action TEXT NOT NULL,                 -- 'notify' or 'blacklist'
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    PRIMARY KEY (pubkey, quota, period)
+);
+CREATE INDEX IF NOT EXISTS idx_quota_breaches_created ON quota_breaches(created_at);
+`,
+		Down: `
+DROP INDEX IF EXISTS idx_quota_breaches_created;
+DROP TABLE IF EXISTS quota_breaches;
+`,
+	},
+	{
+		Version: 27,
+		Name:    "add_sync_verification",
+		Up: `
+-- Events a sync fetched that failed ID or signature verification, and
+-- whether the sync trusted its relays and stored events unverified
+CREATE TABLE IF NOT EXISTS sync_job_verification (
+    sync_job_id INTEGER PRIMARY KEY,
+    skip_verification INTEGER NOT NULL DEFAULT 0,
+    events_rejected INTEGER NOT NULL DEFAULT 0
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS sync_job_verification;
+`,
+	},
+	{
+		Version: 28,
+		Name:    "add_pending_invoice_sources",
+		Up: `
+-- Where a pending invoice came from, for invoices not created by a signup
+CREATE TABLE IF NOT EXISTS pending_invoice_sources (
+    payment_hash TEXT PRIMARY KEY,
+    source TEXT NOT NULL
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS pending_invoice_sources;
+`,
+	},
+	{
+		Version: 29,
+		Name:    "key_coupon_reservations_by_invoice",
+		Up: `
+-- Pending redemptions are keyed by their invoice, so a pubkey can hold
+-- several open invoices for a code; only one redemption can complete
+CREATE TABLE coupon_redemptions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL,
+    pubkey TEXT NOT NULL,
+    payment_hash TEXT UNIQUE,             -- NULL for free access
+    tier_id TEXT NOT NULL,
+    amount_sats INTEGER NOT NULL,         -- price paid
+    discount_sats INTEGER NOT NULL,
+    status TEXT NOT NULL,                 -- pending or redeemed
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    expires_at INTEGER,                   -- invoice expiry while pending
+    redeemed_at INTEGER
+);
+
+INSERT INTO coupon_redemptions_new (id, code, pubkey, payment_hash, tier_id, amount_sats, discount_sats, status, created_at, expires_at, redeemed_at)
+SELECT id, code, pubkey, payment_hash, tier_id, amount_sats, discount_sats, status, created_at, expires_at, redeemed_at
+FROM coupon_redemptions;
+
+DROP TABLE coupon_redemptions;
+ALTER TABLE coupon_redemptions_new RENAME TO coupon_redemptions;
+
+CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_code_pubkey ON coupon_redemptions(code, pubkey);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coupon_redemptions_redeemed ON coupon_redemptions(code, pubkey) WHERE status = 'redeemed';
+`,
+		Down: `
+CREATE TABLE coupon_redemptions_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL,
+    pubkey TEXT NOT NULL,
+    payment_hash TEXT,
+    tier_id TEXT NOT NULL,
+    amount_sats INTEGER NOT NULL,
+    discount_sats INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    expires_at INTEGER,
+    redeemed_at INTEGER,
+    UNIQUE (code, pubkey)
+);
+
+INSERT OR IGNORE INTO coupon_redemptions_old (id, code, pubkey, payment_hash, tier_id, amount_sats, discount_sats, status, created_at, expires_at, redeemed_at)
+SELECT id, code, pubkey, payment_hash, tier_id, amount_sats, discount_sats, status, created_at, expires_at, redeemed_at
+FROM coupon_redemptions
+ORDER BY status = 'redeemed' DESC, id DESC;
+
+DROP TABLE coupon_redemptions;
+ALTER TABLE coupon_redemptions_old RENAME TO coupon_redemptions;
+
+CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_payment_hash ON coupon_redemptions(payment_hash);
+`,
+	},
+}
+
+func findMigration(version int) (Migration, bool) {
+	for _, m := range Migrations {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
 }
 
-// Migrate runs all pending migrations.
+// Migrate runs all pending migrations. It refuses to run when an applied
+// migration no longer matches this build.
 func (d *DB) Migrate(ctx context.Context) error {
+	if err := d.prepareSchemaVersion(ctx); err != nil {
+		return err
+	}
+	if err := d.checkMigrationChecksums(ctx); err != nil {
+		return err
+	}
+
 	currentVersion, err := d.GetSchemaVersion()
 	if err != nil {
 		return fmt.Errorf("failed to get schema version: %w", err)
@@ -58,13 +824,13 @@ func (d *DB) Migrate(ctx context.Context) error {
 			continue
 		}
 
-		fmt.Printf("Applying migration %d: %s\n", m.Version, m.Name)
+		slog.Info("Applying migration", "version", m.Version, "name", m.Name)
 
 		if err := d.applyMigration(ctx, m); err != nil {
 			return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
 		}
 
-		fmt.Printf("Migration %d applied successfully\n", m.Version)
+		slog.Info("Migration applied", "version", m.Version)
 	}
 
 	return nil
@@ -78,7 +844,7 @@ func (d *DB) applyMigration(ctx context.Context, m Migration) error {
 		}
 
 		// Record the migration
-		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_version (version) VALUES (?)", m.Version); err != nil {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_version (version, checksum) VALUES (?, ?)", m.Version, m.Checksum()); err != nil {
 			return err
 		}
 
@@ -86,6 +852,204 @@ func (d *DB) applyMigration(ctx context.Context, m Migration) error {
 	})
 }
 
+// MigrateDown reverts the last n applied migrations, newest first. Every
+// migration is checked to be reversible before any is reverted. The
+// initial schema is never reverted. It returns the reverted migrations.
+func (d *DB) MigrateDown(ctx context.Context, n int) ([]Migration, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("number of migrations to revert must be positive")
+	}
+	if err := d.prepareSchemaVersion(ctx); err != nil {
+		return nil, err
+	}
+	if err := d.checkMigrationChecksums(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := d.GetAppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []Migration
+	for i := len(applied) - 1; i >= 0 && len(targets) < n; i-- {
+		if applied[i] <= 1 {
+			break
+		}
+		m, ok := findMigration(applied[i])
+		if !ok {
+			return nil, fmt.Errorf("%w: version %d is not known to this build", ErrIrreversible, applied[i])
+		}
+		if !m.Reversible() {
+			return nil, fmt.Errorf("%w: %d (%s) has no down script", ErrIrreversible, m.Version, m.Name)
+		}
+		targets = append(targets, m)
+	}
+	if len(targets) < n {
+		return nil, fmt.Errorf("only %d migration(s) can be reverted", len(targets))
+	}
+
+	for i, m := range targets {
+		slog.Info("Reverting migration", "version", m.Version, "name", m.Name)
+
+		err := d.Transaction(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_version WHERE version = ?", m.Version)
+			return err
+		})
+		if err != nil {
+			return targets[:i], fmt.Errorf("failed to revert migration %d (%s): %w", m.Version, m.Name, err)
+		}
+
+		slog.Info("Migration reverted", "version", m.Version)
+	}
+
+	return targets, nil
+}
+
+// ForceVersion records the schema as being at version without running any
+// migration SQL: migrations up to version are marked applied with this
+// build's checksums, and later ones are marked not applied. It is meant
+// for recovery after a failed migration or an intentional schema edit, once
+// the schema has been fixed by hand.
+func (d *DB) ForceVersion(ctx context.Context, version int) error {
+	if _, ok := findMigration(version); !ok && version != 1 {
+		return fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+	}
+	if err := d.prepareSchemaVersion(ctx); err != nil {
+		return err
+	}
+
+	return d.Transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM schema_version WHERE version > ?", version); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO schema_version (version) VALUES (1)"); err != nil {
+			return err
+		}
+		for _, m := range Migrations {
+			if m.Version > version {
+				break
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO schema_version (version, checksum) VALUES (?, ?)
+				ON CONFLICT(version) DO UPDATE SET checksum = excluded.checksum
+			`, m.Version, m.Checksum())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationStatus returns every migration in this build along with any
+// applied version this build does not know, ordered by version.
+func (d *DB) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	if err := d.prepareSchemaVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.AppDB.QueryContext(ctx, "SELECT version, applied_at, COALESCE(checksum, '') FROM schema_version ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationState)
+	var versions []int
+	for rows.Next() {
+		var st MigrationState
+		var appliedAt int64
+		if err := rows.Scan(&st.Version, &appliedAt, &st.AppliedChecksum); err != nil {
+			return nil, err
+		}
+		t := time.Unix(appliedAt, 0)
+		st.Applied = true
+		st.AppliedAt = &t
+		applied[st.Version] = st
+		versions = append(versions, st.Version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	if st, ok := applied[1]; ok {
+		st.Name = "initial_schema"
+		states = append(states, st)
+	}
+	for _, m := range Migrations {
+		st := applied[m.Version]
+		st.Version = m.Version
+		st.Name = m.Name
+		st.Checksum = m.Checksum()
+		st.Reversible = m.Reversible()
+		st.Mismatch = st.Applied && st.AppliedChecksum != st.Checksum
+		states = append(states, st)
+	}
+	for _, v := range versions {
+		if _, ok := findMigration(v); !ok && v != 1 {
+			st := applied[v]
+			st.Unknown = true
+			states = append(states, st)
+		}
+	}
+
+	return states, nil
+}
+
+// prepareSchemaVersion adds the checksum column to databases created before
+// checksums were recorded, and records this build's checksums for applied
+// migrations that have none.
+func (d *DB) prepareSchemaVersion(ctx context.Context) error {
+	var hasChecksum bool
+	err := d.AppDB.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM pragma_table_info('schema_version') WHERE name = 'checksum'",
+	).Scan(&hasChecksum)
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema_version: %w", err)
+	}
+	if !hasChecksum {
+		if _, err := d.AppDB.ExecContext(ctx, "ALTER TABLE schema_version ADD COLUMN checksum TEXT"); err != nil {
+			return fmt.Errorf("failed to add migration checksums: %w", err)
+		}
+	}
+
+	for _, m := range Migrations {
+		_, err := d.AppDB.ExecContext(ctx,
+			"UPDATE schema_version SET checksum = ? WHERE version = ? AND checksum IS NULL",
+			m.Checksum(), m.Version,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record migration checksums: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkMigrationChecksums returns ErrChecksumMismatch listing every applied
+// migration whose checksum differs from this build.
+func (d *DB) checkMigrationChecksums(ctx context.Context) error {
+	states, err := d.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	var mismatched []string
+	for _, st := range states {
+		if st.Mismatch {
+			mismatched = append(mismatched, fmt.Sprintf("%d (%s)", st.Version, st.Name))
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
 // Transaction wrapper that accepts *DB instead of *sql.Tx for simpler usage
 func (d *DB) transactionForMigration(ctx context.Context, fn func() error) error {
 	tx, err := d.AppDB.BeginTx(ctx, nil)
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMigrations_Reversible(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	latest := Migrations[len(Migrations)-1].Version
+
+	reverted, err := database.MigrateDown(ctx, len(Migrations))
+	if err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	if len(reverted) != len(Migrations) || reverted[0].Version != latest {
+		t.Fatalf("expected every migration reverted newest first, got %d", len(reverted))
+	}
+	if version, _ := database.GetSchemaVersion(); version != 1 {
+		t.Errorf("expected schema version 1, got %d", version)
+	}
+	if _, err := database.MigrateDown(ctx, 1); err == nil {
+		t.Error("expected the initial schema not to be reverted")
+	}
+
+	// Every down script leaves a schema the migrations apply to again
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate after reverting failed: %v", err)
+	}
+	if version, _ := database.GetSchemaVersion(); version != latest {
+		t.Errorf("expected schema version %d, got %d", latest, version)
+	}
+}
+
+func TestMigrations_Checksums(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	states, err := database.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if len(states) != len(Migrations)+1 || states[0].Version != 1 {
+		t.Fatalf("expected the initial schema and every migration, got %d", len(states))
+	}
+	for _, st := range states[1:] {
+		if !st.Applied || st.Mismatch || st.AppliedChecksum != st.Checksum {
+			t.Errorf("unexpected state: %+v", st)
+		}
+	}
+
+	// An edited migration is refused until the version is forced
+	database.AppDB.Exec("UPDATE schema_version SET checksum = 'edited' WHERE version = 3")
+	database.AppDB.Exec("DELETE FROM schema_version WHERE version > 20")
+	if err := database.Migrate(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := database.MigrateDown(ctx, 1); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected MigrateDown to refuse, got %v", err)
+	}
+
+	if err := database.ForceVersion(ctx, 20); err != nil {
+		t.Fatalf("ForceVersion failed: %v", err)
+	}
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate after forcing failed: %v", err)
+	}
+	if pending, _ := database.GetPendingMigrations(); len(pending) != 0 {
+		t.Errorf("expected no pending migrations, got %d", len(pending))
+	}
+
+	if err := database.ForceVersion(ctx, 999); !errors.Is(err, ErrUnknownMigration) {
+		t.Errorf("expected ErrUnknownMigration, got %v", err)
+	}
+}
+
+func TestMigrations_Backfill(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	// Databases from before checksums were recorded trust their migrations
+	database.AppDB.Exec("ALTER TABLE schema_version DROP COLUMN checksum")
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	states, _ := database.MigrationStatus(ctx)
+	for _, st := range states[1:] {
+		if st.AppliedChecksum != st.Checksum {
+			t.Errorf("expected a recorded checksum for %d", st.Version)
+		}
+	}
+
+	// Versions this build does not know are reported
+	database.AppDB.Exec("INSERT INTO schema_version (version, checksum) VALUES (999, 'x')")
+	states, _ = database.MigrationStatus(ctx)
+	if last := states[len(states)-1]; last.Version != 999 || !last.Unknown {
+		t.Errorf("expected an unknown version, got %+v", last)
+	}
+	if _, err := database.MigrateDown(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("expected ErrIrreversible, got %v", err)
+	}
+}
@@ -0,0 +1,456 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Relay types selectable with RELAY_TYPE.
+const (
+	RelayTypeNostrRsRelay = "nostr-rs-relay"
+	RelayTypeStrfry       = "strfry"
+)
+
+// relayIndexSchema is the subset of nostr-rs-relay's schema the reader
+// queries use. Relays that do not store events in it are mirrored into a
+// local index with this schema.
+const relayIndexSchema = `
+CREATE TABLE IF NOT EXISTS event (
+	id INTEGER PRIMARY KEY,
+	event_hash BLOB NOT NULL,
+	first_seen INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER,
+	author BLOB NOT NULL,
+	delegated_by BLOB,
+	kind INTEGER NOT NULL,
+	hidden INTEGER DEFAULT 0,
+	content TEXT NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS event_hash_index ON event(event_hash);
+CREATE INDEX IF NOT EXISTS author_index ON event(author);
+CREATE INDEX IF NOT EXISTS kind_index ON event(kind);
+CREATE INDEX IF NOT EXISTS created_at_index ON event(created_at);
+CREATE INDEX IF NOT EXISTS event_expiration ON event(expires_at);
+CREATE TABLE IF NOT EXISTS tag (
+	id INTEGER PRIMARY KEY,
+	event_id INTEGER NOT NULL,
+	name TEXT,
+	value TEXT,
+	value_hex BLOB
+);
+CREATE INDEX IF NOT EXISTS tag_val_index ON tag(value);
+CREATE INDEX IF NOT EXISTS tag_composite_index ON tag(event_id, name, value);
+`
+
+// relayIndexOverlap is how far before the newest indexed event an
+// incremental sync starts, to pick up events that reached the relay late.
+const relayIndexOverlap = 24 * time.Hour
+
+// RelayBackend is a relay that stores events somewhere other than the
+// nostr-rs-relay SQLite database. Roostr reads from a local index of its
+// events and passes deletions and imports through to it.
+type RelayBackend interface {
+	// Type returns the relay type, e.g. RelayTypeStrfry.
+	Type() string
+	// Export calls fn for every stored event created at or after since.
+	Export(ctx context.Context, since int64, fn func(*Event) error) error
+	// Delete removes events by ID.
+	Delete(ctx context.Context, ids []string) error
+	// Import stores events.
+	Import(ctx context.Context, events []*Event) error
+}
+
+// StrfryBackend reaches a strfry relay through its command line: "export"
+// for reads, "delete" and "import" for writes. strfry's LMDB database is
+// locked by the running relay, but these commands are safe alongside it.
+type StrfryBackend struct {
+	binary     string
+	configPath string
+}
+
+// NewStrfryBackend returns a backend that runs binary, passing configPath as
+// --config when set.
+func NewStrfryBackend(binary, configPath string) *StrfryBackend {
+	return &StrfryBackend{binary: binary, configPath: configPath}
+}
+
+// Type returns RelayTypeStrfry.
+func (b *StrfryBackend) Type() string {
+	return RelayTypeStrfry
+}
+
+// command builds a strfry command with the config flag.
+func (b *StrfryBackend) command(ctx context.Context, args ...string) *exec.Cmd {
+	if b.configPath != "" {
+		args = append([]string{"--config=" + b.configPath}, args...)
+	}
+	return exec.CommandContext(ctx, b.binary, args...)
+}
+
+// Export streams "strfry export" output, one event per line.
+func (b *StrfryBackend) Export(ctx context.Context, since int64, fn func(*Event) error) error {
+	cmd := b.command(ctx, "export", "--since="+strconv.FormatInt(since, 10))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run strfry export: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run strfry export: %w", err)
+	}
+
+	scanErr := scanEventLines(stdout, fn)
+	if scanErr != nil {
+		// Stop strfry rather than wait for it to fill the pipe
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	if scanErr != nil {
+		return scanErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("strfry export failed: %w: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Delete runs "strfry delete" with an ids filter.
+func (b *StrfryBackend) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	filter, err := json.Marshal(map[string][]string{"ids": ids})
+	if err != nil {
+		return err
+	}
+	out, err := b.command(ctx, "delete", "--filter="+string(filter)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("strfry delete failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Import pipes events to "strfry import" as JSON lines.
+func (b *StrfryBackend) Import(ctx context.Context, events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var input bytes.Buffer
+	for _, event := range events {
+		line, err := json.Marshal(eventJSON(event))
+		if err != nil {
+			return fmt.Errorf("failed to serialize event: %w", err)
+		}
+		input.Write(line)
+		input.WriteByte('\n')
+	}
+
+	cmd := b.command(ctx, "import")
+	cmd.Stdin = &input
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("strfry import failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// scanEventLines decodes one JSON event per line, skipping lines that are
+// not events.
+func scanEventLines(r io.Reader, fn func(*Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var raw struct {
+			ID        string     `json:"id"`
+			Pubkey    string     `json:"pubkey"`
+			CreatedAt int64      `json:"created_at"`
+			Kind      int        `json:"kind"`
+			Tags      [][]string `json:"tags"`
+			Content   string     `json:"content"`
+			Sig       string     `json:"sig"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil || len(raw.ID) != 64 || len(raw.Pubkey) != 64 {
+			continue
+		}
+		event := &Event{
+			ID:        raw.ID,
+			Pubkey:    raw.Pubkey,
+			CreatedAt: time.Unix(raw.CreatedAt, 0),
+			Kind:      raw.Kind,
+			Tags:      raw.Tags,
+			Content:   raw.Content,
+			Sig:       raw.Sig,
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read events: %w", err)
+	}
+	return nil
+}
+
+// eventJSON returns an event in its wire format, which is also how
+// nostr-rs-relay stores it in the content column.
+func eventJSON(event *Event) map[string]interface{} {
+	tags := event.Tags
+	if tags == nil {
+		tags = [][]string{}
+	}
+	return map[string]interface{}{
+		"id":         event.ID,
+		"pubkey":     event.Pubkey,
+		"created_at": event.CreatedAt.Unix(),
+		"kind":       event.Kind,
+		"tags":       tags,
+		"content":    event.Content,
+		"sig":        event.Sig,
+	}
+}
+
+// SetRelayBackend makes Roostr treat the relay database as a local index of
+// backend's events rather than the relay's own store. Call it before any
+// relay writer is opened.
+func (d *DB) SetRelayBackend(backend RelayBackend) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.backend = backend
+}
+
+// RelayBackend returns the backend set with SetRelayBackend, or nil when the
+// relay database is nostr-rs-relay's own.
+func (d *DB) RelayBackend() RelayBackend {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.backend
+}
+
+// RelayType returns the type of relay Roostr manages.
+func (d *DB) RelayType() string {
+	if backend := d.RelayBackend(); backend != nil {
+		return backend.Type()
+	}
+	return RelayTypeNostrRsRelay
+}
+
+// RelayIndexSyncResult reports one sync of the relay index.
+type RelayIndexSyncResult struct {
+	Since   int64 `json:"since"`
+	Scanned int64 `json:"scanned"`
+	Added   int64 `json:"added"`
+	Removed int64 `json:"removed"`
+}
+
+// SyncRelayIndex copies new events from the relay backend into the local
+// index, creating it on first use. An incremental sync exports from a day
+// before the newest indexed event; a full sync exports everything and also
+// drops indexed events the relay no longer has.
+func (d *DB) SyncRelayIndex(ctx context.Context, full bool) (*RelayIndexSyncResult, error) {
+	backend := d.RelayBackend()
+	if backend == nil {
+		return nil, fmt.Errorf("relay type %s has no index to sync", RelayTypeNostrRsRelay)
+	}
+
+	created, err := d.ensureRelayIndex()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.relayWriteDB()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RelayIndexSyncResult{}
+	if !full {
+		var newest int64
+		conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(created_at), 0) FROM event").Scan(&newest)
+		if newest > 0 {
+			result.Since = newest - int64(relayIndexOverlap.Seconds())
+		}
+	}
+
+	w := &RelayWriter{db: conn, dialect: sqliteDialect{}}
+	seen := make(map[string]bool)
+	err = backend.Export(ctx, result.Since, func(event *Event) error {
+		result.Scanned++
+		if full {
+			seen[event.ID] = true
+		}
+		inserted, err := w.indexEvent(ctx, event)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			result.Added++
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if full {
+		removed, err := w.dropUnseenEvents(ctx, seen)
+		if err != nil {
+			return result, err
+		}
+		result.Removed = removed
+	}
+
+	if created {
+		if err := d.ReconnectRelayDB(); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// ensureRelayIndex creates the relay index if it does not exist yet and
+// applies its schema. Returns true if the file was created.
+func (d *DB) ensureRelayIndex() (bool, error) {
+	path := d.GetRelayPath()
+	if path == "" {
+		return false, fmt.Errorf("relay database path not configured")
+	}
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+	if created {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return false, fmt.Errorf("failed to create relay index directory: %w", err)
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return false, fmt.Errorf("failed to create relay index: %w", err)
+		}
+		f.Close()
+	}
+
+	conn, err := d.relayWriteDB()
+	if err != nil {
+		return false, err
+	}
+	if _, err := conn.Exec(relayIndexSchema); err != nil {
+		return false, fmt.Errorf("failed to create relay index schema: %w", err)
+	}
+	return created, nil
+}
+
+// indexEvent adds an event and its single-letter tags to the index, like
+// nostr-rs-relay does. Returns false if it was already indexed.
+func (w *RelayWriter) indexEvent(ctx context.Context, event *Event) (bool, error) {
+	idBytes, err := hex.DecodeString(event.ID)
+	if err != nil {
+		return false, fmt.Errorf("invalid event ID: %w", err)
+	}
+	pubkeyBytes, err := hex.DecodeString(event.Pubkey)
+	if err != nil {
+		return false, fmt.Errorf("invalid pubkey: %w", err)
+	}
+	content, err := json.Marshal(eventJSON(event))
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize event: %w", err)
+	}
+
+	var inserted bool
+	err = retryBusy(ctx, func() error {
+		var err error
+		inserted, err = w.indexEventTx(ctx, event, idBytes, pubkeyBytes, content)
+		return err
+	})
+	return inserted, err
+}
+
+func (w *RelayWriter) indexEventTx(ctx context.Context, event *Event, idBytes, pubkeyBytes, content []byte) (bool, error) {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO event (event_hash, first_seen, created_at, expires_at, author, kind, content)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, idBytes, time.Now().Unix(), event.CreatedAt.Unix(), expiresAt(event.Tags), pubkeyBytes, event.Kind, string(content))
+	if err != nil {
+		return false, fmt.Errorf("failed to index event: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	rowID, err := result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("failed to index event: %w", err)
+	}
+
+	for _, tag := range event.Tags {
+		if len(tag) < 2 || len(tag[0]) != 1 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO tag (event_id, name, value) VALUES (?, ?, ?)`, rowID, tag[0], tag[1]); err != nil {
+			return false, fmt.Errorf("failed to index tags: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit event: %w", err)
+	}
+	return true, nil
+}
+
+// dropUnseenEvents removes indexed events whose IDs are not in seen, and
+// their tags, without passing the deletion on to the relay.
+func (w *RelayWriter) dropUnseenEvents(ctx context.Context, seen map[string]bool) (int64, error) {
+	rows, err := w.db.QueryContext(ctx, "SELECT event_hash FROM event")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list indexed events: %w", err)
+	}
+	var stale []interface{}
+	for rows.Next() {
+		var hash []byte
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to list indexed events: %w", err)
+		}
+		if !seen[hex.EncodeToString(hash)] {
+			stale = append(stale, hash)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list indexed events: %w", err)
+	}
+
+	var removed int64
+	for len(stale) > 0 {
+		batch := stale
+		if len(batch) > 500 {
+			batch = batch[:500]
+		}
+		stale = stale[len(batch):]
+
+		in := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		n, err := w.deleteRows(ctx, "event_hash IN ("+in+")", batch, "", false)
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
@@ -0,0 +1,300 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStrfry writes a shell script standing in for the strfry binary:
+// "export" prints events.jsonl, "delete" logs its filter to deleted.log and
+// "import" appends its input to imported.jsonl. Every call's arguments are
+// logged to args.log.
+func fakeStrfry(t *testing.T, events ...Event) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "strfry")
+	err := os.WriteFile(script, []byte(`#!/bin/sh
+dir=$(dirname "$0")
+echo "$@" >> "$dir/args.log"
+case "$1" in
+  export) cat "$dir/events.jsonl" ;;
+  delete) echo "$2" >> "$dir/deleted.log" ;;
+  import) cat >> "$dir/imported.jsonl" ;;
+  *) exit 1 ;;
+esac
+`), 0755)
+	if err != nil {
+		t.Fatalf("failed to write fake strfry: %v", err)
+	}
+	writeStrfryEvents(t, dir, events...)
+	return script, dir
+}
+
+// writeStrfryEvents replaces the events the fake strfry exports.
+func writeStrfryEvents(t *testing.T, dir string, events ...Event) {
+	t.Helper()
+
+	var lines []string
+	for i := range events {
+		line, _ := json.Marshal(eventJSON(&events[i]))
+		lines = append(lines, string(line))
+	}
+	// strfry prints progress to stderr, but tolerate noise on stdout too
+	lines = append(lines, "not an event")
+	if err := os.WriteFile(filepath.Join(dir, "events.jsonl"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write events: %v", err)
+	}
+}
+
+// setupStrfryDB creates a DB whose relay index does not exist yet.
+func setupStrfryDB(t *testing.T, backend RelayBackend) *DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	database, err := New(filepath.Join(dir, "index", "nostr.db"), filepath.Join(dir, "roostr.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	database.SetRelayBackend(backend)
+	return database
+}
+
+func testStrfryEvent(id byte, kind int, createdAt time.Time, tags ...[]string) Event {
+	return Event{
+		ID:        strings.Repeat(string("0123456789abcdef"[id%16]), 64),
+		Pubkey:    strings.Repeat("a", 64),
+		CreatedAt: createdAt,
+		Kind:      kind,
+		Tags:      tags,
+		Content:   "hello",
+		Sig:       strings.Repeat("0", 128),
+	}
+}
+
+func TestStrfryRelayIndex(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	e1 := testStrfryEvent(1, 1, now.Add(-time.Hour), []string{"p", strings.Repeat("b", 64)})
+	e2 := testStrfryEvent(2, 7, now.Add(-time.Minute), []string{"e", e1.ID})
+
+	script, dir := fakeStrfry(t, e1, e2)
+	database := setupStrfryDB(t, NewStrfryBackend(script, ""))
+
+	if database.RelayType() != RelayTypeStrfry {
+		t.Errorf("RelayType() = %q, want strfry", database.RelayType())
+	}
+	if database.IsRelayDBConnected() {
+		t.Fatal("relay index should not exist before the first sync")
+	}
+
+	result, err := database.SyncRelayIndex(ctx, false)
+	if err != nil {
+		t.Fatalf("SyncRelayIndex() error = %v", err)
+	}
+	if result.Scanned != 2 || result.Added != 2 || result.Since != 0 {
+		t.Errorf("first sync = %+v, want 2 scanned and added from 0", result)
+	}
+	if !database.IsRelayDBConnected() {
+		t.Fatal("relay index should be connected after the first sync")
+	}
+
+	// The reader queries work on the index
+	events, err := database.GetEvents(ctx, EventFilter{Mentions: strings.Repeat("b", 64)})
+	if err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+	if len(events) != 1 || events[0].ID != e1.ID || len(events[0].Tags) != 1 {
+		t.Errorf("GetEvents() = %+v, want e1 with its tag", events)
+	}
+	stats, err := database.GetRelayStats(ctx)
+	if err != nil {
+		t.Fatalf("GetRelayStats() error = %v", err)
+	}
+	if stats.TotalEvents != 2 {
+		t.Errorf("TotalEvents = %d, want 2", stats.TotalEvents)
+	}
+	var tags int
+	database.RelayDB().QueryRow("SELECT COUNT(*) FROM tag").Scan(&tags)
+	if tags != 2 {
+		t.Errorf("indexed %d tags, want 2", tags)
+	}
+
+	// An incremental sync starts a day before the newest event
+	result, err = database.SyncRelayIndex(ctx, false)
+	if err != nil {
+		t.Fatalf("SyncRelayIndex() error = %v", err)
+	}
+	if result.Added != 0 || result.Since != e2.CreatedAt.Add(-relayIndexOverlap).Unix() {
+		t.Errorf("second sync = %+v, want nothing added since %d", result, e2.CreatedAt.Add(-relayIndexOverlap).Unix())
+	}
+
+	// Deletions reach strfry
+	w, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter() error = %v", err)
+	}
+	deleted, err := w.DeleteEventsByIDs(ctx, []string{e1.ID})
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteEventsByIDs() = %d, %v; want 1", deleted, err)
+	}
+	log, _ := os.ReadFile(filepath.Join(dir, "deleted.log"))
+	if !strings.Contains(string(log), `{"ids":["`+e1.ID+`"]}`) {
+		t.Errorf("strfry delete filter = %q, want e1's ID", log)
+	}
+	database.RelayDB().QueryRow("SELECT COUNT(*) FROM tag").Scan(&tags)
+	if tags != 1 {
+		t.Errorf("%d tags left, want 1", tags)
+	}
+
+	// Inserted events are imported when the writer closes
+	e3 := testStrfryEvent(3, 1, now)
+	if inserted, err := w.InsertEvent(ctx, &e3); err != nil || !inserted {
+		t.Fatalf("InsertEvent() = %v, %v; want true", inserted, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "imported.jsonl")); !os.IsNotExist(err) {
+		t.Error("events should be queued until the writer closes")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	imported, _ := os.ReadFile(filepath.Join(dir, "imported.jsonl"))
+	if !strings.Contains(string(imported), e3.ID) {
+		t.Errorf("imported = %q, want e3", imported)
+	}
+
+	// A full sync drops events strfry no longer has
+	writeStrfryEvents(t, dir, e2)
+	result, err = database.SyncRelayIndex(ctx, true)
+	if err != nil {
+		t.Fatalf("full SyncRelayIndex() error = %v", err)
+	}
+	if result.Scanned != 1 || result.Removed != 1 {
+		t.Errorf("full sync = %+v, want 1 scanned and 1 removed", result)
+	}
+	if stats, _ := database.GetRelayStats(ctx); stats == nil || stats.TotalEvents != 1 {
+		t.Errorf("stats = %+v, want 1 event indexed", stats)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args.log"))
+	if !strings.Contains(string(args), "export --since=0") {
+		t.Errorf("strfry args = %q, want a full export", args)
+	}
+}
+
+// failingBackend exports nothing and fails every write.
+type failingBackend struct{}
+
+func (failingBackend) Type() string { return RelayTypeStrfry }
+func (failingBackend) Export(ctx context.Context, since int64, fn func(*Event) error) error {
+	return nil
+}
+func (failingBackend) Delete(ctx context.Context, ids []string) error {
+	return errors.New("relay unavailable")
+}
+func (failingBackend) Import(ctx context.Context, events []*Event) error {
+	return errors.New("relay unavailable")
+}
+
+func TestRelayBackendDeleteFailureKeepsIndex(t *testing.T) {
+	ctx := context.Background()
+	database := setupStrfryDB(t, failingBackend{})
+	if _, err := database.SyncRelayIndex(ctx, false); err != nil {
+		t.Fatalf("SyncRelayIndex() error = %v", err)
+	}
+
+	w, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter() error = %v", err)
+	}
+	e := testStrfryEvent(4, 1, time.Now())
+	if _, err := w.InsertEvent(ctx, &e); err != nil {
+		t.Fatalf("InsertEvent() error = %v", err)
+	}
+
+	if err := w.Close(); err == nil {
+		t.Error("Close() should report the failed import")
+	}
+	if _, err := w.DeleteEventsByKinds(ctx, []int{1}); err == nil {
+		t.Error("DeleteEventsByKinds() should fail when the relay does")
+	}
+	if stats, _ := database.GetRelayStats(ctx); stats == nil || stats.TotalEvents != 1 {
+		t.Errorf("stats = %+v, want the event kept", stats)
+	}
+}
+
+// recordingBackend exports nothing and records every write.
+type recordingBackend struct {
+	deleted  []string
+	imported []string
+}
+
+func (b *recordingBackend) Type() string { return RelayTypeStrfry }
+func (b *recordingBackend) Export(ctx context.Context, since int64, fn func(*Event) error) error {
+	return nil
+}
+func (b *recordingBackend) Delete(ctx context.Context, ids []string) error {
+	b.deleted = append(b.deleted, ids...)
+	return nil
+}
+func (b *recordingBackend) Import(ctx context.Context, events []*Event) error {
+	for _, e := range events {
+		b.imported = append(b.imported, e.ID)
+	}
+	return nil
+}
+
+func TestStoreEventReplacesQueuedVersion(t *testing.T) {
+	ctx := context.Background()
+	backend := &recordingBackend{}
+	database := setupStrfryDB(t, backend)
+	if _, err := database.SyncRelayIndex(ctx, false); err != nil {
+		t.Fatalf("SyncRelayIndex() error = %v", err)
+	}
+
+	w, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter() error = %v", err)
+	}
+	now := time.Now().Truncate(time.Second)
+	older := testStrfryEvent(5, 0, now.Add(-time.Minute))
+	newer := testStrfryEvent(6, 0, now)
+	if _, err := w.StoreEvent(ctx, &older); err != nil {
+		t.Fatalf("StoreEvent(older) error = %v", err)
+	}
+	result, err := w.StoreEvent(ctx, &newer)
+	if err != nil || !result.Inserted || result.Replaced != 1 {
+		t.Fatalf("StoreEvent(newer) = %+v, %v; want inserted, 1 replaced", result, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// The older version never reached the backend, so it is neither
+	// deleted from nor imported into it
+	if len(backend.deleted) != 0 {
+		t.Errorf("deleted %v from the backend, want nothing", backend.deleted)
+	}
+	if len(backend.imported) != 1 || backend.imported[0] != newer.ID {
+		t.Errorf("imported %v, want only the newer version", backend.imported)
+	}
+}
+
+func TestSyncRelayIndexWithoutBackend(t *testing.T) {
+	database := setupTestRelayDB(t)
+	if _, err := database.SyncRelayIndex(context.Background(), false); err == nil {
+		t.Error("SyncRelayIndex() should fail for nostr-rs-relay")
+	}
+	if database.RelayType() != RelayTypeNostrRsRelay {
+		t.Errorf("RelayType() = %q, want nostr-rs-relay", database.RelayType())
+	}
+}
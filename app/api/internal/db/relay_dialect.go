@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// ErrUnsupportedOnPostgres is returned by relay database operations that
+// only work on nostr-rs-relay's SQLite database.
+var ErrUnsupportedOnPostgres = errors.New("not supported on a PostgreSQL relay database")
+
+// relayQuerier runs queries on a relay database connection or transaction.
+type relayQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// relayDialect is what differs between the databases nostr-rs-relay can
+// store events in. Queries are written with ? placeholders and the event
+// columns these methods return, and select nostr-rs-relay's SQLite types:
+// BLOB IDs, created_at in Unix seconds and the event JSON as text.
+type relayDialect interface {
+	// name identifies the dialect in logs and errors.
+	name() string
+	// bind rewrites ? placeholders for the driver.
+	bind(query string) string
+	// idColumn and authorColumn name the event ID and author columns.
+	idColumn() string
+	authorColumn() string
+	// createdAt selects the event's creation time in Unix seconds.
+	createdAt() string
+	// timeArg is the placeholder compared with created_at, given Unix seconds.
+	timeArg() string
+	// content selects the full event JSON as text.
+	content() string
+	// dateBucket formats created_at, shifted by the offset expression in
+	// seconds, as YYYY-MM-DD or, if hourly, YYYY-MM-DD HH:00.
+	dateBucket(hourly bool, offset string) string
+	// hasTable reports whether a table exists.
+	hasTable(ctx context.Context, q relayQuerier, table string) bool
+	// hasColumn reports whether a table has a column.
+	hasColumn(ctx context.Context, q relayQuerier, table, column string) bool
+	// vacuum reclaims space left by deleted events.
+	vacuum(ctx context.Context, conn *sql.DB) error
+	// integrityCheck checks the database for corruption.
+	integrityCheck(ctx context.Context, conn *sql.DB) (bool, string, error)
+	// pageInfo returns the page count, free pages and page size.
+	pageInfo(ctx context.Context, conn *sql.DB) (int64, int64, int64, error)
+	// size returns the database size in bytes.
+	size(ctx context.Context, conn *sql.DB, path string) (int64, error)
+}
+
+// eventColumns selects an event in the order scanEvent reads it.
+func eventColumns(dl relayDialect) string {
+	return fmt.Sprintf("%s, %s, %s, kind, %s", dl.idColumn(), dl.authorColumn(), dl.createdAt(), dl.content())
+}
+
+// sqliteDialect is nostr-rs-relay's default SQLite database.
+type sqliteDialect struct{}
+
+func (sqliteDialect) name() string             { return "sqlite" }
+func (sqliteDialect) bind(query string) string { return query }
+func (sqliteDialect) idColumn() string         { return "event_hash" }
+func (sqliteDialect) authorColumn() string     { return "author" }
+func (sqliteDialect) createdAt() string        { return "created_at" }
+func (sqliteDialect) timeArg() string          { return "?" }
+func (sqliteDialect) content() string          { return "content" }
+
+func (sqliteDialect) dateBucket(hourly bool, offset string) string {
+	if hourly {
+		return "strftime('%Y-%m-%d %H:00', datetime(created_at + " + offset + ", 'unixepoch'))"
+	}
+	return "DATE(datetime(created_at + " + offset + ", 'unixepoch'))"
+}
+
+func (sqliteDialect) hasTable(ctx context.Context, q relayQuerier, table string) bool {
+	var exists bool
+	q.QueryRowContext(ctx, `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&exists)
+	return exists
+}
+
+func (sqliteDialect) hasColumn(ctx context.Context, q relayQuerier, table, column string) bool {
+	var exists bool
+	q.QueryRowContext(ctx, `SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&exists)
+	return exists
+}
+
+func (sqliteDialect) vacuum(ctx context.Context, conn *sql.DB) error {
+	_, err := conn.ExecContext(ctx, "VACUUM")
+	return err
+}
+
+func (sqliteDialect) integrityCheck(ctx context.Context, conn *sql.DB) (bool, string, error) {
+	var result string
+	if err := conn.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return false, "", err
+	}
+	return result == "ok", result, nil
+}
+
+func (sqliteDialect) pageInfo(ctx context.Context, conn *sql.DB) (pageCount, freePages, pageSize int64, err error) {
+	if err = conn.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get page count: %w", err)
+	}
+	if err = conn.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&freePages); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get free page count: %w", err)
+	}
+	if err = conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get page size: %w", err)
+	}
+	return pageCount, freePages, pageSize, nil
+}
+
+func (sqliteDialect) size(ctx context.Context, conn *sql.DB, path string) (int64, error) {
+	if path == "" {
+		return 0, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to stat relay database: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// postgresDialect is nostr-rs-relay's PostgreSQL schema: the event ID in
+// id, the author in pub_key, created_at as a timestamp and the event JSON
+// as bytea.
+type postgresDialect struct{}
+
+func (postgresDialect) name() string         { return "postgres" }
+func (postgresDialect) idColumn() string     { return "id" }
+func (postgresDialect) authorColumn() string { return "pub_key" }
+func (postgresDialect) createdAt() string {
+	return "CAST(EXTRACT(EPOCH FROM created_at) AS BIGINT)"
+}
+func (postgresDialect) timeArg() string { return "to_timestamp(?)" }
+func (postgresDialect) content() string { return "convert_from(content, 'UTF8')" }
+
+// bind numbers the placeholders: ? becomes $1, $2 and so on. Queries pass
+// values as arguments, so a ? is never inside a string literal.
+func (postgresDialect) bind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (postgresDialect) dateBucket(hourly bool, offset string) string {
+	format := "YYYY-MM-DD"
+	if hourly {
+		format = "YYYY-MM-DD HH24:00"
+	}
+	return fmt.Sprintf("to_char((created_at AT TIME ZONE 'UTC') + make_interval(secs => CAST(%s AS double precision)), '%s')", offset, format)
+}
+
+func (dl postgresDialect) hasTable(ctx context.Context, q relayQuerier, table string) bool {
+	var exists bool
+	q.QueryRowContext(ctx, dl.bind("SELECT to_regclass(CAST(? AS text)) IS NOT NULL"), table).Scan(&exists)
+	return exists
+}
+
+func (dl postgresDialect) hasColumn(ctx context.Context, q relayQuerier, table, column string) bool {
+	var exists bool
+	q.QueryRowContext(ctx, dl.bind(`
+		SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = ? AND column_name = ?)
+	`), table, column).Scan(&exists)
+	return exists
+}
+
+func (postgresDialect) vacuum(ctx context.Context, conn *sql.DB) error {
+	_, err := conn.ExecContext(ctx, "VACUUM (ANALYZE)")
+	return err
+}
+
+// integrityCheck reads every event, which fails on a damaged page. Postgres
+// has no equivalent of SQLite's integrity_check; use amcheck for more.
+func (postgresDialect) integrityCheck(ctx context.Context, conn *sql.DB) (bool, string, error) {
+	var count int64
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM event").Scan(&count); err != nil {
+		return false, err.Error(), nil
+	}
+	return true, fmt.Sprintf("ok (read %d events)", count), nil
+}
+
+func (postgresDialect) pageInfo(ctx context.Context, conn *sql.DB) (pageCount, freePages, pageSize int64, err error) {
+	err = conn.QueryRowContext(ctx, `
+		SELECT pg_database_size(current_database()) / current_setting('block_size')::bigint,
+			current_setting('block_size')::bigint
+	`).Scan(&pageCount, &pageSize)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+	return pageCount, 0, pageSize, nil
+}
+
+func (postgresDialect) size(ctx context.Context, conn *sql.DB, path string) (int64, error) {
+	if conn == nil {
+		return 0, nil
+	}
+	var size int64
+	if err := conn.QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed to get relay database size: %w", err)
+	}
+	return size, nil
+}
+
+// UsePostgresRelay reads the relay database from PostgreSQL at dsn instead
+// of the SQLite file, for nostr-rs-relay configured with engine = "postgres".
+func (d *DB) UsePostgresRelay(dsn string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closeRelayWriteDB()
+	d.retireRelayDB()
+	d.relayDSN = dsn
+	d.dialect = postgresDialect{}
+	return d.connectRelayDB()
+}
+
+// IsRelayPostgres reports whether the relay database is PostgreSQL.
+func (d *DB) IsRelayPostgres() bool {
+	return d.relayDialect().name() == "postgres"
+}
+
+// relayDialect returns the dialect of the relay database.
+func (d *DB) relayDialect() relayDialect {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.dialect == nil {
+		return sqliteDialect{}
+	}
+	return d.dialect
+}
+
+// connectPostgresRelay opens the PostgreSQL relay database.
+func (d *DB) connectPostgresRelay() error {
+	db, err := sql.Open("postgres", d.relayDSN)
+	if err != nil {
+		return fmt.Errorf("failed to open relay database: %w", err)
+	}
+	db.SetMaxOpenConns(d.limits.RelayReaders)
+	db.SetMaxIdleConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to relay database: %w", err)
+	}
+
+	d.relayDB.Store(db)
+	return nil
+}
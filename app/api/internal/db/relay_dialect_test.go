@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostgresDialectBind(t *testing.T) {
+	dl := postgresDialect{}
+	got := dl.bind("SELECT id FROM event WHERE kind IN (?,?) AND created_at < " + dl.timeArg() + " LIMIT ?")
+	want := "SELECT id FROM event WHERE kind IN ($1,$2) AND created_at < to_timestamp($3) LIMIT $4"
+	if got != want {
+		t.Errorf("bind() = %q, want %q", got, want)
+	}
+
+	if got := (sqliteDialect{}).bind("SELECT ?"); got != "SELECT ?" {
+		t.Errorf("sqlite bind() = %q, want the query unchanged", got)
+	}
+}
+
+func TestRetentionScopeWhereDialects(t *testing.T) {
+	operator := strings.Repeat("ab", 32)
+	scope := RetentionScope{
+		Before:     time.Unix(1700000000, 0),
+		Kinds:      []int{1},
+		Exceptions: []string{"kind:0", "pubkey:operator"},
+	}
+
+	where, args := scope.where(sqliteDialect{}, operator)
+	if where != "created_at < ? AND kind IN (?) AND kind NOT IN (?) AND author NOT IN (?)" {
+		t.Errorf("sqlite where = %q", where)
+	}
+	if len(args) != 4 || args[0] != int64(1700000000) {
+		t.Errorf("sqlite args = %v", args)
+	}
+
+	where, _ = scope.where(postgresDialect{}, operator)
+	if where != "created_at < to_timestamp(?) AND kind IN (?) AND kind NOT IN (?) AND pub_key NOT IN (?)" {
+		t.Errorf("postgres where = %q", where)
+	}
+}
+
+func TestEventColumnsDialects(t *testing.T) {
+	if got := eventColumns(sqliteDialect{}); got != "event_hash, author, created_at, kind, content" {
+		t.Errorf("sqlite columns = %q", got)
+	}
+	got := eventColumns(postgresDialect{})
+	for _, want := range []string{"id, pub_key,", "EXTRACT(EPOCH FROM created_at)", "convert_from(content, 'UTF8')"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("postgres columns = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRelayDialectMaintenanceSQLite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	relayPath := filepath.Join(dir, "nostr.db")
+	conn, err := sql.Open("sqlite3", relayPath)
+	if err != nil {
+		t.Fatalf("failed to open relay db: %v", err)
+	}
+	if _, err := conn.Exec(relayIndexSchema); err != nil {
+		t.Fatalf("failed to create relay schema: %v", err)
+	}
+	insertTestEvent(t, conn, strings.Repeat("1", 64), strings.Repeat("a", 64), 1, time.Now(), "hi")
+	conn.Close()
+
+	database, err := New(relayPath, filepath.Join(dir, "roostr.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer database.Close()
+
+	if database.IsRelayPostgres() {
+		t.Fatal("test relay database should be SQLite")
+	}
+	size, err := database.GetRelayDatabaseSize()
+	if err != nil || size == 0 {
+		t.Errorf("GetRelayDatabaseSize() = %d, %v; want the file size", size, err)
+	}
+
+	w, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter() error = %v", err)
+	}
+	defer w.Close()
+
+	if ok, result, err := w.RunIntegrityCheck(ctx); err != nil || !ok || result != "ok" {
+		t.Errorf("RunIntegrityCheck() = %v, %q, %v", ok, result, err)
+	}
+	if pages, _, pageSize, err := w.GetPageInfo(ctx); err != nil || pages == 0 || pageSize == 0 {
+		t.Errorf("GetPageInfo() = %d pages of %d, %v", pages, pageSize, err)
+	}
+	if err := w.RunVacuum(ctx); err != nil {
+		t.Errorf("RunVacuum() error = %v", err)
+	}
+	if !w.dialect.hasTable(ctx, w.db, "event") || w.dialect.hasTable(ctx, w.db, "missing") {
+		t.Error("hasTable() should find event and not missing")
+	}
+}
+
+func TestUsePostgresRelayUnreachable(t *testing.T) {
+	database := setupTestRelayDB(t)
+	database.relayDB.Store(nil)
+
+	// Nothing listens on port 1; the dialect is kept so a reconnect retries
+	err := database.UsePostgresRelay("postgres://roostr@127.0.0.1:1/nostr?sslmode=disable&connect_timeout=1")
+	if err == nil {
+		t.Fatal("UsePostgresRelay() should fail without a server")
+	}
+	if !database.IsRelayPostgres() || database.IsRelayDBConnected() {
+		t.Error("relay database should be PostgreSQL and disconnected")
+	}
+
+	w := &RelayWriter{dialect: database.relayDialect()}
+	if _, err := w.InsertEvent(context.Background(), &Event{ID: strings.Repeat("1", 64)}); err != ErrUnsupportedOnPostgres {
+		t.Errorf("InsertEvent() error = %v, want ErrUnsupportedOnPostgres", err)
+	}
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// IsEphemeralKind reports whether kind is in the NIP-01 ephemeral range,
+// 20000-29999, whose events relays pass on without storing.
+func IsEphemeralKind(kind int) bool {
+	return kind >= 20000 && kind < 30000
+}
+
+// EventExpiration returns the time of an event's NIP-40 expiration tag, and
+// false if it has none or the tag is not a Unix timestamp.
+func EventExpiration(tags [][]string) (time.Time, bool) {
+	for _, tag := range tags {
+		if len(tag) < 2 || tag[0] != "expiration" {
+			continue
+		}
+		ts, err := strconv.ParseInt(tag[1], 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(ts, 0), true
+	}
+	return time.Time{}, false
+}
+
+// IsExpired reports whether an event's NIP-40 expiration is at or before now.
+func IsExpired(tags [][]string, now time.Time) bool {
+	expiration, ok := EventExpiration(tags)
+	return ok && !expiration.After(now)
+}
+
+// expiresAt is the value of an event's expires_at column: its NIP-40
+// expiration in Unix seconds, or NULL if it has none.
+func expiresAt(tags [][]string) interface{} {
+	if expiration, ok := EventExpiration(tags); ok {
+		return expiration.Unix()
+	}
+	return nil
+}
+
+// GetExpiredEventIDs returns the IDs of up to limit events whose NIP-40
+// expiration is at or before now, found through nostr-rs-relay's expires_at
+// index. Relay databases without the column are searched for expiration
+// tags in the stored JSON instead.
+func (d *DB) GetExpiredEventIDs(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+
+	dl := d.relayDialect()
+	if !dl.hasColumn(ctx, relay, "event", "expires_at") {
+		return d.scanExpiredEventIDs(ctx, relay, now, limit)
+	}
+
+	rows, err := relay.QueryContext(ctx, dl.bind(fmt.Sprintf(`
+		SELECT %s
+		FROM event
+		WHERE expires_at IS NOT NULL AND expires_at <= %s
+		LIMIT ?
+	`, dl.idColumn(), dl.timeArg())), now.Unix(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired events: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var idCol []byte
+		if err := rows.Scan(&idCol); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		ids = append(ids, hex.EncodeToString(idCol))
+	}
+	return ids, rows.Err()
+}
+
+// scanExpiredEventIDs finds expired events by reading every event whose
+// JSON mentions an expiration tag.
+func (d *DB) scanExpiredEventIDs(ctx context.Context, relay *sql.DB, now time.Time, limit int) ([]string, error) {
+	dl := d.relayDialect()
+	rows, err := relay.QueryContext(ctx, dl.bind(fmt.Sprintf(`
+		SELECT %s, %s
+		FROM event
+		WHERE %s LIKE ?
+	`, dl.idColumn(), dl.content(), dl.content())), `%"expiration"%`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expiring events: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var idCol []byte
+		var content string
+		if err := rows.Scan(&idCol, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		var event nostrEventJSON
+		if err := json.Unmarshal([]byte(content), &event); err != nil {
+			continue
+		}
+		if IsExpired(event.Tags, now) {
+			ids = append(ids, hex.EncodeToString(idCol))
+			if len(ids) >= limit {
+				break
+			}
+		}
+	}
+	return ids, rows.Err()
+}
+
+// BackfillExpirations sets expires_at on events Roostr stored before it
+// recorded their expiration, so GetExpiredEventIDs finds them through the
+// index. Returns how many events were updated.
+func (w *RelayWriter) BackfillExpirations(ctx context.Context) (int64, error) {
+	if !w.expiresAt || w.dialect.name() != "sqlite" {
+		return 0, nil
+	}
+
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT id, content FROM event WHERE expires_at IS NULL AND content LIKE ?
+	`, `%"expiration"%`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expiring events: %w", err)
+	}
+	expirations := make(map[int64]int64)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan event: %w", err)
+		}
+		var event nostrEventJSON
+		if err := json.Unmarshal([]byte(content), &event); err != nil {
+			continue
+		}
+		if expiration, ok := EventExpiration(event.Tags); ok {
+			expirations[id] = expiration.Unix()
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expiring events: %w", err)
+	}
+
+	var updated int64
+	for id, expiration := range expirations {
+		if _, err := w.exec(ctx, `UPDATE event SET expires_at = ? WHERE id = ?`, expiration, id); err != nil {
+			return updated, fmt.Errorf("failed to set event expiration: %w", err)
+		}
+		updated++
+	}
+	return updated, nil
+}
@@ -1,11 +1,17 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -19,6 +25,7 @@ type Event struct {
 	Tags      [][]string `json:"tags"`
 	Content   string     `json:"content"`
 	Sig       string     `json:"sig"`
+	Redacted  bool       `json:"redacted,omitempty"` // Content withheld by DM privacy mode
 }
 
 // ExportEvent represents a Nostr event for export with Unix timestamp.
@@ -33,17 +40,96 @@ type ExportEvent struct {
 	Sig       string     `json:"sig"`
 }
 
+// EncryptedDMKinds are the kinds whose content is an encrypted direct
+// message: NIP-04 DMs (4) and gift wraps (1059, 1060).
+var EncryptedDMKinds = []int{4, 1059, 1060}
+
+// IsEncryptedDMKind reports whether kind is one of EncryptedDMKinds.
+func IsEncryptedDMKind(kind int) bool {
+	return slices.Contains(EncryptedDMKinds, kind)
+}
+
+// RedactDM clears the content of an encrypted DM, leaving its metadata and
+// marking it redacted. Other kinds are left as they are.
+func (e *Event) RedactDM() {
+	if IsEncryptedDMKind(e.Kind) {
+		e.Content = ""
+		e.Redacted = true
+	}
+}
+
+// RedactDM clears the content of an encrypted DM, leaving its metadata.
+// Exports keep the standard event format, so the event is not marked and
+// its signature no longer verifies. Other kinds are left as they are.
+func (e *ExportEvent) RedactDM() {
+	if IsEncryptedDMKind(e.Kind) {
+		e.Content = ""
+	}
+}
+
+// RedactDMs clears the content of the encrypted DMs among events.
+func RedactDMs(events []Event) {
+	for i := range events {
+		events[i].RedactDM()
+	}
+}
+
 // EventFilter defines filters for querying events.
 type EventFilter struct {
-	IDs      []string  // Event IDs
-	Authors  []string  // Pubkeys (hex)
-	Kinds    []int     // Event kinds
-	Since    time.Time // Events after this time
-	Until    time.Time // Events before this time
-	Limit    int       // Max results (default 50)
-	Offset   int       // Pagination offset
-	Search   string    // Content search (basic)
-	Mentions string    // Filter events mentioning this pubkey (hex)
+	IDs      []string     // Event IDs
+	Authors  []string     // Pubkeys (hex)
+	Kinds    []int        // Event kinds
+	Since    time.Time    // Events after this time
+	Until    time.Time    // Events before this time
+	Limit    int          // Max results (default 50)
+	After    *EventCursor // Continue after this event, for the next page
+	Search   string       // Content search (basic)
+	Mentions string       // Filter events mentioning this pubkey (hex); same as Tags["p"]
+	// Tags filters on single-letter tags, like NIP-01's #e, #p and #t: an
+	// event matches when, for every tag name, it has one of the values.
+	Tags map[string][]string
+}
+
+// EventCursor is a place in the newest-first order GetEvents returns
+// events in: the events after it were created earlier, or in the same
+// second with a lower ID. Unlike an offset it keeps its place when new
+// events arrive, and the relay's created_at index finds it on deep pages.
+type EventCursor struct {
+	CreatedAt int64  // Unix seconds
+	ID        string // Event ID (hex)
+}
+
+// ErrInvalidCursor is returned for a cursor that EventCursor.String did not make.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorAfter returns the cursor of the events after event.
+func CursorAfter(event Event) *EventCursor {
+	return &EventCursor{CreatedAt: event.CreatedAt.Unix(), ID: event.ID}
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c EventCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.CreatedAt, 10) + ":" + c.ID))
+}
+
+// ParseEventCursor decodes a cursor made by EventCursor.String.
+func ParseEventCursor(s string) (*EventCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &EventCursor{CreatedAt: createdAt, ID: id}, nil
 }
 
 // RelayStats holds aggregate statistics from the relay database.
@@ -58,7 +144,8 @@ type RelayStats struct {
 
 // GetEvent retrieves a single event by ID.
 func (d *DB) GetEvent(ctx context.Context, id string) (*Event, error) {
-	if d.RelayDB == nil {
+	relay := d.RelayDB()
+	if relay == nil {
 		return nil, fmt.Errorf("relay database not connected")
 	}
 
@@ -67,24 +154,161 @@ func (d *DB) GetEvent(ctx context.Context, id string) (*Event, error) {
 		return nil, fmt.Errorf("invalid event ID: %w", err)
 	}
 
-	row := d.RelayDB.QueryRowContext(ctx, `
-		SELECT event_hash, author, created_at, kind, content
+	dl := d.relayDialect()
+	row := relay.QueryRowContext(ctx, dl.bind(fmt.Sprintf(`
+		SELECT %s
 		FROM event
-		WHERE event_hash = ?
-	`, idBytes)
+		WHERE %s = ?
+	`, eventColumns(dl), dl.idColumn())), idBytes)
 
 	return scanEvent(row)
 }
 
-// GetEvents retrieves events matching the filter.
-func (d *DB) GetEvents(ctx context.Context, filter EventFilter) ([]Event, error) {
-	if d.RelayDB == nil {
+// StoredEvent is an event as the relay stores it: the indexed columns and
+// the serialized event JSON, unparsed.
+type StoredEvent struct {
+	ID        string // event_hash
+	Pubkey    string // author
+	CreatedAt int64
+	Kind      int
+	JSON      string
+}
+
+// GetStoredEvent returns an event's row as stored, or nil if there is none.
+func (d *DB) GetStoredEvent(ctx context.Context, id string) (*StoredEvent, error) {
+	relay := d.RelayDB()
+	if relay == nil {
 		return nil, fmt.Errorf("relay database not connected")
 	}
 
+	idBytes, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event ID: %w", err)
+	}
+
+	dl := d.relayDialect()
+	var idCol, author []byte
+	stored := &StoredEvent{}
+	err = relay.QueryRowContext(ctx, dl.bind(fmt.Sprintf(`
+		SELECT %s
+		FROM event
+		WHERE %s = ?
+	`, eventColumns(dl), dl.idColumn())), idBytes).Scan(&idCol, &author, &stored.CreatedAt, &stored.Kind, &stored.JSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+	stored.ID = hex.EncodeToString(idCol)
+	stored.Pubkey = hex.EncodeToString(author)
+	return stored, nil
+}
+
+// StreamStoredEvents calls fn with every event row as stored, in no
+// particular order, stopping at the first error fn returns.
+func (d *DB) StreamStoredEvents(ctx context.Context, fn func(StoredEvent) error) error {
+	relay := d.RelayDB()
+	if relay == nil {
+		return fmt.Errorf("relay database not connected")
+	}
+
+	dl := d.relayDialect()
+	rows, err := relay.QueryContext(ctx, `SELECT `+eventColumns(dl)+` FROM event`)
+	if err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var idCol, author []byte
+		var stored StoredEvent
+		if err := rows.Scan(&idCol, &author, &stored.CreatedAt, &stored.Kind, &stored.JSON); err != nil {
+			return fmt.Errorf("failed to scan event: %w", err)
+		}
+		stored.ID = hex.EncodeToString(idCol)
+		stored.Pubkey = hex.EncodeToString(author)
+		if err := fn(stored); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetEvents retrieves events matching the filter, newest first.
+func (d *DB) GetEvents(ctx context.Context, filter EventFilter) ([]Event, error) {
+	events, _, err := d.GetEventsPage(ctx, filter)
+	return events, err
+}
+
+// GetEventsPage retrieves a page of events matching the filter, newest
+// first, and the cursor of the next page, or nil on the last page.
+func (d *DB) GetEventsPage(ctx context.Context, filter EventFilter) ([]Event, *EventCursor, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, nil, fmt.Errorf("relay database not connected")
+	}
+
 	// Build query - nostr-rs-relay uses event_hash for ID, author for pubkey,
 	// and stores the full event JSON in content
-	query := `SELECT event_hash, author, created_at, kind, content FROM event WHERE 1=1`
+	dl := d.relayDialect()
+	where, args, err := d.eventWhere(ctx, relay, dl, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	query := `SELECT ` + eventColumns(dl) + ` FROM event WHERE ` + where
+
+	// Keyset pagination: continue below the cursor in (created_at, ID) order
+	if filter.After != nil {
+		idBytes, err := hex.DecodeString(filter.After.ID)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		query += fmt.Sprintf(" AND (created_at < %s OR (created_at = %s AND %s < ?))",
+			dl.timeArg(), dl.timeArg(), dl.idColumn())
+		args = append(args, filter.After.CreatedAt, filter.After.CreatedAt, idBytes)
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, %s DESC", dl.idColumn())
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if max := d.maxQueryLimit(); limit > max {
+		limit = max
+	}
+	// One more than the page tells whether there is a next page
+	query += fmt.Sprintf(" LIMIT %d", limit+1)
+
+	rows, err := relay.QueryContext(ctx, dl.bind(query), args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		event, err := scanEventRows(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *EventCursor
+	if len(events) > limit {
+		events = events[:limit]
+		next = CursorAfter(events[limit-1])
+	}
+	return events, next, nil
+}
+
+// eventWhere builds the WHERE clause GetEvents and CountEvents share.
+func (d *DB) eventWhere(ctx context.Context, relay *sql.DB, dl relayDialect, filter EventFilter) (string, []interface{}, error) {
+	conds := []string{"1=1"}
 	args := []interface{}{}
 
 	if len(filter.IDs) > 0 {
@@ -92,12 +316,12 @@ func (d *DB) GetEvents(ctx context.Context, filter EventFilter) ([]Event, error)
 		for i, id := range filter.IDs {
 			idBytes, err := hex.DecodeString(id)
 			if err != nil {
-				return nil, fmt.Errorf("invalid event ID: %w", err)
+				return "", nil, fmt.Errorf("invalid event ID: %w", err)
 			}
 			placeholders[i] = "?"
 			args = append(args, idBytes)
 		}
-		query += fmt.Sprintf(" AND event_hash IN (%s)", strings.Join(placeholders, ","))
+		conds = append(conds, fmt.Sprintf("%s IN (%s)", dl.idColumn(), strings.Join(placeholders, ",")))
 	}
 
 	if len(filter.Authors) > 0 {
@@ -105,12 +329,12 @@ func (d *DB) GetEvents(ctx context.Context, filter EventFilter) ([]Event, error)
 		for i, pubkey := range filter.Authors {
 			pubkeyBytes, err := hex.DecodeString(pubkey)
 			if err != nil {
-				return nil, fmt.Errorf("invalid pubkey: %w", err)
+				return "", nil, fmt.Errorf("invalid pubkey: %w", err)
 			}
 			placeholders[i] = "?"
 			args = append(args, pubkeyBytes)
 		}
-		query += fmt.Sprintf(" AND author IN (%s)", strings.Join(placeholders, ","))
+		conds = append(conds, fmt.Sprintf("%s IN (%s)", dl.authorColumn(), strings.Join(placeholders, ",")))
 	}
 
 	if len(filter.Kinds) > 0 {
@@ -119,63 +343,213 @@ func (d *DB) GetEvents(ctx context.Context, filter EventFilter) ([]Event, error)
 			placeholders[i] = "?"
 			args = append(args, kind)
 		}
-		query += fmt.Sprintf(" AND kind IN (%s)", strings.Join(placeholders, ","))
+		conds = append(conds, fmt.Sprintf("kind IN (%s)", strings.Join(placeholders, ",")))
 	}
 
 	if !filter.Since.IsZero() {
-		query += " AND created_at >= ?"
+		conds = append(conds, "created_at >= "+dl.timeArg())
 		args = append(args, filter.Since.Unix())
 	}
 
 	if !filter.Until.IsZero() {
-		query += " AND created_at <= ?"
+		conds = append(conds, "created_at <= "+dl.timeArg())
 		args = append(args, filter.Until.Unix())
 	}
 
 	if filter.Search != "" {
-		query += " AND content LIKE ?"
+		conds = append(conds, dl.content()+" LIKE ?")
 		args = append(args, "%"+filter.Search+"%")
 	}
 
-	if filter.Mentions != "" {
-		// Filter events that have a "p" tag mentioning this pubkey
-		// In nostr-rs-relay, tags are stored within the content JSON
-		query += " AND content LIKE ?"
-		args = append(args, `%["p","`+filter.Mentions+`"%`)
+	tags := filter.tagFilters()
+	if len(tags) > 0 {
+		names := make([]string, 0, len(tags))
+		for name := range tags {
+			if len(name) != 1 || !isTagLetter(name[0]) {
+				return "", nil, fmt.Errorf("invalid tag filter %q: tag names are a single letter", name)
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// nostr-rs-relay indexes single-letter tags in its SQLite tag table;
+		// without one, look for the tag in the stored event JSON
+		indexed := !d.IsRelayPostgres() && dl.hasTable(ctx, relay, "tag")
+		for _, name := range names {
+			var cond string
+			var condArgs []interface{}
+			if indexed {
+				cond, condArgs = tagIndexCondition(name, tags[name])
+			} else {
+				cond, condArgs = tagJSONCondition(dl, name, tags[name])
+			}
+			conds = append(conds, cond)
+			args = append(args, condArgs...)
+		}
+	}
+
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// tagFilters returns the filter's tag filters with Mentions added to "p".
+func (f EventFilter) tagFilters() map[string][]string {
+	tags := make(map[string][]string, len(f.Tags)+1)
+	for name, values := range f.Tags {
+		if len(values) > 0 {
+			tags[name] = values
+		}
+	}
+	if f.Mentions != "" {
+		tags["p"] = append(append([]string{}, tags["p"]...), f.Mentions)
+	}
+	return tags
+}
+
+// isTagLetter reports whether c can name an indexed tag.
+func isTagLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// tagIndexCondition matches events with a name tag holding one of values
+// through the tag table. nostr-rs-relay stores lowercase hex values as
+// bytes in value_hex and everything else as text in value.
+func tagIndexCondition(name string, values []string) (string, []interface{}) {
+	var hexValues []interface{}
+	args := []interface{}{name}
+	for _, value := range values {
+		if b, err := hex.DecodeString(value); err == nil && value != "" && value == strings.ToLower(value) {
+			hexValues = append(hexValues, b)
+		}
+		args = append(args, value)
+	}
+
+	match := "value IN (" + placeholderList(len(values)) + ")"
+	if len(hexValues) > 0 {
+		match = "(" + match + " OR value_hex IN (" + placeholderList(len(hexValues)) + "))"
+		args = append(args, hexValues...)
+	}
+	return "id IN (SELECT event_id FROM tag WHERE name = ? AND " + match + ")", args
+}
+
+// tagJSONCondition matches events with a name tag holding one of values by
+// looking for the tag's start, e.g. ["p","<pubkey>", in the event JSON.
+func tagJSONCondition(dl relayDialect, name string, values []string) (string, []interface{}) {
+	likes := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		// Relays store <, > and & unescaped
+		var tag bytes.Buffer
+		enc := json.NewEncoder(&tag)
+		enc.SetEscapeHTML(false)
+		enc.Encode([]string{name, value})
+		prefix := strings.TrimSuffix(strings.TrimSpace(tag.String()), "]")
+		likes[i] = dl.content() + ` LIKE ? ESCAPE '\'`
+		args[i] = "%" + escapeLike(prefix) + "%"
+	}
+	return "(" + strings.Join(likes, " OR ") + ")", args
+}
+
+// escapeLike escapes LIKE wildcards so s matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// placeholderList returns n comma-separated ? placeholders.
+func placeholderList(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// searchScanPages bounds how many candidate pages SearchEvents reads
+// while looking for confirmed matches.
+const searchScanPages = 5
+
+// SearchTerms splits a NIP-50 search query into lowercase terms.
+// key:value extension tokens (e.g. "language:en") are ignored.
+func SearchTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		if strings.Contains(field, ":") {
+			continue
+		}
+		terms = append(terms, strings.ToLower(field))
+	}
+	return terms
+}
+
+// SearchEvents returns events whose content contains every term of a NIP-50
+// style search query, newest first. The remaining filter fields scope the search.
+func (d *DB) SearchEvents(ctx context.Context, query string, filter EventFilter) ([]Event, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
 	}
 
-	// Order and pagination
-	query += " ORDER BY created_at DESC"
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return []Event{}, nil
+	}
 
 	limit := filter.Limit
 	if limit <= 0 {
 		limit = 50
 	}
-	if limit > 1000 {
-		limit = 1000
+	if max := d.maxQueryLimit(); limit > max {
+		limit = max
 	}
-	query += fmt.Sprintf(" LIMIT %d", limit)
 
-	if filter.Offset > 0 {
-		query += fmt.Sprintf(" OFFSET %d", filter.Offset)
+	// Narrow candidates with the longest term; LIKE runs against the stored
+	// event JSON (tags included), so each candidate is confirmed against the
+	// decoded content.
+	longest := terms[0]
+	for _, term := range terms[1:] {
+		if len(term) > len(longest) {
+			longest = term
+		}
 	}
 
-	rows, err := d.RelayDB.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query events: %w", err)
+	pageSize := limit * 2
+	if pageSize > 1000 {
+		pageSize = 1000
 	}
-	defer rows.Close()
 
-	var events []Event
-	for rows.Next() {
-		event, err := scanEventRows(rows)
+	scoped := filter
+	scoped.Search = longest
+	scoped.Limit = pageSize
+
+	events := []Event{}
+	for page := 0; page < searchScanPages && len(events) < limit; page++ {
+		candidates, next, err := d.GetEventsPage(ctx, scoped)
 		if err != nil {
 			return nil, err
 		}
-		events = append(events, *event)
+		scoped.After = next
+
+		for _, event := range candidates {
+			if contentMatchesTerms(event.Content, terms) {
+				events = append(events, event)
+				if len(events) == limit {
+					break
+				}
+			}
+		}
+
+		if next == nil {
+			break
+		}
 	}
 
-	return events, rows.Err()
+	return events, nil
+}
+
+// contentMatchesTerms reports whether content contains every term, ignoring case.
+func contentMatchesTerms(content string, terms []string) bool {
+	lower := strings.ToLower(content)
+	for _, term := range terms {
+		if !strings.Contains(lower, term) {
+			return false
+		}
+	}
+	return true
 }
 
 // GetRecentEvents retrieves the most recent events.
@@ -185,7 +559,8 @@ func (d *DB) GetRecentEvents(ctx context.Context, limit int) ([]Event, error) {
 
 // GetRelayStats retrieves aggregate statistics from the relay database.
 func (d *DB) GetRelayStats(ctx context.Context) (*RelayStats, error) {
-	if d.RelayDB == nil {
+	relay := d.RelayDB()
+	if relay == nil {
 		return nil, fmt.Errorf("relay database not connected")
 	}
 
@@ -194,19 +569,20 @@ func (d *DB) GetRelayStats(ctx context.Context) (*RelayStats, error) {
 	}
 
 	// Total events
-	err := d.RelayDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM event").Scan(&stats.TotalEvents)
+	err := relay.QueryRowContext(ctx, "SELECT COUNT(*) FROM event").Scan(&stats.TotalEvents)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count events: %w", err)
 	}
 
 	// Unique pubkeys (nostr-rs-relay uses 'author' column)
-	err = d.RelayDB.QueryRowContext(ctx, "SELECT COUNT(DISTINCT author) FROM event").Scan(&stats.TotalPubkeys)
+	dl := d.relayDialect()
+	err = relay.QueryRowContext(ctx, "SELECT COUNT(DISTINCT "+dl.authorColumn()+") FROM event").Scan(&stats.TotalPubkeys)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count pubkeys: %w", err)
 	}
 
 	// Events by kind
-	rows, err := d.RelayDB.QueryContext(ctx, "SELECT kind, COUNT(*) FROM event GROUP BY kind ORDER BY COUNT(*) DESC")
+	rows, err := relay.QueryContext(ctx, "SELECT kind, COUNT(*) FROM event GROUP BY kind ORDER BY COUNT(*) DESC")
 	if err != nil {
 		return nil, fmt.Errorf("failed to count events by kind: %w", err)
 	}
@@ -223,7 +599,7 @@ func (d *DB) GetRelayStats(ctx context.Context) (*RelayStats, error) {
 
 	// Oldest and newest event timestamps
 	var oldest, newest sql.NullInt64
-	err = d.RelayDB.QueryRowContext(ctx, "SELECT MIN(created_at), MAX(created_at) FROM event").Scan(&oldest, &newest)
+	err = relay.QueryRowContext(ctx, fmt.Sprintf("SELECT MIN(%[1]s), MAX(%[1]s) FROM event", dl.createdAt())).Scan(&oldest, &newest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get event time range: %w", err)
 	}
@@ -239,7 +615,8 @@ func (d *DB) GetRelayStats(ctx context.Context) (*RelayStats, error) {
 
 // GetEventsToday returns the count of events created today (since midnight in the given timezone).
 func (d *DB) GetEventsToday(ctx context.Context, loc *time.Location) (int64, error) {
-	if d.RelayDB == nil {
+	relay := d.RelayDB()
+	if relay == nil {
 		return 0, fmt.Errorf("relay database not connected")
 	}
 
@@ -253,8 +630,9 @@ func (d *DB) GetEventsToday(ctx context.Context, loc *time.Location) (int64, err
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 
 	var count int64
-	err := d.RelayDB.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM event WHERE created_at >= ?",
+	dl := d.relayDialect()
+	err := relay.QueryRowContext(ctx,
+		dl.bind("SELECT COUNT(*) FROM event WHERE created_at >= "+dl.timeArg()),
 		startOfDay.Unix(),
 	).Scan(&count)
 	if err != nil {
@@ -264,140 +642,614 @@ func (d *DB) GetEventsToday(ctx context.Context, loc *time.Location) (int64, err
 	return count, nil
 }
 
-// CountEventsByPubkey counts events for each pubkey.
+// authorCountChunk is the most pubkeys one counting query binds, well
+// under SQLite's limit of 999 parameters.
+const authorCountChunk = 500
+
+// authorCountTTL is how long CountEventsByPubkeyCached reuses a count.
+const authorCountTTL = time.Minute
+
+// authorCount is a cached event count of one pubkey.
+type authorCount struct {
+	count     int64
+	countedAt time.Time
+}
+
+// CountEventsByPubkey counts events for each pubkey, keyed by lowercase
+// hex, with one query per chunk of pubkeys. Pubkeys that are not valid hex
+// are left out.
 func (d *DB) CountEventsByPubkey(ctx context.Context, pubkeys []string) (map[string]int64, error) {
-	if d.RelayDB == nil {
+	relay := d.RelayDB()
+	if relay == nil {
 		return nil, fmt.Errorf("relay database not connected")
 	}
 
-	result := make(map[string]int64)
+	args := authorArgs(pubkeys)
+	result := make(map[string]int64, len(args))
+	for _, arg := range args {
+		result[hex.EncodeToString(arg.([]byte))] = 0
+	}
 
-	for _, pubkey := range pubkeys {
-		pubkeyBytes, err := hex.DecodeString(pubkey)
-		if err != nil {
-			continue
-		}
+	dl := d.relayDialect()
+	for len(args) > 0 {
+		chunk := args[:min(len(args), authorCountChunk)]
+		args = args[len(chunk):]
 
-		var count int64
-		err = d.RelayDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM event WHERE author = ?", pubkeyBytes).Scan(&count)
+		in := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		rows, err := relay.QueryContext(ctx, dl.bind(fmt.Sprintf(
+			"SELECT %s, COUNT(*) FROM event WHERE %s IN (%s) GROUP BY %s",
+			dl.authorColumn(), dl.authorColumn(), in, dl.authorColumn())), chunk...)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("failed to count events: %w", err)
+		}
+		for rows.Next() {
+			var author []byte
+			var count int64
+			if err := rows.Scan(&author, &count); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to count events: %w", err)
+			}
+			result[hex.EncodeToString(author)] = count
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to count events: %w", err)
 		}
-		result[pubkey] = count
 	}
 
 	return result, nil
 }
 
-// GetTopAuthors returns the pubkeys with the most events.
-func (d *DB) GetTopAuthors(ctx context.Context, limit int) ([]struct {
-	Pubkey     string `json:"pubkey"`
-	EventCount int64  `json:"event_count"`
-}, error) {
-	if d.RelayDB == nil {
+// ExistingEventIDs returns which of the event IDs are stored on the relay,
+// with one query per chunk of IDs. IDs that are not valid hex are left out.
+func (d *DB) ExistingEventIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	relay := d.RelayDB()
+	if relay == nil {
 		return nil, fmt.Errorf("relay database not connected")
 	}
 
-	if limit <= 0 {
-		limit = 10
-	}
-
-	rows, err := d.RelayDB.QueryContext(ctx, `
-		SELECT author, COUNT(*) as count
-		FROM event
-		GROUP BY author
-		ORDER BY count DESC
-		LIMIT ?
-	`, limit)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query top authors: %w", err)
+	var args []interface{}
+	for _, id := range ids {
+		if idBytes, err := hex.DecodeString(id); err == nil {
+			args = append(args, idBytes)
+		}
 	}
-	defer rows.Close()
 
-	var authors []struct {
-		Pubkey     string `json:"pubkey"`
-		EventCount int64  `json:"event_count"`
-	}
+	existing := make(map[string]bool)
+	dl := d.relayDialect()
+	for len(args) > 0 {
+		chunk := args[:min(len(args), authorCountChunk)]
+		args = args[len(chunk):]
 
-	for rows.Next() {
-		var pubkeyBytes []byte
-		var count int64
-		if err := rows.Scan(&pubkeyBytes, &count); err != nil {
-			return nil, err
+		in := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		rows, err := relay.QueryContext(ctx, dl.bind(fmt.Sprintf(
+			"SELECT %s FROM event WHERE %s IN (%s)", dl.idColumn(), dl.idColumn(), in)), chunk...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up events: %w", err)
+		}
+		for rows.Next() {
+			var id []byte
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to look up events: %w", err)
+			}
+			existing[hex.EncodeToString(id)] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to look up events: %w", err)
 		}
-		authors = append(authors, struct {
-			Pubkey     string `json:"pubkey"`
-			EventCount int64  `json:"event_count"`
-		}{
-			Pubkey:     hex.EncodeToString(pubkeyBytes),
-			EventCount: count,
-		})
 	}
 
-	return authors, rows.Err()
+	return existing, nil
 }
 
-// CountEventsBefore counts events created before the given timestamp.
-func (d *DB) CountEventsBefore(ctx context.Context, before time.Time) (int64, error) {
-	if d.RelayDB == nil {
-		return 0, fmt.Errorf("relay database not connected")
+// CountEventsByPubkeyCached is CountEventsByPubkey with counts reused for
+// up to a minute, for pages that list many members and are reloaded often.
+// Only the pubkeys without a fresh count are queried.
+func (d *DB) CountEventsByPubkeyCached(ctx context.Context, pubkeys []string) (map[string]int64, error) {
+	now := time.Now()
+	result := make(map[string]int64, len(pubkeys))
+	var missing []string
+
+	d.authorCountsMu.Lock()
+	for _, pubkey := range pubkeys {
+		pubkey = strings.ToLower(pubkey)
+		if c, ok := d.authorCounts[pubkey]; ok && now.Sub(c.countedAt) < authorCountTTL {
+			result[pubkey] = c.count
+		} else {
+			missing = append(missing, pubkey)
+		}
 	}
+	d.authorCountsMu.Unlock()
 
-	var count int64
-	err := d.RelayDB.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM event WHERE created_at < ?",
-		before.Unix(),
-	).Scan(&count)
+	if len(missing) == 0 {
+		return result, nil
+	}
+	counts, err := d.CountEventsByPubkey(ctx, missing)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count events: %w", err)
+		return nil, err
 	}
 
-	return count, nil
+	d.authorCountsMu.Lock()
+	defer d.authorCountsMu.Unlock()
+	if d.authorCounts == nil {
+		d.authorCounts = make(map[string]authorCount)
+	}
+	for pubkey, c := range d.authorCounts {
+		if now.Sub(c.countedAt) >= authorCountTTL {
+			delete(d.authorCounts, pubkey)
+		}
+	}
+	for pubkey, count := range counts {
+		result[pubkey] = count
+		d.authorCounts[pubkey] = authorCount{count: count, countedAt: now}
+	}
+	return result, nil
 }
 
-// CountEventsBeforeWithExceptions counts events created before the given timestamp,
-// excluding events that match the exceptions (same logic as DeleteEventsBefore).
-func (d *DB) CountEventsBeforeWithExceptions(ctx context.Context, before time.Time, exceptions []string, operatorPubkey string) (int64, error) {
-	if d.RelayDB == nil {
-		return 0, fmt.Errorf("relay database not connected")
-	}
+// clearAuthorCounts drops the cached event counts.
+func (d *DB) clearAuthorCounts() {
+	d.authorCountsMu.Lock()
+	d.authorCounts = nil
+	d.authorCountsMu.Unlock()
+}
 
-	// If no exceptions, use simpler query
-	if len(exceptions) == 0 {
-		return d.CountEventsBefore(ctx, before)
-	}
+// AuthorActivity summarizes the stored events of a single author.
+type AuthorActivity struct {
+	EventCount   int64      `json:"event_count"`
+	StorageBytes int64      `json:"storage_bytes"`
+	LastActive   *time.Time `json:"last_active,omitempty"`
+}
 
-	// Build query with exceptions (mirrors DeleteEventsBefore logic)
-	query := "SELECT COUNT(*) FROM event WHERE created_at < ?"
-	args := []interface{}{before.Unix()}
+// GetAuthorActivity returns event count, approximate storage and last event
+// time for each pubkey, keyed by lowercase hex, with one query per chunk of
+// pubkeys. Pubkeys without events or that are not valid hex are omitted.
+func (d *DB) GetAuthorActivity(ctx context.Context, pubkeys []string) (map[string]AuthorActivity, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
 
-	// Parse exceptions
-	var kindExceptions []int
-	var pubkeyExceptions [][]byte
+	args := authorArgs(pubkeys)
+	result := make(map[string]AuthorActivity)
+	dl := d.relayDialect()
+	for len(args) > 0 {
+		chunk := args[:min(len(args), authorCountChunk)]
+		args = args[len(chunk):]
 
-	for _, exc := range exceptions {
-		if strings.HasPrefix(exc, "kind:") {
-			var kind int
-			fmt.Sscanf(exc, "kind:%d", &kind)
-			kindExceptions = append(kindExceptions, kind)
-		} else if strings.HasPrefix(exc, "pubkey:") {
-			pubkey := strings.TrimPrefix(exc, "pubkey:")
-			if pubkey == "operator" && operatorPubkey != "" {
-				pubkeyBytes, err := hex.DecodeString(operatorPubkey)
-				if err == nil {
-					pubkeyExceptions = append(pubkeyExceptions, pubkeyBytes)
-				}
-			} else {
-				pubkeyBytes, err := hex.DecodeString(pubkey)
-				if err == nil {
-					pubkeyExceptions = append(pubkeyExceptions, pubkeyBytes)
-				}
+		in := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		rows, err := relay.QueryContext(ctx, dl.bind(fmt.Sprintf(
+			"SELECT %s, COUNT(*), SUM(LENGTH(content)), MAX(%s) FROM event WHERE %s IN (%s) GROUP BY %s",
+			dl.authorColumn(), dl.createdAt(), dl.authorColumn(), in, dl.authorColumn())), chunk...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get author activity: %w", err)
+		}
+		for rows.Next() {
+			var author []byte
+			var activity AuthorActivity
+			var size, lastActive sql.NullInt64
+			if err := rows.Scan(&author, &activity.EventCount, &size, &lastActive); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to get author activity: %w", err)
 			}
+			activity.StorageBytes = size.Int64
+			if lastActive.Valid {
+				t := time.Unix(lastActive.Int64, 0)
+				activity.LastActive = &t
+			}
+			result[hex.EncodeToString(author)] = activity
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to get author activity: %w", err)
 		}
 	}
 
-	// Add kind exceptions
-	if len(kindExceptions) > 0 {
+	return result, nil
+}
+
+// authorArgs decodes hex pubkeys into author column values, once each.
+// Pubkeys that are not valid hex are left out.
+func authorArgs(pubkeys []string) []interface{} {
+	seen := make(map[string]bool, len(pubkeys))
+	var args []interface{}
+	for _, pubkey := range pubkeys {
+		pubkey = strings.ToLower(pubkey)
+		pubkeyBytes, err := hex.DecodeString(pubkey)
+		if err != nil || seen[pubkey] {
+			continue
+		}
+		seen[pubkey] = true
+		args = append(args, pubkeyBytes)
+	}
+	return args
+}
+
+// AuthorUsage is what a single author has stored, for quota checks.
+type AuthorUsage struct {
+	EventsSince  int64 `json:"events_since"` // events created since the given time
+	StorageBytes int64 `json:"storage_bytes"`
+}
+
+// GetAuthorUsage returns, for each pubkey, the events created since since
+// and the approximate storage of all its events. Pubkeys that are not
+// valid hex are left out.
+func (d *DB) GetAuthorUsage(ctx context.Context, pubkeys []string, since time.Time) (map[string]AuthorUsage, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+
+	result := make(map[string]AuthorUsage, len(pubkeys))
+	dl := d.relayDialect()
+	query := dl.bind(fmt.Sprintf(
+		"SELECT COALESCE(SUM(CASE WHEN created_at >= %s THEN 1 ELSE 0 END), 0), COALESCE(SUM(LENGTH(content)), 0) FROM event WHERE %s = ?",
+		dl.timeArg(), dl.authorColumn()))
+
+	for _, pubkey := range pubkeys {
+		pubkeyBytes, err := hex.DecodeString(pubkey)
+		if err != nil {
+			continue
+		}
+		var usage AuthorUsage
+		if err := relay.QueryRowContext(ctx, query, since.Unix(), pubkeyBytes).Scan(&usage.EventsSince, &usage.StorageBytes); err != nil {
+			return nil, fmt.Errorf("failed to get author usage: %w", err)
+		}
+		result[pubkey] = usage
+	}
+
+	return result, nil
+}
+
+// GetAuthorEventCounts returns every author with stored events and how many
+// events each has.
+func (d *DB) GetAuthorEventCounts(ctx context.Context) (map[string]int64, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+
+	dl := d.relayDialect()
+	rows, err := relay.QueryContext(ctx, fmt.Sprintf(`SELECT %[1]s, COUNT(*) FROM event GROUP BY %[1]s`, dl.authorColumn()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count events by author: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var pubkeyBytes []byte
+		var count int64
+		if err := rows.Scan(&pubkeyBytes, &count); err != nil {
+			return nil, err
+		}
+		counts[hex.EncodeToString(pubkeyBytes)] = count
+	}
+	return counts, rows.Err()
+}
+
+// PubkeyActivity summarizes what a single pubkey stores on the relay.
+type PubkeyActivity struct {
+	Pubkey         string        `json:"pubkey"`
+	EventCount     int64         `json:"event_count"`
+	EventsByKind   map[int]int64 `json:"events_by_kind"`
+	StorageBytes   int64         `json:"storage_bytes"`
+	FirstEvent     *time.Time    `json:"first_event,omitempty"`
+	LastEvent      *time.Time    `json:"last_event,omitempty"`
+	Mentions       int64         `json:"mentions"` // events tagging the pubkey
+	EventsOverTime []DateCount   `json:"events_over_time"`
+}
+
+// GetPubkeyActivity returns a pubkey's event counts by kind, approximate
+// storage, first and last event times and mentions, all time, with its daily
+// event counts within the range in loc. A zero since starts the daily counts
+// at the pubkey's first event.
+func (d *DB) GetPubkeyActivity(ctx context.Context, pubkey string, since, until time.Time, loc *time.Location) (*PubkeyActivity, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+
+	pubkeyBytes, err := hex.DecodeString(pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %w", err)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	dl := d.relayDialect()
+	rows, err := relay.QueryContext(ctx, dl.bind(fmt.Sprintf(`
+		SELECT kind, COUNT(*), SUM(LENGTH(content)), MIN(%[1]s), MAX(%[1]s)
+		FROM event
+		WHERE %[2]s = ?
+		GROUP BY kind
+	`, dl.createdAt(), dl.authorColumn())), pubkeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pubkey activity: %w", err)
+	}
+	defer rows.Close()
+
+	activity := &PubkeyActivity{
+		Pubkey:         pubkey,
+		EventsByKind:   make(map[int]int64),
+		EventsOverTime: []DateCount{},
+	}
+	for rows.Next() {
+		var kind int
+		var count int64
+		var size, first, last sql.NullInt64
+		if err := rows.Scan(&kind, &count, &size, &first, &last); err != nil {
+			return nil, fmt.Errorf("failed to scan pubkey activity: %w", err)
+		}
+		activity.EventsByKind[kind] = count
+		activity.EventCount += count
+		activity.StorageBytes += size.Int64
+		if t := time.Unix(first.Int64, 0); first.Valid && (activity.FirstEvent == nil || t.Before(*activity.FirstEvent)) {
+			activity.FirstEvent = &t
+		}
+		if t := time.Unix(last.Int64, 0); last.Valid && (activity.LastEvent == nil || t.After(*activity.LastEvent)) {
+			activity.LastEvent = &t
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	activity.Mentions, err = d.CountEvents(ctx, EventFilter{Mentions: pubkey})
+	if err != nil {
+		return nil, err
+	}
+
+	if activity.FirstEvent == nil {
+		return activity, nil
+	}
+	if since.IsZero() {
+		since = *activity.FirstEvent
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	offset, args := offsetExpr(dl, since, until, loc)
+	rows, err = relay.QueryContext(ctx, dl.bind(`
+		SELECT `+dl.dateBucket(false, offset)+` as date, COUNT(*)
+		FROM event
+		WHERE `+dl.authorColumn()+` = ? AND created_at >= `+dl.timeArg()+` AND created_at <= `+dl.timeArg()+`
+		GROUP BY date ORDER BY date
+	`), append(args, pubkeyBytes, since.Unix(), until.Unix())...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pubkey activity over time: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []DateCount
+	for rows.Next() {
+		var dc DateCount
+		if err := rows.Scan(&dc.Date, &dc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, dc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	activity.EventsOverTime = fillAllDays(counts, since, until, loc)
+
+	return activity, nil
+}
+
+// GetTopAuthors returns the pubkeys with the most events.
+func (d *DB) GetTopAuthors(ctx context.Context, limit int) ([]AuthorCount, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	dl := d.relayDialect()
+	rows, err := relay.QueryContext(ctx, dl.bind(fmt.Sprintf(`
+		SELECT %[1]s, COUNT(*) as count
+		FROM event
+		GROUP BY %[1]s
+		ORDER BY count DESC
+		LIMIT ?
+	`, dl.authorColumn())), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top authors: %w", err)
+	}
+	defer rows.Close()
+
+	var authors []AuthorCount
+
+	for rows.Next() {
+		var pubkeyBytes []byte
+		var count int64
+		if err := rows.Scan(&pubkeyBytes, &count); err != nil {
+			return nil, err
+		}
+		authors = append(authors, AuthorCount{
+			Pubkey:     hex.EncodeToString(pubkeyBytes),
+			EventCount: count,
+		})
+	}
+
+	return authors, rows.Err()
+}
+
+// eventOverheadBytes approximates what an event stores beyond its JSON:
+// binary id (32), pubkey (32), sig (64), timestamp (8), kind (4) and its
+// tag rows (~200 avg).
+const eventOverheadBytes = 340
+
+// AuthorStorage is the approximate storage used by one author.
+type AuthorStorage struct {
+	Pubkey string `json:"pubkey"`
+	Events int64  `json:"events"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// KindStorage is the approximate storage used by one event kind.
+type KindStorage struct {
+	Kind   int   `json:"kind"`
+	Events int64 `json:"events"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// StorageBreakdown is the relay's storage by author and by kind, counting
+// each event's JSON length plus eventOverheadBytes.
+type StorageBreakdown struct {
+	TotalEvents int64           `json:"total_events"`
+	TotalBytes  int64           `json:"total_bytes"`
+	Authors     int64           `json:"authors"`
+	TopAuthors  []AuthorStorage `json:"top_authors"` // largest first
+	Kinds       []KindStorage   `json:"kinds"`       // largest first
+	ComputedAt  time.Time       `json:"computed_at"`
+	DurationMs  int64           `json:"duration_ms"`
+}
+
+// GetStorageBreakdown scans every event to total its storage by kind and by
+// author, keeping the topN largest authors. The scan is expensive on large
+// relays; StorageBreakdownService caches the result.
+func (d *DB) GetStorageBreakdown(ctx context.Context, topN int) (*StorageBreakdown, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+	if topN <= 0 {
+		topN = 20
+	}
+
+	start := time.Now()
+	breakdown := &StorageBreakdown{TopAuthors: []AuthorStorage{}, Kinds: []KindStorage{}}
+	dl := d.relayDialect()
+	size := fmt.Sprintf("COALESCE(SUM(LENGTH(content)), 0) + COUNT(*) * %d", eventOverheadBytes)
+
+	rows, err := relay.QueryContext(ctx, `
+		SELECT kind, COUNT(*), `+size+` AS bytes
+		FROM event
+		GROUP BY kind
+		ORDER BY bytes DESC, kind
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage by kind: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k KindStorage
+		if err := rows.Scan(&k.Kind, &k.Events, &k.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan storage by kind: %w", err)
+		}
+		breakdown.Kinds = append(breakdown.Kinds, k)
+		breakdown.TotalEvents += k.Events
+		breakdown.TotalBytes += k.Bytes
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Every author is read to count them, but only the largest are kept
+	rows, err = relay.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %[1]s, COUNT(*), %[2]s AS bytes
+		FROM event
+		GROUP BY %[1]s
+		ORDER BY bytes DESC, %[1]s
+	`, dl.authorColumn(), size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage by author: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		breakdown.Authors++
+		if len(breakdown.TopAuthors) >= topN {
+			continue
+		}
+		var pubkeyBytes []byte
+		var a AuthorStorage
+		if err := rows.Scan(&pubkeyBytes, &a.Events, &a.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan storage by author: %w", err)
+		}
+		a.Pubkey = hex.EncodeToString(pubkeyBytes)
+		breakdown.TopAuthors = append(breakdown.TopAuthors, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	breakdown.ComputedAt = time.Now()
+	breakdown.DurationMs = time.Since(start).Milliseconds()
+	return breakdown, nil
+}
+
+// CountEventsBefore counts events created before the given timestamp.
+func (d *DB) CountEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return 0, fmt.Errorf("relay database not connected")
+	}
+
+	var count int64
+	dl := d.relayDialect()
+	err := relay.QueryRowContext(ctx,
+		dl.bind("SELECT COUNT(*) FROM event WHERE created_at < "+dl.timeArg()),
+		before.Unix(),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count events: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountEventsBeforeWithExceptions counts events created before the given timestamp,
+// excluding events that match the exceptions (same logic as DeleteEventsBefore).
+func (d *DB) CountEventsBeforeWithExceptions(ctx context.Context, before time.Time, exceptions []string, operatorPubkey string) (int64, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return 0, fmt.Errorf("relay database not connected")
+	}
+
+	// If no exceptions, use simpler query
+	if len(exceptions) == 0 {
+		return d.CountEventsBefore(ctx, before)
+	}
+
+	// Build query with exceptions (mirrors DeleteEventsBefore logic)
+	dl := d.relayDialect()
+	query := "SELECT COUNT(*) FROM event WHERE created_at < " + dl.timeArg()
+	args := []interface{}{before.Unix()}
+
+	// Parse exceptions
+	var kindExceptions []int
+	var pubkeyExceptions [][]byte
+
+	for _, exc := range exceptions {
+		if strings.HasPrefix(exc, "kind:") {
+			var kind int
+			fmt.Sscanf(exc, "kind:%d", &kind)
+			kindExceptions = append(kindExceptions, kind)
+		} else if strings.HasPrefix(exc, "pubkey:") {
+			pubkey := strings.TrimPrefix(exc, "pubkey:")
+			if pubkey == "operator" && operatorPubkey != "" {
+				pubkeyBytes, err := hex.DecodeString(operatorPubkey)
+				if err == nil {
+					pubkeyExceptions = append(pubkeyExceptions, pubkeyBytes)
+				}
+			} else {
+				pubkeyBytes, err := hex.DecodeString(pubkey)
+				if err == nil {
+					pubkeyExceptions = append(pubkeyExceptions, pubkeyBytes)
+				}
+			}
+		}
+	}
+
+	// Add kind exceptions
+	if len(kindExceptions) > 0 {
 		placeholders := make([]string, len(kindExceptions))
 		for i, kind := range kindExceptions {
 			placeholders[i] = "?"
@@ -413,11 +1265,11 @@ func (d *DB) CountEventsBeforeWithExceptions(ctx context.Context, before time.Ti
 			placeholders[i] = "?"
 			args = append(args, pubkey)
 		}
-		query += fmt.Sprintf(" AND author NOT IN (%s)", strings.Join(placeholders, ","))
+		query += fmt.Sprintf(" AND %s NOT IN (%s)", dl.authorColumn(), strings.Join(placeholders, ","))
 	}
 
 	var count int64
-	err := d.RelayDB.QueryRowContext(ctx, query, args...).Scan(&count)
+	err := relay.QueryRowContext(ctx, dl.bind(query), args...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count events: %w", err)
 	}
@@ -425,16 +1277,38 @@ func (d *DB) CountEventsBeforeWithExceptions(ctx context.Context, before time.Ti
 	return count, nil
 }
 
+// CountRetentionScope counts the events a retention rule would delete and
+// the bytes they take up.
+func (d *DB) CountRetentionScope(ctx context.Context, scope RetentionScope, operatorPubkey string) (int64, int64, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return 0, 0, fmt.Errorf("relay database not connected")
+	}
+
+	dl := d.relayDialect()
+	where, args := scope.where(dl, operatorPubkey)
+	var count, size int64
+	err := relay.QueryRowContext(ctx,
+		dl.bind("SELECT COUNT(*), COALESCE(SUM(LENGTH(content)), 0) FROM event WHERE "+where), args...,
+	).Scan(&count, &size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count events: %w", err)
+	}
+
+	return count, size, nil
+}
+
 // EstimateEventSize estimates the average size of an event in bytes.
 // This is a rough estimate used for storage calculations.
 func (d *DB) EstimateEventSize(ctx context.Context) (int64, error) {
-	if d.RelayDB == nil {
+	relay := d.RelayDB()
+	if relay == nil {
 		return 0, fmt.Errorf("relay database not connected")
 	}
 
 	// Get average content length plus overhead for other fields
 	var avgContentLen sql.NullFloat64
-	err := d.RelayDB.QueryRowContext(ctx,
+	err := relay.QueryRowContext(ctx,
 		"SELECT AVG(LENGTH(content)) FROM event",
 	).Scan(&avgContentLen)
 	if err != nil {
@@ -446,9 +1320,7 @@ func (d *DB) EstimateEventSize(ctx context.Context) (int64, error) {
 		return 500, nil
 	}
 
-	// Add overhead for id (32), pubkey (32), sig (64), timestamp (8), kind (4), tags (~200 avg)
-	overhead := int64(340)
-	return int64(avgContentLen.Float64) + overhead, nil
+	return int64(avgContentLen.Float64) + eventOverheadBytes, nil
 }
 
 // Helper functions
@@ -536,11 +1408,34 @@ type AuthorCount struct {
 	EventCount int64  `json:"event_count"`
 }
 
-// GetEventsOverTime returns event counts grouped by date within a time range.
-// If hourly is true, groups by hour and returns all 24 hours for the day.
-// The loc parameter specifies the timezone for formatting timestamps.
-func (d *DB) GetEventsOverTime(ctx context.Context, since, until time.Time, hourly bool, loc *time.Location) ([]DateCount, error) {
-	if d.RelayDB == nil {
+// Time bucket granularities for GetEventsOverTime.
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// ValidBucket reports whether bucket is a GetEventsOverTime granularity.
+func ValidBucket(bucket string) bool {
+	switch bucket {
+	case BucketHour, BucketDay, BucketWeek, BucketMonth:
+		return true
+	}
+	return false
+}
+
+// GetEventsOverTime returns event counts grouped by time within a range, in
+// buckets of an hour, day, week or month in loc. Each event is shifted by
+// the UTC offset in effect when it was created, so buckets stay on local
+// midnight across daylight saving changes. Hourly counts cover the day of
+// since; a day when the clocks change has 23 or 25 hours, and the repeated
+// hour is counted in one bucket. Weeks start on Monday and are labelled
+// with that date, months as YYYY-MM. Buckets without events are filled with
+// zeros; a zero since starts at the first event.
+func (d *DB) GetEventsOverTime(ctx context.Context, since, until time.Time, bucket string, loc *time.Location) ([]DateCount, error) {
+	relay := d.RelayDB()
+	if relay == nil {
 		return nil, fmt.Errorf("relay database not connected")
 	}
 
@@ -548,44 +1443,33 @@ func (d *DB) GetEventsOverTime(ctx context.Context, since, until time.Time, hour
 	if loc == nil {
 		loc = time.UTC
 	}
-
-	// Calculate timezone offset in seconds
-	// We use the 'since' time to get the offset (handles DST correctly for that date)
-	_, offset := since.In(loc).Zone()
-
-	var query string
-	args := []interface{}{}
-
-	if hourly {
-		// Apply timezone offset to created_at before formatting
-		query = `
-			SELECT strftime('%Y-%m-%d %H:00', datetime(created_at + ?, 'unixepoch')) as date, COUNT(*) as count
-			FROM event
-			WHERE 1=1
-		`
-		args = append(args, offset)
-	} else {
-		// Apply timezone offset to created_at before formatting
-		query = `
-			SELECT DATE(datetime(created_at + ?, 'unixepoch')) as date, COUNT(*) as count
-			FROM event
-			WHERE 1=1
-		`
-		args = append(args, offset)
+	if bucket == "" {
+		bucket = BucketDay
+	}
+	if !ValidBucket(bucket) {
+		return nil, fmt.Errorf("invalid bucket %q", bucket)
 	}
 
-	if !since.IsZero() {
-		query += " AND created_at >= ?"
-		args = append(args, since.Unix())
+	dl := d.relayDialect()
+	since, until, ok, err := d.bucketRange(ctx, relay, since, until)
+	if err != nil {
+		return nil, err
 	}
-	if !until.IsZero() {
-		query += " AND created_at <= ?"
-		args = append(args, until.Unix())
+	if !ok {
+		return []DateCount{}, nil
 	}
 
-	query += " GROUP BY date ORDER BY date"
+	hourly := bucket == BucketHour
+	offset, args := offsetExpr(dl, since, until, loc)
+	query := `
+		SELECT ` + dl.dateBucket(hourly, offset) + ` as date, COUNT(*) as count
+		FROM event
+		WHERE created_at >= ` + dl.timeArg() + ` AND created_at <= ` + dl.timeArg() + `
+		GROUP BY date ORDER BY date
+	`
+	args = append(args, since.Unix(), until.Unix())
 
-	rows, err := d.RelayDB.QueryContext(ctx, query, args...)
+	rows, err := relay.QueryContext(ctx, dl.bind(query), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events over time: %w", err)
 	}
@@ -604,34 +1488,275 @@ func (d *DB) GetEventsOverTime(ctx context.Context, since, until time.Time, hour
 		return nil, err
 	}
 
-	// For hourly data, fill in all 24 hours with zeros for missing hours
-	// For daily data, fill in all days in the range with zeros for missing days
-	if hourly && !since.IsZero() {
-		results = fillAllHours(results, since, loc)
-	} else if !hourly && !since.IsZero() && !until.IsZero() {
-		results = fillAllDays(results, since, until, loc)
+	if hourly {
+		return fillAllHours(results, since, loc), nil
+	}
+	results = fillAllDays(results, since, until, loc)
+	if bucket != BucketDay {
+		results = rollupDays(results, bucket)
 	}
-
 	return results, nil
 }
 
-// fillAllHours ensures all 24 hours are represented in the results, filling missing hours with zeros.
-func fillAllHours(data []DateCount, day time.Time, loc *time.Location) []DateCount {
-	// Create a map of existing data
+// bucketRange fills in a zero since with the first event's time and a zero
+// until with now. It reports false if there are no events to start from.
+func (d *DB) bucketRange(ctx context.Context, relay *sql.DB, since, until time.Time) (time.Time, time.Time, bool, error) {
+	if since.IsZero() {
+		var first sql.NullInt64
+		if err := relay.QueryRowContext(ctx, "SELECT MIN("+d.relayDialect().createdAt()+") FROM event").Scan(&first); err != nil {
+			return since, until, false, fmt.Errorf("failed to find first event: %w", err)
+		}
+		if !first.Valid {
+			return since, until, false, nil
+		}
+		since = time.Unix(first.Int64, 0)
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+	return since, until, true, nil
+}
+
+// KindTrends is event counts per kind in each time bucket.
+type KindTrends struct {
+	Buckets []string        `json:"buckets"` // Labelled as GetEventsOverTime labels them
+	Kinds   map[int][]int64 `json:"kinds"`   // Counts per kind, one per bucket
+}
+
+// GetKindTrends returns event counts per kind in buckets of an hour, day,
+// week or month in loc, bucketed the same way as GetEventsOverTime, from a
+// single query grouped by bucket and kind.
+func (d *DB) GetKindTrends(ctx context.Context, since, until time.Time, bucket string, loc *time.Location) (*KindTrends, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	if bucket == "" {
+		bucket = BucketDay
+	}
+	if !ValidBucket(bucket) {
+		return nil, fmt.Errorf("invalid bucket %q", bucket)
+	}
+
+	trends := &KindTrends{Buckets: []string{}, Kinds: make(map[int][]int64)}
+	dl := d.relayDialect()
+	since, until, ok, err := d.bucketRange(ctx, relay, since, until)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return trends, nil
+	}
+
+	hourly := bucket == BucketHour
+	trends.Buckets = bucketLabels(since, until, bucket, loc)
+	index := make(map[string]int, len(trends.Buckets))
+	for i, label := range trends.Buckets {
+		index[label] = i
+	}
+
+	offset, args := offsetExpr(dl, since, until, loc)
+	rows, err := relay.QueryContext(ctx, dl.bind(`
+		SELECT `+dl.dateBucket(hourly, offset)+` as date, kind, COUNT(*)
+		FROM event
+		WHERE created_at >= `+dl.timeArg()+` AND created_at <= `+dl.timeArg()+`
+		GROUP BY date, kind
+	`), append(args, since.Unix(), until.Unix())...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query kind trends: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		var kind int
+		var count int64
+		if err := rows.Scan(&date, &kind, &count); err != nil {
+			return nil, err
+		}
+		if bucket == BucketWeek || bucket == BucketMonth {
+			date = dayBucket(date, bucket)
+		}
+		i, ok := index[date]
+		if !ok {
+			continue
+		}
+		if trends.Kinds[kind] == nil {
+			trends.Kinds[kind] = make([]int64, len(trends.Buckets))
+		}
+		trends.Kinds[kind][i] += count
+	}
+	return trends, rows.Err()
+}
+
+// AuthorGrowth is how many authors posted for the first time in each time
+// bucket, and how many had posted by its end.
+type AuthorGrowth struct {
+	Before int64               `json:"before"` // Authors first seen before the range
+	Points []AuthorGrowthPoint `json:"points"`
+}
+
+// AuthorGrowthPoint is one time bucket of author growth.
+type AuthorGrowthPoint struct {
+	Date  string `json:"date"`
+	New   int64  `json:"new"`   // Authors whose first event is in the bucket
+	Total int64  `json:"total"` // Authors seen by the end of the bucket
+}
+
+// GetAuthorGrowth returns the number of authors whose first event on the
+// relay falls in each bucket of an hour, day, week or month in loc, with the
+// running total. A zero since starts at the first event.
+func (d *DB) GetAuthorGrowth(ctx context.Context, since, until time.Time, bucket string, loc *time.Location) (*AuthorGrowth, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	if bucket == "" {
+		bucket = BucketWeek
+	}
+	if !ValidBucket(bucket) {
+		return nil, fmt.Errorf("invalid bucket %q", bucket)
+	}
+
+	growth := &AuthorGrowth{Points: []AuthorGrowthPoint{}}
+	dl := d.relayDialect()
+	since, until, ok, err := d.bucketRange(ctx, relay, since, until)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return growth, nil
+	}
+
+	firstSeen := `(SELECT ` + dl.authorColumn() + `, MIN(created_at) AS created_at FROM event GROUP BY ` + dl.authorColumn() + `) AS first_seen`
+	if err := relay.QueryRowContext(ctx, dl.bind(`
+		SELECT COUNT(*) FROM `+firstSeen+` WHERE created_at < `+dl.timeArg()+`
+	`), since.Unix()).Scan(&growth.Before); err != nil {
+		return nil, fmt.Errorf("failed to count earlier authors: %w", err)
+	}
+
+	labels := bucketLabels(since, until, bucket, loc)
+	index := make(map[string]int, len(labels))
+	for i, label := range labels {
+		index[label] = i
+		growth.Points = append(growth.Points, AuthorGrowthPoint{Date: label})
+	}
+
+	offset, args := offsetExpr(dl, since, until, loc)
+	rows, err := relay.QueryContext(ctx, dl.bind(`
+		SELECT `+dl.dateBucket(bucket == BucketHour, offset)+` as date, COUNT(*)
+		FROM `+firstSeen+`
+		WHERE created_at >= `+dl.timeArg()+` AND created_at <= `+dl.timeArg()+`
+		GROUP BY date
+	`), append(args, since.Unix(), until.Unix())...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query author growth: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		var count int64
+		if err := rows.Scan(&date, &count); err != nil {
+			return nil, err
+		}
+		if bucket == BucketWeek || bucket == BucketMonth {
+			date = dayBucket(date, bucket)
+		}
+		if i, ok := index[date]; ok {
+			growth.Points[i].New += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	total := growth.Before
+	for i := range growth.Points {
+		total += growth.Points[i].New
+		growth.Points[i].Total = total
+	}
+	return growth, nil
+}
+
+// bucketLabels returns the label of every bucket between since and until:
+// the hours of since's day, or the days, weeks or months of the range.
+func bucketLabels(since, until time.Time, bucket string, loc *time.Location) []string {
+	var buckets []DateCount
+	if bucket == BucketHour {
+		buckets = fillAllHours(nil, since, loc)
+	} else {
+		buckets = fillAllDays(nil, since, until, loc)
+		if bucket != BucketDay {
+			buckets = rollupDays(buckets, bucket)
+		}
+	}
+	labels := make([]string, len(buckets))
+	for i, b := range buckets {
+		labels[i] = b.Date
+	}
+	return labels
+}
+
+// offsetExpr returns a SQL expression for loc's UTC offset in seconds when
+// each event between since and until was created, and its arguments. Within
+// a single offset it is a plain argument; otherwise a CASE switches offset
+// at each transition.
+func offsetExpr(dl relayDialect, since, until time.Time, loc *time.Location) (string, []interface{}) {
+	_, offset := since.In(loc).Zone()
+	var expr strings.Builder
+	var args []interface{}
+	for t := since.In(loc); ; {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(until) || !end.After(t) {
+			break
+		}
+		t = end
+		_, next := t.Zone()
+		if next == offset {
+			continue
+		}
+		if expr.Len() == 0 {
+			expr.WriteString("CASE")
+		}
+		expr.WriteString(" WHEN created_at < " + dl.timeArg() + " THEN ?")
+		args = append(args, t.Unix(), offset)
+		offset = next
+	}
+	if expr.Len() == 0 {
+		return "?", []interface{}{offset}
+	}
+	expr.WriteString(" ELSE ? END")
+	return "(" + expr.String() + ")", append(args, offset)
+}
+
+// fillAllHours ensures every hour of the day is represented in the results,
+// filling missing hours with zeros.
+func fillAllHours(data []DateCount, day time.Time, loc *time.Location) []DateCount {
+	// Create a map of existing data
 	existing := make(map[string]int64)
 	for _, d := range data {
 		existing[d.Date] = d.Count
 	}
 
-	// Generate all 24 hours for the day in the specified timezone
+	// Step through the day in the specified timezone, which has 23 or 25
+	// hours when the clocks change
 	dayInLoc := day.In(loc)
 	dayStart := time.Date(dayInLoc.Year(), dayInLoc.Month(), dayInLoc.Day(), 0, 0, 0, 0, loc)
+	dayEnd := dayStart.AddDate(0, 0, 1)
 	var filled []DateCount
-	for h := 0; h < 24; h++ {
-		hourTime := dayStart.Add(time.Duration(h) * time.Hour)
-		dateStr := hourTime.Format("2006-01-02 15:00")
-		count := existing[dateStr]
-		filled = append(filled, DateCount{Date: dateStr, Count: count})
+	for hourTime := dayStart; hourTime.Before(dayEnd); hourTime = hourTime.Add(time.Hour) {
+		dateStr := hourTime.In(loc).Format("2006-01-02 15:00")
+		if len(filled) > 0 && filled[len(filled)-1].Date == dateStr {
+			continue
+		}
+		filled = append(filled, DateCount{Date: dateStr, Count: existing[dateStr]})
 	}
 
 	return filled
@@ -661,27 +1786,60 @@ func fillAllDays(data []DateCount, since, until time.Time, loc *time.Location) [
 	return filled
 }
 
+// rollupDays sums consecutive daily counts into weeks, labelled with their
+// Monday, or months, labelled YYYY-MM.
+func rollupDays(days []DateCount, bucket string) []DateCount {
+	var rolled []DateCount
+	for _, day := range days {
+		label := dayBucket(day.Date, bucket)
+		if label == "" {
+			continue
+		}
+		if len(rolled) > 0 && rolled[len(rolled)-1].Date == label {
+			rolled[len(rolled)-1].Count += day.Count
+			continue
+		}
+		rolled = append(rolled, DateCount{Date: label, Count: day.Count})
+	}
+	return rolled
+}
+
+// dayBucket returns the label of the week or month a YYYY-MM-DD day falls
+// in, or "" if it is not a day.
+func dayBucket(day, bucket string) string {
+	date, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return ""
+	}
+	if bucket == BucketWeek {
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7).Format("2006-01-02")
+	}
+	return date.Format("2006-01")
+}
+
 // GetEventsByKindInRange returns event counts by kind within a time range.
 func (d *DB) GetEventsByKindInRange(ctx context.Context, since, until time.Time) (map[int]int64, error) {
-	if d.RelayDB == nil {
+	relay := d.RelayDB()
+	if relay == nil {
 		return nil, fmt.Errorf("relay database not connected")
 	}
 
+	dl := d.relayDialect()
 	query := `SELECT kind, COUNT(*) as count FROM event WHERE 1=1`
 	args := []interface{}{}
 
 	if !since.IsZero() {
-		query += " AND created_at >= ?"
+		query += " AND created_at >= " + dl.timeArg()
 		args = append(args, since.Unix())
 	}
 	if !until.IsZero() {
-		query += " AND created_at <= ?"
+		query += " AND created_at <= " + dl.timeArg()
 		args = append(args, until.Unix())
 	}
 
 	query += " GROUP BY kind ORDER BY count DESC"
 
-	rows, err := d.RelayDB.QueryContext(ctx, query, args...)
+	rows, err := relay.QueryContext(ctx, dl.bind(query), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events by kind: %w", err)
 	}
@@ -702,7 +1860,8 @@ func (d *DB) GetEventsByKindInRange(ctx context.Context, since, until time.Time)
 
 // GetTopAuthorsInRange returns the top authors by event count within a time range.
 func (d *DB) GetTopAuthorsInRange(ctx context.Context, limit int, since, until time.Time) ([]AuthorCount, error) {
-	if d.RelayDB == nil {
+	relay := d.RelayDB()
+	if relay == nil {
 		return nil, fmt.Errorf("relay database not connected")
 	}
 
@@ -713,22 +1872,23 @@ func (d *DB) GetTopAuthorsInRange(ctx context.Context, limit int, since, until t
 		limit = 100
 	}
 
-	query := `SELECT author, COUNT(*) as count FROM event WHERE 1=1`
+	dl := d.relayDialect()
+	query := `SELECT ` + dl.authorColumn() + `, COUNT(*) as count FROM event WHERE 1=1`
 	args := []interface{}{}
 
 	if !since.IsZero() {
-		query += " AND created_at >= ?"
+		query += " AND created_at >= " + dl.timeArg()
 		args = append(args, since.Unix())
 	}
 	if !until.IsZero() {
-		query += " AND created_at <= ?"
+		query += " AND created_at <= " + dl.timeArg()
 		args = append(args, until.Unix())
 	}
 
-	query += " GROUP BY author ORDER BY count DESC LIMIT ?"
+	query += " GROUP BY " + dl.authorColumn() + " ORDER BY count DESC LIMIT ?"
 	args = append(args, limit)
 
-	rows, err := d.RelayDB.QueryContext(ctx, query, args...)
+	rows, err := relay.QueryContext(ctx, dl.bind(query), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query top authors: %w", err)
 	}
@@ -750,37 +1910,421 @@ func (d *DB) GetTopAuthorsInRange(ctx context.Context, limit int, since, until t
 	return authors, rows.Err()
 }
 
-// CountEvents counts events matching the filter (for export progress tracking).
-func (d *DB) CountEvents(ctx context.Context, filter EventFilter) (int64, error) {
-	if d.RelayDB == nil {
-		return 0, fmt.Errorf("relay database not connected")
+// NoteEngagement is a note with the reactions and replies it received from
+// other pubkeys.
+type NoteEngagement struct {
+	Event
+	Reactions int64 `json:"reactions"`
+	Replies   int64 `json:"replies"`
+}
+
+// MemberHighlights summarizes engagement with a pubkey's notes.
+type MemberHighlights struct {
+	Pubkey      string           `json:"pubkey"`
+	Notes       int64            `json:"notes"`
+	Reactions   int64            `json:"reactions"`
+	Replies     int64            `json:"replies"`
+	MostReacted []NoteEngagement `json:"most_reacted"`
+	MostReplied []NoteEngagement `json:"most_replied"`
+}
+
+// GetMemberHighlights returns a pubkey's most-reacted and most-replied kind 1
+// notes created within the range, up to limit each. Reactions (kind 7) and
+// replies (kind 1) are found through the relay's tag index by their "e" tags;
+// engagement from the author themselves is not counted.
+func (d *DB) GetMemberHighlights(ctx context.Context, pubkey string, since, until time.Time, limit int) (*MemberHighlights, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
 	}
 
-	// Build count query with same WHERE clauses as GetEvents
-	query := `SELECT COUNT(*) FROM event WHERE 1=1`
-	args := []interface{}{}
+	// Joins on the SQLite tag table's text values
+	if d.IsRelayPostgres() {
+		return nil, ErrUnsupportedOnPostgres
+	}
 
-	if len(filter.Kinds) > 0 {
-		placeholders := make([]string, len(filter.Kinds))
-		for i, kind := range filter.Kinds {
-			placeholders[i] = "?"
-			args = append(args, kind)
+	pubkeyBytes, err := hex.DecodeString(pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %w", err)
+	}
+
+	if limit <= 0 {
+		limit = 5
+	}
+
+	query := `
+		SELECT n.event_hash, n.author, n.created_at, n.kind, n.content,
+			COUNT(DISTINCT CASE WHEN r.kind = 7 THEN r.id END),
+			COUNT(DISTINCT CASE WHEN r.kind = 1 THEN r.id END)
+		FROM event n
+		LEFT JOIN tag t ON t.name = 'e' AND t.value = lower(hex(n.event_hash))
+		LEFT JOIN event r ON r.id = t.event_id AND r.kind IN (1, 7) AND r.author != n.author
+			AND (r.hidden IS NULL OR r.hidden = 0)
+		WHERE n.author = ? AND n.kind = 1 AND (n.hidden IS NULL OR n.hidden = 0)`
+	args := []interface{}{pubkeyBytes}
+
+	if !since.IsZero() {
+		query += " AND n.created_at >= ?"
+		args = append(args, since.Unix())
+	}
+	if !until.IsZero() {
+		query += " AND n.created_at <= ?"
+		args = append(args, until.Unix())
+	}
+	query += " GROUP BY n.id"
+
+	rows, err := relay.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query member highlights: %w", err)
+	}
+	defer rows.Close()
+
+	highlights := &MemberHighlights{
+		Pubkey:      pubkey,
+		MostReacted: []NoteEngagement{},
+		MostReplied: []NoteEngagement{},
+	}
+	var notes []NoteEngagement
+	for rows.Next() {
+		var idBytes, authorBytes []byte
+		var createdAt int64
+		var kind int
+		var contentJSON string
+		var note NoteEngagement
+		if err := rows.Scan(&idBytes, &authorBytes, &createdAt, &kind, &contentJSON, &note.Reactions, &note.Replies); err != nil {
+			return nil, fmt.Errorf("failed to scan member highlight: %w", err)
 		}
-		query += fmt.Sprintf(" AND kind IN (%s)", strings.Join(placeholders, ","))
+		event, err := parseEventFromDB(idBytes, authorBytes, createdAt, kind, contentJSON)
+		if err != nil {
+			return nil, err
+		}
+		note.Event = *event
+
+		highlights.Notes++
+		highlights.Reactions += note.Reactions
+		highlights.Replies += note.Replies
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	if !filter.Since.IsZero() {
-		query += " AND created_at >= ?"
-		args = append(args, filter.Since.Unix())
+	highlights.MostReacted = topNotes(notes, limit, func(n NoteEngagement) int64 { return n.Reactions })
+	highlights.MostReplied = topNotes(notes, limit, func(n NoteEngagement) int64 { return n.Replies })
+	return highlights, nil
+}
+
+// topNotes returns up to limit notes with a non-zero score, highest first and
+// newest first among ties.
+func topNotes(notes []NoteEngagement, limit int, score func(NoteEngagement) int64) []NoteEngagement {
+	ranked := make([]NoteEngagement, 0, len(notes))
+	for _, n := range notes {
+		if score(n) > 0 {
+			ranked = append(ranked, n)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if score(ranked[i]) != score(ranked[j]) {
+			return score(ranked[i]) > score(ranked[j])
+		}
+		return ranked[i].CreatedAt.After(ranked[j].CreatedAt)
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
 	}
+	return ranked
+}
 
-	if !filter.Until.IsZero() {
-		query += " AND created_at <= ?"
-		args = append(args, filter.Until.Unix())
+// ErrNoTagIndex is returned by queries that need nostr-rs-relay's tag table
+// when the relay database has none.
+var ErrNoTagIndex = errors.New("relay database has no tag index")
+
+// Interaction is how often one pubkey engaged with another.
+type Interaction struct {
+	Pubkey    string `json:"pubkey"`
+	Mentions  int64  `json:"mentions"`  // Notes tagging the pubkey that are not replies to it
+	Replies   int64  `json:"replies"`   // Notes replying to one of its events
+	Reactions int64  `json:"reactions"` // Reactions to its events
+	Total     int64  `json:"total"`
+}
+
+// Interactions is who engaged with a pubkey most, with the totals across
+// everyone.
+type Interactions struct {
+	Pubkey      string        `json:"pubkey"`
+	Mentions    int64         `json:"mentions"`
+	Replies     int64         `json:"replies"`
+	Reactions   int64         `json:"reactions"`
+	Pubkeys     int64         `json:"pubkeys"` // Distinct pubkeys that engaged
+	Interactors []Interaction `json:"interactors"`
+}
+
+// GetInteractions returns the pubkeys that mentioned, replied to and reacted
+// to a pubkey most within the range, by total, from the tag table. A kind-1
+// note with an e tag for one of the pubkey's stored events is a reply and
+// one with only its p tag a mention; a reaction counts if it has either.
+// The pubkey's own events and hidden events are not counted.
+func (d *DB) GetInteractions(ctx context.Context, pubkey string, since, until time.Time, limit int) (*Interactions, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+
+	// Joins on the SQLite tag table's values
+	if d.IsRelayPostgres() {
+		return nil, ErrUnsupportedOnPostgres
+	}
+	if !d.relayDialect().hasTable(ctx, relay, "tag") {
+		return nil, ErrNoTagIndex
+	}
+
+	pubkeyBytes, err := hex.DecodeString(pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %w", err)
+	}
+	if limit <= 0 {
+		limit = 20
 	}
 
+	rangeCond := " AND (r.hidden IS NULL OR r.hidden = 0)"
+	var rangeArgs []interface{}
+	if !since.IsZero() {
+		rangeCond += " AND r.created_at >= ?"
+		rangeArgs = append(rangeArgs, since.Unix())
+	}
+	if !until.IsZero() {
+		rangeCond += " AND r.created_at <= ?"
+		rangeArgs = append(rangeArgs, until.Unix())
+	}
+
+	// e tags hold event IDs as bytes in value_hex, or as text in older
+	// nostr-rs-relay databases
+	const tagsOwnEvent = `t.name = 'e' AND (t.value_hex = n.event_hash OR t.value = lower(hex(n.event_hash)))`
+
+	byPubkey := make(map[string]*Interaction)
+	count := func(author []byte, kind int, n int64, reply bool) {
+		key := hex.EncodeToString(author)
+		in := byPubkey[key]
+		if in == nil {
+			in = &Interaction{Pubkey: key}
+			byPubkey[key] = in
+		}
+		switch {
+		case kind == 7:
+			in.Reactions += n
+		case reply:
+			in.Replies += n
+		default:
+			in.Mentions += n
+		}
+		in.Total += n
+	}
+	scan := func(query string, reply bool, args ...interface{}) error {
+		rows, err := relay.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query interactions: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var author []byte
+			var kind int
+			var n int64
+			if err := rows.Scan(&author, &kind, &n); err != nil {
+				return err
+			}
+			count(author, kind, n, reply)
+		}
+		return rows.Err()
+	}
+
+	// Replies and reactions to the pubkey's stored events
+	err = scan(`
+		SELECT r.author, r.kind, COUNT(DISTINCT r.id)
+		FROM event n
+		JOIN tag t ON `+tagsOwnEvent+`
+		JOIN event r ON r.id = t.event_id
+		WHERE n.author = ? AND r.author != ? AND r.kind IN (1, 7)`+rangeCond+`
+		GROUP BY r.author, r.kind
+	`, true, append([]interface{}{pubkeyBytes, pubkeyBytes}, rangeArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mentions, and reactions to events the relay doesn't have
+	err = scan(`
+		SELECT r.author, r.kind, COUNT(DISTINCT r.id)
+		FROM tag p
+		JOIN event r ON r.id = p.event_id
+		WHERE p.name = 'p' AND (p.value_hex = ? OR p.value = ?)
+			AND r.author != ? AND r.kind IN (1, 7)`+rangeCond+`
+			AND NOT EXISTS (
+				SELECT 1 FROM tag t JOIN event n ON `+tagsOwnEvent+`
+				WHERE t.event_id = r.id AND n.author = ?
+			)
+		GROUP BY r.author, r.kind
+	`, false, append(append([]interface{}{pubkeyBytes, pubkey, pubkeyBytes}, rangeArgs...), pubkeyBytes)...)
+	if err != nil {
+		return nil, err
+	}
+
+	interactions := &Interactions{Pubkey: pubkey, Interactors: []Interaction{}}
+	for _, in := range byPubkey {
+		interactions.Mentions += in.Mentions
+		interactions.Replies += in.Replies
+		interactions.Reactions += in.Reactions
+		interactions.Interactors = append(interactions.Interactors, *in)
+	}
+	interactions.Pubkeys = int64(len(byPubkey))
+	sort.Slice(interactions.Interactors, func(i, j int) bool {
+		a, b := interactions.Interactors[i], interactions.Interactors[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Pubkey < b.Pubkey
+	})
+	if len(interactions.Interactors) > limit {
+		interactions.Interactors = interactions.Interactors[:limit]
+	}
+	return interactions, nil
+}
+
+// EventThread is an event with the notes around it in its thread.
+type EventThread struct {
+	Event   *Event   `json:"event"`
+	Parents []Event  `json:"parents"` // root first, ending with the direct parent
+	Replies []Event  `json:"replies"` // direct replies, oldest first
+	Missing []string `json:"missing"` // referenced ancestors the relay doesn't have
+}
+
+// maxThreadDepth bounds how many ancestors GetEventThread follows.
+const maxThreadDepth = 50
+
+// GetEventThread returns an event with its ancestors and its direct kind 1
+// replies, resolved through NIP-10 "e" tags. Replies are found among the
+// newest replyLimit notes referencing the event, which include deeper replies
+// that tag it as their root. Returns nil if the event is not found.
+func (d *DB) GetEventThread(ctx context.Context, id string, replyLimit int) (*EventThread, error) {
+	event, err := d.GetEvent(ctx, id)
+	if err != nil || event == nil {
+		return nil, err
+	}
+
+	thread := &EventThread{Event: event, Parents: []Event{}, Replies: []Event{}, Missing: []string{}}
+	seen := map[string]bool{event.ID: true}
+	current := event
+	for len(thread.Parents) < maxThreadDepth {
+		parentID, rootID := threadParent(current.Tags)
+		if parentID == "" || seen[parentID] {
+			break
+		}
+		seen[parentID] = true
+
+		parent, err := d.GetEvent(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			thread.Parents = append(thread.Parents, *parent)
+			current = parent
+			continue
+		}
+
+		// The chain is broken; the root may still be on the relay
+		thread.Missing = append(thread.Missing, parentID)
+		if rootID != "" && !seen[rootID] {
+			root, err := d.GetEvent(ctx, rootID)
+			if err != nil {
+				return nil, err
+			}
+			if root != nil {
+				thread.Parents = append(thread.Parents, *root)
+			} else {
+				thread.Missing = append(thread.Missing, rootID)
+			}
+		}
+		break
+	}
+	for i, j := 0, len(thread.Parents)-1; i < j; i, j = i+1, j-1 {
+		thread.Parents[i], thread.Parents[j] = thread.Parents[j], thread.Parents[i]
+	}
+
+	replies, err := d.GetEvents(ctx, EventFilter{
+		Kinds: []int{1},
+		Tags:  map[string][]string{"e": {event.ID}},
+		Limit: replyLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := len(replies) - 1; i >= 0; i-- {
+		if parentID, _ := threadParent(replies[i].Tags); parentID == event.ID {
+			thread.Replies = append(thread.Replies, replies[i])
+		}
+	}
+
+	return thread, nil
+}
+
+// threadParent returns the event a note replies to and its thread root from
+// its NIP-10 "e" tags. Marked tags name them directly, a root alone meaning a
+// direct reply to the root; unmarked tags are positional, the first being the
+// root and the last the parent. "mention" tags are not part of the thread.
+func threadParent(tags [][]string) (parent, root string) {
+	var positional []string
+	marked := false
+	for _, tag := range tags {
+		if len(tag) < 2 || tag[0] != "e" || len(tag[1]) != 64 {
+			continue
+		}
+		id := strings.ToLower(tag[1])
+		if _, err := hex.DecodeString(id); err != nil {
+			continue
+		}
+
+		marker := ""
+		if len(tag) >= 4 {
+			marker = tag[3]
+		}
+		switch marker {
+		case "reply":
+			parent, marked = id, true
+		case "root":
+			root, marked = id, true
+		case "mention":
+		default:
+			positional = append(positional, id)
+		}
+	}
+
+	if marked {
+		if parent == "" {
+			parent = root
+		}
+		return parent, root
+	}
+	if len(positional) > 0 {
+		return positional[len(positional)-1], positional[0]
+	}
+	return "", ""
+}
+
+// CountEvents counts events matching the filter (for export progress tracking).
+func (d *DB) CountEvents(ctx context.Context, filter EventFilter) (int64, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return 0, fmt.Errorf("relay database not connected")
+	}
+
+	// Build count query with same WHERE clauses as GetEvents
+	dl := d.relayDialect()
+	where, args, err := d.eventWhere(ctx, relay, dl, filter)
+	if err != nil {
+		return 0, err
+	}
+	query := `SELECT COUNT(*) FROM event WHERE ` + where
+
 	var count int64
-	err := d.RelayDB.QueryRowContext(ctx, query, args...).Scan(&count)
+	err = relay.QueryRowContext(ctx, dl.bind(query), args...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count events: %w", err)
 	}
@@ -788,18 +2332,73 @@ func (d *DB) CountEvents(ctx context.Context, filter EventFilter) (int64, error)
 	return count, nil
 }
 
+// CountEventsByKinds returns the number of stored events for each of the given
+// kinds. Every requested kind is present in the result, with zero if none are stored.
+func (d *DB) CountEventsByKinds(ctx context.Context, kinds []int) (map[int]int64, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+
+	counts := make(map[int]int64, len(kinds))
+	if len(kinds) == 0 {
+		return counts, nil
+	}
+
+	placeholders := make([]string, len(kinds))
+	args := make([]interface{}, len(kinds))
+	for i, kind := range kinds {
+		counts[kind] = 0
+		placeholders[i] = "?"
+		args[i] = kind
+	}
+
+	rows, err := relay.QueryContext(ctx, d.relayDialect().bind(fmt.Sprintf(
+		`SELECT kind, COUNT(*) FROM event WHERE kind IN (%s) GROUP BY kind`, strings.Join(placeholders, ","))), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count events by kind: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind int
+		var count int64
+		if err := rows.Scan(&kind, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan kind count: %w", err)
+		}
+		counts[kind] = count
+	}
+
+	return counts, rows.Err()
+}
+
 // StreamEvents streams events matching the filter to the callback function.
 // Used for exports to avoid loading all events into memory.
 func (d *DB) StreamEvents(ctx context.Context, filter EventFilter, callback func(ExportEvent) error) error {
-	if d.RelayDB == nil {
+	relay := d.RelayDB()
+	if relay == nil {
 		return fmt.Errorf("relay database not connected")
 	}
 
 	// Build query (same WHERE clauses as GetEvents, but no LIMIT for full export)
 	// nostr-rs-relay uses event_hash for ID, author for pubkey, and stores full event JSON in content
-	query := `SELECT event_hash, author, created_at, kind, content FROM event WHERE 1=1`
+	dl := d.relayDialect()
+	query := `SELECT ` + eventColumns(dl) + ` FROM event WHERE 1=1`
 	args := []interface{}{}
 
+	if len(filter.Authors) > 0 {
+		placeholders := make([]string, len(filter.Authors))
+		for i, pubkey := range filter.Authors {
+			pubkeyBytes, err := hex.DecodeString(pubkey)
+			if err != nil {
+				return fmt.Errorf("invalid pubkey: %w", err)
+			}
+			placeholders[i] = "?"
+			args = append(args, pubkeyBytes)
+		}
+		query += fmt.Sprintf(" AND %s IN (%s)", dl.authorColumn(), strings.Join(placeholders, ","))
+	}
+
 	if len(filter.Kinds) > 0 {
 		placeholders := make([]string, len(filter.Kinds))
 		for i, kind := range filter.Kinds {
@@ -810,19 +2409,19 @@ func (d *DB) StreamEvents(ctx context.Context, filter EventFilter, callback func
 	}
 
 	if !filter.Since.IsZero() {
-		query += " AND created_at >= ?"
+		query += " AND created_at >= " + dl.timeArg()
 		args = append(args, filter.Since.Unix())
 	}
 
 	if !filter.Until.IsZero() {
-		query += " AND created_at <= ?"
+		query += " AND created_at <= " + dl.timeArg()
 		args = append(args, filter.Until.Unix())
 	}
 
 	// Order by created_at for consistent export ordering
 	query += " ORDER BY created_at ASC"
 
-	rows, err := d.RelayDB.QueryContext(ctx, query, args...)
+	rows, err := relay.QueryContext(ctx, dl.bind(query), args...)
 	if err != nil {
 		return fmt.Errorf("failed to query events: %w", err)
 	}
@@ -846,32 +2445,7 @@ func (d *DB) StreamEvents(ctx context.Context, filter EventFilter, callback func
 			return fmt.Errorf("failed to scan event: %w", err)
 		}
 
-		// Parse the full event from the content JSON
-		var eventData nostrEventJSON
-		var event ExportEvent
-
-		if err := json.Unmarshal([]byte(contentJSON), &eventData); err != nil {
-			// If parsing fails, use available data
-			event = ExportEvent{
-				ID:        hex.EncodeToString(idBytes),
-				Pubkey:    hex.EncodeToString(authorBytes),
-				CreatedAt: dbCreatedAt,
-				Kind:      kind,
-				Tags:      [][]string{},
-				Content:   contentJSON,
-				Sig:       "",
-			}
-		} else {
-			event = ExportEvent{
-				ID:        hex.EncodeToString(idBytes),
-				Pubkey:    hex.EncodeToString(authorBytes),
-				CreatedAt: dbCreatedAt,
-				Kind:      kind,
-				Tags:      eventData.Tags,
-				Content:   eventData.Content,
-				Sig:       eventData.Sig,
-			}
-		}
+		event := exportEventFromRow(idBytes, authorBytes, dbCreatedAt, kind, contentJSON)
 
 		if err := callback(event); err != nil {
 			return err
@@ -880,3 +2454,109 @@ func (d *DB) StreamEvents(ctx context.Context, filter EventFilter, callback func
 
 	return rows.Err()
 }
+
+// StreamEventsAfter streams events whose relay row ID is greater than afterRowID,
+// in insertion order, passing each event's row ID to the callback. Row IDs only
+// grow, so the last ID seen is a cursor for incremental exports. kinds limits the
+// export when non-empty.
+func (d *DB) StreamEventsAfter(ctx context.Context, afterRowID int64, kinds []int, callback func(int64, ExportEvent) error) error {
+	relay := d.RelayDB()
+	if relay == nil {
+		return fmt.Errorf("relay database not connected")
+	}
+	// nostr-rs-relay's Postgres events have no row ID to use as a cursor
+	if d.IsRelayPostgres() {
+		return ErrUnsupportedOnPostgres
+	}
+
+	query := `SELECT id, event_hash, author, created_at, kind, content FROM event WHERE id > ?`
+	args := []interface{}{afterRowID}
+
+	if len(kinds) > 0 {
+		placeholders := make([]string, len(kinds))
+		for i, kind := range kinds {
+			placeholders[i] = "?"
+			args = append(args, kind)
+		}
+		query += fmt.Sprintf(" AND kind IN (%s)", strings.Join(placeholders, ","))
+	}
+
+	query += " ORDER BY id ASC"
+
+	rows, err := relay.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var rowID int64
+		var idBytes, authorBytes []byte
+		var dbCreatedAt int64
+		var kind int
+		var contentJSON string
+
+		if err := rows.Scan(&rowID, &idBytes, &authorBytes, &dbCreatedAt, &kind, &contentJSON); err != nil {
+			return fmt.Errorf("failed to scan event: %w", err)
+		}
+
+		if err := callback(rowID, exportEventFromRow(idBytes, authorBytes, dbCreatedAt, kind, contentJSON)); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetLatestEventRowID returns the highest relay event row ID, or 0 if the relay
+// has no events. It is the starting cursor for consumers of StreamEventsAfter
+// that should ignore existing events.
+func (d *DB) GetLatestEventRowID(ctx context.Context) (int64, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return 0, fmt.Errorf("relay database not connected")
+	}
+	if d.IsRelayPostgres() {
+		return 0, ErrUnsupportedOnPostgres
+	}
+
+	var rowID sql.NullInt64
+	if err := relay.QueryRowContext(ctx, `SELECT MAX(id) FROM event`).Scan(&rowID); err != nil {
+		return 0, fmt.Errorf("failed to query latest event: %w", err)
+	}
+	return rowID.Int64, nil
+}
+
+// exportEventFromRow builds an ExportEvent from a relay event row, falling back
+// to the indexed columns when the stored event JSON cannot be parsed.
+func exportEventFromRow(idBytes, authorBytes []byte, dbCreatedAt int64, kind int, contentJSON string) ExportEvent {
+	var eventData nostrEventJSON
+	if err := json.Unmarshal([]byte(contentJSON), &eventData); err != nil {
+		// If parsing fails, use available data
+		return ExportEvent{
+			ID:        hex.EncodeToString(idBytes),
+			Pubkey:    hex.EncodeToString(authorBytes),
+			CreatedAt: dbCreatedAt,
+			Kind:      kind,
+			Tags:      [][]string{},
+			Content:   contentJSON,
+			Sig:       "",
+		}
+	}
+
+	return ExportEvent{
+		ID:        hex.EncodeToString(idBytes),
+		Pubkey:    hex.EncodeToString(authorBytes),
+		CreatedAt: dbCreatedAt,
+		Kind:      kind,
+		Tags:      eventData.Tags,
+		Content:   eventData.Content,
+		Sig:       eventData.Sig,
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -75,7 +78,7 @@ func setupTestRelayDB(t *testing.T) *DB {
 	}
 
 	// Attach the relay database
-	database.RelayDB = relayDB
+	database.relayDB.Store(relayDB)
 
 	return database
 }
@@ -158,7 +161,7 @@ func TestGetEvent(t *testing.T) {
 
 	// Insert a test event
 	now := time.Now().Truncate(time.Second)
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Hello world")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Hello world")
 
 	t.Run("GetEvent_existing", func(t *testing.T) {
 		event, err := db.GetEvent(ctx, testEventID1)
@@ -204,17 +207,34 @@ func TestGetEvent(t *testing.T) {
 // GetEvents (Filtering) Tests
 // ============================================================================
 
+func TestExistingEventIDs(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "one")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now, "two")
+
+	existing, err := db.ExistingEventIDs(ctx, []string{testEventID1, testEventID3, "not-hex", testEventID2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(existing) != 2 || !existing[testEventID1] || !existing[testEventID2] {
+		t.Errorf("expected events 1 and 2 to exist, got %v", existing)
+	}
+}
+
 func TestGetEvents(t *testing.T) {
 	db := setupTestRelayDB(t)
 	ctx := context.Background()
 
 	// Insert test events
 	now := time.Now().Truncate(time.Second)
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "First note")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Second note")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey2, 0, now.Add(-2*time.Hour), "Metadata event")
-	insertTestEvent(t, db.RelayDB, testEventID4, testPubkey2, 3, now.Add(-3*time.Hour), "Contact list")
-	insertTestEvent(t, db.RelayDB, testEventID5, testPubkey1, 4, now.Add(-4*time.Hour), "Encrypted message")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "First note")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Second note")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 0, now.Add(-2*time.Hour), "Metadata event")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey2, 3, now.Add(-3*time.Hour), "Contact list")
+	insertTestEvent(t, db.RelayDB(), testEventID5, testPubkey1, 4, now.Add(-4*time.Hour), "Encrypted message")
 
 	t.Run("GetEvents_no_filter", func(t *testing.T) {
 		events, err := db.GetEvents(ctx, EventFilter{})
@@ -306,13 +326,13 @@ func TestGetEvents(t *testing.T) {
 		}
 	})
 
-	t.Run("GetEvents_offset", func(t *testing.T) {
-		events, err := db.GetEvents(ctx, EventFilter{Limit: 2, Offset: 2})
+	t.Run("GetEvents_after_cursor", func(t *testing.T) {
+		events, err := db.GetEvents(ctx, EventFilter{Limit: 2, After: &EventCursor{CreatedAt: now.Add(-time.Hour).Unix(), ID: testEventID2}})
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		if len(events) != 2 {
-			t.Errorf("expected 2 events (offset), got %d", len(events))
+		if len(events) != 2 || events[0].ID != testEventID3 {
+			t.Errorf("expected 2 events from event 3, got %d", len(events))
 		}
 	})
 
@@ -328,6 +348,75 @@ func TestGetEvents(t *testing.T) {
 	})
 }
 
+func TestGetEventsPage(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	// Two events share a second, so the cursor has to break the tie by ID
+	now := time.Now().Truncate(time.Second)
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 1, now.Add(-time.Hour), "Event 3")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey2, 1, now.Add(-2*time.Hour), "Event 4")
+	insertTestEvent(t, db.RelayDB(), testEventID5, testPubkey1, 1, now.Add(-3*time.Hour), "Event 5")
+
+	first, next, err := db.GetEventsPage(ctx, EventFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first) != 2 || next == nil {
+		t.Fatalf("expected a full first page and a cursor, got %d events and %v", len(first), next)
+	}
+
+	// A new event does not shift the pages after the first
+	insertTestEvent(t, db.RelayDB(), strings.Repeat("f", 64), testPubkey3, 1, now.Add(time.Minute), "New event")
+
+	seen := map[string]bool{first[0].ID: true, first[1].ID: true}
+	ids := []string{first[0].ID, first[1].ID}
+	for next != nil {
+		cursor, err := ParseEventCursor(next.String())
+		if err != nil {
+			t.Fatalf("failed to parse cursor %q: %v", next.String(), err)
+		}
+		var page []Event
+		page, next, err = db.GetEventsPage(ctx, EventFilter{Limit: 2, After: cursor})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, event := range page {
+			if seen[event.ID] {
+				t.Errorf("event %s returned twice", event.ID)
+			}
+			seen[event.ID] = true
+			ids = append(ids, event.ID)
+		}
+	}
+
+	// Within the shared second the higher ID comes first
+	want := []string{testEventID1, testEventID3, testEventID2, testEventID4, testEventID5}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("pages returned %v, want %v", ids, want)
+	}
+
+	t.Run("LastPageHasNoCursor", func(t *testing.T) {
+		events, next, err := db.GetEventsPage(ctx, EventFilter{Limit: 6})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(events) != 6 || next != nil {
+			t.Errorf("expected all 6 events and no cursor, got %d and %v", len(events), next)
+		}
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		for _, s := range []string{"", "not base64!", "bm8tY29sb24", "YWJjOmRlYWQ", "MTIzOnh5eg"} {
+			if _, err := ParseEventCursor(s); err != ErrInvalidCursor {
+				t.Errorf("ParseEventCursor(%q) error = %v, want ErrInvalidCursor", s, err)
+			}
+		}
+	})
+}
+
 // ============================================================================
 // GetEvents with Mentions Tests
 // ============================================================================
@@ -340,10 +429,10 @@ func TestGetEventsWithMentions(t *testing.T) {
 
 	// Insert event with p tag mentioning testPubkey2
 	tags := [][]string{{"p", testPubkey2}}
-	insertTestEventWithTags(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Mentioning someone", tags)
+	insertTestEventWithTags(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Mentioning someone", tags)
 
 	// Insert event without mentions
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now, "No mentions")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now, "No mentions")
 
 	t.Run("GetEvents_filter_mentions", func(t *testing.T) {
 		events, err := db.GetEvents(ctx, EventFilter{Mentions: testPubkey2})
@@ -359,6 +448,97 @@ func TestGetEventsWithMentions(t *testing.T) {
 	})
 }
 
+func TestGetEventsTagFilters(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	insert := func(db *DB) {
+		insertTestEventWithTags(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "reply",
+			[][]string{{"e", testEventID3}, {"p", testPubkey2}, {"t", "100%_off"}})
+		insertTestEventWithTags(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Minute), "tagged",
+			[][]string{{"t", "100xxoff"}, {"t", "nostr"}})
+		insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 1, now.Add(-time.Hour), "root")
+	}
+
+	check := func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		tests := []struct {
+			name   string
+			filter EventFilter
+			want   []string
+		}{
+			{"e tag", EventFilter{Tags: map[string][]string{"e": {testEventID3}}}, []string{testEventID1}},
+			{"p tag", EventFilter{Tags: map[string][]string{"p": {testPubkey2}}}, []string{testEventID1}},
+			{"mentions", EventFilter{Mentions: testPubkey2}, []string{testEventID1}},
+			{"wildcards match literally", EventFilter{Tags: map[string][]string{"t": {"100%_off"}}}, []string{testEventID1}},
+			{"any value", EventFilter{Tags: map[string][]string{"t": {"nostr", "100%_off"}}}, []string{testEventID1, testEventID2}},
+			{"every tag", EventFilter{Tags: map[string][]string{"t": {"nostr", "100%_off"}, "p": {testPubkey2}}}, []string{testEventID1}},
+			{"no match", EventFilter{Tags: map[string][]string{"t": {"bitcoin"}}}, nil},
+			{"value is not a prefix", EventFilter{Tags: map[string][]string{"t": {"nost"}}}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				events, err := db.GetEvents(ctx, tt.filter)
+				if err != nil {
+					t.Fatalf("GetEvents() error = %v", err)
+				}
+				var got []string
+				for _, e := range events {
+					got = append(got, e.ID)
+				}
+				if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+					t.Errorf("GetEvents() = %v, want %v", got, tt.want)
+				}
+				count, err := db.CountEvents(ctx, tt.filter)
+				if err != nil || count != int64(len(tt.want)) {
+					t.Errorf("CountEvents() = %d, %v; want %d", count, err, len(tt.want))
+				}
+			})
+		}
+
+		if _, err := db.GetEvents(ctx, EventFilter{Tags: map[string][]string{"#e": {testEventID3}}}); err == nil {
+			t.Error("GetEvents() should reject tag names longer than a letter")
+		}
+	}
+
+	t.Run("event JSON", func(t *testing.T) {
+		db := setupTestRelayDB(t)
+		insert(db)
+		check(t, db)
+	})
+
+	t.Run("tag table", func(t *testing.T) {
+		db := setupTestRelayDB(t)
+		if _, err := db.RelayDB().Exec(`CREATE TABLE tag (
+			id INTEGER PRIMARY KEY, event_id INTEGER NOT NULL, name TEXT, value TEXT, value_hex BLOB
+		)`); err != nil {
+			t.Fatalf("failed to create tag table: %v", err)
+		}
+		insert(db)
+		// Like nostr-rs-relay, hex values go in value_hex; the JSON is blanked
+		// so only the tag table can match
+		for _, tag := range []struct{ id, name, value string }{
+			{testEventID1, "e", testEventID3},
+			{testEventID1, "p", testPubkey2},
+			{testEventID1, "t", "100%_off"},
+			{testEventID2, "t", "100xxoff"},
+			{testEventID2, "t", "nostr"},
+		} {
+			idBytes, _ := hex.DecodeString(tag.id)
+			var value, valueHex interface{} = tag.value, nil
+			if b, err := hex.DecodeString(tag.value); err == nil {
+				value, valueHex = nil, b
+			}
+			if _, err := db.RelayDB().Exec(`INSERT INTO tag (event_id, name, value, value_hex) SELECT id, ?, ?, ? FROM event WHERE event_hash = ?`,
+				tag.name, value, valueHex, idBytes); err != nil {
+				t.Fatalf("failed to insert tag: %v", err)
+			}
+		}
+		if _, err := db.RelayDB().Exec(`UPDATE event SET content = json_set(content, '$.tags', json('[]'))`); err != nil {
+			t.Fatalf("failed to blank tags: %v", err)
+		}
+		check(t, db)
+	})
+}
+
 // ============================================================================
 // GetRecentEvents Tests
 // ============================================================================
@@ -368,9 +548,9 @@ func TestGetRecentEvents(t *testing.T) {
 	ctx := context.Background()
 
 	now := time.Now().Truncate(time.Second)
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Event 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey1, 1, now.Add(-2*time.Hour), "Event 3")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 1, now.Add(-2*time.Hour), "Event 3")
 
 	t.Run("GetRecentEvents", func(t *testing.T) {
 		events, err := db.GetRecentEvents(ctx, 2)
@@ -410,10 +590,10 @@ func TestGetRelayStats(t *testing.T) {
 
 	// Insert test events
 	now := time.Now().Truncate(time.Second)
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Event 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey2, 0, now.Add(-2*time.Hour), "Event 3")
-	insertTestEvent(t, db.RelayDB, testEventID4, testPubkey2, 3, now.Add(-3*time.Hour), "Event 4")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 0, now.Add(-2*time.Hour), "Event 3")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey2, 3, now.Add(-3*time.Hour), "Event 4")
 
 	t.Run("GetRelayStats_with_data", func(t *testing.T) {
 		stats, err := db.GetRelayStats(ctx)
@@ -453,9 +633,9 @@ func TestGetEventsToday(t *testing.T) {
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 
 	// Insert event from today
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, startOfDay.Add(time.Hour), "Today's event")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, startOfDay.Add(time.Hour), "Today's event")
 	// Insert event from yesterday
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, startOfDay.Add(-time.Hour), "Yesterday's event")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, startOfDay.Add(-time.Hour), "Yesterday's event")
 
 	t.Run("GetEventsToday", func(t *testing.T) {
 		count, err := db.GetEventsToday(ctx, time.UTC)
@@ -477,9 +657,9 @@ func TestCountEventsByPubkey(t *testing.T) {
 	ctx := context.Background()
 
 	now := time.Now().Truncate(time.Second)
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Event 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey2, 1, now.Add(-2*time.Hour), "Event 3")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 1, now.Add(-2*time.Hour), "Event 3")
 
 	t.Run("CountEventsByPubkey", func(t *testing.T) {
 		counts, err := db.CountEventsByPubkey(ctx, []string{testPubkey1, testPubkey2, testPubkey3})
@@ -496,6 +676,186 @@ func TestCountEventsByPubkey(t *testing.T) {
 			t.Errorf("expected 0 events for pubkey3, got %d", counts[testPubkey3])
 		}
 	})
+
+	t.Run("ChunksLargeLists", func(t *testing.T) {
+		pubkeys := []string{"not-hex"}
+		for i := 0; i < 2*authorCountChunk; i++ {
+			pubkeys = append(pubkeys, fmt.Sprintf("%064x", i))
+		}
+		pubkeys = append(pubkeys, testPubkey1, testPubkey2)
+
+		counts, err := db.CountEventsByPubkey(ctx, pubkeys)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(counts) != 2*authorCountChunk+2 {
+			t.Errorf("expected %d counts, got %d", 2*authorCountChunk+2, len(counts))
+		}
+		if _, ok := counts["not-hex"]; ok {
+			t.Error("expected invalid pubkey to be left out")
+		}
+		if counts[testPubkey1] != 2 || counts[testPubkey2] != 1 {
+			t.Errorf("unexpected counts past the first chunk: %d, %d", counts[testPubkey1], counts[testPubkey2])
+		}
+	})
+
+	t.Run("LowercasesKeys", func(t *testing.T) {
+		counts, err := db.CountEventsByPubkey(ctx, []string{strings.ToUpper(testPubkey1), testPubkey1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(counts) != 1 || counts[testPubkey1] != 2 {
+			t.Errorf("expected one lowercase key with 2 events, got %v", counts)
+		}
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		counts, err := db.CountEventsByPubkeyCached(ctx, []string{testPubkey1, testPubkey2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if counts[testPubkey1] != 2 {
+			t.Errorf("expected 2 events for pubkey1, got %d", counts[testPubkey1])
+		}
+
+		// A new event is not seen until the cached count expires
+		insertTestEvent(t, db.RelayDB(), "d"+testEventID1[1:], testPubkey1, 1, now, "Event 4")
+		counts, _ = db.CountEventsByPubkeyCached(ctx, []string{testPubkey1, testPubkey3})
+		if counts[testPubkey1] != 2 || counts[testPubkey3] != 0 {
+			t.Errorf("expected cached count of 2 and 0, got %d and %d", counts[testPubkey1], counts[testPubkey3])
+		}
+
+		db.authorCountsMu.Lock()
+		c := db.authorCounts[testPubkey1]
+		c.countedAt = c.countedAt.Add(-authorCountTTL)
+		db.authorCounts[testPubkey1] = c
+		db.authorCountsMu.Unlock()
+
+		counts, _ = db.CountEventsByPubkeyCached(ctx, []string{testPubkey1})
+		if counts[testPubkey1] != 3 {
+			t.Errorf("expected 3 events after expiry, got %d", counts[testPubkey1])
+		}
+	})
+}
+
+// ============================================================================
+// GetAuthorActivity Tests
+// ============================================================================
+
+func TestGetAuthorActivity(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 1, now.Add(-2*time.Hour), "Event 3")
+
+	activity, err := db.GetAuthorActivity(ctx, []string{strings.ToUpper(testPubkey1), testPubkey2, testPubkey3, "not-hex"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	a1, ok := activity[testPubkey1]
+	if !ok {
+		t.Fatal("expected activity for pubkey1")
+	}
+	if a1.EventCount != 2 {
+		t.Errorf("expected 2 events for pubkey1, got %d", a1.EventCount)
+	}
+	if a1.StorageBytes <= 0 {
+		t.Errorf("expected positive storage for pubkey1, got %d", a1.StorageBytes)
+	}
+	if a1.LastActive == nil || !a1.LastActive.Equal(now) {
+		t.Errorf("expected last active %v, got %v", now, a1.LastActive)
+	}
+
+	if activity[testPubkey2].EventCount != 1 {
+		t.Errorf("expected 1 event for pubkey2, got %d", activity[testPubkey2].EventCount)
+	}
+	if _, ok := activity[testPubkey3]; ok {
+		t.Error("expected no activity entry for pubkey3")
+	}
+}
+
+func TestGetPubkeyActivity(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, day, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, day.Add(time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 7, day.AddDate(0, 0, -2), "+")
+	insertTestEventWithTags(t, db.RelayDB(), testEventID4, testPubkey2, 1, day, "hi", [][]string{{"p", testPubkey1}})
+
+	activity, err := db.GetPubkeyActivity(ctx, testPubkey1, day.AddDate(0, 0, -3), day, time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if activity.EventCount != 3 || activity.EventsByKind[1] != 2 || activity.EventsByKind[7] != 1 {
+		t.Errorf("unexpected counts: %+v", activity)
+	}
+	if activity.StorageBytes <= 0 || activity.Mentions != 1 {
+		t.Errorf("expected storage and one mention, got %+v", activity)
+	}
+	if activity.FirstEvent == nil || !activity.FirstEvent.Equal(day.AddDate(0, 0, -2)) ||
+		activity.LastEvent == nil || !activity.LastEvent.Equal(day.Add(time.Hour)) {
+		t.Errorf("unexpected first/last events: %v, %v", activity.FirstEvent, activity.LastEvent)
+	}
+	want := []DateCount{{"2025-03-07", 0}, {"2025-03-08", 1}, {"2025-03-09", 0}, {"2025-03-10", 1}}
+	if fmt.Sprint(activity.EventsOverTime) != fmt.Sprint(want) {
+		t.Errorf("events over time = %v, want %v", activity.EventsOverTime, want)
+	}
+
+	// All time starts at the first event
+	activity, err = db.GetPubkeyActivity(ctx, testPubkey1, time.Time{}, day.Add(2*time.Hour), time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(activity.EventsOverTime) != 3 || activity.EventsOverTime[2].Count != 2 {
+		t.Errorf("unexpected all time events over time: %v", activity.EventsOverTime)
+	}
+
+	activity, err = db.GetPubkeyActivity(ctx, testPubkey3, time.Time{}, time.Time{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if activity.EventCount != 0 || activity.FirstEvent != nil || len(activity.EventsOverTime) != 0 {
+		t.Errorf("expected no activity for pubkey3, got %+v", activity)
+	}
+}
+
+func TestGetStorageBreakdown(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, strings.Repeat("x", 1000))
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 7, now, "+")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 1, now, "short")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey3, 0, now, "{}")
+
+	breakdown, err := db.GetStorageBreakdown(ctx, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if breakdown.TotalEvents != 4 || breakdown.Authors != 3 {
+		t.Errorf("unexpected totals: %+v", breakdown)
+	}
+	if len(breakdown.TopAuthors) != 2 || breakdown.TopAuthors[0].Pubkey != testPubkey1 || breakdown.TopAuthors[0].Events != 2 {
+		t.Errorf("expected pubkey1 as the largest of 2 authors, got %+v", breakdown.TopAuthors)
+	}
+	if len(breakdown.Kinds) != 3 || breakdown.Kinds[0].Kind != 1 || breakdown.Kinds[0].Events != 2 {
+		t.Errorf("expected kind 1 as the largest of 3 kinds, got %+v", breakdown.Kinds)
+	}
+
+	var sum int64
+	for _, k := range breakdown.Kinds {
+		sum += k.Bytes
+	}
+	if sum != breakdown.TotalBytes || breakdown.TotalBytes < 1000+4*eventOverheadBytes {
+		t.Errorf("total bytes %d do not add up (kinds %d)", breakdown.TotalBytes, sum)
+	}
 }
 
 // ============================================================================
@@ -508,12 +868,12 @@ func TestGetTopAuthors(t *testing.T) {
 
 	now := time.Now().Truncate(time.Second)
 	// pubkey1 has 3 events, pubkey2 has 2, pubkey3 has 1
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Event 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey1, 1, now.Add(-2*time.Hour), "Event 3")
-	insertTestEvent(t, db.RelayDB, testEventID4, testPubkey2, 1, now.Add(-3*time.Hour), "Event 4")
-	insertTestEvent(t, db.RelayDB, testEventID5, testPubkey2, 1, now.Add(-4*time.Hour), "Event 5")
-	insertTestEvent(t, db.RelayDB, "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", testPubkey3, 1, now.Add(-5*time.Hour), "Event 6")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 1, now.Add(-2*time.Hour), "Event 3")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey2, 1, now.Add(-3*time.Hour), "Event 4")
+	insertTestEvent(t, db.RelayDB(), testEventID5, testPubkey2, 1, now.Add(-4*time.Hour), "Event 5")
+	insertTestEvent(t, db.RelayDB(), "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", testPubkey3, 1, now.Add(-5*time.Hour), "Event 6")
 
 	t.Run("GetTopAuthors_default_limit", func(t *testing.T) {
 		authors, err := db.GetTopAuthors(ctx, 0)
@@ -552,9 +912,9 @@ func TestCountEventsBefore(t *testing.T) {
 	ctx := context.Background()
 
 	now := time.Now().Truncate(time.Second)
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Event 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-2*time.Hour), "Event 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey1, 1, now.Add(-4*time.Hour), "Event 3")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-2*time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 1, now.Add(-4*time.Hour), "Event 3")
 
 	t.Run("CountEventsBefore", func(t *testing.T) {
 		count, err := db.CountEventsBefore(ctx, now.Add(-time.Hour))
@@ -587,7 +947,7 @@ func TestEstimateEventSize(t *testing.T) {
 	})
 
 	now := time.Now()
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Hello world")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Hello world")
 
 	t.Run("EstimateEventSize_with_data", func(t *testing.T) {
 		size, err := db.EstimateEventSize(ctx)
@@ -613,13 +973,13 @@ func TestGetEventsOverTime(t *testing.T) {
 	yesterday := now.AddDate(0, 0, -1)
 	twoDaysAgo := now.AddDate(0, 0, -2)
 
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now.Add(-time.Hour), "Event 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, yesterday, "Event 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey1, 1, yesterday.Add(-time.Hour), "Event 3")
-	insertTestEvent(t, db.RelayDB, testEventID4, testPubkey1, 1, twoDaysAgo, "Event 4")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now.Add(-time.Hour), "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, yesterday, "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 1, yesterday.Add(-time.Hour), "Event 3")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey1, 1, twoDaysAgo, "Event 4")
 
 	t.Run("GetEventsOverTime_daily", func(t *testing.T) {
-		results, err := db.GetEventsOverTime(ctx, twoDaysAgo, now, false, time.UTC)
+		results, err := db.GetEventsOverTime(ctx, twoDaysAgo, now, BucketDay, time.UTC)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -629,7 +989,7 @@ func TestGetEventsOverTime(t *testing.T) {
 	})
 
 	t.Run("GetEventsOverTime_hourly", func(t *testing.T) {
-		results, err := db.GetEventsOverTime(ctx, now.Add(-24*time.Hour), now, true, time.UTC)
+		results, err := db.GetEventsOverTime(ctx, now.Add(-24*time.Hour), now, BucketHour, time.UTC)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -640,6 +1000,131 @@ func TestGetEventsOverTime(t *testing.T) {
 	})
 }
 
+func TestGetEventsOverTime_DST(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	// The clocks go forward on 9 March 2025 and back on 2 November
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, time.Date(2025, 3, 8, 23, 30, 0, 0, loc), "Before")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, time.Date(2025, 3, 15, 0, 30, 0, 0, loc), "After")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 1, time.Date(2025, 4, 1, 0, 30, 0, 0, loc), "April")
+
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, loc)
+	until := time.Date(2025, 4, 2, 23, 59, 59, 0, loc)
+
+	days, err := db.GetEventsOverTime(ctx, since, until, BucketDay, loc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	counts := make(map[string]int64)
+	for _, d := range days {
+		counts[d.Date] = d.Count
+	}
+	if len(days) != 33 || counts["2025-03-08"] != 1 || counts["2025-03-15"] != 1 || counts["2025-04-01"] != 1 {
+		t.Errorf("expected each event on its local day across the change, got %v", days)
+	}
+
+	weeks, err := db.GetEventsOverTime(ctx, since, until, BucketWeek, loc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if weeks[0].Date != "2025-02-24" || weeks[1].Date != "2025-03-03" || weeks[1].Count != 1 || weeks[2].Date != "2025-03-10" || weeks[2].Count != 1 {
+		t.Errorf("unexpected weeks: %v", weeks)
+	}
+
+	months, err := db.GetEventsOverTime(ctx, since, until, BucketMonth, loc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []DateCount{{"2025-03", 2}, {"2025-04", 1}}; fmt.Sprint(months) != fmt.Sprint(want) {
+		t.Errorf("months = %v, want %v", months, want)
+	}
+
+	hours, err := db.GetEventsOverTime(ctx, time.Date(2025, 3, 9, 0, 0, 0, 0, loc), time.Date(2025, 3, 9, 23, 59, 59, 0, loc), BucketHour, loc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hours) != 23 {
+		t.Errorf("expected 23 hours on the day the clocks go forward, got %d", len(hours))
+	}
+	hours, _ = db.GetEventsOverTime(ctx, time.Date(2025, 11, 2, 0, 0, 0, 0, loc), time.Date(2025, 11, 2, 23, 59, 59, 0, loc), BucketHour, loc)
+	if len(hours) != 24 || hours[1].Date != "2025-11-02 01:00" || hours[2].Date != "2025-11-02 02:00" {
+		t.Errorf("expected the repeated hour once on the day the clocks go back, got %v", hours)
+	}
+
+	if _, err := db.GetEventsOverTime(ctx, since, until, "fortnight", loc); err == nil {
+		t.Error("expected an error for an unknown bucket")
+	}
+}
+
+func TestGetKindTrends(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, day, "Note")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, day.Add(time.Hour), "Note")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 7, day.AddDate(0, 0, -2), "+")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey2, 7, day.AddDate(0, 0, 7), "+")
+
+	trends, err := db.GetKindTrends(ctx, day.AddDate(0, 0, -3), day.Add(2*time.Hour), BucketDay, time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"2025-03-07", "2025-03-08", "2025-03-09", "2025-03-10"}; fmt.Sprint(trends.Buckets) != fmt.Sprint(want) {
+		t.Errorf("buckets = %v, want %v", trends.Buckets, want)
+	}
+	if fmt.Sprint(trends.Kinds[1]) != "[0 0 0 2]" || fmt.Sprint(trends.Kinds[7]) != "[0 1 0 0]" {
+		t.Errorf("unexpected daily kinds: %v", trends.Kinds)
+	}
+
+	// All time by week starts at the first event
+	trends, err = db.GetKindTrends(ctx, time.Time{}, day.AddDate(0, 0, 8), BucketWeek, time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(trends.Buckets) != "[2025-03-03 2025-03-10 2025-03-17]" ||
+		fmt.Sprint(trends.Kinds[1]) != "[0 2 0]" || fmt.Sprint(trends.Kinds[7]) != "[1 0 1]" {
+		t.Errorf("unexpected weekly trends: %+v", trends)
+	}
+}
+
+func TestGetAuthorGrowth(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	// Mondays 3, 10 and 17 March 2025
+	day := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, day.AddDate(0, 0, -30), "Early")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, day.AddDate(0, 0, 8), "Again")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 1, day.AddDate(0, 0, 1), "Hello")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey3, 1, day.AddDate(0, 0, 15), "Hi")
+	insertTestEvent(t, db.RelayDB(), testEventID5, testPubkey2, 1, day.AddDate(0, 0, 16), "Back")
+
+	growth, err := db.GetAuthorGrowth(ctx, day, day.AddDate(0, 0, 20), BucketWeek, time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []AuthorGrowthPoint{{"2025-03-03", 1, 2}, {"2025-03-10", 0, 2}, {"2025-03-17", 1, 3}}
+	if growth.Before != 1 || fmt.Sprint(growth.Points) != fmt.Sprint(want) {
+		t.Errorf("growth = %d %v, want 1 %v", growth.Before, growth.Points, want)
+	}
+
+	// All time starts with the first author
+	growth, err = db.GetAuthorGrowth(ctx, time.Time{}, day.AddDate(0, 0, 20), BucketMonth, time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []AuthorGrowthPoint{{"2025-02", 1, 1}, {"2025-03", 2, 3}}
+	if growth.Before != 0 || fmt.Sprint(growth.Points) != fmt.Sprint(want) {
+		t.Errorf("all time growth = %d %v, want 0 %v", growth.Before, growth.Points, want)
+	}
+}
+
 // ============================================================================
 // GetEventsByKindInRange Tests
 // ============================================================================
@@ -651,10 +1136,10 @@ func TestGetEventsByKindInRange(t *testing.T) {
 	now := time.Now().Truncate(time.Second)
 	yesterday := now.AddDate(0, 0, -1)
 
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Note 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Note 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey1, 0, now.Add(-2*time.Hour), "Metadata")
-	insertTestEvent(t, db.RelayDB, testEventID4, testPubkey1, 3, yesterday, "Contact list")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Note 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Note 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 0, now.Add(-2*time.Hour), "Metadata")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey1, 3, yesterday, "Contact list")
 
 	t.Run("GetEventsByKindInRange_all", func(t *testing.T) {
 		results, err := db.GetEventsByKindInRange(ctx, time.Time{}, time.Time{})
@@ -693,12 +1178,12 @@ func TestGetTopAuthorsInRange(t *testing.T) {
 	yesterday := now.AddDate(0, 0, -1)
 
 	// Today: pubkey1 has 2 events, pubkey2 has 1
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Event 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey2, 1, now.Add(-2*time.Hour), "Event 3")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Event 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Event 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 1, now.Add(-2*time.Hour), "Event 3")
 	// Yesterday: pubkey2 has 2 events (making total 3)
-	insertTestEvent(t, db.RelayDB, testEventID4, testPubkey2, 1, yesterday, "Event 4")
-	insertTestEvent(t, db.RelayDB, testEventID5, testPubkey2, 1, yesterday.Add(-time.Hour), "Event 5")
+	insertTestEvent(t, db.RelayDB(), testEventID4, testPubkey2, 1, yesterday, "Event 4")
+	insertTestEvent(t, db.RelayDB(), testEventID5, testPubkey2, 1, yesterday.Add(-time.Hour), "Event 5")
 
 	t.Run("GetTopAuthorsInRange_today_only", func(t *testing.T) {
 		authors, err := db.GetTopAuthorsInRange(ctx, 10, now.Add(-3*time.Hour), now)
@@ -724,9 +1209,9 @@ func TestCountEvents(t *testing.T) {
 	ctx := context.Background()
 
 	now := time.Now().Truncate(time.Second)
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Note")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 0, now.Add(-time.Hour), "Metadata")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey1, 3, now.Add(-2*time.Hour), "Contacts")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Note")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 0, now.Add(-time.Hour), "Metadata")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 3, now.Add(-2*time.Hour), "Contacts")
 
 	t.Run("CountEvents_all", func(t *testing.T) {
 		count, err := db.CountEvents(ctx, EventFilter{})
@@ -768,9 +1253,9 @@ func TestStreamEvents(t *testing.T) {
 	ctx := context.Background()
 
 	now := time.Now().Truncate(time.Second)
-	insertTestEvent(t, db.RelayDB, testEventID1, testPubkey1, 1, now, "Note 1")
-	insertTestEvent(t, db.RelayDB, testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Note 2")
-	insertTestEvent(t, db.RelayDB, testEventID3, testPubkey1, 0, now.Add(-2*time.Hour), "Metadata")
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Note 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Note 2")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 0, now.Add(-2*time.Hour), "Metadata")
 
 	t.Run("StreamEvents_all", func(t *testing.T) {
 		var events []ExportEvent
@@ -826,11 +1311,348 @@ func TestStreamEvents(t *testing.T) {
 	})
 }
 
+func TestStreamEventsAfter(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	// Insertion order differs from created_at order; the cursor follows insertion.
+	now := time.Now().Truncate(time.Second)
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "Note 1")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 0, now.Add(-time.Hour), "Metadata")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 1, now.Add(-2*time.Hour), "Old note synced late")
+
+	var rowIDs []int64
+	var ids []string
+	err := db.StreamEventsAfter(ctx, 0, nil, func(rowID int64, e ExportEvent) error {
+		rowIDs = append(rowIDs, rowID)
+		ids = append(ids, e.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 3 || ids[0] != testEventID1 || ids[2] != testEventID3 {
+		t.Fatalf("expected events in insertion order, got %v", ids)
+	}
+
+	t.Run("after_cursor", func(t *testing.T) {
+		var got []string
+		db.StreamEventsAfter(ctx, rowIDs[0], nil, func(rowID int64, e ExportEvent) error {
+			got = append(got, e.ID)
+			return nil
+		})
+		if len(got) != 2 || got[0] != testEventID2 {
+			t.Errorf("expected 2 events after cursor, got %v", got)
+		}
+	})
+
+	t.Run("filtered_by_kind", func(t *testing.T) {
+		var got []string
+		db.StreamEventsAfter(ctx, rowIDs[0], []int{1}, func(rowID int64, e ExportEvent) error {
+			got = append(got, e.ID)
+			return nil
+		})
+		if len(got) != 1 || got[0] != testEventID3 {
+			t.Errorf("expected only the late kind 1 event, got %v", got)
+		}
+	})
+}
+
+func TestCountEventsByKinds(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 4, now, "dm")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 4, now, "dm")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 1, now, "note")
+
+	counts, err := db.CountEventsByKinds(ctx, []int{4, 1059})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if counts[4] != 2 {
+		t.Errorf("expected 2 kind 4 events, got %d", counts[4])
+	}
+	if count, ok := counts[1059]; !ok || count != 0 {
+		t.Errorf("expected kind 1059 present with zero, got %d (%v)", count, ok)
+	}
+	if _, ok := counts[1]; ok {
+		t.Error("expected unrequested kinds to be omitted")
+	}
+}
+
+func TestGetInteractions(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := db.GetInteractions(ctx, testPubkey1, time.Time{}, time.Time{}, 10); !errors.Is(err, ErrNoTagIndex) {
+		t.Fatalf("expected ErrNoTagIndex without a tag table, got %v", err)
+	}
+	if _, err := db.RelayDB().Exec(`CREATE TABLE tag (
+		id INTEGER PRIMARY KEY, event_id INTEGER NOT NULL, name TEXT, value TEXT, value_hex BLOB
+	)`); err != nil {
+		t.Fatalf("failed to create tag table: %v", err)
+	}
+
+	eventID := func(n byte) string { return strings.Repeat(hex.EncodeToString([]byte{n}), 32) }
+	// insert adds an event with its tags, hex values as bytes like
+	// nostr-rs-relay unless asText
+	insert := func(n byte, author string, kind int, createdAt time.Time, asText bool, tags ...[2]string) {
+		t.Helper()
+		insertTestEvent(t, db.RelayDB(), eventID(n), author, kind, createdAt, "x")
+		idBytes, _ := hex.DecodeString(eventID(n))
+		for _, tag := range tags {
+			var value, valueHex interface{} = tag[1], nil
+			if b, err := hex.DecodeString(tag[1]); err == nil && !asText {
+				value, valueHex = nil, b
+			}
+			if _, err := db.RelayDB().Exec(`INSERT INTO tag (event_id, name, value, value_hex) SELECT id, ?, ?, ? FROM event WHERE event_hash = ?`,
+				tag[0], value, valueHex, idBytes); err != nil {
+				t.Fatalf("failed to insert tag: %v", err)
+			}
+		}
+	}
+
+	note := eventID(0x01)
+	insert(0x01, testPubkey1, 1, now.Add(-time.Hour), false)
+	// pubkey2 reacts, replies and mentions
+	insert(0x02, testPubkey2, 7, now, false, [2]string{"e", note}, [2]string{"p", testPubkey1})
+	insert(0x03, testPubkey2, 1, now, true, [2]string{"e", note}, [2]string{"p", testPubkey1})
+	insert(0x04, testPubkey2, 1, now, false, [2]string{"p", testPubkey1})
+	// pubkey3 reacts to a note the relay doesn't have; its DM and old
+	// mention are not counted
+	insert(0x05, testPubkey3, 7, now, false, [2]string{"e", eventID(0x99)}, [2]string{"p", testPubkey1})
+	insert(0x06, testPubkey3, 4, now, false, [2]string{"p", testPubkey1})
+	insert(0x07, testPubkey3, 1, now.AddDate(0, -2, 0), false, [2]string{"p", testPubkey1})
+	// Replying to yourself is not an interaction
+	insert(0x08, testPubkey1, 1, now, false, [2]string{"e", note}, [2]string{"p", testPubkey1})
+
+	interactions, err := db.GetInteractions(ctx, testPubkey1, now.AddDate(0, 0, -30), now, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Interaction{
+		{Pubkey: testPubkey2, Mentions: 1, Replies: 1, Reactions: 1, Total: 3},
+		{Pubkey: testPubkey3, Reactions: 1, Total: 1},
+	}
+	if fmt.Sprint(interactions.Interactors) != fmt.Sprint(want) {
+		t.Errorf("interactors = %+v, want %+v", interactions.Interactors, want)
+	}
+	if interactions.Mentions != 1 || interactions.Replies != 1 || interactions.Reactions != 2 || interactions.Pubkeys != 2 {
+		t.Errorf("unexpected totals: %+v", interactions)
+	}
+
+	if limited, _ := db.GetInteractions(ctx, testPubkey1, time.Time{}, time.Time{}, 1); len(limited.Interactors) != 1 || limited.Pubkeys != 2 || limited.Mentions != 2 {
+		t.Errorf("expected one interactor of two all time, with the old mention, got %+v", limited)
+	}
+}
+
+func TestGetMemberHighlights(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	_, err := db.RelayDB().Exec(`
+		CREATE TABLE tag (
+			id INTEGER PRIMARY KEY,
+			event_id INTEGER NOT NULL,
+			name TEXT,
+			value TEXT,
+			FOREIGN KEY(event_id) REFERENCES event(id) ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		t.Fatalf("failed to create tag table: %v", err)
+	}
+
+	engager := testPubkey2
+	now := time.Now()
+	eventID := func(n byte) string { return strings.Repeat(hex.EncodeToString([]byte{n}), 32) }
+	tagEvent := func(id, name, value string) {
+		t.Helper()
+		idBytes, _ := hex.DecodeString(id)
+		if _, err := db.RelayDB().Exec(`INSERT INTO tag (event_id, name, value) SELECT id, ?, ? FROM event WHERE event_hash = ?`,
+			name, value, idBytes); err != nil {
+			t.Fatalf("failed to insert tag: %v", err)
+		}
+	}
+
+	popular, discussed, quiet, old := eventID(0x01), eventID(0x02), eventID(0x03), eventID(0x04)
+	insertTestEvent(t, db.RelayDB(), popular, testPubkey1, 1, now.Add(-3*time.Hour), "popular")
+	insertTestEvent(t, db.RelayDB(), discussed, testPubkey1, 1, now.Add(-2*time.Hour), "discussed")
+	insertTestEvent(t, db.RelayDB(), quiet, testPubkey1, 1, now.Add(-time.Hour), "quiet")
+	insertTestEvent(t, db.RelayDB(), old, testPubkey1, 1, now.AddDate(0, -2, 0), "old")
+
+	// Two reactions on popular, one reply on discussed, one on the old note
+	for i, target := range map[byte]string{0x11: popular, 0x12: popular, 0x14: old} {
+		insertTestEvent(t, db.RelayDB(), eventID(i), engager, 7, now, "+")
+		tagEvent(eventID(i), "e", target)
+	}
+	insertTestEvent(t, db.RelayDB(), eventID(0x13), engager, 1, now, "reply")
+	tagEvent(eventID(0x13), "e", discussed)
+	// The author's own reaction is not counted
+	insertTestEvent(t, db.RelayDB(), eventID(0x15), testPubkey1, 7, now, "+")
+	tagEvent(eventID(0x15), "e", discussed)
+
+	highlights, err := db.GetMemberHighlights(ctx, testPubkey1, now.AddDate(0, 0, -30), now, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if highlights.Notes != 3 || highlights.Reactions != 2 || highlights.Replies != 1 {
+		t.Errorf("unexpected totals: %+v", highlights)
+	}
+	if len(highlights.MostReacted) != 1 || highlights.MostReacted[0].ID != popular || highlights.MostReacted[0].Reactions != 2 {
+		t.Errorf("unexpected most reacted: %+v", highlights.MostReacted)
+	}
+	if len(highlights.MostReplied) != 1 || highlights.MostReplied[0].ID != discussed || highlights.MostReplied[0].Content != "discussed" {
+		t.Errorf("unexpected most replied: %+v", highlights.MostReplied)
+	}
+
+	t.Run("nil_relay", func(t *testing.T) {
+		db.relayDB.Store(nil)
+		if _, err := db.GetMemberHighlights(ctx, testPubkey1, time.Time{}, time.Time{}, 5); err == nil {
+			t.Error("expected error when relay database not connected")
+		}
+	})
+}
+
+func TestGetEventThread(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	eventID := func(n byte) string { return strings.Repeat(hex.EncodeToString([]byte{n}), 32) }
+	root, reply, nested, missing, orphan := eventID(0x01), eventID(0x02), eventID(0x03), eventID(0x04), eventID(0x05)
+
+	insertTestEvent(t, db.RelayDB(), root, testPubkey1, 1, now.Add(-3*time.Hour), "root")
+	// Positional tags: a reply to the root
+	insertTestEventWithTags(t, db.RelayDB(), reply, testPubkey2, 1, now.Add(-2*time.Hour), "reply",
+		[][]string{{"e", root}})
+	// Marked tags: a reply to the reply, mentioning another note
+	insertTestEventWithTags(t, db.RelayDB(), nested, testPubkey3, 1, now.Add(-time.Hour), "nested",
+		[][]string{{"e", root, "", "root"}, {"e", missing, "", "mention"}, {"e", reply, "", "reply"}})
+	// A reply to a note the relay doesn't have
+	insertTestEventWithTags(t, db.RelayDB(), orphan, testPubkey3, 1, now, "orphan",
+		[][]string{{"e", root, "", "root"}, {"e", missing, "", "reply"}})
+	// Reactions are not replies
+	insertTestEventWithTags(t, db.RelayDB(), eventID(0x06), testPubkey3, 7, now, "+",
+		[][]string{{"e", reply}})
+
+	ids := func(events []Event) string {
+		var got []string
+		for _, e := range events {
+			got = append(got, e.ID)
+		}
+		return strings.Join(got, ",")
+	}
+
+	thread, err := db.GetEventThread(ctx, nested, 100)
+	if err != nil {
+		t.Fatalf("GetEventThread() error = %v", err)
+	}
+	if thread.Event.ID != nested || ids(thread.Parents) != root+","+reply || len(thread.Replies) != 0 || len(thread.Missing) != 0 {
+		t.Errorf("unexpected thread for nested reply: %+v", thread)
+	}
+
+	thread, err = db.GetEventThread(ctx, root, 100)
+	if err != nil {
+		t.Fatalf("GetEventThread() error = %v", err)
+	}
+	if len(thread.Parents) != 0 || ids(thread.Replies) != reply {
+		t.Errorf("expected only the direct reply to the root, got %+v", thread)
+	}
+
+	thread, err = db.GetEventThread(ctx, reply, 100)
+	if err != nil {
+		t.Fatalf("GetEventThread() error = %v", err)
+	}
+	if ids(thread.Parents) != root || ids(thread.Replies) != nested {
+		t.Errorf("unexpected thread for reply: %+v", thread)
+	}
+
+	thread, err = db.GetEventThread(ctx, orphan, 100)
+	if err != nil {
+		t.Fatalf("GetEventThread() error = %v", err)
+	}
+	if ids(thread.Parents) != root || len(thread.Missing) != 1 || thread.Missing[0] != missing {
+		t.Errorf("expected the root and the missing parent, got %+v", thread)
+	}
+
+	thread, err = db.GetEventThread(ctx, missing, 100)
+	if err != nil || thread != nil {
+		t.Errorf("expected nil for an unknown event, got %+v, %v", thread, err)
+	}
+}
+
 // CallbackError is a test error type for StreamEvents callback testing.
 type CallbackError struct{}
 
 func (e *CallbackError) Error() string { return "callback error" }
 
+// ============================================================================
+// SearchEvents Tests
+// ============================================================================
+
+func TestSearchEvents(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now.Add(-3*time.Hour), "Running a Bitcoin node at home")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey2, 1, now.Add(-2*time.Hour), "bitcoin relays are fun")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey1, 30023, now.Add(-1*time.Hour), "Long-form notes on NODE operation and bitcoin")
+	// Term only appears in a tag, not the content
+	insertTestEventWithTags(t, db.RelayDB(), testEventID4, testPubkey2, 1, now, "gm", [][]string{{"t", "bitcoin"}})
+
+	t.Run("single term matches content only", func(t *testing.T) {
+		events, err := db.SearchEvents(ctx, "bitcoin", EventFilter{})
+		if err != nil {
+			t.Fatalf("SearchEvents failed: %v", err)
+		}
+		if len(events) != 3 {
+			t.Fatalf("expected 3 events, got %d", len(events))
+		}
+		if events[0].ID != testEventID3 {
+			t.Errorf("expected newest match first, got %s", events[0].ID)
+		}
+	})
+
+	t.Run("all terms must match", func(t *testing.T) {
+		events, err := db.SearchEvents(ctx, "bitcoin node", EventFilter{})
+		if err != nil {
+			t.Fatalf("SearchEvents failed: %v", err)
+		}
+		if len(events) != 2 {
+			t.Errorf("expected 2 events, got %d", len(events))
+		}
+	})
+
+	t.Run("scoped by kind and author with limit", func(t *testing.T) {
+		events, err := db.SearchEvents(ctx, "bitcoin", EventFilter{Authors: []string{testPubkey1}, Kinds: []int{1}, Limit: 1})
+		if err != nil {
+			t.Fatalf("SearchEvents failed: %v", err)
+		}
+		if len(events) != 1 || events[0].ID != testEventID1 {
+			t.Errorf("expected only %s, got %+v", testEventID1, events)
+		}
+	})
+
+	t.Run("extension tokens are ignored", func(t *testing.T) {
+		if terms := SearchTerms("Bitcoin language:en include:spam"); len(terms) != 1 || terms[0] != "bitcoin" {
+			t.Errorf("unexpected terms: %v", terms)
+		}
+		events, err := db.SearchEvents(ctx, "language:en", EventFilter{})
+		if err != nil {
+			t.Fatalf("SearchEvents failed: %v", err)
+		}
+		if len(events) != 0 {
+			t.Errorf("expected no events for an extension-only query, got %d", len(events))
+		}
+	})
+}
+
 // ============================================================================
 // Nil RelayDB Tests
 // ============================================================================
@@ -868,10 +1690,51 @@ func TestRelayReaderNilRelayDB(t *testing.T) {
 		}
 	})
 
+	t.Run("SearchEvents_nil_relay", func(t *testing.T) {
+		_, err := db.SearchEvents(ctx, "bitcoin", EventFilter{})
+		if err == nil {
+			t.Error("expected error for nil relay database")
+		}
+	})
+
 	t.Run("StreamEvents_nil_relay", func(t *testing.T) {
 		err := db.StreamEvents(ctx, EventFilter{}, func(e ExportEvent) error { return nil })
 		if err == nil {
 			t.Error("expected error for nil relay database")
 		}
 	})
+
+	t.Run("StreamEventsAfter_nil_relay", func(t *testing.T) {
+		err := db.StreamEventsAfter(ctx, 0, nil, func(rowID int64, e ExportEvent) error { return nil })
+		if err == nil {
+			t.Error("expected error for nil relay database")
+		}
+	})
+}
+
+func TestSetLimits(t *testing.T) {
+	db := setupTestRelayDB(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	insertTestEvent(t, db.RelayDB(), testEventID1, testPubkey1, 1, now, "First note")
+	insertTestEvent(t, db.RelayDB(), testEventID2, testPubkey1, 1, now.Add(-time.Hour), "Second note")
+	insertTestEvent(t, db.RelayDB(), testEventID3, testPubkey2, 1, now.Add(-2*time.Hour), "Third note")
+
+	if err := db.SetLimits(Limits{MaxQueryLimit: 2, CacheSizeKB: 1024, RelayReaders: 1}); err != nil {
+		t.Fatalf("SetLimits failed: %v", err)
+	}
+	events, err := db.GetEvents(ctx, EventFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("GetEvents failed: %v", err)
+	}
+	if len(events) != 2 || events[0].ID != testEventID1 {
+		t.Errorf("expected the 2 newest events, got %d", len(events))
+	}
+
+	// Unset limits fall back to the defaults
+	db.SetLimits(Limits{MaxQueryLimit: 2})
+	if got := db.Limits(); got.MaxQueryLimit != 2 || got.CacheSizeKB != DefaultLimits.CacheSizeKB || got.RelayReaders != DefaultLimits.RelayReaders {
+		t.Errorf("unexpected limits: %+v", got)
+	}
 }
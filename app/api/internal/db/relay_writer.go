@@ -5,33 +5,167 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrKindExcluded is returned by InsertEvent for kinds the operator has
+// excluded from storage.
+var ErrKindExcluded = errors.New("event kind is excluded from storage on this relay")
+
+// ErrTombstoned is returned by InsertEvent for events the deletion worker
+// removed.
+var ErrTombstoned = errors.New("event was deleted from this relay")
+
+// ErrEphemeral is returned by InsertEvent for ephemeral kinds, which relays
+// do not store.
+var ErrEphemeral = errors.New("ephemeral events are not stored")
+
+// ErrExpired is returned by InsertEvent for events past their NIP-40
+// expiration.
+var ErrExpired = errors.New("event has expired")
+
+// ErrSuperseded is returned by StoreEvent for a replaceable or addressable
+// event older than the version already stored.
+var ErrSuperseded = errors.New("a newer version of this replaceable event is stored")
+
+// relayImportBatch is how many inserted events a writer queues before
+// importing them into a relay backend.
+const relayImportBatch = 500
+
+// A write that finds the relay database locked for longer than the busy
+// timeout is tried again this many times, backing off from
+// relayWriteBackoff.
+const (
+	relayWriteRetries = 4
+	relayWriteBackoff = 250 * time.Millisecond
 )
 
 // RelayWriter provides write operations on the relay database.
-// These operations require a temporary read-write connection.
+// Writers share one read-write connection, so their writes are serialized,
+// and writes the relay keeps locked out are retried. With a relay
+// backend set, the relay database is Roostr's index of the backend's events:
+// deletions are passed on to the backend and inserted events are imported
+// into it in batches, the last when the writer is closed.
 type RelayWriter struct {
-	db *sql.DB
+	db            *sql.DB
+	dialect       relayDialect
+	excludedKinds map[int]bool
+	tombstoned    func(ctx context.Context, eventID string) (bool, error)
+	backend       RelayBackend
+	pending       []*Event
+	expiresAt     bool // The event table has nostr-rs-relay's expires_at column
+	tagTable      bool // The relay database has nostr-rs-relay's tag table
+
+	// Events refused for being ephemeral or expired, added to the
+	// expiration stats when the writer is closed
+	refusedEphemeral int64
+	refusedExpired   int64
+	recordRefused    func(ctx context.Context, ephemeral, expired int64) error
 }
 
-// NewRelayWriter opens a temporary read-write connection to the relay database.
+// NewRelayWriter returns a writer on the relay database's write connection.
+// The writer refuses to insert kinds excluded by the data residency policy
+// and events that were deleted by the deletion worker.
 // The caller must call Close() when done.
 func (d *DB) NewRelayWriter() (*RelayWriter, error) {
-	db, err := d.OpenRelayDBForWrite()
+	excluded, err := d.GetExcludedKinds(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	return &RelayWriter{db: db}, nil
+
+	db, err := d.relayWriteDB()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &RelayWriter{
+		db:            db,
+		dialect:       d.relayDialect(),
+		excludedKinds: make(map[int]bool, len(excluded)),
+		tombstoned:    d.IsTombstoned,
+		backend:       d.RelayBackend(),
+		recordRefused: d.RecordRefusedEvents,
+	}
+	w.expiresAt = w.dialect.hasColumn(context.Background(), db, "event", "expires_at")
+	w.tagTable = w.dialect.hasTable(context.Background(), db, "tag")
+	for _, kind := range excluded {
+		w.excludedKinds[kind] = true
+	}
+	return w, nil
 }
 
-// Close closes the relay writer connection.
+// Close imports any queued events into the relay backend and records the
+// ephemeral and expired events it refused. The shared write connection
+// stays open for other writers.
 func (w *RelayWriter) Close() error {
-	if w.db != nil {
-		return w.db.Close()
+	ctx := context.Background()
+	if w.recordRefused != nil && (w.refusedEphemeral > 0 || w.refusedExpired > 0) {
+		if err := w.recordRefused(ctx, w.refusedEphemeral, w.refusedExpired); err != nil {
+			slog.Warn("Failed to record refused events", "error", err)
+		}
+		w.refusedEphemeral, w.refusedExpired = 0, 0
 	}
-	return nil
+	return w.flush(ctx)
+}
+
+// isBusy reports whether err is SQLite failing to get a lock.
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// retryBusy runs fn, and again after a backoff each time it fails because
+// the relay database is locked.
+func retryBusy(ctx context.Context, fn func() error) error {
+	backoff := relayWriteBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isBusy(err) || attempt == relayWriteRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// exec runs a statement on the write connection, retrying while the relay
+// database is locked.
+func (w *RelayWriter) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := retryBusy(ctx, func() error {
+		var err error
+		result, err = w.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// deletesByRow reports whether deletions must select the events first:
+// to pass them on to a relay backend, or to build queries for a dialect
+// other than SQLite.
+func (w *RelayWriter) deletesByRow() bool {
+	return w.backend != nil || w.dialect.name() != "sqlite"
+}
+
+// flush imports queued events into the relay backend.
+func (w *RelayWriter) flush(ctx context.Context) error {
+	if w.backend == nil || len(w.pending) == 0 {
+		return nil
+	}
+	pending := w.pending
+	w.pending = nil
+	return w.backend.Import(ctx, pending)
 }
 
 // DeleteEventsBefore deletes events created before the given timestamp.
@@ -39,7 +173,7 @@ func (w *RelayWriter) Close() error {
 // Returns the number of deleted events.
 func (w *RelayWriter) DeleteEventsBefore(ctx context.Context, before time.Time, exceptions []string, operatorPubkey string) (int64, error) {
 	// Build the query with exceptions
-	query := "DELETE FROM event WHERE created_at < ?"
+	where := "created_at < " + w.dialect.timeArg()
 	args := []interface{}{before.Unix()}
 
 	// Parse exceptions
@@ -74,7 +208,7 @@ func (w *RelayWriter) DeleteEventsBefore(ctx context.Context, before time.Time,
 			placeholders[i] = "?"
 			args = append(args, kind)
 		}
-		query += fmt.Sprintf(" AND kind NOT IN (%s)", strings.Join(placeholders, ","))
+		where += fmt.Sprintf(" AND kind NOT IN (%s)", strings.Join(placeholders, ","))
 	}
 
 	// Add pubkey exceptions (nostr-rs-relay uses 'author' column)
@@ -84,10 +218,13 @@ func (w *RelayWriter) DeleteEventsBefore(ctx context.Context, before time.Time,
 			placeholders[i] = "?"
 			args = append(args, pubkey)
 		}
-		query += fmt.Sprintf(" AND author NOT IN (%s)", strings.Join(placeholders, ","))
+		where += fmt.Sprintf(" AND %s NOT IN (%s)", w.dialect.authorColumn(), strings.Join(placeholders, ","))
 	}
 
-	result, err := w.db.ExecContext(ctx, query, args...)
+	if w.deletesByRow() {
+		return w.deleteEvents(ctx, where, args, "")
+	}
+	result, err := w.exec(ctx, "DELETE FROM event WHERE "+where, args...)
 	if err != nil {
 		return 0, fmt.Errorf("failed to delete events: %w", err)
 	}
@@ -100,6 +237,216 @@ func (w *RelayWriter) DeleteEventsBefore(ctx context.Context, before time.Time,
 	return count, nil
 }
 
+// RetentionScope selects the events one retention rule deletes: those created
+// before Before, of the given kinds or, if Kinds is empty, of every kind not
+// in ExcludeKinds. Events matching the exceptions are always kept.
+type RetentionScope struct {
+	Before       time.Time
+	Kinds        []int
+	ExcludeKinds []int
+	Exceptions   []string // e.g., ["kind:0", "pubkey:operator"]
+}
+
+// where returns the WHERE clause selecting the scope's events and its
+// arguments. "pubkey:operator" exceptions resolve to operatorPubkey.
+func (s RetentionScope) where(dl relayDialect, operatorPubkey string) (string, []interface{}) {
+	clause := "created_at < " + dl.timeArg()
+	args := []interface{}{s.Before.Unix()}
+
+	excludeKinds := append([]int(nil), s.ExcludeKinds...)
+	var excludeAuthors [][]byte
+	for _, exc := range s.Exceptions {
+		switch {
+		case strings.HasPrefix(exc, "kind:"):
+			var kind int
+			if _, err := fmt.Sscanf(exc, "kind:%d", &kind); err == nil {
+				excludeKinds = append(excludeKinds, kind)
+			}
+		case strings.HasPrefix(exc, "pubkey:"):
+			pubkey := strings.TrimPrefix(exc, "pubkey:")
+			if pubkey == "operator" {
+				pubkey = operatorPubkey
+			}
+			if pubkeyBytes, err := hex.DecodeString(pubkey); err == nil && len(pubkeyBytes) > 0 {
+				excludeAuthors = append(excludeAuthors, pubkeyBytes)
+			}
+		}
+	}
+
+	// Restrict to the rule's kinds
+	if len(s.Kinds) > 0 {
+		placeholders := make([]string, len(s.Kinds))
+		for i, kind := range s.Kinds {
+			placeholders[i] = "?"
+			args = append(args, kind)
+		}
+		clause += fmt.Sprintf(" AND kind IN (%s)", strings.Join(placeholders, ","))
+	}
+
+	// Add kind exceptions
+	if len(excludeKinds) > 0 {
+		placeholders := make([]string, len(excludeKinds))
+		for i, kind := range excludeKinds {
+			placeholders[i] = "?"
+			args = append(args, kind)
+		}
+		clause += fmt.Sprintf(" AND kind NOT IN (%s)", strings.Join(placeholders, ","))
+	}
+
+	// Add pubkey exceptions (nostr-rs-relay uses 'author' column)
+	if len(excludeAuthors) > 0 {
+		placeholders := make([]string, len(excludeAuthors))
+		for i, pubkey := range excludeAuthors {
+			placeholders[i] = "?"
+			args = append(args, pubkey)
+		}
+		clause += fmt.Sprintf(" AND %s NOT IN (%s)", dl.authorColumn(), strings.Join(placeholders, ","))
+	}
+
+	return clause, args
+}
+
+// DeleteRetentionScopeBatch deletes up to limit of the events a retention
+// rule selects, and their tags, in one short transaction so the live relay
+// can write between batches. Returns the number of deleted events; fewer
+// than limit means none are left.
+func (w *RelayWriter) DeleteRetentionScopeBatch(ctx context.Context, scope RetentionScope, operatorPubkey string, limit int) (int64, error) {
+	where, args := scope.where(w.dialect, operatorPubkey)
+	return w.deleteEvents(ctx, where, append(args, limit), " LIMIT ?")
+}
+
+// DeleteEventsByIDsBatch deletes specific events by their IDs, and their
+// tags, in one transaction.
+// Returns the number of deleted events.
+func (w *RelayWriter) DeleteEventsByIDsBatch(ctx context.Context, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		idBytes, err := hex.DecodeString(id)
+		if err != nil {
+			return 0, fmt.Errorf("invalid event ID %s: %w", id, err)
+		}
+		placeholders[i] = "?"
+		args[i] = idBytes
+	}
+
+	return w.deleteEvents(ctx, fmt.Sprintf("%s IN (%s)", w.dialect.idColumn(), strings.Join(placeholders, ",")), args, "")
+}
+
+// deleteEvents deletes the events matching where, and their rows in the
+// relay's tag table, in one transaction. nostr-rs-relay relies on a cascading
+// foreign key for tags, which is off on this connection.
+func (w *RelayWriter) deleteEvents(ctx context.Context, where string, args []interface{}, suffix string) (int64, error) {
+	return w.deleteRows(ctx, where, args, suffix, true)
+}
+
+// deleteRows implements deleteEvents. With passThrough, the events are
+// deleted from the relay backend before the transaction commits, so the
+// index keeps them if the backend fails. A transaction the relay keeps
+// locked out is tried again.
+func (w *RelayWriter) deleteRows(ctx context.Context, where string, args []interface{}, suffix string, passThrough bool) (int64, error) {
+	var count int64
+	err := retryBusy(ctx, func() error {
+		var err error
+		count, err = w.deleteRowsTx(ctx, where, args, suffix, passThrough)
+		return err
+	})
+	return count, err
+}
+
+func (w *RelayWriter) deleteRowsTx(ctx context.Context, where string, args []interface{}, suffix string, passThrough bool) (int64, error) {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Tags reference the row id: an integer in SQLite, the event ID in Postgres
+	dl := w.dialect
+	rows, err := tx.QueryContext(ctx, dl.bind("SELECT id, "+dl.idColumn()+" FROM event WHERE "+where+suffix), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select events: %w", err)
+	}
+	var rowIDs []interface{}
+	var eventIDs []string
+	for rows.Next() {
+		var id interface{}
+		var hash []byte
+		if err := rows.Scan(&id, &hash); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to select events: %w", err)
+		}
+		rowIDs = append(rowIDs, id)
+		eventIDs = append(eventIDs, hex.EncodeToString(hash))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to select events: %w", err)
+	}
+	if len(rowIDs) == 0 {
+		return 0, nil
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?,", len(rowIDs)), ",")
+	if dl.hasTable(ctx, tx, "tag") {
+		if _, err := tx.ExecContext(ctx, dl.bind("DELETE FROM tag WHERE event_id IN ("+in+")"), rowIDs...); err != nil {
+			return 0, fmt.Errorf("failed to delete tags: %w", err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, dl.bind("DELETE FROM event WHERE id IN ("+in+")"), rowIDs...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete events: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	// Events still queued for import are not in the backend yet; they are
+	// dropped from the queue instead, once the deletion commits
+	var queued map[string]bool
+	if passThrough && w.backend != nil {
+		var stored []string
+		stored, queued = w.splitQueued(eventIDs)
+		if len(stored) > 0 {
+			if err := w.backend.Delete(ctx, stored); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit deletion: %w", err)
+	}
+	if len(queued) > 0 {
+		w.pending = slices.DeleteFunc(w.pending, func(e *Event) bool { return queued[strings.ToLower(e.ID)] })
+	}
+	return count, nil
+}
+
+// splitQueued separates the IDs of events queued for import into the relay
+// backend from those of events it already has.
+func (w *RelayWriter) splitQueued(ids []string) (stored []string, queued map[string]bool) {
+	pending := make(map[string]bool, len(w.pending))
+	for _, e := range w.pending {
+		pending[strings.ToLower(e.ID)] = true
+	}
+	queued = make(map[string]bool)
+	for _, id := range ids {
+		if pending[id] {
+			queued[id] = true
+		} else {
+			stored = append(stored, id)
+		}
+	}
+	return stored, queued
+}
+
 // DeleteEventsByIDs deletes specific events by their IDs.
 // Returns the number of deleted events.
 func (w *RelayWriter) DeleteEventsByIDs(ctx context.Context, ids []string) (int64, error) {
@@ -119,8 +466,11 @@ func (w *RelayWriter) DeleteEventsByIDs(ctx context.Context, ids []string) (int6
 		args[i] = idBytes
 	}
 
-	query := fmt.Sprintf("DELETE FROM event WHERE event_hash IN (%s)", strings.Join(placeholders, ","))
-	result, err := w.db.ExecContext(ctx, query, args...)
+	where := fmt.Sprintf("%s IN (%s)", w.dialect.idColumn(), strings.Join(placeholders, ","))
+	if w.deletesByRow() {
+		return w.deleteEvents(ctx, where, args, "")
+	}
+	result, err := w.exec(ctx, "DELETE FROM event WHERE "+where, args...)
 	if err != nil {
 		return 0, fmt.Errorf("failed to delete events: %w", err)
 	}
@@ -133,6 +483,32 @@ func (w *RelayWriter) DeleteEventsByIDs(ctx context.Context, ids []string) (int6
 	return count, nil
 }
 
+// DeleteEventsByKinds deletes all events of the given kinds.
+// Returns the number of deleted events per kind.
+func (w *RelayWriter) DeleteEventsByKinds(ctx context.Context, kinds []int) (map[int]int64, error) {
+	deleted := make(map[int]int64)
+	for _, kind := range kinds {
+		if w.deletesByRow() {
+			n, err := w.deleteEvents(ctx, "kind = ?", []interface{}{kind}, "")
+			if err != nil {
+				return deleted, fmt.Errorf("failed to delete kind %d: %w", kind, err)
+			}
+			if n > 0 {
+				deleted[kind] = n
+			}
+			continue
+		}
+		result, err := w.exec(ctx, `DELETE FROM event WHERE kind = ?`, kind)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete kind %d: %w", kind, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			deleted[kind] = n
+		}
+	}
+	return deleted, nil
+}
+
 // GetEventAuthor returns the author pubkey of an event by ID.
 // Returns empty string if not found.
 func (w *RelayWriter) GetEventAuthor(ctx context.Context, eventID string) (string, error) {
@@ -142,7 +518,8 @@ func (w *RelayWriter) GetEventAuthor(ctx context.Context, eventID string) (strin
 	}
 
 	var pubkeyBytes []byte
-	err = w.db.QueryRowContext(ctx, "SELECT author FROM event WHERE event_hash = ?", idBytes).Scan(&pubkeyBytes)
+	dl := w.dialect
+	err = w.db.QueryRowContext(ctx, dl.bind(fmt.Sprintf("SELECT %s FROM event WHERE %s = ?", dl.authorColumn(), dl.idColumn())), idBytes).Scan(&pubkeyBytes)
 	if err == sql.ErrNoRows {
 		return "", nil
 	}
@@ -155,8 +532,7 @@ func (w *RelayWriter) GetEventAuthor(ctx context.Context, eventID string) (strin
 
 // RunVacuum runs VACUUM on the relay database to reclaim space.
 func (w *RelayWriter) RunVacuum(ctx context.Context) error {
-	_, err := w.db.ExecContext(ctx, "VACUUM")
-	if err != nil {
+	if err := retryBusy(ctx, func() error { return w.dialect.vacuum(ctx, w.db) }); err != nil {
 		return fmt.Errorf("failed to vacuum relay database: %w", err)
 	}
 	return nil
@@ -165,41 +541,55 @@ func (w *RelayWriter) RunVacuum(ctx context.Context) error {
 // RunIntegrityCheck runs an integrity check on the relay database.
 // Returns true if the database is healthy, along with the result message.
 func (w *RelayWriter) RunIntegrityCheck(ctx context.Context) (bool, string, error) {
-	var result string
-	err := w.db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result)
+	ok, result, err := w.dialect.integrityCheck(ctx, w.db)
 	if err != nil {
 		return false, "", fmt.Errorf("failed to run integrity check: %w", err)
 	}
-	return result == "ok", result, nil
+	return ok, result, nil
 }
 
 // GetDatabaseSizeBeforeAfterVacuum calculates the size difference after VACUUM.
 // This is a helper that doesn't actually run VACUUM.
 func (w *RelayWriter) GetPageInfo(ctx context.Context) (pageCount int64, freePages int64, pageSize int64, err error) {
-	err = w.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to get page count: %w", err)
-	}
-
-	err = w.db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&freePages)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to get free page count: %w", err)
-	}
-
-	err = w.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to get page size: %w", err)
-	}
-
-	return pageCount, freePages, pageSize, nil
+	return w.dialect.pageInfo(ctx, w.db)
 }
 
 // InsertEvent inserts a Nostr event into the relay database.
 // Uses INSERT OR IGNORE to handle duplicates gracefully.
 // Returns true if the event was inserted (new), false if it already existed.
+// Returns ErrKindExcluded if the event's kind is excluded from storage,
+// ErrEphemeral for ephemeral kinds, ErrExpired if the event's NIP-40
+// expiration has passed, and ErrTombstoned if the event was deleted.
 // Note: nostr-rs-relay stores events with event_hash (id), author (pubkey),
 // created_at, kind, and content (full serialized event JSON).
 func (w *RelayWriter) InsertEvent(ctx context.Context, event *Event) (bool, error) {
+	if w.excludedKinds[event.Kind] {
+		return false, ErrKindExcluded
+	}
+	if IsEphemeralKind(event.Kind) {
+		w.refusedEphemeral++
+		return false, ErrEphemeral
+	}
+	if IsExpired(event.Tags, time.Now()) {
+		w.refusedExpired++
+		return false, ErrExpired
+	}
+	if w.tombstoned != nil {
+		tombstoned, err := w.tombstoned(ctx, event.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed to check tombstones: %w", err)
+		}
+		if tombstoned {
+			return false, ErrTombstoned
+		}
+	}
+	if w.backend != nil {
+		return w.queueEvent(ctx, event)
+	}
+	if w.dialect.name() != "sqlite" {
+		return false, ErrUnsupportedOnPostgres
+	}
+
 	// Convert hex ID to bytes
 	idBytes, err := hex.DecodeString(event.ID)
 	if err != nil {
@@ -229,19 +619,209 @@ func (w *RelayWriter) InsertEvent(ctx context.Context, event *Event) (bool, erro
 
 	// Insert with nostr-rs-relay schema: event_hash, first_seen, author, created_at, kind, content
 	// first_seen = when we received the event (now)
+	// and, where the relay has the column, expires_at for the expiration index
 	firstSeen := time.Now().Unix()
-	result, err := w.db.ExecContext(ctx, `
-		INSERT OR IGNORE INTO event (event_hash, first_seen, created_at, author, kind, content)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, idBytes, firstSeen, event.CreatedAt.Unix(), pubkeyBytes, event.Kind, string(contentJSON))
+	columns, values := "event_hash, first_seen, created_at, author, kind, content", "?, ?, ?, ?, ?, ?"
+	args := []interface{}{idBytes, firstSeen, event.CreatedAt.Unix(), pubkeyBytes, event.Kind, string(contentJSON)}
+	if w.expiresAt {
+		columns, values = columns+", expires_at", values+", ?"
+		args = append(args, expiresAt(event.Tags))
+	}
+	query := fmt.Sprintf(`
+		INSERT OR IGNORE INTO event (%s)
+		VALUES (%s)
+	`, columns, values)
+
+	var inserted bool
+	err = retryBusy(ctx, func() error {
+		var err error
+		inserted, err = w.insertEventTx(ctx, query, args, event.Tags)
+		return err
+	})
+	return inserted, err
+}
+
+// insertEventTx inserts an event and, like nostr-rs-relay, a tag table row
+// for each single-letter tag, so queries that join on tags find it.
+func (w *RelayWriter) insertEventTx(ctx context.Context, query string, args []interface{}, tags [][]string) (bool, error) {
+	tx, err := w.db.BeginTx(ctx, nil)
 	if err != nil {
-		return false, fmt.Errorf("failed to insert event: %w", err)
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert event: %w", err)
+	}
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return false, fmt.Errorf("failed to get affected rows: %w", err)
 	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	if w.tagTable {
+		rowID, err := result.LastInsertId()
+		if err != nil {
+			return false, fmt.Errorf("failed to insert event: %w", err)
+		}
+		for _, tag := range tags {
+			if len(tag) < 2 || len(tag[0]) != 1 {
+				continue
+			}
+			value, valueHex := tagValue(tag[1])
+			if _, err := tx.ExecContext(ctx, `INSERT INTO tag (event_id, name, value, value_hex) VALUES (?, ?, ?, ?)`, rowID, tag[0], value, valueHex); err != nil {
+				return false, fmt.Errorf("failed to insert tags: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit event: %w", err)
+	}
+	return true, nil
+}
+
+// tagValue returns the tag table's value and value_hex for a tag value:
+// nostr-rs-relay stores even-length lowercase hex as bytes in value_hex and
+// anything else as text in value.
+func tagValue(v string) (interface{}, interface{}) {
+	if v != "" && len(v)%2 == 0 && strings.ToLower(v) == v {
+		if b, err := hex.DecodeString(v); err == nil {
+			return nil, b
+		}
+	}
+	return v, nil
+}
+
+// InsertResult is the outcome of StoreEvent.
+type InsertResult struct {
+	Inserted bool  // The event was new
+	Replaced int64 // Older versions of a replaceable event that were deleted
+}
+
+// StoreEvent inserts an event like InsertEvent, applying NIP-01 replacement:
+// for replaceable kinds (0, 3 and 10000-19999) and addressable kinds
+// (30000-39999), only the newest event per pubkey, kind and, for
+// addressable kinds, d tag is kept. Older stored versions are deleted once
+// the event is inserted, and ErrSuperseded is returned if a newer one is
+// already stored. Versions created in the same second are ordered by ID,
+// the lowest winning.
+func (w *RelayWriter) StoreEvent(ctx context.Context, event *Event) (InsertResult, error) {
+	address, ok := replaceableAddress(event.Kind, event.Tags)
+	if !ok || w.excludedKinds[event.Kind] {
+		inserted, err := w.InsertEvent(ctx, event)
+		return InsertResult{Inserted: inserted}, err
+	}
+
+	versions, err := w.storedVersions(ctx, event.Pubkey, event.Kind, address)
+	if err != nil {
+		return InsertResult{}, err
+	}
+	var stale []string
+	for _, v := range versions {
+		switch {
+		case v.ID == event.ID:
+			return InsertResult{}, nil
+		case v.CreatedAt > event.CreatedAt.Unix(), v.CreatedAt == event.CreatedAt.Unix() && v.ID < event.ID:
+			return InsertResult{}, ErrSuperseded
+		}
+		stale = append(stale, v.ID)
+	}
+
+	inserted, err := w.InsertEvent(ctx, event)
+	if err != nil || !inserted || len(stale) == 0 {
+		return InsertResult{Inserted: inserted}, err
+	}
+	replaced, err := w.DeleteEventsByIDsBatch(ctx, stale)
+	if err != nil {
+		return InsertResult{Inserted: true}, fmt.Errorf("failed to delete replaced versions: %w", err)
+	}
+	return InsertResult{Inserted: true, Replaced: replaced}, nil
+}
+
+// replaceableAddress returns the d tag that, with the pubkey and kind,
+// identifies versions of a replaceable or addressable event; it is empty
+// for replaceable kinds. It returns false for other kinds.
+func replaceableAddress(kind int, tags [][]string) (string, bool) {
+	switch {
+	case kind == 0 || kind == 3 || (kind >= 10000 && kind < 20000):
+		return "", true
+	case kind >= 30000 && kind < 40000:
+		for _, tag := range tags {
+			if len(tag) >= 2 && tag[0] == "d" {
+				return tag[1], true
+			}
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// storedVersion is a stored version of a replaceable event.
+type storedVersion struct {
+	ID        string
+	CreatedAt int64
+}
+
+// storedVersions returns the stored versions of the replaceable event with
+// a pubkey, kind and address. Addresses are read from the stored JSON,
+// since events Roostr wrote before it indexed tags have no rows in the tag
+// table.
+func (w *RelayWriter) storedVersions(ctx context.Context, pubkey string, kind int, address string) ([]storedVersion, error) {
+	pubkeyBytes, err := hex.DecodeString(pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %w", err)
+	}
 
-	return rows > 0, nil
+	dl := w.dialect
+	rows, err := w.db.QueryContext(ctx, dl.bind(fmt.Sprintf(`
+		SELECT %s
+		FROM event
+		WHERE %s = ? AND kind = ?
+	`, eventColumns(dl), dl.authorColumn())), pubkeyBytes, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stored versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []storedVersion
+	for rows.Next() {
+		var idCol, author []byte
+		var createdAt int64
+		var storedKind int
+		var content string
+		if err := rows.Scan(&idCol, &author, &createdAt, &storedKind, &content); err != nil {
+			return nil, fmt.Errorf("failed to find stored versions: %w", err)
+		}
+		if kind >= 30000 {
+			var stored nostrEventJSON
+			if err := json.Unmarshal([]byte(content), &stored); err != nil {
+				continue
+			}
+			if a, _ := replaceableAddress(kind, stored.Tags); a != address {
+				continue
+			}
+		}
+		versions = append(versions, storedVersion{ID: hex.EncodeToString(idCol), CreatedAt: createdAt})
+	}
+	return versions, rows.Err()
+}
+
+// queueEvent indexes an event and queues it for import into the relay
+// backend.
+func (w *RelayWriter) queueEvent(ctx context.Context, event *Event) (bool, error) {
+	inserted, err := w.indexEvent(ctx, event)
+	if err != nil || !inserted {
+		return inserted, err
+	}
+	w.pending = append(w.pending, event)
+	if len(w.pending) >= relayImportBatch {
+		if err := w.flush(ctx); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
 }
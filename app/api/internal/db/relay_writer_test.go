@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRelayWriter returns a writer on an empty relay database.
+func newTestRelayWriter(t *testing.T) (*DB, *RelayWriter) {
+	t.Helper()
+	dir := t.TempDir()
+	relayPath := filepath.Join(dir, "nostr.db")
+	conn, err := sql.Open("sqlite3", relayPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(relayIndexSchema); err != nil {
+		t.Fatalf("failed to create relay schema: %v", err)
+	}
+	conn.Close()
+
+	database, err := New(relayPath, filepath.Join(dir, "roostr.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	w, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter failed: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return database, w
+}
+
+func TestStoreEventReplaceable(t *testing.T) {
+	ctx := context.Background()
+	database, w := newTestRelayWriter(t)
+
+	pubkey := strings.Repeat("a", 64)
+	n := 0
+	event := func(kind int, createdAt int64, tags ...[]string) *Event {
+		n++
+		return &Event{
+			ID:        fmt.Sprintf("%064x", n),
+			Pubkey:    pubkey,
+			CreatedAt: time.Unix(createdAt, 0),
+			Kind:      kind,
+			Tags:      tags,
+		}
+	}
+	store := func(e *Event) InsertResult {
+		t.Helper()
+		result, err := w.StoreEvent(ctx, e)
+		if err != nil {
+			t.Fatalf("StoreEvent(kind %d at %d) failed: %v", e.Kind, e.CreatedAt.Unix(), err)
+		}
+		return result
+	}
+	count := func(kind int) int64 {
+		t.Helper()
+		n, err := database.CountEvents(ctx, EventFilter{Kinds: []int{kind}})
+		if err != nil {
+			t.Fatalf("CountEvents failed: %v", err)
+		}
+		return n
+	}
+
+	// Newer profiles replace older ones; older ones are refused
+	store(event(0, 100))
+	if result := store(event(0, 200)); !result.Inserted || result.Replaced != 1 {
+		t.Errorf("expected a newer profile to replace the first, got %+v", result)
+	}
+	if _, err := w.StoreEvent(ctx, event(0, 150)); !errors.Is(err, ErrSuperseded) {
+		t.Errorf("expected ErrSuperseded for an older profile, got %v", err)
+	}
+	latest := event(0, 300)
+	if result := store(latest); !result.Inserted || result.Replaced != 1 {
+		t.Errorf("expected the newest profile to replace the previous one, got %+v", result)
+	}
+	if got := count(0); got != 1 {
+		t.Errorf("expected 1 stored profile, got %d", got)
+	}
+	if result := store(latest); result.Inserted || result.Replaced != 0 {
+		t.Errorf("expected a duplicate to be skipped, got %+v", result)
+	}
+
+	// In the same second the lowest ID wins
+	tie := event(0, 300)
+	if _, err := w.StoreEvent(ctx, tie); !errors.Is(err, ErrSuperseded) {
+		t.Errorf("expected ErrSuperseded for a higher ID in the same second, got %v", err)
+	}
+
+	// Addressable events are replaced per d tag
+	store(event(30023, 100, []string{"d", "first"}))
+	store(event(30023, 100, []string{"d", "second"}))
+	if result := store(event(30023, 200, []string{"d", "first"})); result.Replaced != 1 {
+		t.Errorf("expected one version of the first article replaced, got %+v", result)
+	}
+	if got := count(30023); got != 2 {
+		t.Errorf("expected 2 stored articles, got %d", got)
+	}
+
+	// Regular events are never replaced
+	store(event(1, 100))
+	if result := store(event(1, 200)); result.Replaced != 0 {
+		t.Errorf("expected notes not to be replaced, got %+v", result)
+	}
+	if got := count(1); got != 2 {
+		t.Errorf("expected 2 stored notes, got %d", got)
+	}
+}
+
+func TestInsertEventEphemeralAndExpired(t *testing.T) {
+	ctx := context.Background()
+	database, w := newTestRelayWriter(t)
+
+	event := func(n, kind int, tags ...[]string) *Event {
+		return &Event{
+			ID:        fmt.Sprintf("%064x", n),
+			Pubkey:    strings.Repeat("a", 64),
+			CreatedAt: time.Now(),
+			Kind:      kind,
+			Tags:      tags,
+		}
+	}
+	expires := func(d time.Duration) []string {
+		return []string{"expiration", fmt.Sprint(time.Now().Add(d).Unix())}
+	}
+
+	if _, err := w.InsertEvent(ctx, event(1, 20001)); !errors.Is(err, ErrEphemeral) {
+		t.Errorf("expected ErrEphemeral for kind 20001, got %v", err)
+	}
+	if _, err := w.InsertEvent(ctx, event(2, 1, expires(-time.Minute))); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired for an expired note, got %v", err)
+	}
+	if inserted, err := w.InsertEvent(ctx, event(3, 1, expires(time.Hour))); err != nil || !inserted {
+		t.Errorf("expected a note expiring later to be stored, got %v, %v", inserted, err)
+	}
+	if inserted, err := w.InsertEvent(ctx, event(4, 30000)); err != nil || !inserted {
+		t.Errorf("expected kind 30000 to be stored, got %v, %v", inserted, err)
+	}
+
+	// Refusals are counted when the writer is closed
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	stats, err := database.GetExpirationStats(ctx)
+	if err != nil || stats.RefusedEphemeral != 1 || stats.RefusedExpired != 1 {
+		t.Errorf("expected one ephemeral and one expired refusal, got %+v, %v", stats, err)
+	}
+
+	// The stored note is found through expires_at once it expires, and so
+	// is one stored without it after the backfill
+	later := time.Now().Add(2 * time.Hour)
+	if ids, err := database.GetExpiredEventIDs(ctx, later, 10); err != nil || len(ids) != 1 || ids[0] != fmt.Sprintf("%064x", 3) {
+		t.Errorf("expected event 3 to expire, got %v, %v", ids, err)
+	}
+	if _, err := w.db.Exec(`UPDATE event SET expires_at = NULL`); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := database.GetExpiredEventIDs(ctx, later, 10); len(ids) != 0 {
+		t.Errorf("expected no expired events without expires_at, got %v", ids)
+	}
+	if n, err := w.BackfillExpirations(ctx); err != nil || n != 1 {
+		t.Errorf("expected one expiration backfilled, got %d, %v", n, err)
+	}
+	if ids, _ := database.GetExpiredEventIDs(ctx, later, 10); len(ids) != 1 {
+		t.Errorf("expected the backfilled event to expire, got %v", ids)
+	}
+}
+
+func TestInsertEventIndexesTags(t *testing.T) {
+	ctx := context.Background()
+	database, w := newTestRelayWriter(t)
+
+	alice, bob := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	note := &Event{ID: fmt.Sprintf("%064x", 1), Pubkey: alice, CreatedAt: time.Now().Add(-time.Minute), Kind: 1}
+	reply := &Event{ID: fmt.Sprintf("%064x", 2), Pubkey: bob, CreatedAt: time.Now(), Kind: 1, Tags: [][]string{
+		{"e", note.ID}, {"p", alice}, {"t", "Nostr"}, {"client", "roostr"},
+	}}
+	for _, event := range []*Event{note, reply} {
+		if _, err := w.InsertEvent(ctx, event); err != nil {
+			t.Fatalf("InsertEvent failed: %v", err)
+		}
+	}
+
+	// Hex values go in value_hex and others in value, like nostr-rs-relay;
+	// multi-letter tags are not indexed
+	var hexTags, textTags int
+	w.db.QueryRow(`SELECT COUNT(value_hex), COUNT(value) FROM tag`).Scan(&hexTags, &textTags)
+	if hexTags != 2 || textTags != 1 {
+		t.Errorf("expected 2 hex and 1 text tag rows, got %d and %d", hexTags, textTags)
+	}
+
+	interactions, err := database.GetInteractions(ctx, alice, time.Time{}, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("GetInteractions failed: %v", err)
+	}
+	if interactions.Replies != 1 || len(interactions.Interactors) != 1 || interactions.Interactors[0].Pubkey != bob {
+		t.Errorf("expected bob's reply to be counted, got %+v", interactions)
+	}
+}
@@ -133,7 +133,7 @@ CREATE INDEX IF NOT EXISTS idx_deletion_requests_author ON deletion_requests(aut
 -- History of sync operations from public relays
 CREATE TABLE IF NOT EXISTS sync_jobs (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
-    status TEXT NOT NULL DEFAULT 'running',  -- running, completed, failed, cancelled
+    status TEXT NOT NULL DEFAULT 'running',  -- running, paused, completed, failed, cancelled
     pubkeys TEXT NOT NULL,              -- JSON array of pubkeys being synced
     relays TEXT NOT NULL,               -- JSON array of source relay URLs
     event_kinds TEXT,                   -- JSON array of event kinds to sync (NULL = all)
@@ -216,7 +216,8 @@ CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
 -- Track schema migrations
 CREATE TABLE IF NOT EXISTS schema_version (
     version INTEGER PRIMARY KEY,
-    applied_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+    applied_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    checksum TEXT                         -- SHA-256 of the migration's SQL; NULL for the initial schema
 );
 
 -- Mark initial schema version
@@ -0,0 +1,623 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The full-text search index lives in search.db next to the app database. It
+// maps relay event row IDs to their content, so it can be rebuilt from the
+// relay database at any time and is left out of backups.
+const searchIndexSchema = `
+CREATE TABLE IF NOT EXISTS search_event (
+	id INTEGER PRIMARY KEY, -- relay event row ID
+	author BLOB NOT NULL,
+	kind INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS search_event_author ON search_event(author);
+CREATE INDEX IF NOT EXISTS search_event_created ON search_event(created_at);
+CREATE TABLE IF NOT EXISTS search_state (
+	key TEXT PRIMARY KEY,
+	value INTEGER NOT NULL
+);
+`
+
+// FTS5 is compiled into go-sqlite3 with the sqlite_fts5 build tag, which
+// release builds set. Other builds fall back to FTS4.
+const (
+	searchFTS5Table = `CREATE VIRTUAL TABLE IF NOT EXISTS event_fts USING fts5(
+	content, content='', contentless_delete=1, tokenize='unicode61 remove_diacritics 2')`
+	searchFTS4Table = `CREATE VIRTUAL TABLE IF NOT EXISTS event_fts USING fts4(
+	content, tokenize=unicode61 "remove_diacritics=2")`
+)
+
+const (
+	// searchIndexBatch is how many events are indexed per transaction.
+	searchIndexBatch = 1000
+
+	// searchPruneBatch is how many indexed events are checked against the
+	// relay database per query when pruning.
+	searchPruneBatch = 500
+
+	// searchRankCandidates bounds how many of the newest matches FTS4 ranks;
+	// FTS5 ranks every match in SQL.
+	searchRankCandidates = 1000
+)
+
+// ErrInvalidSearchQuery is returned for a query with no searchable terms.
+var ErrInvalidSearchQuery = errors.New("search query has no terms")
+
+// ErrSearchIndexUnavailable is returned when the search index cannot be
+// opened, so callers can fall back to scanning event content.
+var ErrSearchIndexUnavailable = errors.New("search index unavailable")
+
+// FullTextQuery is a full-text search over event content. Query holds
+// words, which must all appear, and "quoted phrases", which must appear in
+// order. The remaining fields scope the search.
+type FullTextQuery struct {
+	Query   string
+	Authors []string
+	Kinds   []int
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+	Offset  int
+}
+
+// SearchResult is an event matched by a full-text search.
+type SearchResult struct {
+	Event
+	Rank float64 `json:"rank"` // Relevance; higher is better
+}
+
+// SearchIndexStatus reports how far the search index has caught up.
+type SearchIndexStatus struct {
+	Engine  string `json:"engine"`         // fts5 or fts4
+	Indexed int64  `json:"indexed_events"` // Events in the index
+	Cursor  int64  `json:"cursor"`         // Last relay row ID indexed
+	Latest  int64  `json:"latest_row_id"`  // Newest relay row ID
+}
+
+// searchIndex returns the search index, opening it on first use.
+func (d *DB) searchIndex() (*sql.DB, string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.searchDB != nil {
+		return d.searchDB, d.searchEngine, nil
+	}
+
+	path := filepath.Join(filepath.Dir(d.appPath), "search.db")
+	conn, engine, err := openSearchIndex(path)
+	if errors.Is(err, errSearchEngineChanged) {
+		// Built by a binary with the other FTS version; start over
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Remove(path + suffix)
+		}
+		conn, engine, err = openSearchIndex(path)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	d.searchDB = conn
+	d.searchEngine = engine
+	return conn, engine, nil
+}
+
+// errSearchEngineChanged means the index on disk uses an FTS version this
+// build lacks.
+var errSearchEngineChanged = errors.New("search index uses an unavailable FTS version")
+
+// openSearchIndex opens the search index at path, creating it with FTS5 if
+// available and FTS4 otherwise.
+func openSearchIndex(path string) (*sql.DB, string, error) {
+	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=5000", path)
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open search index: %w", err)
+	}
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+
+	if _, err := conn.Exec(searchIndexSchema); err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	engine := "fts5"
+	if _, err := conn.Exec(searchFTS5Table); err != nil {
+		if !strings.Contains(err.Error(), "no such module: fts5") {
+			conn.Close()
+			return nil, "", fmt.Errorf("failed to create search index: %w", err)
+		}
+		engine = "fts4"
+		if _, err := conn.Exec(searchFTS4Table); err != nil {
+			conn.Close()
+			return nil, "", fmt.Errorf("failed to create search index: %w", err)
+		}
+	}
+
+	var tableSQL string
+	conn.QueryRow(`SELECT sql FROM sqlite_master WHERE name = 'event_fts'`).Scan(&tableSQL)
+	if !strings.Contains(strings.ToLower(tableSQL), engine) {
+		conn.Close()
+		return nil, "", errSearchEngineChanged
+	}
+
+	return conn, engine, nil
+}
+
+// closeSearchIndex closes the search index if it is open. The caller holds d.mu.
+func (d *DB) closeSearchIndex() error {
+	if d.searchDB == nil {
+		return nil
+	}
+	err := d.searchDB.Close()
+	d.searchDB = nil
+	return err
+}
+
+// searchCursor returns the last relay row ID indexed.
+func searchCursor(ctx context.Context, q relayQuerier) int64 {
+	var cursor int64
+	q.QueryRowContext(ctx, `SELECT value FROM search_state WHERE key = 'cursor'`).Scan(&cursor)
+	return cursor
+}
+
+// UpdateSearchIndex indexes events stored since the last update and returns
+// how many were added. If the relay database was replaced by one with fewer
+// rows, the index is rebuilt.
+func (d *DB) UpdateSearchIndex(ctx context.Context) (int, error) {
+	index, _, err := d.searchIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	cursor := searchCursor(ctx, index)
+	latest, err := d.GetLatestEventRowID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if latest < cursor {
+		if err := resetSearchIndex(ctx, index); err != nil {
+			return 0, err
+		}
+		cursor = 0
+	}
+
+	var tx *sql.Tx
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
+	added, pending := 0, 0
+	commit := func() error {
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO search_state (key, value) VALUES ('cursor', ?)`, cursor); err != nil {
+			return fmt.Errorf("failed to save search cursor: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit search index: %w", err)
+		}
+		tx, pending = nil, 0
+		return nil
+	}
+
+	err = d.StreamEventsAfter(ctx, cursor, nil, func(rowID int64, event ExportEvent) error {
+		if tx == nil {
+			var beginErr error
+			if tx, beginErr = index.BeginTx(ctx, nil); beginErr != nil {
+				return fmt.Errorf("failed to begin transaction: %w", beginErr)
+			}
+		}
+		cursor = rowID
+		pending++
+
+		if !IsEncryptedDMKind(event.Kind) && strings.TrimSpace(event.Content) != "" {
+			author, _ := hex.DecodeString(event.Pubkey)
+			if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO search_event (id, author, kind, created_at) VALUES (?, ?, ?, ?)`,
+				rowID, author, event.Kind, event.CreatedAt); err != nil {
+				return fmt.Errorf("failed to index event: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO event_fts (rowid, content) VALUES (?, ?)`, rowID, event.Content); err != nil {
+				return fmt.Errorf("failed to index event content: %w", err)
+			}
+			added++
+		}
+
+		if pending >= searchIndexBatch {
+			return commit()
+		}
+		return nil
+	})
+	if err != nil {
+		return added, err
+	}
+	if tx != nil {
+		if err := commit(); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// resetSearchIndex empties the search index.
+func resetSearchIndex(ctx context.Context, index *sql.DB) error {
+	for _, stmt := range []string{
+		`DELETE FROM event_fts`,
+		`DELETE FROM search_event`,
+		`DELETE FROM search_state`,
+	} {
+		if _, err := index.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to reset search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// PruneSearchIndex removes events that are no longer in the relay database
+// and returns how many were removed.
+func (d *DB) PruneSearchIndex(ctx context.Context) (int, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return 0, fmt.Errorf("relay database not connected")
+	}
+	if d.IsRelayPostgres() {
+		return 0, ErrUnsupportedOnPostgres
+	}
+	index, _, err := d.searchIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var after int64
+	for {
+		rows, err := index.QueryContext(ctx, `SELECT id FROM search_event WHERE id > ? ORDER BY id LIMIT ?`, after, searchPruneBatch)
+		if err != nil {
+			return removed, fmt.Errorf("failed to read search index: %w", err)
+		}
+		var ids []interface{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return removed, err
+			}
+			ids = append(ids, id)
+			after = id
+		}
+		rows.Close()
+		if len(ids) == 0 {
+			return removed, nil
+		}
+
+		present := make(map[int64]bool, len(ids))
+		rows, err = relay.QueryContext(ctx, `SELECT id FROM event WHERE id IN (`+placeholderList(len(ids))+`)`, ids...)
+		if err != nil {
+			return removed, fmt.Errorf("failed to check relay events: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return removed, err
+			}
+			present[id] = true
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			if present[id.(int64)] {
+				continue
+			}
+			if _, err := index.ExecContext(ctx, `DELETE FROM event_fts WHERE rowid = ?`, id); err != nil {
+				return removed, fmt.Errorf("failed to prune search index: %w", err)
+			}
+			if _, err := index.ExecContext(ctx, `DELETE FROM search_event WHERE id = ?`, id); err != nil {
+				return removed, fmt.Errorf("failed to prune search index: %w", err)
+			}
+			removed++
+		}
+	}
+}
+
+// GetSearchIndexStatus reports the search index's engine and progress.
+func (d *DB) GetSearchIndexStatus(ctx context.Context) (*SearchIndexStatus, error) {
+	index, engine, err := d.searchIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &SearchIndexStatus{Engine: engine, Cursor: searchCursor(ctx, index)}
+	if err := index.QueryRowContext(ctx, `SELECT COUNT(*) FROM search_event`).Scan(&status.Indexed); err != nil {
+		return nil, fmt.Errorf("failed to count indexed events: %w", err)
+	}
+	if d.RelayDB() != nil && !d.IsRelayPostgres() {
+		status.Latest, _ = d.GetLatestEventRowID(ctx)
+	}
+	return status, nil
+}
+
+// SearchEventsFullText returns events whose content matches the query, most
+// relevant first. FTS5 ranks every match with BM25; FTS4 ranks the newest
+// searchRankCandidates matches with a BM25-style score.
+func (d *DB) SearchEventsFullText(ctx context.Context, q FullTextQuery) ([]SearchResult, error) {
+	relay := d.RelayDB()
+	if relay == nil {
+		return nil, fmt.Errorf("relay database not connected")
+	}
+	if d.IsRelayPostgres() {
+		return nil, ErrUnsupportedOnPostgres
+	}
+
+	match := ftsMatchQuery(q.Query)
+	if match == "" {
+		return nil, ErrInvalidSearchQuery
+	}
+
+	index, engine, err := d.searchIndex()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSearchIndexUnavailable, err)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if max := d.maxQueryLimit(); limit > max {
+		limit = max
+	}
+	offset := q.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	where := "event_fts MATCH ?"
+	args := []interface{}{match}
+	if len(q.Authors) > 0 {
+		for _, pubkey := range q.Authors {
+			b, err := hex.DecodeString(pubkey)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pubkey: %w", err)
+			}
+			args = append(args, b)
+		}
+		where += " AND e.author IN (" + placeholderList(len(q.Authors)) + ")"
+	}
+	if len(q.Kinds) > 0 {
+		for _, kind := range q.Kinds {
+			args = append(args, kind)
+		}
+		where += " AND e.kind IN (" + placeholderList(len(q.Kinds)) + ")"
+	}
+	if !q.Since.IsZero() {
+		where += " AND e.created_at >= ?"
+		args = append(args, q.Since.Unix())
+	}
+	if !q.Until.IsZero() {
+		where += " AND e.created_at <= ?"
+		args = append(args, q.Until.Unix())
+	}
+
+	var ranked []rankedRow
+	if engine == "fts5" {
+		ranked, err = rankFTS5(ctx, index, where, args, limit, offset)
+	} else {
+		ranked, err = rankFTS4(ctx, index, where, args, limit, offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loadRankedEvents(ctx, relay, ranked)
+}
+
+// rankedRow is a relay event row ID and its search rank.
+type rankedRow struct {
+	id   int64
+	rank float64
+}
+
+func rankFTS5(ctx context.Context, index *sql.DB, where string, args []interface{}, limit, offset int) ([]rankedRow, error) {
+	query := `SELECT e.id, -bm25(event_fts) FROM event_fts JOIN search_event e ON e.id = event_fts.rowid
+		WHERE ` + where + ` ORDER BY bm25(event_fts), e.created_at DESC LIMIT ? OFFSET ?`
+	rows, err := index.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, searchQueryError(err)
+	}
+	defer rows.Close()
+
+	var ranked []rankedRow
+	for rows.Next() {
+		var r rankedRow
+		if err := rows.Scan(&r.id, &r.rank); err != nil {
+			return nil, err
+		}
+		ranked = append(ranked, r)
+	}
+	return ranked, rows.Err()
+}
+
+func rankFTS4(ctx context.Context, index *sql.DB, where string, args []interface{}, limit, offset int) ([]rankedRow, error) {
+	query := `SELECT e.id, e.created_at, matchinfo(event_fts, 'pcnx') FROM event_fts JOIN search_event e ON e.id = event_fts.rowid
+		WHERE ` + where + ` ORDER BY e.created_at DESC LIMIT ?`
+	rows, err := index.QueryContext(ctx, query, append(args, searchRankCandidates)...)
+	if err != nil {
+		return nil, searchQueryError(err)
+	}
+	defer rows.Close()
+
+	type candidate struct {
+		rankedRow
+		createdAt int64
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		var info []byte
+		if err := rows.Scan(&c.id, &c.createdAt, &info); err != nil {
+			return nil, err
+		}
+		c.rank = matchinfoScore(info)
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank > candidates[j].rank
+		}
+		return candidates[i].createdAt > candidates[j].createdAt
+	})
+	if offset >= len(candidates) {
+		return nil, nil
+	}
+	candidates = candidates[offset:]
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	ranked := make([]rankedRow, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.rankedRow
+	}
+	return ranked, nil
+}
+
+// matchinfoScore scores an FTS4 match from matchinfo 'pcnx': phrase and
+// column counts, the row count, then per phrase the hits in this row, in
+// all rows and the rows with a hit. Each phrase adds its BM25 term weight.
+func matchinfoScore(info []byte) float64 {
+	if len(info) < 12 {
+		return 0
+	}
+	value := func(i int) float64 { return float64(binary.LittleEndian.Uint32(info[i*4:])) }
+	phrases, columns, rows := int(value(0)), int(value(1)), value(2)
+
+	var score float64
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns; c++ {
+			base := 3 + 3*(p*columns+c)
+			if (base+3)*4 > len(info) {
+				return score
+			}
+			hits, docs := value(base), value(base+2)
+			if hits == 0 {
+				continue
+			}
+			idf := math.Log(1 + (rows-docs+0.5)/(docs+0.5))
+			score += idf * hits * 2.2 / (hits + 1.2)
+		}
+	}
+	return score
+}
+
+// searchQueryError turns an FTS syntax error into ErrInvalidSearchQuery.
+func searchQueryError(err error) error {
+	if strings.Contains(err.Error(), "syntax error") || strings.Contains(err.Error(), "malformed MATCH") {
+		return ErrInvalidSearchQuery
+	}
+	return fmt.Errorf("failed to search events: %w", err)
+}
+
+// loadRankedEvents reads ranked events from the relay database in rank
+// order, skipping any deleted since they were indexed.
+func loadRankedEvents(ctx context.Context, relay *sql.DB, ranked []rankedRow) ([]SearchResult, error) {
+	results := []SearchResult{}
+	if len(ranked) == 0 {
+		return results, nil
+	}
+
+	ids := make([]interface{}, len(ranked))
+	for i, r := range ranked {
+		ids[i] = r.id
+	}
+	rows, err := relay.QueryContext(ctx, `SELECT id, event_hash, author, created_at, kind, content FROM event
+		WHERE id IN (`+placeholderList(len(ids))+`) AND (hidden IS NULL OR hidden = 0)`, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make(map[int64]*Event, len(ranked))
+	for rows.Next() {
+		var rowID, createdAt int64
+		var idBytes, authorBytes []byte
+		var kind int
+		var contentJSON string
+		if err := rows.Scan(&rowID, &idBytes, &authorBytes, &createdAt, &kind, &contentJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		event, err := parseEventFromDB(idBytes, authorBytes, createdAt, kind, contentJSON)
+		if err != nil {
+			return nil, err
+		}
+		events[rowID] = event
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range ranked {
+		if event, ok := events[r.id]; ok {
+			results = append(results, SearchResult{Event: *event, Rank: r.rank})
+		}
+	}
+	return results, nil
+}
+
+// ftsMatchQuery turns a search query into an FTS MATCH expression that both
+// FTS4 and FTS5 read the same way: each word and "quoted phrase" becomes a
+// quoted phrase, and all must match. Like SearchTerms, bare key:value
+// tokens are ignored, as are tokens without letters or digits.
+func ftsMatchQuery(query string) string {
+	var parts []string
+	add := func(text string) {
+		if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			return
+		}
+		parts = append(parts, `"`+strings.ReplaceAll(text, `"`, `""`)+`"`)
+	}
+
+	for query != "" {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if query == "" {
+			break
+		}
+		if query[0] == '"' {
+			end := strings.IndexByte(query[1:], '"')
+			if end < 0 {
+				add(query[1:])
+				break
+			}
+			add(query[1 : end+1])
+			query = query[end+2:]
+			continue
+		}
+		end := strings.IndexFunc(query, unicode.IsSpace)
+		if end < 0 {
+			end = len(query)
+		}
+		if word := query[:end]; !strings.Contains(word, ":") {
+			add(word)
+		}
+		query = query[end:]
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupSearchDB creates a DB whose relay database is writable through the
+// returned connection, with the search index in a temporary directory.
+func setupSearchDB(t *testing.T) (*DB, *sql.DB) {
+	t.Helper()
+
+	dir := t.TempDir()
+	relayPath := filepath.Join(dir, "nostr.db")
+	relay, err := sql.Open("sqlite3", relayPath)
+	if err != nil {
+		t.Fatalf("failed to open relay db: %v", err)
+	}
+	t.Cleanup(func() { relay.Close() })
+	if _, err := relay.Exec(relayIndexSchema); err != nil {
+		t.Fatalf("failed to create relay schema: %v", err)
+	}
+
+	database, err := New(relayPath, filepath.Join(dir, "roostr.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database, relay
+}
+
+func TestFullTextSearch(t *testing.T) {
+	ctx := context.Background()
+	database, relay := setupSearchDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	eventID := func(n byte) string { return strings.Repeat(hex.EncodeToString([]byte{n}), 32) }
+	insertTestEvent(t, relay, eventID(1), testPubkey1, 1, now.Add(-3*time.Hour), "Bitcoin is the best money")
+	insertTestEvent(t, relay, eventID(2), testPubkey1, 1, now.Add(-2*time.Hour), "The best money is bitcoin")
+	insertTestEvent(t, relay, eventID(3), testPubkey2, 30023, now.Add(-time.Hour), "Bitcoin, bitcoin and more BITCOIN at the conference")
+	insertTestEvent(t, relay, eventID(4), testPubkey2, 4, now, "encrypted bitcoin")
+	insertTestEvent(t, relay, eventID(5), testPubkey2, 7, now, "")
+	insertTestEvent(t, relay, eventID(16), testPubkey2, 1060, now, "wrapped bitcoin")
+
+	added, err := database.UpdateSearchIndex(ctx)
+	if err != nil {
+		t.Fatalf("UpdateSearchIndex() error = %v", err)
+	}
+	if added != 3 {
+		t.Errorf("UpdateSearchIndex() added %d, want 3 (no DMs or empty content)", added)
+	}
+
+	search := func(q FullTextQuery) []string {
+		t.Helper()
+		results, err := database.SearchEventsFullText(ctx, q)
+		if err != nil {
+			t.Fatalf("SearchEventsFullText(%q) error = %v", q.Query, err)
+		}
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+	expect := func(name string, got []string, want ...string) {
+		t.Helper()
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+
+	got := search(FullTextQuery{Query: "bitcoin"})
+	if len(got) != 3 || got[0] != eventID(3) {
+		t.Errorf("bitcoin = %v, want 3 results with the most mentions first", got)
+	}
+	expect("phrase", search(FullTextQuery{Query: `"money is"`}), eventID(2))
+	expect("all words", search(FullTextQuery{Query: "best conference"}))
+	expect("author", search(FullTextQuery{Query: "bitcoin", Authors: []string{testPubkey2}}), eventID(3))
+	expect("kind", search(FullTextQuery{Query: "money", Kinds: []int{30023}}))
+	expect("since", search(FullTextQuery{Query: "money", Since: now.Add(-150 * time.Minute)}), eventID(2))
+	expect("limit", search(FullTextQuery{Query: "bitcoin", Limit: 1}), eventID(3))
+
+	if _, err := database.SearchEventsFullText(ctx, FullTextQuery{Query: "kind:1 !!"}); !errors.Is(err, ErrInvalidSearchQuery) {
+		t.Errorf("query without terms error = %v, want ErrInvalidSearchQuery", err)
+	}
+
+	// Updates only index new events
+	insertTestEvent(t, relay, eventID(6), testPubkey1, 1, now, "money money")
+	if added, err := database.UpdateSearchIndex(ctx); err != nil || added != 1 {
+		t.Errorf("second UpdateSearchIndex() = %d, %v; want 1", added, err)
+	}
+
+	// Deleted events are skipped at once and pruned later
+	if _, err := relay.Exec(`DELETE FROM event WHERE event_hash = ?`, mustDecodeHex(eventID(1))); err != nil {
+		t.Fatalf("failed to delete event: %v", err)
+	}
+	expect("after delete", search(FullTextQuery{Query: `"best money"`}), eventID(2))
+	if removed, err := database.PruneSearchIndex(ctx); err != nil || removed != 1 {
+		t.Errorf("PruneSearchIndex() = %d, %v; want 1", removed, err)
+	}
+
+	status, err := database.GetSearchIndexStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetSearchIndexStatus() error = %v", err)
+	}
+	if status.Indexed != 3 || status.Cursor != status.Latest || status.Cursor == 0 {
+		t.Errorf("status = %+v, want 3 indexed and caught up", status)
+	}
+	if status.Engine != "fts5" && status.Engine != "fts4" {
+		t.Errorf("engine = %q", status.Engine)
+	}
+}
+
+func TestUpdateSearchIndexRebuildsForNewRelayDatabase(t *testing.T) {
+	ctx := context.Background()
+	database, relay := setupSearchDB(t)
+
+	now := time.Now()
+	insertTestEvent(t, relay, testEventID1, testPubkey1, 1, now, "first relay")
+	insertTestEvent(t, relay, testEventID2, testPubkey1, 1, now, "first relay again")
+	if _, err := database.UpdateSearchIndex(ctx); err != nil {
+		t.Fatalf("UpdateSearchIndex() error = %v", err)
+	}
+
+	// A restored relay database with fewer rows than the cursor
+	if _, err := relay.Exec(`DELETE FROM event`); err != nil {
+		t.Fatalf("failed to clear events: %v", err)
+	}
+	if _, err := relay.Exec(`DELETE FROM sqlite_sequence`); err != nil && !strings.Contains(err.Error(), "no such table") {
+		t.Fatalf("failed to reset row IDs: %v", err)
+	}
+	insertTestEvent(t, relay, testEventID3, testPubkey1, 1, now, "second relay")
+
+	if added, err := database.UpdateSearchIndex(ctx); err != nil || added != 1 {
+		t.Fatalf("UpdateSearchIndex() = %d, %v; want the index rebuilt", added, err)
+	}
+	results, err := database.SearchEventsFullText(ctx, FullTextQuery{Query: "relay"})
+	if err != nil || len(results) != 1 || results[0].ID != testEventID3 {
+		t.Errorf("results = %+v, %v; want only the second relay's event", results, err)
+	}
+}
+
+func TestFTSMatchQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"bitcoin", `"bitcoin"`},
+		{"  best   money ", `"best" "money"`},
+		{`"best money" is`, `"best money" "is"`},
+		{`say "hello`, `"say" "hello"`},
+		{`kind:1 language:en nostr`, `"nostr"`},
+		{`"a:b c"`, `"a:b c"`},
+		{`don"t`, `"don""t"`},
+		{`-- ** ""`, ``},
+		{`NEAR(a b) OR c`, `"NEAR(a" "b)" "OR" "c"`},
+	}
+	for _, tt := range tests {
+		if got := ftsMatchQuery(tt.query); got != tt.want {
+			t.Errorf("ftsMatchQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
@@ -3,13 +3,16 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
 	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
 // ============================================================================
@@ -58,7 +61,7 @@ func (h *Handler) SetAccessMode(w http.ResponseWriter, r *http.Request) {
 
 	// Sync to config.toml based on mode
 	if err := h.syncConfigFromDB(ctx); err != nil {
-		log.Printf("Warning: failed to sync config.toml: %v", err)
+		slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
 	}
 
 	// Log the action
@@ -88,7 +91,7 @@ func (h *Handler) GetWhitelist(w http.ResponseWriter, r *http.Request) {
 		for i, e := range entries {
 			pubkeys[i] = e.Pubkey
 		}
-		counts, _ := h.db.CountEventsByPubkey(r.Context(), pubkeys)
+		counts, _ := h.db.CountEventsByPubkeyCached(r.Context(), pubkeys)
 
 		// Build response with counts
 		type entryWithCount struct {
@@ -135,6 +138,14 @@ func (h *Handler) AddToWhitelist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The npub is always derived from the pubkey, never stored as given
+	pubkey, npub, err := resolvePubkey(req.Pubkey, req.Npub)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_PUBKEY")
+		return
+	}
+	req.Pubkey, req.Npub = pubkey, npub
+
 	ctx := r.Context()
 	entry := db.WhitelistEntry{
 		Pubkey:   req.Pubkey,
@@ -149,7 +160,7 @@ func (h *Handler) AddToWhitelist(w http.ResponseWriter, r *http.Request) {
 
 	// Sync to config.toml and reload relay
 	if err := h.syncConfigFromDB(ctx); err != nil {
-		log.Printf("Warning: failed to sync config.toml: %v", err)
+		slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
 	}
 
 	// Log the action
@@ -158,6 +169,13 @@ func (h *Handler) AddToWhitelist(w http.ResponseWriter, r *http.Request) {
 		"nickname": req.Nickname,
 	}, "")
 
+	h.emitWebhook(services.WebhookEventUserWhitelisted, map[string]interface{}{
+		"pubkey": req.Pubkey,
+		"npub":   req.Npub,
+		"source": "manual",
+	})
+	h.wakeProfiles()
+
 	respondJSON(w, http.StatusCreated, map[string]interface{}{
 		"success": true,
 		"message": "Added to whitelist",
@@ -184,7 +202,7 @@ func (h *Handler) RemoveFromWhitelist(w http.ResponseWriter, r *http.Request) {
 
 	// Sync to config.toml and reload relay
 	if err := h.syncConfigFromDB(ctx); err != nil {
-		log.Printf("Warning: failed to sync config.toml: %v", err)
+		slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
 	}
 
 	// Log the action
@@ -239,13 +257,23 @@ func (h *Handler) BulkAddToWhitelist(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		pubkey, npub, err := resolvePubkey(entry.Pubkey, entry.Npub)
+		if err != nil {
+			response.Errors++
+			if len(response.ErrorList) < 100 {
+				response.ErrorList = append(response.ErrorList, fmt.Sprintf("Entry %d: %v", i+1, err))
+			}
+			continue
+		}
+		entry.Pubkey, entry.Npub = pubkey, npub
+
 		dbEntry := db.WhitelistEntry{
 			Pubkey:   entry.Pubkey,
 			Npub:     entry.Npub,
 			Nickname: entry.Nickname,
 		}
 
-		err := h.db.AddWhitelistEntry(ctx, dbEntry)
+		err = h.db.AddWhitelistEntry(ctx, dbEntry)
 		if err != nil {
 			// Check if it's a duplicate (already exists)
 			if err.Error() == "pubkey already in whitelist" {
@@ -260,18 +288,135 @@ func (h *Handler) BulkAddToWhitelist(w http.ResponseWriter, r *http.Request) {
 		}
 
 		response.Added++
+
+		h.emitWebhook(services.WebhookEventUserWhitelisted, map[string]interface{}{
+			"pubkey": entry.Pubkey,
+			"npub":   entry.Npub,
+			"source": "bulk",
+		})
 	}
 
 	// Sync to config.toml once at the end (more efficient than per-entry)
 	if response.Added > 0 {
 		if err := h.syncConfigFromDB(ctx); err != nil {
-			log.Printf("Warning: failed to sync config.toml: %v", err)
+			slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
 		}
 
 		// Log the bulk action
 		h.db.AddAuditLog(ctx, "whitelist_bulk_add", map[string]string{
 			"count": fmt.Sprintf("%d", response.Added),
 		}, "")
+		h.wakeProfiles()
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ImportFollowsRequest is the request body for importing the operator's follows.
+type ImportFollowsRequest struct {
+	Relays []string `json:"relays,omitempty"`  // Defaults to the sync relays
+	DryRun bool     `json:"dry_run,omitempty"` // Return the follows without adding them
+}
+
+// ImportFollowsResponse contains the results of a follow-list import.
+type ImportFollowsResponse struct {
+	Total         int               `json:"total"`      // Follows in the contact list
+	Added         int               `json:"added"`      // Newly whitelisted
+	Duplicates    int               `json:"duplicates"` // Already whitelisted
+	Errors        int               `json:"errors"`     // Failed to add
+	DryRun        bool              `json:"dry_run"`
+	ContactListAt time.Time         `json:"contact_list_at"`
+	Relay         string            `json:"relay"`             // Relay the contact list came from
+	Follows       []services.Follow `json:"follows,omitempty"` // Only for dry runs
+}
+
+// ImportFollows whitelists everyone the operator follows, using the newest
+// NIP-02 contact list (kind 3) on the sync relays. Nicknames come from each
+// follow's kind-0 profile; existing entries are left unchanged.
+// POST /api/v1/access/whitelist/import-follows
+func (h *Handler) ImportFollows(w http.ResponseWriter, r *http.Request) {
+	var req ImportFollowsRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	operator, err := h.db.GetOperatorPubkey(ctx)
+	if err != nil || operator == "" {
+		respondError(w, http.StatusConflict, "Complete setup before importing follows", "NO_OPERATOR")
+		return
+	}
+
+	relays, ok := normalizeBackupRelays(req.Relays)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid relay URL. Must start with wss:// or ws://", "INVALID_URL")
+		return
+	}
+	if len(relays) == 0 {
+		relays = services.SyncRelayURLs(ctx, h.db)
+	}
+
+	list, err := services.FetchFollows(ctx, operator, relays)
+	if errors.Is(err, services.ErrNoContactList) {
+		respondError(w, http.StatusNotFound, "No contact list found for the operator on the given relays", "CONTACT_LIST_NOT_FOUND")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadGateway, "Failed to fetch follows", "FETCH_FAILED")
+		return
+	}
+
+	response := ImportFollowsResponse{
+		Total:         len(list.Follows),
+		DryRun:        req.DryRun,
+		ContactListAt: list.CreatedAt,
+		Relay:         list.Relay,
+	}
+	if req.DryRun {
+		response.Follows = list.Follows
+		respondJSON(w, http.StatusOK, response)
+		return
+	}
+
+	for _, follow := range list.Follows {
+		if existing, _ := h.db.GetWhitelistEntryByPubkey(ctx, follow.Pubkey); existing != nil {
+			response.Duplicates++
+			continue
+		}
+
+		if err := h.db.AddWhitelistEntry(ctx, db.WhitelistEntry{
+			Pubkey:   follow.Pubkey,
+			Npub:     follow.Npub,
+			Nickname: follow.Name,
+			AddedBy:  "follows",
+		}); err != nil {
+			response.Errors++
+			continue
+		}
+		response.Added++
+
+		h.emitWebhook(services.WebhookEventUserWhitelisted, map[string]interface{}{
+			"pubkey": follow.Pubkey,
+			"npub":   follow.Npub,
+			"source": "follows",
+		})
+	}
+
+	if response.Added > 0 {
+		if err := h.syncConfigFromDB(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
+		}
+
+		h.db.AddAuditLog(ctx, "whitelist_import_follows", map[string]interface{}{
+			"added":      response.Added,
+			"duplicates": response.Duplicates,
+			"relay":      list.Relay,
+		}, "")
+		h.wakeProfiles()
 	}
 
 	respondJSON(w, http.StatusOK, response)
@@ -345,6 +490,14 @@ func (h *Handler) AddToBlacklist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The npub is always derived from the pubkey, never stored as given
+	pubkey, npub, err := resolvePubkey(req.Pubkey, req.Npub)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_PUBKEY")
+		return
+	}
+	req.Pubkey, req.Npub = pubkey, npub
+
 	ctx := r.Context()
 	entry := db.BlacklistEntry{
 		Pubkey: req.Pubkey,
@@ -359,7 +512,7 @@ func (h *Handler) AddToBlacklist(w http.ResponseWriter, r *http.Request) {
 
 	// Sync to config.toml and reload relay
 	if err := h.syncConfigFromDB(ctx); err != nil {
-		log.Printf("Warning: failed to sync config.toml: %v", err)
+		slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
 	}
 
 	// Log the action
@@ -367,6 +520,7 @@ func (h *Handler) AddToBlacklist(w http.ResponseWriter, r *http.Request) {
 		"pubkey": req.Pubkey,
 		"reason": req.Reason,
 	}, "")
+	h.wakeProfiles()
 
 	respondJSON(w, http.StatusCreated, map[string]interface{}{
 		"success": true,
@@ -390,7 +544,7 @@ func (h *Handler) RemoveFromBlacklist(w http.ResponseWriter, r *http.Request) {
 
 	// Sync to config.toml and reload relay
 	if err := h.syncConfigFromDB(ctx); err != nil {
-		log.Printf("Warning: failed to sync config.toml: %v", err)
+		slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
 	}
 
 	// Log the action
@@ -457,58 +611,27 @@ func (h *Handler) syncConfigFromDB(_ interface{}) error {
 		return nil // No config manager, skip sync
 	}
 
-	// Use a background context for the sync operations
-	ctx := context.Background()
-
-	// Get current access mode to determine which list to enforce
-	mode, err := h.db.GetAccessMode(ctx)
-	if err != nil {
-		mode = "whitelist" // Default to whitelist if error
+	// Grants follow the members' contact lists, so recount them too
+	if h.services != nil && h.services.FollowAccess != nil {
+		h.services.FollowAccess.Wake()
 	}
 
-	var whitelist, blacklist []string
-
-	switch mode {
-	case "whitelist", "paid":
-		// In whitelist/paid mode: enforce whitelist only, clear blacklist
-		entries, err := h.db.GetWhitelistMeta(ctx)
-		if err != nil {
-			return err
-		}
-		whitelist = make([]string, len(entries))
-		for i, e := range entries {
-			whitelist[i] = e.Pubkey
+	// The admission server reads the lists from the DB, so there is nothing
+	// to write and no restart; it only needs to drop its cached copy
+	if h.configMgr.DelegatesAccess() {
+		if h.services != nil && h.services.Admission != nil {
+			h.services.Admission.Invalidate()
 		}
-		blacklist = []string{} // Empty blacklist so it's not enforced
-
-	case "blacklist":
-		// In blacklist mode: enforce blacklist only, clear whitelist
-		entries, err := h.db.GetBlacklist(ctx)
-		if err != nil {
-			return err
-		}
-		blacklist = make([]string, len(entries))
-		for i, e := range entries {
-			blacklist[i] = e.Pubkey
-		}
-		whitelist = []string{} // Empty whitelist so it's not enforced
+		return nil
+	}
 
-	case "open":
-		// In open mode: no restrictions, clear both lists
-		whitelist = []string{}
-		blacklist = []string{}
+	// Use a background context for the sync operations
+	ctx := context.Background()
 
-	default:
-		// Unknown mode, default to whitelist behavior
-		entries, err := h.db.GetWhitelistMeta(ctx)
-		if err != nil {
-			return err
-		}
-		whitelist = make([]string, len(entries))
-		for i, e := range entries {
-			whitelist[i] = e.Pubkey
-		}
-		blacklist = []string{}
+	// Only the list enforced by the current access mode is written
+	whitelist, blacklist, err := services.AccessLists(ctx, h.db)
+	if err != nil {
+		return err
 	}
 
 	// Update config.toml with the appropriate lists
@@ -524,7 +647,7 @@ func (h *Handler) syncConfigFromDB(_ interface{}) error {
 	// doesn't hot-reload whitelist/blacklist on SIGHUP - it requires a full restart
 	if h.relay != nil {
 		if err := h.relay.Restart(); err != nil {
-			log.Printf("Warning: failed to restart relay: %v", err)
+			slog.Warn("Failed to restart relay", "error", err)
 		}
 	}
 
@@ -615,7 +738,7 @@ func (h *Handler) GetPaidUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// Parse query parameters
-	status := r.URL.Query().Get("status") // active, expired, revoked, all
+	status := r.URL.Query().Get("status") // active, grace, expired, revoked, all
 	limitStr := r.URL.Query().Get("limit")
 	offsetStr := r.URL.Query().Get("offset")
 
@@ -650,7 +773,7 @@ func (h *Handler) GetPaidUsers(w http.ResponseWriter, r *http.Request) {
 		for i, u := range users {
 			pubkeys[i] = u.Pubkey
 		}
-		counts, _ := h.db.CountEventsByPubkey(ctx, pubkeys)
+		counts, _ := h.db.CountEventsByPubkeyCached(ctx, pubkeys)
 
 		for i, u := range users {
 			result[i] = paidUserWithCount{
@@ -704,13 +827,13 @@ func (h *Handler) RevokePaidUserAccess(w http.ResponseWriter, r *http.Request) {
 	entry, _ := h.db.GetWhitelistEntryByPubkey(ctx, pubkey)
 	if entry != nil && !entry.IsOperator {
 		if err := h.db.RemoveWhitelistEntry(ctx, pubkey); err != nil {
-			log.Printf("Warning: failed to remove revoked user from whitelist: %v", err)
+			slog.WarnContext(ctx, "Failed to remove revoked user from whitelist", "error", err)
 		}
 	}
 
 	// Sync config.toml and reload relay
 	if err := h.syncConfigFromDB(ctx); err != nil {
-		log.Printf("Warning: failed to sync config.toml: %v", err)
+		slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
 	}
 
 	// Log the action
@@ -725,6 +848,109 @@ func (h *Handler) RevokePaidUserAccess(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// adminInvoiceExpiry is how long an operator-created invoice stays payable
+// unless the request says otherwise. It is longer than a signup invoice's
+// because it is usually passed on to the user by DM.
+const adminInvoiceExpiry = 24 * time.Hour
+
+// CreatePaidUserInvoice creates an invoice for a tier on behalf of a user,
+// for the operator to pass on. Paying it grants access like a signup.
+// POST /api/v1/access/paid-users/{pubkey}/invoice
+func (h *Handler) CreatePaidUserInvoice(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Lightning == nil {
+		respondError(w, http.StatusServiceUnavailable, "Lightning service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	hexPubkey, npub, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey format", "INVALID_PUBKEY")
+		return
+	}
+
+	var req struct {
+		TierID      string `json:"tier_id"`
+		AmountSats  int64  `json:"amount_sats"`
+		ExpiryHours int    `json:"expiry_hours"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if req.TierID == "" {
+		respondError(w, http.StatusBadRequest, "Tier ID is required", "MISSING_TIER")
+		return
+	}
+	if req.AmountSats < 0 || req.ExpiryHours < 0 || req.ExpiryHours > 24*30 {
+		respondError(w, http.StatusBadRequest, "amount_sats must not be negative and expiry_hours must be at most 720", "VALIDATION_ERROR")
+		return
+	}
+
+	ctx := r.Context()
+
+	tiers, err := h.db.GetPricingTiers(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get pricing tiers", "DB_ERROR")
+		return
+	}
+	var tier *db.PricingTier
+	for i := range tiers {
+		if tiers[i].ID == req.TierID {
+			tier = &tiers[i]
+			break
+		}
+	}
+	if tier == nil {
+		respondError(w, http.StatusNotFound, "Pricing tier not found", "TIER_NOT_FOUND")
+		return
+	}
+	if !tier.Enabled {
+		respondError(w, http.StatusBadRequest, "Pricing tier is disabled", "TIER_DISABLED")
+		return
+	}
+
+	expiry := adminInvoiceExpiry
+	if req.ExpiryHours > 0 {
+		expiry = time.Duration(req.ExpiryHours) * time.Hour
+	}
+	invoice, err := h.services.Lightning.CreateAccessInvoice(ctx, services.AccessInvoiceRequest{
+		Pubkey:     hexPubkey,
+		Npub:       npub,
+		TierID:     tier.ID,
+		Expiry:     expiry,
+		AmountSats: req.AmountSats,
+		Source:     db.InvoiceSourceAdmin,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrLNDNotConfigured) {
+			respondError(w, http.StatusServiceUnavailable, "Lightning is not configured", "LN_NOT_CONFIGURED")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create invoice: "+err.Error(), "INVOICE_FAILED")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "paid_user_invoice_created", map[string]interface{}{
+		"pubkey":       hexPubkey,
+		"tier":         tier.ID,
+		"amount_sats":  invoice.AmountSats,
+		"payment_hash": invoice.PaymentHash,
+	}, "")
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"payment_hash":    invoice.PaymentHash,
+		"payment_request": invoice.PaymentRequest,
+		"amount_sats":     invoice.AmountSats,
+		"tier_id":         invoice.TierID,
+		"tier_name":       invoice.TierName,
+		"expires_at":      invoice.ExpiresAt,
+		"memo":            invoice.Memo,
+		"pubkey":          hexPubkey,
+		"npub":            npub,
+		"source":          db.InvoiceSourceAdmin,
+	})
+}
+
 // ============================================================================
 // Revenue
 // ============================================================================
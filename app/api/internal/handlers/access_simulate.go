@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// SimulateAccessChange reports which authors with events on the relay would
+// gain or lose the right to post under a different access mode or with
+// whitelist and blacklist changes, and how many events those losing it have
+// stored, without applying anything.
+// POST /api/v1/access/simulate
+func (h *Handler) SimulateAccessChange(w http.ResponseWriter, r *http.Request) {
+	var change services.AccessChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = min(parsed, 1000)
+		}
+	}
+
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	// The admission server enforces the blacklist in every mode; config.toml
+	// only in blacklist mode
+	delegated := h.configMgr == nil || h.configMgr.DelegatesAccess()
+
+	sim, err := services.SimulateAccessChange(r.Context(), h.db, change, delegated, limit)
+	switch {
+	case errors.Is(err, services.ErrInvalidAccessChange):
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_ACCESS_CHANGE")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, "Failed to simulate access change", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sim)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// Access a pubkey has, as reported by the public access status lookup.
+const (
+	accessStatusWhitelisted = "whitelisted"
+	accessStatusPaid        = "paid"
+	accessStatusExpired     = "expired"
+	accessStatusBlacklisted = "blacklisted"
+	accessStatusUnknown     = "unknown"
+)
+
+// GetAccessStatusSettings returns the public access status settings.
+// GET /api/v1/access/status-lookup
+func (h *Handler) GetAccessStatusSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetAccessStatusSettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access status settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateAccessStatusSettings saves the public access status settings.
+// PUT /api/v1/access/status-lookup
+func (h *Handler) UpdateAccessStatusSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetAccessStatusSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access status settings", "DB_ERROR")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if err := h.db.SetAccessStatusSettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save access status settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "access_status_settings_updated", settings, "")
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// GetAccessStatus tells a user whether their pubkey has access to the relay,
+// and for paid access its tier and expiry, so they can check a payment went
+// through without asking the operator.
+// GET /public/access-status/{pubkey}
+func (h *Handler) GetAccessStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetAccessStatusSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access status settings", "DB_ERROR")
+		return
+	}
+	if !settings.Enabled {
+		respondError(w, http.StatusNotFound, "Access status lookups are disabled on this relay", "ACCESS_STATUS_DISABLED")
+		return
+	}
+
+	hexPubkey, npub, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey (expected hex or npub)", "INVALID_PUBKEY")
+		return
+	}
+
+	blacklisted, err := h.db.IsBlacklisted(ctx, hexPubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access status", "DB_ERROR")
+		return
+	}
+	paidUser, err := h.db.GetPaidUserByPubkey(ctx, hexPubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access status", "DB_ERROR")
+		return
+	}
+	entry, err := h.db.GetWhitelistEntryByPubkey(ctx, hexPubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access status", "DB_ERROR")
+		return
+	}
+
+	response := accessStatusResponse(blacklisted, paidUser, entry != nil)
+	response["pubkey"] = hexPubkey
+	response["npub"] = npub
+
+	// An unpaid invoice explains a payment that has not gone through yet
+	invoices, err := h.db.GetPendingInvoicesByPubkey(ctx, hexPubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access status", "DB_ERROR")
+		return
+	}
+	pending := false
+	for _, inv := range invoices {
+		if inv.Status == "pending" && inv.ExpiresAt.After(time.Now()) {
+			pending = true
+			break
+		}
+	}
+	response["pending_payment"] = pending
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// accessStatusResponse describes the access of a pubkey. The blacklist wins
+// over any access, and a paid subscription is reported before the whitelist
+// entry paying creates. Revoked subscriptions are not reported.
+func accessStatusResponse(blacklisted bool, paidUser *db.PaidUser, whitelisted bool) map[string]interface{} {
+	if blacklisted {
+		return map[string]interface{}{"status": accessStatusBlacklisted}
+	}
+	if paidUser != nil && paidUser.Status != "revoked" {
+		status := accessStatusPaid
+		if paidUser.Status == "expired" {
+			status = accessStatusExpired
+		}
+		return map[string]interface{}{
+			"status":              status,
+			"tier":                paidUser.Tier,
+			"subscription_status": paidUser.Status,
+			"expires_at":          paidUser.ExpiresAt,
+		}
+	}
+	if whitelisted {
+		return map[string]interface{}{"status": accessStatusWhitelisted}
+	}
+	return map[string]interface{}{"status": accessStatusUnknown}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestAccessStatusResponse(t *testing.T) {
+	expiresAt := time.Now().Add(10 * 24 * time.Hour)
+	paid := func(status string) *db.PaidUser {
+		return &db.PaidUser{Tier: "monthly", Status: status, ExpiresAt: &expiresAt}
+	}
+
+	tests := []struct {
+		name        string
+		blacklisted bool
+		paidUser    *db.PaidUser
+		whitelisted bool
+		want        string
+	}{
+		{"unknown", false, nil, false, accessStatusUnknown},
+		{"whitelisted", false, nil, true, accessStatusWhitelisted},
+		{"paid over whitelist", false, paid("active"), true, accessStatusPaid},
+		{"grace", false, paid("grace"), false, accessStatusPaid},
+		{"expired", false, paid("expired"), false, accessStatusExpired},
+		{"revoked but whitelisted", false, paid("revoked"), true, accessStatusWhitelisted},
+		{"revoked", false, paid("revoked"), false, accessStatusUnknown},
+		{"blacklisted", true, paid("active"), true, accessStatusBlacklisted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := accessStatusResponse(tt.blacklisted, tt.paidUser, tt.whitelisted)
+			if resp["status"] != tt.want {
+				t.Fatalf("expected status %s, got %v", tt.want, resp["status"])
+			}
+			_, hasTier := resp["tier"]
+			if wantTier := tt.want == accessStatusPaid || tt.want == accessStatusExpired; hasTier != wantTier {
+				t.Errorf("expected tier present %v, got %v", wantTier, resp)
+			}
+			if tt.want == accessStatusPaid && resp["expires_at"] != &expiresAt {
+				t.Errorf("expected the subscription expiry, got %v", resp["expires_at"])
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetAdmissionStatus reports whether the relay asks the event admission
+// server about each event, and how many events it permitted and denied.
+// GET /api/v1/access/admission
+func (h *Handler) GetAdmissionStatus(w http.ResponseWriter, r *http.Request) {
+	stats := services.AdmissionStats{}
+	if h.services != nil && h.services.Admission != nil {
+		stats = h.services.Admission.Stats()
+	}
+	respondJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// KindSeries is one kind's event counts per time bucket. The series rolling
+// up every kind outside the top has no kind and is labelled "other".
+type KindSeries struct {
+	Kind     *int    `json:"kind"`
+	Label    string  `json:"label"`
+	Name     string  `json:"name"`
+	Category string  `json:"category"`
+	Total    int64   `json:"total"`
+	Counts   []int64 `json:"counts"`
+}
+
+// kindTrendSeries returns a series for each of the top kinds by total,
+// largest first, then one for all other kinds if there are any.
+func kindTrendSeries(trends *db.KindTrends, top int) []KindSeries {
+	series := make([]KindSeries, 0, len(trends.Kinds))
+	for kind, counts := range trends.Kinds {
+		var total int64
+		for _, count := range counts {
+			total += count
+		}
+		info := nostr.LookupKind(kind)
+		series = append(series, KindSeries{
+			Kind:     &kind,
+			Label:    getKindLabel(kind),
+			Name:     info.Name,
+			Category: info.Category,
+			Total:    total,
+			Counts:   counts,
+		})
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Total != series[j].Total {
+			return series[i].Total > series[j].Total
+		}
+		return *series[i].Kind < *series[j].Kind
+	})
+	if len(series) <= top {
+		return series
+	}
+
+	other := KindSeries{Label: "other", Name: "Other", Category: "other", Counts: make([]int64, len(trends.Buckets))}
+	for _, s := range series[top:] {
+		other.Total += s.Total
+		for i, count := range s.Counts {
+			other.Counts[i] += count
+		}
+	}
+	return append(series[:top:top], other)
+}
+
+// GetKindTrends returns event counts per kind over time, ready for a
+// stacked area chart: one series per top kind and one for all others,
+// each with a count per bucket. Buckets are as for events-over-time.
+// GET /api/v1/analytics/kind-trends
+func (h *Handler) GetKindTrends(w http.ResponseWriter, r *http.Request) {
+	timeRange := r.URL.Query().Get("time_range")
+	if timeRange == "" {
+		timeRange = "30days"
+	}
+	timezone := r.URL.Query().Get("timezone")
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = db.BucketDay
+		if timeRange == "today" {
+			granularity = db.BucketHour
+		}
+	}
+	if !db.ValidBucket(granularity) {
+		respondError(w, http.StatusBadRequest, "Granularity must be hour, day, week or month", "INVALID_GRANULARITY")
+		return
+	}
+	top := 5
+	if topStr := r.URL.Query().Get("top"); topStr != "" {
+		if parsed, err := strconv.Atoi(topStr); err == nil && parsed > 0 {
+			top = min(parsed, 20)
+		}
+	}
+
+	loc := time.UTC
+	if timezone != "" && timezone != "UTC" {
+		if parsed, err := time.LoadLocation(timezone); err == nil {
+			loc = parsed
+		}
+	}
+
+	if !h.db.IsRelayDBConnected() {
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"buckets":     []string{},
+			"series":      []KindSeries{},
+			"time_range":  timeRange,
+			"granularity": granularity,
+			"top":         top,
+		})
+		return
+	}
+
+	// Every kind is cached, so changing top needs no new query
+	key := "kind-trends:" + timeRange + ":" + granularity + ":" + timezone
+	value, computedAt, err := h.cachedStats(r, key, func(ctx context.Context) (interface{}, error) {
+		since, until := parseTimeRange(timeRange, timezone)
+		return h.db.GetKindTrends(ctx, since, until, granularity, loc)
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get kind trends", "STATS_FAILED")
+		return
+	}
+	trends := value.(*db.KindTrends)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"buckets":     trends.Buckets,
+		"series":      kindTrendSeries(trends, top),
+		"time_range":  timeRange,
+		"granularity": granularity,
+		"top":         top,
+		"computed_at": computedAt,
+	})
+}
+
+// GetAuthorGrowth returns how many authors posted on the relay for the first
+// time in each week, or other bucket, with the running total of authors, so
+// operators can see whether their community is growing.
+// GET /api/v1/analytics/author-growth
+func (h *Handler) GetAuthorGrowth(w http.ResponseWriter, r *http.Request) {
+	timeRange := r.URL.Query().Get("time_range")
+	if timeRange == "" {
+		timeRange = "alltime"
+	}
+	timezone := r.URL.Query().Get("timezone")
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = db.BucketWeek
+	}
+	if !db.ValidBucket(granularity) {
+		respondError(w, http.StatusBadRequest, "Granularity must be hour, day, week or month", "INVALID_GRANULARITY")
+		return
+	}
+
+	loc := time.UTC
+	if timezone != "" && timezone != "UTC" {
+		if parsed, err := time.LoadLocation(timezone); err == nil {
+			loc = parsed
+		}
+	}
+
+	if !h.db.IsRelayDBConnected() {
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"data":           []db.AuthorGrowthPoint{},
+			"authors_before": 0,
+			"new_authors":    0,
+			"total_authors":  0,
+			"time_range":     timeRange,
+			"granularity":    granularity,
+		})
+		return
+	}
+
+	key := "author-growth:" + timeRange + ":" + granularity + ":" + timezone
+	value, computedAt, err := h.cachedStats(r, key, func(ctx context.Context) (interface{}, error) {
+		since, until := parseTimeRange(timeRange, timezone)
+		return h.db.GetAuthorGrowth(ctx, since, until, granularity, loc)
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get author growth", "STATS_FAILED")
+		return
+	}
+	growth := value.(*db.AuthorGrowth)
+
+	total := growth.Before
+	if len(growth.Points) > 0 {
+		total = growth.Points[len(growth.Points)-1].Total
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"data":           growth.Points,
+		"authors_before": growth.Before,
+		"new_authors":    total - growth.Before,
+		"total_authors":  total,
+		"time_range":     timeRange,
+		"granularity":    granularity,
+		"computed_at":    computedAt,
+	})
+}
+
+// Interactor is a pubkey that engaged with another, with its name and
+// access.
+type Interactor struct {
+	db.Interaction
+	Npub   string `json:"npub"`
+	Name   string `json:"name,omitempty"` // Whitelist nickname, else cached profile name
+	Access string `json:"access"`         // As for top authors
+}
+
+// GetInteractions returns who mentioned, replied to and reacted to a pubkey
+// most, so operators can see how members engage with each other.
+// GET /api/v1/analytics/interactions/{pubkey}
+func (h *Handler) GetInteractions(w http.ResponseWriter, r *http.Request) {
+	pubkey, npub, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey (expected hex or npub)", "INVALID_PUBKEY")
+		return
+	}
+
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = min(parsed, 100)
+		}
+	}
+	timeRange := r.URL.Query().Get("time_range")
+	if timeRange == "" {
+		timeRange = "30days"
+	}
+	timezone := r.URL.Query().Get("timezone")
+
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	key := fmt.Sprintf("interactions:%s:%s:%s:%d", pubkey, timeRange, timezone, limit)
+	value, computedAt, err := h.cachedStats(r, key, func(ctx context.Context) (interface{}, error) {
+		since, until := parseTimeRange(timeRange, timezone)
+		return h.db.GetInteractions(ctx, pubkey, since, until, limit)
+	})
+	switch {
+	case errors.Is(err, db.ErrUnsupportedOnPostgres), errors.Is(err, db.ErrNoTagIndex):
+		respondError(w, http.StatusNotImplemented, "Interactions need a SQLite relay database with a tag index", "INTERACTIONS_UNSUPPORTED")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, "Failed to get interactions", "STATS_FAILED")
+		return
+	}
+	interactions := value.(*db.Interactions)
+
+	pubkeys := make([]string, len(interactions.Interactors))
+	for i, in := range interactions.Interactors {
+		pubkeys[i] = in.Pubkey
+	}
+	access, err := h.db.GetAuthorAccess(r.Context(), pubkeys)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get author access", "DB_ERROR")
+		return
+	}
+	interactors := make([]Interactor, len(interactions.Interactors))
+	for i, in := range interactions.Interactors {
+		a := access[in.Pubkey]
+		interactors[i] = Interactor{Interaction: in, Name: a.Nickname, Access: authorAccessLabel(a)}
+		interactors[i].Npub, _ = nostr.EncodeNpub(in.Pubkey)
+		if interactors[i].Name == "" {
+			interactors[i].Name = a.Name
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"pubkey":      pubkey,
+		"npub":        npub,
+		"time_range":  timeRange,
+		"mentions":    interactions.Mentions,
+		"replies":     interactions.Replies,
+		"reactions":   interactions.Reactions,
+		"pubkeys":     interactions.Pubkeys,
+		"interactors": interactors,
+		"computed_at": computedAt,
+	})
+}
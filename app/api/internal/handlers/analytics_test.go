@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestKindTrendSeries(t *testing.T) {
+	trends := &db.KindTrends{
+		Buckets: []string{"2025-03-08", "2025-03-09", "2025-03-10"},
+		Kinds: map[int][]int64{
+			1:     {5, 5, 5},
+			7:     {1, 2, 3},
+			3:     {0, 1, 0},
+			30023: {0, 0, 1},
+		},
+	}
+
+	series := kindTrendSeries(trends, 2)
+	if len(series) != 3 {
+		t.Fatalf("expected 2 kinds and other, got %+v", series)
+	}
+	if *series[0].Kind != 1 || series[0].Total != 15 || *series[1].Kind != 7 || series[1].Label != "reactions" {
+		t.Errorf("expected notes then reactions, got %+v", series[:2])
+	}
+	if other := series[2]; other.Kind != nil || other.Label != "other" || other.Total != 2 || fmt.Sprint(other.Counts) != "[0 1 1]" {
+		t.Errorf("unexpected other series: %+v", other)
+	}
+
+	if all := kindTrendSeries(trends, 10); len(all) != 4 || all[3].Kind == nil {
+		t.Errorf("expected every kind without other, got %+v", all)
+	}
+	if empty := kindTrendSeries(&db.KindTrends{Buckets: []string{}, Kinds: map[int][]int64{}}, 5); empty == nil || len(empty) != 0 {
+		t.Errorf("expected an empty slice, got %#v", empty)
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// auditCSVHeader is the column layout of the audit log export.
+var auditCSVHeader = []string{"id", "time", "action", "performed_by", "details"}
+
+// GetAuditLog returns audit log entries, newest first.
+// GET /api/v1/audit
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseAuditLogFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.Limit = parseIntParam(r.URL.Query().Get("limit"), 50)
+	if filter.Limit < 1 || filter.Limit > 500 {
+		filter.Limit = 50
+	}
+	filter.Offset = parseIntParam(r.URL.Query().Get("offset"), 0)
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	entries, total, err := h.db.GetAuditLog(r.Context(), filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get audit log", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"total":   total,
+	})
+}
+
+// ExportAuditLogCSV streams the audit log entries matching the filters as CSV.
+// GET /api/v1/audit.csv
+func (h *Handler) ExportAuditLogCSV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, ok := parseAuditLogFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.Limit = -1
+
+	entries, total, err := h.db.GetAuditLog(ctx, filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get audit log", "DB_ERROR")
+		return
+	}
+
+	filename := fmt.Sprintf("roostr-audit-%s.csv", time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+
+	cw := csv.NewWriter(w)
+	cw.Write(auditCSVHeader)
+
+	for i, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			e.Action,
+			e.PerformedBy,
+			string(e.Details),
+		})
+
+		// Flush periodically so large exports stream to the client
+		if (i+1)%100 == 0 {
+			cw.Flush()
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.ErrorContext(ctx, "Audit log CSV export failed", "error", err)
+	}
+}
+
+// parseAuditLogFilter reads the audit log filters from the query string,
+// writing an error response on failure.
+func parseAuditLogFilter(w http.ResponseWriter, r *http.Request) (db.AuditLogFilter, bool) {
+	query := r.URL.Query()
+	filter := db.AuditLogFilter{
+		Action:      query.Get("action"),
+		PerformedBy: query.Get("performed_by"),
+		Query:       query.Get("q"),
+	}
+
+	if filter.PerformedBy != "" {
+		if hex, _, err := nostr.ValidatePubkey(filter.PerformedBy); err == nil {
+			filter.PerformedBy = hex
+		}
+	}
+	if pubkey := query.Get("pubkey"); pubkey != "" {
+		hex, npub, err := nostr.ValidatePubkey(pubkey)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid pubkey", "INVALID_PUBKEY")
+			return filter, false
+		}
+		filter.Pubkeys = []string{hex, npub}
+	}
+
+	if since := query.Get("since"); since != "" {
+		if ts, err := strconv.ParseInt(since, 10, 64); err == nil {
+			filter.Since = time.Unix(ts, 0)
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if ts, err := strconv.ParseInt(until, 10, 64); err == nil {
+			filter.Until = time.Unix(ts, 0)
+		}
+	}
+	return filter, true
+}
+
+// GetAuditSettings returns how long the audit log is kept.
+// GET /api/v1/audit/settings
+func (h *Handler) GetAuditSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetAuditSettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get audit settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateAuditSettings saves how long the audit log is kept and prunes it to
+// the new window.
+// PUT /api/v1/audit/settings
+func (h *Handler) UpdateAuditSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetAuditSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get audit settings", "DB_ERROR")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if settings.RetentionDays < 0 {
+		respondError(w, http.StatusBadRequest, "retention_days must be 0 or more", "INVALID_SETTINGS")
+		return
+	}
+
+	if err := h.db.SetAuditSettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save audit settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "audit_settings_updated", settings, "")
+
+	if h.services != nil && h.services.Audit != nil {
+		if _, err := h.services.Audit.Prune(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to prune audit log", "error", err)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
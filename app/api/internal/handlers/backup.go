@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// minBackupPassphraseLength is the shortest passphrase backups can be
+// encrypted with.
+const minBackupPassphraseLength = 12
+
+// CreateBackup takes a full backup of the relay database, the app database
+// and config.toml as a tar.gz. By default the archive is streamed back as a
+// download; with ?save=true it is written to the backup directory instead.
+// With ?encrypt=true it is encrypted with the backup passphrase.
+// POST /api/v1/backup?save=true&encrypt=true
+func (h *Handler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+	ctx := r.Context()
+
+	passphrase := ""
+	if r.URL.Query().Get("encrypt") == "true" {
+		passphrase, _ = h.db.GetBackupPassphrase(ctx)
+		if passphrase == "" {
+			respondBackupError(w, services.ErrBackupPassphraseNotSet)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("save") == "true" {
+		backup, err := h.services.Backup.Save(ctx, passphrase)
+		if err != nil {
+			respondBackupError(w, err)
+			return
+		}
+
+		h.db.AddAuditLog(ctx, "backup_created", map[string]interface{}{
+			"name": backup.Name,
+			"size": backup.Size,
+		}, "")
+
+		respondJSON(w, http.StatusCreated, map[string]interface{}{
+			"success": true,
+			"backup":  backup,
+		})
+		return
+	}
+
+	snapshot, err := h.services.Backup.Snapshot(ctx)
+	if err != nil {
+		respondBackupError(w, err)
+		return
+	}
+	defer snapshot.Close()
+
+	filename := snapshot.Filename(passphrase != "")
+	if passphrase != "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/gzip")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	var size int64
+	if passphrase != "" {
+		size, err = snapshot.WriteEncryptedTo(w, passphrase)
+	} else {
+		size, err = snapshot.WriteTo(w)
+	}
+	if err != nil {
+		// Headers are sent; all we can do is log and cut the download short
+		slog.Error("Failed to stream backup", "error", err)
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "backup_downloaded", map[string]interface{}{
+		"name":  filename,
+		"size":  size,
+		"files": snapshot.Manifest.Files,
+	}, "")
+}
+
+// ListBackups returns the backups saved in the backup directory, newest
+// first.
+// GET /api/v1/backup/archives
+func (h *Handler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+
+	backups, err := h.services.Backup.List()
+	if err != nil {
+		respondBackupError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"backups": backups,
+		"total":   len(backups),
+	})
+}
+
+// DownloadBackup streams a saved backup.
+// GET /api/v1/backup/archives/{name}
+func (h *Handler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+
+	name := r.PathValue("name")
+	f, err := h.services.Backup.Open(name)
+	if err != nil {
+		respondBackupError(w, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to read backup", "BACKUP_READ_FAILED")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// DeleteBackup removes a saved backup.
+// DELETE /api/v1/backup/archives/{name}
+func (h *Handler) DeleteBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+	ctx := r.Context()
+
+	name := r.PathValue("name")
+	if err := h.services.Backup.Delete(name); err != nil {
+		respondBackupError(w, err)
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "backup_deleted", map[string]interface{}{
+		"name": name,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Backup deleted",
+	})
+}
+
+// BackupScheduleRequest is the request body for updating the backup
+// schedule. A non-empty passphrase replaces the saved one.
+type BackupScheduleRequest struct {
+	db.BackupSchedule
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// GetBackupSchedule returns the backup schedule, the last scheduled backup
+// and when the next one is due.
+// GET /api/v1/backup/schedule
+func (h *Handler) GetBackupSchedule(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+	h.respondBackupSchedule(w, r)
+}
+
+// UpdateBackupSchedule saves the backup schedule and, if given, the backup
+// passphrase.
+// PUT /api/v1/backup/schedule
+func (h *Handler) UpdateBackupSchedule(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+	ctx := r.Context()
+
+	var req BackupScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if err := services.ValidateBackupSchedule(&req.BackupSchedule); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_SCHEDULE")
+		return
+	}
+	// A schedule set in the config file can only be resent unchanged, e.g.
+	// to set the passphrase
+	if cfg := h.currentConfig(); cfg != nil && cfg.BackupSchedule != nil &&
+		req.BackupSchedule != services.ConfigBackupSchedule(cfg.BackupSchedule) {
+		respondConfigManaged(w, "backup schedule settings")
+		return
+	}
+	if req.Passphrase != "" && len(req.Passphrase) < minBackupPassphraseLength {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Passphrase must be at least %d characters", minBackupPassphraseLength), "PASSPHRASE_TOO_SHORT")
+		return
+	}
+	if req.Encrypt && req.Passphrase == "" {
+		if current, _ := h.db.GetBackupPassphrase(ctx); current == "" {
+			respondBackupError(w, services.ErrBackupPassphraseNotSet)
+			return
+		}
+	}
+
+	if req.Passphrase != "" {
+		if err := h.db.SetBackupPassphrase(ctx, req.Passphrase); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to save passphrase", "DB_ERROR")
+			return
+		}
+	}
+	if err := h.db.SetBackupSchedule(ctx, &req.BackupSchedule); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update backup schedule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "backup_schedule_updated", map[string]interface{}{
+		"enabled":            req.Enabled,
+		"frequency":          req.Frequency,
+		"retain":             req.Retain,
+		"encrypt":            req.Encrypt,
+		"passphrase_changed": req.Passphrase != "",
+	}, "")
+
+	h.respondBackupSchedule(w, r)
+}
+
+// respondBackupSchedule writes the backup schedule and its status.
+func (h *Handler) respondBackupSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	schedule, err := h.db.GetBackupSchedule(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get backup schedule", "DB_ERROR")
+		return
+	}
+	last, err := h.db.GetLastScheduledBackup(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get last backup", "DB_ERROR")
+		return
+	}
+	passphrase, _ := h.db.GetBackupPassphrase(ctx)
+
+	var nextRun *time.Time
+	if schedule.Enabled {
+		next := services.NextBackupTime(schedule, time.Now())
+		nextRun = &next
+	}
+	saved := 0
+	if backups, err := h.services.Backup.List(); err == nil {
+		saved = len(backups)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"schedule":       schedule,
+		"passphrase_set": passphrase != "",
+		"last_run":       last,
+		"next_run_at":    nextRun,
+		"saved_backups":  saved,
+	})
+}
+
+// respondBackupError maps backup service errors to responses.
+func respondBackupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrBackupInProgress):
+		respondError(w, http.StatusConflict, err.Error(), "BACKUP_IN_PROGRESS")
+	case errors.Is(err, services.ErrBackupFileNotFound):
+		respondError(w, http.StatusNotFound, err.Error(), "BACKUP_NOT_FOUND")
+	case errors.Is(err, services.ErrInvalidBackupName):
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_BACKUP_NAME")
+	case errors.Is(err, services.ErrBackupPassphraseNotSet):
+		respondError(w, http.StatusBadRequest, err.Error(), "BACKUP_PASSPHRASE_NOT_SET")
+	case errors.Is(err, services.ErrBackupDirNotConfigured):
+		respondError(w, http.StatusServiceUnavailable, err.Error(), "BACKUP_DIR_NOT_CONFIGURED")
+	default:
+		slog.Error("Backup failed", "error", err)
+		respondError(w, http.StatusInternalServerError, "Backup failed: "+err.Error(), "BACKUP_FAILED")
+	}
+}
+
+// backupAvailable responds with 503 if the backup service is not running.
+func (h *Handler) backupAvailable(w http.ResponseWriter) bool {
+	if h.services == nil || h.services.Backup == nil {
+		respondError(w, http.StatusServiceUnavailable, "Backup service not available", "SERVICE_UNAVAILABLE")
+		return false
+	}
+	return true
+}
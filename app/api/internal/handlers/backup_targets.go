@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/secrets"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// backupTargetCheckTimeout bounds a backup target connection test.
+const backupTargetCheckTimeout = 30 * time.Second
+
+// BackupTargetRequest is the request body for creating, updating or testing
+// a backup target. Omitted fields are left unchanged on update.
+type BackupTargetRequest struct {
+	Name        *string               `json:"name"`
+	Type        *string               `json:"type"`
+	Destination *db.ExportDestination `json:"destination"`
+	Retain      *int                  `json:"retain"`
+	Enabled     *bool                 `json:"enabled"`
+}
+
+// BackupTargetResponse is a backup target with credentials redacted and its
+// most recent upload attached.
+type BackupTargetResponse struct {
+	db.BackupTarget
+	LastUpload *db.BackupUpload `json:"last_upload,omitempty"`
+}
+
+// GetBackupTargets returns the remote targets backups are uploaded to.
+// GET /api/v1/backup/targets
+func (h *Handler) GetBackupTargets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	targets, err := h.db.GetBackupTargets(ctx)
+	if err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get backup targets", "DB_ERROR")
+		return
+	}
+
+	response := make([]BackupTargetResponse, 0, len(targets))
+	for _, t := range targets {
+		item := backupTargetResponse(t)
+		if uploads, err := h.db.GetBackupUploads(ctx, t.ID, 1); err == nil && len(uploads) > 0 {
+			item.LastUpload = &uploads[0]
+		}
+		response = append(response, item)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"targets": response,
+	})
+}
+
+// CreateBackupTarget adds a backup target. Scheduled backups are uploaded to
+// it from the next run.
+// POST /api/v1/backup/targets
+func (h *Handler) CreateBackupTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req BackupTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	t := &db.BackupTarget{Retain: 7, Enabled: true}
+	applyBackupTargetRequest(t, req)
+	if t.Name == "" && t.Type != "" {
+		t.Name = "Backups to " + t.Type
+	}
+	if err := services.ValidateBackupTarget(t); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_TARGET")
+		return
+	}
+
+	if err := h.db.CreateBackupTarget(ctx, t); err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create backup target", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "backup_target_created", map[string]interface{}{
+		"id":   t.ID,
+		"name": t.Name,
+		"type": t.Type,
+	}, "")
+
+	if created, _ := h.db.GetBackupTarget(ctx, t.ID); created != nil {
+		t = created
+	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"target": backupTargetResponse(*t),
+	})
+}
+
+// GetBackupTarget returns a backup target and its recent uploads.
+// GET /api/v1/backup/targets/{id}
+func (h *Handler) GetBackupTarget(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.loadBackupTarget(w, r)
+	if !ok {
+		return
+	}
+
+	uploads, err := h.db.GetBackupUploads(r.Context(), t.ID, 20)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get backup uploads", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"target":  backupTargetResponse(*t),
+		"uploads": uploads,
+	})
+}
+
+// UpdateBackupTarget changes a backup target's settings. A destination update
+// that omits secret_key or password (or sends the redacted value) keeps the
+// stored one.
+// PATCH /api/v1/backup/targets/{id}
+func (h *Handler) UpdateBackupTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	t, ok := h.loadBackupTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var req BackupTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	previous := t.Destination
+	applyBackupTargetRequest(t, req)
+	if req.Destination != nil {
+		keepDestinationSecrets(&t.Destination, previous)
+	}
+	if err := services.ValidateBackupTarget(t); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_TARGET")
+		return
+	}
+
+	if err := h.db.UpdateBackupTarget(ctx, t); err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to update backup target", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "backup_target_updated", map[string]interface{}{
+		"id":      t.ID,
+		"name":    t.Name,
+		"type":    t.Type,
+		"enabled": t.Enabled,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"target": backupTargetResponse(*t),
+	})
+}
+
+// DeleteBackupTarget removes a backup target and its upload records. Backups
+// already uploaded are left in place.
+// DELETE /api/v1/backup/targets/{id}
+func (h *Handler) DeleteBackupTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	t, ok := h.loadBackupTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteBackupTarget(ctx, t.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete backup target", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "backup_target_deleted", map[string]interface{}{
+		"id":   t.ID,
+		"name": t.Name,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Backup target deleted",
+	})
+}
+
+// TestBackupTarget checks that a saved backup target accepts uploads by
+// uploading an empty file and deleting it.
+// POST /api/v1/backup/targets/{id}/test
+func (h *Handler) TestBackupTarget(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+
+	t, ok := h.loadBackupTarget(w, r)
+	if !ok {
+		return
+	}
+	h.checkBackupTarget(w, r, t)
+}
+
+// TestBackupTargetSettings checks a backup target's settings before it is
+// saved.
+// POST /api/v1/backup/targets/test
+func (h *Handler) TestBackupTargetSettings(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+
+	var req BackupTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	t := &db.BackupTarget{Name: "test"}
+	applyBackupTargetRequest(t, req)
+	h.checkBackupTarget(w, r, t)
+}
+
+// checkBackupTarget runs a connection test and writes the result.
+func (h *Handler) checkBackupTarget(w http.ResponseWriter, r *http.Request, t *db.BackupTarget) {
+	ctx, cancel := context.WithTimeout(r.Context(), backupTargetCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.services.Backup.CheckTarget(ctx, t)
+	if errors.Is(err, services.ErrInvalidBackupTarget) {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_TARGET")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadGateway, "Connection test failed: "+err.Error(), "TARGET_UNREACHABLE")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"message":     "Connection OK",
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+}
+
+// UploadBackup uploads a saved backup to every enabled backup target. Runs
+// as a job; with async=true it returns 202 and the job.
+// POST /api/v1/backup/archives/{name}/upload
+func (h *Handler) UploadBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.backupAvailable(w) {
+		return
+	}
+	name := r.PathValue("name")
+
+	// Check the name first so a missing backup is a 404, not a failed job
+	f, err := h.services.Backup.Open(name)
+	if err != nil {
+		respondBackupError(w, err)
+		return
+	}
+	f.Close()
+
+	params := map[string]interface{}{"name": name}
+	h.runJob(w, r, services.JobTypeBackupUpload, params, func(ctx context.Context, run *services.JobRun) (interface{}, error) {
+		uploads, err := h.services.Backup.Upload(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		failed := 0
+		for _, upload := range uploads {
+			if upload.Status != "completed" {
+				failed++
+			}
+		}
+		h.db.AddAuditLog(context.WithoutCancel(ctx), "backup_uploaded", map[string]interface{}{
+			"name":     name,
+			"uploaded": len(uploads) - failed,
+			"failed":   failed,
+		}, "")
+
+		return map[string]interface{}{
+			"name":    name,
+			"uploads": uploads,
+			"failed":  failed,
+		}, nil
+	}, "Failed to upload backup", "UPLOAD_FAILED")
+}
+
+// loadBackupTarget resolves the {id} path value, writing an error response on failure.
+func (h *Handler) loadBackupTarget(w http.ResponseWriter, r *http.Request) (*db.BackupTarget, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "Invalid target ID", "INVALID_ID")
+		return nil, false
+	}
+
+	t, err := h.db.GetBackupTarget(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return nil, false
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get backup target", "DB_ERROR")
+		return nil, false
+	}
+	if t == nil {
+		respondError(w, http.StatusNotFound, "Backup target not found", "NOT_FOUND")
+		return nil, false
+	}
+
+	return t, true
+}
+
+// backupTargetResponse redacts a target's credentials.
+func backupTargetResponse(t db.BackupTarget) BackupTargetResponse {
+	redactDestination(&t.Destination)
+	return BackupTargetResponse{BackupTarget: t}
+}
+
+// applyBackupTargetRequest copies the fields present in req onto t.
+func applyBackupTargetRequest(t *db.BackupTarget, req BackupTargetRequest) {
+	if req.Name != nil {
+		t.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Type != nil {
+		t.Type = *req.Type
+	}
+	if req.Destination != nil {
+		t.Destination = *req.Destination
+	}
+	if req.Retain != nil {
+		t.Retain = *req.Retain
+	}
+	if req.Enabled != nil {
+		t.Enabled = *req.Enabled
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// bandwidthTotals is the traffic for a day or IP class.
+type bandwidthTotals struct {
+	BytesIn     int64 `json:"bytes_in"`
+	BytesOut    int64 `json:"bytes_out"`
+	Connections int64 `json:"connections"`
+}
+
+// bandwidthDay is one day of traffic broken down by IP class.
+type bandwidthDay struct {
+	Date string `json:"date"`
+	bandwidthTotals
+	ByClass map[string]bandwidthTotals `json:"by_class"`
+}
+
+// GetBandwidthStats returns relay traffic per day and client IP class,
+// as accounted by the bandwidth proxy.
+// GET /api/v1/stats/bandwidth
+func (h *Handler) GetBandwidthStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	days := 30
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed < 1 || parsed > 365 {
+			respondError(w, http.StatusBadRequest, "days must be between 1 and 365", "INVALID_DAYS")
+			return
+		}
+		days = parsed
+	}
+
+	today := time.Now().UTC()
+	since := today.AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+
+	usage, err := h.db.GetBandwidthUsage(ctx, since)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get bandwidth usage", "DB_ERROR")
+		return
+	}
+
+	bandwidth := h.services.Bandwidth
+	usage = append(usage, bandwidth.Unflushed()...)
+
+	dayList, byClass, totals := summarizeBandwidth(usage, today, days)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":            bandwidth.IsEnabled(),
+		"active_connections": bandwidth.ActiveConnections(),
+		"days":               dayList,
+		"by_class":           byClass,
+		"totals":             totals,
+	})
+}
+
+// summarizeBandwidth groups usage rows into one entry per day (oldest first,
+// zero-filled) ending at today, plus per-class and overall totals.
+func summarizeBandwidth(usage []db.BandwidthUsage, today time.Time, days int) ([]bandwidthDay, map[string]bandwidthTotals, bandwidthTotals) {
+	dayList := make([]bandwidthDay, days)
+	index := make(map[string]int, days)
+	start := today.AddDate(0, 0, -(days - 1))
+	for i := 0; i < days; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		dayList[i] = bandwidthDay{Date: date, ByClass: map[string]bandwidthTotals{}}
+		index[date] = i
+	}
+
+	byClass := map[string]bandwidthTotals{}
+	var totals bandwidthTotals
+
+	for _, u := range usage {
+		i, ok := index[u.Date]
+		if !ok {
+			continue
+		}
+
+		day := &dayList[i]
+		day.BytesIn += u.BytesIn
+		day.BytesOut += u.BytesOut
+		day.Connections += u.Connections
+
+		c := day.ByClass[u.IPClass]
+		c.BytesIn += u.BytesIn
+		c.BytesOut += u.BytesOut
+		c.Connections += u.Connections
+		day.ByClass[u.IPClass] = c
+
+		c = byClass[u.IPClass]
+		c.BytesIn += u.BytesIn
+		c.BytesOut += u.BytesOut
+		c.Connections += u.Connections
+		byClass[u.IPClass] = c
+
+		totals.BytesIn += u.BytesIn
+		totals.BytesOut += u.BytesOut
+		totals.Connections += u.Connections
+	}
+
+	return dayList, byClass, totals
+}
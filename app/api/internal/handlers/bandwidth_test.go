@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestSummarizeBandwidth(t *testing.T) {
+	today := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	usage := []db.BandwidthUsage{
+		{Date: "2024-03-08", IPClass: "lan", BytesIn: 100, BytesOut: 200, Connections: 1},
+		{Date: "2024-03-10", IPClass: "lan", BytesIn: 10, BytesOut: 20, Connections: 2},
+		{Date: "2024-03-10", IPClass: "public_ipv4", BytesIn: 1, BytesOut: 2, Connections: 3},
+		// Unflushed counters for the same day/class are merged
+		{Date: "2024-03-10", IPClass: "lan", BytesIn: 5, BytesOut: 5, Connections: 0},
+		// Outside the window
+		{Date: "2024-03-01", IPClass: "lan", BytesIn: 999, BytesOut: 999, Connections: 9},
+	}
+
+	days, byClass, totals := summarizeBandwidth(usage, today, 3)
+
+	if len(days) != 3 || days[0].Date != "2024-03-08" || days[2].Date != "2024-03-10" {
+		t.Fatalf("unexpected days: %+v", days)
+	}
+	if days[1].BytesIn != 0 || len(days[1].ByClass) != 0 {
+		t.Errorf("expected zero-filled middle day, got %+v", days[1])
+	}
+	if days[2].BytesIn != 16 || days[2].ByClass["lan"].BytesIn != 15 {
+		t.Errorf("unexpected last day: %+v", days[2])
+	}
+	if byClass["lan"].BytesOut != 225 || byClass["public_ipv4"].Connections != 3 {
+		t.Errorf("unexpected class totals: %+v", byClass)
+	}
+	if totals.BytesIn != 116 || totals.BytesOut != 227 || totals.Connections != 6 {
+		t.Errorf("unexpected totals: %+v", totals)
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// RunBench times standard query workloads (stats, filtered listings and an
+// export) against the relay database and reports their latencies. Only
+// available with DEBUG=true, since a run loads the relay database.
+// POST /api/v1/debug/bench?iterations=10
+func (h *Handler) RunBench(w http.ResponseWriter, r *http.Request) {
+	if cfg := h.currentConfig(); cfg == nil || !cfg.Debug {
+		respondError(w, http.StatusNotFound, "Debug endpoints are disabled (set DEBUG=true)", "DEBUG_DISABLED")
+		return
+	}
+	if h.services == nil || h.services.Bench == nil {
+		respondError(w, http.StatusServiceUnavailable, "Benchmark service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	iterations := parseIntParam(r.URL.Query().Get("iterations"), services.DefaultBenchIterations)
+	if iterations < 1 || iterations > services.MaxBenchIterations {
+		respondError(w, http.StatusBadRequest, "iterations must be between 1 and 200", "INVALID_ITERATIONS")
+		return
+	}
+
+	report, err := h.services.Bench.Run(r.Context(), iterations)
+	if err != nil {
+		if errors.Is(err, services.ErrBenchRunning) {
+			respondError(w, http.StatusConflict, "A benchmark is already running", "BENCH_RUNNING")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Benchmark failed", "BENCH_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/config"
+)
+
+func TestRunBenchNeedsDebug(t *testing.T) {
+	h := &Handler{cfg: &config.Config{}}
+	w := httptest.NewRecorder()
+	h.RunBench(w, httptest.NewRequest("POST", "/api/v1/debug/bench", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without DEBUG, got %d", w.Code)
+	}
+
+	h.cfg.Debug = true
+	w = httptest.NewRecorder()
+	h.RunBench(w, httptest.NewRequest("POST", "/api/v1/debug/bench", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 without services, got %d", w.Code)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// StartBroadcast starts pushing local events to remote relays. Authors may be
+// hex or npub; no relays means the sync relays.
+// POST /api/v1/broadcast
+func (h *Handler) StartBroadcast(w http.ResponseWriter, r *http.Request) {
+	if !h.broadcastAvailable(w) {
+		return
+	}
+
+	var req services.BroadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	b, job, ok := h.startBroadcast(w, r, req)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/broadcast/%d", b.ID))
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"broadcast": b,
+		"job":       job,
+	})
+}
+
+// ListBroadcasts returns broadcasts, newest first.
+// GET /api/v1/broadcast?limit=20&offset=0
+func (h *Handler) ListBroadcasts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := parseIntParam(query.Get("limit"), 20)
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	offset := parseIntParam(query.Get("offset"), 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	broadcasts, total, err := h.db.GetBroadcastJobs(r.Context(), limit, offset)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get broadcasts", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"broadcasts": broadcasts,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// GetBroadcast returns a broadcast with its per-relay results.
+// GET /api/v1/broadcast/{id}
+func (h *Handler) GetBroadcast(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.broadcastFromPath(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, b)
+}
+
+// CancelBroadcast asks a running broadcast to stop. Results so far are kept.
+// POST /api/v1/broadcast/{id}/cancel
+func (h *Handler) CancelBroadcast(w http.ResponseWriter, r *http.Request) {
+	if !h.broadcastAvailable(w) {
+		return
+	}
+	b, ok := h.broadcastFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Broadcast.Cancel(b); err != nil {
+		respondError(w, http.StatusConflict, "Broadcast is not running", "JOB_NOT_RUNNING")
+		return
+	}
+
+	h.db.AddAuditLog(r.Context(), "broadcast_cancelled", map[string]interface{}{
+		"broadcast_id": b.ID,
+		"job_id":       b.JobID,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Cancellation requested",
+	})
+}
+
+// startBroadcast validates a broadcast request and starts it, responding with
+// an error if it could not be started.
+func (h *Handler) startBroadcast(w http.ResponseWriter, r *http.Request, req services.BroadcastRequest) (*db.BroadcastJob, *db.Job, bool) {
+	for i, author := range req.Authors {
+		hex, _, err := nostr.ValidatePubkey(author)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid author %q (expected hex or npub)", author), "INVALID_PUBKEY")
+			return nil, nil, false
+		}
+		req.Authors[i] = strings.ToLower(hex)
+	}
+	if req.Since != nil && req.Until != nil && *req.Since > *req.Until {
+		respondError(w, http.StatusBadRequest, "since must be before until", "INVALID_TIME_RANGE")
+		return nil, nil, false
+	}
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return nil, nil, false
+	}
+
+	b, job, err := h.services.Broadcast.Broadcast(r.Context(), req)
+	switch {
+	case errors.Is(err, services.ErrNoBroadcastRelays):
+		respondError(w, http.StatusBadRequest, "No relays given and no sync relays configured", "MISSING_RELAYS")
+		return nil, nil, false
+	case errors.Is(err, services.ErrTooManyBroadcastRelays), errors.Is(err, services.ErrInvalidBroadcastRelay):
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_RELAYS")
+		return nil, nil, false
+	}
+	if !jobStarted(w, err) {
+		return nil, nil, false
+	}
+
+	h.db.AddAuditLog(r.Context(), "broadcast_started", map[string]interface{}{
+		"broadcast_id": b.ID,
+		"job_id":       job.ID,
+		"authors":      len(b.Authors),
+		"kinds":        b.Kinds,
+		"relays":       b.Relays,
+	}, "")
+
+	return b, job, true
+}
+
+// broadcastAvailable responds with 503 if the broadcast service is not running.
+func (h *Handler) broadcastAvailable(w http.ResponseWriter) bool {
+	if h.services == nil || h.services.Broadcast == nil {
+		respondError(w, http.StatusServiceUnavailable, "Broadcast service not available", "SERVICE_UNAVAILABLE")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) broadcastFromPath(w http.ResponseWriter, r *http.Request) (*db.BroadcastJob, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid broadcast ID", "INVALID_ID")
+		return nil, false
+	}
+	b, err := h.db.GetBroadcastJob(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get broadcast", "DB_ERROR")
+		return nil, false
+	}
+	if b == nil {
+		respondError(w, http.StatusNotFound, "Broadcast not found", "BROADCAST_NOT_FOUND")
+		return nil, false
+	}
+	return b, true
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetCashuSettings returns the Cashu payment settings.
+// GET /api/v1/access/cashu
+func (h *Handler) GetCashuSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetCashuSettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get Cashu settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateCashuSettings saves the Cashu payment settings.
+// PUT /api/v1/access/cashu
+func (h *Handler) UpdateCashuSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetCashuSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get Cashu settings", "DB_ERROR")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	mints := []string{}
+	for _, m := range settings.Mints {
+		m = services.NormalizeMintURL(m)
+		u, err := url.Parse(m)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			respondError(w, http.StatusBadRequest, "Mint URLs must be http or https URLs: "+m, "INVALID_SETTINGS")
+			return
+		}
+		mints = append(mints, m)
+	}
+	settings.Mints = mints
+	if settings.Enabled && len(settings.Mints) == 0 {
+		respondError(w, http.StatusBadRequest, "At least one mint is required to accept Cashu", "INVALID_SETTINGS")
+		return
+	}
+
+	if err := h.db.SetCashuSettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save Cashu settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "cashu_settings_updated", settings, "")
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// PayWithCashu pays for relay access with a Cashu token. The token is melted
+// at its mint to pay an access invoice, and the pubkey is whitelisted once the
+// payment arrives.
+// POST /public/cashu-payment
+func (h *Handler) PayWithCashu(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accessMode, err := h.db.GetAccessMode(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access mode", "DB_ERROR")
+		return
+	}
+	if accessMode != "paid" {
+		respondError(w, http.StatusBadRequest, "Paid access is not enabled", "PAID_ACCESS_DISABLED")
+		return
+	}
+
+	var req struct {
+		Pubkey string `json:"pubkey"` // hex, npub or NIP-05 identifier
+		TierID string `json:"tier_id"`
+		Token  string `json:"token"` // cashuA or cashuB token
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if req.Pubkey == "" {
+		respondError(w, http.StatusBadRequest, "Pubkey is required", "MISSING_PUBKEY")
+		return
+	}
+	if req.TierID == "" {
+		respondError(w, http.StatusBadRequest, "Tier ID is required", "MISSING_TIER")
+		return
+	}
+	if req.Token == "" {
+		respondError(w, http.StatusBadRequest, "Token is required", "MISSING_TOKEN")
+		return
+	}
+
+	hexPubkey, npub, ok := h.signupPubkey(w, r, req.Pubkey)
+	if !ok {
+		return
+	}
+
+	if h.services == nil || h.services.Cashu == nil {
+		respondError(w, http.StatusServiceUnavailable, "Cashu service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+	result, err := h.services.Cashu.Pay(ctx, hexPubkey, npub, req.TierID, req.Token)
+	switch {
+	case errors.Is(err, services.ErrCashuDisabled):
+		respondError(w, http.StatusBadRequest, err.Error(), "CASHU_DISABLED")
+	case errors.Is(err, services.ErrInvalidCashuToken):
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_TOKEN")
+	case errors.Is(err, services.ErrCashuMintNotAccepted):
+		respondError(w, http.StatusBadRequest, err.Error(), "MINT_NOT_ACCEPTED")
+	case errors.Is(err, services.ErrCashuUnit):
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_TOKEN")
+	case errors.Is(err, services.ErrCashuInsufficient):
+		respondError(w, http.StatusBadRequest, err.Error(), "INSUFFICIENT_AMOUNT")
+	case errors.Is(err, services.ErrCashuMeltFailed), errors.Is(err, services.ErrCashuMintNotReachable):
+		respondError(w, http.StatusBadGateway, err.Error(), "MINT_FAILED")
+	case errors.Is(err, services.ErrLNDNotConfigured):
+		respondError(w, http.StatusServiceUnavailable, "Lightning is not configured", "LN_NOT_CONFIGURED")
+	case err != nil:
+		slog.ErrorContext(ctx, "Failed to pay with Cashu", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to redeem token", "CASHU_FAILED")
+	case result.Status == "paid":
+		respondJSON(w, http.StatusCreated, result)
+	default:
+		respondJSON(w, http.StatusAccepted, result)
+	}
+}
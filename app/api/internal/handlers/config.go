@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
 // UpdateConfigRequest represents a partial config update request.
@@ -144,7 +147,7 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Write updated config
-	if err := h.configMgr.Write(cfg); err != nil {
+	if err := h.configMgr.WriteAs(cfg, "admin", "Updated "+getUpdatedSections(&req)); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to write config", "CONFIG_WRITE_FAILED")
 		return
 	}
@@ -152,7 +155,7 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	// Reload relay to apply changes
 	if h.relay != nil {
 		if err := h.relay.Reload(); err != nil {
-			log.Printf("Warning: failed to reload relay: %v", err)
+			slog.WarnContext(r.Context(), "Failed to reload relay", "error", err)
 		}
 	}
 
@@ -210,6 +213,60 @@ func (h *Handler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetConfigDrift reports how config.toml differs from the config Roostr
+// last wrote, with the whitelist and blacklist taken from the DB.
+// GET /api/v1/config/drift
+func (h *Handler) GetConfigDrift(w http.ResponseWriter, r *http.Request) {
+	if h.configMgr == nil || h.services == nil || h.services.ConfigDrift == nil {
+		respondError(w, http.StatusServiceUnavailable, "Config manager not available", "CONFIG_NOT_AVAILABLE")
+		return
+	}
+
+	drift, err := h.services.ConfigDrift.Check(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to check config drift", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to check config drift", "CONFIG_READ_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, drift)
+}
+
+// ReconcileConfig resolves config drift, either adopting the edits to
+// config.toml into the DB or overwriting the file from the DB.
+// POST /api/v1/config/reconcile
+func (h *Handler) ReconcileConfig(w http.ResponseWriter, r *http.Request) {
+	if h.configMgr == nil || h.services == nil || h.services.ConfigDrift == nil {
+		respondError(w, http.StatusServiceUnavailable, "Config manager not available", "CONFIG_NOT_AVAILABLE")
+		return
+	}
+
+	var req struct {
+		Direction string `json:"direction"` // adopt or overwrite
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if req.Direction != services.ReconcileAdopt && req.Direction != services.ReconcileOverwrite {
+		respondError(w, http.StatusBadRequest, "direction must be adopt or overwrite", "INVALID_DIRECTION")
+		return
+	}
+
+	result, err := h.services.ConfigDrift.Reconcile(r.Context(), req.Direction)
+	switch {
+	case errors.Is(err, services.ErrConfigFileInvalid):
+		respondError(w, http.StatusConflict, err.Error(), "CONFIG_INVALID")
+	case errors.Is(err, services.ErrNoConfigBaseline):
+		respondError(w, http.StatusConflict, err.Error(), "NO_BASELINE")
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to reconcile config", "direction", req.Direction, "error", err)
+		respondError(w, http.StatusInternalServerError, err.Error(), "RECONCILE_FAILED")
+	default:
+		respondJSON(w, http.StatusOK, result)
+	}
+}
+
 // validateConfigUpdate validates the config update request.
 func validateConfigUpdate(req *UpdateConfigRequest) error {
 	if req.Info != nil {
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/relay"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetConfigHistory lists the versions of config.toml Roostr wrote, newest
+// first.
+// GET /api/v1/config/history
+func (h *Handler) GetConfigHistory(w http.ResponseWriter, r *http.Request) {
+	limit := parseIntParam(r.URL.Query().Get("limit"), 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := parseIntParam(r.URL.Query().Get("offset"), 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	versions, total, err := h.db.GetConfigVersions(r.Context(), limit, offset)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get config history", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"versions": versions,
+		"total":    total,
+	})
+}
+
+// GetConfigVersion returns a version of config.toml with its content.
+// GET /api/v1/config/history/{id}
+func (h *Handler) GetConfigVersion(w http.ResponseWriter, r *http.Request) {
+	version, ok := h.loadConfigVersion(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, version)
+}
+
+// RollbackConfig restores the settings of an earlier version of config.toml
+// and reloads the relay. The whitelist and blacklist are left as they are,
+// since they follow the DB.
+// POST /api/v1/config/history/{id}/rollback
+func (h *Handler) RollbackConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.configMgr == nil {
+		respondError(w, http.StatusServiceUnavailable, "Config manager not available", "CONFIG_NOT_AVAILABLE")
+		return
+	}
+	version, ok := h.loadConfigVersion(w, r)
+	if !ok {
+		return
+	}
+
+	cfg, err := relay.ParseConfig([]byte(version.Content))
+	if err != nil {
+		respondError(w, http.StatusConflict, "Config version is not valid TOML", "CONFIG_INVALID")
+		return
+	}
+	whitelist, blacklist, err := services.AccessLists(ctx, h.db)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access lists", "DB_ERROR")
+		return
+	}
+	cfg.Authorization.PubkeyWhitelist = whitelist
+	cfg.Authorization.PubkeyBlacklist = blacklist
+
+	if err := h.configMgr.WriteAs(cfg, "admin", fmt.Sprintf("Rolled back to version %d", version.ID)); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to write config", "CONFIG_WRITE_FAILED")
+		return
+	}
+
+	if h.relay != nil {
+		if err := h.relay.Reload(); err != nil {
+			slog.WarnContext(ctx, "Failed to reload relay", "error", err)
+		}
+	}
+
+	h.db.AddAuditLog(ctx, "config_rolled_back", map[string]interface{}{
+		"version_id": version.ID,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"message":    "Configuration rolled back",
+		"version_id": version.ID,
+	})
+}
+
+// loadConfigVersion resolves the {id} path value, writing an error response on failure.
+func (h *Handler) loadConfigVersion(w http.ResponseWriter, r *http.Request) (*db.ConfigVersion, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "Invalid version ID", "INVALID_ID")
+		return nil, false
+	}
+
+	version, err := h.db.GetConfigVersion(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get config version", "DB_ERROR")
+		return nil, false
+	}
+	if version == nil {
+		respondError(w, http.StatusNotFound, "Config version not found", "NOT_FOUND")
+		return nil, false
+	}
+	return version, true
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetConfigPresets lists the limits presets and which one the relay's
+// current settings match, if any.
+// GET /api/v1/config/presets
+func (h *Handler) GetConfigPresets(w http.ResponseWriter, r *http.Request) {
+	presets := services.LimitsPresets()
+
+	current := ""
+	if h.configMgr != nil {
+		cfg, err := h.configMgr.Read()
+		policy, policyErr := h.db.GetRetentionPolicy(r.Context())
+		if err == nil && policyErr == nil {
+			for _, p := range presets {
+				if p.Matches(cfg, policy) {
+					current = p.ID
+				}
+			}
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"presets": presets,
+		"current": current,
+	})
+}
+
+// ApplyConfigPreset sets the relay's limits, kind allowlist and retention
+// from a preset and reloads the relay.
+// POST /api/v1/config/presets
+func (h *Handler) ApplyConfigPreset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.configMgr == nil {
+		respondError(w, http.StatusServiceUnavailable, "Config manager not available", "CONFIG_NOT_AVAILABLE")
+		return
+	}
+
+	var req struct {
+		Preset string `json:"preset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	preset := services.FindLimitsPreset(req.Preset)
+	if preset == nil {
+		respondError(w, http.StatusBadRequest, "Unknown preset: "+req.Preset, "INVALID_PRESET")
+		return
+	}
+
+	cfg, err := h.configMgr.Read()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to read config", "CONFIG_READ_FAILED")
+		return
+	}
+	policy, err := h.db.GetRetentionPolicy(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get retention policy", "DB_ERROR")
+		return
+	}
+	preset.Apply(cfg, policy)
+
+	if err := h.configMgr.WriteAs(cfg, "admin", "Applied preset: "+preset.Name); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to write config", "CONFIG_WRITE_FAILED")
+		return
+	}
+	if err := h.db.SetRetentionPolicy(ctx, policy); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save retention policy", "DB_ERROR")
+		return
+	}
+
+	if h.relay != nil {
+		if err := h.relay.Reload(); err != nil {
+			slog.WarnContext(ctx, "Failed to reload relay", "error", err)
+		}
+	}
+
+	h.db.AddAuditLog(ctx, "config_preset_applied", map[string]interface{}{
+		"preset": preset.ID,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Preset applied",
+		"preset":  preset,
+	})
+}
+
+// GetConfigPresetRecommendation recommends a limits preset from the event
+// volume of the last 30 days, the hardware and the access mode.
+// GET /api/v1/config/presets/recommendation
+func (h *Handler) GetConfigPresetRecommendation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	in := services.PresetInputs{}
+	in.AccessMode, _ = h.db.GetAccessMode(ctx)
+	if h.db.IsRelayDBConnected() {
+		count, err := h.db.CountEvents(ctx, db.EventFilter{Since: time.Now().AddDate(0, 0, -30)})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to count events", "DB_ERROR")
+			return
+		}
+		in.EventsPerDay = float64(count) / 30
+	}
+	in.DiskTotalBytes, _ = h.db.GetTotalDiskSpace()
+	if h.services != nil && h.services.Hardware != nil {
+		in.Hardware = h.services.Hardware.Profile()
+	}
+
+	respondJSON(w, http.StatusOK, services.RecommendLimitsPreset(in))
+}
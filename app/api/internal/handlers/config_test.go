@@ -673,3 +673,12 @@ func TestGetUpdatedSections(t *testing.T) {
 		}
 	})
 }
+
+func TestInvalidKinds(t *testing.T) {
+	if got := invalidKinds([]int{0, 4, 1059, 65535}); len(got) != 0 {
+		t.Errorf("expected valid kinds, got invalid %v", got)
+	}
+	if got := invalidKinds([]int{4, -1, 70000}); len(got) != 2 {
+		t.Errorf("expected 2 invalid kinds, got %v", got)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetCoupons returns all coupon codes with their use counts.
+// GET /api/v1/access/coupons
+func (h *Handler) GetCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.db.GetCoupons(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get coupons", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"coupons": coupons,
+	})
+}
+
+// CreateCoupon adds a coupon code. A random code is generated if none is
+// given.
+// POST /api/v1/access/coupons
+func (h *Handler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	coupon := db.Coupon{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	coupon.Code = services.NormalizeCouponCode(coupon.Code)
+	if coupon.Code == "" {
+		code, err := services.GenerateCouponCode()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to generate code", "INTERNAL_ERROR")
+			return
+		}
+		coupon.Code = code
+	}
+	if !h.validCoupon(w, r, &coupon) {
+		return
+	}
+
+	created, err := h.db.CreateCoupon(ctx, &coupon)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create coupon", "DB_ERROR")
+		return
+	}
+	if !created {
+		respondError(w, http.StatusConflict, "A coupon with this code already exists", "COUPON_EXISTS")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "coupon_created", map[string]interface{}{
+		"code":  coupon.Code,
+		"kind":  coupon.Kind,
+		"value": coupon.Value,
+	}, "")
+
+	saved, _ := h.db.GetCoupon(ctx, coupon.Code)
+	respondJSON(w, http.StatusCreated, saved)
+}
+
+// UpdateCoupon changes a coupon's terms. Fields not given keep their values.
+// PATCH /api/v1/access/coupons/{code}
+func (h *Handler) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := services.NormalizeCouponCode(r.PathValue("code"))
+
+	coupon, err := h.db.GetCoupon(ctx, code)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get coupon", "DB_ERROR")
+		return
+	}
+	if coupon == nil {
+		respondError(w, http.StatusNotFound, "Coupon not found", "NOT_FOUND")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(coupon); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	coupon.Code = code
+	if !h.validCoupon(w, r, coupon) {
+		return
+	}
+
+	if _, err := h.db.UpdateCoupon(ctx, coupon); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update coupon", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "coupon_updated", map[string]interface{}{
+		"code":    coupon.Code,
+		"enabled": coupon.Enabled,
+	}, "")
+
+	saved, _ := h.db.GetCoupon(ctx, code)
+	respondJSON(w, http.StatusOK, saved)
+}
+
+// DeleteCoupon removes a coupon code. Past redemptions are kept.
+// DELETE /api/v1/access/coupons/{code}
+func (h *Handler) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := services.NormalizeCouponCode(r.PathValue("code"))
+
+	deleted, err := h.db.DeleteCoupon(ctx, code)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete coupon", "DB_ERROR")
+		return
+	}
+	if !deleted {
+		respondError(w, http.StatusNotFound, "Coupon not found", "NOT_FOUND")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "coupon_deleted", map[string]string{"code": code}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Coupon deleted",
+	})
+}
+
+// GetCouponRedemptions returns who redeemed a coupon, and who holds a use
+// with an open invoice.
+// GET /api/v1/access/coupons/{code}/redemptions
+func (h *Handler) GetCouponRedemptions(w http.ResponseWriter, r *http.Request) {
+	code := services.NormalizeCouponCode(r.PathValue("code"))
+
+	redemptions, err := h.db.GetCouponRedemptions(r.Context(), code)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get redemptions", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"code":        code,
+		"redemptions": redemptions,
+	})
+}
+
+// validCoupon checks a coupon's terms and that its tier exists, responding
+// with the problem if not.
+func (h *Handler) validCoupon(w http.ResponseWriter, r *http.Request, coupon *db.Coupon) bool {
+	if err := services.ValidateCoupon(coupon); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_COUPON")
+		return false
+	}
+	if coupon.TierID == "" {
+		return true
+	}
+
+	tiers, err := h.db.GetPricingTiers(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get pricing tiers", "DB_ERROR")
+		return false
+	}
+	for _, t := range tiers {
+		if t.ID == coupon.TierID {
+			return true
+		}
+	}
+	respondError(w, http.StatusBadRequest, "Unknown pricing tier: "+coupon.TierID, "INVALID_COUPON")
+	return false
+}
+
+// respondCouponError maps coupon errors to responses.
+func respondCouponError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrCouponNotFound):
+		respondError(w, http.StatusNotFound, err.Error(), "COUPON_NOT_FOUND")
+	case errors.Is(err, services.ErrCouponExpired):
+		respondError(w, http.StatusGone, err.Error(), "COUPON_EXPIRED")
+	case errors.Is(err, services.ErrCouponUsedUp):
+		respondError(w, http.StatusConflict, err.Error(), "COUPON_USED_UP")
+	case errors.Is(err, services.ErrCouponAlreadyRedeemed):
+		respondError(w, http.StatusConflict, err.Error(), "COUPON_ALREADY_REDEEMED")
+	case errors.Is(err, services.ErrCouponWrongTier):
+		respondError(w, http.StatusBadRequest, err.Error(), "COUPON_WRONG_TIER")
+	case errors.Is(err, services.ErrTierNotFound):
+		respondError(w, http.StatusNotFound, "Pricing tier not found", "TIER_NOT_FOUND")
+	case errors.Is(err, services.ErrTierDisabled):
+		respondError(w, http.StatusBadRequest, "Pricing tier is disabled", "TIER_DISABLED")
+	default:
+		slog.Error("Failed to apply coupon", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to apply coupon", "COUPON_FAILED")
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csrfHeader is the request header carrying the CSRF token.
+const csrfHeader = "X-CSRF-Token"
+
+// csrfTokenTTL is how long an issued CSRF token is accepted.
+const csrfTokenTTL = 12 * time.Hour
+
+// issueCSRFToken creates a token of the form <expiry>.<nonce>.<mac>, signed
+// with secret so that it can be checked without storing it.
+func issueCSRFToken(secret []byte, now time.Time) (string, time.Time, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := now.Add(csrfTokenTTL).Truncate(time.Second)
+	payload := strconv.FormatInt(expiresAt.Unix(), 10) + "." + hex.EncodeToString(nonce)
+	return payload + "." + signCSRF(secret, payload), expiresAt, nil
+}
+
+// validCSRFToken reports whether token was issued with secret and has not
+// expired.
+func validCSRFToken(secret []byte, token string, now time.Time) bool {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return false
+	}
+	payload, mac := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(signCSRF(secret, payload))) {
+		return false
+	}
+
+	expiry, _, ok := strings.Cut(payload, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	return now.Before(time.Unix(unix, 0))
+}
+
+func signCSRF(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CSRF requires a valid X-CSRF-Token header on state-changing /api/* requests
+// made by a browser, so that another site cannot drive the admin API through
+// the operator's browser. Requests without browser headers (scripts, curl)
+// cannot be forged this way and are let through; /public/* is not covered.
+func CSRF(secret func(context.Context) ([]byte, error)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !requiresCSRF(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := secret(r.Context())
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to load CSRF secret", "error", err)
+				respondError(w, http.StatusInternalServerError, "Failed to check CSRF token", "CSRF_FAILED")
+				return
+			}
+			if !validCSRFToken(key, r.Header.Get(csrfHeader), time.Now()) {
+				respondError(w, http.StatusForbidden, "Missing or invalid CSRF token", "CSRF_INVALID")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requiresCSRF reports whether a request must carry a CSRF token: a
+// state-changing /api/* request with headers only a browser sends.
+func requiresCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+	for _, header := range []string{"Origin", "Referer", "Sec-Fetch-Site", "Cookie"} {
+		if r.Header.Get(header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCSRFToken issues a CSRF token for the web UI to send in the
+// X-CSRF-Token header of state-changing requests.
+// GET /api/v1/csrf
+func (h *Handler) GetCSRFToken(w http.ResponseWriter, r *http.Request) {
+	secret, err := h.db.GetCSRFSecret(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to issue CSRF token", "DB_ERROR")
+		return
+	}
+
+	token, expiresAt, err := issueCSRFToken(secret, time.Now())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to issue CSRF token", "CSRF_FAILED")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
+
+// GetCORSSettings returns the origins allowed to call the admin API
+// cross-origin.
+// GET /api/v1/settings/cors
+func (h *Handler) GetCORSSettings(w http.ResponseWriter, r *http.Request) {
+	origins, err := h.db.GetAdminAllowedOrigins(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get allowed origins", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"allowed_origins": origins,
+	})
+}
+
+// UpdateCORSSettings replaces the origins allowed to call the admin API
+// cross-origin. An empty list keeps the API same-origin only.
+// PUT /api/v1/settings/cors
+func (h *Handler) UpdateCORSSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		AllowedOrigins []string `json:"allowed_origins"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	origins := make([]string, 0, len(req.AllowedOrigins))
+	seen := make(map[string]bool)
+	for _, o := range req.AllowedOrigins {
+		normalized, ok := normalizeOrigin(o)
+		if !ok || normalized == "*" {
+			respondErrorWithDetails(w, http.StatusBadRequest, "Invalid origin", "INVALID_ORIGIN", o)
+			return
+		}
+		if !seen[normalized] {
+			seen[normalized] = true
+			origins = append(origins, normalized)
+		}
+	}
+
+	if err := h.db.SetAdminAllowedOrigins(ctx, origins); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save allowed origins", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "cors_updated", map[string]interface{}{
+		"allowed_origins": origins,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":         true,
+		"allowed_origins": origins,
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCSRFToken(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Unix(1700000000, 0)
+
+	token, expiresAt, err := issueCSRFToken(secret, now)
+	if err != nil {
+		t.Fatalf("issueCSRFToken() error = %v", err)
+	}
+	if !expiresAt.Equal(now.Add(csrfTokenTTL)) {
+		t.Errorf("expiresAt = %v, want %v", expiresAt, now.Add(csrfTokenTTL))
+	}
+
+	tests := []struct {
+		name   string
+		secret []byte
+		token  string
+		now    time.Time
+		want   bool
+	}{
+		{"valid", secret, token, now, true},
+		{"expired", secret, token, expiresAt, false},
+		{"other_secret", []byte("another secret"), token, now, false},
+		{"tampered", secret, "9" + token, now, false},
+		{"empty", secret, "", now, false},
+		{"no_mac", secret, "1700000000", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validCSRFToken(tt.secret, tt.token, tt.now); got != tt.want {
+				t.Errorf("validCSRFToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	token, _, err := issueCSRFToken(secret, time.Now())
+	if err != nil {
+		t.Fatalf("issueCSRFToken() error = %v", err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := CSRF(func(context.Context) ([]byte, error) {
+		return secret, nil
+	})(next)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"browser_post_without_token", "POST", "/api/v1/access/whitelist", map[string]string{"Origin": "http://localhost:3001"}, http.StatusForbidden},
+		{"browser_post_with_token", "POST", "/api/v1/access/whitelist", map[string]string{"Origin": "http://localhost:3001", csrfHeader: token}, http.StatusOK},
+		{"browser_delete_bad_token", "DELETE", "/api/v1/events/abc", map[string]string{"Sec-Fetch-Site": "same-origin", csrfHeader: "bogus"}, http.StatusForbidden},
+		{"browser_get", "GET", "/api/v1/stats/summary", map[string]string{"Origin": "http://localhost:3001"}, http.StatusOK},
+		{"script_post", "POST", "/api/v1/access/whitelist", nil, http.StatusOK},
+		{"public_post", "POST", "/public/create-invoice", map[string]string{"Origin": "https://example.com"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// DashboardResponse is everything the dashboard shows, gathered in one call.
+type DashboardResponse struct {
+	Relay            DashboardRelay      `json:"relay"`
+	Events           DashboardEvents     `json:"events"`
+	Storage          DashboardStorage    `json:"storage"`
+	Members          db.MemberCounts     `json:"members"`
+	PendingDeletions int64               `json:"pending_deletions"`
+	PaidUsers        DashboardPaidUsers  `json:"paid_users"`
+	Revenue          DashboardRevenue    `json:"revenue"`
+	LastSync         *DashboardSync      `json:"last_sync"`
+	LastBackup       *db.BackupRunRecord `json:"last_backup"`
+	LastNostrBackup  *time.Time          `json:"last_nostr_backup"`
+	Errors           map[string]string   `json:"errors,omitempty"`
+}
+
+// DashboardRelay is the relay's status.
+type DashboardRelay struct {
+	Status            string `json:"status"` // running, stopped, restarting or unknown
+	DatabaseConnected bool   `json:"database_connected"`
+	APIUptimeSeconds  int64  `json:"api_uptime_seconds"`
+}
+
+// DashboardEvents are the relay's event counts.
+type DashboardEvents struct {
+	Total      int64     `json:"total"`
+	Today      int64     `json:"today"`
+	ComputedAt time.Time `json:"computed_at"` // When the total was counted
+}
+
+// DashboardStorage is the disk usage and its status tier.
+type DashboardStorage struct {
+	DatabaseSize    int64   `json:"database_size"`
+	AppDatabaseSize int64   `json:"app_database_size"`
+	AvailableSpace  int64   `json:"available_space"`
+	TotalSpace      int64   `json:"total_space"`
+	UsagePercent    float64 `json:"usage_percent"`
+	Status          string  `json:"status"` // healthy, warning, low or critical
+	IngestPaused    bool    `json:"ingest_paused"`
+}
+
+// DashboardPaidUsers counts paid subscriptions.
+type DashboardPaidUsers struct {
+	Active       int64 `json:"active"`
+	ExpiringSoon int64 `json:"expiring_soon"` // Within 7 days
+}
+
+// DashboardRevenue is the revenue since the start of the month.
+type DashboardRevenue struct {
+	Since     time.Time `json:"since"`
+	MonthSats int64     `json:"month_sats"`
+	Payments  int64     `json:"payments"`
+}
+
+// DashboardSync is the most recent sync.
+type DashboardSync struct {
+	ID          int64      `json:"id"`
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// GetDashboard returns the dashboard's metrics in a single call: relay
+// status, event counts, storage, members by access category, pending
+// deletions, paid users, this month's revenue and the last sync and backup.
+// A metric that cannot be read is left at its zero value and named in
+// errors, so one failing source does not blank the dashboard. Days and
+// months start in the ?timezone= given, UTC by default.
+// GET /api/v1/dashboard
+func (h *Handler) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	loc := time.UTC
+	if timezone := r.URL.Query().Get("timezone"); timezone != "" && timezone != "UTC" {
+		if parsed, err := time.LoadLocation(timezone); err == nil {
+			loc = parsed
+		}
+	}
+	now := time.Now().In(loc)
+
+	resp := DashboardResponse{Errors: map[string]string{}}
+	fail := func(metric string, err error) bool {
+		if err != nil {
+			resp.Errors[metric] = err.Error()
+		}
+		return err != nil
+	}
+
+	// Relay
+	connected := h.db.IsRelayDBConnected()
+	resp.Relay = DashboardRelay{
+		Status:            h.relayState(),
+		DatabaseConnected: connected,
+		APIUptimeSeconds:  int64(time.Since(h.startTime).Seconds()),
+	}
+
+	// Events
+	if connected {
+		if stats, computedAt, err := h.relayStats(ctx, false); !fail("total_events", err) {
+			resp.Events.Total = stats.TotalEvents
+			resp.Events.ComputedAt = computedAt
+		}
+		today, err := h.db.GetEventsToday(ctx, loc)
+		if !fail("events_today", err) {
+			resp.Events.Today = today
+		}
+	}
+
+	// Storage
+	resp.Storage.DatabaseSize, _ = h.db.GetRelayDatabaseSize()
+	resp.Storage.AppDatabaseSize, _ = h.db.GetAppDatabaseSize()
+	resp.Storage.AvailableSpace, _ = h.db.GetAvailableDiskSpace()
+	resp.Storage.TotalSpace, _ = h.db.GetTotalDiskSpace()
+	if resp.Storage.TotalSpace > 0 {
+		used := resp.Storage.TotalSpace - resp.Storage.AvailableSpace
+		resp.Storage.UsagePercent = float64(used) / float64(resp.Storage.TotalSpace) * 100
+	}
+	resp.Storage.Status = services.StorageLevel(resp.Storage.UsagePercent)
+	resp.Storage.IngestPaused = h.services != nil && h.services.Pressure.IngestPaused()
+
+	// Members and access
+	if members, err := h.db.CountMembers(ctx); !fail("members", err) {
+		resp.Members = *members
+	}
+	var err error
+	resp.PendingDeletions, err = h.db.GetPendingDeletionCount(ctx)
+	fail("pending_deletions", err)
+	resp.PaidUsers.Active, err = h.db.CountActivePaidUsers(ctx)
+	fail("paid_users", err)
+	resp.PaidUsers.ExpiringSoon, err = h.db.CountExpiringPaidUsers(ctx, 7)
+	fail("paid_users", err)
+
+	// Revenue
+	resp.Revenue.Since = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	resp.Revenue.MonthSats, resp.Revenue.Payments, err = h.db.GetRevenueInRange(ctx, resp.Revenue.Since, now.Add(time.Second))
+	fail("revenue", err)
+
+	// Last sync and backups
+	if jobs, err := h.db.GetSyncJobs(ctx, "", 1, 0); !fail("last_sync", err) && len(jobs) > 0 {
+		resp.LastSync = &DashboardSync{
+			ID:          jobs[0].ID,
+			Status:      jobs[0].Status,
+			StartedAt:   jobs[0].StartedAt,
+			CompletedAt: jobs[0].CompletedAt,
+		}
+	}
+	if backup, err := h.db.GetLastScheduledBackup(ctx); !fail("last_backup", err) {
+		resp.LastBackup = backup
+	}
+	if backup, err := h.db.GetLastNostrBackup(ctx); !fail("last_nostr_backup", err) && backup != nil {
+		resp.LastNostrBackup = &backup.At
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
 // GetEvents returns a paginated list of events.
@@ -22,10 +24,19 @@ func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
 
 	filter := db.EventFilter{
 		Limit:  parseIntParam(query.Get("limit"), 50),
-		Offset: parseIntParam(query.Get("offset"), 0),
 		Search: query.Get("search"),
 	}
 
+	// Pages after the first continue from the previous page's next_cursor
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := db.ParseEventCursor(cursor)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid cursor", "INVALID_CURSOR")
+			return
+		}
+		filter.After = after
+	}
+
 	// Parse kinds
 	if kinds := query.Get("kinds"); kinds != "" {
 		for _, k := range strings.Split(kinds, ",") {
@@ -57,20 +68,118 @@ func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
 		filter.Mentions = mentions
 	}
 
-	events, err := h.db.GetEvents(r.Context(), filter)
+	// Parse tag filters: #e, #p, #t and other single-letter tags (URL-encoded
+	// as %23e), each a comma-separated list of values
+	for key, values := range query {
+		if len(key) == 0 || key[0] != '#' {
+			continue
+		}
+		if len(key) != 2 || !isTagLetter(key[1]) {
+			respondError(w, http.StatusBadRequest, "Tag filters are # and a single letter, like #e or #t", "INVALID_TAG_FILTER")
+			return
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(map[string][]string)
+		}
+		for _, value := range values {
+			filter.Tags[key[1:]] = append(filter.Tags[key[1:]], strings.Split(value, ",")...)
+		}
+	}
+
+	events, next, err := h.db.GetEventsPage(r.Context(), filter)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to get events", "EVENTS_FETCH_FAILED")
 		return
 	}
+	if h.redactDMs(r.Context()) {
+		db.RedactDMs(events)
+	}
+
+	var nextCursor *string
+	if next != nil {
+		cursor := next.String()
+		nextCursor = &cursor
+	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"events": events,
-		"count":  len(events),
-		"limit":  filter.Limit,
-		"offset": filter.Offset,
+		"events":      labelEvents(events),
+		"count":       len(events),
+		"limit":       filter.Limit,
+		"next_cursor": nextCursor,
 	})
 }
 
+// SearchEvents runs a full-text search over event content, most relevant
+// first. q holds words and "quoted phrases"; authors, kinds, since and until
+// scope the search as in GetEvents.
+// GET /api/v1/events/search
+func (h *Handler) SearchEvents(w http.ResponseWriter, r *http.Request) {
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	query := r.URL.Query()
+	q := db.FullTextQuery{
+		Query:  strings.TrimSpace(query.Get("q")),
+		Limit:  parseIntParam(query.Get("limit"), 50),
+		Offset: parseIntParam(query.Get("offset"), 0),
+	}
+	if q.Query == "" {
+		respondError(w, http.StatusBadRequest, "Search query is required", "MISSING_QUERY")
+		return
+	}
+	if kinds := query.Get("kinds"); kinds != "" {
+		for _, k := range strings.Split(kinds, ",") {
+			if kind, err := strconv.Atoi(strings.TrimSpace(k)); err == nil {
+				q.Kinds = append(q.Kinds, kind)
+			}
+		}
+	}
+	if authors := query.Get("authors"); authors != "" {
+		q.Authors = strings.Split(authors, ",")
+	}
+	if since := query.Get("since"); since != "" {
+		if ts, err := strconv.ParseInt(since, 10, 64); err == nil {
+			q.Since = time.Unix(ts, 0)
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if ts, err := strconv.ParseInt(until, 10, 64); err == nil {
+			q.Until = time.Unix(ts, 0)
+		}
+	}
+
+	results, err := h.db.SearchEventsFullText(r.Context(), q)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrInvalidSearchQuery):
+			respondError(w, http.StatusBadRequest, "Search query has no words to search for", "INVALID_QUERY")
+		case errors.Is(err, db.ErrUnsupportedOnPostgres):
+			respondError(w, http.StatusNotImplemented, "Full-text search needs a SQLite relay database", "SEARCH_UNSUPPORTED")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to search events", "SEARCH_FAILED")
+		}
+		return
+	}
+	if h.redactDMs(r.Context()) {
+		for i := range results {
+			results[i].RedactDM()
+		}
+	}
+
+	response := map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+		"limit":   q.Limit,
+		"offset":  q.Offset,
+	}
+	if status, err := h.db.GetSearchIndexStatus(r.Context()); err == nil {
+		response["index"] = status
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
 // GetEvent returns a single event by ID.
 func (h *Handler) GetEvent(w http.ResponseWriter, r *http.Request) {
 	if !h.db.IsRelayDBConnected() {
@@ -94,10 +203,75 @@ func (h *Handler) GetEvent(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusNotFound, "Event not found", "EVENT_NOT_FOUND")
 		return
 	}
+	if h.redactDMs(r.Context()) {
+		event.RedactDM()
+	}
 
 	respondJSON(w, http.StatusOK, event)
 }
 
+// VerifyEvent recomputes a stored event's ID and checks its signature,
+// reporting whether the event as stored is valid.
+// POST /api/v1/events/{id}/verify
+func (h *Handler) VerifyEvent(w http.ResponseWriter, r *http.Request) {
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		respondError(w, http.StatusBadRequest, "Event ID is required", "MISSING_ID")
+		return
+	}
+
+	stored, err := h.db.GetStoredEvent(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get event", "EVENT_FETCH_FAILED")
+		return
+	}
+	if stored == nil {
+		respondError(w, http.StatusNotFound, "Event not found", "EVENT_NOT_FOUND")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, services.VerifyStoredEvent(*stored))
+}
+
+// GetEventThread returns an event with the notes it replies to and the
+// replies to it, for reviewing a note in context.
+// GET /api/v1/events/{id}/thread
+func (h *Handler) GetEventThread(w http.ResponseWriter, r *http.Request) {
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		respondError(w, http.StatusBadRequest, "Event ID is required", "MISSING_ID")
+		return
+	}
+
+	thread, err := h.db.GetEventThread(r.Context(), id, parseIntParam(r.URL.Query().Get("limit"), 100))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get event thread", "EVENT_FETCH_FAILED")
+		return
+	}
+
+	if thread == nil {
+		respondError(w, http.StatusNotFound, "Event not found", "EVENT_NOT_FOUND")
+		return
+	}
+	if h.redactDMs(r.Context()) {
+		thread.Event.RedactDM()
+		db.RedactDMs(thread.Parents)
+		db.RedactDMs(thread.Replies)
+	}
+
+	respondJSON(w, http.StatusOK, thread)
+}
+
 // DeleteEvent queues an event for deletion.
 func (h *Handler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -163,9 +337,12 @@ func (h *Handler) GetRecentEvents(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusInternalServerError, "Failed to get recent events", "EVENTS_FETCH_FAILED")
 		return
 	}
+	if h.redactDMs(r.Context()) {
+		db.RedactDMs(events)
+	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"events": events,
+		"events": labelEvents(events),
 	})
 }
 
@@ -179,3 +356,8 @@ func parseIntParam(s string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+// isTagLetter reports whether c names a single-letter tag relays index.
+func isTagLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
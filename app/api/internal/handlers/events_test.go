@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -70,10 +71,15 @@ func (m *mockEventsDB) GetEvents(ctx context.Context, filter db.EventFilter) ([]
 		result = append(result, e)
 	}
 
-	// Apply limit/offset
-	start := filter.Offset
-	if start > len(result) {
-		return []db.Event{}, nil
+	// Apply cursor/limit
+	start := 0
+	if filter.After != nil {
+		for i, e := range result {
+			if e.ID == filter.After.ID {
+				start = i + 1
+				break
+			}
+		}
 	}
 	end := start + filter.Limit
 	if end > len(result) {
@@ -163,8 +169,7 @@ func TestGetEvents(t *testing.T) {
 			}
 
 			filter := db.EventFilter{
-				Limit:  parseIntParam(r.URL.Query().Get("limit"), 50),
-				Offset: parseIntParam(r.URL.Query().Get("offset"), 0),
+				Limit: parseIntParam(r.URL.Query().Get("limit"), 50),
 			}
 
 			events, _ := mockDB.GetEvents(r.Context(), filter)
@@ -173,7 +178,6 @@ func TestGetEvents(t *testing.T) {
 				"events": events,
 				"count":  len(events),
 				"limit":  filter.Limit,
-				"offset": filter.Offset,
 			})
 		})
 
@@ -208,8 +212,7 @@ func TestGetEvents(t *testing.T) {
 		mux := http.NewServeMux()
 		mux.HandleFunc("GET /api/v1/events", func(w http.ResponseWriter, r *http.Request) {
 			filter := db.EventFilter{
-				Limit:  50,
-				Offset: 0,
+				Limit: 50,
 			}
 
 			if kinds := r.URL.Query().Get("kinds"); kinds != "" {
@@ -252,8 +255,7 @@ func TestGetEvents(t *testing.T) {
 		mux := http.NewServeMux()
 		mux.HandleFunc("GET /api/v1/events", func(w http.ResponseWriter, r *http.Request) {
 			filter := db.EventFilter{
-				Limit:  50,
-				Offset: 0,
+				Limit: 50,
 			}
 
 			if authors := r.URL.Query().Get("authors"); authors != "" {
@@ -293,7 +295,6 @@ func TestGetEvents(t *testing.T) {
 		mux.HandleFunc("GET /api/v1/events", func(w http.ResponseWriter, r *http.Request) {
 			filter := db.EventFilter{
 				Limit:  50,
-				Offset: 0,
 				Search: r.URL.Query().Get("search"),
 			}
 
@@ -318,11 +319,11 @@ func TestGetEvents(t *testing.T) {
 		}
 	})
 
-	t.Run("pagination_with_limit_offset", func(t *testing.T) {
+	t.Run("pagination_with_cursor", func(t *testing.T) {
 		mockDB := newMockEventsDB()
 		for i := 0; i < 10; i++ {
 			mockDB.events = append(mockDB.events, db.Event{
-				ID:   "event" + string(rune('a'+i)),
+				ID:   fmt.Sprintf("%064x", i),
 				Kind: 1,
 			})
 		}
@@ -330,21 +331,33 @@ func TestGetEvents(t *testing.T) {
 		mux := http.NewServeMux()
 		mux.HandleFunc("GET /api/v1/events", func(w http.ResponseWriter, r *http.Request) {
 			filter := db.EventFilter{
-				Limit:  parseIntParam(r.URL.Query().Get("limit"), 50),
-				Offset: parseIntParam(r.URL.Query().Get("offset"), 0),
+				Limit: parseIntParam(r.URL.Query().Get("limit"), 50),
+			}
+			if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+				after, err := db.ParseEventCursor(cursor)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				filter.After = after
 			}
 
 			events, _ := mockDB.GetEvents(r.Context(), filter)
+			var nextCursor *string
+			if len(events) == filter.Limit {
+				cursor := db.CursorAfter(events[len(events)-1]).String()
+				nextCursor = &cursor
+			}
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"events": events,
-				"count":  len(events),
-				"limit":  filter.Limit,
-				"offset": filter.Offset,
+				"events":      events,
+				"count":       len(events),
+				"limit":       filter.Limit,
+				"next_cursor": nextCursor,
 			})
 		})
 
-		req := httptest.NewRequest("GET", "/api/v1/events?limit=3&offset=2", nil)
+		req := httptest.NewRequest("GET", "/api/v1/events?limit=3", nil)
 		w := httptest.NewRecorder()
 		mux.ServeHTTP(w, req)
 
@@ -355,8 +368,26 @@ func TestGetEvents(t *testing.T) {
 		if count != 3 {
 			t.Errorf("expected 3 events with limit=3, got %d", count)
 		}
-		if resp["offset"].(float64) != 2 {
-			t.Errorf("expected offset 2, got %v", resp["offset"])
+		next, ok := resp["next_cursor"].(string)
+		if !ok || next == "" {
+			t.Fatalf("expected next_cursor, got %v", resp["next_cursor"])
+		}
+
+		req = httptest.NewRequest("GET", "/api/v1/events?limit=3&cursor="+next, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		json.NewDecoder(w.Body).Decode(&resp)
+		events := resp["events"].([]interface{})
+		if first := events[0].(map[string]interface{})["id"]; first != fmt.Sprintf("%064x", 3) {
+			t.Errorf("expected the second page to start at the fourth event, got %v", first)
+		}
+
+		req = httptest.NewRequest("GET", "/api/v1/events?cursor=bogus", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for an invalid cursor, got %d", w.Code)
 		}
 	})
 }
@@ -1,19 +1,80 @@
 package handlers
 
 import (
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
+// exportFormat is how an export is written, as chosen with ?format=.
+type exportFormat struct {
+	name        string
+	array       bool   // a JSON array rather than one event per line
+	verifiable  bool   // leave out events whose signatures no longer verify
+	gzip        bool   // gzip-compressed
+	extension   string // file extension, before .gz
+	contentType string // content type when not compressed
+}
+
+// exportFormats are the export formats by name. Each can be compressed by
+// adding .gz to the name.
+var exportFormats = map[string]exportFormat{
+	// One event per line, for Roostr's own import and most tools
+	"ndjson": {extension: "ndjson", contentType: "application/x-ndjson"},
+	"jsonl":  {extension: "jsonl", contentType: "application/x-ndjson"},
+	// A JSON array of events
+	"json": {array: true, extension: "json", contentType: "application/json"},
+	// A JSON array that nostr-tools' verifyEvent accepts event by event
+	"nostr-tools": {array: true, verifiable: true, extension: "json", contentType: "application/json"},
+	// The JSONL `strfry import` reads; it rejects events that do not verify
+	"strfry": {verifiable: true, extension: "jsonl", contentType: "application/x-ndjson"},
+}
+
+// parseExportFormat returns the export format for a ?format= value: a name
+// from exportFormats, optionally ending in .gz, or "gzip" for gzipped
+// NDJSON. The default is NDJSON.
+func parseExportFormat(value string) (exportFormat, bool) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	switch name {
+	case "":
+		name = "ndjson"
+	case "gzip":
+		name = "ndjson.gz"
+	}
+	base, gzipped := strings.CutSuffix(name, ".gz")
+	format, ok := exportFormats[base]
+	if !ok {
+		return exportFormat{}, false
+	}
+	format.name = name
+	format.gzip = gzipped
+	return format, true
+}
+
+// filename returns the export's file name for a date.
+func (f exportFormat) filename(date string) string {
+	filename := fmt.Sprintf("nostr-backup-%s.%s", date, f.extension)
+	if f.gzip {
+		filename += ".gz"
+	}
+	return filename
+}
+
 // ExportEvents handles GET /api/v1/events/export
-// Streams events as NDJSON or JSON for backup/migration.
+// Streams events as NDJSON, a JSON array, strfry's import format or a
+// nostr-tools-compatible array, optionally gzipped, for backup and
+// migration. The export is recorded as a job whose ID is sent in the
+// X-Job-ID header.
 func (h *Handler) ExportEvents(w http.ResponseWriter, r *http.Request) {
 	if !h.db.IsRelayDBConnected() {
 		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
@@ -23,13 +84,9 @@ func (h *Handler) ExportEvents(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	query := r.URL.Query()
 
-	// Format: ndjson (default) or json
-	format := query.Get("format")
-	if format == "" {
-		format = "ndjson"
-	}
-	if format != "ndjson" && format != "json" {
-		respondError(w, http.StatusBadRequest, "Invalid format. Must be 'ndjson' or 'json'", "INVALID_FORMAT")
+	format, ok := parseExportFormat(query.Get("format"))
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid format. Must be 'ndjson', 'jsonl', 'json', 'nostr-tools' or 'strfry', optionally ending in '.gz', or 'gzip'", "INVALID_FORMAT")
 		return
 	}
 
@@ -60,7 +117,7 @@ func (h *Handler) ExportEvents(w http.ResponseWriter, r *http.Request) {
 	// Count total events for progress tracking
 	count, err := h.db.CountEvents(r.Context(), filter)
 	if err != nil {
-		log.Printf("Warning: failed to count events for export: %v", err)
+		slog.WarnContext(r.Context(), "Failed to count events for export", "error", err)
 		// Continue without count header
 	}
 
@@ -73,21 +130,13 @@ func (h *Handler) ExportEvents(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Generate filename with current date in user's timezone
-	filename := fmt.Sprintf("nostr-backup-%s", time.Now().In(loc).Format("2006-01-02"))
-	if format == "ndjson" {
-		filename += ".ndjson"
-	} else {
-		filename += ".json"
-	}
-
-	// Set response headers
-	if format == "ndjson" {
-		w.Header().Set("Content-Type", "application/x-ndjson")
+	// Set response headers, with the file name dated in the user's timezone
+	if format.gzip {
+		w.Header().Set("Content-Type", "application/gzip")
 	} else {
-		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Type", format.contentType)
 	}
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, format.filename(time.Now().In(loc).Format("2006-01-02"))))
 	if count > 0 {
 		w.Header().Set("X-Total-Count", strconv.FormatInt(count, 10))
 	}
@@ -99,50 +148,125 @@ func (h *Handler) ExportEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Write response based on format
-	if format == "ndjson" {
-		h.streamNDJSON(w, r, filter, flusher)
-	} else {
-		h.streamJSON(w, r, filter, flusher)
+	// Track the export as a job that lasts as long as the download
+	ctx := r.Context()
+	var run *services.JobRun
+	if h.services != nil && h.services.Jobs != nil {
+		run, err = h.services.Jobs.Begin(ctx, services.JobTypeExport, map[string]interface{}{
+			"format": format.name,
+			"kinds":  filter.Kinds,
+			"since":  query.Get("since"),
+			"until":  query.Get("until"),
+		})
+		if !jobStarted(w, err) {
+			return
+		}
+		ctx = run.Context()
+		w.Header().Set("X-Job-ID", strconv.FormatInt(run.ID(), 10))
+	}
+	progress := func(exported int) {
+		if run != nil {
+			run.Progress(int64(exported), count, fmt.Sprintf("%d events exported", exported))
+		}
+	}
+
+	// Compressed output is flushed through the gzip stream
+	var out io.Writer = w
+	flush := flusher.Flush
+	if format.gzip {
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		out = zw
+		flush = func() {
+			zw.Flush()
+			flusher.Flush()
+		}
+	}
+
+	redact := h.redactDMs(ctx)
+	exported, omitted, err := h.streamExport(ctx, out, format, filter, redact, flush, progress)
+	if err != nil {
+		// Can't send error response after headers are written
+		// Log it and stop
+		slog.ErrorContext(ctx, "Export stream failed", "error", err)
+	}
+
+	if run != nil {
+		result := map[string]interface{}{
+			"format":   format.name,
+			"exported": exported,
+		}
+		if omitted > 0 {
+			result["omitted"] = omitted
+		}
+		run.Finish(result, err)
 	}
 }
 
-// streamNDJSON writes events as newline-delimited JSON.
-func (h *Handler) streamNDJSON(w http.ResponseWriter, r *http.Request, filter db.EventFilter, flusher http.Flusher) {
-	eventCount := 0
+// streamExport writes events in the export format and returns how many were
+// written, and how many were left out. With redact, encrypted DMs are
+// written without content, or left out by formats whose events must verify.
+func (h *Handler) streamExport(ctx context.Context, w io.Writer, format exportFormat, filter db.EventFilter, redact bool, flush func(), progress func(int)) (int, int, error) {
+	if format.array {
+		if _, err := w.Write([]byte("[\n")); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	eventCount, omitted := 0, 0
+	err := h.db.StreamEvents(ctx, filter, func(event db.ExportEvent) error {
+		if redact && db.IsEncryptedDMKind(event.Kind) {
+			if format.verifiable {
+				omitted++
+				return nil
+			}
+			event.RedactDM()
+		}
+		if event.Tags == nil {
+			event.Tags = [][]string{}
+		}
 
-	err := h.db.StreamEvents(r.Context(), filter, func(event db.ExportEvent) error {
 		// Encode event to JSON
 		data, err := json.Marshal(event)
 		if err != nil {
 			return fmt.Errorf("failed to marshal event: %w", err)
 		}
 
-		// Write JSON line
+		// Events are separated by commas in an array and end lines otherwise
+		if format.array && eventCount > 0 {
+			if _, err := w.Write([]byte(",\n")); err != nil {
+				return err
+			}
+		}
 		if _, err := w.Write(data); err != nil {
 			return err
 		}
-		if _, err := w.Write([]byte("\n")); err != nil {
-			return err
+		if !format.array {
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return err
+			}
 		}
 
 		eventCount++
 		// Flush every 100 events for responsive streaming
 		if eventCount%100 == 0 {
-			flusher.Flush()
+			flush()
+			progress(eventCount)
 		}
 
 		return nil
 	})
 
-	if err != nil {
-		// Can't send error response after headers are written
-		// Log it and stop
-		log.Printf("Export stream error: %v", err)
+	if format.array {
+		if _, wErr := w.Write([]byte("\n]")); wErr != nil && err == nil {
+			err = wErr
+		}
 	}
 
 	// Final flush
-	flusher.Flush()
+	flush()
+	progress(eventCount)
+	return eventCount, omitted, err
 }
 
 // GetExportEstimate handles GET /api/v1/events/export/estimate
@@ -195,56 +319,3 @@ func (h *Handler) GetExportEstimate(w http.ResponseWriter, r *http.Request) {
 		"estimated_bytes": estimatedBytes,
 	})
 }
-
-// streamJSON writes events as a JSON array.
-func (h *Handler) streamJSON(w http.ResponseWriter, r *http.Request, filter db.EventFilter, flusher http.Flusher) {
-	// Write opening bracket
-	if _, err := w.Write([]byte("[\n")); err != nil {
-		log.Printf("Export stream error: %v", err)
-		return
-	}
-
-	first := true
-	eventCount := 0
-
-	err := h.db.StreamEvents(r.Context(), filter, func(event db.ExportEvent) error {
-		// Encode event to JSON
-		data, err := json.Marshal(event)
-		if err != nil {
-			return fmt.Errorf("failed to marshal event: %w", err)
-		}
-
-		// Write comma separator for all but first event
-		if !first {
-			if _, err := w.Write([]byte(",\n")); err != nil {
-				return err
-			}
-		}
-		first = false
-
-		// Write JSON event
-		if _, err := w.Write(data); err != nil {
-			return err
-		}
-
-		eventCount++
-		// Flush every 100 events
-		if eventCount%100 == 0 {
-			flusher.Flush()
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		log.Printf("Export stream error: %v", err)
-	}
-
-	// Write closing bracket
-	if _, err := w.Write([]byte("\n]")); err != nil {
-		log.Printf("Export stream error: %v", err)
-	}
-
-	// Final flush
-	flusher.Flush()
-}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/secrets"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// redactedSecret replaces stored credentials in responses. Sending it back on
+// update keeps the stored value.
+const redactedSecret = "********"
+
+// ExportScheduleRequest is the request body for creating or updating an export
+// schedule. Omitted fields are left unchanged on update.
+type ExportScheduleRequest struct {
+	Name            *string               `json:"name"`
+	DestinationType *string               `json:"destination_type"`
+	Destination     *db.ExportDestination `json:"destination"`
+	Kinds           *[]int                `json:"kinds"`
+	IntervalHours   *int                  `json:"interval_hours"`
+	RetentionCount  *int                  `json:"retention_count"`
+	Enabled         *bool                 `json:"enabled"`
+}
+
+// ExportScheduleResponse is an export schedule with credentials redacted and
+// its most recent artifact attached.
+type ExportScheduleResponse struct {
+	db.ExportSchedule
+	Running      bool               `json:"running"`
+	LastManifest *db.ExportManifest `json:"last_manifest,omitempty"`
+}
+
+// GetExportSchedules returns all export schedules with their status.
+// GET /api/v1/exports/schedules
+func (h *Handler) GetExportSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	schedules, err := h.db.GetExportSchedules(ctx)
+	if err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get export schedules", "DB_ERROR")
+		return
+	}
+
+	response := make([]ExportScheduleResponse, 0, len(schedules))
+	for _, s := range schedules {
+		item := h.exportScheduleResponse(s)
+		if manifests, err := h.db.GetExportManifests(ctx, s.ID, 1); err == nil && len(manifests) > 0 {
+			item.LastManifest = &manifests[0]
+		}
+		response = append(response, item)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"schedules": response,
+	})
+}
+
+// CreateExportSchedule adds a new export schedule. Its first run starts within a minute.
+// POST /api/v1/exports/schedules
+func (h *Handler) CreateExportSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req ExportScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if req.DestinationType == nil || req.Destination == nil {
+		respondError(w, http.StatusBadRequest, "destination_type and destination are required", "MISSING_DESTINATION")
+		return
+	}
+
+	s := &db.ExportSchedule{
+		DestinationType: *req.DestinationType,
+		Kinds:           []int{},
+		IntervalHours:   24,
+		RetentionCount:  7,
+		Enabled:         true,
+	}
+	applyExportScheduleRequest(s, req)
+	if s.Name == "" {
+		s.Name = "Daily export to " + s.DestinationType
+	}
+
+	if !validateExportSchedule(w, s) {
+		return
+	}
+
+	if err := h.db.CreateExportSchedule(ctx, s); err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create export schedule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "export_schedule_created", map[string]interface{}{
+		"id":               s.ID,
+		"name":             s.Name,
+		"destination_type": s.DestinationType,
+	}, "")
+
+	h.wakeExports()
+
+	created, _ := h.db.GetExportSchedule(ctx, s.ID)
+	if created != nil {
+		s = created
+	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"schedule": h.exportScheduleResponse(*s),
+	})
+}
+
+// GetExportSchedule returns a schedule and its recent manifests.
+// GET /api/v1/exports/schedules/{id}
+func (h *Handler) GetExportSchedule(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadExportSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	manifests, err := h.db.GetExportManifests(r.Context(), s.ID, 20)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get export manifests", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"schedule":  h.exportScheduleResponse(*s),
+		"manifests": manifests,
+	})
+}
+
+// UpdateExportSchedule changes a schedule's settings. A destination update that
+// omits secret_key or password (or sends the redacted value) keeps the stored one.
+// PATCH /api/v1/exports/schedules/{id}
+func (h *Handler) UpdateExportSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	s, ok := h.loadExportSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	var req ExportScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	previous := s.Destination
+	previousInterval := s.IntervalHours
+	applyExportScheduleRequest(s, req)
+	if req.Destination != nil {
+		keepDestinationSecrets(&s.Destination, previous)
+	}
+	if s.IntervalHours != previousInterval && s.LastRunAt != nil {
+		s.NextRunAt = s.LastRunAt.Add(time.Duration(s.IntervalHours) * time.Hour)
+	}
+
+	if !validateExportSchedule(w, s) {
+		return
+	}
+
+	if err := h.db.UpdateExportSchedule(ctx, s); err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to update export schedule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "export_schedule_updated", map[string]interface{}{
+		"id":               s.ID,
+		"name":             s.Name,
+		"destination_type": s.DestinationType,
+		"enabled":          s.Enabled,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"schedule": h.exportScheduleResponse(*s),
+	})
+}
+
+// DeleteExportSchedule removes a schedule and its manifests. Artifacts already
+// shipped are left at the destination.
+// DELETE /api/v1/exports/schedules/{id}
+func (h *Handler) DeleteExportSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	s, ok := h.loadExportSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteExportSchedule(ctx, s.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete export schedule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "export_schedule_deleted", map[string]interface{}{
+		"id":   s.ID,
+		"name": s.Name,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Export schedule deleted",
+	})
+}
+
+// RunExportSchedule makes a schedule due now and wakes the export worker.
+// POST /api/v1/exports/schedules/{id}/run
+func (h *Handler) RunExportSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	s, ok := h.loadExportSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.Enabled {
+		respondError(w, http.StatusConflict, "Export schedule is disabled", "SCHEDULE_DISABLED")
+		return
+	}
+
+	s.NextRunAt = time.Now()
+	if err := h.db.UpdateExportSchedule(ctx, s); err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to queue export", "DB_ERROR")
+		return
+	}
+
+	h.wakeExports()
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"success": true,
+		"message": "Export queued",
+	})
+}
+
+// GetExportManifests returns the artifacts produced by a schedule, newest first.
+// GET /api/v1/exports/schedules/{id}/manifests
+func (h *Handler) GetExportManifests(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadExportSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+			if limit > 500 {
+				limit = 500
+			}
+		}
+	}
+
+	manifests, err := h.db.GetExportManifests(r.Context(), s.ID, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get export manifests", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"manifests": manifests,
+	})
+}
+
+// loadExportSchedule resolves the {id} path value, writing an error response on failure.
+func (h *Handler) loadExportSchedule(w http.ResponseWriter, r *http.Request) (*db.ExportSchedule, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "Invalid schedule ID", "INVALID_ID")
+		return nil, false
+	}
+
+	s, err := h.db.GetExportSchedule(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return nil, false
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get export schedule", "DB_ERROR")
+		return nil, false
+	}
+	if s == nil {
+		respondError(w, http.StatusNotFound, "Export schedule not found", "NOT_FOUND")
+		return nil, false
+	}
+
+	return s, true
+}
+
+// exportScheduleResponse redacts credentials and adds the running flag.
+func (h *Handler) exportScheduleResponse(s db.ExportSchedule) ExportScheduleResponse {
+	redactDestination(&s.Destination)
+	running := false
+	if h.services != nil {
+		running = h.services.Exports.Current() == s.ID
+	}
+	return ExportScheduleResponse{ExportSchedule: s, Running: running}
+}
+
+// redactDestination replaces a destination's S3 secret key and WebDAV
+// password.
+func redactDestination(dest *db.ExportDestination) {
+	if dest.SecretKey != "" {
+		dest.SecretKey = redactedSecret
+	}
+	if dest.Password != "" {
+		dest.Password = redactedSecret
+	}
+}
+
+// keepDestinationSecrets restores credentials from previous that an update
+// left empty or sent back redacted.
+func keepDestinationSecrets(dest *db.ExportDestination, previous db.ExportDestination) {
+	if dest.SecretKey == "" || dest.SecretKey == redactedSecret {
+		dest.SecretKey = previous.SecretKey
+	}
+	if dest.Password == "" || dest.Password == redactedSecret {
+		dest.Password = previous.Password
+	}
+}
+
+// wakeExports asks the export worker to check for due schedules, if available.
+func (h *Handler) wakeExports() {
+	if h.services == nil {
+		return
+	}
+	h.services.Exports.Wake()
+}
+
+// applyExportScheduleRequest copies the fields present in req onto s.
+func applyExportScheduleRequest(s *db.ExportSchedule, req ExportScheduleRequest) {
+	if req.Name != nil {
+		s.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.DestinationType != nil {
+		s.DestinationType = *req.DestinationType
+	}
+	if req.Destination != nil {
+		s.Destination = *req.Destination
+	}
+	if req.Kinds != nil {
+		s.Kinds = *req.Kinds
+	}
+	if req.IntervalHours != nil {
+		s.IntervalHours = *req.IntervalHours
+	}
+	if req.RetentionCount != nil {
+		s.RetentionCount = *req.RetentionCount
+	}
+	if req.Enabled != nil {
+		s.Enabled = *req.Enabled
+	}
+}
+
+// validateExportSchedule checks a schedule's settings, writing an error response on failure.
+func validateExportSchedule(w http.ResponseWriter, s *db.ExportSchedule) bool {
+	if s.Name == "" {
+		respondError(w, http.StatusBadRequest, "Name is required", "MISSING_NAME")
+		return false
+	}
+	if s.IntervalHours < 1 || s.IntervalHours > 720 {
+		respondError(w, http.StatusBadRequest, "interval_hours must be between 1 and 720", "INVALID_INTERVAL")
+		return false
+	}
+	if s.RetentionCount < 0 || s.RetentionCount > 365 {
+		respondError(w, http.StatusBadRequest, "retention_count must be between 0 and 365", "INVALID_RETENTION")
+		return false
+	}
+	if err := services.ValidateExportDestination(s.DestinationType, s.Destination); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid destination: "+err.Error(), "INVALID_DESTINATION")
+		return false
+	}
+	return true
+}
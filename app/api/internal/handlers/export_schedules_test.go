@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestValidateExportSchedule(t *testing.T) {
+	valid := db.ExportSchedule{
+		Name:            "Nightly",
+		DestinationType: "local",
+		Destination:     db.ExportDestination{Path: "/data/exports"},
+		IntervalHours:   24,
+		RetentionCount:  7,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*db.ExportSchedule)
+		code   int
+	}{
+		{"valid", func(s *db.ExportSchedule) {}, 0},
+		{"missing_name", func(s *db.ExportSchedule) { s.Name = "" }, http.StatusBadRequest},
+		{"interval_zero", func(s *db.ExportSchedule) { s.IntervalHours = 0 }, http.StatusBadRequest},
+		{"retention_negative", func(s *db.ExportSchedule) { s.RetentionCount = -1 }, http.StatusBadRequest},
+		{"bad_destination", func(s *db.ExportSchedule) { s.DestinationType = "s3" }, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.modify(&s)
+			rec := httptest.NewRecorder()
+			ok := validateExportSchedule(rec, &s)
+			if ok != (tt.code == 0) {
+				t.Fatalf("validateExportSchedule() = %v, want %v", ok, tt.code == 0)
+			}
+			if !ok && rec.Code != tt.code {
+				t.Errorf("expected status %d, got %d", tt.code, rec.Code)
+			}
+		})
+	}
+}
+
+func TestApplyExportScheduleRequest(t *testing.T) {
+	s := &db.ExportSchedule{Name: "Nightly", IntervalHours: 24, RetentionCount: 7, Enabled: true, Kinds: []int{}}
+	interval := 6
+	enabled := false
+	kinds := []int{1, 30023}
+
+	applyExportScheduleRequest(s, ExportScheduleRequest{IntervalHours: &interval, Enabled: &enabled, Kinds: &kinds})
+
+	if s.Name != "Nightly" || s.RetentionCount != 7 {
+		t.Error("expected omitted fields to be unchanged")
+	}
+	if s.IntervalHours != 6 || s.Enabled || len(s.Kinds) != 2 {
+		t.Errorf("expected provided fields to be applied, got %+v", s)
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import "testing"
+
+func TestParseExportFormat(t *testing.T) {
+	tests := []struct {
+		value      string
+		ok         bool
+		array      bool
+		verifiable bool
+		gzip       bool
+		filename   string
+	}{
+		{"", true, false, false, false, "nostr-backup-2024-01-15.ndjson"},
+		{"ndjson", true, false, false, false, "nostr-backup-2024-01-15.ndjson"},
+		{"jsonl", true, false, false, false, "nostr-backup-2024-01-15.jsonl"},
+		{"json", true, true, false, false, "nostr-backup-2024-01-15.json"},
+		{"nostr-tools", true, true, true, false, "nostr-backup-2024-01-15.json"},
+		{"strfry", true, false, true, false, "nostr-backup-2024-01-15.jsonl"},
+		{"strfry.gz", true, false, true, true, "nostr-backup-2024-01-15.jsonl.gz"},
+		{"JSON.gz", true, true, false, true, "nostr-backup-2024-01-15.json.gz"},
+		{"gzip", true, false, false, true, "nostr-backup-2024-01-15.ndjson.gz"},
+		{"csv", false, false, false, false, ""},
+		{".gz", false, false, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			format, ok := parseExportFormat(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if format.array != tt.array || format.verifiable != tt.verifiable || format.gzip != tt.gzip {
+				t.Errorf("expected array %v, verifiable %v, gzip %v, got %+v", tt.array, tt.verifiable, tt.gzip, format)
+			}
+			if got := format.filename("2024-01-15"); got != tt.filename {
+				t.Errorf("expected filename %s, got %s", tt.filename, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// FollowGrantMember is a member through whose contact list a grant was made.
+type FollowGrantMember struct {
+	Pubkey   string `json:"pubkey"`
+	Npub     string `json:"npub"`
+	Nickname string `json:"nickname,omitempty"`
+}
+
+// FollowGrantResponse is a pubkey allowed to post because members follow it.
+type FollowGrantResponse struct {
+	Pubkey    string              `json:"pubkey"`
+	Npub      string              `json:"npub"`
+	Followers int                 `json:"followers"`
+	Via       []FollowGrantMember `json:"via"`
+	GrantedAt time.Time           `json:"granted_at"`
+}
+
+// GetFollowAccess returns the follow access settings and the pubkeys
+// granted access, each with the members who follow it.
+// GET /api/v1/access/follows
+func (h *Handler) GetFollowAccess(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetFollowAccessSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get follow access settings", "DB_ERROR")
+		return
+	}
+	grants, err := h.db.GetFollowGrants(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get follow grants", "DB_ERROR")
+		return
+	}
+	members, err := h.db.GetWhitelistMeta(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get whitelist", "DB_ERROR")
+		return
+	}
+	byPubkey := make(map[string]db.WhitelistEntry, len(members))
+	for _, m := range members {
+		byPubkey[m.Pubkey] = m
+	}
+
+	response := make([]FollowGrantResponse, len(grants))
+	for i, g := range grants {
+		npub, _ := nostr.EncodeNpub(g.Pubkey)
+		via := make([]FollowGrantMember, len(g.Via))
+		for j, pk := range g.Via {
+			via[j] = FollowGrantMember{Pubkey: pk, Npub: byPubkey[pk].Npub, Nickname: byPubkey[pk].Nickname}
+			if via[j].Npub == "" {
+				via[j].Npub, _ = nostr.EncodeNpub(pk)
+			}
+		}
+		response[i] = FollowGrantResponse{
+			Pubkey:    g.Pubkey,
+			Npub:      npub,
+			Followers: g.Followers,
+			Via:       via,
+			GrantedAt: g.GrantedAt,
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"settings": settings,
+		"grants":   response,
+	})
+}
+
+// UpdateFollowAccess saves the follow access settings and recomputes the
+// grants in the background.
+// PUT /api/v1/access/follows
+func (h *Handler) UpdateFollowAccess(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req db.FollowAccessSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if req.MinFollowers < 1 || req.MinFollowers > 1000 {
+		respondError(w, http.StatusBadRequest, "min_followers must be between 1 and 1000", "INVALID_MIN_FOLLOWERS")
+		return
+	}
+
+	if err := h.db.SetFollowAccessSettings(ctx, &req); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save follow access settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "follow_access_updated", map[string]interface{}{
+		"enabled":       req.Enabled,
+		"min_followers": req.MinFollowers,
+	}, "")
+
+	if h.services != nil && h.services.FollowAccess != nil {
+		h.services.FollowAccess.Wake()
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"settings": req,
+	})
+}
+
+// RunFollowAccess recomputes the follow grants now from members' contact
+// lists and returns what changed.
+// POST /api/v1/access/follows/run
+func (h *Handler) RunFollowAccess(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.FollowAccess == nil {
+		respondError(w, http.StatusServiceUnavailable, "Follow access service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	result, err := h.services.FollowAccess.Run(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update follow grants: "+err.Error(), "RUN_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
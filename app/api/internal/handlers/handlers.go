@@ -5,6 +5,7 @@ package handlers
 import (
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/config"
@@ -17,6 +18,7 @@ import (
 type Handler struct {
 	db        *db.DB
 	cfg       *config.Config
+	cfgMu     sync.RWMutex
 	configMgr *relay.ConfigManager
 	relay     *relay.Relay
 	services  *services.Services
@@ -35,6 +37,20 @@ func New(database *db.DB, cfg *config.Config, configMgr *relay.ConfigManager, re
 	}
 }
 
+// currentConfig returns the configuration, which can be replaced on reload.
+func (h *Handler) currentConfig() *config.Config {
+	h.cfgMu.RLock()
+	defer h.cfgMu.RUnlock()
+	return h.cfg
+}
+
+// SetConfig replaces the configuration after a reload.
+func (h *Handler) SetConfig(cfg *config.Config) {
+	h.cfgMu.Lock()
+	h.cfg = cfg
+	h.cfgMu.Unlock()
+}
+
 // RegisterRoutes registers all HTTP routes on the given mux.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	// Health check (both root and API paths for flexibility)
@@ -45,31 +61,54 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/v1/setup/status", h.GetSetupStatus)
 	mux.HandleFunc("GET /api/v1/setup/validate-identity", h.ValidateIdentity)
 	mux.HandleFunc("POST /api/v1/setup/complete", h.CompleteSetup)
+	mux.HandleFunc("POST /api/v1/setup/restore/fetch", h.FetchNostrBackup)
+	mux.HandleFunc("POST /api/v1/setup/restore", h.RestoreNostrBackup)
 
 	// Dashboard/Stats endpoints
+	mux.HandleFunc("GET /api/v1/dashboard", h.GetDashboard)
 	mux.HandleFunc("GET /api/v1/stats/summary", h.GetStatsSummary)
 	mux.HandleFunc("GET /api/v1/stats/stream", h.StreamDashboardStats)
 	mux.HandleFunc("GET /api/v1/stats/events-over-time", h.GetEventsOverTime)
 	mux.HandleFunc("GET /api/v1/stats/events-by-kind", h.GetEventsByKind)
 	mux.HandleFunc("GET /api/v1/stats/top-authors", h.GetTopAuthors)
+	mux.HandleFunc("GET /api/v1/analytics/kind-trends", h.GetKindTrends)
+	mux.HandleFunc("GET /api/v1/analytics/author-growth", h.GetAuthorGrowth)
+	mux.HandleFunc("GET /api/v1/analytics/interactions/{pubkey}", h.GetInteractions)
+	mux.HandleFunc("GET /api/v1/stats/bandwidth", h.GetBandwidthStats)
+	mux.HandleFunc("GET /api/v1/kinds", h.GetKinds)
+	mux.HandleFunc("GET /api/v1/system/stats", h.GetSystemStats)
 	mux.HandleFunc("GET /api/v1/relay/status", h.GetRelayStatus)
+	mux.HandleFunc("GET /api/v1/relay/uptime", h.GetRelayUptime)
 	mux.HandleFunc("GET /api/v1/relay/urls", h.GetRelayURLs)
 	mux.HandleFunc("GET /api/v1/events/recent", h.GetRecentEvents)
 
+	// Member endpoints
+	mux.HandleFunc("GET /api/v1/members/{pubkey}/highlights", h.GetMemberHighlights)
+	mux.HandleFunc("GET /api/v1/members/coverage", h.GetMirrorCoverage)
+	mux.HandleFunc("GET /api/v1/members/{pubkey}/coverage", h.GetMemberCoverage)
+	mux.HandleFunc("GET /api/v1/users/{pubkey}/activity", h.GetUserActivity)
+
 	// Relay control endpoints
 	mux.HandleFunc("POST /api/v1/relay/reload", h.ReloadRelay)
 	mux.HandleFunc("POST /api/v1/relay/restart", h.RestartRelay)
 	mux.HandleFunc("GET /api/v1/relay/logs", h.GetRelayLogs)
 	mux.HandleFunc("GET /api/v1/relay/logs/stream", h.StreamRelayLogs)
+	mux.HandleFunc("GET /api/v1/relay/announce", h.GetRelayAnnouncement)
+	mux.HandleFunc("PUT /api/v1/relay/announce", h.UpdateRelayAnnouncement)
+	mux.HandleFunc("POST /api/v1/relay/announce", h.PublishRelayAnnouncement)
 
 	// Access control endpoints
 	mux.HandleFunc("GET /api/v1/access/mode", h.GetAccessMode)
 	mux.HandleFunc("PUT /api/v1/access/mode", h.SetAccessMode)
+	mux.HandleFunc("POST /api/v1/access/simulate", h.SimulateAccessChange)
 
 	// Whitelist endpoints
 	mux.HandleFunc("GET /api/v1/access/whitelist", h.GetWhitelist)
 	mux.HandleFunc("POST /api/v1/access/whitelist", h.AddToWhitelist)
 	mux.HandleFunc("POST /api/v1/access/whitelist/bulk", h.BulkAddToWhitelist)
+	mux.HandleFunc("POST /api/v1/access/whitelist/import-follows", h.ImportFollows)
+	mux.HandleFunc("GET /api/v1/access/whitelist/export", h.ExportWhitelist)
+	mux.HandleFunc("POST /api/v1/access/whitelist/import", h.ImportWhitelist)
 	mux.HandleFunc("DELETE /api/v1/access/whitelist/{pubkey}", h.RemoveFromWhitelist)
 	mux.HandleFunc("PATCH /api/v1/access/whitelist/{pubkey}", h.UpdateWhitelistEntry)
 
@@ -83,44 +122,141 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("PUT /api/v1/access/pricing", h.UpdatePricingTiers)
 	mux.HandleFunc("GET /api/v1/access/paid-users", h.GetPaidUsers)
 	mux.HandleFunc("DELETE /api/v1/access/paid-users/{pubkey}", h.RevokePaidUserAccess)
+	mux.HandleFunc("POST /api/v1/access/paid-users/{pubkey}/invoice", h.CreatePaidUserInvoice)
 	mux.HandleFunc("GET /api/v1/access/revenue", h.GetRevenueStats)
+	mux.HandleFunc("GET /api/v1/access/renewals", h.GetRenewalSettings)
+	mux.HandleFunc("PUT /api/v1/access/renewals", h.UpdateRenewalSettings)
+	mux.HandleFunc("POST /api/v1/access/renewals/run", h.RunRenewals)
+	mux.HandleFunc("GET /api/v1/access/expiry", h.GetExpirySettings)
+	mux.HandleFunc("PUT /api/v1/access/expiry", h.UpdateExpirySettings)
+
+	// Coupon endpoints
+	mux.HandleFunc("GET /api/v1/access/coupons", h.GetCoupons)
+	mux.HandleFunc("POST /api/v1/access/coupons", h.CreateCoupon)
+	mux.HandleFunc("PATCH /api/v1/access/coupons/{code}", h.UpdateCoupon)
+	mux.HandleFunc("DELETE /api/v1/access/coupons/{code}", h.DeleteCoupon)
+	mux.HandleFunc("GET /api/v1/access/coupons/{code}/redemptions", h.GetCouponRedemptions)
+
+	// Zap payment endpoints
+	mux.HandleFunc("GET /api/v1/access/zaps", h.GetZapSettings)
+	mux.HandleFunc("PUT /api/v1/access/zaps", h.UpdateZapSettings)
+
+	// Cashu payment endpoints
+	mux.HandleFunc("GET /api/v1/access/cashu", h.GetCashuSettings)
+	mux.HandleFunc("PUT /api/v1/access/cashu", h.UpdateCashuSettings)
+	mux.HandleFunc("GET /api/v1/access/status-lookup", h.GetAccessStatusSettings)
+	mux.HandleFunc("PUT /api/v1/access/status-lookup", h.UpdateAccessStatusSettings)
+
+	// Kind policy endpoints
+	mux.HandleFunc("GET /api/v1/access/policies", h.GetKindPolicies)
+	mux.HandleFunc("PUT /api/v1/access/policies/{tier}", h.UpdateKindPolicy)
+	mux.HandleFunc("DELETE /api/v1/access/policies/{tier}", h.DeleteKindPolicy)
+
+	// Quota endpoints
+	mux.HandleFunc("GET /api/v1/access/quotas", h.GetQuotaPolicies)
+	mux.HandleFunc("PUT /api/v1/access/quotas/{tier}", h.UpdateQuotaPolicy)
+	mux.HandleFunc("DELETE /api/v1/access/quotas/{tier}", h.DeleteQuotaPolicy)
+
+	// Event admission endpoints
+	mux.HandleFunc("GET /api/v1/access/admission", h.GetAdmissionStatus)
+
+	// Follow access endpoints
+	mux.HandleFunc("GET /api/v1/access/follows", h.GetFollowAccess)
+	mux.HandleFunc("PUT /api/v1/access/follows", h.UpdateFollowAccess)
+	mux.HandleFunc("POST /api/v1/access/follows/run", h.RunFollowAccess)
+
+	// Report endpoints
+	mux.HandleFunc("GET /api/v1/reports/members.csv", h.ExportMembersCSV)
+	mux.HandleFunc("GET /api/v1/reports/schedule", h.GetReportSchedule)
+	mux.HandleFunc("PUT /api/v1/reports/schedule", h.UpdateReportSchedule)
+	mux.HandleFunc("GET /api/v1/reports/summary", h.GetReportSummary)
+	mux.HandleFunc("POST /api/v1/reports/send", h.SendReport)
+
+	// Audit log endpoints
+	mux.HandleFunc("GET /api/v1/audit", h.GetAuditLog)
+	mux.HandleFunc("GET /api/v1/audit.csv", h.ExportAuditLogCSV)
+	mux.HandleFunc("GET /api/v1/audit/settings", h.GetAuditSettings)
+	mux.HandleFunc("PUT /api/v1/audit/settings", h.UpdateAuditSettings)
 
 	// NIP-05 resolution endpoint
 	mux.HandleFunc("GET /api/v1/nip05/{identifier}", h.ResolveNIP05)
 
 	// Event browser endpoints
 	mux.HandleFunc("GET /api/v1/events", h.GetEvents)
+	mux.HandleFunc("GET /api/v1/events/search", h.SearchEvents)
 	mux.HandleFunc("GET /api/v1/events/export", h.ExportEvents)
 	mux.HandleFunc("GET /api/v1/events/export/estimate", h.GetExportEstimate)
 	mux.HandleFunc("POST /api/v1/events/import", h.ImportEvents)
+	mux.HandleFunc("POST /api/v1/import/upload", h.UploadImport)
 	mux.HandleFunc("GET /api/v1/events/{id}", h.GetEvent)
+	mux.HandleFunc("GET /api/v1/events/{id}/thread", h.GetEventThread)
+	mux.HandleFunc("POST /api/v1/events/{id}/verify", h.VerifyEvent)
 	mux.HandleFunc("DELETE /api/v1/events/{id}", h.DeleteEvent)
 
 	// Configuration endpoints
 	mux.HandleFunc("GET /api/v1/config", h.GetConfig)
 	mux.HandleFunc("PATCH /api/v1/config", h.UpdateConfig)
 	mux.HandleFunc("POST /api/v1/config/reload", h.ReloadConfig)
+	mux.HandleFunc("GET /api/v1/config/drift", h.GetConfigDrift)
+	mux.HandleFunc("POST /api/v1/config/reconcile", h.ReconcileConfig)
+	mux.HandleFunc("GET /api/v1/config/history", h.GetConfigHistory)
+	mux.HandleFunc("GET /api/v1/config/history/{id}", h.GetConfigVersion)
+	mux.HandleFunc("POST /api/v1/config/history/{id}/rollback", h.RollbackConfig)
+	mux.HandleFunc("GET /api/v1/config/presets", h.GetConfigPresets)
+	mux.HandleFunc("POST /api/v1/config/presets", h.ApplyConfigPreset)
+	mux.HandleFunc("GET /api/v1/config/presets/recommendation", h.GetConfigPresetRecommendation)
 
 	// Settings endpoints
+	mux.HandleFunc("GET /api/v1/settings", h.GetSettings)
 	mux.HandleFunc("GET /api/v1/settings/timezone", h.GetTimezone)
 	mux.HandleFunc("PUT /api/v1/settings/timezone", h.SetTimezone)
+	mux.HandleFunc("GET /api/v1/settings/hardware", h.GetHardware)
+	mux.HandleFunc("PUT /api/v1/settings/hardware", h.SetHardware)
+	mux.HandleFunc("GET /api/v1/settings/cors", h.GetCORSSettings)
+	mux.HandleFunc("PUT /api/v1/settings/cors", h.UpdateCORSSettings)
+	mux.HandleFunc("GET /api/v1/settings/privacy", h.GetPrivacySettings)
+	mux.HandleFunc("PUT /api/v1/settings/privacy", h.UpdatePrivacySettings)
+	mux.HandleFunc("GET /api/v1/csrf", h.GetCSRFToken)
 
 	// Storage management endpoints
 	mux.HandleFunc("GET /api/v1/storage/status", h.GetStorageStatus)
 	mux.HandleFunc("GET /api/v1/storage/retention", h.GetRetentionPolicy)
 	mux.HandleFunc("PUT /api/v1/storage/retention", h.UpdateRetentionPolicy)
 	mux.HandleFunc("POST /api/v1/storage/retention/run", h.RunRetentionNow)
+	mux.HandleFunc("POST /api/v1/storage/retention/preview", h.PreviewRetentionPolicy)
 	mux.HandleFunc("POST /api/v1/storage/cleanup", h.ManualCleanup)
 	mux.HandleFunc("POST /api/v1/storage/vacuum", h.RunVacuum)
 	mux.HandleFunc("GET /api/v1/storage/deletion-requests", h.GetDeletionRequests)
+	mux.HandleFunc("GET /api/v1/storage/tombstones", h.GetTombstones)
+	mux.HandleFunc("DELETE /api/v1/storage/tombstones/{id}", h.DeleteTombstone)
+	mux.HandleFunc("GET /api/v1/storage/tombstones/publishing", h.GetTombstonePublishing)
+	mux.HandleFunc("PUT /api/v1/storage/tombstones/publishing", h.UpdateTombstonePublishing)
+	mux.HandleFunc("GET /api/v1/storage/media", h.GetMediaStorage)
+	mux.HandleFunc("PUT /api/v1/storage/media", h.UpdateMediaSettings)
+	mux.HandleFunc("POST /api/v1/storage/media/scan", h.ScanMedia)
 	mux.HandleFunc("GET /api/v1/storage/estimate", h.GetStorageEstimate)
+	mux.HandleFunc("GET /api/v1/storage/breakdown", h.GetStorageBreakdown)
+	mux.HandleFunc("GET /api/v1/storage/pressure", h.GetStoragePressurePolicy)
+	mux.HandleFunc("PUT /api/v1/storage/pressure", h.UpdateStoragePressurePolicy)
 	mux.HandleFunc("POST /api/v1/storage/integrity-check", h.RunIntegrityCheck)
+	mux.HandleFunc("GET /api/v1/storage/maintenance/schedule", h.GetStorageMaintenanceSchedule)
+	mux.HandleFunc("PUT /api/v1/storage/maintenance/schedule", h.UpdateStorageMaintenanceSchedule)
+	mux.HandleFunc("GET /api/v1/storage/maintenance/history", h.GetStorageMaintenanceHistory)
+	mux.HandleFunc("GET /api/v1/storage/npub-check", h.GetNpubCheck)
+	mux.HandleFunc("POST /api/v1/storage/npub-check", h.FixNpubs)
+	mux.HandleFunc("GET /api/v1/storage/relay-index", h.GetRelayIndexStatus)
+	mux.HandleFunc("POST /api/v1/storage/relay-index/sync", h.SyncRelayIndex)
 
 	// Sync endpoints
 	mux.HandleFunc("POST /api/v1/sync/start", h.StartSync)
 	mux.HandleFunc("GET /api/v1/sync/status", h.GetSyncStatus)
 	mux.HandleFunc("POST /api/v1/sync/cancel", h.CancelSync)
 	mux.HandleFunc("GET /api/v1/sync/history", h.GetSyncHistory)
+	mux.HandleFunc("GET /api/v1/sync/jobs/{id}/progress", h.StreamSyncProgress)
+	mux.HandleFunc("POST /api/v1/sync/jobs/{id}/pause", h.PauseSync)
+	mux.HandleFunc("POST /api/v1/sync/jobs/{id}/resume", h.ResumeSync)
+	mux.HandleFunc("POST /api/v1/sync/migrate", h.StartMigration)
+	mux.HandleFunc("GET /api/v1/sync/migrations/{id}/report", h.GetMigrationReport)
 	// Sync pubkeys configuration
 	mux.HandleFunc("GET /api/v1/sync/pubkeys", h.GetSyncPubkeys)
 	mux.HandleFunc("POST /api/v1/sync/pubkeys", h.AddSyncPubkey)
@@ -131,6 +267,10 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("DELETE /api/v1/sync/relays/{url}", h.RemoveSyncRelay)
 	mux.HandleFunc("POST /api/v1/sync/relays/reset", h.ResetSyncRelays)
 
+	// Relay information (NIP-11) endpoints
+	mux.HandleFunc("GET /api/v1/relay-info", h.GetRelayInfoSettings)
+	mux.HandleFunc("PUT /api/v1/relay-info", h.UpdateRelayInfoSettings)
+
 	// Support endpoints
 	mux.HandleFunc("GET /api/v1/support/config", h.GetSupportConfig)
 
@@ -138,11 +278,145 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/v1/lightning/status", h.GetLightningStatus)
 	mux.HandleFunc("PUT /api/v1/lightning/config", h.SaveLightningConfig)
 	mux.HandleFunc("POST /api/v1/lightning/test", h.TestLightningConnection)
+	mux.HandleFunc("POST /api/v1/lightning/validate-permissions", h.ValidateLightningPermissions)
+	mux.HandleFunc("PUT /api/v1/lightning/mock", h.UpdateMockLightning)
+	mux.HandleFunc("POST /api/v1/lightning/mock/invoices/{payment_hash}/settle", h.SettleMockInvoice)
+
+	// Secrets endpoints
+	mux.HandleFunc("GET /api/v1/secrets/status", h.GetSecretsStatus)
+	mux.HandleFunc("POST /api/v1/secrets/setup", h.SetupSecrets)
+	mux.HandleFunc("POST /api/v1/secrets/unlock", h.UnlockSecrets)
+	mux.HandleFunc("POST /api/v1/secrets/lock", h.LockSecrets)
+
+	// Operator signer endpoints (NIP-46)
+	mux.HandleFunc("GET /api/v1/signer", h.GetSigner)
+	mux.HandleFunc("DELETE /api/v1/signer", h.UnpairSigner)
+	mux.HandleFunc("POST /api/v1/signer/pair", h.PairSigner)
+	mux.HandleFunc("POST /api/v1/signer/nostrconnect", h.StartSignerPairing)
+	mux.HandleFunc("POST /api/v1/signer/test", h.TestSigner)
 
 	// Public signup endpoints (no auth required)
 	mux.HandleFunc("GET /public/relay-info", h.GetRelayInfo)
+	mux.HandleFunc("GET /public/signup-check", h.CheckSignupIdentity)
 	mux.HandleFunc("POST /public/create-invoice", h.CreateSignupInvoice)
+	mux.HandleFunc("POST /public/cashu-payment", h.PayWithCashu)
 	mux.HandleFunc("GET /public/invoice-status/{hash}", h.GetInvoiceStatus)
+	mux.HandleFunc("POST /public/invoice-reissue/{hash}", h.ReissueSignupInvoice)
+	mux.HandleFunc("POST /public/invoice-cancel/{hash}", h.CancelSignupInvoice)
+	mux.HandleFunc("GET /public/access-status/{pubkey}", h.GetAccessStatus)
+	mux.HandleFunc("GET /public/renew/{npub}", h.GetRenewal)
+	mux.HandleFunc("GET /public/widget-config", h.GetWidgetConfig)
+	mux.HandleFunc("GET /public/search", h.ServeSearchRelay)
+	mux.HandleFunc("GET /public/status", h.GetPublicStatus)
+	mux.HandleFunc("GET /public/nip11", h.GetNIP11Document)
+	mux.HandleFunc("POST /public/nip86", h.ServeNIP86)
+
+	// Lightning address (LNURL-pay with NIP-57 zaps, no auth required)
+	mux.HandleFunc("GET /.well-known/lnurlp/{name}", h.GetLNURLPay)
+	mux.HandleFunc("GET /public/lnurlp/{name}", h.GetZapInvoice)
+
+	// Signup widget embedding (CORS allowlist for /public/* routes)
+	mux.HandleFunc("GET /api/v1/signup/cors", h.GetSignupCORS)
+	mux.HandleFunc("PUT /api/v1/signup/cors", h.UpdateSignupCORS)
+
+	// Security endpoints
+	mux.HandleFunc("GET /api/v1/security/rate-limits", h.GetRateLimitSettings)
+	mux.HandleFunc("PUT /api/v1/security/rate-limits", h.UpdateRateLimitSettings)
+	mux.HandleFunc("GET /api/v1/security/bans", h.GetIPBans)
+	mux.HandleFunc("POST /api/v1/security/bans", h.BanIP)
+	mux.HandleFunc("DELETE /api/v1/security/bans/{ip...}", h.UnbanIP)
+
+	// Moderation endpoints
+	mux.HandleFunc("GET /api/v1/moderation/settings", h.GetModerationSettings)
+	mux.HandleFunc("PUT /api/v1/moderation/settings", h.UpdateModerationSettings)
+	mux.HandleFunc("GET /api/v1/moderation/rules", h.GetModerationRules)
+	mux.HandleFunc("POST /api/v1/moderation/rules", h.CreateModerationRule)
+	mux.HandleFunc("GET /api/v1/moderation/rules/{id}", h.GetModerationRule)
+	mux.HandleFunc("PATCH /api/v1/moderation/rules/{id}", h.UpdateModerationRule)
+	mux.HandleFunc("DELETE /api/v1/moderation/rules/{id}", h.DeleteModerationRule)
+	mux.HandleFunc("GET /api/v1/moderation/actions", h.GetModerationActions)
+	mux.HandleFunc("POST /api/v1/moderation/scan", h.RunModerationScan)
+
+	// Webhook endpoints
+	mux.HandleFunc("GET /api/v1/webhooks", h.GetWebhooks)
+	mux.HandleFunc("POST /api/v1/webhooks", h.CreateWebhook)
+	mux.HandleFunc("GET /api/v1/webhooks/{id}", h.GetWebhook)
+	mux.HandleFunc("PATCH /api/v1/webhooks/{id}", h.UpdateWebhook)
+	mux.HandleFunc("DELETE /api/v1/webhooks/{id}", h.DeleteWebhook)
+	mux.HandleFunc("GET /api/v1/webhooks/{id}/deliveries", h.GetWebhookDeliveries)
+	mux.HandleFunc("POST /api/v1/webhooks/{id}/test", h.TestWebhook)
+
+	// Scheduled export endpoints
+	mux.HandleFunc("GET /api/v1/exports/schedules", h.GetExportSchedules)
+	mux.HandleFunc("POST /api/v1/exports/schedules", h.CreateExportSchedule)
+	mux.HandleFunc("GET /api/v1/exports/schedules/{id}", h.GetExportSchedule)
+	mux.HandleFunc("PATCH /api/v1/exports/schedules/{id}", h.UpdateExportSchedule)
+	mux.HandleFunc("DELETE /api/v1/exports/schedules/{id}", h.DeleteExportSchedule)
+	mux.HandleFunc("POST /api/v1/exports/schedules/{id}/run", h.RunExportSchedule)
+	mux.HandleFunc("GET /api/v1/exports/schedules/{id}/manifests", h.GetExportManifests)
+
+	// Data residency endpoints
+	mux.HandleFunc("GET /api/v1/residency", h.GetResidencyPolicy)
+	mux.HandleFunc("PUT /api/v1/residency", h.UpdateResidencyPolicy)
+	mux.HandleFunc("GET /api/v1/residency/report", h.GetResidencyReport)
+	mux.HandleFunc("POST /api/v1/residency/purge", h.PurgeExcludedKinds)
+
+	// Backup endpoints
+	mux.HandleFunc("POST /api/v1/backup", h.CreateBackup)
+	mux.HandleFunc("GET /api/v1/backup/archives", h.ListBackups)
+	mux.HandleFunc("GET /api/v1/backup/archives/{name}", h.DownloadBackup)
+	mux.HandleFunc("DELETE /api/v1/backup/archives/{name}", h.DeleteBackup)
+	mux.HandleFunc("GET /api/v1/backup/schedule", h.GetBackupSchedule)
+	mux.HandleFunc("PUT /api/v1/backup/schedule", h.UpdateBackupSchedule)
+	mux.HandleFunc("POST /api/v1/backup/archives/{name}/upload", h.UploadBackup)
+	mux.HandleFunc("GET /api/v1/backup/targets", h.GetBackupTargets)
+	mux.HandleFunc("POST /api/v1/backup/targets", h.CreateBackupTarget)
+	mux.HandleFunc("POST /api/v1/backup/targets/test", h.TestBackupTargetSettings)
+	mux.HandleFunc("GET /api/v1/backup/targets/{id}", h.GetBackupTarget)
+	mux.HandleFunc("PATCH /api/v1/backup/targets/{id}", h.UpdateBackupTarget)
+	mux.HandleFunc("DELETE /api/v1/backup/targets/{id}", h.DeleteBackupTarget)
+	mux.HandleFunc("POST /api/v1/backup/targets/{id}/test", h.TestBackupTarget)
+
+	// Nostr backup endpoints
+	mux.HandleFunc("GET /api/v1/backup/nostr", h.GetNostrBackup)
+	mux.HandleFunc("PUT /api/v1/backup/nostr", h.UpdateNostrBackup)
+	mux.HandleFunc("POST /api/v1/backup/nostr/publish", h.PublishNostrBackup)
+
+	// Mention notification endpoints
+	mux.HandleFunc("GET /api/v1/notifications/mentions", h.GetMentionNotifications)
+	mux.HandleFunc("PUT /api/v1/notifications/mentions", h.UpdateMentionNotifications)
+	mux.HandleFunc("PUT /api/v1/notifications/mentions/{pubkey}", h.SaveMentionSubscription)
+	mux.HandleFunc("DELETE /api/v1/notifications/mentions/{pubkey}", h.DeleteMentionSubscription)
+	mux.HandleFunc("GET /api/v1/notifications/email", h.GetEmailSettings)
+	mux.HandleFunc("PUT /api/v1/notifications/email", h.UpdateEmailSettings)
+	mux.HandleFunc("DELETE /api/v1/notifications/email", h.DeleteEmailSettings)
+	mux.HandleFunc("POST /api/v1/notifications/email/test", h.SendTestEmail)
+
+	// Maintenance endpoints
+	mux.HandleFunc("GET /api/v1/maintenance", h.GetMaintenance)
+	mux.HandleFunc("PUT /api/v1/maintenance/status", h.UpdateRelayStatus)
+	mux.HandleFunc("POST /api/v1/maintenance/windows", h.CreateMaintenanceWindow)
+	mux.HandleFunc("POST /api/v1/maintenance/windows/{id}/announce", h.AnnounceMaintenanceWindow)
+	mux.HandleFunc("DELETE /api/v1/maintenance/windows/{id}", h.DeleteMaintenanceWindow)
+
+	// Profile endpoints
+	mux.HandleFunc("GET /api/v1/profiles/{pubkey}", h.GetProfile)
+
+	// Job endpoints
+	mux.HandleFunc("GET /api/v1/jobs", h.ListJobs)
+	mux.HandleFunc("POST /api/v1/jobs", h.StartJob)
+	mux.HandleFunc("GET /api/v1/jobs/{id}", h.GetJob)
+	mux.HandleFunc("POST /api/v1/jobs/{id}/cancel", h.CancelJob)
+	mux.HandleFunc("GET /api/v1/jobs/{id}/stream", h.StreamJob)
+
+	// Broadcast endpoints
+	mux.HandleFunc("GET /api/v1/broadcast", h.ListBroadcasts)
+	mux.HandleFunc("POST /api/v1/broadcast", h.StartBroadcast)
+	mux.HandleFunc("GET /api/v1/broadcast/{id}", h.GetBroadcast)
+	mux.HandleFunc("POST /api/v1/broadcast/{id}/cancel", h.CancelBroadcast)
+
+	// Debug endpoints (DEBUG=true only)
+	mux.HandleFunc("POST /api/v1/debug/bench", h.RunBench)
 
 	// Serve static files for the UI (SPA fallback)
 	mux.HandleFunc("/", h.ServeUI)
@@ -163,7 +437,7 @@ func (h *Handler) ServeUI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If static directory is not configured, show placeholder
-	if h.cfg.StaticDir == "" {
+	if h.currentConfig().StaticDir == "" {
 		w.Header().Set("Content-Type", "text/html")
 		w.Write([]byte(`<!DOCTYPE html>
 <html>
@@ -190,13 +464,14 @@ func (h *Handler) serveStaticFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Try to serve the requested file
-	filePath := h.cfg.StaticDir + path
+	staticDir := h.currentConfig().StaticDir
+	filePath := staticDir + path
 
 	// Check if the file exists
 	info, err := os.Stat(filePath)
 	if err != nil || info.IsDir() {
 		// File doesn't exist or is a directory - serve index.html for SPA routing
-		filePath = h.cfg.StaticDir + "/index.html"
+		filePath = staticDir + "/index.html"
 	}
 
 	// Serve the file
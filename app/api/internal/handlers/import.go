@@ -5,15 +5,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
 	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
 // ImportEventsRequest defines options for the import operation.
@@ -29,6 +31,13 @@ type ImportEventsResponse struct {
 	Processed  int      `json:"processed"`   // Events attempted
 	Added      int      `json:"added"`       // Successfully inserted (new)
 	Duplicates int      `json:"duplicates"`  // Already existed
+	Excluded   int      `json:"excluded"`    // Kind excluded by the data residency policy
+	Tombstoned int      `json:"tombstoned"`  // Deleted from this relay before
+	Rejected   int      `json:"rejected"`    // Failed ID or signature verification
+	Superseded int      `json:"superseded"`  // Replaceable events older than the stored version
+	Ephemeral  int      `json:"ephemeral"`   // Ephemeral kinds, which are never stored
+	Expired    int      `json:"expired"`     // Past their NIP-40 expiration
+	Replaced   int64    `json:"replaced"`    // Stored versions of replaceable events deleted
 	Errors     int      `json:"errors"`      // Failed to insert
 	ErrorList  []string `json:"error_list"`  // Error messages (limited to first 100)
 }
@@ -36,11 +45,17 @@ type ImportEventsResponse struct {
 // ImportEvents handles POST /api/v1/events/import
 // Accepts NDJSON or JSON array format file uploads.
 // Compatible with exports from strfry, nosdump, nostrudel, and other Nostr tools.
+// The import runs as a job; with async=true it responds 202 with the job
+// instead of waiting for the result.
 func (h *Handler) ImportEvents(w http.ResponseWriter, r *http.Request) {
 	if !h.db.IsRelayDBConnected() {
 		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
 		return
 	}
+	if h.services != nil && h.services.Pressure.IngestPaused() {
+		respondError(w, http.StatusInsufficientStorage, services.ErrStoragePressure.Error(), "STORAGE_PRESSURE")
+		return
+	}
 
 	// Parse multipart form (max 500MB file)
 	if err := r.ParseMultipartForm(500 << 20); err != nil {
@@ -56,7 +71,7 @@ func (h *Handler) ImportEvents(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	log.Printf("Importing events from file: %s (%d bytes)", header.Filename, header.Size)
+	slog.InfoContext(r.Context(), "Importing events from file", "filename", header.Filename, "bytes", header.Size)
 
 	// Parse options from form data
 	options := ImportEventsRequest{
@@ -85,7 +100,7 @@ func (h *Handler) ImportEvents(w http.ResponseWriter, r *http.Request) {
 
 	// Detect format by finding first non-whitespace character
 	format := detectFormat(data)
-	log.Printf("Detected format: %s", format)
+	slog.DebugContext(r.Context(), "Detected import format", "format", format)
 
 	// Parse events based on format
 	var events []*nostr.SyncEvent
@@ -100,21 +115,33 @@ func (h *Handler) ImportEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create a relay writer for inserting events
-	writer, err := h.db.NewRelayWriter()
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to open database for writing", "DB_WRITE_ERROR")
-		return
+	// Import as a job so it can be watched and cancelled
+	params := map[string]interface{}{
+		"filename": header.Filename,
+		"bytes":    header.Size,
+		"events":   len(events),
+		"options":  options,
 	}
-	defer writer.Close()
+	h.runJob(w, r, services.JobTypeImport, params, func(ctx context.Context, run *services.JobRun) (interface{}, error) {
+		// Create a relay writer for inserting events
+		writer, err := h.db.NewRelayWriter()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database for writing: %w", err)
+		}
+		defer writer.Close()
 
-	// Import events
-	response := h.importEvents(r.Context(), writer, events, options)
+		total := int64(len(events))
+		run.Progress(0, total, "")
+		response := h.importEvents(ctx, writer, events, options, func(processed int) {
+			run.Progress(int64(processed), total, fmt.Sprintf("%d of %d events processed", processed, total))
+		})
 
-	log.Printf("Import complete: %d total, %d added, %d duplicates, %d errors",
-		response.Total, response.Added, response.Duplicates, response.Errors)
+		slog.Info("Import complete",
+			"total", response.Total, "added", response.Added, "duplicates", response.Duplicates,
+			"excluded", response.Excluded, "tombstoned", response.Tombstoned, "errors", response.Errors)
 
-	respondJSON(w, http.StatusOK, response)
+		return response, ctx.Err()
+	}, "Failed to import events", "IMPORT_FAILED")
 }
 
 // detectFormat determines if the data is NDJSON or JSON array.
@@ -176,62 +203,101 @@ func parseNDJSON(data []byte) ([]*nostr.SyncEvent, error) {
 	return events, nil
 }
 
-// importEvents processes and inserts events into the database.
-func (h *Handler) importEvents(ctx context.Context, writer *db.RelayWriter, events []*nostr.SyncEvent, options ImportEventsRequest) ImportEventsResponse {
+// importEventsProgressEvery is how many events are processed between
+// progress reports.
+const importEventsProgressEvery = 100
+
+// importEvents processes and inserts events into the database. It reports
+// progress to the optional progress func and stops early if ctx is cancelled.
+func (h *Handler) importEvents(ctx context.Context, writer *db.RelayWriter, events []*nostr.SyncEvent, options ImportEventsRequest, progress func(processed int)) ImportEventsResponse {
 	response := ImportEventsResponse{
 		Total:     len(events),
 		ErrorList: make([]string, 0),
 	}
 
 	for i, event := range events {
-		response.Processed++
-
-		// Verify event if requested
-		if options.VerifySignatures {
-			if err := event.Verify(); err != nil {
-				errMsg := fmt.Sprintf("Event %d: verification failed: %v", i+1, err)
-				response.Errors++
-				if len(response.ErrorList) < 100 {
-					response.ErrorList = append(response.ErrorList, errMsg)
-				}
-				if options.StopOnError {
-					break
-				}
-				continue
-			}
+		if ctx.Err() != nil {
+			break
 		}
-
-		// Convert to DB format
-		dbEvent := &db.Event{
-			ID:        event.ID,
-			Pubkey:    event.Pubkey,
-			CreatedAt: time.Unix(event.CreatedAt, 0),
-			Kind:      event.Kind,
-			Tags:      event.Tags,
-			Content:   event.Content,
-			Sig:       event.Sig,
+		if progress != nil && i > 0 && i%importEventsProgressEvery == 0 {
+			progress(i)
+		}
+		if h.importEvent(ctx, writer, i+1, event, options, &response) {
+			break
 		}
+	}
 
-		// Insert event
-		inserted, err := writer.InsertEvent(ctx, dbEvent)
-		if err != nil {
-			errMsg := fmt.Sprintf("Event %d: insert failed: %v", i+1, err)
-			response.Errors++
+	if progress != nil {
+		progress(response.Processed)
+	}
+	return response
+}
+
+// importEvent verifies and inserts one event, counting the outcome in
+// response. n is the event's position in the file, from 1. It reports
+// whether the import should stop.
+func (h *Handler) importEvent(ctx context.Context, writer *db.RelayWriter, n int, event *nostr.SyncEvent, options ImportEventsRequest, response *ImportEventsResponse) bool {
+	response.Processed++
+
+	// Verify event if requested
+	if options.VerifySignatures {
+		if err := event.Verify(); err != nil {
+			errMsg := fmt.Sprintf("Event %d: verification failed: %v", n, err)
+			response.Rejected++
 			if len(response.ErrorList) < 100 {
 				response.ErrorList = append(response.ErrorList, errMsg)
 			}
-			if options.StopOnError {
-				break
-			}
-			continue
+			return options.StopOnError
 		}
+	}
 
-		if inserted {
-			response.Added++
-		} else {
-			response.Duplicates++
+	// Convert to DB format
+	dbEvent := &db.Event{
+		ID:        event.ID,
+		Pubkey:    event.Pubkey,
+		CreatedAt: time.Unix(event.CreatedAt, 0),
+		Kind:      event.Kind,
+		Tags:      event.Tags,
+		Content:   event.Content,
+		Sig:       event.Sig,
+	}
+
+	// Insert event, replacing older versions of replaceable events
+	stored, err := writer.StoreEvent(ctx, dbEvent)
+	response.Replaced += stored.Replaced
+	if errors.Is(err, db.ErrKindExcluded) {
+		response.Excluded++
+		return false
+	}
+	if errors.Is(err, db.ErrTombstoned) {
+		response.Tombstoned++
+		return false
+	}
+	if errors.Is(err, db.ErrSuperseded) {
+		response.Superseded++
+		return false
+	}
+	if errors.Is(err, db.ErrEphemeral) {
+		response.Ephemeral++
+		return false
+	}
+	if errors.Is(err, db.ErrExpired) {
+		response.Expired++
+		return false
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Event %d: insert failed: %v", n, err)
+		response.Errors++
+		if len(response.ErrorList) < 100 {
+			response.ErrorList = append(response.ErrorList, errMsg)
 		}
+		return options.StopOnError
 	}
 
-	return response
+	if stored.Inserted {
+		response.Added++
+	} else {
+		response.Duplicates++
+	}
+	return false
 }
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// importUploadMaxBytes caps the size of an uploaded event dump.
+const importUploadMaxBytes = 4 << 30 // 4 GiB
+
+// errStopImport ends an upload import early because of stop_on_error.
+var errStopImport = errors.New("import stopped on error")
+
+// UploadImport handles POST /api/v1/import/upload
+// Imports an event dump, such as a .jsonl export from another relay or a
+// client, as the first load of a new relay. Unlike /api/v1/events/import,
+// the upload is written to disk and read as a stream, so dumps larger than
+// memory can be imported. It responds 202 with the import job, whose
+// progress is the share of the file read.
+func (h *Handler) UploadImport(w http.ResponseWriter, r *http.Request) {
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+	if !h.jobsAvailable(w) {
+		return
+	}
+	if h.services.Pressure != nil && h.services.Pressure.IngestPaused() {
+		respondError(w, http.StatusInsufficientStorage, services.ErrStoragePressure.Error(), "STORAGE_PRESSURE")
+		return
+	}
+
+	// Large dumps take longer to upload than the server's read timeout
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+	r.Body = http.MaxBytesReader(w, r.Body, importUploadMaxBytes)
+	upload, err := h.stageImportUpload(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			respondError(w, http.StatusRequestEntityTooLarge, "Upload is larger than 4 GiB", "UPLOAD_TOO_LARGE")
+		case errors.Is(err, errMissingUpload):
+			respondError(w, http.StatusBadRequest, "No file provided", "MISSING_FILE")
+		case errors.Is(err, http.ErrNotMultipart):
+			respondError(w, http.StatusBadRequest, "Failed to parse form data", "INVALID_FORM")
+		default:
+			slog.ErrorContext(r.Context(), "Failed to receive import upload", "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to receive upload", "UPLOAD_FAILED")
+		}
+		return
+	}
+
+	options := ImportEventsRequest{
+		VerifySignatures: upload.verifySignatures,
+		SkipDuplicates:   true,
+		StopOnError:      upload.stopOnError,
+	}
+	params := map[string]interface{}{
+		"filename": upload.filename,
+		"bytes":    upload.size,
+		"options":  options,
+	}
+	job, err := h.services.Jobs.Run(services.JobTypeImport, params, func(ctx context.Context, run *services.JobRun) (interface{}, error) {
+		defer os.Remove(upload.path)
+		return h.importUpload(ctx, run, upload, options)
+	})
+	if err != nil {
+		os.Remove(upload.path)
+	}
+	if !jobStarted(w, err) {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Importing uploaded events", "filename", upload.filename, "bytes", upload.size, "job_id", job.ID)
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/jobs/%d", job.ID))
+	respondJSON(w, http.StatusAccepted, job)
+}
+
+// errMissingUpload is returned when the form has no file part.
+var errMissingUpload = errors.New("no file provided")
+
+// importUpload is an uploaded dump staged on disk.
+type importUpload struct {
+	path        string
+	filename    string
+	size        int64
+	stopOnError bool
+
+	// verifySignatures is false for dumps from a trusted source, which are
+	// stored without checking event IDs and signatures.
+	verifySignatures bool
+}
+
+// stageImportUpload streams the form's file part to a temporary file next
+// to the app database, rather than holding it in memory or in /tmp, which
+// may be small.
+func (h *Handler) stageImportUpload(r *http.Request) (*importUpload, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	upload := &importUpload{
+		stopOnError:      query.Get("stop_on_error") == "true",
+		verifySignatures: query.Get("verify_signatures") != "false",
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			upload.remove()
+			return nil, err
+		}
+
+		switch part.FormName() {
+		case "stop_on_error":
+			value, _ := io.ReadAll(io.LimitReader(part, 16))
+			upload.stopOnError = strings.TrimSpace(string(value)) == "true"
+		case "verify_signatures":
+			value, _ := io.ReadAll(io.LimitReader(part, 16))
+			upload.verifySignatures = strings.TrimSpace(string(value)) != "false"
+		case "file":
+			if upload.path != "" {
+				continue
+			}
+			f, err := os.CreateTemp(filepath.Dir(h.currentConfig().AppDBPath), ".import-*")
+			if err != nil {
+				return nil, err
+			}
+			upload.path = f.Name()
+			upload.filename = part.FileName()
+			upload.size, err = io.Copy(f, part)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				upload.remove()
+				return nil, err
+			}
+		}
+		part.Close()
+	}
+
+	if upload.path == "" {
+		return nil, errMissingUpload
+	}
+	return upload, nil
+}
+
+func (u *importUpload) remove() {
+	if u.path != "" {
+		os.Remove(u.path)
+	}
+}
+
+// importUpload imports the staged dump, reporting how much of it has been
+// read. The counts so far are returned even if the dump turns out to be
+// malformed part way through.
+func (h *Handler) importUpload(ctx context.Context, run *services.JobRun, upload *importUpload, options ImportEventsRequest) (interface{}, error) {
+	f, err := os.Open(upload.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	writer, err := h.db.NewRelayWriter()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database for writing: %w", err)
+	}
+	defer writer.Close()
+
+	read := &countingReader{r: f}
+	src, err := maybeGunzip(read)
+	if err != nil {
+		return nil, err
+	}
+
+	response := ImportEventsResponse{ErrorList: make([]string, 0)}
+	run.Progress(0, upload.size, "")
+	err = decodeEventStream(src, func(event *nostr.SyncEvent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		response.Total++
+		if h.importEvent(ctx, writer, response.Total, event, options, &response) {
+			return errStopImport
+		}
+		if response.Total%importEventsProgressEvery == 0 {
+			run.Progress(read.n, upload.size, fmt.Sprintf("%d events processed", response.Total))
+		}
+		return nil
+	})
+	if errors.Is(err, errStopImport) {
+		err = nil
+	}
+	if err != nil && ctx.Err() == nil {
+		err = fmt.Errorf("after %d events: %w", response.Total, err)
+	}
+	run.Progress(read.n, upload.size, fmt.Sprintf("%d events processed", response.Total))
+
+	slog.Info("Upload import complete",
+		"filename", upload.filename, "total", response.Total, "added", response.Added, "duplicates", response.Duplicates,
+		"excluded", response.Excluded, "tombstoned", response.Tombstoned, "errors", response.Errors)
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return response, err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// maybeGunzip returns a reader that decompresses r if it is gzipped.
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// decodeEventStream reads an event dump from r, calling fn for each event
+// until fn returns an error. The dump is NDJSON or a JSON array, and each
+// item is an event or a relay message such as ["EVENT", <sub>, <event>] as
+// written by nostr-tools and nak; other messages (EOSE, NOTICE) are skipped.
+func decodeEventStream(r io.Reader, fn func(*nostr.SyncEvent) error) error {
+	br := bufio.NewReader(r)
+	handle := func(raw json.RawMessage) error {
+		event, err := decodeDumpItem(raw)
+		if err != nil || event == nil {
+			return err
+		}
+		return fn(event)
+	}
+
+	first, err := firstNonSpace(br)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(br)
+	if first != '[' {
+		return decodeValues(dec, handle)
+	}
+
+	// A JSON array of items, or NDJSON of relay messages if the first
+	// array is itself a message
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	var message []json.RawMessage
+	for i := 0; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		var label string
+		if message != nil || (i == 0 && json.Unmarshal(raw, &label) == nil) {
+			message = append(message, raw)
+			continue
+		}
+		if err := handle(raw); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if message != nil {
+		event, err := eventFromMessage(message)
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+	}
+	return decodeValues(dec, handle)
+}
+
+// firstNonSpace returns the first byte of br that is not whitespace,
+// leaving it unread.
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// decodeValues decodes the remaining top-level values in dec.
+func decodeValues(dec *json.Decoder, handle func(json.RawMessage) error) error {
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handle(raw); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeDumpItem decodes an event, or the event in an EVENT relay message.
+// It returns nil for other relay messages.
+func decodeDumpItem(raw json.RawMessage) (*nostr.SyncEvent, error) {
+	if len(raw) > 0 && raw[0] == '[' {
+		var msg []json.RawMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return nil, err
+		}
+		return eventFromMessage(msg)
+	}
+	var event nostr.SyncEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// eventFromMessage returns the event in ["EVENT", <sub>, <event>] or
+// ["EVENT", <event>], and nil for other messages.
+func eventFromMessage(msg []json.RawMessage) (*nostr.SyncEvent, error) {
+	var label string
+	if len(msg) < 2 || json.Unmarshal(msg[0], &label) != nil || label != "EVENT" {
+		return nil, nil
+	}
+	var event nostr.SyncEvent
+	if err := json.Unmarshal(msg[len(msg)-1], &event); err != nil {
+		return nil, fmt.Errorf("invalid EVENT message: %w", err)
+	}
+	return &event, nil
+}
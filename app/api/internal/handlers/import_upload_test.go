@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/config"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestDecodeEventStream(t *testing.T) {
+	event := func(id string) string {
+		return `{"id":"` + id + `","pubkey":"aa","created_at":1700000000,"kind":1,"tags":[],"content":"hi","sig":"bb"}`
+	}
+
+	tests := []struct {
+		name string
+		dump string
+		want string
+	}{
+		{"ndjson", event("1") + "\n\n" + event("2") + "\n", "1,2"},
+		{"json array", "  [\n" + event("1") + ",\n" + event("2") + "\n]", "1,2"},
+		{"empty array", "[]", ""},
+		{"empty", " \n", ""},
+		{"relay messages", `["EVENT","sub",` + event("1") + "]\n" + `["EOSE","sub"]` + "\n" + `["EVENT",` + event("2") + "]\n", "1,2"},
+		{"array of relay messages", `[["EVENT","sub",` + event("1") + `],["NOTICE","slow down"],` + event("2") + "]", "1,2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			err := decodeEventStream(strings.NewReader(tt.dump), func(e *nostr.SyncEvent) error {
+				ids = append(ids, e.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("decodeEventStream failed: %v", err)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("expected events %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	// Events before malformed input are still delivered
+	var n int
+	err := decodeEventStream(strings.NewReader(event("1")+"\n{not json}\n"), func(*nostr.SyncEvent) error {
+		n++
+		return nil
+	})
+	if err == nil || n != 1 {
+		t.Errorf("expected an error after 1 event, got %d events and %v", n, err)
+	}
+}
+
+func TestMaybeGunzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"id":"1"}`))
+	zw.Close()
+
+	for _, input := range [][]byte{buf.Bytes(), []byte(`{"id":"1"}`)} {
+		r, err := maybeGunzip(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("maybeGunzip failed: %v", err)
+		}
+		if data, _ := io.ReadAll(r); string(data) != `{"id":"1"}` {
+			t.Errorf("unexpected data %q", data)
+		}
+	}
+}
+
+func TestStageImportUpload(t *testing.T) {
+	dir := t.TempDir()
+	h := &Handler{}
+	h.SetConfig(&config.Config{AppDBPath: filepath.Join(dir, "roostr.db")})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "events.jsonl")
+	fw.Write([]byte("{}\n{}\n"))
+	mw.WriteField("stop_on_error", "true")
+	mw.WriteField("verify_signatures", "false")
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/api/v1/import/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	upload, err := h.stageImportUpload(r)
+	if err != nil {
+		t.Fatalf("stageImportUpload failed: %v", err)
+	}
+	defer upload.remove()
+
+	if upload.filename != "events.jsonl" || upload.size != 6 || !upload.stopOnError || upload.verifySignatures {
+		t.Errorf("unexpected upload: %+v", upload)
+	}
+	if filepath.Dir(upload.path) != dir {
+		t.Errorf("expected the upload next to the app database, got %s", upload.path)
+	}
+	if data, _ := os.ReadFile(upload.path); string(data) != "{}\n{}\n" {
+		t.Errorf("unexpected staged data %q", data)
+	}
+
+	// A form without a file is refused
+	body.Reset()
+	mw = multipart.NewWriter(&body)
+	mw.WriteField("stop_on_error", "true")
+	mw.Close()
+	r = httptest.NewRequest("POST", "/api/v1/import/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if _, err := h.stageImportUpload(r); err != errMissingUpload {
+		t.Errorf("expected errMissingUpload, got %v", err)
+	}
+}
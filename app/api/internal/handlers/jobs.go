@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// StartJobRequest is the request body for starting a job.
+type StartJobRequest struct {
+	Type   string          `json:"type"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// ListJobs returns jobs, newest first.
+// GET /api/v1/jobs?type=vacuum&status=running&limit=20&offset=0
+func (h *Handler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsAvailable(w) {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := db.JobFilter{
+		Type:   query.Get("type"),
+		Status: query.Get("status"),
+		Limit:  20,
+	}
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		filter.Limit = l
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o > 0 {
+		filter.Offset = o
+	}
+
+	jobs, total, err := h.services.Jobs.List(r.Context(), filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get jobs", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"jobs":   jobs,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// StartJob starts a vacuum, integrity_check, cleanup, retention, sync,
+// migration, broadcast or verify_events job. Params are the request body of the operation's own endpoint. Imports and
+// exports carry a file, so they are started from their own endpoints.
+// POST /api/v1/jobs
+func (h *Handler) StartJob(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsAvailable(w) {
+		return
+	}
+
+	var req StartJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	decodeParams := func(v interface{}) bool {
+		if len(req.Params) == 0 {
+			return true
+		}
+		if err := json.Unmarshal(req.Params, v); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid job params", "INVALID_PARAMS")
+			return false
+		}
+		return true
+	}
+
+	ctx := r.Context()
+
+	var job *db.Job
+	var err error
+	switch req.Type {
+	case services.JobTypeVacuum:
+		if !h.storageMaintenanceAvailable(w) {
+			return
+		}
+		job, err = h.services.Jobs.Run(req.Type, nil, h.vacuumJob)
+	case services.JobTypeIntegrityCheck:
+		if !h.storageMaintenanceAvailable(w) {
+			return
+		}
+		job, err = h.services.Jobs.Run(req.Type, nil, h.integrityCheckJob)
+	case services.JobTypeRetention:
+		if h.services.Retention == nil {
+			respondError(w, http.StatusServiceUnavailable, "Retention service not available", "SERVICE_UNAVAILABLE")
+			return
+		}
+		job, err = h.services.Jobs.Run(req.Type, nil, h.retentionJob)
+	case services.JobTypeVerifyEvents:
+		if !h.db.IsRelayDBConnected() {
+			respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+			return
+		}
+		job, err = h.services.Jobs.Run(req.Type, nil, h.verifyEventsJob)
+	case services.JobTypeCleanup:
+		var params CleanupRequest
+		if !decodeParams(&params) {
+			return
+		}
+		cleanup, status, code, msg := h.prepareCleanup(ctx, params)
+		if code != "" {
+			respondError(w, status, msg, code)
+			return
+		}
+		job, err = h.services.Jobs.Run(req.Type, params, cleanup.run)
+	case services.JobTypeSync:
+		var params services.SyncRequest
+		if !decodeParams(&params) {
+			return
+		}
+		if len(params.Pubkeys) == 0 {
+			respondError(w, http.StatusBadRequest, "At least one pubkey is required", "MISSING_PUBKEYS")
+			return
+		}
+		job, err = h.services.Sync.StartSyncJob(ctx, params)
+		if err != nil && err.Error() == "a sync job is already running" {
+			err = services.ErrJobAlreadyRunning
+		}
+	case services.JobTypeMigration:
+		if h.services.Migration == nil {
+			respondError(w, http.StatusServiceUnavailable, "Migration service not available", "SERVICE_UNAVAILABLE")
+			return
+		}
+		var params services.MigrationRequest
+		if !decodeParams(&params) {
+			return
+		}
+		started, ok := h.startMigration(w, r, params)
+		if !ok {
+			return
+		}
+		job = started
+	case services.JobTypeBroadcast:
+		if !h.broadcastAvailable(w) {
+			return
+		}
+		var params services.BroadcastRequest
+		if !decodeParams(&params) {
+			return
+		}
+		_, started, ok := h.startBroadcast(w, r, params)
+		if !ok {
+			return
+		}
+		job = started
+	case services.JobTypeImport:
+		respondError(w, http.StatusBadRequest, "Upload the file to POST /api/v1/events/import with async=true", "UNSUPPORTED_JOB_TYPE")
+		return
+	case services.JobTypeExport:
+		respondError(w, http.StatusBadRequest, "Exports run while downloading from GET /api/v1/events/export", "UNSUPPORTED_JOB_TYPE")
+		return
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Unknown job type %q", req.Type), "INVALID_JOB_TYPE")
+		return
+	}
+
+	if !jobStarted(w, err) {
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/jobs/%d", job.ID))
+	respondJSON(w, http.StatusAccepted, job)
+}
+
+// GetJob returns a job with its progress and, once finished, its result.
+// GET /api/v1/jobs/{id}
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsAvailable(w) {
+		return
+	}
+	job, ok := h.jobFromPath(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, job)
+}
+
+// CancelJob asks a running job to stop.
+// POST /api/v1/jobs/{id}/cancel
+func (h *Handler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsAvailable(w) {
+		return
+	}
+	job, ok := h.jobFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Jobs.Cancel(job.ID); err != nil {
+		respondError(w, http.StatusConflict, "Job is not running", "JOB_NOT_RUNNING")
+		return
+	}
+
+	h.db.AddAuditLog(r.Context(), "job_cancelled", map[string]interface{}{
+		"job_id": job.ID,
+		"type":   job.Type,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Cancellation requested",
+	})
+}
+
+// StreamJob streams a job's progress via Server-Sent Events. Each update is
+// a "job" event with the job as JSON; the stream ends with a "done" event
+// once the job has finished.
+// GET /api/v1/jobs/{id}/stream
+func (h *Handler) StreamJob(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsAvailable(w) {
+		return
+	}
+	job, ok := h.jobFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "Streaming not supported", "STREAMING_NOT_SUPPORTED")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{}) // No deadline
+
+	send := func(event string, job *db.Job) {
+		data, _ := json.Marshal(job)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	updates, unsubscribe, running := h.services.Jobs.Subscribe(job.ID)
+	if !running {
+		// Already finished; the stored state is final
+		if finished, err := h.services.Jobs.Get(r.Context(), job.ID); err == nil && finished != nil {
+			job = finished
+		}
+		send("done", job)
+		return
+	}
+	defer unsubscribe()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	var last db.Job
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				send("done", &last)
+				return
+			}
+			last = update
+			send("job", &update)
+		case <-keepalive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// jobsAvailable responds with 503 if the job service is not running.
+func (h *Handler) jobsAvailable(w http.ResponseWriter) bool {
+	if h.services == nil || h.services.Jobs == nil {
+		respondError(w, http.StatusServiceUnavailable, "Job service not available", "SERVICE_UNAVAILABLE")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) jobFromPath(w http.ResponseWriter, r *http.Request) (*db.Job, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid job ID", "INVALID_ID")
+		return nil, false
+	}
+	job, err := h.services.Jobs.Get(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get job", "DB_ERROR")
+		return nil, false
+	}
+	if job == nil {
+		respondError(w, http.StatusNotFound, "Job not found", "JOB_NOT_FOUND")
+		return nil, false
+	}
+	return job, true
+}
+
+// jobStarted responds with an error if a job could not be started.
+func jobStarted(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, services.ErrJobAlreadyRunning) {
+		respondError(w, http.StatusConflict, "A job of this type is already running", "JOB_ALREADY_RUNNING")
+		return false
+	}
+	if errors.Is(err, services.ErrStoragePressure) {
+		respondError(w, http.StatusInsufficientStorage, err.Error(), "STORAGE_PRESSURE")
+		return false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to start job: "+err.Error(), "JOB_START_FAILED")
+		return false
+	}
+	return true
+}
+
+// runJob runs fn as a job for an operation's own endpoint. With async=true
+// in the query string or form it responds 202 with the job right away; otherwise it
+// waits and responds with the job's result, plus success and job_id, in the
+// endpoint's usual format.
+func (h *Handler) runJob(w http.ResponseWriter, r *http.Request, jobType string, params interface{}, fn services.JobFunc, failMsg, failCode string) {
+	if !h.jobsAvailable(w) {
+		return
+	}
+
+	job, err := h.services.Jobs.Run(jobType, params, fn)
+	if !jobStarted(w, err) {
+		return
+	}
+	h.respondJob(w, r, job, failMsg, failCode)
+}
+
+// respondJob responds for a job started by an operation's own endpoint; see
+// runJob.
+func (h *Handler) respondJob(w http.ResponseWriter, r *http.Request, job *db.Job, failMsg, failCode string) {
+	if r.FormValue("async") == "true" {
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/jobs/%d", job.ID))
+		respondJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	// The job keeps running if the client goes away
+	job, err := h.services.Jobs.Wait(r.Context(), job.ID)
+	if err != nil || job == nil {
+		return
+	}
+	respondJobResult(w, job, failMsg, failCode)
+}
+
+// respondJobResult writes a finished job's result with success and job_id
+// added, or an error if it did not complete.
+func respondJobResult(w http.ResponseWriter, job *db.Job, failMsg, failCode string) {
+	switch job.Status {
+	case db.JobStatusCompleted:
+		body := map[string]interface{}{}
+		json.Unmarshal(job.Result, &body)
+		if _, ok := body["success"]; !ok {
+			body["success"] = true
+		}
+		body["job_id"] = job.ID
+		respondJSON(w, http.StatusOK, body)
+	case db.JobStatusCancelled:
+		respondError(w, http.StatusConflict, "Job was cancelled", "JOB_CANCELLED")
+	default:
+		respondError(w, http.StatusInternalServerError, failMsg+": "+job.Error, failCode)
+	}
+}
+
+// vacuumJob runs VACUUM on both databases.
+func (h *Handler) vacuumJob(ctx context.Context, run *services.JobRun) (interface{}, error) {
+	return h.services.Maintenance.Vacuum(ctx, run, services.MaintenanceTriggerManual)
+}
+
+// integrityCheckJob runs an integrity check on both databases.
+func (h *Handler) integrityCheckJob(ctx context.Context, run *services.JobRun) (interface{}, error) {
+	return h.services.Maintenance.IntegrityCheck(ctx, run, services.MaintenanceTriggerManual)
+}
+
+// retentionJob runs the retention policy now.
+func (h *Handler) retentionJob(ctx context.Context, run *services.JobRun) (interface{}, error) {
+	return h.services.Retention.Run(ctx, run)
+}
+
+// verifyEventsJob verifies the ID and signature of every stored event.
+func (h *Handler) verifyEventsJob(ctx context.Context, run *services.JobRun) (interface{}, error) {
+	return services.VerifyEvents(ctx, h.db, run)
+}
+
+// cleanupJob deletes the events before a date, as prepared by prepareCleanup.
+type cleanupJob struct {
+	h              *Handler
+	req            CleanupRequest
+	beforeDate     time.Time
+	exceptions     []string
+	operatorPubkey string
+}
+
+// prepareCleanup validates a cleanup request and resolves its exceptions. On
+// failure it returns an HTTP status, error code and message.
+func (h *Handler) prepareCleanup(ctx context.Context, req CleanupRequest) (*cleanupJob, int, string, string) {
+	// Parse the before date
+	beforeDate, err := time.Parse(time.RFC3339, req.BeforeDate)
+	if err != nil {
+		return nil, http.StatusBadRequest, "INVALID_DATE", "Invalid date format. Use ISO 8601 (YYYY-MM-DDTHH:MM:SSZ)"
+	}
+
+	// Validate date is in the past
+	if beforeDate.After(time.Now()) {
+		return nil, http.StatusBadRequest, "FUTURE_DATE", "Date must be in the past"
+	}
+
+	job := &cleanupJob{h: h, req: req, beforeDate: beforeDate}
+
+	if req.ApplyExceptions {
+		// Use explicit exceptions if provided, otherwise use retention policy
+		if len(req.Exceptions) > 0 {
+			job.exceptions = req.Exceptions
+		} else {
+			policy, err := h.db.GetRetentionPolicy(ctx)
+			if err != nil {
+				return nil, http.StatusInternalServerError, "RETENTION_GET_FAILED", "Failed to get retention policy"
+			}
+			job.exceptions = policy.Exceptions
+		}
+		// Get operator pubkey for exception handling
+		job.operatorPubkey, _ = h.db.GetOperatorPubkey(ctx)
+	}
+	// If ApplyExceptions is false, exceptions stays nil/empty - delete ALL events
+
+	return job, 0, "", ""
+}
+
+func (c *cleanupJob) run(ctx context.Context, run *services.JobRun) (interface{}, error) {
+	h := c.h
+
+	scope := db.RetentionScope{Before: c.beforeDate, Exceptions: c.exceptions}
+	expected, _, _ := h.db.CountRetentionScope(ctx, scope, c.operatorPubkey)
+	run.Progress(0, expected, "Deleting events")
+
+	// Get size before cleanup
+	sizeBefore, _ := h.db.GetRelayDatabaseSize()
+
+	// Delete in batches so the relay keeps writing during a large cleanup
+	cleaner := services.NewEventCleaner(h.db)
+	deletedCount, err := cleaner.DeleteScope(ctx, scope, c.operatorPubkey, func(deleted int64) {
+		run.Progress(deleted, expected, fmt.Sprintf("Deleted %d of %d events", deleted, expected))
+	})
+	if err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("failed to delete events: %w", err)
+	}
+
+	// Get size after cleanup (before vacuum)
+	sizeAfter, _ := h.db.GetRelayDatabaseSize()
+	spaceFreed := sizeBefore - sizeAfter
+
+	h.db.AddAuditLog(context.Background(), "manual_cleanup", map[string]interface{}{
+		"before_date":      c.req.BeforeDate,
+		"deleted_count":    deletedCount,
+		"space_freed":      spaceFreed,
+		"apply_exceptions": c.req.ApplyExceptions,
+		"exceptions_used":  c.exceptions,
+		"cancelled":        ctx.Err() != nil,
+	}, "")
+	if ctx.Err() != nil {
+		// Keep the count of what was deleted before the cancellation
+		return map[string]interface{}{
+			"deleted_count": deletedCount,
+			"space_freed":   spaceFreed,
+		}, ctx.Err()
+	}
+
+	return map[string]interface{}{
+		"deleted_count": deletedCount,
+		"space_freed":   spaceFreed,
+		"message":       "Cleanup completed. Run VACUUM to fully reclaim disk space.",
+	}, nil
+}
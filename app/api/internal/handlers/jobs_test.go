@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestRespondJobResult(t *testing.T) {
+	t.Run("completed_adds_success_and_job_id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		respondJobResult(w, &db.Job{
+			ID:     7,
+			Status: db.JobStatusCompleted,
+			Result: json.RawMessage(`{"space_reclaimed":42}`),
+		}, "Failed to vacuum", "VACUUM_FAILED")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp["success"] != true || resp["job_id"] != float64(7) || resp["space_reclaimed"] != float64(42) {
+			t.Errorf("unexpected response: %v", resp)
+		}
+	})
+
+	t.Run("keeps_result_success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		respondJobResult(w, &db.Job{
+			ID:     8,
+			Status: db.JobStatusCompleted,
+			Result: json.RawMessage(`{"success":false}`),
+		}, "Failed to check database integrity", "INTEGRITY_CHECK_FAILED")
+
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp["success"] != false {
+			t.Errorf("expected the job's success to be kept, got %v", resp["success"])
+		}
+	})
+
+	t.Run("failed_uses_operation_code", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		respondJobResult(w, &db.Job{ID: 9, Status: db.JobStatusFailed, Error: "disk full"}, "Failed to vacuum", "VACUUM_FAILED")
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp["code"] != "VACUUM_FAILED" {
+			t.Errorf("expected code VACUUM_FAILED, got %v", resp["code"])
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		respondJobResult(w, &db.Job{ID: 10, Status: db.JobStatusCancelled}, "Failed to vacuum", "VACUUM_FAILED")
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d", w.Code)
+		}
+	})
+}
+
+func TestJobsUnavailable(t *testing.T) {
+	h := &Handler{}
+	w := httptest.NewRecorder()
+	h.ListJobs(w, httptest.NewRequest("GET", "/api/v1/jobs", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+}
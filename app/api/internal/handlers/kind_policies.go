@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// KindPolicyTier is an access tier a kind policy can apply to.
+type KindPolicyTier struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// GetKindPolicies returns the per-tier kind policies and the tiers they can
+// apply to.
+// GET /api/v1/access/policies
+func (h *Handler) GetKindPolicies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	policies, err := h.db.GetKindPolicies(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get kind policies", "DB_ERROR")
+		return
+	}
+	pricing, err := h.db.GetPricingTiers(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get pricing tiers", "DB_ERROR")
+		return
+	}
+
+	tiers := []KindPolicyTier{{ID: db.KindPolicyTierWhitelist, Name: "Whitelist"}}
+	for _, t := range pricing {
+		tiers = append(tiers, KindPolicyTier{ID: t.ID, Name: t.Name})
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"policies": policies,
+		"tiers":    tiers,
+	})
+}
+
+// UpdateKindPolicy sets the kinds the members of a tier may post. Events
+// stored from then on that the policy does not permit are deleted.
+// PUT /api/v1/access/policies/{tier}
+func (h *Handler) UpdateKindPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		AllowKinds []int `json:"allow_kinds"`
+		DenyKinds  []int `json:"deny_kinds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	policy := db.KindPolicy{
+		Tier:       r.PathValue("tier"),
+		AllowKinds: req.AllowKinds,
+		DenyKinds:  req.DenyKinds,
+	}
+	if policy.AllowKinds == nil {
+		policy.AllowKinds = []int{}
+	}
+	if policy.DenyKinds == nil {
+		policy.DenyKinds = []int{}
+	}
+
+	pricing, err := h.db.GetPricingTiers(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get pricing tiers", "DB_ERROR")
+		return
+	}
+	if err := services.ValidateKindPolicy(&policy, pricing); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_POLICY")
+		return
+	}
+
+	policies, err := h.db.GetKindPolicies(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get kind policies", "DB_ERROR")
+		return
+	}
+	replaced := false
+	for i, p := range policies {
+		if p.Tier == policy.Tier {
+			policies[i] = policy
+			replaced = true
+		}
+	}
+	if !replaced {
+		policies = append(policies, policy)
+	}
+	if err := h.db.SetKindPolicies(ctx, policies); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save kind policies", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "kind_policy_updated", policy, "")
+	h.wakeKindPolicies()
+
+	respondJSON(w, http.StatusOK, policy)
+}
+
+// DeleteKindPolicy removes a tier's kind policy, letting its members post
+// every kind again.
+// DELETE /api/v1/access/policies/{tier}
+func (h *Handler) DeleteKindPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tier := r.PathValue("tier")
+
+	policies, err := h.db.GetKindPolicies(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get kind policies", "DB_ERROR")
+		return
+	}
+	kept := policies[:0]
+	for _, p := range policies {
+		if p.Tier != tier {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(policies) {
+		respondError(w, http.StatusNotFound, "Kind policy not found", "NOT_FOUND")
+		return
+	}
+	if err := h.db.SetKindPolicies(ctx, kept); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save kind policies", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "kind_policy_removed", map[string]interface{}{
+		"tier": tier,
+	}, "")
+	h.wakeKindPolicies()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Kind policy removed",
+	})
+}
+
+// wakeKindPolicies asks the kind policy service to check new events now
+// and the admission server to apply the policies to the next event.
+func (h *Handler) wakeKindPolicies() {
+	if h.services == nil {
+		return
+	}
+	if h.services.KindPolicies != nil {
+		h.services.KindPolicies.Wake()
+	}
+	if h.services.Admission != nil {
+		h.services.Admission.Invalidate()
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// CategoryCount is the event count of one kind category.
+type CategoryCount struct {
+	Category string  `json:"category"`
+	Name     string  `json:"name"`
+	Count    int64   `json:"count"`
+	Percent  float64 `json:"percent"`
+}
+
+// LabeledEvent is an event with the name and category of its kind.
+type LabeledEvent struct {
+	db.Event
+	KindName     string `json:"kind_name"`
+	KindCategory string `json:"kind_category"`
+}
+
+// GetKinds returns the kinds catalog and the categories kinds roll up into.
+// GET /api/v1/kinds
+func (h *Handler) GetKinds(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"kinds":      nostr.KindCatalog(),
+		"categories": nostr.KindCategories,
+	})
+}
+
+// categoryCounts rolls kind counts up into categories, largest first,
+// leaving out categories without events.
+func categoryCounts(counts map[int]int64) []CategoryCount {
+	byCategory := make(map[string]int64)
+	var total int64
+	for kind, count := range counts {
+		byCategory[nostr.LookupKind(kind).Category] += count
+		total += count
+	}
+
+	categories := []CategoryCount{}
+	for _, c := range nostr.KindCategories {
+		count := byCategory[c.ID]
+		if count == 0 {
+			continue
+		}
+		percent := 0.0
+		if total > 0 {
+			percent = float64(count) / float64(total) * 100
+		}
+		categories = append(categories, CategoryCount{Category: c.ID, Name: c.Name, Count: count, Percent: percent})
+	}
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Count > categories[j].Count })
+	return categories
+}
+
+// labelEvents adds the kind name and category to each event.
+func labelEvents(events []db.Event) []LabeledEvent {
+	labeled := make([]LabeledEvent, len(events))
+	for i, e := range events {
+		info := nostr.LookupKind(e.Kind)
+		labeled[i] = LabeledEvent{Event: e, KindName: info.Name, KindCategory: info.Category}
+	}
+	return labeled
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/roostr/roostr/app/api/internal/secrets"
 	"github.com/roostr/roostr/app/api/internal/services"
 )
 
@@ -16,6 +17,17 @@ func (h *Handler) GetLightningStatus(w http.ResponseWriter, r *http.Request) {
 
 	// Load config from database if not already loaded
 	if err := h.services.Lightning.LoadConfig(ctx); err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"configured": true,
+				"enabled":    false,
+				"connected":  false,
+				"locked":     true,
+				"error":      "Stored secrets are locked",
+				"error_code": "SECRETS_LOCKED",
+			})
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to load Lightning config", "CONFIG_LOAD_FAILED")
 		return
 	}
@@ -37,7 +49,7 @@ func (h *Handler) GetLightningStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	enabled := dbCfg != nil && dbCfg.Enabled
+	enabled := (dbCfg != nil && dbCfg.Enabled) || h.services.Lightning.IsForcedMock()
 
 	// Try to get node info
 	info, err := h.services.Lightning.GetInfo(ctx)
@@ -73,6 +85,14 @@ func (h *Handler) GetLightningStatus(w http.ResponseWriter, r *http.Request) {
 		"node_info":  info,
 	}
 
+	if cfg := h.services.Lightning.GetConfig(); cfg != nil && cfg.NodeType == services.NodeTypeMock {
+		response["mock"] = services.ParseMockLightningOptions(cfg.Host)
+	}
+
+	if h.services.InvoiceMonitor != nil {
+		response["invoice_detection"] = h.services.InvoiceMonitor.Mode()
+	}
+
 	if balance != nil {
 		response["balance"] = balance
 	}
@@ -80,16 +100,20 @@ func (h *Handler) GetLightningStatus(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
-// SaveLightningConfig saves the Lightning node configuration.
+// SaveLightningConfig saves the Lightning node configuration. An LND
+// macaroon that can do more than handle invoices is refused unless
+// allow_excessive_permissions is set.
 // PUT /api/v1/lightning/config
 func (h *Handler) SaveLightningConfig(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var req struct {
-		Host        string `json:"host"`
-		MacaroonHex string `json:"macaroon_hex"`
-		TLSCertPath string `json:"tls_cert_path,omitempty"`
-		Enabled     bool   `json:"enabled"`
+		NodeType                  string `json:"node_type,omitempty"`
+		Host                      string `json:"host"`
+		MacaroonHex               string `json:"macaroon_hex"`
+		TLSCertPath               string `json:"tls_cert_path,omitempty"`
+		Enabled                   bool   `json:"enabled"`
+		AllowExcessivePermissions bool   `json:"allow_excessive_permissions"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -97,22 +121,37 @@ func (h *Handler) SaveLightningConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Host == "" {
-		respondError(w, http.StatusBadRequest, "Host is required", "MISSING_HOST")
+	if !isSupportedNodeType(req.NodeType) {
+		respondError(w, http.StatusBadRequest, "Node type must be 'lnd', 'cln', 'lnbits', 'nwc' or 'mock'", "INVALID_NODE_TYPE")
 		return
 	}
 
-	if req.MacaroonHex == "" {
-		respondError(w, http.StatusBadRequest, "Macaroon is required", "MISSING_MACAROON")
+	if req.MacaroonHex == "" && req.NodeType != services.NodeTypeMock {
+		respondError(w, http.StatusBadRequest, credentialName(req.NodeType)+" is required", "MISSING_MACAROON")
 		return
 	}
 
-	// Normalize host - strip protocol prefix if present
-	host := strings.TrimPrefix(req.Host, "https://")
-	host = strings.TrimPrefix(host, "http://")
-	host = strings.TrimSuffix(host, "/")
+	host, err := normalizeLightningHost(req.NodeType, req.Host, req.MacaroonHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_NWC_URI")
+		return
+	}
+
+	if host == "" {
+		respondError(w, http.StatusBadRequest, "Host is required", "MISSING_HOST")
+		return
+	}
+
+	var permissions *services.MacaroonCheck
+	if isLNDNodeType(req.NodeType) {
+		var ok bool
+		if permissions, ok = checkMacaroonPermissions(w, req.MacaroonHex, req.AllowExcessivePermissions); !ok {
+			return
+		}
+	}
 
 	cfg := &services.LNDConfig{
+		NodeType:    req.NodeType,
 		Host:        host,
 		MacaroonHex: req.MacaroonHex,
 		TLSCertPath: req.TLSCertPath,
@@ -120,22 +159,97 @@ func (h *Handler) SaveLightningConfig(w http.ResponseWriter, r *http.Request) {
 
 	// Save to database and update service
 	if err := h.services.Lightning.SaveConfig(ctx, cfg, req.Enabled); err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to save config: "+err.Error(), "SAVE_FAILED")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	if permissions != nil && len(permissions.Excessive) > 0 {
+		h.db.AddAuditLog(ctx, "lightning_excessive_permissions_allowed", map[string]interface{}{
+			"excessive": permissions.Excessive,
+		}, "")
+	}
+
+	response := map[string]interface{}{
 		"success": true,
 		"message": "Lightning configuration saved",
+	}
+	if permissions != nil {
+		response["permissions"] = permissions
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ValidateLightningPermissions reports the permissions an LND macaroon
+// grants, which of them go beyond handling invoices and what is missing to
+// take payments. Other node types are not checked.
+// POST /api/v1/lightning/validate-permissions
+func (h *Handler) ValidateLightningPermissions(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		NodeType    string `json:"node_type,omitempty"`
+		MacaroonHex string `json:"macaroon_hex"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if !isLNDNodeType(req.NodeType) {
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"checked": false,
+			"message": "Permissions can only be checked for LND macaroons",
+		})
+		return
+	}
+
+	check, err := services.CheckLNDMacaroon(req.MacaroonHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_MACAROON")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"checked":      true,
+		"permissions":  check.Permissions,
+		"excessive":    check.Excessive,
+		"missing":      check.Missing,
+		"warnings":     check.Warnings,
+		"invoice_only": check.InvoiceOnly,
 	})
 }
 
+// checkMacaroonPermissions checks an LND macaroon before it is saved,
+// writing an error response when it cannot take payments or grants more than
+// invoice handling without allowExcessive.
+func checkMacaroonPermissions(w http.ResponseWriter, macaroonHex string, allowExcessive bool) (*services.MacaroonCheck, bool) {
+	check, err := services.CheckLNDMacaroon(macaroonHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_MACAROON")
+		return nil, false
+	}
+	if len(check.Missing) > 0 {
+		respondErrorWithDetails(w, http.StatusBadRequest,
+			"Macaroon cannot "+strings.Join(check.Missing, " or "), "INSUFFICIENT_PERMISSIONS", check)
+		return nil, false
+	}
+	if len(check.Excessive) > 0 && !allowExcessive {
+		respondErrorWithDetails(w, http.StatusBadRequest,
+			"Macaroon grants more than invoice access; use invoice.macaroon instead", "EXCESSIVE_PERMISSIONS", check)
+		return nil, false
+	}
+	return check, true
+}
+
 // TestLightningConnection tests a Lightning node connection with provided config.
 // POST /api/v1/lightning/test
 func (h *Handler) TestLightningConnection(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var req struct {
+		NodeType    string `json:"node_type,omitempty"`
 		Host        string `json:"host"`
 		MacaroonHex string `json:"macaroon_hex"`
 		TLSCertPath string `json:"tls_cert_path,omitempty"`
@@ -146,22 +260,29 @@ func (h *Handler) TestLightningConnection(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if req.Host == "" {
-		respondError(w, http.StatusBadRequest, "Host is required", "MISSING_HOST")
+	if !isSupportedNodeType(req.NodeType) {
+		respondError(w, http.StatusBadRequest, "Node type must be 'lnd', 'cln', 'lnbits', 'nwc' or 'mock'", "INVALID_NODE_TYPE")
 		return
 	}
 
-	if req.MacaroonHex == "" {
-		respondError(w, http.StatusBadRequest, "Macaroon is required", "MISSING_MACAROON")
+	if req.MacaroonHex == "" && req.NodeType != services.NodeTypeMock {
+		respondError(w, http.StatusBadRequest, credentialName(req.NodeType)+" is required", "MISSING_MACAROON")
 		return
 	}
 
-	// Normalize host - strip protocol prefix if present
-	host := strings.TrimPrefix(req.Host, "https://")
-	host = strings.TrimPrefix(host, "http://")
-	host = strings.TrimSuffix(host, "/")
+	host, err := normalizeLightningHost(req.NodeType, req.Host, req.MacaroonHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_NWC_URI")
+		return
+	}
+
+	if host == "" {
+		respondError(w, http.StatusBadRequest, "Host is required", "MISSING_HOST")
+		return
+	}
 
 	cfg := &services.LNDConfig{
+		NodeType:    req.NodeType,
 		Host:        host,
 		MacaroonHex: req.MacaroonHex,
 		TLSCertPath: req.TLSCertPath,
@@ -177,10 +298,10 @@ func (h *Handler) TestLightningConnection(w http.ResponseWriter, r *http.Request
 
 		if errors.Is(err, services.ErrLNDAuthFailed) {
 			response["error_code"] = "AUTH_FAILED"
-			response["message"] = "Authentication failed. Please check your macaroon."
+			response["message"] = "Authentication failed. Please check your macaroon, rune, API key or wallet connection."
 		} else if errors.Is(err, services.ErrLNDConnectionFailed) {
 			response["error_code"] = "CONNECTION_FAILED"
-			response["message"] = "Could not connect to the LND node. Please check the host address."
+			response["message"] = "Could not connect to the Lightning node. Please check the host address."
 		} else {
 			response["error_code"] = "UNKNOWN"
 		}
@@ -192,7 +313,118 @@ func (h *Handler) TestLightningConnection(w http.ResponseWriter, r *http.Request
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success":   true,
 		"node_info": info,
-		"message":   "Successfully connected to LND node",
+		"message":   "Successfully connected to Lightning node",
 	})
 }
 
+// isSupportedNodeType reports whether the node type has a Lightning backend.
+// An empty node type defaults to LND.
+func isSupportedNodeType(nodeType string) bool {
+	switch nodeType {
+	case "", services.NodeTypeLND, services.NodeTypeCLN, services.NodeTypeLNbits, services.NodeTypeNWC, services.NodeTypeMock:
+		return true
+	}
+	return false
+}
+
+// isLNDNodeType reports whether a node type is LND, the default.
+func isLNDNodeType(nodeType string) bool {
+	return nodeType == "" || nodeType == services.NodeTypeLND
+}
+
+// credentialName returns the user-facing name of the credential stored in
+// macaroon_hex for the given node type.
+func credentialName(nodeType string) string {
+	switch nodeType {
+	case services.NodeTypeCLN:
+		return "Rune"
+	case services.NodeTypeLNbits:
+		return "API key"
+	case services.NodeTypeNWC:
+		return "Connection string"
+	}
+	return "Macaroon"
+}
+
+// normalizeLightningHost returns the host to store for the node type.
+// LND and CLN hosts have any protocol prefix stripped, LNbits keeps its base
+// URL, NWC takes the relay from the connection string, and the mock backend
+// stores its options.
+func normalizeLightningHost(nodeType, host, credential string) (string, error) {
+	switch nodeType {
+	case services.NodeTypeMock:
+		return services.ParseMockLightningOptions(host).Host(), nil
+	case services.NodeTypeLNbits:
+		return strings.TrimSuffix(host, "/"), nil
+	case services.NodeTypeNWC:
+		conn, err := services.ParseNWCURI(credential)
+		if err != nil {
+			return "", err
+		}
+		return conn.Relay, nil
+	}
+
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	return strings.TrimSuffix(host, "/"), nil
+}
+
+
+// UpdateMockLightning changes the mock backend's settle delay and failure toggles.
+// PUT /api/v1/lightning/mock
+func (h *Handler) UpdateMockLightning(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var opts services.MockLightningOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if err := h.services.Lightning.LoadConfig(ctx); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load Lightning config", "CONFIG_LOAD_FAILED")
+		return
+	}
+
+	if err := h.services.Lightning.SetMockOptions(ctx, opts); err != nil {
+		if errors.Is(err, services.ErrUnsupportedNodeType) {
+			respondError(w, http.StatusConflict, "Lightning node is not the mock backend", "NOT_MOCK_BACKEND")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to save config: "+err.Error(), "SAVE_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"mock":    opts,
+	})
+}
+
+// SettleMockInvoice pays a mock invoice immediately.
+// POST /api/v1/lightning/mock/invoices/{payment_hash}/settle
+func (h *Handler) SettleMockInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := h.services.Lightning.LoadConfig(ctx); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load Lightning config", "CONFIG_LOAD_FAILED")
+		return
+	}
+
+	cfg := h.services.Lightning.GetConfig()
+	if cfg == nil || cfg.NodeType != services.NodeTypeMock {
+		respondError(w, http.StatusConflict, "Lightning node is not the mock backend", "NOT_MOCK_BACKEND")
+		return
+	}
+
+	paymentHash := r.PathValue("payment_hash")
+	if !h.services.Lightning.SettleMockInvoice(paymentHash) {
+		respondError(w, http.StatusNotFound, "Invoice not found, expired or already settled", "NOT_FOUND")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"payment_hash": paymentHash,
+	})
+}
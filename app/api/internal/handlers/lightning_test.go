@@ -902,3 +902,19 @@ func TestSIGNUP_API_003_GetInvoiceStatus(t *testing.T) {
 		}
 	})
 }
+
+func TestNormalizeLightningHost_Mock(t *testing.T) {
+	if !isSupportedNodeType(services.NodeTypeMock) {
+		t.Fatal("expected mock node type to be supported")
+	}
+
+	host, err := normalizeLightningHost(services.NodeTypeMock, "", "")
+	if err != nil || host != "settle_after=5" {
+		t.Errorf("expected default mock options, got %q (%v)", host, err)
+	}
+
+	host, _ = normalizeLightningHost(services.NodeTypeMock, "fail_check=1&settle_after=-1&bogus=x", "")
+	if host != "fail_check=true&settle_after=-1" {
+		t.Errorf("expected normalized mock options, got %q", host)
+	}
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Operational states advertised on /public/status.
+const (
+	relayStateOK          = "ok"
+	relayStateMaintenance = "maintenance"
+	relayStateReadOnly    = "read-only"
+)
+
+// maxPublicMaintenanceWindows caps the upcoming windows listed publicly.
+const maxPublicMaintenanceWindows = 5
+
+// maxMaintenanceMessageLength caps status and window messages, in bytes.
+const maxMaintenanceMessageLength = 500
+
+// RelayStatus is the operational state shown to relay users.
+type RelayStatus struct {
+	State    string                 `json:"state"` // ok, maintenance, read-only
+	Message  string                 `json:"message,omitempty"`
+	Since    *time.Time             `json:"since,omitempty"`
+	Until    *time.Time             `json:"until,omitempty"` // End of the active window
+	Upcoming []db.MaintenanceWindow `json:"upcoming"`
+}
+
+// GetPublicStatus returns the relay's operational state and upcoming
+// maintenance windows. The state is "ok" unless the operator set one, or a
+// scheduled window is in progress; an operator-set state wins.
+// GET /public/status
+func (h *Handler) GetPublicStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	override, err := h.db.GetRelayStatusOverride(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get relay status", "DB_ERROR")
+		return
+	}
+	now := time.Now()
+	windows, err := h.db.GetMaintenanceWindows(ctx, now)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get maintenance windows", "DB_ERROR")
+		return
+	}
+
+	status := resolveRelayStatus(override, windows, now)
+
+	response := map[string]interface{}{
+		"state":    status.State,
+		"message":  status.Message,
+		"since":    status.Since,
+		"until":    status.Until,
+		"upcoming": publicMaintenanceWindows(status.Upcoming),
+	}
+	if h.configMgr != nil {
+		if cfg, _ := h.configMgr.Read(); cfg != nil {
+			response["relay_name"] = cfg.Info.Name
+		}
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetMaintenance returns the operator-set state, the resolved public status
+// and all windows that have not ended.
+// GET /api/v1/maintenance
+func (h *Handler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	override, err := h.db.GetRelayStatusOverride(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get relay status", "DB_ERROR")
+		return
+	}
+	now := time.Now()
+	windows, err := h.db.GetMaintenanceWindows(ctx, now)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get maintenance windows", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"override": override,
+		"status":   resolveRelayStatus(override, windows, now),
+		"windows":  windows,
+	})
+}
+
+// UpdateRelayStatusRequest is the request body for setting the relay state.
+type UpdateRelayStatusRequest struct {
+	State    string `json:"state"`    // ok, maintenance, read-only
+	Message  string `json:"message"`  // Shown on /public/status
+	Announce bool   `json:"announce"` // Publish the change as a Nostr note
+}
+
+// UpdateRelayStatus sets or clears the operator's state. It applies
+// immediately and lasts until set back to "ok".
+// PUT /api/v1/maintenance/status
+func (h *Handler) UpdateRelayStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req UpdateRelayStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	switch req.State {
+	case relayStateOK, relayStateMaintenance, relayStateReadOnly:
+	default:
+		respondError(w, http.StatusBadRequest, "state must be ok, maintenance or read-only", "INVALID_STATE")
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if len(req.Message) > maxMaintenanceMessageLength {
+		respondError(w, http.StatusBadRequest, "message must be at most 500 characters", "INVALID_MESSAGE")
+		return
+	}
+
+	override := &db.RelayStatusOverride{State: req.State, Message: req.Message, Since: time.Now().UTC()}
+	if req.State == relayStateOK {
+		override.Message = ""
+	}
+	if err := h.db.SetRelayStatusOverride(ctx, override); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save relay status", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "relay_status_updated", map[string]interface{}{
+		"state":   req.State,
+		"message": req.Message,
+	}, "")
+
+	response := map[string]interface{}{
+		"success":  true,
+		"override": override,
+	}
+	if req.Announce {
+		response["announcement"] = h.announce(r, statusAnnouncement(h.relayDisplayName(), req.State, req.Message))
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// CreateMaintenanceWindowRequest is the request body for scheduling maintenance.
+type CreateMaintenanceWindowRequest struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Mode     string    `json:"mode"` // maintenance (default) or read-only
+	Message  string    `json:"message"`
+	Announce bool      `json:"announce"` // Publish the schedule as a Nostr note
+}
+
+// CreateMaintenanceWindow schedules a maintenance window.
+// POST /api/v1/maintenance/windows
+func (h *Handler) CreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CreateMaintenanceWindowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	mw, code, msg := buildMaintenanceWindow(&req, time.Now())
+	if code != "" {
+		respondError(w, http.StatusBadRequest, msg, code)
+		return
+	}
+
+	if err := h.db.CreateMaintenanceWindow(ctx, mw); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save maintenance window", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "maintenance_scheduled", map[string]interface{}{
+		"id":        mw.ID,
+		"starts_at": mw.StartsAt,
+		"ends_at":   mw.EndsAt,
+		"mode":      mw.Mode,
+	}, "")
+
+	response := map[string]interface{}{
+		"window": mw,
+	}
+	if req.Announce {
+		response["announcement"] = h.announceWindow(r, mw)
+	}
+
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// AnnounceMaintenanceWindow publishes a window's announcement note, again if
+// it was already announced.
+// POST /api/v1/maintenance/windows/{id}/announce
+func (h *Handler) AnnounceMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	mw, ok := h.maintenanceWindowFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	announcement := h.announceWindow(r, mw)
+	if !announcement.Published {
+		respondError(w, http.StatusBadGateway, announcement.Error, "ANNOUNCE_FAILED")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"window":       mw,
+		"announcement": announcement,
+	})
+}
+
+// DeleteMaintenanceWindow cancels a maintenance window.
+// DELETE /api/v1/maintenance/windows/{id}
+func (h *Handler) DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	mw, ok := h.maintenanceWindowFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.db.DeleteMaintenanceWindow(ctx, mw.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete maintenance window", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "maintenance_cancelled", map[string]interface{}{
+		"id":        mw.ID,
+		"starts_at": mw.StartsAt,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Maintenance window cancelled",
+	})
+}
+
+// maintenanceWindowFromPath loads the window named by the {id} path value,
+// responding with an error if it is invalid or missing.
+func (h *Handler) maintenanceWindowFromPath(w http.ResponseWriter, r *http.Request) (*db.MaintenanceWindow, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid maintenance window ID", "INVALID_ID")
+		return nil, false
+	}
+	mw, err := h.db.GetMaintenanceWindow(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get maintenance window", "DB_ERROR")
+		return nil, false
+	}
+	if mw == nil {
+		respondError(w, http.StatusNotFound, "Maintenance window not found", "NOT_FOUND")
+		return nil, false
+	}
+	return mw, true
+}
+
+// Announcement is the outcome of publishing an announcement note.
+type Announcement struct {
+	Published bool   `json:"published"`
+	EventID   string `json:"event_id,omitempty"`
+	Content   string `json:"content"`
+	Error     string `json:"error,omitempty"`
+}
+
+// announceWindow publishes a window's announcement and records when it was sent.
+func (h *Handler) announceWindow(r *http.Request, mw *db.MaintenanceWindow) Announcement {
+	announcement := h.announce(r, windowAnnouncement(h.relayDisplayName(), mw))
+	if announcement.Published {
+		now := time.Now()
+		if err := h.db.SetMaintenanceAnnounced(r.Context(), mw.ID, now); err != nil {
+			slog.WarnContext(r.Context(), "Failed to record maintenance announcement", "id", mw.ID, "error", err)
+		}
+		mw.AnnouncedAt = &now
+	}
+	return announcement
+}
+
+// announce publishes a public note from the relay's notification key.
+// Failures are reported in the result rather than failing the request.
+func (h *Handler) announce(r *http.Request, content string) Announcement {
+	announcement := Announcement{Content: content}
+	if h.services == nil || h.services.Notifier == nil {
+		announcement.Error = "Notification service not available"
+		return announcement
+	}
+
+	eventID, err := h.services.Notifier.PublishNote(r.Context(), content)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Failed to publish announcement", "error", err)
+		announcement.Error = "Announcement was not accepted by any relay"
+		return announcement
+	}
+	announcement.Published = true
+	announcement.EventID = eventID
+	return announcement
+}
+
+// relayDisplayName returns the relay name from config.toml, or a generic one.
+func (h *Handler) relayDisplayName() string {
+	if h.configMgr != nil {
+		if cfg, _ := h.configMgr.Read(); cfg != nil && cfg.Info.Name != "" {
+			return cfg.Info.Name
+		}
+	}
+	return "This relay"
+}
+
+// buildMaintenanceWindow validates a scheduling request. On failure it
+// returns an error code and message.
+func buildMaintenanceWindow(req *CreateMaintenanceWindowRequest, now time.Time) (*db.MaintenanceWindow, string, string) {
+	mode := req.Mode
+	if mode == "" {
+		mode = relayStateMaintenance
+	}
+	if mode != relayStateMaintenance && mode != relayStateReadOnly {
+		return nil, "INVALID_MODE", "mode must be maintenance or read-only"
+	}
+	if req.StartsAt.IsZero() || req.EndsAt.IsZero() {
+		return nil, "INVALID_TIME", "starts_at and ends_at are required"
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		return nil, "INVALID_TIME", "ends_at must be after starts_at"
+	}
+	if !req.EndsAt.After(now) {
+		return nil, "INVALID_TIME", "ends_at must be in the future"
+	}
+	message := strings.TrimSpace(req.Message)
+	if len(message) > maxMaintenanceMessageLength {
+		return nil, "INVALID_MESSAGE", "message must be at most 500 characters"
+	}
+
+	return &db.MaintenanceWindow{
+		StartsAt: req.StartsAt.Truncate(time.Second),
+		EndsAt:   req.EndsAt.Truncate(time.Second),
+		Mode:     mode,
+		Message:  message,
+	}, "", ""
+}
+
+// resolveRelayStatus combines the operator-set state with the scheduled
+// windows. windows must not include windows that have ended.
+func resolveRelayStatus(override *db.RelayStatusOverride, windows []db.MaintenanceWindow, now time.Time) RelayStatus {
+	status := RelayStatus{State: relayStateOK, Upcoming: []db.MaintenanceWindow{}}
+
+	for _, mw := range windows {
+		if mw.StartsAt.After(now) {
+			status.Upcoming = append(status.Upcoming, mw)
+			continue
+		}
+		if status.State == relayStateOK {
+			since, until := mw.StartsAt, mw.EndsAt
+			status.State = mw.Mode
+			status.Message = mw.Message
+			status.Since = &since
+			status.Until = &until
+		}
+	}
+
+	if override != nil && override.State != "" && override.State != relayStateOK {
+		since := override.Since
+		status.State = override.State
+		status.Message = override.Message
+		status.Since = &since
+		status.Until = nil
+	}
+	return status
+}
+
+// publicMaintenanceWindows strips internal fields from the upcoming windows.
+func publicMaintenanceWindows(windows []db.MaintenanceWindow) []map[string]interface{} {
+	public := make([]map[string]interface{}, 0, len(windows))
+	for _, mw := range windows {
+		if len(public) == maxPublicMaintenanceWindows {
+			break
+		}
+		public = append(public, map[string]interface{}{
+			"starts_at": mw.StartsAt.UTC(),
+			"ends_at":   mw.EndsAt.UTC(),
+			"mode":      mw.Mode,
+			"message":   mw.Message,
+		})
+	}
+	return public
+}
+
+// windowAnnouncement is the note text announcing a maintenance window.
+func windowAnnouncement(relayName string, mw *db.MaintenanceWindow) string {
+	const layout = "Mon Jan 2 15:04 MST"
+	var text string
+	if mw.Mode == relayStateReadOnly {
+		text = fmt.Sprintf("%s will be read-only from %s to %s.", relayName,
+			mw.StartsAt.UTC().Format(layout), mw.EndsAt.UTC().Format(layout))
+	} else {
+		text = fmt.Sprintf("%s has scheduled maintenance from %s to %s.", relayName,
+			mw.StartsAt.UTC().Format(layout), mw.EndsAt.UTC().Format(layout))
+	}
+	if mw.Message != "" {
+		text += " " + mw.Message
+	}
+	return text
+}
+
+// statusAnnouncement is the note text announcing a change of state.
+func statusAnnouncement(relayName, state, message string) string {
+	var text string
+	switch state {
+	case relayStateMaintenance:
+		text = relayName + " is down for maintenance."
+	case relayStateReadOnly:
+		text = relayName + " is read-only for now."
+	default:
+		text = relayName + " is back to normal operation."
+	}
+	if message != "" {
+		text += " " + message
+	}
+	return text
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestResolveRelayStatus(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	active := db.MaintenanceWindow{ID: 1, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), Mode: "read-only", Message: "Migrating disks"}
+	upcoming := db.MaintenanceWindow{ID: 2, StartsAt: now.Add(24 * time.Hour), EndsAt: now.Add(25 * time.Hour), Mode: "maintenance"}
+
+	t.Run("ok", func(t *testing.T) {
+		status := resolveRelayStatus(&db.RelayStatusOverride{State: "ok"}, []db.MaintenanceWindow{upcoming}, now)
+		if status.State != "ok" || status.Since != nil || len(status.Upcoming) != 1 {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	})
+
+	t.Run("active_window", func(t *testing.T) {
+		status := resolveRelayStatus(&db.RelayStatusOverride{State: "ok"}, []db.MaintenanceWindow{active, upcoming}, now)
+		if status.State != "read-only" || status.Message != "Migrating disks" || status.Until == nil || !status.Until.Equal(active.EndsAt) {
+			t.Errorf("unexpected status: %+v", status)
+		}
+		if len(status.Upcoming) != 1 || status.Upcoming[0].ID != 2 {
+			t.Errorf("expected only the future window as upcoming, got %+v", status.Upcoming)
+		}
+	})
+
+	t.Run("override_wins", func(t *testing.T) {
+		override := &db.RelayStatusOverride{State: "maintenance", Message: "Power outage", Since: now.Add(-time.Minute)}
+		status := resolveRelayStatus(override, []db.MaintenanceWindow{active}, now)
+		if status.State != "maintenance" || status.Message != "Power outage" || status.Until != nil {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	})
+}
+
+func TestBuildMaintenanceWindow(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	mw, code, _ := buildMaintenanceWindow(&CreateMaintenanceWindowRequest{
+		StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour), Message: " Upgrading ",
+	}, now)
+	if code != "" || mw.Mode != "maintenance" || mw.Message != "Upgrading" {
+		t.Errorf("unexpected window: %+v (%s)", mw, code)
+	}
+
+	tests := []struct {
+		name string
+		req  CreateMaintenanceWindowRequest
+		code string
+	}{
+		{"missing_times", CreateMaintenanceWindowRequest{}, "INVALID_TIME"},
+		{"ends_before_start", CreateMaintenanceWindowRequest{StartsAt: now.Add(2 * time.Hour), EndsAt: now.Add(time.Hour)}, "INVALID_TIME"},
+		{"already_over", CreateMaintenanceWindowRequest{StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)}, "INVALID_TIME"},
+		{"in_progress", CreateMaintenanceWindowRequest{StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), Mode: "read-only"}, ""},
+		{"bad_mode", CreateMaintenanceWindowRequest{StartsAt: now, EndsAt: now.Add(time.Hour), Mode: "offline"}, "INVALID_MODE"},
+		{"long_message", CreateMaintenanceWindowRequest{StartsAt: now, EndsAt: now.Add(time.Hour), Message: strings.Repeat("x", 501)}, "INVALID_MESSAGE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, code, _ := buildMaintenanceWindow(&tt.req, now); code != tt.code {
+				t.Errorf("expected code %q, got %q", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestWindowAnnouncement(t *testing.T) {
+	mw := &db.MaintenanceWindow{
+		StartsAt: time.Date(2024, 1, 20, 2, 0, 0, 0, time.UTC),
+		EndsAt:   time.Date(2024, 1, 20, 4, 0, 0, 0, time.UTC),
+		Mode:     "read-only",
+		Message:  "Moving to a bigger disk.",
+	}
+	got := windowAnnouncement("Home Relay", mw)
+	want := "Home Relay will be read-only from Sat Jan 20 02:00 UTC to Sat Jan 20 04:00 UTC. Moving to a bigger disk."
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetMediaStorage returns the media settings, the media stored on the
+// Blossom server that relay events link to, and the members using the most.
+// GET /api/v1/storage/media
+func (h *Handler) GetMediaStorage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	limit := parseIntParam(r.URL.Query().Get("limit"), 10)
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	settings, err := h.db.GetMediaSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get media settings", "DB_ERROR")
+		return
+	}
+	total, owners, err := h.db.GetMediaUsageByOwner(ctx, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get media usage", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"settings":   settings,
+		"total":      total,
+		"top_owners": owners,
+	})
+}
+
+// UpdateMediaSettings saves the media settings and scans for media if
+// enabled.
+// PUT /api/v1/storage/media
+func (h *Handler) UpdateMediaSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req db.MediaSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	req.ServerURL = strings.TrimRight(strings.TrimSpace(req.ServerURL), "/")
+	if req.ServerURL != "" {
+		u, err := url.Parse(req.ServerURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			respondError(w, http.StatusBadRequest, "Invalid server_url. Must start with https:// or http://", "INVALID_URL")
+			return
+		}
+	}
+	if req.Enabled && req.ServerURL == "" {
+		respondError(w, http.StatusBadRequest, "server_url is required to enable media accounting", "INVALID_URL")
+		return
+	}
+
+	if err := h.db.SetMediaSettings(ctx, &req); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save media settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "media_settings_updated", map[string]interface{}{
+		"enabled":               req.Enabled,
+		"server_url":            req.ServerURL,
+		"delete_with_retention": req.DeleteWithRetention,
+	}, "")
+
+	if req.Enabled && h.services != nil && h.services.Media != nil {
+		h.services.Media.Wake()
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"settings": req,
+	})
+}
+
+// ScanMedia finds media links in events stored since the last scan and
+// checks the size of media not checked yet.
+// POST /api/v1/storage/media/scan
+func (h *Handler) ScanMedia(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Media == nil {
+		respondError(w, http.StatusServiceUnavailable, "Media service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	result, err := h.services.Media.Scan(r.Context())
+	if err != nil {
+		if errors.Is(err, services.ErrMediaDisabled) {
+			respondError(w, http.StatusConflict, "Media accounting is not enabled", "MEDIA_DISABLED")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Media scan failed: "+err.Error(), "SCAN_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
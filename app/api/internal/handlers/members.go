@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetMemberHighlights returns a member's most-reacted and most-replied notes
+// stored on this relay, for featuring in community digests.
+// GET /api/v1/members/{pubkey}/highlights
+func (h *Handler) GetMemberHighlights(w http.ResponseWriter, r *http.Request) {
+	pubkey, npub, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey (expected hex or npub)", "INVALID_PUBKEY")
+		return
+	}
+
+	limit := 5
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+			if limit > 50 {
+				limit = 50
+			}
+		}
+	}
+
+	timeRange := r.URL.Query().Get("time_range")
+	if timeRange == "" {
+		timeRange = "30days"
+	}
+
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	since, until := parseTimeRange(timeRange, r.URL.Query().Get("timezone"))
+
+	highlights, err := h.db.GetMemberHighlights(r.Context(), pubkey, since, until, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get member highlights", "STATS_FAILED")
+		return
+	}
+
+	// Only mirrored members have been checked; nil otherwise
+	coverage, _ := h.db.GetMirrorCoverage(r.Context(), pubkey)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"pubkey":          highlights.Pubkey,
+		"npub":            npub,
+		"time_range":      timeRange,
+		"notes":           highlights.Notes,
+		"reactions":       highlights.Reactions,
+		"replies":         highlights.Replies,
+		"most_reacted":    highlights.MostReacted,
+		"most_replied":    highlights.MostReplied,
+		"mirror_coverage": coverage,
+	})
+}
+
+// GetUserActivity returns what a pubkey stores on the relay: event counts
+// by kind, first and last events, storage, mentions and daily posting counts.
+// GET /api/v1/users/{pubkey}/activity
+func (h *Handler) GetUserActivity(w http.ResponseWriter, r *http.Request) {
+	pubkey, npub, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey (expected hex or npub)", "INVALID_PUBKEY")
+		return
+	}
+
+	timeRange := r.URL.Query().Get("time_range")
+	if timeRange == "" {
+		timeRange = "30days"
+	}
+	timezone := r.URL.Query().Get("timezone")
+
+	loc := time.UTC
+	if timezone != "" && timezone != "UTC" {
+		if parsed, err := time.LoadLocation(timezone); err == nil {
+			loc = parsed
+		}
+	}
+
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	since, until := parseTimeRange(timeRange, timezone)
+
+	activity, err := h.db.GetPubkeyActivity(r.Context(), pubkey, since, until, loc)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get user activity", "STATS_FAILED")
+		return
+	}
+	media, err := h.db.GetMediaUsage(r.Context(), pubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get media usage", "DB_ERROR")
+		return
+	}
+	var quota *services.QuotaStatus
+	if h.services != nil && h.services.Quotas != nil {
+		if quota, err = h.services.Quotas.Status(r.Context(), pubkey); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to get quota status", "DB_ERROR")
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"pubkey":             activity.Pubkey,
+		"npub":               npub,
+		"time_range":         timeRange,
+		"event_count":        activity.EventCount,
+		"events_by_kind":     activity.EventsByKind,
+		"events_by_category": categoryCounts(activity.EventsByKind),
+		"storage_bytes":      activity.StorageBytes,
+		"media":              media,
+		"quota":              quota,
+		"first_event":        activity.FirstEvent,
+		"last_event":         activity.LastEvent,
+		"mentions":           activity.Mentions,
+		"events_over_time":   activity.EventsOverTime,
+	})
+}
+
+// MemberCoverageResponse is a mirrored member's last coverage check.
+type MemberCoverageResponse struct {
+	Pubkey   string             `json:"pubkey"`
+	Npub     string             `json:"npub"`
+	Nickname string             `json:"nickname,omitempty"`
+	Coverage *db.MirrorCoverage `json:"coverage"` // nil if not checked yet
+}
+
+// GetMirrorCoverage returns the last coverage check for every mirrored
+// member (sync pubkey), with the coverage across all of them.
+// GET /api/v1/members/coverage
+func (h *Handler) GetMirrorCoverage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	pubkeys, err := h.db.GetSyncPubkeys(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get sync pubkeys", "DB_ERROR")
+		return
+	}
+	coverages, err := h.db.GetMirrorCoverages(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get mirror coverage", "DB_ERROR")
+		return
+	}
+
+	members := make([]MemberCoverageResponse, 0, len(pubkeys))
+	var checked []*db.MirrorCoverage
+	for _, pk := range pubkeys {
+		c := coverages[pk.Pubkey]
+		if c != nil {
+			checked = append(checked, c)
+		}
+		members = append(members, MemberCoverageResponse{
+			Pubkey:   pk.Pubkey,
+			Npub:     pk.Npub,
+			Nickname: pk.Nickname,
+			Coverage: c,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"members": members,
+		"overall": overallCoverage(checked),
+	})
+}
+
+// overallCoverage sums coverage checks into one, with Pubkey, relays and
+// missing IDs left empty. It returns nil if none were made.
+func overallCoverage(checks []*db.MirrorCoverage) *db.MirrorCoverage {
+	if len(checks) == 0 {
+		return nil
+	}
+	overall := &db.MirrorCoverage{Relays: map[string]int{}, MissingIDs: []string{}}
+	for _, c := range checks {
+		overall.Sampled += c.Sampled
+		overall.Present += c.Present
+		if overall.Since.IsZero() || c.Since.Before(overall.Since) {
+			overall.Since = c.Since
+		}
+		if c.CheckedAt.After(overall.CheckedAt) {
+			overall.CheckedAt = c.CheckedAt
+		}
+	}
+	overall.SetCoverage()
+	return overall
+}
+
+// GetMemberCoverage returns what fraction of a member's recent events on the
+// sync relays is stored on this relay. A member that was never checked, or
+// refresh=true, is checked now.
+// GET /api/v1/members/{pubkey}/coverage?refresh=true
+func (h *Handler) GetMemberCoverage(w http.ResponseWriter, r *http.Request) {
+	pubkey, npub, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey (expected hex or npub)", "INVALID_PUBKEY")
+		return
+	}
+	pubkey = strings.ToLower(pubkey)
+
+	ctx := r.Context()
+
+	coverage, err := h.db.GetMirrorCoverage(ctx, pubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get mirror coverage", "DB_ERROR")
+		return
+	}
+
+	if coverage == nil || r.URL.Query().Get("refresh") == "true" {
+		if h.services == nil || h.services.Coverage == nil {
+			respondError(w, http.StatusServiceUnavailable, "Coverage service not available", "SERVICE_UNAVAILABLE")
+			return
+		}
+		if !h.db.IsRelayDBConnected() {
+			respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+			return
+		}
+		coverage, err = h.services.Coverage.Check(ctx, pubkey)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check mirror coverage", "COVERAGE_CHECK_FAILED")
+			return
+		}
+	}
+
+	nickname := ""
+	if pk, err := h.db.GetSyncPubkeyByPubkey(ctx, pubkey); err == nil && pk != nil {
+		nickname = pk.Nickname
+	}
+
+	respondJSON(w, http.StatusOK, MemberCoverageResponse{
+		Pubkey:   pubkey,
+		Npub:     npub,
+		Nickname: nickname,
+		Coverage: coverage,
+	})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestOverallCoverage(t *testing.T) {
+	if overallCoverage(nil) != nil {
+		t.Error("expected nil without checks")
+	}
+
+	a := &db.MirrorCoverage{Sampled: 10, Present: 9, Since: time.Unix(100, 0), CheckedAt: time.Unix(500, 0)}
+	b := &db.MirrorCoverage{Sampled: 30, Present: 21, Since: time.Unix(50, 0), CheckedAt: time.Unix(400, 0)}
+	empty := &db.MirrorCoverage{Since: time.Unix(200, 0), CheckedAt: time.Unix(600, 0)}
+
+	overall := overallCoverage([]*db.MirrorCoverage{a, b, empty})
+	if overall.Sampled != 40 || overall.Present != 30 {
+		t.Errorf("expected 30 of 40 present, got %d of %d", overall.Present, overall.Sampled)
+	}
+	if overall.Coverage == nil || *overall.Coverage != 75 {
+		t.Errorf("expected 75%% coverage, got %v", overall.Coverage)
+	}
+	if overall.Since.Unix() != 50 || overall.CheckedAt.Unix() != 600 {
+		t.Errorf("expected the earliest window and latest check, got %v and %v", overall.Since, overall.CheckedAt)
+	}
+}
@@ -1,9 +1,17 @@
 package handlers
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/roostr/roostr/app/api/internal/logging"
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
 // Middleware wraps an http.Handler with additional functionality.
@@ -17,6 +25,21 @@ func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
 	return h
 }
 
+// RequestID assigns each request an ID, reusing a valid X-Request-ID from the
+// client, and echoes it in the response. The ID is added to the request
+// context so every log line for the request carries it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logging.RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(logging.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
 // Logging logs each request with timing information.
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -27,24 +50,95 @@ func Logging(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
-		log.Printf("%s %s %d %s",
-			r.Method,
-			r.URL.Path,
-			wrapped.status,
-			time.Since(start),
+		level := slog.LevelInfo
+		if wrapped.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(r.Context(), level, "Request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", wrapped.status),
+			slog.Duration("duration", time.Since(start)),
 		)
 	})
 }
 
-// CORS adds Cross-Origin Resource Sharing headers.
-func CORS(next http.Handler) http.Handler {
+// CORS adds Cross-Origin Resource Sharing headers for origins on the
+// operator's admin allowlist. The UI is served from the same origin and needs
+// none; an empty allowlist keeps the API same-origin only.
+// Public routes are skipped; their CORS headers come from Handler.PublicCORS.
+func CORS(allowedOrigins func(context.Context) ([]string, error)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isPublicPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			origin := r.Header.Get("Origin")
+			if origin != "" {
+				allowed, err := allowedOrigins(r.Context())
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Failed to load admin allowed origins", "error", err)
+				}
+				if originAllowed(origin, allowed) {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+					w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, "+csrfHeader)
+					w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+					w.Header().Set("Access-Control-Max-Age", "86400")
+				}
+				w.Header().Add("Vary", "Origin")
+			}
+
+			// Handle preflight requests
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// originAllowed reports whether origin is on an admin allowlist. Unlike the
+// signup allowlist, an empty list allows nothing and "*" is not accepted.
+func originAllowed(origin string, allowed []string) bool {
+	normalized := strings.TrimSuffix(strings.ToLower(origin), "/")
+	for _, a := range allowed {
+		if a != "*" && strings.EqualFold(a, normalized) {
+			return true
+		}
+	}
+	return false
+}
+
+// PublicCORS adds CORS headers for /public/* routes based on the operator's
+// signup origin allowlist, so the signup widget can be embedded on other sites.
+// An empty allowlist allows any origin.
+func (h *Handler) PublicCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow requests from any origin in development
-		// In production, this should be restricted
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Max-Age", "86400")
+		if !isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			allowed, err := h.db.GetSignupAllowedOrigins(r.Context())
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to load signup allowed origins", "error", err)
+			}
+			if allowOrigin, ok := matchAllowedOrigin(origin, allowed); ok {
+				w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+				w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+				w.Header().Set("Access-Control-Max-Age", "86400")
+			}
+			w.Header().Add("Vary", "Origin")
+		}
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -56,12 +150,131 @@ func CORS(next http.Handler) http.Handler {
 	})
 }
 
+// isPublicPath reports whether the path belongs to the unauthenticated public
+// API. The relay's Lightning address is served under /.well-known.
+func isPublicPath(path string) bool {
+	return strings.HasPrefix(path, "/public/") || strings.HasPrefix(path, "/.well-known/lnurlp/")
+}
+
+// matchAllowedOrigin returns the Access-Control-Allow-Origin value for origin.
+// An empty allowlist or a "*" entry allows every origin.
+func matchAllowedOrigin(origin string, allowed []string) (string, bool) {
+	if len(allowed) == 0 {
+		return "*", true
+	}
+	normalized := strings.TrimSuffix(strings.ToLower(origin), "/")
+	for _, a := range allowed {
+		if a == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(a, normalized) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// RateLimit refuses requests from banned addresses and applies the per-IP
+// rate limit of the request's route group. The UI's static files are only
+// subject to bans.
+func (h *Handler) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.services == nil || h.services.RateLimit == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		addr, ok := clientAddr(r, h.trustedProxies())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decision := h.services.RateLimit.Check(r.Context(), addr, rateLimitGroup(r))
+		switch {
+		case decision.Banned:
+			respondError(w, http.StatusForbidden, "Your IP address is banned", "IP_BANNED")
+		case !decision.Allowed:
+			seconds := int(decision.RetryAfter.Seconds() + 1)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			respondError(w, http.StatusTooManyRequests, "Too many requests, try again later", "RATE_LIMITED")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// rateLimitGroup returns the rate limit group of a request, or "" for routes
+// that are not rate limited.
+func rateLimitGroup(r *http.Request) string {
+	switch {
+	case r.Method == http.MethodPost && (r.URL.Path == "/public/create-invoice" || r.URL.Path == "/public/cashu-payment"),
+		r.URL.Path == "/public/signup-check",
+		r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/public/invoice-"),
+		strings.HasPrefix(r.URL.Path, "/public/renew/"),
+		strings.HasPrefix(r.URL.Path, "/public/access-status/"),
+		strings.HasPrefix(r.URL.Path, "/public/lnurlp/"):
+		return services.RateGroupSignup
+	case isPublicPath(r.URL.Path):
+		return services.RateGroupPublic
+	case strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/api/v1/health":
+		return services.RateGroupAPI
+	}
+	return ""
+}
+
+// trustedProxies returns the proxies whose X-Forwarded-For is trusted.
+func (h *Handler) trustedProxies() []netip.Prefix {
+	cfg := h.currentConfig()
+	if cfg == nil {
+		return nil
+	}
+	return cfg.TrustedProxies
+}
+
+// clientAddr returns the address of the client. When the connection comes
+// from a trusted proxy, X-Forwarded-For is walked from the right and the
+// first address that is not a trusted proxy is the client.
+func clientAddr(r *http.Request, trusted []netip.Prefix) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && isTrustedProxy(addr, trusted); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+	}
+	return addr, true
+}
+
+// isTrustedProxy reports whether addr is in one of the trusted prefixes.
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // Recover recovers from panics and returns a 500 error.
 func Recover(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
+				slog.ErrorContext(r.Context(), "Panic recovered", "panic", err)
 				respondError(w, http.StatusInternalServerError, "Internal server error", "INTERNAL_ERROR")
 			}
 		}()
@@ -81,6 +294,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap returns the underlying ResponseWriter so http.ResponseController
+// can reach it (e.g. to hijack WebSocket connections).
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Flush implements http.Flusher for streaming responses.
 func (rw *responseWriter) Flush() {
 	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/logging"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	t.Run("reuses_valid_client_id", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/health", nil)
+		req.Header.Set("X-Request-ID", "client-abc.1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if seen != "client-abc.1" || rec.Header().Get("X-Request-ID") != "client-abc.1" {
+			t.Errorf("expected client ID in context and response, got %q / %q", seen, rec.Header().Get("X-Request-ID"))
+		}
+	})
+
+	t.Run("replaces_invalid_client_id", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/health", nil)
+		req.Header.Set("X-Request-ID", "bad id")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		got := rec.Header().Get("X-Request-ID")
+		if got == "bad id" || len(got) != 32 || seen != got {
+			t.Errorf("expected generated ID, got %q (context %q)", got, seen)
+		}
+	})
+}
+
+func TestClientAddr(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("127.0.0.1/32")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted proxy is the client", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed left entries are ignored", "10.0.0.2:5000", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "127.0.0.1:5000", []string{"198.51.100.1", "10.0.0.3"}, "198.51.100.1"},
+		{"garbage stops the walk", "10.0.0.2:5000", []string{"nonsense"}, "10.0.0.2"},
+		{"ipv6", "[2001:db8::1]:5000", nil, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/stats/summary", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, f := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", f)
+			}
+			addr, ok := clientAddr(req, trusted)
+			if !ok || addr.String() != tt.want {
+				t.Errorf("got %v (%v), want %s", addr, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitGroup(t *testing.T) {
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodPost, "/public/create-invoice", "signup"},
+		{http.MethodPost, "/public/cashu-payment", "signup"},
+		{http.MethodGet, "/public/renew/npub1abc", "signup"},
+		{http.MethodGet, "/public/lnurlp/relay", "signup"},
+		{http.MethodGet, "/public/signup-check", "signup"},
+		{http.MethodPost, "/public/invoice-reissue/abc", "signup"},
+		{http.MethodGet, "/public/invoice-status/abc", "public"},
+		{http.MethodGet, "/public/access-status/npub1abc", "signup"},
+		{http.MethodGet, "/.well-known/lnurlp/relay", "public"},
+		{http.MethodGet, "/public/relay-info", "public"},
+		{http.MethodGet, "/api/v1/access/whitelist", "api"},
+		{http.MethodGet, "/api/v1/health", ""},
+		{http.MethodGet, "/assets/app.js", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := rateLimitGroup(req); got != tt.want {
+			t.Errorf("rateLimitGroup(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
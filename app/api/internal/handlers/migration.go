@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// StartMigration starts moving every event of a set of pubkeys from another
+// relay, such as a hosted relay being left behind, to this one. It runs as
+// a job whose result is the migration report.
+// POST /api/v1/sync/migrate
+func (h *Handler) StartMigration(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Migration == nil {
+		respondError(w, http.StatusServiceUnavailable, "Migration service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	var req services.MigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if len(req.Pubkeys) == 0 {
+		respondError(w, http.StatusBadRequest, "At least one pubkey is required", "MISSING_PUBKEYS")
+		return
+	}
+
+	job, ok := h.startMigration(w, r, req)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/sync/migrations/%d/report", job.ID))
+	respondJSON(w, http.StatusAccepted, job)
+}
+
+// startMigration starts a migration job, responding with an error if it
+// could not be started.
+func (h *Handler) startMigration(w http.ResponseWriter, r *http.Request, req services.MigrationRequest) (*db.Job, bool) {
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return nil, false
+	}
+
+	job, err := h.services.Migration.StartMigration(r.Context(), req)
+	switch {
+	case errors.Is(err, nostr.ErrInvalidURL):
+		respondError(w, http.StatusBadRequest, "Source relay must be a ws:// or wss:// URL", "INVALID_RELAY_URL")
+		return nil, false
+	case errors.Is(err, services.ErrInvalidAuthKey):
+		respondError(w, http.StatusBadRequest, "Auth key must be an nsec or hex secret key", "INVALID_AUTH_KEY")
+		return nil, false
+	case errors.Is(err, services.ErrMigrationSyncRunning):
+		respondError(w, http.StatusConflict, "Wait for the running sync to finish", "SYNC_ALREADY_RUNNING")
+		return nil, false
+	case errors.Is(err, services.ErrJobAlreadyRunning):
+		respondError(w, http.StatusConflict, "A migration is already running", "MIGRATION_ALREADY_RUNNING")
+		return nil, false
+	case errors.Is(err, services.ErrInvalidMigration):
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_MIGRATION")
+		return nil, false
+	}
+	if !jobStarted(w, err) {
+		return nil, false
+	}
+
+	h.db.AddAuditLog(r.Context(), "migration_started", map[string]interface{}{
+		"job_id":        job.ID,
+		"source_relay":  req.SourceRelay,
+		"pubkeys":       len(req.Pubkeys),
+		"authenticated": req.AuthKey != "",
+	}, "")
+
+	return job, true
+}
+
+// GetMigrationReport returns a finished migration's report: per pubkey,
+// how many events the source relay had, how many were stored and whether
+// every one was found afterwards. A cancelled migration reports what it got
+// through.
+// GET /api/v1/sync/migrations/{id}/report
+func (h *Handler) GetMigrationReport(w http.ResponseWriter, r *http.Request) {
+	if !h.jobsAvailable(w) {
+		return
+	}
+	job, ok := h.jobFromPath(w, r)
+	if !ok {
+		return
+	}
+	if job.Type != services.JobTypeMigration {
+		respondError(w, http.StatusNotFound, "Migration not found", "JOB_NOT_FOUND")
+		return
+	}
+	if job.Status == db.JobStatusRunning {
+		respondErrorWithDetails(w, http.StatusConflict, "Migration is still running", "JOB_RUNNING", job)
+		return
+	}
+	if len(job.Result) == 0 {
+		respondError(w, http.StatusNotFound, "Migration failed before producing a report: "+job.Error, "REPORT_NOT_FOUND")
+		return
+	}
+
+	var report services.MigrationReport
+	if err := json.Unmarshal(job.Result, &report); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to read migration report", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"job_id": job.ID,
+		"status": job.Status,
+		"error":  job.Error,
+		"report": report,
+	})
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// ModerationRuleRequest is the request body for creating or updating a
+// moderation rule. Omitted fields are left unchanged on update.
+type ModerationRuleRequest struct {
+	Name    *string                  `json:"name"`
+	Type    *string                  `json:"type"`
+	Config  *db.ModerationRuleConfig `json:"config"`
+	Score   *int                     `json:"score"`
+	Enabled *bool                    `json:"enabled"`
+}
+
+// GetModerationSettings returns the auto-moderation settings.
+// GET /api/v1/moderation/settings
+func (h *Handler) GetModerationSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetModerationSettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get moderation settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateModerationSettings saves the auto-moderation settings. Enabling
+// moderation starts with events stored from then on.
+// PUT /api/v1/moderation/settings
+func (h *Handler) UpdateModerationSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetModerationSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get moderation settings", "DB_ERROR")
+		return
+	}
+	wasEnabled := settings.Enabled
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if err := services.ValidateModerationSettings(settings); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_SETTINGS")
+		return
+	}
+
+	if err := h.db.SetModerationSettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save moderation settings", "DB_ERROR")
+		return
+	}
+	// Start from the newest event, not from where moderation was turned off
+	if settings.Enabled && !wasEnabled {
+		h.db.SetModerationCursor(ctx, 0)
+	}
+
+	h.db.AddAuditLog(ctx, "moderation_settings_updated", settings, "")
+	h.wakeModeration()
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// GetModerationRules returns all moderation rules.
+// GET /api/v1/moderation/rules
+func (h *Handler) GetModerationRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.db.GetModerationRules(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get moderation rules", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+	})
+}
+
+// CreateModerationRule adds a moderation rule.
+// POST /api/v1/moderation/rules
+func (h *Handler) CreateModerationRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req ModerationRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	rule := &db.ModerationRule{Score: 1, Enabled: true}
+	applyModerationRuleRequest(rule, req)
+	if rule.Name == "" {
+		rule.Name = strings.ReplaceAll(rule.Type, "_", " ")
+	}
+	if err := services.ValidateModerationRule(rule); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_RULE")
+		return
+	}
+
+	if err := h.db.CreateModerationRule(ctx, rule); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create moderation rule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "moderation_rule_created", map[string]interface{}{
+		"id":    rule.ID,
+		"name":  rule.Name,
+		"type":  rule.Type,
+		"score": rule.Score,
+	}, "")
+	h.wakeModeration()
+
+	if created, _ := h.db.GetModerationRule(ctx, rule.ID); created != nil {
+		rule = created
+	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"rule": rule,
+	})
+}
+
+// GetModerationRule returns a moderation rule.
+// GET /api/v1/moderation/rules/{id}
+func (h *Handler) GetModerationRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.loadModerationRule(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"rule": rule,
+	})
+}
+
+// UpdateModerationRule changes a moderation rule. A config in the request
+// replaces the stored one.
+// PATCH /api/v1/moderation/rules/{id}
+func (h *Handler) UpdateModerationRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rule, ok := h.loadModerationRule(w, r)
+	if !ok {
+		return
+	}
+
+	var req ModerationRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	applyModerationRuleRequest(rule, req)
+	if err := services.ValidateModerationRule(rule); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_RULE")
+		return
+	}
+
+	if err := h.db.UpdateModerationRule(ctx, rule); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update moderation rule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "moderation_rule_updated", map[string]interface{}{
+		"id":      rule.ID,
+		"name":    rule.Name,
+		"type":    rule.Type,
+		"score":   rule.Score,
+		"enabled": rule.Enabled,
+	}, "")
+	h.wakeModeration()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"rule": rule,
+	})
+}
+
+// DeleteModerationRule removes a moderation rule. Actions it contributed
+// to stay in the action log.
+// DELETE /api/v1/moderation/rules/{id}
+func (h *Handler) DeleteModerationRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rule, ok := h.loadModerationRule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteModerationRule(ctx, rule.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete moderation rule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "moderation_rule_deleted", map[string]interface{}{
+		"id":   rule.ID,
+		"name": rule.Name,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Moderation rule deleted",
+	})
+}
+
+// GetModerationActions returns the auto-moderation action log, newest
+// first, optionally for one pubkey or action.
+// GET /api/v1/moderation/actions
+func (h *Handler) GetModerationActions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.ModerationActionFilter{
+		Pubkey: query.Get("pubkey"),
+		Action: query.Get("action"),
+		Limit:  parseIntParam(query.Get("limit"), 50),
+		Offset: parseIntParam(query.Get("offset"), 0),
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+	switch filter.Action {
+	case "", services.ModerationFlag, services.ModerationDelete, services.ModerationBlacklist:
+	default:
+		respondError(w, http.StatusBadRequest, "Action must be flag, delete or blacklist", "INVALID_ACTION")
+		return
+	}
+
+	actions, total, err := h.db.GetModerationActions(r.Context(), filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get moderation actions", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"actions": actions,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
+
+// RunModerationScan moderates new events now instead of waiting for the
+// next scan.
+// POST /api/v1/moderation/scan
+func (h *Handler) RunModerationScan(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Moderation == nil {
+		respondError(w, http.StatusServiceUnavailable, "Moderation service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	result, err := h.services.Moderation.Scan(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to moderate events", "MODERATION_FAILED")
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+// loadModerationRule resolves the {id} path value, writing an error response on failure.
+func (h *Handler) loadModerationRule(w http.ResponseWriter, r *http.Request) (*db.ModerationRule, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "Invalid rule ID", "INVALID_ID")
+		return nil, false
+	}
+
+	rule, err := h.db.GetModerationRule(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get moderation rule", "DB_ERROR")
+		return nil, false
+	}
+	if rule == nil {
+		respondError(w, http.StatusNotFound, "Moderation rule not found", "NOT_FOUND")
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// applyModerationRuleRequest copies the fields present in req onto rule.
+func applyModerationRuleRequest(rule *db.ModerationRule, req ModerationRuleRequest) {
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Type != nil {
+		rule.Type = *req.Type
+	}
+	if req.Config != nil {
+		rule.Config = *req.Config
+	}
+	if req.Score != nil {
+		rule.Score = *req.Score
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
+
+// wakeModeration asks the moderation service to pick up changed rules or
+// settings now.
+func (h *Handler) wakeModeration() {
+	if h.services == nil || h.services.Moderation == nil {
+		return
+	}
+	h.services.Moderation.Wake()
+}
@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// nip86ContentType is the content type of NIP-86 requests and responses.
+const nip86ContentType = "application/nostr+json+rpc"
+
+// httpAuthKind is the NIP-98 HTTP authentication event kind.
+const httpAuthKind = 27235
+
+// httpAuthMaxClockSkew bounds how far a NIP-98 event's created_at may drift.
+const httpAuthMaxClockSkew = time.Minute
+
+// nip86MaxBody caps the size of a NIP-86 request body.
+const nip86MaxBody = 64 << 10
+
+// nip86Methods lists the supported NIP-86 methods.
+var nip86Methods = []string{
+	"supportedmethods",
+	"banpubkey",
+	"unbanpubkey",
+	"listbannedpubkeys",
+	"allowpubkey",
+	"unallowpubkey",
+	"listallowedpubkeys",
+	"listeventsneedingmoderation",
+	"banevent",
+	"listbannedevents",
+	"changerelayname",
+	"changerelaydescription",
+	"changerelayicon",
+	"allowkind",
+	"disallowkind",
+	"listallowedkinds",
+}
+
+// nip86Request is a NIP-86 JSON-RPC call.
+type nip86Request struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// nip86Response is a NIP-86 JSON-RPC result. Error is empty on success.
+type nip86Response struct {
+	Result interface{} `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// nip86PubkeyReason is a pubkey list entry.
+type nip86PubkeyReason struct {
+	Pubkey string `json:"pubkey"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// nip86EventReason is an event list entry.
+type nip86EventReason struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ServeNIP86 answers NIP-86 relay management calls, backed by the same
+// whitelist, blacklist and config.toml as the REST API. Calls must carry a
+// NIP-98 Authorization header signed by the operator. Proxy POSTs to the
+// relay URL with "Content-Type: application/nostr+json+rpc" here.
+// POST /public/nip86
+func (h *Handler) ServeNIP86(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, nip86MaxBody+1))
+	if err != nil || len(body) > nip86MaxBody {
+		respondNIP86(w, http.StatusBadRequest, nip86Response{Error: "invalid request body"})
+		return
+	}
+
+	pubkey, reason := validateHTTPAuth(r.Header.Get("Authorization"), r.Method, r.Host, body, time.Now())
+	if reason != "" {
+		respondNIP86(w, http.StatusUnauthorized, nip86Response{Error: reason})
+		return
+	}
+	if operator, _ := h.db.GetOperatorPubkey(r.Context()); operator == "" || pubkey != operator {
+		respondNIP86(w, http.StatusUnauthorized, nip86Response{Error: "pubkey is not the relay operator"})
+		return
+	}
+
+	var req nip86Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		respondNIP86(w, http.StatusBadRequest, nip86Response{Error: "invalid JSON-RPC request"})
+		return
+	}
+
+	result, err := h.callNIP86(r.Context(), pubkey, req)
+	if err != nil {
+		respondNIP86(w, http.StatusOK, nip86Response{Error: err.Error()})
+		return
+	}
+	respondNIP86(w, http.StatusOK, nip86Response{Result: result})
+}
+
+// respondNIP86 writes a NIP-86 response.
+func respondNIP86(w http.ResponseWriter, status int, resp nip86Response) {
+	w.Header().Set("Content-Type", nip86ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// callNIP86 runs one method. Errors are returned to the caller as the
+// response's error message.
+func (h *Handler) callNIP86(ctx context.Context, operator string, req nip86Request) (interface{}, error) {
+	switch req.Method {
+	case "supportedmethods":
+		return nip86Methods, nil
+
+	case "banpubkey":
+		pubkey, npub, err := nip86Pubkey(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		reason := nip86OptionalString(req.Params, 1)
+		if err := h.db.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: pubkey, Npub: npub, Reason: reason}); err != nil {
+			return nil, errors.New("failed to ban pubkey")
+		}
+		h.afterAccessChange(ctx)
+		h.db.AddAuditLog(ctx, "blacklist_add", map[string]string{"pubkey": pubkey, "reason": reason, "source": "nip86"}, operator)
+		h.wakeProfiles()
+		return true, nil
+
+	case "unbanpubkey":
+		pubkey, _, err := nip86Pubkey(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.db.RemoveBlacklistEntry(ctx, pubkey); err != nil {
+			return nil, errors.New("failed to unban pubkey")
+		}
+		h.afterAccessChange(ctx)
+		h.db.AddAuditLog(ctx, "blacklist_remove", map[string]string{"pubkey": pubkey, "source": "nip86"}, operator)
+		return true, nil
+
+	case "listbannedpubkeys":
+		entries, err := h.db.GetBlacklist(ctx)
+		if err != nil {
+			return nil, errors.New("failed to list banned pubkeys")
+		}
+		list := make([]nip86PubkeyReason, len(entries))
+		for i, e := range entries {
+			list[i] = nip86PubkeyReason{Pubkey: e.Pubkey, Reason: e.Reason}
+		}
+		return list, nil
+
+	case "allowpubkey":
+		pubkey, npub, err := nip86Pubkey(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		reason := nip86OptionalString(req.Params, 1)
+		entry := db.WhitelistEntry{Pubkey: pubkey, Npub: npub, Nickname: reason, AddedBy: "nip86"}
+		if err := h.db.AddWhitelistEntry(ctx, entry); err != nil {
+			return nil, errors.New("failed to allow pubkey")
+		}
+		h.afterAccessChange(ctx)
+		h.db.AddAuditLog(ctx, "whitelist_add", map[string]string{"pubkey": pubkey, "nickname": reason, "source": "nip86"}, operator)
+		h.emitWebhook(services.WebhookEventUserWhitelisted, map[string]interface{}{
+			"pubkey": pubkey,
+			"npub":   npub,
+			"source": "nip86",
+		})
+		h.wakeProfiles()
+		return true, nil
+
+	case "unallowpubkey":
+		pubkey, _, err := nip86Pubkey(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.db.RemoveWhitelistEntry(ctx, pubkey); err != nil {
+			if err.Error() == "cannot remove operator from whitelist" {
+				return nil, err
+			}
+			return nil, errors.New("failed to unallow pubkey")
+		}
+		h.afterAccessChange(ctx)
+		h.db.AddAuditLog(ctx, "whitelist_remove", map[string]string{"pubkey": pubkey, "source": "nip86"}, operator)
+		return true, nil
+
+	case "listallowedpubkeys":
+		entries, err := h.db.GetWhitelistMeta(ctx)
+		if err != nil {
+			return nil, errors.New("failed to list allowed pubkeys")
+		}
+		list := make([]nip86PubkeyReason, len(entries))
+		for i, e := range entries {
+			list[i] = nip86PubkeyReason{Pubkey: e.Pubkey, Reason: e.Nickname}
+		}
+		return list, nil
+
+	case "listeventsneedingmoderation":
+		actions, _, err := h.db.GetModerationActions(ctx, db.ModerationActionFilter{Action: services.ModerationFlag, Limit: 500})
+		if err != nil {
+			return nil, errors.New("failed to list flagged events")
+		}
+		list := make([]nip86EventReason, len(actions))
+		for i, a := range actions {
+			list[i] = nip86EventReason{ID: a.EventID, Reason: strings.Join(a.Rules, ", ")}
+		}
+		return list, nil
+
+	case "banevent":
+		id, err := nip86String(req.Params, 0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := hex.DecodeString(id); err != nil || len(id) != 64 {
+			return nil, errors.New("invalid event id")
+		}
+		reason := nip86OptionalString(req.Params, 1)
+		if !h.db.IsRelayDBConnected() {
+			return nil, errors.New("relay database not connected")
+		}
+		event, err := h.db.GetEvent(ctx, id)
+		if err != nil {
+			return nil, errors.New("failed to look up event")
+		}
+		if event == nil {
+			return nil, errors.New("event not found")
+		}
+		if err := services.QueueAuthorDeletion(ctx, h.db, id, event.Pubkey, reason); err != nil {
+			return nil, errors.New("failed to ban event")
+		}
+		h.db.AddAuditLog(ctx, "event_banned", map[string]string{"event_id": id, "reason": reason, "source": "nip86"}, operator)
+		return true, nil
+
+	case "listbannedevents":
+		requests, err := h.db.GetDeletionRequests(ctx, "")
+		if err != nil {
+			return nil, errors.New("failed to list banned events")
+		}
+		list := []nip86EventReason{}
+		for _, req := range requests {
+			// Deletions requested by the author with a NIP-09 event are not bans
+			if !strings.HasPrefix(req.EventID, "admin-") {
+				continue
+			}
+			for _, id := range req.TargetEventIDs {
+				list = append(list, nip86EventReason{ID: id, Reason: req.Reason})
+			}
+		}
+		return list, nil
+
+	case "changerelayname", "changerelaydescription", "changerelayicon":
+		value, err := nip86String(req.Params, 0)
+		if err != nil {
+			return nil, err
+		}
+		return true, h.changeRelayInfo(ctx, operator, req.Method, value)
+
+	case "allowkind", "disallowkind":
+		var kind int
+		if len(req.Params) == 0 || json.Unmarshal(req.Params[0], &kind) != nil || kind < 0 || kind > 65535 {
+			return nil, errors.New("expected a kind number")
+		}
+		return true, h.changeAllowedKinds(ctx, operator, req.Method == "allowkind", kind)
+
+	case "listallowedkinds":
+		if h.configMgr == nil {
+			return nil, errors.New("config manager not available")
+		}
+		cfg, err := h.configMgr.Read()
+		if err != nil {
+			return nil, errors.New("failed to read config")
+		}
+		kinds := cfg.Authorization.EventKindAllowlist
+		if kinds == nil {
+			kinds = []int{}
+		}
+		return kinds, nil
+	}
+
+	return nil, fmt.Errorf("unsupported method %q", req.Method)
+}
+
+// changeRelayInfo sets the relay's name, description or icon in config.toml.
+func (h *Handler) changeRelayInfo(ctx context.Context, operator, method, value string) error {
+	value = strings.TrimSpace(value)
+	switch {
+	case method == "changerelayname" && len(value) > 64:
+		return errors.New("name must be 64 characters or less")
+	case method == "changerelaydescription" && len(value) > 500:
+		return errors.New("description must be 500 characters or less")
+	case method == "changerelayicon" && value != "" && !isValidWebhookURL(value):
+		return errors.New("icon must be an http(s) URL")
+	}
+
+	err := h.updateRelayConfig(ctx, operator, "NIP-86 "+method, func(cfg *relay.Config) error {
+		switch method {
+		case "changerelayname":
+			cfg.Info.Name = value
+		case "changerelaydescription":
+			cfg.Info.Description = value
+		case "changerelayicon":
+			cfg.Info.RelayIcon = value
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	h.db.AddAuditLog(ctx, "relay_info_updated", map[string]string{
+		strings.TrimPrefix(method, "changerelay"): value,
+		"source": "nip86",
+	}, operator)
+	return nil
+}
+
+// changeAllowedKinds adds a kind to or removes it from the relay's event kind
+// allowlist. An empty allowlist accepts every kind, so the last allowed kind
+// cannot be disallowed.
+func (h *Handler) changeAllowedKinds(ctx context.Context, operator string, allow bool, kind int) error {
+	var kinds []int
+	reason := fmt.Sprintf("NIP-86 disallowkind %d", kind)
+	if allow {
+		reason = fmt.Sprintf("NIP-86 allowkind %d", kind)
+	}
+	err := h.updateRelayConfig(ctx, operator, reason, func(cfg *relay.Config) error {
+		kinds = cfg.Authorization.EventKindAllowlist
+		if allow {
+			if !slices.Contains(kinds, kind) {
+				kinds = append(kinds, kind)
+				slices.Sort(kinds)
+			}
+		} else {
+			if len(kinds) == 0 {
+				return errors.New("the relay accepts all kinds; allow the kinds to keep instead")
+			}
+			kinds = slices.DeleteFunc(kinds, func(k int) bool { return k == kind })
+			if len(kinds) == 0 {
+				return errors.New("cannot disallow the last allowed kind; an empty allowlist accepts all kinds")
+			}
+		}
+		cfg.Authorization.EventKindAllowlist = kinds
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	h.db.AddAuditLog(ctx, "config_updated", map[string]interface{}{
+		"event_kind_allowlist": kinds,
+		"source":               "nip86",
+	}, operator)
+	return nil
+}
+
+// updateRelayConfig applies change to config.toml on the operator's behalf
+// and reloads the relay. Nothing is written if change fails.
+func (h *Handler) updateRelayConfig(ctx context.Context, operator, reason string, change func(cfg *relay.Config) error) error {
+	if h.configMgr == nil {
+		return errors.New("config manager not available")
+	}
+	cfg, err := h.configMgr.Read()
+	if err != nil {
+		return errors.New("failed to read config")
+	}
+	if err := change(cfg); err != nil {
+		return err
+	}
+	if err := h.configMgr.WriteAs(cfg, operator, reason); err != nil {
+		return errors.New("failed to write config")
+	}
+	if h.relay != nil {
+		if err := h.relay.Reload(); err != nil {
+			slog.WarnContext(ctx, "Failed to reload relay", "error", err)
+		}
+	}
+	return nil
+}
+
+// afterAccessChange syncs the whitelist and blacklist to config.toml.
+func (h *Handler) afterAccessChange(ctx context.Context) {
+	if err := h.syncConfigFromDB(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
+	}
+}
+
+// validateHTTPAuth checks a NIP-98 Authorization header for a request to
+// host, returning the signer's pubkey, or a reason when it is invalid. The
+// payload tag must hash the body.
+func validateHTTPAuth(header, method, host string, body []byte, now time.Time) (string, string) {
+	encoded, ok := strings.CutPrefix(header, "Nostr ")
+	if !ok {
+		return "", "missing NIP-98 Authorization header"
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "invalid: Authorization is not base64"
+	}
+	var event nostr.SyncEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return "", "invalid: Authorization is not an event"
+	}
+
+	if event.Kind != httpAuthKind {
+		return "", "invalid: auth event must be kind 27235"
+	}
+	created := time.Unix(event.CreatedAt, 0)
+	if created.Before(now.Add(-httpAuthMaxClockSkew)) || created.After(now.Add(httpAuthMaxClockSkew)) {
+		return "", "invalid: auth event created_at is too far from now"
+	}
+
+	var gotURL, gotMethod, gotPayload string
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "u":
+			gotURL = tag[1]
+		case "method":
+			gotMethod = tag[1]
+		case "payload":
+			gotPayload = tag[1]
+		}
+	}
+	if u, err := url.Parse(gotURL); err != nil || !strings.EqualFold(u.Host, host) {
+		return "", "invalid: u tag does not match this relay"
+	}
+	if !strings.EqualFold(gotMethod, method) {
+		return "", "invalid: method tag does not match the request"
+	}
+	sum := sha256.Sum256(body)
+	if !strings.EqualFold(gotPayload, hex.EncodeToString(sum[:])) {
+		return "", "invalid: payload tag does not match the request body"
+	}
+
+	if err := event.Verify(); err != nil {
+		return "", "invalid: bad signature"
+	}
+	return event.Pubkey, ""
+}
+
+// nip86String returns the string parameter at i.
+func nip86String(params []json.RawMessage, i int) (string, error) {
+	var s string
+	if i >= len(params) || json.Unmarshal(params[i], &s) != nil {
+		return "", fmt.Errorf("expected a string as parameter %d", i+1)
+	}
+	return s, nil
+}
+
+// nip86OptionalString returns the string parameter at i, or "".
+func nip86OptionalString(params []json.RawMessage, i int) string {
+	s, _ := nip86String(params, i)
+	return s
+}
+
+// nip86Pubkey returns the first parameter as a hex pubkey and npub.
+func nip86Pubkey(params []json.RawMessage) (string, string, error) {
+	s, err := nip86String(params, 0)
+	if err != nil {
+		return "", "", err
+	}
+	pubkey, npub, err := resolvePubkey(s, "")
+	if err != nil {
+		return "", "", errors.New("invalid pubkey")
+	}
+	return pubkey, npub, nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func httpAuthHeader(t *testing.T, secret string, tags [][]string, createdAt time.Time) string {
+	t.Helper()
+	event := nostr.NewEvent(httpAuthKind, tags, "")
+	event.CreatedAt = createdAt.Unix()
+	if err := event.Sign(secret); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	raw, _ := json.Marshal(event)
+	return "Nostr " + base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestValidateHTTPAuth(t *testing.T) {
+	now := time.Now()
+	secret, err := nostr.GenerateSecretKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretKey failed: %v", err)
+	}
+	pubkey, _ := nostr.GetPublicKey(secret)
+
+	body := []byte(`{"method":"supportedmethods","params":[]}`)
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	tags := func(u, method, payload string) [][]string {
+		return [][]string{{"u", u}, {"method", method}, {"payload", payload}}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		header := httpAuthHeader(t, secret, tags("https://relay.example.com/", "POST", payload), now)
+		got, reason := validateHTTPAuth(header, "POST", "relay.example.com", body, now)
+		if reason != "" || got != pubkey {
+			t.Errorf("expected %s, got %q (%q)", pubkey, got, reason)
+		}
+	})
+
+	invalid := map[string]string{
+		"missing":      "",
+		"not base64":   "Nostr %%%",
+		"other host":   httpAuthHeader(t, secret, tags("https://evil.example.com/", "POST", payload), now),
+		"other method": httpAuthHeader(t, secret, tags("https://relay.example.com/", "GET", payload), now),
+		"other body":   httpAuthHeader(t, secret, tags("https://relay.example.com/", "POST", hex.EncodeToString(make([]byte, 32))), now),
+		"no payload":   httpAuthHeader(t, secret, [][]string{{"u", "https://relay.example.com/"}, {"method", "POST"}}, now),
+		"stale":        httpAuthHeader(t, secret, tags("https://relay.example.com/", "POST", payload), now.Add(-5*time.Minute)),
+	}
+	for name, header := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, reason := validateHTTPAuth(header, "POST", "relay.example.com", body, now); reason == "" {
+				t.Error("expected the header to be rejected")
+			}
+		})
+	}
+
+	t.Run("tampered", func(t *testing.T) {
+		event := nostr.NewEvent(httpAuthKind, tags("https://relay.example.com/", "POST", payload), "")
+		event.CreatedAt = now.Unix()
+		event.Sign(secret)
+		event.Content = "changed"
+		raw, _ := json.Marshal(event)
+		header := "Nostr " + base64.StdEncoding.EncodeToString(raw)
+		if _, reason := validateHTTPAuth(header, "POST", "relay.example.com", body, now); reason != "invalid: bad signature" {
+			t.Errorf("expected a bad signature, got %q", reason)
+		}
+	})
+}
+
+func TestNIP86Params(t *testing.T) {
+	var req nip86Request
+	json.Unmarshal([]byte(`{"method":"banpubkey","params":["npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6","spam"]}`), &req)
+
+	pubkey, npub, err := nip86Pubkey(req.Params)
+	if err != nil || pubkey != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" || npub == "" {
+		t.Errorf("unexpected pubkey %q %q (%v)", pubkey, npub, err)
+	}
+	if reason := nip86OptionalString(req.Params, 1); reason != "spam" {
+		t.Errorf("expected reason spam, got %q", reason)
+	}
+	if reason := nip86OptionalString(req.Params, 2); reason != "" {
+		t.Errorf("expected no reason, got %q", reason)
+	}
+
+	json.Unmarshal([]byte(`{"method":"banpubkey","params":[42]}`), &req)
+	if _, _, err := nip86Pubkey(req.Params); err == nil {
+		t.Error("expected a non-string pubkey to be rejected")
+	}
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// maxBackupIntervalHours caps how long the backup worker waits to republish
+// an unchanged backup (30 days).
+const maxBackupIntervalHours = 720
+
+// GetNostrBackup returns the Nostr backup settings, the pubkey backups are
+// signed with, and the last published backup.
+// GET /api/v1/backup/nostr
+func (h *Handler) GetNostrBackup(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.NostrBackup == nil {
+		respondError(w, http.StatusServiceUnavailable, "Backup service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	settings, err := h.db.GetNostrBackupSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get backup settings", "DB_ERROR")
+		return
+	}
+	last, err := h.db.GetLastNostrBackup(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get last backup", "DB_ERROR")
+		return
+	}
+	backupPubkey, err := h.services.NostrBackup.BackupPubkey(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get backup key", "DB_ERROR")
+		return
+	}
+	backupNpub, _ := nostr.EncodeNpub(backupPubkey)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"settings":      settings,
+		"backup_pubkey": backupPubkey,
+		"backup_npub":   backupNpub,
+		"last_backup":   last,
+	})
+}
+
+// UpdateNostrBackup saves the Nostr backup settings and checks whether a
+// backup is due.
+// PUT /api/v1/backup/nostr
+func (h *Handler) UpdateNostrBackup(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.NostrBackup == nil {
+		respondError(w, http.StatusServiceUnavailable, "Backup service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	var req db.NostrBackupSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	relays, ok := normalizeBackupRelays(req.Relays)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid relay URL. Must start with wss:// or ws://", "INVALID_URL")
+		return
+	}
+	req.Relays = relays
+
+	if req.IntervalHours == 0 {
+		req.IntervalHours = 24
+	}
+	if req.IntervalHours < 1 || req.IntervalHours > maxBackupIntervalHours {
+		respondError(w, http.StatusBadRequest, "interval_hours must be between 1 and 720", "INVALID_INTERVAL")
+		return
+	}
+
+	if err := h.db.SetNostrBackupSettings(ctx, &req); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save backup settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "nostr_backup_updated", map[string]interface{}{
+		"enabled": req.Enabled,
+		"relays":  req.Relays,
+	}, "")
+
+	h.services.NostrBackup.Wake()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"settings": req,
+	})
+}
+
+// PublishNostrBackup publishes a backup now, even if nothing changed.
+// POST /api/v1/backup/nostr/publish
+func (h *Handler) PublishNostrBackup(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.NostrBackup == nil {
+		respondError(w, http.StatusServiceUnavailable, "Backup service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	record, err := h.services.NostrBackup.Publish(r.Context())
+	switch {
+	case errors.Is(err, services.ErrNoOperator):
+		respondError(w, http.StatusConflict, "Complete setup before publishing a backup", "NO_OPERATOR")
+		return
+	case errors.Is(err, services.ErrNoBackupRelays):
+		respondError(w, http.StatusBadRequest, "No backup relays configured", "NO_RELAYS")
+		return
+	case err != nil && record != nil:
+		respondErrorWithDetails(w, http.StatusBadGateway, "Backup was not accepted by any relay", "PUBLISH_FAILED", record.Relays)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to publish Nostr backup", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to publish backup", "PUBLISH_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, record)
+}
+
+// FetchBackupRequest is the request body for finding a backup during setup.
+type FetchBackupRequest struct {
+	OperatorIdentity string   `json:"operator_identity"`
+	Relays           []string `json:"relays"` // Defaults to the default sync relays
+}
+
+// FetchNostrBackup finds the operator's latest backup on the given relays.
+// The event is returned still encrypted; the client decrypts it with the
+// operator's signer (NIP-04, from the event's pubkey) and posts the result
+// to /api/v1/setup/restore.
+// POST /api/v1/setup/restore/fetch
+func (h *Handler) FetchNostrBackup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if completed, _ := h.db.IsSetupCompleted(ctx); completed {
+		respondError(w, http.StatusConflict, "Setup already completed", "SETUP_ALREADY_DONE")
+		return
+	}
+
+	var req FetchBackupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	pubkey, _, _, _, err := nostr.ResolveIdentity(ctx, req.OperatorIdentity)
+	if err != nil {
+		respondErrorWithDetails(w, http.StatusBadRequest, "Invalid operator identity", "INVALID_IDENTITY", err.Error())
+		return
+	}
+
+	relays, ok := normalizeBackupRelays(req.Relays)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid relay URL. Must start with wss:// or ws://", "INVALID_URL")
+		return
+	}
+	if len(relays) == 0 {
+		relays = services.DefaultSyncRelays
+	}
+
+	found, err := services.FetchLatestNostrBackup(ctx, pubkey, relays)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "No backup found on the given relays", "BACKUP_NOT_FOUND")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, found)
+}
+
+// RestoreBackupRequest is the request body for restoring from a backup.
+type RestoreBackupRequest struct {
+	Backup *services.NostrBackup `json:"backup"` // Decrypted event content
+}
+
+// RestoreNostrBackup restores the whitelist and settings from a decrypted
+// backup and completes setup.
+// POST /api/v1/setup/restore
+func (h *Handler) RestoreNostrBackup(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.NostrBackup == nil {
+		respondError(w, http.StatusServiceUnavailable, "Backup service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	if completed, _ := h.db.IsSetupCompleted(ctx); completed {
+		respondError(w, http.StatusConflict, "Setup already completed", "SETUP_ALREADY_DONE")
+		return
+	}
+
+	var req RestoreBackupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Backup == nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	backup := req.Backup
+
+	if !nostr.IsValidHexPubkey(backup.OperatorPubkey) {
+		respondError(w, http.StatusBadRequest, "Backup has no valid operator pubkey", "INVALID_BACKUP")
+		return
+	}
+	switch backup.AccessMode {
+	case "", "whitelist", "paid", "blacklist", "open":
+	default:
+		respondError(w, http.StatusBadRequest, "Backup has an invalid access mode", "INVALID_BACKUP")
+		return
+	}
+	if invalid := invalidKinds(backup.ExcludedKinds); len(invalid) > 0 {
+		respondError(w, http.StatusBadRequest, "Backup has invalid excluded kinds", "INVALID_BACKUP")
+		return
+	}
+
+	if err := h.services.NostrBackup.Restore(ctx, backup); err != nil {
+		slog.ErrorContext(ctx, "Failed to restore backup", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to restore backup", "RESTORE_FAILED")
+		return
+	}
+
+	// Push the restored lists and excluded kinds to the relay config
+	if h.services.Residency != nil {
+		if err := h.services.Residency.SetExcludedKinds(ctx, backup.ExcludedKinds); err != nil {
+			slog.WarnContext(ctx, "Failed to sync excluded kinds to relay config", "error", err)
+		}
+	}
+	if err := h.syncConfigFromDB(nil); err != nil {
+		slog.WarnContext(ctx, "Failed to sync restored whitelist to relay config", "error", err)
+	}
+
+	if err := h.db.SetSetupCompleted(ctx); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to complete setup", "COMPLETE_FAILED")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "setup_restored", map[string]interface{}{
+		"operator":    backup.OperatorPubkey,
+		"members":     len(backup.Whitelist),
+		"backup_at":   backup.CreatedAt,
+		"access_mode": backup.AccessMode,
+	}, backup.OperatorPubkey)
+
+	mode, _ := h.db.GetAccessMode(ctx)
+	npub, _ := nostr.EncodeNpub(backup.OperatorPubkey)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":         true,
+		"message":         "Setup restored from backup",
+		"operator_pubkey": backup.OperatorPubkey,
+		"operator_npub":   npub,
+		"access_mode":     mode,
+		"whitelist_count": len(backup.Whitelist),
+		"blacklist_count": len(backup.Blacklist),
+	})
+}
+
+// normalizeBackupRelays trims and de-duplicates relay URLs. It reports false
+// if any URL is not a WebSocket URL.
+func normalizeBackupRelays(relays []string) ([]string, bool) {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, u := range relays {
+		u = strings.TrimRight(strings.TrimSpace(u), "/")
+		if u == "" || seen[u] {
+			continue
+		}
+		if !isValidRelayURL(u) {
+			return nil, false
+		}
+		seen[u] = true
+		normalized = append(normalized, u)
+	}
+	return normalized, true
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import "testing"
+
+func TestNormalizeBackupRelays(t *testing.T) {
+	relays, ok := normalizeBackupRelays([]string{" wss://relay.example.com/ ", "wss://relay.example.com", "", "ws://localhost:7000"})
+	if !ok {
+		t.Fatal("expected valid relays")
+	}
+	if len(relays) != 2 || relays[0] != "wss://relay.example.com" || relays[1] != "ws://localhost:7000" {
+		t.Errorf("unexpected relays: %v", relays)
+	}
+
+	if _, ok := normalizeBackupRelays([]string{"https://relay.example.com"}); ok {
+		t.Error("expected https URL to be rejected")
+	}
+
+	if relays, ok := normalizeBackupRelays(nil); !ok || relays == nil || len(relays) != 0 {
+		t.Errorf("expected empty list for nil input, got %v", relays)
+	}
+}
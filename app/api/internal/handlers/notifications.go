@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/secrets"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// Bounds for a member's mention batch window, in minutes.
+const (
+	minMentionBatchMinutes     = 5
+	maxMentionBatchMinutes     = 1440
+	defaultMentionBatchMinutes = 60
+)
+
+// GetMentionNotifications returns the mention notification settings, the
+// pubkey DMs are sent from, and every member's subscription.
+// GET /api/v1/notifications/mentions
+func (h *Handler) GetMentionNotifications(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Notifier == nil {
+		respondError(w, http.StatusServiceUnavailable, "Notification service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	settings, err := h.db.GetMentionSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get mention settings", "DB_ERROR")
+		return
+	}
+	subs, err := h.db.GetMentionSubscriptions(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get mention subscriptions", "DB_ERROR")
+		return
+	}
+	notifierPubkey, err := h.services.Notifier.Pubkey(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get notification key", "DB_ERROR")
+		return
+	}
+	notifierNpub, _ := nostr.EncodeNpub(notifierPubkey)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"settings":        settings,
+		"notifier_pubkey": notifierPubkey,
+		"notifier_npub":   notifierNpub,
+		"email_available": h.services.Notifier.EmailAvailable(),
+		"subscriptions":   subs,
+	})
+}
+
+// UpdateMentionNotifications turns the mention notification service on or off.
+// Turning it on only reports mentions stored from then on.
+// PUT /api/v1/notifications/mentions
+func (h *Handler) UpdateMentionNotifications(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Mentions == nil {
+		respondError(w, http.StatusServiceUnavailable, "Notification service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	var req db.MentionSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	current, err := h.db.GetMentionSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get mention settings", "DB_ERROR")
+		return
+	}
+	if req.Enabled && !current.Enabled {
+		// Start from the newest event rather than mentions stored while disabled
+		if err := h.db.SetMentionCursor(ctx, 0); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to save mention settings", "DB_ERROR")
+			return
+		}
+	}
+
+	if err := h.db.SetMentionSettings(ctx, &req); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save mention settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "mention_notifications_updated", map[string]interface{}{
+		"enabled": req.Enabled,
+	}, "")
+
+	h.services.Mentions.Wake()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"settings": req,
+	})
+}
+
+// MentionSubscriptionRequest is the request body for a member's mention
+// notification preferences.
+type MentionSubscriptionRequest struct {
+	Enabled      *bool  `json:"enabled"` // Defaults to true
+	Channel      string `json:"channel"` // dm (default) or email
+	Email        string `json:"email"`
+	QuietStart   string `json:"quiet_start"` // HH:MM
+	QuietEnd     string `json:"quiet_end"`   // HH:MM
+	Timezone     string `json:"timezone"`    // IANA name, defaults to UTC
+	BatchMinutes int    `json:"batch_minutes"`
+}
+
+// SaveMentionSubscription opts a member in to mention notifications or
+// updates their preferences.
+// PUT /api/v1/notifications/mentions/{pubkey}
+func (h *Handler) SaveMentionSubscription(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Notifier == nil {
+		respondError(w, http.StatusServiceUnavailable, "Notification service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	pubkey, _, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey (expected hex or npub)", "INVALID_PUBKEY")
+		return
+	}
+	pubkey = strings.ToLower(pubkey)
+
+	var req MentionSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	sub, code, msg := buildMentionSubscription(pubkey, &req)
+	if code != "" {
+		respondError(w, http.StatusBadRequest, msg, code)
+		return
+	}
+	if sub.Channel == services.NotifyChannelEmail && !h.services.Notifier.EmailAvailable() {
+		respondError(w, http.StatusBadRequest, "Email is not configured on this relay", "EMAIL_NOT_CONFIGURED")
+		return
+	}
+
+	ctx := r.Context()
+
+	member, err := h.db.GetWhitelistEntryByPubkey(ctx, pubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to look up member", "DB_ERROR")
+		return
+	}
+	if member == nil {
+		respondError(w, http.StatusNotFound, "Pubkey is not on the whitelist", "NOT_A_MEMBER")
+		return
+	}
+
+	if err := h.db.SaveMentionSubscription(ctx, sub); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save subscription", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "mention_subscription_saved", map[string]interface{}{
+		"pubkey":  pubkey,
+		"enabled": sub.Enabled,
+		"channel": sub.Channel,
+	}, "")
+
+	saved, err := h.db.GetMentionSubscription(ctx, pubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get subscription", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, saved)
+}
+
+// DeleteMentionSubscription opts a member out and drops their queued mentions.
+// DELETE /api/v1/notifications/mentions/{pubkey}
+func (h *Handler) DeleteMentionSubscription(w http.ResponseWriter, r *http.Request) {
+	pubkey, _, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey (expected hex or npub)", "INVALID_PUBKEY")
+		return
+	}
+	pubkey = strings.ToLower(pubkey)
+
+	ctx := r.Context()
+
+	sub, err := h.db.GetMentionSubscription(ctx, pubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get subscription", "DB_ERROR")
+		return
+	}
+	if sub == nil {
+		respondError(w, http.StatusNotFound, "Subscription not found", "NOT_FOUND")
+		return
+	}
+
+	if err := h.db.DeleteMentionSubscription(ctx, pubkey); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete subscription", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "mention_subscription_deleted", map[string]interface{}{
+		"pubkey": pubkey,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Subscription removed",
+	})
+}
+
+// buildMentionSubscription validates a subscription request and fills in
+// defaults. On failure it returns an error code and message.
+func buildMentionSubscription(pubkey string, req *MentionSubscriptionRequest) (*db.MentionSubscription, string, string) {
+	sub := &db.MentionSubscription{
+		Pubkey:       pubkey,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+		Channel:      req.Channel,
+		Email:        strings.TrimSpace(req.Email),
+		QuietStart:   req.QuietStart,
+		QuietEnd:     req.QuietEnd,
+		Timezone:     req.Timezone,
+		BatchMinutes: req.BatchMinutes,
+	}
+
+	switch sub.Channel {
+	case "":
+		sub.Channel = services.NotifyChannelDM
+	case services.NotifyChannelDM:
+	case services.NotifyChannelEmail:
+		if !validEmail(sub.Email) {
+			return nil, "INVALID_EMAIL", "A valid email address is required for the email channel"
+		}
+	default:
+		return nil, "INVALID_CHANNEL", "channel must be dm or email"
+	}
+	if sub.Channel != services.NotifyChannelEmail {
+		sub.Email = ""
+	}
+
+	if (sub.QuietStart == "") != (sub.QuietEnd == "") {
+		return nil, "INVALID_QUIET_HOURS", "quiet_start and quiet_end must be set together"
+	}
+	if sub.QuietStart != "" {
+		if _, ok := services.ParseClock(sub.QuietStart); !ok {
+			return nil, "INVALID_QUIET_HOURS", "quiet_start must be HH:MM"
+		}
+		if _, ok := services.ParseClock(sub.QuietEnd); !ok {
+			return nil, "INVALID_QUIET_HOURS", "quiet_end must be HH:MM"
+		}
+	}
+
+	if sub.Timezone == "" {
+		sub.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(sub.Timezone); err != nil {
+		return nil, "INVALID_TIMEZONE", "Unknown timezone"
+	}
+
+	if sub.BatchMinutes == 0 {
+		sub.BatchMinutes = defaultMentionBatchMinutes
+	}
+	if sub.BatchMinutes < minMentionBatchMinutes || sub.BatchMinutes > maxMentionBatchMinutes {
+		return nil, "INVALID_BATCH", "batch_minutes must be between 5 and 1440"
+	}
+
+	return sub, "", ""
+}
+
+// EmailSettingsResponse is the mail server in use, with the password
+// redacted.
+type EmailSettingsResponse struct {
+	Source         string           `json:"source"` // saved, config, or none
+	Settings       *db.SMTPSettings `json:"settings"`
+	EmailAvailable bool             `json:"email_available"`
+}
+
+// GetEmailSettings returns the mail server used for email notifications:
+// the settings saved from the UI, or else the config file's.
+// GET /api/v1/notifications/email
+func (h *Handler) GetEmailSettings(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Notifier == nil {
+		respondError(w, http.StatusServiceUnavailable, "Notification service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	saved, err := h.db.GetSMTPSettings(ctx)
+	if err != nil {
+		if errors.Is(err, secrets.ErrLocked) {
+			respondSecretsError(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get email settings", "DB_ERROR")
+		return
+	}
+
+	response := EmailSettingsResponse{Source: "saved", Settings: saved}
+	if saved == nil {
+		cfg, _ := h.services.Notifier.SMTP(ctx)
+		response.Source = "config"
+		if cfg.Host == "" {
+			response.Source = "none"
+		}
+		response.Settings = &db.SMTPSettings{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			TLS:      cfg.TLS,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+		}
+	}
+	if response.Settings.Password != "" {
+		response.Settings.Password = redactedSecret
+	}
+	response.EmailAvailable = h.services.Notifier.EmailAvailable()
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// UpdateEmailSettings saves the mail server, used in place of the config
+// file's. A password left empty or sent back redacted keeps the saved one.
+// PUT /api/v1/notifications/email
+func (h *Handler) UpdateEmailSettings(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Notifier == nil {
+		respondError(w, http.StatusServiceUnavailable, "Notification service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	var req db.SMTPSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if code, msg := normalizeSMTPSettings(&req); code != "" {
+		respondError(w, http.StatusBadRequest, msg, code)
+		return
+	}
+
+	saved, err := h.db.GetSMTPSettings(ctx)
+	if err != nil {
+		respondSecretsError(w, err)
+		return
+	}
+	keepSMTPPassword(&req, saved)
+
+	if err := h.db.SetSMTPSettings(ctx, &req); err != nil {
+		respondSecretsError(w, err)
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "email_settings_updated", map[string]interface{}{
+		"host":        req.Host,
+		"port":        req.Port,
+		"tls":         req.TLS,
+		"alert_email": req.AlertEmail,
+	}, "")
+
+	if req.Password != "" {
+		req.Password = redactedSecret
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"settings": req,
+	})
+}
+
+// DeleteEmailSettings removes the saved mail server, going back to the
+// config file's.
+// DELETE /api/v1/notifications/email
+func (h *Handler) DeleteEmailSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := h.db.SetSMTPSettings(ctx, nil); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete email settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "email_settings_deleted", nil, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Email settings removed",
+	})
+}
+
+// TestEmailRequest is the request body for sending a test email.
+type TestEmailRequest struct {
+	To       string           `json:"to"`       // Defaults to the alert address
+	Settings *db.SMTPSettings `json:"settings"` // Settings to try before saving; defaults to those in use
+}
+
+// SendTestEmail sends a test email, through the mail server in use or
+// through settings not saved yet.
+// POST /api/v1/notifications/email/test
+func (h *Handler) SendTestEmail(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Notifier == nil {
+		respondError(w, http.StatusServiceUnavailable, "Notification service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	var req TestEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	saved, err := h.db.GetSMTPSettings(ctx)
+	if err != nil {
+		respondSecretsError(w, err)
+		return
+	}
+
+	var cfg services.SMTPConfig
+	if req.Settings != nil {
+		if code, msg := normalizeSMTPSettings(req.Settings); code != "" {
+			respondError(w, http.StatusBadRequest, msg, code)
+			return
+		}
+		keepSMTPPassword(req.Settings, saved)
+		cfg = services.SMTPConfigFromSettings(req.Settings)
+		if req.To == "" {
+			req.To = req.Settings.AlertEmail
+		}
+	} else {
+		if cfg, err = h.services.Notifier.SMTP(ctx); err != nil {
+			respondSecretsError(w, err)
+			return
+		}
+		if req.To == "" && saved != nil {
+			req.To = saved.AlertEmail
+		}
+	}
+
+	req.To = strings.TrimSpace(req.To)
+	if !validEmail(req.To) {
+		respondError(w, http.StatusBadRequest, "A valid recipient address is required", "INVALID_EMAIL")
+		return
+	}
+
+	start := time.Now()
+	body := "This is a test email from Roostr. Email notifications are working."
+	err = h.services.Notifier.SendEmailWith(cfg, req.To, "Roostr test email", body, "text/plain")
+	if errors.Is(err, services.ErrEmailNotConfigured) {
+		respondError(w, http.StatusBadRequest, "Email is not configured on this relay", "EMAIL_NOT_CONFIGURED")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadGateway, "Test email failed: "+err.Error(), "SEND_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"message":     "Test email sent to " + req.To,
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+}
+
+// normalizeSMTPSettings validates mail server settings and fills in the TLS
+// mode and port. On failure it returns an error code and message.
+func normalizeSMTPSettings(s *db.SMTPSettings) (string, string) {
+	s.Host = strings.TrimSpace(s.Host)
+	s.From = strings.TrimSpace(s.From)
+	s.AlertEmail = strings.TrimSpace(s.AlertEmail)
+
+	if s.Host == "" || strings.ContainsAny(s.Host, " /:") {
+		return "INVALID_HOST", "host must be a mail server hostname"
+	}
+	switch s.TLS {
+	case "":
+		s.TLS = services.SMTPTLSStartTLS
+	case services.SMTPTLSStartTLS, services.SMTPTLSImplicit:
+	default:
+		return "INVALID_TLS", "tls must be starttls or tls"
+	}
+	if s.Port == "" {
+		s.Port = services.SMTPConfigFromSettings(s).Port
+	}
+	if port, err := strconv.Atoi(s.Port); err != nil || port < 1 || port > 65535 {
+		return "INVALID_PORT", "port must be between 1 and 65535"
+	}
+	if !validEmail(s.From) {
+		return "INVALID_FROM", "from must be an email address"
+	}
+	if s.AlertEmail != "" && !validEmail(s.AlertEmail) {
+		return "INVALID_EMAIL", "alert_email must be an email address"
+	}
+	return "", ""
+}
+
+// keepSMTPPassword restores the saved password when s leaves it empty or
+// sends it back redacted.
+func keepSMTPPassword(s *db.SMTPSettings, saved *db.SMTPSettings) {
+	if s.Password != "" && s.Password != redactedSecret {
+		return
+	}
+	s.Password = ""
+	if saved != nil {
+		s.Password = saved.Password
+	}
+}
+
+// validEmail reports whether addr is a bare email address.
+func validEmail(addr string) bool {
+	_, err := mail.ParseAddress(addr)
+	return err == nil && !strings.ContainsAny(addr, "<>")
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestBuildMentionSubscription(t *testing.T) {
+	pubkey := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+	sub, code, _ := buildMentionSubscription(pubkey, &MentionSubscriptionRequest{Email: "ignored@example.com"})
+	if code != "" {
+		t.Fatalf("unexpected error %s", code)
+	}
+	if !sub.Enabled || sub.Channel != "dm" || sub.Email != "" || sub.Timezone != "UTC" || sub.BatchMinutes != 60 {
+		t.Errorf("unexpected defaults: %+v", sub)
+	}
+
+	disabled := false
+	if sub, _, _ := buildMentionSubscription(pubkey, &MentionSubscriptionRequest{Enabled: &disabled}); sub.Enabled {
+		t.Error("expected enabled=false to be kept")
+	}
+
+	tests := []struct {
+		name string
+		req  MentionSubscriptionRequest
+		code string
+	}{
+		{"email", MentionSubscriptionRequest{Channel: "email", Email: "alice@example.com"}, ""},
+		{"missing_email", MentionSubscriptionRequest{Channel: "email"}, "INVALID_EMAIL"},
+		{"named_email", MentionSubscriptionRequest{Channel: "email", Email: "Alice <alice@example.com>"}, "INVALID_EMAIL"},
+		{"bad_channel", MentionSubscriptionRequest{Channel: "sms"}, "INVALID_CHANNEL"},
+		{"quiet_hours", MentionSubscriptionRequest{QuietStart: "22:00", QuietEnd: "07:00"}, ""},
+		{"half_quiet_hours", MentionSubscriptionRequest{QuietStart: "22:00"}, "INVALID_QUIET_HOURS"},
+		{"bad_quiet_hours", MentionSubscriptionRequest{QuietStart: "24:00", QuietEnd: "07:00"}, "INVALID_QUIET_HOURS"},
+		{"timezone", MentionSubscriptionRequest{Timezone: "Europe/Berlin"}, ""},
+		{"bad_timezone", MentionSubscriptionRequest{Timezone: "Mars/Olympus"}, "INVALID_TIMEZONE"},
+		{"batch_too_small", MentionSubscriptionRequest{BatchMinutes: 1}, "INVALID_BATCH"},
+		{"batch_too_large", MentionSubscriptionRequest{BatchMinutes: 2000}, "INVALID_BATCH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, code, _ := buildMentionSubscription(pubkey, &tt.req); code != tt.code {
+				t.Errorf("expected code %q, got %q", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestNormalizeSMTPSettings(t *testing.T) {
+	s := db.SMTPSettings{Host: " smtp.example.com ", From: "relay@example.com"}
+	if code, _ := normalizeSMTPSettings(&s); code != "" {
+		t.Fatalf("unexpected error %s", code)
+	}
+	if s.Host != "smtp.example.com" || s.TLS != "starttls" || s.Port != "587" {
+		t.Errorf("unexpected defaults: %+v", s)
+	}
+	s = db.SMTPSettings{Host: "smtp.example.com", TLS: "tls", From: "relay@example.com"}
+	if normalizeSMTPSettings(&s); s.Port != "465" {
+		t.Errorf("expected port 465 for implicit TLS, got %s", s.Port)
+	}
+
+	tests := []struct {
+		name     string
+		settings db.SMTPSettings
+		code     string
+	}{
+		{"missing_host", db.SMTPSettings{From: "relay@example.com"}, "INVALID_HOST"},
+		{"host_with_port", db.SMTPSettings{Host: "smtp.example.com:25", From: "relay@example.com"}, "INVALID_HOST"},
+		{"bad_tls", db.SMTPSettings{Host: "smtp.example.com", TLS: "ssl", From: "relay@example.com"}, "INVALID_TLS"},
+		{"bad_port", db.SMTPSettings{Host: "smtp.example.com", Port: "70000", From: "relay@example.com"}, "INVALID_PORT"},
+		{"missing_from", db.SMTPSettings{Host: "smtp.example.com"}, "INVALID_FROM"},
+		{"bad_alert_email", db.SMTPSettings{Host: "smtp.example.com", From: "relay@example.com", AlertEmail: "operator"}, "INVALID_EMAIL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := normalizeSMTPSettings(&tt.settings); code != tt.code {
+				t.Errorf("expected code %q, got %q", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestKeepSMTPPassword(t *testing.T) {
+	saved := &db.SMTPSettings{Password: "hunter2"}
+
+	for _, password := range []string{"", redactedSecret} {
+		s := db.SMTPSettings{Password: password}
+		if keepSMTPPassword(&s, saved); s.Password != "hunter2" {
+			t.Errorf("expected %q to keep the saved password, got %q", password, s.Password)
+		}
+	}
+	s := db.SMTPSettings{Password: "new"}
+	if keepSMTPPassword(&s, saved); s.Password != "new" {
+		t.Errorf("expected a new password to replace the saved one, got %q", s.Password)
+	}
+	s = db.SMTPSettings{Password: redactedSecret}
+	if keepSMTPPassword(&s, nil); s.Password != "" {
+		t.Errorf("expected no password without saved settings, got %q", s.Password)
+	}
+}
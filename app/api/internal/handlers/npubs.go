@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// errPubkeyNpubMismatch is returned when a request's pubkey and npub disagree.
+var errPubkeyNpubMismatch = errors.New("pubkey and npub do not match")
+
+// GetNpubCheck reports stored npubs that are missing or do not match their
+// hex pubkey, without changing anything.
+// GET /api/v1/storage/npub-check
+func (h *Handler) GetNpubCheck(w http.ResponseWriter, r *http.Request) {
+	report, err := services.CheckNpubs(r.Context(), h.db, true)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to check npubs", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// FixNpubs recomputes missing and mismatched npubs from their hex pubkeys.
+// POST /api/v1/storage/npub-check
+func (h *Handler) FixNpubs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	report, err := services.CheckNpubs(ctx, h.db, false)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fix npubs", "DB_ERROR")
+		return
+	}
+
+	if report.Fixed > 0 {
+		h.db.AddAuditLog(ctx, "npubs_fixed", map[string]interface{}{
+			"fixed":  report.Fixed,
+			"issues": len(report.Issues),
+		}, "")
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// resolvePubkey returns the lowercase hex pubkey and the npub derived from
+// it. pubkey may be hex or npub. A client-supplied npub is only used as a
+// check and must encode the same key.
+func resolvePubkey(pubkey, npub string) (string, string, error) {
+	var hexPubkey, encoded string
+	for _, input := range []string{pubkey, npub} {
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		pk, enc, err := nostr.ValidatePubkey(input)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid pubkey %q", input)
+		}
+		pk = strings.ToLower(pk)
+		if hexPubkey != "" && hexPubkey != pk {
+			return "", "", errPubkeyNpubMismatch
+		}
+		hexPubkey, encoded = pk, enc
+	}
+	if hexPubkey == "" {
+		return "", "", errors.New("missing pubkey")
+	}
+	return hexPubkey, encoded, nil
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestResolvePubkey(t *testing.T) {
+	pubkey := strings.Repeat("ab", 32)
+	npub, _ := nostr.EncodeNpub(pubkey)
+	otherNpub, _ := nostr.EncodeNpub(strings.Repeat("cd", 32))
+
+	for _, tt := range []struct{ name, pubkey, npub string }{
+		{"hex", pubkey, ""},
+		{"uppercase_hex", strings.ToUpper(pubkey), ""},
+		{"npub_in_pubkey", npub, ""},
+		{"npub_only", "", npub},
+		{"both", pubkey, npub},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPubkey, gotNpub, err := resolvePubkey(tt.pubkey, tt.npub)
+			if err != nil || gotPubkey != pubkey || gotNpub != npub {
+				t.Errorf("resolvePubkey() = %q, %q, %v", gotPubkey, gotNpub, err)
+			}
+		})
+	}
+
+	if _, _, err := resolvePubkey(pubkey, otherNpub); !errors.Is(err, errPubkeyNpubMismatch) {
+		t.Errorf("expected mismatch error, got %v", err)
+	}
+	if _, _, err := resolvePubkey("abc123", ""); err == nil {
+		t.Error("expected an error for an invalid pubkey")
+	}
+	if _, _, err := resolvePubkey("", ""); err == nil {
+		t.Error("expected an error for a missing pubkey")
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// GetPrivacySettings returns whether DM privacy mode is on.
+// GET /api/v1/settings/privacy
+func (h *Handler) GetPrivacySettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetPrivacySettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get privacy settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"redact_dms":         settings.RedactDMs,
+		"encrypted_dm_kinds": db.EncryptedDMKinds,
+	})
+}
+
+// UpdatePrivacySettings turns DM privacy mode on or off.
+// PUT /api/v1/settings/privacy
+func (h *Handler) UpdatePrivacySettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		RedactDMs *bool `json:"redact_dms"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if req.RedactDMs == nil {
+		respondError(w, http.StatusBadRequest, "redact_dms is required", "INVALID_SETTINGS")
+		return
+	}
+
+	previous, err := h.db.GetPrivacySettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get privacy settings", "DB_ERROR")
+		return
+	}
+	settings := &db.PrivacySettings{RedactDMs: *req.RedactDMs}
+	if err := h.db.SetPrivacySettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save privacy settings", "DB_ERROR")
+		return
+	}
+
+	if previous.RedactDMs != settings.RedactDMs {
+		h.db.AddAuditLog(ctx, "privacy_settings_updated", map[string]interface{}{
+			"redact_dms":          settings.RedactDMs,
+			"previous_redact_dms": previous.RedactDMs,
+		}, "")
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"redact_dms":         settings.RedactDMs,
+		"encrypted_dm_kinds": db.EncryptedDMKinds,
+	})
+}
+
+// redactDMs reports whether DM content must be withheld from responses.
+// If the setting can't be read it errs on the side of withholding.
+func (h *Handler) redactDMs(ctx context.Context) bool {
+	settings, err := h.db.GetPrivacySettings(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get privacy settings", "error", err)
+		return true
+	}
+	return settings.RedactDMs
+}
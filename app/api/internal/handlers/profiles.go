@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// ProfileResponse is a cached profile with the pubkey's npub.
+type ProfileResponse struct {
+	*db.Profile
+	Npub string `json:"npub"`
+}
+
+// GetProfile returns the cached kind-0 metadata for a pubkey. A pubkey that
+// was never looked up, or refresh=true, is looked up now on the local relay
+// and the sync relays.
+// GET /api/v1/profiles/{pubkey}?refresh=true
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	pubkey, npub, err := nostr.ValidatePubkey(r.PathValue("pubkey"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey (expected hex or npub)", "INVALID_PUBKEY")
+		return
+	}
+	pubkey = strings.ToLower(pubkey)
+
+	ctx := r.Context()
+
+	profile, err := h.db.GetProfile(ctx, pubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get profile", "DB_ERROR")
+		return
+	}
+
+	if profile == nil || r.URL.Query().Get("refresh") == "true" {
+		if h.services == nil || h.services.Profiles == nil {
+			respondError(w, http.StatusServiceUnavailable, "Profile service not available", "SERVICE_UNAVAILABLE")
+			return
+		}
+		updated, err := h.services.Profiles.Refresh(ctx, []string{pubkey})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to look up profile", "LOOKUP_FAILED")
+			return
+		}
+		profile = updated[pubkey]
+	}
+
+	if profile == nil || profile.Source == "" {
+		respondError(w, http.StatusNotFound, "No profile found for this pubkey", "PROFILE_NOT_FOUND")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, ProfileResponse{Profile: profile, Npub: npub})
+}
+
+// wakeProfiles asks the profile service to look up newly added pubkeys.
+func (h *Handler) wakeProfiles() {
+	if h.services == nil || h.services.Profiles == nil {
+		return
+	}
+	h.services.Profiles.Wake()
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetQuotaPolicies returns the per-tier quotas and the tiers they can apply
+// to.
+// GET /api/v1/access/quotas
+func (h *Handler) GetQuotaPolicies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	policies, err := h.db.GetQuotaPolicies(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get quotas", "DB_ERROR")
+		return
+	}
+	pricing, err := h.db.GetPricingTiers(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get pricing tiers", "DB_ERROR")
+		return
+	}
+
+	tiers := []KindPolicyTier{{ID: db.KindPolicyTierWhitelist, Name: "Whitelist"}}
+	for _, t := range pricing {
+		tiers = append(tiers, KindPolicyTier{ID: t.ID, Name: t.Name})
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"policies": policies,
+		"tiers":    tiers,
+	})
+}
+
+// UpdateQuotaPolicy sets how much the members of a tier may post.
+// PUT /api/v1/access/quotas/{tier}
+func (h *Handler) UpdateQuotaPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		EventsPerDay    int64  `json:"events_per_day"`
+		MaxStorageBytes int64  `json:"max_storage_bytes"`
+		Action          string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	policy := db.QuotaPolicy{
+		Tier:            r.PathValue("tier"),
+		EventsPerDay:    req.EventsPerDay,
+		MaxStorageBytes: req.MaxStorageBytes,
+		Action:          req.Action,
+	}
+
+	pricing, err := h.db.GetPricingTiers(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get pricing tiers", "DB_ERROR")
+		return
+	}
+	if err := services.ValidateQuotaPolicy(&policy, pricing); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_QUOTA")
+		return
+	}
+
+	policies, err := h.db.GetQuotaPolicies(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get quotas", "DB_ERROR")
+		return
+	}
+	replaced := false
+	for i, p := range policies {
+		if p.Tier == policy.Tier {
+			policies[i] = policy
+			replaced = true
+		}
+	}
+	if !replaced {
+		policies = append(policies, policy)
+	}
+	if err := h.db.SetQuotaPolicies(ctx, policies); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save quotas", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "quota_policy_updated", policy, "")
+	h.wakeQuotas()
+
+	respondJSON(w, http.StatusOK, policy)
+}
+
+// DeleteQuotaPolicy removes a tier's quota.
+// DELETE /api/v1/access/quotas/{tier}
+func (h *Handler) DeleteQuotaPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tier := r.PathValue("tier")
+
+	policies, err := h.db.GetQuotaPolicies(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get quotas", "DB_ERROR")
+		return
+	}
+	kept := policies[:0]
+	for _, p := range policies {
+		if p.Tier != tier {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(policies) {
+		respondError(w, http.StatusNotFound, "Quota not found", "NOT_FOUND")
+		return
+	}
+	if err := h.db.SetQuotaPolicies(ctx, kept); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save quotas", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "quota_policy_removed", map[string]interface{}{
+		"tier": tier,
+	}, "")
+	h.wakeQuotas()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Quota removed",
+	})
+}
+
+// wakeQuotas asks the quota service to measure usage against the changed
+// quotas now.
+func (h *Handler) wakeQuotas() {
+	if h.services != nil && h.services.Quotas != nil {
+		h.services.Quotas.Wake()
+	}
+}
@@ -73,12 +73,64 @@ func (h *Handler) RestartRelay(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// relayLogLevels ranks log levels for the level filter. WARNING is an alias
+// for WARN; unknown levels rank as INFO.
+var relayLogLevels = map[string]int{
+	"TRACE":   0,
+	"DEBUG":   1,
+	"INFO":    2,
+	"WARN":    3,
+	"WARNING": 3,
+	"ERROR":   4,
+}
+
+// parseMinLogLevel parses the level query parameter. An empty level returns
+// 0, which matches everything.
+func parseMinLogLevel(level string) (int, error) {
+	if level == "" {
+		return 0, nil
+	}
+	rank, ok := relayLogLevels[strings.ToUpper(level)]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q", level)
+	}
+	return rank, nil
+}
+
+// logLevelAtLeast reports whether a log entry's level is at or above minLevel.
+func logLevelAtLeast(level string, minLevel int) bool {
+	rank, ok := relayLogLevels[strings.ToUpper(level)]
+	if !ok {
+		rank = relayLogLevels["INFO"]
+	}
+	return rank >= minLevel
+}
+
 // GetRelayLogs returns recent log entries from the relay log buffer or file.
+// Query parameters: lines (or limit, default 100, max 1000), level (minimum
+// level to include) and follow (stream new entries via SSE instead).
 // GET /api/v1/relay/logs
 func (h *Handler) GetRelayLogs(w http.ResponseWriter, r *http.Request) {
-	// Parse limit parameter (default 100, max 1000)
+	query := r.URL.Query()
+
+	minLevel, err := parseMinLogLevel(query.Get("level"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid level. Must be: trace, debug, info, warn, or error", "INVALID_LEVEL")
+		return
+	}
+
+	if query.Get("follow") == "true" {
+		h.streamRelayLogs(w, r, minLevel)
+		return
+	}
+
+	// Parse lines parameter (default 100, max 1000); limit is the older name
 	limit := 100
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+	limitStr := query.Get("lines")
+	if limitStr == "" {
+		limitStr = query.Get("limit")
+	}
+	if limitStr != "" {
 		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
 			limit = parsed
 			if limit > 1000 {
@@ -89,20 +141,23 @@ func (h *Handler) GetRelayLogs(w http.ResponseWriter, r *http.Request) {
 
 	// First try to get logs from relay's in-memory buffer
 	if h.relay != nil {
-		relayEntries := h.relay.GetRecentLogs(limit)
+		// Filter the whole buffer so lines counts matching entries
+		relayEntries := h.relay.GetRecentLogs(0)
 		if len(relayEntries) > 0 {
 			// Convert relay.LogEntry to handler LogEntry
-			entries := make([]LogEntry, len(relayEntries))
-			for i, e := range relayEntries {
-				entries[i] = LogEntry{
-					Timestamp: e.Timestamp,
-					Level:     e.Level,
-					Message:   e.Message,
+			entries := make([]LogEntry, 0, limit)
+			for _, e := range relayEntries {
+				if len(entries) == limit {
+					break
+				}
+				if logLevelAtLeast(e.Level, minLevel) {
+					entries = append(entries, convertRelayLogEntry(e))
 				}
 			}
 			respondJSON(w, http.StatusOK, map[string]interface{}{
 				"logs":        entries,
 				"total_lines": len(entries),
+				"source":      "supervisor",
 			})
 			return
 		}
@@ -125,7 +180,7 @@ func (h *Handler) GetRelayLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read and parse log entries
-	entries, err := readLogFile(logPath, limit)
+	entries, err := readLogFile(logPath, limit, minLevel)
 	if err != nil {
 		// Log file may not exist yet or be inaccessible
 		respondJSON(w, http.StatusOK, map[string]interface{}{
@@ -139,11 +194,17 @@ func (h *Handler) GetRelayLogs(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"logs":        entries,
 		"total_lines": len(entries),
+		"source":      "file",
 	})
 }
 
 // getRelayLogPath determines the path to the relay's log file.
+// An explicitly configured RELAY_LOG_FILE takes precedence over the relay's
+// own logging folder.
 func (h *Handler) getRelayLogPath() (string, error) {
+	if cfg := h.currentConfig(); cfg != nil && cfg.RelayLogFile != "" {
+		return cfg.RelayLogFile, nil
+	}
 	if h.configMgr == nil {
 		return "", nil
 	}
@@ -198,8 +259,8 @@ func findMostRecentLogFile(folder, prefix string) (string, error) {
 	return logFiles[0], nil
 }
 
-// readLogFile reads the last N lines from a log file and parses them.
-func readLogFile(path string, limit int) ([]LogEntry, error) {
+// readLogFile returns the last N entries at or above minLevel from a log file.
+func readLogFile(path string, limit, minLevel int) ([]LogEntry, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -217,23 +278,13 @@ func readLogFile(path string, limit int) ([]LogEntry, error) {
 		return nil, err
 	}
 
-	// Get last N lines
-	startIdx := len(lines) - limit
-	if startIdx < 0 {
-		startIdx = 0
-	}
-	recentLines := lines[startIdx:]
-
-	// Parse lines into LogEntry structs
-	entries := make([]LogEntry, 0, len(recentLines))
-	for _, line := range recentLines {
-		entry := parseLogLine(line)
-		entries = append(entries, entry)
-	}
-
-	// Reverse so newest is first
-	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
-		entries[i], entries[j] = entries[j], entries[i]
+	// Walk back from the newest line until N matching entries are found
+	entries := make([]LogEntry, 0, limit)
+	for i := len(lines) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := parseLogLine(lines[i])
+		if logLevelAtLeast(entry.Level, minLevel) {
+			entries = append(entries, entry)
+		}
 	}
 
 	return entries, nil
@@ -242,10 +293,16 @@ func readLogFile(path string, limit int) ([]LogEntry, error) {
 // logLineRegex matches common log formats like:
 // [2025-12-13T14:32:01Z INFO nostr_rs_relay] Message here
 // 2025-12-13 14:32:01 INFO Message here
-var logLineRegex = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}[Z]?)\]?\s*(\w+)\s*(.*)$`)
+// 2025-12-13T14:32:01.123456Z  INFO nostr_rs_relay::server: Message here
+var logLineRegex = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?[Z]?)\]?\s*(\w+)\s*(.*)$`)
+
+// ansiEscapeRegex matches terminal color codes, which the relay writes even
+// when its output is not a terminal.
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
 
 // parseLogLine parses a log line into a LogEntry struct.
 func parseLogLine(line string) LogEntry {
+	line = ansiEscapeRegex.ReplaceAllString(line, "")
 	matches := logLineRegex.FindStringSubmatch(line)
 	if len(matches) >= 4 {
 		return LogEntry{
@@ -264,8 +321,20 @@ func parseLogLine(line string) LogEntry {
 }
 
 // StreamRelayLogs streams relay logs in real-time via Server-Sent Events (SSE).
+// It accepts the same level parameter as GetRelayLogs.
 // GET /api/v1/relay/logs/stream
 func (h *Handler) StreamRelayLogs(w http.ResponseWriter, r *http.Request) {
+	minLevel, err := parseMinLogLevel(r.URL.Query().Get("level"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid level. Must be: trace, debug, info, warn, or error", "INVALID_LEVEL")
+		return
+	}
+	h.streamRelayLogs(w, r, minLevel)
+}
+
+// streamRelayLogs streams entries at or above minLevel from the supervisor's
+// log buffer, or by tailing the log file.
+func (h *Handler) streamRelayLogs(w http.ResponseWriter, r *http.Request, minLevel int) {
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -303,6 +372,9 @@ func (h *Handler) StreamRelayLogs(w http.ResponseWriter, r *http.Request) {
 					if !ok {
 						return
 					}
+					if !logLevelAtLeast(entry.Level, minLevel) {
+						continue
+					}
 					// Send log entry as SSE event
 					data, _ := json.Marshal(convertRelayLogEntry(entry))
 					fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
@@ -366,6 +438,9 @@ func (h *Handler) StreamRelayLogs(w http.ResponseWriter, r *http.Request) {
 				for scanner.Scan() {
 					line := scanner.Text()
 					entry := parseLogLine(line)
+					if !logLevelAtLeast(entry.Level, minLevel) {
+						continue
+					}
 					// Send as SSE event
 					fmt.Fprintf(w, "event: log\ndata: {\"timestamp\":%q,\"level\":%q,\"message\":%q}\n\n",
 						entry.Timestamp, entry.Level, entry.Message)
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/secrets"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// maxAnnouncementIntervalHours caps how long an unchanged announcement is
+// left before it is refreshed (7 days), so discovery sites see it as live.
+const maxAnnouncementIntervalHours = 168
+
+// geohashAlphabet is the base32 alphabet of geohashes.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GetRelayAnnouncement returns the announcement settings, the listing that
+// would be published, how it is signed, and the last announcement.
+// GET /api/v1/relay/announce
+func (h *Handler) GetRelayAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Announcement == nil || h.services.Signer == nil {
+		respondError(w, http.StatusServiceUnavailable, "Announcement service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	settings, err := h.db.GetRelayAnnouncementSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get announcement settings", "DB_ERROR")
+		return
+	}
+	last, err := h.db.GetLastRelayAnnouncement(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get last announcement", "DB_ERROR")
+		return
+	}
+
+	response := map[string]interface{}{
+		"settings":          settings,
+		"last_announcement": last,
+		"signer":            services.SignerModeNone,
+	}
+	if status, err := h.services.Signer.Status(ctx); err == nil {
+		response["signer"] = status.Mode
+	} else if errors.Is(err, secrets.ErrLocked) {
+		response["signer"] = "locked"
+	}
+	if announcement, err := h.services.Announcement.Build(ctx, settings); err == nil {
+		response["preview"] = announcement
+	} else {
+		response["preview_error"] = err.Error()
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// UpdateRelayAnnouncement saves the announcement settings and publishes the
+// announcement if it is due.
+// PUT /api/v1/relay/announce
+func (h *Handler) UpdateRelayAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Announcement == nil {
+		respondError(w, http.StatusServiceUnavailable, "Announcement service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	var req db.RelayAnnouncementSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	relays, ok := normalizeBackupRelays(req.Relays)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid relay URL. Must start with wss:// or ws://", "INVALID_URL")
+		return
+	}
+	req.Relays = relays
+
+	req.RelayURL = strings.TrimRight(strings.TrimSpace(req.RelayURL), "/")
+	if req.RelayURL != "" && !isValidRelayURL(req.RelayURL) {
+		respondError(w, http.StatusBadRequest, "Invalid relay_url. Must start with wss:// or ws://", "INVALID_URL")
+		return
+	}
+
+	req.Geohash = strings.ToLower(strings.TrimSpace(req.Geohash))
+	if len(req.Geohash) > 12 || strings.Trim(req.Geohash, geohashAlphabet) != "" {
+		respondError(w, http.StatusBadRequest, "Invalid geohash", "INVALID_GEOHASH")
+		return
+	}
+
+	if req.IntervalHours == 0 {
+		req.IntervalHours = 24
+	}
+	if req.IntervalHours < 1 || req.IntervalHours > maxAnnouncementIntervalHours {
+		respondError(w, http.StatusBadRequest, "interval_hours must be between 1 and 168", "INVALID_INTERVAL")
+		return
+	}
+
+	if err := h.db.SetRelayAnnouncementSettings(ctx, &req); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save announcement settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "relay_announcement_updated", map[string]interface{}{
+		"enabled":    req.Enabled,
+		"relay_url":  req.RelayURL,
+		"relays":     req.Relays,
+		"relay_list": req.RelayList,
+	}, "")
+
+	h.services.Announcement.Wake()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"settings": req,
+	})
+}
+
+// PublishRelayAnnouncement publishes the announcement now, even if nothing
+// changed.
+// POST /api/v1/relay/announce
+func (h *Handler) PublishRelayAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Announcement == nil {
+		respondError(w, http.StatusServiceUnavailable, "Announcement service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	record, err := h.services.Announcement.Publish(r.Context())
+	switch {
+	case errors.Is(err, services.ErrNoRelayURL):
+		respondError(w, http.StatusBadRequest, err.Error(), "NO_RELAY_URL")
+		return
+	case errors.Is(err, services.ErrNoSigner):
+		respondError(w, http.StatusConflict, "Pair a remote signer or delegate the operator's key first", "NO_SIGNER")
+		return
+	case errors.Is(err, secrets.ErrLocked):
+		respondSecretsError(w, err)
+		return
+	case err != nil && record != nil:
+		respondErrorWithDetails(w, http.StatusBadGateway, "Announcement was not accepted by any relay", "PUBLISH_FAILED", record.Relays)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to publish relay announcement", "error", err)
+		respondError(w, http.StatusBadGateway, "Failed to publish announcement: "+err.Error(), "PUBLISH_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, record)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetRelayIndexStatus returns the relay type and, for relays read through
+// an index such as strfry, the most recent index sync.
+// GET /api/v1/storage/relay-index
+func (h *Handler) GetRelayIndexStatus(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"relay_type": h.db.RelayType(),
+		"indexed":    h.db.RelayBackend() != nil,
+	}
+	if h.services != nil && h.services.RelayIndex != nil {
+		if status := h.services.RelayIndex.Status(); status != nil {
+			response["last_sync"] = status
+		}
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// SyncRelayIndex copies new events from the relay into the relay index. With
+// full=true it rebuilds the index, dropping events the relay deleted on its
+// own. Runs as a job; with async=true it returns 202 and the job.
+// POST /api/v1/storage/relay-index/sync
+func (h *Handler) SyncRelayIndex(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.RelayIndex == nil {
+		respondError(w, http.StatusServiceUnavailable, "Relay index service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+	if h.db.RelayBackend() == nil {
+		respondError(w, http.StatusBadRequest, services.ErrNoRelayIndex.Error(), "NO_RELAY_INDEX")
+		return
+	}
+
+	full := r.URL.Query().Get("full") == "true"
+	params := map[string]interface{}{"full": full}
+	h.runJob(w, r, services.JobTypeRelayIndexSync, params, func(ctx context.Context, run *services.JobRun) (interface{}, error) {
+		return h.services.RelayIndex.Sync(ctx, full)
+	}, "Failed to sync relay index", "RELAY_INDEX_SYNC_FAILED")
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// RelayInfoRequest is the request body for updating the NIP-11 document.
+// Name, description, pubkey, contact and icon are written to config.toml;
+// the rest is stored by Roostr.
+type RelayInfoRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Pubkey      string `json:"pubkey"` // hex or npub
+	Contact     string `json:"contact"`
+	Icon        string `json:"icon"`
+	db.RelayInfoSettings
+}
+
+// GetNIP11Document serves the relay's NIP-11 information document. Proxy
+// requests to the relay URL with "Accept: application/nostr+json" here to
+// serve it in place of nostr-rs-relay's own, which lacks fees, banner and
+// policies.
+// GET /public/nip11
+func (h *Handler) GetNIP11Document(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.buildRelayInfo(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to build relay info", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to build relay information", "RELAY_INFO_FAILED")
+		return
+	}
+
+	// NIP-11 requires these for any origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept")
+	w.Header().Set("Access-Control-Allow-Methods", "GET")
+	w.Header().Set("Content-Type", "application/nostr+json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// GetRelayInfoSettings returns the editable NIP-11 fields and the document
+// they produce.
+// GET /api/v1/relay-info
+func (h *Handler) GetRelayInfoSettings(w http.ResponseWriter, r *http.Request) {
+	if h.configMgr == nil {
+		respondError(w, http.StatusServiceUnavailable, "Config manager not available", "CONFIG_NOT_AVAILABLE")
+		return
+	}
+	h.respondRelayInfo(w, r)
+}
+
+// UpdateRelayInfoSettings saves the NIP-11 fields and reloads the relay so
+// its own information document matches.
+// PUT /api/v1/relay-info
+func (h *Handler) UpdateRelayInfoSettings(w http.ResponseWriter, r *http.Request) {
+	if h.configMgr == nil {
+		respondError(w, http.StatusServiceUnavailable, "Config manager not available", "CONFIG_NOT_AVAILABLE")
+		return
+	}
+	ctx := r.Context()
+
+	var req RelayInfoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if err := normalizeRelayInfo(&req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "VALIDATION_ERROR")
+		return
+	}
+
+	cfg, err := h.configMgr.Read()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to read config", "CONFIG_READ_FAILED")
+		return
+	}
+	cfg.Info.Name = req.Name
+	cfg.Info.Description = req.Description
+	cfg.Info.Pubkey = req.Pubkey
+	cfg.Info.Contact = req.Contact
+	cfg.Info.RelayIcon = req.Icon
+	if err := h.configMgr.WriteAs(cfg, "admin", "Updated relay information"); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to write config", "CONFIG_WRITE_FAILED")
+		return
+	}
+	if err := h.db.SetRelayInfoSettings(ctx, &req.RelayInfoSettings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save relay information", "DB_ERROR")
+		return
+	}
+
+	if h.relay != nil {
+		if err := h.relay.Reload(); err != nil {
+			slog.WarnContext(ctx, "Failed to reload relay", "error", err)
+		}
+	}
+
+	h.db.AddAuditLog(ctx, "relay_info_updated", map[string]interface{}{
+		"name": req.Name,
+	}, "")
+
+	h.respondRelayInfo(w, r)
+}
+
+// respondRelayInfo writes the editable fields and the generated document.
+func (h *Handler) respondRelayInfo(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cfg, err := h.configMgr.Read()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to read config", "CONFIG_READ_FAILED")
+		return
+	}
+	settings, err := h.db.GetRelayInfoSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get relay information", "DB_ERROR")
+		return
+	}
+	doc, err := services.BuildRelayInfo(ctx, h.db, cfg)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to build relay information", "RELAY_INFO_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"info": RelayInfoRequest{
+			Name:              cfg.Info.Name,
+			Description:       cfg.Info.Description,
+			Pubkey:            cfg.Info.Pubkey,
+			Contact:           cfg.Info.Contact,
+			Icon:              cfg.Info.RelayIcon,
+			RelayInfoSettings: *settings,
+		},
+		"document": doc,
+	})
+}
+
+// buildRelayInfo builds the NIP-11 document, without the config fields if
+// config.toml cannot be read.
+func (h *Handler) buildRelayInfo(r *http.Request) (*services.RelayInfoDocument, error) {
+	var cfg *relay.Config
+	if h.configMgr != nil {
+		if read, err := h.configMgr.Read(); err == nil {
+			cfg = read
+		}
+	}
+	return services.BuildRelayInfo(r.Context(), h.db, cfg)
+}
+
+// normalizeRelayInfo validates the request, converts the pubkey to hex and
+// replaces nil lists with empty ones.
+func normalizeRelayInfo(req *RelayInfoRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if len(req.Name) > 64 {
+		return fmt.Errorf("name must be 64 characters or less")
+	}
+	if len(req.Description) > 500 {
+		return fmt.Errorf("description must be 500 characters or less")
+	}
+	if req.Pubkey != "" {
+		hexPubkey, _, err := nostr.ValidatePubkey(req.Pubkey)
+		if err != nil {
+			return fmt.Errorf("pubkey must be a hex pubkey or npub")
+		}
+		req.Pubkey = hexPubkey
+	}
+
+	urls := map[string]string{
+		"icon":             req.Icon,
+		"banner":           req.Banner,
+		"payments_url":     req.PaymentsURL,
+		"posting_policy":   req.PostingPolicy,
+		"privacy_policy":   req.PrivacyPolicy,
+		"terms_of_service": req.TermsOfService,
+	}
+	for field, value := range urls {
+		if value != "" && !isValidWebhookURL(value) {
+			return fmt.Errorf("%s must be an http(s) URL", field)
+		}
+	}
+
+	for _, country := range req.RelayCountries {
+		if country != "*" && !isUpperAlpha(country, 2) {
+			return fmt.Errorf("relay_countries must be ISO 3166-1 alpha-2 codes such as US, or *")
+		}
+	}
+	for _, tag := range req.LanguageTags {
+		if tag == "" || len(tag) > 35 || strings.Trim(tag, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-*") != "" {
+			return fmt.Errorf("language_tags must be IETF language tags such as en or pt-BR")
+		}
+	}
+	if len(req.Tags) > 20 {
+		return fmt.Errorf("at most 20 tags are allowed")
+	}
+	for _, tag := range req.Tags {
+		if tag == "" || len(tag) > 32 {
+			return fmt.Errorf("tags must be between 1 and 32 characters")
+		}
+	}
+	for _, nip := range req.SupportedNIPs {
+		if nip < 1 || nip > 999 {
+			return fmt.Errorf("supported_nips must be NIP numbers")
+		}
+	}
+
+	if req.RelayCountries == nil {
+		req.RelayCountries = []string{}
+	}
+	if req.LanguageTags == nil {
+		req.LanguageTags = []string{}
+	}
+	if req.Tags == nil {
+		req.Tags = []string{}
+	}
+	if req.SupportedNIPs == nil {
+		req.SupportedNIPs = []int{}
+	}
+	return nil
+}
+
+// isUpperAlpha reports whether s is n uppercase ASCII letters.
+func isUpperAlpha(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestNormalizeRelayInfo(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		req := RelayInfoRequest{
+			Name:   "  Home Relay ",
+			Pubkey: "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6",
+			Icon:   "https://example.com/icon.png",
+			RelayInfoSettings: db.RelayInfoSettings{
+				RelayCountries: []string{"CA", "*"},
+				LanguageTags:   []string{"en", "pt-BR"},
+				SupportedNIPs:  []int{1, 11},
+			},
+		}
+		if err := normalizeRelayInfo(&req); err != nil {
+			t.Fatalf("expected valid, got %v", err)
+		}
+		if req.Name != "Home Relay" {
+			t.Errorf("expected the name trimmed, got %q", req.Name)
+		}
+		if req.Pubkey != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" {
+			t.Errorf("expected the npub converted to hex, got %s", req.Pubkey)
+		}
+		if req.Tags == nil {
+			t.Error("expected nil lists replaced with empty ones")
+		}
+	})
+
+	invalid := map[string]RelayInfoRequest{
+		"pubkey":   {Pubkey: "not-a-key"},
+		"banner":   {RelayInfoSettings: db.RelayInfoSettings{Banner: "ftp://example.com/banner.png"}},
+		"country":  {RelayInfoSettings: db.RelayInfoSettings{RelayCountries: []string{"usa"}}},
+		"language": {RelayInfoSettings: db.RelayInfoSettings{LanguageTags: []string{"en_US"}}},
+		"nip":      {RelayInfoSettings: db.RelayInfoSettings{SupportedNIPs: []int{0}}},
+	}
+	for name, req := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if err := normalizeRelayInfo(&req); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
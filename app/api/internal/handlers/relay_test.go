@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -370,6 +372,18 @@ func TestParseLogLine(t *testing.T) {
 		}
 	})
 
+	t.Run("relay_tracing_format_with_colors", func(t *testing.T) {
+		line := "\x1b[2m2025-12-13T14:32:01.123456Z\x1b[0m \x1b[33m WARN\x1b[0m nostr_rs_relay::db: rejected event"
+		entry := parseLogLine(line)
+
+		if entry.Timestamp != "2025-12-13T14:32:01.123456Z" || entry.Level != "WARN" {
+			t.Errorf("unexpected entry: %+v", entry)
+		}
+		if entry.Message != "nostr_rs_relay::db: rejected event" {
+			t.Errorf("unexpected message: %q", entry.Message)
+		}
+	})
+
 	t.Run("various_log_levels", func(t *testing.T) {
 		testCases := []struct {
 			line          string
@@ -494,3 +508,53 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+// ============================================================================
+// Log Level Filter Tests
+// ============================================================================
+
+func TestParseMinLogLevel(t *testing.T) {
+	if rank, err := parseMinLogLevel(""); err != nil || rank != 0 {
+		t.Errorf("expected empty level to match everything, got %d (%v)", rank, err)
+	}
+	if rank, err := parseMinLogLevel("warning"); err != nil || rank != relayLogLevels["WARN"] {
+		t.Errorf("expected warning to alias WARN, got %d (%v)", rank, err)
+	}
+	if _, err := parseMinLogLevel("loud"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+
+	warn, _ := parseMinLogLevel("warn")
+	if !logLevelAtLeast("ERROR", warn) || logLevelAtLeast("INFO", warn) {
+		t.Error("expected ERROR to pass and INFO to fail a warn filter")
+	}
+	if logLevelAtLeast("NOTICE", warn) {
+		t.Error("expected unknown levels to rank as INFO")
+	}
+}
+
+func TestReadLogFile_LevelFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	content := "2025-01-01 12:00:00 ERROR first error\n" +
+		"2025-01-01 12:00:01 INFO info\n" +
+		"2025-01-01 12:00:02 WARN warning\n" +
+		"2025-01-01 12:00:03 ERROR second error\n" +
+		"2025-01-01 12:00:04 DEBUG debug\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	warn, _ := parseMinLogLevel("warn")
+	entries, err := readLogFile(path, 2, warn)
+	if err != nil {
+		t.Fatalf("readLogFile failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Message != "second error" || entries[1].Message != "warning" {
+		t.Errorf("expected the two newest warn+ entries, got %+v", entries)
+	}
+
+	entries, _ = readLogFile(path, 100, 0)
+	if len(entries) != 5 || entries[0].Level != "DEBUG" {
+		t.Errorf("expected all entries newest first, got %+v", entries)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// maxRenewalDaysBefore bounds how early renewal invoices are sent.
+const maxRenewalDaysBefore = 90
+
+// maxGraceDays bounds how long expired users keep access.
+const maxGraceDays = 90
+
+// GetRenewalSettings returns the subscription renewal settings.
+// GET /api/v1/access/renewals
+func (h *Handler) GetRenewalSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetRenewalSettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get renewal settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateRenewalSettings saves the subscription renewal settings.
+// PUT /api/v1/access/renewals
+func (h *Handler) UpdateRenewalSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetRenewalSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get renewal settings", "DB_ERROR")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if settings.DaysBefore < 1 || settings.DaysBefore > maxRenewalDaysBefore {
+		respondError(w, http.StatusBadRequest, "days_before must be between 1 and 90", "INVALID_SETTINGS")
+		return
+	}
+	if settings.RenewURL != "" {
+		u, err := url.Parse(settings.RenewURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			respondError(w, http.StatusBadRequest, "renew_url must be an http or https URL", "INVALID_SETTINGS")
+			return
+		}
+	}
+
+	if err := h.db.SetRenewalSettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save renewal settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "renewal_settings_updated", settings, "")
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// GetExpirySettings returns the subscription expiry settings.
+// GET /api/v1/access/expiry
+func (h *Handler) GetExpirySettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetExpirySettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get expiry settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateExpirySettings saves the subscription expiry settings. A new grace
+// period applies to users already in grace from the next expiry run.
+// PUT /api/v1/access/expiry
+func (h *Handler) UpdateExpirySettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetExpirySettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get expiry settings", "DB_ERROR")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if settings.GraceDays < 0 || settings.GraceDays > maxGraceDays {
+		respondError(w, http.StatusBadRequest, "grace_days must be between 0 and 90", "INVALID_SETTINGS")
+		return
+	}
+
+	if err := h.db.SetExpirySettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save expiry settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "expiry_settings_updated", settings, "")
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// RunRenewals sends renewal invoices to the paid users that are due one now
+// instead of waiting for the hourly job.
+// POST /api/v1/access/renewals/run
+func (h *Handler) RunRenewals(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Renewal == nil {
+		respondError(w, http.StatusServiceUnavailable, "Renewal service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	sent, err := h.services.Renewal.RunNow(r.Context())
+	if errors.Is(err, services.ErrLNDNotConfigured) {
+		respondError(w, http.StatusServiceUnavailable, "Lightning is not configured", "LN_NOT_CONFIGURED")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to send renewal invoices", "RENEWAL_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"sent":    sent,
+	})
+}
+
+// GetRenewal returns a paid user's subscription and an invoice that renews
+// it, creating the invoice if there is no open one. Paying it extends the
+// subscription from its current expiry.
+// GET /public/renew/{npub}
+func (h *Handler) GetRenewal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accessMode, err := h.db.GetAccessMode(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access mode", "DB_ERROR")
+		return
+	}
+	if accessMode != "paid" {
+		respondError(w, http.StatusBadRequest, "Paid access is not enabled", "PAID_ACCESS_DISABLED")
+		return
+	}
+
+	hexPubkey, npub, err := nostr.ValidatePubkey(r.PathValue("npub"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pubkey format: "+err.Error(), "INVALID_PUBKEY")
+		return
+	}
+
+	user, err := h.db.GetPaidUserByPubkey(ctx, hexPubkey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get subscription", "DB_ERROR")
+		return
+	}
+	if user == nil || user.Status == "revoked" {
+		respondError(w, http.StatusNotFound, "No subscription found for this pubkey", "NOT_FOUND")
+		return
+	}
+
+	if h.services == nil || h.services.Renewal == nil {
+		respondError(w, http.StatusServiceUnavailable, "Renewal service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+	invoice, err := h.services.Renewal.Invoice(ctx, user)
+	switch {
+	case errors.Is(err, services.ErrNoRenewalTier):
+		respondError(w, http.StatusConflict, "This subscription cannot be renewed", "NOT_RENEWABLE")
+		return
+	case errors.Is(err, services.ErrLNDNotConfigured):
+		respondError(w, http.StatusServiceUnavailable, "Lightning is not configured", "LN_NOT_CONFIGURED")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, "Failed to create invoice", "INVOICE_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"npub":       npub,
+		"tier":       user.Tier,
+		"status":     user.Status,
+		"expires_at": user.ExpiresAt,
+		"invoice": map[string]interface{}{
+			"payment_hash":    invoice.PaymentHash,
+			"payment_request": invoice.PaymentRequest,
+			"amount_sats":     invoice.AmountSats,
+			"tier_id":         invoice.TierID,
+			"expires_at":      invoice.ExpiresAt.Unix(),
+		},
+	})
+}
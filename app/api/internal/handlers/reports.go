@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// membersCSVHeader is the column layout of the members report.
+var membersCSVHeader = []string{
+	"npub", "pubkey", "nickname", "joined", "tier", "status", "expires",
+	"events", "storage_bytes", "last_active",
+}
+
+// memberReportRow combines whitelist and paid-user data for a single member.
+type memberReportRow struct {
+	Pubkey   string
+	Npub     string
+	Nickname string
+	Joined   time.Time
+	Paid     *db.PaidUser
+}
+
+// ExportMembersCSV streams a spreadsheet-friendly report of whitelisted and paid members.
+// GET /api/v1/reports/members.csv
+func (h *Handler) ExportMembersCSV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	loc := time.UTC
+	if timezone := r.URL.Query().Get("timezone"); timezone != "" && timezone != "UTC" {
+		if parsed, err := time.LoadLocation(timezone); err == nil {
+			loc = parsed
+		}
+	}
+
+	whitelist, err := h.db.GetWhitelistMeta(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get whitelist", "DB_ERROR")
+		return
+	}
+
+	paidUsers, err := h.db.GetPaidUsers(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get paid users", "DB_ERROR")
+		return
+	}
+
+	members := buildMemberReportRows(whitelist, paidUsers)
+
+	tiers, err := h.db.GetPricingTiers(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get pricing tiers", "DB_ERROR")
+		return
+	}
+	tierNames := make(map[string]string, len(tiers))
+	for _, t := range tiers {
+		tierNames[t.ID] = t.Name
+	}
+
+	// Event stats are optional; the report is still useful without the relay DB
+	pubkeys := make([]string, len(members))
+	for i, m := range members {
+		pubkeys[i] = m.Pubkey
+	}
+	activity := map[string]db.AuthorActivity{}
+	if h.db.IsRelayDBConnected() {
+		if a, err := h.db.GetAuthorActivity(ctx, pubkeys); err == nil {
+			activity = a
+		} else {
+			slog.WarnContext(ctx, "Failed to get member activity for report", "error", err)
+		}
+	}
+
+	filename := fmt.Sprintf("roostr-members-%s.csv", time.Now().In(loc).Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(members)))
+
+	cw := csv.NewWriter(w)
+	cw.Write(membersCSVHeader)
+
+	for i, m := range members {
+		if ctx.Err() != nil {
+			return
+		}
+		cw.Write(memberReportRecord(m, activity[m.Pubkey], tierNames, loc))
+
+		// Flush periodically so large reports stream to the client
+		if (i+1)%100 == 0 {
+			cw.Flush()
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.ErrorContext(ctx, "Members CSV export failed", "error", err)
+	}
+}
+
+// buildMemberReportRows merges whitelist entries with paid users.
+// Paid users that are no longer whitelisted (e.g. expired) are appended at the end.
+func buildMemberReportRows(whitelist []db.WhitelistEntry, paidUsers []db.PaidUser) []memberReportRow {
+	paidByPubkey := make(map[string]*db.PaidUser, len(paidUsers))
+	for i := range paidUsers {
+		paidByPubkey[paidUsers[i].Pubkey] = &paidUsers[i]
+	}
+
+	rows := make([]memberReportRow, 0, len(whitelist)+len(paidUsers))
+	seen := make(map[string]bool, len(whitelist))
+	for _, e := range whitelist {
+		seen[e.Pubkey] = true
+		rows = append(rows, memberReportRow{
+			Pubkey:   e.Pubkey,
+			Npub:     e.Npub,
+			Nickname: e.Nickname,
+			Joined:   e.AddedAt,
+			Paid:     paidByPubkey[e.Pubkey],
+		})
+	}
+
+	for i := range paidUsers {
+		u := &paidUsers[i]
+		if seen[u.Pubkey] {
+			continue
+		}
+		seen[u.Pubkey] = true
+		rows = append(rows, memberReportRow{
+			Pubkey: u.Pubkey,
+			Npub:   u.Npub,
+			Joined: u.CreatedAt,
+			Paid:   u,
+		})
+	}
+
+	return rows
+}
+
+// memberReportRecord formats a member as a CSV record matching membersCSVHeader.
+// Tiers are shown by name, or by ID if the tier was deleted.
+func memberReportRecord(m memberReportRow, activity db.AuthorActivity, tierNames map[string]string, loc *time.Location) []string {
+	formatDate := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(loc).Format("2006-01-02 15:04:05")
+	}
+
+	var tier, status, expires string
+	if m.Paid != nil {
+		tier = m.Paid.Tier
+		if name := tierNames[tier]; name != "" {
+			tier = name
+		}
+		status = m.Paid.Status
+		if m.Paid.ExpiresAt != nil {
+			expires = formatDate(*m.Paid.ExpiresAt)
+		}
+	}
+
+	var lastActive string
+	if activity.LastActive != nil {
+		lastActive = formatDate(*activity.LastActive)
+	}
+
+	return []string{
+		m.Npub,
+		m.Pubkey,
+		csvSafe(m.Nickname),
+		formatDate(m.Joined),
+		csvSafe(tier),
+		status,
+		expires,
+		strconv.FormatInt(activity.EventCount, 10),
+		strconv.FormatInt(activity.StorageBytes, 10),
+		lastActive,
+	}
+}
+
+// csvSafe keeps a spreadsheet from running a member-supplied value as a
+// formula, by prefixing values that start like one with a quote.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// GetReportSchedule returns the scheduled report settings and the last
+// report sent.
+// GET /api/v1/reports/schedule
+func (h *Handler) GetReportSchedule(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Reports == nil {
+		respondError(w, http.StatusServiceUnavailable, "Report service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	schedule, err := h.db.GetReportSchedule(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get report schedule", "DB_ERROR")
+		return
+	}
+	last, err := h.db.GetLastReport(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get last report", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"schedule":        schedule,
+		"last_report":     last,
+		"email_available": h.services.Notifier.EmailAvailable(),
+	})
+}
+
+// UpdateReportSchedule saves the scheduled report settings. A report for
+// the last complete period is sent soon after enabling it.
+// PUT /api/v1/reports/schedule
+func (h *Handler) UpdateReportSchedule(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Reports == nil {
+		respondError(w, http.StatusServiceUnavailable, "Report service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	var req db.ReportSchedule
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	switch req.Frequency {
+	case "":
+		req.Frequency = services.ReportFrequencyWeekly
+	case services.ReportFrequencyWeekly, services.ReportFrequencyMonthly:
+	default:
+		respondError(w, http.StatusBadRequest, "frequency must be weekly or monthly", "INVALID_FREQUENCY")
+		return
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	switch req.Channel {
+	case "":
+		req.Channel = services.NotifyChannelDM
+	case services.NotifyChannelDM:
+	case services.NotifyChannelEmail:
+		if !validEmail(req.Email) {
+			respondError(w, http.StatusBadRequest, "A valid email address is required for the email channel", "INVALID_EMAIL")
+			return
+		}
+		if !h.services.Notifier.EmailAvailable() {
+			respondError(w, http.StatusBadRequest, "Email is not configured on this relay", "EMAIL_NOT_CONFIGURED")
+			return
+		}
+	default:
+		respondError(w, http.StatusBadRequest, "channel must be dm or email", "INVALID_CHANNEL")
+		return
+	}
+	if req.Channel != services.NotifyChannelEmail {
+		req.Email = ""
+	}
+
+	if err := h.db.SetReportSchedule(ctx, &req); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save report schedule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "report_schedule_updated", map[string]interface{}{
+		"enabled":   req.Enabled,
+		"frequency": req.Frequency,
+		"channel":   req.Channel,
+	}, "")
+
+	h.services.Reports.Wake()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"schedule": req,
+	})
+}
+
+// GetReportSummary builds the report for the last complete week or month
+// without sending it. With format=html it responds with the email's HTML.
+// GET /api/v1/reports/summary
+func (h *Handler) GetReportSummary(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Reports == nil {
+		respondError(w, http.StatusServiceUnavailable, "Report service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	frequency := r.URL.Query().Get("frequency")
+	if frequency == "" {
+		schedule, err := h.db.GetReportSchedule(ctx)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to get report schedule", "DB_ERROR")
+			return
+		}
+		frequency = schedule.Frequency
+	}
+	if frequency != services.ReportFrequencyWeekly && frequency != services.ReportFrequencyMonthly {
+		respondError(w, http.StatusBadRequest, "frequency must be weekly or monthly", "INVALID_FREQUENCY")
+		return
+	}
+
+	report, err := h.services.Reports.Build(ctx, frequency)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to build report", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to build report", "REPORT_FAILED")
+		return
+	}
+
+	if r.URL.Query().Get("format") == "html" {
+		body, err := services.RenderReportHTML(report)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to render report", "REPORT_FAILED")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(body))
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// SendReport sends the report for the last complete period now, on the
+// scheduled channel, even if it was already sent or the schedule is off.
+// POST /api/v1/reports/send
+func (h *Handler) SendReport(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Reports == nil {
+		respondError(w, http.StatusServiceUnavailable, "Report service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	report, err := h.services.Reports.Send(r.Context())
+	switch {
+	case errors.Is(err, services.ErrNoOperator):
+		respondError(w, http.StatusConflict, "Complete setup before sending a report", "NO_OPERATOR")
+		return
+	case errors.Is(err, services.ErrEmailNotConfigured):
+		respondError(w, http.StatusBadRequest, "Email is not configured on this relay", "EMAIL_NOT_CONFIGURED")
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to send report", "error", err)
+		respondError(w, http.StatusBadGateway, "Failed to send report", "SEND_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestBuildMemberReportRows(t *testing.T) {
+	joined := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	expires := joined.AddDate(0, 1, 0)
+
+	whitelist := []db.WhitelistEntry{
+		{Pubkey: "aaaa", Npub: "npub1aaaa", Nickname: "alice", AddedAt: joined},
+		{Pubkey: "bbbb", Npub: "npub1bbbb", AddedAt: joined},
+	}
+	paidUsers := []db.PaidUser{
+		{Pubkey: "bbbb", Npub: "npub1bbbb", Tier: "Monthly", Status: "active", ExpiresAt: &expires},
+		{Pubkey: "cccc", Npub: "npub1cccc", Tier: "Yearly", Status: "expired", CreatedAt: joined},
+	}
+
+	rows := buildMemberReportRows(whitelist, paidUsers)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	if rows[0].Paid != nil {
+		t.Error("expected alice to have no paid record")
+	}
+	if rows[1].Paid == nil || rows[1].Paid.Tier != "Monthly" {
+		t.Error("expected bbbb to be joined with its paid record")
+	}
+	if rows[2].Pubkey != "cccc" || rows[2].Joined != joined {
+		t.Errorf("expected non-whitelisted paid user appended, got %+v", rows[2])
+	}
+}
+
+func TestMemberReportRecord(t *testing.T) {
+	joined := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	expires := joined.AddDate(0, 1, 0)
+	lastActive := joined.Add(48 * time.Hour)
+
+	tests := []struct {
+		name     string
+		row      memberReportRow
+		activity db.AuthorActivity
+		want     []string
+	}{
+		{
+			name: "free member without events",
+			row:  memberReportRow{Pubkey: "aaaa", Npub: "npub1aaaa", Nickname: "alice", Joined: joined},
+			want: []string{"npub1aaaa", "aaaa", "alice", "2024-01-15 12:00:00", "", "", "", "0", "0", ""},
+		},
+		{
+			name: "paid member with activity",
+			row: memberReportRow{
+				Pubkey: "bbbb", Npub: "npub1bbbb", Joined: joined,
+				Paid: &db.PaidUser{Tier: "monthly", Status: "active", ExpiresAt: &expires},
+			},
+			activity: db.AuthorActivity{EventCount: 42, StorageBytes: 2048, LastActive: &lastActive},
+			want:     []string{"npub1bbbb", "bbbb", "", "2024-01-15 12:00:00", "Monthly", "active", "2024-02-15 12:00:00", "42", "2048", "2024-01-17 12:00:00"},
+		},
+		{
+			name: "formula nickname and deleted tier",
+			row: memberReportRow{
+				Pubkey: "cccc", Npub: "npub1cccc", Nickname: "=HYPERLINK(\"http://evil\")", Joined: joined,
+				Paid: &db.PaidUser{Tier: "-legacy", Status: "expired"},
+			},
+			want: []string{"npub1cccc", "cccc", "'=HYPERLINK(\"http://evil\")", "2024-01-15 12:00:00", "'-legacy", "expired", "", "0", "0", ""},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := memberReportRecord(tc.row, tc.activity, map[string]string{"monthly": "Monthly"}, time.UTC)
+			if len(got) != len(membersCSVHeader) {
+				t.Fatalf("expected %d columns, got %d", len(membersCSVHeader), len(got))
+			}
+			for i := range tc.want {
+				if got[i] != tc.want[i] {
+					t.Errorf("column %s: expected %q, got %q", membersCSVHeader[i], tc.want[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// ResidencyPolicyRequest is the request body for updating excluded kinds.
+type ResidencyPolicyRequest struct {
+	ExcludedKinds []int `json:"excluded_kinds"`
+}
+
+// GetResidencyPolicy returns the kinds excluded from storage and suggested presets.
+// GET /api/v1/residency
+func (h *Handler) GetResidencyPolicy(w http.ResponseWriter, r *http.Request) {
+	kinds, err := h.db.GetExcludedKinds(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get residency policy", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"excluded_kinds": kinds,
+		"presets":        services.ResidencyKindPresets,
+	})
+}
+
+// UpdateResidencyPolicy replaces the kinds excluded from storage, syncs them to
+// the relay's kind blacklist and purges any stored events of those kinds.
+// PUT /api/v1/residency
+func (h *Handler) UpdateResidencyPolicy(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Residency == nil {
+		respondError(w, http.StatusServiceUnavailable, "Residency service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	var req ResidencyPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if invalid := invalidKinds(req.ExcludedKinds); len(invalid) > 0 {
+		respondErrorWithDetails(w, http.StatusBadRequest, "Kinds must be between 0 and 65535", "INVALID_KINDS", map[string]interface{}{
+			"invalid": invalid,
+		})
+		return
+	}
+
+	syncErr := h.services.Residency.SetExcludedKinds(ctx, req.ExcludedKinds)
+	if syncErr != nil {
+		slog.WarnContext(ctx, "Failed to sync excluded kinds to relay config", "error", syncErr)
+	}
+
+	kinds, err := h.db.GetExcludedKinds(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save residency policy", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "residency_policy_updated", map[string]interface{}{
+		"excluded_kinds": kinds,
+	}, "")
+
+	response := map[string]interface{}{
+		"success":             true,
+		"excluded_kinds":      kinds,
+		"relay_config_synced": syncErr == nil,
+	}
+	if syncErr != nil {
+		response["warning"] = "Relay config could not be updated; excluded kinds will be purged by the background worker instead"
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetResidencyReport verifies that the relay database contains no excluded kinds.
+// GET /api/v1/residency/report
+func (h *Handler) GetResidencyReport(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Residency == nil {
+		respondError(w, http.StatusServiceUnavailable, "Residency service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	report, err := h.services.Residency.Report(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to build residency report", "REPORT_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// PurgeExcludedKinds deletes stored events of excluded kinds now and returns
+// the updated report.
+// POST /api/v1/residency/purge
+func (h *Handler) PurgeExcludedKinds(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Residency == nil {
+		respondError(w, http.StatusServiceUnavailable, "Residency service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	ctx := r.Context()
+
+	purge, err := h.services.Residency.Purge(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to purge excluded kinds", "PURGE_FAILED")
+		return
+	}
+
+	var deleted int64
+	if purge != nil {
+		deleted = purge.Deleted
+	}
+
+	report, err := h.services.Residency.Report(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to build residency report", "REPORT_FAILED")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"deleted": deleted,
+		"report":  report,
+	})
+}
+
+// invalidKinds returns the kinds outside the valid Nostr kind range.
+func invalidKinds(kinds []int) []int {
+	var invalid []int
+	for _, kind := range kinds {
+		if kind < 0 || kind > 65535 {
+			invalid = append(invalid, kind)
+		}
+	}
+	return invalid
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// Search relay limits
+const (
+	searchRelayDefaultLimit = 20
+	searchRelayMaxLimit     = 100
+	searchRelayMaxFilters   = 5
+	searchRelayIdleTimeout  = 5 * time.Minute
+	searchRelayQueryTimeout = 10 * time.Second
+)
+
+// authEventKind is the NIP-42 client authentication event kind.
+const authEventKind = 22242
+
+// authMaxClockSkew bounds how far an AUTH event's created_at may drift.
+const authMaxClockSkew = 10 * time.Minute
+
+// searchFilter is a NIP-01 filter with the NIP-50 search field.
+type searchFilter struct {
+	IDs     []string `json:"ids,omitempty"`
+	Authors []string `json:"authors,omitempty"`
+	Kinds   []int    `json:"kinds,omitempty"`
+	Since   *int64   `json:"since,omitempty"`
+	Until   *int64   `json:"until,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+	Search  string   `json:"search,omitempty"`
+}
+
+// searchSession holds the state of one search relay connection.
+type searchSession struct {
+	h         *Handler
+	conn      *nostr.ServerConn
+	challenge string
+	host      string
+	pubkey    string // authenticated member, empty until AUTH succeeds
+}
+
+// ServeSearchRelay serves a read-only Nostr relay that answers NIP-50 search
+// REQs from the relay database. Members must authenticate with NIP-42 first.
+// GET /public/search (WebSocket)
+func (h *Handler) ServeSearchRelay(w http.ResponseWriter, r *http.Request) {
+	// NIP-11 information document
+	if r.Header.Get("Accept") == "application/nostr+json" {
+		w.Header().Set("Content-Type", "application/nostr+json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":           "roostr search",
+			"description":    "Read-only NIP-50 search for relay members",
+			"supported_nips": []int{1, 11, 42, 50},
+			"software":       "roostr",
+			"limitation": map[string]interface{}{
+				"auth_required":     true,
+				"restricted_writes": true,
+				"max_limit":         searchRelayMaxLimit,
+				"max_filters":       searchRelayMaxFilters,
+			},
+		})
+		return
+	}
+
+	conn, err := nostr.Upgrade(w, r)
+	if err != nil {
+		if errors.Is(err, nostr.ErrNotWebSocket) {
+			respondError(w, http.StatusBadRequest, "WebSocket upgrade required", "WEBSOCKET_REQUIRED")
+		} else {
+			slog.WarnContext(r.Context(), "Search relay upgrade failed", "error", err)
+		}
+		return
+	}
+	defer conn.Close()
+
+	challenge := make([]byte, 16)
+	rand.Read(challenge)
+
+	session := &searchSession{
+		h:         h,
+		conn:      conn,
+		challenge: hex.EncodeToString(challenge),
+		host:      r.Host,
+	}
+	session.serve()
+}
+
+// serve runs the message loop until the client disconnects or goes idle.
+func (s *searchSession) serve() {
+	s.send("AUTH", s.challenge)
+
+	for {
+		message, err := s.conn.ReadMessage(searchRelayIdleTimeout)
+		if err != nil {
+			return
+		}
+
+		var raw []json.RawMessage
+		if err := json.Unmarshal(message, &raw); err != nil || len(raw) < 2 {
+			s.send("NOTICE", "invalid: could not parse message")
+			continue
+		}
+
+		var msgType string
+		if err := json.Unmarshal(raw[0], &msgType); err != nil {
+			s.send("NOTICE", "invalid: could not parse message")
+			continue
+		}
+
+		switch msgType {
+		case "REQ":
+			s.handleReq(raw[1:])
+		case "CLOSE":
+			// Subscriptions end at EOSE; nothing to clean up
+		case "AUTH":
+			s.handleAuth(raw[1])
+		case "EVENT":
+			var event nostr.SyncEvent
+			json.Unmarshal(raw[1], &event)
+			s.send("OK", event.ID, false, "blocked: this relay is read-only")
+		default:
+			s.send("NOTICE", "unsupported: "+msgType)
+		}
+	}
+}
+
+// handleReq answers a REQ with matching search results followed by EOSE.
+func (s *searchSession) handleReq(args []json.RawMessage) {
+	var subID string
+	if err := json.Unmarshal(args[0], &subID); err != nil || subID == "" || len(subID) > 64 {
+		s.send("NOTICE", "invalid: bad subscription id")
+		return
+	}
+
+	if s.pubkey == "" {
+		s.send("CLOSED", subID, "auth-required: search is limited to relay members")
+		return
+	}
+
+	rawFilters := args[1:]
+	if len(rawFilters) == 0 || len(rawFilters) > searchRelayMaxFilters {
+		s.send("CLOSED", subID, "invalid: expected 1 to 5 filters")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), searchRelayQueryTimeout)
+	defer cancel()
+
+	redact := s.h.redactDMs(ctx)
+	seen := make(map[string]bool)
+	for _, rawFilter := range rawFilters {
+		var filter searchFilter
+		if err := json.Unmarshal(rawFilter, &filter); err != nil {
+			s.send("CLOSED", subID, "invalid: could not parse filter")
+			return
+		}
+		if len(db.SearchTerms(filter.Search)) == 0 {
+			s.send("CLOSED", subID, "unsupported: only NIP-50 search filters are supported")
+			return
+		}
+
+		events, err := s.h.searchEvents(ctx, filter)
+		if err != nil {
+			s.send("CLOSED", subID, "error: search failed")
+			return
+		}
+
+		for _, e := range events {
+			if seen[e.ID] {
+				continue
+			}
+			seen[e.ID] = true
+			event := db.ExportEvent{
+				ID:        e.ID,
+				Pubkey:    e.Pubkey,
+				CreatedAt: e.CreatedAt.Unix(),
+				Kind:      e.Kind,
+				Tags:      e.Tags,
+				Content:   e.Content,
+				Sig:       e.Sig,
+			}
+			if redact {
+				event.RedactDM()
+			}
+			s.send("EVENT", subID, event)
+		}
+	}
+
+	s.send("EOSE", subID)
+}
+
+// handleAuth validates a NIP-42 AUTH event and checks relay membership.
+func (s *searchSession) handleAuth(rawEvent json.RawMessage) {
+	var event nostr.SyncEvent
+	if err := json.Unmarshal(rawEvent, &event); err != nil {
+		s.send("NOTICE", "invalid: could not parse AUTH event")
+		return
+	}
+
+	if reason := validateAuthEvent(&event, s.challenge, s.host, time.Now()); reason != "" {
+		s.send("OK", event.ID, false, reason)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), searchRelayQueryTimeout)
+	defer cancel()
+
+	if !s.h.isRelayMember(ctx, event.Pubkey) {
+		s.send("OK", event.ID, false, "restricted: search is limited to relay members")
+		return
+	}
+
+	s.pubkey = event.Pubkey
+	s.send("OK", event.ID, true, "")
+}
+
+// send writes a relay message, logging write failures.
+func (s *searchSession) send(msg ...interface{}) {
+	if err := s.conn.WriteJSON(msg); err != nil {
+		slog.Debug("Search relay write failed", "error", err)
+	}
+}
+
+// validateAuthEvent checks a NIP-42 AUTH event against the session challenge
+// and relay host, returning an OK-message reason when it is invalid.
+func validateAuthEvent(event *nostr.SyncEvent, challenge, host string, now time.Time) string {
+	if event.Kind != authEventKind {
+		return "invalid: AUTH event must be kind 22242"
+	}
+
+	created := time.Unix(event.CreatedAt, 0)
+	if created.Before(now.Add(-authMaxClockSkew)) || created.After(now.Add(authMaxClockSkew)) {
+		return "invalid: AUTH event created_at is too far from now"
+	}
+
+	var gotChallenge, gotRelay string
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "challenge":
+			gotChallenge = tag[1]
+		case "relay":
+			gotRelay = tag[1]
+		}
+	}
+	if gotChallenge != challenge {
+		return "invalid: challenge mismatch"
+	}
+	if u, err := url.Parse(gotRelay); err != nil || !strings.EqualFold(u.Host, host) {
+		return "invalid: relay tag does not match this relay"
+	}
+
+	if err := event.Verify(); err != nil {
+		return "invalid: bad signature"
+	}
+
+	return ""
+}
+
+// toEventFilter converts a search filter to a db.EventFilter, clamping the limit.
+func toEventFilter(f searchFilter) db.EventFilter {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = searchRelayDefaultLimit
+	}
+	if limit > searchRelayMaxLimit {
+		limit = searchRelayMaxLimit
+	}
+
+	filter := db.EventFilter{
+		IDs:     f.IDs,
+		Authors: f.Authors,
+		Kinds:   f.Kinds,
+		Limit:   limit,
+	}
+	if f.Since != nil {
+		filter.Since = time.Unix(*f.Since, 0)
+	}
+	if f.Until != nil {
+		filter.Until = time.Unix(*f.Until, 0)
+	}
+	return filter
+}
+
+// searchEvents answers a search filter from the full-text index, most
+// relevant first. Filters naming event IDs, which match few events, and
+// relays without a usable index scan event content instead.
+func (h *Handler) searchEvents(ctx context.Context, filter searchFilter) ([]db.Event, error) {
+	if len(filter.IDs) == 0 {
+		q := db.FullTextQuery{Query: filter.Search, Authors: filter.Authors, Kinds: filter.Kinds}
+		scoped := toEventFilter(filter)
+		q.Since, q.Until, q.Limit = scoped.Since, scoped.Until, scoped.Limit
+
+		results, err := h.db.SearchEventsFullText(ctx, q)
+		switch {
+		case err == nil:
+			events := make([]db.Event, len(results))
+			for i, result := range results {
+				events[i] = result.Event
+			}
+			return events, nil
+		case !errors.Is(err, db.ErrSearchIndexUnavailable) && !errors.Is(err, db.ErrUnsupportedOnPostgres):
+			return nil, err
+		}
+	}
+	return h.db.SearchEvents(ctx, filter.Search, toEventFilter(filter))
+}
+
+// isRelayMember reports whether pubkey is whitelisted or has active paid access.
+func (h *Handler) isRelayMember(ctx context.Context, pubkey string) bool {
+	if entry, err := h.db.GetWhitelistEntryByPubkey(ctx, pubkey); err == nil && entry != nil {
+		return true
+	}
+	if user, err := h.db.GetPaidUserByPubkey(ctx, pubkey); err == nil && user != nil && user.Status == "active" {
+		return true
+	}
+	return false
+}
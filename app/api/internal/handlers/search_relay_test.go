@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/testutil"
+)
+
+func signedAuthEvent(t *testing.T, challenge, relay string, createdAt time.Time) *nostr.SyncEvent {
+	t.Helper()
+	secret, err := nostr.GenerateSecretKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretKey failed: %v", err)
+	}
+	event := nostr.NewEvent(authEventKind, [][]string{{"relay", relay}, {"challenge", challenge}}, "")
+	event.CreatedAt = createdAt.Unix()
+	if err := event.Sign(secret); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return event
+}
+
+func TestValidateAuthEvent(t *testing.T) {
+	now := time.Now()
+
+	t.Run("valid", func(t *testing.T) {
+		event := signedAuthEvent(t, "abc", "wss://relay.example.com/public/search", now)
+		if reason := validateAuthEvent(event, "abc", "relay.example.com", now); reason != "" {
+			t.Errorf("expected valid AUTH, got %q", reason)
+		}
+	})
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		event := signedAuthEvent(t, "other", "wss://relay.example.com", now)
+		if reason := validateAuthEvent(event, "abc", "relay.example.com", now); reason == "" {
+			t.Error("expected challenge mismatch")
+		}
+	})
+
+	t.Run("wrong relay", func(t *testing.T) {
+		event := signedAuthEvent(t, "abc", "wss://elsewhere.example.com", now)
+		if reason := validateAuthEvent(event, "abc", "relay.example.com", now); reason == "" {
+			t.Error("expected relay mismatch")
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		event := signedAuthEvent(t, "abc", "wss://relay.example.com", now.Add(-time.Hour))
+		if reason := validateAuthEvent(event, "abc", "relay.example.com", now); reason == "" {
+			t.Error("expected stale AUTH to be rejected")
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		event := signedAuthEvent(t, "abc", "wss://relay.example.com", now)
+		event.Tags = append(event.Tags, []string{"extra", "tag"})
+		if reason := validateAuthEvent(event, "abc", "relay.example.com", now); reason == "" {
+			t.Error("expected bad signature")
+		}
+	})
+}
+
+func TestToEventFilter(t *testing.T) {
+	since := int64(1700000000)
+
+	filter := toEventFilter(searchFilter{Kinds: []int{1}, Since: &since, Search: "bitcoin"})
+	if filter.Limit != searchRelayDefaultLimit {
+		t.Errorf("expected default limit %d, got %d", searchRelayDefaultLimit, filter.Limit)
+	}
+	if filter.Since.Unix() != since || !filter.Until.IsZero() {
+		t.Errorf("unexpected time range: %v - %v", filter.Since, filter.Until)
+	}
+
+	filter = toEventFilter(searchFilter{Limit: 5000})
+	if filter.Limit != searchRelayMaxLimit {
+		t.Errorf("expected limit clamped to %d, got %d", searchRelayMaxLimit, filter.Limit)
+	}
+}
+
+// insertSearchTestEvent writes an event straight into a relay database.
+func insertSearchTestEvent(t *testing.T, relayDB *sql.DB, n byte, kind int, content string) string {
+	t.Helper()
+	id := strings.Repeat(hex.EncodeToString([]byte{n}), 32)
+	author := strings.Repeat("ab", 32)
+	raw, _ := json.Marshal(map[string]interface{}{
+		"id": id, "pubkey": author, "created_at": time.Now().Unix(), "kind": kind,
+		"tags": [][]string{}, "content": content, "sig": strings.Repeat("0", 128),
+	})
+	hash, _ := hex.DecodeString(id)
+	authorBytes, _ := hex.DecodeString(author)
+	if _, err := relayDB.Exec(`INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content) VALUES (?, ?, ?, ?, ?, 0, ?)`,
+		hash, time.Now().Unix(), time.Now().Unix(), authorBytes, kind, string(raw)); err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+	return id
+}
+
+func TestSearchEventsUsesIndex(t *testing.T) {
+	ctx := context.Background()
+	fixture := testutil.NewRelayDB(t, testutil.RelayOptions{Authors: 5, Events: 100})
+	database, err := db.New(fixture.Path, filepath.Join(t.TempDir(), "roostr.db"))
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	relayDB, err := sql.Open("sqlite3", fixture.Path)
+	if err != nil {
+		t.Fatalf("failed to open relay database: %v", err)
+	}
+	t.Cleanup(func() { relayDB.Close() })
+	h := &Handler{db: database}
+
+	if _, err := database.UpdateSearchIndex(ctx); err != nil {
+		t.Fatalf("UpdateSearchIndex failed: %v", err)
+	}
+	id := insertSearchTestEvent(t, relayDB, 0xf1, 1, "a note about zymurgy")
+
+	// Searches go to the index, which has not seen the new event yet
+	events, err := h.searchEvents(ctx, searchFilter{Search: "zymurgy"})
+	if err != nil || len(events) != 0 {
+		t.Fatalf("expected no indexed match yet, got %d events, %v", len(events), err)
+	}
+	// Filters by ID scan the events instead
+	if events, _ := h.searchEvents(ctx, searchFilter{Search: "zymurgy", IDs: []string{id}}); len(events) != 1 {
+		t.Errorf("expected the event found by ID, got %d events", len(events))
+	}
+
+	database.UpdateSearchIndex(ctx)
+	events, err = h.searchEvents(ctx, searchFilter{Search: "zymurgy"})
+	if err != nil || len(events) != 1 || events[0].ID != id {
+		t.Errorf("expected the indexed event, got %+v, %v", events, err)
+	}
+}
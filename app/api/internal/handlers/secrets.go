@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/secrets"
+)
+
+// SecretsPassphraseRequest is the request body for setting up or unlocking
+// the secrets vault.
+type SecretsPassphraseRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// GetSecretsStatus reports whether stored credentials are encrypted and
+// whether the vault is unlocked.
+// GET /api/v1/secrets/status
+func (h *Handler) GetSecretsStatus(w http.ResponseWriter, r *http.Request) {
+	plaintext, err := h.db.CountPlaintextSecrets(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get secrets status", "DB_ERROR")
+		return
+	}
+
+	vault := h.db.Secrets()
+	cfg := h.currentConfig()
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"configured":        vault.Configured(),
+		"locked":            vault.Locked(),
+		"plaintext_secrets": plaintext,
+		"passphrase_file":   cfg != nil && cfg.SecretsPassphraseFile != "",
+	})
+}
+
+// SetupSecrets sets the passphrase that encrypts stored credentials and
+// encrypts those already saved. The passphrase cannot be recovered.
+// POST /api/v1/secrets/setup
+func (h *Handler) SetupSecrets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req SecretsPassphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	encrypted, err := h.db.SetupSecrets(ctx, req.Passphrase)
+	if err != nil {
+		respondSecretsError(w, err)
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "secrets_setup", map[string]int{"encrypted": encrypted}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"encrypted": encrypted,
+	})
+}
+
+// UnlockSecrets unlocks the stored credentials until the next restart or
+// lock, and encrypts any still stored in plaintext.
+// POST /api/v1/secrets/unlock
+func (h *Handler) UnlockSecrets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req SecretsPassphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if err := h.db.Secrets().Unlock(req.Passphrase); err != nil {
+		respondSecretsError(w, err)
+		return
+	}
+	encrypted, err := h.db.EncryptStoredSecrets(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encrypt stored secrets", "error", err)
+	}
+	if h.services != nil && h.services.Lightning != nil {
+		if err := h.services.Lightning.LoadConfig(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to load Lightning config", "error", err)
+		}
+	}
+
+	h.db.AddAuditLog(ctx, "secrets_unlocked", nil, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"encrypted": encrypted,
+	})
+}
+
+// LockSecrets forgets the key and the decrypted credentials. Lightning
+// payments stop until the secrets are unlocked again.
+// POST /api/v1/secrets/lock
+func (h *Handler) LockSecrets(w http.ResponseWriter, r *http.Request) {
+	vault := h.db.Secrets()
+	if !vault.Configured() {
+		respondSecretsError(w, secrets.ErrNotConfigured)
+		return
+	}
+
+	vault.Lock()
+	if h.services != nil && h.services.Lightning != nil {
+		h.services.Lightning.ClearConfig()
+	}
+
+	h.db.AddAuditLog(r.Context(), "secrets_locked", nil, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Secrets locked",
+	})
+}
+
+// respondSecretsError maps secrets vault errors to responses.
+func respondSecretsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, secrets.ErrPassphraseTooShort):
+		respondError(w, http.StatusBadRequest, err.Error(), "PASSPHRASE_TOO_SHORT")
+	case errors.Is(err, secrets.ErrWrongPassphrase):
+		respondError(w, http.StatusForbidden, err.Error(), "WRONG_PASSPHRASE")
+	case errors.Is(err, secrets.ErrNotConfigured):
+		respondError(w, http.StatusConflict, err.Error(), "SECRETS_NOT_CONFIGURED")
+	case errors.Is(err, secrets.ErrAlreadyConfigured):
+		respondError(w, http.StatusConflict, err.Error(), "SECRETS_ALREADY_CONFIGURED")
+	case errors.Is(err, secrets.ErrLocked):
+		respondError(w, http.StatusLocked, "Stored secrets are locked; unlock them first", "SECRETS_LOCKED")
+	default:
+		slog.Error("Secrets operation failed", "error", err)
+		respondError(w, http.StatusInternalServerError, "Secrets operation failed", "SECRETS_FAILED")
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// IPBanRequest is the request body for banning an address or range.
+type IPBanRequest struct {
+	IP              string `json:"ip"`
+	Reason          string `json:"reason"`
+	DurationMinutes int    `json:"duration_minutes"` // 0 bans permanently
+}
+
+// GetRateLimitSettings returns the per-IP rate limit settings.
+// GET /api/v1/security/rate-limits
+func (h *Handler) GetRateLimitSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetRateLimitSettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get rate limit settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateRateLimitSettings saves the per-IP rate limit settings and applies
+// them at once.
+// PUT /api/v1/security/rate-limits
+func (h *Handler) UpdateRateLimitSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if cfg := h.currentConfig(); cfg != nil && cfg.RateLimits != nil {
+		respondConfigManaged(w, "rate limits")
+		return
+	}
+
+	settings, err := h.db.GetRateLimitSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get rate limit settings", "DB_ERROR")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if err := services.ValidateRateLimitSettings(settings); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_SETTINGS")
+		return
+	}
+
+	if err := h.db.SetRateLimitSettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save rate limit settings", "DB_ERROR")
+		return
+	}
+	if h.services != nil && h.services.RateLimit != nil {
+		if err := h.services.RateLimit.Reload(ctx); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to apply rate limit settings", "DB_ERROR")
+			return
+		}
+	}
+
+	h.db.AddAuditLog(ctx, "rate_limits_updated", settings, "")
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// GetIPBans returns the bans in effect and the address the request came
+// from, so the operator can avoid banning themselves.
+// GET /api/v1/security/bans
+func (h *Handler) GetIPBans(w http.ResponseWriter, r *http.Request) {
+	bans, err := h.db.GetIPBans(r.Context(), time.Now())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get IP bans", "DB_ERROR")
+		return
+	}
+
+	response := map[string]interface{}{
+		"bans": bans,
+	}
+	if addr, ok := clientAddr(r, h.trustedProxies()); ok {
+		response["your_ip"] = addr.String()
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// BanIP bans an address or CIDR range from the API, permanently or for
+// duration_minutes.
+// POST /api/v1/security/bans
+func (h *Handler) BanIP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.services == nil || h.services.RateLimit == nil {
+		respondError(w, http.StatusServiceUnavailable, "Rate limit service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	var req IPBanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	prefix, err := services.ParseIPBan(strings.TrimSpace(req.IP))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_IP")
+		return
+	}
+	if req.DurationMinutes < 0 {
+		respondError(w, http.StatusBadRequest, "duration_minutes cannot be negative", "INVALID_DURATION")
+		return
+	}
+	if addr, ok := clientAddr(r, h.trustedProxies()); ok && prefix.Contains(addr) {
+		respondError(w, http.StatusBadRequest, "The ban would cover your own IP address", "CANNOT_BAN_SELF")
+		return
+	}
+	if prefix.Addr().IsLoopback() {
+		respondError(w, http.StatusBadRequest, "Loopback addresses cannot be banned", "CANNOT_BAN_SELF")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.DurationMinutes > 0 {
+		t := time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+		expiresAt = &t
+	}
+	ip := services.FormatIPBan(prefix)
+	if err := h.services.RateLimit.Ban(ctx, ip, req.Reason, services.IPBanManual, expiresAt); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to ban IP", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "ip_banned", map[string]interface{}{
+		"ip":         ip,
+		"reason":     req.Reason,
+		"source":     services.IPBanManual,
+		"expires_at": expiresAt,
+	}, "")
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success":    true,
+		"ip":         ip,
+		"expires_at": expiresAt,
+	})
+}
+
+// UnbanIP lifts a ban. CIDR ranges are given as e.g. 203.0.113.0/24.
+// DELETE /api/v1/security/bans/{ip...}
+func (h *Handler) UnbanIP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.services == nil || h.services.RateLimit == nil {
+		respondError(w, http.StatusServiceUnavailable, "Rate limit service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	prefix, err := services.ParseIPBan(r.PathValue("ip"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_IP")
+		return
+	}
+	ip := services.FormatIPBan(prefix)
+
+	removed, err := h.services.RateLimit.Unban(ctx, ip)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to unban IP", "DB_ERROR")
+		return
+	}
+	if !removed {
+		respondError(w, http.StatusNotFound, "IP is not banned", "NOT_FOUND")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "ip_unbanned", map[string]string{"ip": ip}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "IP unbanned",
+	})
+}
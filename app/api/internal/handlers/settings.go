@@ -2,10 +2,47 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
+
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
+// GetSettings returns the effective configuration, from the config file
+// and environment, with passwords and secrets redacted, and which saved
+// settings the config file manages.
+// GET /api/v1/settings
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	cfg := h.currentConfig()
+	if cfg == nil {
+		respondError(w, http.StatusServiceUnavailable, "Configuration not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	managed := []string{}
+	if cfg.BackupSchedule != nil {
+		managed = append(managed, "backup_schedule")
+	}
+	if cfg.RateLimits != nil {
+		managed = append(managed, "rate_limits")
+	}
+	if len(cfg.Webhooks) > 0 {
+		managed = append(managed, "webhooks")
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"config":  cfg.Redacted(),
+		"managed": managed,
+	})
+}
+
+// respondConfigManaged rejects a change to a setting the config file
+// manages, since it would be replaced on the next reload.
+func respondConfigManaged(w http.ResponseWriter, setting string) {
+	respondError(w, http.StatusConflict, "The "+setting+" are set in the config file", "CONFIG_MANAGED")
+}
+
 // GetTimezone returns the user's preferred timezone.
 // GET /api/v1/settings/timezone
 func (h *Handler) GetTimezone(w http.ResponseWriter, r *http.Request) {
@@ -53,3 +90,71 @@ func (h *Handler) SetTimezone(w http.ResponseWriter, r *http.Request) {
 
 	respondJSON(w, http.StatusOK, map[string]string{"timezone": req.Timezone})
 }
+
+// HardwareResponse is the response for the hardware settings.
+type HardwareResponse struct {
+	Profile      services.HardwareProfile `json:"profile"`
+	LowPowerMode string                   `json:"low_power_mode"`
+	Tuning       services.Tuning          `json:"tuning"`
+}
+
+// GetHardware returns the detected hardware, the low-power mode setting and
+// the limits in effect.
+// GET /api/v1/settings/hardware
+func (h *Handler) GetHardware(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Hardware == nil {
+		respondError(w, http.StatusServiceUnavailable, "Hardware service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	hw := h.services.Hardware
+	respondJSON(w, http.StatusOK, HardwareResponse{
+		Profile:      hw.Profile(),
+		LowPowerMode: hw.Mode(),
+		Tuning:       hw.Tuning(),
+	})
+}
+
+// SetHardware sets the low-power mode: "on", "off", or "auto" to follow the
+// detected hardware. The new limits apply at once.
+// PUT /api/v1/settings/hardware
+func (h *Handler) SetHardware(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Hardware == nil {
+		respondError(w, http.StatusServiceUnavailable, "Hardware service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	var req struct {
+		LowPowerMode string `json:"low_power_mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_REQUEST")
+		return
+	}
+
+	ctx := r.Context()
+	hw := h.services.Hardware
+
+	previous := hw.Mode()
+	tuning, err := hw.SetMode(ctx, req.LowPowerMode)
+	if errors.Is(err, services.ErrInvalidLowPowerMode) {
+		respondError(w, http.StatusBadRequest, "low_power_mode must be auto, on or off", "INVALID_LOW_POWER_MODE")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save low-power mode", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "low_power_mode_changed", map[string]interface{}{
+		"from":      previous,
+		"to":        req.LowPowerMode,
+		"low_power": tuning.LowPower,
+	}, "")
+
+	respondJSON(w, http.StatusOK, HardwareResponse{
+		Profile:      hw.Profile(),
+		LowPowerMode: req.LowPowerMode,
+		Tuning:       tuning,
+	})
+}
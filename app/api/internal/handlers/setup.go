@@ -163,16 +163,13 @@ func (h *Handler) CompleteSetup(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else if req.OperatorPubkey != "" {
-		// Legacy: use provided pubkey and npub directly
-		hexPubkey, npub, err = nostr.ValidatePubkey(req.OperatorPubkey)
+		// Legacy: operator_npub is only checked against the pubkey, the
+		// stored npub is always derived from it
+		hexPubkey, npub, err = resolvePubkey(req.OperatorPubkey, req.OperatorNpub)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, "Invalid operator pubkey", "INVALID_PUBKEY")
 			return
 		}
-		// Use provided npub if hex pubkey was given
-		if req.OperatorNpub != "" {
-			npub = req.OperatorNpub
-		}
 	} else {
 		respondError(w, http.StatusBadRequest, "Operator identity is required", "MISSING_IDENTITY")
 		return
@@ -242,7 +239,7 @@ func (h *Handler) CompleteSetup(w http.ResponseWriter, r *http.Request) {
 			cfg.Info.Contact = npub
 
 			// Write updated config
-			if err := h.configMgr.Write(cfg); err != nil {
+			if err := h.configMgr.WriteAs(cfg, "setup", "Initial setup"); err != nil {
 				// Log but don't fail setup - config can be updated later via settings
 			}
 		}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/secrets"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetSigner reports how Roostr signs events as the operator: through a
+// paired NIP-46 remote signer, the delegated secret key, or not at all.
+// GET /api/v1/signer
+func (h *Handler) GetSigner(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Signer == nil {
+		respondError(w, http.StatusServiceUnavailable, "Signer not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	status, err := h.services.Signer.Status(r.Context())
+	if err != nil {
+		respondSignerError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, status)
+}
+
+// PairSignerRequest is the request body for pairing with a bunker.
+type PairSignerRequest struct {
+	BunkerURI string `json:"bunker_uri"`
+}
+
+// PairSigner connects to the operator's remote signer with a bunker:// URI
+// and saves the pairing. The operator may have to approve the connection
+// in their signer.
+// POST /api/v1/signer/pair
+func (h *Handler) PairSigner(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Signer == nil {
+		respondError(w, http.StatusServiceUnavailable, "Signer not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+
+	var req PairSignerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	remote, err := h.services.Signer.Pair(ctx, req.BunkerURI)
+	if err != nil {
+		respondSignerError(w, err)
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "signer_paired", map[string]interface{}{
+		"signer_pubkey": remote.SignerPubkey,
+		"relays":        remote.Relays,
+	}, "")
+
+	h.GetSigner(w, r)
+}
+
+// StartSignerPairingRequest is the request body for a nostrconnect:// pairing.
+type StartSignerPairingRequest struct {
+	Relays []string `json:"relays"`
+}
+
+// StartSignerPairing returns a nostrconnect:// URI for the operator to scan
+// with their signer app. The pairing completes in the background; GET
+// /api/v1/signer reports it.
+// POST /api/v1/signer/nostrconnect
+func (h *Handler) StartSignerPairing(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Signer == nil {
+		respondError(w, http.StatusServiceUnavailable, "Signer not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	var req StartSignerPairingRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+			return
+		}
+	}
+	relays, ok := normalizeBackupRelays(req.Relays)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid relay URL. Must start with wss:// or ws://", "INVALID_URL")
+		return
+	}
+
+	pairing, err := h.services.Signer.StartPairing(relays)
+	if err != nil {
+		respondSignerError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, pairing)
+}
+
+// TestSigner asks the paired remote signer to answer a ping.
+// POST /api/v1/signer/test
+func (h *Handler) TestSigner(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Signer == nil {
+		respondError(w, http.StatusServiceUnavailable, "Signer not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	if err := h.services.Signer.Ping(r.Context()); err != nil {
+		respondSignerError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// UnpairSigner forgets the remote signer.
+// DELETE /api/v1/signer
+func (h *Handler) UnpairSigner(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Signer == nil {
+		respondError(w, http.StatusServiceUnavailable, "Signer not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.services.Signer.Unpair(ctx); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to unpair signer", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "signer_unpaired", nil, "")
+
+	h.GetSigner(w, r)
+}
+
+// respondSignerError maps signer errors to API errors.
+func respondSignerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, secrets.ErrLocked):
+		respondSecretsError(w, err)
+	case errors.Is(err, services.ErrInvalidBunkerURI):
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_URI")
+	case errors.Is(err, services.ErrNoSigner):
+		respondError(w, http.StatusConflict, "No remote signer is paired", "NOT_PAIRED")
+	case errors.Is(err, services.ErrSignerMismatch):
+		respondError(w, http.StatusBadRequest, "The remote signer does not sign as the operator", "KEY_MISMATCH")
+	case errors.Is(err, services.ErrSignerRejected):
+		respondError(w, http.StatusForbidden, err.Error(), "SIGNER_REJECTED")
+	default:
+		slog.Warn("Remote signer request failed", "error", err)
+		respondError(w, http.StatusBadGateway, err.Error(), "SIGNER_UNREACHABLE")
+	}
+}
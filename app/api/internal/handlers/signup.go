@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/roostr/roostr/app/api/internal/db"
 	"github.com/roostr/roostr/app/api/internal/nostr"
 	"github.com/roostr/roostr/app/api/internal/services"
 )
@@ -47,30 +52,24 @@ func (h *Handler) GetRelayInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Filter to only enabled tiers
-	var enabledTiers []map[string]interface{}
-	for _, t := range tiers {
-		if t.Enabled {
-			tier := map[string]interface{}{
-				"id":          t.ID,
-				"name":        t.Name,
-				"amount_sats": t.AmountSats,
-			}
-			if t.DurationDays != nil {
-				tier["duration_days"] = *t.DurationDays
-			}
-			enabledTiers = append(enabledTiers, tier)
-		}
-	}
+	enabledTiers := publicTiers(tiers)
 
 	// Check if Lightning is configured
 	lnConfigured := h.services.Lightning.IsConfigured()
 
+	// Cashu tokens are melted to pay an invoice, so they need Lightning too
+	cashu := map[string]interface{}{"enabled": false, "mints": []string{}}
+	if settings, err := h.db.GetCashuSettings(ctx); err == nil && settings.Enabled && lnConfigured {
+		cashu = map[string]interface{}{"enabled": true, "mints": settings.Mints}
+	}
+
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"paid_access_enabled":  true,
 		"lightning_configured": lnConfigured,
 		"name":                 relayName,
 		"description":          relayDescription,
 		"tiers":                enabledTiers,
+		"cashu":                cashu,
 	})
 }
 
@@ -92,8 +91,9 @@ func (h *Handler) CreateSignupInvoice(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Pubkey string `json:"pubkey"` // hex or npub
+		Pubkey string `json:"pubkey"` // hex, npub or NIP-05 identifier
 		TierID string `json:"tier_id"`
+		Coupon string `json:"coupon"` // optional coupon or invite code
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -111,33 +111,58 @@ func (h *Handler) CreateSignupInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate and convert pubkey format
-	hexPubkey, npub, err := nostr.ValidatePubkey(req.Pubkey)
-	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid pubkey format: "+err.Error(), "INVALID_PUBKEY")
+	hexPubkey, npub, ok := h.signupPubkey(w, r, req.Pubkey)
+	if !ok {
 		return
 	}
 
-	// Check if already whitelisted
-	existing, _ := h.db.GetWhitelistEntryByPubkey(ctx, hexPubkey)
-	if existing != nil {
-		respondError(w, http.StatusConflict, "This pubkey already has access to the relay", "ALREADY_WHITELISTED")
-		return
+	// Apply the coupon, if any
+	var quote *services.CouponQuote
+	if req.Coupon != "" {
+		quote, err = h.services.Coupons.Quote(ctx, req.Coupon, hexPubkey, req.TierID)
+		if err != nil {
+			respondCouponError(w, err)
+			return
+		}
 	}
 
-	// Check if already a paid user with active status
-	paidUser, _ := h.db.GetPaidUserByPubkey(ctx, hexPubkey)
-	if paidUser != nil && paidUser.Status == "active" {
-		respondError(w, http.StatusConflict, "This pubkey already has active paid access", "ALREADY_PAID")
+	// A coupon covering the whole price grants access without an invoice
+	if quote != nil && quote.Free {
+		if err := h.services.Coupons.RedeemFree(ctx, quote, hexPubkey); err != nil {
+			if errors.Is(err, services.ErrCouponUsedUp) {
+				respondCouponError(w, err)
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to redeem coupon", "DB_ERROR")
+			return
+		}
+		expiresAt, err := h.services.InvoiceMonitor.GrantCouponAccess(ctx, hexPubkey, npub, quote.TierID, quote.Code)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to grant access", "DB_ERROR")
+			return
+		}
+		respondJSON(w, http.StatusCreated, map[string]interface{}{
+			"status":        "paid",
+			"free":          true,
+			"amount_sats":   0,
+			"discount_sats": quote.DiscountSats,
+			"coupon":        quote.Code,
+			"tier_id":       quote.TierID,
+			"expires_at":    expiresAt,
+		})
 		return
 	}
 
 	// Create the invoice
-	invoice, err := h.services.Lightning.CreateAccessInvoice(ctx, services.AccessInvoiceRequest{
+	invoiceReq := services.AccessInvoiceRequest{
 		Pubkey: hexPubkey,
 		Npub:   npub,
 		TierID: req.TierID,
-	})
+	}
+	if quote != nil {
+		invoiceReq.AmountSats = quote.AmountSats
+	}
+	invoice, err := h.services.Lightning.CreateAccessInvoice(ctx, invoiceReq)
 	if err != nil {
 		if err == services.ErrLNDNotConfigured {
 			respondError(w, http.StatusServiceUnavailable, "Lightning is not configured", "LN_NOT_CONFIGURED")
@@ -147,7 +172,7 @@ func (h *Handler) CreateSignupInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, map[string]interface{}{
+	response := map[string]interface{}{
 		"payment_hash":    invoice.PaymentHash,
 		"payment_request": invoice.PaymentRequest,
 		"amount_sats":     invoice.AmountSats,
@@ -155,7 +180,27 @@ func (h *Handler) CreateSignupInvoice(w http.ResponseWriter, r *http.Request) {
 		"tier_name":       invoice.TierName,
 		"expires_at":      invoice.ExpiresAt,
 		"memo":            invoice.Memo,
-	})
+	}
+	if quote != nil {
+		// The discounted invoice must not be paid without its coupon use
+		// held, so it is cancelled if the use cannot be reserved
+		if err := h.services.Coupons.Reserve(ctx, quote, hexPubkey, invoice.PaymentHash, time.Unix(invoice.ExpiresAt, 0)); err != nil {
+			slog.ErrorContext(ctx, "Failed to reserve coupon", "coupon", quote.Code, "error", err)
+			if err := h.services.Lightning.CancelAccessInvoice(ctx, invoice.PaymentHash); err != nil {
+				slog.WarnContext(ctx, "Failed to cancel invoice", "payment_hash", invoice.PaymentHash, "error", err)
+			}
+			if errors.Is(err, services.ErrCouponUsedUp) {
+				respondCouponError(w, err)
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to apply coupon", "COUPON_FAILED")
+			return
+		}
+		response["coupon"] = quote.Code
+		response["discount_sats"] = quote.DiscountSats
+	}
+
+	respondJSON(w, http.StatusCreated, response)
 }
 
 // GetInvoiceStatus checks the status of a signup invoice.
@@ -191,10 +236,11 @@ func (h *Handler) GetInvoiceStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If expired locally, return that
-	if pendingInvoice.Status == "expired" {
+	// If expired or cancelled locally, return that; paying a cancelled
+	// invoice grants no access
+	if pendingInvoice.Status == "expired" || pendingInvoice.Status == "cancelled" {
 		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"status":       "expired",
+			"status":       pendingInvoice.Status,
 			"payment_hash": paymentHash,
 		})
 		return
@@ -208,7 +254,7 @@ func (h *Handler) GetInvoiceStatus(w http.ResponseWriter, r *http.Request) {
 			if err := h.services.InvoiceMonitor.ProcessPayment(ctx, paymentHash); err != nil {
 				// Log the error but still return success to the client
 				// The background service will retry if needed
-				log.Printf("Warning: failed to process payment from status check: %v", err)
+				slog.WarnContext(ctx, "Failed to process payment from status check", "payment_hash", paymentHash, "error", err)
 			}
 
 			// Re-fetch to get the updated paid_at timestamp
@@ -235,3 +281,226 @@ func (h *Handler) GetInvoiceStatus(w http.ResponseWriter, r *http.Request) {
 		"expires_at":   pendingInvoice.ExpiresAt.Unix(),
 	})
 }
+
+// ReissueSignupInvoice replaces an open signup invoice, for example one the
+// user lost, with a new invoice for the same tier and price, and cancels the
+// old one.
+// POST /public/invoice-reissue/{hash}
+func (h *Handler) ReissueSignupInvoice(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Lightning == nil {
+		respondError(w, http.StatusServiceUnavailable, "Lightning service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	invoice, err := h.services.Lightning.ReissueAccessInvoice(r.Context(), r.PathValue("hash"))
+	if err != nil {
+		respondInvoiceChangeError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"payment_hash":    invoice.PaymentHash,
+		"payment_request": invoice.PaymentRequest,
+		"amount_sats":     invoice.AmountSats,
+		"tier_id":         invoice.TierID,
+		"tier_name":       invoice.TierName,
+		"expires_at":      invoice.ExpiresAt,
+		"memo":            invoice.Memo,
+		"replaces":        r.PathValue("hash"),
+	})
+}
+
+// CancelSignupInvoice cancels an open signup invoice and frees any coupon
+// reserved for it.
+// POST /public/invoice-cancel/{hash}
+func (h *Handler) CancelSignupInvoice(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Lightning == nil {
+		respondError(w, http.StatusServiceUnavailable, "Lightning service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	paymentHash := r.PathValue("hash")
+	if err := h.services.Lightning.CancelAccessInvoice(r.Context(), paymentHash); err != nil {
+		respondInvoiceChangeError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"status":       "cancelled",
+		"payment_hash": paymentHash,
+	})
+}
+
+// respondInvoiceChangeError responds with the reason an invoice could not be
+// reissued or cancelled.
+func respondInvoiceChangeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		respondError(w, http.StatusNotFound, "Invoice not found", "INVOICE_NOT_FOUND")
+	case errors.Is(err, services.ErrInvoiceNotOpen):
+		respondError(w, http.StatusConflict, "Invoice is already paid, expired or cancelled", "INVOICE_NOT_OPEN")
+	case errors.Is(err, services.ErrLNDNotConfigured):
+		respondError(w, http.StatusServiceUnavailable, "Lightning is not configured", "LN_NOT_CONFIGURED")
+	default:
+		respondError(w, http.StatusInternalServerError, "Failed to update invoice: "+err.Error(), "INVOICE_FAILED")
+	}
+}
+
+// signupPubkey resolves the identity entered for a signup and checks that it
+// does not already have access, responding with the problem if it does.
+func (h *Handler) signupPubkey(w http.ResponseWriter, r *http.Request, pubkey string) (string, string, bool) {
+	hexPubkey, npub, _, ok := signupIdentity(w, r, pubkey)
+	if !ok {
+		return "", "", false
+	}
+
+	switch h.signupAccessStatus(r.Context(), hexPubkey) {
+	case signupStatusWhitelisted:
+		respondError(w, http.StatusConflict, "This pubkey already has access to the relay", "ALREADY_WHITELISTED")
+		return "", "", false
+	case signupStatusPaid:
+		respondError(w, http.StatusConflict, "This pubkey already has active paid access", "ALREADY_PAID")
+		return "", "", false
+	}
+
+	return hexPubkey, npub, true
+}
+
+// Access a pubkey already has, as reported to the signup page.
+const (
+	signupStatusAvailable   = "available"
+	signupStatusWhitelisted = "whitelisted"
+	signupStatusPaid        = "paid"
+)
+
+// signupAccessStatus reports whether a pubkey can sign up or already has
+// access.
+func (h *Handler) signupAccessStatus(ctx context.Context, hexPubkey string) string {
+	if existing, _ := h.db.GetWhitelistEntryByPubkey(ctx, hexPubkey); existing != nil {
+		return signupStatusWhitelisted
+	}
+	if paidUser, _ := h.db.GetPaidUserByPubkey(ctx, hexPubkey); paidUser != nil && paidUser.Status == "active" {
+		return signupStatusPaid
+	}
+	return signupStatusAvailable
+}
+
+// signupIdentity resolves what a user typed on the signup page - a hex
+// pubkey, an npub, a nostr: URI or a NIP-05 identifier - to a hex pubkey and
+// npub, responding with the problem if it cannot. The NIP-05 identifier is
+// returned when one was resolved. Callers are unauthenticated, so NIP-05
+// domains are only fetched from public addresses, and every way the lookup
+// can fail gets the same response.
+func signupIdentity(w http.ResponseWriter, r *http.Request, input string) (string, string, string, bool) {
+	hexPubkey, npub, source, _, err := nostr.ResolvePublicIdentity(r.Context(), input)
+	switch {
+	case err == nil:
+	case errors.Is(err, nostr.ErrInvalidNIP05Format):
+		respondError(w, http.StatusBadRequest, "Invalid NIP-05 identifier format", "INVALID_NIP05")
+		return "", "", "", false
+	case errors.Is(err, nostr.ErrNIP05NotFound), errors.Is(err, nostr.ErrNIP05InvalidPubkey), errors.Is(err, nostr.ErrNIP05FetchFailed):
+		respondError(w, http.StatusBadRequest, "Could not resolve NIP-05 identifier", "NIP05_UNRESOLVED")
+		return "", "", "", false
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid pubkey: enter an npub, a 64-character hex key or a NIP-05 identifier", "INVALID_PUBKEY")
+		return "", "", "", false
+	}
+
+	var nip05 string
+	if source == "nip05" {
+		nip05 = strings.ToLower(strings.TrimSpace(input))
+	}
+	return strings.ToLower(hexPubkey), npub, nip05, true
+}
+
+// signupProfileTimeout bounds the profile lookup for a signup preview.
+const signupProfileTimeout = 5 * time.Second
+
+// CheckSignupIdentity resolves the identity entered on the signup page and
+// returns the canonical pubkey, whether it can sign up, and a profile
+// preview so the user can confirm it is theirs before paying.
+// GET /public/signup-check?input=alice@example.com
+func (h *Handler) CheckSignupIdentity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accessMode, err := h.db.GetAccessMode(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access mode", "DB_ERROR")
+		return
+	}
+	if accessMode != "paid" {
+		respondError(w, http.StatusBadRequest, "Paid access is not enabled", "PAID_ACCESS_DISABLED")
+		return
+	}
+
+	input := r.URL.Query().Get("input")
+	if strings.TrimSpace(input) == "" {
+		respondError(w, http.StatusBadRequest, "Input parameter is required", "MISSING_INPUT")
+		return
+	}
+
+	hexPubkey, npub, nip05, ok := signupIdentity(w, r, input)
+	if !ok {
+		return
+	}
+
+	response := map[string]interface{}{
+		"pubkey":  hexPubkey,
+		"npub":    npub,
+		"status":  h.signupAccessStatus(ctx, hexPubkey),
+		"profile": nil,
+	}
+	if nip05 != "" {
+		response["nip05"] = nip05
+	}
+	if profile := h.signupProfile(ctx, hexPubkey); profile != nil {
+		response["profile"] = map[string]interface{}{
+			"name":         profile.Name,
+			"display_name": profile.DisplayName,
+			"picture":      profile.Picture,
+			"nip05":        profile.Nip05,
+		}
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// signupProfile returns the cached profile for a pubkey, looking it up if it
+// was never fetched, or nil if there is none. The preview is best effort, so
+// lookup failures are not reported.
+func (h *Handler) signupProfile(ctx context.Context, hexPubkey string) *db.Profile {
+	profile, err := h.db.GetProfile(ctx, hexPubkey)
+	if err != nil {
+		return nil
+	}
+	if profile == nil && h.services != nil && h.services.Profiles != nil {
+		lookupCtx, cancel := context.WithTimeout(ctx, signupProfileTimeout)
+		defer cancel()
+		if updated, err := h.services.Profiles.Refresh(lookupCtx, []string{hexPubkey}); err == nil {
+			profile = updated[hexPubkey]
+		}
+	}
+	if profile == nil || profile.Source == "" {
+		return nil
+	}
+	return profile
+}
+
+// publicTiers returns the enabled pricing tiers in the shape exposed to public clients.
+func publicTiers(tiers []db.PricingTier) []map[string]interface{} {
+	var enabledTiers []map[string]interface{}
+	for _, t := range tiers {
+		if t.Enabled {
+			tier := map[string]interface{}{
+				"id":          t.ID,
+				"name":        t.Name,
+				"amount_sats": t.AmountSats,
+			}
+			if t.DurationDays != nil {
+				tier["duration_days"] = *t.DurationDays
+			}
+			enabledTiers = append(enabledTiers, tier)
+		}
+	}
+	return enabledTiers
+}
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
 )
 
 // mockDB implements minimal DB interface for testing
@@ -222,3 +224,46 @@ func TestRoutePatternMatching(t *testing.T) {
 		})
 	}
 }
+
+func TestSignupIdentity(t *testing.T) {
+	hexPubkey := strings.Repeat("ab", 32)
+	npub, err := nostr.EncodeNpub(hexPubkey)
+	if err != nil {
+		t.Fatalf("EncodeNpub failed: %v", err)
+	}
+
+	for _, input := range []string{hexPubkey, strings.ToUpper(hexPubkey), " " + npub + " ", "nostr:" + npub} {
+		w := httptest.NewRecorder()
+		gotHex, gotNpub, nip05, ok := signupIdentity(w, httptest.NewRequest("GET", "/", nil), input)
+		if !ok || gotHex != hexPubkey || gotNpub != npub || nip05 != "" {
+			t.Errorf("signupIdentity(%q) = %q, %q, %q, %v", input, gotHex, gotNpub, nip05, ok)
+		}
+	}
+
+	for _, input := range []string{"", "npub1invalid", "abc", "a@b"} {
+		w := httptest.NewRecorder()
+		if _, _, _, ok := signupIdentity(w, httptest.NewRequest("GET", "/", nil), input); ok {
+			t.Errorf("expected %q to be rejected", input)
+			continue
+		}
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusBadRequest || resp["code"] != "INVALID_PUBKEY" {
+			t.Errorf("signupIdentity(%q): expected 400 INVALID_PUBKEY, got %d %v", input, w.Code, resp["code"])
+		}
+	}
+
+	// Domains that are addresses are never fetched
+	for _, input := range []string{"x@10.0.0.5", "x@10.0.0.5:8443", "x@relay.example.com:8443"} {
+		w := httptest.NewRecorder()
+		if _, _, _, ok := signupIdentity(w, httptest.NewRequest("GET", "/", nil), input); ok {
+			t.Errorf("expected %q to be rejected", input)
+			continue
+		}
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusBadRequest || resp["code"] != "INVALID_NIP05" {
+			t.Errorf("signupIdentity(%q): expected 400 INVALID_NIP05, got %d %v", input, w.Code, resp["code"])
+		}
+	}
+}
@@ -7,6 +7,11 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
 // GetStatsSummary returns aggregate statistics from the relay for the dashboard.
@@ -41,19 +46,20 @@ func (h *Handler) GetStatsSummary(w http.ResponseWriter, r *http.Request) {
 	// If relay DB is not connected, return minimal data
 	if !relayConnected {
 		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"total_events":      0,
-			"events_today":      0,
-			"storage_bytes":     storageBytes,
-			"whitelisted_count": whitelistCount,
-			"events_by_kind":    map[string]int64{},
-			"uptime_seconds":    uptimeSeconds,
-			"relay_status":      relayStatus,
+			"total_events":       0,
+			"events_today":       0,
+			"storage_bytes":      storageBytes,
+			"whitelisted_count":  whitelistCount,
+			"events_by_kind":     map[string]int64{},
+			"events_by_category": []CategoryCount{},
+			"uptime_seconds":     uptimeSeconds,
+			"relay_status":       relayStatus,
 		})
 		return
 	}
 
 	// Get relay stats
-	stats, err := h.db.GetRelayStats(ctx)
+	stats, computedAt, err := h.relayStats(ctx, r.URL.Query().Get("fresh") == "true")
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to get stats", "STATS_FAILED")
 		return
@@ -62,11 +68,26 @@ func (h *Handler) GetStatsSummary(w http.ResponseWriter, r *http.Request) {
 	// Get events today (in user's timezone)
 	eventsToday, _ := h.db.GetEventsToday(ctx, loc)
 
-	// Build events_by_kind with human-friendly labels per spec
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"total_events":       stats.TotalEvents,
+		"events_today":       eventsToday,
+		"storage_bytes":      storageBytes,
+		"whitelisted_count":  whitelistCount,
+		"events_by_kind":     dashboardKindCounts(stats.EventsByKind),
+		"events_by_category": categoryCounts(stats.EventsByKind),
+		"uptime_seconds":     uptimeSeconds,
+		"relay_status":       relayStatus,
+		"computed_at":        computedAt,
+	})
+}
+
+// dashboardKindCounts groups kind counts under the dashboard's fixed labels:
+// posts, follows, dms, reposts, reactions and other.
+func dashboardKindCounts(counts map[int]int64) map[string]int64 {
 	eventsByKind := map[string]int64{}
 	var otherCount int64 = 0
 
-	for kind, count := range stats.EventsByKind {
+	for kind, count := range counts {
 		switch kind {
 		case 1:
 			eventsByKind["posts"] = count
@@ -86,16 +107,27 @@ func (h *Handler) GetStatsSummary(w http.ResponseWriter, r *http.Request) {
 	if otherCount > 0 {
 		eventsByKind["other"] = otherCount
 	}
+	return eventsByKind
+}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"total_events":      stats.TotalEvents,
-		"events_today":      eventsToday,
-		"storage_bytes":     storageBytes,
-		"whitelisted_count": whitelistCount,
-		"events_by_kind":    eventsByKind,
-		"uptime_seconds":    uptimeSeconds,
-		"relay_status":      relayStatus,
-	})
+// relayStats returns the relay's totals from the stats cache, or straight
+// from the database when the cache is not available.
+func (h *Handler) relayStats(ctx context.Context, fresh bool) (*db.RelayStats, time.Time, error) {
+	if h.services == nil || h.services.StatsCache == nil {
+		stats, err := h.db.GetRelayStats(ctx)
+		return stats, time.Now(), err
+	}
+	return h.services.StatsCache.RelayStats(ctx, fresh)
+}
+
+// cachedStats returns a statistic from the stats cache, computing it when
+// it is missing or the request asks for ?fresh=true.
+func (h *Handler) cachedStats(r *http.Request, key string, compute services.StatsComputeFunc) (interface{}, time.Time, error) {
+	if h.services == nil || h.services.StatsCache == nil {
+		value, err := compute(r.Context())
+		return value, time.Now(), err
+	}
+	return h.services.StatsCache.Get(r.Context(), key, r.URL.Query().Get("fresh") == "true", compute)
 }
 
 // GetRelayStatus returns the current status of the relay with detailed process information.
@@ -104,70 +136,107 @@ func (h *Handler) GetRelayStatus(w http.ResponseWriter, r *http.Request) {
 	apiUptimeSeconds := int64(time.Since(h.startTime).Seconds())
 
 	// Determine relay status
-	var status string
+	status := h.relayState()
 	var pid int
 	var memoryBytes int64
 	var relayUptimeSeconds int64
+	var supervisor *relay.SupervisorStatus
 
 	if h.relay != nil {
-		if h.relay.IsRestarting() {
-			status = "restarting"
-		} else if h.relay.IsRunning() {
-			status = "running"
+		if h.relay.IsSupervised() {
+			s := h.relay.SupervisorStatus()
+			supervisor = &s
+		}
+		if status == "running" {
 			pid = h.relay.GetPID()
 			memoryBytes = h.relay.GetMemoryUsage()
 			relayUptimeSeconds = h.relay.GetProcessUptime()
-		} else {
-			status = "stopped"
-		}
-	} else {
-		// No relay manager - fall back to database connection check
-		if relayConnected {
-			status = "running"
-		} else {
-			status = "unknown"
 		}
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"status":             status,
 		"pid":                pid,
 		"memory_bytes":       memoryBytes,
 		"uptime_seconds":     relayUptimeSeconds,
 		"database_connected": relayConnected,
 		"api_uptime_seconds": apiUptimeSeconds,
-	})
+		"supervised":         supervisor != nil,
+	}
+	if supervisor != nil {
+		response["restart_count"] = supervisor.RestartCount
+		response["supervisor"] = supervisor
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// relayState returns whether the relay process is running, stopped or
+// restarting. Without a relay manager it is running if its database is
+// connected, and unknown otherwise.
+func (h *Handler) relayState() string {
+	if h.relay == nil {
+		if h.db.IsRelayDBConnected() {
+			return "running"
+		}
+		return "unknown"
+	}
+
+	restartPending := false
+	if h.relay.IsSupervised() {
+		restartPending = h.relay.SupervisorStatus().NextRestartAt != nil
+	}
+	switch {
+	case h.relay.IsRestarting() || restartPending:
+		return "restarting"
+	case h.relay.IsRunning():
+		return "running"
+	default:
+		return "stopped"
+	}
 }
 
 // GetRelayURLs returns the relay's local and Tor WebSocket URLs.
 func (h *Handler) GetRelayURLs(w http.ResponseWriter, r *http.Request) {
+	cfg := h.currentConfig()
 	response := map[string]interface{}{
-		"local":         h.cfg.RelayURL,
-		"relay_port":    h.cfg.RelayPort,
+		"local":         cfg.RelayURL,
+		"relay_port":    cfg.RelayPort,
 		"tor":           "",
 		"tor_available": false,
 	}
 
-	if h.cfg.TorAddress != "" {
+	if cfg.TorAddress != "" {
 		// Format Tor URL - TOR_ADDRESS includes the port
-		response["tor"] = "ws://" + h.cfg.TorAddress
+		response["tor"] = "ws://" + cfg.TorAddress
 		response["tor_available"] = true
 	}
 
 	respondJSON(w, http.StatusOK, response)
 }
 
-// GetEventsOverTime returns event counts grouped by date for charting.
+// GetEventsOverTime returns event counts grouped by hour, day, week or month
+// for charting. The granularity defaults to hours for today and days
+// otherwise.
 // STATS-API-001: GET /api/v1/stats/events-over-time
 func (h *Handler) GetEventsOverTime(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	// Parse query parameters
 	timeRange := r.URL.Query().Get("time_range")
 	if timeRange == "" {
 		timeRange = "7days"
 	}
 	timezone := r.URL.Query().Get("timezone")
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = db.BucketDay
+		if timeRange == "today" {
+			granularity = db.BucketHour
+		}
+	}
+	if !db.ValidBucket(granularity) {
+		respondError(w, http.StatusBadRequest, "Granularity must be hour, day, week or month", "INVALID_GRANULARITY")
+		return
+	}
 
 	// Load timezone location
 	loc := time.UTC
@@ -179,23 +248,26 @@ func (h *Handler) GetEventsOverTime(w http.ResponseWriter, r *http.Request) {
 
 	if !h.db.IsRelayDBConnected() {
 		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"data":       []interface{}{},
-			"time_range": timeRange,
-			"total":      0,
+			"data":        []interface{}{},
+			"time_range":  timeRange,
+			"granularity": granularity,
+			"total":       0,
 		})
 		return
 	}
 
-	since, until := parseTimeRange(timeRange, timezone)
-
-	// Use hourly buckets for "today" view
-	hourly := timeRange == "today"
-
-	data, err := h.db.GetEventsOverTime(ctx, since, until, hourly, loc)
+	// The range is worked out on each computation so cached "today" and
+	// "7days" values move on at midnight
+	key := "events-over-time:" + timeRange + ":" + granularity + ":" + timezone
+	value, computedAt, err := h.cachedStats(r, key, func(ctx context.Context) (interface{}, error) {
+		since, until := parseTimeRange(timeRange, timezone)
+		return h.db.GetEventsOverTime(ctx, since, until, granularity, loc)
+	})
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to get events over time", "STATS_FAILED")
 		return
 	}
+	data := value.([]db.DateCount)
 
 	// Calculate total
 	var total int64
@@ -204,17 +276,17 @@ func (h *Handler) GetEventsOverTime(w http.ResponseWriter, r *http.Request) {
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"data":       data,
-		"time_range": timeRange,
-		"total":      total,
+		"data":        data,
+		"time_range":  timeRange,
+		"granularity": granularity,
+		"total":       total,
+		"computed_at": computedAt,
 	})
 }
 
 // GetEventsByKind returns event distribution by kind for charting.
 // STATS-API-002: GET /api/v1/stats/events-by-kind
 func (h *Handler) GetEventsByKind(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	// Parse time_range query parameter first
 	timeRange := r.URL.Query().Get("time_range")
 	if timeRange == "" {
@@ -224,6 +296,7 @@ func (h *Handler) GetEventsByKind(w http.ResponseWriter, r *http.Request) {
 	if !h.db.IsRelayDBConnected() {
 		respondJSON(w, http.StatusOK, map[string]interface{}{
 			"kinds":      []interface{}{},
+			"categories": []CategoryCount{},
 			"time_range": timeRange,
 			"total":      0,
 		})
@@ -232,13 +305,17 @@ func (h *Handler) GetEventsByKind(w http.ResponseWriter, r *http.Request) {
 
 	// Parse timezone from query parameter
 	timezone := r.URL.Query().Get("timezone")
-	since, until := parseTimeRange(timeRange, timezone)
 
-	kindCounts, err := h.db.GetEventsByKindInRange(ctx, since, until)
+	key := "events-by-kind:" + timeRange + ":" + timezone
+	value, computedAt, err := h.cachedStats(r, key, func(ctx context.Context) (interface{}, error) {
+		since, until := parseTimeRange(timeRange, timezone)
+		return h.db.GetEventsByKindInRange(ctx, since, until)
+	})
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to get events by kind", "STATS_FAILED")
 		return
 	}
+	kindCounts := value.(map[int]int64)
 
 	// Calculate total
 	var total int64
@@ -248,39 +325,106 @@ func (h *Handler) GetEventsByKind(w http.ResponseWriter, r *http.Request) {
 
 	// Build response with labels and percentages
 	type kindInfo struct {
-		Kind    int     `json:"kind"`
-		Label   string  `json:"label"`
-		Count   int64   `json:"count"`
-		Percent float64 `json:"percent"`
+		Kind     int     `json:"kind"`
+		Label    string  `json:"label"`
+		Name     string  `json:"name"`
+		Category string  `json:"category"`
+		Count    int64   `json:"count"`
+		Percent  float64 `json:"percent"`
 	}
 
 	var kinds []kindInfo
 	for kind, count := range kindCounts {
 		label := getKindLabel(kind)
+		info := nostr.LookupKind(kind)
 		percent := 0.0
 		if total > 0 {
 			percent = float64(count) / float64(total) * 100
 		}
 		kinds = append(kinds, kindInfo{
-			Kind:    kind,
-			Label:   label,
-			Count:   count,
-			Percent: percent,
+			Kind:     kind,
+			Label:    label,
+			Name:     info.Name,
+			Category: info.Category,
+			Count:    count,
+			Percent:  percent,
 		})
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"kinds":      kinds,
-		"time_range": timeRange,
-		"total":      total,
+		"kinds":       kinds,
+		"categories":  categoryCounts(kindCounts),
+		"time_range":  timeRange,
+		"total":       total,
+		"computed_at": computedAt,
 	})
 }
 
-// GetTopAuthors returns the most active pubkeys by event count.
+// TopAuthor is one of the most active pubkeys with its name and access, so
+// the analytics view can show "Alice (whitelisted)" without a lookup per row.
+type TopAuthor struct {
+	Pubkey      string `json:"pubkey"`
+	Npub        string `json:"npub"`
+	EventCount  int64  `json:"event_count"`
+	Name        string `json:"name,omitempty"` // Whitelist nickname, else cached profile name
+	Nip05       string `json:"nip05,omitempty"`
+	Picture     string `json:"picture,omitempty"`
+	Access      string `json:"access"` // operator, paid, whitelisted, follow, blacklisted or none
+	Whitelisted bool   `json:"whitelisted"`
+	Paid        bool   `json:"paid"`
+	Blacklisted bool   `json:"blacklisted"`
+}
+
+// topAuthors joins event counts with what the app knows about each author.
+func topAuthors(authors []db.AuthorCount, access map[string]db.AuthorAccess) []TopAuthor {
+	top := make([]TopAuthor, len(authors))
+	for i, author := range authors {
+		a := access[author.Pubkey]
+		npub, _ := nostr.EncodeNpub(author.Pubkey)
+		t := TopAuthor{
+			Pubkey:      author.Pubkey,
+			Npub:        npub,
+			EventCount:  author.EventCount,
+			Name:        a.Nickname,
+			Nip05:       a.Nip05,
+			Picture:     a.Picture,
+			Whitelisted: a.Whitelisted,
+			Paid:        a.PaidStatus == "active" || a.PaidStatus == "grace",
+			Blacklisted: a.Blacklisted,
+		}
+		if t.Name == "" {
+			t.Name = a.Name
+		}
+		t.Access = authorAccessLabel(a)
+		top[i] = t
+	}
+	return top
+}
+
+// authorAccessLabel names the access an author has. A blacklisted author is
+// blacklisted whatever other access it has; otherwise the operator comes
+// first, then paid, whitelisted and follow access.
+func authorAccessLabel(a db.AuthorAccess) string {
+	switch {
+	case a.Blacklisted:
+		return "blacklisted"
+	case a.Operator:
+		return "operator"
+	case a.PaidStatus == "active" || a.PaidStatus == "grace":
+		return "paid"
+	case a.Whitelisted:
+		return "whitelisted"
+	case a.Follow:
+		return "follow"
+	}
+	return "none"
+}
+
+// GetTopAuthors returns the most active pubkeys by event count, with their
+// names and access. Counts are cached; names and access are read on every
+// request so a newly whitelisted author shows at once.
 // STATS-API-003: GET /api/v1/stats/top-authors
 func (h *Handler) GetTopAuthors(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	// Parse limit query parameter first
 	limit := 10
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -309,18 +453,33 @@ func (h *Handler) GetTopAuthors(w http.ResponseWriter, r *http.Request) {
 
 	// Parse timezone from query parameter
 	timezone := r.URL.Query().Get("timezone")
-	since, until := parseTimeRange(timeRange, timezone)
 
-	authors, err := h.db.GetTopAuthorsInRange(ctx, limit, since, until)
+	key := fmt.Sprintf("top-authors:%s:%s:%d", timeRange, timezone, limit)
+	value, computedAt, err := h.cachedStats(r, key, func(ctx context.Context) (interface{}, error) {
+		since, until := parseTimeRange(timeRange, timezone)
+		return h.db.GetTopAuthorsInRange(ctx, limit, since, until)
+	})
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to get top authors", "STATS_FAILED")
 		return
 	}
+	authors := value.([]db.AuthorCount)
+
+	pubkeys := make([]string, len(authors))
+	for i, author := range authors {
+		pubkeys[i] = author.Pubkey
+	}
+	access, err := h.db.GetAuthorAccess(r.Context(), pubkeys)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get author access", "DB_ERROR")
+		return
+	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"authors":    authors,
-		"time_range": timeRange,
-		"limit":      limit,
+		"authors":     topAuthors(authors, access),
+		"time_range":  timeRange,
+		"limit":       limit,
+		"computed_at": computedAt,
 	})
 }
 
@@ -361,7 +520,8 @@ func parseTimeRange(rangeStr, timezone string) (since, until time.Time) {
 	return since, until
 }
 
-// getKindLabel returns a human-friendly label for a Nostr event kind.
+// getKindLabel returns the dashboard's label for a Nostr event kind; see
+// nostr.LookupKind for names and categories.
 func getKindLabel(kind int) string {
 	switch kind {
 	case 0:
@@ -460,64 +620,44 @@ func (h *Handler) sendDashboardUpdate(w http.ResponseWriter, flusher http.Flushe
 
 	if !relayConnected {
 		stats = map[string]interface{}{
-			"total_events":      0,
-			"events_today":      0,
-			"storage_bytes":     storageBytes,
-			"whitelisted_count": whitelistCount,
-			"events_by_kind":    map[string]int64{},
-			"uptime_seconds":    uptimeSeconds,
-			"relay_status":      relayStatus,
+			"total_events":       0,
+			"events_today":       0,
+			"storage_bytes":      storageBytes,
+			"whitelisted_count":  whitelistCount,
+			"events_by_kind":     map[string]int64{},
+			"events_by_category": []CategoryCount{},
+			"uptime_seconds":     uptimeSeconds,
+			"relay_status":       relayStatus,
 		}
 		recentEvents = []interface{}{}
 	} else {
 		// Get relay stats
-		relayStats, err := h.db.GetRelayStats(ctx)
+		relayStats, _, err := h.relayStats(ctx, false)
 		if err != nil {
 			// On error, send empty stats instead of returning
 			stats = map[string]interface{}{
-				"total_events":      0,
-				"events_today":      0,
-				"storage_bytes":     storageBytes,
-				"whitelisted_count": whitelistCount,
-				"events_by_kind":    map[string]int64{},
-				"uptime_seconds":    uptimeSeconds,
-				"relay_status":      relayStatus,
+				"total_events":       0,
+				"events_today":       0,
+				"storage_bytes":      storageBytes,
+				"whitelisted_count":  whitelistCount,
+				"events_by_kind":     map[string]int64{},
+				"events_by_category": []CategoryCount{},
+				"uptime_seconds":     uptimeSeconds,
+				"relay_status":       relayStatus,
 			}
 			recentEvents = []interface{}{}
 		} else {
 			eventsToday, _ := h.db.GetEventsToday(ctx, loc)
 
-			// Build events_by_kind
-			eventsByKind := map[string]int64{}
-			var otherCount int64 = 0
-			for kind, count := range relayStats.EventsByKind {
-				switch kind {
-				case 1:
-					eventsByKind["posts"] = count
-				case 3:
-					eventsByKind["follows"] = count
-				case 4, 14:
-					eventsByKind["dms"] = eventsByKind["dms"] + count
-				case 6:
-					eventsByKind["reposts"] = count
-				case 7:
-					eventsByKind["reactions"] = count
-				default:
-					otherCount += count
-				}
-			}
-			if otherCount > 0 {
-				eventsByKind["other"] = otherCount
-			}
-
 			stats = map[string]interface{}{
-				"total_events":      relayStats.TotalEvents,
-				"events_today":      eventsToday,
-				"storage_bytes":     storageBytes,
-				"whitelisted_count": whitelistCount,
-				"events_by_kind":    eventsByKind,
-				"uptime_seconds":    uptimeSeconds,
-				"relay_status":      relayStatus,
+				"total_events":       relayStats.TotalEvents,
+				"events_today":       eventsToday,
+				"storage_bytes":      storageBytes,
+				"whitelisted_count":  whitelistCount,
+				"events_by_kind":     dashboardKindCounts(relayStats.EventsByKind),
+				"events_by_category": categoryCounts(relayStats.EventsByKind),
+				"uptime_seconds":     uptimeSeconds,
+				"relay_status":       relayStatus,
 			}
 
 			// Get recent events
@@ -525,7 +665,10 @@ func (h *Handler) sendDashboardUpdate(w http.ResponseWriter, flusher http.Flushe
 			if err != nil {
 				recentEvents = []interface{}{}
 			} else {
-				recentEvents = events
+				if h.redactDMs(ctx) {
+					db.RedactDMs(events)
+				}
+				recentEvents = labelEvents(events)
 			}
 		}
 	}
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
 )
 
 // ============================================================================
@@ -544,3 +546,65 @@ func TestGetKindLabel(t *testing.T) {
 		}
 	}
 }
+
+func TestCategoryCounts(t *testing.T) {
+	categories := categoryCounts(map[int]int64{1: 50, 1111: 10, 7: 30, 4: 5, 1059: 5})
+
+	expected := []struct {
+		category string
+		count    int64
+	}{
+		{"notes", 60},
+		{"reactions", 30},
+		{"dms", 10},
+	}
+	if len(categories) != len(expected) {
+		t.Fatalf("expected %d categories, got %+v", len(expected), categories)
+	}
+	for i, e := range expected {
+		if categories[i].Category != e.category || categories[i].Count != e.count {
+			t.Errorf("category %d: expected %s=%d, got %+v", i, e.category, e.count, categories[i])
+		}
+	}
+	if categories[0].Percent != 60 {
+		t.Errorf("expected notes at 60%%, got %v", categories[0].Percent)
+	}
+
+	if empty := categoryCounts(nil); empty == nil || len(empty) != 0 {
+		t.Errorf("expected an empty slice, got %#v", empty)
+	}
+}
+
+func TestTopAuthors(t *testing.T) {
+	alice := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	authors := []db.AuthorCount{
+		{Pubkey: alice, EventCount: 40},
+		{Pubkey: "bob", EventCount: 30},
+		{Pubkey: "carol", EventCount: 20},
+		{Pubkey: "spammer", EventCount: 10},
+		{Pubkey: "stranger", EventCount: 5},
+	}
+	access := map[string]db.AuthorAccess{
+		alice:     {Nickname: "Alice", Name: "alice_n", Whitelisted: true},
+		"bob":     {Name: "Bob", Whitelisted: true, PaidStatus: "active"},
+		"carol":   {Whitelisted: true, PaidStatus: "expired"},
+		"spammer": {Whitelisted: true, Blacklisted: true},
+	}
+
+	top := topAuthors(authors, access)
+	want := []struct{ name, access string }{
+		{"Alice", "whitelisted"},
+		{"Bob", "paid"},
+		{"", "whitelisted"},
+		{"", "blacklisted"},
+		{"", "none"},
+	}
+	for i, w := range want {
+		if top[i].Name != w.name || top[i].Access != w.access || top[i].EventCount != authors[i].EventCount {
+			t.Errorf("author %d: expected %s (%s), got %+v", i, w.name, w.access, top[i])
+		}
+	}
+	if top[0].Npub == "" || top[1].Paid != true || top[2].Paid {
+		t.Errorf("unexpected npub or paid flags: %+v", top[:3])
+	}
+}
@@ -2,8 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
 )
 
 // StorageStatusResponse represents the storage status response.
@@ -19,12 +24,17 @@ type StorageStatusResponse struct {
 	NewestEvent     *time.Time `json:"newest_event,omitempty"`
 	Status          string     `json:"status"`
 	PendingDeletions int64     `json:"pending_deletions"`
+	RelayType       string     `json:"relay_type"`
+	RelayDatabase   string     `json:"relay_database"` // sqlite or postgres
+	IngestPaused    bool       `json:"ingest_paused"`  // syncs and imports paused by the storage pressure policy
+	Expiration      *db.ExpirationStats `json:"expiration"` // events deleted past their NIP-40 expiration, and ephemeral or expired events refused
 }
 
 // RetentionPolicyRequest represents a retention policy update request.
 type RetentionPolicyRequest struct {
 	RetentionDays int64    `json:"retention_days"`
 	Exceptions    []string `json:"exceptions"`
+	KindRules     []db.RetentionRule `json:"kind_rules"`
 	HonorNIP09    bool     `json:"honor_nip09"`
 }
 
@@ -97,16 +107,20 @@ func (h *Handler) GetStorageStatus(w http.ResponseWriter, r *http.Request) {
 		pendingDeletions = 0
 	}
 
-	// Determine status based on usage
-	status := "healthy"
-	if usagePercent >= 95 {
-		status = "critical"
-	} else if usagePercent >= 90 {
-		status = "low"
-	} else if usagePercent >= 80 {
-		status = "warning"
+	expiration, err := h.db.GetExpirationStats(ctx)
+	if err != nil {
+		expiration = &db.ExpirationStats{}
+	}
+
+	relayDatabase := "sqlite"
+	if h.db.IsRelayPostgres() {
+		relayDatabase = "postgres"
 	}
 
+	// Determine status based on usage
+	status := services.StorageLevel(usagePercent)
+	ingestPaused := h.services != nil && h.services.Pressure.IngestPaused()
+
 	respondJSON(w, http.StatusOK, StorageStatusResponse{
 		DatabaseSize:     relayDBSize,
 		AppDatabaseSize:  appDBSize,
@@ -119,6 +133,10 @@ func (h *Handler) GetStorageStatus(w http.ResponseWriter, r *http.Request) {
 		NewestEvent:      newestEvent,
 		Status:           status,
 		PendingDeletions: pendingDeletions,
+		RelayType:        h.db.RelayType(),
+		RelayDatabase:    relayDatabase,
+		IngestPaused:     ingestPaused,
+		Expiration:       expiration,
 	})
 }
 
@@ -136,6 +154,7 @@ func (h *Handler) GetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"retention_days": policy.RetentionDays,
 		"exceptions":     policy.Exceptions,
+		"kind_rules":     policy.KindRules,
 		"honor_nip09":    policy.HonorNIP09,
 		"last_run":       policy.LastRun,
 	})
@@ -157,6 +176,10 @@ func (h *Handler) UpdateRetentionPolicy(w http.ResponseWriter, r *http.Request)
 		respondError(w, http.StatusBadRequest, "Retention days must be non-negative", "INVALID_RETENTION_DAYS")
 		return
 	}
+	if err := services.ValidateRetentionRules(req.KindRules); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_RETENTION_RULE")
+		return
+	}
 
 	// Get current policy to preserve LastRun
 	currentPolicy, err := h.db.GetRetentionPolicy(ctx)
@@ -169,6 +192,7 @@ func (h *Handler) UpdateRetentionPolicy(w http.ResponseWriter, r *http.Request)
 	newPolicy := currentPolicy
 	newPolicy.RetentionDays = req.RetentionDays
 	newPolicy.Exceptions = req.Exceptions
+	newPolicy.KindRules = req.KindRules
 	newPolicy.HonorNIP09 = req.HonorNIP09
 
 	if err := h.db.SetRetentionPolicy(ctx, newPolicy); err != nil {
@@ -180,6 +204,7 @@ func (h *Handler) UpdateRetentionPolicy(w http.ResponseWriter, r *http.Request)
 	h.db.AddAuditLog(ctx, "retention_policy_updated", map[string]interface{}{
 		"retention_days": req.RetentionDays,
 		"exceptions":     req.Exceptions,
+		"kind_rules":     req.KindRules,
 		"honor_nip09":    req.HonorNIP09,
 	}, "")
 
@@ -188,145 +213,86 @@ func (h *Handler) UpdateRetentionPolicy(w http.ResponseWriter, r *http.Request)
 		"message":        "Retention policy updated",
 		"retention_days": req.RetentionDays,
 		"exceptions":     req.Exceptions,
+		"kind_rules":     req.KindRules,
 		"honor_nip09":    req.HonorNIP09,
 	})
 }
 
-// ManualCleanup performs a manual cleanup of events before a given date.
-// POST /api/v1/storage/cleanup
-func (h *Handler) ManualCleanup(w http.ResponseWriter, r *http.Request) {
+// PreviewRetentionPolicy returns how many events and bytes each retention
+// rule would delete, without deleting anything. The body is an optional
+// policy to try out; without one the saved policy is previewed.
+// POST /api/v1/storage/retention/preview
+func (h *Handler) PreviewRetentionPolicy(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	var req CleanupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+	if h.services == nil || h.services.Retention == nil {
+		respondError(w, http.StatusServiceUnavailable, "Retention service not available", "SERVICE_UNAVAILABLE")
 		return
 	}
 
-	// Parse the before date
-	beforeDate, err := time.Parse(time.RFC3339, req.BeforeDate)
+	policy, err := h.db.GetRetentionPolicy(ctx)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid date format. Use ISO 8601 (YYYY-MM-DDTHH:MM:SSZ)", "INVALID_DATE")
+		respondError(w, http.StatusInternalServerError, "Failed to get retention policy", "RETENTION_GET_FAILED")
 		return
 	}
 
-	// Validate date is in the past
-	if beforeDate.After(time.Now()) {
-		respondError(w, http.StatusBadRequest, "Date must be in the past", "FUTURE_DATE")
+	var req RetentionPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
 		return
-	}
-
-	// Determine which exceptions to apply (if any)
-	var exceptions []string
-	var operatorPubkey string
-
-	if req.ApplyExceptions {
-		// Use explicit exceptions if provided, otherwise use retention policy
-		if len(req.Exceptions) > 0 {
-			exceptions = req.Exceptions
-		} else {
-			policy, err := h.db.GetRetentionPolicy(ctx)
-			if err != nil {
-				respondError(w, http.StatusInternalServerError, "Failed to get retention policy", "RETENTION_GET_FAILED")
-				return
-			}
-			exceptions = policy.Exceptions
+	} else if err == nil {
+		if req.RetentionDays < 0 {
+			respondError(w, http.StatusBadRequest, "Retention days must be non-negative", "INVALID_RETENTION_DAYS")
+			return
 		}
-		// Get operator pubkey for exception handling
-		operatorPubkey, _ = h.db.GetOperatorPubkey(ctx)
+		if err := services.ValidateRetentionRules(req.KindRules); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error(), "INVALID_RETENTION_RULE")
+			return
+		}
+		policy.RetentionDays = req.RetentionDays
+		policy.Exceptions = req.Exceptions
+		policy.KindRules = req.KindRules
 	}
-	// If ApplyExceptions is false, exceptions stays nil/empty - delete ALL events
-
-	// Get size before cleanup
-	sizeBefore, _ := h.db.GetRelayDatabaseSize()
 
-	// Open relay writer for deletion
-	writer, err := h.db.NewRelayWriter()
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to open database for writing", "DB_WRITE_FAILED")
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
 		return
 	}
-	defer writer.Close()
 
-	// Delete events
-	deletedCount, err := writer.DeleteEventsBefore(ctx, beforeDate, exceptions, operatorPubkey)
+	preview, err := h.services.Retention.Preview(ctx, policy)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to delete events", "DELETE_FAILED")
+		respondError(w, http.StatusInternalServerError, "Failed to preview retention policy", "COUNT_FAILED")
 		return
 	}
 
-	// Get size after cleanup (before vacuum)
-	sizeAfter, _ := h.db.GetRelayDatabaseSize()
-	spaceFreed := sizeBefore - sizeAfter
-
-	// Add audit log
-	h.db.AddAuditLog(ctx, "manual_cleanup", map[string]interface{}{
-		"before_date":       req.BeforeDate,
-		"deleted_count":     deletedCount,
-		"space_freed":       spaceFreed,
-		"apply_exceptions":  req.ApplyExceptions,
-		"exceptions_used":   exceptions,
-	}, "")
-
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"success":       true,
-		"deleted_count": deletedCount,
-		"space_freed":   spaceFreed,
-		"message":       "Cleanup completed. Run VACUUM to fully reclaim disk space.",
-	})
+	respondJSON(w, http.StatusOK, preview)
 }
 
-// RunVacuum runs SQLite VACUUM on the databases to reclaim disk space.
-// POST /api/v1/storage/vacuum
-func (h *Handler) RunVacuum(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	startTime := time.Now()
-
-	// Get sizes before vacuum
-	relayDBSizeBefore, _ := h.db.GetRelayDatabaseSize()
-	appDBSizeBefore, _ := h.db.GetAppDatabaseSize()
-
-	// Vacuum app database
-	if err := h.db.RunAppVacuum(ctx); err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to vacuum app database", "VACUUM_FAILED")
+// ManualCleanup performs a manual cleanup of events before a given date.
+// POST /api/v1/storage/cleanup
+func (h *Handler) ManualCleanup(w http.ResponseWriter, r *http.Request) {
+	var req CleanupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
 		return
 	}
 
-	// Vacuum relay database
-	writer, err := h.db.NewRelayWriter()
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to open relay database for vacuum", "DB_WRITE_FAILED")
+	cleanup, status, code, msg := h.prepareCleanup(r.Context(), req)
+	if code != "" {
+		respondError(w, status, msg, code)
 		return
 	}
-	defer writer.Close()
 
-	if err := writer.RunVacuum(ctx); err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to vacuum relay database", "VACUUM_FAILED")
+	h.runJob(w, r, services.JobTypeCleanup, req, cleanup.run, "Failed to delete events", "DELETE_FAILED")
+}
+
+// RunVacuum runs SQLite VACUUM on the databases to reclaim disk space.
+// POST /api/v1/storage/vacuum
+func (h *Handler) RunVacuum(w http.ResponseWriter, r *http.Request) {
+	if !h.storageMaintenanceAvailable(w) {
 		return
 	}
-
-	// Get sizes after vacuum
-	relayDBSizeAfter, _ := h.db.GetRelayDatabaseSize()
-	appDBSizeAfter, _ := h.db.GetAppDatabaseSize()
-
-	spaceReclaimed := (relayDBSizeBefore - relayDBSizeAfter) + (appDBSizeBefore - appDBSizeAfter)
-	duration := time.Since(startTime)
-
-	// Update last vacuum timestamp
-	h.db.SetLastVacuumRun(ctx, time.Now())
-
-	// Add audit log
-	h.db.AddAuditLog(ctx, "vacuum_run", map[string]interface{}{
-		"space_reclaimed": spaceReclaimed,
-		"duration_ms":     duration.Milliseconds(),
-	}, "")
-
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"success":         true,
-		"space_reclaimed": spaceReclaimed,
-		"duration_ms":     duration.Milliseconds(),
-	})
+	h.runJob(w, r, services.JobTypeVacuum, nil, h.vacuumJob, "Failed to vacuum", "VACUUM_FAILED")
 }
 
 // GetDeletionRequests returns the list of NIP-09 deletion requests.
@@ -425,82 +391,58 @@ func (h *Handler) GetStorageEstimate(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetStorageBreakdown returns storage used per author and per kind, from
+// the breakdown the background worker last computed.
+// GET /api/v1/storage/breakdown?limit=20&refresh=false
+func (h *Handler) GetStorageBreakdown(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Breakdown == nil {
+		respondError(w, http.StatusServiceUnavailable, "Storage breakdown service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+	if !h.db.IsRelayDBConnected() {
+		respondError(w, http.StatusServiceUnavailable, "Relay database not connected", "RELAY_NOT_CONNECTED")
+		return
+	}
+
+	limit := parseIntParam(r.URL.Query().Get("limit"), 20)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	var breakdown *db.StorageBreakdown
+	var err error
+	if r.URL.Query().Get("refresh") == "true" {
+		breakdown, err = h.services.Breakdown.Refresh(r.Context())
+	} else {
+		breakdown, err = h.services.Breakdown.Get(r.Context())
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get storage breakdown", "BREAKDOWN_FAILED")
+		return
+	}
+
+	if len(breakdown.TopAuthors) > limit {
+		breakdown.TopAuthors = breakdown.TopAuthors[:limit]
+	}
+	respondJSON(w, http.StatusOK, breakdown)
+}
+
 // RunRetentionNow runs the retention policy immediately.
 // POST /api/v1/storage/retention/run
 func (h *Handler) RunRetentionNow(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	if h.services == nil || h.services.Retention == nil {
 		respondError(w, http.StatusServiceUnavailable, "Retention service not available", "SERVICE_UNAVAILABLE")
 		return
 	}
 
-	result, err := h.services.Retention.RunNowSync(ctx)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to run retention: "+err.Error(), "RETENTION_FAILED")
-		return
-	}
-
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"success":                   true,
-		"events_deleted":            result.EventsDeleted,
-		"deletion_requests_processed": result.DeletionRequests,
-		"deletion_events_deleted":   result.DeletionEventsDeleted,
-		"retention_days":            result.RetentionDays,
-		"cutoff":                    result.Cutoff,
-		"disabled":                  result.Disabled,
-	})
+	h.runJob(w, r, services.JobTypeRetention, nil, h.retentionJob, "Failed to run retention", "RETENTION_FAILED")
 }
 
 // RunIntegrityCheck runs an integrity check on the databases.
 // POST /api/v1/storage/integrity-check
 func (h *Handler) RunIntegrityCheck(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	startTime := time.Now()
-
-	// Check app database
-	appOK, appResult, err := h.db.RunAppIntegrityCheck(ctx)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to check app database integrity", "INTEGRITY_CHECK_FAILED")
+	if !h.storageMaintenanceAvailable(w) {
 		return
 	}
-
-	// Check relay database
-	var relayOK bool
-	var relayResult string
-
-	writer, err := h.db.NewRelayWriter()
-	if err != nil {
-		relayOK = false
-		relayResult = "Failed to open database"
-	} else {
-		defer writer.Close()
-		relayOK, relayResult, err = writer.RunIntegrityCheck(ctx)
-		if err != nil {
-			relayOK = false
-			relayResult = err.Error()
-		}
-	}
-
-	duration := time.Since(startTime)
-
-	// Update last integrity check timestamp
-	h.db.SetLastIntegrityCheck(ctx, time.Now())
-
-	// Add audit log
-	h.db.AddAuditLog(ctx, "integrity_check", map[string]interface{}{
-		"app_ok":       appOK,
-		"relay_ok":     relayOK,
-		"duration_ms":  duration.Milliseconds(),
-	}, "")
-
-	allOK := appOK && relayOK
-
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"success":     allOK,
-		"app_db":      map[string]interface{}{"ok": appOK, "result": appResult},
-		"relay_db":    map[string]interface{}{"ok": relayOK, "result": relayResult},
-		"duration_ms": duration.Milliseconds(),
-	})
+	h.runJob(w, r, services.JobTypeIntegrityCheck, nil, h.integrityCheckJob, "Failed to check database integrity", "INTEGRITY_CHECK_FAILED")
 }
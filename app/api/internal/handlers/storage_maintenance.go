@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetStorageMaintenanceSchedule returns the schedule for automatic VACUUM
+// and integrity checks, with when each last ran.
+// GET /api/v1/storage/maintenance/schedule
+func (h *Handler) GetStorageMaintenanceSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	schedule, err := h.db.GetStorageMaintenanceSchedule(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get maintenance schedule", "DB_ERROR")
+		return
+	}
+	lastVacuum, _ := h.db.GetLastVacuumRun(ctx)
+	lastIntegrityCheck, _ := h.db.GetLastIntegrityCheck(ctx)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"schedule":             schedule,
+		"last_vacuum":          lastVacuum,
+		"last_integrity_check": lastIntegrityCheck,
+	})
+}
+
+// UpdateStorageMaintenanceSchedule saves the schedule for automatic VACUUM
+// and integrity checks.
+// PUT /api/v1/storage/maintenance/schedule
+func (h *Handler) UpdateStorageMaintenanceSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var schedule db.StorageMaintenanceSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+	if err := services.ValidateMaintenanceSchedule(&schedule); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), "INVALID_SCHEDULE")
+		return
+	}
+
+	if err := h.db.SetStorageMaintenanceSchedule(ctx, &schedule); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update maintenance schedule", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "maintenance_schedule_updated", map[string]interface{}{
+		"enabled":                 schedule.Enabled,
+		"vacuum_interval_days":    schedule.VacuumIntervalDays,
+		"integrity_interval_days": schedule.IntegrityIntervalDays,
+		"window_start_hour":       schedule.WindowStartHour,
+		"window_end_hour":         schedule.WindowEndHour,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"schedule": schedule,
+	})
+}
+
+// GetStorageMaintenanceHistory returns past VACUUM and integrity check runs,
+// one entry per database, newest first.
+// GET /api/v1/storage/maintenance/history?task=vacuum&limit=50&offset=0
+func (h *Handler) GetStorageMaintenanceHistory(w http.ResponseWriter, r *http.Request) {
+	if !h.storageMaintenanceAvailable(w) {
+		return
+	}
+
+	query := r.URL.Query()
+	task := query.Get("task")
+	if task != "" && task != services.JobTypeVacuum && task != services.JobTypeIntegrityCheck {
+		respondError(w, http.StatusBadRequest, "task must be vacuum or integrity_check", "INVALID_TASK")
+		return
+	}
+	limit := 50
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	offset := 0
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	runs, total, err := h.services.Maintenance.History(r.Context(), task, limit, offset)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get maintenance history", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"runs":   runs,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// storageMaintenanceAvailable responds with 503 if the storage maintenance
+// service is not running.
+func (h *Handler) storageMaintenanceAvailable(w http.ResponseWriter) bool {
+	if h.services == nil || h.services.Maintenance == nil {
+		respondError(w, http.StatusServiceUnavailable, "Storage maintenance service not available", "SERVICE_UNAVAILABLE")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetStoragePressurePolicy returns the actions taken as the disk fills up,
+// with what they have done so far.
+// GET /api/v1/storage/pressure
+func (h *Handler) GetStoragePressurePolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.db.GetStoragePressurePolicy(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get storage pressure policy", "DB_ERROR")
+		return
+	}
+
+	var state *services.StoragePressureState
+	if h.services != nil && h.services.Pressure != nil {
+		s := h.services.Pressure.State()
+		state = &s
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"policy": policy,
+		"state":  state,
+	})
+}
+
+// UpdateStoragePressurePolicy saves the actions taken as the disk fills up
+// and applies them right away.
+// PUT /api/v1/storage/pressure
+func (h *Handler) UpdateStoragePressurePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	policy, err := h.db.GetStoragePressurePolicy(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get storage pressure policy", "DB_ERROR")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(policy); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if policy.RetentionPercent < 0 || policy.RetentionPercent > 100 || policy.PausePercent < 0 || policy.PausePercent > 100 {
+		respondError(w, http.StatusBadRequest, "Thresholds must be between 0 and 100 percent", "INVALID_SETTINGS")
+		return
+	}
+	if policy.RetentionPercent > 0 && policy.RetentionDays < 1 {
+		respondError(w, http.StatusBadRequest, "retention_days must be at least 1 when retention_percent is set", "INVALID_SETTINGS")
+		return
+	}
+
+	if err := h.db.SetStoragePressurePolicy(ctx, policy); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save storage pressure policy", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "storage_pressure_updated", policy, "")
+
+	if h.services != nil && h.services.Pressure != nil {
+		h.services.Pressure.Wake()
+	}
+
+	respondJSON(w, http.StatusOK, policy)
+}
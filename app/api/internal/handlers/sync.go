@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
 	"github.com/roostr/roostr/app/api/internal/nostr"
@@ -14,10 +18,11 @@ import (
 
 // StartSyncRequest is the request body for starting a sync.
 type StartSyncRequest struct {
-	Pubkeys        []string `json:"pubkeys"`
-	Relays         []string `json:"relays,omitempty"`
-	EventKinds     []int    `json:"event_kinds,omitempty"`
-	SinceTimestamp *int64   `json:"since_timestamp,omitempty"`
+	Pubkeys          []string `json:"pubkeys"`
+	Relays           []string `json:"relays,omitempty"`
+	EventKinds       []int    `json:"event_kinds,omitempty"`
+	SinceTimestamp   *int64   `json:"since_timestamp,omitempty"`
+	SkipVerification bool     `json:"skip_verification,omitempty"`
 }
 
 // StartSync initiates a sync job from public relays.
@@ -39,18 +44,23 @@ func (h *Handler) StartSync(w http.ResponseWriter, r *http.Request) {
 
 	// Start sync via service
 	syncReq := services.SyncRequest{
-		Pubkeys:        req.Pubkeys,
-		Relays:         req.Relays,
-		EventKinds:     req.EventKinds,
-		SinceTimestamp: req.SinceTimestamp,
+		Pubkeys:          req.Pubkeys,
+		Relays:           req.Relays,
+		EventKinds:       req.EventKinds,
+		SinceTimestamp:   req.SinceTimestamp,
+		SkipVerification: req.SkipVerification,
 	}
 
 	jobID, err := h.services.Sync.StartSync(ctx, syncReq)
 	if err != nil {
-		if err.Error() == "a sync job is already running" {
+		if errors.Is(err, services.ErrSyncAlreadyRunning) {
 			respondError(w, http.StatusConflict, err.Error(), "SYNC_ALREADY_RUNNING")
 			return
 		}
+		if errors.Is(err, services.ErrStoragePressure) {
+			respondError(w, http.StatusInsufficientStorage, err.Error(), "STORAGE_PRESSURE")
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to start sync: "+err.Error(), "SYNC_START_FAILED")
 		return
 	}
@@ -125,6 +135,160 @@ func (h *Handler) CancelSync(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// StreamSyncProgress streams a sync's progress via Server-Sent Events. Each
+// update is a "progress" event with the event counts and every relay's
+// connection status; the stream ends with a "done" event holding the final
+// status and error message. A finished sync gets only the "done" event.
+// GET /api/v1/sync/jobs/{id}/progress
+func (h *Handler) StreamSyncProgress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	job, ok := h.syncJobFromPath(w, r)
+	if !ok {
+		return
+	}
+	jobID := job.ID
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "Streaming not supported", "STREAMING_NOT_SUPPORTED")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{}) // No deadline
+
+	send := func(event string, progress services.SyncProgress) {
+		data, _ := json.Marshal(progress)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	// done sends the final state. The sync_jobs record is authoritative for
+	// the counts; relay states are only known for the most recent sync.
+	done := func() {
+		final := services.SyncProgress{Relays: []services.SyncRelayStatus{}}
+		if progress, _, ok := h.services.Sync.Progress(jobID); ok {
+			final = progress
+		}
+		if finished, err := h.db.GetSyncJob(context.Background(), jobID); err == nil && finished != nil {
+			job = finished
+		}
+		final.JobID = job.ID
+		final.Status = job.Status
+		final.EventsFetched = job.EventsFetched
+		final.EventsStored = job.EventsStored
+		final.EventsSkipped = job.EventsSkipped
+		final.EventsRejected = job.EventsRejected
+		final.ErrorMessage = job.ErrorMessage
+		send("done", final)
+	}
+
+	_, runID, _ := h.services.Sync.Progress(jobID)
+	if runID == 0 || h.services.Jobs == nil {
+		done()
+		return
+	}
+	updates, unsubscribe, running := h.services.Jobs.Subscribe(runID)
+	if !running {
+		done()
+		return
+	}
+	defer unsubscribe()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-updates:
+			if !ok {
+				done()
+				return
+			}
+			if progress, _, ok := h.services.Sync.Progress(jobID); ok && progress.Status == "running" {
+				send("progress", progress)
+			}
+		case <-keepalive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// PauseSync stops a running sync so it can be resumed later. Its cursors are
+// kept, and it is saved with status "paused".
+// POST /api/v1/sync/jobs/{id}/pause
+func (h *Handler) PauseSync(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.syncJobFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Sync.PauseSync(job.ID); err != nil {
+		respondError(w, http.StatusConflict, "Sync job is not running", "SYNC_NOT_RUNNING")
+		return
+	}
+
+	h.db.AddAuditLog(r.Context(), "sync_paused", map[string]interface{}{
+		"job_id": job.ID,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Sync pause requested",
+	})
+}
+
+// ResumeSync resumes a paused sync from where each relay and pubkey left
+// off. Syncs still running when the API stopped are paused and can be
+// resumed too.
+// POST /api/v1/sync/jobs/{id}/resume
+func (h *Handler) ResumeSync(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.syncJobFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	run, err := h.services.Sync.ResumeSync(r.Context(), job.ID)
+	switch {
+	case errors.Is(err, services.ErrSyncNotPaused):
+		respondError(w, http.StatusConflict, "Sync job is not paused", "SYNC_NOT_PAUSED")
+		return
+	case errors.Is(err, services.ErrSyncAlreadyRunning):
+		respondError(w, http.StatusConflict, err.Error(), "SYNC_ALREADY_RUNNING")
+		return
+	case errors.Is(err, services.ErrStoragePressure):
+		respondError(w, http.StatusInsufficientStorage, err.Error(), "STORAGE_PRESSURE")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, "Failed to resume sync: "+err.Error(), "SYNC_RESUME_FAILED")
+		return
+	case run == nil:
+		respondError(w, http.StatusNotFound, "Job not found", "JOB_NOT_FOUND")
+		return
+	}
+
+	h.db.AddAuditLog(r.Context(), "sync_resumed", map[string]interface{}{
+		"job_id": job.ID,
+		"run_id": run.ID,
+	}, "")
+
+	w.Header().Set("Location", "/api/v1/sync/status")
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"job_id":  job.ID,
+		"status":  "running",
+		"message": "Sync job resumed",
+	})
+}
+
 // GetSyncHistory returns a list of past sync jobs.
 // GET /api/v1/sync/history
 // GET /api/v1/sync/history?limit=10&offset=0
@@ -285,6 +449,7 @@ func (h *Handler) RemoveSyncPubkey(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusInternalServerError, "Failed to remove sync pubkey", "DB_ERROR")
 		return
 	}
+	h.db.DeleteMirrorCoverage(ctx, pubkey)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -427,6 +592,26 @@ func (h *Handler) ResetSyncRelays(w http.ResponseWriter, r *http.Request) {
 }
 
 // isValidRelayURL checks if a URL is a valid WebSocket relay URL
+// syncJobFromPath loads the sync job named by the {id} path value, responding
+// with an error if there is none.
+func (h *Handler) syncJobFromPath(w http.ResponseWriter, r *http.Request) (*db.SyncJob, bool) {
+	jobID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid job ID", "INVALID_ID")
+		return nil, false
+	}
+	job, err := h.db.GetSyncJob(r.Context(), jobID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get job status", "STATUS_FAILED")
+		return nil, false
+	}
+	if job == nil {
+		respondError(w, http.StatusNotFound, "Job not found", "JOB_NOT_FOUND")
+		return nil, false
+	}
+	return job, true
+}
+
 func isValidRelayURL(u string) bool {
 	if !strings.HasPrefix(u, "wss://") && !strings.HasPrefix(u, "ws://") {
 		return false
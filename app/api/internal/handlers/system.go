@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetSystemStats returns the latest system sample and the samples of the
+// last hours, up to a day, for charting CPU, memory, disk I/O and the API
+// and relay processes.
+// GET /api/v1/system/stats?hours=24
+func (h *Handler) GetSystemStats(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.SystemStats == nil {
+		respondError(w, http.StatusServiceUnavailable, "System stats service not available", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	hours := parseIntParam(r.URL.Query().Get("hours"), 24)
+	if hours < 1 || hours > 24 {
+		respondError(w, http.StatusBadRequest, "Hours must be between 1 and 24", "INVALID_HOURS")
+		return
+	}
+
+	stats := h.services.SystemStats
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"current":          stats.Latest(),
+		"samples":          stats.Samples(time.Now().Add(-time.Duration(hours) * time.Hour)),
+		"interval_seconds": int(services.SystemStatsInterval.Seconds()),
+	})
+}
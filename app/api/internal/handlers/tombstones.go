@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/secrets"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// GetTombstones returns the events the deletion worker removed, newest
+// first. Tombstoned events are skipped by syncs and imports.
+// GET /api/v1/storage/tombstones
+func (h *Handler) GetTombstones(w http.ResponseWriter, r *http.Request) {
+	limit := parseIntParam(r.URL.Query().Get("limit"), 50)
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	offset := parseIntParam(r.URL.Query().Get("offset"), 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	tombstones, total, err := h.db.GetTombstones(r.Context(), limit, offset)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get tombstones", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"tombstones": tombstones,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// DeleteTombstone removes an event's tombstone, so the next sync or import
+// can bring the event back.
+// DELETE /api/v1/storage/tombstones/{id}
+func (h *Handler) DeleteTombstone(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := strings.ToLower(r.PathValue("id"))
+
+	deleted, err := h.db.DeleteTombstone(ctx, id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete tombstone", "DB_ERROR")
+		return
+	}
+	if !deleted {
+		respondError(w, http.StatusNotFound, "Tombstone not found", "NOT_FOUND")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "tombstone_deleted", map[string]interface{}{"event_id": id}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"event_id": id,
+	})
+}
+
+// TombstonePublishingRequest is the request body for updating deletion
+// publishing. SigningKey is the operator's nsec or hex secret key; it is
+// left unchanged when omitted and removed when empty.
+type TombstonePublishingRequest struct {
+	Enabled    bool     `json:"enabled"`
+	Relays     []string `json:"relays"`
+	SigningKey *string  `json:"signing_key,omitempty"`
+}
+
+// GetTombstonePublishing returns whether deletions are published to other
+// relays as NIP-09 kind 5 events and whether the operator's key is set.
+// GET /api/v1/storage/tombstones/publishing
+func (h *Handler) GetTombstonePublishing(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetTombstonePublishing(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get publishing settings", "DB_ERROR")
+		return
+	}
+	h.respondTombstonePublishing(w, r, settings)
+}
+
+// UpdateTombstonePublishing saves the deletion publishing settings and the
+// operator's delegated key, which must belong to the operator pubkey.
+// PUT /api/v1/storage/tombstones/publishing
+func (h *Handler) UpdateTombstonePublishing(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req TombstonePublishingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	relays, ok := normalizeBackupRelays(req.Relays)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid relay URL. Must start with wss:// or ws://", "INVALID_URL")
+		return
+	}
+
+	if req.SigningKey != nil {
+		secret := strings.TrimSpace(*req.SigningKey)
+		if secret != "" {
+			if strings.HasPrefix(secret, "nsec1") {
+				decoded, err := nostr.DecodeNsec(secret)
+				if err != nil {
+					respondError(w, http.StatusBadRequest, "Invalid nsec", "INVALID_KEY")
+					return
+				}
+				secret = decoded
+			}
+			pubkey, err := nostr.GetPublicKey(strings.ToLower(secret))
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "Signing key must be an nsec or 64 hex characters", "INVALID_KEY")
+				return
+			}
+			operatorPubkey, _ := h.db.GetAppState(ctx, "operator_pubkey")
+			if pubkey != operatorPubkey {
+				respondError(w, http.StatusBadRequest, "Signing key does not belong to the operator pubkey", "KEY_MISMATCH")
+				return
+			}
+			secret = strings.ToLower(secret)
+		}
+		if err := h.db.SetOperatorSigningKey(ctx, secret); err != nil {
+			if errors.Is(err, secrets.ErrLocked) {
+				respondSecretsError(w, err)
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to save signing key", "DB_ERROR")
+			return
+		}
+	}
+
+	settings := db.TombstonePublishing{Enabled: req.Enabled, Relays: relays}
+	if err := h.db.SetTombstonePublishing(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save publishing settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "tombstone_publishing_updated", map[string]interface{}{
+		"enabled":             settings.Enabled,
+		"relays":              settings.Relays,
+		"signing_key_changed": req.SigningKey != nil,
+	}, "")
+
+	h.respondTombstonePublishing(w, r, &settings)
+}
+
+// respondTombstonePublishing writes the publishing settings, whether the
+// operator's key can be used, and how deletions are signed.
+func (h *Handler) respondTombstonePublishing(w http.ResponseWriter, r *http.Request, settings *db.TombstonePublishing) {
+	keyStatus := "missing"
+	if secret, err := h.db.GetOperatorSigningKey(r.Context()); errors.Is(err, secrets.ErrLocked) {
+		keyStatus = "locked"
+	} else if err == nil && secret != "" {
+		keyStatus = "set"
+	}
+	signerMode := services.SignerModeNone
+	if h.services != nil && h.services.Signer != nil {
+		if status, err := h.services.Signer.Status(r.Context()); err == nil {
+			signerMode = status.Mode
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"settings":    settings,
+		"signing_key": keyStatus,
+		"signer":      signerMode,
+	})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// UptimeResponse is the response for the relay uptime endpoint.
+type UptimeResponse struct {
+	Enabled bool             `json:"enabled"`
+	Current *db.UptimeCheck  `json:"current"`
+	Last24h db.UptimeSummary `json:"last_24h"`
+	Last7d  db.UptimeSummary `json:"last_7d"`
+	Last30d db.UptimeSummary `json:"last_30d"`
+	Daily   []db.UptimeDay   `json:"daily"`
+}
+
+// GetRelayUptime returns the relay's availability and REQ latency, from the
+// checks the uptime service makes against the relay's WebSocket.
+// GET /api/v1/relay/uptime?days=30
+func (h *Handler) GetRelayUptime(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	days := parseIntParam(r.URL.Query().Get("days"), 30)
+	if days < 1 || days > 90 {
+		respondError(w, http.StatusBadRequest, "Days must be between 1 and 90", "INVALID_DAYS")
+		return
+	}
+
+	resp := UptimeResponse{
+		Enabled: h.services != nil && h.services.Uptime != nil && h.services.Uptime.IsEnabled(),
+	}
+	var err error
+	if resp.Current, err = h.db.GetLatestUptimeCheck(ctx); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get uptime checks", "DB_ERROR")
+		return
+	}
+
+	now := time.Now()
+	for _, p := range []struct {
+		summary *db.UptimeSummary
+		since   time.Time
+	}{
+		{&resp.Last24h, now.Add(-24 * time.Hour)},
+		{&resp.Last7d, now.AddDate(0, 0, -7)},
+		{&resp.Last30d, now.AddDate(0, 0, -30)},
+	} {
+		if *p.summary, err = h.db.GetUptimeSummary(ctx, p.since); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to get uptime checks", "DB_ERROR")
+			return
+		}
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	if resp.Daily, err = h.db.GetUptimeDaily(ctx, today.AddDate(0, 0, -(days-1))); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get uptime checks", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// WebhookRequest is the request body for creating or updating a webhook.
+// Omitted fields are left unchanged on update.
+type WebhookRequest struct {
+	URL          *string  `json:"url"`
+	Events       []string `json:"events"`
+	Description  *string  `json:"description"`
+	Enabled      *bool    `json:"enabled"`
+	Secret       *string  `json:"secret"`
+	RotateSecret bool     `json:"rotate_secret,omitempty"`
+}
+
+// GetWebhooks returns all configured webhooks and the available events.
+// GET /api/v1/webhooks
+func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.db.GetWebhooks(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get webhooks", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"webhooks":         webhooks,
+		"available_events": services.WebhookEvents,
+	})
+}
+
+// CreateWebhook registers a new webhook. The signing secret is generated
+// when not provided and is only returned in this response.
+// POST /api/v1/webhooks
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if req.URL == nil || *req.URL == "" {
+		respondError(w, http.StatusBadRequest, "URL is required", "MISSING_URL")
+		return
+	}
+
+	wh := &db.Webhook{
+		URL:     *req.URL,
+		Events:  req.Events,
+		Enabled: true,
+	}
+	if req.Description != nil {
+		wh.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		wh.Enabled = *req.Enabled
+	}
+	if req.Secret != nil && *req.Secret != "" {
+		wh.Secret = *req.Secret
+	} else {
+		wh.Secret = services.GenerateWebhookSecret()
+	}
+
+	if !h.validateWebhook(w, wh) {
+		return
+	}
+
+	if err := h.db.CreateWebhook(ctx, wh); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create webhook", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "webhook_created", map[string]interface{}{
+		"id":     wh.ID,
+		"url":    wh.URL,
+		"events": wh.Events,
+	}, "")
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"webhook": wh,
+		"secret":  wh.Secret,
+	})
+}
+
+// GetWebhook returns a single webhook.
+// GET /api/v1/webhooks/{id}
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	wh, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"webhook": wh,
+	})
+}
+
+// UpdateWebhook changes a webhook's URL, events, description, enabled flag or secret.
+// PATCH /api/v1/webhooks/{id}
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	wh, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	if req.URL != nil {
+		wh.URL = *req.URL
+	}
+	if req.Events != nil {
+		wh.Events = req.Events
+	}
+	if req.Description != nil {
+		wh.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		wh.Enabled = *req.Enabled
+	}
+
+	secretChanged := false
+	if req.RotateSecret {
+		wh.Secret = services.GenerateWebhookSecret()
+		secretChanged = true
+	} else if req.Secret != nil && *req.Secret != "" {
+		wh.Secret = *req.Secret
+		secretChanged = true
+	}
+
+	if !h.validateWebhook(w, wh) {
+		return
+	}
+
+	if err := h.db.UpdateWebhook(ctx, wh); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update webhook", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "webhook_updated", map[string]interface{}{
+		"id":             wh.ID,
+		"url":            wh.URL,
+		"events":         wh.Events,
+		"enabled":        wh.Enabled,
+		"secret_changed": secretChanged,
+	}, "")
+
+	response := map[string]interface{}{
+		"webhook": wh,
+	}
+	if secretChanged {
+		response["secret"] = wh.Secret
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+// DELETE /api/v1/webhooks/{id}
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	wh, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteWebhook(ctx, wh.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete webhook", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "webhook_deleted", map[string]interface{}{
+		"id":  wh.ID,
+		"url": wh.URL,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Webhook deleted",
+	})
+}
+
+// GetWebhookDeliveries returns the delivery log for a webhook, newest first.
+// GET /api/v1/webhooks/{id}/deliveries
+func (h *Handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	wh, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+			if limit > 500 {
+				limit = 500
+			}
+		}
+	}
+
+	deliveries, err := h.db.GetWebhookDeliveries(r.Context(), wh.ID, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get deliveries", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+	})
+}
+
+// TestWebhook queues a webhook.test delivery.
+// POST /api/v1/webhooks/{id}/test
+func (h *Handler) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	wh, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := h.services.Webhooks.SendTest(r.Context(), wh.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to queue test delivery", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"success":     true,
+		"delivery_id": deliveryID,
+		"message":     "Test delivery queued",
+	})
+}
+
+// loadWebhook resolves the {id} path value, writing an error response on failure.
+func (h *Handler) loadWebhook(w http.ResponseWriter, r *http.Request) (*db.Webhook, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "Invalid webhook ID", "INVALID_ID")
+		return nil, false
+	}
+
+	wh, err := h.db.GetWebhook(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get webhook", "DB_ERROR")
+		return nil, false
+	}
+	if wh == nil {
+		respondError(w, http.StatusNotFound, "Webhook not found", "NOT_FOUND")
+		return nil, false
+	}
+
+	return wh, true
+}
+
+// validateWebhook checks the URL and events, writing an error response on failure.
+func (h *Handler) validateWebhook(w http.ResponseWriter, wh *db.Webhook) bool {
+	if !isValidWebhookURL(wh.URL) {
+		respondError(w, http.StatusBadRequest, "URL must be an absolute http(s) URL", "INVALID_URL")
+		return false
+	}
+
+	if len(wh.Events) == 0 {
+		respondError(w, http.StatusBadRequest, "At least one event is required", "MISSING_EVENTS")
+		return false
+	}
+
+	var invalid []string
+	for _, event := range wh.Events {
+		if !services.IsWebhookEvent(event) {
+			invalid = append(invalid, event)
+		}
+	}
+	if len(invalid) > 0 {
+		respondErrorWithDetails(w, http.StatusBadRequest, "Unknown webhook events", "INVALID_EVENTS", map[string]interface{}{
+			"invalid":          invalid,
+			"available_events": services.WebhookEvents,
+		})
+		return false
+	}
+
+	return true
+}
+
+// isValidWebhookURL reports whether raw is an absolute http or https URL.
+func isValidWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// emitWebhook notifies webhook subscribers of an event, if webhooks are available.
+func (h *Handler) emitWebhook(event string, data map[string]interface{}) {
+	if h.services == nil {
+		return
+	}
+	h.services.Webhooks.Emit(event, data)
+}
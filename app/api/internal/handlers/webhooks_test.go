@@ -0,0 +1,23 @@
+package handlers
+
+import "testing"
+
+func TestIsValidWebhookURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/hooks/roostr", true},
+		{"http://192.168.1.10:8080/hook", true},
+		{"ftp://example.com", false},
+		{"example.com/hook", false},
+		{"https://", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidWebhookURL(tt.url); got != tt.want {
+			t.Errorf("isValidWebhookURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
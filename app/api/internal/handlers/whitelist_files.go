@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// maxWhitelistImportBytes caps the size of an uploaded whitelist file.
+const maxWhitelistImportBytes = 10 << 20
+
+// maxNicknameLength caps imported nicknames, in characters.
+const maxNicknameLength = 100
+
+// whitelistCSVHeader is the column layout of whitelist exports.
+var whitelistCSVHeader = []string{"pubkey", "npub", "nickname", "added_at"}
+
+// WhitelistFileEntry is one whitelist entry in an export or import file.
+type WhitelistFileEntry struct {
+	Pubkey   string     `json:"pubkey"`
+	Npub     string     `json:"npub"`
+	Nickname string     `json:"nickname,omitempty"`
+	AddedAt  *time.Time `json:"added_at,omitempty"`
+}
+
+// WhitelistFile is the JSON export format.
+type WhitelistFile struct {
+	ExportedAt time.Time            `json:"exported_at"`
+	Count      int                  `json:"count"`
+	Entries    []WhitelistFileEntry `json:"entries"`
+}
+
+// ExportWhitelist downloads the whitelist as CSV or JSON.
+// GET /api/v1/access/whitelist/export?format=csv|json
+func (h *Handler) ExportWhitelist(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		respondError(w, http.StatusBadRequest, "format must be csv or json", "INVALID_FORMAT")
+		return
+	}
+
+	ctx := r.Context()
+
+	entries, err := h.db.GetWhitelistMeta(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get whitelist", "DB_ERROR")
+		return
+	}
+
+	now := time.Now().UTC()
+	filename := fmt.Sprintf("roostr-whitelist-%s.%s", now.Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(entries)))
+
+	if format == "json" {
+		file := WhitelistFile{ExportedAt: now, Count: len(entries), Entries: make([]WhitelistFileEntry, len(entries))}
+		for i, e := range entries {
+			addedAt := e.AddedAt.UTC()
+			file.Entries[i] = WhitelistFileEntry{Pubkey: e.Pubkey, Npub: e.Npub, Nickname: e.Nickname, AddedAt: &addedAt}
+		}
+		respondJSON(w, http.StatusOK, file)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write(whitelistCSVHeader)
+	for _, e := range entries {
+		cw.Write([]string{e.Pubkey, e.Npub, e.Nickname, e.AddedAt.UTC().Format(time.RFC3339)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.ErrorContext(ctx, "Whitelist CSV export failed", "error", err)
+	}
+}
+
+// ImportWhitelistResponse contains the results of a whitelist file import.
+// In a dry run, Added is the number of entries that would be added.
+type ImportWhitelistResponse struct {
+	Total      int      `json:"total"`      // Entries in the file
+	Added      int      `json:"added"`      // New entries
+	Duplicates int      `json:"duplicates"` // Already whitelisted or repeated in the file
+	Invalid    int      `json:"invalid"`    // Failed validation
+	Errors     int      `json:"errors"`     // Failed to add
+	ErrorList  []string `json:"error_list"` // Error messages (limited to first 100)
+	DryRun     bool     `json:"dry_run"`
+}
+
+// ImportWhitelist adds the entries of an uploaded CSV or JSON file to the
+// whitelist. Pubkeys may be hex or npub. Entries already on the whitelist are
+// left unchanged. With dry_run=true nothing is written and the response
+// reports what would happen.
+// POST /api/v1/access/whitelist/import (multipart/form-data: file, dry_run)
+func (h *Handler) ImportWhitelist(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxWhitelistImportBytes+1<<20)
+	if err := r.ParseMultipartForm(maxWhitelistImportBytes); err != nil {
+		respondError(w, http.StatusBadRequest, "Failed to parse form data", "INVALID_FORM")
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "No file provided", "MISSING_FILE")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxWhitelistImportBytes+1))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to read file", "READ_ERROR")
+		return
+	}
+	if len(data) > maxWhitelistImportBytes {
+		respondError(w, http.StatusRequestEntityTooLarge, "File is larger than 10 MB", "FILE_TOO_LARGE")
+		return
+	}
+
+	rows, err := parseWhitelistFile(data)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to parse file: %v", err), "PARSE_ERROR")
+		return
+	}
+	if len(rows) == 0 {
+		respondError(w, http.StatusBadRequest, "No entries provided", "EMPTY_REQUEST")
+		return
+	}
+
+	ctx := r.Context()
+	response := ImportWhitelistResponse{
+		Total:     len(rows),
+		ErrorList: make([]string, 0),
+		DryRun:    r.FormValue("dry_run") == "true",
+	}
+	addError := func(format string, args ...interface{}) {
+		if len(response.ErrorList) < 100 {
+			response.ErrorList = append(response.ErrorList, fmt.Sprintf(format, args...))
+		}
+	}
+
+	seen := make(map[string]bool, len(rows))
+	var added []db.WhitelistEntry
+	for _, row := range rows {
+		entry, err := validateWhitelistRow(row)
+		if err != nil {
+			response.Invalid++
+			addError("Entry %d: %v", row.Line, err)
+			continue
+		}
+		if seen[entry.Pubkey] {
+			response.Duplicates++
+			continue
+		}
+		seen[entry.Pubkey] = true
+
+		existing, err := h.db.GetWhitelistEntryByPubkey(ctx, entry.Pubkey)
+		if err != nil {
+			response.Errors++
+			addError("Entry %d: %v", row.Line, err)
+			continue
+		}
+		if existing != nil {
+			response.Duplicates++
+			continue
+		}
+
+		if !response.DryRun {
+			if err := h.db.AddWhitelistEntry(ctx, *entry); err != nil {
+				response.Errors++
+				addError("Entry %d: %v", row.Line, err)
+				continue
+			}
+			added = append(added, *entry)
+		}
+		response.Added++
+	}
+
+	if response.DryRun || len(added) == 0 {
+		respondJSON(w, http.StatusOK, response)
+		return
+	}
+
+	if err := h.syncConfigFromDB(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to sync config.toml", "error", err)
+	}
+
+	h.db.AddAuditLog(ctx, "whitelist_import", map[string]interface{}{
+		"added":      response.Added,
+		"duplicates": response.Duplicates,
+		"invalid":    response.Invalid,
+	}, "")
+
+	for _, entry := range added {
+		h.emitWebhook(services.WebhookEventUserWhitelisted, map[string]interface{}{
+			"pubkey": entry.Pubkey,
+			"npub":   entry.Npub,
+			"source": "import",
+		})
+	}
+	h.wakeProfiles()
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// whitelistRow is an entry read from an import file, before validation.
+type whitelistRow struct {
+	Line     int // CSV line or JSON array position, 1-based, for error messages
+	Pubkey   string
+	Npub     string
+	Nickname string
+}
+
+// parseWhitelistFile reads a JSON or CSV whitelist file. JSON may be an
+// export ({"entries": [...]}) or a bare array of entries. CSV needs a header
+// row with a pubkey or npub column; a nickname (or name) column is optional.
+func parseWhitelistFile(data []byte) ([]whitelistRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM from spreadsheet apps
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	switch trimmed[0] {
+	case '{', '[':
+		return parseWhitelistJSON(trimmed)
+	default:
+		return parseWhitelistCSV(data)
+	}
+}
+
+func parseWhitelistJSON(data []byte) ([]whitelistRow, error) {
+	var entries []WhitelistFileEntry
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+	} else {
+		var file WhitelistFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, err
+		}
+		entries = file.Entries
+	}
+
+	rows := make([]whitelistRow, len(entries))
+	for i, e := range entries {
+		rows[i] = whitelistRow{Line: i + 1, Pubkey: e.Pubkey, Npub: e.Npub, Nickname: e.Nickname}
+	}
+	return rows, nil
+}
+
+func parseWhitelistCSV(data []byte) ([]whitelistRow, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["nickname"]; !ok {
+		if i, ok := columns["name"]; ok {
+			columns["nickname"] = i
+		}
+	}
+	_, hasPubkey := columns["pubkey"]
+	_, hasNpub := columns["npub"]
+	if !hasPubkey && !hasNpub {
+		return nil, errors.New("header row must include a pubkey or npub column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []whitelistRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, whitelistRow{
+			Line:     line,
+			Pubkey:   field(record, "pubkey"),
+			Npub:     field(record, "npub"),
+			Nickname: field(record, "nickname"),
+		})
+	}
+	return rows, nil
+}
+
+// validateWhitelistRow resolves a row's pubkey from its pubkey or npub field
+// and checks that both agree when both are given.
+func validateWhitelistRow(row whitelistRow) (*db.WhitelistEntry, error) {
+	pubkey, npub, err := resolvePubkey(row.Pubkey, row.Npub)
+	if err != nil {
+		return nil, err
+	}
+
+	nickname := strings.TrimSpace(row.Nickname)
+	if utf8.RuneCountInString(nickname) > maxNicknameLength {
+		return nil, fmt.Errorf("nickname longer than %d characters", maxNicknameLength)
+	}
+
+	return &db.WhitelistEntry{Pubkey: pubkey, Npub: npub, Nickname: nickname, AddedBy: "import"}, nil
+}
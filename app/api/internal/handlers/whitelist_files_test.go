@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestParseWhitelistFile(t *testing.T) {
+	alice := strings.Repeat("aa", 32)
+	bob := strings.Repeat("bb", 32)
+
+	t.Run("csv", func(t *testing.T) {
+		data := "\xef\xbb\xbfNpub, Name\n" + alice + ",Alice\n\n" + bob + "\n"
+		rows, err := parseWhitelistFile([]byte(data))
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if len(rows) != 2 || rows[0].Npub != alice || rows[0].Nickname != "Alice" || rows[1].Line != 4 || rows[1].Nickname != "" {
+			t.Errorf("unexpected rows: %+v", rows)
+		}
+	})
+
+	t.Run("csv_without_pubkey_column", func(t *testing.T) {
+		if _, err := parseWhitelistFile([]byte("name\nAlice\n")); err == nil {
+			t.Error("expected an error for a header without pubkey or npub")
+		}
+	})
+
+	t.Run("json_export", func(t *testing.T) {
+		data := `{"exported_at":"2024-01-01T00:00:00Z","count":1,"entries":[{"pubkey":"` + alice + `","nickname":"Alice"}]}`
+		rows, err := parseWhitelistFile([]byte(data))
+		if err != nil || len(rows) != 1 || rows[0].Pubkey != alice || rows[0].Nickname != "Alice" {
+			t.Errorf("unexpected rows: %+v (%v)", rows, err)
+		}
+	})
+
+	t.Run("json_array", func(t *testing.T) {
+		rows, err := parseWhitelistFile([]byte(` [{"npub":"x"},{"pubkey":"` + bob + `"}]`))
+		if err != nil || len(rows) != 2 || rows[1].Line != 2 {
+			t.Errorf("unexpected rows: %+v (%v)", rows, err)
+		}
+	})
+
+	t.Run("invalid_json", func(t *testing.T) {
+		if _, err := parseWhitelistFile([]byte(`{"entries":`)); err == nil {
+			t.Error("expected a parse error")
+		}
+	})
+}
+
+func TestValidateWhitelistRow(t *testing.T) {
+	alice := strings.Repeat("aa", 32)
+	aliceNpub, _ := nostr.EncodeNpub(alice)
+
+	entry, err := validateWhitelistRow(whitelistRow{Npub: aliceNpub, Nickname: " Alice "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Pubkey != alice || entry.Npub != aliceNpub || entry.Nickname != "Alice" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	if entry, err := validateWhitelistRow(whitelistRow{Pubkey: strings.ToUpper(alice)}); err != nil || entry.Pubkey != alice {
+		t.Errorf("expected hex pubkey to be lowercased, got %+v (%v)", entry, err)
+	}
+
+	tests := []struct {
+		name string
+		row  whitelistRow
+	}{
+		{"missing", whitelistRow{Nickname: "Alice"}},
+		{"invalid", whitelistRow{Pubkey: "not-a-pubkey"}},
+		{"mismatch", whitelistRow{Pubkey: strings.Repeat("bb", 32), Npub: aliceNpub}},
+		{"long_nickname", whitelistRow{Pubkey: alice, Nickname: strings.Repeat("x", 101)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := validateWhitelistRow(tt.row); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
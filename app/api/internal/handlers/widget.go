@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GetWidgetConfig returns the configuration the embeddable signup widget needs.
+// GET /public/widget-config
+func (h *Handler) GetWidgetConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accessMode, err := h.db.GetAccessMode(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get access mode", "DB_ERROR")
+		return
+	}
+
+	relayInfo := map[string]interface{}{
+		"url": h.currentConfig().RelayURL,
+	}
+	if h.configMgr != nil {
+		if cfg, _ := h.configMgr.Read(); cfg != nil {
+			relayInfo["name"] = cfg.Info.Name
+			relayInfo["description"] = cfg.Info.Description
+			if cfg.Info.RelayURL != "" {
+				relayInfo["url"] = cfg.Info.RelayURL
+			}
+			if cfg.Info.RelayIcon != "" {
+				relayInfo["icon"] = cfg.Info.RelayIcon
+			}
+		}
+	}
+
+	response := map[string]interface{}{
+		"paid_access_enabled":  accessMode == "paid",
+		"lightning_configured": h.services.Lightning.IsConfigured(),
+		"relay":                relayInfo,
+		"api_base":             requestBaseURL(r),
+		"endpoints": map[string]string{
+			"relay_info":     "/public/relay-info",
+			"signup_check":   "/public/signup-check",
+			"create_invoice": "/public/create-invoice",
+			"invoice_status": "/public/invoice-status/{hash}",
+		},
+		"poll_interval_ms": 3000,
+	}
+
+	if accessMode == "paid" {
+		tiers, err := h.db.GetPricingTiers(ctx)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to get pricing tiers", "DB_ERROR")
+			return
+		}
+		response["tiers"] = publicTiers(tiers)
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetSignupCORS returns the origins allowed to embed the signup widget.
+// GET /api/v1/signup/cors
+func (h *Handler) GetSignupCORS(w http.ResponseWriter, r *http.Request) {
+	origins, err := h.db.GetSignupAllowedOrigins(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get allowed origins", "DB_ERROR")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"allowed_origins": origins,
+		"allow_all":       len(origins) == 0,
+	})
+}
+
+// UpdateSignupCORS replaces the origins allowed to embed the signup widget.
+// PUT /api/v1/signup/cors
+func (h *Handler) UpdateSignupCORS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		AllowedOrigins []string `json:"allowed_origins"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	origins := make([]string, 0, len(req.AllowedOrigins))
+	seen := make(map[string]bool)
+	for _, o := range req.AllowedOrigins {
+		normalized, ok := normalizeOrigin(o)
+		if !ok {
+			respondErrorWithDetails(w, http.StatusBadRequest, "Invalid origin", "INVALID_ORIGIN", o)
+			return
+		}
+		if !seen[normalized] {
+			seen[normalized] = true
+			origins = append(origins, normalized)
+		}
+	}
+
+	if err := h.db.SetSignupAllowedOrigins(ctx, origins); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save allowed origins", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "signup_cors_updated", map[string]interface{}{
+		"allowed_origins": origins,
+	}, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":         true,
+		"allowed_origins": origins,
+		"allow_all":       len(origins) == 0,
+	})
+}
+
+// normalizeOrigin validates an origin ("https://example.com[:port]") and
+// returns it lowercased without a trailing slash. "*" is accepted as-is.
+func normalizeOrigin(origin string) (string, bool) {
+	origin = strings.TrimSpace(origin)
+	if origin == "*" {
+		return origin, true
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(origin, "/"))
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", false
+	}
+
+	return strings.ToLower(u.Scheme + "://" + u.Host), true
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the API.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
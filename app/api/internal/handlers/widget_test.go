@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeOrigin(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"https://Example.com", "https://example.com", true},
+		{"https://example.com/", "https://example.com", true},
+		{"http://localhost:5173", "http://localhost:5173", true},
+		{" * ", "*", true},
+		{"example.com", "", false},
+		{"ftp://example.com", "", false},
+		{"https://example.com/signup", "", false},
+		{"", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, ok := normalizeOrigin(tc.input)
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("normalizeOrigin(%q) = (%q, %v), want (%q, %v)", tc.input, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestMatchAllowedOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		allowed []string
+		want    string
+		ok      bool
+	}{
+		{"empty allowlist allows all", "https://a.com", nil, "*", true},
+		{"wildcard entry", "https://a.com", []string{"*"}, "*", true},
+		{"listed origin echoed", "https://a.com", []string{"https://a.com"}, "https://a.com", true},
+		{"case insensitive", "https://A.com", []string{"https://a.com"}, "https://A.com", true},
+		{"unlisted origin rejected", "https://evil.com", []string{"https://a.com"}, "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := matchAllowedOrigin(tc.origin, tc.allowed)
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("matchAllowedOrigin(%q) = (%q, %v), want (%q, %v)", tc.origin, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestCORSSkipsPublicRoutes(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := CORS(func(context.Context) ([]string, error) {
+		return []string{"https://admin.example.com"}, nil
+	})(next)
+
+	t.Run("admin_route_gets_headers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/health", nil)
+		req.Header.Set("Origin", "https://admin.example.com")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://admin.example.com" {
+			t.Errorf("expected allowed origin echoed on admin route, got %q", got)
+		}
+	})
+
+	t.Run("unlisted_origin_gets_no_headers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/health", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected no CORS header for unlisted origin, got %q", got)
+		}
+		if w.Header().Get("Vary") != "Origin" {
+			t.Error("expected Vary: Origin")
+		}
+	})
+
+	t.Run("public_route_left_to_PublicCORS", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/public/widget-config", nil)
+		req.Header.Set("Origin", "https://admin.example.com")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Error("expected global CORS middleware to skip public route")
+		}
+	})
+}
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://admin.example.com", "*"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://admin.example.com", true},
+		{"https://ADMIN.example.com/", true},
+		{"https://other.example.com", false},
+		{"*", false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(tt.origin, allowed); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	if originAllowed("https://admin.example.com", nil) {
+		t.Error("expected empty allowlist to allow nothing")
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/services"
+)
+
+// lightningAddressName is the form of the name part of a Lightning address.
+var lightningAddressName = regexp.MustCompile(`^[a-z0-9._-]{1,64}$`)
+
+// GetZapSettings returns the zap payment settings and the relay's Lightning
+// address.
+// GET /api/v1/access/zaps
+func (h *Handler) GetZapSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetZapSettings(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get zap settings", "DB_ERROR")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":           settings.Enabled,
+		"username":          settings.Username,
+		"lightning_address": settings.Username + "@" + r.Host,
+	})
+}
+
+// UpdateZapSettings saves the zap payment settings.
+// PUT /api/v1/access/zaps
+func (h *Handler) UpdateZapSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	settings, err := h.db.GetZapSettings(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get zap settings", "DB_ERROR")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", "INVALID_JSON")
+		return
+	}
+
+	settings.Username = strings.ToLower(strings.TrimSpace(settings.Username))
+	if !lightningAddressName.MatchString(settings.Username) {
+		respondError(w, http.StatusBadRequest, "username must be 1-64 lowercase letters, digits, dots, dashes or underscores", "INVALID_SETTINGS")
+		return
+	}
+
+	if err := h.db.SetZapSettings(ctx, settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save zap settings", "DB_ERROR")
+		return
+	}
+
+	h.db.AddAuditLog(ctx, "zap_settings_updated", settings, "")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":           settings.Enabled,
+		"username":          settings.Username,
+		"lightning_address": settings.Username + "@" + r.Host,
+	})
+}
+
+// GetLNURLPay serves the LNURL-pay parameters of the relay's Lightning
+// address, so wallets can zap it to pay for access.
+// GET /.well-known/lnurlp/{name}
+func (h *Handler) GetLNURLPay(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Zaps == nil {
+		respondLNURLError(w, http.StatusServiceUnavailable, "Zap service not available")
+		return
+	}
+
+	name := r.PathValue("name")
+	callback := requestBaseURL(r) + "/public/lnurlp/" + url.PathEscape(name)
+	params, err := h.services.Zaps.PayParams(r.Context(), name, r.Host, callback)
+	if err != nil {
+		respondZapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, params)
+}
+
+// GetZapInvoice is the LNURL-pay callback. It takes the amount in millisats
+// and a zap request in the nostr parameter, and returns an invoice that
+// whitelists the zap sender when paid. Plain payments without a zap request
+// are refused since they do not say who is paying.
+// GET /public/lnurlp/{name}
+func (h *Handler) GetZapInvoice(w http.ResponseWriter, r *http.Request) {
+	if h.services == nil || h.services.Zaps == nil {
+		respondLNURLError(w, http.StatusServiceUnavailable, "Zap service not available")
+		return
+	}
+
+	query := r.URL.Query()
+	amountMsats, err := strconv.ParseInt(query.Get("amount"), 10, 64)
+	if err != nil || amountMsats <= 0 {
+		respondLNURLError(w, http.StatusBadRequest, "amount must be a positive number of millisats")
+		return
+	}
+	zapRequest := query.Get("nostr")
+	if zapRequest == "" {
+		respondLNURLError(w, http.StatusBadRequest, "Access can only be paid by zap; a zap request is required")
+		return
+	}
+
+	invoice, err := h.services.Zaps.Invoice(r.Context(), r.PathValue("name"), amountMsats, zapRequest)
+	if err != nil {
+		respondZapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"pr":     invoice.PaymentRequest,
+		"routes": []interface{}{},
+	})
+}
+
+// respondLNURLError writes an error in the LNURL format wallets expect.
+func respondLNURLError(w http.ResponseWriter, status int, reason string) {
+	respondJSON(w, status, map[string]string{
+		"status": "ERROR",
+		"reason": reason,
+	})
+}
+
+// respondZapError maps zap errors to LNURL error responses.
+func respondZapError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrZapsDisabled):
+		respondLNURLError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidZapRequest), errors.Is(err, services.ErrZapAmount):
+		respondLNURLError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrLNDNotConfigured):
+		respondLNURLError(w, http.StatusServiceUnavailable, "Lightning is not configured")
+	default:
+		slog.Error("Failed to handle zap", "error", err)
+		respondLNURLError(w, http.StatusInternalServerError, "Failed to create invoice")
+	}
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup_JSONWithRequestID(t *testing.T) {
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+
+	var buf bytes.Buffer
+	logger, err := Setup(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.InfoContext(ctx, "Sync completed", "job_id", 7)
+	logger.Debug("hidden below info")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one line, got %q", buf.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("expected JSON output: %v", err)
+	}
+	if entry["msg"] != "Sync completed" || entry["request_id"] != "abc123" || entry["job_id"] != float64(7) {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+func TestSetup_InvalidOptions(t *testing.T) {
+	if _, err := Setup(&bytes.Buffer{}, "loud", "json"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if _, err := Setup(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"abc-123_X.y", true},
+		{NewRequestID(), true},
+		{"", false},
+		{strings.Repeat("a", 65), false},
+		{"bad id", false},
+		{"inject\nline", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.id); got != tt.valid {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.valid)
+		}
+	}
+}
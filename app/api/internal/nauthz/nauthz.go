@@ -0,0 +1,213 @@
+// Package nauthz implements the event admission gRPC service nostr-rs-relay
+// calls to ask whether to store an event (the nauthz.Authorization service
+// of nostr-rs-relay's proto/nauthz.proto). Its two messages are encoded by
+// hand, so no protobuf code generation is needed.
+package nauthz
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// EventAdmitPath is the gRPC method nostr-rs-relay calls for each event.
+const EventAdmitPath = "/nauthz.Authorization/EventAdmit"
+
+// Decision is the outcome of an admission request.
+type Decision int
+
+// Decisions, as numbered in nauthz.proto.
+const (
+	DecisionUnspecified Decision = 0
+	DecisionPermit      Decision = 1
+	DecisionDeny        Decision = 2
+)
+
+// Event is an event submitted to the relay.
+type Event struct {
+	ID        string // hex
+	Pubkey    string // hex
+	CreatedAt int64
+	Kind      int
+	Content   string
+	Tags      [][]string
+	Sig       string // hex
+}
+
+// Nip05Name is the NIP-05 name the relay verified for the author.
+type Nip05Name struct {
+	Local  string
+	Domain string
+}
+
+// EventRequest asks whether to admit an event, with what the relay knows
+// about the client that sent it. Empty fields were not provided.
+type EventRequest struct {
+	Event      Event
+	IPAddr     string
+	Origin     string
+	UserAgent  string
+	AuthPubkey string // hex pubkey the client authenticated as with NIP-42
+	Nip05      *Nip05Name
+}
+
+// EventReply is the admission decision. The message is shown to the client
+// in the OK response, so it should start with a NIP-01 prefix such as
+// "blocked:" or "rate-limited:".
+type EventReply struct {
+	Decision Decision
+	Message  string
+}
+
+// Authorizer decides whether events are admitted.
+type Authorizer interface {
+	EventAdmit(ctx context.Context, req *EventRequest) *EventReply
+}
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformed = errors.New("malformed protobuf message")
+
+// protoField is one field of a protobuf message. Varint and fixed values
+// are in num, length-delimited values in data.
+type protoField struct {
+	number int
+	wire   int
+	num    uint64
+	data   []byte
+}
+
+// readFields calls fn with each field of a protobuf message.
+func readFields(b []byte, fn func(f protoField) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformed
+		}
+		b = b[n:]
+
+		f := protoField{number: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			if f.num, n = binary.Uvarint(b); n <= 0 {
+				return errMalformed
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errMalformed
+			}
+			f.num, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errMalformed
+			}
+			f.num, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return errMalformed
+			}
+			f.data, b = b[n:n+int(length)], b[n+int(length):]
+		default:
+			return errMalformed
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalEventRequest decodes an EventRequest message.
+func UnmarshalEventRequest(b []byte) (*EventRequest, error) {
+	req := &EventRequest{}
+	err := readFields(b, func(f protoField) error {
+		switch f.number {
+		case 1:
+			return unmarshalEvent(f.data, &req.Event)
+		case 2:
+			req.IPAddr = string(f.data)
+		case 3:
+			req.Origin = string(f.data)
+		case 4:
+			req.UserAgent = string(f.data)
+		case 5:
+			req.AuthPubkey = hex.EncodeToString(f.data)
+		case 6:
+			req.Nip05 = &Nip05Name{}
+			return readFields(f.data, func(f protoField) error {
+				switch f.number {
+				case 1:
+					req.Nip05.Local = string(f.data)
+				case 2:
+					req.Nip05.Domain = string(f.data)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func unmarshalEvent(b []byte, e *Event) error {
+	return readFields(b, func(f protoField) error {
+		switch f.number {
+		case 1:
+			e.ID = hex.EncodeToString(f.data)
+		case 2:
+			e.Pubkey = hex.EncodeToString(f.data)
+		case 3:
+			e.CreatedAt = int64(f.num)
+		case 4:
+			e.Kind = int(f.num)
+		case 5:
+			e.Content = string(f.data)
+		case 6:
+			var tag []string
+			err := readFields(f.data, func(f protoField) error {
+				if f.number == 1 {
+					tag = append(tag, string(f.data))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			e.Tags = append(e.Tags, tag)
+		case 7:
+			e.Sig = hex.EncodeToString(f.data)
+		}
+		return nil
+	})
+}
+
+// Marshal encodes the reply as an EventReply message.
+func (r *EventReply) Marshal() []byte {
+	var b []byte
+	if r.Decision != DecisionUnspecified {
+		b = appendTag(b, 1, wireVarint)
+		b = binary.AppendUvarint(b, uint64(r.Decision))
+	}
+	if r.Message != "" {
+		b = appendTag(b, 2, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(r.Message)))
+		b = append(b, r.Message...)
+	}
+	return b
+}
+
+func appendTag(b []byte, number, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(number)<<3|uint64(wire))
+}
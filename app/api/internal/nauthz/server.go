@@ -0,0 +1,93 @@
+package nauthz
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxMessageSize caps a request message, well above any event size
+// nostr-rs-relay accepts.
+const maxMessageSize = 4 << 20
+
+// serviceDesc describes the nauthz.Authorization service, as protoc would
+// generate it from nauthz.proto.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "nauthz.Authorization",
+	HandlerType: (*Authorizer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "EventAdmit", Handler: eventAdmitHandler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "nauthz.proto",
+}
+
+func eventAdmitHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &EventRequest{}
+	if err := dec(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, status.Convert(err).Message())
+	}
+	return srv.(Authorizer).EventAdmit(ctx, req), nil
+}
+
+// codec encodes the service's messages with the hand-written protobuf
+// functions, so no generated code is needed.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	reply, ok := v.(*EventReply)
+	if !ok {
+		return nil, fmt.Errorf("nauthz: cannot marshal %T", v)
+	}
+	return reply.Marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	req, ok := v.(*EventRequest)
+	if !ok {
+		return fmt.Errorf("nauthz: cannot unmarshal into %T", v)
+	}
+	decoded, err := UnmarshalEventRequest(data)
+	if err != nil {
+		return err
+	}
+	*req = *decoded
+	return nil
+}
+
+func (codec) Name() string { return "proto" }
+
+// Server serves the admission service to nostr-rs-relay over cleartext
+// HTTP/2 (h2c). Calls to any other method are answered with Unimplemented.
+type Server struct {
+	grpc *grpc.Server
+}
+
+// NewServer creates a server that asks authorizer about each event.
+func NewServer(authorizer Authorizer) *Server {
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(codec{}),
+		grpc.MaxRecvMsgSize(maxMessageSize),
+	)
+	server.RegisterService(&serviceDesc, authorizer)
+	return &Server{grpc: server}
+}
+
+// Serve accepts connections on listener until the server is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	if err := s.grpc.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+	return nil
+}
+
+// Close stops accepting connections, closes open ones and waits for their
+// requests to finish.
+func (s *Server) Close() error {
+	s.grpc.Stop()
+	return nil
+}
@@ -0,0 +1,162 @@
+package nauthz
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// kindAuthorizer denies events of one kind.
+type kindAuthorizer struct {
+	deny int
+	seen chan *EventRequest
+}
+
+func (a *kindAuthorizer) EventAdmit(_ context.Context, req *EventRequest) *EventReply {
+	a.seen <- req
+	if req.Event.Kind == a.deny {
+		return &EventReply{Decision: DecisionDeny, Message: "restricted: kind not allowed"}
+	}
+	return &EventReply{Decision: DecisionPermit}
+}
+
+func appendBytesField(b []byte, number int, data []byte) []byte {
+	b = appendTag(b, number, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// marshalEventRequest encodes a request the way nostr-rs-relay does.
+func marshalEventRequest(pubkey string, kind int, content, ip string) []byte {
+	pk, _ := hex.DecodeString(pubkey)
+
+	var event []byte
+	event = appendBytesField(event, 1, make([]byte, 32))
+	event = appendBytesField(event, 2, pk)
+	event = appendTag(event, 3, wireFixed64)
+	event = binary.LittleEndian.AppendUint64(event, 1700000000)
+	event = appendTag(event, 4, wireVarint)
+	event = binary.AppendUvarint(event, uint64(kind))
+	event = appendBytesField(event, 5, []byte(content))
+	tag := appendBytesField(appendBytesField(nil, 1, []byte("p")), 1, []byte(pubkey))
+	event = appendBytesField(event, 6, tag)
+
+	var req []byte
+	req = appendBytesField(req, 1, event)
+	req = appendBytesField(req, 2, []byte(ip))
+	req = appendBytesField(req, 6, appendBytesField(appendBytesField(nil, 1, []byte("alice")), 2, []byte("example.com")))
+	return req
+}
+
+func TestUnmarshalEventRequest(t *testing.T) {
+	pubkey := strings.Repeat("ab", 32)
+	req, err := UnmarshalEventRequest(marshalEventRequest(pubkey, 30023, "hello", "192.0.2.1"))
+	if err != nil {
+		t.Fatalf("UnmarshalEventRequest failed: %v", err)
+	}
+	e := req.Event
+	if e.Pubkey != pubkey || e.Kind != 30023 || e.Content != "hello" || e.CreatedAt != 1700000000 {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if len(e.Tags) != 1 || strings.Join(e.Tags[0], ",") != "p,"+pubkey {
+		t.Errorf("unexpected tags: %v", e.Tags)
+	}
+	if req.IPAddr != "192.0.2.1" || req.Nip05 == nil || req.Nip05.Local != "alice" || req.Nip05.Domain != "example.com" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+
+	if _, err := UnmarshalEventRequest([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
+
+// rawCodec sends and receives messages as they are on the wire.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error)      { return v.([]byte), nil }
+func (rawCodec) Unmarshal(data []byte, v interface{}) error { *v.(*[]byte) = data; return nil }
+func (rawCodec) Name() string                               { return "proto" }
+
+func TestServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	authorizer := &kindAuthorizer{deny: 4, seen: make(chan *EventRequest, 10)}
+	server := NewServer(authorizer)
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	call := func(method string, message []byte) ([]byte, error) {
+		var reply []byte
+		err := conn.Invoke(ctx, method, message, &reply, grpc.ForceCodec(rawCodec{}))
+		return reply, err
+	}
+
+	pubkey := strings.Repeat("cd", 32)
+	decision := func(reply []byte) (Decision, string) {
+		var d Decision
+		var msg string
+		readFields(reply, func(f protoField) error {
+			switch f.number {
+			case 1:
+				d = Decision(f.num)
+			case 2:
+				msg = string(f.data)
+			}
+			return nil
+		})
+		return d, msg
+	}
+
+	reply, err := call(EventAdmitPath, marshalEventRequest(pubkey, 1, "hi", "192.0.2.1"))
+	if err != nil {
+		t.Fatalf("EventAdmit failed: %v", err)
+	}
+	if d, _ := decision(reply); d != DecisionPermit {
+		t.Errorf("expected permit, got %d", d)
+	}
+	if req := <-authorizer.seen; req.Event.Pubkey != pubkey || req.IPAddr != "192.0.2.1" {
+		t.Errorf("authorizer got unexpected request: %+v", req)
+	}
+
+	// A large event arrives over several DATA frames
+	reply, _ = call(EventAdmitPath, marshalEventRequest(pubkey, 4, strings.Repeat("x", 40000), ""))
+	if d, msg := decision(reply); d != DecisionDeny || msg != "restricted: kind not allowed" {
+		t.Errorf("expected deny, got %d %q", d, msg)
+	}
+	if req := <-authorizer.seen; len(req.Event.Content) != 40000 {
+		t.Errorf("expected the whole event, got %d bytes of content", len(req.Event.Content))
+	}
+
+	// Garbage is answered with a gRPC error, not a dropped connection
+	if _, err := call(EventAdmitPath, []byte{0xff, 0xff}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a malformed request, got %v", err)
+	}
+	// Only EventAdmit is served
+	if _, err := call("/nauthz.Authorization/Other", marshalEventRequest(pubkey, 1, "hi", "")); status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected Unimplemented for an unknown method, got %v", err)
+	}
+	select {
+	case req := <-authorizer.seen:
+		t.Errorf("authorizer asked about a call to another method: %+v", req)
+	default:
+	}
+}
@@ -27,8 +27,19 @@ var (
 	ErrHandshakeFailed  = errors.New("WebSocket handshake failed")
 	ErrInvalidFrame     = errors.New("invalid WebSocket frame")
 	ErrMessageTooLarge  = errors.New("message too large")
+
+	// ErrStopSubscription can be returned from a subscription callback to end
+	// the subscription without reporting an error.
+	ErrStopSubscription = errors.New("stop subscription")
+
+	// ErrAuthRequired is returned when a relay closes a subscription until
+	// the client authenticates (NIP-42) and no key is set or it was refused.
+	ErrAuthRequired = errors.New("relay requires authentication")
 )
 
+// KindClientAuth is the kind of NIP-42 authentication events.
+const KindClientAuth = 22242
+
 // WebSocket opcodes
 const (
 	opContinuation = 0x0
@@ -48,6 +59,10 @@ type Client struct {
 	mu       sync.Mutex
 	closed   atomic.Bool
 	subCount atomic.Int64
+
+	authKey   string // secret key to answer NIP-42 challenges with
+	challenge atomic.Value
+	authed    atomic.Bool
 }
 
 // Filter represents a Nostr subscription filter.
@@ -55,6 +70,9 @@ type Filter struct {
 	IDs     []string `json:"ids,omitempty"`
 	Authors []string `json:"authors,omitempty"`
 	Kinds   []int    `json:"kinds,omitempty"`
+	ETags   []string `json:"#e,omitempty"`
+	PTags   []string `json:"#p,omitempty"`
+	DTags   []string `json:"#d,omitempty"`
 	Since   *int64   `json:"since,omitempty"`
 	Until   *int64   `json:"until,omitempty"`
 	Limit   int      `json:"limit,omitempty"`
@@ -65,6 +83,13 @@ func NewClient(relayURL string) *Client {
 	return &Client{url: relayURL}
 }
 
+// SetAuthKey sets the secret key (hex) the client answers a relay's NIP-42
+// AUTH challenge with. Without one, subscriptions the relay closes with
+// "auth-required:" fail with ErrAuthRequired.
+func (c *Client) SetAuthKey(secretHex string) {
+	c.authKey = secretHex
+}
+
 // Connect establishes a WebSocket connection to the relay.
 func (c *Client) Connect(ctx context.Context) error {
 	u, err := url.Parse(c.url)
@@ -106,22 +131,26 @@ func (c *Client) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 
-	// Perform WebSocket handshake
-	if err := c.handshake(conn, u); err != nil {
+	// Perform WebSocket handshake. Frames the relay sends right after it,
+	// such as a NIP-42 challenge, are read from the same buffer.
+	reader := bufio.NewReader(conn)
+	if err := c.handshake(conn, reader, u); err != nil {
 		conn.Close()
 		return err
 	}
 
 	c.conn = conn
-	c.reader = bufio.NewReader(conn)
+	c.reader = reader
 	c.writer = bufio.NewWriter(conn)
 	c.closed.Store(false)
+	c.challenge.Store("")
+	c.authed.Store(false)
 
 	return nil
 }
 
 // handshake performs the WebSocket upgrade handshake.
-func (c *Client) handshake(conn net.Conn, u *url.URL) error {
+func (c *Client) handshake(conn net.Conn, reader *bufio.Reader, u *url.URL) error {
 	// Generate random key
 	key := make([]byte, 16)
 	if _, err := rand.Read(key); err != nil {
@@ -155,7 +184,6 @@ func (c *Client) handshake(conn net.Conn, u *url.URL) error {
 	}
 
 	// Read response
-	reader := bufio.NewReader(conn)
 	statusLine, err := reader.ReadString('\n')
 	if err != nil {
 		return fmt.Errorf("failed to read response: %w", err)
@@ -220,6 +248,113 @@ func (c *Client) Close() error {
 
 // Subscribe sends a REQ message and calls the callback for each event until EOSE.
 func (c *Client) Subscribe(ctx context.Context, filter Filter, callback func(*SyncEvent) error) error {
+	return c.subscribe(ctx, filter, nil, true, callback)
+}
+
+// Request subscribes to filter, then publishes event and calls the callback for
+// each matching event (stored or live) until the callback returns
+// ErrStopSubscription, the relay closes the subscription, or ctx is done.
+// It is used for request/response protocols such as NIP-47 where the reply
+// is an ephemeral event that must be subscribed to before the request is sent.
+func (c *Client) Request(ctx context.Context, filter Filter, event *SyncEvent, callback func(*SyncEvent) error) error {
+	return c.subscribe(ctx, filter, event, false, callback)
+}
+
+// Publish sends an EVENT message without waiting for the relay's OK.
+func (c *Client) Publish(event *SyncEvent) error {
+	msg, err := json.Marshal([]interface{}{"EVENT", event})
+	if err != nil {
+		return fmt.Errorf("failed to marshal EVENT: %w", err)
+	}
+	if err := c.writeFrame(opText, msg); err != nil {
+		return fmt.Errorf("failed to send EVENT: %w", err)
+	}
+	return nil
+}
+
+// PublishResult is a relay's NIP-01 OK response to a published event.
+type PublishResult struct {
+	Accepted bool
+	Message  string
+}
+
+// Duplicate reports whether the relay already had the event.
+func (r PublishResult) Duplicate() bool {
+	return strings.HasPrefix(r.Message, "duplicate:")
+}
+
+// PublishBatch sends EVENT messages for all events, then waits for the
+// relay's OK responses until every event is answered or ctx is done. Events
+// the relay did not answer are missing from the result.
+func (c *Client) PublishBatch(ctx context.Context, events []*SyncEvent) (map[string]PublishResult, error) {
+	pending := make(map[string]bool, len(events))
+	for _, event := range events {
+		if err := c.Publish(event); err != nil {
+			return nil, err
+		}
+		pending[event.ID] = true
+	}
+
+	results := make(map[string]PublishResult, len(events))
+	for len(pending) > 0 {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+
+		// Set read deadline (never past the context deadline)
+		if c.conn != nil {
+			deadline := time.Now().Add(30 * time.Second)
+			if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+				deadline = d
+			}
+			c.conn.SetReadDeadline(deadline)
+		}
+
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() && !c.closed.Load() {
+				continue
+			}
+			return results, err
+		}
+
+		switch opcode {
+		case opText:
+			// ["OK", <event id>, <true|false>, <message>]
+			var msg []json.RawMessage
+			if json.Unmarshal(payload, &msg) != nil || len(msg) < 3 {
+				continue
+			}
+			var msgType, id string
+			var accepted bool
+			if json.Unmarshal(msg[0], &msgType) != nil || msgType != "OK" ||
+				json.Unmarshal(msg[1], &id) != nil || json.Unmarshal(msg[2], &accepted) != nil {
+				continue
+			}
+			if !pending[id] {
+				continue
+			}
+			result := PublishResult{Accepted: accepted}
+			if len(msg) >= 4 {
+				json.Unmarshal(msg[3], &result.Message)
+			}
+			results[id] = result
+			delete(pending, id)
+
+		case opClose:
+			c.closed.Store(true)
+			return results, ErrConnectionClosed
+
+		case opPing:
+			c.writeFrame(opPong, payload)
+		}
+	}
+	return results, nil
+}
+
+// subscribe sends a REQ (optionally followed by an EVENT) and reads messages,
+// returning at EOSE when untilEOSE is set.
+func (c *Client) subscribe(ctx context.Context, filter Filter, publish *SyncEvent, untilEOSE bool, callback func(*SyncEvent) error) error {
 	subID := fmt.Sprintf("sub-%d", c.subCount.Add(1))
 
 	// Build REQ message: ["REQ", subID, filter]
@@ -234,20 +369,47 @@ func (c *Client) Subscribe(ctx context.Context, filter Filter, callback func(*Sy
 		return fmt.Errorf("failed to send REQ: %w", err)
 	}
 
-	// Read messages until EOSE
+	// A relay requiring NIP-42 closes the REQ with "auth-required:". It is
+	// sent again once, after authenticating, which may have to wait for
+	// the relay's challenge.
+	authRetried, awaitingChallenge := false, false
+	resend := func() error {
+		authRetried, awaitingChallenge = true, false
+		if err := c.writeFrame(opText, reqJSON); err != nil {
+			return fmt.Errorf("failed to send REQ: %w", err)
+		}
+		return nil
+	}
+
+	// Publish after the subscription is registered so replies aren't missed
+	if publish != nil {
+		if err := c.Publish(publish); err != nil {
+			return err
+		}
+	}
+
+	closeSub := func() {
+		closeMsg, _ := json.Marshal([]interface{}{"CLOSE", subID})
+		c.writeFrame(opText, closeMsg)
+	}
+
+	// Read messages until EOSE (or until the callback stops us)
 	for {
 		select {
 		case <-ctx.Done():
 			// Send CLOSE message
-			closeMsg, _ := json.Marshal([]interface{}{"CLOSE", subID})
-			c.writeFrame(opText, closeMsg)
+			closeSub()
 			return ctx.Err()
 		default:
 		}
 
-		// Set read deadline
+		// Set read deadline (never past the context deadline)
 		if c.conn != nil {
-			c.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+			deadline := time.Now().Add(30 * time.Second)
+			if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+				deadline = d
+			}
+			c.conn.SetReadDeadline(deadline)
 		}
 
 		// Read frame
@@ -282,22 +444,59 @@ func (c *Client) Subscribe(ctx context.Context, filter Filter, callback func(*Sy
 
 				// Call callback
 				if err := callback(event); err != nil {
+					if errors.Is(err, ErrStopSubscription) {
+						closeSub()
+						return nil
+					}
 					return err
 				}
 
 			case "EOSE":
+				if !untilEOSE {
+					continue // Keep listening for live events
+				}
 				// End of stored events - send CLOSE and return
-				closeMsg, _ := json.Marshal([]interface{}{"CLOSE", subID})
-				c.writeFrame(opText, closeMsg)
+				closeSub()
 				return nil
 
 			case "NOTICE":
 				// Log notices but continue
 				continue
 
+			case "AUTH":
+				var challenge string
+				if json.Unmarshal(data, &challenge) != nil {
+					continue
+				}
+				c.challenge.Store(challenge)
+				if err := c.authenticate(); err != nil {
+					return err
+				}
+				if awaitingChallenge && c.authed.Load() {
+					if err := resend(); err != nil {
+						return err
+					}
+				}
+
 			case "CLOSED":
-				// Subscription was closed by relay
-				return nil
+				reason := closedReason(payload)
+				if !strings.HasPrefix(reason, "auth-required:") {
+					// Subscription was closed by relay
+					return nil
+				}
+				if c.authKey == "" || authRetried {
+					return fmt.Errorf("%w: %s", ErrAuthRequired, reason)
+				}
+				if err := c.authenticate(); err != nil {
+					return err
+				}
+				if !c.authed.Load() {
+					awaitingChallenge = true
+					continue
+				}
+				if err := resend(); err != nil {
+					return err
+				}
 			}
 
 		case opClose:
@@ -314,6 +513,41 @@ func (c *Client) Subscribe(ctx context.Context, filter Filter, callback func(*Sy
 	}
 }
 
+// authenticate answers the relay's NIP-42 challenge with a signed
+// authentication event, once per connection. It does nothing without a key
+// or before the relay has sent a challenge.
+func (c *Client) authenticate() error {
+	challenge, _ := c.challenge.Load().(string)
+	if c.authKey == "" || challenge == "" || c.authed.Load() {
+		return nil
+	}
+
+	event := NewEvent(KindClientAuth, [][]string{{"relay", c.url}, {"challenge", challenge}}, "")
+	if err := event.Sign(c.authKey); err != nil {
+		return fmt.Errorf("failed to sign AUTH: %w", err)
+	}
+	msg, err := json.Marshal([]interface{}{"AUTH", event})
+	if err != nil {
+		return fmt.Errorf("failed to marshal AUTH: %w", err)
+	}
+	if err := c.writeFrame(opText, msg); err != nil {
+		return fmt.Errorf("failed to send AUTH: %w", err)
+	}
+	c.authed.Store(true)
+	return nil
+}
+
+// closedReason returns the message of a CLOSED relay message, if any.
+func closedReason(payload []byte) string {
+	var raw []json.RawMessage
+	if json.Unmarshal(payload, &raw) != nil || len(raw) < 3 {
+		return ""
+	}
+	var reason string
+	json.Unmarshal(raw[2], &reason)
+	return reason
+}
+
 // parseRelayMessage parses a Nostr relay message and returns the message type and data.
 func parseRelayMessage(payload []byte) (string, []byte, error) {
 	var raw []json.RawMessage
@@ -394,13 +628,23 @@ func (c *Client) readFrame() (byte, []byte, error) {
 		return 0, nil, ErrConnectionClosed
 	}
 
+	_, opcode, payload, err := readWSFrame(c.reader, maxClientMessageSize)
+	return opcode, payload, err
+}
+
+// maxClientMessageSize caps frames read from remote relays (16MB).
+const maxClientMessageSize = 16 * 1024 * 1024
+
+// readWSFrame reads a single WebSocket frame, unmasking it if needed.
+// It returns ErrMessageTooLarge for payloads over maxSize.
+func readWSFrame(r *bufio.Reader, maxSize int) (bool, byte, []byte, error) {
 	// Read first two bytes
 	header := make([]byte, 2)
-	if _, err := io.ReadFull(c.reader, header); err != nil {
-		return 0, nil, err
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false, 0, nil, err
 	}
 
-	// fin := header[0]&0x80 != 0
+	fin := header[0]&0x80 != 0
 	opcode := header[0] & 0x0F
 	masked := header[1]&0x80 != 0
 	payloadLen := int(header[1] & 0x7F)
@@ -408,36 +652,36 @@ func (c *Client) readFrame() (byte, []byte, error) {
 	// Extended payload length
 	if payloadLen == 126 {
 		extLen := make([]byte, 2)
-		if _, err := io.ReadFull(c.reader, extLen); err != nil {
-			return 0, nil, err
+		if _, err := io.ReadFull(r, extLen); err != nil {
+			return false, 0, nil, err
 		}
 		payloadLen = int(binary.BigEndian.Uint16(extLen))
 	} else if payloadLen == 127 {
 		extLen := make([]byte, 8)
-		if _, err := io.ReadFull(c.reader, extLen); err != nil {
-			return 0, nil, err
+		if _, err := io.ReadFull(r, extLen); err != nil {
+			return false, 0, nil, err
 		}
 		payloadLen = int(binary.BigEndian.Uint64(extLen))
 	}
 
-	// Sanity check on payload size (max 16MB)
-	if payloadLen > 16*1024*1024 {
-		return 0, nil, ErrMessageTooLarge
+	// Sanity check on payload size
+	if payloadLen < 0 || payloadLen > maxSize {
+		return false, 0, nil, ErrMessageTooLarge
 	}
 
 	// Read mask key if present (server frames are typically not masked)
 	var maskKey []byte
 	if masked {
 		maskKey = make([]byte, 4)
-		if _, err := io.ReadFull(c.reader, maskKey); err != nil {
-			return 0, nil, err
+		if _, err := io.ReadFull(r, maskKey); err != nil {
+			return false, 0, nil, err
 		}
 	}
 
 	// Read payload
 	payload := make([]byte, payloadLen)
-	if _, err := io.ReadFull(c.reader, payload); err != nil {
-		return 0, nil, err
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
 	}
 
 	// Unmask if needed
@@ -447,7 +691,7 @@ func (c *Client) readFrame() (byte, []byte, error) {
 		}
 	}
 
-	return opcode, payload, nil
+	return fin, opcode, payload, nil
 }
 
 // IsConnected returns true if the client is connected.
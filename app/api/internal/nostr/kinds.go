@@ -0,0 +1,139 @@
+package nostr
+
+import "sort"
+
+// Kind categories, for rolling up event counts into something operators who
+// do not know kind numbers can read.
+const (
+	KindCategoryProfiles   = "profiles"
+	KindCategoryNotes      = "notes"
+	KindCategoryReposts    = "reposts"
+	KindCategoryReactions  = "reactions"
+	KindCategoryDMs        = "dms"
+	KindCategoryZaps       = "zaps"
+	KindCategoryLongForm   = "long-form"
+	KindCategoryMedia      = "media"
+	KindCategoryLists      = "lists"
+	KindCategoryModeration = "moderation"
+	KindCategoryEphemeral  = "ephemeral"
+	KindCategoryOther      = "other"
+)
+
+// KindCategory is a group of related event kinds.
+type KindCategory struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// KindCategories lists the categories in display order.
+var KindCategories = []KindCategory{
+	{KindCategoryNotes, "Notes"},
+	{KindCategoryReactions, "Reactions"},
+	{KindCategoryReposts, "Reposts"},
+	{KindCategoryDMs, "Direct messages"},
+	{KindCategoryZaps, "Zaps"},
+	{KindCategoryLongForm, "Long-form"},
+	{KindCategoryMedia, "Media"},
+	{KindCategoryProfiles, "Profiles"},
+	{KindCategoryLists, "Lists"},
+	{KindCategoryModeration, "Moderation"},
+	{KindCategoryEphemeral, "Ephemeral"},
+	{KindCategoryOther, "Other"},
+}
+
+// KindInfo describes an event kind.
+type KindInfo struct {
+	Kind     int    `json:"kind"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// kindCatalog names the kinds from the NIPs a relay commonly stores.
+var kindCatalog = map[int]KindInfo{
+	0:     {Name: "Profile", Category: KindCategoryProfiles},
+	1:     {Name: "Note", Category: KindCategoryNotes},
+	3:     {Name: "Follow list", Category: KindCategoryLists},
+	4:     {Name: "Encrypted DM", Category: KindCategoryDMs},
+	5:     {Name: "Deletion request", Category: KindCategoryModeration},
+	6:     {Name: "Repost", Category: KindCategoryReposts},
+	7:     {Name: "Reaction", Category: KindCategoryReactions},
+	8:     {Name: "Badge award", Category: KindCategoryOther},
+	13:    {Name: "Seal", Category: KindCategoryDMs},
+	14:    {Name: "Private DM", Category: KindCategoryDMs},
+	15:    {Name: "Private file message", Category: KindCategoryDMs},
+	16:    {Name: "Generic repost", Category: KindCategoryReposts},
+	20:    {Name: "Picture", Category: KindCategoryMedia},
+	21:    {Name: "Video", Category: KindCategoryMedia},
+	22:    {Name: "Short video", Category: KindCategoryMedia},
+	40:    {Name: "Channel creation", Category: KindCategoryNotes},
+	41:    {Name: "Channel metadata", Category: KindCategoryNotes},
+	42:    {Name: "Channel message", Category: KindCategoryNotes},
+	1059:  {Name: "Gift wrap", Category: KindCategoryDMs},
+	1063:  {Name: "File metadata", Category: KindCategoryMedia},
+	1111:  {Name: "Comment", Category: KindCategoryNotes},
+	1311:  {Name: "Live chat message", Category: KindCategoryNotes},
+	1984:  {Name: "Report", Category: KindCategoryModeration},
+	1985:  {Name: "Label", Category: KindCategoryModeration},
+	9734:  {Name: "Zap request", Category: KindCategoryZaps},
+	9735:  {Name: "Zap receipt", Category: KindCategoryZaps},
+	9802:  {Name: "Highlight", Category: KindCategoryNotes},
+	10000: {Name: "Mute list", Category: KindCategoryLists},
+	10001: {Name: "Pinned notes", Category: KindCategoryLists},
+	10002: {Name: "Relay list", Category: KindCategoryLists},
+	10003: {Name: "Bookmarks", Category: KindCategoryLists},
+	10050: {Name: "DM relay list", Category: KindCategoryLists},
+	10063: {Name: "Blossom server list", Category: KindCategoryLists},
+	13194: {Name: "Wallet info", Category: KindCategoryOther},
+	22242: {Name: "Client authentication", Category: KindCategoryEphemeral},
+	23194: {Name: "Wallet request", Category: KindCategoryEphemeral},
+	23195: {Name: "Wallet response", Category: KindCategoryEphemeral},
+	24133: {Name: "Nostr Connect", Category: KindCategoryEphemeral},
+	30000: {Name: "Follow set", Category: KindCategoryLists},
+	30001: {Name: "Generic list", Category: KindCategoryLists},
+	30002: {Name: "Relay set", Category: KindCategoryLists},
+	30003: {Name: "Bookmark set", Category: KindCategoryLists},
+	30008: {Name: "Profile badges", Category: KindCategoryProfiles},
+	30009: {Name: "Badge definition", Category: KindCategoryOther},
+	30023: {Name: "Long-form article", Category: KindCategoryLongForm},
+	30024: {Name: "Draft article", Category: KindCategoryLongForm},
+	30078: {Name: "App data", Category: KindCategoryOther},
+	30311: {Name: "Live event", Category: KindCategoryOther},
+	30315: {Name: "User status", Category: KindCategoryProfiles},
+	31922: {Name: "Date calendar event", Category: KindCategoryOther},
+	31923: {Name: "Time calendar event", Category: KindCategoryOther},
+	31989: {Name: "App recommendation", Category: KindCategoryOther},
+	31990: {Name: "App handler", Category: KindCategoryOther},
+}
+
+// LookupKind describes kind. Kinds not in the catalog are named after the
+// NIP-01 range they fall in: ephemeral, replaceable or addressable.
+func LookupKind(kind int) KindInfo {
+	if info, ok := kindCatalog[kind]; ok {
+		info.Kind = kind
+		return info
+	}
+
+	info := KindInfo{Kind: kind, Category: KindCategoryOther}
+	switch {
+	case kind >= 20000 && kind < 30000:
+		info.Name = "Ephemeral event"
+		info.Category = KindCategoryEphemeral
+	case kind >= 10000 && kind < 20000:
+		info.Name = "Replaceable event"
+	case kind >= 30000 && kind < 40000:
+		info.Name = "Addressable event"
+	default:
+		info.Name = "Unknown"
+	}
+	return info
+}
+
+// KindCatalog returns every kind in the catalog, in kind order.
+func KindCatalog() []KindInfo {
+	kinds := make([]KindInfo, 0, len(kindCatalog))
+	for kind := range kindCatalog {
+		kinds = append(kinds, LookupKind(kind))
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].Kind < kinds[j].Kind })
+	return kinds
+}
@@ -0,0 +1,44 @@
+package nostr
+
+import "testing"
+
+func TestLookupKind(t *testing.T) {
+	tests := []struct {
+		kind     int
+		name     string
+		category string
+	}{
+		{1, "Note", KindCategoryNotes},
+		{7, "Reaction", KindCategoryReactions},
+		{1059, "Gift wrap", KindCategoryDMs},
+		{30023, "Long-form article", KindCategoryLongForm},
+		{10002, "Relay list", KindCategoryLists},
+		{22242, "Client authentication", KindCategoryEphemeral},
+		{20001, "Ephemeral event", KindCategoryEphemeral},
+		{10123, "Replaceable event", KindCategoryOther},
+		{34567, "Addressable event", KindCategoryOther},
+		{4242, "Unknown", KindCategoryOther},
+	}
+	for _, tt := range tests {
+		info := LookupKind(tt.kind)
+		if info.Kind != tt.kind || info.Name != tt.name || info.Category != tt.category {
+			t.Errorf("LookupKind(%d) = %+v, want %q in %q", tt.kind, info, tt.name, tt.category)
+		}
+	}
+}
+
+func TestKindCatalogCategories(t *testing.T) {
+	known := map[string]bool{}
+	for _, c := range KindCategories {
+		known[c.ID] = true
+	}
+	catalog := KindCatalog()
+	for i, info := range catalog {
+		if !known[info.Category] {
+			t.Errorf("kind %d has unknown category %q", info.Kind, info.Category)
+		}
+		if i > 0 && catalog[i-1].Kind >= info.Kind {
+			t.Errorf("catalog not in kind order at kind %d", info.Kind)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package nostr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// NIP-04 errors
+var (
+	ErrInvalidCiphertext = errors.New("invalid NIP-04 ciphertext")
+)
+
+// nip04SharedSecret computes the ECDH shared secret (x coordinate) between
+// our secret key and the peer's x-only pubkey.
+func nip04SharedSecret(secretHex, pubkeyHex string) ([]byte, error) {
+	priv, err := parseSecretKey(secretHex)
+	if err != nil {
+		return nil, err
+	}
+
+	pubkeyBytes, err := hex.DecodeString(pubkeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHexPubkey, err)
+	}
+	pub, err := schnorr.ParsePubKey(pubkeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHexPubkey, err)
+	}
+
+	return btcec.GenerateSharedSecret(priv, pub), nil
+}
+
+// NIP04Encrypt encrypts plaintext for the given pubkey using NIP-04
+// (AES-256-CBC). The result has the form "<base64 ciphertext>?iv=<base64 iv>".
+func NIP04Encrypt(secretHex, pubkeyHex, plaintext string) (string, error) {
+	key, err := nip04SharedSecret(secretHex, pubkeyHex)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	// PKCS#7 padding
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append([]byte(plaintext), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	return base64.StdEncoding.EncodeToString(ciphertext) + "?iv=" + base64.StdEncoding.EncodeToString(iv), nil
+}
+
+// NIP04Decrypt decrypts a NIP-04 payload sent by the given pubkey.
+func NIP04Decrypt(secretHex, pubkeyHex, payload string) (string, error) {
+	parts := strings.Split(payload, "?iv=")
+	if len(parts) != 2 {
+		return "", ErrInvalidCiphertext
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(iv) != aes.BlockSize {
+		return "", ErrInvalidCiphertext
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return "", ErrInvalidCiphertext
+	}
+
+	key, err := nip04SharedSecret(secretHex, pubkeyHex)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// Strip PKCS#7 padding
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(plaintext) {
+		return "", ErrInvalidCiphertext
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return "", ErrInvalidCiphertext
+		}
+	}
+
+	return string(plaintext[:len(plaintext)-padding]), nil
+}
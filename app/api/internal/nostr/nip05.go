@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -19,6 +23,62 @@ var (
 	ErrNIP05InvalidPubkey = errors.New("NIP-05 response contains invalid pubkey")
 )
 
+// maxNIP05ResponseSize caps a nostr.json response. The name is passed in
+// the query, so servers that honour it return a single entry.
+const maxNIP05ResponseSize = 1 << 20
+
+var (
+	nip05Client = &http.Client{Timeout: 10 * time.Second}
+
+	// publicNIP05Client only connects to public addresses, so lookups on
+	// behalf of unauthenticated users cannot reach the operator's network.
+	// The check is made on the address dialled, after DNS and on every
+	// redirect.
+	publicNIP05Client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+				Control: dialPublicOnly,
+			}).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+	}
+)
+
+// dialPublicOnly refuses connections to loopback, private, link-local and
+// other non-public addresses.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	addr := addrPort.Addr().Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || !isPublicAddr(addr) {
+		return fmt.Errorf("refusing to connect to non-public address %s", addr)
+	}
+	return nil
+}
+
+// nonPublicPrefixes are ranges that pass IsGlobalUnicast and IsPrivate but
+// are not reachable on the internet.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, may map to private IPv4
+	netip.MustParsePrefix("2002::/16"),     // 6to4, likewise
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
 // NIP05Result contains the result of a NIP-05 resolution
 type NIP05Result struct {
 	Name   string   `json:"name"`   // The resolved name part
@@ -72,17 +132,34 @@ func ResolveNIP05(ctx context.Context, identifier string) (*NIP05Result, error)
 	return ResolveNIP05Parts(ctx, name, domain)
 }
 
+// ResolvePublicNIP05 resolves a NIP-05 identifier on behalf of an
+// unauthenticated user. The domain must be a host name without a port, and
+// only public addresses are connected to.
+func ResolvePublicNIP05(ctx context.Context, identifier string) (*NIP05Result, error) {
+	name, domain, err := ParseNIP05(identifier)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(domain, ":[]/") {
+		return nil, ErrInvalidNIP05Format
+	}
+	if _, err := netip.ParseAddr(domain); err == nil {
+		return nil, ErrInvalidNIP05Format
+	}
+
+	return resolveNIP05(ctx, publicNIP05Client, name, domain)
+}
+
 // ResolveNIP05Parts resolves a NIP-05 using separate name and domain
 func ResolveNIP05Parts(ctx context.Context, name, domain string) (*NIP05Result, error) {
+	return resolveNIP05(ctx, nip05Client, name, domain)
+}
+
+func resolveNIP05(ctx context.Context, client *http.Client, name, domain string) (*NIP05Result, error) {
 	// Build the URL
 	nip05URL := fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s",
 		domain, url.QueryEscape(name))
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
 	// Create request with context
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nip05URL, nil)
 	if err != nil {
@@ -107,7 +184,7 @@ func ResolveNIP05Parts(ctx context.Context, name, domain string) (*NIP05Result,
 
 	// Parse response
 	var nip05Resp nip05Response
-	if err := json.NewDecoder(resp.Body).Decode(&nip05Resp); err != nil {
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxNIP05ResponseSize)).Decode(&nip05Resp); err != nil {
 		return nil, fmt.Errorf("%w: invalid JSON response", ErrNIP05FetchFailed)
 	}
 
@@ -152,11 +229,25 @@ func ResolveNIP05Parts(ctx context.Context, name, domain string) (*NIP05Result,
 }
 
 // ResolveIdentity attempts to resolve an identity string to a pubkey.
-// It tries in order: npub, hex pubkey, NIP-05 identifier.
+// It tries in order: npub, hex pubkey, NIP-05 identifier. A nostr: URI
+// prefix is ignored.
 // Returns (hexPubkey, npub, source, nip05Name, error)
 // source is one of: "npub", "hex", "nip05"
 func ResolveIdentity(ctx context.Context, input string) (hexPubkey, npub, source, nip05Name string, err error) {
+	return resolveIdentity(ctx, input, ResolveNIP05)
+}
+
+// ResolvePublicIdentity is ResolveIdentity for unauthenticated users:
+// NIP-05 identifiers are resolved with ResolvePublicNIP05.
+func ResolvePublicIdentity(ctx context.Context, input string) (hexPubkey, npub, source, nip05Name string, err error) {
+	return resolveIdentity(ctx, input, ResolvePublicNIP05)
+}
+
+func resolveIdentity(ctx context.Context, input string, resolve func(context.Context, string) (*NIP05Result, error)) (hexPubkey, npub, source, nip05Name string, err error) {
 	input = strings.TrimSpace(input)
+	if len(input) > 6 && strings.EqualFold(input[:6], "nostr:") {
+		input = input[6:]
+	}
 
 	// Try as npub first
 	if strings.HasPrefix(strings.ToLower(input), "npub") {
@@ -178,7 +269,7 @@ func ResolveIdentity(ctx context.Context, input string) (hexPubkey, npub, source
 
 	// Try as NIP-05 identifier
 	if IsNIP05Identifier(input) {
-		result, err := ResolveNIP05(ctx, input)
+		result, err := resolve(ctx, input)
 		if err != nil {
 			return "", "", "", "", err
 		}
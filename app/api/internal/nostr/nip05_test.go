@@ -0,0 +1,39 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDialPublicOnly(t *testing.T) {
+	for address, public := range map[string]bool{
+		"93.184.216.34:443":      true,
+		"[2606:4700::1111]:443":  true,
+		"127.0.0.1:443":          false,
+		"10.0.0.5:8443":          false,
+		"192.168.1.1:443":        false,
+		"169.254.169.254:80":     false,
+		"100.64.0.1:443":         false,
+		"[::1]:443":              false,
+		"[fe80::1]:443":          false,
+		"[fd00::1]:443":          false,
+		"[::ffff:127.0.0.1]:443": false,
+		"[64:ff9b::a00:5]:443":   false,
+		"0.0.0.0:443":            false,
+		"[2002:a00:5::1]:443":    false,
+		"[2001:db8::1]:443":      true, // Documentation range, but not ours to refuse
+	} {
+		if err := dialPublicOnly("tcp", address, nil); (err == nil) != public {
+			t.Errorf("dialPublicOnly(%q) = %v, want public %v", address, err, public)
+		}
+	}
+}
+
+func TestResolvePublicNIP05_RejectsAddresses(t *testing.T) {
+	for _, identifier := range []string{"x@10.0.0.5", "x@10.0.0.5:8443", "x@example.com:8443", "x@[::1]"} {
+		if _, err := ResolvePublicNIP05(context.Background(), identifier); !errors.Is(err, ErrInvalidNIP05Format) {
+			t.Errorf("ResolvePublicNIP05(%q) = %v, want ErrInvalidNIP05Format", identifier, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package nostr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotWebSocket is returned by Upgrade for requests that are not WebSocket upgrades.
+var ErrNotWebSocket = errors.New("not a WebSocket upgrade request")
+
+// maxServerMessageSize caps messages accepted from clients (64KB).
+const maxServerMessageSize = 64 * 1024
+
+// ServerConn is the server side of a WebSocket connection, used to speak the
+// Nostr relay protocol to clients from the API.
+type ServerConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+	closed atomic.Bool
+}
+
+// Upgrade performs the WebSocket handshake on an HTTP request and takes over
+// the underlying connection.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*ServerConn, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return nil, ErrNotWebSocket
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("%w: unsupported version", ErrHandshakeFailed)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("%w: missing key", ErrHandshakeFailed)
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHandshakeFailed, err)
+	}
+
+	// Clear any deadlines inherited from the HTTP server
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n" +
+		"\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send handshake: %w", err)
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send handshake: %w", err)
+	}
+
+	return &ServerConn{conn: conn, reader: rw.Reader}, nil
+}
+
+// headerHasToken reports whether a comma-separated header contains token.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message, answering pings and
+// reassembling fragmented messages. It returns ErrConnectionClosed once the
+// client closes, and times out if nothing arrives within idleTimeout.
+func (c *ServerConn) ReadMessage(idleTimeout time.Duration) ([]byte, error) {
+	var message []byte
+	for {
+		if c.closed.Load() {
+			return nil, ErrConnectionClosed
+		}
+		if idleTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+
+		fin, opcode, payload, err := readWSFrame(c.reader, maxServerMessageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeFrame(opClose, []byte{})
+			c.Close()
+			return nil, ErrConnectionClosed
+		case opText, opBinary, opContinuation:
+			message = append(message, payload...)
+			if len(message) > maxServerMessageSize {
+				return nil, ErrMessageTooLarge
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, ErrInvalidFrame
+		}
+	}
+}
+
+// WriteJSON sends v as a JSON text message.
+func (c *ServerConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+// Close closes the connection.
+func (c *ServerConn) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// writeFrame writes an unmasked frame (servers must not mask).
+func (c *ServerConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return ErrConnectionClosed
+	}
+
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|opcode)
+
+	payloadLen := len(payload)
+	if payloadLen <= 125 {
+		frame = append(frame, byte(payloadLen))
+	} else if payloadLen <= 65535 {
+		frame = append(frame, 126, byte(payloadLen>>8), byte(payloadLen))
+	} else {
+		frame = append(frame, 127)
+		lenBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(lenBytes, uint64(payloadLen))
+		frame = append(frame, lenBytes...)
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(frame)
+	return err
+}
@@ -0,0 +1,71 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerConnWithClient(t *testing.T) {
+	secret, _ := GenerateSecretKey()
+	stored := NewEvent(1, nil, "hello from the server")
+	if err := stored.Sign(secret); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		message, err := conn.ReadMessage(5 * time.Second)
+		if err != nil {
+			t.Errorf("ReadMessage failed: %v", err)
+			return
+		}
+		var req []json.RawMessage
+		json.Unmarshal(message, &req)
+		var subID string
+		json.Unmarshal(req[1], &subID)
+
+		conn.WriteJSON([]interface{}{"EVENT", subID, stored})
+		conn.WriteJSON([]interface{}{"EOSE", subID})
+		conn.ReadMessage(5 * time.Second) // wait for the client to close
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	var received []*SyncEvent
+	err := client.Subscribe(ctx, Filter{Kinds: []int{1}}, func(e *SyncEvent) error {
+		received = append(received, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if len(received) != 1 || received[0].ID != stored.ID {
+		t.Errorf("expected stored event, got %+v", received)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	req := httptest.NewRequest("GET", "/public/search", nil)
+	if _, err := Upgrade(httptest.NewRecorder(), req); err != ErrNotWebSocket {
+		t.Errorf("expected ErrNotWebSocket, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// Signing errors
+var (
+	ErrInvalidSecretKey = errors.New("invalid secret key: must be 64 hex characters")
+)
+
+// GenerateSecretKey returns a new random secret key as hex.
+func GenerateSecretKey() (string, error) {
+	priv, err := btcec.NewPrivateKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return hex.EncodeToString(priv.Serialize()), nil
+}
+
+// parseSecretKey decodes a hex secret key.
+func parseSecretKey(secretHex string) (*btcec.PrivateKey, error) {
+	secretBytes, err := hex.DecodeString(secretHex)
+	if err != nil || len(secretBytes) != 32 {
+		return nil, ErrInvalidSecretKey
+	}
+	priv, _ := btcec.PrivKeyFromBytes(secretBytes)
+	return priv, nil
+}
+
+// DecodeNsec decodes an nsec bech32 string to a hex secret key.
+func DecodeNsec(nsec string) (string, error) {
+	hrp, data, err := DecodeBech32(nsec)
+	if err != nil || hrp != "nsec" || len(data) != 32 {
+		return "", ErrInvalidSecretKey
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// GetPublicKey returns the x-only hex pubkey for a hex secret key.
+func GetPublicKey(secretHex string) (string, error) {
+	priv, err := parseSecretKey(secretHex)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(schnorr.SerializePubKey(priv.PubKey())), nil
+}
+
+// NewEvent builds an unsigned event with the current timestamp.
+func NewEvent(kind int, tags [][]string, content string) *SyncEvent {
+	if tags == nil {
+		tags = [][]string{}
+	}
+	return &SyncEvent{
+		CreatedAt: time.Now().Unix(),
+		Kind:      kind,
+		Tags:      tags,
+		Content:   content,
+	}
+}
+
+// Sign sets the event pubkey, computes its ID and signs it with the secret key.
+func (e *SyncEvent) Sign(secretHex string) error {
+	priv, err := parseSecretKey(secretHex)
+	if err != nil {
+		return err
+	}
+
+	e.Pubkey = hex.EncodeToString(schnorr.SerializePubKey(priv.PubKey()))
+	if e.Tags == nil {
+		e.Tags = [][]string{}
+	}
+
+	id, err := e.ComputeID()
+	if err != nil {
+		return err
+	}
+	e.ID = id
+
+	idBytes, _ := hex.DecodeString(id)
+	sig, err := schnorr.Sign(priv, idBytes)
+	if err != nil {
+		return fmt.Errorf("failed to sign event: %w", err)
+	}
+	e.Sig = hex.EncodeToString(sig.Serialize())
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package nostr
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret, err := GenerateSecretKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretKey failed: %v", err)
+	}
+
+	event := NewEvent(1, [][]string{{"t", "roostr"}}, "hello")
+	if err := event.Sign(secret); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	pubkey, err := GetPublicKey(secret)
+	if err != nil {
+		t.Fatalf("GetPublicKey failed: %v", err)
+	}
+	if event.Pubkey != pubkey {
+		t.Errorf("expected pubkey %s, got %s", pubkey, event.Pubkey)
+	}
+	if err := event.Verify(); err != nil {
+		t.Errorf("expected signed event to verify, got %v", err)
+	}
+
+	event.Content = "tampered"
+	if err := event.Verify(); err == nil {
+		t.Error("expected tampered event to fail verification")
+	}
+}
+
+func TestSignInvalidKey(t *testing.T) {
+	event := NewEvent(1, nil, "hello")
+	if err := event.Sign("not-a-key"); err != ErrInvalidSecretKey {
+		t.Errorf("expected ErrInvalidSecretKey, got %v", err)
+	}
+}
+
+func TestNIP04RoundTrip(t *testing.T) {
+	aliceSecret, _ := GenerateSecretKey()
+	bobSecret, _ := GenerateSecretKey()
+	alicePubkey, _ := GetPublicKey(aliceSecret)
+	bobPubkey, _ := GetPublicKey(bobSecret)
+
+	payload, err := NIP04Encrypt(aliceSecret, bobPubkey, `{"method":"get_info"}`)
+	if err != nil {
+		t.Fatalf("NIP04Encrypt failed: %v", err)
+	}
+	if !strings.Contains(payload, "?iv=") {
+		t.Errorf("expected ?iv= in payload, got %s", payload)
+	}
+
+	plaintext, err := NIP04Decrypt(bobSecret, alicePubkey, payload)
+	if err != nil {
+		t.Fatalf("NIP04Decrypt failed: %v", err)
+	}
+	if plaintext != `{"method":"get_info"}` {
+		t.Errorf("unexpected plaintext: %s", plaintext)
+	}
+
+	if _, err := NIP04Decrypt(bobSecret, alicePubkey, "garbage"); err == nil {
+		t.Error("expected error for malformed payload")
+	}
+
+	// A block ending in 3 whose other padding bytes are not 3 is rejected
+	payload, _ = NIP04Encrypt(aliceSecret, bobPubkey, "0123456789abc\x01\x02\x03")
+	parts := strings.Split(payload, "?iv=")
+	ciphertext, _ := base64.StdEncoding.DecodeString(parts[0])
+	truncated := base64.StdEncoding.EncodeToString(ciphertext[:16]) + "?iv=" + parts[1]
+	if _, err := NIP04Decrypt(bobSecret, alicePubkey, truncated); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("expected ErrInvalidCiphertext for bad padding, got %v", err)
+	}
+}
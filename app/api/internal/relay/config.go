@@ -4,13 +4,16 @@ package relay
 import (
 	"bytes"
 	"os"
+	"reflect"
+	"strings"
 	"sync"
 
 	"github.com/BurntSushi/toml"
 )
 
 // Config represents the nostr-rs-relay configuration file structure.
-// We only define the sections we need to modify; TOML will preserve others.
+// We only define the sections we need to modify; Write keeps any other
+// sections and keys already in the file.
 type Config struct {
 	Info          InfoConfig          `toml:"info"`
 	Database      DatabaseConfig      `toml:"database"`
@@ -18,6 +21,7 @@ type Config struct {
 	Limits        LimitsConfig        `toml:"limits"`
 	Authorization AuthorizationConfig `toml:"authorization"`
 	Logging       LoggingConfig       `toml:"logging"`
+	GRPC          GRPCConfig          `toml:"grpc,omitempty"`
 }
 
 // InfoConfig contains relay metadata.
@@ -49,6 +53,8 @@ type LimitsConfig struct {
 	MaxWSMessageBytes   int `toml:"max_ws_message_bytes"`
 	MaxSubsPerConn      int `toml:"max_subs_per_conn,omitempty"`
 	MinPowDifficulty    int `toml:"min_pow_difficulty,omitempty"`
+
+	EventKindBlacklist []int `toml:"event_kind_blacklist,omitempty"`
 }
 
 // AuthorizationConfig contains access control settings.
@@ -65,10 +71,54 @@ type LoggingConfig struct {
 	FilePrefix string `toml:"file_prefix"`
 }
 
+// GRPCConfig points the relay at an event admission server, which decides
+// whether each event is stored.
+type GRPCConfig struct {
+	EventAdmissionServer string `toml:"event_admission_server,omitempty"`
+	RestrictsWrite       bool   `toml:"restricts_write,omitempty"`
+}
+
+// managedKeys maps each section of Config to the keys it defines.
+var managedKeys = func() map[string]map[string]bool {
+	keys := map[string]map[string]bool{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		section := t.Field(i)
+		fields := map[string]bool{}
+		for j := 0; j < section.Type.NumField(); j++ {
+			fields[tomlKey(section.Type.Field(j))] = true
+		}
+		keys[tomlKey(section)] = fields
+	}
+	return keys
+}()
+
+// tomlKey returns the TOML key of a struct field.
+func tomlKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+	return name
+}
+
+// ParseConfig parses the contents of a config file.
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if _, err := toml.Decode(string(data), &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// WriteRecorder is called after each config write with the file contents,
+// who made the change and why, e.g. to keep a history.
+type WriteRecorder func(content []byte, actor, reason string)
+
 // ConfigManager handles reading and writing relay configuration.
 type ConfigManager struct {
-	path string
-	mu   sync.RWMutex
+	path      string
+	lastWrite []byte // contents of the last Write
+	recorder  WriteRecorder
+	admission string // event admission server URL, if access is delegated
+	mu        sync.RWMutex
 }
 
 // NewConfigManager creates a new ConfigManager for the given config file path.
@@ -95,21 +145,166 @@ func (cm *ConfigManager) Read() (*Config, error) {
 	return &cfg, nil
 }
 
-// Write writes the configuration to the TOML file.
+// ReadRaw returns the contents of the config file.
+func (cm *ConfigManager) ReadRaw() ([]byte, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return os.ReadFile(cm.path)
+}
+
+// LastWrite returns the contents of the last Write, or nil if this
+// ConfigManager has not written the file.
+func (cm *ConfigManager) LastWrite() []byte {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.lastWrite
+}
+
+// SetRecorder sets the function told about each config write.
+func (cm *ConfigManager) SetRecorder(recorder WriteRecorder) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.recorder = recorder
+}
+
+// SetAdmissionServer delegates access control to the event admission
+// server at url, or stops delegating it when url is empty. While delegated,
+// every write points the relay at the server and leaves the access lists
+// empty, since the server enforces them.
+func (cm *ConfigManager) SetAdmissionServer(url string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.admission = url
+}
+
+// DelegatesAccess reports whether the access lists are enforced by an event
+// admission server rather than written to the config file.
+func (cm *ConfigManager) DelegatesAccess() bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.admission != ""
+}
+
+// Write writes the configuration to the TOML file as a change made by
+// Roostr itself. Use WriteAs for changes made on someone's behalf.
 func (cm *ConfigManager) Write(cfg *Config) error {
+	return cm.WriteAs(cfg, "roostr", "")
+}
+
+// WriteAs writes the configuration to the TOML file, recording who made the
+// change and why. Sections and keys in the file that Config does not define
+// are kept.
+func (cm *ConfigManager) WriteAs(cfg *Config, actor, reason string) error {
+	content, recorder, err := cm.write(cfg)
+	if err != nil {
+		return err
+	}
+	if recorder != nil {
+		recorder(content, actor, reason)
+	}
+	return nil
+}
+
+// write writes cfg, returning the contents written and the recorder to
+// tell about them.
+func (cm *ConfigManager) write(cfg *Config) ([]byte, WriteRecorder, error) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	if cm.admission != "" {
+		delegated := *cfg
+		delegated.GRPC = GRPCConfig{EventAdmissionServer: cm.admission, RestrictsWrite: true}
+		delegated.Authorization.PubkeyWhitelist = []string{}
+		delegated.Authorization.PubkeyBlacklist = []string{}
+		cfg = &delegated
+	}
+
 	var buf bytes.Buffer
 	buf.WriteString("# Roostr - nostr-rs-relay configuration\n")
 	buf.WriteString("# This file is managed by Roostr. Manual edits may be overwritten.\n\n")
 
+	var doc interface{} = cfg
+	if extra := cm.unmanaged(); len(extra) > 0 {
+		merged, err := mergeUnmanaged(cfg, extra)
+		if err != nil {
+			return nil, nil, err
+		}
+		doc = merged
+	}
+
 	encoder := toml.NewEncoder(&buf)
-	if err := encoder.Encode(cfg); err != nil {
-		return err
+	if err := encoder.Encode(doc); err != nil {
+		return nil, nil, err
 	}
 
-	return os.WriteFile(cm.path, buf.Bytes(), 0644)
+	if err := os.WriteFile(cm.path, buf.Bytes(), 0644); err != nil {
+		return nil, nil, err
+	}
+	cm.lastWrite = buf.Bytes()
+	return cm.lastWrite, cm.recorder, nil
+}
+
+// unmanaged returns the sections and keys in the config file that Config
+// does not define. A missing or unparseable file has none.
+func (cm *ConfigManager) unmanaged() map[string]interface{} {
+	var doc map[string]interface{}
+	if _, err := toml.DecodeFile(cm.path, &doc); err != nil {
+		return nil
+	}
+
+	extra := map[string]interface{}{}
+	for key, value := range doc {
+		fields, ok := managedKeys[key]
+		if !ok {
+			extra[key] = value
+			continue
+		}
+		section, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range section {
+			if fields[k] {
+				continue
+			}
+			kept, _ := extra[key].(map[string]interface{})
+			if kept == nil {
+				kept = map[string]interface{}{}
+				extra[key] = kept
+			}
+			kept[k] = v
+		}
+	}
+	return extra
+}
+
+// mergeUnmanaged returns cfg as a TOML document with the extra sections and
+// keys added.
+func mergeUnmanaged(cfg *Config, extra map[string]interface{}) (map[string]interface{}, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if _, err := toml.Decode(buf.String(), &doc); err != nil {
+		return nil, err
+	}
+
+	for key, value := range extra {
+		if _, ok := managedKeys[key]; !ok {
+			doc[key] = value
+			continue
+		}
+		section, _ := doc[key].(map[string]interface{})
+		if section == nil {
+			section = map[string]interface{}{}
+			doc[key] = section
+		}
+		for k, v := range value.(map[string]interface{}) {
+			section[k] = v
+		}
+	}
+	return doc, nil
 }
 
 // UpdateWhitelist updates the pubkey whitelist in the config file.
@@ -121,7 +316,7 @@ func (cm *ConfigManager) UpdateWhitelist(pubkeys []string) error {
 	}
 
 	cfg.Authorization.PubkeyWhitelist = pubkeys
-	return cm.Write(cfg)
+	return cm.WriteAs(cfg, "roostr", "Whitelist synced")
 }
 
 // UpdateBlacklist updates the pubkey blacklist in the config file.
@@ -133,7 +328,28 @@ func (cm *ConfigManager) UpdateBlacklist(pubkeys []string) error {
 	}
 
 	cfg.Authorization.PubkeyBlacklist = pubkeys
-	return cm.Write(cfg)
+	return cm.WriteAs(cfg, "roostr", "Blacklist synced")
+}
+
+// UpdateExcludedKinds writes kinds to the relay's event kind blacklist so the
+// relay rejects them on publish, regardless of the allowlist.
+func (cm *ConfigManager) UpdateExcludedKinds(kinds []int) error {
+	cfg, err := cm.Read()
+	if err != nil {
+		return err
+	}
+
+	cfg.Limits.EventKindBlacklist = kinds
+	return cm.WriteAs(cfg, "roostr", "Excluded kinds updated")
+}
+
+// GetExcludedKinds returns the event kind blacklist from the config file.
+func (cm *ConfigManager) GetExcludedKinds() ([]int, error) {
+	cfg, err := cm.Read()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Limits.EventKindBlacklist, nil
 }
 
 // GetWhitelist returns the current whitelist from the config file.
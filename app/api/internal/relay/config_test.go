@@ -0,0 +1,148 @@
+package relay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigManagerWriteKeepsUnmanagedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte(`
+[info]
+name = "Test"
+relay_icon = "https://example.com/icon.png"
+
+[options]
+reject_future_seconds = 1800
+
+[limits]
+messages_per_sec = 5
+broadcast_buffer = 16384
+`), 0644)
+
+	cm := NewConfigManager(path)
+	if cm.LastWrite() != nil {
+		t.Fatal("expected no last write before writing")
+	}
+	if err := cm.UpdateWhitelist([]string{"aa"}); err != nil {
+		t.Fatalf("UpdateWhitelist() error = %v", err)
+	}
+	cfg, err := cm.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	cfg.Info.RelayIcon = "" // cleared fields stay cleared
+	if err := cm.Write(cfg); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	content := string(data)
+	for _, want := range []string{"reject_future_seconds = 1800", "broadcast_buffer = 16384", "messages_per_sec = 5", `"aa"`} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %q in written config:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "relay_icon") {
+		t.Errorf("expected relay_icon cleared:\n%s", content)
+	}
+	if string(cm.LastWrite()) != content {
+		t.Error("expected LastWrite() to return the written config")
+	}
+}
+
+func TestConfigManagerAdmissionServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("[info]\nname = \"Test\"\n\n[grpc]\nevent_admission_server = \"http://old:1\"\nlogging = true\n"), 0644)
+
+	cm := NewConfigManager(path)
+	cm.SetAdmissionServer("http://127.0.0.1:50051")
+	if !cm.DelegatesAccess() {
+		t.Fatal("expected access to be delegated")
+	}
+	if err := cm.UpdateWhitelist([]string{"aa"}); err != nil {
+		t.Fatalf("UpdateWhitelist() error = %v", err)
+	}
+	cfg, err := cm.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if cfg.GRPC.EventAdmissionServer != "http://127.0.0.1:50051" || !cfg.GRPC.RestrictsWrite {
+		t.Errorf("expected the relay pointed at the admission server, got %+v", cfg.GRPC)
+	}
+	if len(cfg.Authorization.PubkeyWhitelist) != 0 {
+		t.Errorf("expected no whitelist while delegated, got %v", cfg.Authorization.PubkeyWhitelist)
+	}
+
+	// Stopping delegation removes the server but keeps unmanaged keys
+	cm.SetAdmissionServer("")
+	cfg.GRPC = GRPCConfig{}
+	if err := cm.Write(cfg); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "event_admission_server") || !strings.Contains(string(data), "logging = true") {
+		t.Errorf("unexpected [grpc] section after delegation stopped:\n%s", data)
+	}
+}
+
+func TestConfigManagerRecordsWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("[info]\nname = \"Test\"\n"), 0644)
+
+	cm := NewConfigManager(path)
+	type write struct{ actor, reason string }
+	var writes []write
+	cm.SetRecorder(func(content []byte, actor, reason string) {
+		if !strings.Contains(string(content), `name = "Test"`) {
+			t.Errorf("unexpected recorded content:\n%s", content)
+		}
+		writes = append(writes, write{actor, reason})
+	})
+
+	cfg, _ := cm.Read()
+	cm.WriteAs(cfg, "admin", "Updated limits")
+	cm.UpdateWhitelist([]string{"aa"})
+	cm.Write(cfg)
+
+	want := []write{{"admin", "Updated limits"}, {"roostr", "Whitelist synced"}, {"roostr", ""}}
+	if len(writes) != len(want) {
+		t.Fatalf("expected %d recorded writes, got %+v", len(want), writes)
+	}
+	for i := range want {
+		if writes[i] != want[i] {
+			t.Errorf("write %d = %+v, want %+v", i, writes[i], want[i])
+		}
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	file := &Config{
+		Info:          InfoConfig{Name: "Edited"},
+		Limits:        LimitsConfig{MessagesPerSec: 10},
+		Authorization: AuthorizationConfig{PubkeyWhitelist: []string{"aa", "cc"}},
+	}
+	desired := &Config{
+		Info:          InfoConfig{Name: "Relay"},
+		Limits:        LimitsConfig{MessagesPerSec: 10},
+		Authorization: AuthorizationConfig{PubkeyWhitelist: []string{"bb", "aa"}, PubkeyBlacklist: []string{}},
+	}
+
+	changes := DiffConfig(file, desired)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	if c := changes[0]; c.Key != "info.name" || c.File != "Edited" || c.Desired != "Relay" {
+		t.Errorf("unexpected change %+v", c)
+	}
+	if c := changes[1]; c.Key != "authorization.pubkey_whitelist" ||
+		len(c.Added) != 1 || c.Added[0] != "cc" || len(c.Removed) != 1 || c.Removed[0] != "bb" {
+		t.Errorf("unexpected change %+v", c)
+	}
+
+	if changes := DiffConfig(desired, desired); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
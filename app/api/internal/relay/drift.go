@@ -0,0 +1,70 @@
+package relay
+
+import "reflect"
+
+// ConfigChange is a setting that differs between the config file and the
+// config Roostr expects.
+type ConfigChange struct {
+	Key     string      `json:"key"` // section.key, e.g. limits.messages_per_sec
+	File    interface{} `json:"file,omitempty"`
+	Desired interface{} `json:"desired,omitempty"`
+
+	// For pubkey lists, the pubkeys only in the file and only in the
+	// desired config, instead of both lists.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// DiffConfig returns the settings that differ between file and desired.
+// Pubkey lists are compared as sets, and an empty list matches a missing one.
+func DiffConfig(file, desired *Config) []ConfigChange {
+	changes := []ConfigChange{}
+
+	fv, dv := reflect.ValueOf(file).Elem(), reflect.ValueOf(desired).Elem()
+	t := fv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		section := t.Field(i)
+		for j := 0; j < section.Type.NumField(); j++ {
+			a, b := fv.Field(i).Field(j), dv.Field(i).Field(j)
+			key := tomlKey(section) + "." + tomlKey(section.Type.Field(j))
+
+			if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+				continue
+			}
+			if pubkeys, ok := a.Interface().([]string); ok {
+				added, removed := diffStrings(pubkeys, b.Interface().([]string))
+				if len(added) > 0 || len(removed) > 0 {
+					changes = append(changes, ConfigChange{Key: key, Added: added, Removed: removed})
+				}
+				continue
+			}
+			if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+				changes = append(changes, ConfigChange{Key: key, File: a.Interface(), Desired: b.Interface()})
+			}
+		}
+	}
+	return changes
+}
+
+// diffStrings returns the strings only in a and the strings only in b.
+func diffStrings(a, b []string) (onlyA, onlyB []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	for _, s := range a {
+		if !inB[s] {
+			onlyA = append(onlyA, s)
+		}
+	}
+	for _, s := range b {
+		if !inA[s] {
+			onlyB = append(onlyB, s)
+		}
+	}
+	return onlyA, onlyB
+}
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"regexp"
@@ -105,17 +106,41 @@ type Relay struct {
 	cmd        *exec.Cmd
 	logBuffer  *LogBuffer
 
+	// findPID locates a relay not started by Roostr (findRelayPID by default)
+	findPID func() (int, error)
+
 	mu         sync.RWMutex
 	restarting bool
+
+	// Child process supervision state, guarded by mu (see supervisor.go)
+	supervised   bool
+	stopCh       chan struct{}
+	childDone    chan struct{}
+	startedAt    time.Time
+	expectExit   bool
+	restartCount int
+	crashCount   int
+	lastExit     *ExitInfo
+	nextRestart  time.Time
+	backoff      time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	stableAfter  time.Duration
 }
 
 // New creates a new Relay instance.
 func New(binaryPath, configPath string) *Relay {
-	return &Relay{
+	r := &Relay{
 		BinaryPath: binaryPath,
 		ConfigPath: configPath,
 		logBuffer:  NewLogBuffer(1000), // Keep last 1000 log entries
+
+		minBackoff:  defaultMinBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		stableAfter: defaultStableAfter,
 	}
+	r.findPID = r.findRelayPID
+	return r
 }
 
 // IsRestarting returns true if a restart is currently in progress.
@@ -149,39 +174,19 @@ func (r *Relay) Start() error {
 		args = append(args, "--config", r.ConfigPath)
 	}
 
-	r.cmd = exec.Command(r.BinaryPath, args...)
-
-	// Capture stdout and stderr for log buffer
-	stdoutPipe, err := r.cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
-	stderrPipe, err := r.cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
-
-	if err := r.cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start relay: %w", err)
-	}
-
-	// Start goroutines to capture output
-	go r.captureOutput(stdoutPipe)
-	go r.captureOutput(stderrPipe)
-
-	return nil
+	return r.startChild(args)
 }
 
 // captureOutput reads from a pipe and stores log entries in the buffer.
-func (r *Relay) captureOutput(pipe io.ReadCloser) {
+func (r *Relay) captureOutput(pipe io.ReadCloser, wg *sync.WaitGroup) {
+	defer wg.Done()
+
 	scanner := bufio.NewScanner(pipe)
 	for scanner.Scan() {
-		line := scanner.Text()
-		// Also print to stdout for debugging
-		fmt.Println(line)
-		// Parse and store in buffer
-		entry := parseLogLine(line)
+		// Parse and store in buffer, and pass through to our own log
+		entry := parseLogLine(scanner.Text())
 		r.logBuffer.Add(entry)
+		slog.Info("Relay output", "relay_level", entry.Level, "message", entry.Message)
 	}
 }
 
@@ -189,8 +194,12 @@ func (r *Relay) captureOutput(pipe io.ReadCloser) {
 // 2024-01-15T10:30:00.123456Z  INFO nostr_rs_relay::server: message
 var logLineRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?Z?)\s+(\w+)\s+(.+)$`)
 
+// ansiEscapeRegex matches terminal color codes in the relay's output.
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
 // parseLogLine parses a log line into a LogEntry.
 func parseLogLine(line string) LogEntry {
+	line = ansiEscapeRegex.ReplaceAllString(line, "")
 	matches := logLineRegex.FindStringSubmatch(line)
 	if matches != nil && len(matches) == 4 {
 		return LogEntry{
@@ -207,9 +216,14 @@ func parseLogLine(line string) LogEntry {
 	}
 }
 
-// Stop stops the relay process.
+// Stop stops the relay process. A child started by Roostr is stopped
+// directly; otherwise the relay is found by name.
 func (r *Relay) Stop() error {
-	pid, err := r.findRelayPID()
+	if r.stopChild() {
+		return nil
+	}
+
+	pid, err := r.findPID()
 	if err != nil {
 		return err
 	}
@@ -261,7 +275,7 @@ func (r *Relay) Restart() error {
 		// Stop the relay
 		if err := r.Stop(); err != nil {
 			// Log error but continue - process may already be stopped
-			fmt.Printf("Warning during relay stop: %v\n", err)
+			slog.Warn("Failed to stop relay cleanly", "error", err)
 		}
 
 		// Wait a moment for cleanup
@@ -269,7 +283,7 @@ func (r *Relay) Restart() error {
 
 		// Start the relay
 		if err := r.Start(); err != nil {
-			fmt.Printf("Error starting relay: %v\n", err)
+			slog.Error("Failed to start relay", "error", err)
 		}
 	}()
 
@@ -279,7 +293,11 @@ func (r *Relay) Restart() error {
 // Reload sends SIGHUP to the relay process to reload its configuration.
 // Returns nil if no relay process is found (graceful handling for dev environments).
 func (r *Relay) Reload() error {
-	pid, err := r.findRelayPID()
+	if pid := r.childPID(); pid > 0 {
+		return signalPID(pid, syscall.SIGHUP)
+	}
+
+	pid, err := r.findPID()
 	if err != nil {
 		// No relay running - this is okay in development
 		return nil
@@ -367,7 +385,10 @@ func (r *Relay) findRelayPIDWithPS() (int, error) {
 
 // IsRunning checks if the relay process is currently running.
 func (r *Relay) IsRunning() bool {
-	pid, err := r.findRelayPID()
+	if r.childPID() > 0 {
+		return true
+	}
+	pid, err := r.findPID()
 	if err != nil {
 		return false
 	}
@@ -381,7 +402,10 @@ func (r *Relay) Status() (bool, error) {
 
 // GetPID returns the PID of the relay process, or 0 if not running.
 func (r *Relay) GetPID() int {
-	pid, err := r.findRelayPID()
+	if pid := r.childPID(); pid > 0 {
+		return pid
+	}
+	pid, err := r.findPID()
 	if err != nil {
 		return 0
 	}
@@ -425,6 +449,10 @@ func (r *Relay) GetMemoryUsage() int64 {
 // GetProcessUptime returns the uptime of the relay process in seconds.
 // Returns 0 if the process is not running or if uptime info is unavailable.
 func (r *Relay) GetProcessUptime() int64 {
+	if uptime, ok := r.childUptime(); ok {
+		return int64(uptime.Seconds())
+	}
+
 	pid := r.GetPID()
 	if pid == 0 {
 		return 0
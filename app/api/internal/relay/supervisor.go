@@ -0,0 +1,314 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Restart backoff defaults. The delay doubles after each crash up to
+// maxBackoff, and resets once the relay has stayed up for stableAfter.
+const (
+	defaultMinBackoff  = time.Second
+	defaultMaxBackoff  = 2 * time.Minute
+	defaultStableAfter = time.Minute
+
+	// stopTimeout is how long a child gets to exit after SIGTERM before SIGKILL
+	stopTimeout = 10 * time.Second
+)
+
+// ErrExternallyManaged is returned by Supervise when a relay process is
+// already running that Roostr did not start, so it cannot be supervised.
+var ErrExternallyManaged = errors.New("relay is already running outside Roostr")
+
+// ExitInfo describes how the relay process last exited.
+type ExitInfo struct {
+	At       time.Time `json:"at"`
+	Code     int       `json:"code"`            // -1 if killed by a signal
+	Error    string    `json:"error,omitempty"` // Wait error, e.g. "signal: killed"
+	Expected bool      `json:"expected"`        // Stopped by Roostr rather than crashed
+	Uptime   int64     `json:"uptime_seconds"`  // How long the process had been running
+}
+
+// SupervisorStatus reports the supervisor's view of the relay process.
+type SupervisorStatus struct {
+	Supervised    bool       `json:"supervised"`
+	RestartCount  int        `json:"restart_count"` // Automatic restarts after crashes
+	CrashCount    int        `json:"crash_count"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	LastExit      *ExitInfo  `json:"last_exit,omitempty"`
+	NextRestartAt *time.Time `json:"next_restart_at,omitempty"` // Set while waiting out the backoff
+}
+
+// Supervise starts the relay as a child process and keeps it running,
+// restarting it with exponential backoff whenever it exits unexpectedly.
+// It returns ErrExternallyManaged if a relay started elsewhere is running.
+func (r *Relay) Supervise() error {
+	r.mu.Lock()
+	if r.supervised {
+		r.mu.Unlock()
+		return nil
+	}
+	hasChild := r.cmd != nil
+	r.mu.Unlock()
+
+	if !hasChild && r.IsRunning() {
+		return ErrExternallyManaged
+	}
+
+	r.mu.Lock()
+	r.supervised = true
+	r.stopCh = make(chan struct{})
+	r.backoff = r.minBackoff
+	r.mu.Unlock()
+
+	if err := r.Start(); err != nil {
+		// Keep supervising; the relay may come up on a later attempt
+		slog.Error("Failed to start relay", "error", err)
+		r.scheduleRestart()
+	}
+	return nil
+}
+
+// Shutdown stops supervising and stops the child process, if any.
+func (r *Relay) Shutdown() {
+	r.mu.Lock()
+	if r.supervised {
+		r.supervised = false
+		close(r.stopCh)
+	}
+	r.mu.Unlock()
+
+	r.stopChild()
+}
+
+// IsSupervised reports whether the relay is running under the supervisor.
+func (r *Relay) IsSupervised() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.supervised
+}
+
+// SupervisorStatus returns restart counters and exit information.
+func (r *Relay) SupervisorStatus() SupervisorStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	status := SupervisorStatus{
+		Supervised:   r.supervised,
+		RestartCount: r.restartCount,
+		CrashCount:   r.crashCount,
+	}
+	if r.cmd != nil {
+		startedAt := r.startedAt
+		status.StartedAt = &startedAt
+	}
+	if r.lastExit != nil {
+		lastExit := *r.lastExit
+		status.LastExit = &lastExit
+	}
+	if !r.nextRestart.IsZero() {
+		nextRestart := r.nextRestart
+		status.NextRestartAt = &nextRestart
+	}
+	return status
+}
+
+// startChild launches the relay binary as a child process, captures its
+// output and waits for it in the background.
+func (r *Relay) startChild(args []string) error {
+	cmd := exec.Command(r.BinaryPath, args...)
+
+	// Capture stdout and stderr for log buffer
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start relay: %w", err)
+	}
+
+	done := make(chan struct{})
+	r.mu.Lock()
+	r.cmd = cmd
+	r.childDone = done
+	r.startedAt = time.Now()
+	r.expectExit = false
+	r.nextRestart = time.Time{}
+	r.mu.Unlock()
+
+	slog.Info("Relay started", "pid", cmd.Process.Pid)
+
+	// Start goroutines to capture output; Wait must not run until they finish
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go r.captureOutput(stdoutPipe, &wg)
+	go r.captureOutput(stderrPipe, &wg)
+	go r.waitChild(cmd, done, &wg)
+
+	return nil
+}
+
+// waitChild reaps the child process, records how it exited and schedules a
+// restart if it crashed while supervised.
+func (r *Relay) waitChild(cmd *exec.Cmd, done chan struct{}, output *sync.WaitGroup) {
+	output.Wait()
+	err := cmd.Wait()
+
+	r.mu.Lock()
+	exit := &ExitInfo{
+		At:       time.Now(),
+		Code:     cmd.ProcessState.ExitCode(),
+		Expected: r.expectExit,
+		Uptime:   int64(time.Since(r.startedAt).Seconds()),
+	}
+	if err != nil {
+		exit.Error = err.Error()
+	}
+	r.lastExit = exit
+	if r.cmd == cmd {
+		r.cmd = nil
+	}
+	r.expectExit = false
+	if !exit.Expected {
+		r.crashCount++
+		// A relay that stayed up long enough earns a fresh backoff
+		if time.Since(r.startedAt) >= r.stableAfter {
+			r.backoff = r.minBackoff
+		}
+	}
+	supervised := r.supervised
+	close(done)
+	r.mu.Unlock()
+
+	if exit.Expected {
+		slog.Info("Relay stopped", "pid", cmd.Process.Pid)
+		return
+	}
+
+	slog.Error("Relay exited unexpectedly", "pid", cmd.Process.Pid, "exit_code", exit.Code, "error", exit.Error, "uptime_seconds", exit.Uptime)
+	if supervised {
+		r.scheduleRestart()
+	}
+}
+
+// scheduleRestart starts the relay again after the current backoff,
+// doubling the backoff for the next attempt.
+func (r *Relay) scheduleRestart() {
+	r.mu.Lock()
+	if !r.supervised {
+		r.mu.Unlock()
+		return
+	}
+	delay := r.backoff
+	r.backoff = nextBackoff(r.backoff, r.minBackoff, r.maxBackoff)
+	r.nextRestart = time.Now().Add(delay)
+	stopCh := r.stopCh
+	r.mu.Unlock()
+
+	slog.Info("Restarting relay after backoff", "delay", delay.String())
+
+	go func() {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(delay):
+		}
+
+		r.mu.Lock()
+		r.nextRestart = time.Time{}
+		if !r.supervised || r.cmd != nil {
+			// Shut down, or already restarted manually
+			r.mu.Unlock()
+			return
+		}
+		r.restartCount++
+		r.mu.Unlock()
+
+		if err := r.Start(); err != nil {
+			slog.Error("Failed to restart relay", "error", err)
+			r.scheduleRestart()
+		}
+	}()
+}
+
+// nextBackoff doubles the backoff within [min, max].
+func nextBackoff(current, min, max time.Duration) time.Duration {
+	if current < min {
+		return min
+	}
+	next := current * 2
+	if next > max {
+		return max
+	}
+	return next
+}
+
+// stopChild stops the child process started by Roostr and waits for it to
+// exit. It reports false if there is no child to stop.
+func (r *Relay) stopChild() bool {
+	r.mu.Lock()
+	cmd, done := r.cmd, r.childDone
+	if cmd == nil {
+		r.mu.Unlock()
+		return false
+	}
+	r.expectExit = true
+	r.mu.Unlock()
+
+	// Send SIGTERM for graceful shutdown
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		slog.Warn("Failed to send SIGTERM to relay", "error", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		// Force kill if graceful shutdown takes too long
+		cmd.Process.Kill()
+		<-done
+	}
+	return true
+}
+
+// childPID returns the PID of the child process, or 0 if there is none.
+func (r *Relay) childPID() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cmd == nil || r.cmd.Process == nil {
+		return 0
+	}
+	return r.cmd.Process.Pid
+}
+
+// childUptime returns how long the child process has been running.
+func (r *Relay) childUptime() (time.Duration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cmd == nil {
+		return 0, false
+	}
+	return time.Since(r.startedAt), true
+}
+
+// signalPID sends sig to the process with the given PID.
+func signalPID(pid int, sig os.Signal) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("failed to find process %d: %w", pid, err)
+	}
+	if err := process.Signal(sig); err != nil {
+		return fmt.Errorf("failed to send %v to relay: %w", sig, err)
+	}
+	return nil
+}
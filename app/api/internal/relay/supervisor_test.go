@@ -0,0 +1,113 @@
+package relay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeScript writes an executable shell script standing in for the relay binary.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fake-relay")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	return path
+}
+
+// newTestRelay returns a Relay that ignores relay processes outside the test.
+func newTestRelay(t *testing.T, script string) *Relay {
+	r := New(writeScript(t, script), "")
+	r.findPID = func() (int, error) { return 0, nil }
+	return r
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestSupervise_RestartsAfterCrash(t *testing.T) {
+	r := newTestRelay(t, "echo started\nexit 3")
+	r.minBackoff = 10 * time.Millisecond
+	r.maxBackoff = 40 * time.Millisecond
+	defer r.Shutdown()
+
+	if err := r.Supervise(); err != nil {
+		t.Fatalf("Supervise failed: %v", err)
+	}
+
+	waitFor(t, "two automatic restarts", func() bool {
+		return r.SupervisorStatus().RestartCount >= 2
+	})
+
+	status := r.SupervisorStatus()
+	if !status.Supervised || status.CrashCount < 2 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if status.LastExit == nil || status.LastExit.Code != 3 || status.LastExit.Expected {
+		t.Errorf("expected unexpected exit with code 3, got %+v", status.LastExit)
+	}
+	if len(r.GetRecentLogs(1)) == 0 || r.GetRecentLogs(1)[0].Message != "started" {
+		t.Errorf("expected relay output in log buffer, got %v", r.GetRecentLogs(1))
+	}
+}
+
+func TestSupervise_StopIsNotACrash(t *testing.T) {
+	r := newTestRelay(t, "exec sleep 30")
+	r.minBackoff = 10 * time.Millisecond
+
+	if err := r.Supervise(); err != nil {
+		t.Fatalf("Supervise failed: %v", err)
+	}
+	if pid := r.GetPID(); pid == 0 || !r.IsRunning() {
+		t.Fatalf("expected running child, pid=%d", pid)
+	}
+
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	status := r.SupervisorStatus()
+	if status.CrashCount != 0 || status.RestartCount != 0 || status.NextRestartAt != nil {
+		t.Errorf("expected no crash or restart after Stop, got %+v", status)
+	}
+	if status.LastExit == nil || !status.LastExit.Expected {
+		t.Errorf("expected an expected exit, got %+v", status.LastExit)
+	}
+	if r.childPID() != 0 {
+		t.Error("expected child to be gone")
+	}
+
+	r.Shutdown()
+	if r.IsSupervised() {
+		t.Error("expected supervision to end after Shutdown")
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	tests := []struct {
+		current, want time.Duration
+	}{
+		{0, time.Second},
+		{time.Second, 2 * time.Second},
+		{30 * time.Second, time.Minute},
+		{time.Minute, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := nextBackoff(tt.current, time.Second, time.Minute); got != tt.want {
+			t.Errorf("nextBackoff(%v) = %v, want %v", tt.current, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Package secrets encrypts credentials stored in the app database, such as
+// the Lightning macaroon, with a key derived from an operator passphrase.
+// The key is only ever held in memory; until the vault is unlocked, stored
+// secrets cannot be read.
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"strings"
+	"sync"
+)
+
+// Encrypted values are stored as the prefix followed by the base64 nonce and
+// AES-256-GCM ciphertext. The name of the field is authenticated, so a value
+// cannot be moved to another column.
+const (
+	encryptedPrefix = "enc:v1:"
+	saltSize        = 16
+	checkValue      = "roostr-secrets"
+)
+
+// MinPassphraseLength is the shortest passphrase accepted by Setup.
+const MinPassphraseLength = 12
+
+// KDFIterations is the PBKDF2-HMAC-SHA256 work factor for new vaults.
+// Unlocking reads it from the saved parameters.
+var KDFIterations = 600000
+
+var (
+	// ErrLocked is returned when reading or writing a secret while the
+	// vault is locked.
+	ErrLocked = errors.New("secrets are locked")
+
+	// ErrWrongPassphrase is returned when unlocking with a passphrase that
+	// does not match the one the vault was set up with.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+
+	// ErrNotConfigured is returned when unlocking before a passphrase is set.
+	ErrNotConfigured = errors.New("no secrets passphrase is set")
+
+	// ErrAlreadyConfigured is returned when setting up a vault twice.
+	ErrAlreadyConfigured = errors.New("a secrets passphrase is already set")
+
+	// ErrPassphraseTooShort is returned by Setup for short passphrases.
+	ErrPassphraseTooShort = errors.New("passphrase must be at least 12 characters")
+)
+
+// Params are the key derivation parameters saved with the vault. Check is a
+// known value sealed with the key, so a wrong passphrase is detected.
+type Params struct {
+	Salt       []byte `json:"salt"`
+	Iterations int    `json:"iterations"`
+	Check      string `json:"check"`
+}
+
+// Vault holds the encryption key while unlocked. A vault without params has
+// not been set up, and stores secrets as given.
+type Vault struct {
+	mu     sync.RWMutex
+	params *Params
+	aead   cipher.AEAD // nil while locked
+}
+
+// NewVault returns a vault that has not been set up.
+func NewVault() *Vault {
+	return &Vault{}
+}
+
+// Load sets the saved parameters and locks the vault.
+func (v *Vault) Load(params *Params) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.params = params
+	v.aead = nil
+}
+
+// Setup derives a key from a new passphrase and unlocks the vault with it,
+// returning the parameters to save.
+func (v *Vault) Setup(passphrase string) (*Params, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, ErrPassphraseTooShort
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.params != nil {
+		return nil, ErrAlreadyConfigured
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, KDFIterations)
+	if err != nil {
+		return nil, err
+	}
+	check, err := seal(aead, "check", checkValue)
+	if err != nil {
+		return nil, err
+	}
+
+	v.params = &Params{Salt: salt, Iterations: KDFIterations, Check: check}
+	v.aead = aead
+	return v.params, nil
+}
+
+// Unlock derives the key from passphrase and keeps it in memory.
+func (v *Vault) Unlock(passphrase string) error {
+	v.mu.RLock()
+	params := v.params
+	v.mu.RUnlock()
+	if params == nil {
+		return ErrNotConfigured
+	}
+	if params.Iterations <= 0 {
+		return ErrWrongPassphrase
+	}
+
+	aead, err := newAEAD(passphrase, params.Salt, params.Iterations)
+	if err != nil {
+		return err
+	}
+	if check, err := open(aead, "check", params.Check); err != nil || check != checkValue {
+		return ErrWrongPassphrase
+	}
+
+	v.mu.Lock()
+	v.aead = aead
+	v.mu.Unlock()
+	return nil
+}
+
+// Lock forgets the key.
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.aead = nil
+}
+
+// Configured reports whether a passphrase has been set.
+func (v *Vault) Configured() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.params != nil
+}
+
+// Locked reports whether the vault is set up but its key is not in memory.
+func (v *Vault) Locked() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.params != nil && v.aead == nil
+}
+
+// Seal encrypts the value of a field for storage. Empty values and values
+// in a vault that has not been set up are stored as given.
+func (v *Vault) Seal(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.params == nil {
+		return value, nil
+	}
+	if v.aead == nil {
+		return "", ErrLocked
+	}
+	return seal(v.aead, field, value)
+}
+
+// Open decrypts a stored field value. Values stored before the vault was set
+// up are returned as they are.
+func (v *Vault) Open(field, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.aead == nil {
+		return "", ErrLocked
+	}
+	return open(v.aead, field, value)
+}
+
+// IsEncrypted reports whether a stored value was sealed by a vault.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+func seal(aead cipher.AEAD, field, value string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(field))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func open(aead cipher.AEAD, field, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return "", errors.New("failed to decrypt " + field)
+	}
+	return string(plain), nil
+}
+
+// newAEAD derives the AES-256-GCM key from a passphrase.
+func newAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key := PBKDF2SHA256([]byte(passphrase), salt, iterations, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PBKDF2SHA256 derives a key of keyLen bytes as in RFC 8018 with
+// HMAC-SHA256.
+func PBKDF2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocks := (keyLen + sha256.Size - 1) / sha256.Size
+	key := make([]byte, 0, blocks*sha256.Size)
+	u := make([]byte, sha256.Size)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, uint32(block)))
+		u = prf.Sum(u[:0])
+		t := bytes.Clone(u)
+		for i := 1; i < iterations; i++ {
+			u = pbkdf2Round(prf, u)
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// pbkdf2Round computes the next U value of PBKDF2.
+func pbkdf2Round(prf hash.Hash, u []byte) []byte {
+	prf.Reset()
+	prf.Write(u)
+	return prf.Sum(u[:0])
+}
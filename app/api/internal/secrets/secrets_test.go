@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// fastKDF lowers the work factor for the test.
+func fastKDF(t *testing.T) {
+	old := KDFIterations
+	KDFIterations = 1000
+	t.Cleanup(func() { KDFIterations = old })
+}
+
+func TestVault(t *testing.T) {
+	fastKDF(t)
+	v := NewVault()
+
+	// Before setup values are stored as given
+	stored, err := v.Seal("lightning_config.macaroon", "0201abcd")
+	if err != nil || stored != "0201abcd" {
+		t.Fatalf("Seal() before setup = %q, %v", stored, err)
+	}
+
+	if _, err := v.Setup("short"); !errors.Is(err, ErrPassphraseTooShort) {
+		t.Errorf("expected ErrPassphraseTooShort, got %v", err)
+	}
+	params, err := v.Setup("correct horse battery")
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if _, err := v.Setup("correct horse battery"); !errors.Is(err, ErrAlreadyConfigured) {
+		t.Errorf("expected ErrAlreadyConfigured, got %v", err)
+	}
+
+	sealed, err := v.Seal("lightning_config.macaroon", "0201abcd")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if !IsEncrypted(sealed) {
+		t.Fatalf("expected an encrypted value, got %q", sealed)
+	}
+	if got, err := v.Open("lightning_config.macaroon", sealed); err != nil || got != "0201abcd" {
+		t.Errorf("Open() = %q, %v", got, err)
+	}
+	if _, err := v.Open("lightning_config.cert", sealed); err == nil {
+		t.Error("expected a value moved to another field to fail")
+	}
+	if got, _ := v.Open("lightning_config.macaroon", "plain"); got != "plain" {
+		t.Errorf("expected plaintext to pass through, got %q", got)
+	}
+
+	// A restarted vault is locked until unlocked with the same passphrase
+	restarted := NewVault()
+	restarted.Load(params)
+	if !restarted.Locked() {
+		t.Fatal("expected loaded vault to be locked")
+	}
+	if _, err := restarted.Open("lightning_config.macaroon", sealed); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if _, err := restarted.Seal("lightning_config.macaroon", "x"); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if err := restarted.Unlock("wrong passphrase!"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := restarted.Unlock("correct horse battery"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if got, err := restarted.Open("lightning_config.macaroon", sealed); err != nil || got != "0201abcd" {
+		t.Errorf("Open() after unlock = %q, %v", got, err)
+	}
+
+	restarted.Lock()
+	if !restarted.Locked() {
+		t.Error("expected vault to be locked again")
+	}
+}
+
+func TestUnlockNotConfigured(t *testing.T) {
+	if err := NewVault().Unlock("correct horse battery"); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11 test vector
+	got := PBKDF2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if hex.EncodeToString(got) != want {
+		t.Errorf("unexpected key %x", got)
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// ErrInvalidAccessChange is returned for an access change with an unknown
+// mode or a pubkey that is not hex or npub.
+var ErrInvalidAccessChange = errors.New("invalid access change")
+
+// AccessChange is a hypothetical change to the access settings. Pubkeys may
+// be hex or npub.
+type AccessChange struct {
+	Mode            string   `json:"mode"` // Empty keeps the current mode
+	WhitelistAdd    []string `json:"whitelist_add"`
+	WhitelistRemove []string `json:"whitelist_remove"`
+	BlacklistAdd    []string `json:"blacklist_add"`
+	BlacklistRemove []string `json:"blacklist_remove"`
+}
+
+// SimulatedAuthor is an author whose posting rights would change, with the
+// events it has stored.
+type SimulatedAuthor struct {
+	Pubkey string `json:"pubkey"`
+	Events int64  `json:"events"`
+}
+
+// AccessSimulation is what an access change would do to the authors with
+// events on the relay.
+type AccessSimulation struct {
+	CurrentMode   string            `json:"current_mode"`
+	Mode          string            `json:"mode"`
+	Authors       int64             `json:"authors"` // Authors with stored events
+	AllowedBefore int64             `json:"allowed_before"`
+	AllowedAfter  int64             `json:"allowed_after"`
+	Gain          []SimulatedAuthor `json:"gain"`
+	GainCount     int64             `json:"gain_count"`
+	Lose          []SimulatedAuthor `json:"lose"`
+	LoseCount     int64             `json:"lose_count"`
+	LoseEvents    int64             `json:"lose_events"` // Stored events of the authors who would lose access
+	NewPubkeys    int64             `json:"new_pubkeys"` // Whitelisted pubkeys with no stored events yet
+	Truncated     bool              `json:"truncated"`   // Gain or lose was cut to the limit
+}
+
+// accessPolicy decides who may post, the way the admission server does.
+type accessPolicy struct {
+	mode      string
+	operator  string
+	members   map[string]bool // Whitelist
+	grants    map[string]bool // Follow grants
+	blacklist map[string]bool
+	// Without the admission server, open mode writes no blacklist to
+	// config.toml, so nostr-rs-relay does not enforce it
+	openBlacklist bool
+}
+
+func (p *accessPolicy) allows(pubkey string) bool {
+	if pubkey == p.operator {
+		return true
+	}
+	if p.blacklist[pubkey] && (p.mode != "open" || p.openBlacklist) {
+		return false
+	}
+	switch p.mode {
+	case "open", "blacklist":
+		return true
+	}
+	// Whitelist and paid modes; paid users are added to the whitelist
+	return p.members[pubkey] || p.grants[pubkey]
+}
+
+// clone returns a copy of the policy that can be changed independently.
+func (p *accessPolicy) clone() *accessPolicy {
+	c := *p
+	c.members, c.blacklist = make(map[string]bool, len(p.members)), make(map[string]bool, len(p.blacklist))
+	for pk := range p.members {
+		c.members[pk] = true
+	}
+	for pk := range p.blacklist {
+		c.blacklist[pk] = true
+	}
+	return &c
+}
+
+// SimulateAccessChange reports which authors with stored events would gain
+// or lose the right to post if change were applied, and how many events
+// those who lose it have stored, without changing anything. delegated
+// reports whether the admission server enforces access. Follow grants are
+// taken as they are now. The gain and lose lists hold up to limit authors
+// each, those with the most events first.
+func SimulateAccessChange(ctx context.Context, database *db.DB, change AccessChange, delegated bool, limit int) (*AccessSimulation, error) {
+	var err error
+	current := &accessPolicy{
+		members:       make(map[string]bool),
+		grants:        make(map[string]bool),
+		blacklist:     make(map[string]bool),
+		openBlacklist: delegated,
+	}
+	if current.mode, err = database.GetAccessMode(ctx); err != nil {
+		return nil, err
+	}
+	current.operator, _ = database.GetAppState(ctx, "operator_pubkey")
+
+	members, err := database.GetWhitelistMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		current.members[m.Pubkey] = true
+	}
+	grants, err := database.GetFollowGrants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range grants {
+		current.grants[g.Pubkey] = true
+	}
+	blacklist, err := database.GetBlacklist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range blacklist {
+		current.blacklist[e.Pubkey] = true
+	}
+
+	proposed := current.clone()
+	if change.Mode != "" {
+		switch change.Mode {
+		case "open", "whitelist", "paid", "blacklist":
+			proposed.mode = change.Mode
+		default:
+			return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidAccessChange, change.Mode)
+		}
+	}
+	for _, edit := range []struct {
+		pubkeys []string
+		set     map[string]bool
+		add     bool
+	}{
+		{change.WhitelistAdd, proposed.members, true},
+		{change.WhitelistRemove, proposed.members, false},
+		{change.BlacklistAdd, proposed.blacklist, true},
+		{change.BlacklistRemove, proposed.blacklist, false},
+	} {
+		for _, input := range edit.pubkeys {
+			pubkey, _, err := nostr.ValidatePubkey(input)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not a hex or npub pubkey", ErrInvalidAccessChange, input)
+			}
+			if edit.add {
+				edit.set[pubkey] = true
+			} else {
+				delete(edit.set, pubkey)
+			}
+		}
+	}
+
+	counts, err := database.GetAuthorEventCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &AccessSimulation{
+		CurrentMode: current.mode,
+		Mode:        proposed.mode,
+		Authors:     int64(len(counts)),
+		Gain:        []SimulatedAuthor{},
+		Lose:        []SimulatedAuthor{},
+	}
+	for pubkey, events := range counts {
+		before, after := current.allows(pubkey), proposed.allows(pubkey)
+		if before {
+			sim.AllowedBefore++
+		}
+		if after {
+			sim.AllowedAfter++
+		}
+		switch {
+		case after && !before:
+			sim.Gain = append(sim.Gain, SimulatedAuthor{Pubkey: pubkey, Events: events})
+		case before && !after:
+			sim.Lose = append(sim.Lose, SimulatedAuthor{Pubkey: pubkey, Events: events})
+			sim.LoseEvents += events
+		}
+	}
+	for pubkey := range proposed.members {
+		if _, ok := counts[pubkey]; !ok && !current.members[pubkey] {
+			sim.NewPubkeys++
+		}
+	}
+
+	sim.GainCount, sim.LoseCount = int64(len(sim.Gain)), int64(len(sim.Lose))
+	for _, list := range []*[]SimulatedAuthor{&sim.Gain, &sim.Lose} {
+		authors := *list
+		sort.Slice(authors, func(i, j int) bool {
+			if authors[i].Events != authors[j].Events {
+				return authors[i].Events > authors[j].Events
+			}
+			return authors[i].Pubkey < authors[j].Pubkey
+		})
+		if limit > 0 && len(authors) > limit {
+			*list = authors[:limit]
+			sim.Truncated = true
+		}
+	}
+	return sim, nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestSimulateAccessChange(t *testing.T) {
+	ctx := context.Background()
+	database, relayDB := setupTestDBWithRelay(t)
+
+	alice, bob, carol, dave, spammer := strings.Repeat("a1", 32), strings.Repeat("b2", 32), strings.Repeat("c3", 32), strings.Repeat("d4", 32), strings.Repeat("e5", 32)
+	n := byte(0)
+	for author, events := range map[string]int{alice: 3, bob: 1, carol: 2, dave: 1, spammer: 4} {
+		for i := 0; i < events; i++ {
+			n++
+			insertKindTestEvent(t, relayDB, n, author, 1)
+		}
+	}
+	database.SetAccessMode(ctx, "open")
+	if err := database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: alice, Npub: "npub1alice"}); err != nil {
+		t.Fatalf("AddWhitelistEntry failed: %v", err)
+	}
+	if _, _, err := database.ReplaceFollowGrants(ctx, []db.FollowGrant{{Pubkey: bob, Followers: 1}}); err != nil {
+		t.Fatalf("ReplaceFollowGrants failed: %v", err)
+	}
+	if err := database.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: spammer, Npub: "npub1spam"}); err != nil {
+		t.Fatalf("AddBlacklistEntry failed: %v", err)
+	}
+
+	// Switching to whitelist mode shuts out everyone without a whitelist
+	// entry or follow grant, except dave who is added by npub
+	daveNpub, _ := nostr.EncodeNpub(dave)
+	newcomer := strings.Repeat("f6", 32)
+	sim, err := SimulateAccessChange(ctx, database, AccessChange{Mode: "whitelist", WhitelistAdd: []string{daveNpub, newcomer}}, true, 10)
+	if err != nil {
+		t.Fatalf("SimulateAccessChange failed: %v", err)
+	}
+	if sim.CurrentMode != "open" || sim.Authors != 5 || sim.AllowedBefore != 4 || sim.AllowedAfter != 3 {
+		t.Errorf("unexpected summary: %+v", sim)
+	}
+	if fmt.Sprint(sim.Lose) != fmt.Sprint([]SimulatedAuthor{{carol, 2}}) || sim.LoseEvents != 2 || len(sim.Gain) != 0 || sim.NewPubkeys != 1 {
+		t.Errorf("expected carol to lose access and one new pubkey, got %+v", sim)
+	}
+
+	sim, _ = SimulateAccessChange(ctx, database, AccessChange{Mode: "paid"}, true, 1)
+	if sim.LoseCount != 2 || len(sim.Lose) != 1 || sim.Lose[0].Pubkey != carol || !sim.Truncated || sim.LoseEvents != 3 {
+		t.Errorf("expected the list cut to carol of two, got %+v", sim)
+	}
+
+	// Without the admission server open mode doesn't enforce the blacklist,
+	// so blacklist mode shuts out the spammer
+	sim, _ = SimulateAccessChange(ctx, database, AccessChange{Mode: "blacklist"}, false, 10)
+	if fmt.Sprint(sim.Lose) != fmt.Sprint([]SimulatedAuthor{{spammer, 4}}) || sim.AllowedBefore != 5 {
+		t.Errorf("expected the spammer to lose access, got %+v", sim)
+	}
+	sim, _ = SimulateAccessChange(ctx, database, AccessChange{BlacklistRemove: []string{spammer}}, true, 10)
+	if fmt.Sprint(sim.Gain) != fmt.Sprint([]SimulatedAuthor{{spammer, 4}}) || sim.Mode != "open" {
+		t.Errorf("expected the spammer to gain access, got %+v", sim)
+	}
+
+	if mode, _ := database.GetAccessMode(ctx); mode != "open" {
+		t.Errorf("expected the access mode unchanged, got %s", mode)
+	}
+	if _, err := SimulateAccessChange(ctx, database, AccessChange{Mode: "invite"}, true, 10); !errors.Is(err, ErrInvalidAccessChange) {
+		t.Errorf("expected ErrInvalidAccessChange for an unknown mode, got %v", err)
+	}
+	if _, err := SimulateAccessChange(ctx, database, AccessChange{BlacklistAdd: []string{"nope"}}, true, 10); !errors.Is(err, ErrInvalidAccessChange) {
+		t.Errorf("expected ErrInvalidAccessChange for a bad pubkey, got %v", err)
+	}
+}
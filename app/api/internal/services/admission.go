@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nauthz"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// admissionStateTTL is how long the access state is cached between
+// events. Changes made through the API take effect at once, since they
+// invalidate the cache.
+const admissionStateTTL = 10 * time.Second
+
+// AdmissionURL returns the URL the relay reaches the admission server at
+// when it listens on listenAddr. A listen address without a host is
+// reached over loopback.
+func AdmissionURL(listenAddr string) string {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "http://" + listenAddr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// ConfigureAdmission points config.toml at the admission server at url, or
+// when url is empty, removes a server set earlier and writes the access
+// lists back, so the relay enforces them itself again. It reports whether
+// the file changed, in which case the relay must be restarted.
+func ConfigureAdmission(ctx context.Context, database *db.DB, configMgr *relay.ConfigManager, url string) (bool, error) {
+	configMgr.SetAdmissionServer(url)
+
+	cfg, err := configMgr.Read()
+	if err != nil {
+		return false, err
+	}
+	if cfg.GRPC.EventAdmissionServer == url {
+		return false, nil
+	}
+
+	reason := "Event admission server enabled"
+	if url == "" {
+		reason = "Event admission server disabled"
+		cfg.GRPC = relay.GRPCConfig{}
+		whitelist, blacklist, err := AccessLists(ctx, database)
+		if err != nil {
+			return false, err
+		}
+		cfg.Authorization.PubkeyWhitelist = whitelist
+		cfg.Authorization.PubkeyBlacklist = blacklist
+	}
+	return true, configMgr.WriteAs(cfg, "roostr", reason)
+}
+
+// admissionState is the access state events are checked against.
+type admissionState struct {
+	mode      string
+	operator  string
+	whitelist map[string]bool
+	blacklist map[string]bool
+	kinds     *KindPolicySet
+	loadedAt  time.Time
+}
+
+// AdmissionStats counts the admission decisions since startup.
+type AdmissionStats struct {
+	Enabled   bool   `json:"enabled"`
+	URL       string `json:"url,omitempty"`
+	Permitted int64  `json:"permitted"`
+	Denied    int64  `json:"denied"`
+}
+
+// AdmissionService is the event admission server nostr-rs-relay asks about
+// every event it receives. It enforces the blacklist, the whitelist in
+// whitelist and paid modes, the per-tier kind policies and quotas, IP bans
+// and the per-author event rate limit as they are in the DB, so access
+// changes need no config.toml rewrite or relay restart.
+type AdmissionService struct {
+	db        *db.DB
+	rateLimit *RateLimitService
+	quotas    *QuotaService
+	now       func() time.Time
+
+	listenAddr string
+	server     *nauthz.Server
+	running    bool
+	mu         sync.Mutex
+
+	stateMu sync.Mutex
+	state   *admissionState
+
+	permitted atomic.Int64
+	denied    atomic.Int64
+}
+
+// NewAdmissionService creates a new admission service.
+func NewAdmissionService(database *db.DB, rateLimit *RateLimitService) *AdmissionService {
+	return &AdmissionService{
+		db:        database,
+		rateLimit: rateLimit,
+		now:       time.Now,
+	}
+}
+
+// Configure sets the address to serve on; empty disables the server.
+func (s *AdmissionService) Configure(listenAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listenAddr = listenAddr
+}
+
+// IsEnabled returns whether the admission server is configured.
+func (s *AdmissionService) IsEnabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listenAddr != ""
+}
+
+// Start begins serving if a listen address is configured.
+func (s *AdmissionService) Start() {
+	s.mu.Lock()
+	if s.running || s.listenAddr == "" {
+		s.mu.Unlock()
+		return
+	}
+
+	listener, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		s.mu.Unlock()
+		slog.Error("Admission server: failed to listen", "addr", s.listenAddr, "error", err)
+		return
+	}
+
+	s.server = nauthz.NewServer(s)
+	s.running = true
+	server := s.server
+	s.mu.Unlock()
+
+	go server.Serve(listener)
+	slog.Info("Admission server listening", "addr", s.listenAddr)
+}
+
+// Stop closes the server and its connections.
+func (s *AdmissionService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	server := s.server
+	s.mu.Unlock()
+
+	server.Close()
+	slog.Info("Admission server stopped")
+}
+
+// Invalidate drops the cached access state, so the next event is checked
+// against the DB as it is now.
+func (s *AdmissionService) Invalidate() {
+	s.stateMu.Lock()
+	s.state = nil
+	s.stateMu.Unlock()
+}
+
+// Stats returns the decisions made since startup.
+func (s *AdmissionService) Stats() AdmissionStats {
+	s.mu.Lock()
+	addr := s.listenAddr
+	s.mu.Unlock()
+
+	stats := AdmissionStats{
+		Enabled:   addr != "",
+		Permitted: s.permitted.Load(),
+		Denied:    s.denied.Load(),
+	}
+	if stats.Enabled {
+		stats.URL = AdmissionURL(addr)
+	}
+	return stats
+}
+
+// EventAdmit decides whether the relay stores an event.
+func (s *AdmissionService) EventAdmit(ctx context.Context, req *nauthz.EventRequest) *nauthz.EventReply {
+	reply := s.decide(ctx, req)
+	if reply.Decision == nauthz.DecisionPermit {
+		s.permitted.Add(1)
+	} else {
+		s.denied.Add(1)
+		slog.Debug("Event denied", "pubkey", req.Event.Pubkey, "kind", req.Event.Kind, "reason", reply.Message)
+	}
+	return reply
+}
+
+func (s *AdmissionService) decide(ctx context.Context, req *nauthz.EventRequest) *nauthz.EventReply {
+	permit := &nauthz.EventReply{Decision: nauthz.DecisionPermit}
+	deny := func(format string, args ...interface{}) *nauthz.EventReply {
+		return &nauthz.EventReply{Decision: nauthz.DecisionDeny, Message: fmt.Sprintf(format, args...)}
+	}
+
+	state, err := s.loadState(ctx)
+	if err != nil {
+		// While admission is delegated config.toml holds no access lists,
+		// so permitting here would open the relay to everyone
+		slog.ErrorContext(ctx, "Admission server: failed to load access state", "error", err)
+		return deny("error: could not check access, try again later")
+	}
+
+	pubkey := req.Event.Pubkey
+	if pubkey == state.operator {
+		return permit
+	}
+
+	if s.rateLimit != nil && req.IPAddr != "" {
+		if addr, err := netip.ParseAddr(strings.Trim(req.IPAddr, "[]")); err == nil && s.rateLimit.IsBanned(addr) {
+			return deny("blocked: your IP address is banned")
+		}
+	}
+	if state.blacklist[pubkey] {
+		return deny("blocked: pubkey is blacklisted")
+	}
+	switch state.mode {
+	case "whitelist":
+		if !state.whitelist[pubkey] {
+			return deny("restricted: pubkey is not on this relay's whitelist")
+		}
+	case "paid":
+		if !state.whitelist[pubkey] {
+			return deny("restricted: this relay requires paid access")
+		}
+	}
+	if ok, tier := state.kinds.Permits(pubkey, req.Event.Kind); !ok {
+		return deny("restricted: kind %d is not allowed for the %s tier", req.Event.Kind, tier)
+	}
+	if s.quotas != nil {
+		reached := s.quotas.Reached(pubkey)
+		if slices.Contains(reached, db.QuotaStorage) {
+			return deny("blocked: storage quota used up")
+		}
+		if slices.Contains(reached, db.QuotaEventsPerDay) {
+			return deny("rate-limited: daily event quota reached, try again after midnight UTC")
+		}
+	}
+	if s.rateLimit != nil {
+		if decision := s.rateLimit.CheckAuthor(pubkey); !decision.Allowed {
+			return deny("rate-limited: slow down, try again in %d seconds", int(decision.RetryAfter.Seconds())+1)
+		}
+	}
+	return permit
+}
+
+// loadState returns the cached access state, reading it from the DB when
+// it is missing or stale.
+func (s *AdmissionService) loadState(ctx context.Context) (*admissionState, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	now := s.now()
+	if s.state != nil && now.Sub(s.state.loadedAt) < admissionStateTTL {
+		return s.state, nil
+	}
+
+	state := &admissionState{
+		whitelist: make(map[string]bool),
+		blacklist: make(map[string]bool),
+		loadedAt:  now,
+	}
+	var err error
+	if state.mode, err = s.db.GetAccessMode(ctx); err != nil {
+		return nil, err
+	}
+	state.operator, _ = s.db.GetAppState(ctx, "operator_pubkey")
+
+	members, err := s.db.GetWhitelistMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		state.whitelist[m.Pubkey] = true
+	}
+	grants, err := s.db.GetFollowGrants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range grants {
+		state.whitelist[g.Pubkey] = true
+	}
+	blacklist, err := s.db.GetBlacklist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range blacklist {
+		state.blacklist[e.Pubkey] = true
+	}
+	if state.kinds, err = LoadKindPolicySet(ctx, s.db); err != nil {
+		return nil, err
+	}
+
+	s.state = state
+	return state, nil
+}
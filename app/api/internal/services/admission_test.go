@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nauthz"
+)
+
+func TestAdmissionURL(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1:50051": "http://127.0.0.1:50051",
+		":50051":          "http://127.0.0.1:50051",
+		"0.0.0.0:50051":   "http://127.0.0.1:50051",
+		"[::1]:50051":     "http://[::1]:50051",
+	}
+	for addr, want := range tests {
+		if got := AdmissionURL(addr); got != want {
+			t.Errorf("AdmissionURL(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestAdmissionService_EventAdmit(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	member := strings.Repeat("aa", 32)
+	paid := strings.Repeat("bb", 32)
+	banned := strings.Repeat("cc", 32)
+	stranger := strings.Repeat("dd", 32)
+	operator := strings.Repeat("ee", 32)
+
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: member})
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: paid})
+	database.AddPaidUser(ctx, db.PaidUser{Pubkey: paid, Tier: "monthly", Status: "active"})
+	database.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: banned})
+	database.SetAppState(ctx, "operator_pubkey", operator)
+	database.SetAccessMode(ctx, "whitelist")
+	database.SetKindPolicies(ctx, []db.KindPolicy{
+		{Tier: db.KindPolicyTierWhitelist, AllowKinds: []int{0, 1, 3, 7}},
+	})
+
+	rateLimit := NewRateLimitService(database)
+	if err := rateLimit.Ban(ctx, "192.0.2.0/24", "test", IPBanManual, nil); err != nil {
+		t.Fatalf("Ban failed: %v", err)
+	}
+	rateLimit.settings.Events = db.RateLimit{PerMinute: 60, Burst: 2}
+	svc := NewAdmissionService(database, rateLimit)
+
+	admit := func(pubkey string, kind int, ip string) *nauthz.EventReply {
+		return svc.EventAdmit(ctx, &nauthz.EventRequest{
+			Event:  nauthz.Event{Pubkey: pubkey, Kind: kind},
+			IPAddr: ip,
+		})
+	}
+
+	tests := []struct {
+		name    string
+		pubkey  string
+		kind    int
+		ip      string
+		permit  bool
+		message string
+	}{
+		{"member note", member, 1, "", true, ""},
+		{"member long-form", member, 30023, "", false, "restricted: kind 30023"},
+		{"paid member long-form", paid, 30023, "", true, ""},
+		{"blacklisted", banned, 1, "", false, "blocked:"},
+		{"not whitelisted", stranger, 1, "", false, "restricted:"},
+		{"banned IP", member, 1, "192.0.2.7", false, "blocked:"},
+		{"operator", operator, 30023, "192.0.2.7", true, ""},
+		{"member note again", member, 1, "", true, ""},
+		{"member over rate limit", member, 1, "", false, "rate-limited:"},
+	}
+	for _, tt := range tests {
+		reply := admit(tt.pubkey, tt.kind, tt.ip)
+		if (reply.Decision == nauthz.DecisionPermit) != tt.permit || !strings.HasPrefix(reply.Message, tt.message) {
+			t.Errorf("%s: got %+v", tt.name, reply)
+		}
+	}
+
+	// Access changes apply once the cached state is invalidated
+	database.SetAccessMode(ctx, "open")
+	if reply := admit(stranger, 1, ""); reply.Decision != nauthz.DecisionDeny {
+		t.Errorf("expected the cached mode to still apply, got %+v", reply)
+	}
+	svc.Invalidate()
+	if reply := admit(stranger, 1, ""); reply.Decision != nauthz.DecisionPermit {
+		t.Errorf("expected stranger permitted in open mode, got %+v", reply)
+	}
+
+	// The cache also expires on its own
+	database.SetAccessMode(ctx, "whitelist")
+	svc.now = func() time.Time { return time.Now().Add(admissionStateTTL) }
+	if reply := admit(stranger, 1, ""); reply.Decision != nauthz.DecisionDeny {
+		t.Errorf("expected stranger denied after the cache expired, got %+v", reply)
+	}
+
+	stats := svc.Stats()
+	if stats.Enabled || stats.Permitted != 5 || stats.Denied != 7 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Without the access state even members are refused
+	database.AppDB.Close()
+	svc.Invalidate()
+	if reply := admit(member, 1, ""); reply.Decision != nauthz.DecisionDeny || !strings.HasPrefix(reply.Message, "error:") {
+		t.Errorf("expected a deny when the DB fails, got %+v", reply)
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// AuditService prunes the audit log to the retention window in the audit
+// settings.
+type AuditService struct {
+	db       *db.DB
+	interval time.Duration
+	now      func() time.Time
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// NewAuditService creates a new audit service.
+func NewAuditService(database *db.DB) *AuditService {
+	return &AuditService{
+		db:       database,
+		interval: 24 * time.Hour,
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins pruning the audit log daily.
+func (s *AuditService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops pruning.
+func (s *AuditService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *AuditService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.prune(ctx)
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.prune(ctx)
+		}
+	}
+}
+
+func (s *AuditService) prune(ctx context.Context) {
+	if _, err := s.Prune(ctx); err != nil && ctx.Err() == nil {
+		slog.Warn("Failed to prune audit log", "error", err)
+	}
+}
+
+// Prune deletes audit log entries older than the retention window and
+// returns how many were deleted.
+func (s *AuditService) Prune(ctx context.Context) (int64, error) {
+	settings, err := s.db.GetAuditSettings(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if settings.RetentionDays <= 0 {
+		return 0, nil
+	}
+
+	deleted, err := s.db.DeleteAuditLogBefore(ctx, s.now().AddDate(0, 0, -settings.RetentionDays))
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		slog.Info("Pruned audit log", "deleted", deleted, "retention_days", settings.RetentionDays)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestAuditServicePrune(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	audit := NewAuditService(database)
+
+	database.AddAuditLog(ctx, "whitelist_add", nil, "")
+	database.AddAuditLog(ctx, "whitelist_remove", nil, "")
+	database.AppDB.Exec("UPDATE audit_log SET created_at = ? WHERE action = 'whitelist_add'", time.Now().AddDate(0, 0, -40).Unix())
+
+	// The default window keeps a year
+	if n, err := audit.Prune(ctx); err != nil || n != 0 {
+		t.Fatalf("Prune() = %d, %v; want nothing pruned", n, err)
+	}
+
+	database.SetAuditSettings(ctx, &db.AuditSettings{RetentionDays: 0})
+	if n, _ := audit.Prune(ctx); n != 0 {
+		t.Errorf("expected nothing pruned when kept forever, got %d", n)
+	}
+
+	database.SetAuditSettings(ctx, &db.AuditSettings{RetentionDays: 30})
+	if n, err := audit.Prune(ctx); err != nil || n != 1 {
+		t.Errorf("Prune() = %d, %v; want 1", n, err)
+	}
+	if _, total, _ := database.GetAuditLog(ctx, db.AuditLogFilter{}); total != 1 {
+		t.Errorf("expected 1 entry left, got %d", total)
+	}
+}
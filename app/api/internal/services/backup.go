@@ -0,0 +1,671 @@
+package services
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// File names inside a backup archive.
+const (
+	BackupAppDBFile    = "roostr.db"
+	BackupRelayDBFile  = "nostr.db"
+	BackupConfigFile   = "config.toml"
+	BackupManifestFile = "manifest.json"
+)
+
+const (
+	// backupFilePrefix and backupFileSuffix frame the names of saved backups;
+	// encrypted backups add backupEncryptedSuffix.
+	backupFilePrefix      = "roostr-backup-"
+	backupFileSuffix      = ".tar.gz"
+	backupEncryptedSuffix = ".enc"
+
+	// backupTimeFormat is the timestamp in a saved backup's name.
+	backupTimeFormat = "20060102-150405"
+
+	// backupCheckInterval is how often the scheduler looks for a due backup.
+	backupCheckInterval = 15 * time.Minute
+
+	// backupCatchUp is how long after its scheduled time a missed backup is
+	// still taken, e.g. when Roostr was restarting at the time.
+	backupCatchUp = 6 * time.Hour
+
+	// maxBackupRetain bounds how many scheduled backups can be kept.
+	maxBackupRetain = 365
+)
+
+var (
+	// ErrBackupInProgress is returned when a backup is started while another
+	// is running.
+	ErrBackupInProgress = errors.New("a backup is already running")
+
+	// ErrBackupFileNotFound is returned for a saved backup that does not exist.
+	ErrBackupFileNotFound = errors.New("backup not found")
+
+	// ErrInvalidBackupName is returned for a name that is not a saved
+	// backup's file name.
+	ErrInvalidBackupName = errors.New("invalid backup name")
+
+	// ErrBackupDirNotConfigured is returned when saving or listing backups
+	// without a backup directory.
+	ErrBackupDirNotConfigured = errors.New("backup directory not configured")
+
+	// ErrBackupPassphraseNotSet is returned when encrypting a backup before
+	// a passphrase is set.
+	ErrBackupPassphraseNotSet = errors.New("backup passphrase not set")
+
+	// ErrInvalidBackupSchedule is returned for a schedule with an unknown
+	// frequency or a value out of range.
+	ErrInvalidBackupSchedule = errors.New("invalid backup schedule")
+)
+
+// BackupManifest describes the contents of a backup archive.
+type BackupManifest struct {
+	CreatedAt     time.Time `json:"created_at"`
+	SchemaVersion int       `json:"schema_version"`
+	Files         []string  `json:"files"`
+}
+
+// BackupFile is a backup archive saved in the backup directory.
+type BackupFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Encrypted bool      `json:"encrypted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupSnapshot holds consistent copies of the databases and config, ready
+// to be written as an archive. Close removes the copies.
+type BackupSnapshot struct {
+	Manifest BackupManifest
+	dir      string
+}
+
+// BackupService takes full backups of Roostr: the relay and app databases,
+// copied with SQLite's online backup API while both stay in use, and the
+// relay's config.toml, packed into one tar.gz. Backups are streamed to the
+// caller or saved in the backup directory, by hand or daily or weekly on a
+// schedule that keeps the newest few. Backups can be encrypted with a
+// passphrase.
+type BackupService struct {
+	db         *db.DB
+	dir        string
+	configPath string
+	now        func() time.Time
+	running    bool // A snapshot is being taken
+	mu         sync.Mutex
+
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	scheduled bool // The scheduler is running
+}
+
+// NewBackupService creates a new backup service.
+func NewBackupService(database *db.DB) *BackupService {
+	return &BackupService{
+		db:     database,
+		now:    time.Now,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Configure sets the directory saved backups go to and the relay config file
+// to include.
+func (s *BackupService) Configure(dir, configPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dir = dir
+	s.configPath = configPath
+}
+
+// Start begins the backup scheduler.
+func (s *BackupService) Start() {
+	s.mu.Lock()
+	if s.scheduled {
+		s.mu.Unlock()
+		return
+	}
+	s.scheduled = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the backup scheduler.
+func (s *BackupService) Stop() {
+	s.mu.Lock()
+	if !s.scheduled {
+		s.mu.Unlock()
+		return
+	}
+	s.scheduled = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run is the main loop for the scheduler.
+func (s *BackupService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	slog.Info("Backup scheduler started")
+
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			slog.Info("Backup scheduler stopped")
+			return
+		case <-ticker.C:
+			s.runDue(ctx)
+		}
+	}
+}
+
+// runDue takes a scheduled backup if one is due.
+func (s *BackupService) runDue(ctx context.Context) {
+	schedule, err := s.db.GetBackupSchedule(ctx)
+	if err != nil {
+		slog.Error("Failed to load backup schedule", "error", err)
+		return
+	}
+	if !schedule.Enabled {
+		return
+	}
+
+	var last *time.Time
+	if record, _ := s.db.GetLastScheduledBackup(ctx); record != nil {
+		last = &record.At
+	}
+	if backupDue(schedule, last, s.now()) {
+		s.RunScheduled(ctx)
+	}
+}
+
+// RunScheduled takes a backup as the schedule would, rotates old backups,
+// uploads the backup to the backup targets and records the outcome.
+func (s *BackupService) RunScheduled(ctx context.Context) (*db.BackupRunRecord, error) {
+	record := db.BackupRunRecord{At: s.now(), Status: "ok"}
+
+	backup, deleted, err := s.scheduledBackup(ctx)
+	if backup != nil {
+		record.Name = backup.Name
+		record.Size = backup.Size
+	}
+	record.Deleted = deleted
+	if err != nil {
+		record.Status = "failed"
+		record.Error = err.Error()
+		slog.Error("Scheduled backup failed", "error", err)
+	}
+
+	if backup != nil {
+		uploads, uploadErr := s.Upload(ctx, backup.Name)
+		for _, upload := range uploads {
+			if upload.Status == "completed" {
+				record.Uploaded++
+				continue
+			}
+			record.UploadFailures++
+			if uploadErr == nil {
+				uploadErr = errors.New(upload.Error)
+			}
+		}
+		if uploadErr != nil && err == nil {
+			record.Status = "partial"
+			record.Error = "upload failed: " + uploadErr.Error()
+			err = uploadErr
+		}
+	}
+
+	if dbErr := s.db.SetLastScheduledBackup(context.WithoutCancel(ctx), record); dbErr != nil {
+		slog.Error("Failed to record scheduled backup", "error", dbErr)
+	}
+	s.db.AddAuditLog(context.WithoutCancel(ctx), "backup_scheduled", map[string]interface{}{
+		"name":            record.Name,
+		"status":          record.Status,
+		"deleted":         record.Deleted,
+		"uploaded":        record.Uploaded,
+		"upload_failures": record.UploadFailures,
+	}, "")
+	return &record, err
+}
+
+// scheduledBackup saves a backup with the schedule's encryption and rotates
+// old backups.
+func (s *BackupService) scheduledBackup(ctx context.Context) (*BackupFile, int, error) {
+	schedule, err := s.db.GetBackupSchedule(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	passphrase := ""
+	if schedule.Encrypt {
+		passphrase, _ = s.db.GetBackupPassphrase(ctx)
+		if passphrase == "" {
+			return nil, 0, ErrBackupPassphraseNotSet
+		}
+	}
+
+	backup, err := s.Save(ctx, passphrase)
+	if err != nil {
+		return nil, 0, err
+	}
+	deleted, err := s.Rotate(schedule.Retain)
+	return backup, deleted, err
+}
+
+// backupDue reports whether the schedule's latest backup time has passed
+// recently without a backup since.
+func backupDue(schedule *db.BackupSchedule, last *time.Time, now time.Time) bool {
+	slot := lastBackupSlot(schedule, now)
+	if now.Sub(slot) >= backupCatchUp {
+		return false
+	}
+	return last == nil || last.Before(slot)
+}
+
+// lastBackupSlot returns the latest scheduled backup time at or before now.
+func lastBackupSlot(schedule *db.BackupSchedule, now time.Time) time.Time {
+	slot := time.Date(now.Year(), now.Month(), now.Day(), schedule.Hour, 0, 0, 0, now.Location())
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if schedule.Frequency == "weekly" {
+		back := (int(slot.Weekday()) - schedule.Weekday + 7) % 7
+		slot = slot.AddDate(0, 0, -back)
+	}
+	return slot
+}
+
+// NextBackupTime returns when the schedule next takes a backup after now.
+func NextBackupTime(schedule *db.BackupSchedule, now time.Time) time.Time {
+	days := 1
+	if schedule.Frequency == "weekly" {
+		days = 7
+	}
+	return lastBackupSlot(schedule, now).AddDate(0, 0, days)
+}
+
+// ValidateBackupSchedule checks a schedule's frequency, time and retention.
+func ValidateBackupSchedule(schedule *db.BackupSchedule) error {
+	if schedule.Frequency != "daily" && schedule.Frequency != "weekly" {
+		return fmt.Errorf("%w: frequency must be daily or weekly", ErrInvalidBackupSchedule)
+	}
+	if schedule.Hour < 0 || schedule.Hour > 23 {
+		return fmt.Errorf("%w: hour must be between 0 and 23", ErrInvalidBackupSchedule)
+	}
+	if schedule.Weekday < 0 || schedule.Weekday > 6 {
+		return fmt.Errorf("%w: weekday must be between 0 (Sunday) and 6", ErrInvalidBackupSchedule)
+	}
+	if schedule.Retain < 0 || schedule.Retain > maxBackupRetain {
+		return fmt.Errorf("%w: retain must be between 0 and %d", ErrInvalidBackupSchedule, maxBackupRetain)
+	}
+	return nil
+}
+
+// Snapshot copies the databases and config into a temporary directory. The
+// relay database is left out if the relay has not created it yet. Only one
+// snapshot is taken at a time.
+func (s *BackupService) Snapshot(ctx context.Context) (*BackupSnapshot, error) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil, ErrBackupInProgress
+	}
+	s.running = true
+	configPath := s.configPath
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	dir, err := os.MkdirTemp("", "roostr-backup-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	snapshot := &BackupSnapshot{dir: dir, Manifest: BackupManifest{CreatedAt: time.Now().UTC()}}
+	snapshot.Manifest.SchemaVersion, _ = s.db.GetSchemaVersion()
+
+	if err := s.db.BackupAppDB(ctx, filepath.Join(dir, BackupAppDBFile)); err != nil {
+		snapshot.Close()
+		return nil, fmt.Errorf("failed to back up app database: %w", err)
+	}
+	snapshot.Manifest.Files = append(snapshot.Manifest.Files, BackupAppDBFile)
+
+	err = s.db.BackupRelayDB(ctx, filepath.Join(dir, BackupRelayDBFile))
+	switch {
+	case errors.Is(err, db.ErrRelayDBMissing):
+		slog.Warn("Relay database not found, backing up without it")
+	case err != nil:
+		snapshot.Close()
+		return nil, fmt.Errorf("failed to back up relay database: %w", err)
+	default:
+		snapshot.Manifest.Files = append(snapshot.Manifest.Files, BackupRelayDBFile)
+	}
+
+	if configPath != "" {
+		if err := copyFile(configPath, filepath.Join(dir, BackupConfigFile)); err == nil {
+			snapshot.Manifest.Files = append(snapshot.Manifest.Files, BackupConfigFile)
+		} else if !os.IsNotExist(err) {
+			snapshot.Close()
+			return nil, fmt.Errorf("failed to copy config: %w", err)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// WriteTo writes the snapshot to w as a tar.gz with a manifest.json.
+func (b *BackupSnapshot) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	gz := gzip.NewWriter(counter)
+	tw := tar.NewWriter(gz)
+
+	manifest, _ := json.MarshalIndent(b.Manifest, "", "  ")
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    BackupManifestFile,
+		Mode:    0600,
+		Size:    int64(len(manifest)),
+		ModTime: b.Manifest.CreatedAt,
+	}); err != nil {
+		return counter.n, err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return counter.n, err
+	}
+
+	for _, name := range b.Manifest.Files {
+		if err := addTarFile(tw, filepath.Join(b.dir, name), name, b.Manifest.CreatedAt); err != nil {
+			return counter.n, fmt.Errorf("failed to add %s: %w", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return counter.n, err
+	}
+	err := gz.Close()
+	return counter.n, err
+}
+
+// WriteEncryptedTo writes the snapshot to w as an archive encrypted with
+// passphrase.
+func (b *BackupSnapshot) WriteEncryptedTo(w io.Writer, passphrase string) (int64, error) {
+	counter := &countingWriter{w: w}
+	enc, err := newBackupEncrypter(counter, passphrase)
+	if err != nil {
+		return counter.n, err
+	}
+	if _, err := b.WriteTo(enc); err != nil {
+		return counter.n, err
+	}
+	err = enc.Close()
+	return counter.n, err
+}
+
+// Close removes the snapshot's copies.
+func (b *BackupSnapshot) Close() error {
+	return os.RemoveAll(b.dir)
+}
+
+// Filename returns the name the snapshot is saved or downloaded as.
+func (b *BackupSnapshot) Filename(encrypted bool) string {
+	name := backupFilePrefix + b.Manifest.CreatedAt.Format(backupTimeFormat) + backupFileSuffix
+	if encrypted {
+		name += backupEncryptedSuffix
+	}
+	return name
+}
+
+// Save takes a backup and writes it to the backup directory, encrypted if a
+// passphrase is given.
+func (s *BackupService) Save(ctx context.Context, passphrase string) (*BackupFile, error) {
+	dir := s.backupDir()
+	if dir == "" {
+		return nil, ErrBackupDirNotConfigured
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	snapshot, err := s.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Close()
+
+	// Write to a temporary name so a partial archive is never listed
+	tmp, err := os.CreateTemp(dir, ".roostr-backup-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	var size int64
+	if passphrase != "" {
+		size, err = snapshot.WriteEncryptedTo(tmp, passphrase)
+	} else {
+		size, err = snapshot.WriteTo(tmp)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	name := snapshot.Filename(passphrase != "")
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return nil, fmt.Errorf("failed to save backup: %w", err)
+	}
+
+	slog.Info("Backup saved", "name", name, "size", size, "files", snapshot.Manifest.Files)
+	return &BackupFile{Name: name, Size: size, Encrypted: passphrase != "", CreatedAt: snapshot.Manifest.CreatedAt}, nil
+}
+
+// Rotate deletes all but the newest keep saved backups and returns how many
+// it deleted. keep 0 keeps everything.
+func (s *BackupService) Rotate(keep int) (int, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+	backups, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for i := keep; i < len(backups); i++ {
+		if err := s.Delete(backups[i].Name); err != nil {
+			return deleted, fmt.Errorf("failed to delete %s: %w", backups[i].Name, err)
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		slog.Info("Rotated old backups", "deleted", deleted, "kept", keep)
+	}
+	return deleted, nil
+}
+
+// List returns the saved backups, newest first.
+func (s *BackupService) List() ([]BackupFile, error) {
+	dir := s.backupDir()
+	if dir == "" {
+		return nil, ErrBackupDirNotConfigured
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []BackupFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []BackupFile{}
+	for _, entry := range entries {
+		createdAt, ok := parseBackupName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupFile{
+			Name:      entry.Name(),
+			Size:      info.Size(),
+			Encrypted: strings.HasSuffix(entry.Name(), backupEncryptedSuffix),
+			CreatedAt: createdAt,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Open opens a saved backup for reading.
+func (s *BackupService) Open(name string) (*os.File, error) {
+	path, err := s.backupPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrBackupFileNotFound
+	}
+	return f, err
+}
+
+// Delete removes a saved backup.
+func (s *BackupService) Delete(name string) error {
+	path, err := s.backupPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrBackupFileNotFound
+	}
+	return err
+}
+
+// backupDir returns the configured backup directory.
+func (s *BackupService) backupDir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dir
+}
+
+// backupPath returns the path of a saved backup, rejecting names that are
+// not backup file names so no other file can be read or removed.
+func (s *BackupService) backupPath(name string) (string, error) {
+	dir := s.backupDir()
+	if dir == "" {
+		return "", ErrBackupDirNotConfigured
+	}
+	if _, ok := parseBackupName(name); !ok {
+		return "", ErrInvalidBackupName
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// parseBackupName returns the time in a saved backup's file name.
+func parseBackupName(name string) (time.Time, bool) {
+	stamp := strings.TrimSuffix(name, backupEncryptedSuffix)
+	if !strings.HasPrefix(stamp, backupFilePrefix) || !strings.HasSuffix(stamp, backupFileSuffix) {
+		return time.Time{}, false
+	}
+	stamp = strings.TrimSuffix(strings.TrimPrefix(stamp, backupFilePrefix), backupFileSuffix)
+	t, err := time.Parse(backupTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// addTarFile writes the file at path to tw as name.
+func addTarFile(tw *tar.Writer, path, name string, modTime time.Time) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    info.Size(),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// copyFile copies the file at src to a new file at dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/roostr/roostr/app/api/internal/secrets"
+)
+
+// Encrypted backups start with a header of the magic bytes, the PBKDF2
+// iteration count, a salt and a nonce prefix. The archive follows in chunks
+// sealed with AES-256-GCM, each a flag byte (1 on the last chunk), the
+// ciphertext length and the ciphertext. The flag is authenticated, so a
+// truncated backup fails to decrypt instead of restoring partially.
+const (
+	backupEncryptionMagic = "RSTRBAK1"
+	backupSaltSize        = 16
+	backupNoncePrefixSize = 8
+	backupChunkSize       = 64 * 1024
+)
+
+// backupKDFIterations is the PBKDF2-HMAC-SHA256 work factor for new
+// encrypted backups. Decryption reads it from the header.
+var backupKDFIterations = 600000
+
+var (
+	// ErrBackupEncrypted is returned when restoring an encrypted backup
+	// without a passphrase.
+	ErrBackupEncrypted = errors.New("backup is encrypted; a passphrase is required")
+
+	// ErrBackupPassphrase is returned when an encrypted backup does not
+	// decrypt, because the passphrase is wrong or the file is damaged.
+	ErrBackupPassphrase = errors.New("wrong passphrase or damaged backup")
+)
+
+// isEncryptedBackup reports whether r starts with the encrypted backup magic,
+// without consuming it.
+func isEncryptedBackup(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(backupEncryptionMagic))
+	return err == nil && string(magic) == backupEncryptionMagic
+}
+
+// backupEncrypter seals everything written to it in chunks.
+type backupEncrypter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+// newBackupEncrypter writes the header to w and returns a writer that
+// encrypts to w with a key derived from passphrase. Close writes the last
+// chunk.
+func newBackupEncrypter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	header := make([]byte, 0, len(backupEncryptionMagic)+4+backupSaltSize+backupNoncePrefixSize)
+	header = append(header, backupEncryptionMagic...)
+	header = binary.BigEndian.AppendUint32(header, uint32(backupKDFIterations))
+
+	random := make([]byte, backupSaltSize+backupNoncePrefixSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	header = append(header, random...)
+	salt, prefix := random[:backupSaltSize], random[backupSaltSize:]
+
+	aead, err := newBackupAEAD(passphrase, salt, backupKDFIterations)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &backupEncrypter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, backupChunkSize)}, nil
+}
+
+func (e *backupEncrypter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+		// Keep a full buffer until more arrives, so the last chunk is never
+		// empty unless the whole archive is
+		if len(e.buf) == cap(e.buf) && len(p) > 0 {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close seals the remaining data as the last chunk.
+func (e *backupEncrypter) Close() error {
+	return e.seal(true)
+}
+
+// seal writes the buffer as one chunk.
+func (e *backupEncrypter) seal(last bool) error {
+	flag := []byte{0}
+	if last {
+		flag[0] = 1
+	}
+	ciphertext := e.aead.Seal(nil, backupNonce(e.prefix, e.counter), e.buf, flag)
+	e.counter++
+	e.buf = e.buf[:0]
+
+	chunk := make([]byte, 0, 5+len(ciphertext))
+	chunk = append(chunk, flag...)
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(ciphertext)))
+	chunk = append(chunk, ciphertext...)
+	_, err := e.w.Write(chunk)
+	return err
+}
+
+// backupDecrypter opens the chunks of an encrypted backup.
+type backupDecrypter struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	done    bool
+}
+
+// newBackupDecrypter reads the header from r and returns a reader of the
+// decrypted archive.
+func newBackupDecrypter(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, len(backupEncryptionMagic)+4+backupSaltSize+backupNoncePrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read backup header: %w", err)
+	}
+	if string(header[:len(backupEncryptionMagic)]) != backupEncryptionMagic {
+		return nil, errors.New("not an encrypted backup")
+	}
+	rest := header[len(backupEncryptionMagic):]
+	iterations := int(binary.BigEndian.Uint32(rest))
+	salt := rest[4 : 4+backupSaltSize]
+	prefix := rest[4+backupSaltSize:]
+	if iterations <= 0 {
+		return nil, ErrBackupPassphrase
+	}
+
+	aead, err := newBackupAEAD(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	return &backupDecrypter{r: r, aead: aead, prefix: prefix}, nil
+}
+
+func (d *backupDecrypter) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk.
+func (d *backupDecrypter) open() error {
+	var head [5]byte
+	if _, err := io.ReadFull(d.r, head[:]); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF // Ended before the last chunk
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(head[1:])
+	if size > backupChunkSize+uint32(d.aead.Overhead()) {
+		return ErrBackupPassphrase
+	}
+	ciphertext := make([]byte, size)
+	if _, err := io.ReadFull(d.r, ciphertext); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	plain, err := d.aead.Open(nil, backupNonce(d.prefix, d.counter), ciphertext, head[:1])
+	if err != nil {
+		return ErrBackupPassphrase
+	}
+	d.counter++
+	d.plain = plain
+	d.done = head[0] == 1
+	return nil
+}
+
+// newBackupAEAD derives the AES-256-GCM key from a passphrase.
+func newBackupAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key := secrets.PBKDF2SHA256([]byte(passphrase), salt, iterations, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// backupNonce returns the nonce of chunk n: the prefix and the chunk counter.
+func backupNonce(prefix []byte, n uint32) []byte {
+	return binary.BigEndian.AppendUint32(bytes.Clone(prefix), n)
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrInvalidBackup is returned for an archive that is not a Roostr backup or
+// is missing files its manifest lists.
+var ErrInvalidBackup = errors.New("invalid backup archive")
+
+// BackupRestoreTargets are the paths a restore writes each backed-up file
+// to. An empty path skips that file.
+type BackupRestoreTargets struct {
+	AppDBPath   string
+	RelayDBPath string
+	ConfigPath  string
+}
+
+// RestoreBackup unpacks a backup archive, checks both databases, and moves
+// the files into place. Files it replaces are kept with a .pre-restore
+// suffix. Encrypted archives need their passphrase.
+//
+// Roostr and the relay must not be running: the databases are replaced
+// underneath any open connection. The migrate tool restores before it opens
+// the databases, so a restored app database is then migrated as usual.
+func RestoreBackup(r io.Reader, passphrase string, targets BackupRestoreTargets) (*BackupManifest, error) {
+	br := bufio.NewReader(r)
+	var archive io.Reader = br
+	if isEncryptedBackup(br) {
+		if passphrase == "" {
+			return nil, ErrBackupEncrypted
+		}
+		dec, err := newBackupDecrypter(br, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		archive = dec
+	}
+
+	// Stage next to the app database so files can be renamed into place
+	stagingParent := ""
+	if targets.AppDBPath != "" {
+		stagingParent = filepath.Dir(targets.AppDBPath)
+		if err := os.MkdirAll(stagingParent, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+	staging, err := os.MkdirTemp(stagingParent, ".roostr-restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := extractBackup(archive, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{BackupAppDBFile, BackupRelayDBFile} {
+		if !manifestHas(manifest, name) {
+			continue
+		}
+		if err := checkBackupDatabase(filepath.Join(staging, name)); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, name, err)
+		}
+	}
+
+	installs := []struct {
+		name   string
+		target string
+	}{
+		{BackupAppDBFile, targets.AppDBPath},
+		{BackupRelayDBFile, targets.RelayDBPath},
+		{BackupConfigFile, targets.ConfigPath},
+	}
+	for _, install := range installs {
+		if install.target == "" || !manifestHas(manifest, install.name) {
+			continue
+		}
+		if err := installRestoredFile(filepath.Join(staging, install.name), install.target); err != nil {
+			return manifest, fmt.Errorf("failed to restore %s: %w", install.name, err)
+		}
+	}
+	return manifest, nil
+}
+
+// extractBackup unpacks a backup archive into dir and returns its manifest.
+// Only the files a backup contains are accepted.
+func extractBackup(r io.Reader, dir string) (*BackupManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		if errors.Is(err, ErrBackupPassphrase) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	tr := tar.NewReader(gz)
+
+	allowed := map[string]bool{
+		BackupManifestFile: true,
+		BackupAppDBFile:    true,
+		BackupRelayDBFile:  true,
+		BackupConfigFile:   true,
+	}
+	seen := make(map[string]bool)
+	var manifest *BackupManifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, ErrBackupPassphrase) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !allowed[hdr.Name] {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBackup, hdr.Name)
+		}
+
+		if hdr.Name == BackupManifestFile {
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%w: bad manifest: %v", ErrInvalidBackup, err)
+			}
+			continue
+		}
+
+		f, err := os.OpenFile(filepath.Join(dir, hdr.Name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			if errors.Is(err, ErrBackupPassphrase) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		seen[hdr.Name] = true
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: no manifest", ErrInvalidBackup)
+	}
+	for _, name := range manifest.Files {
+		if !seen[name] {
+			return nil, fmt.Errorf("%w: %s is missing", ErrInvalidBackup, name)
+		}
+	}
+	return manifest, nil
+}
+
+// checkBackupDatabase runs a quick integrity check on a restored database.
+func checkBackupDatabase(path string) error {
+	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return errors.New(result)
+	}
+	return nil
+}
+
+// installRestoredFile moves src to target, keeping any file it replaces as
+// target.pre-restore. A database's WAL and shared-memory files move with it,
+// so they are not applied to the restored file.
+func installRestoredFile(src, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if _, err := os.Stat(target + suffix); err != nil {
+			continue
+		}
+		if err := os.Rename(target+suffix, target+".pre-restore"+suffix); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(src, target); err == nil {
+		return nil
+	}
+	// Different filesystem: copy instead
+	return copyFile(src, target)
+}
+
+// manifestHas reports whether a manifest lists name.
+func manifestHas(manifest *BackupManifest, name string) bool {
+	for _, f := range manifest.Files {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
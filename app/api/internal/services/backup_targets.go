@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// backupTargetTypes are the destinations backups can be uploaded to. They are
+// all off-box: a copy on the relay's own disk is already in the backup
+// directory.
+var backupTargetTypes = map[string]bool{
+	ExportDestinationS3:     true,
+	ExportDestinationWebDAV: true,
+	ExportDestinationSFTP:   true,
+}
+
+// ErrInvalidBackupTarget is returned for a target with a missing name, an
+// unsupported type or bad destination settings.
+var ErrInvalidBackupTarget = errors.New("invalid backup target")
+
+// ValidateBackupTarget checks a target's name, type, retention and
+// destination settings.
+func ValidateBackupTarget(target *db.BackupTarget) error {
+	if target.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidBackupTarget)
+	}
+	if !backupTargetTypes[target.Type] {
+		return fmt.Errorf("%w: type must be s3, webdav or sftp", ErrInvalidBackupTarget)
+	}
+	if target.Retain < 0 || target.Retain > maxBackupRetain {
+		return fmt.Errorf("%w: retain must be between 0 and %d", ErrInvalidBackupTarget, maxBackupRetain)
+	}
+	if err := ValidateExportDestination(target.Type, target.Destination); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackupTarget, err)
+	}
+	return nil
+}
+
+// CheckTarget verifies that a target accepts uploads.
+func (s *BackupService) CheckTarget(ctx context.Context, target *db.BackupTarget) error {
+	if err := ValidateBackupTarget(target); err != nil {
+		return err
+	}
+	return CheckExportDestination(ctx, target.Type, target.Destination)
+}
+
+// Upload sends a saved backup to every enabled target and prunes each
+// target's old uploads beyond its retention count. A failed upload is
+// recorded and does not stop the others.
+func (s *BackupService) Upload(ctx context.Context, name string) ([]db.BackupUpload, error) {
+	path, err := s.backupPath(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, ErrBackupFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := s.db.GetBackupTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uploads := []db.BackupUpload{}
+	for _, target := range targets {
+		if !target.Enabled {
+			continue
+		}
+		if ctx.Err() != nil {
+			return uploads, ctx.Err()
+		}
+		uploads = append(uploads, s.uploadTo(ctx, target, name, path, info.Size()))
+	}
+	return uploads, nil
+}
+
+// uploadTo sends one backup to one target and records the outcome.
+func (s *BackupService) uploadTo(ctx context.Context, target db.BackupTarget, name, path string, size int64) db.BackupUpload {
+	upload := db.BackupUpload{TargetID: target.ID, Name: name, Size: size, Status: "completed"}
+
+	dest, err := newExportDestination(target.Type, target.Destination)
+	if err == nil {
+		upload.Location, err = dest.Upload(ctx, name, path)
+	}
+	if err != nil {
+		upload.Status = "failed"
+		upload.Error = err.Error()
+		slog.Error("Backup upload failed", "target", target.Name, "backup", name, "error", err)
+	} else {
+		slog.Info("Backup uploaded", "target", target.Name, "location", upload.Location)
+	}
+
+	if err := s.db.AddBackupUpload(context.WithoutCancel(ctx), &upload); err != nil {
+		slog.Error("Failed to record backup upload", "error", err)
+	}
+	if upload.Status == "completed" && target.Retain > 0 {
+		s.pruneTarget(ctx, target, dest)
+	}
+	return upload
+}
+
+// pruneTarget deletes uploads beyond the target's retention count.
+func (s *BackupService) pruneTarget(ctx context.Context, target db.BackupTarget, dest exportDestination) {
+	old, err := s.db.GetPrunableBackupUploads(ctx, target.ID, target.Retain)
+	if err != nil {
+		return
+	}
+	for _, upload := range old {
+		if err := dest.Delete(ctx, upload.Location); err != nil {
+			slog.Warn("Failed to prune uploaded backup", "location", upload.Location, "error", err)
+			continue
+		}
+		s.db.MarkBackupUploadPruned(ctx, upload.ID)
+	}
+}
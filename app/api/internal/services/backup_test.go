@@ -0,0 +1,446 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// readBackupArchive unpacks a backup archive into dir and returns its
+// manifest.
+func readBackupArchive(t *testing.T, r io.Reader, dir string) BackupManifest {
+	t.Helper()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("not a gzip archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest BackupManifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		if hdr.Name == BackupManifestFile {
+			json.Unmarshal(data, &manifest)
+		}
+		os.WriteFile(filepath.Join(dir, hdr.Name), data, 0600)
+	}
+	return manifest
+}
+
+func TestBackupSaveAndRestore(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+	insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{
+		ID:        "aa00000000000000000000000000000000000000000000000000000000000001",
+		Pubkey:    "bb00000000000000000000000000000000000000000000000000000000000002",
+		CreatedAt: 1700000000,
+		Kind:      1,
+		Content:   "backed up",
+	})
+	database.SetAppState(ctx, "backup_marker", "present")
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	os.WriteFile(configPath, []byte("[info]\nname = \"test relay\"\n"), 0644)
+
+	svc := NewBackupService(database)
+	svc.Configure(filepath.Join(dir, "backups"), configPath)
+
+	backup, err := svc.Save(ctx, "")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if backup.Size == 0 {
+		t.Error("expected a non-empty archive")
+	}
+
+	backups, err := svc.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Name != backup.Name || backups[0].Size != backup.Size {
+		t.Fatalf("expected the saved backup to be listed, got %+v", backups)
+	}
+
+	f, err := svc.Open(backup.Name)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	restored := t.TempDir()
+	manifest := readBackupArchive(t, f, restored)
+	f.Close()
+
+	if len(manifest.Files) != 3 || manifest.SchemaVersion == 0 {
+		t.Errorf("expected both databases and the config in the manifest, got %+v", manifest)
+	}
+	if data, _ := os.ReadFile(filepath.Join(restored, BackupConfigFile)); string(data) != "[info]\nname = \"test relay\"\n" {
+		t.Errorf("unexpected config in backup: %q", data)
+	}
+
+	relayCopy, err := sql.Open("sqlite3", filepath.Join(restored, BackupRelayDBFile))
+	if err != nil {
+		t.Fatalf("failed to open relay copy: %v", err)
+	}
+	defer relayCopy.Close()
+	var events int
+	if err := relayCopy.QueryRow("SELECT COUNT(*) FROM event").Scan(&events); err != nil || events != 1 {
+		t.Errorf("expected the event in the relay copy, got %d (%v)", events, err)
+	}
+
+	appCopy, err := sql.Open("sqlite3", filepath.Join(restored, BackupAppDBFile))
+	if err != nil {
+		t.Fatalf("failed to open app copy: %v", err)
+	}
+	defer appCopy.Close()
+	var marker string
+	appCopy.QueryRow("SELECT value FROM app_state WHERE key = 'backup_marker'").Scan(&marker)
+	if marker != "present" {
+		t.Errorf("expected app state in the app copy, got %q", marker)
+	}
+
+	if err := svc.Delete(backup.Name); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := svc.Delete(backup.Name); !errors.Is(err, ErrBackupFileNotFound) {
+		t.Errorf("expected ErrBackupFileNotFound, got %v", err)
+	}
+}
+
+func TestBackupWithoutRelayDB(t *testing.T) {
+	database := setupTestDB(t)
+	svc := NewBackupService(database)
+
+	snapshot, err := svc.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	defer snapshot.Close()
+
+	if len(snapshot.Manifest.Files) != 1 || snapshot.Manifest.Files[0] != BackupAppDBFile {
+		t.Errorf("expected only the app database, got %v", snapshot.Manifest.Files)
+	}
+}
+
+func TestBackupNames(t *testing.T) {
+	svc := NewBackupService(setupTestDB(t))
+	if _, err := svc.List(); !errors.Is(err, ErrBackupDirNotConfigured) {
+		t.Errorf("expected ErrBackupDirNotConfigured, got %v", err)
+	}
+
+	svc.Configure(t.TempDir(), "")
+	for _, name := range []string{"../roostr.db", "roostr-backup-x.tar.gz", "config.toml", "roostr-backup-20260301-030000.tar.gz/.."} {
+		if err := svc.Delete(name); !errors.Is(err, ErrInvalidBackupName) {
+			t.Errorf("expected ErrInvalidBackupName for %q, got %v", name, err)
+		}
+	}
+	if _, err := svc.Open("roostr-backup-20260301-030000.tar.gz"); !errors.Is(err, ErrBackupFileNotFound) {
+		t.Errorf("expected ErrBackupFileNotFound, got %v", err)
+	}
+}
+
+// fastBackupKDF lowers the key derivation work factor for a test.
+func fastBackupKDF(t *testing.T) {
+	old := backupKDFIterations
+	backupKDFIterations = 1000
+	t.Cleanup(func() { backupKDFIterations = old })
+}
+
+func TestBackupEncryption(t *testing.T) {
+	fastBackupKDF(t)
+
+	// Several chunks, the last one partial
+	plain := bytes.Repeat([]byte("roostr backup "), backupChunkSize/5)
+
+	var sealed bytes.Buffer
+	enc, err := newBackupEncrypter(&sealed, "correct horse battery")
+	if err != nil {
+		t.Fatalf("newBackupEncrypter failed: %v", err)
+	}
+	enc.Write(plain[:1000])
+	enc.Write(plain[1000:])
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if bytes.Contains(sealed.Bytes(), []byte("roostr backup")) {
+		t.Fatal("expected the output to be encrypted")
+	}
+
+	dec, err := newBackupDecrypter(bytes.NewReader(sealed.Bytes()), "correct horse battery")
+	if err != nil {
+		t.Fatalf("newBackupDecrypter failed: %v", err)
+	}
+	opened, err := io.ReadAll(dec)
+	if err != nil || !bytes.Equal(opened, plain) {
+		t.Fatalf("expected the plaintext back, got %d bytes (%v)", len(opened), err)
+	}
+
+	dec, _ = newBackupDecrypter(bytes.NewReader(sealed.Bytes()), "wrong passphrase")
+	if _, err := io.ReadAll(dec); !errors.Is(err, ErrBackupPassphrase) {
+		t.Errorf("expected ErrBackupPassphrase for a wrong passphrase, got %v", err)
+	}
+
+	truncated := sealed.Bytes()[:sealed.Len()-len(plain)%backupChunkSize-50]
+	dec, _ = newBackupDecrypter(bytes.NewReader(truncated), "correct horse battery")
+	if _, err := io.ReadAll(dec); err == nil {
+		t.Error("expected a truncated backup to fail")
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	fastBackupKDF(t)
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+	insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{
+		ID:        "aa00000000000000000000000000000000000000000000000000000000000003",
+		Pubkey:    "bb00000000000000000000000000000000000000000000000000000000000004",
+		CreatedAt: 1700000000,
+		Kind:      1,
+	})
+	database.SetAppState(ctx, "backup_marker", "restored")
+
+	dir := t.TempDir()
+	svc := NewBackupService(database)
+	svc.Configure(filepath.Join(dir, "backups"), "")
+
+	backup, err := svc.Save(ctx, "correct horse battery")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if !backup.Encrypted || filepath.Ext(backup.Name) != ".enc" {
+		t.Fatalf("expected an encrypted backup, got %+v", backup)
+	}
+	path := filepath.Join(dir, "backups", backup.Name)
+
+	target := filepath.Join(dir, "restore")
+	targets := BackupRestoreTargets{
+		AppDBPath:   filepath.Join(target, "roostr.db"),
+		RelayDBPath: filepath.Join(target, "nostr.db"),
+	}
+	os.MkdirAll(target, 0755)
+	os.WriteFile(targets.AppDBPath, []byte("old"), 0600)
+
+	restore := func(passphrase string) (*BackupManifest, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open backup: %v", err)
+		}
+		defer f.Close()
+		return RestoreBackup(f, passphrase, targets)
+	}
+
+	if _, err := restore(""); !errors.Is(err, ErrBackupEncrypted) {
+		t.Fatalf("expected ErrBackupEncrypted, got %v", err)
+	}
+	if _, err := restore("wrong passphrase"); !errors.Is(err, ErrBackupPassphrase) {
+		t.Fatalf("expected ErrBackupPassphrase, got %v", err)
+	}
+	manifest, err := restore("correct horse battery")
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if len(manifest.Files) != 2 {
+		t.Errorf("expected both databases restored, got %v", manifest.Files)
+	}
+
+	if data, _ := os.ReadFile(targets.AppDBPath + ".pre-restore"); string(data) != "old" {
+		t.Errorf("expected the replaced app database to be kept, got %q", data)
+	}
+	restored, err := db.New(targets.RelayDBPath, targets.AppDBPath)
+	if err != nil {
+		t.Fatalf("failed to open restored databases: %v", err)
+	}
+	defer restored.Close()
+	if marker, _ := restored.GetAppState(ctx, "backup_marker"); marker != "restored" {
+		t.Errorf("expected app state from the backup, got %q", marker)
+	}
+	var events int
+	restored.RelayDB().QueryRow("SELECT COUNT(*) FROM event").Scan(&events)
+	if events != 1 {
+		t.Errorf("expected 1 restored event, got %d", events)
+	}
+
+	if _, err := RestoreBackup(strings.NewReader("not a backup"), "", targets); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("expected ErrInvalidBackup, got %v", err)
+	}
+}
+
+func TestBackupSchedule(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 3, day, hour, 30, 0, 0, time.Local) // 1 March 2026 is a Sunday
+	}
+	daily := &db.BackupSchedule{Frequency: "daily", Hour: 2}
+	weekly := &db.BackupSchedule{Frequency: "weekly", Hour: 2, Weekday: 3}
+
+	if got := lastBackupSlot(daily, at(4, 1)); !got.Equal(time.Date(2026, 3, 3, 2, 0, 0, 0, time.Local)) {
+		t.Errorf("expected yesterday's slot before 02:00, got %v", got)
+	}
+	if got := lastBackupSlot(weekly, at(6, 12)); !got.Equal(time.Date(2026, 3, 4, 2, 0, 0, 0, time.Local)) {
+		t.Errorf("expected Wednesday's slot, got %v", got)
+	}
+	if got := NextBackupTime(weekly, at(6, 12)); !got.Equal(time.Date(2026, 3, 11, 2, 0, 0, 0, time.Local)) {
+		t.Errorf("expected next Wednesday, got %v", got)
+	}
+
+	if !backupDue(daily, nil, at(4, 2)) {
+		t.Error("expected a backup due just after its time")
+	}
+	if backupDue(daily, nil, at(4, 12)) {
+		t.Error("expected no catch-up long after the time")
+	}
+	ran := at(4, 2)
+	if backupDue(daily, &ran, at(4, 3)) {
+		t.Error("expected no second backup for the same slot")
+	}
+	if backupDue(weekly, nil, at(5, 2)) {
+		t.Error("expected no weekly backup on the wrong day")
+	}
+
+	for _, schedule := range []db.BackupSchedule{
+		{Frequency: "hourly"},
+		{Frequency: "daily", Hour: 24},
+		{Frequency: "weekly", Weekday: 7},
+		{Frequency: "daily", Retain: -1},
+	} {
+		if err := ValidateBackupSchedule(&schedule); !errors.Is(err, ErrInvalidBackupSchedule) {
+			t.Errorf("expected ErrInvalidBackupSchedule for %+v, got %v", schedule, err)
+		}
+	}
+}
+
+func TestRunScheduledBackupRotates(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	for _, name := range []string{"roostr-backup-20260101-020000.tar.gz", "roostr-backup-20260102-020000.tar.gz.enc", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0600)
+	}
+
+	svc := NewBackupService(database)
+	svc.Configure(dir, "")
+	database.SetBackupSchedule(ctx, &db.BackupSchedule{Enabled: true, Frequency: "daily", Hour: 2, Retain: 2})
+
+	record, err := svc.RunScheduled(ctx)
+	if err != nil {
+		t.Fatalf("RunScheduled failed: %v", err)
+	}
+	if record.Status != "ok" || record.Deleted != 1 {
+		t.Errorf("expected one old backup rotated out, got %+v", record)
+	}
+
+	backups, _ := svc.List()
+	if len(backups) != 2 || backups[0].Name != record.Name || !backups[1].Encrypted {
+		t.Errorf("expected the new and the newest old backup kept, got %+v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error("expected other files to be left alone")
+	}
+	if last, _ := database.GetLastScheduledBackup(ctx); last == nil || last.Name != record.Name {
+		t.Errorf("expected the run to be recorded, got %+v", last)
+	}
+
+	// Encryption without a passphrase fails and is recorded
+	database.SetBackupSchedule(ctx, &db.BackupSchedule{Enabled: true, Frequency: "daily", Encrypt: true})
+	if record, err := svc.RunScheduled(ctx); !errors.Is(err, ErrBackupPassphraseNotSet) || record.Status != "failed" {
+		t.Errorf("expected a failed run without a passphrase, got %+v (%v)", record, err)
+	}
+}
+
+func TestUploadBackupToTargets(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	files := map[string]string{}
+	server := newWebDAVTestServer(t, files)
+
+	dir := t.TempDir()
+	svc := NewBackupService(database)
+	svc.Configure(dir, "")
+
+	good := &db.BackupTarget{Name: "nextcloud", Type: ExportDestinationWebDAV, Retain: 1, Enabled: true,
+		Destination: db.ExportDestination{URL: server.URL + "/dav", User: "roostr", Password: "secret"}}
+	bad := &db.BackupTarget{Name: "wrong password", Type: ExportDestinationWebDAV, Enabled: true,
+		Destination: db.ExportDestination{URL: server.URL + "/dav", User: "roostr", Password: "wrong"}}
+	off := &db.BackupTarget{Name: "disabled", Type: ExportDestinationWebDAV, Enabled: false,
+		Destination: db.ExportDestination{URL: server.URL + "/other"}}
+	for _, target := range []*db.BackupTarget{good, bad, off} {
+		if err := ValidateBackupTarget(target); err != nil {
+			t.Fatalf("expected a valid target, got %v", err)
+		}
+		database.CreateBackupTarget(ctx, target)
+	}
+
+	names := []string{"roostr-backup-20260301-020000.tar.gz", "roostr-backup-20260302-020000.tar.gz"}
+	for _, name := range names {
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0600)
+		uploads, err := svc.Upload(ctx, name)
+		if err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		if len(uploads) != 2 || uploads[0].Status != "completed" || uploads[1].Status != "failed" {
+			t.Fatalf("expected one completed and one failed upload, got %+v", uploads)
+		}
+	}
+
+	// Retain 1 pruned the first backup from the good target
+	if _, ok := files["/dav/"+names[0]]; ok {
+		t.Error("expected the older upload to be pruned")
+	}
+	if files["/dav/"+names[1]] != names[1] {
+		t.Errorf("expected the newest upload to be kept, got %v", files)
+	}
+
+	uploads, _ := database.GetBackupUploads(ctx, good.ID, 10)
+	if len(uploads) != 2 || uploads[1].Status != "pruned" {
+		t.Errorf("expected the older upload marked pruned, got %+v", uploads)
+	}
+	target, _ := database.GetBackupTarget(ctx, bad.ID)
+	if target.LastStatus != "failed" || !strings.Contains(target.LastError, "401") {
+		t.Errorf("expected the failure recorded on the target, got %+v", target)
+	}
+
+	if _, err := svc.Upload(ctx, "roostr-backup-20260303-020000.tar.gz"); !errors.Is(err, ErrBackupFileNotFound) {
+		t.Errorf("expected ErrBackupFileNotFound, got %v", err)
+	}
+
+	// A scheduled backup that saves but fails an upload is partial
+	database.SetBackupSchedule(ctx, &db.BackupSchedule{Enabled: true, Frequency: "daily"})
+	record, err := svc.RunScheduled(ctx)
+	if err == nil || record.Status != "partial" || record.Uploaded != 1 || record.UploadFailures != 1 {
+		t.Errorf("expected a partial run, got %+v (%v)", record, err)
+	}
+}
+
+func TestValidateBackupTarget(t *testing.T) {
+	for _, target := range []db.BackupTarget{
+		{Type: ExportDestinationS3, Destination: db.ExportDestination{Bucket: "b", Region: "r", AccessKey: "a", SecretKey: "s"}},
+		{Name: "local", Type: ExportDestinationLocal, Destination: db.ExportDestination{Path: "/data/backups"}},
+		{Name: "relay", Type: ExportDestinationRelay, Destination: db.ExportDestination{RelayURL: "wss://relay.example.com"}},
+		{Name: "s3", Type: ExportDestinationS3, Destination: db.ExportDestination{Bucket: "b", Region: "r"}},
+		{Name: "sftp", Type: ExportDestinationSFTP, Retain: -1, Destination: db.ExportDestination{Host: "h", User: "u", Path: "/p"}},
+	} {
+		if err := ValidateBackupTarget(&target); !errors.Is(err, ErrInvalidBackupTarget) {
+			t.Errorf("expected ErrInvalidBackupTarget for %+v, got %v", target, err)
+		}
+	}
+}
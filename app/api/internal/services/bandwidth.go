@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Client IP classes used for bandwidth accounting.
+const (
+	IPClassLocal      = "local" // loopback, which includes Tor hidden service traffic on most setups
+	IPClassLAN        = "lan"
+	IPClassPublicIPv4 = "public_ipv4"
+	IPClassPublicIPv6 = "public_ipv6"
+)
+
+// BandwidthService runs an optional TCP proxy in front of the relay's
+// WebSocket port and accounts bytes transferred per UTC day and client IP class.
+// Point the public relay port at the proxy listen address to enable it.
+type BandwidthService struct {
+	db            *db.DB
+	listenAddr    string
+	upstreamAddr  string
+	flushInterval time.Duration
+	listener      net.Listener
+
+	countersMu sync.Mutex
+	counters   map[bandwidthKey]*db.BandwidthUsage
+
+	connsMu     sync.Mutex
+	conns       map[net.Conn]struct{}
+	activeConns atomic.Int64
+
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running bool
+	mu      sync.Mutex
+}
+
+// bandwidthKey identifies an in-memory counter bucket.
+type bandwidthKey struct {
+	date    string
+	ipClass string
+}
+
+// NewBandwidthService creates a new bandwidth accounting service.
+func NewBandwidthService(database *db.DB) *BandwidthService {
+	return &BandwidthService{
+		db:            database,
+		flushInterval: time.Minute,
+		counters:      make(map[bandwidthKey]*db.BandwidthUsage),
+		conns:         make(map[net.Conn]struct{}),
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Configure sets the proxy listen address and the relay address it forwards to.
+// An empty listen address disables the proxy. Call before Start.
+func (s *BandwidthService) Configure(listenAddr, upstreamAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listenAddr = listenAddr
+	s.upstreamAddr = upstreamAddr
+}
+
+// IsEnabled returns whether proxy mode is configured.
+func (s *BandwidthService) IsEnabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listenAddr != ""
+}
+
+// ActiveConnections returns the number of connections currently proxied.
+func (s *BandwidthService) ActiveConnections() int64 {
+	return s.activeConns.Load()
+}
+
+// Start begins accepting proxy connections if a listen address is configured.
+func (s *BandwidthService) Start() {
+	s.mu.Lock()
+	if s.running || s.listenAddr == "" {
+		s.mu.Unlock()
+		return
+	}
+
+	listener, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		s.mu.Unlock()
+		slog.Error("Bandwidth proxy: failed to listen", "addr", s.listenAddr, "error", err)
+		return
+	}
+
+	s.listener = listener
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(2)
+	go s.acceptLoop(listener)
+	go s.flushLoop()
+
+	slog.Info("Bandwidth proxy listening", "addr", s.listenAddr, "upstream", s.upstreamAddr)
+}
+
+// Stop closes the listener and open connections and flushes counters.
+func (s *BandwidthService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.listener.Close()
+	s.mu.Unlock()
+
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsMu.Unlock()
+
+	s.wg.Wait()
+	s.flush()
+	slog.Info("Bandwidth proxy stopped")
+}
+
+// acceptLoop accepts client connections until the listener is closed.
+func (s *BandwidthService) acceptLoop(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.stopCh:
+				return
+			default:
+			}
+			slog.Warn("Bandwidth proxy: accept failed", "error", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn proxies one client connection to the relay, counting bytes.
+func (s *BandwidthService) handleConn(client net.Conn) {
+	defer s.wg.Done()
+
+	upstream, err := net.DialTimeout("tcp", s.upstreamAddr, 5*time.Second)
+	if err != nil {
+		slog.Warn("Bandwidth proxy: relay unreachable", "upstream", s.upstreamAddr, "error", err)
+		client.Close()
+		return
+	}
+
+	s.track(client, true)
+	s.track(upstream, true)
+	s.activeConns.Add(1)
+	defer func() {
+		s.track(client, false)
+		s.track(upstream, false)
+		s.activeConns.Add(-1)
+	}()
+
+	// Stop may have swept open connections before these were tracked
+	select {
+	case <-s.stopCh:
+		client.Close()
+		upstream.Close()
+		return
+	default:
+	}
+
+	class := classifyIP(client.RemoteAddr())
+	s.add(class, 0, 0, 1)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		s.pipe(upstream, client, class, true)
+		done <- struct{}{}
+	}()
+	go func() {
+		s.pipe(client, upstream, class, false)
+		done <- struct{}{}
+	}()
+
+	// When either side finishes, close both so the other copy returns
+	<-done
+	client.Close()
+	upstream.Close()
+	<-done
+}
+
+// track registers or unregisters a connection for shutdown.
+func (s *BandwidthService) track(conn net.Conn, add bool) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+// pipe copies src to dst, counting bytes as inbound (client to relay) or outbound.
+func (s *BandwidthService) pipe(dst, src net.Conn, class string, inbound bool) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+			if inbound {
+				s.add(class, int64(n), 0, 0)
+			} else {
+				s.add(class, 0, int64(n), 0)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// add accumulates traffic into today's in-memory bucket.
+func (s *BandwidthService) add(class string, bytesIn, bytesOut, connections int64) {
+	key := bandwidthKey{date: time.Now().UTC().Format("2006-01-02"), ipClass: class}
+
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	u, ok := s.counters[key]
+	if !ok {
+		u = &db.BandwidthUsage{Date: key.date, IPClass: key.ipClass}
+		s.counters[key] = u
+	}
+	u.BytesIn += bytesIn
+	u.BytesOut += bytesOut
+	u.Connections += connections
+}
+
+// Unflushed returns a copy of counters not yet written to the database.
+func (s *BandwidthService) Unflushed() []db.BandwidthUsage {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	usage := make([]db.BandwidthUsage, 0, len(s.counters))
+	for _, u := range s.counters {
+		usage = append(usage, *u)
+	}
+	return usage
+}
+
+// flushLoop periodically persists counters.
+func (s *BandwidthService) flushLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// flush writes in-memory counters to the database and resets them.
+func (s *BandwidthService) flush() {
+	s.countersMu.Lock()
+	pending := s.counters
+	s.counters = make(map[bandwidthKey]*db.BandwidthUsage)
+	s.countersMu.Unlock()
+
+	ctx := context.Background()
+	for _, u := range pending {
+		if err := s.db.AddBandwidthUsage(ctx, *u); err != nil {
+			slog.Error("Bandwidth proxy: failed to save usage", "date", u.Date, "ip_class", u.IPClass, "error", err)
+		}
+	}
+}
+
+// classifyIP maps a client address to an IP class.
+func classifyIP(addr net.Addr) string {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err == nil {
+			ip = net.ParseIP(host)
+		}
+	}
+
+	switch {
+	case ip == nil:
+		return IPClassPublicIPv4
+	case ip.IsLoopback():
+		return IPClassLocal
+	case ip.IsPrivate(), ip.IsLinkLocalUnicast():
+		return IPClassLAN
+	case ip.To4() != nil:
+		return IPClassPublicIPv4
+	default:
+		return IPClassPublicIPv6
+	}
+}
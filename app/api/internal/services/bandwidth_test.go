@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClassifyIP(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"127.0.0.1:1234", IPClassLocal},
+		{"[::1]:1234", IPClassLocal},
+		{"192.168.1.20:1234", IPClassLAN},
+		{"10.0.0.5:1234", IPClassLAN},
+		{"[fd00::1]:1234", IPClassLAN},
+		{"[fe80::1]:1234", IPClassLAN},
+		{"8.8.8.8:1234", IPClassPublicIPv4},
+		{"[2001:4860::8888]:1234", IPClassPublicIPv6},
+	}
+
+	for _, tt := range tests {
+		addr, err := net.ResolveTCPAddr("tcp", tt.addr)
+		if err != nil {
+			t.Fatalf("failed to resolve %s: %v", tt.addr, err)
+		}
+		if got := classifyIP(addr); got != tt.want {
+			t.Errorf("classifyIP(%s) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestBandwidthProxyAccounting(t *testing.T) {
+	database := setupTestDB(t)
+
+	// Upstream echo server standing in for the relay
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	svc := NewBandwidthService(database)
+	svc.Configure("127.0.0.1:0", upstream.Addr().String())
+	svc.Start()
+	if !svc.IsEnabled() || svc.listener == nil {
+		t.Fatal("expected proxy to be listening")
+	}
+
+	conn, err := net.Dial("tcp", svc.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("expected echo, got %q (%v)", buf, err)
+	}
+	conn.Close()
+
+	svc.Stop()
+
+	usage, err := database.GetBandwidthUsage(context.Background(), "2000-01-01")
+	if err != nil {
+		t.Fatalf("GetBandwidthUsage failed: %v", err)
+	}
+	if len(usage) != 1 {
+		t.Fatalf("expected 1 usage row, got %d", len(usage))
+	}
+	u := usage[0]
+	if u.IPClass != IPClassLocal || u.BytesIn != 5 || u.BytesOut != 5 || u.Connections != 1 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
+
+func TestBandwidthProxyDisabled(t *testing.T) {
+	svc := NewBandwidthService(nil)
+	svc.Start()
+	if svc.IsEnabled() {
+		t.Error("expected proxy to be disabled without a listen address")
+	}
+	svc.Stop()
+}
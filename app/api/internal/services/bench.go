@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+const (
+	// DefaultBenchIterations is how many times each workload runs by default.
+	DefaultBenchIterations = 10
+
+	// MaxBenchIterations bounds the iterations of one run.
+	MaxBenchIterations = 200
+
+	// benchExportEvents is how many events the export workload streams.
+	benchExportEvents = 1000
+
+	// benchDeepPage is how many pages in the deep listing workload starts.
+	benchDeepPage = 20
+)
+
+// ErrBenchRunning is returned while another benchmark run is in progress.
+var ErrBenchRunning = errors.New("a benchmark is already running")
+
+// errBenchExportDone stops the export workload once it has enough events.
+var errBenchExportDone = errors.New("export workload done")
+
+// BenchWorkload is a standard query against the relay database.
+type BenchWorkload struct {
+	Name  string
+	Group string // stats, listings or exports
+	Run   func(ctx context.Context) error
+}
+
+// BenchResult is the latency of one workload over a run.
+type BenchResult struct {
+	Name       string  `json:"name"`
+	Group      string  `json:"group"`
+	Iterations int     `json:"iterations"`
+	P50Ms      float64 `json:"p50_ms"`
+	P95Ms      float64 `json:"p95_ms"`
+	MaxMs      float64 `json:"max_ms"`
+	MeanMs     float64 `json:"mean_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// BenchReport is the outcome of a benchmark run, with what it ran against.
+type BenchReport struct {
+	Events     int64           `json:"events"`
+	Pubkeys    int64           `json:"pubkeys"`
+	Hardware   HardwareProfile `json:"hardware"`
+	Tuning     Tuning          `json:"tuning"`
+	Results    []BenchResult   `json:"results"`
+	StartedAt  time.Time       `json:"started_at"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// BenchService times standard query workloads against the attached relay
+// database, for sizing hardware and spotting query regressions. One run
+// happens at a time.
+type BenchService struct {
+	db       *db.DB
+	hardware *HardwareService
+	running  sync.Mutex
+}
+
+// NewBenchService creates a benchmark service.
+func NewBenchService(database *db.DB, hardware *HardwareService) *BenchService {
+	return &BenchService{db: database, hardware: hardware}
+}
+
+// Run runs every workload iterations times, after one untimed warm-up run,
+// and reports the latencies. It returns ErrBenchRunning if a run is already
+// in progress.
+func (s *BenchService) Run(ctx context.Context, iterations int) (*BenchReport, error) {
+	if !s.running.TryLock() {
+		return nil, ErrBenchRunning
+	}
+	defer s.running.Unlock()
+
+	if iterations <= 0 {
+		iterations = DefaultBenchIterations
+	}
+	if iterations > MaxBenchIterations {
+		iterations = MaxBenchIterations
+	}
+
+	report := &BenchReport{StartedAt: time.Now().UTC()}
+	stats, err := s.db.GetRelayStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relay stats: %w", err)
+	}
+	report.Events = stats.TotalEvents
+	report.Pubkeys = stats.TotalPubkeys
+	if s.hardware != nil {
+		report.Hardware = s.hardware.Profile()
+		report.Tuning = s.hardware.Tuning()
+	}
+
+	workloads, err := BenchWorkloads(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range workloads {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report.Results = append(report.Results, runWorkload(ctx, w, iterations))
+	}
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	return report, nil
+}
+
+// runWorkload times a workload. A failing workload is reported with its
+// error rather than timed.
+func runWorkload(ctx context.Context, w BenchWorkload, iterations int) BenchResult {
+	result := BenchResult{Name: w.Name, Group: w.Group}
+	if err := w.Run(ctx); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	durations := make([]time.Duration, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		started := time.Now()
+		if err := w.Run(ctx); err != nil {
+			result.Error = err.Error()
+			break
+		}
+		durations = append(durations, time.Since(started))
+	}
+	result.Iterations = len(durations)
+	result.P50Ms, result.P95Ms, result.MaxMs, result.MeanMs = latencies(durations)
+	return result
+}
+
+// latencies returns the median, 95th percentile, maximum and mean of
+// durations in milliseconds, using the nearest-rank percentile.
+func latencies(durations []time.Duration) (p50, p95, max, mean float64) {
+	if len(durations) == 0 {
+		return 0, 0, 0, 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(sorted))+0.999999) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return ms(rank(0.50)), ms(rank(0.95)), ms(sorted[len(sorted)-1]), ms(total / time.Duration(len(sorted)))
+}
+
+// BenchWorkloads returns the standard workloads for the relay database: the
+// dashboard's stats queries, event listings filtered the ways the UI filters
+// them, and an export. Listings are scoped to the most active author, so
+// they return full pages on any relay.
+func BenchWorkloads(ctx context.Context, database *db.DB) ([]BenchWorkload, error) {
+	top, err := database.GetTopAuthors(ctx, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the most active author: %w", err)
+	}
+	author := ""
+	if len(top) > 0 {
+		author = top[0].Pubkey
+	}
+
+	// The cursor of a page deep into the event list
+	deep := db.EventFilter{Limit: 50}
+	for i := 0; i < benchDeepPage; i++ {
+		_, next, err := database.GetEventsPage(ctx, deep)
+		if err != nil {
+			return nil, fmt.Errorf("failed to page through events: %w", err)
+		}
+		if next == nil {
+			break
+		}
+		deep.After = next
+	}
+
+	until := time.Now()
+	since := until.AddDate(0, 0, -30)
+
+	workloads := []BenchWorkload{
+		{"relay_stats", "stats", func(ctx context.Context) error {
+			_, err := database.GetRelayStats(ctx)
+			return err
+		}},
+		{"events_by_kind_30d", "stats", func(ctx context.Context) error {
+			_, err := database.GetEventsByKindInRange(ctx, since, until)
+			return err
+		}},
+		{"events_over_time_30d", "stats", func(ctx context.Context) error {
+			_, err := database.GetEventsOverTime(ctx, since, until, db.BucketDay, time.UTC)
+			return err
+		}},
+		{"top_authors_30d", "stats", func(ctx context.Context) error {
+			_, err := database.GetTopAuthorsInRange(ctx, 10, since, until)
+			return err
+		}},
+		{"list_recent", "listings", func(ctx context.Context) error {
+			_, _, err := database.GetEventsPage(ctx, db.EventFilter{Limit: 50})
+			return err
+		}},
+		{"list_deep_page", "listings", func(ctx context.Context) error {
+			_, _, err := database.GetEventsPage(ctx, deep)
+			return err
+		}},
+		{"list_by_kind", "listings", func(ctx context.Context) error {
+			_, _, err := database.GetEventsPage(ctx, db.EventFilter{Limit: 50, Kinds: []int{1}})
+			return err
+		}},
+		{"list_content_search", "listings", func(ctx context.Context) error {
+			_, _, err := database.GetEventsPage(ctx, db.EventFilter{Limit: 50, Search: "nostr"})
+			return err
+		}},
+	}
+	if author != "" {
+		workloads = append(workloads,
+			BenchWorkload{"list_by_author", "listings", func(ctx context.Context) error {
+				_, _, err := database.GetEventsPage(ctx, db.EventFilter{Limit: 50, Authors: []string{author}})
+				return err
+			}},
+			BenchWorkload{"list_mentions", "listings", func(ctx context.Context) error {
+				_, _, err := database.GetEventsPage(ctx, db.EventFilter{Limit: 50, Tags: map[string][]string{"p": {author}}})
+				return err
+			}},
+			BenchWorkload{"author_activity_30d", "stats", func(ctx context.Context) error {
+				_, err := database.GetPubkeyActivity(ctx, author, since, until, time.UTC)
+				return err
+			}},
+		)
+	}
+	workloads = append(workloads, BenchWorkload{"export_1000", "exports", func(ctx context.Context) error {
+		exported := 0
+		err := database.StreamEvents(ctx, db.EventFilter{}, func(db.ExportEvent) error {
+			exported++
+			if exported >= benchExportEvents {
+				return errBenchExportDone
+			}
+			return nil
+		})
+		if errors.Is(err, errBenchExportDone) {
+			return nil
+		}
+		return err
+	}})
+	return workloads, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/testutil"
+)
+
+// openBenchFixture opens a generated relay database. Set
+// ROOSTR_FIXTURE_EVENTS to benchmark against a larger one.
+func openBenchFixture(tb testing.TB, opts testutil.RelayOptions) *db.DB {
+	tb.Helper()
+
+	fixture := testutil.NewRelayDB(tb, opts)
+	database, err := db.New(fixture.Path, filepath.Join(tb.TempDir(), "roostr.db"))
+	if err != nil {
+		tb.Fatalf("failed to open fixture: %v", err)
+	}
+	tb.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestLatencies(t *testing.T) {
+	var durations []time.Duration
+	for i := 20; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	p50, p95, max, mean := latencies(durations)
+	if p50 != 10 || p95 != 19 || max != 20 || mean != 10.5 {
+		t.Errorf("latencies = %v, %v, %v, %v; want 10, 19, 20, 10.5", p50, p95, max, mean)
+	}
+	if p50, _, _, _ := latencies(nil); p50 != 0 {
+		t.Errorf("expected no latencies for no runs, got %v", p50)
+	}
+}
+
+func TestBenchService_Run(t *testing.T) {
+	database := openBenchFixture(t, testutil.RelayOptions{Authors: 20, Events: 1500})
+	svc := NewBenchService(database, nil)
+
+	report, err := svc.Run(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Events != 1500 || report.Pubkeys == 0 {
+		t.Errorf("unexpected relay size: %d events, %d pubkeys", report.Events, report.Pubkeys)
+	}
+	groups := map[string]bool{}
+	for _, r := range report.Results {
+		groups[r.Group] = true
+		if r.Error != "" || r.Iterations != 3 || r.P95Ms < r.P50Ms || r.MaxMs < r.P95Ms {
+			t.Errorf("unexpected result: %+v", r)
+		}
+	}
+	if !groups["stats"] || !groups["listings"] || !groups["exports"] {
+		t.Errorf("expected stats, listings and exports workloads, got %v", groups)
+	}
+
+	// One run at a time
+	svc.running.Lock()
+	if _, err := svc.Run(context.Background(), 1); err != ErrBenchRunning {
+		t.Errorf("expected ErrBenchRunning, got %v", err)
+	}
+	svc.running.Unlock()
+}
+
+func BenchmarkWorkloads(b *testing.B) {
+	ctx := context.Background()
+	database := openBenchFixture(b, testutil.RelayOptions{Authors: 200})
+
+	workloads, err := BenchWorkloads(ctx, database)
+	if err != nil {
+		b.Fatalf("BenchWorkloads failed: %v", err)
+	}
+	for _, w := range workloads {
+		b.Run(w.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := w.Run(ctx); err != nil {
+					b.Fatalf("%s failed: %v", w.Name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+const (
+	// broadcastBatchSize is how many events are sent to each relay before
+	// waiting for its OK responses.
+	broadcastBatchSize = 100
+
+	// broadcastBatchTimeout bounds how long a relay gets to answer a batch.
+	broadcastBatchTimeout = time.Minute
+
+	// broadcastConnectTimeout bounds each relay connection attempt.
+	broadcastConnectTimeout = 15 * time.Second
+
+	// maxBroadcastRelays bounds how many relays one broadcast pushes to.
+	maxBroadcastRelays = 20
+)
+
+var (
+	// ErrNoBroadcastRelays is returned when a broadcast has no relays to push to.
+	ErrNoBroadcastRelays = errors.New("at least one relay is required")
+
+	// ErrTooManyBroadcastRelays is returned when a broadcast lists too many relays.
+	ErrTooManyBroadcastRelays = fmt.Errorf("at most %d relays are allowed", maxBroadcastRelays)
+
+	// ErrInvalidBroadcastRelay is returned for a relay URL that is not a
+	// WebSocket URL.
+	ErrInvalidBroadcastRelay = errors.New("invalid relay URL")
+)
+
+// BroadcastRequest selects the local events to push and the relays to push
+// them to. Empty authors or kinds match all; no relays means the sync relays.
+type BroadcastRequest struct {
+	Authors []string `json:"authors,omitempty"`
+	Kinds   []int    `json:"kinds,omitempty"`
+	Since   *int64   `json:"since,omitempty"`
+	Until   *int64   `json:"until,omitempty"`
+	Relays  []string `json:"relays,omitempty"`
+}
+
+// broadcastConn is a connection to a relay that accepts published events.
+type broadcastConn interface {
+	PublishBatch(ctx context.Context, events []*nostr.SyncEvent) (map[string]nostr.PublishResult, error)
+	Close() error
+}
+
+// BroadcastService pushes local events to remote relays, e.g. so a private
+// relay keeps a copy of its members' notes on public relays. Each broadcast
+// runs as a job of type "broadcast"; its broadcast_jobs record keeps the
+// per-relay OK, duplicate and error counts.
+type BroadcastService struct {
+	db      *db.DB
+	jobs    *JobService
+	relays  func(context.Context) []string
+	connect func(ctx context.Context, url string) (broadcastConn, error)
+}
+
+// NewBroadcastService creates a new broadcast service.
+func NewBroadcastService(database *db.DB) *BroadcastService {
+	return &BroadcastService{
+		db:   database,
+		jobs: NewJobService(database),
+		relays: func(ctx context.Context) []string {
+			return SyncRelayURLs(ctx, database)
+		},
+		connect: func(ctx context.Context, url string) (broadcastConn, error) {
+			client := nostr.NewClient(url)
+			if err := client.Connect(ctx); err != nil {
+				return nil, err
+			}
+			return client, nil
+		},
+	}
+}
+
+// Start marks broadcasts left running by a previous process as failed.
+func (s *BroadcastService) Start() {
+	if n, err := s.db.FailInterruptedBroadcastJobs(context.Background()); err != nil {
+		slog.Error("Failed to mark interrupted broadcasts", "error", err)
+	} else if n > 0 {
+		slog.Warn("Marked interrupted broadcasts as failed", "count", n)
+	}
+}
+
+// Broadcast starts pushing the selected events in the background and returns
+// the broadcast and the job running it.
+func (s *BroadcastService) Broadcast(ctx context.Context, req BroadcastRequest) (*db.BroadcastJob, *db.Job, error) {
+	if len(req.Relays) == 0 {
+		req.Relays = s.relays(ctx)
+	}
+	relays, err := normalizeBroadcastRelays(req.Relays)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := &db.BroadcastJob{
+		Authors:      req.Authors,
+		Kinds:        req.Kinds,
+		Relays:       relays,
+		RelayResults: make(map[string]*db.BroadcastRelayResult, len(relays)),
+	}
+	if b.Authors == nil {
+		b.Authors = []string{}
+	}
+	if b.Kinds == nil {
+		b.Kinds = []int{}
+	}
+	if req.Since != nil {
+		t := time.Unix(*req.Since, 0)
+		b.Since = &t
+	}
+	if req.Until != nil {
+		t := time.Unix(*req.Until, 0)
+		b.Until = &t
+	}
+	for _, url := range relays {
+		b.RelayResults[url] = &db.BroadcastRelayResult{}
+	}
+
+	if err := s.db.CreateBroadcastJob(ctx, b); err != nil {
+		return nil, nil, fmt.Errorf("failed to create broadcast: %w", err)
+	}
+
+	params := map[string]interface{}{"broadcast_id": b.ID, "request": req}
+	job, err := s.jobs.Run(JobTypeBroadcast, params, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return s.run(ctx, b, run)
+	})
+	if err != nil {
+		s.db.CompleteBroadcastJob(context.Background(), b, "failed", err.Error())
+		return nil, nil, err
+	}
+	if err := s.db.SetBroadcastJobID(ctx, b.ID, job.ID); err != nil {
+		slog.Warn("Failed to link broadcast to its job", "broadcast_id", b.ID, "job_id", job.ID, "error", err)
+	}
+
+	// The running job owns b; return a copy read back from the database
+	saved, err := s.db.GetBroadcastJob(ctx, b.ID)
+	if err != nil || saved == nil {
+		return nil, nil, fmt.Errorf("failed to read broadcast: %v", err)
+	}
+	return saved, job, nil
+}
+
+// Cancel stops a running broadcast.
+func (s *BroadcastService) Cancel(b *db.BroadcastJob) error {
+	return s.jobs.Cancel(b.JobID)
+}
+
+// normalizeBroadcastRelays validates relay URLs and removes duplicates.
+func normalizeBroadcastRelays(urls []string) ([]string, error) {
+	seen := make(map[string]bool)
+	relays := make([]string, 0, len(urls))
+	for _, url := range urls {
+		url = strings.TrimRight(strings.TrimSpace(url), "/")
+		if !strings.HasPrefix(url, "wss://") && !strings.HasPrefix(url, "ws://") {
+			return nil, fmt.Errorf("%w %q: must start with wss:// or ws://", ErrInvalidBroadcastRelay, url)
+		}
+		if !seen[url] {
+			seen[url] = true
+			relays = append(relays, url)
+		}
+	}
+	if len(relays) == 0 {
+		return nil, ErrNoBroadcastRelays
+	}
+	if len(relays) > maxBroadcastRelays {
+		return nil, ErrTooManyBroadcastRelays
+	}
+	return relays, nil
+}
+
+// run pushes the events as the job run and returns the finished broadcast.
+func (s *BroadcastService) run(ctx context.Context, b *db.BroadcastJob, run *JobRun) (*db.BroadcastJob, error) {
+	filter := db.EventFilter{Authors: b.Authors, Kinds: b.Kinds}
+	if b.Since != nil {
+		filter.Since = *b.Since
+	}
+	if b.Until != nil {
+		filter.Until = *b.Until
+	}
+
+	total, err := s.db.CountEvents(ctx, filter)
+	if err == nil {
+		b.EventsTotal = total
+	}
+
+	conns := make(map[string]broadcastConn, len(b.Relays))
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	var batch []*nostr.SyncEvent
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.sendBatch(ctx, b, conns, batch)
+		b.EventsSent += int64(len(batch))
+		batch = batch[:0]
+
+		run.Progress(b.EventsSent, b.EventsTotal, fmt.Sprintf("%d events sent to %d relays", b.EventsSent, len(b.Relays)))
+		if err := s.db.UpdateBroadcastProgress(context.Background(), b); err != nil {
+			slog.Warn("Failed to save broadcast progress", "broadcast_id", b.ID, "error", err)
+		}
+	}
+
+	err = s.db.StreamEvents(ctx, filter, func(e db.ExportEvent) error {
+		event := nostr.SyncEvent(e)
+		batch = append(batch, &event)
+		if len(batch) == broadcastBatchSize {
+			flush()
+		}
+		return ctx.Err()
+	})
+	if err == nil {
+		flush()
+	}
+	if b.EventsSent > b.EventsTotal {
+		b.EventsTotal = b.EventsSent
+	}
+
+	status, errorMsg := "completed", ""
+	switch {
+	case ctx.Err() != nil:
+		status = "cancelled"
+	case err != nil:
+		status, errorMsg = "failed", err.Error()
+	}
+	if dbErr := s.db.CompleteBroadcastJob(context.Background(), b, status, errorMsg); dbErr != nil {
+		slog.Error("Failed to save broadcast result", "broadcast_id", b.ID, "error", dbErr)
+	}
+	b.Status = status
+	b.ErrorMessage = errorMsg
+
+	slog.Info("Broadcast finished", "broadcast_id", b.ID, "status", status, "events", b.EventsSent, "relays", len(b.Relays))
+	return b, err
+}
+
+// sendBatch publishes a batch to every relay at once and counts the
+// responses. A relay that cannot be reached counts the whole batch as errors
+// and is reconnected on the next batch.
+func (s *BroadcastService) sendBatch(ctx context.Context, b *db.BroadcastJob, conns map[string]broadcastConn, batch []*nostr.SyncEvent) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	current := make(map[string]broadcastConn, len(conns))
+	for url, conn := range conns {
+		current[url] = conn
+	}
+	for _, url := range b.Relays {
+		conn := current[url]
+		wg.Add(1)
+		go func(url string, conn broadcastConn) {
+			defer wg.Done()
+
+			var results map[string]nostr.PublishResult
+			var err error
+			if conn == nil {
+				connectCtx, cancel := context.WithTimeout(ctx, broadcastConnectTimeout)
+				conn, err = s.connect(connectCtx, url)
+				cancel()
+			}
+			if err == nil {
+				batchCtx, cancel := context.WithTimeout(ctx, broadcastBatchTimeout)
+				results, err = conn.PublishBatch(batchCtx, batch)
+				cancel()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if conn != nil {
+					conn.Close()
+				}
+				delete(conns, url)
+			} else {
+				conns[url] = conn
+			}
+			countBroadcastResults(b.RelayResults[url], batch, results, err)
+		}(url, conn)
+	}
+	wg.Wait()
+}
+
+// countBroadcastResults adds a relay's responses to a batch to its counts.
+// Events without a response count as errors.
+func countBroadcastResults(counts *db.BroadcastRelayResult, batch []*nostr.SyncEvent, results map[string]nostr.PublishResult, err error) {
+	for _, event := range batch {
+		result, ok := results[event.ID]
+		switch {
+		case !ok:
+			counts.Error++
+			if err != nil {
+				counts.LastError = err.Error()
+			} else {
+				counts.LastError = "no response"
+			}
+		case result.Duplicate():
+			counts.Duplicate++
+		case result.Accepted:
+			counts.OK++
+		default:
+			counts.Error++
+			counts.LastError = result.Message
+		}
+	}
+}
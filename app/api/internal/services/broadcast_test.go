@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+type fakeBroadcastConn struct {
+	publish func(events []*nostr.SyncEvent) (map[string]nostr.PublishResult, error)
+	closed  bool
+}
+
+func (c *fakeBroadcastConn) PublishBatch(ctx context.Context, events []*nostr.SyncEvent) (map[string]nostr.PublishResult, error) {
+	return c.publish(events)
+}
+
+func (c *fakeBroadcastConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestBroadcastService_Broadcast(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	member := strings.Repeat("aa", 32)
+	event := func(n byte, pubkey string, kind int, createdAt int64) *nostr.SyncEvent {
+		return &nostr.SyncEvent{ID: strings.Repeat(hex.EncodeToString([]byte{n}), 32), Pubkey: pubkey, Kind: kind, CreatedAt: createdAt, Sig: strings.Repeat("cc", 64)}
+	}
+	note := event(1, member, 1, 1700000100)
+	known := event(2, member, 1, 1700000200)
+	rejected := event(3, member, 1, 1700000300)
+	insertCoverageTestEvent(t, relayDB, note)
+	insertCoverageTestEvent(t, relayDB, known)
+	insertCoverageTestEvent(t, relayDB, rejected)
+	insertCoverageTestEvent(t, relayDB, event(4, member, 7, 1700000400))                   // other kind
+	insertCoverageTestEvent(t, relayDB, event(5, strings.Repeat("bb", 32), 1, 1700000500)) // other author
+	insertCoverageTestEvent(t, relayDB, event(6, member, 1, 1600000000))                   // too old
+
+	conns := map[string]*fakeBroadcastConn{
+		"wss://good": {publish: func(events []*nostr.SyncEvent) (map[string]nostr.PublishResult, error) {
+			results := make(map[string]nostr.PublishResult)
+			for _, e := range events {
+				switch e.ID {
+				case known.ID:
+					results[e.ID] = nostr.PublishResult{Accepted: true, Message: "duplicate: already have this event"}
+				case rejected.ID:
+					results[e.ID] = nostr.PublishResult{Message: "blocked: not on whitelist"}
+				default:
+					results[e.ID] = nostr.PublishResult{Accepted: true}
+				}
+			}
+			return results, nil
+		}},
+		"wss://flaky": {publish: func(events []*nostr.SyncEvent) (map[string]nostr.PublishResult, error) {
+			return map[string]nostr.PublishResult{note.ID: {Accepted: true}}, errors.New("connection reset")
+		}},
+	}
+	svc := NewBroadcastService(database)
+	svc.relays = func(context.Context) []string { return nil }
+	svc.connect = func(ctx context.Context, url string) (broadcastConn, error) {
+		if conn, ok := conns[url]; ok {
+			return conn, nil
+		}
+		return nil, errors.New("dial failed")
+	}
+
+	since := int64(1700000000)
+	b, job, err := svc.Broadcast(ctx, BroadcastRequest{
+		Authors: []string{member},
+		Kinds:   []int{1},
+		Since:   &since,
+		Relays:  []string{"wss://good/", "wss://flaky", "wss://down", "wss://good"},
+	})
+	if err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+	if b.JobID != job.ID || len(b.Relays) != 3 {
+		t.Errorf("unexpected broadcast: %+v", b)
+	}
+
+	if done := waitJob(t, svc.jobs, job.ID); done.Status != db.JobStatusCompleted {
+		t.Fatalf("expected a completed job, got %+v", done)
+	}
+
+	saved, err := database.GetBroadcastJob(ctx, b.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("GetBroadcastJob failed: %v", err)
+	}
+	if saved.Status != "completed" || saved.EventsTotal != 3 || saved.EventsSent != 3 || saved.CompletedAt == nil {
+		t.Errorf("unexpected saved broadcast: %+v", saved)
+	}
+
+	good := saved.RelayResults["wss://good"]
+	if good == nil || good.OK != 1 || good.Duplicate != 1 || good.Error != 1 || good.LastError != "blocked: not on whitelist" {
+		t.Errorf("unexpected results for wss://good: %+v", good)
+	}
+	flaky := saved.RelayResults["wss://flaky"]
+	if flaky == nil || flaky.OK != 1 || flaky.Error != 2 || flaky.LastError != "connection reset" {
+		t.Errorf("unexpected results for wss://flaky: %+v", flaky)
+	}
+	down := saved.RelayResults["wss://down"]
+	if down == nil || down.Error != 3 || down.LastError != "dial failed" {
+		t.Errorf("unexpected results for wss://down: %+v", down)
+	}
+	if !conns["wss://good"].closed || !conns["wss://flaky"].closed {
+		t.Error("expected connections to be closed")
+	}
+}
+
+func TestBroadcastService_DefaultsToSyncRelays(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	svc := NewBroadcastService(database)
+	svc.relays = func(context.Context) []string { return nil }
+	if _, _, err := svc.Broadcast(ctx, BroadcastRequest{}); !errors.Is(err, ErrNoBroadcastRelays) {
+		t.Errorf("expected ErrNoBroadcastRelays, got %v", err)
+	}
+
+	svc.relays = func(context.Context) []string { return []string{"wss://sync"} }
+	svc.connect = func(ctx context.Context, url string) (broadcastConn, error) {
+		return nil, errors.New("dial failed")
+	}
+	b, job, err := svc.Broadcast(ctx, BroadcastRequest{})
+	if err != nil {
+		t.Fatalf("Broadcast failed: %v", err)
+	}
+	waitJob(t, svc.jobs, job.ID)
+	if len(b.Relays) != 1 || b.Relays[0] != "wss://sync" {
+		t.Errorf("expected the sync relays, got %v", b.Relays)
+	}
+}
+
+func TestNormalizeBroadcastRelays(t *testing.T) {
+	relays, err := normalizeBroadcastRelays([]string{" wss://a.com/ ", "ws://b.com", "wss://a.com"})
+	if err != nil || len(relays) != 2 || relays[0] != "wss://a.com" || relays[1] != "ws://b.com" {
+		t.Errorf("unexpected relays %v, err %v", relays, err)
+	}
+
+	if _, err := normalizeBroadcastRelays([]string{"https://a.com"}); !errors.Is(err, ErrInvalidBroadcastRelay) {
+		t.Errorf("expected ErrInvalidBroadcastRelay, got %v", err)
+	}
+	if _, err := normalizeBroadcastRelays(nil); !errors.Is(err, ErrNoBroadcastRelays) {
+		t.Errorf("expected ErrNoBroadcastRelays, got %v", err)
+	}
+
+	many := make([]string, maxBroadcastRelays+1)
+	for i := range many {
+		many[i] = "wss://relay" + strings.Repeat("x", i)
+	}
+	if _, err := normalizeBroadcastRelays(many); !errors.Is(err, ErrTooManyBroadcastRelays) {
+		t.Errorf("expected ErrTooManyBroadcastRelays, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Errors returned when a Cashu token cannot pay for access.
+var (
+	ErrCashuDisabled         = errors.New("Cashu payments are not enabled")
+	ErrCashuMintNotAccepted  = errors.New("tokens from this mint are not accepted")
+	ErrCashuUnit             = errors.New("only sat tokens are accepted")
+	ErrCashuInsufficient     = errors.New("token is worth less than the price plus the mint's fee reserve")
+	ErrCashuMeltFailed       = errors.New("mint did not pay the invoice")
+	ErrCashuMintNotReachable = errors.New("failed to reach the mint")
+)
+
+// CashuResult is the outcome of paying for access with a Cashu token.
+type CashuResult struct {
+	Status      string     `json:"status"` // paid, or pending while the mint's payment is in flight
+	PaymentHash string     `json:"payment_hash"`
+	TierID      string     `json:"tier_id"`
+	AmountSats  int64      `json:"amount_sats"`
+	TokenSats   int64      `json:"token_sats"`
+	FeeSats     int64      `json:"fee_sats"` // token value beyond the price, kept by the mint
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// CashuService accepts Cashu ecash tokens for paid access. Instead of
+// holding ecash, it melts the token at its mint (NUT-05) to pay an access
+// invoice on the relay's own node, then whitelists the pubkey as for any
+// paid invoice. Only tokens from the operator's accepted mints are taken.
+type CashuService struct {
+	db        *db.DB
+	lightning *LightningService
+	monitor   *InvoiceMonitorService
+	client    *http.Client
+}
+
+// NewCashuService creates a new Cashu service.
+func NewCashuService(database *db.DB, lightning *LightningService, monitor *InvoiceMonitorService) *CashuService {
+	return &CashuService{
+		db:        database,
+		lightning: lightning,
+		monitor:   monitor,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// NormalizeMintURL returns a mint URL as it is compared and stored.
+func NormalizeMintURL(mint string) string {
+	return strings.TrimRight(strings.TrimSpace(mint), "/")
+}
+
+// cashuMeltQuote is a mint's melt quote or melt response (NUT-05).
+type cashuMeltQuote struct {
+	Quote      string `json:"quote"`
+	Amount     int64  `json:"amount"`
+	FeeReserve int64  `json:"fee_reserve"`
+	State      string `json:"state"` // UNPAID, PENDING or PAID
+	Paid       bool   `json:"paid"`  // mints before NUT-05 states
+}
+
+// Pay redeems a Cashu token for a tier's access. The token must be worth at
+// least the price plus the mint's fee reserve for paying the invoice.
+func (s *CashuService) Pay(ctx context.Context, pubkey, npub, tierID, encoded string) (*CashuResult, error) {
+	settings, err := s.db.GetCashuSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Enabled {
+		return nil, ErrCashuDisabled
+	}
+
+	token, err := ParseCashuToken(encoded)
+	if err != nil {
+		return nil, err
+	}
+	mint := NormalizeMintURL(token.Mint)
+	if !mintAccepted(settings.Mints, mint) {
+		return nil, ErrCashuMintNotAccepted
+	}
+	if token.Unit != "sat" {
+		return nil, ErrCashuUnit
+	}
+
+	invoice, err := s.lightning.CreateAccessInvoice(ctx, AccessInvoiceRequest{
+		Pubkey: pubkey,
+		Npub:   npub,
+		TierID: tierID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tokenSats := token.Amount()
+	if tokenSats < invoice.AmountSats {
+		return nil, ErrCashuInsufficient
+	}
+
+	var quote cashuMeltQuote
+	if err := s.post(ctx, mint, "/v1/melt/quote/bolt11", map[string]string{
+		"request": invoice.PaymentRequest,
+		"unit":    "sat",
+	}, &quote); err != nil {
+		return nil, err
+	}
+	if tokenSats < quote.Amount+quote.FeeReserve {
+		return nil, fmt.Errorf("%w: %d sats needed", ErrCashuInsufficient, quote.Amount+quote.FeeReserve)
+	}
+
+	// Recorded before melting so the payment is labelled Cashu however it
+	// is detected
+	if err := s.db.AddCashuPayment(ctx, &db.CashuPayment{
+		PaymentHash:    invoice.PaymentHash,
+		Pubkey:         pubkey,
+		Mint:           mint,
+		TokenSats:      tokenSats,
+		FeeReserveSats: quote.FeeReserve,
+		Status:         "pending",
+	}); err != nil {
+		return nil, err
+	}
+
+	var melt cashuMeltQuote
+	if err := s.post(ctx, mint, "/v1/melt/bolt11", map[string]interface{}{
+		"quote":  quote.Quote,
+		"inputs": token.Proofs,
+	}, &melt); err != nil {
+		s.setStatus(ctx, invoice.PaymentHash, "failed")
+		return nil, err
+	}
+
+	result := &CashuResult{
+		Status:      "pending",
+		PaymentHash: invoice.PaymentHash,
+		TierID:      invoice.TierID,
+		AmountSats:  invoice.AmountSats,
+		TokenSats:   tokenSats,
+		FeeSats:     tokenSats - invoice.AmountSats,
+	}
+	switch {
+	case melt.State == "PAID" || melt.Paid:
+	case melt.State == "PENDING":
+		// The invoice monitor whitelists the pubkey once the payment lands
+		return result, nil
+	default:
+		s.setStatus(ctx, invoice.PaymentHash, "failed")
+		return nil, ErrCashuMeltFailed
+	}
+
+	// Whitelist only once the relay's own node has the payment
+	settled, err := s.lightning.CheckInvoice(ctx, invoice.PaymentHash)
+	if err != nil || !settled.Settled {
+		return result, nil
+	}
+	s.setStatus(ctx, invoice.PaymentHash, "paid")
+	if err := s.monitor.ProcessPayment(ctx, invoice.PaymentHash); err != nil {
+		return nil, err
+	}
+
+	result.Status = "paid"
+	if user, _ := s.db.GetPaidUserByPubkey(ctx, pubkey); user != nil {
+		result.ExpiresAt = user.ExpiresAt
+	}
+	return result, nil
+}
+
+// setStatus updates a Cashu payment's status, logging failures.
+func (s *CashuService) setStatus(ctx context.Context, paymentHash, status string) {
+	if err := s.db.UpdateCashuPaymentStatus(ctx, paymentHash, status); err != nil {
+		slog.WarnContext(ctx, "Failed to update Cashu payment", "payment_hash", paymentHash, "error", err)
+	}
+}
+
+// post sends a JSON request to a mint and decodes the response. Mint errors
+// carry a detail message (NUT-00).
+func (s *CashuService) post(ctx context.Context, mint, path string, body, out interface{}) error {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mint+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCashuMintNotReachable, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCashuMintNotReachable, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		var mintErr struct {
+			Detail string `json:"detail"`
+		}
+		json.Unmarshal(respBody, &mintErr)
+		if mintErr.Detail == "" {
+			mintErr.Detail = resp.Status
+		}
+		return fmt.Errorf("%w: %s", ErrCashuMeltFailed, mintErr.Detail)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("%w: invalid response: %v", ErrCashuMintNotReachable, err)
+	}
+	return nil
+}
+
+// mintAccepted reports whether a normalized mint URL is on the accepted list.
+func mintAccepted(mints []string, mint string) bool {
+	for _, m := range mints {
+		if NormalizeMintURL(m) == mint {
+			return true
+		}
+	}
+	return false
+}
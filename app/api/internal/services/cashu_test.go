@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// cashuTokenV3 serializes a V3 token with proofs of the given amounts.
+func cashuTokenV3(mint string, amounts ...int64) string {
+	proofs := []CashuProof{}
+	for _, a := range amounts {
+		proofs = append(proofs, CashuProof{Amount: a, ID: "009a1f293253e41e", Secret: "secret", C: "02bc"})
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"token": []map[string]interface{}{{"mint": mint, "proofs": proofs}},
+		"unit":  "sat",
+	})
+	return "cashuA" + base64.URLEncoding.EncodeToString(data)
+}
+
+func TestParseCashuToken(t *testing.T) {
+	// Test vector from NUT-00
+	v4 := "cashuBpGF0gaJhaUgArSaMTR9YJmFwgaNhYQFhc3hAOWE2ZGJiODQ3YmQyMzJiYTc2ZGIwZGYxOTcyMTZiMjlkM2I4Y2MxNDU1M2NkMjc4MjdmYzFjYzk0MmZlZGI0ZWFjWCEDhhhUP_trhpXfStS6vN6So0qWvc2X3O4NfM-Y1HISZ5JhZGlUaGFuayB5b3VhbXVodHRwOi8vbG9jYWxob3N0OjMzMzhhdWNzYXQ="
+	token, err := ParseCashuToken(v4)
+	if err != nil {
+		t.Fatalf("ParseCashuToken(v4) error = %v", err)
+	}
+	if token.Mint != "http://localhost:3338" || token.Unit != "sat" || token.Memo != "Thank you" || token.Amount() != 1 {
+		t.Errorf("unexpected v4 token %+v", token)
+	}
+	if p := token.Proofs[0]; p.ID != "00ad268c4d1f5826" || !strings.HasPrefix(p.C, "038618543ffb") {
+		t.Errorf("unexpected v4 proof %+v", p)
+	}
+
+	token, err = ParseCashuToken("cashu:" + cashuTokenV3("https://mint.example", 2, 8))
+	if err != nil {
+		t.Fatalf("ParseCashuToken(v3) error = %v", err)
+	}
+	if token.Mint != "https://mint.example" || token.Amount() != 10 || len(token.Proofs) != 2 {
+		t.Errorf("unexpected v3 token %+v", token)
+	}
+
+	twoMints, _ := json.Marshal(map[string]interface{}{
+		"token": []map[string]interface{}{
+			{"mint": "https://a.example", "proofs": []CashuProof{{Amount: 1, ID: "00", Secret: "s", C: "02"}}},
+			{"mint": "https://b.example", "proofs": []CashuProof{{Amount: 1, ID: "00", Secret: "s", C: "02"}}},
+		},
+	})
+	for _, bad := range []string{
+		"",
+		"lnbc1000",
+		"cashuA!!!",
+		"cashuBpGF0",
+		"cashuA" + base64.URLEncoding.EncodeToString(twoMints),
+		cashuTokenV3("https://mint.example"),
+		cashuTokenV3("https://mint.example", 0),
+	} {
+		if _, err := ParseCashuToken(bad); !errors.Is(err, ErrInvalidCashuToken) {
+			t.Errorf("ParseCashuToken(%q) error = %v, want ErrInvalidCashuToken", bad, err)
+		}
+	}
+}
+
+func TestCashuPay(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	lightning := NewLightningService(database)
+	lightning.Configure(&LNDConfig{NodeType: NodeTypeMock, Host: "settle_after=-1"})
+	monitor := NewInvoiceMonitorService(database, lightning, nil, nil)
+	cashu := NewCashuService(database, lightning, monitor)
+
+	// The mint pays mock invoices by settling them
+	var melted []CashuProof
+	mint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Request string       `json:"request"`
+			Quote   string       `json:"quote"`
+			Inputs  []CashuProof `json:"inputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/v1/melt/quote/bolt11":
+			hash := req.Request[strings.LastIndex(req.Request, "mock1")+len("mock1"):]
+			json.NewEncoder(w).Encode(map[string]interface{}{"quote": hash, "amount": 5000, "fee_reserve": 10, "state": "UNPAID"})
+		case "/v1/melt/bolt11":
+			melted = req.Inputs
+			lightning.SettleMockInvoice(req.Quote)
+			json.NewEncoder(w).Encode(map[string]interface{}{"state": "PAID"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mint.Close()
+
+	token := cashuTokenV3(mint.URL+"/", 4096, 512, 256, 128, 16, 8)
+	if _, err := cashu.Pay(ctx, "aa01", "npub1cashu", "monthly", token); !errors.Is(err, ErrCashuDisabled) {
+		t.Fatalf("expected ErrCashuDisabled, got %v", err)
+	}
+
+	database.SetCashuSettings(ctx, &db.CashuSettings{Enabled: true, Mints: []string{"https://other.example"}})
+	if _, err := cashu.Pay(ctx, "aa01", "npub1cashu", "monthly", token); !errors.Is(err, ErrCashuMintNotAccepted) {
+		t.Fatalf("expected ErrCashuMintNotAccepted, got %v", err)
+	}
+
+	database.SetCashuSettings(ctx, &db.CashuSettings{Enabled: true, Mints: []string{mint.URL}})
+	if _, err := cashu.Pay(ctx, "aa01", "npub1cashu", "monthly", cashuTokenV3(mint.URL, 4096, 512, 256, 128)); !errors.Is(err, ErrCashuInsufficient) {
+		t.Fatalf("expected ErrCashuInsufficient, got %v", err)
+	}
+	if melted != nil {
+		t.Fatal("expected no melt for an insufficient token")
+	}
+
+	result, err := cashu.Pay(ctx, "aa01", "npub1cashu", "monthly", token)
+	if err != nil {
+		t.Fatalf("Pay() error = %v", err)
+	}
+	if result.Status != "paid" || result.AmountSats != 5000 || result.TokenSats != 5016 || result.FeeSats != 16 || result.ExpiresAt == nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(melted) != 6 {
+		t.Errorf("expected the token's proofs melted, got %d", len(melted))
+	}
+
+	if user, _ := database.GetPaidUserByPubkey(ctx, "aa01"); user == nil || user.Status != "active" {
+		t.Errorf("expected an active paid user, got %+v", user)
+	}
+	var paymentType string
+	database.AppDB.QueryRow("SELECT payment_type FROM payment_history WHERE payment_hash = ?", result.PaymentHash).Scan(&paymentType)
+	if paymentType != db.PaymentTypeCashu {
+		t.Errorf("payment_type = %q, want cashu", paymentType)
+	}
+	if payment, _ := database.GetCashuPayment(ctx, result.PaymentHash); payment == nil || payment.Status != "paid" || payment.FeeReserveSats != 10 {
+		t.Errorf("unexpected Cashu payment %+v", payment)
+	}
+}
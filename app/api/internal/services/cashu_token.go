@@ -0,0 +1,276 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrInvalidCashuToken is returned for a token that cannot be parsed.
+var ErrInvalidCashuToken = errors.New("invalid Cashu token")
+
+// CashuToken is a parsed Cashu token (NUT-00) from a single mint.
+type CashuToken struct {
+	Mint   string       `json:"mint"`
+	Unit   string       `json:"unit"`
+	Memo   string       `json:"memo,omitempty"`
+	Proofs []CashuProof `json:"proofs"`
+}
+
+// CashuProof is an ecash proof, in the JSON form mints accept as an input.
+type CashuProof struct {
+	Amount  int64  `json:"amount"`
+	ID      string `json:"id"`
+	Secret  string `json:"secret"`
+	C       string `json:"C"`
+	Witness string `json:"witness,omitempty"`
+}
+
+// Amount returns the total value of the token's proofs.
+func (t *CashuToken) Amount() int64 {
+	var total int64
+	for _, p := range t.Proofs {
+		total += p.Amount
+	}
+	return total
+}
+
+// ParseCashuToken parses a serialized token: "cashuA" followed by base64
+// JSON (V3), or "cashuB" followed by base64 CBOR (V4). A "cashu:" URI prefix
+// is allowed. Tokens spanning several mints are not supported.
+func ParseCashuToken(s string) (*CashuToken, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "cashu:")
+
+	var token *CashuToken
+	var err error
+	switch {
+	case strings.HasPrefix(s, "cashuA"):
+		token, err = parseCashuTokenV3(s[len("cashuA"):])
+	case strings.HasPrefix(s, "cashuB"):
+		token, err = parseCashuTokenV4(s[len("cashuB"):])
+	default:
+		return nil, fmt.Errorf("%w: must start with cashuA or cashuB", ErrInvalidCashuToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Mint == "" {
+		return nil, fmt.Errorf("%w: no mint", ErrInvalidCashuToken)
+	}
+	if token.Unit == "" {
+		token.Unit = "sat"
+	}
+	if len(token.Proofs) == 0 {
+		return nil, fmt.Errorf("%w: no proofs", ErrInvalidCashuToken)
+	}
+	var total int64
+	for _, p := range token.Proofs {
+		if p.Amount <= 0 || p.Amount > math.MaxInt64-total || p.Secret == "" || p.C == "" || p.ID == "" {
+			return nil, fmt.Errorf("%w: malformed proof", ErrInvalidCashuToken)
+		}
+		total += p.Amount
+	}
+	return token, nil
+}
+
+// decodeCashuBase64 decodes URL-safe or standard base64, padded or not.
+func decodeCashuBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if data, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return data, nil
+	}
+	data, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not base64", ErrInvalidCashuToken)
+	}
+	return data, nil
+}
+
+// parseCashuTokenV3 parses the JSON body of a V3 token.
+func parseCashuTokenV3(encoded string) (*CashuToken, error) {
+	data, err := decodeCashuBase64(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var v3 struct {
+		Token []struct {
+			Mint   string       `json:"mint"`
+			Proofs []CashuProof `json:"proofs"`
+		} `json:"token"`
+		Unit string `json:"unit"`
+		Memo string `json:"memo"`
+	}
+	if err := json.Unmarshal(data, &v3); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCashuToken, err)
+	}
+
+	token := &CashuToken{Unit: v3.Unit, Memo: v3.Memo}
+	for _, entry := range v3.Token {
+		if token.Mint != "" && entry.Mint != token.Mint {
+			return nil, fmt.Errorf("%w: tokens from several mints are not supported", ErrInvalidCashuToken)
+		}
+		token.Mint = entry.Mint
+		token.Proofs = append(token.Proofs, entry.Proofs...)
+	}
+	return token, nil
+}
+
+// parseCashuTokenV4 parses the CBOR body of a V4 token, whose keys are
+// abbreviated and whose keyset IDs and signatures are bytes.
+func parseCashuTokenV4(encoded string) (*CashuToken, error) {
+	data, err := decodeCashuBase64(encoded)
+	if err != nil {
+		return nil, err
+	}
+	value, rest, err := decodeCBOR(data, 0)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed CBOR", ErrInvalidCashuToken)
+	}
+
+	root, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed CBOR", ErrInvalidCashuToken)
+	}
+	token := &CashuToken{}
+	token.Mint, _ = root["m"].(string)
+	token.Unit, _ = root["u"].(string)
+	token.Memo, _ = root["d"].(string)
+
+	entries, _ := root["t"].([]interface{})
+	for _, e := range entries {
+		entry, _ := e.(map[string]interface{})
+		id, _ := entry["i"].([]byte)
+		proofs, _ := entry["p"].([]interface{})
+		for _, p := range proofs {
+			proof, _ := p.(map[string]interface{})
+			amount, _ := proof["a"].(uint64)
+			secret, _ := proof["s"].(string)
+			c, _ := proof["c"].([]byte)
+			witness, _ := proof["w"].(string)
+			if amount > math.MaxInt64 {
+				return nil, fmt.Errorf("%w: malformed proof", ErrInvalidCashuToken)
+			}
+			token.Proofs = append(token.Proofs, CashuProof{
+				Amount:  int64(amount),
+				ID:      hex.EncodeToString(id),
+				Secret:  secret,
+				C:       hex.EncodeToString(c),
+				Witness: witness,
+			})
+		}
+	}
+	return token, nil
+}
+
+// maxCBORDepth bounds nesting when decoding untrusted tokens.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the CBOR item at the start of data and returns it with
+// the remaining bytes. It handles the subset V4 tokens use: integers, byte
+// and text strings, arrays, maps with text keys, booleans and null, all of
+// definite length.
+func decodeCBOR(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errors.New("cbor: nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, nil, errors.New("cbor: unexpected end")
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		}
+		return nil, nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+	}
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(data) < n {
+			return nil, nil, errors.New("cbor: unexpected end")
+		}
+		buf := make([]byte, 8)
+		copy(buf[8-n:], data[:n])
+		arg = binary.BigEndian.Uint64(buf)
+		data = data[n:]
+	default:
+		return nil, nil, errors.New("cbor: indefinite lengths are not supported")
+	}
+
+	switch major {
+	case 0:
+		return arg, data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errors.New("cbor: unexpected end")
+		}
+		b := data[:arg]
+		if major == 3 {
+			return string(b), data[arg:], nil
+		}
+		return append([]byte(nil), b...), data[arg:], nil
+	case 4:
+		if arg > uint64(len(data)) {
+			return nil, nil, errors.New("cbor: unexpected end")
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, rest, err := decodeCBOR(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+			data = rest
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, nil, errors.New("cbor: unexpected end")
+		}
+		m := make(map[string]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, rest, err := decodeCBOR(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, nil, errors.New("cbor: map keys must be text")
+			}
+			value, rest, err := decodeCBOR(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[k] = value
+			data = rest
+		}
+		return m, data, nil
+	case 6:
+		// Tags only annotate the item that follows
+		return decodeCBOR(data, depth+1)
+	}
+	return nil, nil, fmt.Errorf("cbor: unsupported major type %d", major)
+}
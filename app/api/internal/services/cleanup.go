@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+const (
+	// cleanupBatchSize is how many events a cleanup deletes per transaction.
+	cleanupBatchSize = 500
+
+	// cleanupBatchPause is how long a cleanup waits between batches, so the
+	// relay gets the write lock while a large cleanup runs.
+	cleanupBatchPause = 20 * time.Millisecond
+)
+
+// EventCleaner deletes events from the live relay database in small batched
+// transactions. It reports the running count after each batch and stops
+// between batches when its context is cancelled.
+type EventCleaner struct {
+	db        *db.DB
+	batchSize int
+	pause     time.Duration
+}
+
+// NewEventCleaner creates a new event cleaner.
+func NewEventCleaner(database *db.DB) *EventCleaner {
+	return &EventCleaner{
+		db:        database,
+		batchSize: cleanupBatchSize,
+		pause:     cleanupBatchPause,
+	}
+}
+
+// DeleteScope deletes the events a scope selects. progress, if not nil, is
+// called with the number deleted so far after each batch. On cancellation it
+// returns the number deleted so far with the context's error.
+func (c *EventCleaner) DeleteScope(ctx context.Context, scope db.RetentionScope, operatorPubkey string, progress func(deleted int64)) (int64, error) {
+	writer, err := c.db.NewRelayWriter()
+	if err != nil {
+		return 0, err
+	}
+	defer writer.Close()
+
+	var deleted int64
+	for {
+		n, err := writer.DeleteRetentionScopeBatch(ctx, scope, operatorPubkey, c.batchSize)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+		if progress != nil {
+			progress(deleted)
+		}
+		if n < int64(c.batchSize) {
+			return deleted, nil
+		}
+		if err := c.wait(ctx); err != nil {
+			return deleted, err
+		}
+	}
+}
+
+// wait pauses between batches, returning early if ctx is done.
+func (c *EventCleaner) wait(ctx context.Context) error {
+	timer := time.NewTimer(c.pause)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
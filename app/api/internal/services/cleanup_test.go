@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestEventCleaner_DeleteScope(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	// nostr-rs-relay keeps tags in their own table
+	if _, err := relayDB.Exec(`CREATE TABLE tag (id INTEGER PRIMARY KEY, event_id INTEGER NOT NULL, name TEXT, value TEXT)`); err != nil {
+		t.Fatalf("failed to create tag table: %v", err)
+	}
+
+	author := strings.Repeat("aa", 32)
+	old := time.Now().AddDate(0, 0, -10).Unix()
+	for n := byte(1); n <= 7; n++ {
+		createdAt := old
+		if n == 7 {
+			createdAt = time.Now().Unix()
+		}
+		insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{ID: strings.Repeat(hex.EncodeToString([]byte{n}), 32), Pubkey: author, Kind: 1, CreatedAt: createdAt})
+		relayDB.Exec(`INSERT INTO tag (event_id, name, value) SELECT MAX(id), 't', 'nostr' FROM event`)
+	}
+
+	cleaner := NewEventCleaner(database)
+	cleaner.batchSize = 2
+	cleaner.pause = 0
+	scope := db.RetentionScope{Before: time.Now().AddDate(0, 0, -1)}
+
+	// Cancel after the first batch
+	cancelCtx, cancel := context.WithCancel(ctx)
+	deleted, err := cleaner.DeleteScope(cancelCtx, scope, "", func(int64) { cancel() })
+	if !errors.Is(err, context.Canceled) || deleted != 2 {
+		t.Fatalf("expected 2 deleted before cancellation, got %d, err %v", deleted, err)
+	}
+
+	var progress []int64
+	deleted, err = cleaner.DeleteScope(ctx, scope, "", func(n int64) { progress = append(progress, n) })
+	if err != nil || deleted != 4 {
+		t.Fatalf("expected the remaining 4 deleted, got %d, err %v", deleted, err)
+	}
+	if len(progress) != 3 || progress[2] != 4 {
+		t.Errorf("expected progress after each batch, got %v", progress)
+	}
+
+	var events, tags int
+	relayDB.QueryRow(`SELECT COUNT(*) FROM event`).Scan(&events)
+	relayDB.QueryRow(`SELECT COUNT(*) FROM tag`).Scan(&tags)
+	if events != 1 || tags != 1 {
+		t.Errorf("expected the recent event and its tag to be kept, got %d events and %d tags", events, tags)
+	}
+}
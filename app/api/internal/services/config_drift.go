@@ -0,0 +1,501 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// Reconcile directions.
+const (
+	ReconcileAdopt     = "adopt"     // keep the file, updating the DB to match
+	ReconcileOverwrite = "overwrite" // rewrite the file from the DB
+)
+
+var (
+	// ErrConfigFileInvalid is returned when adopting a config file that is
+	// not valid TOML or lists invalid pubkeys.
+	ErrConfigFileInvalid = errors.New("config.toml is not valid")
+
+	// ErrNoConfigBaseline is returned when overwriting a config file that
+	// cannot be parsed before Roostr has saved a config to restore.
+	ErrNoConfigBaseline = errors.New("no saved config to restore")
+)
+
+// ConfigDrift describes how config.toml differs from what Roostr expects.
+type ConfigDrift struct {
+	Drifted    bool                 `json:"drifted"`
+	Changes    []relay.ConfigChange `json:"changes"`
+	Error      string               `json:"error,omitempty"` // why the file could not be parsed
+	DetectedAt *time.Time           `json:"detected_at,omitempty"`
+	CheckedAt  *time.Time           `json:"checked_at,omitempty"`
+}
+
+// ReconcileResult is what reconciling config drift changed.
+type ReconcileResult struct {
+	Direction string       `json:"direction"`
+	Added     []string     `json:"added,omitempty"`   // pubkeys added to the DB's active list
+	Removed   []string     `json:"removed,omitempty"` // pubkeys removed from it
+	Drift     *ConfigDrift `json:"drift"`
+}
+
+// ConfigDriftService watches config.toml for manual edits. Roostr rewrites
+// the file whenever the whitelist or blacklist changes, so edits made by
+// hand are otherwise lost without notice. The service compares the file
+// with the last config Roostr wrote, with the access lists taken from the
+// DB, and reconciles them in either direction on request.
+type ConfigDriftService struct {
+	db        *db.DB
+	configMgr *relay.ConfigManager
+	relay     *relay.Relay
+	interval  time.Duration
+	now       func() time.Time
+	state     ConfigDrift
+	seen      string // file hash at the last check, to skip unchanged files
+	written   string // hash of the last write by configMgr saved as the baseline
+	stateMu   sync.RWMutex
+	checkMu   sync.Mutex // one check or reconcile at a time
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+}
+
+// NewConfigDriftService creates a new config drift service.
+func NewConfigDriftService(database *db.DB, configMgr *relay.ConfigManager, relayCtl *relay.Relay) *ConfigDriftService {
+	return &ConfigDriftService{
+		db:        database,
+		configMgr: configMgr,
+		relay:     relayCtl,
+		interval:  10 * time.Second,
+		now:       time.Now,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins watching the config file.
+func (s *ConfigDriftService) Start() {
+	s.mu.Lock()
+	if s.running || s.configMgr == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops watching the config file.
+func (s *ConfigDriftService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *ConfigDriftService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.watch(ctx)
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.watch(ctx)
+		}
+	}
+}
+
+// watch checks the config file when it has changed since the last check.
+func (s *ConfigDriftService) watch(ctx context.Context) {
+	content, err := s.configMgr.ReadRaw()
+	if err != nil {
+		slog.Debug("Failed to read config.toml", "error", err)
+		return
+	}
+	s.stateMu.RLock()
+	unchanged := s.seen == configHash(content)
+	s.stateMu.RUnlock()
+	if unchanged {
+		return
+	}
+	if _, err := s.Check(ctx); err != nil && ctx.Err() == nil {
+		slog.Warn("Failed to check config.toml for manual edits", "error", err)
+	}
+}
+
+// State returns the result of the last check.
+func (s *ConfigDriftService) State() ConfigDrift {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.state
+}
+
+// Check compares config.toml with the config Roostr expects, recording
+// newly found drift in the audit log.
+func (s *ConfigDriftService) Check(ctx context.Context) (*ConfigDrift, error) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+	return s.check(ctx)
+}
+
+func (s *ConfigDriftService) check(ctx context.Context) (*ConfigDrift, error) {
+	if s.configMgr == nil {
+		return &ConfigDrift{Changes: []relay.ConfigChange{}}, nil
+	}
+
+	baseline, err := s.updateBaseline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.configMgr.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	hash := configHash(content)
+
+	drift := ConfigDrift{Changes: []relay.ConfigChange{}}
+	file, err := relay.ParseConfig(content)
+	if err != nil {
+		drift.Drifted = true
+		drift.Error = err.Error()
+	} else {
+		if baseline == nil {
+			// Nothing saved yet, e.g. after upgrading: accept the file as it is
+			baseline = &db.ConfigBaseline{SHA256: hash, Content: string(content), SavedAt: s.now()}
+			if err := s.db.SetConfigBaseline(ctx, baseline); err != nil {
+				return nil, err
+			}
+		}
+		desired, err := s.desired(ctx, baseline, file)
+		if err != nil {
+			return nil, err
+		}
+		drift.Changes = relay.DiffConfig(file, desired)
+		drift.Drifted = len(drift.Changes) > 0
+	}
+
+	now := s.now()
+	drift.CheckedAt = &now
+
+	s.stateMu.Lock()
+	prev := s.state
+	if drift.Drifted {
+		drift.DetectedAt = prev.DetectedAt
+		if drift.DetectedAt == nil {
+			drift.DetectedAt = &now
+		}
+	}
+	s.state = drift
+	s.seen = hash
+	s.stateMu.Unlock()
+
+	if drift.Drifted && prev.DetectedAt == nil {
+		keys := make([]string, len(drift.Changes))
+		for i, c := range drift.Changes {
+			keys[i] = c.Key
+		}
+		slog.Warn("config.toml was edited outside Roostr", "keys", keys, "error", drift.Error)
+		s.db.AddAuditLog(ctx, "config_drift_detected", map[string]interface{}{
+			"keys":  keys,
+			"error": drift.Error,
+		}, "")
+	}
+
+	return &drift, nil
+}
+
+// updateBaseline saves Roostr's latest write to the config file as the
+// baseline, returning the current baseline.
+func (s *ConfigDriftService) updateBaseline(ctx context.Context) (*db.ConfigBaseline, error) {
+	baseline, err := s.db.GetConfigBaseline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	written := s.configMgr.LastWrite()
+	if written == nil || configHash(written) == s.written {
+		return baseline, nil
+	}
+	baseline = &db.ConfigBaseline{SHA256: configHash(written), Content: string(written), SavedAt: s.now()}
+	if err := s.db.SetConfigBaseline(ctx, baseline); err != nil {
+		return nil, err
+	}
+	s.written = baseline.SHA256
+	return baseline, nil
+}
+
+// desired returns the config Roostr expects: the baseline, with the access
+// lists from the DB. file stands in for a baseline that cannot be parsed.
+func (s *ConfigDriftService) desired(ctx context.Context, baseline *db.ConfigBaseline, file *relay.Config) (*relay.Config, error) {
+	var desired *relay.Config
+	if baseline != nil {
+		desired, _ = relay.ParseConfig([]byte(baseline.Content))
+	}
+	if desired == nil {
+		if file == nil {
+			return nil, ErrNoConfigBaseline
+		}
+		copied := *file
+		desired = &copied
+	}
+
+	whitelist, blacklist, err := AccessLists(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	if s.configMgr.DelegatesAccess() {
+		// The admission server enforces the lists, so the file has none
+		whitelist, blacklist = []string{}, []string{}
+	}
+	desired.Authorization.PubkeyWhitelist = whitelist
+	desired.Authorization.PubkeyBlacklist = blacklist
+	return desired, nil
+}
+
+// Reconcile resolves config drift. Adopting keeps the file and updates the
+// DB's active access list to match it; the inactive list cannot be adopted
+// and is cleared on the next sync. Overwriting rewrites the file from the
+// baseline and the DB. Either way the relay is restarted to load the result.
+func (s *ConfigDriftService) Reconcile(ctx context.Context, direction string) (*ReconcileResult, error) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	if s.configMgr == nil {
+		return nil, errors.New("config manager not available")
+	}
+	baseline, err := s.updateBaseline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.configMgr.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	file, parseErr := relay.ParseConfig(content)
+
+	result := &ReconcileResult{Direction: direction}
+	switch direction {
+	case ReconcileAdopt:
+		if parseErr != nil {
+			return nil, fmt.Errorf("%w: %v", ErrConfigFileInvalid, parseErr)
+		}
+		// While the admission server enforces the lists, the file's are
+		// empty and adopting them would clear the DB's
+		if !s.configMgr.DelegatesAccess() {
+			if result.Added, result.Removed, err = s.adoptAccessList(ctx, file); err != nil {
+				return nil, err
+			}
+		}
+		baseline = &db.ConfigBaseline{SHA256: configHash(content), Content: string(content), SavedAt: s.now()}
+		if err := s.db.SetConfigBaseline(ctx, baseline); err != nil {
+			return nil, err
+		}
+
+	case ReconcileOverwrite:
+		desired, err := s.desired(ctx, baseline, file)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.configMgr.WriteAs(desired, "admin", "Overwrote manual edits"); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown direction %q", direction)
+	}
+
+	// nostr-rs-relay only reads the access lists at startup
+	if s.relay != nil {
+		if err := s.relay.Restart(); err != nil {
+			slog.Warn("Failed to restart relay", "error", err)
+		}
+	}
+
+	if result.Drift, err = s.check(ctx); err != nil {
+		return nil, err
+	}
+	s.db.AddAuditLog(ctx, "config_reconciled", map[string]interface{}{
+		"direction": direction,
+		"added":     result.Added,
+		"removed":   result.Removed,
+	}, "")
+	return result, nil
+}
+
+// adoptAccessList makes the DB's active access list match the file's,
+// returning the pubkeys added and removed.
+func (s *ConfigDriftService) adoptAccessList(ctx context.Context, file *relay.Config) (added, removed []string, err error) {
+	mode, _ := s.db.GetAccessMode(ctx)
+	whitelist, blacklist, err := AccessLists(ctx, s.db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fileList, dbList []string
+	switch mode {
+	case "blacklist":
+		fileList, dbList = file.Authorization.PubkeyBlacklist, blacklist
+	case "open":
+		return nil, nil, nil
+	default:
+		fileList, dbList = file.Authorization.PubkeyWhitelist, whitelist
+	}
+
+	inDB := make(map[string]bool, len(dbList))
+	for _, pubkey := range dbList {
+		inDB[pubkey] = true
+	}
+	// Follow grants are recomputed from members' contact lists, so removing
+	// one from the file cannot revoke it
+	granted := map[string]bool{}
+	if mode != "blacklist" {
+		grants, err := s.db.GetFollowGrants(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, g := range grants {
+			granted[g.Pubkey] = true
+		}
+	}
+	inFile := make(map[string]bool, len(fileList))
+	for _, entry := range fileList {
+		pubkey, npub, err := nostr.ValidatePubkey(entry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid pubkey %q", ErrConfigFileInvalid, entry)
+		}
+		inFile[pubkey] = true
+		if inDB[pubkey] {
+			continue
+		}
+		if mode == "blacklist" {
+			err = s.db.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: pubkey, Npub: npub, Reason: "Added to config.toml"})
+		} else {
+			err = s.db.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: pubkey, Npub: npub, AddedBy: "config.toml"})
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		inDB[pubkey] = true
+		added = append(added, pubkey)
+	}
+
+	for _, pubkey := range dbList {
+		if inFile[pubkey] || granted[pubkey] {
+			continue
+		}
+		if mode == "blacklist" {
+			err = s.db.RemoveBlacklistEntry(ctx, pubkey)
+		} else {
+			err = s.db.RemoveWhitelistEntry(ctx, pubkey)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to remove %s: %w", pubkey, err)
+		}
+		removed = append(removed, pubkey)
+	}
+	return added, removed, nil
+}
+
+// AccessLists returns the whitelist and blacklist config.toml should hold.
+// Only the list the access mode enforces is filled; the other is empty so
+// nostr-rs-relay does not enforce both.
+func AccessLists(ctx context.Context, database *db.DB) (whitelist, blacklist []string, err error) {
+	mode, err := database.GetAccessMode(ctx)
+	if err != nil {
+		mode = "whitelist" // Default to whitelist if error
+	}
+
+	whitelist, blacklist = []string{}, []string{}
+	switch mode {
+	case "blacklist":
+		entries, err := database.GetBlacklist(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, e := range entries {
+			blacklist = append(blacklist, e.Pubkey)
+		}
+
+	case "open":
+		// No restrictions
+
+	default:
+		// Whitelist and paid modes, and unknown modes. Pubkeys granted
+		// through members' follows may post too.
+		var err error
+		if whitelist, err = database.GetEffectiveWhitelist(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	return whitelist, blacklist, nil
+}
+
+// applyAccessLists brings the relay in step with the access lists in the
+// DB, for services that change them: the admission server drops its cached
+// copy, or config.toml is rewritten and the relay restarted.
+func applyAccessLists(ctx context.Context, database *db.DB, configMgr *relay.ConfigManager, relayCtl *relay.Relay, admission *AdmissionService) {
+	if admission != nil {
+		admission.Invalidate()
+	}
+	if configMgr == nil || configMgr.DelegatesAccess() {
+		return
+	}
+
+	whitelist, blacklist, err := AccessLists(ctx, database)
+	if err != nil {
+		slog.Warn("Failed to read access lists", "error", err)
+		return
+	}
+	if err := configMgr.UpdateWhitelist(whitelist); err != nil {
+		slog.Warn("Failed to sync whitelist to config.toml", "error", err)
+		return
+	}
+	if err := configMgr.UpdateBlacklist(blacklist); err != nil {
+		slog.Warn("Failed to sync blacklist to config.toml", "error", err)
+		return
+	}
+	// nostr-rs-relay only reads the access lists at startup
+	if relayCtl != nil {
+		if err := relayCtl.Restart(); err != nil {
+			slog.Warn("Failed to restart relay", "error", err)
+		}
+	}
+}
+
+// configHash returns the hex SHA-256 of config file contents.
+func configHash(content []byte) string {
+	if content == nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+func TestConfigDriftService(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	const (
+		pk1 = "1111111111111111111111111111111111111111111111111111111111111111"
+		pk2 = "2222222222222222222222222222222222222222222222222222222222222222"
+	)
+	database.SetAccessMode(ctx, "whitelist")
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: pk1, Npub: "npub1one"})
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("[info]\nname = \"Relay\"\n"), 0644)
+	configMgr := relay.NewConfigManager(path)
+	configMgr.UpdateWhitelist([]string{pk1})
+	drift := NewConfigDriftService(database, configMgr, nil)
+
+	state, err := drift.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if state.Drifted {
+		t.Fatalf("expected no drift after Roostr's own write, got %+v", state.Changes)
+	}
+
+	// Edit the file by hand
+	edit := func(from, to string) {
+		data, _ := os.ReadFile(path)
+		os.WriteFile(path, []byte(strings.Replace(string(data), from, to, 1)), 0644)
+	}
+	edit(`name = "Relay"`, `name = "Edited"`)
+	edit(`"`+pk1+`"`, `"`+pk1+`", "`+pk2+`"`)
+
+	state, _ = drift.Check(ctx)
+	if !state.Drifted || len(state.Changes) != 2 || state.DetectedAt == nil {
+		t.Fatalf("expected two changes, got %+v", state)
+	}
+	if c := state.Changes[1]; c.Key != "authorization.pubkey_whitelist" || len(c.Added) != 1 || c.Added[0] != pk2 {
+		t.Errorf("unexpected whitelist change %+v", c)
+	}
+
+	// Overwriting restores the file from the last write and the DB
+	result, err := drift.Reconcile(ctx, ReconcileOverwrite)
+	if err != nil {
+		t.Fatalf("Reconcile(overwrite) error = %v", err)
+	}
+	if result.Drift.Drifted {
+		t.Errorf("expected no drift after overwriting, got %+v", result.Drift.Changes)
+	}
+	if cfg, _ := configMgr.Read(); cfg.Info.Name != "Relay" || len(cfg.Authorization.PubkeyWhitelist) != 1 {
+		t.Errorf("unexpected config after overwriting %+v", cfg)
+	}
+
+	// Adopting keeps the file and updates the DB
+	edit(`name = "Relay"`, `name = "Edited"`)
+	edit(`"`+pk1+`"`, `"`+pk2+`"`)
+	result, err = drift.Reconcile(ctx, ReconcileAdopt)
+	if err != nil {
+		t.Fatalf("Reconcile(adopt) error = %v", err)
+	}
+	if result.Drift.Drifted || len(result.Added) != 1 || result.Added[0] != pk2 || len(result.Removed) != 1 || result.Removed[0] != pk1 {
+		t.Errorf("unexpected adopt result %+v", result)
+	}
+	if entry, _ := database.GetWhitelistEntryByPubkey(ctx, pk2); entry == nil || entry.AddedBy != "config.toml" {
+		t.Errorf("expected adopted whitelist entry, got %+v", entry)
+	}
+	if entry, _ := database.GetWhitelistEntryByPubkey(ctx, pk1); entry != nil {
+		t.Errorf("expected removed whitelist entry, got %+v", entry)
+	}
+	if cfg, _ := configMgr.Read(); cfg.Info.Name != "Edited" {
+		t.Errorf("expected the file kept, got name %q", cfg.Info.Name)
+	}
+
+	// A file that is not TOML is drift that can only be overwritten
+	os.WriteFile(path, []byte("[info\n"), 0644)
+	if state, _ := drift.Check(ctx); !state.Drifted || state.Error == "" {
+		t.Errorf("expected drift with a parse error, got %+v", state)
+	}
+	if _, err := drift.Reconcile(ctx, ReconcileAdopt); !errors.Is(err, ErrConfigFileInvalid) {
+		t.Errorf("expected ErrConfigFileInvalid, got %v", err)
+	}
+	if _, err := drift.Reconcile(ctx, ReconcileOverwrite); err != nil {
+		t.Fatalf("Reconcile(overwrite) error = %v", err)
+	}
+	if cfg, err := configMgr.Read(); err != nil || cfg.Info.Name != "Edited" {
+		t.Errorf("expected the adopted config restored, got %+v, %v", cfg, err)
+	}
+}
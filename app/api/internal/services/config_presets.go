@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// Limits preset IDs.
+const (
+	PresetFamily        = "family"
+	PresetPaidCommunity = "paid_community"
+	PresetArchive       = "archive"
+)
+
+// LimitsPreset is a set of relay limits and retention chosen to work
+// together for one kind of relay, so operators need not tune each knob.
+type LimitsPreset struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	Description         string `json:"description"`
+	MaxEventBytes       int    `json:"max_event_bytes"`
+	MaxWSMessageBytes   int    `json:"max_ws_message_bytes"`
+	MessagesPerSec      int    `json:"messages_per_sec"`
+	SubscriptionsPerMin int    `json:"subscriptions_per_min"`
+	MaxSubsPerConn      int    `json:"max_subs_per_conn"`
+	EventKindAllowlist  []int  `json:"event_kind_allowlist"` // empty accepts every kind
+	RetentionDays       int64  `json:"retention_days"`       // 0 keeps events forever
+}
+
+// limitsPresets are the curated presets, from smallest to largest.
+var limitsPresets = []LimitsPreset{
+	{
+		ID:                  PresetFamily,
+		Name:                "Family relay",
+		Description:         "A few people on modest hardware. Social kinds only, small events, and a year of history.",
+		MaxEventBytes:       64 * 1024,
+		MaxWSMessageBytes:   128 * 1024,
+		MessagesPerSec:      5,
+		SubscriptionsPerMin: 60,
+		MaxSubsPerConn:      10,
+		// Profiles, notes, follows, DMs, deletions, reposts, reactions,
+		// private messages, zaps, mute and relay lists, long-form posts
+		EventKindAllowlist: []int{0, 1, 3, 4, 5, 6, 7, 13, 14, 16, 1059, 9735, 10000, 10002, 30023},
+		RetentionDays:      365,
+	},
+	{
+		ID:                  PresetPaidCommunity,
+		Name:                "Paid community",
+		Description:         "Members pay to post, so every kind is accepted and kept. Room for busy clients.",
+		MaxEventBytes:       128 * 1024,
+		MaxWSMessageBytes:   256 * 1024,
+		MessagesPerSec:      10,
+		SubscriptionsPerMin: 120,
+		MaxSubsPerConn:      20,
+		EventKindAllowlist:  []int{},
+		RetentionDays:       0,
+	},
+	{
+		ID:                  PresetArchive,
+		Name:                "Archive node",
+		Description:         "Keeps everything it is sent, including large events and bulk imports. Needs a big disk.",
+		MaxEventBytes:       512 * 1024,
+		MaxWSMessageBytes:   1024 * 1024,
+		MessagesPerSec:      50,
+		SubscriptionsPerMin: 300,
+		MaxSubsPerConn:      50,
+		EventKindAllowlist:  []int{},
+		RetentionDays:       0,
+	},
+}
+
+// LimitsPresets returns the curated limits presets.
+func LimitsPresets() []LimitsPreset {
+	return slices.Clone(limitsPresets)
+}
+
+// FindLimitsPreset returns the preset with id, or nil.
+func FindLimitsPreset(id string) *LimitsPreset {
+	for _, p := range limitsPresets {
+		if p.ID == id {
+			return &p
+		}
+	}
+	return nil
+}
+
+// Apply sets the preset's limits in cfg and its retention in policy.
+func (p *LimitsPreset) Apply(cfg *relay.Config, policy *db.RetentionPolicy) {
+	cfg.Limits.MaxEventBytes = p.MaxEventBytes
+	cfg.Limits.MaxWSMessageBytes = p.MaxWSMessageBytes
+	cfg.Limits.MessagesPerSec = p.MessagesPerSec
+	cfg.Limits.SubscriptionsPerMin = p.SubscriptionsPerMin
+	cfg.Limits.MaxSubsPerConn = p.MaxSubsPerConn
+	cfg.Authorization.EventKindAllowlist = slices.Clone(p.EventKindAllowlist)
+	policy.RetentionDays = p.RetentionDays
+}
+
+// Matches reports whether cfg and policy hold the preset's settings.
+func (p *LimitsPreset) Matches(cfg *relay.Config, policy *db.RetentionPolicy) bool {
+	return cfg.Limits.MaxEventBytes == p.MaxEventBytes &&
+		cfg.Limits.MaxWSMessageBytes == p.MaxWSMessageBytes &&
+		cfg.Limits.MessagesPerSec == p.MessagesPerSec &&
+		cfg.Limits.SubscriptionsPerMin == p.SubscriptionsPerMin &&
+		cfg.Limits.MaxSubsPerConn == p.MaxSubsPerConn &&
+		slices.Equal(cfg.Authorization.EventKindAllowlist, p.EventKindAllowlist) &&
+		policy.RetentionDays == p.RetentionDays
+}
+
+// PresetInputs is what a preset recommendation is based on.
+type PresetInputs struct {
+	AccessMode     string          `json:"access_mode"`
+	EventsPerDay   float64         `json:"events_per_day"` // over the last 30 days
+	DiskTotalBytes int64           `json:"disk_total_bytes"`
+	Hardware       HardwareProfile `json:"hardware"`
+}
+
+// PresetRecommendation is the preset suited to a relay, and why.
+type PresetRecommendation struct {
+	Preset  LimitsPreset `json:"preset"`
+	Reasons []string     `json:"reasons"`
+	Inputs  PresetInputs `json:"inputs"`
+}
+
+const (
+	// archiveEventsPerDay is the event volume that calls for an archive node.
+	archiveEventsPerDay = 10000
+
+	// archiveDiskBytes and archiveMemoryBytes are the least disk and RAM an
+	// archive node is recommended on.
+	archiveDiskBytes   = 500 << 30 // 500 GiB
+	archiveMemoryBytes = 4 << 30   // 4 GiB
+
+	// smallDiskBytes is the disk size below which only the family preset is
+	// recommended.
+	smallDiskBytes = 32 << 30 // 32 GiB
+)
+
+// RecommendLimitsPreset picks the preset suited to the observed event
+// volume, the hardware and the access mode.
+func RecommendLimitsPreset(in PresetInputs) *PresetRecommendation {
+	rec := func(id string, reasons ...string) *PresetRecommendation {
+		return &PresetRecommendation{Preset: *FindLimitsPreset(id), Reasons: reasons, Inputs: in}
+	}
+
+	if in.Hardware.Small {
+		return rec(PresetFamily, "The hardware is small (little RAM, one CPU or an SD card), so events are kept small and expire after a year.")
+	}
+	if in.DiskTotalBytes > 0 && in.DiskTotalBytes < smallDiskBytes {
+		return rec(PresetFamily, fmt.Sprintf("The disk holds only %d GiB, so events expire after a year.", in.DiskTotalBytes>>30))
+	}
+
+	bigDisk := in.DiskTotalBytes >= archiveDiskBytes
+	enoughMemory := in.Hardware.MemoryBytes == 0 || in.Hardware.MemoryBytes >= archiveMemoryBytes
+	if in.EventsPerDay >= archiveEventsPerDay && bigDisk && enoughMemory {
+		return rec(PresetArchive,
+			fmt.Sprintf("The relay stores about %.0f events a day.", in.EventsPerDay),
+			"The disk and RAM are large enough to keep everything.")
+	}
+
+	if in.AccessMode == "paid" {
+		reasons := []string{"Paid access is on, so members' events are kept."}
+		if in.EventsPerDay >= archiveEventsPerDay {
+			reasons = append(reasons, "The event volume suits an archive node, but the disk or RAM is too small for one.")
+		}
+		return rec(PresetPaidCommunity, reasons...)
+	}
+	if in.EventsPerDay >= 1000 {
+		return rec(PresetPaidCommunity, fmt.Sprintf("The relay stores about %.0f events a day, more than a family relay.", in.EventsPerDay))
+	}
+	return rec(PresetFamily, fmt.Sprintf("The relay stores about %.0f events a day.", in.EventsPerDay))
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+func TestLimitsPresetApply(t *testing.T) {
+	cfg := &relay.Config{Authorization: relay.AuthorizationConfig{EventKindAllowlist: []int{1}}}
+	policy := &db.RetentionPolicy{RetentionDays: 90, HonorNIP09: true}
+
+	for _, p := range LimitsPresets() {
+		if p.Matches(cfg, policy) {
+			t.Errorf("expected %s not to match before applying", p.ID)
+		}
+	}
+
+	preset := FindLimitsPreset(PresetPaidCommunity)
+	preset.Apply(cfg, policy)
+	if !preset.Matches(cfg, policy) {
+		t.Errorf("expected %s to match after applying", preset.ID)
+	}
+	if len(cfg.Authorization.EventKindAllowlist) != 0 || policy.RetentionDays != 0 || !policy.HonorNIP09 {
+		t.Errorf("unexpected settings after applying: %+v, %+v", cfg.Authorization, policy)
+	}
+	if FindLimitsPreset(PresetFamily).Matches(cfg, policy) {
+		t.Error("expected the family preset not to match")
+	}
+	if FindLimitsPreset("huge") != nil {
+		t.Error("expected no preset for an unknown ID")
+	}
+}
+
+func TestRecommendLimitsPreset(t *testing.T) {
+	big := HardwareProfile{MemoryBytes: 8 << 30, CPUs: 4, Storage: StorageSSD}
+	tests := []struct {
+		name string
+		in   PresetInputs
+		want string
+	}{
+		{"small hardware", PresetInputs{AccessMode: "paid", EventsPerDay: 50000, DiskTotalBytes: 1 << 40, Hardware: HardwareProfile{CPUs: 1, Small: true}}, PresetFamily},
+		{"small disk", PresetInputs{EventsPerDay: 5000, DiskTotalBytes: 16 << 30, Hardware: big}, PresetFamily},
+		{"busy relay on big hardware", PresetInputs{AccessMode: "whitelist", EventsPerDay: 20000, DiskTotalBytes: 1 << 40, Hardware: big}, PresetArchive},
+		{"busy relay on a small disk", PresetInputs{AccessMode: "paid", EventsPerDay: 20000, DiskTotalBytes: 100 << 30, Hardware: big}, PresetPaidCommunity},
+		{"paid relay", PresetInputs{AccessMode: "paid", EventsPerDay: 10, DiskTotalBytes: 100 << 30, Hardware: big}, PresetPaidCommunity},
+		{"busy whitelist relay", PresetInputs{AccessMode: "whitelist", EventsPerDay: 2000, DiskTotalBytes: 100 << 30, Hardware: big}, PresetPaidCommunity},
+		{"quiet whitelist relay", PresetInputs{AccessMode: "whitelist", EventsPerDay: 40, DiskTotalBytes: 100 << 30, Hardware: big}, PresetFamily},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := RecommendLimitsPreset(tt.in)
+			if rec.Preset.ID != tt.want || len(rec.Reasons) == 0 {
+				t.Errorf("RecommendLimitsPreset() = %s %v, want %s", rec.Preset.ID, rec.Reasons, tt.want)
+			}
+		})
+	}
+}
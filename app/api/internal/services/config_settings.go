@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/roostr/roostr/app/api/internal/config"
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// ApplyConfig applies the settings that can change while Roostr runs: mail,
+// notification relays and the backup directory, and the saved settings the
+// config file manages. It is called at startup and on reload. Each managed
+// setting is applied on its own, so one invalid section does not hold back
+// the others; the errors are joined.
+func (s *Services) ApplyConfig(ctx context.Context, cfg *config.Config) error {
+	s.Backup.Configure(cfg.BackupDir, cfg.ConfigPath)
+	s.Notifier.ConfigureSMTP(SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		TLS:      cfg.SMTPTLS,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	})
+	s.Notifier.ConfigureRelays(cfg.NotificationRelays)
+
+	var errs []error
+	if cfg.BackupSchedule != nil {
+		if err := s.applyBackupSchedule(ctx, cfg.BackupSchedule); err != nil {
+			errs = append(errs, fmt.Errorf("backups.schedule: %w", err))
+		}
+	}
+	if cfg.RateLimits != nil {
+		if err := s.applyRateLimits(ctx, cfg.RateLimits); err != nil {
+			errs = append(errs, fmt.Errorf("rate_limits: %w", err))
+		}
+	}
+	for _, wh := range cfg.Webhooks {
+		if err := s.applyWebhook(ctx, wh); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks %s: %w", wh.URL, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ConfigBackupSchedule returns the backup schedule set in the config file.
+func ConfigBackupSchedule(cfg *config.BackupSchedule) db.BackupSchedule {
+	schedule := db.BackupSchedule{
+		Enabled:   cfg.Enabled,
+		Frequency: cfg.Frequency,
+		Hour:      cfg.Hour,
+		Weekday:   cfg.Weekday,
+		Retain:    cfg.Retain,
+		Encrypt:   cfg.Encrypt,
+	}
+	if schedule.Frequency == "" {
+		schedule.Frequency = "daily"
+	}
+	return schedule
+}
+
+func (s *Services) applyBackupSchedule(ctx context.Context, cfg *config.BackupSchedule) error {
+	schedule := ConfigBackupSchedule(cfg)
+	if err := ValidateBackupSchedule(&schedule); err != nil {
+		return err
+	}
+	if schedule.Encrypt {
+		if passphrase, _ := s.db.GetBackupPassphrase(ctx); passphrase == "" {
+			return ErrBackupPassphraseNotSet
+		}
+	}
+
+	current, err := s.db.GetBackupSchedule(ctx)
+	if err != nil {
+		return err
+	}
+	if *current == schedule {
+		return nil
+	}
+	return s.db.SetBackupSchedule(ctx, &schedule)
+}
+
+func (s *Services) applyRateLimits(ctx context.Context, cfg *config.RateLimits) error {
+	limit := func(l config.RateLimit) db.RateLimit {
+		return db.RateLimit{PerMinute: l.PerMinute, Burst: l.Burst}
+	}
+	settings := db.RateLimitSettings{
+		Enabled:           cfg.Enabled,
+		Signup:            limit(cfg.Signup),
+		Public:            limit(cfg.Public),
+		API:               limit(cfg.API),
+		Events:            limit(cfg.Events),
+		AutoBanViolations: cfg.AutoBanViolations,
+		AutoBanMinutes:    cfg.AutoBanMinutes,
+	}
+	if err := ValidateRateLimitSettings(&settings); err != nil {
+		return err
+	}
+
+	current, err := s.db.GetRateLimitSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if *current == settings {
+		return nil
+	}
+	if err := s.db.SetRateLimitSettings(ctx, &settings); err != nil {
+		return err
+	}
+	return s.RateLimit.Reload(ctx)
+}
+
+// applyWebhook creates the webhook, or updates the saved webhook with the
+// same URL. Without a secret in the file, a saved webhook keeps its secret
+// and a new one gets a generated secret.
+func (s *Services) applyWebhook(ctx context.Context, cfg config.Webhook) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	if len(cfg.Events) == 0 {
+		return fmt.Errorf("at least one event is required")
+	}
+	for _, event := range cfg.Events {
+		if !IsWebhookEvent(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	webhooks, err := s.db.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, wh := range webhooks {
+		if wh.URL != cfg.URL {
+			continue
+		}
+		updated := wh
+		updated.Events = cfg.Events
+		updated.Description = cfg.Description
+		updated.Enabled = !cfg.Disabled
+		if cfg.Secret != "" {
+			updated.Secret = cfg.Secret
+		}
+		if slices.Equal(updated.Events, wh.Events) && updated.Description == wh.Description &&
+			updated.Enabled == wh.Enabled && updated.Secret == wh.Secret {
+			return nil
+		}
+		return s.db.UpdateWebhook(ctx, &updated)
+	}
+
+	wh := &db.Webhook{
+		URL:         cfg.URL,
+		Events:      cfg.Events,
+		Description: cfg.Description,
+		Enabled:     !cfg.Disabled,
+		Secret:      cfg.Secret,
+	}
+	if wh.Secret == "" {
+		wh.Secret = GenerateWebhookSecret()
+	}
+	return s.db.CreateWebhook(ctx, wh)
+}
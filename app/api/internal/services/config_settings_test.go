@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/config"
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestServices_ApplyConfig(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	svc := &Services{
+		db:        database,
+		Backup:    NewBackupService(database),
+		Notifier:  NewNotifier(database, nil),
+		RateLimit: NewRateLimitService(database),
+	}
+
+	database.CreateWebhook(ctx, &db.Webhook{URL: "https://hooks.example.com/a", Secret: "kept", Events: []string{"user.expired"}, Enabled: true})
+
+	cfg := &config.Config{
+		BackupDir:          t.TempDir(),
+		SMTPHost:           "smtp.example.com",
+		SMTPFrom:           "relay@example.com",
+		NotificationRelays: []string{"wss://notify.example.com"},
+		BackupSchedule:     &config.BackupSchedule{Enabled: true, Hour: 4, Retain: 3},
+		RateLimits: &config.RateLimits{
+			Enabled: true,
+			Signup:  config.RateLimit{PerMinute: 2, Burst: 1},
+			Public:  config.RateLimit{PerMinute: 100, Burst: 50},
+			API:     config.RateLimit{PerMinute: 500, Burst: 100},
+			Events:  config.RateLimit{PerMinute: 60, Burst: 30},
+		},
+		Webhooks: []config.Webhook{
+			{URL: "https://hooks.example.com/a", Events: []string{"invoice.paid"}},
+			{URL: "https://hooks.example.com/b", Events: []string{"sync.completed"}, Disabled: true},
+		},
+	}
+	if err := svc.ApplyConfig(ctx, cfg); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+
+	if !svc.Notifier.EmailAvailable() || svc.Backup.backupDir() != cfg.BackupDir {
+		t.Error("expected mail and the backup directory to be configured")
+	}
+	if schedule, _ := database.GetBackupSchedule(ctx); !schedule.Enabled || schedule.Frequency != "daily" || schedule.Hour != 4 {
+		t.Errorf("unexpected backup schedule: %+v", schedule)
+	}
+	if limits, _ := database.GetRateLimitSettings(ctx); limits.Signup.PerMinute != 2 {
+		t.Errorf("unexpected rate limits: %+v", limits)
+	}
+
+	webhooks, _ := database.GetWebhooks(ctx)
+	if len(webhooks) != 2 {
+		t.Fatalf("expected 2 webhooks, got %d", len(webhooks))
+	}
+	if webhooks[0].Secret != "kept" || webhooks[0].Events[0] != "invoice.paid" {
+		t.Errorf("expected the saved webhook to be updated and keep its secret, got %+v", webhooks[0])
+	}
+	if webhooks[1].Enabled || webhooks[1].Secret == "" {
+		t.Errorf("expected a disabled webhook with a generated secret, got %+v", webhooks[1])
+	}
+
+	// Invalid sections are reported and skipped
+	cfg.BackupSchedule = &config.BackupSchedule{Frequency: "hourly"}
+	cfg.Webhooks = append(cfg.Webhooks, config.Webhook{URL: "https://hooks.example.com/c", Events: []string{"nope"}})
+	err := svc.ApplyConfig(ctx, cfg)
+	if err == nil || !errors.Is(err, ErrInvalidBackupSchedule) {
+		t.Errorf("expected an invalid schedule error, got %v", err)
+	}
+	if webhooks, _ := database.GetWebhooks(ctx); len(webhooks) != 2 {
+		t.Errorf("expected the invalid webhook to be skipped, got %d webhooks", len(webhooks))
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Errors returned when a coupon cannot be applied.
+var (
+	ErrCouponNotFound        = errors.New("coupon code not found")
+	ErrCouponExpired         = errors.New("coupon code has expired")
+	ErrCouponUsedUp          = db.ErrCouponUsedUp
+	ErrCouponAlreadyRedeemed = errors.New("coupon code was already redeemed by this pubkey")
+	ErrCouponWrongTier       = errors.New("coupon code is not valid for this tier")
+	ErrTierNotFound          = errors.New("pricing tier not found")
+	ErrTierDisabled          = errors.New("pricing tier is disabled")
+)
+
+// couponCodePattern is the form of a coupon code after normalizing.
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// couponCodeAlphabet leaves out characters that are easily confused.
+const couponCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// CouponQuote is the price of a tier after a coupon.
+type CouponQuote struct {
+	Code         string `json:"code"`
+	TierID       string `json:"tier_id"`
+	PriceSats    int64  `json:"price_sats"`
+	DiscountSats int64  `json:"discount_sats"`
+	AmountSats   int64  `json:"amount_sats"`
+	Free         bool   `json:"free"`
+}
+
+// CouponService applies coupon codes to paid access signups and tracks who
+// redeemed them.
+type CouponService struct {
+	db  *db.DB
+	now func() time.Time
+}
+
+// NewCouponService creates a new coupon service.
+func NewCouponService(database *db.DB) *CouponService {
+	return &CouponService{db: database, now: time.Now}
+}
+
+// NormalizeCouponCode returns a coupon code as it is stored. Codes are not
+// case sensitive.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// GenerateCouponCode returns a random eight character code.
+func GenerateCouponCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = couponCodeAlphabet[int(b[i])%len(couponCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// ValidateCoupon checks a coupon's terms before it is saved. The code must
+// already be normalized.
+func ValidateCoupon(c *db.Coupon) error {
+	if !couponCodePattern.MatchString(c.Code) {
+		return fmt.Errorf("code must be 3-32 letters, digits, dashes or underscores")
+	}
+	switch c.Kind {
+	case db.CouponPercent:
+		if c.Value < 1 || c.Value > 100 {
+			return fmt.Errorf("percent discount must be between 1 and 100")
+		}
+	case db.CouponFixed:
+		if c.Value < 1 {
+			return fmt.Errorf("fixed discount must be at least 1 sat")
+		}
+	case db.CouponFree:
+		c.Value = 0
+	default:
+		return fmt.Errorf("kind must be percent, fixed or free")
+	}
+	if c.MaxUses != nil && *c.MaxUses < 1 {
+		return fmt.Errorf("max_uses must be at least 1")
+	}
+	return nil
+}
+
+// Quote checks that a pubkey can use a coupon for a tier and returns the
+// discounted price. A discount that covers the whole price is free access.
+func (s *CouponService) Quote(ctx context.Context, code, pubkey, tierID string) (*CouponQuote, error) {
+	coupon, err := s.db.GetCoupon(ctx, NormalizeCouponCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if coupon == nil || !coupon.Enabled {
+		return nil, ErrCouponNotFound
+	}
+	if coupon.ExpiresAt != nil && !s.now().Before(*coupon.ExpiresAt) {
+		return nil, ErrCouponExpired
+	}
+	if coupon.TierID != "" && coupon.TierID != tierID {
+		return nil, ErrCouponWrongTier
+	}
+
+	// The pubkey's own open invoice holds a use that a new invoice replaces
+	redemption, err := s.db.GetCouponRedemption(ctx, coupon.Code, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	uses := coupon.Uses
+	if redemption != nil {
+		if redemption.Status == "redeemed" {
+			return nil, ErrCouponAlreadyRedeemed
+		}
+		if redemption.ExpiresAt != nil && redemption.ExpiresAt.After(s.now()) {
+			uses--
+		}
+	}
+	if coupon.MaxUses != nil && uses >= *coupon.MaxUses {
+		return nil, ErrCouponUsedUp
+	}
+
+	tier, err := s.tier(ctx, tierID)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &CouponQuote{Code: coupon.Code, TierID: tier.ID, PriceSats: tier.AmountSats}
+	switch coupon.Kind {
+	case db.CouponPercent:
+		quote.DiscountSats = tier.AmountSats * coupon.Value / 100
+	case db.CouponFixed:
+		quote.DiscountSats = min(coupon.Value, tier.AmountSats)
+	case db.CouponFree:
+		quote.DiscountSats = tier.AmountSats
+	}
+	quote.AmountSats = tier.AmountSats - quote.DiscountSats
+	quote.Free = quote.AmountSats == 0
+	return quote, nil
+}
+
+// Reserve holds a use of the coupon for a pubkey until their invoice is paid
+// or expires.
+func (s *CouponService) Reserve(ctx context.Context, quote *CouponQuote, pubkey, paymentHash string, expiresAt time.Time) error {
+	return s.db.AddCouponRedemption(ctx, &db.CouponRedemption{
+		Code:         quote.Code,
+		Pubkey:       pubkey,
+		PaymentHash:  paymentHash,
+		TierID:       quote.TierID,
+		AmountSats:   quote.AmountSats,
+		DiscountSats: quote.DiscountSats,
+		Status:       "pending",
+		ExpiresAt:    &expiresAt,
+	})
+}
+
+// RedeemFree records a free-access redemption.
+func (s *CouponService) RedeemFree(ctx context.Context, quote *CouponQuote, pubkey string) error {
+	return s.db.AddCouponRedemption(ctx, &db.CouponRedemption{
+		Code:         quote.Code,
+		Pubkey:       pubkey,
+		TierID:       quote.TierID,
+		DiscountSats: quote.DiscountSats,
+		Status:       "redeemed",
+	})
+}
+
+// tier returns an enabled pricing tier by ID.
+func (s *CouponService) tier(ctx context.Context, tierID string) (*db.PricingTier, error) {
+	tiers, err := s.db.GetPricingTiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tiers {
+		if t.ID != tierID {
+			continue
+		}
+		if !t.Enabled {
+			return nil, fmt.Errorf("%w: %s", ErrTierDisabled, tierID)
+		}
+		return &t, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTierNotFound, tierID)
+}
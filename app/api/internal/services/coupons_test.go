@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestValidateCoupon(t *testing.T) {
+	one, zero := 1, 0
+	tests := []struct {
+		coupon db.Coupon
+		ok     bool
+	}{
+		{db.Coupon{Code: "FRIENDS", Kind: db.CouponPercent, Value: 50}, true},
+		{db.Coupon{Code: "SATS-1000", Kind: db.CouponFixed, Value: 1000, MaxUses: &one}, true},
+		{db.Coupon{Code: "INVITE", Kind: db.CouponFree}, true},
+		{db.Coupon{Code: "AB", Kind: db.CouponFree}, false},
+		{db.Coupon{Code: "lower", Kind: db.CouponFree}, false},
+		{db.Coupon{Code: "HALF", Kind: db.CouponPercent, Value: 101}, false},
+		{db.Coupon{Code: "NONE", Kind: db.CouponFixed}, false},
+		{db.Coupon{Code: "BOGUS", Kind: "bogus"}, false},
+		{db.Coupon{Code: "ZERO", Kind: db.CouponFree, MaxUses: &zero}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateCoupon(&tt.coupon); (err == nil) != tt.ok {
+			t.Errorf("ValidateCoupon(%+v) error = %v, want ok %v", tt.coupon, err, tt.ok)
+		}
+	}
+}
+
+func TestCouponQuote(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	coupons := NewCouponService(database)
+
+	one := 1
+	past := time.Now().Add(-time.Hour)
+	for _, c := range []db.Coupon{
+		{Code: "HALF", Kind: db.CouponPercent, Value: 50, Enabled: true},
+		{Code: "BIGFIXED", Kind: db.CouponFixed, Value: 1000000, Enabled: true},
+		{Code: "ONCE", Kind: db.CouponFixed, Value: 1000, MaxUses: &one, Enabled: true},
+		{Code: "YEARLY", Kind: db.CouponFree, TierID: "yearly", Enabled: true},
+		{Code: "OLD", Kind: db.CouponFree, ExpiresAt: &past, Enabled: true},
+		{Code: "OFF", Kind: db.CouponFree},
+	} {
+		if _, err := database.CreateCoupon(ctx, &c); err != nil {
+			t.Fatalf("CreateCoupon() error = %v", err)
+		}
+	}
+
+	quote, err := coupons.Quote(ctx, " half ", "aa01", "monthly")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+	if quote.Code != "HALF" || quote.PriceSats != 5000 || quote.AmountSats != 2500 || quote.Free {
+		t.Errorf("unexpected quote %+v", quote)
+	}
+
+	if quote, _ := coupons.Quote(ctx, "BIGFIXED", "aa01", "monthly"); quote == nil || !quote.Free || quote.DiscountSats != 5000 {
+		t.Errorf("expected a discount covering the price to be free, got %+v", quote)
+	}
+
+	for code, want := range map[string]error{
+		"MISSING": ErrCouponNotFound,
+		"OFF":     ErrCouponNotFound,
+		"OLD":     ErrCouponExpired,
+		"YEARLY":  ErrCouponWrongTier,
+	} {
+		if _, err := coupons.Quote(ctx, code, "aa01", "monthly"); !errors.Is(err, want) {
+			t.Errorf("Quote(%s) error = %v, want %v", code, err, want)
+		}
+	}
+
+	// An open invoice holds the only use, except for the pubkey holding it
+	quote, _ = coupons.Quote(ctx, "ONCE", "aa01", "monthly")
+	if err := coupons.Reserve(ctx, quote, "aa01", "hash1", time.Now().Add(15*time.Minute)); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if _, err := coupons.Quote(ctx, "ONCE", "aa02", "monthly"); !errors.Is(err, ErrCouponUsedUp) {
+		t.Errorf("expected ErrCouponUsedUp for another pubkey, got %v", err)
+	}
+	if _, err := coupons.Quote(ctx, "ONCE", "aa01", "monthly"); err != nil {
+		t.Errorf("expected the holder to get a new quote, got %v", err)
+	}
+
+	if err := database.RedeemCouponPayment(ctx, "hash1"); err != nil {
+		t.Fatalf("RedeemCouponPayment() error = %v", err)
+	}
+	if _, err := coupons.Quote(ctx, "ONCE", "aa01", "monthly"); !errors.Is(err, ErrCouponAlreadyRedeemed) {
+		t.Errorf("expected ErrCouponAlreadyRedeemed, got %v", err)
+	}
+	redemptions, _ := database.GetCouponRedemptions(ctx, "ONCE")
+	if len(redemptions) != 1 || redemptions[0].Status != "redeemed" || redemptions[0].RedeemedAt == nil {
+		t.Errorf("unexpected redemptions %+v", redemptions)
+	}
+}
+
+func TestCouponQuoteTier(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	coupons := NewCouponService(database)
+
+	if _, err := database.CreateCoupon(ctx, &db.Coupon{Code: "HALF", Kind: db.CouponPercent, Value: 50, Enabled: true}); err != nil {
+		t.Fatalf("CreateCoupon() error = %v", err)
+	}
+	if _, err := coupons.Quote(ctx, "HALF", "aa01", "missing"); !errors.Is(err, ErrTierNotFound) {
+		t.Errorf("expected ErrTierNotFound, got %v", err)
+	}
+
+	tiers, _ := database.GetPricingTiers(ctx)
+	tier := tiers[0]
+	tier.Enabled = false
+	if err := database.UpdatePricingTier(ctx, tier); err != nil {
+		t.Fatalf("UpdatePricingTier() error = %v", err)
+	}
+	if _, err := coupons.Quote(ctx, "HALF", "aa01", tier.ID); !errors.Is(err, ErrTierDisabled) {
+		t.Errorf("expected ErrTierDisabled, got %v", err)
+	}
+}
+
+func TestCouponReservationPerInvoice(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	coupons := NewCouponService(database)
+
+	one := 1
+	if _, err := database.CreateCoupon(ctx, &db.Coupon{Code: "ONCE", Kind: db.CouponFixed, Value: 1000, MaxUses: &one, Enabled: true}); err != nil {
+		t.Fatalf("CreateCoupon() error = %v", err)
+	}
+
+	// Two open invoices for the same pubkey hold one use between them
+	expiresAt := time.Now().Add(15 * time.Minute)
+	for _, hash := range []string{"hash1", "hash2"} {
+		quote, err := coupons.Quote(ctx, "ONCE", "aa01", "monthly")
+		if err != nil {
+			t.Fatalf("Quote() error = %v", err)
+		}
+		if err := coupons.Reserve(ctx, quote, "aa01", hash, expiresAt); err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+	}
+	if c, _ := database.GetCoupon(ctx, "ONCE"); c.Uses != 1 {
+		t.Errorf("expected 1 use held, got %d", c.Uses)
+	}
+
+	// Paying the older invoice still redeems the coupon
+	if err := database.RedeemCouponPayment(ctx, "hash1"); err != nil {
+		t.Fatalf("RedeemCouponPayment() error = %v", err)
+	}
+	redemptions, _ := database.GetCouponRedemptions(ctx, "ONCE")
+	if len(redemptions) != 1 || redemptions[0].PaymentHash != "hash1" || redemptions[0].Status != "redeemed" {
+		t.Errorf("expected the first invoice redeemed, got %+v", redemptions)
+	}
+
+	// Paying the other one afterwards uses nothing more
+	if err := database.RedeemCouponPayment(ctx, "hash2"); err != nil {
+		t.Fatalf("RedeemCouponPayment() error = %v", err)
+	}
+	if c, _ := database.GetCoupon(ctx, "ONCE"); c.Uses != 1 {
+		t.Errorf("expected 1 use, got %d", c.Uses)
+	}
+}
+
+func TestGrantCouponAccess(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	monitor := NewInvoiceMonitorService(database, nil, nil, nil)
+	expiresAt, err := monitor.GrantCouponAccess(ctx, "aa01", "npub1free", "monthly", "INVITE")
+	if err != nil {
+		t.Fatalf("GrantCouponAccess() error = %v", err)
+	}
+	if expiresAt == nil || expiresAt.Before(time.Now().AddDate(0, 0, 29)) {
+		t.Errorf("expected a monthly expiry, got %v", expiresAt)
+	}
+
+	user, _ := database.GetPaidUserByPubkey(ctx, "aa01")
+	if user == nil || user.Status != "active" || user.AmountSats != 0 {
+		t.Errorf("unexpected paid user %+v", user)
+	}
+	entry, _ := database.GetWhitelistEntryByPubkey(ctx, "aa01")
+	if entry == nil || entry.AddedBy != "coupon:INVITE" {
+		t.Errorf("expected whitelist entry added by the coupon, got %+v", entry)
+	}
+}
+
+func TestCouponConcurrentRedemptions(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	coupons := NewCouponService(database)
+
+	two := 2
+	if _, err := database.CreateCoupon(ctx, &db.Coupon{Code: "INVITE", Kind: db.CouponFree, MaxUses: &two, Enabled: true}); err != nil {
+		t.Fatalf("CreateCoupon() error = %v", err)
+	}
+
+	// Every signup is quoted before any redeems, as when they race
+	const signups = 10
+	quotes := make([]*CouponQuote, signups)
+	for i := range quotes {
+		quote, err := coupons.Quote(ctx, "INVITE", fmt.Sprintf("bb%02d", i), "monthly")
+		if err != nil {
+			t.Fatalf("Quote() error = %v", err)
+		}
+		quotes[i] = quote
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed, usedUp := 0, 0
+	for i, quote := range quotes {
+		wg.Add(1)
+		go func(pubkey string, quote *CouponQuote) {
+			defer wg.Done()
+			err := coupons.RedeemFree(ctx, quote, pubkey)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				redeemed++
+			case errors.Is(err, ErrCouponUsedUp):
+				usedUp++
+			default:
+				t.Errorf("RedeemFree() error = %v", err)
+			}
+		}(fmt.Sprintf("bb%02d", i), quote)
+	}
+	wg.Wait()
+
+	if redeemed != 2 || usedUp != signups-2 {
+		t.Errorf("expected 2 redemptions and %d used up, got %d and %d", signups-2, redeemed, usedUp)
+	}
+	if redemptions, _ := database.GetCouponRedemptions(ctx, "INVITE"); len(redemptions) != 2 {
+		t.Errorf("expected 2 stored redemptions, got %d", len(redemptions))
+	}
+}
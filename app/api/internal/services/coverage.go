@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+const (
+	// coverageWindow is how far back a coverage check samples events.
+	coverageWindow = 7 * 24 * time.Hour
+
+	// coverageGracePeriod skips events this new, which a sync may not have
+	// picked up yet.
+	coverageGracePeriod = 10 * time.Minute
+
+	// coverageSampleSize bounds how many events are requested from each relay.
+	coverageSampleSize = 100
+
+	// coverageMaxMissing bounds how many missing event IDs are kept.
+	coverageMaxMissing = 20
+
+	// coverageCheckAge is how long a coverage check is used before the
+	// background worker runs it again.
+	coverageCheckAge = 24 * time.Hour
+)
+
+// CoverageService compares mirrored members' recent events on the sync
+// relays with the events stored locally, so the operator can see whether
+// sync is actually keeping up. Each sync pubkey is checked once a day.
+type CoverageService struct {
+	db       *db.DB
+	hardware *HardwareService
+	interval time.Duration
+	now      func() time.Time
+	relays   func(context.Context) []string
+	query    func(ctx context.Context, url string, filter nostr.Filter, callback func(*nostr.SyncEvent)) error
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// NewCoverageService creates a new mirror coverage service.
+func NewCoverageService(database *db.DB) *CoverageService {
+	return &CoverageService{
+		db:       database,
+		interval: time.Hour,
+		now:      time.Now,
+		relays: func(ctx context.Context) []string {
+			return SyncRelayURLs(ctx, database)
+		},
+		query:  queryRelay,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start begins the background coverage worker.
+func (s *CoverageService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the coverage worker.
+func (s *CoverageService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *CoverageService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.checkDue(ctx)
+		}
+	}
+}
+
+// checkDue checks the sync pubkeys that have never been checked or whose
+// last check is older than coverageCheckAge (longer in low-power mode).
+func (s *CoverageService) checkDue(ctx context.Context) {
+	if !s.db.IsRelayDBConnected() {
+		return
+	}
+	pubkeys, err := s.db.GetSyncPubkeys(ctx)
+	if err != nil {
+		slog.Error("Failed to list sync pubkeys for coverage check", "error", err)
+		return
+	}
+	checked, err := s.db.GetMirrorCoverages(ctx)
+	if err != nil {
+		slog.Error("Failed to get mirror coverage", "error", err)
+		return
+	}
+
+	staleBefore := s.now().Add(-s.hardware.Tuning().refreshAge(coverageCheckAge))
+	for _, pk := range pubkeys {
+		if c, ok := checked[pk.Pubkey]; ok && c.CheckedAt.After(staleBefore) {
+			continue
+		}
+		if _, err := s.Check(ctx, pk.Pubkey); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Failed to check mirror coverage", "pubkey", pk.Pubkey, "error", err)
+		}
+	}
+}
+
+// Check samples a pubkey's recent events on the sync relays, looks each one
+// up locally, and saves the result. Ephemeral events, kinds excluded by the
+// data residency policy, and events newer than coverageGracePeriod are not
+// sampled. A replaceable event counts as present if the relay holds it or a
+// newer version.
+func (s *CoverageService) Check(ctx context.Context, pubkey string) (*db.MirrorCoverage, error) {
+	now := s.now()
+	since := now.Add(-coverageWindow)
+	until := now.Add(-coverageGracePeriod)
+
+	excluded := make(map[int]bool)
+	if kinds, err := s.db.GetExcludedKinds(ctx); err == nil {
+		for _, k := range kinds {
+			excluded[k] = true
+		}
+	}
+
+	sinceUnix, untilUnix := since.Unix(), until.Unix()
+	filter := nostr.Filter{
+		Authors: []string{pubkey},
+		Since:   &sinceUnix,
+		Until:   &untilUnix,
+		Limit:   coverageSampleSize,
+	}
+
+	sample := make(map[string]*nostr.SyncEvent)
+	relayCounts := make(map[string]int)
+	for _, url := range s.relays(ctx) {
+		count := 0
+		err := s.query(ctx, url, filter, func(event *nostr.SyncEvent) {
+			if event.Pubkey != pubkey || event.CreatedAt < sinceUnix || event.CreatedAt > untilUnix ||
+				isEphemeralKind(event.Kind) || excluded[event.Kind] {
+				return
+			}
+			count++
+			sample[event.ID] = event
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to sample events for coverage", "relay", url, "error", err)
+			continue
+		}
+		relayCounts[url] = count
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// Keep only the newest version of each replaceable event
+	newest := make(map[string]*nostr.SyncEvent)
+	for id, event := range sample {
+		key, ok := replaceableKey(event.Kind, event.Tags)
+		if !ok {
+			continue
+		}
+		if current, ok := newest[key]; ok {
+			if current.CreatedAt >= event.CreatedAt {
+				delete(sample, id)
+				continue
+			}
+			delete(sample, current.ID)
+		}
+		newest[key] = event
+	}
+
+	present, err := s.presentLocally(ctx, pubkey, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := &db.MirrorCoverage{
+		Pubkey:     pubkey,
+		Sampled:    len(sample),
+		Present:    len(present),
+		Relays:     relayCounts,
+		MissingIDs: []string{},
+		Since:      time.Unix(sinceUnix, 0),
+		CheckedAt:  time.Unix(now.Unix(), 0),
+	}
+	// Newest missing events first
+	var missing []*nostr.SyncEvent
+	for id, event := range sample {
+		if !present[id] {
+			missing = append(missing, event)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].CreatedAt != missing[j].CreatedAt {
+			return missing[i].CreatedAt > missing[j].CreatedAt
+		}
+		return missing[i].ID < missing[j].ID
+	})
+	for _, event := range missing {
+		if len(coverage.MissingIDs) == coverageMaxMissing {
+			break
+		}
+		coverage.MissingIDs = append(coverage.MissingIDs, event.ID)
+	}
+	coverage.SetCoverage()
+
+	if err := s.db.SaveMirrorCoverage(ctx, coverage); err != nil {
+		return nil, err
+	}
+	return coverage, nil
+}
+
+// presentLocally returns the IDs of sampled events that the relay holds.
+func (s *CoverageService) presentLocally(ctx context.Context, pubkey string, sample map[string]*nostr.SyncEvent) (map[string]bool, error) {
+	present := make(map[string]bool)
+
+	ids := make([]string, 0, len(sample))
+	replaceableKinds := make(map[int]bool)
+	for id, event := range sample {
+		ids = append(ids, id)
+		if _, ok := replaceableKey(event.Kind, event.Tags); ok {
+			replaceableKinds[event.Kind] = true
+		}
+	}
+	for start := 0; start < len(ids); start += profileBatchSize {
+		end := start + profileBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		events, err := s.db.GetEvents(ctx, db.EventFilter{IDs: ids[start:end], Limit: end - start})
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			present[event.ID] = true
+		}
+	}
+
+	if len(replaceableKinds) == 0 {
+		return present, nil
+	}
+
+	// A newer local version stands in for a replaceable event
+	kinds := make([]int, 0, len(replaceableKinds))
+	for k := range replaceableKinds {
+		kinds = append(kinds, k)
+	}
+	local, err := s.db.GetEvents(ctx, db.EventFilter{Authors: []string{pubkey}, Kinds: kinds, Limit: 1000})
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]int64)
+	for _, event := range local {
+		key, _ := replaceableKey(event.Kind, event.Tags)
+		if created := event.CreatedAt.Unix(); created > latest[key] {
+			latest[key] = created
+		}
+	}
+	for id, event := range sample {
+		if key, ok := replaceableKey(event.Kind, event.Tags); ok && latest[key] >= event.CreatedAt {
+			present[id] = true
+		}
+	}
+	return present, nil
+}
+
+// isEphemeralKind reports whether relays are expected not to store a kind.
+func isEphemeralKind(kind int) bool {
+	return kind >= 20000 && kind < 30000
+}
+
+// replaceableKey returns the key that identifies versions of a replaceable
+// or addressable event, and false for other kinds.
+func replaceableKey(kind int, tags [][]string) (string, bool) {
+	switch {
+	case kind == 0 || kind == 3 || (kind >= 10000 && kind < 20000):
+		return strconv.Itoa(kind) + ":", true
+	case kind >= 30000 && kind < 40000:
+		d := ""
+		for _, tag := range tags {
+			if len(tag) >= 2 && tag[0] == "d" {
+				d = tag[1]
+				break
+			}
+		}
+		return strconv.Itoa(kind) + ":" + d, true
+	}
+	return "", false
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func insertCoverageTestEvent(t *testing.T, relayDB *sql.DB, e *nostr.SyncEvent) {
+	t.Helper()
+	if e.Tags == nil {
+		e.Tags = [][]string{}
+	}
+	content, _ := json.Marshal(e)
+	hash, _ := hex.DecodeString(e.ID)
+	author, _ := hex.DecodeString(e.Pubkey)
+	if _, err := relayDB.Exec(`INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content) VALUES (?, ?, ?, ?, ?, 0, ?)`,
+		hash, time.Now().Unix(), e.CreatedAt, author, e.Kind, string(content)); err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+}
+
+func TestCoverageService_Check(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	member := strings.Repeat("aa", 32)
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration) int64 { return now.Add(-ago).Unix() }
+	event := func(n byte, kind int, ago time.Duration, tags ...[]string) *nostr.SyncEvent {
+		return &nostr.SyncEvent{ID: strings.Repeat(hex.EncodeToString([]byte{n}), 32), Pubkey: member, Kind: kind, CreatedAt: at(ago), Tags: tags}
+	}
+
+	stored := event(1, 1, time.Hour)
+	missing := event(2, 1, 2*time.Hour)
+	alsoStored := event(3, 1, 3*time.Hour)
+	oldProfile := event(4, 0, 5*time.Hour) // a newer version is stored
+	article := event(5, 30023, time.Hour, []string{"d", "post"})
+	ephemeral := event(6, 20001, time.Hour) // never stored
+	tooNew := event(7, 1, time.Minute)      // sync may not have run yet
+	excludedDM := event(8, 4, time.Hour)    // excluded by residency policy
+	otherAuthor := event(9, 1, time.Hour)
+	otherAuthor.Pubkey = strings.Repeat("bb", 32)
+
+	insertCoverageTestEvent(t, relayDB, stored)
+	insertCoverageTestEvent(t, relayDB, alsoStored)
+	insertCoverageTestEvent(t, relayDB, event(10, 0, 4*time.Hour))
+	insertCoverageTestEvent(t, relayDB, event(11, 30023, 2*time.Hour, []string{"d", "post"})) // older than the article
+	database.SetExcludedKinds(ctx, []int{4})
+
+	relays := map[string][]*nostr.SyncEvent{
+		"wss://one": {stored, missing, oldProfile, ephemeral, tooNew, excludedDM, otherAuthor},
+		"wss://two": {stored, alsoStored, article},
+	}
+	svc := NewCoverageService(database)
+	svc.now = func() time.Time { return now }
+	svc.relays = func(context.Context) []string { return []string{"wss://one", "wss://two"} }
+	svc.query = func(ctx context.Context, url string, filter nostr.Filter, callback func(*nostr.SyncEvent)) error {
+		if len(filter.Authors) != 1 || filter.Authors[0] != member || *filter.Since != at(coverageWindow) {
+			t.Errorf("unexpected filter: %+v", filter)
+		}
+		for _, e := range relays[url] {
+			callback(e)
+		}
+		return nil
+	}
+
+	coverage, err := svc.Check(ctx, member)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	// stored, alsoStored and oldProfile are present; missing and article are not
+	if coverage.Sampled != 5 || coverage.Present != 3 {
+		t.Errorf("expected 3 of 5 present, got %d of %d", coverage.Present, coverage.Sampled)
+	}
+	if coverage.Coverage == nil || *coverage.Coverage != 60 {
+		t.Errorf("expected 60%% coverage, got %v", coverage.Coverage)
+	}
+	if len(coverage.MissingIDs) != 2 || coverage.MissingIDs[0] != article.ID || coverage.MissingIDs[1] != missing.ID {
+		t.Errorf("expected missing IDs newest first, got %v", coverage.MissingIDs)
+	}
+	if coverage.Relays["wss://one"] != 3 || coverage.Relays["wss://two"] != 3 {
+		t.Errorf("unexpected relay counts: %v", coverage.Relays)
+	}
+
+	saved, _ := database.GetMirrorCoverage(ctx, member)
+	if saved == nil || saved.Sampled != 5 || saved.Present != 3 || len(saved.MissingIDs) != 2 || !saved.CheckedAt.Equal(now) {
+		t.Errorf("unexpected saved coverage: %+v", saved)
+	}
+}
+
+func TestCoverageService_CheckDue(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	fresh := strings.Repeat("aa", 32)
+	stale := strings.Repeat("bb", 32)
+	database.AddSyncPubkey(ctx, db.SyncPubkey{Pubkey: fresh})
+	database.AddSyncPubkey(ctx, db.SyncPubkey{Pubkey: stale})
+
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	database.SaveMirrorCoverage(ctx, &db.MirrorCoverage{Pubkey: fresh, Since: now, CheckedAt: now.Add(-time.Hour)})
+	database.SaveMirrorCoverage(ctx, &db.MirrorCoverage{Pubkey: stale, Since: now, CheckedAt: now.Add(-coverageCheckAge - time.Hour)})
+
+	var checked []string
+	svc := NewCoverageService(database)
+	svc.now = func() time.Time { return now }
+	svc.relays = func(context.Context) []string { return []string{"wss://one"} }
+	svc.query = func(ctx context.Context, url string, filter nostr.Filter, callback func(*nostr.SyncEvent)) error {
+		checked = append(checked, filter.Authors...)
+		return nil
+	}
+
+	svc.checkDue(ctx)
+
+	if len(checked) != 1 || checked[0] != stale {
+		t.Errorf("expected only the stale pubkey to be checked, got %v", checked)
+	}
+	if c, _ := database.GetMirrorCoverage(ctx, stale); c == nil || c.Coverage != nil || !c.CheckedAt.Equal(now) {
+		t.Errorf("expected an empty check to be saved, got %+v", c)
+	}
+}
@@ -2,20 +2,34 @@ package services
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
 )
 
-// DeletionService handles NIP-09 deletion request processing.
+// DeletionKind is the NIP-09 deletion event kind.
+const DeletionKind = 5
+
+// DeletionService handles NIP-09 deletion request processing. Deleted
+// events are tombstoned so syncs and imports do not bring them back, and
+// the deletions can be published to other relays, signed as the operator.
 type DeletionService struct {
-	db *db.DB
+	db      *db.DB
+	signer  *Signer
+	publish func(ctx context.Context, url string, event *nostr.SyncEvent) error
 }
 
-// NewDeletionService creates a new deletion service.
-func NewDeletionService(database *db.DB) *DeletionService {
+// NewDeletionService creates a new deletion service that signs the
+// deletions it publishes with signer.
+func NewDeletionService(database *db.DB, signer *Signer) *DeletionService {
 	return &DeletionService{
-		db: database,
+		db:      database,
+		signer:  signer,
+		publish: publishToRelay,
 	}
 }
 
@@ -28,7 +42,9 @@ type DeletionResult struct {
 
 // ProcessPendingDeletions processes all pending NIP-09 deletion requests.
 // It verifies that the deletion request author matches the target event author
-// before deleting events.
+// before deleting events. Each request's events are deleted in one
+// transaction, and cancellation stops it between requests with the result so
+// far.
 func (s *DeletionService) ProcessPendingDeletions(ctx context.Context) (*DeletionResult, error) {
 	// Check if NIP-09 deletions are honored
 	policy, err := s.db.GetRetentionPolicy(ctx)
@@ -37,7 +53,7 @@ func (s *DeletionService) ProcessPendingDeletions(ctx context.Context) (*Deletio
 	}
 
 	if !policy.HonorNIP09 {
-		log.Println("NIP-09 deletions not honored by policy, skipping")
+		slog.Debug("NIP-09 deletions not honored by policy, skipping")
 		return &DeletionResult{}, nil
 	}
 
@@ -61,6 +77,10 @@ func (s *DeletionService) ProcessPendingDeletions(ctx context.Context) (*Deletio
 	result := &DeletionResult{}
 
 	for _, req := range requests {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
 		var eventsDeleted int64
 		var validTargets []string
 
@@ -68,7 +88,7 @@ func (s *DeletionService) ProcessPendingDeletions(ctx context.Context) (*Deletio
 		for _, targetID := range req.TargetEventIDs {
 			author, err := writer.GetEventAuthor(ctx, targetID)
 			if err != nil {
-				log.Printf("Failed to get author for event %s: %v", targetID, err)
+				slog.Warn("Failed to get author for event", "event_id", targetID, "error", err)
 				continue
 			}
 
@@ -81,39 +101,58 @@ func (s *DeletionService) ProcessPendingDeletions(ctx context.Context) (*Deletio
 				// Author matches, add to valid targets
 				validTargets = append(validTargets, targetID)
 			} else {
-				log.Printf("Rejecting deletion of event %s: requester %s is not author %s",
-					targetID, req.AuthorPubkey[:16], author[:16])
+				slog.Info("Rejecting deletion: requester is not the author",
+					"event_id", targetID, "requester", req.AuthorPubkey[:16], "author", author[:16])
 			}
 		}
 
-		// Delete valid targets
+		// Tombstone valid targets first, so a sync running alongside cannot
+		// bring them back, then delete them
 		if len(validTargets) > 0 {
-			deleted, err := writer.DeleteEventsByIDs(ctx, validTargets)
+			if err := s.tombstone(ctx, req, validTargets); err != nil {
+				slog.Error("Failed to record deleted events", "request_id", req.ID, "error", err)
+				s.db.UpdateDeletionRequestStatus(ctx, req.ID, "failed", 0)
+				result.Failed++
+				continue
+			}
+			deleted, err := writer.DeleteEventsByIDsBatch(ctx, validTargets)
 			if err != nil {
-				log.Printf("Failed to delete events for request %d: %v", req.ID, err)
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				slog.Error("Failed to delete events for deletion request", "request_id", req.ID, "error", err)
 				// Mark as failed
 				s.db.UpdateDeletionRequestStatus(ctx, req.ID, "failed", 0)
 				result.Failed++
 				continue
 			}
 			eventsDeleted = deleted
+			s.publishDeletion(ctx, validTargets, req.Reason)
 		}
 
 		// Mark request as processed
 		if err := s.db.UpdateDeletionRequestStatus(ctx, req.ID, "processed", eventsDeleted); err != nil {
-			log.Printf("Failed to update deletion request %d status: %v", req.ID, err)
+			slog.Error("Failed to update deletion request status", "request_id", req.ID, "error", err)
 		}
 
 		result.Processed++
 		result.EventsDeleted += eventsDeleted
 	}
 
-	log.Printf("Processed %d deletion requests, deleted %d events, %d failed",
-		result.Processed, result.EventsDeleted, result.Failed)
+	slog.Info("Processed deletion requests",
+		"processed", result.Processed, "deleted", result.EventsDeleted, "failed", result.Failed)
 
 	return result, nil
 }
 
+// QueueAuthorDeletion queues the deletion of an event the operator removed.
+// The deletion service only deletes events of the requester, so the request
+// is made in the event author's name.
+func QueueAuthorDeletion(ctx context.Context, database *db.DB, eventID, author, reason string) error {
+	_, err := database.CreateDeletionRequest(ctx, eventID, author, reason)
+	return err
+}
+
 // ProcessSingleRequest processes a specific deletion request by ID.
 func (s *DeletionService) ProcessSingleRequest(ctx context.Context, requestID int64) error {
 	requests, err := s.db.GetDeletionRequests(ctx, "pending")
@@ -154,12 +193,73 @@ func (s *DeletionService) ProcessSingleRequest(ctx context.Context, requestID in
 
 	var eventsDeleted int64
 	if len(validTargets) > 0 {
-		eventsDeleted, err = writer.DeleteEventsByIDs(ctx, validTargets)
+		if err := s.tombstone(ctx, *targetRequest, validTargets); err != nil {
+			s.db.UpdateDeletionRequestStatus(ctx, requestID, "failed", 0)
+			return fmt.Errorf("failed to record deleted events: %w", err)
+		}
+		eventsDeleted, err = writer.DeleteEventsByIDsBatch(ctx, validTargets)
 		if err != nil {
 			s.db.UpdateDeletionRequestStatus(ctx, requestID, "failed", 0)
 			return err
 		}
+		s.publishDeletion(ctx, validTargets, targetRequest.Reason)
 	}
 
 	return s.db.UpdateDeletionRequestStatus(ctx, requestID, "processed", eventsDeleted)
 }
+
+// tombstone records the events a request is about to delete, so syncs and
+// imports refuse them from then on. A request whose tombstones cannot be
+// recorded deletes nothing. If the deletion then fails, the tombstones stay
+// and keep the events from being stored again.
+func (s *DeletionService) tombstone(ctx context.Context, req db.DeletionRequest, eventIDs []string) error {
+	now := time.Now()
+	tombstones := make([]db.Tombstone, len(eventIDs))
+	for i, id := range eventIDs {
+		tombstones[i] = db.Tombstone{EventID: id, Author: req.AuthorPubkey, DeletedAt: now, Reason: req.Reason}
+	}
+	return s.db.AddTombstones(ctx, tombstones)
+}
+
+// publishDeletion publishes a NIP-09 deletion of the events to other
+// relays, signed as the operator, if publishing is enabled and the
+// operator paired a remote signer or delegated their key. Relays only act
+// on deletions of the signer's own events; for others the event records
+// the operator's decision.
+func (s *DeletionService) publishDeletion(ctx context.Context, eventIDs []string, reason string) {
+	settings, err := s.db.GetTombstonePublishing(ctx)
+	if err != nil || !settings.Enabled {
+		return
+	}
+
+	tags := make([][]string, len(eventIDs))
+	for i, id := range eventIDs {
+		tags[i] = []string{"e", id}
+	}
+	event := nostr.NewEvent(DeletionKind, tags, reason)
+	if err := s.signer.Sign(ctx, event); err != nil {
+		if !errors.Is(err, ErrNoSigner) {
+			slog.Warn("Deletions not published: failed to sign as the operator", "error", err)
+		}
+		return
+	}
+
+	relays := settings.Relays
+	if len(relays) == 0 {
+		relays = SyncRelayURLs(ctx, s.db)
+	}
+	published := 0
+	for _, url := range relays {
+		if err := s.publish(ctx, url, event); err != nil {
+			slog.Warn("Failed to publish deletion event", "relay", url, "error", err)
+			continue
+		}
+		published++
+	}
+	if published == 0 {
+		return
+	}
+	if err := s.db.SetTombstonesPublished(ctx, eventIDs, event.ID); err != nil {
+		slog.Error("Failed to record published deletion", "event_id", event.ID, "error", err)
+	}
+}
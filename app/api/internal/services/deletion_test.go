@@ -2,10 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -13,14 +16,14 @@ import (
 func TestDeletionService_Constructor(t *testing.T) {
 	t.Run("NewDeletionService_creates_service", func(t *testing.T) {
 		database := setupTestDB(t)
-		svc := NewDeletionService(database)
+		svc := NewDeletionService(database, NewSigner(database))
 		if svc == nil {
 			t.Fatal("expected service to be created")
 		}
 	})
 
 	t.Run("NewDeletionService_nil_database", func(t *testing.T) {
-		svc := NewDeletionService(nil)
+		svc := NewDeletionService(nil, nil)
 		if svc == nil {
 			t.Fatal("expected service to be created even with nil database")
 		}
@@ -33,7 +36,7 @@ func TestDeletionService_ProcessPendingDeletions(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("ProcessPendingDeletions_empty", func(t *testing.T) {
-		svc := NewDeletionService(database)
+		svc := NewDeletionService(database, NewSigner(database))
 
 		// Ensure NIP-09 is honored
 		database.SetRetentionPolicy(ctx, &db.RetentionPolicy{
@@ -54,7 +57,7 @@ func TestDeletionService_ProcessPendingDeletions(t *testing.T) {
 	})
 
 	t.Run("ProcessPendingDeletions_NIP09_disabled", func(t *testing.T) {
-		svc := NewDeletionService(database)
+		svc := NewDeletionService(database, NewSigner(database))
 
 		// Disable NIP-09
 		database.SetRetentionPolicy(ctx, &db.RetentionPolicy{
@@ -224,3 +227,136 @@ func TestDeletionService_RetentionPolicyIntegration(t *testing.T) {
 		}
 	})
 }
+
+func TestDeletionService_Tombstones(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+	database.SetRetentionPolicy(ctx, &db.RetentionPolicy{HonorNIP09: true})
+
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	operator, _ := nostr.GetPublicKey(operatorSecret)
+	database.SetAppState(ctx, "operator_pubkey", operator)
+	if err := database.SetOperatorSigningKey(ctx, operatorSecret); err != nil {
+		t.Fatalf("SetOperatorSigningKey failed: %v", err)
+	}
+	relay, url := newFakeBackupRelay(t)
+	database.SetTombstonePublishing(ctx, db.TombstonePublishing{Enabled: true, Relays: []string{url}})
+
+	spammer := "ab" + operator[2:]
+	id := insertKindTestEvent(t, relayDB, 1, spammer, 1)
+	database.CreateDeletionRequest(ctx, id, spammer, "spam")
+
+	svc := NewDeletionService(database, NewSigner(database))
+	result, err := svc.ProcessPendingDeletions(ctx)
+	if err != nil {
+		t.Fatalf("ProcessPendingDeletions failed: %v", err)
+	}
+	if result.EventsDeleted != 1 {
+		t.Fatalf("expected 1 event deleted, got %d", result.EventsDeleted)
+	}
+
+	// The deletion is published as a kind 5 event signed by the operator
+	select {
+	case <-relay.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deletion event was not published")
+	}
+	relay.mu.Lock()
+	deletion := relay.events[0]
+	relay.mu.Unlock()
+	if deletion.Kind != DeletionKind || deletion.Pubkey != operator || deletion.Content != "spam" {
+		t.Errorf("unexpected deletion event: %+v", deletion)
+	}
+	if len(deletion.Tags) != 1 || deletion.Tags[0][0] != "e" || deletion.Tags[0][1] != id {
+		t.Errorf("expected an e tag for the deleted event, got %v", deletion.Tags)
+	}
+	if err := deletion.Verify(); err != nil {
+		t.Errorf("deletion event does not verify: %v", err)
+	}
+
+	tombstones, total, err := database.GetTombstones(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("GetTombstones failed: %v", err)
+	}
+	if total != 1 || tombstones[0].EventID != id || tombstones[0].Author != spammer || tombstones[0].Reason != "spam" {
+		t.Fatalf("unexpected tombstones: %+v", tombstones)
+	}
+	if tombstones[0].DeletionEventID != deletion.ID {
+		t.Errorf("expected the published deletion %s to be recorded, got %q", deletion.ID, tombstones[0].DeletionEventID)
+	}
+
+	// A sync or import cannot bring the event back until the tombstone is removed
+	event := &db.Event{ID: id, Pubkey: spammer, CreatedAt: time.Unix(1700000001, 0), Kind: 1, Tags: [][]string{}}
+	writer, err := database.NewRelayWriter()
+	if err != nil {
+		t.Fatalf("NewRelayWriter failed: %v", err)
+	}
+	defer writer.Close()
+	if _, err := writer.InsertEvent(ctx, event); !errors.Is(err, db.ErrTombstoned) {
+		t.Errorf("InsertEvent() error = %v, want ErrTombstoned", err)
+	}
+
+	if deleted, err := database.DeleteTombstone(ctx, id); err != nil || !deleted {
+		t.Fatalf("DeleteTombstone = %v, %v", deleted, err)
+	}
+	if inserted, err := writer.InsertEvent(ctx, event); err != nil || !inserted {
+		t.Errorf("expected the event to be inserted after its tombstone was removed, got %v, %v", inserted, err)
+	}
+}
+
+func TestDeletionService_TombstonesWithoutKey(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+	database.SetRetentionPolicy(ctx, &db.RetentionPolicy{HonorNIP09: true})
+	database.SetTombstonePublishing(ctx, db.TombstonePublishing{Enabled: true, Relays: []string{"ws://127.0.0.1:1"}})
+
+	author := strings.Repeat("cd", 32)
+	id := insertKindTestEvent(t, relayDB, 2, author, 1)
+	database.CreateDeletionRequest(ctx, id, author, "")
+
+	svc := NewDeletionService(database, NewSigner(database))
+	published := 0
+	svc.publish = func(ctx context.Context, url string, event *nostr.SyncEvent) error {
+		published++
+		return nil
+	}
+	if _, err := svc.ProcessPendingDeletions(ctx); err != nil {
+		t.Fatalf("ProcessPendingDeletions failed: %v", err)
+	}
+
+	if tombstoned, _ := database.IsTombstoned(ctx, id); !tombstoned {
+		t.Error("expected the deleted event to be tombstoned")
+	}
+	if published != 0 {
+		t.Errorf("expected nothing published without the operator's key, published %d", published)
+	}
+}
+
+func TestDeletionService_TombstoneFailureKeepsEvents(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+	database.SetRetentionPolicy(ctx, &db.RetentionPolicy{HonorNIP09: true})
+
+	author := strings.Repeat("ef", 32)
+	id := insertKindTestEvent(t, relayDB, 3, author, 1)
+	database.CreateDeletionRequest(ctx, id, author, "")
+	if _, err := database.AppDB.Exec(`DROP TABLE event_tombstones`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a tombstone the event could be synced back, so it is kept
+	// and the request fails
+	result, err := NewDeletionService(database, NewSigner(database)).ProcessPendingDeletions(ctx)
+	if err != nil {
+		t.Fatalf("ProcessPendingDeletions failed: %v", err)
+	}
+	if result.Failed != 1 || result.EventsDeleted != 0 {
+		t.Errorf("expected the request to fail without deleting, got %+v", result)
+	}
+	if count, _ := database.CountEvents(ctx, db.EventFilter{}); count != 1 {
+		t.Errorf("expected the event to be kept, %d events left", count)
+	}
+	if failed, _ := database.GetDeletionRequests(ctx, "failed"); len(failed) != 1 {
+		t.Errorf("expected the request marked failed, got %+v", failed)
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// expirationBatchSize is how many expired events are deleted per
+// transaction, so the relay can write between batches.
+const expirationBatchSize = 500
+
+// ExpirationService deletes events once their NIP-40 expiration tag has
+// passed. Syncs and imports refuse expired events, but events the relay
+// stored itself stay until this service finds them.
+type ExpirationService struct {
+	db       *db.DB
+	interval time.Duration
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+	runMu    sync.Mutex
+
+	backfilled bool // Expirations of events stored before expires_at was set have been filled in
+}
+
+// NewExpirationService creates a new expiration service.
+func NewExpirationService(database *db.DB) *ExpirationService {
+	return &ExpirationService{
+		db:       database,
+		interval: 10 * time.Minute,
+		wakeCh:   make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins deleting expired events in the background.
+func (s *ExpirationService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the expiration worker.
+func (s *ExpirationService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to delete expired events now.
+func (s *ExpirationService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ExpirationService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		case <-s.wakeCh:
+		}
+		if _, err := s.Run(context.Background()); err != nil && !errors.Is(err, db.ErrUnsupportedOnPostgres) {
+			slog.Error("Failed to delete expired events", "error", err)
+		}
+	}
+}
+
+// Run deletes every event whose expiration has passed and returns how many
+// were deleted.
+func (s *ExpirationService) Run(ctx context.Context) (int64, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	if !s.db.IsRelayDBConnected() {
+		return 0, nil
+	}
+	if !s.backfilled {
+		s.backfill(ctx)
+	}
+
+	now := time.Now()
+	ids, err := s.db.GetExpiredEventIDs(ctx, now, expirationBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check with the read-only connection first to avoid opening a writer
+	// every interval when nothing has expired.
+	var deleted int64
+	if len(ids) > 0 {
+		writer, err := s.db.NewRelayWriter()
+		if err != nil {
+			return 0, err
+		}
+		defer writer.Close()
+
+		for len(ids) > 0 {
+			n, err := writer.DeleteEventsByIDsBatch(ctx, ids)
+			deleted += n
+			if err != nil {
+				s.record(ctx, now, deleted)
+				return deleted, err
+			}
+			if n == 0 || len(ids) < expirationBatchSize {
+				break
+			}
+			if ids, err = s.db.GetExpiredEventIDs(ctx, now, expirationBatchSize); err != nil {
+				s.record(ctx, now, deleted)
+				return deleted, err
+			}
+		}
+	}
+
+	s.record(ctx, now, deleted)
+	if deleted > 0 {
+		slog.Info("Deleted expired events", "deleted", deleted)
+	}
+	return deleted, nil
+}
+
+// backfill sets expires_at on events stored before Roostr recorded it, once
+// per process, so they are found through the expiration index.
+func (s *ExpirationService) backfill(ctx context.Context) {
+	writer, err := s.db.NewRelayWriter()
+	if err != nil {
+		slog.Warn("Failed to backfill event expirations", "error", err)
+		return
+	}
+	defer writer.Close()
+
+	updated, err := writer.BackfillExpirations(ctx)
+	if err != nil {
+		slog.Warn("Failed to backfill event expirations", "error", err)
+		return
+	}
+	s.backfilled = true
+	if updated > 0 {
+		slog.Info("Backfilled event expirations", "events", updated)
+	}
+}
+
+func (s *ExpirationService) record(ctx context.Context, at time.Time, deleted int64) {
+	if err := s.db.RecordExpirationRun(ctx, at, deleted); err != nil {
+		slog.Warn("Failed to record expiration run", "error", err)
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestExpirationService_Run(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	expires := func(at time.Time) []string {
+		return []string{"expiration", strconv.FormatInt(at.Unix(), 10)}
+	}
+	event := func(n byte, tags ...[]string) *nostr.SyncEvent {
+		return &nostr.SyncEvent{
+			ID:        strings.Repeat(hex.EncodeToString([]byte{n}), 32),
+			Pubkey:    strings.Repeat("ab", 32),
+			Kind:      1,
+			CreatedAt: now.Add(-time.Hour).Unix(),
+			Tags:      tags,
+		}
+	}
+	insertCoverageTestEvent(t, relayDB, event(1, expires(now.Add(-time.Minute))))
+	insertCoverageTestEvent(t, relayDB, event(2, expires(now.Add(-time.Minute)), []string{"t", "nostr"}))
+	insertCoverageTestEvent(t, relayDB, event(3, expires(now.Add(time.Hour))))
+	insertCoverageTestEvent(t, relayDB, event(4, []string{"expiration", "soon"}))
+	insertCoverageTestEvent(t, relayDB, event(5))
+
+	s := NewExpirationService(database)
+	deleted, err := s.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 expired events deleted, got %d", deleted)
+	}
+	if count, _ := database.CountEvents(ctx, db.EventFilter{}); count != 3 {
+		t.Errorf("expected 3 events left, got %d", count)
+	}
+
+	if _, err := s.Run(ctx); err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	stats, err := database.GetExpirationStats(ctx)
+	if err != nil {
+		t.Fatalf("GetExpirationStats failed: %v", err)
+	}
+	if stats.LastRunAt == nil || stats.LastDeleted != 0 || stats.TotalDeleted != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
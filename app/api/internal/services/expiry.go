@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,25 +13,32 @@ import (
 )
 
 // ExpiryService handles automatic subscription expiry processing.
-// It runs daily at midnight, finds expired paid users, marks them as expired,
-// removes them from the whitelist, and syncs the relay config.
+// It runs daily at midnight and finds expired paid users. With a grace period
+// configured they keep access with status 'grace' until it ends; then they
+// are marked as expired, removed from the whitelist, and the relay config is
+// synced. Between runs it marks unpaid invoices expired, telling their users
+// if the expiry settings ask for it.
 type ExpiryService struct {
-	db        *db.DB
-	configMgr *relay.ConfigManager
-	relay     *relay.Relay
-	stopCh    chan struct{}
-	wg        sync.WaitGroup
-	running   bool
-	mu        sync.Mutex
+	db              *db.DB
+	webhooks        *WebhookService
+	notifier        *Notifier
+	configMgr       *relay.ConfigManager
+	relay           *relay.Relay
+	invoiceInterval time.Duration
+	stopCh          chan struct{}
+	wg              sync.WaitGroup
+	running         bool
+	mu              sync.Mutex
 }
 
 // NewExpiryService creates a new expiry service.
 func NewExpiryService(database *db.DB, configMgr *relay.ConfigManager, relayCtl *relay.Relay) *ExpiryService {
 	return &ExpiryService{
-		db:        database,
-		configMgr: configMgr,
-		relay:     relayCtl,
-		stopCh:    make(chan struct{}),
+		db:              database,
+		configMgr:       configMgr,
+		relay:           relayCtl,
+		invoiceInterval: 5 * time.Minute,
+		stopCh:          make(chan struct{}),
 	}
 }
 
@@ -67,7 +76,7 @@ func (s *ExpiryService) Stop() {
 func (s *ExpiryService) run() {
 	defer s.wg.Done()
 
-	log.Println("Expiry service started")
+	slog.Info("Expiry service started")
 
 	// Calculate time until next midnight
 	now := time.Now()
@@ -78,10 +87,13 @@ func (s *ExpiryService) run() {
 	timer := time.NewTimer(timeUntilMidnight)
 	defer timer.Stop()
 
+	invoiceTicker := time.NewTicker(s.invoiceInterval)
+	defer invoiceTicker.Stop()
+
 	for {
 		select {
 		case <-s.stopCh:
-			log.Println("Expiry service stopped")
+			slog.Info("Expiry service stopped")
 			return
 		case <-timer.C:
 			// Run the expiry job
@@ -89,77 +101,248 @@ func (s *ExpiryService) run() {
 
 			// Reset timer for next day
 			timer.Reset(24 * time.Hour)
+		case <-invoiceTicker.C:
+			s.processExpiredInvoices(context.Background())
 		}
 	}
 }
 
-// processExpiredSubscriptions finds and processes all expired paid users.
+// processExpiredInvoices marks pending invoices past their expiry as expired
+// and, with NotifyInvoiceDM set, tells each user whose signup stalled that
+// their invoice lapsed. Users who got access another way or have a newer
+// open invoice are not told. It returns how many users were notified.
+func (s *ExpiryService) processExpiredInvoices(ctx context.Context) int {
+	invoices, err := s.db.ExpirePendingInvoices(ctx)
+	if err != nil {
+		slog.Error("Failed to expire pending invoices", "error", err)
+		return 0
+	}
+	if len(invoices) == 0 {
+		return 0
+	}
+	slog.Debug("Expired unpaid invoices", "count", len(invoices))
+
+	settings, err := s.db.GetExpirySettings(ctx)
+	if err != nil {
+		slog.Error("Failed to get expiry settings", "error", err)
+		return 0
+	}
+	if !settings.NotifyInvoiceDM || s.notifier == nil {
+		return 0
+	}
+
+	tiers, _ := s.db.GetPricingTiers(ctx)
+	notified := 0
+	seen := make(map[string]bool)
+	for _, inv := range invoices {
+		if seen[inv.Pubkey] {
+			continue
+		}
+		seen[inv.Pubkey] = true
+		if s.signupSettled(ctx, inv.Pubkey) {
+			continue
+		}
+
+		tier := inv.TierID
+		for _, t := range tiers {
+			if t.ID == inv.TierID {
+				tier = t.Name
+			}
+		}
+		message := fmt.Sprintf(
+			"Your invoice for %s access to this relay expired before it was paid, so nothing was charged. Sign up again for a new invoice.%s",
+			tier, s.signupLink(ctx))
+		if err := s.notifier.SendDM(ctx, inv.Pubkey, message); err != nil {
+			slog.Warn("Failed to send invoice expiry DM", "pubkey", inv.Pubkey, "error", err)
+			continue
+		}
+		notified++
+	}
+	return notified
+}
+
+// signupSettled reports whether a pubkey whose invoice expired no longer
+// needs telling: it has access, or an open invoice to pay.
+func (s *ExpiryService) signupSettled(ctx context.Context, pubkey string) bool {
+	if entry, _ := s.db.GetWhitelistEntryByPubkey(ctx, pubkey); entry != nil {
+		return true
+	}
+	if user, _ := s.db.GetPaidUserByPubkey(ctx, pubkey); user != nil && (user.Status == "active" || user.Status == "grace") {
+		return true
+	}
+	invoices, _ := s.db.GetPendingInvoicesByPubkey(ctx, pubkey)
+	for _, inv := range invoices {
+		if inv.Status == "pending" && inv.ExpiresAt.After(time.Now()) {
+			return true
+		}
+	}
+	return false
+}
+
+// signupLink returns the signup page as a sentence, or nothing if no public
+// URL is set in the renewal settings.
+func (s *ExpiryService) signupLink(ctx context.Context) string {
+	settings, err := s.db.GetRenewalSettings(ctx)
+	if err != nil || settings.RenewURL == "" {
+		return ""
+	}
+	return fmt.Sprintf(" Sign up at %s/signup", strings.TrimRight(settings.RenewURL, "/"))
+}
+
+// processExpiredSubscriptions moves expired paid users into their grace
+// period, and those whose grace period has ended out of the whitelist.
 func (s *ExpiryService) processExpiredSubscriptions() {
 	ctx := context.Background()
 
-	log.Println("Starting expiry job")
+	slog.Debug("Starting expiry job")
 
-	// Get expired users (active + expires_at < now)
-	expired, err := s.db.GetExpiredPaidUsers(ctx)
+	settings, err := s.db.GetExpirySettings(ctx)
 	if err != nil {
-		log.Printf("Failed to get expired paid users: %v", err)
+		slog.Error("Failed to get expiry settings", "error", err)
 		return
 	}
 
-	if len(expired) == 0 {
-		log.Println("Expiry job: no expired subscriptions")
+	// Get expired users that still have access (active or grace + expires_at < now)
+	lapsed, err := s.db.GetExpiredPaidUsers(ctx)
+	if err != nil {
+		slog.Error("Failed to get expired paid users", "error", err)
 		return
 	}
 
-	// Process each expired user
-	for _, user := range expired {
-		log.Printf("Subscription expired: %s (tier: %s)", user.Npub, user.Tier)
+	if len(lapsed) == 0 {
+		slog.Debug("Expiry job: no expired subscriptions")
+		return
+	}
 
-		// Mark as expired
-		if err := s.db.UpdatePaidUserStatus(ctx, user.Pubkey, "expired"); err != nil {
-			log.Printf("Failed to update status for %s: %v", user.Pubkey, err)
+	now := time.Now()
+	grace := time.Duration(settings.GraceDays) * 24 * time.Hour
+	expired, graced := 0, 0
+	for _, user := range lapsed {
+		graceEnds := user.ExpiresAt.Add(grace)
+		if now.Before(graceEnds) {
+			if user.Status != "grace" && s.startGrace(ctx, user, graceEnds, settings) {
+				graced++
+			}
 			continue
 		}
+		if s.expire(ctx, user, settings) {
+			expired++
+		}
+	}
 
-		// Remove from whitelist
-		if err := s.db.RemoveWhitelistEntry(ctx, user.Pubkey); err != nil {
-			log.Printf("Failed to remove %s from whitelist: %v", user.Pubkey, err)
+	// Sync whitelist to config.toml and reload relay
+	if expired > 0 {
+		if err := s.syncWhitelist(ctx); err != nil {
+			slog.Warn("Failed to sync whitelist", "error", err)
 		}
+	}
 
-		// Audit log
-		s.db.AddAuditLog(ctx, "subscription_expired", map[string]interface{}{
-			"pubkey": user.Pubkey,
-			"npub":   user.Npub,
-			"tier":   user.Tier,
-		}, "")
+	slog.Info("Expiry job completed", "expired", expired, "grace", graced)
+}
+
+// startGrace moves an expired user into their grace period, keeping them on
+// the whitelist.
+func (s *ExpiryService) startGrace(ctx context.Context, user db.PaidUser, graceEnds time.Time, settings *db.ExpirySettings) bool {
+	slog.Info("Subscription in grace period", "npub", user.Npub, "tier", user.Tier, "grace_ends", graceEnds)
+
+	if err := s.db.UpdatePaidUserStatus(ctx, user.Pubkey, "grace"); err != nil {
+		slog.Error("Failed to update paid user status", "pubkey", user.Pubkey, "error", err)
+		return false
 	}
 
-	// Sync whitelist to config.toml and reload relay
-	if err := s.syncWhitelist(ctx); err != nil {
-		log.Printf("Warning: failed to sync whitelist: %v", err)
+	s.db.AddAuditLog(ctx, "subscription_grace_started", map[string]interface{}{
+		"pubkey":     user.Pubkey,
+		"npub":       user.Npub,
+		"tier":       user.Tier,
+		"grace_ends": graceEnds.Unix(),
+	}, "")
+
+	s.webhooks.Emit(WebhookEventUserGrace, map[string]interface{}{
+		"pubkey":        user.Pubkey,
+		"npub":          user.Npub,
+		"tier":          user.Tier,
+		"expires_at":    user.ExpiresAt,
+		"grace_ends_at": graceEnds,
+	})
+
+	if settings.NotifyDM {
+		s.notify(ctx, user, fmt.Sprintf(
+			"Your %s access to this relay expired on %s. You keep access until %s; renew before then to keep posting.%s",
+			user.Tier, user.ExpiresAt.UTC().Format("2006-01-02"), graceEnds.UTC().Format("2006-01-02"), s.renewLink(ctx, user)))
 	}
+	return true
+}
+
+// expire marks a user as expired and removes them from the whitelist.
+func (s *ExpiryService) expire(ctx context.Context, user db.PaidUser, settings *db.ExpirySettings) bool {
+	slog.Info("Subscription expired", "npub", user.Npub, "tier", user.Tier)
 
-	log.Printf("Expiry job completed: processed %d expired subscriptions", len(expired))
+	// Mark as expired
+	if err := s.db.UpdatePaidUserStatus(ctx, user.Pubkey, "expired"); err != nil {
+		slog.Error("Failed to update paid user status", "pubkey", user.Pubkey, "error", err)
+		return false
+	}
+
+	// Remove from whitelist
+	if err := s.db.RemoveWhitelistEntry(ctx, user.Pubkey); err != nil {
+		slog.Error("Failed to remove expired user from whitelist", "pubkey", user.Pubkey, "error", err)
+	}
+
+	// Audit log
+	s.db.AddAuditLog(ctx, "subscription_expired", map[string]interface{}{
+		"pubkey": user.Pubkey,
+		"npub":   user.Npub,
+		"tier":   user.Tier,
+		"grace":  user.Status == "grace",
+	}, "")
+
+	s.webhooks.Emit(WebhookEventUserExpired, map[string]interface{}{
+		"pubkey": user.Pubkey,
+		"npub":   user.Npub,
+		"tier":   user.Tier,
+	})
+
+	if settings.NotifyDM {
+		s.notify(ctx, user, fmt.Sprintf(
+			"Your %s access to this relay has ended. Renew to get it back.%s",
+			user.Tier, s.renewLink(ctx, user)))
+	}
+	return true
+}
+
+// notify sends a user a DM about their subscription.
+func (s *ExpiryService) notify(ctx context.Context, user db.PaidUser, message string) {
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.SendDM(ctx, user.Pubkey, message); err != nil {
+		slog.Warn("Failed to send expiry DM", "pubkey", user.Pubkey, "error", err)
+	}
+}
+
+// renewLink returns the user's renewal link as a sentence, or nothing if no
+// public URL is set in the renewal settings.
+func (s *ExpiryService) renewLink(ctx context.Context, user db.PaidUser) string {
+	settings, err := s.db.GetRenewalSettings(ctx)
+	if err != nil || settings.RenewURL == "" {
+		return ""
+	}
+	return fmt.Sprintf(" Renew at %s/public/renew/%s", strings.TrimRight(settings.RenewURL, "/"), user.Npub)
 }
 
 // syncWhitelist syncs the whitelist from DB to config.toml and reloads the relay.
 func (s *ExpiryService) syncWhitelist(ctx context.Context) error {
-	if s.configMgr == nil {
+	// The admission server reads the whitelist from the DB
+	if s.configMgr == nil || s.configMgr.DelegatesAccess() {
 		return nil
 	}
 
-	// Get whitelist entries from DB
-	entries, err := s.db.GetWhitelistMeta(ctx)
+	// Get the whitelist, with follow grants, from DB
+	whitelist, err := s.db.GetEffectiveWhitelist(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Extract hex pubkeys for config.toml
-	whitelist := make([]string, len(entries))
-	for i, e := range entries {
-		whitelist[i] = e.Pubkey
-	}
-
 	// Update config.toml whitelist
 	if err := s.configMgr.UpdateWhitelist(whitelist); err != nil {
 		return err
@@ -168,7 +351,7 @@ func (s *ExpiryService) syncWhitelist(ctx context.Context) error {
 	// Reload relay to pick up config changes
 	if s.relay != nil {
 		if err := s.relay.Reload(); err != nil {
-			log.Printf("Warning: failed to reload relay: %v", err)
+			slog.Warn("Failed to reload relay", "error", err)
 		}
 	}
 
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -342,6 +343,52 @@ func TestSUB002_BackgroundExpiryJob(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("ExpiryService_grace_period", func(t *testing.T) {
+		database := setupTestDB(t)
+		ctx := context.Background()
+
+		if err := database.SetExpirySettings(ctx, &db.ExpirySettings{GraceDays: 3}); err != nil {
+			t.Fatalf("failed to save expiry settings: %v", err)
+		}
+
+		// One user is inside the grace period, the other past it
+		inGrace := time.Now().Add(-24 * time.Hour)
+		pastGrace := time.Now().Add(-4 * 24 * time.Hour)
+		for pubkey, expiresAt := range map[string]*time.Time{"graceuser": &inGrace, "pastgrace": &pastGrace} {
+			if err := database.AddPaidUser(ctx, db.PaidUser{
+				Pubkey: pubkey, Npub: "npub1" + pubkey, Tier: "monthly", AmountSats: 1000, Status: "active", ExpiresAt: expiresAt,
+			}); err != nil {
+				t.Fatalf("failed to add paid user: %v", err)
+			}
+			if err := database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: pubkey, AddedBy: "payment:monthly"}); err != nil {
+				t.Fatalf("failed to add whitelist entry: %v", err)
+			}
+		}
+
+		svc := NewExpiryService(database, nil, nil)
+		svc.processExpiredSubscriptions()
+
+		whitelisted := func(pubkey string) bool {
+			entry, _ := database.GetWhitelistEntryByPubkey(ctx, pubkey)
+			return entry != nil
+		}
+		if user, _ := database.GetPaidUserByPubkey(ctx, "graceuser"); user.Status != "grace" || !whitelisted("graceuser") {
+			t.Errorf("expected whitelisted user in grace, got status %q", user.Status)
+		}
+		if user, _ := database.GetPaidUserByPubkey(ctx, "pastgrace"); user.Status != "expired" || whitelisted("pastgrace") {
+			t.Errorf("expected user past grace to expire, got status %q", user.Status)
+		}
+
+		// Shortening the grace period ends it on the next run
+		if err := database.SetExpirySettings(ctx, &db.ExpirySettings{GraceDays: 0}); err != nil {
+			t.Fatalf("failed to save expiry settings: %v", err)
+		}
+		svc.processExpiredSubscriptions()
+		if user, _ := database.GetPaidUserByPubkey(ctx, "graceuser"); user.Status != "expired" || whitelisted("graceuser") {
+			t.Errorf("expected grace to end, got status %q", user.Status)
+		}
+	})
 }
 
 // TestSUB003_ExpiryWarningDisplay tests expiry warning related functionality (SUB-003)
@@ -415,3 +462,64 @@ func TestExpiryService_Constructor(t *testing.T) {
 		}
 	})
 }
+
+func TestExpiryService_ExpiredInvoices(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	relay, url := newFakeBackupRelay(t)
+	notifier := NewNotifier(database, nil)
+	notifier.ConfigureRelays([]string{url})
+	svc := NewExpiryService(database, nil, nil)
+	svc.notifier = notifier
+
+	stalled := strings.Repeat("a1", 32)
+	member := strings.Repeat("b2", 32)
+	retrying := strings.Repeat("c3", 32)
+	invoice := func(hash, pubkey string, expiresIn time.Duration) {
+		t.Helper()
+		if err := database.CreatePendingInvoice(ctx, &db.PendingInvoice{
+			PaymentHash: hash, Pubkey: pubkey, Npub: "npub1" + hash, TierID: "monthly",
+			AmountSats: 5000, PaymentRequest: "lnbc" + hash, ExpiresAt: time.Now().Add(expiresIn),
+		}); err != nil {
+			t.Fatalf("CreatePendingInvoice() error = %v", err)
+		}
+	}
+	invoice("h1", stalled, -time.Minute)
+	invoice("h2", stalled, -time.Hour)
+	invoice("h3", member, -time.Minute)
+	invoice("h4", retrying, -time.Minute)
+	invoice("h5", retrying, time.Hour)
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: member})
+
+	// Without the setting invoices expire silently
+	if n := svc.processExpiredInvoices(ctx); n != 0 {
+		t.Fatalf("expected no DMs by default, sent %d", n)
+	}
+	for hash, want := range map[string]string{"h1": "expired", "h3": "expired", "h5": "pending"} {
+		if inv, _ := database.GetPendingInvoice(ctx, hash); inv == nil || inv.Status != want {
+			t.Errorf("expected %s to be %s, got %+v", hash, want, inv)
+		}
+	}
+
+	database.SetExpirySettings(ctx, &db.ExpirySettings{NotifyInvoiceDM: true})
+	invoice("h6", stalled, -time.Second)
+	invoice("h7", member, -time.Second)
+	invoice("h8", retrying, -time.Second)
+	if n := svc.processExpiredInvoices(ctx); n != 1 {
+		t.Fatalf("expected one DM, to the stalled signup, sent %d", n)
+	}
+	select {
+	case <-relay.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("relay did not receive the DM")
+	}
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	if len(relay.events) != 1 || relay.events[0].Kind != 4 || relay.events[0].Tags[0][1] != stalled {
+		t.Errorf("expected a DM to %s, got %+v", stalled, relay.events)
+	}
+	if n := svc.processExpiredInvoices(ctx); n != 0 {
+		t.Errorf("expected no DMs once every invoice expired, sent %d", n)
+	}
+}
@@ -0,0 +1,559 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// exportDestination uploads export artifacts and deletes old ones.
+type exportDestination interface {
+	// Upload ships the file at localPath as name and returns where it was stored.
+	Upload(ctx context.Context, name, localPath string) (string, error)
+	// Delete removes an artifact previously returned by Upload.
+	Delete(ctx context.Context, location string) error
+}
+
+// sftpHostPattern and sftpUserPattern limit SFTP hosts to hostname and IP
+// characters and users to a safe set, neither starting with "-", so ssh
+// cannot read them as options.
+var (
+	sftpHostPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.:-]*$`)
+	sftpUserPattern = regexp.MustCompile(`^[A-Za-z0-9._][A-Za-z0-9._-]*$`)
+)
+
+// ValidateExportDestination checks that the settings required by destType are present.
+func ValidateExportDestination(destType string, dest db.ExportDestination) error {
+	switch destType {
+	case ExportDestinationLocal:
+		if dest.Path == "" || !filepath.IsAbs(dest.Path) {
+			return errors.New("path must be an absolute directory")
+		}
+	case ExportDestinationS3:
+		if dest.Bucket == "" || dest.Region == "" {
+			return errors.New("bucket and region are required")
+		}
+		if dest.AccessKey == "" || dest.SecretKey == "" {
+			return errors.New("access_key and secret_key are required")
+		}
+		if dest.Endpoint != "" {
+			u, err := url.Parse(dest.Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.New("endpoint must be an http(s) URL")
+			}
+		}
+	case ExportDestinationSFTP:
+		if dest.Host == "" || dest.User == "" || dest.Path == "" {
+			return errors.New("host, user and path are required")
+		}
+		if strings.ContainsAny(dest.Path+dest.Host+dest.User+dest.KeyPath, "\"\n\r") {
+			return errors.New("sftp settings must not contain quotes or newlines")
+		}
+		if !sftpHostPattern.MatchString(dest.Host) {
+			return errors.New("host must be a hostname or IP address")
+		}
+		if !sftpUserPattern.MatchString(dest.User) {
+			return errors.New("user may only contain letters, digits, '.', '_' and '-', and must not start with '-'")
+		}
+		if dest.KeyPath != "" && !filepath.IsAbs(dest.KeyPath) {
+			return errors.New("key_path must be an absolute path")
+		}
+		if dest.Port < 0 || dest.Port > 65535 {
+			return errors.New("port must be between 1 and 65535")
+		}
+	case ExportDestinationWebDAV:
+		u, err := url.Parse(dest.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an http(s) URL")
+		}
+		if dest.Password != "" && dest.User == "" {
+			return errors.New("user is required with a password")
+		}
+	case ExportDestinationRelay:
+		u, err := url.Parse(dest.RelayURL)
+		if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			return errors.New("relay_url must be a ws:// or wss:// URL")
+		}
+	default:
+		return fmt.Errorf("unknown destination type %q", destType)
+	}
+	return nil
+}
+
+// newExportDestination builds the destination for a schedule.
+func newExportDestination(destType string, dest db.ExportDestination) (exportDestination, error) {
+	if err := ValidateExportDestination(destType, dest); err != nil {
+		return nil, err
+	}
+
+	switch destType {
+	case ExportDestinationLocal:
+		return &localExportDestination{dir: dest.Path}, nil
+	case ExportDestinationS3:
+		return newS3ExportDestination(dest), nil
+	case ExportDestinationSFTP:
+		return &sftpExportDestination{dest: dest}, nil
+	case ExportDestinationWebDAV:
+		return newWebDAVExportDestination(dest), nil
+	default:
+		return &relayExportDestination{url: dest.RelayURL}, nil
+	}
+}
+
+// CheckExportDestination verifies a destination's settings and credentials by
+// uploading an empty file and deleting it again.
+func CheckExportDestination(ctx context.Context, destType string, dest db.ExportDestination) error {
+	d, err := newExportDestination(destType, dest)
+	if err != nil {
+		return err
+	}
+
+	probe, err := os.CreateTemp("", "roostr-check-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	defer os.Remove(probe.Name())
+
+	name := fmt.Sprintf("roostr-connection-check-%d.txt", time.Now().Unix())
+	location, err := d.Upload(ctx, name, probe.Name())
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	if err := d.Delete(ctx, location); err != nil {
+		return fmt.Errorf("uploaded but could not delete %s: %w", location, err)
+	}
+	return nil
+}
+
+// artifactContentType returns the Content-Type an artifact is uploaded with.
+func artifactContentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".ndjson"):
+		return "application/x-ndjson"
+	case strings.HasSuffix(name, ".tar.gz"):
+		return "application/gzip"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// ============================================================================
+// Local directory
+// ============================================================================
+
+type localExportDestination struct {
+	dir string
+}
+
+func (d *localExportDestination) Upload(ctx context.Context, name, localPath string) (string, error) {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return "", err
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	target := filepath.Join(d.dir, name)
+	tmp := target + ".part"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return target, nil
+}
+
+func (d *localExportDestination) Delete(ctx context.Context, location string) error {
+	err := os.Remove(location)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ============================================================================
+// S3-compatible object storage
+// ============================================================================
+
+// s3ExportDestination PUTs objects with AWS Signature Version 4 using
+// path-style URLs, which works with AWS, MinIO, Backblaze B2 and Garage.
+type s3ExportDestination struct {
+	endpoint  string
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+func newS3ExportDestination(dest db.ExportDestination) *s3ExportDestination {
+	endpoint := strings.TrimSuffix(dest.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://s3." + dest.Region + ".amazonaws.com"
+	}
+	return &s3ExportDestination{
+		endpoint:  endpoint,
+		region:    dest.Region,
+		bucket:    dest.Bucket,
+		prefix:    strings.Trim(dest.Prefix, "/"),
+		accessKey: dest.AccessKey,
+		secretKey: dest.SecretKey,
+		client:    &http.Client{Timeout: 30 * time.Minute},
+		now:       time.Now,
+	}
+}
+
+func (d *s3ExportDestination) key(name string) string {
+	if d.prefix == "" {
+		return name
+	}
+	return d.prefix + "/" + name
+}
+
+func (d *s3ExportDestination) Upload(ctx context.Context, name, localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	key := d.key(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.objectURL(key), f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", artifactContentType(name))
+	if err := d.do(req, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return "", err
+	}
+	return "s3://" + d.bucket + "/" + key, nil
+}
+
+func (d *s3ExportDestination) Delete(ctx context.Context, location string) error {
+	key := strings.TrimPrefix(location, "s3://"+d.bucket+"/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, d.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	return d.do(req, emptyPayloadHash)
+}
+
+func (d *s3ExportDestination) objectURL(key string) string {
+	return d.endpoint + "/" + s3EscapePath(d.bucket+"/"+key)
+}
+
+func (d *s3ExportDestination) do(req *http.Request, payloadHash string) error {
+	signS3Request(req, payloadHash, d.accessKey, d.secretKey, d.region, d.now())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signS3Request adds AWS Signature Version 4 headers for the s3 service.
+func signS3Request(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes each path segment as SigV4 requires.
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		var b strings.Builder
+		for _, c := range []byte(seg) {
+			if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+				c == '-' || c == '_' || c == '.' || c == '~' {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		segments[i] = b.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+// ============================================================================
+// SFTP
+// ============================================================================
+
+// sftpExportDestination shells out to the OpenSSH sftp client in batch mode,
+// authenticating with a key file. New host keys are accepted on first use.
+type sftpExportDestination struct {
+	dest db.ExportDestination
+}
+
+func (d *sftpExportDestination) Upload(ctx context.Context, name, localPath string) (string, error) {
+	remote := path.Join(d.dest.Path, name)
+	if err := d.batch(ctx, fmt.Sprintf("-mkdir \"%s\"\nput \"%s\" \"%s\"\n", d.dest.Path, localPath, remote)); err != nil {
+		return "", err
+	}
+	return d.location(remote), nil
+}
+
+func (d *sftpExportDestination) Delete(ctx context.Context, location string) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	return d.batch(ctx, fmt.Sprintf("rm \"%s\"\n", u.Path))
+}
+
+func (d *sftpExportDestination) port() int {
+	if d.dest.Port == 0 {
+		return 22
+	}
+	return d.dest.Port
+}
+
+func (d *sftpExportDestination) location(remote string) string {
+	return (&url.URL{
+		Scheme: "sftp",
+		User:   url.User(d.dest.User),
+		Host:   d.dest.Host + ":" + strconv.Itoa(d.port()),
+		Path:   remote,
+	}).String()
+}
+
+func (d *sftpExportDestination) batch(ctx context.Context, commands string) error {
+	args := []string{
+		"-b", "-",
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-P", strconv.Itoa(d.port()),
+	}
+	if d.dest.KeyPath != "" {
+		args = append(args, "-i", d.dest.KeyPath)
+	}
+	args = append(args, "--", d.dest.User+"@"+d.dest.Host)
+
+	cmd := exec.CommandContext(ctx, "sftp", args...)
+	cmd.Stdin = strings.NewReader(commands)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sftp failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// ============================================================================
+// WebDAV
+// ============================================================================
+
+// webdavExportDestination PUTs files into a WebDAV collection such as a
+// Nextcloud folder, with HTTP basic auth. Nextcloud users should use an app
+// password.
+type webdavExportDestination struct {
+	collection string // URL ending in a slash
+	user       string
+	password   string
+	client     *http.Client
+}
+
+func newWebDAVExportDestination(dest db.ExportDestination) *webdavExportDestination {
+	return &webdavExportDestination{
+		collection: strings.TrimSuffix(dest.URL, "/") + "/",
+		user:       dest.User,
+		password:   dest.Password,
+		client:     &http.Client{Timeout: 30 * time.Minute},
+	}
+}
+
+func (d *webdavExportDestination) Upload(ctx context.Context, name, localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// Create the collection; 405 means it already exists
+	mkcol, err := http.NewRequestWithContext(ctx, "MKCOL", d.collection, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := d.do(mkcol, http.StatusMethodNotAllowed); err != nil {
+		return "", err
+	}
+
+	location := d.collection + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location, f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", artifactContentType(name))
+	if err := d.do(req); err != nil {
+		return "", err
+	}
+	return location, nil
+}
+
+func (d *webdavExportDestination) Delete(ctx context.Context, location string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, location, nil)
+	if err != nil {
+		return err
+	}
+	return d.do(req, http.StatusNotFound)
+}
+
+// do sends req, treating 2xx and the given statuses as success.
+func (d *webdavExportDestination) do(req *http.Request, ok ...int) error {
+	if d.user != "" {
+		req.SetBasicAuth(d.user, d.password)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	for _, status := range ok {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("webdav %s returned %d: %s", req.Method, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// ============================================================================
+// Nostr relay
+// ============================================================================
+
+// relayExportDestination republishes exported events to another relay.
+// Events cannot be removed from a relay, so Delete is a no-op.
+type relayExportDestination struct {
+	url string
+}
+
+func (d *relayExportDestination) Upload(ctx context.Context, name, localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	client := nostr.NewClient(d.url)
+	if err := client.Connect(ctx); err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		var event nostr.SyncEvent
+		if err := dec.Decode(&event); err != nil {
+			return "", fmt.Errorf("failed to read export: %w", err)
+		}
+		if err := client.Publish(&event); err != nil {
+			return "", err
+		}
+	}
+	return d.url, nil
+}
+
+func (d *relayExportDestination) Delete(ctx context.Context, location string) error {
+	return nil
+}
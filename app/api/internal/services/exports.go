@@ -0,0 +1,295 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/secrets"
+)
+
+// Export destination types.
+const (
+	ExportDestinationLocal  = "local"
+	ExportDestinationS3     = "s3"
+	ExportDestinationSFTP   = "sftp"
+	ExportDestinationWebDAV = "webdav"
+	ExportDestinationRelay  = "relay"
+)
+
+// exportRetryDelay is how long to wait before retrying a failed run, unless
+// the schedule's own interval is shorter.
+const exportRetryDelay = time.Hour
+
+// ExportService runs scheduled incremental NDJSON exports of new relay events
+// and ships each artifact to the schedule's destination.
+type ExportService struct {
+	db         *db.DB
+	interval   time.Duration
+	stagingDir string
+
+	current   int64 // ID of the schedule being exported, 0 when idle
+	currentMu sync.Mutex
+
+	wakeCh  chan struct{}
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running bool
+	mu      sync.Mutex
+}
+
+// NewExportService creates a new export service.
+func NewExportService(database *db.DB) *ExportService {
+	return &ExportService{
+		db:       database,
+		interval: time.Minute,
+		wakeCh:   make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins checking for due export schedules.
+func (s *ExportService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the scheduler, cancelling any export in progress.
+func (s *ExportService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake makes the scheduler check for due schedules immediately.
+func (s *ExportService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Current returns the ID of the schedule currently exporting, or 0.
+func (s *ExportService) Current() int64 {
+	s.currentMu.Lock()
+	defer s.currentMu.Unlock()
+	return s.current
+}
+
+// run is the main scheduler loop.
+func (s *ExportService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	slog.Info("Export service started")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.runDue(ctx)
+
+	for {
+		select {
+		case <-s.stopCh:
+			slog.Info("Export service stopped")
+			return
+		case <-ticker.C:
+			s.runDue(ctx)
+		case <-s.wakeCh:
+			s.runDue(ctx)
+		}
+	}
+}
+
+// runDue runs every schedule whose next run is due, one at a time.
+func (s *ExportService) runDue(ctx context.Context) {
+	if !s.db.IsRelayDBConnected() {
+		return
+	}
+
+	// Destinations cannot be read until the secrets are unlocked
+	schedules, err := s.db.GetDueExportSchedules(ctx, time.Now())
+	if errors.Is(err, secrets.ErrLocked) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load export schedules", "error", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+		s.RunSchedule(ctx, schedule)
+	}
+}
+
+// RunSchedule exports events added since the schedule's cursor, uploads the
+// artifact, records a manifest, and prunes artifacts beyond the retention count.
+// A run with no new events records success without creating an artifact.
+func (s *ExportService) RunSchedule(ctx context.Context, schedule db.ExportSchedule) (*db.ExportManifest, error) {
+	s.currentMu.Lock()
+	s.current = schedule.ID
+	s.currentMu.Unlock()
+	defer func() {
+		s.currentMu.Lock()
+		s.current = 0
+		s.currentMu.Unlock()
+	}()
+
+	interval := time.Duration(schedule.IntervalHours) * time.Hour
+	manifest, err := s.export(ctx, schedule)
+	if err != nil {
+		slog.Error("Export failed", "schedule", schedule.Name, "error", err)
+		retry := exportRetryDelay
+		if interval < retry {
+			retry = interval
+		}
+		s.db.RecordExportRun(ctx, schedule.ID, "failed", err.Error(), schedule.Cursor, time.Now().Add(retry))
+		return manifest, err
+	}
+
+	cursor := schedule.Cursor
+	if manifest != nil {
+		cursor = manifest.ToCursor
+		slog.Info("Export completed", "schedule", schedule.Name, "events", manifest.EventCount, "bytes", manifest.Bytes, "location", manifest.Location)
+	}
+	s.db.RecordExportRun(ctx, schedule.ID, "completed", "", cursor, time.Now().Add(interval))
+
+	if manifest != nil && schedule.RetentionCount > 0 {
+		s.prune(ctx, schedule)
+	}
+	return manifest, nil
+}
+
+// export writes new events to a staging file and uploads it. A failed upload
+// is recorded as a failed manifest.
+func (s *ExportService) export(ctx context.Context, schedule db.ExportSchedule) (*db.ExportManifest, error) {
+	dest, err := newExportDestination(schedule.DestinationType, schedule.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	staging, err := os.CreateTemp(s.stagingDir, "roostr-export-*.ndjson")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging file: %w", err)
+	}
+	defer os.Remove(staging.Name())
+	defer staging.Close()
+
+	manifest := &db.ExportManifest{
+		ScheduleID: schedule.ID,
+		FromCursor: schedule.Cursor,
+		ToCursor:   schedule.Cursor,
+	}
+
+	// DM privacy mode holds back DM content; read errors hold it back too
+	privacy, err := s.db.GetPrivacySettings(ctx)
+	redact := err != nil || privacy.RedactDMs
+
+	hash := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(staging, hash))
+	err = s.db.StreamEventsAfter(ctx, schedule.Cursor, schedule.Kinds, func(rowID int64, event db.ExportEvent) error {
+		if redact {
+			event.RedactDM()
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		manifest.ToCursor = rowID
+		manifest.EventCount++
+		manifest.Bytes += int64(len(data))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write staging file: %w", err)
+	}
+	if manifest.EventCount == 0 {
+		return nil, nil
+	}
+	manifest.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	manifest.Filename = fmt.Sprintf("roostr-export-%d-%s-%d.ndjson",
+		schedule.ID, time.Now().UTC().Format("20060102-150405"), manifest.ToCursor)
+
+	if err := staging.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write staging file: %w", err)
+	}
+
+	location, uploadErr := dest.Upload(ctx, manifest.Filename, staging.Name())
+	manifest.Location = location
+	manifest.Status = "completed"
+	if uploadErr != nil {
+		manifest.Status = "failed"
+		manifest.Error = uploadErr.Error()
+	}
+
+	if err := s.db.CreateExportManifest(ctx, manifest); err != nil {
+		slog.Error("Failed to record export manifest", "error", err)
+	}
+	if uploadErr != nil {
+		return manifest, fmt.Errorf("upload failed: %w", uploadErr)
+	}
+	return manifest, nil
+}
+
+// prune deletes artifacts beyond the schedule's retention count.
+func (s *ExportService) prune(ctx context.Context, schedule db.ExportSchedule) {
+	old, err := s.db.GetPrunableExportManifests(ctx, schedule.ID, schedule.RetentionCount)
+	if err != nil || len(old) == 0 {
+		return
+	}
+
+	dest, err := newExportDestination(schedule.DestinationType, schedule.Destination)
+	if err != nil {
+		return
+	}
+
+	for _, m := range old {
+		if err := dest.Delete(ctx, m.Location); err != nil {
+			slog.Warn("Failed to prune export", "location", m.Location, "error", err)
+			continue
+		}
+		s.db.MarkExportManifestPruned(ctx, m.ID)
+	}
+}
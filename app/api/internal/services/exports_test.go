@@ -0,0 +1,398 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// setupTestDBWithRelay creates an app database attached to a writable relay
+// database with the nostr-rs-relay event table. The returned handle writes
+// test fixtures directly.
+func setupTestDBWithRelay(t *testing.T) (*db.DB, *sql.DB) {
+	t.Helper()
+
+	dir := t.TempDir()
+	relayPath := filepath.Join(dir, "relay.db")
+
+	relayDB, err := sql.Open("sqlite3", relayPath)
+	if err != nil {
+		t.Fatalf("failed to open relay db: %v", err)
+	}
+	t.Cleanup(func() { relayDB.Close() })
+
+	_, err = relayDB.Exec(`
+		CREATE TABLE event (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_hash BLOB NOT NULL UNIQUE,
+			first_seen INTEGER NOT NULL,
+			created_at INTEGER,
+			author BLOB NOT NULL,
+			delegated_by BLOB,
+			kind INTEGER,
+			hidden INTEGER,
+			content TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		t.Fatalf("failed to create relay schema: %v", err)
+	}
+
+	database, err := db.New(relayPath, filepath.Join(dir, "roostr.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	return database, relayDB
+}
+
+func insertExportTestEvent(t *testing.T, relayDB *sql.DB, n byte, kind int) {
+	t.Helper()
+	id := strings.Repeat(hex.EncodeToString([]byte{n}), 32)
+	content := `{"id":"` + id + `","pubkey":"` + strings.Repeat("ab", 32) + `","created_at":1700000000,"kind":1,"tags":[],"content":"hello","sig":""}`
+	hash, _ := hex.DecodeString(id)
+	author, _ := hex.DecodeString(strings.Repeat("ab", 32))
+	if _, err := relayDB.Exec(`INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content) VALUES (?, ?, ?, ?, ?, 0, ?)`,
+		hash, time.Now().Unix(), 1700000000, author, kind, content); err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+}
+
+func TestExportSchedule_LocalIncremental(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+	outDir := t.TempDir()
+
+	schedule := &db.ExportSchedule{
+		Name:            "Local",
+		DestinationType: ExportDestinationLocal,
+		Destination:     db.ExportDestination{Path: outDir},
+		Kinds:           []int{},
+		IntervalHours:   24,
+		RetentionCount:  1,
+		Enabled:         true,
+	}
+	if err := database.CreateExportSchedule(ctx, schedule); err != nil {
+		t.Fatalf("CreateExportSchedule failed: %v", err)
+	}
+
+	svc := NewExportService(database)
+	svc.stagingDir = t.TempDir()
+
+	runNext := func() *db.ExportManifest {
+		t.Helper()
+		s, _ := database.GetExportSchedule(ctx, schedule.ID)
+		manifest, err := svc.RunSchedule(ctx, *s)
+		if err != nil {
+			t.Fatalf("RunSchedule failed: %v", err)
+		}
+		return manifest
+	}
+
+	insertExportTestEvent(t, relayDB, 1, 1)
+	insertExportTestEvent(t, relayDB, 2, 1)
+
+	first := runNext()
+	if first == nil || first.EventCount != 2 || first.Status != "completed" {
+		t.Fatalf("unexpected first manifest: %+v", first)
+	}
+
+	data, err := os.ReadFile(first.Location)
+	if err != nil {
+		t.Fatalf("expected artifact at %s: %v", first.Location, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != first.SHA256 || int64(len(data)) != first.Bytes {
+		t.Error("manifest checksum or size does not match artifact")
+	}
+	if lines := readLines(t, first.Location); len(lines) != 2 || !strings.Contains(lines[0], `"content":"hello"`) {
+		t.Errorf("unexpected artifact contents: %v", lines)
+	}
+
+	t.Run("no_new_events_skips_artifact", func(t *testing.T) {
+		if m := runNext(); m != nil {
+			t.Errorf("expected no artifact, got %+v", m)
+		}
+		s, _ := database.GetExportSchedule(ctx, schedule.ID)
+		if s.LastStatus != "completed" || !s.NextRunAt.After(time.Now().Add(23*time.Hour)) {
+			t.Errorf("expected completed run scheduled a day out, got %+v", s)
+		}
+	})
+
+	t.Run("exports_only_new_events_and_prunes", func(t *testing.T) {
+		insertExportTestEvent(t, relayDB, 3, 1)
+
+		second := runNext()
+		if second == nil || second.EventCount != 1 || second.FromCursor != first.ToCursor {
+			t.Fatalf("unexpected second manifest: %+v", second)
+		}
+
+		if _, err := os.Stat(first.Location); !os.IsNotExist(err) {
+			t.Error("expected first artifact to be pruned")
+		}
+		manifests, _ := database.GetExportManifests(ctx, schedule.ID, 10)
+		if len(manifests) != 2 || manifests[1].Status != "pruned" {
+			t.Errorf("expected first manifest marked pruned, got %+v", manifests)
+		}
+	})
+}
+
+func TestExportSchedule_FailedUploadKeepsCursor(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	// A file where the destination directory should be makes MkdirAll fail.
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	os.WriteFile(blocker, nil, 0644)
+
+	schedule := &db.ExportSchedule{
+		Name:            "Broken",
+		DestinationType: ExportDestinationLocal,
+		Destination:     db.ExportDestination{Path: filepath.Join(blocker, "exports")},
+		IntervalHours:   24,
+		Enabled:         true,
+	}
+	database.CreateExportSchedule(ctx, schedule)
+	insertExportTestEvent(t, relayDB, 1, 1)
+
+	svc := NewExportService(database)
+	svc.stagingDir = t.TempDir()
+	s, _ := database.GetExportSchedule(ctx, schedule.ID)
+	manifest, err := svc.RunSchedule(ctx, *s)
+	if err == nil {
+		t.Fatal("expected upload error")
+	}
+	if manifest == nil || manifest.Status != "failed" {
+		t.Errorf("expected failed manifest, got %+v", manifest)
+	}
+
+	s, _ = database.GetExportSchedule(ctx, schedule.ID)
+	if s.Cursor != 0 || s.LastStatus != "failed" {
+		t.Errorf("expected cursor unchanged after failure, got %+v", s)
+	}
+	if !s.NextRunAt.Before(time.Now().Add(2 * time.Hour)) {
+		t.Error("expected failed run to be retried within the hour")
+	}
+}
+
+func TestS3ExportDestination(t *testing.T) {
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+			http.Error(w, "bad auth: "+auth, http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+				http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = string(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	dest := newS3ExportDestination(db.ExportDestination{
+		Endpoint:  server.URL,
+		Region:    "us-east-1",
+		Bucket:    "backups",
+		Prefix:    "/relay/",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+	})
+	dest.now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+	local := filepath.Join(t.TempDir(), "export.ndjson")
+	os.WriteFile(local, []byte("{\"id\":\"1\"}\n"), 0644)
+
+	location, err := dest.Upload(context.Background(), "export.ndjson", local)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if location != "s3://backups/relay/export.ndjson" {
+		t.Errorf("unexpected location %s", location)
+	}
+	if objects["/backups/relay/export.ndjson"] != "{\"id\":\"1\"}\n" {
+		t.Errorf("object not stored: %v", objects)
+	}
+
+	if err := dest.Delete(context.Background(), location); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if len(objects) != 0 {
+		t.Error("expected object to be deleted")
+	}
+}
+
+// newWebDAVTestServer serves a WebDAV collection at /dav/ backed by files,
+// requiring basic auth as roostr/secret.
+func newWebDAVTestServer(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "roostr" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "MKCOL":
+			if files[r.URL.Path] == "collection" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			files[r.URL.Path] = "collection"
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			files[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			if _, ok := files[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(files, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebDAVExportDestination(t *testing.T) {
+	files := map[string]string{}
+	server := newWebDAVTestServer(t, files)
+
+	dest := newWebDAVExportDestination(db.ExportDestination{URL: server.URL + "/dav", User: "roostr", Password: "secret"})
+
+	local := filepath.Join(t.TempDir(), "export.ndjson")
+	os.WriteFile(local, []byte("{\"id\":\"1\"}\n"), 0644)
+
+	for i := 0; i < 2; i++ { // The second upload finds the collection already there
+		location, err := dest.Upload(context.Background(), "my export.ndjson", local)
+		if err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		if location != server.URL+"/dav/my%20export.ndjson" {
+			t.Errorf("unexpected location %s", location)
+		}
+	}
+	if files["/dav/my export.ndjson"] != "{\"id\":\"1\"}\n" {
+		t.Errorf("file not stored: %v", files)
+	}
+
+	if err := dest.Delete(context.Background(), server.URL+"/dav/my%20export.ndjson"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := files["/dav/my export.ndjson"]; ok {
+		t.Error("expected file to be deleted")
+	}
+	if err := dest.Delete(context.Background(), server.URL+"/dav/gone.ndjson"); err != nil {
+		t.Errorf("expected deleting a missing file to succeed, got %v", err)
+	}
+
+	wrong := newWebDAVExportDestination(db.ExportDestination{URL: server.URL + "/dav", User: "roostr", Password: "wrong"})
+	if _, err := wrong.Upload(context.Background(), "export.ndjson", local); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected a 401 error, got %v", err)
+	}
+}
+
+func TestCheckExportDestination(t *testing.T) {
+	files := map[string]string{}
+	server := newWebDAVTestServer(t, files)
+
+	if err := CheckExportDestination(context.Background(), ExportDestinationWebDAV, db.ExportDestination{URL: server.URL + "/dav", User: "roostr", Password: "secret"}); err != nil {
+		t.Fatalf("expected the check to pass, got %v", err)
+	}
+	if len(files) != 1 { // Only the collection is left
+		t.Errorf("expected the probe file to be removed, got %v", files)
+	}
+
+	if err := CheckExportDestination(context.Background(), ExportDestinationWebDAV, db.ExportDestination{URL: server.URL + "/dav", User: "roostr"}); err == nil {
+		t.Error("expected the check to fail with bad credentials")
+	}
+}
+
+func TestS3EscapePath(t *testing.T) {
+	if got := s3EscapePath("bucket/my backups/a+b.ndjson"); got != "bucket/my%20backups/a%2Bb.ndjson" {
+		t.Errorf("unexpected escaped path %s", got)
+	}
+}
+
+func TestValidateExportDestination(t *testing.T) {
+	tests := []struct {
+		name     string
+		destType string
+		dest     db.ExportDestination
+		valid    bool
+	}{
+		{"local", ExportDestinationLocal, db.ExportDestination{Path: "/data/exports"}, true},
+		{"local_relative", ExportDestinationLocal, db.ExportDestination{Path: "exports"}, false},
+		{"s3", ExportDestinationS3, db.ExportDestination{Bucket: "b", Region: "r", AccessKey: "a", SecretKey: "s"}, true},
+		{"s3_missing_keys", ExportDestinationS3, db.ExportDestination{Bucket: "b", Region: "r"}, false},
+		{"sftp", ExportDestinationSFTP, db.ExportDestination{Host: "backup.lan", User: "roostr", Path: "/srv/nostr"}, true},
+		{"sftp_quote", ExportDestinationSFTP, db.ExportDestination{Host: "backup.lan", User: "roostr", Path: "/srv/\"x"}, false},
+		{"sftp_ip_and_key", ExportDestinationSFTP, db.ExportDestination{Host: "192.168.1.20", User: "nostr.backup_1", Path: "/srv/nostr", KeyPath: "/data/.ssh/id_ed25519"}, true},
+		{"sftp_option_host", ExportDestinationSFTP, db.ExportDestination{Host: "-oProxyCommand=sh -c id", User: "roostr", Path: "/srv/nostr"}, false},
+		{"sftp_host_space", ExportDestinationSFTP, db.ExportDestination{Host: "backup.lan -v", User: "roostr", Path: "/srv/nostr"}, false},
+		{"sftp_option_user", ExportDestinationSFTP, db.ExportDestination{Host: "backup.lan", User: "-oProxyCommand=id", Path: "/srv/nostr"}, false},
+		{"sftp_user_at", ExportDestinationSFTP, db.ExportDestination{Host: "backup.lan", User: "a@evil", Path: "/srv/nostr"}, false},
+		{"sftp_option_key", ExportDestinationSFTP, db.ExportDestination{Host: "backup.lan", User: "roostr", Path: "/srv/nostr", KeyPath: "-oProxyCommand=id"}, false},
+		{"sftp_relative_key", ExportDestinationSFTP, db.ExportDestination{Host: "backup.lan", User: "roostr", Path: "/srv/nostr", KeyPath: "keys/id_ed25519"}, false},
+		{"webdav", ExportDestinationWebDAV, db.ExportDestination{URL: "https://cloud.example.com/remote.php/dav/files/alice/roostr", User: "alice", Password: "app-password"}, true},
+		{"webdav_ftp", ExportDestinationWebDAV, db.ExportDestination{URL: "ftp://cloud.example.com/roostr"}, false},
+		{"webdav_password_without_user", ExportDestinationWebDAV, db.ExportDestination{URL: "https://cloud.example.com/dav", Password: "x"}, false},
+		{"relay", ExportDestinationRelay, db.ExportDestination{RelayURL: "wss://relay.example.com"}, true},
+		{"relay_http", ExportDestinationRelay, db.ExportDestination{RelayURL: "https://relay.example.com"}, false},
+		{"unknown", "ftp", db.ExportDestination{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateExportDestination(tt.destType, tt.dest)
+			if (err == nil) != tt.valid {
+				t.Errorf("ValidateExportDestination() error = %v, want valid=%v", err, tt.valid)
+			}
+		})
+	}
+}
+
+// readLines is used to check NDJSON artifacts line by line.
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
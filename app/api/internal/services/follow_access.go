@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// FollowAccessResult is what a follow access run found and changed.
+type FollowAccessResult struct {
+	Members int      `json:"members"` // Members whose follows were counted
+	Lists   int      `json:"lists"`   // Members with a contact list on the relay
+	Granted int      `json:"granted"` // Pubkeys granted access after the run
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// FollowAccessService lets pubkeys followed by enough whitelisted members
+// post in whitelist and paid modes. It counts the members' latest kind-3
+// contact lists stored on the relay and keeps the follow grants in step,
+// each with the members it came through. Granted pubkeys are part of the
+// effective whitelist written to config.toml and used by the admission
+// server.
+type FollowAccessService struct {
+	db        *db.DB
+	configMgr *relay.ConfigManager
+	relay     *relay.Relay
+	admission *AdmissionService
+	interval  time.Duration
+	runMu     sync.Mutex // one run at a time
+	wakeCh    chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+}
+
+// NewFollowAccessService creates a new follow access service.
+func NewFollowAccessService(database *db.DB, configMgr *relay.ConfigManager, relayCtl *relay.Relay) *FollowAccessService {
+	return &FollowAccessService{
+		db:        database,
+		configMgr: configMgr,
+		relay:     relayCtl,
+		interval:  time.Hour,
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins recomputing follow grants in the background.
+func (s *FollowAccessService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the follow access worker.
+func (s *FollowAccessService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to recompute now, e.g. after the whitelist or the
+// settings change.
+func (s *FollowAccessService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *FollowAccessService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Run(context.Background()); err != nil {
+			slog.Error("Failed to update follow grants", "error", err)
+		}
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		case <-s.wakeCh:
+		}
+	}
+}
+
+// Run recomputes the follow grants. When follow access is off, or the access
+// mode is not whitelist or paid, all grants are revoked.
+func (s *FollowAccessService) Run(ctx context.Context) (*FollowAccessResult, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	result := &FollowAccessResult{Added: []string{}, Removed: []string{}}
+	settings, err := s.db.GetFollowAccessSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := s.db.GetAccessMode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []db.FollowGrant
+	if settings.Enabled && (mode == "whitelist" || mode == "paid") {
+		if !s.db.IsRelayDBConnected() {
+			// Keep the grants rather than revoke them all while the
+			// contact lists cannot be read
+			return result, nil
+		}
+		if grants, err = s.computeGrants(ctx, settings.MinFollowers, result); err != nil {
+			return nil, err
+		}
+	}
+
+	added, removed, err := s.db.ReplaceFollowGrants(ctx, grants)
+	if err != nil {
+		return nil, err
+	}
+	result.Granted = len(grants)
+	if added != nil {
+		result.Added = added
+	}
+	if removed != nil {
+		result.Removed = removed
+	}
+	if len(added)+len(removed) == 0 {
+		return result, nil
+	}
+
+	slog.Info("Updated follow grants", "granted", result.Granted, "added", len(added), "removed", len(removed))
+	s.db.AddAuditLog(ctx, "follow_grants_updated", map[string]interface{}{
+		"min_followers": settings.MinFollowers,
+		"added":         added,
+		"removed":       removed,
+	}, "follow_access")
+	applyAccessLists(ctx, s.db, s.configMgr, s.relay, s.admission)
+	return result, nil
+}
+
+// computeGrants counts how many members follow each pubkey, from each
+// member's latest contact list, and returns those followed by at least
+// minFollowers. Members and blacklisted pubkeys are never granted.
+func (s *FollowAccessService) computeGrants(ctx context.Context, minFollowers int, result *FollowAccessResult) ([]db.FollowGrant, error) {
+	if minFollowers < 1 {
+		minFollowers = 1
+	}
+	members, err := s.db.GetWhitelistMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	excluded := make(map[string]bool, len(members))
+	for _, m := range members {
+		excluded[m.Pubkey] = true
+	}
+	blacklist, err := s.db.GetBlacklist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range blacklist {
+		excluded[e.Pubkey] = true
+	}
+
+	result.Members = len(members)
+	via := make(map[string][]string)
+	for _, m := range members {
+		events, err := s.db.GetEvents(ctx, db.EventFilter{Authors: []string{m.Pubkey}, Kinds: []int{3}, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			continue
+		}
+		result.Lists++
+		contacts := &nostr.SyncEvent{Pubkey: m.Pubkey, Kind: 3, Tags: events[0].Tags}
+		for _, pk := range contactPubkeys(contacts, m.Pubkey) {
+			if !excluded[pk] {
+				via[pk] = append(via[pk], m.Pubkey)
+			}
+		}
+	}
+
+	var grants []db.FollowGrant
+	for pk, followers := range via {
+		if len(followers) < minFollowers {
+			continue
+		}
+		grants = append(grants, db.FollowGrant{Pubkey: pk, Followers: len(followers), Via: followers})
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].Pubkey < grants[j].Pubkey })
+	return grants, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// insertContactList stores a kind-3 contact list following the given pubkeys.
+func insertContactList(t *testing.T, relayDB *sql.DB, n byte, author string, createdAt int64, follows ...string) {
+	t.Helper()
+	id := strings.Repeat(hex.EncodeToString([]byte{n}), 32)
+	tags := [][]string{}
+	for _, pk := range follows {
+		tags = append(tags, []string{"p", pk})
+	}
+	raw, _ := json.Marshal(map[string]interface{}{
+		"id": id, "pubkey": author, "created_at": createdAt, "kind": 3,
+		"tags": tags, "content": "", "sig": "",
+	})
+	hash, _ := hex.DecodeString(id)
+	authorBytes, _ := hex.DecodeString(author)
+	if _, err := relayDB.Exec(`INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content) VALUES (?, ?, ?, ?, 3, 0, ?)`,
+		hash, time.Now().Unix(), createdAt, authorBytes, string(raw)); err != nil {
+		t.Fatalf("failed to insert contact list: %v", err)
+	}
+}
+
+func TestFollowAccessService_Run(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	alice := strings.Repeat("a", 64)
+	bob := strings.Repeat("b", 64)
+	carol := strings.Repeat("c", 64)
+	dave := strings.Repeat("d", 64)
+	eve := strings.Repeat("e", 64)
+	spammer := strings.Repeat("f", 64)
+
+	database.SetAccessMode(ctx, "whitelist")
+	for _, pk := range []string{alice, bob, carol} {
+		database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: pk, Npub: "npub1" + pk[:8]})
+	}
+	database.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: spammer, Npub: "npub1spam"})
+
+	// Only alice's newest list counts; members and the blacklist are skipped
+	insertContactList(t, relayDB, 1, alice, 1000, eve)
+	insertContactList(t, relayDB, 2, alice, 2000, dave, eve, bob, spammer)
+	insertContactList(t, relayDB, 3, bob, 2000, dave, spammer, alice)
+	insertContactList(t, relayDB, 4, carol, 2000, eve)
+
+	svc := NewFollowAccessService(database, nil, nil)
+	database.SetFollowAccessSettings(ctx, &db.FollowAccessSettings{Enabled: true, MinFollowers: 2})
+
+	result, err := svc.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Members != 3 || result.Lists != 3 || result.Granted != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !slices.Equal(result.Added, []string{dave, eve}) {
+		t.Errorf("expected dave and eve granted, got %v", result.Added)
+	}
+
+	grants, err := database.GetFollowGrants(ctx)
+	if err != nil {
+		t.Fatalf("GetFollowGrants failed: %v", err)
+	}
+	via := map[string][]string{}
+	for _, g := range grants {
+		slices.Sort(g.Via)
+		via[g.Pubkey] = g.Via
+	}
+	if !slices.Equal(via[dave], []string{alice, bob}) || !slices.Equal(via[eve], []string{alice, carol}) {
+		t.Errorf("unexpected provenance: %v", via)
+	}
+
+	whitelist, _, err := AccessLists(ctx, database)
+	if err != nil {
+		t.Fatalf("AccessLists failed: %v", err)
+	}
+	if len(whitelist) != 5 || whitelist[3] != dave || whitelist[4] != eve {
+		t.Errorf("expected members then grants, got %v", whitelist)
+	}
+
+	// Raising the threshold revokes the grants
+	database.SetFollowAccessSettings(ctx, &db.FollowAccessSettings{Enabled: true, MinFollowers: 3})
+	if result, err = svc.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Granted != 0 || !slices.Equal(result.Removed, []string{dave, eve}) {
+		t.Errorf("expected the grants revoked, got %+v", result)
+	}
+
+	// Grants only apply in whitelist and paid modes
+	database.SetFollowAccessSettings(ctx, &db.FollowAccessSettings{Enabled: true, MinFollowers: 1})
+	database.SetAccessMode(ctx, "open")
+	if result, err = svc.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Granted != 0 {
+		t.Errorf("expected no grants in open mode, got %+v", result)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+const (
+	// followsRelayTimeout bounds each relay query made while importing follows.
+	followsRelayTimeout = 15 * time.Second
+
+	// profileBatchSize is how many authors go in one kind-0 filter; relays
+	// commonly reject larger filters.
+	profileBatchSize = 250
+)
+
+// ErrNoContactList is returned when no relay has a contact list for the pubkey.
+var ErrNoContactList = errors.New("no contact list found on the given relays")
+
+// Follow is a pubkey from a NIP-02 contact list, with the name from its
+// kind-0 profile when one was found.
+type Follow struct {
+	Pubkey string `json:"pubkey"`
+	Npub   string `json:"npub"`
+	Name   string `json:"name,omitempty"`
+}
+
+// FollowList is a pubkey's latest contact list.
+type FollowList struct {
+	Pubkey    string    `json:"pubkey"`
+	CreatedAt time.Time `json:"created_at"`
+	Relay     string    `json:"relay"` // Relay the contact list was taken from
+	Follows   []Follow  `json:"follows"`
+}
+
+// FetchFollows fetches the newest kind-3 contact list for pubkey from the
+// given relays and resolves a display name for each follow from kind-0
+// profiles. Relays that fail are skipped.
+func FetchFollows(ctx context.Context, pubkey string, relays []string) (*FollowList, error) {
+	var contacts *nostr.SyncEvent
+	var contactsRelay string
+	for _, url := range relays {
+		err := queryRelay(ctx, url, nostr.Filter{Authors: []string{pubkey}, Kinds: []int{3}, Limit: 1}, func(event *nostr.SyncEvent) {
+			if event.Pubkey != pubkey || event.Kind != 3 {
+				return
+			}
+			if contacts == nil || event.CreatedAt > contacts.CreatedAt {
+				contacts = event
+				contactsRelay = url
+			}
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to fetch contact list", "relay", url, "error", err)
+		}
+	}
+	if contacts == nil {
+		return nil, ErrNoContactList
+	}
+
+	list := &FollowList{
+		Pubkey:    pubkey,
+		CreatedAt: time.Unix(contacts.CreatedAt, 0),
+		Relay:     contactsRelay,
+		Follows:   []Follow{},
+	}
+
+	pubkeys := contactPubkeys(contacts, pubkey)
+	names := fetchProfileNames(ctx, pubkeys, relays)
+	for _, pk := range pubkeys {
+		npub, _ := nostr.EncodeNpub(pk)
+		list.Follows = append(list.Follows, Follow{Pubkey: pk, Npub: npub, Name: names[pk]})
+	}
+	return list, nil
+}
+
+// contactPubkeys returns the valid, de-duplicated "p" tags of a contact list,
+// excluding the owner.
+func contactPubkeys(event *nostr.SyncEvent, owner string) []string {
+	seen := map[string]bool{owner: true}
+	var pubkeys []string
+	for _, tag := range event.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		pk := strings.ToLower(tag[1])
+		if seen[pk] || !nostr.IsValidHexPubkey(pk) {
+			continue
+		}
+		seen[pk] = true
+		pubkeys = append(pubkeys, pk)
+	}
+	return pubkeys
+}
+
+// fetchProfileNames returns a display name for each pubkey with a kind-0
+// profile.
+func fetchProfileNames(ctx context.Context, pubkeys []string, relays []string) map[string]string {
+	names := make(map[string]string)
+	for pk, event := range fetchProfiles(ctx, pubkeys, relays) {
+		if name := profileName(event.Content); name != "" {
+			names[pk] = name
+		}
+	}
+	return names
+}
+
+// fetchProfiles returns the newest kind-0 event found for each pubkey, asking
+// each relay only for the profiles still missing.
+func fetchProfiles(ctx context.Context, pubkeys []string, relays []string) map[string]*nostr.SyncEvent {
+	profiles := make(map[string]*nostr.SyncEvent)
+
+	for _, url := range relays {
+		var missing []string
+		for _, pk := range pubkeys {
+			if _, ok := profiles[pk]; !ok {
+				missing = append(missing, pk)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+
+		for start := 0; start < len(missing); start += profileBatchSize {
+			end := start + profileBatchSize
+			if end > len(missing) {
+				end = len(missing)
+			}
+			filter := nostr.Filter{Authors: missing[start:end], Kinds: []int{0}}
+			err := queryRelay(ctx, url, filter, func(event *nostr.SyncEvent) {
+				if event.Kind != 0 {
+					return
+				}
+				if current, ok := profiles[event.Pubkey]; ok && event.CreatedAt <= current.CreatedAt {
+					return
+				}
+				profiles[event.Pubkey] = event
+			})
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to fetch profiles", "relay", url, "error", err)
+				break
+			}
+		}
+	}
+	return profiles
+}
+
+// profileName picks a display name from kind-0 metadata, preferring
+// display_name over name.
+func profileName(content string) string {
+	var meta struct {
+		DisplayName string `json:"display_name"`
+		Name        string `json:"name"`
+	}
+	if json.Unmarshal([]byte(content), &meta) != nil {
+		return ""
+	}
+	if name := strings.TrimSpace(meta.DisplayName); name != "" {
+		return name
+	}
+	return strings.TrimSpace(meta.Name)
+}
+
+// queryRelay runs one filter against a relay until EOSE, passing each
+// verified event to callback.
+func queryRelay(ctx context.Context, url string, filter nostr.Filter, callback func(*nostr.SyncEvent)) error {
+	ctx, cancel := context.WithTimeout(ctx, followsRelayTimeout)
+	defer cancel()
+
+	client := nostr.NewClient(url)
+	if err := client.Connect(ctx); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Subscribe(ctx, filter, func(event *nostr.SyncEvent) error {
+		if event.Verify() != nil {
+			return nil
+		}
+		callback(event)
+		return nil
+	})
+}
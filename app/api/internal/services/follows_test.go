@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestContactPubkeys(t *testing.T) {
+	owner := "1111111111111111111111111111111111111111111111111111111111111111"
+	alice := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	bob := "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+
+	event := &nostr.SyncEvent{Tags: [][]string{
+		{"p", alice},
+		{"p", "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB"},
+		{"p", bob},
+		{"p", owner},
+		{"p", "not-a-pubkey"},
+		{"e", alice},
+		{"p"},
+	}}
+
+	got := contactPubkeys(event, owner)
+	if len(got) != 2 || got[0] != alice || got[1] != bob {
+		t.Errorf("unexpected pubkeys: %v", got)
+	}
+}
+
+func TestProfileName(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"display_name":"Alice","name":"alice"}`, "Alice"},
+		{`{"display_name":" ","name":"alice"}`, "alice"},
+		{`{"about":"no name"}`, ""},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		if got := profileName(tt.content); got != tt.want {
+			t.Errorf("profileName(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestFetchFollows(t *testing.T) {
+	ctx := context.Background()
+
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	operator, _ := nostr.GetPublicKey(operatorSecret)
+	aliceSecret, _ := nostr.GenerateSecretKey()
+	alice, _ := nostr.GetPublicKey(aliceSecret)
+	bobSecret, _ := nostr.GenerateSecretKey()
+	bob, _ := nostr.GetPublicKey(bobSecret)
+
+	fake, url := newFakeBackupRelay(t)
+
+	t.Run("no_contact_list", func(t *testing.T) {
+		if _, err := FetchFollows(ctx, operator, []string{url}); !errors.Is(err, ErrNoContactList) {
+			t.Errorf("expected ErrNoContactList, got %v", err)
+		}
+	})
+
+	older := nostr.NewEvent(3, [][]string{{"p", bob}}, "")
+	older.CreatedAt -= 60
+	older.Sign(operatorSecret)
+	contacts := nostr.NewEvent(3, [][]string{{"p", alice}, {"p", bob}}, "")
+	contacts.Sign(operatorSecret)
+	profile := nostr.NewEvent(0, nil, `{"name":"alice"}`)
+	profile.Sign(aliceSecret)
+	fake.events = append(fake.events, older, contacts, profile)
+
+	list, err := FetchFollows(ctx, operator, []string{url})
+	if err != nil {
+		t.Fatalf("FetchFollows failed: %v", err)
+	}
+	if list.Relay != url || list.CreatedAt.Unix() != contacts.CreatedAt {
+		t.Errorf("expected the newest contact list, got %+v", list)
+	}
+	if len(list.Follows) != 2 {
+		t.Fatalf("expected 2 follows, got %d", len(list.Follows))
+	}
+	if list.Follows[0].Pubkey != alice || list.Follows[0].Name != "alice" || list.Follows[0].Npub == "" {
+		t.Errorf("unexpected follow: %+v", list.Follows[0])
+	}
+	if list.Follows[1].Pubkey != bob || list.Follows[1].Name != "" {
+		t.Errorf("unexpected follow: %+v", list.Follows[1])
+	}
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Storage types reported by hardware detection.
+const (
+	StorageSD      = "sd"
+	StorageSSD     = "ssd"
+	StorageHDD     = "hdd"
+	StorageUnknown = "unknown"
+)
+
+// Low-power mode settings. Auto turns low-power mode on for small hardware.
+const (
+	LowPowerAuto = "auto"
+	LowPowerOn   = "on"
+	LowPowerOff  = "off"
+)
+
+const (
+	// smallHardwareMemory is the RAM below which hardware counts as small.
+	smallHardwareMemory = 1 << 30 // 1 GiB
+)
+
+// ErrInvalidLowPowerMode is returned for a low-power mode other than auto,
+// on or off.
+var ErrInvalidLowPowerMode = errors.New("low-power mode must be auto, on or off")
+
+// HardwareProfile describes the machine Roostr runs on.
+type HardwareProfile struct {
+	MemoryBytes uint64 `json:"memory_bytes"` // 0 if unknown
+	CPUs        int    `json:"cpus"`
+	Storage     string `json:"storage"` // sd, ssd, hdd or unknown
+	Small       bool   `json:"small"`   // Pi Zero-class: little RAM, one CPU or an SD card
+}
+
+// Tuning holds the limits that depend on whether low-power mode is on.
+type Tuning struct {
+	LowPower        bool `json:"low_power"`
+	QueryLimit      int  `json:"query_limit"`      // Most events one relay database query returns
+	CacheSizeKB     int  `json:"cache_size_kb"`    // SQLite page cache per connection
+	RelayReaders    int  `json:"relay_readers"`    // Read connections to the relay database
+	SyncConcurrency int  `json:"sync_concurrency"` // Relays a sync fetches from at once
+	RefreshFactor   int  `json:"refresh_factor"`   // Multiplies how long profiles and coverage checks are reused
+}
+
+var (
+	// standardTuning is used unless low-power mode is on.
+	standardTuning = Tuning{
+		QueryLimit:      db.DefaultLimits.MaxQueryLimit,
+		CacheSizeKB:     db.DefaultLimits.CacheSizeKB,
+		RelayReaders:    db.DefaultLimits.RelayReaders,
+		SyncConcurrency: 3,
+		RefreshFactor:   1,
+	}
+
+	// lowPowerTuning keeps memory, I/O and network use down on small boxes.
+	lowPowerTuning = Tuning{
+		LowPower:        true,
+		QueryLimit:      200,
+		CacheSizeKB:     1024,
+		RelayReaders:    1,
+		SyncConcurrency: 1,
+		RefreshFactor:   7,
+	}
+)
+
+// DetectHardware reports the machine's RAM and CPUs, and the type of storage
+// that holds path.
+func DetectHardware(path string) HardwareProfile {
+	p := HardwareProfile{
+		CPUs:    runtime.NumCPU(),
+		Storage: detectStorage(path),
+	}
+	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+		p.MemoryBytes = parseMemTotal(data)
+	}
+	p.Small = isSmallHardware(p)
+	return p
+}
+
+// isSmallHardware reports whether a machine should run in low-power mode by
+// default.
+func isSmallHardware(p HardwareProfile) bool {
+	return (p.MemoryBytes > 0 && p.MemoryBytes < smallHardwareMemory) || p.CPUs <= 1 || p.Storage == StorageSD
+}
+
+// parseMemTotal returns the MemTotal of /proc/meminfo in bytes, or 0.
+func parseMemTotal(meminfo []byte) uint64 {
+	scanner := bufio.NewScanner(bytes.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
+
+// detectStorage looks up the block device holding path in sysfs. SD and eMMC
+// cards show up as mmcblk devices; otherwise the rotational flag tells disks
+// from flash.
+func detectStorage(path string) string {
+	var st syscall.Stat_t
+	for path != "" {
+		if err := syscall.Stat(path, &st); err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return StorageUnknown
+		}
+		path = parent
+	}
+
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	sysPath := fmt.Sprintf("/sys/dev/block/%d:%d", major, minor)
+
+	target, err := filepath.EvalSymlinks(sysPath)
+	if err != nil {
+		return StorageUnknown
+	}
+	return classifyBlockDevice(target)
+}
+
+// classifyBlockDevice returns the storage type of a sysfs block device or
+// partition directory.
+func classifyBlockDevice(sysPath string) string {
+	if strings.HasPrefix(filepath.Base(sysPath), "mmcblk") {
+		return StorageSD
+	}
+	// Partitions keep the queue attributes on their parent device
+	for _, dir := range []string{sysPath, filepath.Dir(sysPath)} {
+		data, err := os.ReadFile(filepath.Join(dir, "queue", "rotational"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(data)) == "1" {
+			return StorageHDD
+		}
+		return StorageSSD
+	}
+	return StorageUnknown
+}
+
+// HardwareService detects the hardware at startup and tunes query limits,
+// cache sizes, sync concurrency and background refreshes to it. Low-power
+// mode can be forced on or off in settings; "auto" follows the detected
+// hardware.
+type HardwareService struct {
+	db      *db.DB
+	mu      sync.RWMutex
+	profile HardwareProfile
+	mode    string
+	tuning  Tuning
+}
+
+// NewHardwareService creates a new hardware service with standard tuning.
+func NewHardwareService(database *db.DB) *HardwareService {
+	return &HardwareService{
+		db:      database,
+		profile: HardwareProfile{CPUs: runtime.NumCPU(), Storage: StorageUnknown},
+		mode:    LowPowerAuto,
+		tuning:  standardTuning,
+	}
+}
+
+// Configure sets the detected hardware profile. Call before Start.
+func (s *HardwareService) Configure(profile HardwareProfile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile = profile
+}
+
+// Start loads the low-power mode setting and applies its tuning.
+func (s *HardwareService) Start() {
+	mode, err := s.db.GetLowPowerMode(context.Background())
+	if err != nil {
+		slog.Warn("Failed to load low-power mode setting", "error", err)
+		mode = LowPowerAuto
+	}
+
+	s.mu.Lock()
+	s.mode = mode
+	s.mu.Unlock()
+
+	tuning := s.apply()
+	slog.Info("Hardware tuning applied", "mode", mode, "low_power", tuning.LowPower,
+		"memory_bytes", s.Profile().MemoryBytes, "cpus", s.Profile().CPUs, "storage", s.Profile().Storage)
+}
+
+// Profile returns the detected hardware profile.
+func (s *HardwareService) Profile() HardwareProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.profile
+}
+
+// Mode returns the low-power mode setting: auto, on or off.
+func (s *HardwareService) Mode() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mode
+}
+
+// Tuning returns the limits in effect. A nil service returns standard tuning.
+func (s *HardwareService) Tuning() Tuning {
+	if s == nil {
+		return standardTuning
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tuning
+}
+
+// SetMode saves the low-power mode setting and applies its tuning.
+func (s *HardwareService) SetMode(ctx context.Context, mode string) (Tuning, error) {
+	if mode != LowPowerAuto && mode != LowPowerOn && mode != LowPowerOff {
+		return Tuning{}, ErrInvalidLowPowerMode
+	}
+	if err := s.db.SetLowPowerMode(ctx, mode); err != nil {
+		return Tuning{}, err
+	}
+
+	s.mu.Lock()
+	s.mode = mode
+	s.mu.Unlock()
+
+	return s.apply(), nil
+}
+
+// apply picks the tuning for the current mode and hardware and hands the
+// database limits to the database.
+func (s *HardwareService) apply() Tuning {
+	s.mu.Lock()
+	lowPower := s.mode == LowPowerOn || (s.mode == LowPowerAuto && s.profile.Small)
+	s.tuning = standardTuning
+	if lowPower {
+		s.tuning = lowPowerTuning
+	}
+	tuning := s.tuning
+	s.mu.Unlock()
+
+	if err := s.db.SetLimits(db.Limits{
+		MaxQueryLimit: tuning.QueryLimit,
+		CacheSizeKB:   tuning.CacheSizeKB,
+		RelayReaders:  tuning.RelayReaders,
+	}); err != nil {
+		slog.Warn("Failed to apply database limits", "error", err)
+	}
+	return tuning
+}
+
+// refreshAge scales how long a background worker reuses a result by the
+// tuning's refresh factor.
+func (t Tuning) refreshAge(age time.Duration) time.Duration {
+	if t.RefreshFactor <= 1 {
+		return age
+	}
+	return age * time.Duration(t.RefreshFactor)
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseMemTotal(t *testing.T) {
+	meminfo := []byte("MemTotal:         443500 kB\nMemFree:          120000 kB\n")
+	if got := parseMemTotal(meminfo); got != 443500*1024 {
+		t.Errorf("expected %d bytes, got %d", 443500*1024, got)
+	}
+	if got := parseMemTotal([]byte("MemFree: 1 kB\n")); got != 0 {
+		t.Errorf("expected 0 without MemTotal, got %d", got)
+	}
+}
+
+func TestIsSmallHardware(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile HardwareProfile
+		want    bool
+	}{
+		{"pi zero", HardwareProfile{MemoryBytes: 512 << 20, CPUs: 1, Storage: StorageSD}, true},
+		{"little RAM", HardwareProfile{MemoryBytes: 512 << 20, CPUs: 4, Storage: StorageSSD}, true},
+		{"SD card", HardwareProfile{MemoryBytes: 4 << 30, CPUs: 4, Storage: StorageSD}, true},
+		{"single CPU", HardwareProfile{MemoryBytes: 4 << 30, CPUs: 1, Storage: StorageSSD}, true},
+		{"mini PC", HardwareProfile{MemoryBytes: 8 << 30, CPUs: 4, Storage: StorageSSD}, false},
+		{"unknown RAM", HardwareProfile{CPUs: 4, Storage: StorageUnknown}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSmallHardware(tt.profile); got != tt.want {
+				t.Errorf("isSmallHardware(%+v) = %v, want %v", tt.profile, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyBlockDevice(t *testing.T) {
+	dir := t.TempDir()
+	device := func(path, rotational string) string {
+		full := filepath.Join(dir, path)
+		os.MkdirAll(full, 0755)
+		if rotational != "" {
+			os.MkdirAll(filepath.Join(full, "queue"), 0755)
+			os.WriteFile(filepath.Join(full, "queue", "rotational"), []byte(rotational+"\n"), 0644)
+		}
+		return full
+	}
+
+	device("sda", "1")
+	device("nvme0n1", "0")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{device("mmcblk0/mmcblk0p2", ""), StorageSD},
+		{device("sda/sda1", ""), StorageHDD},
+		{device("nvme0n1/nvme0n1p1", ""), StorageSSD},
+		{device("loop0", ""), StorageUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyBlockDevice(tt.path); got != tt.want {
+			t.Errorf("classifyBlockDevice(%s) = %q, want %q", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}
+
+func TestHardwareServiceSetMode(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	svc := NewHardwareService(database)
+	svc.Configure(HardwareProfile{MemoryBytes: 512 << 20, CPUs: 1, Storage: StorageSD, Small: true})
+	svc.Start()
+
+	// Auto follows the detected hardware
+	if svc.Mode() != LowPowerAuto || !svc.Tuning().LowPower {
+		t.Fatalf("expected auto low-power mode on small hardware, got %q %+v", svc.Mode(), svc.Tuning())
+	}
+	if limits := database.Limits(); limits.MaxQueryLimit != lowPowerTuning.QueryLimit || limits.RelayReaders != 1 {
+		t.Errorf("expected low-power database limits, got %+v", limits)
+	}
+
+	tuning, err := svc.SetMode(ctx, LowPowerOff)
+	if err != nil {
+		t.Fatalf("SetMode failed: %v", err)
+	}
+	if tuning.LowPower || database.Limits().MaxQueryLimit != standardTuning.QueryLimit {
+		t.Errorf("expected standard tuning, got %+v", tuning)
+	}
+	if mode, _ := database.GetLowPowerMode(ctx); mode != LowPowerOff {
+		t.Errorf("expected the mode to be saved, got %q", mode)
+	}
+
+	if _, err := svc.SetMode(ctx, "max"); !errors.Is(err, ErrInvalidLowPowerMode) {
+		t.Errorf("expected ErrInvalidLowPowerMode, got %v", err)
+	}
+
+	// The saved mode survives a restart
+	restarted := NewHardwareService(database)
+	restarted.Configure(HardwareProfile{MemoryBytes: 512 << 20, CPUs: 1, Small: true})
+	restarted.Start()
+	if restarted.Mode() != LowPowerOff || restarted.Tuning().LowPower {
+		t.Errorf("expected low-power mode to stay off, got %q %+v", restarted.Mode(), restarted.Tuning())
+	}
+}
+
+func TestTuningRefreshAge(t *testing.T) {
+	if got := standardTuning.refreshAge(24 * time.Hour); got != 24*time.Hour {
+		t.Errorf("expected standard tuning to keep the age, got %v", got)
+	}
+	if got := lowPowerTuning.refreshAge(24 * time.Hour); got != 7*24*time.Hour {
+		t.Errorf("expected low-power tuning to refresh weekly, got %v", got)
+	}
+	var nilService *HardwareService
+	if got := nilService.Tuning(); got != standardTuning {
+		t.Errorf("expected standard tuning without a service, got %+v", got)
+	}
+}
@@ -2,26 +2,46 @@ package services
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
 	"github.com/roostr/roostr/app/api/internal/relay"
 )
 
+// Invoice detection modes reported by Mode.
+const (
+	InvoiceDetectionStreaming = "streaming"
+	InvoiceDetectionPolling   = "polling"
+)
+
+// Reconnect backoff bounds for the invoice subscription.
+const (
+	subscriptionMinBackoff = 1 * time.Second
+	subscriptionMaxBackoff = 2 * time.Minute
+)
+
 // InvoiceMonitorService monitors pending invoices and processes payments.
-// It uses both polling and LND WebSocket subscription for payment detection.
+// It relies on LND's invoice subscription stream when available and falls
+// back to polling CheckInvoice when streaming is unsupported or disconnected.
 type InvoiceMonitorService struct {
-	db        *db.DB
-	lightning *LightningService
-	configMgr *relay.ConfigManager
-	relay     *relay.Relay
-	interval  time.Duration
-	stopCh    chan struct{}
-	wg        sync.WaitGroup
-	running   bool
-	mu        sync.Mutex
+	db             *db.DB
+	lightning      *LightningService
+	webhooks       *WebhookService
+	zaps           *ZapService
+	configMgr      *relay.ConfigManager
+	relay          *relay.Relay
+	interval       time.Duration // polling interval without a stream
+	safetyInterval time.Duration // sweep interval while streaming
+	streaming      atomic.Bool
+	processMu      sync.Mutex
+	stopCh         chan struct{}
+	wg             sync.WaitGroup
+	running        bool
+	mu             sync.Mutex
 }
 
 // NewInvoiceMonitorService creates a new InvoiceMonitorService.
@@ -32,12 +52,13 @@ func NewInvoiceMonitorService(
 	relayCtl *relay.Relay,
 ) *InvoiceMonitorService {
 	return &InvoiceMonitorService{
-		db:        database,
-		lightning: lightning,
-		configMgr: configMgr,
-		relay:     relayCtl,
-		interval:  10 * time.Second,
-		stopCh:    make(chan struct{}),
+		db:             database,
+		lightning:      lightning,
+		configMgr:      configMgr,
+		relay:          relayCtl,
+		interval:       10 * time.Second,
+		safetyInterval: 5 * time.Minute,
+		stopCh:         make(chan struct{}),
 	}
 }
 
@@ -60,7 +81,7 @@ func (s *InvoiceMonitorService) Start() {
 	s.wg.Add(1)
 	go s.runSubscription()
 
-	log.Println("Invoice monitor service started")
+	slog.Info("Invoice monitor service started")
 }
 
 // Stop gracefully stops the invoice monitoring.
@@ -75,7 +96,7 @@ func (s *InvoiceMonitorService) Stop() {
 	s.mu.Unlock()
 
 	s.wg.Wait()
-	log.Println("Invoice monitor service stopped")
+	slog.Info("Invoice monitor service stopped")
 }
 
 // IsRunning returns whether the service is currently running.
@@ -85,7 +106,17 @@ func (s *InvoiceMonitorService) IsRunning() bool {
 	return s.running
 }
 
-// runPoller polls pending invoices at regular intervals.
+// Mode returns how settlements are currently detected: "streaming" while the
+// invoice subscription is connected, otherwise "polling".
+func (s *InvoiceMonitorService) Mode() string {
+	if s.streaming.Load() {
+		return InvoiceDetectionStreaming
+	}
+	return InvoiceDetectionPolling
+}
+
+// runPoller polls pending invoices. While the subscription stream is
+// connected it only runs an occasional safety sweep.
 func (s *InvoiceMonitorService) runPoller() {
 	defer s.wg.Done()
 
@@ -94,65 +125,118 @@ func (s *InvoiceMonitorService) runPoller() {
 
 	// Run immediately on start
 	s.checkPendingInvoices()
+	lastCheck := time.Now()
 
 	for {
 		select {
 		case <-s.stopCh:
 			return
 		case <-ticker.C:
+			if s.streaming.Load() && time.Since(lastCheck) < s.safetyInterval {
+				continue
+			}
 			s.checkPendingInvoices()
+			lastCheck = time.Now()
 		}
 	}
 }
 
-// runSubscription subscribes to LND invoice updates via streaming API.
+// runSubscription keeps an LND invoice subscription open, reconnecting
+// with exponential backoff.
 func (s *InvoiceMonitorService) runSubscription() {
 	defer s.wg.Done()
 
+	backoff := subscriptionMinBackoff
 	for {
 		select {
 		case <-s.stopCh:
 			return
 		default:
-			if !s.lightning.IsConfigured() {
-				// LND not configured, wait and retry
-				time.Sleep(30 * time.Second)
-				continue
+		}
+
+		if !s.lightning.IsConfigured() {
+			// Not configured, wait and retry
+			if !s.sleep(30 * time.Second) {
+				return
 			}
+			continue
+		}
+
+		connected, err := s.subscribeInvoices()
+		s.streaming.Store(false)
 
-			err := s.subscribeInvoices()
-			if err != nil {
-				log.Printf("Invoice subscription error: %v, reconnecting in 5s", err)
-				select {
-				case <-s.stopCh:
-					return
-				case <-time.After(5 * time.Second):
-					// Continue to retry
-				}
+		if errors.Is(err, ErrSubscribeUnsupported) {
+			// Node type has no streaming API; rely on the poller
+			if !s.sleep(30 * time.Second) {
+				return
 			}
+			continue
+		}
+
+		if connected {
+			// The stream was up, so start the backoff over
+			backoff = subscriptionMinBackoff
+		}
+		if err != nil {
+			slog.Warn("Invoice subscription error, polling until reconnected", "error", err, "retry_in", backoff)
 		}
+		if !s.sleep(backoff) {
+			return
+		}
+		backoff = nextSubscriptionBackoff(backoff)
+	}
+}
+
+// sleep waits for d and reports false if the service was stopped meanwhile.
+func (s *InvoiceMonitorService) sleep(d time.Duration) bool {
+	select {
+	case <-s.stopCh:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// nextSubscriptionBackoff doubles the reconnect delay up to subscriptionMaxBackoff.
+func nextSubscriptionBackoff(current time.Duration) time.Duration {
+	next := current * 2
+	if next > subscriptionMaxBackoff {
+		return subscriptionMaxBackoff
 	}
+	return next
 }
 
-// subscribeInvoices connects to LND's invoice subscription stream.
-func (s *InvoiceMonitorService) subscribeInvoices() error {
+// subscribeInvoices connects to LND's invoice subscription stream and blocks
+// until it ends. It reports whether the stream was established.
+func (s *InvoiceMonitorService) subscribeInvoices() (bool, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Monitor for stop signal
 	go func() {
-		<-s.stopCh
-		cancel()
+		select {
+		case <-s.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
 
-	return s.lightning.SubscribeInvoices(ctx, func(paymentHash string, settled bool) {
+	connected := false
+	err := s.lightning.SubscribeInvoices(ctx, func() {
+		connected = true
+		s.streaming.Store(true)
+		slog.Info("Invoice subscription connected")
+		// Catch up on anything settled while the stream was down
+		s.checkPendingInvoices()
+	}, func(paymentHash string, settled bool) {
 		if settled {
-			log.Printf("Invoice subscription: received settled invoice %s", paymentHash)
+			slog.Info("Invoice subscription: received settled invoice", "payment_hash", paymentHash)
 			if err := s.ProcessPayment(context.Background(), paymentHash); err != nil {
-				log.Printf("Failed to process payment from subscription: %v", err)
+				slog.Error("Failed to process payment from subscription", "payment_hash", paymentHash, "error", err)
 			}
 		}
 	})
+	return connected, err
 }
 
 // checkPendingInvoices checks all pending invoices with LND.
@@ -166,7 +250,7 @@ func (s *InvoiceMonitorService) checkPendingInvoices() {
 	// Get all pending invoices that haven't expired
 	invoices, err := s.db.GetPendingInvoicesAwaitingPayment(ctx)
 	if err != nil {
-		log.Printf("Failed to get pending invoices: %v", err)
+		slog.Error("Failed to get pending invoices", "error", err)
 		return
 	}
 
@@ -175,14 +259,14 @@ func (s *InvoiceMonitorService) checkPendingInvoices() {
 		lndInvoice, err := s.lightning.CheckInvoice(ctx, invoice.PaymentHash)
 		if err != nil {
 			// Log but continue checking other invoices
-			log.Printf("Failed to check invoice %s: %v", invoice.PaymentHash, err)
+			slog.Warn("Failed to check invoice", "payment_hash", invoice.PaymentHash, "error", err)
 			continue
 		}
 
 		if lndInvoice.Settled {
-			log.Printf("Invoice poller: detected settled invoice %s", invoice.PaymentHash)
+			slog.Info("Invoice poller: detected settled invoice", "payment_hash", invoice.PaymentHash)
 			if err := s.ProcessPayment(ctx, invoice.PaymentHash); err != nil {
-				log.Printf("Failed to process payment: %v", err)
+				slog.Error("Failed to process payment", "payment_hash", invoice.PaymentHash, "error", err)
 			}
 		}
 	}
@@ -191,6 +275,11 @@ func (s *InvoiceMonitorService) checkPendingInvoices() {
 // ProcessPayment handles a confirmed payment by auto-whitelisting the user.
 // This method is idempotent - safe to call multiple times for the same payment.
 func (s *InvoiceMonitorService) ProcessPayment(ctx context.Context, paymentHash string) error {
+	// Serialize processing so the stream, poller and status endpoint
+	// can't whitelist the same payment concurrently
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+
 	// 1. Get the pending invoice
 	pending, err := s.db.GetPendingInvoice(ctx, paymentHash)
 	if err != nil {
@@ -204,80 +293,186 @@ func (s *InvoiceMonitorService) ProcessPayment(ctx context.Context, paymentHash
 		// Already processed - idempotent
 		return nil
 	}
+	if pending.Status == "cancelled" {
+		// The user cancelled or replaced the invoice, and any coupon it
+		// used may have been released, so paying it grants nothing. Nodes
+		// that cannot cancel invoices still accept the payment, which the
+		// operator has to refund.
+		slog.WarnContext(ctx, "Cancelled invoice was paid", "pubkey", pending.Pubkey, "payment_hash", paymentHash)
+		s.db.AddAuditLog(ctx, "cancelled_invoice_paid", map[string]interface{}{
+			"pubkey":       pending.Pubkey,
+			"tier":         pending.TierID,
+			"amount_sats":  pending.AmountSats,
+			"payment_hash": paymentHash,
+		}, "")
+		return nil
+	}
 
-	log.Printf("Processing payment for pubkey %s (tier: %s, amount: %d sats)",
-		pending.Pubkey, pending.TierID, pending.AmountSats)
+	slog.InfoContext(ctx, "Processing payment",
+		"pubkey", pending.Pubkey, "tier", pending.TierID, "amount_sats", pending.AmountSats)
 
-	// 2. Get the pricing tier for expiry calculation
-	tier, err := s.getPricingTier(ctx, pending.TierID)
+	// 2. Whitelist the user and create or extend their subscription
+	expiresAt, renewal, err := s.grantAccess(ctx, pending.Pubkey, pending.Npub, pending.TierID, pending.AmountSats, "payment:"+pending.TierID)
 	if err != nil {
 		return err
 	}
+
+	// 3. Mark invoice as paid, and any coupon it used as redeemed
+	if err := s.db.UpdatePendingInvoiceStatus(ctx, paymentHash, "paid"); err != nil {
+		slog.WarnContext(ctx, "Failed to update invoice status", "payment_hash", paymentHash, "error", err)
+	}
+	if err := s.db.RedeemCouponPayment(ctx, paymentHash); err != nil {
+		slog.WarnContext(ctx, "Failed to redeem coupon", "payment_hash", paymentHash, "error", err)
+	}
+
+	// 4. Add payment history, noting invoices paid by melting a Cashu token
+	paymentType := db.PaymentTypeLightning
+	if cashu, _ := s.db.GetCashuPayment(ctx, paymentHash); cashu != nil {
+		paymentType = db.PaymentTypeCashu
+	}
+	if err := s.db.AddPaymentHistory(ctx, pending.Pubkey, paymentHash, pending.TierID, pending.AmountSats, pending.PaymentRequest, paymentType); err != nil {
+		slog.WarnContext(ctx, "Failed to add payment history", "payment_hash", paymentHash, "error", err)
+	}
+
+	// 5. Sync config.toml and reload relay
+	if err := s.syncWhitelist(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to sync whitelist", "error", err)
+	}
+
+	// 6. Audit log
+	s.db.AddAuditLog(ctx, "payment_confirmed", map[string]interface{}{
+		"pubkey":       pending.Pubkey,
+		"tier":         pending.TierID,
+		"amount_sats":  pending.AmountSats,
+		"payment_hash": paymentHash,
+		"payment_type": paymentType,
+		"renewal":      renewal,
+	}, "")
+
+	// 7. Notify webhooks
+	s.webhooks.Emit(WebhookEventInvoicePaid, map[string]interface{}{
+		"pubkey":       pending.Pubkey,
+		"npub":         pending.Npub,
+		"tier":         pending.TierID,
+		"amount_sats":  pending.AmountSats,
+		"payment_hash": paymentHash,
+		"expires_at":   expiresAt,
+	})
+	s.webhooks.Emit(WebhookEventUserWhitelisted, map[string]interface{}{
+		"pubkey": pending.Pubkey,
+		"npub":   pending.Npub,
+		"source": "payment",
+	})
+
+	// 8. Publish the zap receipt if the invoice was a zap, without holding
+	// up payment processing on relays
+	if s.zaps != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := s.zaps.PublishReceipt(context.Background(), paymentHash); err != nil {
+				slog.Warn("Failed to publish zap receipt", "payment_hash", paymentHash, "error", err)
+			}
+		}()
+	}
+
+	slog.InfoContext(ctx, "Processed payment", "pubkey", pending.Pubkey, "payment_hash", paymentHash)
+	return nil
+}
+
+// GrantCouponAccess gives a pubkey a tier's access without payment, for a
+// free-access coupon, and returns when it expires.
+func (s *InvoiceMonitorService) GrantCouponAccess(ctx context.Context, pubkey, npub, tierID, code string) (*time.Time, error) {
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+
+	expiresAt, renewal, err := s.grantAccess(ctx, pubkey, npub, tierID, 0, "coupon:"+code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.syncWhitelist(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to sync whitelist", "error", err)
+	}
+
+	s.db.AddAuditLog(ctx, "coupon_access_granted", map[string]interface{}{
+		"pubkey":  pubkey,
+		"tier":    tierID,
+		"coupon":  code,
+		"renewal": renewal,
+	}, "")
+
+	s.webhooks.Emit(WebhookEventUserWhitelisted, map[string]interface{}{
+		"pubkey": pubkey,
+		"npub":   npub,
+		"source": "coupon",
+	})
+
+	return expiresAt, nil
+}
+
+// grantAccess whitelists a pubkey and creates their paid user record, or
+// extends it on renewal. A renewal in the grace period continues from the
+// missed expiry. It returns the new expiry and whether it was a renewal.
+func (s *InvoiceMonitorService) grantAccess(ctx context.Context, pubkey, npub, tierID string, amountSats int64, addedBy string) (*time.Time, bool, error) {
+	// Get the pricing tier for expiry calculation
+	tier, err := s.getPricingTier(ctx, tierID)
+	if err != nil {
+		return nil, false, err
+	}
 	if tier == nil {
-		log.Printf("Warning: tier %s not found, using tier name from invoice", pending.TierID)
+		slog.WarnContext(ctx, "Tier not found, using tier name from invoice", "tier", tierID)
 	}
 
-	// 3. Calculate expiry date
+	// Calculate expiry date, extending the current period on renewal
+	existing, err := s.db.GetPaidUserByPubkey(ctx, pubkey)
+	if err != nil {
+		return nil, false, err
+	}
 	var expiresAt *time.Time
 	if tier != nil && tier.DurationDays != nil {
-		t := time.Now().AddDate(0, 0, *tier.DurationDays)
+		start := time.Now()
+		if existing != nil && existing.ExpiresAt != nil &&
+			(existing.Status == "grace" || existing.Status == "active" && existing.ExpiresAt.After(start)) {
+			start = *existing.ExpiresAt
+		}
+		t := start.AddDate(0, 0, *tier.DurationDays)
 		expiresAt = &t
 	}
 
-	// 4. Add to whitelist
+	// Add to whitelist
 	whitelistEntry := db.WhitelistEntry{
-		Pubkey:  pending.Pubkey,
-		Npub:    pending.Npub,
-		AddedBy: "payment:" + pending.TierID,
+		Pubkey:  pubkey,
+		Npub:    npub,
+		AddedBy: addedBy,
 	}
 	if err := s.db.AddWhitelistEntry(ctx, whitelistEntry); err != nil {
-		log.Printf("Warning: failed to add whitelist entry: %v", err)
+		slog.WarnContext(ctx, "Failed to add whitelist entry", "pubkey", pubkey, "error", err)
 		// Continue - user might already be whitelisted
 	}
 
-	// 5. Create or update paid user record
-	tierName := pending.TierID
+	// Create or update paid user record
+	tierName := tierID
 	if tier != nil {
 		tierName = tier.Name
 	}
 	paidUser := db.PaidUser{
-		Pubkey:     pending.Pubkey,
-		Npub:       pending.Npub,
+		Pubkey:     pubkey,
+		Npub:       npub,
 		Tier:       tierName,
-		AmountSats: pending.AmountSats,
+		AmountSats: amountSats,
 		Status:     "active",
 		ExpiresAt:  expiresAt,
 	}
-	if err := s.db.AddPaidUser(ctx, paidUser); err != nil {
-		log.Printf("Warning: failed to add paid user: %v", err)
-		// Continue - might be a renewal
-	}
-
-	// 6. Mark invoice as paid
-	if err := s.db.UpdatePendingInvoiceStatus(ctx, paymentHash, "paid"); err != nil {
-		log.Printf("Warning: failed to update invoice status: %v", err)
-	}
-
-	// 7. Add payment history
-	if err := s.db.AddPaymentHistory(ctx, pending.Pubkey, paymentHash, pending.TierID, pending.AmountSats, pending.PaymentRequest); err != nil {
-		log.Printf("Warning: failed to add payment history: %v", err)
-	}
-
-	// 8. Sync config.toml and reload relay
-	if err := s.syncWhitelist(ctx); err != nil {
-		log.Printf("Warning: failed to sync whitelist: %v", err)
+	if existing != nil {
+		if err := s.db.RenewPaidUser(ctx, paidUser); err != nil {
+			slog.WarnContext(ctx, "Failed to renew paid user", "pubkey", pubkey, "error", err)
+		}
+	} else if err := s.db.AddPaidUser(ctx, paidUser); err != nil {
+		slog.WarnContext(ctx, "Failed to add paid user", "pubkey", pubkey, "error", err)
 	}
 
-	// 9. Audit log
-	s.db.AddAuditLog(ctx, "payment_confirmed", map[string]interface{}{
-		"pubkey":       pending.Pubkey,
-		"tier":         pending.TierID,
-		"amount_sats":  pending.AmountSats,
-		"payment_hash": paymentHash,
-	}, "")
-
-	log.Printf("Successfully processed payment for %s", pending.Pubkey)
-	return nil
+	return expiresAt, existing != nil, nil
 }
 
 // getPricingTier retrieves a pricing tier by ID.
@@ -296,22 +491,17 @@ func (s *InvoiceMonitorService) getPricingTier(ctx context.Context, tierID strin
 
 // syncWhitelist syncs the whitelist from DB to config.toml and reloads the relay.
 func (s *InvoiceMonitorService) syncWhitelist(ctx context.Context) error {
-	if s.configMgr == nil {
+	// The admission server reads the whitelist from the DB
+	if s.configMgr == nil || s.configMgr.DelegatesAccess() {
 		return nil
 	}
 
-	// Get whitelist entries from DB
-	entries, err := s.db.GetWhitelistMeta(ctx)
+	// Get the whitelist, with follow grants, from DB
+	whitelist, err := s.db.GetEffectiveWhitelist(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Extract hex pubkeys for config.toml
-	whitelist := make([]string, len(entries))
-	for i, e := range entries {
-		whitelist[i] = e.Pubkey
-	}
-
 	// Update config.toml whitelist
 	if err := s.configMgr.UpdateWhitelist(whitelist); err != nil {
 		return err
@@ -320,7 +510,7 @@ func (s *InvoiceMonitorService) syncWhitelist(ctx context.Context) error {
 	// Reload relay to pick up config changes
 	if s.relay != nil {
 		if err := s.relay.Reload(); err != nil {
-			log.Printf("Warning: failed to reload relay: %v", err)
+			slog.Warn("Failed to reload relay", "error", err)
 		}
 	}
 
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextSubscriptionBackoff(t *testing.T) {
+	backoff := subscriptionMinBackoff
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second}
+	for _, want := range expected {
+		backoff = nextSubscriptionBackoff(backoff)
+		if backoff != want {
+			t.Errorf("expected %s, got %s", want, backoff)
+		}
+	}
+
+	if got := nextSubscriptionBackoff(subscriptionMaxBackoff); got != subscriptionMaxBackoff {
+		t.Errorf("expected backoff capped at %s, got %s", subscriptionMaxBackoff, got)
+	}
+}
+
+func TestInvoiceMonitorMode(t *testing.T) {
+	monitor := NewInvoiceMonitorService(nil, nil, nil, nil)
+	if monitor.Mode() != InvoiceDetectionPolling {
+		t.Errorf("expected polling mode by default, got %s", monitor.Mode())
+	}
+
+	monitor.streaming.Store(true)
+	if monitor.Mode() != InvoiceDetectionStreaming {
+		t.Errorf("expected streaming mode, got %s", monitor.Mode())
+	}
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Job types.
+const (
+	JobTypeVacuum         = "vacuum"
+	JobTypeIntegrityCheck = "integrity_check"
+	JobTypeCleanup        = "cleanup"
+	JobTypeRetention      = "retention"
+	JobTypeSync           = "sync"
+	JobTypeImport         = "import"
+	JobTypeExport         = "export"
+	JobTypeBroadcast      = "broadcast"
+	JobTypeBackupUpload   = "backup_upload"
+	JobTypeRelayIndexSync = "relay_index_sync"
+	JobTypeVerifyEvents   = "verify_events"
+)
+
+// jobProgressSaveInterval bounds how often progress is written to the
+// database. Subscribers see every update.
+const jobProgressSaveInterval = time.Second
+
+var (
+	// ErrJobAlreadyRunning is returned when a job of the same type is running.
+	ErrJobAlreadyRunning = errors.New("a job of this type is already running")
+
+	// ErrJobNotRunning is returned when cancelling a job that is not running.
+	ErrJobNotRunning = errors.New("job is not running")
+
+	// ErrJobsStopping is the cause of the cancellation of jobs still running
+	// when the service stops, as reported by context.Cause.
+	ErrJobsStopping = errors.New("server is shutting down")
+)
+
+// JobFunc performs a job. It reports progress through run, stops when ctx
+// is cancelled, and returns a JSON-encodable result.
+type JobFunc func(ctx context.Context, run *JobRun) (interface{}, error)
+
+// JobService runs long operations as jobs: each is recorded in the jobs
+// table with its progress and result, can be cancelled, and can be watched
+// while it runs. Only one job of each type runs at a time.
+type JobService struct {
+	db      *db.DB
+	mu      sync.Mutex
+	running map[int64]*JobRun
+	wg      sync.WaitGroup
+}
+
+// NewJobService creates a new job service.
+func NewJobService(database *db.DB) *JobService {
+	return &JobService{
+		db:      database,
+		running: make(map[int64]*JobRun),
+	}
+}
+
+// Start marks jobs left running by a previous process as failed.
+func (s *JobService) Start() {
+	if n, err := s.db.FailInterruptedJobs(context.Background()); err != nil {
+		slog.Error("Failed to mark interrupted jobs", "error", err)
+	} else if n > 0 {
+		slog.Warn("Marked interrupted jobs as failed", "count", n)
+	}
+}
+
+// Stop cancels running jobs and waits for background jobs to finish.
+func (s *JobService) Stop() {
+	s.mu.Lock()
+	for _, run := range s.running {
+		run.cancel(ErrJobsStopping)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Run runs fn in the background as a new job and returns the job.
+func (s *JobService) Run(jobType string, params interface{}, fn JobFunc) (*db.Job, error) {
+	run, err := s.Begin(context.Background(), jobType, params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		result, err := fn(run.ctx, run)
+		run.Finish(result, err)
+	}()
+
+	return run.Snapshot(), nil
+}
+
+// Begin records a new job that the caller runs itself, e.g. an export
+// streamed to the request that started it. The job's context is derived
+// from parent. The caller must call Finish.
+func (s *JobService) Begin(parent context.Context, jobType string, params interface{}) (*JobRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, run := range s.running {
+		if run.job.Type == jobType {
+			return nil, ErrJobAlreadyRunning
+		}
+	}
+
+	job, err := s.db.CreateJob(context.Background(), jobType, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	ctx, cancel := context.WithCancelCause(parent)
+	run := &JobRun{
+		svc:    s,
+		ctx:    ctx,
+		cancel: cancel,
+		job:    *job,
+		subs:   make(map[chan db.Job]struct{}),
+		done:   make(chan struct{}),
+	}
+	s.running[job.ID] = run
+
+	slog.Info("Job started", "job_id", job.ID, "type", jobType)
+	return run, nil
+}
+
+// Get returns a job with its live progress if it is running.
+func (s *JobService) Get(ctx context.Context, id int64) (*db.Job, error) {
+	if run := s.lookup(id); run != nil {
+		return run.Snapshot(), nil
+	}
+	return s.db.GetJob(ctx, id)
+}
+
+// List returns jobs matching the filter, with live progress for running jobs.
+func (s *JobService) List(ctx context.Context, filter db.JobFilter) ([]db.Job, int64, error) {
+	jobs, total, err := s.db.GetJobs(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range jobs {
+		if run := s.lookup(jobs[i].ID); run != nil {
+			jobs[i] = *run.Snapshot()
+		}
+	}
+	return jobs, total, nil
+}
+
+// Cancel asks a running job to stop. The job ends as cancelled once its
+// function returns.
+func (s *JobService) Cancel(id int64) error {
+	run := s.lookup(id)
+	if run == nil {
+		return ErrJobNotRunning
+	}
+	run.cancel(nil)
+	return nil
+}
+
+// Wait blocks until the job finishes or ctx is done, and returns the job.
+func (s *JobService) Wait(ctx context.Context, id int64) (*db.Job, error) {
+	if run := s.lookup(id); run != nil {
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return s.db.GetJob(ctx, id)
+}
+
+// Subscribe returns a channel that receives the job's state after each
+// update, ending with its final state before the channel is closed. It
+// returns false if the job is not running.
+func (s *JobService) Subscribe(id int64) (<-chan db.Job, func(), bool) {
+	run := s.lookup(id)
+	if run == nil {
+		return nil, nil, false
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.finished {
+		return nil, nil, false
+	}
+
+	ch := make(chan db.Job, 1)
+	ch <- run.job
+	run.subs[ch] = struct{}{}
+
+	unsubscribe := func() {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		if _, ok := run.subs[ch]; ok {
+			delete(run.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, true
+}
+
+func (s *JobService) lookup(id int64) *JobRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[id]
+}
+
+// JobRun is a running job.
+type JobRun struct {
+	svc       *JobService
+	ctx       context.Context
+	cancel    context.CancelCauseFunc
+	mu        sync.Mutex
+	job       db.Job
+	lastSaved time.Time
+	subs      map[chan db.Job]struct{}
+	finished  bool
+	done      chan struct{}
+}
+
+// ID returns the job's ID.
+func (r *JobRun) ID() int64 {
+	return r.job.ID
+}
+
+// Context returns the job's context, cancelled when the job is cancelled.
+func (r *JobRun) Context() context.Context {
+	return r.ctx
+}
+
+// Snapshot returns a copy of the job's current state.
+func (r *JobRun) Snapshot() *db.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := r.job
+	return &job
+}
+
+// Progress records how far the job has got. total is 0 if unknown.
+func (r *JobRun) Progress(current, total int64, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return
+	}
+
+	r.job.ProgressCurrent = current
+	r.job.ProgressTotal = total
+	r.job.Message = message
+	r.publish()
+
+	if time.Since(r.lastSaved) >= jobProgressSaveInterval {
+		r.lastSaved = time.Now()
+		if err := r.svc.db.UpdateJobProgress(context.Background(), r.job.ID, current, total, message); err != nil {
+			slog.Warn("Failed to save job progress", "job_id", r.job.ID, "error", err)
+		}
+	}
+}
+
+// Finish records the job's result. A job whose context was cancelled ends
+// as cancelled, otherwise a non-nil err fails it.
+func (r *JobRun) Finish(result interface{}, err error) {
+	r.mu.Lock()
+	if r.finished {
+		r.mu.Unlock()
+		return
+	}
+	r.finished = true
+
+	cancelled := r.ctx.Err() != nil
+	switch {
+	case cancelled:
+		r.job.Status = db.JobStatusCancelled
+	case err != nil:
+		r.job.Status = db.JobStatusFailed
+	default:
+		r.job.Status = db.JobStatusCompleted
+	}
+	if err != nil && !(cancelled && errors.Is(err, context.Canceled)) {
+		r.job.Error = err.Error()
+	}
+	if result != nil {
+		if data, mErr := json.Marshal(result); mErr == nil {
+			r.job.Result = data
+		}
+	}
+	now := time.Unix(time.Now().Unix(), 0)
+	r.job.CompletedAt = &now
+
+	if dbErr := r.svc.db.CompleteJob(context.Background(), &r.job); dbErr != nil {
+		slog.Error("Failed to save job result", "job_id", r.job.ID, "error", dbErr)
+	}
+
+	r.publish()
+	for ch := range r.subs {
+		close(ch)
+	}
+	r.subs = nil
+	job := r.job
+	r.mu.Unlock()
+
+	r.svc.mu.Lock()
+	delete(r.svc.running, job.ID)
+	r.svc.mu.Unlock()
+
+	r.cancel(nil)
+	close(r.done)
+
+	slog.Info("Job finished", "job_id", job.ID, "type", job.Type, "status", job.Status, "error", job.Error)
+}
+
+// publish sends the current state to subscribers, replacing any update
+// they have not read yet. The caller holds r.mu.
+func (r *JobRun) publish() {
+	for ch := range r.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- r.job
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func waitJob(t *testing.T, svc *JobService, id int64) *db.Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := svc.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	return job
+}
+
+func TestJobServiceRun(t *testing.T) {
+	database := setupTestDB(t)
+	svc := NewJobService(database)
+
+	job, err := svc.Run(JobTypeVacuum, map[string]int{"n": 1}, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		run.Progress(1, 2, "working")
+		return map[string]int{"space_reclaimed": 42}, nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if job.Status != db.JobStatusRunning {
+		t.Errorf("expected a running job, got %q", job.Status)
+	}
+
+	done := waitJob(t, svc, job.ID)
+	if done.Status != db.JobStatusCompleted || string(done.Result) != `{"space_reclaimed":42}` ||
+		done.ProgressCurrent != 1 || done.ProgressTotal != 2 || done.CompletedAt == nil {
+		t.Errorf("unexpected finished job: %+v", done)
+	}
+
+	failed, _ := svc.Run(JobTypeVacuum, nil, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return nil, errors.New("disk full")
+	})
+	if done := waitJob(t, svc, failed.ID); done.Status != db.JobStatusFailed || done.Error != "disk full" {
+		t.Errorf("expected a failed job, got %+v", done)
+	}
+}
+
+func TestJobServiceOnePerType(t *testing.T) {
+	database := setupTestDB(t)
+	svc := NewJobService(database)
+	defer svc.Stop()
+
+	release := make(chan struct{})
+	job, err := svc.Run(JobTypeCleanup, nil, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if _, err := svc.Run(JobTypeCleanup, nil, nil); !errors.Is(err, ErrJobAlreadyRunning) {
+		t.Errorf("expected ErrJobAlreadyRunning, got %v", err)
+	}
+
+	// Other types still run
+	other, err := svc.Run(JobTypeIntegrityCheck, nil, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("expected another type to start, got %v", err)
+	}
+	waitJob(t, svc, other.ID)
+
+	close(release)
+	waitJob(t, svc, job.ID)
+	if _, err := svc.Run(JobTypeCleanup, nil, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return nil, nil
+	}); err != nil {
+		t.Errorf("expected a new cleanup job once the first finished, got %v", err)
+	}
+}
+
+func TestJobServiceCancel(t *testing.T) {
+	database := setupTestDB(t)
+	svc := NewJobService(database)
+
+	started := make(chan struct{})
+	job, _ := svc.Run(JobTypeRetention, nil, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+
+	if err := svc.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	done := waitJob(t, svc, job.ID)
+	if done.Status != db.JobStatusCancelled || done.Error != "" {
+		t.Errorf("expected a cancelled job, got %+v", done)
+	}
+
+	if err := svc.Cancel(job.ID); !errors.Is(err, ErrJobNotRunning) {
+		t.Errorf("expected ErrJobNotRunning, got %v", err)
+	}
+}
+
+func TestJobServiceSubscribe(t *testing.T) {
+	database := setupTestDB(t)
+	svc := NewJobService(database)
+
+	run, err := svc.Begin(context.Background(), JobTypeExport, nil)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+
+	updates, unsubscribe, ok := svc.Subscribe(run.ID())
+	if !ok {
+		t.Fatal("expected to subscribe to a running job")
+	}
+	defer unsubscribe()
+
+	if first := <-updates; first.Status != db.JobStatusRunning {
+		t.Errorf("expected the current state first, got %+v", first)
+	}
+
+	run.Progress(10, 20, "")
+	if update := <-updates; update.ProgressCurrent != 10 {
+		t.Errorf("expected a progress update, got %+v", update)
+	}
+
+	run.Finish(nil, nil)
+	var last db.Job
+	for update := range updates {
+		last = update
+	}
+	if last.Status != db.JobStatusCompleted {
+		t.Errorf("expected the final state before the channel closed, got %+v", last)
+	}
+
+	if _, _, ok := svc.Subscribe(run.ID()); ok {
+		t.Error("expected no subscription to a finished job")
+	}
+}
+
+func TestJobServiceStartFailsInterruptedJobs(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	job, _ := database.CreateJob(ctx, JobTypeImport, nil)
+
+	NewJobService(database).Start()
+
+	got, _ := database.GetJob(ctx, job.ID)
+	if got.Status != db.JobStatusFailed {
+		t.Errorf("expected the interrupted job to be failed, got %+v", got)
+	}
+}
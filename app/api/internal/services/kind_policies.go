@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// maxEventKind is the largest event kind NIP-01 allows.
+const maxEventKind = 65535
+
+// ValidateKindPolicy checks that a policy names a known tier and that its
+// kinds are valid and not both allowed and denied.
+func ValidateKindPolicy(policy *db.KindPolicy, tiers []db.PricingTier) error {
+	known := policy.Tier == db.KindPolicyTierWhitelist
+	for _, t := range tiers {
+		if t.ID == policy.Tier {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown tier: %s", policy.Tier)
+	}
+
+	for _, kinds := range [][]int{policy.AllowKinds, policy.DenyKinds} {
+		for _, k := range kinds {
+			if k < 0 || k > maxEventKind {
+				return fmt.Errorf("invalid kind: %d", k)
+			}
+		}
+	}
+	for _, k := range policy.DenyKinds {
+		if slices.Contains(policy.AllowKinds, k) {
+			return fmt.Errorf("kind %d is both allowed and denied", k)
+		}
+	}
+	return nil
+}
+
+// KindPolicySet resolves members to their access tier and checks event
+// kinds against the tier's policy.
+type KindPolicySet struct {
+	policies map[string]db.KindPolicy // by tier
+	tiers    map[string]string        // member pubkey to tier
+	operator string
+}
+
+// LoadKindPolicySet reads the kind policies and the tier of every member.
+func LoadKindPolicySet(ctx context.Context, database *db.DB) (*KindPolicySet, error) {
+	policies, err := database.GetKindPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set := &KindPolicySet{
+		policies: make(map[string]db.KindPolicy, len(policies)),
+		tiers:    make(map[string]string),
+	}
+	for _, p := range policies {
+		set.policies[p.Tier] = p
+	}
+	if len(set.policies) == 0 {
+		return set, nil
+	}
+
+	set.operator, _ = database.GetAppState(ctx, "operator_pubkey")
+	if set.tiers, err = LoadMemberTiers(ctx, database); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// LoadMemberTiers maps each member to their access tier: the pricing tier of
+// paid members in good standing, otherwise the whitelist tier for
+// whitelisted members.
+func LoadMemberTiers(ctx context.Context, database *db.DB) (map[string]string, error) {
+	tiers := make(map[string]string)
+	members, err := database.GetWhitelistMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		tiers[m.Pubkey] = db.KindPolicyTierWhitelist
+	}
+	paid, err := database.GetPaidUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range paid {
+		if u.Status == "active" || u.Status == "grace" {
+			tiers[u.Pubkey] = u.Tier
+		}
+	}
+	return tiers, nil
+}
+
+// Empty reports whether there are no policies to enforce.
+func (s *KindPolicySet) Empty() bool {
+	return len(s.policies) == 0
+}
+
+// Permits reports whether pubkey may post events of kind, and the tier
+// whose policy decided it. Authors outside any tier with a policy, and the
+// operator, may post every kind.
+func (s *KindPolicySet) Permits(pubkey string, kind int) (bool, string) {
+	if pubkey == s.operator {
+		return true, ""
+	}
+	tier, ok := s.tiers[pubkey]
+	if !ok {
+		return true, ""
+	}
+	policy, ok := s.policies[tier]
+	if !ok {
+		return true, tier
+	}
+	return policy.Permits(kind), tier
+}
+
+// KindPolicyService enforces the per-tier kind policies on events as they
+// reach the relay database. nostr-rs-relay only has a global kind
+// allowlist, so events of kinds a member's tier may not post are queued for
+// deletion once stored. Events stored before a policy is first saved are
+// left alone.
+type KindPolicyService struct {
+	db       *db.DB
+	interval time.Duration
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+	scanMu   sync.Mutex
+}
+
+// KindPolicyScan summarizes one pass over new events.
+type KindPolicyScan struct {
+	Scanned int `json:"scanned"`
+	Deleted int `json:"deleted"`
+}
+
+// NewKindPolicyService creates a new kind policy service.
+func NewKindPolicyService(database *db.DB) *KindPolicyService {
+	return &KindPolicyService{
+		db:       database,
+		interval: 30 * time.Second,
+		wakeCh:   make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins enforcing the kind policies in the background.
+func (s *KindPolicyService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the kind policy worker.
+func (s *KindPolicyService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to scan now, e.g. after the policies change.
+func (s *KindPolicyService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *KindPolicyService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		case <-s.wakeCh:
+		}
+		if _, err := s.Scan(context.Background()); err != nil && !errors.Is(err, db.ErrUnsupportedOnPostgres) {
+			slog.Error("Failed to enforce kind policies", "error", err)
+		}
+	}
+}
+
+// Scan checks events stored since the last scan against the kind policies.
+// While there are no policies it only keeps the position current.
+func (s *KindPolicyService) Scan(ctx context.Context) (*KindPolicyScan, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	result := &KindPolicyScan{}
+	if !s.db.IsRelayDBConnected() {
+		return result, nil
+	}
+
+	set, err := LoadKindPolicySet(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := s.db.GetKindPolicyCursor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if set.Empty() || cursor == 0 {
+		latest, err := s.db.GetLatestEventRowID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if latest == cursor {
+			return result, nil
+		}
+		return result, s.db.SetKindPolicyCursor(ctx, latest)
+	}
+
+	last := cursor
+	err = s.db.StreamEventsAfter(ctx, cursor, nil, func(rowID int64, event db.ExportEvent) error {
+		last = rowID
+		result.Scanned++
+
+		ok, tier := set.Permits(event.Pubkey, event.Kind)
+		if ok {
+			return nil
+		}
+		reason := fmt.Sprintf("Kind %d is not allowed for the %s tier", event.Kind, tier)
+		if err := QueueAuthorDeletion(ctx, s.db, event.ID, event.Pubkey, reason); err != nil {
+			return err
+		}
+		result.Deleted++
+		return nil
+	})
+
+	if last > cursor {
+		if err := s.db.SetKindPolicyCursor(ctx, last); err != nil {
+			return result, err
+		}
+	}
+	if result.Deleted > 0 {
+		slog.Info("Enforced kind policies", "scanned", result.Scanned, "deleted", result.Deleted)
+	}
+	return result, err
+}
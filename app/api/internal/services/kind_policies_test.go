@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func insertKindTestEvent(t *testing.T, relayDB *sql.DB, n byte, author string, kind int) string {
+	t.Helper()
+	id := strings.Repeat(hex.EncodeToString([]byte{n}), 32)
+	raw, _ := json.Marshal(map[string]interface{}{
+		"id": id, "pubkey": author, "created_at": 1700000000 + int64(n), "kind": kind,
+		"tags": [][]string{}, "content": "", "sig": "",
+	})
+	hash, _ := hex.DecodeString(id)
+	authorBytes, _ := hex.DecodeString(author)
+	if _, err := relayDB.Exec(`INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content) VALUES (?, ?, ?, ?, ?, 0, ?)`,
+		hash, time.Now().Unix(), 1700000000+int64(n), authorBytes, kind, string(raw)); err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+	return id
+}
+
+func TestValidateKindPolicy(t *testing.T) {
+	tiers := []db.PricingTier{{ID: "monthly"}}
+
+	tests := []struct {
+		name    string
+		policy  db.KindPolicy
+		wantErr bool
+	}{
+		{"whitelist tier", db.KindPolicy{Tier: db.KindPolicyTierWhitelist, AllowKinds: []int{0, 1, 3, 7}}, false},
+		{"pricing tier", db.KindPolicy{Tier: "monthly", DenyKinds: []int{4}}, false},
+		{"unknown tier", db.KindPolicy{Tier: "gold"}, true},
+		{"negative kind", db.KindPolicy{Tier: "monthly", AllowKinds: []int{-1}}, true},
+		{"kind too large", db.KindPolicy{Tier: "monthly", DenyKinds: []int{70000}}, true},
+		{"allowed and denied", db.KindPolicy{Tier: "monthly", AllowKinds: []int{1, 4}, DenyKinds: []int{4}}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateKindPolicy(&tt.policy, tiers); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKindPolicyService_Scan(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	free := strings.Repeat("aa", 32)
+	paid := strings.Repeat("bb", 32)
+	stranger := strings.Repeat("cc", 32)
+	operator := strings.Repeat("dd", 32)
+
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: free})
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: paid})
+	database.AddPaidUser(ctx, db.PaidUser{Pubkey: paid, Tier: "monthly", Status: "active"})
+	database.SetAppState(ctx, "operator_pubkey", operator)
+
+	// Events stored before any policy exists are left alone
+	insertKindTestEvent(t, relayDB, 1, free, 30023)
+
+	svc := NewKindPolicyService(database)
+	if result, err := svc.Scan(ctx); err != nil || result.Scanned != 0 {
+		t.Fatalf("expected a scan without policies to only record the cursor, got %+v, %v", result, err)
+	}
+
+	database.SetKindPolicies(ctx, []db.KindPolicy{
+		{Tier: db.KindPolicyTierWhitelist, AllowKinds: []int{0, 1, 3, 7}},
+		{Tier: "monthly", DenyKinds: []int{4}},
+	})
+
+	insertKindTestEvent(t, relayDB, 2, free, 1)
+	longForm := insertKindTestEvent(t, relayDB, 3, free, 30023)
+	insertKindTestEvent(t, relayDB, 4, paid, 30023)
+	dm := insertKindTestEvent(t, relayDB, 5, paid, 4)
+	insertKindTestEvent(t, relayDB, 6, stranger, 4)
+	insertKindTestEvent(t, relayDB, 7, operator, 30023)
+
+	result, err := svc.Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.Scanned != 6 || result.Deleted != 2 {
+		t.Fatalf("unexpected scan result: %+v", result)
+	}
+
+	requests, err := database.GetDeletionRequests(ctx, "pending")
+	if err != nil {
+		t.Fatalf("GetDeletionRequests failed: %v", err)
+	}
+	queued := make(map[string]db.DeletionRequest)
+	for _, req := range requests {
+		for _, id := range req.TargetEventIDs {
+			queued[id] = req
+		}
+	}
+	if len(queued) != 2 {
+		t.Fatalf("expected 2 events queued for deletion, got %d", len(queued))
+	}
+	if req, ok := queued[longForm]; !ok || req.AuthorPubkey != free || !strings.Contains(req.Reason, "whitelist") {
+		t.Errorf("expected long-form post by free member to be deleted, got %+v", req)
+	}
+	if req, ok := queued[dm]; !ok || !strings.Contains(req.Reason, "monthly") {
+		t.Errorf("expected DM by paid member to be deleted, got %+v", req)
+	}
+
+	// Nothing new means nothing to do
+	if result, err := svc.Scan(ctx); err != nil || result.Scanned != 0 {
+		t.Errorf("expected an empty rescan, got %+v, %v", result, err)
+	}
+}
@@ -1,7 +1,6 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/base64"
@@ -10,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
@@ -19,19 +19,52 @@ import (
 
 // Lightning errors
 var (
-	ErrLNDNotConfigured    = errors.New("LND is not configured")
-	ErrLNDConnectionFailed = errors.New("failed to connect to LND")
-	ErrLNDAuthFailed       = errors.New("LND authentication failed")
-	ErrLNDNotSynced        = errors.New("LND node is not synced to chain")
+	ErrLNDNotConfigured     = errors.New("LND is not configured")
+	ErrLNDConnectionFailed  = errors.New("failed to connect to LND")
+	ErrLNDAuthFailed        = errors.New("LND authentication failed")
+	ErrLNDNotSynced         = errors.New("LND node is not synced to chain")
+	ErrUnsupportedNodeType  = errors.New("unsupported Lightning node type")
+	ErrSubscribeUnsupported = errors.New("invoice subscription not supported for this node type")
+	ErrInvoiceNotFound      = errors.New("invoice not found")
+	ErrInvoiceNotOpen       = errors.New("invoice is no longer open")
 )
 
-// LNDConfig holds the configuration for connecting to an LND node.
+// Supported Lightning node types (matches lightning_config.node_type).
+const (
+	NodeTypeLND    = "lnd"
+	NodeTypeCLN    = "cln"
+	NodeTypeLNbits = "lnbits"
+	NodeTypeNWC    = "nwc"
+	NodeTypeMock   = "mock" // built-in test backend, no node required
+)
+
+// LNDConfig holds the configuration for connecting to a Lightning node.
+// Despite the name it is shared by all backends. MacaroonHex holds the
+// backend credential: the CLN rune, the LNbits API key or the NWC connection string.
 type LNDConfig struct {
-	Host        string `json:"host"`         // e.g., "umbrel.local:8080"
-	MacaroonHex string `json:"macaroon_hex"` // admin.macaroon as hex
+	NodeType    string `json:"node_type,omitempty"` // "lnd" (default), "cln", "lnbits" or "nwc"
+	Host        string `json:"host"`                // e.g., "umbrel.local:8080"
+	MacaroonHex string `json:"macaroon_hex"`        // admin.macaroon as hex, or backend credential
 	TLSCertPath string `json:"tls_cert_path,omitempty"`
 }
 
+// LightningBackend is implemented by each supported Lightning node type.
+type LightningBackend interface {
+	GetInfo(ctx context.Context) (*NodeInfo, error)
+	GetBalance(ctx context.Context) (*ChannelBalance, error)
+	CreateInvoice(ctx context.Context, amountSats int64, memo string, expirySecs int64) (*Invoice, error)
+	// CreateDescriptionInvoice creates an invoice that commits to the SHA-256
+	// hash of description instead of carrying it, as LNURL-pay requires.
+	CreateDescriptionInvoice(ctx context.Context, amountSats int64, description string, expirySecs int64) (*Invoice, error)
+	CheckInvoice(ctx context.Context, paymentHash string) (*Invoice, error)
+}
+
+// invoiceCanceller is implemented by backends that can cancel an unpaid
+// invoice, so it can no longer be paid.
+type invoiceCanceller interface {
+	CancelInvoice(ctx context.Context, paymentHash string) error
+}
+
 // NodeInfo contains information about the Lightning node.
 type NodeInfo struct {
 	Alias           string `json:"alias"`
@@ -62,12 +95,15 @@ type Invoice struct {
 	SettledAt      *time.Time `json:"settled_at,omitempty"`
 }
 
-// LightningService handles Lightning Network operations via LND.
+// LightningService handles Lightning Network operations via LND, CLN, LNbits or NWC.
 type LightningService struct {
-	db     *db.DB
-	mu     sync.RWMutex
-	client *http.Client
-	config *LNDConfig
+	db           *db.DB
+	mu           sync.RWMutex
+	client       *http.Client
+	streamClient *http.Client // no overall timeout, for long-lived subscriptions
+	config       *LNDConfig
+	forcedMock   bool              // set by UseMock; stored config is ignored
+	mockStore    *mockInvoiceStore // invoices of the mock backend
 }
 
 // NewLightningService creates a new Lightning service.
@@ -86,13 +122,39 @@ func NewLightningService(database *db.DB) *LightningService {
 			Transport: tr,
 			Timeout:   30 * time.Second,
 		},
+		streamClient: &http.Client{
+			Transport: tr,
+		},
+		mockStore: newMockInvoiceStore(),
 	}
 }
 
+// UseMock switches to the mock backend regardless of the stored config, for
+// development and CI. The options use the same format as a mock node's host.
+func (s *LightningService) UseMock(options string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forcedMock = true
+	s.config = &LNDConfig{
+		NodeType: NodeTypeMock,
+		Host:     ParseMockLightningOptions(options).Host(),
+	}
+}
+
+// IsForcedMock reports whether UseMock is in effect.
+func (s *LightningService) IsForcedMock() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.forcedMock
+}
+
 // Configure sets the LND configuration.
 func (s *LightningService) Configure(cfg *LNDConfig) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.forcedMock {
+		return
+	}
 	s.config = cfg
 }
 
@@ -105,6 +167,7 @@ func (s *LightningService) GetConfig() *LNDConfig {
 	}
 	// Return a copy
 	return &LNDConfig{
+		NodeType:    s.config.NodeType,
 		Host:        s.config.Host,
 		MacaroonHex: s.config.MacaroonHex,
 		TLSCertPath: s.config.TLSCertPath,
@@ -115,7 +178,8 @@ func (s *LightningService) GetConfig() *LNDConfig {
 func (s *LightningService) IsConfigured() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.config != nil && s.config.Host != "" && s.config.MacaroonHex != ""
+	return s.config != nil && s.config.Host != "" &&
+		(s.config.MacaroonHex != "" || s.config.NodeType == NodeTypeMock)
 }
 
 // LoadConfig loads the configuration from the database.
@@ -130,7 +194,11 @@ func (s *LightningService) LoadConfig(ctx context.Context) error {
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.forcedMock {
+		return nil
+	}
 	s.config = &LNDConfig{
+		NodeType:    cfg.NodeType,
 		Host:        cfg.Endpoint,
 		MacaroonHex: cfg.Macaroon,
 		TLSCertPath: cfg.Cert,
@@ -138,10 +206,25 @@ func (s *LightningService) LoadConfig(ctx context.Context) error {
 	return nil
 }
 
+// ClearConfig forgets the loaded configuration, so that the node credential
+// is no longer held in memory once the secrets are locked.
+func (s *LightningService) ClearConfig() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.forcedMock {
+		s.config = nil
+	}
+}
+
 // SaveConfig saves the configuration to the database.
 func (s *LightningService) SaveConfig(ctx context.Context, cfg *LNDConfig, enabled bool) error {
+	nodeType := cfg.NodeType
+	if nodeType == "" {
+		nodeType = NodeTypeLND
+	}
+
 	dbCfg := &db.LightningConfig{
-		NodeType: "lnd",
+		NodeType: nodeType,
 		Endpoint: cfg.Host,
 		Macaroon: cfg.MacaroonHex,
 		Cert:     cfg.TLSCertPath,
@@ -153,7 +236,9 @@ func (s *LightningService) SaveConfig(ctx context.Context, cfg *LNDConfig, enabl
 	}
 
 	s.mu.Lock()
-	s.config = cfg
+	if !s.forcedMock {
+		s.config = cfg
+	}
 	s.mu.Unlock()
 
 	return nil
@@ -162,12 +247,17 @@ func (s *LightningService) SaveConfig(ctx context.Context, cfg *LNDConfig, enabl
 // TestConnection tests the connection to LND with the provided config.
 // Returns node info if successful.
 func (s *LightningService) TestConnection(ctx context.Context, cfg *LNDConfig) (*NodeInfo, error) {
-	if cfg == nil || cfg.Host == "" || cfg.MacaroonHex == "" {
+	if cfg == nil || cfg.Host == "" || (cfg.MacaroonHex == "" && cfg.NodeType != NodeTypeMock) {
 		return nil, ErrLNDNotConfigured
 	}
 
 	// Use provided config for this test
-	info, err := s.getInfoWithConfig(ctx, cfg)
+	backend, err := s.backendFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := backend.GetInfo(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -175,230 +265,97 @@ func (s *LightningService) TestConnection(ctx context.Context, cfg *LNDConfig) (
 	return info, nil
 }
 
-// GetInfo returns information about the connected LND node.
+// GetInfo returns information about the connected Lightning node.
 func (s *LightningService) GetInfo(ctx context.Context) (*NodeInfo, error) {
-	cfg := s.GetConfig()
-	if cfg == nil {
-		return nil, ErrLNDNotConfigured
-	}
-	return s.getInfoWithConfig(ctx, cfg)
-}
-
-func (s *LightningService) getInfoWithConfig(ctx context.Context, cfg *LNDConfig) (*NodeInfo, error) {
-	resp, err := s.doRequest(ctx, cfg, "GET", "/v1/getinfo", nil)
+	backend, err := s.backend()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		return nil, ErrLNDAuthFailed
-	}
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("%w: %s", ErrLNDConnectionFailed, string(body))
-	}
-
-	var result struct {
-		Alias             string `json:"alias"`
-		IdentityPubkey    string `json:"identity_pubkey"`
-		Version           string `json:"version"`
-		SyncedToChain     bool   `json:"synced_to_chain"`
-		SyncedToGraph     bool   `json:"synced_to_graph"`
-		NumActiveChannels int    `json:"num_active_channels"`
-		NumPeers          int    `json:"num_peers"`
-		BlockHeight       int64  `json:"block_height"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode LND response: %w", err)
-	}
-
-	return &NodeInfo{
-		Alias:             result.Alias,
-		Pubkey:            result.IdentityPubkey,
-		Version:           result.Version,
-		SyncedToChain:     result.SyncedToChain,
-		SyncedToGraph:     result.SyncedToGraph,
-		NumActiveChannels: result.NumActiveChannels,
-		NumPeers:          result.NumPeers,
-		BlockHeight:       result.BlockHeight,
-	}, nil
+	return backend.GetInfo(ctx)
 }
 
-// GetBalance returns the channel balance of the LND node.
+// GetBalance returns the channel balance of the connected Lightning node.
 func (s *LightningService) GetBalance(ctx context.Context) (*ChannelBalance, error) {
-	cfg := s.GetConfig()
-	if cfg == nil {
-		return nil, ErrLNDNotConfigured
-	}
-
-	resp, err := s.doRequest(ctx, cfg, "GET", "/v1/balance/channels", nil)
+	backend, err := s.backend()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("%w: %s", ErrLNDConnectionFailed, string(body))
-	}
-
-	var result struct {
-		LocalBalance struct {
-			Sat  string `json:"sat"`
-			Msat string `json:"msat"`
-		} `json:"local_balance"`
-		RemoteBalance struct {
-			Sat  string `json:"sat"`
-			Msat string `json:"msat"`
-		} `json:"remote_balance"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode balance response: %w", err)
-	}
-
-	var localBal, remoteBal int64
-	fmt.Sscanf(result.LocalBalance.Sat, "%d", &localBal)
-	fmt.Sscanf(result.RemoteBalance.Sat, "%d", &remoteBal)
-
-	return &ChannelBalance{
-		LocalBalance:  localBal,
-		RemoteBalance: remoteBal,
-		TotalBalance:  localBal + remoteBal,
-	}, nil
+	return backend.GetBalance(ctx)
 }
 
 // CreateInvoice generates a Lightning invoice.
 func (s *LightningService) CreateInvoice(ctx context.Context, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
-	cfg := s.GetConfig()
-	if cfg == nil {
-		return nil, ErrLNDNotConfigured
+	backend, err := s.backend()
+	if err != nil {
+		return nil, err
 	}
 
 	if expirySecs <= 0 {
 		expirySecs = 900 // Default 15 minutes
 	}
 
-	reqBody := map[string]interface{}{
-		"value":  amountSats,
-		"memo":   memo,
-		"expiry": expirySecs,
-	}
+	return backend.CreateInvoice(ctx, amountSats, memo, expirySecs)
+}
 
-	resp, err := s.doRequest(ctx, cfg, "POST", "/v1/invoices", reqBody)
+// CreateDescriptionInvoice generates a Lightning invoice committing to the
+// hash of description.
+func (s *LightningService) CreateDescriptionInvoice(ctx context.Context, amountSats int64, description string, expirySecs int64) (*Invoice, error) {
+	backend, err := s.backend()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to create invoice: %s", string(body))
-	}
 
-	var result struct {
-		RHash          string `json:"r_hash"` // base64 encoded
-		PaymentRequest string `json:"payment_request"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode invoice response: %w", err)
-	}
-
-	// Convert r_hash from base64 to hex
-	rHashBytes, err := base64.StdEncoding.DecodeString(result.RHash)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode payment hash: %w", err)
+	if expirySecs <= 0 {
+		expirySecs = 900 // Default 15 minutes
 	}
-	paymentHash := hex.EncodeToString(rHashBytes)
 
-	return &Invoice{
-		PaymentRequest: result.PaymentRequest,
-		PaymentHash:    paymentHash,
-		AmountSats:     amountSats,
-		ExpiresAt:      time.Now().Add(time.Duration(expirySecs) * time.Second),
-		Memo:           memo,
-		Settled:        false,
-	}, nil
+	return backend.CreateDescriptionInvoice(ctx, amountSats, description, expirySecs)
 }
 
 // CheckInvoice checks the status of an invoice by payment hash.
 func (s *LightningService) CheckInvoice(ctx context.Context, paymentHash string) (*Invoice, error) {
-	cfg := s.GetConfig()
-	if cfg == nil {
-		return nil, ErrLNDNotConfigured
-	}
-
-	// LND expects the payment hash as URL-safe base64
-	hashBytes, err := hex.DecodeString(paymentHash)
-	if err != nil {
-		return nil, fmt.Errorf("invalid payment hash: %w", err)
-	}
-	hashB64 := base64.URLEncoding.EncodeToString(hashBytes)
-
-	resp, err := s.doRequest(ctx, cfg, "GET", "/v1/invoice/"+hashB64, nil)
+	backend, err := s.backend()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("invoice not found")
-	}
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to check invoice: %s", string(body))
-	}
-
-	var result struct {
-		Memo           string `json:"memo"`
-		Value          string `json:"value"`
-		Settled        bool   `json:"settled"`
-		SettleDate     string `json:"settle_date"`
-		PaymentRequest string `json:"payment_request"`
-		Expiry         string `json:"expiry"`
-		CreationDate   string `json:"creation_date"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode invoice response: %w", err)
-	}
-
-	var amountSats int64
-	fmt.Sscanf(result.Value, "%d", &amountSats)
-
-	var expirySecs, creationDate int64
-	fmt.Sscanf(result.Expiry, "%d", &expirySecs)
-	fmt.Sscanf(result.CreationDate, "%d", &creationDate)
+	return backend.CheckInvoice(ctx, paymentHash)
+}
 
-	invoice := &Invoice{
-		PaymentRequest: result.PaymentRequest,
-		PaymentHash:    paymentHash,
-		AmountSats:     amountSats,
-		ExpiresAt:      time.Unix(creationDate+expirySecs, 0),
-		Memo:           result.Memo,
-		Settled:        result.Settled,
+// backend returns the backend for the currently configured node.
+func (s *LightningService) backend() (LightningBackend, error) {
+	cfg := s.GetConfig()
+	if cfg == nil {
+		return nil, ErrLNDNotConfigured
 	}
+	return s.backendFor(cfg)
+}
 
-	if result.Settled && result.SettleDate != "" && result.SettleDate != "0" {
-		var settleTs int64
-		fmt.Sscanf(result.SettleDate, "%d", &settleTs)
-		if settleTs > 0 {
-			t := time.Unix(settleTs, 0)
-			invoice.SettledAt = &t
-		}
+// backendFor returns the backend matching cfg.NodeType.
+func (s *LightningService) backendFor(cfg *LNDConfig) (LightningBackend, error) {
+	switch cfg.NodeType {
+	case "", NodeTypeLND:
+		return &lndBackend{client: s.client, cfg: cfg}, nil
+	case NodeTypeCLN:
+		return &clnBackend{client: s.client, cfg: cfg}, nil
+	case NodeTypeLNbits:
+		return &lnbitsBackend{client: s.client, cfg: cfg}, nil
+	case NodeTypeNWC:
+		return &nwcBackend{cfg: cfg}, nil
+	case NodeTypeMock:
+		return &mockBackend{store: s.mockStore, opts: ParseMockLightningOptions(cfg.Host)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedNodeType, cfg.NodeType)
 	}
-
-	return invoice, nil
 }
 
 // AccessInvoiceRequest contains the parameters for creating an access invoice.
 type AccessInvoiceRequest struct {
-	Pubkey string // hex pubkey
-	Npub   string // bech32 npub
-	TierID string // pricing tier ID
+	Pubkey     string        // hex pubkey
+	Npub       string        // bech32 npub
+	TierID     string        // pricing tier ID
+	Expiry     time.Duration // invoice lifetime; 0 for 15 minutes
+	AmountSats int64         // discounted price; 0 for the tier price
+	Source     string        // where the invoice came from; empty for a signup
 }
 
 // AccessInvoice represents an invoice for relay access.
@@ -438,11 +395,19 @@ func (s *LightningService) CreateAccessInvoice(ctx context.Context, req AccessIn
 	}
 	memo := fmt.Sprintf("Roostr %s access for %s", tier.Name, shortPubkey)
 
-	// Create invoice via LND (15 minute expiry)
+	amountSats := tier.AmountSats
+	if req.AmountSats > 0 && req.AmountSats < amountSats {
+		amountSats = req.AmountSats
+	}
+
+	// Create invoice on the node (15 minute expiry unless requested)
 	expirySecs := int64(900)
-	invoice, err := s.CreateInvoice(ctx, tier.AmountSats, memo, expirySecs)
+	if req.Expiry > 0 {
+		expirySecs = int64(req.Expiry / time.Second)
+	}
+	invoice, err := s.CreateInvoice(ctx, amountSats, memo, expirySecs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create LND invoice: %w", err)
+		return nil, fmt.Errorf("failed to create Lightning invoice: %w", err)
 	}
 
 	// Store in database for tracking
@@ -451,9 +416,10 @@ func (s *LightningService) CreateAccessInvoice(ctx context.Context, req AccessIn
 		Pubkey:         req.Pubkey,
 		Npub:           req.Npub,
 		TierID:         req.TierID,
-		AmountSats:     tier.AmountSats,
+		AmountSats:     amountSats,
 		PaymentRequest: invoice.PaymentRequest,
 		Memo:           memo,
+		Source:         req.Source,
 		ExpiresAt:      invoice.ExpiresAt,
 	}
 
@@ -464,7 +430,7 @@ func (s *LightningService) CreateAccessInvoice(ctx context.Context, req AccessIn
 	return &AccessInvoice{
 		PaymentHash:    invoice.PaymentHash,
 		PaymentRequest: invoice.PaymentRequest,
-		AmountSats:     tier.AmountSats,
+		AmountSats:     amountSats,
 		TierID:         req.TierID,
 		TierName:       tier.Name,
 		ExpiresAt:      invoice.ExpiresAt.Unix(),
@@ -491,17 +457,140 @@ func (s *LightningService) GetPendingInvoice(ctx context.Context, paymentHash st
 	return s.db.GetPendingInvoice(ctx, paymentHash)
 }
 
+// openPendingInvoice returns a pending invoice that is unpaid and unexpired.
+func (s *LightningService) openPendingInvoice(ctx context.Context, paymentHash string) (*db.PendingInvoice, error) {
+	pending, err := s.db.GetPendingInvoice(ctx, paymentHash)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		return nil, ErrInvoiceNotFound
+	}
+	if pending.Status != "pending" || !pending.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvoiceNotOpen
+	}
+	return pending, nil
+}
+
+// ReissueAccessInvoice replaces an open access invoice with a new one for the
+// same pubkey, tier and price, lasting as long as the first did, and cancels
+// the old one. A coupon reserved for the old invoice moves to the new one.
+func (s *LightningService) ReissueAccessInvoice(ctx context.Context, paymentHash string) (*AccessInvoice, error) {
+	old, err := s.openPendingInvoice(ctx, paymentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice, err := s.CreateAccessInvoice(ctx, AccessInvoiceRequest{
+		Pubkey:     old.Pubkey,
+		Npub:       old.Npub,
+		TierID:     old.TierID,
+		Expiry:     old.ExpiresAt.Sub(old.CreatedAt),
+		AmountSats: old.AmountSats,
+		Source:     old.Source,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cancelPendingInvoice(ctx, paymentHash); err != nil {
+		return nil, err
+	}
+	if err := s.db.ReassignCouponReservation(ctx, paymentHash, invoice.PaymentHash, time.Unix(invoice.ExpiresAt, 0)); err != nil {
+		return nil, fmt.Errorf("failed to move coupon reservation: %w", err)
+	}
+	return invoice, nil
+}
+
+// CancelAccessInvoice cancels an open access invoice and releases any coupon
+// reserved for it.
+func (s *LightningService) CancelAccessInvoice(ctx context.Context, paymentHash string) error {
+	if _, err := s.openPendingInvoice(ctx, paymentHash); err != nil {
+		return err
+	}
+	if err := s.cancelPendingInvoice(ctx, paymentHash); err != nil {
+		return err
+	}
+	return s.db.ReleaseCouponReservation(ctx, paymentHash)
+}
+
+// cancelPendingInvoice marks an open invoice cancelled, so paying it no
+// longer grants access, and cancels it on the node where the backend
+// supports that, so it cannot be paid at all. It fails with
+// ErrInvoiceNotOpen if the invoice was paid in the meantime.
+func (s *LightningService) cancelPendingInvoice(ctx context.Context, paymentHash string) error {
+	cancelled, err := s.db.CancelPendingInvoice(ctx, paymentHash)
+	if err != nil {
+		return fmt.Errorf("failed to cancel invoice: %w", err)
+	}
+	if !cancelled {
+		return ErrInvoiceNotOpen
+	}
+
+	backend, err := s.backend()
+	if err != nil {
+		return nil
+	}
+	if canceller, ok := backend.(invoiceCanceller); ok {
+		if err := canceller.CancelInvoice(ctx, paymentHash); err != nil {
+			slog.WarnContext(ctx, "Failed to cancel invoice on the node", "payment_hash", paymentHash, "error", err)
+		}
+	}
+	return nil
+}
+
+// SettleMockInvoice settles an invoice on the mock backend immediately. It
+// reports false if the mock backend is not in use or the invoice cannot be settled.
+func (s *LightningService) SettleMockInvoice(paymentHash string) bool {
+	cfg := s.GetConfig()
+	if cfg == nil || cfg.NodeType != NodeTypeMock {
+		return false
+	}
+	return s.mockStore.settle(paymentHash)
+}
+
+// SetMockOptions changes the mock backend's options, persisting them unless
+// UseMock is in effect. It fails if the mock backend is not in use.
+func (s *LightningService) SetMockOptions(ctx context.Context, opts MockLightningOptions) error {
+	cfg := s.GetConfig()
+	if cfg == nil || cfg.NodeType != NodeTypeMock {
+		return ErrUnsupportedNodeType
+	}
+	cfg.Host = opts.Host()
+
+	if s.IsForcedMock() {
+		s.mu.Lock()
+		s.config = cfg
+		s.mu.Unlock()
+		return nil
+	}
+
+	dbCfg, err := s.db.GetLightningConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load lightning config: %w", err)
+	}
+	return s.SaveConfig(ctx, cfg, dbCfg != nil && dbCfg.Enabled)
+}
+
 // InvoiceCallback is called when an invoice update is received.
 type InvoiceCallback func(paymentHash string, settled bool)
 
 // SubscribeInvoices subscribes to LND invoice updates via the streaming REST API.
-// The callback is called for each invoice update. This method blocks until the
-// context is cancelled or an error occurs.
-func (s *LightningService) SubscribeInvoices(ctx context.Context, callback InvoiceCallback) error {
+// onConnected (optional) is called once the stream is established, and the
+// callback is called for each invoice update. This method blocks until the
+// context is cancelled or an error occurs. Only LND and the mock backend support
+// subscriptions; other node types return ErrSubscribeUnsupported and rely on polling.
+func (s *LightningService) SubscribeInvoices(ctx context.Context, onConnected func(), callback InvoiceCallback) error {
 	cfg := s.GetConfig()
 	if cfg == nil {
 		return ErrLNDNotConfigured
 	}
+	if cfg.NodeType == NodeTypeMock {
+		return s.mockStore.subscribe(ctx, onConnected, callback)
+	}
+	if cfg.NodeType != "" && cfg.NodeType != NodeTypeLND {
+		return ErrSubscribeUnsupported
+	}
 
 	url := fmt.Sprintf("https://%s/v1/invoices/subscribe", cfg.Host)
 
@@ -512,7 +601,14 @@ func (s *LightningService) SubscribeInvoices(ctx context.Context, callback Invoi
 
 	req.Header.Set("Grpc-Metadata-macaroon", cfg.MacaroonHex)
 
-	resp, err := s.client.Do(req)
+	// The regular client's 30s timeout would cut the stream, so use the
+	// stream client when available
+	client := s.streamClient
+	if client == nil {
+		client = s.client
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrLNDConnectionFailed, err)
 	}
@@ -526,6 +622,10 @@ func (s *LightningService) SubscribeInvoices(ctx context.Context, callback Invoi
 		return fmt.Errorf("%w: %s", ErrLNDConnectionFailed, string(body))
 	}
 
+	if onConnected != nil {
+		onConnected()
+	}
+
 	// LND streaming API returns newline-delimited JSON
 	decoder := json.NewDecoder(resp.Body)
 	for {
@@ -538,7 +638,8 @@ func (s *LightningService) SubscribeInvoices(ctx context.Context, callback Invoi
 		var update struct {
 			Result struct {
 				RHash   string `json:"r_hash"` // base64 encoded
-				Settled bool   `json:"settled"`
+				Settled bool   `json:"settled"` // deprecated in newer LND
+				State   string `json:"state"`
 			} `json:"result"`
 		}
 
@@ -559,38 +660,6 @@ func (s *LightningService) SubscribeInvoices(ctx context.Context, callback Invoi
 		}
 		paymentHash := hex.EncodeToString(rHashBytes)
 
-		callback(paymentHash, update.Result.Settled)
+		callback(paymentHash, update.Result.Settled || update.Result.State == "SETTLED")
 	}
 }
-
-// doRequest performs an HTTP request to the LND REST API.
-func (s *LightningService) doRequest(ctx context.Context, cfg *LNDConfig, method, path string, body interface{}) (*http.Response, error) {
-	url := fmt.Sprintf("https://%s%s", cfg.Host, path)
-
-	var reqBody io.Reader
-	if body != nil {
-		jsonBody, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
-		}
-		reqBody = bytes.NewReader(jsonBody)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set the macaroon header
-	req.Header.Set("Grpc-Metadata-macaroon", cfg.MacaroonHex)
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrLNDConnectionFailed, err)
-	}
-
-	return resp, nil
-}
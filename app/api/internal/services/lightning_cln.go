@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clnBackend implements LightningBackend against the Core Lightning REST
+// plugin (clnrest). Requests are authenticated with a rune.
+type clnBackend struct {
+	client *http.Client
+	cfg    *LNDConfig
+}
+
+// clnMsat decodes CLN millisatoshi amounts, which are plain integers on
+// current releases and "1000msat" strings on older ones.
+type clnMsat int64
+
+// UnmarshalJSON accepts both numeric and "<n>msat" string amounts.
+func (m *clnMsat) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	str = strings.TrimSuffix(str, "msat")
+	if str == "" || str == "null" {
+		*m = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid msat amount %q: %w", string(data), err)
+	}
+	*m = clnMsat(v)
+	return nil
+}
+
+// Sats returns the amount in whole satoshis.
+func (m clnMsat) Sats() int64 {
+	return int64(m) / 1000
+}
+
+// GetInfo returns information about the CLN node.
+func (b *clnBackend) GetInfo(ctx context.Context) (*NodeInfo, error) {
+	var result struct {
+		ID                    string `json:"id"`
+		Alias                 string `json:"alias"`
+		Version               string `json:"version"`
+		NumPeers              int    `json:"num_peers"`
+		NumActiveChannels     int    `json:"num_active_channels"`
+		BlockHeight           int64  `json:"blockheight"`
+		WarningBitcoindSync   string `json:"warning_bitcoind_sync"`
+		WarningLightningdSync string `json:"warning_lightningd_sync"`
+	}
+
+	if err := b.call(ctx, "getinfo", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &NodeInfo{
+		Alias:             result.Alias,
+		Pubkey:            result.ID,
+		Version:           result.Version,
+		SyncedToChain:     result.WarningBitcoindSync == "" && result.WarningLightningdSync == "",
+		SyncedToGraph:     result.WarningLightningdSync == "",
+		NumActiveChannels: result.NumActiveChannels,
+		NumPeers:          result.NumPeers,
+		BlockHeight:       result.BlockHeight,
+	}, nil
+}
+
+// GetBalance returns the channel balance of the CLN node.
+// Only channels in the CHANNELD_NORMAL state are counted.
+func (b *clnBackend) GetBalance(ctx context.Context) (*ChannelBalance, error) {
+	var result struct {
+		Channels []struct {
+			State         string  `json:"state"`
+			OurAmountMsat clnMsat `json:"our_amount_msat"`
+			AmountMsat    clnMsat `json:"amount_msat"`
+		} `json:"channels"`
+	}
+
+	if err := b.call(ctx, "listfunds", nil, &result); err != nil {
+		return nil, err
+	}
+
+	var localBal, remoteBal int64
+	for _, ch := range result.Channels {
+		if ch.State != "CHANNELD_NORMAL" {
+			continue
+		}
+		localBal += ch.OurAmountMsat.Sats()
+		remoteBal += (ch.AmountMsat - ch.OurAmountMsat).Sats()
+	}
+
+	return &ChannelBalance{
+		LocalBalance:  localBal,
+		RemoteBalance: remoteBal,
+		TotalBalance:  localBal + remoteBal,
+	}, nil
+}
+
+// CreateInvoice generates a Lightning invoice on the CLN node.
+// CLN requires a unique label per invoice, so one is derived from the current time.
+func (b *clnBackend) CreateInvoice(ctx context.Context, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
+	params := map[string]interface{}{
+		"amount_msat": amountSats * 1000,
+		"label":       fmt.Sprintf("roostr-%d", time.Now().UnixNano()),
+		"description": memo,
+		"expiry":      expirySecs,
+	}
+	return b.invoice(ctx, params, amountSats, memo)
+}
+
+// CreateDescriptionInvoice generates an invoice on the CLN node that carries
+// only the hash of description.
+func (b *clnBackend) CreateDescriptionInvoice(ctx context.Context, amountSats int64, description string, expirySecs int64) (*Invoice, error) {
+	params := map[string]interface{}{
+		"amount_msat":  amountSats * 1000,
+		"label":        fmt.Sprintf("roostr-%d", time.Now().UnixNano()),
+		"description":  description,
+		"deschashonly": true,
+		"expiry":       expirySecs,
+	}
+	return b.invoice(ctx, params, amountSats, description)
+}
+
+// invoice calls the CLN invoice method.
+func (b *clnBackend) invoice(ctx context.Context, params map[string]interface{}, amountSats int64, memo string) (*Invoice, error) {
+	var result struct {
+		PaymentHash string `json:"payment_hash"`
+		Bolt11      string `json:"bolt11"`
+		ExpiresAt   int64  `json:"expires_at"`
+	}
+
+	if err := b.call(ctx, "invoice", params, &result); err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	return &Invoice{
+		PaymentRequest: result.Bolt11,
+		PaymentHash:    result.PaymentHash,
+		AmountSats:     amountSats,
+		ExpiresAt:      time.Unix(result.ExpiresAt, 0),
+		Memo:           memo,
+		Settled:        false,
+	}, nil
+}
+
+// CheckInvoice checks the status of a CLN invoice by payment hash.
+func (b *clnBackend) CheckInvoice(ctx context.Context, paymentHash string) (*Invoice, error) {
+	params := map[string]interface{}{
+		"payment_hash": paymentHash,
+	}
+
+	var result struct {
+		Invoices []struct {
+			PaymentHash string  `json:"payment_hash"`
+			Bolt11      string  `json:"bolt11"`
+			Description string  `json:"description"`
+			AmountMsat  clnMsat `json:"amount_msat"`
+			Status      string  `json:"status"` // "unpaid", "paid" or "expired"
+			ExpiresAt   int64   `json:"expires_at"`
+			PaidAt      int64   `json:"paid_at"`
+		} `json:"invoices"`
+	}
+
+	if err := b.call(ctx, "listinvoices", params, &result); err != nil {
+		return nil, fmt.Errorf("failed to check invoice: %w", err)
+	}
+
+	if len(result.Invoices) == 0 {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	inv := result.Invoices[0]
+
+	invoice := &Invoice{
+		PaymentRequest: inv.Bolt11,
+		PaymentHash:    paymentHash,
+		AmountSats:     inv.AmountMsat.Sats(),
+		ExpiresAt:      time.Unix(inv.ExpiresAt, 0),
+		Memo:           inv.Description,
+		Settled:        inv.Status == "paid",
+	}
+
+	if invoice.Settled && inv.PaidAt > 0 {
+		t := time.Unix(inv.PaidAt, 0)
+		invoice.SettledAt = &t
+	}
+
+	return invoice, nil
+}
+
+// call invokes a CLN RPC method via clnrest and decodes the JSON result into out.
+func (b *clnBackend) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	url := fmt.Sprintf("https://%s/v1/%s", b.cfg.Host, method)
+
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	jsonBody, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Rune", b.cfg.MacaroonHex)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLNDConnectionFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return ErrLNDAuthFailed
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w: %s", ErrLNDConnectionFailed, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode CLN response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lnbitsBackend implements LightningBackend against an LNbits wallet.
+// Host is the LNbits base URL and MacaroonHex holds the wallet's invoice/read key.
+type lnbitsBackend struct {
+	client *http.Client
+	cfg    *LNDConfig
+}
+
+// lnbitsTime decodes LNbits timestamps, which are Unix seconds on older
+// releases and ISO-8601 strings on newer ones.
+type lnbitsTime struct {
+	time.Time
+}
+
+// UnmarshalJSON accepts numeric and string timestamps.
+func (t *lnbitsTime) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	if str == "" || str == "null" {
+		return nil
+	}
+	if secs, err := strconv.ParseFloat(str, 64); err == nil {
+		t.Time = time.Unix(int64(secs), 0)
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999", "2006-01-02 15:04:05.999999", "2006-01-02 15:04:05"} {
+		if parsed, err := time.Parse(layout, str); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid LNbits timestamp %q", str)
+}
+
+// GetInfo returns information about the LNbits wallet.
+// LNbits has no node-level info, so the wallet name is used as the alias.
+func (b *lnbitsBackend) GetInfo(ctx context.Context) (*NodeInfo, error) {
+	var result struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := b.do(ctx, "GET", "/api/v1/wallet", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &NodeInfo{
+		Alias:         result.Name,
+		Pubkey:        result.ID,
+		Version:       "LNbits",
+		SyncedToChain: true,
+		SyncedToGraph: true,
+	}, nil
+}
+
+// GetBalance returns the LNbits wallet balance as the local balance.
+func (b *lnbitsBackend) GetBalance(ctx context.Context) (*ChannelBalance, error) {
+	var result struct {
+		Balance int64 `json:"balance"` // msat
+	}
+	if err := b.do(ctx, "GET", "/api/v1/wallet", nil, &result); err != nil {
+		return nil, err
+	}
+
+	sats := result.Balance / 1000
+	return &ChannelBalance{
+		LocalBalance: sats,
+		TotalBalance: sats,
+	}, nil
+}
+
+// CreateInvoice generates an incoming invoice on the LNbits wallet.
+func (b *lnbitsBackend) CreateInvoice(ctx context.Context, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
+	reqBody := map[string]interface{}{
+		"out":    false,
+		"amount": amountSats,
+		"memo":   memo,
+		"expiry": expirySecs,
+	}
+	return b.createPayment(ctx, reqBody, amountSats, memo, expirySecs)
+}
+
+// CreateDescriptionInvoice generates an invoice on the LNbits wallet with a
+// description hash.
+func (b *lnbitsBackend) CreateDescriptionInvoice(ctx context.Context, amountSats int64, description string, expirySecs int64) (*Invoice, error) {
+	reqBody := map[string]interface{}{
+		"out":                  false,
+		"amount":               amountSats,
+		"unhashed_description": hex.EncodeToString([]byte(description)),
+		"expiry":               expirySecs,
+	}
+	return b.createPayment(ctx, reqBody, amountSats, description, expirySecs)
+}
+
+// createPayment posts an incoming payment request to LNbits.
+func (b *lnbitsBackend) createPayment(ctx context.Context, reqBody map[string]interface{}, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
+	var result struct {
+		PaymentHash    string `json:"payment_hash"`
+		PaymentRequest string `json:"payment_request"`
+		Bolt11         string `json:"bolt11"`
+	}
+	if err := b.do(ctx, "POST", "/api/v1/payments", reqBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	paymentRequest := result.PaymentRequest
+	if paymentRequest == "" {
+		paymentRequest = result.Bolt11
+	}
+
+	return &Invoice{
+		PaymentRequest: paymentRequest,
+		PaymentHash:    result.PaymentHash,
+		AmountSats:     amountSats,
+		ExpiresAt:      time.Now().Add(time.Duration(expirySecs) * time.Second),
+		Memo:           memo,
+		Settled:        false,
+	}, nil
+}
+
+// CheckInvoice checks the status of an LNbits payment by payment hash.
+func (b *lnbitsBackend) CheckInvoice(ctx context.Context, paymentHash string) (*Invoice, error) {
+	var result struct {
+		Paid    bool `json:"paid"`
+		Details *struct {
+			Bolt11 string     `json:"bolt11"`
+			Memo   string     `json:"memo"`
+			Amount int64      `json:"amount"` // msat
+			Time   lnbitsTime `json:"time"`
+			Expiry lnbitsTime `json:"expiry"`
+		} `json:"details"`
+	}
+	if err := b.do(ctx, "GET", "/api/v1/payments/"+paymentHash, nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to check invoice: %w", err)
+	}
+
+	invoice := &Invoice{
+		PaymentHash: paymentHash,
+		Settled:     result.Paid,
+	}
+	if result.Details != nil {
+		invoice.PaymentRequest = result.Details.Bolt11
+		invoice.Memo = result.Details.Memo
+		invoice.AmountSats = result.Details.Amount / 1000
+		invoice.ExpiresAt = result.Details.Expiry.Time
+	}
+
+	return invoice, nil
+}
+
+// baseURL returns the LNbits base URL, defaulting to https when no scheme is set.
+func (b *lnbitsBackend) baseURL() string {
+	host := strings.TrimSuffix(b.cfg.Host, "/")
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = "https://" + host
+	}
+	return host
+}
+
+// do performs an LNbits API request and decodes the JSON response into out.
+func (b *lnbitsBackend) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL()+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Api-Key", b.cfg.MacaroonHex)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLNDConnectionFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return ErrLNDAuthFailed
+	}
+	if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/api/v1/payments/") {
+		return fmt.Errorf("invoice not found")
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w: %s", ErrLNDConnectionFailed, string(respBody))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode LNbits response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// lndBackend implements LightningBackend against the LND REST API.
+type lndBackend struct {
+	client *http.Client
+	cfg    *LNDConfig
+}
+
+// GetInfo returns information about the LND node.
+func (b *lndBackend) GetInfo(ctx context.Context) (*NodeInfo, error) {
+	resp, err := b.doRequest(ctx, "GET", "/v1/getinfo", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrLNDAuthFailed
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%w: %s", ErrLNDConnectionFailed, string(body))
+	}
+
+	var result struct {
+		Alias             string `json:"alias"`
+		IdentityPubkey    string `json:"identity_pubkey"`
+		Version           string `json:"version"`
+		SyncedToChain     bool   `json:"synced_to_chain"`
+		SyncedToGraph     bool   `json:"synced_to_graph"`
+		NumActiveChannels int    `json:"num_active_channels"`
+		NumPeers          int    `json:"num_peers"`
+		BlockHeight       int64  `json:"block_height"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode LND response: %w", err)
+	}
+
+	return &NodeInfo{
+		Alias:             result.Alias,
+		Pubkey:            result.IdentityPubkey,
+		Version:           result.Version,
+		SyncedToChain:     result.SyncedToChain,
+		SyncedToGraph:     result.SyncedToGraph,
+		NumActiveChannels: result.NumActiveChannels,
+		NumPeers:          result.NumPeers,
+		BlockHeight:       result.BlockHeight,
+	}, nil
+}
+
+// GetBalance returns the channel balance of the LND node.
+func (b *lndBackend) GetBalance(ctx context.Context) (*ChannelBalance, error) {
+	resp, err := b.doRequest(ctx, "GET", "/v1/balance/channels", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%w: %s", ErrLNDConnectionFailed, string(body))
+	}
+
+	var result struct {
+		LocalBalance struct {
+			Sat  string `json:"sat"`
+			Msat string `json:"msat"`
+		} `json:"local_balance"`
+		RemoteBalance struct {
+			Sat  string `json:"sat"`
+			Msat string `json:"msat"`
+		} `json:"remote_balance"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode balance response: %w", err)
+	}
+
+	var localBal, remoteBal int64
+	fmt.Sscanf(result.LocalBalance.Sat, "%d", &localBal)
+	fmt.Sscanf(result.RemoteBalance.Sat, "%d", &remoteBal)
+
+	return &ChannelBalance{
+		LocalBalance:  localBal,
+		RemoteBalance: remoteBal,
+		TotalBalance:  localBal + remoteBal,
+	}, nil
+}
+
+// CreateInvoice generates a Lightning invoice on the LND node.
+func (b *lndBackend) CreateInvoice(ctx context.Context, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
+	reqBody := map[string]interface{}{
+		"value":  amountSats,
+		"memo":   memo,
+		"expiry": expirySecs,
+	}
+	return b.addInvoice(ctx, reqBody, amountSats, memo, expirySecs)
+}
+
+// CreateDescriptionInvoice generates an invoice on the LND node with a
+// description hash.
+func (b *lndBackend) CreateDescriptionInvoice(ctx context.Context, amountSats int64, description string, expirySecs int64) (*Invoice, error) {
+	hash := sha256.Sum256([]byte(description))
+	reqBody := map[string]interface{}{
+		"value":            amountSats,
+		"description_hash": base64.StdEncoding.EncodeToString(hash[:]),
+		"expiry":           expirySecs,
+	}
+	return b.addInvoice(ctx, reqBody, amountSats, description, expirySecs)
+}
+
+// addInvoice posts an invoice request to LND.
+func (b *lndBackend) addInvoice(ctx context.Context, reqBody map[string]interface{}, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
+	resp, err := b.doRequest(ctx, "POST", "/v1/invoices", reqBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to create invoice: %s", string(body))
+	}
+
+	var result struct {
+		RHash          string `json:"r_hash"` // base64 encoded
+		PaymentRequest string `json:"payment_request"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode invoice response: %w", err)
+	}
+
+	// Convert r_hash from base64 to hex
+	rHashBytes, err := base64.StdEncoding.DecodeString(result.RHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode payment hash: %w", err)
+	}
+	paymentHash := hex.EncodeToString(rHashBytes)
+
+	return &Invoice{
+		PaymentRequest: result.PaymentRequest,
+		PaymentHash:    paymentHash,
+		AmountSats:     amountSats,
+		ExpiresAt:      time.Now().Add(time.Duration(expirySecs) * time.Second),
+		Memo:           memo,
+		Settled:        false,
+	}, nil
+}
+
+// CheckInvoice checks the status of an LND invoice by payment hash.
+func (b *lndBackend) CheckInvoice(ctx context.Context, paymentHash string) (*Invoice, error) {
+	// LND expects the payment hash as URL-safe base64
+	hashBytes, err := hex.DecodeString(paymentHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment hash: %w", err)
+	}
+	hashB64 := base64.URLEncoding.EncodeToString(hashBytes)
+
+	resp, err := b.doRequest(ctx, "GET", "/v1/invoice/"+hashB64, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to check invoice: %s", string(body))
+	}
+
+	var result struct {
+		Memo           string `json:"memo"`
+		Value          string `json:"value"`
+		Settled        bool   `json:"settled"`
+		SettleDate     string `json:"settle_date"`
+		PaymentRequest string `json:"payment_request"`
+		Expiry         string `json:"expiry"`
+		CreationDate   string `json:"creation_date"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode invoice response: %w", err)
+	}
+
+	var amountSats int64
+	fmt.Sscanf(result.Value, "%d", &amountSats)
+
+	var expirySecs, creationDate int64
+	fmt.Sscanf(result.Expiry, "%d", &expirySecs)
+	fmt.Sscanf(result.CreationDate, "%d", &creationDate)
+
+	invoice := &Invoice{
+		PaymentRequest: result.PaymentRequest,
+		PaymentHash:    paymentHash,
+		AmountSats:     amountSats,
+		ExpiresAt:      time.Unix(creationDate+expirySecs, 0),
+		Memo:           result.Memo,
+		Settled:        result.Settled,
+	}
+
+	if result.Settled && result.SettleDate != "" && result.SettleDate != "0" {
+		var settleTs int64
+		fmt.Sscanf(result.SettleDate, "%d", &settleTs)
+		if settleTs > 0 {
+			t := time.Unix(settleTs, 0)
+			invoice.SettledAt = &t
+		}
+	}
+
+	return invoice, nil
+}
+
+// CancelInvoice cancels an unpaid LND invoice, so it can no longer be paid.
+func (b *lndBackend) CancelInvoice(ctx context.Context, paymentHash string) error {
+	hashBytes, err := hex.DecodeString(paymentHash)
+	if err != nil {
+		return fmt.Errorf("invalid payment hash: %w", err)
+	}
+
+	resp, err := b.doRequest(ctx, "POST", "/v2/invoices/cancel", map[string]interface{}{
+		"payment_hash": base64.StdEncoding.EncodeToString(hashBytes),
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to cancel invoice: %s", string(body))
+	}
+	return nil
+}
+
+// doRequest performs an HTTP request to the LND REST API.
+func (b *lndBackend) doRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	url := fmt.Sprintf("https://%s%s", b.cfg.Host, path)
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set the macaroon header
+	req.Header.Set("Grpc-Metadata-macaroon", b.cfg.MacaroonHex)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLNDConnectionFailed, err)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrMockFailure is returned by the mock Lightning backend when a failure is toggled on.
+var ErrMockFailure = errors.New("mock Lightning failure")
+
+// defaultMockSettleAfter is how long mock invoices take to settle unless configured.
+const defaultMockSettleAfter = 5
+
+// MockLightningOptions control the mock Lightning backend. The options are
+// stored in LNDConfig.Host as a query string, e.g. "settle_after=5&fail_create=true".
+type MockLightningOptions struct {
+	SettleAfterSecs int  `json:"settle_after_secs"` // negative means settle manually only
+	FailCreate      bool `json:"fail_create"`       // CreateInvoice returns ErrMockFailure
+	FailCheck       bool `json:"fail_check"`        // CheckInvoice returns ErrMockFailure
+	Offline         bool `json:"offline"`           // GetInfo and GetBalance fail to connect
+}
+
+// ParseMockLightningOptions parses mock options from a config host string.
+// Unknown or invalid values fall back to the defaults.
+func ParseMockLightningOptions(host string) MockLightningOptions {
+	opts := MockLightningOptions{SettleAfterSecs: defaultMockSettleAfter}
+	if host == "" || host == "true" {
+		return opts
+	}
+
+	values, err := url.ParseQuery(host)
+	if err != nil {
+		return opts
+	}
+	if v, err := strconv.Atoi(values.Get("settle_after")); err == nil {
+		opts.SettleAfterSecs = v
+	}
+	opts.FailCreate, _ = strconv.ParseBool(values.Get("fail_create"))
+	opts.FailCheck, _ = strconv.ParseBool(values.Get("fail_check"))
+	opts.Offline, _ = strconv.ParseBool(values.Get("offline"))
+	return opts
+}
+
+// Host encodes the options for storage in LNDConfig.Host.
+func (o MockLightningOptions) Host() string {
+	values := url.Values{}
+	values.Set("settle_after", strconv.Itoa(o.SettleAfterSecs))
+	if o.FailCreate {
+		values.Set("fail_create", "true")
+	}
+	if o.FailCheck {
+		values.Set("fail_check", "true")
+	}
+	if o.Offline {
+		values.Set("offline", "true")
+	}
+	return values.Encode()
+}
+
+// mockInvoiceStore holds mock invoices across backend instances so that
+// settlement survives config changes, and fans settlements out to subscribers.
+type mockInvoiceStore struct {
+	mu          sync.Mutex
+	invoices    map[string]*Invoice
+	subscribers map[chan string]struct{}
+}
+
+func newMockInvoiceStore() *mockInvoiceStore {
+	return &mockInvoiceStore{
+		invoices:    make(map[string]*Invoice),
+		subscribers: make(map[chan string]struct{}),
+	}
+}
+
+// settle marks an invoice paid and notifies subscribers. It reports false if
+// the invoice is unknown, already settled or expired.
+func (s *mockInvoiceStore) settle(paymentHash string) bool {
+	s.mu.Lock()
+	inv, ok := s.invoices[paymentHash]
+	if !ok || inv.Settled || time.Now().After(inv.ExpiresAt) {
+		s.mu.Unlock()
+		return false
+	}
+	now := time.Now()
+	inv.Settled = true
+	inv.SettledAt = &now
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- paymentHash:
+		default:
+			// Slow subscriber; the invoice monitor's poller catches up
+		}
+	}
+	s.mu.Unlock()
+	return true
+}
+
+// cancel removes an unsettled invoice, so it can no longer be paid. It
+// reports false if the invoice is unknown or already settled.
+func (s *mockInvoiceStore) cancel(paymentHash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv, ok := s.invoices[paymentHash]
+	if !ok || inv.Settled {
+		return false
+	}
+	delete(s.invoices, paymentHash)
+	return true
+}
+
+// subscribe streams settled payment hashes to callback until ctx is done.
+func (s *mockInvoiceStore) subscribe(ctx context.Context, onConnected func(), callback InvoiceCallback) error {
+	ch := make(chan string, 16)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}()
+
+	if onConnected != nil {
+		onConnected()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case paymentHash := <-ch:
+			callback(paymentHash, true)
+		}
+	}
+}
+
+// mockBackend implements LightningBackend without a node. Invoices settle on
+// their own after SettleAfterSecs, or through LightningService.SettleMockInvoice.
+type mockBackend struct {
+	store *mockInvoiceStore
+	opts  MockLightningOptions
+}
+
+// GetInfo returns a fixed regtest node identity.
+func (b *mockBackend) GetInfo(ctx context.Context) (*NodeInfo, error) {
+	if b.opts.Offline {
+		return nil, fmt.Errorf("%w: %v", ErrLNDConnectionFailed, ErrMockFailure)
+	}
+	return &NodeInfo{
+		Alias:             "roostr-mock",
+		Pubkey:            "02" + hex.EncodeToString(make([]byte, 32)),
+		Version:           "mock",
+		SyncedToChain:     true,
+		SyncedToGraph:     true,
+		NumActiveChannels: 1,
+		NumPeers:          1,
+	}, nil
+}
+
+// GetBalance returns the total of settled mock invoices as the local balance.
+func (b *mockBackend) GetBalance(ctx context.Context) (*ChannelBalance, error) {
+	if b.opts.Offline {
+		return nil, fmt.Errorf("%w: %v", ErrLNDConnectionFailed, ErrMockFailure)
+	}
+
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	var local int64
+	for _, inv := range b.store.invoices {
+		if inv.Settled {
+			local += inv.AmountSats
+		}
+	}
+	return &ChannelBalance{LocalBalance: local, TotalBalance: local}, nil
+}
+
+// CreateInvoice creates a mock invoice and schedules its settlement.
+func (b *mockBackend) CreateInvoice(ctx context.Context, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
+	if b.opts.FailCreate {
+		return nil, ErrMockFailure
+	}
+
+	preimage := make([]byte, 32)
+	if _, err := rand.Read(preimage); err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(preimage)
+	paymentHash := hex.EncodeToString(hash[:])
+
+	inv := &Invoice{
+		// Regtest-style prefix so it is never mistaken for a payable invoice
+		PaymentRequest: fmt.Sprintf("lnbcrt%dmock1%s", amountSats, paymentHash),
+		PaymentHash:    paymentHash,
+		AmountSats:     amountSats,
+		ExpiresAt:      time.Now().Add(time.Duration(expirySecs) * time.Second),
+		Memo:           memo,
+	}
+
+	copied := *inv
+
+	b.store.mu.Lock()
+	b.store.invoices[paymentHash] = inv
+	b.store.mu.Unlock()
+
+	if b.opts.SettleAfterSecs >= 0 {
+		time.AfterFunc(time.Duration(b.opts.SettleAfterSecs)*time.Second, func() {
+			b.store.settle(paymentHash)
+		})
+	}
+
+	return &copied, nil
+}
+
+// CreateDescriptionInvoice creates a mock invoice. Mock invoices are not
+// real BOLT 11 strings, so the description is kept as the memo.
+func (b *mockBackend) CreateDescriptionInvoice(ctx context.Context, amountSats int64, description string, expirySecs int64) (*Invoice, error) {
+	return b.CreateInvoice(ctx, amountSats, description, expirySecs)
+}
+
+// CancelInvoice cancels an unsettled mock invoice.
+func (b *mockBackend) CancelInvoice(ctx context.Context, paymentHash string) error {
+	if !b.store.cancel(paymentHash) {
+		return fmt.Errorf("invoice not open: %s", paymentHash)
+	}
+	return nil
+}
+
+// CheckInvoice returns the current state of a mock invoice.
+func (b *mockBackend) CheckInvoice(ctx context.Context, paymentHash string) (*Invoice, error) {
+	if b.opts.FailCheck {
+		return nil, ErrMockFailure
+	}
+
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	inv, ok := b.store.invoices[paymentHash]
+	if !ok {
+		return nil, fmt.Errorf("invoice not found: %s", paymentHash)
+	}
+	copied := *inv
+	return &copied, nil
+}
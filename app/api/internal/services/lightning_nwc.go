@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// NIP-47 event kinds
+const (
+	nwcKindRequest  = 23194
+	nwcKindResponse = 23195
+)
+
+// nwcTimeout bounds a single NWC request/response round trip.
+const nwcTimeout = 30 * time.Second
+
+// ErrInvalidNWCURI is returned for malformed Nostr Wallet Connect strings.
+var ErrInvalidNWCURI = errors.New("invalid Nostr Wallet Connect URI")
+
+// NWCConnection holds the parts of a nostr+walletconnect:// connection string.
+type NWCConnection struct {
+	WalletPubkey string
+	Relay        string
+	Secret       string
+}
+
+// ParseNWCURI parses a NIP-47 connection string of the form
+// nostr+walletconnect://<wallet pubkey>?relay=<wss url>&secret=<hex>.
+func ParseNWCURI(uri string) (*NWCConnection, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNWCURI, err)
+	}
+	if u.Scheme != "nostr+walletconnect" && u.Scheme != "nostrwalletconnect" {
+		return nil, fmt.Errorf("%w: unexpected scheme %q", ErrInvalidNWCURI, u.Scheme)
+	}
+
+	// Accept both "scheme://pubkey?..." and "scheme:pubkey?..."
+	pubkey := u.Host
+	if pubkey == "" {
+		pubkey = u.Opaque
+	}
+	pubkey = strings.ToLower(pubkey)
+	if !nostr.IsValidHexPubkey(pubkey) {
+		return nil, fmt.Errorf("%w: invalid wallet pubkey", ErrInvalidNWCURI)
+	}
+
+	query := u.Query()
+	relay := query.Get("relay")
+	if !strings.HasPrefix(relay, "wss://") && !strings.HasPrefix(relay, "ws://") {
+		return nil, fmt.Errorf("%w: missing or invalid relay", ErrInvalidNWCURI)
+	}
+
+	secret := strings.ToLower(query.Get("secret"))
+	if _, err := nostr.GetPublicKey(secret); err != nil {
+		return nil, fmt.Errorf("%w: invalid secret", ErrInvalidNWCURI)
+	}
+
+	return &NWCConnection{
+		WalletPubkey: pubkey,
+		Relay:        relay,
+		Secret:       secret,
+	}, nil
+}
+
+// nwcBackend implements LightningBackend over Nostr Wallet Connect (NIP-47).
+// MacaroonHex holds the full connection string; Host mirrors its relay.
+type nwcBackend struct {
+	cfg *LNDConfig
+}
+
+// nwcResponse is the decrypted content of a NIP-47 response event.
+type nwcResponse struct {
+	ResultType string          `json:"result_type"`
+	Error      *nwcError       `json:"error"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// nwcError is a NIP-47 error object.
+type nwcError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// nwcTransaction is the NIP-47 invoice/transaction object.
+type nwcTransaction struct {
+	Invoice     string `json:"invoice"`
+	Description string `json:"description"`
+	PaymentHash string `json:"payment_hash"`
+	Amount      int64  `json:"amount"` // msat
+	CreatedAt   int64  `json:"created_at"`
+	ExpiresAt   int64  `json:"expires_at"`
+	SettledAt   int64  `json:"settled_at"`
+	State       string `json:"state"`
+}
+
+// GetInfo returns information about the connected wallet.
+func (b *nwcBackend) GetInfo(ctx context.Context) (*NodeInfo, error) {
+	var result struct {
+		Alias       string `json:"alias"`
+		Pubkey      string `json:"pubkey"`
+		Network     string `json:"network"`
+		BlockHeight int64  `json:"block_height"`
+	}
+	if err := b.call(ctx, "get_info", map[string]interface{}{}, &result); err != nil {
+		return nil, err
+	}
+
+	return &NodeInfo{
+		Alias:         result.Alias,
+		Pubkey:        result.Pubkey,
+		Version:       "NWC",
+		SyncedToChain: true,
+		SyncedToGraph: true,
+		BlockHeight:   result.BlockHeight,
+	}, nil
+}
+
+// GetBalance returns the wallet balance as the local balance.
+func (b *nwcBackend) GetBalance(ctx context.Context) (*ChannelBalance, error) {
+	var result struct {
+		Balance int64 `json:"balance"` // msat
+	}
+	if err := b.call(ctx, "get_balance", map[string]interface{}{}, &result); err != nil {
+		return nil, err
+	}
+
+	sats := result.Balance / 1000
+	return &ChannelBalance{
+		LocalBalance: sats,
+		TotalBalance: sats,
+	}, nil
+}
+
+// CreateInvoice asks the wallet to create an invoice via make_invoice.
+func (b *nwcBackend) CreateInvoice(ctx context.Context, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
+	params := map[string]interface{}{
+		"amount":      amountSats * 1000,
+		"description": memo,
+		"expiry":      expirySecs,
+	}
+	return b.makeInvoice(ctx, params, amountSats, memo, expirySecs)
+}
+
+// CreateDescriptionInvoice asks the wallet for an invoice with a description
+// hash via make_invoice.
+func (b *nwcBackend) CreateDescriptionInvoice(ctx context.Context, amountSats int64, description string, expirySecs int64) (*Invoice, error) {
+	hash := sha256.Sum256([]byte(description))
+	params := map[string]interface{}{
+		"amount":           amountSats * 1000,
+		"description_hash": hex.EncodeToString(hash[:]),
+		"expiry":           expirySecs,
+	}
+	return b.makeInvoice(ctx, params, amountSats, description, expirySecs)
+}
+
+// makeInvoice calls make_invoice on the wallet.
+func (b *nwcBackend) makeInvoice(ctx context.Context, params map[string]interface{}, amountSats int64, memo string, expirySecs int64) (*Invoice, error) {
+	var tx nwcTransaction
+	if err := b.call(ctx, "make_invoice", params, &tx); err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	invoice := tx.toInvoice(tx.PaymentHash)
+	invoice.AmountSats = amountSats
+	invoice.Memo = memo
+	if tx.ExpiresAt == 0 {
+		invoice.ExpiresAt = time.Now().Add(time.Duration(expirySecs) * time.Second)
+	}
+	return invoice, nil
+}
+
+// CheckInvoice looks up an invoice via lookup_invoice.
+func (b *nwcBackend) CheckInvoice(ctx context.Context, paymentHash string) (*Invoice, error) {
+	params := map[string]interface{}{
+		"payment_hash": paymentHash,
+	}
+
+	var tx nwcTransaction
+	if err := b.call(ctx, "lookup_invoice", params, &tx); err != nil {
+		return nil, fmt.Errorf("failed to check invoice: %w", err)
+	}
+
+	return tx.toInvoice(paymentHash), nil
+}
+
+// toInvoice converts a NIP-47 transaction into an Invoice.
+func (tx *nwcTransaction) toInvoice(paymentHash string) *Invoice {
+	invoice := &Invoice{
+		PaymentRequest: tx.Invoice,
+		PaymentHash:    paymentHash,
+		AmountSats:     tx.Amount / 1000,
+		Memo:           tx.Description,
+		Settled:        tx.SettledAt > 0 || tx.State == "settled",
+	}
+	if tx.ExpiresAt > 0 {
+		invoice.ExpiresAt = time.Unix(tx.ExpiresAt, 0)
+	}
+	if tx.SettledAt > 0 {
+		t := time.Unix(tx.SettledAt, 0)
+		invoice.SettledAt = &t
+	}
+	return invoice
+}
+
+// call sends an encrypted NIP-47 request to the wallet and decodes the result into out.
+func (b *nwcBackend) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	conn, err := ParseNWCURI(b.cfg.MacaroonHex)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"method": method,
+		"params": params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal NWC request: %w", err)
+	}
+
+	content, err := nostr.NIP04Encrypt(conn.Secret, conn.WalletPubkey, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt NWC request: %w", err)
+	}
+
+	request := nostr.NewEvent(nwcKindRequest, [][]string{{"p", conn.WalletPubkey}}, content)
+	if err := request.Sign(conn.Secret); err != nil {
+		return fmt.Errorf("failed to sign NWC request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, nwcTimeout)
+	defer cancel()
+
+	client := nostr.NewClient(conn.Relay)
+	if err := client.Connect(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrLNDConnectionFailed, err)
+	}
+	defer client.Close()
+
+	filter := nostr.Filter{
+		Kinds:   []int{nwcKindResponse},
+		Authors: []string{conn.WalletPubkey},
+		ETags:   []string{request.ID},
+	}
+
+	var resp *nwcResponse
+	err = client.Request(ctx, filter, request, func(event *nostr.SyncEvent) error {
+		if event.Pubkey != conn.WalletPubkey || event.Verify() != nil {
+			return nil // Ignore anything not signed by the wallet
+		}
+		plaintext, err := nostr.NIP04Decrypt(conn.Secret, conn.WalletPubkey, event.Content)
+		if err != nil {
+			return nil
+		}
+		var r nwcResponse
+		if err := json.Unmarshal([]byte(plaintext), &r); err != nil {
+			return nil
+		}
+		resp = &r
+		return nostr.ErrStopSubscription
+	})
+	if resp == nil {
+		if err == nil {
+			err = errors.New("no response from wallet")
+		}
+		return fmt.Errorf("%w: %v", ErrLNDConnectionFailed, err)
+	}
+
+	if resp.Error != nil {
+		switch resp.Error.Code {
+		case "UNAUTHORIZED", "RESTRICTED":
+			return ErrLNDAuthFailed
+		case "NOT_FOUND":
+			return fmt.Errorf("invoice not found")
+		}
+		return fmt.Errorf("NWC %s failed: %s: %s", method, resp.Error.Code, resp.Error.Message)
+	}
+
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("failed to decode NWC result: %w", err)
+	}
+
+	return nil
+}
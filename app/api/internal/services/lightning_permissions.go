@@ -0,0 +1,236 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidMacaroon is returned when a macaroon cannot be decoded.
+var ErrInvalidMacaroon = errors.New("macaroon is not a hex-encoded LND macaroon")
+
+// MacaroonCheck is the result of checking the permissions an LND macaroon
+// grants against what Roostr needs to take payments.
+type MacaroonCheck struct {
+	Permissions []string `json:"permissions"` // entity:action pairs granted
+	Excessive   []string `json:"excessive"`   // Permissions beyond invoices and read-only status
+	Missing     []string `json:"missing"`     // Capabilities required to take payments
+	Warnings    []string `json:"warnings"`    // Optional capabilities not granted
+	InvoiceOnly bool     `json:"invoice_only"`
+}
+
+// macaroonCapability is something Roostr does with the node, granted by an
+// entity permission or by the RPC's URI permission.
+type macaroonCapability struct {
+	name     string
+	grants   []string
+	required bool
+	warning  string
+}
+
+var macaroonCapabilities = []macaroonCapability{
+	{name: "create invoices", grants: []string{"invoices:write", "uri:/lnrpc.Lightning/AddInvoice"}, required: true},
+	{name: "look up invoices", grants: []string{"invoices:read", "uri:/lnrpc.Lightning/LookupInvoice"}, required: true},
+	{
+		name:    "subscribe to invoices",
+		grants:  []string{"invoices:read", "uri:/lnrpc.Lightning/SubscribeInvoices"},
+		warning: "Payments are detected by polling instead of streaming",
+	},
+	{
+		name:    "read node info",
+		grants:  []string{"info:read", "uri:/lnrpc.Lightning/GetInfo"},
+		warning: "Node status cannot be shown or verified",
+	},
+	{
+		name:    "read channel balance",
+		grants:  []string{"offchain:read", "uri:/lnrpc.Lightning/ChannelBalance"},
+		warning: "Channel balance cannot be shown",
+	},
+}
+
+// safeMacaroonPermissions are granted by LND's invoice.macaroon or needed for
+// the status page; none of them can move funds or change the node.
+var safeMacaroonPermissions = map[string]bool{
+	"invoices:read":  true,
+	"invoices:write": true,
+	"info:read":      true,
+	"offchain:read":  true,
+	"onchain:read":   true,
+	"address:read":   true,
+	"address:write":  true,
+}
+
+// CheckLNDMacaroon decodes the permissions of a hex-encoded LND macaroon and
+// reports those beyond invoice handling and those missing. LND records the
+// permissions in the macaroon's identifier, so no node connection is needed.
+func CheckLNDMacaroon(macaroonHex string) (*MacaroonCheck, error) {
+	permissions, err := LNDMacaroonPermissions(macaroonHex)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &MacaroonCheck{
+		Permissions: permissions,
+		Excessive:   []string{},
+		Missing:     []string{},
+		Warnings:    []string{},
+	}
+	granted := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		granted[p] = true
+		if !safeMacaroonPermissions[p] && !isSafeURIPermission(p) {
+			check.Excessive = append(check.Excessive, p)
+		}
+	}
+
+	for _, c := range macaroonCapabilities {
+		ok := false
+		for _, g := range c.grants {
+			ok = ok || granted[g]
+		}
+		switch {
+		case ok:
+		case c.required:
+			check.Missing = append(check.Missing, c.name)
+		default:
+			check.Warnings = append(check.Warnings, c.warning)
+		}
+	}
+
+	check.InvoiceOnly = len(check.Excessive) == 0 && len(check.Missing) == 0
+	return check, nil
+}
+
+// isSafeURIPermission reports whether a URI permission is for an RPC used
+// to take payments or show status.
+func isSafeURIPermission(permission string) bool {
+	for _, c := range macaroonCapabilities {
+		for _, g := range c.grants {
+			if g == permission && strings.HasPrefix(g, "uri:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LNDMacaroonPermissions returns the sorted entity:action permissions of a
+// hex-encoded LND macaroon.
+func LNDMacaroonPermissions(macaroonHex string) ([]string, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(macaroonHex))
+	if err != nil {
+		return nil, ErrInvalidMacaroon
+	}
+	id, err := macaroonIdentifier(raw)
+	if err != nil {
+		return nil, err
+	}
+	// LND identifiers are a version byte followed by a MacaroonId protobuf
+	if len(id) == 0 || id[0] != 3 {
+		return nil, ErrInvalidMacaroon
+	}
+
+	seen := make(map[string]bool)
+	err = protoFields(id[1:], func(num int, op []byte) error {
+		if num != 3 { // ops
+			return nil
+		}
+		var entity string
+		var actions []string
+		if err := protoFields(op, func(num int, value []byte) error {
+			switch num {
+			case 1:
+				entity = string(value)
+			case 2:
+				actions = append(actions, string(value))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, action := range actions {
+			seen[entity+":"+action] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]string, 0, len(seen))
+	for p := range seen {
+		permissions = append(permissions, p)
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// macaroonIdentifier returns the identifier of a version 2 binary macaroon.
+// The header is a series of type, length, value fields ending with type 0;
+// the identifier is type 2.
+func macaroonIdentifier(raw []byte) ([]byte, error) {
+	if len(raw) == 0 || raw[0] != 2 {
+		return nil, ErrInvalidMacaroon
+	}
+	data := raw[1:]
+	for {
+		fieldType, n := binary.Uvarint(data)
+		if n <= 0 || fieldType == 0 {
+			return nil, ErrInvalidMacaroon
+		}
+		data = data[n:]
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, ErrInvalidMacaroon
+		}
+		value := data[n : n+int(length)]
+		if fieldType == 2 {
+			return value, nil
+		}
+		data = data[n+int(length):]
+	}
+}
+
+// protoFields calls fn with the number and value of each length-delimited
+// field of a protobuf message, skipping fields of other wire types.
+func protoFields(msg []byte, fn func(num int, value []byte) error) error {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return ErrInvalidMacaroon
+		}
+		msg = msg[n:]
+
+		switch key & 7 {
+		case 0: // varint
+			if _, n = binary.Uvarint(msg); n <= 0 {
+				return ErrInvalidMacaroon
+			}
+			msg = msg[n:]
+		case 1: // 64-bit
+			if len(msg) < 8 {
+				return ErrInvalidMacaroon
+			}
+			msg = msg[8:]
+		case 5: // 32-bit
+			if len(msg) < 4 {
+				return ErrInvalidMacaroon
+			}
+			msg = msg[4:]
+		case 2: // length-delimited
+			length, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < length {
+				return ErrInvalidMacaroon
+			}
+			if err := fn(int(key>>3), msg[n:n+int(length)]); err != nil {
+				return err
+			}
+			msg = msg[n+int(length):]
+		default:
+			return ErrInvalidMacaroon
+		}
+	}
+	return nil
+}
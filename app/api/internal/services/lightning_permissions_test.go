@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testMacaroon builds a hex-encoded version 2 macaroon whose identifier
+// grants ops, given as entity followed by actions.
+func testMacaroon(ops ...[]string) string {
+	field := func(b []byte, num uint64, value []byte) []byte {
+		b = binary.AppendUvarint(b, num<<3|2)
+		b = binary.AppendUvarint(b, uint64(len(value)))
+		return append(b, value...)
+	}
+
+	id := []byte{3}
+	id = field(id, 1, make([]byte, 16)) // nonce
+	id = field(id, 2, []byte{0})        // storage ID
+	for _, op := range ops {
+		msg := field(nil, 1, []byte(op[0]))
+		for _, action := range op[1:] {
+			msg = field(msg, 2, []byte(action))
+		}
+		id = field(id, 3, msg)
+	}
+
+	raw := []byte{2}
+	raw = append(raw, 1, 3)
+	raw = append(raw, "lnd"...)
+	raw = append(raw, 2)
+	raw = binary.AppendUvarint(raw, uint64(len(id)))
+	raw = append(raw, id...)
+	raw = append(raw, 0, 0, 6, 32)
+	raw = append(raw, make([]byte, 32)...)
+	return hex.EncodeToString(raw)
+}
+
+func TestCheckLNDMacaroon(t *testing.T) {
+	t.Run("invoice_macaroon", func(t *testing.T) {
+		mac := testMacaroon(
+			[]string{"address", "read", "write"},
+			[]string{"invoices", "read", "write"},
+			[]string{"onchain", "read"},
+		)
+		check, err := CheckLNDMacaroon(mac)
+		if err != nil {
+			t.Fatalf("CheckLNDMacaroon() error = %v", err)
+		}
+		want := []string{"address:read", "address:write", "invoices:read", "invoices:write", "onchain:read"}
+		if !reflect.DeepEqual(check.Permissions, want) {
+			t.Errorf("Permissions = %v, want %v", check.Permissions, want)
+		}
+		if !check.InvoiceOnly || len(check.Excessive) != 0 || len(check.Missing) != 0 {
+			t.Errorf("expected invoice-only macaroon, got %+v", check)
+		}
+		if len(check.Warnings) != 2 {
+			t.Errorf("expected warnings for node info and balance, got %v", check.Warnings)
+		}
+	})
+
+	t.Run("admin_macaroon", func(t *testing.T) {
+		mac := testMacaroon(
+			[]string{"info", "read", "write"},
+			[]string{"invoices", "read", "write"},
+			[]string{"offchain", "read", "write"},
+			[]string{"macaroon", "generate"},
+		)
+		check, err := CheckLNDMacaroon(mac)
+		if err != nil {
+			t.Fatalf("CheckLNDMacaroon() error = %v", err)
+		}
+		want := []string{"info:write", "macaroon:generate", "offchain:write"}
+		if !reflect.DeepEqual(check.Excessive, want) {
+			t.Errorf("Excessive = %v, want %v", check.Excessive, want)
+		}
+		if check.InvoiceOnly {
+			t.Error("expected admin macaroon not to be invoice-only")
+		}
+	})
+
+	t.Run("uri_permissions", func(t *testing.T) {
+		mac := testMacaroon(
+			[]string{"uri", "/lnrpc.Lightning/AddInvoice", "/lnrpc.Lightning/LookupInvoice", "/lnrpc.Lightning/GetInfo"},
+		)
+		check, err := CheckLNDMacaroon(mac)
+		if err != nil {
+			t.Fatalf("CheckLNDMacaroon() error = %v", err)
+		}
+		if !check.InvoiceOnly {
+			t.Errorf("expected invoice-only macaroon, got %+v", check)
+		}
+	})
+
+	t.Run("read_only_macaroon", func(t *testing.T) {
+		check, err := CheckLNDMacaroon(testMacaroon([]string{"invoices", "read"}, []string{"info", "read"}))
+		if err != nil {
+			t.Fatalf("CheckLNDMacaroon() error = %v", err)
+		}
+		if !reflect.DeepEqual(check.Missing, []string{"create invoices"}) {
+			t.Errorf("Missing = %v", check.Missing)
+		}
+		if check.InvoiceOnly {
+			t.Error("expected a macaroon that cannot create invoices to fail")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, mac := range []string{"not hex", "abc123", "0201036c6e64", ""} {
+			if _, err := CheckLNDMacaroon(mac); !errors.Is(err, ErrInvalidMacaroon) {
+				t.Errorf("CheckLNDMacaroon(%q) error = %v, want ErrInvalidMacaroon", mac, err)
+			}
+		}
+	})
+}
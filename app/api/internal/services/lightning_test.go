@@ -3,12 +3,16 @@ package services
 import (
 	"context"
 	"encoding/hex"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
 )
 
 // TestLN001_LightningServiceBasics tests basic LightningService functionality (LN-001)
@@ -449,3 +453,606 @@ func TestLN001_Errors(t *testing.T) {
 		})
 	}
 }
+
+// TestLN007_MockCLNServer tests the Core Lightning backend with a mock clnrest server (LN-007)
+func TestLN007_MockCLNServer(t *testing.T) {
+	newCLNService := func(server *httptest.Server) *LightningService {
+		return &LightningService{
+			client: server.Client(),
+			config: &LNDConfig{
+				NodeType:    NodeTypeCLN,
+				Host:        strings.TrimPrefix(server.URL, "https://"),
+				MacaroonHex: "testrune",
+			},
+		}
+	}
+
+	t.Run("GetInfo_success", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/getinfo" {
+				t.Errorf("unexpected path: %s", r.URL.Path)
+			}
+			if r.Method != "POST" {
+				t.Errorf("expected POST, got %s", r.Method)
+			}
+			if r.Header.Get("Rune") != "testrune" {
+				t.Error("missing or incorrect rune header")
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":                  "03cln",
+				"alias":               "CLNNode",
+				"version":             "v24.02",
+				"num_peers":           3,
+				"num_active_channels": 2,
+				"blockheight":         830000,
+			})
+		}))
+		defer server.Close()
+
+		info, err := newCLNService(server).GetInfo(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Alias != "CLNNode" || info.Pubkey != "03cln" {
+			t.Errorf("unexpected node info: %+v", info)
+		}
+		if !info.SyncedToChain {
+			t.Error("expected SyncedToChain to be true without sync warnings")
+		}
+		if info.BlockHeight != 830000 {
+			t.Errorf("expected block height 830000, got %d", info.BlockHeight)
+		}
+	})
+
+	t.Run("GetInfo_unauthorized", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		_, err := newCLNService(server).GetInfo(context.Background())
+		if err != ErrLNDAuthFailed {
+			t.Errorf("expected ErrLNDAuthFailed, got %v", err)
+		}
+	})
+
+	t.Run("GetBalance_sums_normal_channels", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/listfunds" {
+				t.Errorf("unexpected path: %s", r.URL.Path)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"channels": []map[string]interface{}{
+					{"state": "CHANNELD_NORMAL", "our_amount_msat": 600000000, "amount_msat": 1000000000},
+					{"state": "CHANNELD_NORMAL", "our_amount_msat": "400000000msat", "amount_msat": "500000000msat"},
+					{"state": "ONCHAIN", "our_amount_msat": 999000, "amount_msat": 999000},
+				},
+			})
+		}))
+		defer server.Close()
+
+		balance, err := newCLNService(server).GetBalance(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if balance.LocalBalance != 1000000 {
+			t.Errorf("expected local balance 1000000, got %d", balance.LocalBalance)
+		}
+		if balance.RemoteBalance != 500000 {
+			t.Errorf("expected remote balance 500000, got %d", balance.RemoteBalance)
+		}
+		if balance.TotalBalance != 1500000 {
+			t.Errorf("expected total balance 1500000, got %d", balance.TotalBalance)
+		}
+	})
+
+	t.Run("CreateInvoice_success", func(t *testing.T) {
+		expiresAt := time.Now().Add(900 * time.Second).Unix()
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/invoice" {
+				t.Errorf("unexpected path: %s", r.URL.Path)
+			}
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["amount_msat"].(float64) != 1000000 {
+				t.Errorf("expected amount_msat 1000000, got %v", req["amount_msat"])
+			}
+			if req["description"].(string) != "Test invoice" {
+				t.Errorf("expected description 'Test invoice', got %v", req["description"])
+			}
+			if label, _ := req["label"].(string); !strings.HasPrefix(label, "roostr-") {
+				t.Errorf("expected roostr- label, got %v", req["label"])
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"payment_hash": "abcd",
+				"bolt11":       "lnbc10u1pcln",
+				"expires_at":   expiresAt,
+			})
+		}))
+		defer server.Close()
+
+		invoice, err := newCLNService(server).CreateInvoice(context.Background(), 1000, "Test invoice", 900)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if invoice.PaymentRequest != "lnbc10u1pcln" || invoice.PaymentHash != "abcd" {
+			t.Errorf("unexpected invoice: %+v", invoice)
+		}
+		if invoice.ExpiresAt.Unix() != expiresAt {
+			t.Errorf("expected expires_at %d, got %d", expiresAt, invoice.ExpiresAt.Unix())
+		}
+	})
+
+	t.Run("CheckInvoice_paid", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/listinvoices" {
+				t.Errorf("unexpected path: %s", r.URL.Path)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"invoices": []map[string]interface{}{
+					{
+						"payment_hash": "abcd",
+						"bolt11":       "lnbc10u1pcln",
+						"description":  "memo",
+						"amount_msat":  21000000,
+						"status":       "paid",
+						"expires_at":   1700000900,
+						"paid_at":      1700000100,
+					},
+				},
+			})
+		}))
+		defer server.Close()
+
+		invoice, err := newCLNService(server).CheckInvoice(context.Background(), "abcd")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !invoice.Settled {
+			t.Error("expected invoice to be settled")
+		}
+		if invoice.AmountSats != 21000 {
+			t.Errorf("expected 21000 sats, got %d", invoice.AmountSats)
+		}
+		if invoice.SettledAt == nil || invoice.SettledAt.Unix() != 1700000100 {
+			t.Errorf("unexpected settled_at: %v", invoice.SettledAt)
+		}
+	})
+
+	t.Run("CheckInvoice_not_found", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"invoices": []interface{}{}})
+		}))
+		defer server.Close()
+
+		if _, err := newCLNService(server).CheckInvoice(context.Background(), "abcd"); err == nil {
+			t.Error("expected error for missing invoice")
+		}
+	})
+
+	t.Run("SubscribeInvoices_unsupported", func(t *testing.T) {
+		svc := NewLightningService(nil)
+		svc.Configure(&LNDConfig{NodeType: NodeTypeCLN, Host: "localhost:3010", MacaroonHex: "rune"})
+		err := svc.SubscribeInvoices(context.Background(), nil, func(string, bool) {})
+		if err != ErrSubscribeUnsupported {
+			t.Errorf("expected ErrSubscribeUnsupported, got %v", err)
+		}
+	})
+
+	t.Run("Unknown_node_type", func(t *testing.T) {
+		svc := NewLightningService(nil)
+		_, err := svc.TestConnection(context.Background(), &LNDConfig{NodeType: "eclair", Host: "x:1", MacaroonHex: "y"})
+		if !errors.Is(err, ErrUnsupportedNodeType) {
+			t.Errorf("expected ErrUnsupportedNodeType, got %v", err)
+		}
+	})
+}
+
+// TestLN008_MockLNbitsServer tests the LNbits backend with a mock LNbits API (LN-008)
+func TestLN008_MockLNbitsServer(t *testing.T) {
+	newLNbitsService := func(server *httptest.Server) *LightningService {
+		return &LightningService{
+			client: server.Client(),
+			config: &LNDConfig{
+				NodeType:    NodeTypeLNbits,
+				Host:        server.URL,
+				MacaroonHex: "testapikey",
+			},
+		}
+	}
+
+	t.Run("GetBalance_success", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/wallet" {
+				t.Errorf("unexpected path: %s", r.URL.Path)
+			}
+			if r.Header.Get("X-Api-Key") != "testapikey" {
+				t.Error("missing or incorrect API key header")
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":    "Relay Wallet",
+				"balance": 2500000,
+			})
+		}))
+		defer server.Close()
+
+		balance, err := newLNbitsService(server).GetBalance(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if balance.LocalBalance != 2500 {
+			t.Errorf("expected local balance 2500, got %d", balance.LocalBalance)
+		}
+	})
+
+	t.Run("GetInfo_unauthorized", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		_, err := newLNbitsService(server).GetInfo(context.Background())
+		if err != ErrLNDAuthFailed {
+			t.Errorf("expected ErrLNDAuthFailed, got %v", err)
+		}
+	})
+
+	t.Run("CreateInvoice_success", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/payments" || r.Method != "POST" {
+				t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			}
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["out"] != false || body["amount"] != float64(1000) {
+				t.Errorf("unexpected body: %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"payment_hash":    "abc123",
+				"payment_request": "lnbc10u1...",
+			})
+		}))
+		defer server.Close()
+
+		invoice, err := newLNbitsService(server).CreateInvoice(context.Background(), 1000, "Relay access", 600)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if invoice.PaymentHash != "abc123" || invoice.PaymentRequest != "lnbc10u1..." {
+			t.Errorf("unexpected invoice: %+v", invoice)
+		}
+		if invoice.AmountSats != 1000 {
+			t.Errorf("expected 1000 sats, got %d", invoice.AmountSats)
+		}
+	})
+
+	t.Run("CheckInvoice_paid", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/payments/abc123" {
+				t.Errorf("unexpected path: %s", r.URL.Path)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"paid": true,
+				"details": map[string]interface{}{
+					"bolt11": "lnbc10u1...",
+					"memo":   "Relay access",
+					"amount": 1000000,
+					"time":   1700000000,
+					"expiry": "2023-11-14T22:23:20",
+				},
+			})
+		}))
+		defer server.Close()
+
+		invoice, err := newLNbitsService(server).CheckInvoice(context.Background(), "abc123")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !invoice.Settled {
+			t.Error("expected invoice to be settled")
+		}
+		if invoice.AmountSats != 1000 {
+			t.Errorf("expected 1000 sats, got %d", invoice.AmountSats)
+		}
+		if invoice.ExpiresAt.IsZero() {
+			t.Error("expected expiry to be parsed")
+		}
+	})
+}
+
+// TestLN008_ParseNWCURI tests Nostr Wallet Connect connection string parsing (LN-008)
+func TestLN008_ParseNWCURI(t *testing.T) {
+	walletPubkey := "b889ff5b1513b641e2a139f661a661364979c5beee91842f8f0ef42ab558e9d4"
+	secret := "71a8c14c1407c113601079c4302dab36460f0ccd0ad506f1f2dc73b5100e4f3c"
+
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr bool
+	}{
+		{"valid", "nostr+walletconnect://" + walletPubkey + "?relay=wss%3A%2F%2Frelay.example.com&secret=" + secret, false},
+		{"opaque form", "nostr+walletconnect:" + walletPubkey + "?relay=wss://relay.example.com&secret=" + secret, false},
+		{"wrong scheme", "https://" + walletPubkey + "?relay=wss://relay.example.com&secret=" + secret, true},
+		{"missing relay", "nostr+walletconnect://" + walletPubkey + "?secret=" + secret, true},
+		{"bad secret", "nostr+walletconnect://" + walletPubkey + "?relay=wss://relay.example.com&secret=nothex", true},
+		{"bad pubkey", "nostr+walletconnect://abc?relay=wss://relay.example.com&secret=" + secret, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := ParseNWCURI(tt.uri)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidNWCURI) {
+					t.Errorf("expected ErrInvalidNWCURI, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if conn.WalletPubkey != walletPubkey || conn.Relay != "wss://relay.example.com" || conn.Secret != secret {
+				t.Errorf("unexpected connection: %+v", conn)
+			}
+		})
+	}
+}
+
+// TestLN009_SubscribeInvoicesStream tests the LND invoice subscription stream (LN-009)
+func TestLN009_SubscribeInvoicesStream(t *testing.T) {
+	hash := []byte{0xab, 0xcd, 0xef}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/invoices/subscribe" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		enc := json.NewEncoder(w)
+		enc.Encode(map[string]interface{}{"result": map[string]interface{}{"r_hash": base64.StdEncoding.EncodeToString(hash), "state": "OPEN"}})
+		enc.Encode(map[string]interface{}{"result": map[string]interface{}{"r_hash": base64.StdEncoding.EncodeToString(hash), "state": "SETTLED"}})
+	}))
+	defer server.Close()
+
+	svc := &LightningService{
+		client: server.Client(),
+		config: &LNDConfig{Host: strings.TrimPrefix(server.URL, "https://"), MacaroonHex: "abc"},
+	}
+
+	connected := false
+	var updates []bool
+	err := svc.SubscribeInvoices(context.Background(), func() { connected = true }, func(paymentHash string, settled bool) {
+		if paymentHash != "abcdef" {
+			t.Errorf("expected hex payment hash abcdef, got %s", paymentHash)
+		}
+		updates = append(updates, settled)
+	})
+
+	if err == nil {
+		t.Error("expected error when the stream closes")
+	}
+	if !connected {
+		t.Error("expected onConnected to be called")
+	}
+	if len(updates) != 2 || updates[0] || !updates[1] {
+		t.Errorf("expected [false true] settled updates, got %v", updates)
+	}
+}
+
+// TestLN010_MockBackend tests the built-in mock Lightning backend (LN-010)
+func TestLN010_MockBackend(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("options_round_trip", func(t *testing.T) {
+		opts := MockLightningOptions{SettleAfterSecs: -1, FailCheck: true}
+		if got := ParseMockLightningOptions(opts.Host()); got != opts {
+			t.Errorf("expected %+v, got %+v", opts, got)
+		}
+		if got := ParseMockLightningOptions("true"); got.SettleAfterSecs != defaultMockSettleAfter || got.FailCreate {
+			t.Errorf("expected defaults, got %+v", got)
+		}
+	})
+
+	t.Run("auto_settles_and_streams", func(t *testing.T) {
+		svc := NewLightningService(nil)
+		svc.Configure(&LNDConfig{NodeType: NodeTypeMock, Host: "settle_after=0"})
+		if !svc.IsConfigured() {
+			t.Fatal("expected mock node to be configured without a credential")
+		}
+
+		subCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		connected := make(chan struct{})
+		settled := make(chan string, 1)
+		go svc.SubscribeInvoices(subCtx, func() { close(connected) }, func(paymentHash string, isSettled bool) {
+			if isSettled {
+				settled <- paymentHash
+			}
+		})
+		<-connected
+
+		invoice, err := svc.CreateInvoice(ctx, 1000, "test", 60)
+		if err != nil {
+			t.Fatalf("CreateInvoice failed: %v", err)
+		}
+		if !strings.HasPrefix(invoice.PaymentRequest, "lnbcrt1000mock1") {
+			t.Errorf("unexpected payment request %s", invoice.PaymentRequest)
+		}
+
+		select {
+		case hash := <-settled:
+			if hash != invoice.PaymentHash {
+				t.Errorf("expected settlement for %s, got %s", invoice.PaymentHash, hash)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected invoice to settle")
+		}
+
+		checked, err := svc.CheckInvoice(ctx, invoice.PaymentHash)
+		if err != nil || !checked.Settled || checked.SettledAt == nil {
+			t.Errorf("expected settled invoice, got %+v (%v)", checked, err)
+		}
+		balance, _ := svc.GetBalance(ctx)
+		if balance.LocalBalance != 1000 {
+			t.Errorf("expected balance 1000, got %d", balance.LocalBalance)
+		}
+	})
+
+	t.Run("manual_settle", func(t *testing.T) {
+		svc := NewLightningService(nil)
+		svc.Configure(&LNDConfig{NodeType: NodeTypeMock, Host: "settle_after=-1"})
+
+		invoice, _ := svc.CreateInvoice(ctx, 500, "test", 60)
+		checked, _ := svc.CheckInvoice(ctx, invoice.PaymentHash)
+		if checked.Settled {
+			t.Fatal("expected invoice to stay pending")
+		}
+		if !svc.SettleMockInvoice(invoice.PaymentHash) {
+			t.Fatal("expected manual settle to succeed")
+		}
+		if svc.SettleMockInvoice(invoice.PaymentHash) {
+			t.Error("expected second settle to be refused")
+		}
+		checked, _ = svc.CheckInvoice(ctx, invoice.PaymentHash)
+		if !checked.Settled {
+			t.Error("expected invoice to be settled")
+		}
+	})
+
+	t.Run("failure_toggles", func(t *testing.T) {
+		svc := NewLightningService(nil)
+		svc.Configure(&LNDConfig{NodeType: NodeTypeMock, Host: "fail_create=true&offline=true"})
+
+		if _, err := svc.CreateInvoice(ctx, 100, "test", 60); !errors.Is(err, ErrMockFailure) {
+			t.Errorf("expected ErrMockFailure, got %v", err)
+		}
+		if _, err := svc.GetInfo(ctx); !errors.Is(err, ErrLNDConnectionFailed) {
+			t.Errorf("expected connection failure, got %v", err)
+		}
+	})
+
+	t.Run("forced_mock_ignores_stored_config", func(t *testing.T) {
+		svc := NewLightningService(nil)
+		svc.UseMock("settle_after=1")
+		svc.Configure(&LNDConfig{Host: "localhost:8080", MacaroonHex: "abc"})
+
+		cfg := svc.GetConfig()
+		if cfg.NodeType != NodeTypeMock || !svc.IsForcedMock() {
+			t.Errorf("expected forced mock config, got %+v", cfg)
+		}
+
+		if err := svc.SetMockOptions(ctx, MockLightningOptions{FailCheck: true}); err != nil {
+			t.Fatalf("SetMockOptions failed: %v", err)
+		}
+		if !ParseMockLightningOptions(svc.GetConfig().Host).FailCheck {
+			t.Error("expected fail_check to be applied")
+		}
+	})
+}
+
+func TestCreateAccessInvoiceSource(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	lightning := NewLightningService(database)
+	lightning.Configure(&LNDConfig{NodeType: NodeTypeMock, Host: "settle_after=-1"})
+
+	signup, err := lightning.CreateAccessInvoice(ctx, AccessInvoiceRequest{Pubkey: "aa01", Npub: "npub1signup", TierID: "monthly"})
+	if err != nil {
+		t.Fatalf("CreateAccessInvoice() error = %v", err)
+	}
+	admin, err := lightning.CreateAccessInvoice(ctx, AccessInvoiceRequest{
+		Pubkey: "aa02",
+		Npub:   "npub1admin",
+		TierID: "monthly",
+		Expiry: 24 * time.Hour,
+		Source: db.InvoiceSourceAdmin,
+	})
+	if err != nil {
+		t.Fatalf("CreateAccessInvoice() error = %v", err)
+	}
+	if time.Until(time.Unix(admin.ExpiresAt, 0)) < 23*time.Hour {
+		t.Errorf("expected the requested expiry, expires %v", time.Unix(admin.ExpiresAt, 0))
+	}
+
+	for hash, want := range map[string]string{signup.PaymentHash: db.InvoiceSourceSignup, admin.PaymentHash: db.InvoiceSourceAdmin} {
+		inv, err := database.GetPendingInvoice(ctx, hash)
+		if err != nil || inv == nil {
+			t.Fatalf("GetPendingInvoice(%s) = %v, %v", hash, inv, err)
+		}
+		if inv.Source != want || inv.Status != "pending" {
+			t.Errorf("expected a pending %s invoice, got %s %s", want, inv.Status, inv.Source)
+		}
+	}
+	awaiting, _ := database.GetPendingInvoicesAwaitingPayment(ctx)
+	if len(awaiting) != 2 {
+		t.Errorf("expected both invoices to be awaiting payment, got %d", len(awaiting))
+	}
+}
+
+func TestReissueAccessInvoice(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	lightning := NewLightningService(database)
+	lightning.Configure(&LNDConfig{NodeType: NodeTypeMock, Host: "settle_after=-1"})
+	coupons := NewCouponService(database)
+
+	database.CreateCoupon(ctx, &db.Coupon{Code: "HALF", Kind: db.CouponPercent, Value: 50, Enabled: true})
+	quote, err := coupons.Quote(ctx, "HALF", "aa01", "monthly")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+	first, err := lightning.CreateAccessInvoice(ctx, AccessInvoiceRequest{
+		Pubkey: "aa01", Npub: "npub1first", TierID: "monthly", AmountSats: quote.AmountSats, Expiry: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("CreateAccessInvoice() error = %v", err)
+	}
+	coupons.Reserve(ctx, quote, "aa01", first.PaymentHash, time.Unix(first.ExpiresAt, 0))
+
+	second, err := lightning.ReissueAccessInvoice(ctx, first.PaymentHash)
+	if err != nil {
+		t.Fatalf("ReissueAccessInvoice() error = %v", err)
+	}
+	if second.PaymentHash == first.PaymentHash || second.AmountSats != first.AmountSats || second.TierID != "monthly" {
+		t.Errorf("expected a new invoice for the same price, got %+v", second)
+	}
+	if lifetime := time.Until(time.Unix(second.ExpiresAt, 0)); lifetime < 59*time.Minute {
+		t.Errorf("expected the first invoice's lifetime, got %v", lifetime)
+	}
+	if old, _ := database.GetPendingInvoice(ctx, first.PaymentHash); old.Status != "cancelled" {
+		t.Errorf("expected the old invoice cancelled, got %s", old.Status)
+	}
+	if r, _ := database.GetCouponRedemption(ctx, "HALF", "aa01"); r == nil || r.PaymentHash != second.PaymentHash {
+		t.Errorf("expected the coupon to move to the new invoice, got %+v", r)
+	}
+	if _, err := lightning.ReissueAccessInvoice(ctx, first.PaymentHash); !errors.Is(err, ErrInvoiceNotOpen) {
+		t.Errorf("expected ErrInvoiceNotOpen for a cancelled invoice, got %v", err)
+	}
+	if _, err := lightning.ReissueAccessInvoice(ctx, "unknown"); !errors.Is(err, ErrInvoiceNotFound) {
+		t.Errorf("expected ErrInvoiceNotFound, got %v", err)
+	}
+
+	if err := lightning.CancelAccessInvoice(ctx, second.PaymentHash); err != nil {
+		t.Fatalf("CancelAccessInvoice() error = %v", err)
+	}
+	if r, _ := database.GetCouponRedemption(ctx, "HALF", "aa01"); r != nil {
+		t.Errorf("expected the coupon reservation released, got %+v", r)
+	}
+	if err := lightning.CancelAccessInvoice(ctx, second.PaymentHash); !errors.Is(err, ErrInvoiceNotOpen) {
+		t.Errorf("expected ErrInvoiceNotOpen cancelling twice, got %v", err)
+	}
+	if lightning.SettleMockInvoice(second.PaymentHash) {
+		t.Error("expected the cancelled invoice to be unpayable on the node")
+	}
+
+	// A payment that reaches a cancelled invoice anyway grants nothing
+	monitor := NewInvoiceMonitorService(database, lightning, nil, nil)
+	if err := monitor.ProcessPayment(ctx, first.PaymentHash); err != nil {
+		t.Fatalf("ProcessPayment() error = %v", err)
+	}
+	if user, _ := database.GetPaidUserByPubkey(ctx, "aa01"); user != nil {
+		t.Errorf("expected no access from a cancelled invoice, got %+v", user)
+	}
+	if old, _ := database.GetPendingInvoice(ctx, first.PaymentHash); old.Status != "cancelled" {
+		t.Errorf("expected the paid cancelled invoice to stay cancelled, got %s", old.Status)
+	}
+}
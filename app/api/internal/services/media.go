@@ -0,0 +1,423 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+const (
+	// BlossomAuthKind is the Blossom (BUD-01) authorization event kind.
+	BlossomAuthKind = 24242
+
+	mediaRequestTimeout = 15 * time.Second
+	mediaCheckBatch     = 100
+	mediaSweepBatch     = 200
+)
+
+// ErrMediaDisabled is returned when the Blossom server is not configured.
+var ErrMediaDisabled = errors.New("media accounting is not enabled")
+
+// mediaURLPattern finds http(s) URLs in event content and tag values.
+var mediaURLPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// MediaScanResult is the outcome of a media scan.
+type MediaScanResult struct {
+	Links   int `json:"links"`   // Links from events to blobs found
+	Checked int `json:"checked"` // Blobs whose size was checked
+	Missing int `json:"missing"` // Blobs the server no longer has
+}
+
+// MediaService keeps account of the media relay events link to on the
+// operator's Blossom server: it finds blob links in stored events, asks the
+// server for each blob's size, and deletes blobs no stored event links to
+// once retention has removed their events.
+type MediaService struct {
+	db       *db.DB
+	signer   *Signer
+	client   *http.Client
+	interval time.Duration
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+	scanMu   sync.Mutex
+}
+
+// NewMediaService creates a new media service.
+func NewMediaService(database *db.DB, signer *Signer) *MediaService {
+	return &MediaService{
+		db:       database,
+		signer:   signer,
+		client:   &http.Client{Timeout: mediaRequestTimeout},
+		interval: 10 * time.Minute,
+		wakeCh:   make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the background media worker.
+func (s *MediaService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the media worker.
+func (s *MediaService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to scan now, e.g. after settings change.
+func (s *MediaService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *MediaService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.process()
+		case <-s.wakeCh:
+			s.process()
+		}
+	}
+}
+
+func (s *MediaService) process() {
+	if _, err := s.Scan(context.Background()); err != nil && !errors.Is(err, ErrMediaDisabled) {
+		slog.Error("Media scan failed", "error", err)
+	}
+}
+
+// Scan records blob links from events stored since the last scan, then
+// checks the size of blobs not checked yet. The first scan covers every
+// stored event, so existing media is counted.
+func (s *MediaService) Scan(ctx context.Context) (*MediaScanResult, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	settings, server, err := s.server(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MediaScanResult{}
+	// Events are found by row ID, which a PostgreSQL relay database lacks;
+	// blobs already found are still checked
+	links, err := s.scanEvents(ctx, server)
+	result.Links = links
+	if err != nil && !errors.Is(err, db.ErrUnsupportedOnPostgres) {
+		return result, err
+	}
+
+	blobs, err := s.db.GetUncheckedMediaBlobs(ctx, mediaCheckBatch)
+	if err != nil {
+		return result, err
+	}
+	for _, blob := range blobs {
+		size, mimeType, found, err := s.head(ctx, settings.ServerURL, blob.SHA256)
+		if err != nil {
+			slog.Warn("Failed to check media blob", "sha256", blob.SHA256, "error", err)
+			continue
+		}
+		if err := s.db.SetMediaBlobChecked(ctx, blob.SHA256, size, mimeType, !found); err != nil {
+			return result, err
+		}
+		result.Checked++
+		if !found {
+			result.Missing++
+		}
+	}
+	return result, nil
+}
+
+// server returns the media settings and the Blossom server's URL, or
+// ErrMediaDisabled.
+func (s *MediaService) server(ctx context.Context) (*db.MediaSettings, *url.URL, error) {
+	settings, err := s.db.GetMediaSettings(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !settings.Enabled || settings.ServerURL == "" {
+		return nil, nil, ErrMediaDisabled
+	}
+	server, err := url.Parse(settings.ServerURL)
+	if err != nil || server.Host == "" {
+		return nil, nil, fmt.Errorf("invalid media server URL %q", settings.ServerURL)
+	}
+	return settings, server, nil
+}
+
+// scanEvents records blob links from events stored since the media cursor.
+func (s *MediaService) scanEvents(ctx context.Context, server *url.URL) (int, error) {
+	cursor, err := s.db.GetMediaCursor(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	last := cursor
+	links := 0
+	var refs []db.MediaRef
+	flush := func() error {
+		if len(refs) == 0 {
+			return nil
+		}
+		if err := s.db.AddMediaRefs(ctx, refs); err != nil {
+			return err
+		}
+		links += len(refs)
+		refs = refs[:0]
+		return s.db.SetMediaCursor(ctx, last)
+	}
+
+	err = s.db.StreamEventsAfter(ctx, cursor, nil, func(rowID int64, event db.ExportEvent) error {
+		last = rowID
+		refs = append(refs, extractMediaRefs(event, server.Host)...)
+		if len(refs) >= mediaSweepBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return links, err
+	}
+	if err := flush(); err != nil {
+		return links, err
+	}
+	// Keep the progress made through events without media
+	if last > cursor {
+		return links, s.db.SetMediaCursor(ctx, last)
+	}
+	return links, nil
+}
+
+// extractMediaRefs returns the links from an event's content and tags to
+// blobs on the server with the given host. Blossom URLs end in the blob's
+// SHA-256, optionally with a file extension.
+func extractMediaRefs(event db.ExportEvent, host string) []db.MediaRef {
+	texts := []string{event.Content}
+	for _, tag := range event.Tags {
+		texts = append(texts, tag...)
+	}
+
+	seen := make(map[string]bool)
+	var refs []db.MediaRef
+	for _, text := range texts {
+		for _, raw := range mediaURLPattern.FindAllString(text, -1) {
+			u, err := url.Parse(raw)
+			if err != nil || !strings.EqualFold(u.Host, host) {
+				continue
+			}
+			name := path.Base(u.Path)
+			sha256 := strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+			if !isBlobHash(sha256) || seen[sha256] {
+				continue
+			}
+			seen[sha256] = true
+			refs = append(refs, db.MediaRef{
+				SHA256:  sha256,
+				EventID: event.ID,
+				Owner:   event.Pubkey,
+				URL:     raw,
+			})
+		}
+	}
+	return refs
+}
+
+// isBlobHash reports whether s is a hex SHA-256.
+func isBlobHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// head asks the server for a blob's size and type (BUD-01 HEAD /<sha256>).
+// found is false if the server does not have the blob.
+func (s *MediaService) head(ctx context.Context, serverURL, sha256 string) (size int64, mimeType string, found bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, blobURL(serverURL, sha256), nil)
+	if err != nil {
+		return 0, "", false, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, "", false, nil
+	case resp.StatusCode != http.StatusOK:
+		return 0, "", false, fmt.Errorf("server returned %s", resp.Status)
+	}
+	size, err = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("server did not report the blob's size")
+	}
+	return size, resp.Header.Get("Content-Type"), true, nil
+}
+
+// SweepOrphans deletes the blobs no stored event links to from the server
+// and forgets them, signing each deletion as the operator. It returns how
+// many blobs were deleted and the bytes freed.
+func (s *MediaService) SweepOrphans(ctx context.Context) (int, int64, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	settings, server, err := s.server(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Links from events stored since the last scan keep their blobs
+	if _, err := s.scanEvents(ctx, server); err != nil && !errors.Is(err, db.ErrUnsupportedOnPostgres) {
+		return 0, 0, err
+	}
+
+	var orphans []db.MediaBlob
+	after := ""
+	for {
+		blobs, refs, err := s.db.GetMediaBlobsAfter(ctx, after, mediaSweepBatch)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(blobs) == 0 {
+			break
+		}
+		var ids []string
+		for _, blob := range blobs {
+			ids = append(ids, refs[blob.SHA256]...)
+		}
+		existing, err := s.db.ExistingEventIDs(ctx, ids)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, blob := range blobs {
+			linked := false
+			for _, id := range refs[blob.SHA256] {
+				if existing[id] {
+					linked = true
+					break
+				}
+			}
+			if !linked {
+				orphans = append(orphans, blob)
+			}
+		}
+		after = blobs[len(blobs)-1].SHA256
+	}
+
+	deleted := 0
+	var freed int64
+	for _, blob := range orphans {
+		if !blob.Missing {
+			if err := s.deleteBlob(ctx, settings.ServerURL, blob.SHA256); err != nil {
+				if errors.Is(err, ErrNoSigner) {
+					return deleted, freed, err
+				}
+				slog.Warn("Failed to delete media blob", "sha256", blob.SHA256, "error", err)
+				continue
+			}
+		}
+		if err := s.db.DeleteMediaBlob(ctx, blob.SHA256); err != nil {
+			return deleted, freed, err
+		}
+		deleted++
+		if blob.Size != nil {
+			freed += *blob.Size
+		}
+	}
+
+	if deleted > 0 {
+		s.db.AddAuditLog(ctx, "media_swept", map[string]interface{}{
+			"deleted":     deleted,
+			"bytes_freed": freed,
+		}, "")
+	}
+	return deleted, freed, nil
+}
+
+// deleteBlob deletes a blob from the server (BUD-02 DELETE /<sha256>) with
+// an authorization event signed as the operator. A blob the server no
+// longer has counts as deleted.
+func (s *MediaService) deleteBlob(ctx context.Context, serverURL, sha256 string) error {
+	event := nostr.NewEvent(BlossomAuthKind, [][]string{
+		{"t", "delete"},
+		{"x", sha256},
+		{"expiration", strconv.FormatInt(time.Now().Add(5*time.Minute).Unix(), 10)},
+	}, "Delete blob")
+	if err := s.signer.Sign(ctx, event); err != nil {
+		return err
+	}
+	auth, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, blobURL(serverURL, sha256), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Nostr "+base64.StdEncoding.EncodeToString(auth))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// blobURL returns the server's URL for a blob.
+func blobURL(serverURL, sha256 string) string {
+	return strings.TrimRight(serverURL, "/") + "/" + sha256
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// fakeBlossom is a Blossom server holding blobs of the given sizes. It
+// records the deletions it accepted.
+type fakeBlossom struct {
+	mu      sync.Mutex
+	blobs   map[string]int
+	deleted []string
+	authErr string
+}
+
+func newFakeBlossom(t *testing.T, blobs map[string]int) (*fakeBlossom, string) {
+	t.Helper()
+	fb := &fakeBlossom{blobs: blobs}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fb.mu.Lock()
+		defer fb.mu.Unlock()
+
+		sha256 := strings.TrimPrefix(r.URL.Path, "/")
+		size, ok := fb.blobs[sha256]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+		case http.MethodDelete:
+			raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.Header.Get("Authorization"), "Nostr "))
+			var event nostr.SyncEvent
+			if err != nil || json.Unmarshal(raw, &event) != nil || event.Verify() != nil ||
+				event.Kind != BlossomAuthKind || !hasTag(&event, "t", "delete") || !hasTag(&event, "x", sha256) {
+				fb.authErr = "invalid authorization for " + sha256
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			delete(fb.blobs, sha256)
+			fb.deleted = append(fb.deleted, sha256)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return fb, srv.URL
+}
+
+func TestExtractMediaRefs(t *testing.T) {
+	blob1 := strings.Repeat("ab", 32)
+	blob2 := strings.Repeat("cd", 32)
+	event := db.ExportEvent{
+		ID:     strings.Repeat("01", 32),
+		Pubkey: strings.Repeat("aa", 32),
+		Content: "look https://media.example.com/" + blob1 + ".png and https://other.example.com/" + blob2 +
+			" and again https://MEDIA.example.com/" + strings.ToUpper(blob1),
+		Tags: [][]string{
+			{"imeta", "url https://media.example.com/" + blob2 + ".jpg", "m image/jpeg"},
+			{"r", "https://media.example.com/not-a-blob.png"},
+		},
+	}
+
+	refs := extractMediaRefs(event, "media.example.com")
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 links, got %+v", refs)
+	}
+	if refs[0].SHA256 != blob1 || refs[1].SHA256 != blob2 {
+		t.Errorf("unexpected links: %+v", refs)
+	}
+	for _, ref := range refs {
+		if ref.EventID != event.ID || ref.Owner != event.Pubkey {
+			t.Errorf("unexpected link: %+v", ref)
+		}
+	}
+}
+
+func TestMediaService_ScanAndSweep(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	operator, _ := nostr.GetPublicKey(operatorSecret)
+	database.SetAppState(ctx, "operator_pubkey", operator)
+	database.SetOperatorSigningKey(ctx, operatorSecret)
+
+	alice := strings.Repeat("aa", 32)
+	bob := strings.Repeat("bb", 32)
+	blob1 := strings.Repeat("11", 32)
+	blob2 := strings.Repeat("22", 32)
+	blob3 := strings.Repeat("33", 32)
+	blossom, serverURL := newFakeBlossom(t, map[string]int{blob1: 1000, blob2: 250})
+
+	svc := NewMediaService(database, NewSigner(database))
+
+	if _, err := svc.Scan(ctx); err != ErrMediaDisabled {
+		t.Fatalf("expected ErrMediaDisabled, got %v", err)
+	}
+	database.SetMediaSettings(ctx, &db.MediaSettings{Enabled: true, ServerURL: serverURL, DeleteWithRetention: true})
+
+	// Events existing before the first scan are counted
+	note1 := insertModerationTestEvent(t, relayDB, 1, alice, 1700000001, serverURL+"/"+blob1+".png")
+	insertModerationTestEvent(t, relayDB, 2, alice, 1700000002, "again "+serverURL+"/"+blob1)
+	insertModerationTestEvent(t, relayDB, 3, bob, 1700000003, serverURL+"/"+blob2+" "+serverURL+"/"+blob3)
+	insertModerationTestEvent(t, relayDB, 4, bob, 1700000004, "no media here")
+
+	result, err := svc.Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.Links != 4 || result.Checked != 3 || result.Missing != 1 {
+		t.Errorf("unexpected scan result: %+v", result)
+	}
+
+	usage, _ := database.GetMediaUsage(ctx, alice)
+	if usage.Blobs != 1 || usage.Bytes != 1000 || usage.Unchecked != 0 {
+		t.Errorf("unexpected usage for alice: %+v", usage)
+	}
+	total, owners, _ := database.GetMediaUsageByOwner(ctx, 10)
+	if total.Blobs != 2 || total.Bytes != 1250 {
+		t.Errorf("unexpected total usage: %+v", total)
+	}
+	if len(owners) != 2 || owners[0].Pubkey != alice {
+		t.Errorf("expected alice to use the most media, got %+v", owners)
+	}
+
+	// A later scan only reads new events
+	if result, _ := svc.Scan(ctx); result.Links != 0 || result.Checked != 0 {
+		t.Errorf("expected nothing new, got %+v", result)
+	}
+
+	// Nothing is swept while every blob is linked
+	if deleted, _, err := svc.SweepOrphans(ctx); err != nil || deleted != 0 {
+		t.Fatalf("expected no deletions, got %d (%v)", deleted, err)
+	}
+
+	// Blobs are deleted once no stored event links to them
+	hash, _ := hex.DecodeString(note1)
+	relayDB.Exec("DELETE FROM event WHERE event_hash = ?", hash)
+	if deleted, _, err := svc.SweepOrphans(ctx); err != nil || deleted != 0 {
+		t.Fatalf("expected blob1 to be kept for its other event, got %d (%v)", deleted, err)
+	}
+	relayDB.Exec("DELETE FROM event WHERE kind = 1 AND author IN (?, ?)", mustDecodeHex(alice), mustDecodeHex(bob))
+
+	deleted, freed, err := svc.SweepOrphans(ctx)
+	if err != nil {
+		t.Fatalf("SweepOrphans failed: %v", err)
+	}
+	if deleted != 3 || freed != 1250 {
+		t.Errorf("expected 3 blobs and 1250 bytes freed, got %d and %d", deleted, freed)
+	}
+	if blossom.authErr != "" {
+		t.Error(blossom.authErr)
+	}
+	if len(blossom.deleted) != 2 || len(blossom.blobs) != 0 {
+		t.Errorf("expected both blobs deleted from the server, got %v", blossom.deleted)
+	}
+	if total, _, _ := database.GetMediaUsageByOwner(ctx, 10); total.Blobs != 0 {
+		t.Errorf("expected no media left, got %+v", total)
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
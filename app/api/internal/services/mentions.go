@@ -0,0 +1,454 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// MentionKinds are the event kinds that count as mentions: notes and replies,
+// reposts, reactions and zap receipts.
+var MentionKinds = []int{1, 6, 7, 9735}
+
+// maxSummaryLinks is how many of the newest mentions a summary links to.
+const maxSummaryLinks = 3
+
+// MentionService watches events stored on the relay for mentions ("p" tags)
+// of members who opted in, and sends each member a summary of new mentions.
+// Mentions are queued once per member and event, so an event is never
+// reported twice. Summaries are held during the member's quiet hours and sent
+// at most once per batch window.
+type MentionService struct {
+	db        *db.DB
+	notifier  *Notifier
+	configMgr *relay.ConfigManager
+	interval  time.Duration
+	now       func() time.Time
+	wakeCh    chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+}
+
+// NewMentionService creates a new mention notification service.
+func NewMentionService(database *db.DB, notifier *Notifier, configMgr *relay.ConfigManager) *MentionService {
+	return &MentionService{
+		db:        database,
+		notifier:  notifier,
+		configMgr: configMgr,
+		interval:  time.Minute,
+		now:       time.Now,
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins the background mention worker.
+func (s *MentionService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the mention worker.
+func (s *MentionService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to scan and deliver now, e.g. after settings change.
+func (s *MentionService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *MentionService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.process(context.Background())
+		case <-s.wakeCh:
+			s.process(context.Background())
+		}
+	}
+}
+
+// process queues new mentions and sends the summaries that are due.
+func (s *MentionService) process(ctx context.Context) {
+	settings, err := s.db.GetMentionSettings(ctx)
+	if err != nil {
+		slog.Error("Failed to get mention settings", "error", err)
+		return
+	}
+	if !settings.Enabled {
+		return
+	}
+
+	// Mentions are found by row ID, which a PostgreSQL relay database lacks
+	if err := s.scan(ctx); err != nil && !errors.Is(err, db.ErrUnsupportedOnPostgres) {
+		slog.Error("Failed to scan for mentions", "error", err)
+	}
+	s.deliver(ctx)
+}
+
+// scan queues mentions from events stored since the last scan. The first scan
+// only records the current position, so existing events are never reported.
+func (s *MentionService) scan(ctx context.Context) error {
+	cursor, err := s.db.GetMentionCursor(ctx)
+	if err != nil {
+		return err
+	}
+	if cursor == 0 {
+		latest, err := s.db.GetLatestEventRowID(ctx)
+		if err != nil {
+			return err
+		}
+		return s.db.SetMentionCursor(ctx, latest)
+	}
+
+	recipients, err := s.recipients(ctx)
+	if err != nil {
+		return err
+	}
+	blocked := make(map[string]bool)
+	if blacklist, err := s.db.GetBlacklist(ctx); err == nil {
+		for _, entry := range blacklist {
+			blocked[entry.Pubkey] = true
+		}
+	}
+
+	last := cursor
+	queued := 0
+	err = s.db.StreamEventsAfter(ctx, cursor, MentionKinds, func(rowID int64, event db.ExportEvent) error {
+		last = rowID
+		if blocked[event.Pubkey] {
+			return nil
+		}
+		for _, recipient := range mentionedRecipients(event, recipients) {
+			added, err := s.db.QueueMention(ctx, db.QueuedMention{
+				Recipient: recipient,
+				EventID:   event.ID,
+				Author:    event.Pubkey,
+				Kind:      event.Kind,
+				CreatedAt: time.Unix(event.CreatedAt, 0),
+			})
+			if err != nil {
+				return err
+			}
+			if added {
+				queued++
+			}
+		}
+		return nil
+	})
+
+	// Keep the progress made even if streaming stopped early
+	if last > cursor {
+		if err := s.db.SetMentionCursor(ctx, last); err != nil {
+			return err
+		}
+	}
+	if queued > 0 {
+		slog.Debug("Queued mentions", "count", queued)
+	}
+	return err
+}
+
+// recipients returns the whitelisted members with enabled subscriptions.
+func (s *MentionService) recipients(ctx context.Context) (map[string]bool, error) {
+	subs, err := s.db.GetMentionSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.db.GetWhitelistMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	whitelisted := make(map[string]bool, len(members))
+	for _, m := range members {
+		whitelisted[m.Pubkey] = true
+	}
+
+	recipients := make(map[string]bool)
+	for _, sub := range subs {
+		if sub.Enabled && whitelisted[sub.Pubkey] {
+			recipients[sub.Pubkey] = true
+		}
+	}
+	return recipients, nil
+}
+
+// mentionedRecipients returns the recipients tagged in the event, once each,
+// leaving out the event's author.
+func mentionedRecipients(event db.ExportEvent, recipients map[string]bool) []string {
+	var mentioned []string
+	seen := make(map[string]bool)
+	for _, tag := range event.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		pk := strings.ToLower(tag[1])
+		if !recipients[pk] || seen[pk] || pk == event.Pubkey {
+			continue
+		}
+		seen[pk] = true
+		mentioned = append(mentioned, pk)
+	}
+	return mentioned
+}
+
+// deliver sends a summary to each member whose notification is due.
+func (s *MentionService) deliver(ctx context.Context) {
+	subs, err := s.db.GetMentionSubscriptions(ctx)
+	if err != nil {
+		slog.Error("Failed to get mention subscriptions", "error", err)
+		return
+	}
+
+	now := s.now()
+	for _, sub := range subs {
+		if !sub.Enabled || sub.Pending == 0 || !notificationDue(&sub, now) {
+			continue
+		}
+		if err := s.notify(ctx, &sub, now); err != nil {
+			slog.Warn("Failed to send mention notification", "pubkey", sub.Pubkey, "channel", sub.Channel, "error", err)
+		}
+	}
+}
+
+// notify sends one member their queued mentions and clears them.
+func (s *MentionService) notify(ctx context.Context, sub *db.MentionSubscription, now time.Time) error {
+	mentions, err := s.db.GetQueuedMentions(ctx, sub.Pubkey)
+	if err != nil || len(mentions) == 0 {
+		return err
+	}
+
+	subject, body := summarizeMentions(mentions, s.authorNames(ctx), relayName(s.configMgr, "your home relay"))
+
+	switch sub.Channel {
+	case NotifyChannelEmail:
+		err = s.notifier.SendEmail(ctx, sub.Email, subject, body)
+	default:
+		err = s.notifier.SendDM(ctx, sub.Pubkey, body)
+	}
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(mentions))
+	for i, m := range mentions {
+		ids[i] = m.EventID
+	}
+	if err := s.db.DeleteQueuedMentions(ctx, sub.Pubkey, ids); err != nil {
+		return err
+	}
+	slog.Info("Sent mention notification", "pubkey", sub.Pubkey, "channel", sub.Channel, "mentions", len(mentions))
+	return s.db.SetMentionNotified(ctx, sub.Pubkey, now)
+}
+
+// authorNames maps whitelisted pubkeys to their nicknames.
+func (s *MentionService) authorNames(ctx context.Context) map[string]string {
+	names := make(map[string]string)
+	members, _ := s.db.GetWhitelistMeta(ctx)
+	for _, m := range members {
+		if m.Nickname != "" {
+			names[m.Pubkey] = m.Nickname
+		}
+	}
+	return names
+}
+
+// notificationDue reports whether a member may be notified at now: outside
+// their quiet hours and at least one batch window after the last notification.
+func notificationDue(sub *db.MentionSubscription, now time.Time) bool {
+	if inQuietHours(now, sub.QuietStart, sub.QuietEnd, sub.Timezone) {
+		return false
+	}
+	if sub.LastNotifiedAt != nil && now.Sub(*sub.LastNotifiedAt) < time.Duration(sub.BatchMinutes)*time.Minute {
+		return false
+	}
+	return true
+}
+
+// inQuietHours reports whether now falls between start and end (HH:MM) in
+// the given timezone. The range may wrap past midnight, e.g. 22:00-07:00.
+// Missing or invalid times mean no quiet hours.
+func inQuietHours(now time.Time, start, end, timezone string) bool {
+	startMin, ok1 := ParseClock(start)
+	endMin, ok2 := ParseClock(end)
+	if !ok1 || !ok2 || startMin == endMin {
+		return false
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	if startMin < endMin {
+		return minute >= startMin && minute < endMin
+	}
+	return minute >= startMin || minute < endMin
+}
+
+// ParseClock parses an HH:MM time of day into minutes after midnight.
+func ParseClock(value string) (int, bool) {
+	hours, minutes, found := strings.Cut(value, ":")
+	if !found || len(hours) != 2 || len(minutes) != 2 {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(hours)
+	m, err2 := strconv.Atoi(minutes)
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+// summarizeMentions builds the subject and body of a mention summary: counts
+// by kind, who mentioned the member, and links to the newest mentions.
+func summarizeMentions(mentions []db.QueuedMention, names map[string]string, relayName string) (string, string) {
+	counts := make(map[int]int)
+	var authors []string
+	seenAuthor := make(map[string]bool)
+	for _, m := range mentions {
+		counts[m.Kind]++
+		if !seenAuthor[m.Author] {
+			seenAuthor[m.Author] = true
+			authors = append(authors, m.Author)
+		}
+	}
+
+	subject := fmt.Sprintf("%s on %s", plural(len(mentions), "new mention", "new mentions"), relayName)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "You have %s on %s:\n", plural(len(mentions), "new mention", "new mentions"), relayName)
+	for _, line := range []struct {
+		kind             int
+		singular, plural string
+	}{
+		{1, "note or reply", "notes and replies"},
+		{6, "repost", "reposts"},
+		{7, "reaction", "reactions"},
+		{9735, "zap", "zaps"},
+	} {
+		if counts[line.kind] > 0 {
+			fmt.Fprintf(&body, "- %s\n", plural(counts[line.kind], line.singular, line.plural))
+		}
+	}
+
+	fmt.Fprintf(&body, "\nFrom %s.\n", authorList(authors, names))
+
+	var links []string
+	for i := len(mentions) - 1; i >= 0 && len(links) < maxSummaryLinks; i-- {
+		if mentions[i].Kind != 1 {
+			continue
+		}
+		if link := noteLink(mentions[i].EventID); link != "" {
+			links = append(links, link)
+		}
+	}
+	if len(links) > 0 {
+		body.WriteString("\nLatest:\n")
+		for _, link := range links {
+			fmt.Fprintf(&body, "%s\n", link)
+		}
+	}
+
+	return subject, body.String()
+}
+
+// authorList names up to three authors and counts the rest.
+func authorList(authors []string, names map[string]string) string {
+	const shown = 3
+	var labels []string
+	for i, pk := range authors {
+		if i == shown {
+			break
+		}
+		labels = append(labels, authorLabel(pk, names))
+	}
+	if extra := len(authors) - len(labels); extra > 0 {
+		labels = append(labels, plural(extra, "other", "others"))
+	}
+
+	switch len(labels) {
+	case 1:
+		return labels[0]
+	case 2:
+		return labels[0] + " and " + labels[1]
+	default:
+		return strings.Join(labels[:len(labels)-1], ", ") + " and " + labels[len(labels)-1]
+	}
+}
+
+// authorLabel returns the member nickname for a pubkey, or a shortened npub.
+func authorLabel(pubkey string, names map[string]string) string {
+	if name := names[pubkey]; name != "" {
+		return name
+	}
+	npub, err := nostr.EncodeNpub(pubkey)
+	if err != nil {
+		return pubkey
+	}
+	return npub[:12] + "…"
+}
+
+// noteLink returns a nostr: URI for an event ID, or "" if it is invalid.
+func noteLink(eventID string) string {
+	data, err := hex.DecodeString(eventID)
+	if err != nil || len(data) != 32 {
+		return ""
+	}
+	note, err := nostr.EncodeBech32("note", data)
+	if err != nil {
+		return ""
+	}
+	return "nostr:" + note
+}
+
+// plural formats a count with the singular or plural noun.
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
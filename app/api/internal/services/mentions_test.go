@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func insertMentionTestEvent(t *testing.T, relayDB *sql.DB, n byte, author string, kind int, tags [][]string) {
+	t.Helper()
+	id := strings.Repeat(hex.EncodeToString([]byte{n}), 32)
+	content, _ := json.Marshal(map[string]interface{}{
+		"id": id, "pubkey": author, "created_at": 1700000000 + int64(n), "kind": kind,
+		"tags": tags, "content": "hello", "sig": "",
+	})
+	hash, _ := hex.DecodeString(id)
+	authorBytes, _ := hex.DecodeString(author)
+	if _, err := relayDB.Exec(`INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content) VALUES (?, ?, ?, ?, ?, 0, ?)`,
+		hash, time.Now().Unix(), 1700000000+int64(n), authorBytes, kind, string(content)); err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+}
+
+func TestMentionService_ScanAndDeliver(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	member := strings.Repeat("aa", 32)
+	optedOut := strings.Repeat("bb", 32)
+	author := strings.Repeat("cc", 32)
+	spammer := strings.Repeat("dd", 32)
+
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: member, Nickname: "alice"})
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: optedOut})
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: author, Nickname: "carol"})
+	database.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: spammer})
+	database.SetMentionSettings(ctx, &db.MentionSettings{Enabled: true})
+	database.SaveMentionSubscription(ctx, &db.MentionSubscription{
+		Pubkey: member, Enabled: true, Channel: NotifyChannelEmail, Email: "alice@example.com",
+		Timezone: "UTC", BatchMinutes: 60,
+	})
+
+	// Existing events are not reported
+	insertMentionTestEvent(t, relayDB, 1, author, 1, [][]string{{"p", member}})
+
+	var sent []string
+	notifier := NewNotifier(database, nil)
+	notifier.ConfigureSMTP(SMTPConfig{Host: "mail.example.com", From: "relay@example.com"})
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	svc := NewMentionService(database, notifier, nil)
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	svc.process(ctx)
+	if len(sent) != 0 {
+		t.Fatalf("expected no notification for existing events, got %d", len(sent))
+	}
+
+	insertMentionTestEvent(t, relayDB, 2, author, 1, [][]string{{"p", member}, {"p", member}})
+	insertMentionTestEvent(t, relayDB, 3, author, 7, [][]string{{"p", member}})
+	insertMentionTestEvent(t, relayDB, 4, member, 1, [][]string{{"p", member}})   // self-mention
+	insertMentionTestEvent(t, relayDB, 5, spammer, 1, [][]string{{"p", member}})  // blacklisted
+	insertMentionTestEvent(t, relayDB, 6, author, 1, [][]string{{"p", optedOut}}) // not subscribed
+	insertMentionTestEvent(t, relayDB, 7, author, 30023, [][]string{{"p", member}})
+
+	svc.process(ctx)
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(sent))
+	}
+	if !strings.Contains(sent[0], "2 new mentions") || !strings.Contains(sent[0], "1 reaction") ||
+		!strings.Contains(sent[0], "From carol.") || !strings.Contains(sent[0], "To: alice@example.com") {
+		t.Errorf("unexpected email:\n%s", sent[0])
+	}
+	if sub, _ := database.GetMentionSubscription(ctx, member); sub.Pending != 0 || sub.LastNotifiedAt == nil {
+		t.Errorf("expected queue cleared and notification recorded, got %+v", sub)
+	}
+
+	t.Run("batched", func(t *testing.T) {
+		insertMentionTestEvent(t, relayDB, 8, author, 1, [][]string{{"p", member}})
+		now = now.Add(30 * time.Minute)
+		svc.process(ctx)
+		if len(sent) != 1 {
+			t.Fatalf("expected the mention to wait for the batch window, got %d notifications", len(sent))
+		}
+
+		now = now.Add(31 * time.Minute)
+		svc.process(ctx)
+		if len(sent) != 2 || !strings.Contains(sent[1], "1 new mention") {
+			t.Fatalf("expected a second notification after the batch window, got %d", len(sent))
+		}
+	})
+
+	t.Run("quiet_hours", func(t *testing.T) {
+		database.SaveMentionSubscription(ctx, &db.MentionSubscription{
+			Pubkey: member, Enabled: true, Channel: NotifyChannelEmail, Email: "alice@example.com",
+			QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC", BatchMinutes: 5,
+		})
+		insertMentionTestEvent(t, relayDB, 9, author, 1, [][]string{{"p", member}})
+
+		now = time.Date(2024, 1, 15, 23, 0, 0, 0, time.UTC)
+		svc.process(ctx)
+		if len(sent) != 2 {
+			t.Fatal("expected no notification during quiet hours")
+		}
+
+		now = time.Date(2024, 1, 16, 7, 0, 0, 0, time.UTC)
+		svc.process(ctx)
+		if len(sent) != 3 {
+			t.Fatal("expected the held notification after quiet hours")
+		}
+	})
+}
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2024, 1, 15, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		start, end string
+		timezone   string
+		want       bool
+	}{
+		{"unset", at(23, 0), "", "", "UTC", false},
+		{"same_day_inside", at(13, 30), "13:00", "14:00", "UTC", true},
+		{"same_day_end_exclusive", at(14, 0), "13:00", "14:00", "UTC", false},
+		{"overnight_late", at(23, 0), "22:00", "07:00", "UTC", true},
+		{"overnight_early", at(6, 59), "22:00", "07:00", "UTC", true},
+		{"overnight_outside", at(12, 0), "22:00", "07:00", "UTC", false},
+		{"timezone", at(3, 0), "22:00", "07:00", "America/New_York", true}, // 22:00 in New York
+		{"invalid", at(23, 0), "10pm", "07:00", "UTC", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inQuietHours(tt.now, tt.start, tt.end, tt.timezone); got != tt.want {
+				t.Errorf("inQuietHours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeMentions(t *testing.T) {
+	alice := strings.Repeat("aa", 32)
+	bob := strings.Repeat("bb", 32)
+	mentions := []db.QueuedMention{
+		{EventID: strings.Repeat("01", 32), Author: alice, Kind: 1},
+		{EventID: strings.Repeat("02", 32), Author: bob, Kind: 1},
+		{EventID: strings.Repeat("03", 32), Author: alice, Kind: 6},
+		{EventID: strings.Repeat("04", 32), Author: alice, Kind: 9735},
+	}
+
+	subject, body := summarizeMentions(mentions, map[string]string{alice: "alice"}, "Home")
+	if subject != "4 new mentions on Home" {
+		t.Errorf("unexpected subject: %q", subject)
+	}
+	for _, want := range []string{"2 notes and replies", "1 repost", "1 zap", "From alice and npub1", "nostr:note1"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected body to contain %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "reaction") {
+		t.Errorf("expected no reaction line:\n%s", body)
+	}
+}
+
+func TestBuildEmail_StripsHeaderLineBreaks(t *testing.T) {
+	msg := string(buildEmail("relay@example.com", "a@example.com\r\nBcc: x@example.com", "Hi", "line one\nline two", time.Unix(0, 0)))
+	if strings.Contains(msg, "\r\nBcc:") {
+		t.Errorf("header injection not prevented:\n%s", msg)
+	}
+	if !strings.Contains(msg, "line one\r\nline two") {
+		t.Errorf("expected CRLF line endings in body:\n%s", msg)
+	}
+}
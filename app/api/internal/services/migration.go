@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// JobTypeMigration is the job type of relay migrations.
+const JobTypeMigration = "migration"
+
+// Defaults for how a migration walks back through a pubkey's events.
+const (
+	// migrationPageSize is how many events are requested per page. Relays
+	// often cap results lower, which paging copes with.
+	migrationPageSize = 500
+
+	// defaultMigrationWindowDays is the span of time fetched at once. Each
+	// window is paged through on its own, so relays that cap results per
+	// query or order them loosely still hand over everything.
+	defaultMigrationWindowDays = 30
+)
+
+var (
+	// ErrMigrationSyncRunning is returned when starting a migration while a
+	// sync is writing to the relay.
+	ErrMigrationSyncRunning = errors.New("a sync job is running")
+
+	// ErrInvalidMigration is returned for a migration request without
+	// pubkeys or with one that is not valid.
+	ErrInvalidMigration = errors.New("invalid migration")
+
+	// ErrInvalidAuthKey is returned when the NIP-42 key is not a valid nsec
+	// or hex secret key.
+	ErrInvalidAuthKey = errors.New("invalid auth key")
+)
+
+// MigrationService moves a user's events from a relay they are leaving,
+// such as a hosted relay, to this one. It is a sync from a single relay
+// that fetches everything for the pubkeys, then checks every event made it
+// and reports per pubkey. Each migration runs as a job of type "migration"
+// whose result is the MigrationReport.
+type MigrationService struct {
+	db       *db.DB
+	jobs     *JobService
+	sync     *SyncService
+	pressure *StoragePressureService
+}
+
+// NewMigrationService creates a new migration service.
+func NewMigrationService(database *db.DB) *MigrationService {
+	return &MigrationService{db: database, jobs: NewJobService(database)}
+}
+
+// MigrationRequest contains parameters for starting a migration.
+type MigrationRequest struct {
+	SourceRelay    string   `json:"source_relay"`
+	Pubkeys        []string `json:"pubkeys"`
+	EventKinds     []int    `json:"event_kinds,omitempty"`
+	SinceTimestamp *int64   `json:"since_timestamp,omitempty"`
+
+	// WindowDays is the span of time fetched at once, 30 days by default.
+	WindowDays int `json:"window_days,omitempty"`
+
+	// AuthKey is the nsec or hex secret key to answer the source relay's
+	// NIP-42 challenge with, for relays that only serve their members. It
+	// is kept in memory for the migration and never saved.
+	AuthKey string `json:"auth_key,omitempty"`
+
+	// SkipVerification stores events without checking their IDs and
+	// signatures.
+	SkipVerification bool `json:"skip_verification,omitempty"`
+}
+
+// migrationParams is a migration request as saved in the job's params. The
+// auth key is left out.
+type migrationParams struct {
+	MigrationRequest
+	Authenticated bool `json:"authenticated"`
+}
+
+// MigrationCounts are the event counts of a migration.
+type MigrationCounts struct {
+	// Fetched is how many distinct events the source relay returned.
+	Fetched int64 `json:"fetched"`
+	// Stored is how many were new to this relay.
+	Stored int64 `json:"stored"`
+	// AlreadyPresent is how many this relay already had.
+	AlreadyPresent int64 `json:"already_present"`
+	// Skipped is how many this relay does not keep: excluded kinds,
+	// deleted events, replaced versions, ephemeral and expired events.
+	Skipped int64 `json:"skipped"`
+	// Rejected is how many failed verification.
+	Rejected int64 `json:"rejected"`
+	// Failed is how many could not be written.
+	Failed int64 `json:"failed"`
+	// Missing is how many stored or already present events were not
+	// found when the migration checked afterwards.
+	Missing int64 `json:"missing"`
+}
+
+func (c *MigrationCounts) add(o MigrationCounts) {
+	c.Fetched += o.Fetched
+	c.Stored += o.Stored
+	c.AlreadyPresent += o.AlreadyPresent
+	c.Skipped += o.Skipped
+	c.Rejected += o.Rejected
+	c.Failed += o.Failed
+	c.Missing += o.Missing
+}
+
+// MigrationPubkeyReport is the outcome of migrating one pubkey.
+type MigrationPubkeyReport struct {
+	Pubkey string `json:"pubkey"`
+	MigrationCounts
+	// Windows is how many windows of time were fetched.
+	Windows int `json:"windows"`
+	// LocalEvents is how many matching events this relay has for the
+	// pubkey afterwards.
+	LocalEvents int64 `json:"local_events"`
+	// Verified is set when every event the relay keeps was found.
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MigrationReport is the result of a migration job.
+type MigrationReport struct {
+	SourceRelay   string                   `json:"source_relay"`
+	Authenticated bool                     `json:"authenticated"`
+	StartedAt     time.Time                `json:"started_at"`
+	FinishedAt    time.Time                `json:"finished_at"`
+	Complete      bool                     `json:"complete"`
+	Totals        MigrationCounts          `json:"totals"`
+	Pubkeys       []*MigrationPubkeyReport `json:"pubkeys"`
+}
+
+// StartMigration validates a migration request and starts it as a job.
+func (s *MigrationService) StartMigration(ctx context.Context, req MigrationRequest) (*db.Job, error) {
+	if !strings.HasPrefix(req.SourceRelay, "ws://") && !strings.HasPrefix(req.SourceRelay, "wss://") {
+		return nil, fmt.Errorf("%w: must start with ws:// or wss://", nostr.ErrInvalidURL)
+	}
+	if len(req.Pubkeys) == 0 {
+		return nil, fmt.Errorf("%w: at least one pubkey is required", ErrInvalidMigration)
+	}
+	pubkeys := make([]string, 0, len(req.Pubkeys))
+	seen := make(map[string]bool, len(req.Pubkeys))
+	for _, input := range req.Pubkeys {
+		pubkey, _, err := nostr.ValidatePubkey(input)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid pubkey %q: %v", ErrInvalidMigration, input, err)
+		}
+		if !seen[pubkey] {
+			seen[pubkey] = true
+			pubkeys = append(pubkeys, pubkey)
+		}
+	}
+	req.Pubkeys = pubkeys
+
+	authKey, err := migrationAuthKey(req.AuthKey)
+	if err != nil {
+		return nil, err
+	}
+	if req.WindowDays <= 0 {
+		req.WindowDays = defaultMigrationWindowDays
+	}
+
+	if s.sync != nil && s.sync.IsRunning() {
+		return nil, ErrMigrationSyncRunning
+	}
+	if s.pressure.IngestPaused() {
+		return nil, ErrStoragePressure
+	}
+
+	params := migrationParams{MigrationRequest: req, Authenticated: authKey != ""}
+	params.AuthKey = ""
+	return s.jobs.Run(JobTypeMigration, params, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		report, err := s.runMigration(ctx, req, authKey, run)
+		if report == nil {
+			return nil, err
+		}
+		return report, err
+	})
+}
+
+// migrationAuthKey returns the hex secret key of an nsec or hex key.
+func migrationAuthKey(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", nil
+	}
+	secret := input
+	if strings.HasPrefix(input, "nsec") {
+		decoded, err := nostr.DecodeNsec(input)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidAuthKey, err)
+		}
+		secret = decoded
+	}
+	if _, err := nostr.GetPublicKey(secret); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAuthKey, err)
+	}
+	return secret, nil
+}
+
+// runMigration fetches each pubkey's events from the source relay, checks
+// they were stored and returns the report. The connection is reopened for
+// the next pubkey if the relay drops it.
+func (s *MigrationService) runMigration(ctx context.Context, req MigrationRequest, authKey string, run *JobRun) (*MigrationReport, error) {
+	report := &MigrationReport{
+		SourceRelay:   req.SourceRelay,
+		Authenticated: authKey != "",
+		StartedAt:     time.Now(),
+		Pubkeys:       make([]*MigrationPubkeyReport, 0, len(req.Pubkeys)),
+	}
+
+	writer, err := s.db.NewRelayWriter()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open relay writer: %w", err)
+	}
+	defer writer.Close()
+
+	connect := func() (*nostr.Client, error) {
+		client := nostr.NewClient(req.SourceRelay)
+		if authKey != "" {
+			client.SetAuthKey(authKey)
+		}
+		if err := client.Connect(ctx); err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", req.SourceRelay, err)
+		}
+		return client, nil
+	}
+	client, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { client.Close() }()
+
+	total := int64(len(req.Pubkeys))
+	for i, pubkey := range req.Pubkeys {
+		if ctx.Err() != nil {
+			break
+		}
+		if !client.IsConnected() {
+			client.Close()
+			if client, err = connect(); err != nil {
+				return nil, err
+			}
+		}
+
+		run.Progress(int64(i), total, fmt.Sprintf("Migrating %s…", pubkey[:16]))
+		pubkeyReport := s.migratePubkey(ctx, client, pubkey, req, writer, func(counts MigrationCounts) {
+			run.Progress(int64(i), total, fmt.Sprintf("Pubkey %d of %d: %d events fetched, %d stored", i+1, total, counts.Fetched, counts.Stored))
+		})
+		report.Pubkeys = append(report.Pubkeys, pubkeyReport)
+		report.Totals.add(pubkeyReport.MigrationCounts)
+	}
+
+	report.FinishedAt = time.Now()
+	report.Complete = ctx.Err() == nil && len(report.Pubkeys) == len(req.Pubkeys)
+	for _, p := range report.Pubkeys {
+		if !p.Verified {
+			report.Complete = false
+		}
+	}
+	run.Progress(int64(len(report.Pubkeys)), total, fmt.Sprintf("%d events fetched, %d stored, %d missing",
+		report.Totals.Fetched, report.Totals.Stored, report.Totals.Missing))
+
+	slog.Info("Migration finished", "relay", req.SourceRelay, "pubkeys", len(report.Pubkeys),
+		"fetched", report.Totals.Fetched, "stored", report.Totals.Stored, "missing", report.Totals.Missing, "complete", report.Complete)
+	return report, ctx.Err()
+}
+
+// migratePubkey fetches one pubkey's events a window of time at a time,
+// newest first, then checks the events were stored. Within a window events
+// are paged by their created_at; below a window a single event is asked for
+// to find where the next one starts, so years without events cost one
+// request.
+func (s *MigrationService) migratePubkey(ctx context.Context, client *nostr.Client, pubkey string, req MigrationRequest, writer *db.RelayWriter, onProgress func(MigrationCounts)) *MigrationPubkeyReport {
+	report := &MigrationPubkeyReport{Pubkey: pubkey}
+	window := int64(req.WindowDays) * 24 * 60 * 60
+	var since int64
+	if req.SinceTimestamp != nil {
+		since = *req.SinceTimestamp
+	}
+
+	// Events this relay should have once stored
+	var expected []string
+	seen := make(map[string]bool)
+
+	store := func(event *nostr.SyncEvent) error {
+		if seen[event.ID] {
+			return nil
+		}
+		seen[event.ID] = true
+		report.Fetched++
+
+		if !req.SkipVerification {
+			if err := event.Verify(); err != nil {
+				report.Rejected++
+				return nil
+			}
+		}
+
+		stored, err := writer.StoreEvent(ctx, &db.Event{
+			ID:        event.ID,
+			Pubkey:    event.Pubkey,
+			CreatedAt: time.Unix(event.CreatedAt, 0),
+			Kind:      event.Kind,
+			Tags:      event.Tags,
+			Content:   event.Content,
+			Sig:       event.Sig,
+		})
+		switch {
+		case errors.Is(err, db.ErrKindExcluded), errors.Is(err, db.ErrTombstoned), errors.Is(err, db.ErrSuperseded),
+			errors.Is(err, db.ErrEphemeral), errors.Is(err, db.ErrExpired):
+			report.Skipped++
+		case err != nil:
+			slog.Warn("Migration: failed to store event", "event_id", event.ID, "error", err)
+			report.Failed++
+		case stored.Inserted:
+			report.Stored++
+			expected = append(expected, event.ID)
+		default:
+			report.AlreadyPresent++
+			expected = append(expected, event.ID)
+		}
+
+		if report.Fetched%100 == 0 {
+			onProgress(report.MigrationCounts)
+		}
+		return nil
+	}
+
+	filter := func(windowStart, until int64, limit int) nostr.Filter {
+		return nostr.Filter{
+			Authors: []string{pubkey},
+			Kinds:   req.EventKinds,
+			Since:   &windowStart,
+			Until:   &until,
+			Limit:   limit,
+		}
+	}
+
+	until := time.Now().Unix()
+	for until >= since && ctx.Err() == nil {
+		windowStart := max(until-window+1, since)
+		report.Windows++
+
+		// Page through the window. The next page starts at the oldest event
+		// seen; events sharing that second are fetched again and ignored.
+		pageUntil := until
+		for {
+			var oldest *int64
+			err := client.Subscribe(ctx, filter(windowStart, pageUntil, migrationPageSize), func(event *nostr.SyncEvent) error {
+				if oldest == nil || event.CreatedAt < *oldest {
+					createdAt := event.CreatedAt
+					oldest = &createdAt
+				}
+				return store(event)
+			})
+			if err != nil {
+				report.Error = err.Error()
+				return s.finishPubkey(ctx, report, expected, req)
+			}
+			if oldest == nil || *oldest >= pageUntil {
+				break
+			}
+			pageUntil = *oldest
+		}
+
+		// Find the newest event below the window
+		if windowStart <= since {
+			break
+		}
+		var next *int64
+		err := client.Subscribe(ctx, filter(since, windowStart-1, 1), func(event *nostr.SyncEvent) error {
+			if next == nil || event.CreatedAt > *next {
+				createdAt := event.CreatedAt
+				next = &createdAt
+			}
+			return nil
+		})
+		if err != nil {
+			report.Error = err.Error()
+			return s.finishPubkey(ctx, report, expected, req)
+		}
+		if next == nil {
+			break
+		}
+		until = min(*next, windowStart-1)
+	}
+
+	return s.finishPubkey(ctx, report, expected, req)
+}
+
+// finishPubkey checks the events that should be stored are, and counts the
+// pubkey's events on this relay.
+func (s *MigrationService) finishPubkey(ctx context.Context, report *MigrationPubkeyReport, expected []string, req MigrationRequest) *MigrationPubkeyReport {
+	// The checks still run for a cancelled migration, to report what made it
+	ctx = context.WithoutCancel(ctx)
+
+	existing, err := s.db.ExistingEventIDs(ctx, expected)
+	if err != nil {
+		if report.Error == "" {
+			report.Error = err.Error()
+		}
+		return report
+	}
+	report.Missing = int64(len(expected) - len(existing))
+
+	filter := db.EventFilter{Authors: []string{report.Pubkey}, Kinds: req.EventKinds}
+	if req.SinceTimestamp != nil {
+		filter.Since = time.Unix(*req.SinceTimestamp, 0)
+	}
+	if report.LocalEvents, err = s.db.CountEvents(ctx, filter); err != nil && report.Error == "" {
+		report.Error = err.Error()
+	}
+
+	report.Verified = report.Error == "" && report.Missing == 0 && report.Failed == 0
+	return report
+}
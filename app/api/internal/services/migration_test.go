@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// fakeSourceRelay is a relay that answers REQs by author, since, until and
+// limit, newest first, and can require NIP-42 authentication.
+type fakeSourceRelay struct {
+	mu          sync.Mutex
+	events      []*nostr.SyncEvent
+	requireAuth bool
+	authedAs    string
+	reqs        int
+}
+
+func newFakeSourceRelay(t *testing.T, requireAuth bool) (*fakeSourceRelay, string) {
+	t.Helper()
+	relay := &fakeSourceRelay{requireAuth: requireAuth}
+	server := httptest.NewServer(http.HandlerFunc(relay.serve))
+	t.Cleanup(server.Close)
+	return relay, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func (f *fakeSourceRelay) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := nostr.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	const challenge = "challenge-123"
+	authed := false
+	if f.requireAuth {
+		conn.WriteJSON([]interface{}{"AUTH", challenge})
+	}
+
+	for {
+		message, err := conn.ReadMessage(5 * time.Second)
+		if err != nil {
+			return
+		}
+		var raw []json.RawMessage
+		if json.Unmarshal(message, &raw) != nil || len(raw) < 2 {
+			continue
+		}
+		var msgType, subID string
+		json.Unmarshal(raw[0], &msgType)
+
+		switch msgType {
+		case "AUTH":
+			var event nostr.SyncEvent
+			json.Unmarshal(raw[1], &event)
+			ok := event.Verify() == nil && event.Kind == nostr.KindClientAuth
+			hasChallenge := false
+			for _, tag := range event.Tags {
+				if len(tag) >= 2 && tag[0] == "challenge" && tag[1] == challenge {
+					hasChallenge = true
+				}
+			}
+			authed = ok && hasChallenge
+			if authed {
+				f.mu.Lock()
+				f.authedAs = event.Pubkey
+				f.mu.Unlock()
+			}
+			conn.WriteJSON([]interface{}{"OK", event.ID, authed, ""})
+		case "REQ":
+			json.Unmarshal(raw[1], &subID)
+			if f.requireAuth && !authed {
+				conn.WriteJSON([]interface{}{"CLOSED", subID, "auth-required: members only"})
+				continue
+			}
+			var filter nostr.Filter
+			json.Unmarshal(raw[2], &filter)
+			for _, event := range f.match(filter) {
+				conn.WriteJSON([]interface{}{"EVENT", subID, event})
+			}
+			conn.WriteJSON([]interface{}{"EOSE", subID})
+		}
+	}
+}
+
+// match returns the events matching a filter, newest first, up to its
+// limit, capped at 50 like many public relays.
+func (f *fakeSourceRelay) match(filter nostr.Filter) []*nostr.SyncEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reqs++
+
+	var matched []*nostr.SyncEvent
+	for _, event := range f.events {
+		if len(filter.Authors) > 0 && filter.Authors[0] != event.Pubkey {
+			continue
+		}
+		if filter.Since != nil && event.CreatedAt < *filter.Since {
+			continue
+		}
+		if filter.Until != nil && event.CreatedAt > *filter.Until {
+			continue
+		}
+		matched = append(matched, event)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].CreatedAt > matched[j].CreatedAt })
+	limit := min(filter.Limit, 50)
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched
+}
+
+func TestMigrationService_Migrate(t *testing.T) {
+	ctx := context.Background()
+	secret, _ := nostr.GenerateSecretKey()
+	pubkey, _ := nostr.GetPublicKey(secret)
+
+	fake, url := newFakeSourceRelay(t, true)
+	now := time.Now().Unix()
+	sign := func(createdAt int64, content string) *nostr.SyncEvent {
+		event := &nostr.SyncEvent{Pubkey: pubkey, CreatedAt: createdAt, Kind: 1, Tags: [][]string{}, Content: content}
+		if err := event.Sign(secret); err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		return event
+	}
+	// 120 recent notes, more than the relay returns at once, and a few
+	// from years ago with nothing in between
+	for i := 0; i < 120; i++ {
+		fake.events = append(fake.events, sign(now-int64(i*60), "recent"))
+	}
+	for i := 0; i < 3; i++ {
+		fake.events = append(fake.events, sign(now-int64(5*365*24*3600+i), "old"))
+	}
+	tampered := sign(now-7200*24, "tampered")
+	tampered.Content = "changed after signing"
+	fake.events = append(fake.events, tampered)
+
+	database, _ := setupTestDBWithRelay(t)
+	svc := NewMigrationService(database)
+
+	if _, err := svc.StartMigration(ctx, MigrationRequest{SourceRelay: "https://example.com", Pubkeys: []string{pubkey}}); !errors.Is(err, nostr.ErrInvalidURL) {
+		t.Errorf("expected ErrInvalidURL for an http URL, got %v", err)
+	}
+	if _, err := svc.StartMigration(ctx, MigrationRequest{SourceRelay: url, Pubkeys: []string{"nope"}}); !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("expected ErrInvalidMigration for a bad pubkey, got %v", err)
+	}
+	if _, err := svc.StartMigration(ctx, MigrationRequest{SourceRelay: url, Pubkeys: []string{pubkey}, AuthKey: "nsec1bad"}); !errors.Is(err, ErrInvalidAuthKey) {
+		t.Errorf("expected ErrInvalidAuthKey, got %v", err)
+	}
+
+	// Without a key the relay refuses to serve the events
+	run, err := svc.StartMigration(ctx, MigrationRequest{SourceRelay: url, Pubkeys: []string{pubkey}})
+	if err != nil {
+		t.Fatalf("StartMigration failed: %v", err)
+	}
+	job := waitJob(t, svc.jobs, run.ID)
+	var report MigrationReport
+	json.Unmarshal(job.Result, &report)
+	if report.Complete || len(report.Pubkeys) != 1 || !strings.Contains(report.Pubkeys[0].Error, "auth-required") {
+		t.Fatalf("expected an incomplete report with the auth error, got %+v", report.Pubkeys)
+	}
+
+	run, err = svc.StartMigration(ctx, MigrationRequest{SourceRelay: url, Pubkeys: []string{pubkey}, AuthKey: secret})
+	if err != nil {
+		t.Fatalf("StartMigration failed: %v", err)
+	}
+	job = waitJob(t, svc.jobs, run.ID)
+	if strings.Contains(string(job.Params), secret) {
+		t.Error("expected the auth key not to be saved in the job params")
+	}
+	report = MigrationReport{}
+	if err := json.Unmarshal(job.Result, &report); err != nil {
+		t.Fatalf("failed to read report: %v (job %+v)", err, job)
+	}
+	if fake.authedAs != pubkey {
+		t.Errorf("expected the relay to see AUTH from %s, got %q", pubkey, fake.authedAs)
+	}
+
+	got := report.Pubkeys[0]
+	if got.Fetched != 124 || got.Stored != 123 || got.Rejected != 1 || got.Missing != 0 {
+		t.Errorf("expected 124 fetched, 123 stored, 1 rejected, got %+v", got.MigrationCounts)
+	}
+	if !got.Verified || !report.Complete || got.LocalEvents != 123 {
+		t.Errorf("expected a verified migration with 123 local events, got %+v", got)
+	}
+	// The five empty years below the recent window cost one probe, not a
+	// request per window
+	if fake.reqs > 20 {
+		t.Errorf("expected gaps to be skipped, got %d requests", fake.reqs)
+	}
+
+	// Running again finds everything already present
+	run, _ = svc.StartMigration(ctx, MigrationRequest{SourceRelay: url, Pubkeys: []string{pubkey}, AuthKey: secret})
+	job = waitJob(t, svc.jobs, run.ID)
+	report = MigrationReport{}
+	json.Unmarshal(job.Result, &report)
+	if report.Totals.AlreadyPresent != 123 || report.Totals.Stored != 0 || !report.Complete {
+		t.Errorf("expected 123 already present on a second run, got %+v", report.Totals)
+	}
+}
@@ -0,0 +1,541 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// Moderation rule types.
+const (
+	RuleDuplicateContent = "duplicate_content"
+	RuleLinkDensity      = "link_density"
+	RuleBannedWords      = "banned_words"
+	RuleBurstRate        = "burst_rate"
+)
+
+// Moderation actions, from least to most severe.
+const (
+	ModerationFlag      = "flag"
+	ModerationDelete    = "delete"
+	ModerationBlacklist = "blacklist"
+)
+
+const (
+	// duplicateMinLength is the shortest content duplicate_content compares;
+	// short replies such as "+" or "gm" repeat legitimately.
+	duplicateMinLength = 20
+
+	// moderationActionRetention is how long the action log is kept.
+	moderationActionRetention = 90 * 24 * time.Hour
+
+	// blacklistedRule is the rule name recorded for events by authors who
+	// are already blacklisted.
+	blacklistedRule = "blacklisted"
+)
+
+// linkPattern matches URLs in event content.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?|wss?)://\S+`)
+
+// ValidateModerationRule checks a rule's name, type, score and the settings
+// its type needs.
+func ValidateModerationRule(rule *db.ModerationRule) error {
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+	if rule.Score < 1 || rule.Score > 100 {
+		return errors.New("score must be between 1 and 100")
+	}
+
+	cfg := rule.Config
+	switch rule.Type {
+	case RuleDuplicateContent, RuleBurstRate:
+		if cfg.WindowSeconds <= 0 {
+			return errors.New("window_seconds must be positive")
+		}
+		if cfg.WindowSeconds > 7*24*3600 {
+			return errors.New("window_seconds can be at most a week")
+		}
+		if cfg.MaxCount <= 0 {
+			return errors.New("max_count must be positive")
+		}
+	case RuleLinkDensity:
+		if cfg.MaxLinks <= 0 && cfg.MaxLinkRatio <= 0 {
+			return errors.New("max_links or max_link_ratio is required")
+		}
+		if cfg.MaxLinks < 0 || cfg.MaxLinkRatio < 0 || cfg.MaxLinkRatio > 1 {
+			return errors.New("max_links must be positive and max_link_ratio between 0 and 1")
+		}
+	case RuleBannedWords:
+		if len(cfg.Patterns) == 0 {
+			return errors.New("patterns are required")
+		}
+		for _, pattern := range cfg.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown rule type %q", rule.Type)
+	}
+	return nil
+}
+
+// ValidateModerationSettings checks that thresholds are not negative.
+func ValidateModerationSettings(settings *db.ModerationSettings) error {
+	if settings.FlagScore < 0 || settings.DeleteScore < 0 || settings.BlacklistScore < 0 {
+		return errors.New("scores cannot be negative")
+	}
+	return nil
+}
+
+// moderationEngine scores events against the enabled rules. It remembers
+// recent content and posting times for the windowed rules; that memory
+// starts empty when Roostr starts.
+type moderationEngine struct {
+	rules    []compiledRule
+	contents map[string][]int64 // Content hash -> created_at of each copy
+	posts    map[string][]int64 // Pubkey -> created_at of each event
+}
+
+// compiledRule is a rule with its patterns compiled.
+type compiledRule struct {
+	db.ModerationRule
+	patterns []*regexp.Regexp
+	kinds    map[int]bool
+}
+
+func newModerationEngine() *moderationEngine {
+	return &moderationEngine{
+		contents: make(map[string][]int64),
+		posts:    make(map[string][]int64),
+	}
+}
+
+// setRules replaces the rules, keeping the window memory.
+func (e *moderationEngine) setRules(rules []db.ModerationRule) {
+	e.rules = e.rules[:0]
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		c := compiledRule{ModerationRule: rule}
+		for _, pattern := range rule.Config.Patterns {
+			if re, err := regexp.Compile(pattern); err == nil {
+				c.patterns = append(c.patterns, re)
+			}
+		}
+		if len(rule.Config.Kinds) > 0 {
+			c.kinds = make(map[int]bool)
+			for _, kind := range rule.Config.Kinds {
+				c.kinds[kind] = true
+			}
+		}
+		e.rules = append(e.rules, c)
+	}
+}
+
+// score returns the event's score and the names of the rules it matched.
+// Every event is remembered for the windowed rules, matched or not.
+func (e *moderationEngine) score(event db.ExportEvent) (int, []string) {
+	contentKey := ""
+	if normalized := strings.Join(strings.Fields(strings.ToLower(event.Content)), " "); len(normalized) >= duplicateMinLength {
+		sum := sha256.Sum256([]byte(normalized))
+		contentKey = string(sum[:])
+	}
+
+	score := 0
+	var matched []string
+	for _, rule := range e.rules {
+		if rule.kinds != nil && !rule.kinds[event.Kind] {
+			continue
+		}
+		if rule.matches(e, event, contentKey) {
+			score += rule.Score
+			matched = append(matched, rule.Name)
+		}
+	}
+
+	if contentKey != "" {
+		e.contents[contentKey] = append(e.contents[contentKey], event.CreatedAt)
+	}
+	e.posts[event.Pubkey] = append(e.posts[event.Pubkey], event.CreatedAt)
+	return score, matched
+}
+
+// matches reports whether the event breaks the rule. Windowed rules count
+// the events remembered so far, so the event itself is the one over the limit.
+func (r compiledRule) matches(e *moderationEngine, event db.ExportEvent, contentKey string) bool {
+	cfg := r.Config
+	switch r.Type {
+	case RuleDuplicateContent:
+		if contentKey == "" {
+			return false
+		}
+		return countSince(e.contents[contentKey], event.CreatedAt-int64(cfg.WindowSeconds)) >= cfg.MaxCount
+	case RuleBurstRate:
+		return countSince(e.posts[event.Pubkey], event.CreatedAt-int64(cfg.WindowSeconds)) >= cfg.MaxCount
+	case RuleLinkDensity:
+		links := linkPattern.FindAllString(event.Content, -1)
+		if cfg.MaxLinks > 0 && len(links) > cfg.MaxLinks {
+			return true
+		}
+		if cfg.MaxLinkRatio > 0 && len(links) > 0 {
+			linkChars := 0
+			for _, link := range links {
+				linkChars += len(link)
+			}
+			return float64(linkChars)/float64(len(event.Content)) > cfg.MaxLinkRatio
+		}
+	case RuleBannedWords:
+		for _, re := range r.patterns {
+			if re.MatchString(event.Content) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countSince counts the times at or after since.
+func countSince(times []int64, since int64) int {
+	n := 0
+	for _, t := range times {
+		if t >= since {
+			n++
+		}
+	}
+	return n
+}
+
+// forget drops remembered events older than the longest window before now.
+func (e *moderationEngine) forget(now int64) {
+	window := 0
+	for _, rule := range e.rules {
+		if rule.Config.WindowSeconds > window {
+			window = rule.Config.WindowSeconds
+		}
+	}
+	cutoff := now - int64(window)
+	for _, m := range []map[string][]int64{e.contents, e.posts} {
+		for key, times := range m {
+			kept := times[:0]
+			for _, t := range times {
+				if t >= cutoff {
+					kept = append(kept, t)
+				}
+			}
+			if len(kept) == 0 {
+				delete(m, key)
+			} else {
+				m[key] = kept
+			}
+		}
+	}
+}
+
+// decideModeration returns the action a score calls for, or "" for none.
+func decideModeration(settings *db.ModerationSettings, score int) string {
+	switch {
+	case score <= 0:
+		return ""
+	case settings.BlacklistScore > 0 && score >= settings.BlacklistScore:
+		return ModerationBlacklist
+	case settings.DeleteScore > 0 && score >= settings.DeleteScore:
+		return ModerationDelete
+	case settings.FlagScore > 0 && score >= settings.FlagScore:
+		return ModerationFlag
+	}
+	return ""
+}
+
+// ModerationService scores events as they reach the relay database, from
+// clients or from syncs, and flags, deletes or blacklists according to the
+// moderation settings. Events already stored when moderation is first
+// enabled are not moderated. Events by blacklisted authors are deleted, so
+// the blacklist also holds in open mode, where the relay does not enforce it.
+type ModerationService struct {
+	db        *db.DB
+	configMgr *relay.ConfigManager
+	relay     *relay.Relay
+	engine    *moderationEngine
+	interval  time.Duration
+	now       func() time.Time
+	lastPrune time.Time
+	wakeCh    chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+	scanMu    sync.Mutex // serializes scans, which share the engine
+}
+
+// ModerationScan summarizes one pass over new events.
+type ModerationScan struct {
+	Scanned     int `json:"scanned"`
+	Flagged     int `json:"flagged"`
+	Deleted     int `json:"deleted"`
+	Blacklisted int `json:"blacklisted"`
+}
+
+// NewModerationService creates a new moderation service.
+func NewModerationService(database *db.DB, configMgr *relay.ConfigManager, relayCtl *relay.Relay) *ModerationService {
+	return &ModerationService{
+		db:        database,
+		configMgr: configMgr,
+		relay:     relayCtl,
+		engine:    newModerationEngine(),
+		interval:  30 * time.Second,
+		now:       time.Now,
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins moderating new events in the background.
+func (s *ModerationService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the moderation worker.
+func (s *ModerationService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to scan now, e.g. after rules or settings change.
+func (s *ModerationService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ModerationService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		case <-s.wakeCh:
+		}
+		if _, err := s.Scan(context.Background()); err != nil && !errors.Is(err, db.ErrUnsupportedOnPostgres) {
+			slog.Error("Failed to moderate events", "error", err)
+		}
+	}
+}
+
+// Scan moderates events stored since the last scan. The first scan after
+// moderation is enabled only records the current position.
+func (s *ModerationService) Scan(ctx context.Context) (*ModerationScan, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	result := &ModerationScan{}
+	settings, err := s.db.GetModerationSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Enabled || !s.db.IsRelayDBConnected() {
+		return result, nil
+	}
+
+	s.pruneActions(ctx)
+
+	cursor, err := s.db.GetModerationCursor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == 0 {
+		latest, err := s.db.GetLatestEventRowID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return result, s.db.SetModerationCursor(ctx, latest)
+	}
+
+	rules, err := s.db.GetModerationRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.engine.setRules(rules)
+
+	exempt := make(map[string]bool)
+	if operator, _ := s.db.GetAppState(ctx, "operator_pubkey"); operator != "" {
+		exempt[operator] = true
+	}
+	if settings.ExemptMembers {
+		members, err := s.db.GetWhitelistMeta(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			exempt[m.Pubkey] = true
+		}
+	}
+	blacklisted := make(map[string]bool)
+	blacklist, err := s.db.GetBlacklist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range blacklist {
+		blacklisted[entry.Pubkey] = true
+	}
+
+	last := cursor
+	err = s.db.StreamEventsAfter(ctx, cursor, nil, func(rowID int64, event db.ExportEvent) error {
+		last = rowID
+		if exempt[event.Pubkey] {
+			return nil
+		}
+		result.Scanned++
+
+		score, matched := 0, []string{blacklistedRule}
+		action := ModerationDelete
+		if !blacklisted[event.Pubkey] {
+			score, matched = s.engine.score(event)
+			action = decideModeration(settings, score)
+		}
+		if action == "" {
+			return nil
+		}
+
+		if err := s.apply(ctx, event, score, matched, action); err != nil {
+			return err
+		}
+		switch action {
+		case ModerationFlag:
+			result.Flagged++
+		case ModerationDelete:
+			result.Deleted++
+		case ModerationBlacklist:
+			result.Blacklisted++
+			blacklisted[event.Pubkey] = true
+		}
+		return nil
+	})
+	s.engine.forget(s.now().Unix())
+
+	if last > cursor {
+		if err := s.db.SetModerationCursor(ctx, last); err != nil {
+			return result, err
+		}
+	}
+	if result.Blacklisted > 0 {
+		s.syncBlacklist(ctx)
+	}
+	if result.Flagged+result.Deleted+result.Blacklisted > 0 {
+		slog.Info("Moderated events", "scanned", result.Scanned, "flagged", result.Flagged,
+			"deleted", result.Deleted, "blacklisted", result.Blacklisted)
+	}
+	return result, err
+}
+
+// apply carries out an action and records it.
+func (s *ModerationService) apply(ctx context.Context, event db.ExportEvent, score int, matched []string, action string) error {
+	reason := "Auto-moderation: " + strings.Join(matched, ", ")
+
+	if action == ModerationBlacklist {
+		npub, _ := nostr.EncodeNpub(event.Pubkey)
+		if err := s.db.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: event.Pubkey, Npub: npub, Reason: reason}); err != nil {
+			return fmt.Errorf("failed to blacklist %s: %w", event.Pubkey, err)
+		}
+		s.db.AddAuditLog(ctx, "moderation_blacklist", map[string]interface{}{
+			"pubkey":   event.Pubkey,
+			"event_id": event.ID,
+			"score":    score,
+			"rules":    matched,
+		}, "moderation")
+	}
+
+	if action == ModerationDelete || action == ModerationBlacklist {
+		if err := QueueAuthorDeletion(ctx, s.db, event.ID, event.Pubkey, reason); err != nil {
+			return err
+		}
+	}
+
+	return s.db.AddModerationAction(ctx, &db.ModerationAction{
+		EventID: event.ID,
+		Pubkey:  event.Pubkey,
+		Kind:    event.Kind,
+		Score:   score,
+		Rules:   matched,
+		Action:  action,
+	})
+}
+
+// syncBlacklist writes the blacklist to config.toml and restarts the relay
+// when the relay enforces it, i.e. in blacklist mode.
+func (s *ModerationService) syncBlacklist(ctx context.Context) {
+	if s.configMgr == nil || s.configMgr.DelegatesAccess() {
+		return
+	}
+	if mode, _ := s.db.GetAccessMode(ctx); mode != "blacklist" {
+		return
+	}
+
+	entries, err := s.db.GetBlacklist(ctx)
+	if err != nil {
+		slog.Warn("Failed to read blacklist", "error", err)
+		return
+	}
+	pubkeys := make([]string, len(entries))
+	for i, e := range entries {
+		pubkeys[i] = e.Pubkey
+	}
+	if err := s.configMgr.UpdateBlacklist(pubkeys); err != nil {
+		slog.Warn("Failed to sync blacklist to config.toml", "error", err)
+		return
+	}
+	// nostr-rs-relay only reads the blacklist at startup
+	if s.relay != nil {
+		if err := s.relay.Restart(); err != nil {
+			slog.Warn("Failed to restart relay", "error", err)
+		}
+	}
+}
+
+// pruneActions drops old entries from the action log once a day.
+func (s *ModerationService) pruneActions(ctx context.Context) {
+	now := s.now()
+	if now.Sub(s.lastPrune) < 24*time.Hour {
+		return
+	}
+	s.lastPrune = now
+	if _, err := s.db.DeleteModerationActionsBefore(ctx, now.Add(-moderationActionRetention)); err != nil {
+		slog.Warn("Failed to prune moderation actions", "error", err)
+	}
+}
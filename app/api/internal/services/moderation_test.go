@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func insertModerationTestEvent(t *testing.T, relayDB *sql.DB, n byte, author string, createdAt int64, content string) string {
+	t.Helper()
+	id := strings.Repeat(hex.EncodeToString([]byte{n}), 32)
+	raw, _ := json.Marshal(map[string]interface{}{
+		"id": id, "pubkey": author, "created_at": createdAt, "kind": 1,
+		"tags": [][]string{}, "content": content, "sig": "",
+	})
+	hash, _ := hex.DecodeString(id)
+	authorBytes, _ := hex.DecodeString(author)
+	if _, err := relayDB.Exec(`INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content) VALUES (?, ?, ?, ?, 1, 0, ?)`,
+		hash, time.Now().Unix(), createdAt, authorBytes, string(raw)); err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+	return id
+}
+
+func TestModerationEngine(t *testing.T) {
+	engine := newModerationEngine()
+	engine.setRules([]db.ModerationRule{
+		{Name: "dupes", Type: RuleDuplicateContent, Score: 2, Enabled: true,
+			Config: db.ModerationRuleConfig{WindowSeconds: 600, MaxCount: 2}},
+		{Name: "burst", Type: RuleBurstRate, Score: 1, Enabled: true,
+			Config: db.ModerationRuleConfig{WindowSeconds: 60, MaxCount: 3}},
+		{Name: "links", Type: RuleLinkDensity, Score: 3, Enabled: true,
+			Config: db.ModerationRuleConfig{MaxLinks: 2}},
+		{Name: "words", Type: RuleBannedWords, Score: 5, Enabled: true,
+			Config: db.ModerationRuleConfig{Patterns: []string{`(?i)free\s+bitcoin`}, Kinds: []int{1}}},
+		{Name: "disabled", Type: RuleBannedWords, Score: 50, Enabled: false,
+			Config: db.ModerationRuleConfig{Patterns: []string{`.`}}},
+	})
+
+	spam := "Buy my course today, limited offer!"
+	event := func(pubkey string, at int64, content string) db.ExportEvent {
+		return db.ExportEvent{Pubkey: pubkey, Kind: 1, CreatedAt: at, Content: content}
+	}
+
+	tests := []struct {
+		name    string
+		event   db.ExportEvent
+		score   int
+		matched []string
+	}{
+		{"first copy", event("a", 1000, spam), 0, nil},
+		{"second copy", event("b", 1010, spam), 0, nil},
+		{"third copy, differently spaced", event("c", 1020, "buy my  course TODAY, limited offer!"), 2, []string{"dupes"}},
+		{"short text is never a duplicate", event("d", 1030, "gm"), 0, nil},
+		{"fourth post in a minute", event("d", 1031, "gm"), 0, nil},
+		{"burst", event("d", 1032, "gm"), 0, nil},
+		{"burst over limit", event("d", 1033, "gm"), 1, []string{"burst"}},
+		{"links", event("e", 2000, "https://a.example https://b.example wss://c.example"), 3, []string{"links"}},
+		{"banned words", event("f", 2000, "Get FREE  bitcoin now"), 5, []string{"words"}},
+		{"banned words on another kind", db.ExportEvent{Pubkey: "f", Kind: 30023, CreatedAt: 2001, Content: "free bitcoin"}, 0, nil},
+	}
+	for _, tt := range tests {
+		score, matched := engine.score(tt.event)
+		if score != tt.score || strings.Join(matched, ",") != strings.Join(tt.matched, ",") {
+			t.Errorf("%s: got score %d %v, want %d %v", tt.name, score, matched, tt.score, tt.matched)
+		}
+	}
+
+	// Copies outside the window are forgotten
+	engine.forget(5000)
+	if score, _ := engine.score(event("g", 5000, spam)); score != 0 {
+		t.Errorf("expected forgotten copies not to count, got score %d", score)
+	}
+}
+
+func TestDecideModeration(t *testing.T) {
+	settings := &db.ModerationSettings{FlagScore: 1, DeleteScore: 5, BlacklistScore: 10}
+	tests := []struct {
+		score int
+		want  string
+	}{
+		{0, ""},
+		{1, ModerationFlag},
+		{4, ModerationFlag},
+		{5, ModerationDelete},
+		{10, ModerationBlacklist},
+	}
+	for _, tt := range tests {
+		if got := decideModeration(settings, tt.score); got != tt.want {
+			t.Errorf("decideModeration(%d) = %q, want %q", tt.score, got, tt.want)
+		}
+	}
+
+	if got := decideModeration(&db.ModerationSettings{DeleteScore: 5}, 3); got != "" {
+		t.Errorf("expected no action below the only threshold, got %q", got)
+	}
+}
+
+func TestValidateModerationRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    db.ModerationRule
+		wantErr bool
+	}{
+		{"valid burst", db.ModerationRule{Name: "burst", Type: RuleBurstRate, Score: 1,
+			Config: db.ModerationRuleConfig{WindowSeconds: 60, MaxCount: 10}}, false},
+		{"missing window", db.ModerationRule{Name: "burst", Type: RuleBurstRate, Score: 1,
+			Config: db.ModerationRuleConfig{MaxCount: 10}}, true},
+		{"missing name", db.ModerationRule{Type: RuleBurstRate, Score: 1,
+			Config: db.ModerationRuleConfig{WindowSeconds: 60, MaxCount: 10}}, true},
+		{"score too high", db.ModerationRule{Name: "links", Type: RuleLinkDensity, Score: 101,
+			Config: db.ModerationRuleConfig{MaxLinks: 3}}, true},
+		{"ratio over 1", db.ModerationRule{Name: "links", Type: RuleLinkDensity, Score: 1,
+			Config: db.ModerationRuleConfig{MaxLinkRatio: 1.5}}, true},
+		{"bad pattern", db.ModerationRule{Name: "words", Type: RuleBannedWords, Score: 1,
+			Config: db.ModerationRuleConfig{Patterns: []string{"("}}}, true},
+		{"unknown type", db.ModerationRule{Name: "x", Type: "vibes", Score: 1}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateModerationRule(&tt.rule)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got err %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestModerationService_Scan(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	member := strings.Repeat("aa", 32)
+	spammer := strings.Repeat("bb", 32)
+	banned := strings.Repeat("cc", 32)
+	other := strings.Repeat("dd", 32)
+
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: member})
+	database.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: banned})
+	database.SetModerationSettings(ctx, &db.ModerationSettings{
+		Enabled: true, FlagScore: 1, DeleteScore: 5, BlacklistScore: 10, ExemptMembers: true,
+	})
+	database.CreateModerationRule(ctx, &db.ModerationRule{Name: "scam", Type: RuleBannedWords, Score: 5, Enabled: true,
+		Config: db.ModerationRuleConfig{Patterns: []string{`(?i)free bitcoin`}}})
+	database.CreateModerationRule(ctx, &db.ModerationRule{Name: "links", Type: RuleLinkDensity, Score: 1, Enabled: true,
+		Config: db.ModerationRuleConfig{MaxLinks: 1}})
+
+	// Events stored before moderation starts are left alone
+	insertModerationTestEvent(t, relayDB, 1, spammer, 1700000000, "free bitcoin")
+
+	svc := NewModerationService(database, nil, nil)
+	if result, err := svc.Scan(ctx); err != nil || result.Scanned != 0 {
+		t.Fatalf("expected the first scan to only record the cursor, got %+v, %v", result, err)
+	}
+
+	insertModerationTestEvent(t, relayDB, 2, member, 1700000100, "free bitcoin https://a.example https://b.example")
+	flagged := insertModerationTestEvent(t, relayDB, 3, other, 1700000101, "https://a.example https://b.example")
+	deleted := insertModerationTestEvent(t, relayDB, 4, other, 1700000102, "free bitcoin")
+	insertModerationTestEvent(t, relayDB, 5, other, 1700000103, "just a note")
+	blacklisted := insertModerationTestEvent(t, relayDB, 6, banned, 1700000104, "hello")
+
+	result, err := svc.Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.Scanned != 4 || result.Flagged != 1 || result.Deleted != 2 || result.Blacklisted != 0 {
+		t.Fatalf("unexpected scan result: %+v", result)
+	}
+
+	actions, total, err := database.GetModerationActions(ctx, db.ModerationActionFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetModerationActions failed: %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("expected 3 actions, got %d", total)
+	}
+	byEvent := make(map[string]db.ModerationAction)
+	for _, a := range actions {
+		byEvent[a.EventID] = a
+	}
+	if byEvent[flagged].Action != ModerationFlag {
+		t.Errorf("expected flag for link spam, got %+v", byEvent[flagged])
+	}
+	if a := byEvent[deleted]; a.Action != ModerationDelete || a.Score != 5 || strings.Join(a.Rules, ",") != "scam" {
+		t.Errorf("unexpected action for scam event: %+v", a)
+	}
+	if a := byEvent[blacklisted]; a.Action != ModerationDelete || strings.Join(a.Rules, ",") != blacklistedRule {
+		t.Errorf("expected blacklisted author's event to be deleted, got %+v", a)
+	}
+
+	// Deletions are queued in the author's name so the deletion service honors them
+	requests, err := database.GetDeletionRequests(ctx, "pending")
+	if err != nil {
+		t.Fatalf("GetDeletionRequests failed: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 deletion requests, got %d", len(requests))
+	}
+	for _, req := range requests {
+		if len(req.TargetEventIDs) != 1 {
+			t.Fatalf("expected one target per request, got %v", req.TargetEventIDs)
+		}
+		if req.TargetEventIDs[0] == deleted && req.AuthorPubkey != other {
+			t.Errorf("expected deletion requested as %s, got %s", other, req.AuthorPubkey)
+		}
+	}
+
+	// Nothing new, nothing scanned
+	if result, err := svc.Scan(ctx); err != nil || result.Scanned != 0 {
+		t.Errorf("expected an empty rescan, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,547 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+const (
+	// NostrBackupKind is the NIP-78 application-specific data kind. It is
+	// parameterized replaceable, so relays keep only the latest backup.
+	NostrBackupKind = 30078
+
+	// NostrBackupDTag identifies Roostr backups among the operator's app data.
+	NostrBackupDTag = "roostr-backup"
+
+	nostrBackupVersion = 1
+	nostrBackupTimeout = 15 * time.Second
+)
+
+var (
+	// ErrNoOperator is returned when there is no operator to encrypt a backup to.
+	ErrNoOperator = errors.New("operator pubkey not set")
+
+	// ErrNoBackupRelays is returned when no relays are configured for backups.
+	ErrNoBackupRelays = errors.New("no backup relays configured")
+
+	// ErrBackupNotFound is returned when no relay holds a backup for the operator.
+	ErrBackupNotFound = errors.New("no backup found on the given relays")
+)
+
+// NostrBackup is the plaintext contents of a backup event: the member lists
+// and the settings needed to bring a fresh install back to the same state.
+type NostrBackup struct {
+	Version          int                 `json:"version"`
+	CreatedAt        time.Time           `json:"created_at"`
+	OperatorPubkey   string              `json:"operator_pubkey"`
+	AccessMode       string              `json:"access_mode"`
+	RelayName        string              `json:"relay_name,omitempty"`
+	RelayDescription string              `json:"relay_description,omitempty"`
+	Whitelist        []db.WhitelistEntry `json:"whitelist"`
+	Blacklist        []db.BlacklistEntry `json:"blacklist"`
+	PricingTiers     []db.PricingTier    `json:"pricing_tiers"`
+	ExcludedKinds    []int               `json:"excluded_kinds"`
+}
+
+// hash returns a digest of the backup contents, ignoring when it was built,
+// so unchanged state is not republished.
+func (b *NostrBackup) hash() string {
+	copied := *b
+	copied.CreatedAt = time.Time{}
+	data, _ := json.Marshal(copied)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FoundBackup is a backup event fetched from a relay, still encrypted.
+type FoundBackup struct {
+	Event *nostr.SyncEvent `json:"event"`
+	Relay string           `json:"relay"`
+}
+
+// NostrBackupService publishes the whitelist and settings as a NIP-04
+// encrypted, parameterized replaceable event addressed to the operator. The
+// event is signed with a key Roostr generates for this purpose; the operator
+// decrypts it with their own key, so a backup survives losing this device.
+type NostrBackupService struct {
+	db        *db.DB
+	configMgr *relay.ConfigManager
+	interval  time.Duration
+	wakeCh    chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+	publishMu sync.Mutex
+}
+
+// NewNostrBackupService creates a new Nostr backup service.
+func NewNostrBackupService(database *db.DB, configMgr *relay.ConfigManager) *NostrBackupService {
+	return &NostrBackupService{
+		db:        database,
+		configMgr: configMgr,
+		interval:  time.Hour,
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins the background backup worker.
+func (s *NostrBackupService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the backup worker.
+func (s *NostrBackupService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to check for changes now, e.g. after settings change.
+func (s *NostrBackupService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// run publishes a backup when enabled and the contents changed or the
+// interval has passed since the last one.
+func (s *NostrBackupService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.publishIfDue()
+		case <-s.wakeCh:
+			s.publishIfDue()
+		}
+	}
+}
+
+func (s *NostrBackupService) publishIfDue() {
+	ctx := context.Background()
+
+	settings, err := s.db.GetNostrBackupSettings(ctx)
+	if err != nil || !settings.Enabled {
+		return
+	}
+
+	backup, err := s.Build(ctx)
+	if err != nil {
+		slog.Error("Failed to build Nostr backup", "error", err)
+		return
+	}
+
+	last, _ := s.db.GetLastNostrBackup(ctx)
+	interval := time.Duration(settings.IntervalHours) * time.Hour
+	if last != nil && last.Hash == backup.hash() && time.Since(last.At) < interval {
+		return
+	}
+
+	if _, err := s.publish(ctx, settings, backup); err != nil {
+		slog.Error("Nostr backup failed", "error", err)
+	}
+}
+
+// Build collects the current whitelist and settings into a backup.
+func (s *NostrBackupService) Build(ctx context.Context) (*NostrBackup, error) {
+	operator, err := s.db.GetOperatorPubkey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if operator == "" {
+		return nil, ErrNoOperator
+	}
+
+	backup := &NostrBackup{
+		Version:        nostrBackupVersion,
+		CreatedAt:      time.Now().UTC(),
+		OperatorPubkey: operator,
+		Whitelist:      []db.WhitelistEntry{},
+		Blacklist:      []db.BlacklistEntry{},
+		PricingTiers:   []db.PricingTier{},
+	}
+
+	if backup.AccessMode, err = s.db.GetAccessMode(ctx); err != nil {
+		return nil, err
+	}
+	if whitelist, err := s.db.GetWhitelistMeta(ctx); err != nil {
+		return nil, err
+	} else if whitelist != nil {
+		backup.Whitelist = whitelist
+	}
+	if blacklist, err := s.db.GetBlacklist(ctx); err != nil {
+		return nil, err
+	} else if blacklist != nil {
+		backup.Blacklist = blacklist
+	}
+	if tiers, err := s.db.GetPricingTiers(ctx); err != nil {
+		return nil, err
+	} else if tiers != nil {
+		backup.PricingTiers = tiers
+	}
+	if backup.ExcludedKinds, err = s.db.GetExcludedKinds(ctx); err != nil {
+		return nil, err
+	}
+
+	if s.configMgr != nil {
+		if cfg, err := s.configMgr.Read(); err == nil {
+			backup.RelayName = cfg.Info.Name
+			backup.RelayDescription = cfg.Info.Description
+		}
+	}
+
+	return backup, nil
+}
+
+// Publish builds and publishes a backup now, regardless of whether it changed.
+func (s *NostrBackupService) Publish(ctx context.Context) (*db.NostrBackupRecord, error) {
+	settings, err := s.db.GetNostrBackupSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := s.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.publish(ctx, settings, backup)
+}
+
+// publish encrypts the backup to the operator, signs it with the backup key
+// and sends it to each relay. It fails only if no relay accepted it.
+func (s *NostrBackupService) publish(ctx context.Context, settings *db.NostrBackupSettings, backup *NostrBackup) (*db.NostrBackupRecord, error) {
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+
+	relays, err := s.backupRelays(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := s.buildEvent(ctx, backup)
+	if err != nil {
+		return nil, err
+	}
+
+	record := db.NostrBackupRecord{
+		At:      time.Now(),
+		EventID: event.ID,
+		Hash:    backup.hash(),
+		Members: len(backup.Whitelist),
+	}
+
+	published := 0
+	for _, url := range relays {
+		result := db.NostrBackupRelayResult{URL: url, OK: true}
+		if err := publishToRelay(ctx, url, event); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+			slog.Warn("Failed to publish Nostr backup", "relay", url, "error", err)
+		} else {
+			published++
+		}
+		record.Relays = append(record.Relays, result)
+	}
+
+	if published == 0 {
+		return &record, fmt.Errorf("backup was not published to any of %d relays", len(relays))
+	}
+
+	if err := s.db.SetLastNostrBackup(ctx, record); err != nil {
+		return nil, err
+	}
+	s.db.AddAuditLog(ctx, "nostr_backup_published", map[string]interface{}{
+		"event_id": event.ID,
+		"members":  record.Members,
+		"relays":   published,
+	}, "")
+
+	slog.Info("Published Nostr backup", "event_id", event.ID, "members", record.Members, "relays", published)
+	return &record, nil
+}
+
+// buildEvent encrypts the backup and wraps it in a signed backup event.
+func (s *NostrBackupService) buildEvent(ctx context.Context, backup *NostrBackup) (*nostr.SyncEvent, error) {
+	secret, err := s.backupKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(backup)
+	if err != nil {
+		return nil, err
+	}
+	content, err := nostr.NIP04Encrypt(secret, backup.OperatorPubkey, string(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+
+	event := nostr.NewEvent(NostrBackupKind, [][]string{
+		{"d", NostrBackupDTag},
+		{"p", backup.OperatorPubkey},
+		{"alt", "Encrypted Roostr relay backup"},
+	}, content)
+	if err := event.Sign(secret); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// backupKey returns the backup signing key, generating it on first use.
+func (s *NostrBackupService) backupKey(ctx context.Context) (string, error) {
+	secret, err := s.db.GetNostrBackupKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	if secret != "" {
+		return secret, nil
+	}
+
+	if secret, err = nostr.GenerateSecretKey(); err != nil {
+		return "", err
+	}
+	if err := s.db.SetNostrBackupKey(ctx, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// BackupPubkey returns the pubkey backups are signed with, generating the key
+// on first use.
+func (s *NostrBackupService) BackupPubkey(ctx context.Context) (string, error) {
+	secret, err := s.backupKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	return nostr.GetPublicKey(secret)
+}
+
+// backupRelays returns the configured relays, falling back to the sync relays.
+func (s *NostrBackupService) backupRelays(ctx context.Context, settings *db.NostrBackupSettings) ([]string, error) {
+	if len(settings.Relays) > 0 {
+		return settings.Relays, nil
+	}
+
+	syncRelays, err := s.db.GetSyncRelays(ctx)
+	if err != nil {
+		return nil, err
+	}
+	relays := make([]string, 0, len(syncRelays))
+	for _, r := range syncRelays {
+		relays = append(relays, r.URL)
+	}
+	if len(relays) == 0 {
+		return nil, ErrNoBackupRelays
+	}
+	return relays, nil
+}
+
+// publishToRelay sends the event to a single relay.
+func publishToRelay(ctx context.Context, url string, event *nostr.SyncEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, nostrBackupTimeout)
+	defer cancel()
+
+	client := nostr.NewClient(url)
+	if err := client.Connect(ctx); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Publish(event)
+}
+
+// FetchLatestNostrBackup looks for the operator's backups on the given relays and
+// returns the newest valid one. The content is left encrypted; only the
+// operator's key (or this install's backup key) can decrypt it.
+func FetchLatestNostrBackup(ctx context.Context, operatorPubkey string, relays []string) (*FoundBackup, error) {
+	filter := nostr.Filter{
+		Kinds: []int{NostrBackupKind},
+		PTags: []string{operatorPubkey},
+		DTags: []string{NostrBackupDTag},
+		Limit: 20,
+	}
+
+	var latest *FoundBackup
+	for _, url := range relays {
+		err := func() error {
+			ctx, cancel := context.WithTimeout(ctx, nostrBackupTimeout)
+			defer cancel()
+
+			client := nostr.NewClient(url)
+			if err := client.Connect(ctx); err != nil {
+				return err
+			}
+			defer client.Close()
+
+			return client.Subscribe(ctx, filter, func(event *nostr.SyncEvent) error {
+				if !isBackupFor(event, operatorPubkey) || event.Verify() != nil {
+					return nil
+				}
+				if latest == nil || event.CreatedAt > latest.Event.CreatedAt {
+					latest = &FoundBackup{Event: event, Relay: url}
+				}
+				return nil
+			})
+		}()
+		if err != nil {
+			slog.Warn("Failed to fetch Nostr backup", "relay", url, "error", err)
+		}
+	}
+
+	if latest == nil {
+		return nil, ErrBackupNotFound
+	}
+	return latest, nil
+}
+
+// isBackupFor reports whether an event is a Roostr backup addressed to pubkey.
+// Relays may ignore tag filters, so they are checked again here.
+func isBackupFor(event *nostr.SyncEvent, pubkey string) bool {
+	if event.Kind != NostrBackupKind {
+		return false
+	}
+	var hasD, hasP bool
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch {
+		case tag[0] == "d" && tag[1] == NostrBackupDTag:
+			hasD = true
+		case tag[0] == "p" && tag[1] == pubkey:
+			hasP = true
+		}
+	}
+	return hasD && hasP
+}
+
+// Restore writes a decrypted backup to the app database: operator, access
+// mode, member lists, pricing tiers and excluded kinds, plus the relay name
+// and description in config.toml. The caller syncs the relay config afterwards.
+func (s *NostrBackupService) Restore(ctx context.Context, backup *NostrBackup) error {
+	if !nostr.IsValidHexPubkey(backup.OperatorPubkey) {
+		return ErrNoOperator
+	}
+
+	if err := s.db.SetOperatorPubkey(ctx, backup.OperatorPubkey); err != nil {
+		return err
+	}
+	operatorNpub, _ := nostr.EncodeNpub(backup.OperatorPubkey)
+	s.db.SetAppState(ctx, "operator_npub", operatorNpub)
+
+	if backup.AccessMode != "" {
+		if err := s.db.SetAccessMode(ctx, backup.AccessMode); err != nil {
+			return err
+		}
+	}
+
+	// Oldest first, so restored entries keep their relative order
+	whitelist := append([]db.WhitelistEntry(nil), backup.Whitelist...)
+	sort.SliceStable(whitelist, func(i, j int) bool {
+		return whitelist[i].AddedAt.Before(whitelist[j].AddedAt)
+	})
+	hasOperator := false
+	for _, entry := range whitelist {
+		if !nostr.IsValidHexPubkey(entry.Pubkey) {
+			continue
+		}
+		entry.IsOperator = entry.Pubkey == backup.OperatorPubkey
+		hasOperator = hasOperator || entry.IsOperator
+		if err := s.db.AddWhitelistEntry(ctx, entry); err != nil {
+			return err
+		}
+	}
+	if !hasOperator {
+		if err := s.db.AddWhitelistEntry(ctx, db.WhitelistEntry{
+			Pubkey:     backup.OperatorPubkey,
+			Npub:       operatorNpub,
+			Nickname:   "Operator",
+			IsOperator: true,
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range backup.Blacklist {
+		if !nostr.IsValidHexPubkey(entry.Pubkey) {
+			continue
+		}
+		if err := s.db.AddBlacklistEntry(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	for _, tier := range backup.PricingTiers {
+		if err := s.db.UpdatePricingTier(ctx, tier); err != nil {
+			return err
+		}
+	}
+
+	if backup.ExcludedKinds != nil {
+		if err := s.db.SetExcludedKinds(ctx, normalizeKinds(backup.ExcludedKinds)); err != nil {
+			return err
+		}
+	}
+
+	s.db.AddSyncPubkey(ctx, db.SyncPubkey{
+		Pubkey:     backup.OperatorPubkey,
+		Npub:       operatorNpub,
+		Nickname:   "Operator",
+		IsOperator: true,
+	})
+
+	if s.configMgr != nil {
+		if cfg, err := s.configMgr.Read(); err == nil {
+			if backup.RelayName != "" {
+				cfg.Info.Name = backup.RelayName
+			}
+			if backup.RelayDescription != "" {
+				cfg.Info.Description = backup.RelayDescription
+			}
+			cfg.Info.Pubkey = backup.OperatorPubkey
+			cfg.Info.Contact = operatorNpub
+			if err := s.configMgr.WriteAs(cfg, "roostr", "Restored from Nostr backup"); err != nil {
+				slog.WarnContext(ctx, "Failed to write restored relay info to config", "error", err)
+			}
+		}
+	}
+
+	return nil
+}
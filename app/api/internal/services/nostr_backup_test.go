@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// fakeBackupRelay is a minimal relay that stores published events and
+// returns all of them for any REQ.
+type fakeBackupRelay struct {
+	mu       sync.Mutex
+	events   []*nostr.SyncEvent
+	received chan struct{}
+}
+
+func newFakeBackupRelay(t *testing.T) (*fakeBackupRelay, string) {
+	t.Helper()
+	relay := &fakeBackupRelay{received: make(chan struct{}, 10)}
+	server := httptest.NewServer(http.HandlerFunc(relay.serve))
+	t.Cleanup(server.Close)
+	return relay, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func (f *fakeBackupRelay) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := nostr.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		message, err := conn.ReadMessage(5 * time.Second)
+		if err != nil {
+			return
+		}
+		var raw []json.RawMessage
+		if json.Unmarshal(message, &raw) != nil || len(raw) < 2 {
+			continue
+		}
+		var msgType, subID string
+		json.Unmarshal(raw[0], &msgType)
+
+		switch msgType {
+		case "EVENT":
+			var event nostr.SyncEvent
+			json.Unmarshal(raw[1], &event)
+			f.mu.Lock()
+			f.events = append(f.events, &event)
+			f.mu.Unlock()
+			f.received <- struct{}{}
+		case "REQ":
+			json.Unmarshal(raw[1], &subID)
+			f.mu.Lock()
+			for _, event := range f.events {
+				conn.WriteJSON([]interface{}{"EVENT", subID, event})
+			}
+			f.mu.Unlock()
+			conn.WriteJSON([]interface{}{"EOSE", subID})
+		}
+	}
+}
+
+func TestNostrBackup_PublishFetchRestore(t *testing.T) {
+	ctx := context.Background()
+	source := setupTestDB(t)
+
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	operator, _ := nostr.GetPublicKey(operatorSecret)
+	memberSecret, _ := nostr.GenerateSecretKey()
+	member, _ := nostr.GetPublicKey(memberSecret)
+
+	svc := NewNostrBackupService(source, nil)
+
+	t.Run("requires_operator", func(t *testing.T) {
+		if _, err := svc.Publish(ctx); !errors.Is(err, ErrNoOperator) {
+			t.Errorf("expected ErrNoOperator, got %v", err)
+		}
+	})
+
+	source.SetOperatorPubkey(ctx, operator)
+	source.SetAccessMode(ctx, "paid")
+	source.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: operator, Nickname: "Operator", IsOperator: true})
+	source.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: member, Nickname: "alice"})
+	source.SetExcludedKinds(ctx, []int{4})
+
+	fake, url := newFakeBackupRelay(t)
+	source.SetNostrBackupSettings(ctx, &db.NostrBackupSettings{Enabled: true, Relays: []string{url}, IntervalHours: 24})
+
+	record, err := svc.Publish(ctx)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if record.Members != 2 || len(record.Relays) != 1 || !record.Relays[0].OK {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	select {
+	case <-fake.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("relay did not receive the backup")
+	}
+
+	found, err := FetchLatestNostrBackup(ctx, operator, []string{url})
+	if err != nil {
+		t.Fatalf("FetchLatestNostrBackup failed: %v", err)
+	}
+	if found.Event.ID != record.EventID || found.Event.Kind != NostrBackupKind {
+		t.Errorf("fetched wrong event: %+v", found.Event)
+	}
+	if strings.Contains(found.Event.Content, "alice") {
+		t.Error("backup content is not encrypted")
+	}
+
+	t.Run("not_found_for_other_operator", func(t *testing.T) {
+		if _, err := FetchLatestNostrBackup(ctx, member, []string{url}); !errors.Is(err, ErrBackupNotFound) {
+			t.Errorf("expected ErrBackupNotFound, got %v", err)
+		}
+	})
+
+	// The operator decrypts with their own key and the backup event's pubkey
+	plaintext, err := nostr.NIP04Decrypt(operatorSecret, found.Event.Pubkey, found.Event.Content)
+	if err != nil {
+		t.Fatalf("operator could not decrypt backup: %v", err)
+	}
+	var backup NostrBackup
+	if err := json.Unmarshal([]byte(plaintext), &backup); err != nil {
+		t.Fatalf("invalid backup JSON: %v", err)
+	}
+
+	target := setupTestDB(t)
+	if err := NewNostrBackupService(target, nil).Restore(ctx, &backup); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if got, _ := target.GetOperatorPubkey(ctx); got != operator {
+		t.Errorf("expected operator %s, got %s", operator, got)
+	}
+	if mode, _ := target.GetAccessMode(ctx); mode != "paid" {
+		t.Errorf("expected access mode paid, got %s", mode)
+	}
+	entry, _ := target.GetWhitelistEntryByPubkey(ctx, member)
+	if entry == nil || entry.Nickname != "alice" || entry.IsOperator {
+		t.Errorf("member not restored: %+v", entry)
+	}
+	if op, _ := target.GetWhitelistEntryByPubkey(ctx, operator); op == nil || !op.IsOperator {
+		t.Errorf("operator not restored: %+v", op)
+	}
+	if kinds, _ := target.GetExcludedKinds(ctx); len(kinds) != 1 || kinds[0] != 4 {
+		t.Errorf("expected excluded kinds [4], got %v", kinds)
+	}
+}
+
+func TestNostrBackup_HashIgnoresCreatedAt(t *testing.T) {
+	a := &NostrBackup{Version: 1, OperatorPubkey: "abc", CreatedAt: time.Unix(1, 0)}
+	b := &NostrBackup{Version: 1, OperatorPubkey: "abc", CreatedAt: time.Unix(2, 0)}
+	if a.hash() != b.hash() {
+		t.Error("expected hash to ignore created_at")
+	}
+
+	b.AccessMode = "open"
+	if a.hash() == b.hash() {
+		t.Error("expected hash to change with contents")
+	}
+}
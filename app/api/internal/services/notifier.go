@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// Notification channels.
+const (
+	NotifyChannelDM    = "dm"
+	NotifyChannelEmail = "email"
+)
+
+// SMTP TLS modes.
+const (
+	SMTPTLSStartTLS = "starttls" // Upgrade with STARTTLS, usually on port 587
+	SMTPTLSImplicit = "tls"      // TLS from the start, usually on port 465
+)
+
+// smtpTimeout bounds connecting to the mail server with implicit TLS.
+const smtpTimeout = 30 * time.Second
+
+// ErrEmailNotConfigured is returned when sending email without SMTP settings.
+var ErrEmailNotConfigured = errors.New("email is not configured")
+
+// SMTPConfig holds the outgoing mail server settings.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	TLS      string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPConfigFromSettings converts mail server settings saved from the UI.
+func SMTPConfigFromSettings(settings *db.SMTPSettings) SMTPConfig {
+	cfg := SMTPConfig{
+		Host:     settings.Host,
+		Port:     settings.Port,
+		TLS:      settings.TLS,
+		Username: settings.Username,
+		Password: settings.Password,
+		From:     settings.From,
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = SMTPTLSStartTLS
+	}
+	if cfg.Port == "" && cfg.TLS == SMTPTLSImplicit {
+		cfg.Port = "465"
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	return cfg
+}
+
+// Notifier delivers notifications to members and the operator as NIP-04
+// direct messages or email. DMs are sent as the operator through the shared
+// Signer when it can sign, and otherwise by a key generated for this
+// install. They are published to the sync relays, so they reach members
+// through the relays their clients already read, unless notification relays
+// are configured.
+type Notifier struct {
+	db       *db.DB
+	signer   *Signer // nil sends as the notification key
+	smtp     SMTPConfig
+	relays   []string
+	mu       sync.RWMutex
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+	// sendMailTLS is sendMail for servers that expect TLS from the start
+	sendMailTLS func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewNotifier creates a notifier that sends DMs as the operator through
+// signer when it can sign. Email stays disabled until ConfigureSMTP or until
+// mail server settings are saved.
+func NewNotifier(database *db.DB, signer *Signer) *Notifier {
+	return &Notifier{
+		db:          database,
+		signer:      signer,
+		sendMail:    smtp.SendMail,
+		sendMailTLS: sendMailImplicitTLS,
+	}
+}
+
+// ConfigureSMTP sets the mail server from the config file, used for the
+// email channel unless settings are saved from the UI.
+func (n *Notifier) ConfigureSMTP(cfg SMTPConfig) {
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = SMTPTLSStartTLS
+	}
+	n.mu.Lock()
+	n.smtp = cfg
+	n.mu.Unlock()
+}
+
+// ConfigureRelays sets the relays DMs and notes are published to; none uses
+// the sync relays.
+func (n *Notifier) ConfigureRelays(relays []string) {
+	n.mu.Lock()
+	n.relays = relays
+	n.mu.Unlock()
+}
+
+// EmailAvailable reports whether the email channel is configured.
+func (n *Notifier) EmailAvailable() bool {
+	cfg, err := n.SMTP(context.Background())
+	return err == nil && cfg.Host != "" && cfg.From != ""
+}
+
+// SMTP returns the mail server in use: the settings saved from the UI, or
+// else the config file's.
+func (n *Notifier) SMTP(ctx context.Context) (SMTPConfig, error) {
+	saved, err := n.db.GetSMTPSettings(ctx)
+	if err != nil {
+		return SMTPConfig{}, err
+	}
+	if saved != nil {
+		return SMTPConfigFromSettings(saved), nil
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.smtp, nil
+}
+
+// Pubkey returns the pubkey notification DMs are sent from, generating the
+// notification key on first use if the operator cannot sign.
+func (n *Notifier) Pubkey(ctx context.Context) (string, error) {
+	sender, err := n.sender(ctx)
+	if err != nil {
+		return "", err
+	}
+	return sender.pubkey(ctx)
+}
+
+// SendDM sends an encrypted direct message (kind 4) to recipient on the
+// sync relays.
+func (n *Notifier) SendDM(ctx context.Context, recipient, message string) error {
+	sender, err := n.sender(ctx)
+	if err != nil {
+		return err
+	}
+
+	content, err := sender.encrypt(ctx, recipient, message)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	event := nostr.NewEvent(4, [][]string{{"p", recipient}}, content)
+	if err := sender.sign(ctx, event); err != nil {
+		return err
+	}
+	return n.publish(ctx, event)
+}
+
+// PublishNote publishes a public text note (kind 1) from the notification
+// sender to the sync relays and returns its event ID.
+func (n *Notifier) PublishNote(ctx context.Context, content string) (string, error) {
+	sender, err := n.sender(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	event := nostr.NewEvent(1, [][]string{}, content)
+	if err := sender.sign(ctx, event); err != nil {
+		return "", err
+	}
+	if err := n.publish(ctx, event); err != nil {
+		return "", err
+	}
+	return event.ID, nil
+}
+
+// notificationSender signs and encrypts notifications as one identity: the
+// operator through the Signer, or the notification key.
+type notificationSender struct {
+	signer *Signer
+	secret string
+}
+
+// sender returns who notifications are sent as: the operator when the
+// Signer can sign, and otherwise the notification key.
+func (n *Notifier) sender(ctx context.Context) (notificationSender, error) {
+	if n.signer != nil {
+		_, err := n.signer.Pubkey(ctx)
+		if err == nil {
+			return notificationSender{signer: n.signer}, nil
+		}
+		if !errors.Is(err, ErrNoSigner) {
+			return notificationSender{}, err
+		}
+	}
+	secret, err := n.key(ctx)
+	if err != nil {
+		return notificationSender{}, err
+	}
+	return notificationSender{secret: secret}, nil
+}
+
+func (s notificationSender) pubkey(ctx context.Context) (string, error) {
+	if s.signer != nil {
+		return s.signer.Pubkey(ctx)
+	}
+	return nostr.GetPublicKey(s.secret)
+}
+
+func (s notificationSender) encrypt(ctx context.Context, recipient, plaintext string) (string, error) {
+	if s.signer != nil {
+		return s.signer.Encrypt(ctx, recipient, plaintext)
+	}
+	return nostr.NIP04Encrypt(s.secret, recipient, plaintext)
+}
+
+func (s notificationSender) sign(ctx context.Context, event *nostr.SyncEvent) error {
+	if s.signer != nil {
+		return s.signer.Sign(ctx, event)
+	}
+	return event.Sign(s.secret)
+}
+
+// publish sends the event to every notification relay, or every sync relay
+// if none are configured. It succeeds if at least one relay took the event.
+func (n *Notifier) publish(ctx context.Context, event *nostr.SyncEvent) error {
+	n.mu.RLock()
+	relays := n.relays
+	n.mu.RUnlock()
+	if len(relays) == 0 {
+		relays = SyncRelayURLs(ctx, n.db)
+	}
+
+	var errs []error
+	published := 0
+	for _, url := range relays {
+		if err := publishToRelay(ctx, url, event); err != nil {
+			slog.WarnContext(ctx, "Failed to publish notification", "relay", url, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			continue
+		}
+		published++
+	}
+	if published == 0 {
+		return fmt.Errorf("notification was not published: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// SendEmail sends a plain-text email.
+func (n *Notifier) SendEmail(ctx context.Context, to, subject, body string) error {
+	cfg, err := n.SMTP(ctx)
+	if err != nil {
+		return err
+	}
+	return n.SendEmailWith(cfg, to, subject, body, "text/plain")
+}
+
+// SendHTMLEmail sends an HTML email.
+func (n *Notifier) SendHTMLEmail(ctx context.Context, to, subject, body string) error {
+	cfg, err := n.SMTP(ctx)
+	if err != nil {
+		return err
+	}
+	return n.SendEmailWith(cfg, to, subject, body, "text/html")
+}
+
+// SendEmailWith sends an email through the given mail server, e.g. to test
+// settings before saving them.
+func (n *Notifier) SendEmailWith(cfg SMTPConfig, to, subject, body, contentType string) error {
+	if cfg.Host == "" || cfg.From == "" {
+		return ErrEmailNotConfigured
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	msg := buildMessage(cfg.From, to, subject, body, contentType, time.Now())
+	if cfg.TLS == SMTPTLSImplicit {
+		return n.sendMailTLS(addr, auth, cfg.From, []string{to}, msg)
+	}
+	return n.sendMail(addr, auth, cfg.From, []string{to}, msg)
+}
+
+// NotifyOperator sends an alert to the operator as a DM, and by email when
+// an alert address is saved with the mail server settings. It succeeds if
+// either reached the operator.
+func (n *Notifier) NotifyOperator(ctx context.Context, subject, message string) error {
+	var errs []error
+	sent := false
+
+	if operator, _ := n.db.GetOperatorPubkey(ctx); operator != "" {
+		if err := n.SendDM(ctx, operator, message); err != nil {
+			errs = append(errs, fmt.Errorf("dm: %w", err))
+		} else {
+			sent = true
+		}
+	}
+
+	settings, err := n.db.GetSMTPSettings(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("email: %w", err))
+	} else if settings != nil && settings.AlertEmail != "" {
+		if err := n.SendEmail(ctx, settings.AlertEmail, subject, message); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		} else {
+			sent = true
+		}
+	}
+
+	if sent {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// sendMailImplicitTLS is smtp.SendMail for servers that expect a TLS
+// handshake on connect instead of STARTTLS.
+func sendMailImplicitTLS(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(a); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildEmail formats a plain-text message, stripping line breaks from headers.
+func buildEmail(from, to, subject, body string, date time.Time) []byte {
+	return buildMessage(from, to, subject, body, "text/plain", date)
+}
+
+// buildMessage formats a message with the given content type.
+func buildMessage(from, to, subject, body, contentType string, date time.Time) []byte {
+	header := strings.NewReplacer("\r", "", "\n", " ")
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&msg, "To: %s\r\n", header.Replace(to))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", header.Replace(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s; charset=UTF-8\r\n", contentType)
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(msg.String())
+}
+
+// key returns the notification signing key, generating it on first use.
+func (n *Notifier) key(ctx context.Context) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	secret, err := n.db.GetNotificationKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	if secret != "" {
+		return secret, nil
+	}
+
+	if secret, err = nostr.GenerateSecretKey(); err != nil {
+		return "", err
+	}
+	if err := n.db.SetNotificationKey(ctx, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
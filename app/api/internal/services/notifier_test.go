@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestNotifier_SavedSMTPSettings(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	var plainAddrs, tlsAddrs []string
+	var sent []string
+	notifier := NewNotifier(database, nil)
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		plainAddrs = append(plainAddrs, addr)
+		sent = append(sent, string(msg))
+		return nil
+	}
+	notifier.sendMailTLS = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		tlsAddrs = append(tlsAddrs, addr)
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	if notifier.EmailAvailable() {
+		t.Fatal("expected email to be unavailable without settings")
+	}
+	if err := notifier.SendEmail(ctx, "op@example.com", "Hi", "body"); err != ErrEmailNotConfigured {
+		t.Fatalf("expected ErrEmailNotConfigured, got %v", err)
+	}
+
+	// The config file's server is used until settings are saved
+	notifier.ConfigureSMTP(SMTPConfig{Host: "config.example.com", From: "relay@example.com"})
+	if err := notifier.SendEmail(ctx, "op@example.com", "Hi", "body"); err != nil {
+		t.Fatalf("SendEmail failed: %v", err)
+	}
+	if len(plainAddrs) != 1 || plainAddrs[0] != "config.example.com:587" {
+		t.Errorf("expected the config file's server, got %v", plainAddrs)
+	}
+
+	if err := database.SetSMTPSettings(ctx, &db.SMTPSettings{
+		Host: "saved.example.com", TLS: SMTPTLSImplicit, From: "alerts@example.com", AlertEmail: "op@example.com",
+	}); err != nil {
+		t.Fatalf("SetSMTPSettings failed: %v", err)
+	}
+	if err := notifier.SendEmail(ctx, "op@example.com", "Hi", "body"); err != nil {
+		t.Fatalf("SendEmail failed: %v", err)
+	}
+	if len(tlsAddrs) != 1 || tlsAddrs[0] != "saved.example.com:465" {
+		t.Errorf("expected the saved server over implicit TLS, got %v", tlsAddrs)
+	}
+
+	// Alerts reach the alert address without an operator to DM
+	if err := notifier.NotifyOperator(ctx, "Disk nearly full", "Roostr: disk is 96% full"); err != nil {
+		t.Fatalf("NotifyOperator failed: %v", err)
+	}
+	last := sent[len(sent)-1]
+	if len(tlsAddrs) != 2 || !strings.Contains(last, "To: op@example.com") || !strings.Contains(last, "Subject: Disk nearly full") {
+		t.Errorf("expected the alert to be emailed, got:\n%s", last)
+	}
+
+	// Removing the saved settings goes back to the config file
+	database.SetSMTPSettings(ctx, nil)
+	cfg, err := notifier.SMTP(ctx)
+	if err != nil || cfg.Host != "config.example.com" {
+		t.Errorf("expected the config file's server, got %+v, %v", cfg, err)
+	}
+}
+
+func TestNotifier_SendsAsOperator(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	notifier := NewNotifier(database, NewSigner(database))
+
+	// Without an operator signer, notifications come from the notification key
+	notificationPubkey, err := notifier.Pubkey(ctx)
+	if err != nil {
+		t.Fatalf("Pubkey failed: %v", err)
+	}
+	secret, _ := database.GetNotificationKey(ctx)
+	if want, _ := nostr.GetPublicKey(secret); notificationPubkey != want {
+		t.Errorf("expected the notification key, got %s", notificationPubkey)
+	}
+
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	operator, _ := nostr.GetPublicKey(operatorSecret)
+	if err := database.SetOperatorSigningKey(ctx, operatorSecret); err != nil {
+		t.Fatalf("SetOperatorSigningKey failed: %v", err)
+	}
+	if pubkey, _ := notifier.Pubkey(ctx); pubkey != operator {
+		t.Errorf("expected notifications from the operator, got %s", pubkey)
+	}
+
+	recipientSecret, _ := nostr.GenerateSecretKey()
+	recipient, _ := nostr.GetPublicKey(recipientSecret)
+	sender, err := notifier.sender(ctx)
+	if err != nil {
+		t.Fatalf("sender failed: %v", err)
+	}
+	content, err := sender.encrypt(ctx, recipient, "hello")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	event := nostr.NewEvent(4, [][]string{{"p", recipient}}, content)
+	if err := sender.sign(ctx, event); err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	if event.Pubkey != operator || event.Verify() != nil {
+		t.Errorf("expected a DM signed by the operator, got %+v", event)
+	}
+	if plaintext, err := nostr.NIP04Decrypt(recipientSecret, operator, content); err != nil || plaintext != "hello" {
+		t.Errorf("expected the recipient to read the DM, got %q, %v", plaintext, err)
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// Problems found by CheckNpubs.
+const (
+	NpubProblemMissing       = "missing"        // npub column is empty
+	NpubProblemMismatch      = "mismatch"       // npub does not encode the pubkey
+	NpubProblemInvalidPubkey = "invalid_pubkey" // pubkey is not 64 hex characters
+)
+
+// NpubIssue is a stored npub that does not match its pubkey.
+type NpubIssue struct {
+	Table    string `json:"table"`
+	Pubkey   string `json:"pubkey"`
+	Stored   string `json:"stored_npub"`
+	Expected string `json:"expected_npub,omitempty"` // Empty for invalid pubkeys
+	Problem  string `json:"problem"`
+	Fixed    bool   `json:"fixed"`
+}
+
+// NpubReport is the result of an npub consistency check.
+type NpubReport struct {
+	CheckedAt time.Time   `json:"checked_at"`
+	Checked   int         `json:"checked"` // Distinct pubkeys checked, per table
+	Issues    []NpubIssue `json:"issues"`
+	Fixed     int         `json:"fixed"`
+	DryRun    bool        `json:"dry_run"`
+}
+
+// CheckNpubs compares every stored npub with the npub encoded from its hex
+// pubkey. Unless dryRun is set, missing and mismatched npubs are replaced
+// with the encoded value. Invalid pubkeys are reported but left alone.
+func CheckNpubs(ctx context.Context, database *db.DB, dryRun bool) (*NpubReport, error) {
+	report := &NpubReport{CheckedAt: time.Now(), Issues: []NpubIssue{}, DryRun: dryRun}
+
+	for _, table := range db.NpubTables {
+		pairs, err := database.GetPubkeyNpubs(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		report.Checked += len(pairs)
+
+		for _, pair := range pairs {
+			issue := checkNpub(pair)
+			if issue == nil {
+				continue
+			}
+			issue.Table = table
+
+			if !dryRun && issue.Expected != "" {
+				if _, err := database.SetNpub(ctx, table, pair.Pubkey, issue.Expected); err != nil {
+					return nil, err
+				}
+				issue.Fixed = true
+				report.Fixed++
+			}
+			report.Issues = append(report.Issues, *issue)
+		}
+	}
+
+	if len(report.Issues) > 0 {
+		slog.Info("Npub consistency check", "issues", len(report.Issues), "fixed", report.Fixed, "dry_run", dryRun)
+	}
+	return report, nil
+}
+
+// checkNpub returns the issue with a stored pair, or nil if it is consistent.
+func checkNpub(pair db.PubkeyNpub) *NpubIssue {
+	issue := &NpubIssue{Pubkey: pair.Pubkey, Stored: pair.Npub}
+
+	expected, err := nostr.EncodeNpub(pair.Pubkey)
+	if err != nil {
+		issue.Problem = NpubProblemInvalidPubkey
+		return issue
+	}
+	if pair.Npub == expected {
+		return nil
+	}
+
+	issue.Expected = expected
+	if pair.Npub == "" {
+		issue.Problem = NpubProblemMissing
+	} else {
+		issue.Problem = NpubProblemMismatch
+	}
+	return issue
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestCheckNpubs(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	good := strings.Repeat("aa", 32)
+	goodNpub, _ := nostr.EncodeNpub(good)
+	wrong := strings.Repeat("bb", 32)
+	missing := strings.Repeat("cc", 32)
+	missingNpub, _ := nostr.EncodeNpub(missing)
+
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: good, Npub: goodNpub})
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: wrong, Npub: goodNpub})
+	database.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: "not-a-pubkey", Npub: "npub1x"})
+	for i, hash := range []string{"hash1", "hash2"} {
+		database.CreatePendingInvoice(ctx, &db.PendingInvoice{
+			PaymentHash: hash, Pubkey: missing, TierID: "monthly", AmountSats: int64(i + 1),
+			PaymentRequest: "lnbc1", ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}
+
+	report, err := CheckNpubs(ctx, database, true)
+	if err != nil {
+		t.Fatalf("CheckNpubs failed: %v", err)
+	}
+	if report.Checked != 4 || len(report.Issues) != 3 || report.Fixed != 0 {
+		t.Fatalf("unexpected dry-run report: %+v", report)
+	}
+	problems := map[string]string{}
+	for _, issue := range report.Issues {
+		problems[issue.Table+"/"+issue.Pubkey] = issue.Problem
+	}
+	if problems["whitelist_meta/"+wrong] != NpubProblemMismatch ||
+		problems["pending_invoices/"+missing] != NpubProblemMissing ||
+		problems["blacklist/not-a-pubkey"] != NpubProblemInvalidPubkey {
+		t.Errorf("unexpected issues: %v", problems)
+	}
+	if entry, _ := database.GetWhitelistEntryByPubkey(ctx, wrong); entry.Npub != goodNpub {
+		t.Error("expected a dry run to leave the npub unchanged")
+	}
+
+	report, err = CheckNpubs(ctx, database, false)
+	if err != nil {
+		t.Fatalf("CheckNpubs failed: %v", err)
+	}
+	if report.Fixed != 2 {
+		t.Errorf("expected 2 fixes, got %+v", report)
+	}
+	wrongNpub, _ := nostr.EncodeNpub(wrong)
+	if entry, _ := database.GetWhitelistEntryByPubkey(ctx, wrong); entry.Npub != wrongNpub {
+		t.Errorf("expected npub to be recomputed, got %s", entry.Npub)
+	}
+	invoice, _ := database.GetPendingInvoice(ctx, "hash2")
+	if invoice == nil || invoice.Npub != missingNpub {
+		t.Errorf("expected every invoice row to be fixed, got %+v", invoice)
+	}
+
+	if report, _ := CheckNpubs(ctx, database, false); len(report.Issues) != 1 {
+		t.Errorf("expected only the invalid pubkey to remain, got %+v", report.Issues)
+	}
+}
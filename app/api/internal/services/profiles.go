@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+const (
+	// profileRefreshAge is how long a cached profile is used before it is
+	// looked up again.
+	profileRefreshAge = 24 * time.Hour
+
+	// profileRefreshLimit bounds how many profiles one refresh looks up, so
+	// a large whitelist is spread over several runs.
+	profileRefreshLimit = 500
+)
+
+// ProfileService keeps kind-0 metadata (name, picture, NIP-05) cached for
+// every whitelisted, blacklisted and paid pubkey so the UI can show names
+// instead of hex keys. Profiles are read from the local relay first; pubkeys
+// without a stored profile are looked up on the sync relays.
+type ProfileService struct {
+	db       *db.DB
+	hardware *HardwareService
+	interval time.Duration
+	now      func() time.Time
+	relays   func(context.Context) []string
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+	refresh  sync.Mutex // serializes refreshes from the worker and handlers
+}
+
+// NewProfileService creates a new profile enrichment service.
+func NewProfileService(database *db.DB) *ProfileService {
+	return &ProfileService{
+		db:       database,
+		interval: time.Hour,
+		now:      time.Now,
+		relays: func(ctx context.Context) []string {
+			return SyncRelayURLs(ctx, database)
+		},
+		wakeCh: make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start begins the background profile worker. The first refresh runs
+// immediately.
+func (s *ProfileService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the profile worker.
+func (s *ProfileService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to refresh now, e.g. after pubkeys were added.
+func (s *ProfileService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ProfileService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.refreshDue(ctx)
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.refreshDue(ctx)
+		case <-s.wakeCh:
+			s.refreshDue(ctx)
+		}
+	}
+}
+
+// refreshDue looks up the tracked pubkeys that have no cached profile or
+// whose profile is older than profileRefreshAge (longer in low-power mode).
+func (s *ProfileService) refreshDue(ctx context.Context) {
+	pubkeys, err := s.trackedPubkeys(ctx)
+	if err != nil {
+		slog.Error("Failed to list pubkeys for profile refresh", "error", err)
+		return
+	}
+	cached, err := s.db.GetProfiles(ctx)
+	if err != nil {
+		slog.Error("Failed to get cached profiles", "error", err)
+		return
+	}
+
+	staleBefore := s.now().Add(-s.hardware.Tuning().refreshAge(profileRefreshAge))
+	var due []string
+	for _, pk := range pubkeys {
+		if p, ok := cached[pk]; !ok || p.FetchedAt.Before(staleBefore) {
+			due = append(due, pk)
+		}
+	}
+	// Never-fetched pubkeys first, then the oldest lookups
+	sort.SliceStable(due, func(i, j int) bool {
+		return fetchedAt(cached[due[i]]).Before(fetchedAt(cached[due[j]]))
+	})
+	if len(due) > profileRefreshLimit {
+		due = due[:profileRefreshLimit]
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	if _, err := s.Refresh(ctx, due); err != nil {
+		slog.Error("Failed to refresh profiles", "error", err)
+	}
+}
+
+func fetchedAt(p *db.Profile) time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	return p.FetchedAt
+}
+
+// Refresh looks up the given pubkeys now and returns their updated profiles.
+// A cached profile is only replaced by a newer kind-0 event.
+func (s *ProfileService) Refresh(ctx context.Context, pubkeys []string) (map[string]*db.Profile, error) {
+	s.refresh.Lock()
+	defer s.refresh.Unlock()
+
+	found, err := s.localProfiles(ctx, pubkeys)
+	if err != nil {
+		// The relay database may be unavailable; public relays still work
+		slog.Warn("Failed to read profiles from the relay database", "error", err)
+		found = make(map[string]*db.Profile)
+	}
+
+	var missing []string
+	for _, pk := range pubkeys {
+		if _, ok := found[pk]; !ok {
+			missing = append(missing, pk)
+		}
+	}
+	if len(missing) > 0 {
+		for pk, event := range fetchProfiles(ctx, missing, s.relays(ctx)) {
+			if p := profileFromEvent(pk, event.Content, event.CreatedAt, "remote"); p != nil {
+				found[pk] = p
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	now := s.now()
+	updated := make(map[string]*db.Profile, len(pubkeys))
+	for _, pk := range pubkeys {
+		p := found[pk]
+		current, err := s.db.GetProfile(ctx, pk)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil && current.EventCreatedAt != nil &&
+			(p == nil || p.EventCreatedAt.Before(*current.EventCreatedAt)) {
+			p = current
+		}
+		if p == nil {
+			p = &db.Profile{Pubkey: pk}
+		}
+		p.FetchedAt = now
+		if err := s.db.SaveProfile(ctx, p); err != nil {
+			return nil, err
+		}
+		updated[pk] = p
+	}
+
+	slog.Debug("Refreshed profiles", "requested", len(pubkeys), "found", len(found))
+	return updated, nil
+}
+
+// localProfiles reads the newest stored kind-0 event for each pubkey from the
+// relay database.
+func (s *ProfileService) localProfiles(ctx context.Context, pubkeys []string) (map[string]*db.Profile, error) {
+	profiles := make(map[string]*db.Profile)
+	for start := 0; start < len(pubkeys); start += profileBatchSize {
+		end := start + profileBatchSize
+		if end > len(pubkeys) {
+			end = len(pubkeys)
+		}
+		events, err := s.db.GetEvents(ctx, db.EventFilter{
+			Authors: pubkeys[start:end],
+			Kinds:   []int{0},
+			Limit:   1000,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Events are newest first, so the first one per author wins
+		for _, event := range events {
+			if _, ok := profiles[event.Pubkey]; ok {
+				continue
+			}
+			if p := profileFromEvent(event.Pubkey, event.Content, event.CreatedAt.Unix(), "local"); p != nil {
+				profiles[event.Pubkey] = p
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// trackedPubkeys returns the whitelisted, blacklisted and paid pubkeys,
+// sorted and de-duplicated.
+func (s *ProfileService) trackedPubkeys(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+
+	whitelist, err := s.db.GetWhitelistMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range whitelist {
+		seen[e.Pubkey] = true
+	}
+
+	blacklist, err := s.db.GetBlacklist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range blacklist {
+		seen[e.Pubkey] = true
+	}
+
+	paid, err := s.db.GetPaidUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range paid {
+		seen[u.Pubkey] = true
+	}
+
+	pubkeys := make([]string, 0, len(seen))
+	for pk := range seen {
+		if nostr.IsValidHexPubkey(pk) {
+			pubkeys = append(pubkeys, pk)
+		}
+	}
+	sort.Strings(pubkeys)
+	return pubkeys, nil
+}
+
+// maxProfileFieldLength caps cached profile fields, in bytes.
+const maxProfileFieldLength = 1024
+
+// profileFromEvent parses kind-0 metadata. It returns nil if the content is
+// not a JSON object.
+func profileFromEvent(pubkey, content string, createdAt int64, source string) *db.Profile {
+	var meta struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+		Picture     string `json:"picture"`
+		Nip05       string `json:"nip05"`
+	}
+	if json.Unmarshal([]byte(content), &meta) != nil {
+		return nil
+	}
+
+	eventCreatedAt := time.Unix(createdAt, 0)
+	p := &db.Profile{
+		Pubkey:         pubkey,
+		Name:           profileField(meta.Name),
+		DisplayName:    profileField(meta.DisplayName),
+		Nip05:          profileField(meta.Nip05),
+		Source:         source,
+		EventCreatedAt: &eventCreatedAt,
+	}
+	// Only keep web links; other schemes are not safe to render as images
+	if picture := profileField(meta.Picture); strings.HasPrefix(picture, "https://") || strings.HasPrefix(picture, "http://") {
+		p.Picture = picture
+	}
+	return p
+}
+
+func profileField(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > maxProfileFieldLength {
+		return ""
+	}
+	return value
+}
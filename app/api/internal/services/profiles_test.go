@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func insertProfileTestEvent(t *testing.T, relayDB *sql.DB, n byte, author string, createdAt int64, content string) {
+	t.Helper()
+	id := strings.Repeat(hex.EncodeToString([]byte{n}), 32)
+	event, _ := json.Marshal(map[string]interface{}{
+		"id": id, "pubkey": author, "created_at": createdAt, "kind": 0,
+		"tags": [][]string{}, "content": content, "sig": "",
+	})
+	hash, _ := hex.DecodeString(id)
+	authorBytes, _ := hex.DecodeString(author)
+	if _, err := relayDB.Exec(`INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content) VALUES (?, ?, ?, ?, 0, 0, ?)`,
+		hash, time.Now().Unix(), createdAt, authorBytes, string(event)); err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+}
+
+func TestProfileService_Refresh(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+	fake, relayURL := newFakeBackupRelay(t)
+
+	member := strings.Repeat("aa", 32)
+	paidSecret, _ := nostr.GenerateSecretKey()
+	paid, _ := nostr.GetPublicKey(paidSecret)
+	blocked := strings.Repeat("bb", 32)
+
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: member})
+	database.AddPaidUser(ctx, db.PaidUser{Pubkey: paid, Tier: "monthly", Status: "active"})
+	database.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: blocked})
+
+	// The member's profile is stored locally, in two versions
+	insertProfileTestEvent(t, relayDB, 1, member, 1700000000, `{"name":"old"}`)
+	insertProfileTestEvent(t, relayDB, 2, member, 1700000100, `{"name":"alice","picture":"https://example.com/a.png","nip05":"alice@example.com"}`)
+
+	// The paid user's profile is only on a public relay
+	remote := nostr.NewEvent(0, nil, `{"display_name":"Bob","picture":"javascript:alert(1)"}`)
+	remote.Sign(paidSecret)
+	fake.events = append(fake.events, remote)
+
+	svc := NewProfileService(database)
+	svc.relays = func(context.Context) []string { return []string{relayURL} }
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	svc.refreshDue(ctx)
+
+	profiles, _ := database.GetProfiles(ctx)
+	if len(profiles) != 3 {
+		t.Fatalf("expected 3 cached profiles, got %d", len(profiles))
+	}
+	if p := profiles[member]; p.Name != "alice" || p.Nip05 != "alice@example.com" || p.Source != "local" {
+		t.Errorf("unexpected local profile: %+v", p)
+	}
+	if p := profiles[paid]; p.DisplayName != "Bob" || p.Picture != "" || p.Source != "remote" {
+		t.Errorf("unexpected remote profile: %+v", p)
+	}
+	if p := profiles[blocked]; p.Source != "" || !p.FetchedAt.Equal(now) {
+		t.Errorf("expected an empty lookup to be recorded, got %+v", p)
+	}
+
+	t.Run("keeps_newer_cached_profile", func(t *testing.T) {
+		relayDB.Exec(`DELETE FROM event`)
+		insertProfileTestEvent(t, relayDB, 3, member, 1600000000, `{"name":"ancient"}`)
+
+		now = now.Add(profileRefreshAge + time.Minute)
+		svc.refreshDue(ctx)
+
+		if p, _ := database.GetProfile(ctx, member); p.Name != "alice" || !p.FetchedAt.Equal(now) {
+			t.Errorf("expected the newer cached profile to be kept, got %+v", p)
+		}
+	})
+}
+
+func TestProfileFromEvent(t *testing.T) {
+	pubkey := strings.Repeat("aa", 32)
+
+	p := profileFromEvent(pubkey, `{"name":" alice ","display_name":"Alice","picture":"https://example.com/a.png","nip05":"alice@example.com"}`, 100, "local")
+	if p.Name != "alice" || p.DisplayName != "Alice" || p.Picture != "https://example.com/a.png" ||
+		p.Nip05 != "alice@example.com" || p.EventCreatedAt.Unix() != 100 {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+
+	if p := profileFromEvent(pubkey, `{"picture":"data:image/png;base64,AAAA"}`, 100, "local"); p.Picture != "" {
+		t.Errorf("expected non-web picture to be dropped, got %q", p.Picture)
+	}
+	if p := profileFromEvent(pubkey, `{"name":"`+strings.Repeat("x", maxProfileFieldLength+1)+`"}`, 100, "local"); p.Name != "" {
+		t.Error("expected an oversized name to be dropped")
+	}
+	if p := profileFromEvent(pubkey, `not json`, 100, "local"); p != nil {
+		t.Errorf("expected nil for invalid content, got %+v", p)
+	}
+}
@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// quotaBreachRetention is how long breaches are kept.
+const quotaBreachRetention = 90 * 24 * time.Hour
+
+// ValidateQuotaPolicy checks that a policy names a known tier, sets at least
+// one limit and has a known action, defaulting the action to notify.
+func ValidateQuotaPolicy(policy *db.QuotaPolicy, tiers []db.PricingTier) error {
+	known := policy.Tier == db.KindPolicyTierWhitelist
+	for _, t := range tiers {
+		if t.ID == policy.Tier {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown tier: %s", policy.Tier)
+	}
+	if policy.EventsPerDay < 0 || policy.MaxStorageBytes < 0 {
+		return errors.New("limits cannot be negative")
+	}
+	if policy.EventsPerDay == 0 && policy.MaxStorageBytes == 0 {
+		return errors.New("set events_per_day, max_storage_bytes or both")
+	}
+	switch policy.Action {
+	case "":
+		policy.Action = db.QuotaActionNotify
+	case db.QuotaActionNotify, db.QuotaActionBlacklist:
+	default:
+		return fmt.Errorf("unknown action: %s", policy.Action)
+	}
+	return nil
+}
+
+// QuotaStatus is a member's usage against their tier's quota.
+type QuotaStatus struct {
+	Tier         string          `json:"tier,omitempty"`
+	Policy       *db.QuotaPolicy `json:"policy"` // nil when the tier has no quota
+	EventsToday  int64           `json:"events_today"`
+	StorageBytes int64           `json:"storage_bytes"`
+	Reached      []string        `json:"reached"` // quotas used up
+	ResetsAt     time.Time       `json:"resets_at"`
+}
+
+// reachedQuotas returns the quotas of policy that usage has used up.
+func reachedQuotas(policy *db.QuotaPolicy, usage db.AuthorUsage) []string {
+	reached := []string{}
+	if policy.EventsPerDay > 0 && usage.EventsSince >= policy.EventsPerDay {
+		reached = append(reached, db.QuotaEventsPerDay)
+	}
+	if policy.MaxStorageBytes > 0 && usage.StorageBytes >= policy.MaxStorageBytes {
+		reached = append(reached, db.QuotaStorage)
+	}
+	return reached
+}
+
+// QuotaCheck summarizes one pass over the members with a quota.
+type QuotaCheck struct {
+	Checked     int `json:"checked"`
+	Reached     int `json:"reached"`     // members with a quota used up
+	Breaches    int `json:"breaches"`    // new breaches recorded
+	Blacklisted int `json:"blacklisted"` // members blacklisted for them
+}
+
+// QuotaService enforces the per-tier quotas on events per day (UTC) and
+// stored bytes. Each minute it measures the usage of members whose tier has
+// a quota. The admission server turns away events from members who have
+// used up a quota; without it, quotas are enforced by the action alone.
+// The first time a member reaches a quota in a period they are told by DM,
+// and blacklisted if the policy says so.
+type QuotaService struct {
+	db        *db.DB
+	notifier  *Notifier
+	webhooks  *WebhookService
+	configMgr *relay.ConfigManager
+	relay     *relay.Relay
+	admission *AdmissionService
+	interval  time.Duration
+	now       func() time.Time
+	reached   map[string][]string // pubkey to quotas used up, as of the last check
+	reachedMu sync.RWMutex
+	checkMu   sync.Mutex
+	wakeCh    chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+}
+
+// NewQuotaService creates a new quota service.
+func NewQuotaService(database *db.DB, notifier *Notifier, configMgr *relay.ConfigManager, relayCtl *relay.Relay) *QuotaService {
+	return &QuotaService{
+		db:        database,
+		notifier:  notifier,
+		configMgr: configMgr,
+		relay:     relayCtl,
+		interval:  time.Minute,
+		now:       time.Now,
+		reached:   make(map[string][]string),
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins checking quotas in the background.
+func (s *QuotaService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the quota worker.
+func (s *QuotaService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to check now, e.g. after the policies change.
+func (s *QuotaService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *QuotaService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		case <-s.wakeCh:
+		}
+		if _, err := s.Check(context.Background()); err != nil {
+			slog.Error("Failed to check quotas", "error", err)
+		}
+	}
+}
+
+// Reached returns the quotas pubkey had used up at the last check.
+func (s *QuotaService) Reached(pubkey string) []string {
+	s.reachedMu.RLock()
+	defer s.reachedMu.RUnlock()
+	return s.reached[pubkey]
+}
+
+// Status returns pubkey's usage against its tier's quota, measured now.
+func (s *QuotaService) Status(ctx context.Context, pubkey string) (*QuotaStatus, error) {
+	tiers, err := LoadMemberTiers(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := s.db.GetQuotaPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dayStart := s.dayStart()
+	status := &QuotaStatus{Tier: tiers[pubkey], Reached: []string{}, ResetsAt: dayStart.AddDate(0, 0, 1)}
+	usage, err := s.db.GetAuthorUsage(ctx, []string{pubkey}, dayStart)
+	if err != nil {
+		return nil, err
+	}
+	status.EventsToday = usage[pubkey].EventsSince
+	status.StorageBytes = usage[pubkey].StorageBytes
+
+	for _, p := range policies {
+		if status.Tier != "" && p.Tier == status.Tier {
+			status.Policy = &p
+			status.Reached = reachedQuotas(&p, usage[pubkey])
+			break
+		}
+	}
+	return status, nil
+}
+
+// Check measures the usage of every member whose tier has a quota, records
+// and acts on new breaches, and updates what the admission server turns
+// away.
+func (s *QuotaService) Check(ctx context.Context) (*QuotaCheck, error) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	result := &QuotaCheck{}
+	policies, err := s.db.GetQuotaPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 || !s.db.IsRelayDBConnected() {
+		s.setReached(map[string][]string{})
+		return result, nil
+	}
+
+	byTier := make(map[string]*db.QuotaPolicy, len(policies))
+	for i := range policies {
+		byTier[policies[i].Tier] = &policies[i]
+	}
+	tiers, err := LoadMemberTiers(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	operator, _ := s.db.GetAppState(ctx, "operator_pubkey")
+	var pubkeys []string
+	for pk, tier := range tiers {
+		if byTier[tier] != nil && pk != operator {
+			pubkeys = append(pubkeys, pk)
+		}
+	}
+	slices.Sort(pubkeys)
+
+	dayStart := s.dayStart()
+	usage, err := s.db.GetAuthorUsage(ctx, pubkeys, dayStart)
+	if err != nil {
+		return nil, err
+	}
+
+	reached := make(map[string][]string)
+	for _, pk := range pubkeys {
+		result.Checked++
+		policy := byTier[tiers[pk]]
+		quotas := reachedQuotas(policy, usage[pk])
+		if !slices.Contains(quotas, db.QuotaStorage) {
+			// Reaching the storage quota again after freeing space is news
+			if err := s.db.ClearQuotaBreach(ctx, pk, db.QuotaStorage, ""); err != nil {
+				return nil, err
+			}
+		}
+		if len(quotas) == 0 {
+			continue
+		}
+		reached[pk] = quotas
+		result.Reached++
+
+		for _, quota := range quotas {
+			breach := db.QuotaBreach{Pubkey: pk, Quota: quota, Action: policy.Action}
+			switch quota {
+			case db.QuotaEventsPerDay:
+				breach.Period = dayStart.Format("2006-01-02")
+				breach.Usage, breach.Limit = usage[pk].EventsSince, policy.EventsPerDay
+			case db.QuotaStorage:
+				breach.Usage, breach.Limit = usage[pk].StorageBytes, policy.MaxStorageBytes
+			}
+			added, err := s.db.RecordQuotaBreach(ctx, breach)
+			if err != nil {
+				return nil, err
+			}
+			if !added {
+				continue
+			}
+			result.Breaches++
+			blacklisted, err := s.breached(ctx, breach, policy.Tier)
+			if err != nil {
+				return nil, err
+			}
+			if blacklisted {
+				result.Blacklisted++
+			}
+		}
+	}
+	s.setReached(reached)
+
+	if _, err := s.db.DeleteQuotaBreachesBefore(ctx, s.now().Add(-quotaBreachRetention)); err != nil {
+		slog.Warn("Failed to prune quota breaches", "error", err)
+	}
+	if result.Blacklisted > 0 {
+		applyAccessLists(ctx, s.db, s.configMgr, s.relay, s.admission)
+	}
+	if result.Breaches > 0 {
+		slog.Info("Checked quotas", "checked", result.Checked, "breaches", result.Breaches, "blacklisted", result.Blacklisted)
+	}
+	return result, nil
+}
+
+// breached acts on a new breach: it is logged, emitted as a webhook, the
+// member is told, and blacklisted if the policy says so. It reports whether
+// the member was blacklisted.
+func (s *QuotaService) breached(ctx context.Context, breach db.QuotaBreach, tier string) (bool, error) {
+	npub, _ := nostr.EncodeNpub(breach.Pubkey)
+	s.db.AddAuditLog(ctx, "quota_exceeded", map[string]interface{}{
+		"pubkey": breach.Pubkey,
+		"tier":   tier,
+		"quota":  breach.Quota,
+		"usage":  breach.Usage,
+		"limit":  breach.Limit,
+		"action": breach.Action,
+	}, "quotas")
+	s.webhooks.Emit(WebhookEventQuotaExceeded, map[string]interface{}{
+		"pubkey": breach.Pubkey,
+		"npub":   npub,
+		"tier":   tier,
+		"quota":  breach.Quota,
+		"usage":  breach.Usage,
+		"limit":  breach.Limit,
+		"action": breach.Action,
+	})
+
+	blacklist := breach.Action == db.QuotaActionBlacklist
+	if blacklist {
+		reason := fmt.Sprintf("Reached the %s quota", breach.Quota)
+		if err := s.db.AddBlacklistEntry(ctx, db.BlacklistEntry{Pubkey: breach.Pubkey, Npub: npub, Reason: reason}); err != nil {
+			return false, fmt.Errorf("failed to blacklist %s: %w", breach.Pubkey, err)
+		}
+	}
+	s.notify(ctx, breach, blacklist)
+	return blacklist, nil
+}
+
+// notify tells a member by DM that they reached a quota.
+func (s *QuotaService) notify(ctx context.Context, breach db.QuotaBreach, blacklisted bool) {
+	if s.notifier == nil {
+		return
+	}
+
+	var message string
+	switch breach.Quota {
+	case db.QuotaEventsPerDay:
+		message = fmt.Sprintf("You have reached your quota of %d events a day on this relay.", breach.Limit)
+		if !blacklisted {
+			message += " New events are accepted again after midnight UTC."
+		}
+	default:
+		message = fmt.Sprintf("You have used your %.1f MB of storage on this relay.", float64(breach.Limit)/1e6)
+		if !blacklisted {
+			message += " Delete some events to post again."
+		}
+	}
+	if blacklisted {
+		message += " Your pubkey has been blocked; contact the operator to restore it."
+	}
+	if err := s.notifier.SendDM(ctx, breach.Pubkey, message); err != nil {
+		slog.Warn("Failed to send quota DM", "pubkey", breach.Pubkey, "error", err)
+	}
+}
+
+func (s *QuotaService) setReached(reached map[string][]string) {
+	s.reachedMu.Lock()
+	s.reached = reached
+	s.reachedMu.Unlock()
+}
+
+// dayStart returns the start of the current UTC day, when daily quotas reset.
+func (s *QuotaService) dayStart() time.Time {
+	now := s.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
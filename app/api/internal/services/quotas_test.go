@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nauthz"
+)
+
+func TestValidateQuotaPolicy(t *testing.T) {
+	tiers := []db.PricingTier{{ID: "monthly"}}
+	tests := []struct {
+		policy db.QuotaPolicy
+		valid  bool
+	}{
+		{db.QuotaPolicy{Tier: "whitelist", EventsPerDay: 100}, true},
+		{db.QuotaPolicy{Tier: "monthly", MaxStorageBytes: 1 << 20, Action: "blacklist"}, true},
+		{db.QuotaPolicy{Tier: "yearly", EventsPerDay: 100}, false},
+		{db.QuotaPolicy{Tier: "whitelist"}, false},
+		{db.QuotaPolicy{Tier: "whitelist", EventsPerDay: -1}, false},
+		{db.QuotaPolicy{Tier: "whitelist", EventsPerDay: 100, Action: "delete"}, false},
+	}
+	for _, tt := range tests {
+		err := ValidateQuotaPolicy(&tt.policy, tiers)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateQuotaPolicy(%+v) = %v, want valid %v", tt.policy, err, tt.valid)
+		}
+		if err == nil && tt.policy.Action == "" {
+			t.Errorf("expected the action defaulted for %+v", tt.policy)
+		}
+	}
+}
+
+func TestQuotaService_Check(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	member := strings.Repeat("a1", 32)
+	paid := strings.Repeat("b2", 32)
+	quiet := strings.Repeat("c3", 32)
+	for _, pk := range []string{member, paid, quiet} {
+		database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: pk, Npub: "npub1" + pk[:8]})
+	}
+	database.AddPaidUser(ctx, db.PaidUser{Pubkey: paid, Tier: "monthly", Status: "active"})
+	database.SetQuotaPolicies(ctx, []db.QuotaPolicy{
+		{Tier: db.KindPolicyTierWhitelist, EventsPerDay: 2, Action: db.QuotaActionNotify},
+		{Tier: "monthly", MaxStorageBytes: 100, Action: db.QuotaActionBlacklist},
+	})
+
+	now := time.Now().Unix()
+	insertModerationTestEvent(t, relayDB, 1, member, now, "one")
+	insertModerationTestEvent(t, relayDB, 2, member, now, "two")
+	insertModerationTestEvent(t, relayDB, 3, member, now-3*86400, "old, not counted today")
+	insertModerationTestEvent(t, relayDB, 4, paid, now-3*86400, strings.Repeat("x", 200))
+	insertModerationTestEvent(t, relayDB, 5, quiet, now, "hi")
+
+	svc := NewQuotaService(database, nil, nil, nil)
+	result, err := svc.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.Checked != 3 || result.Reached != 2 || result.Breaches != 2 || result.Blacklisted != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if got := svc.Reached(member); len(got) != 1 || got[0] != db.QuotaEventsPerDay {
+		t.Errorf("expected member over the daily quota, got %v", got)
+	}
+	if got := svc.Reached(quiet); len(got) != 0 {
+		t.Errorf("expected quiet member under quota, got %v", got)
+	}
+	if blacklist, _ := database.GetBlacklist(ctx); len(blacklist) != 1 || blacklist[0].Pubkey != paid {
+		t.Errorf("expected the paid member blacklisted, got %+v", blacklist)
+	}
+
+	// Breaches are acted on once per period
+	if result, err = svc.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.Reached != 2 || result.Breaches != 0 {
+		t.Errorf("expected no new breaches, got %+v", result)
+	}
+	breaches, _ := database.GetQuotaBreaches(ctx, member, 10)
+	if len(breaches) != 1 || breaches[0].Usage != 2 || breaches[0].Limit != 2 || breaches[0].Period == "" {
+		t.Errorf("unexpected breaches: %+v", breaches)
+	}
+
+	status, err := svc.Status(ctx, member)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Tier != db.KindPolicyTierWhitelist || status.Policy == nil || status.EventsToday != 2 || len(status.Reached) != 1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	// The admission server turns the member away until the quota resets
+	admission := NewAdmissionService(database, nil)
+	admission.quotas = svc
+	database.SetAccessMode(ctx, "whitelist")
+	reply := admission.EventAdmit(ctx, &nauthz.EventRequest{Event: nauthz.Event{Pubkey: member, Kind: 1}})
+	if reply.Decision == nauthz.DecisionPermit || !strings.HasPrefix(reply.Message, "rate-limited: daily event quota") {
+		t.Errorf("expected the member denied, got %+v", reply)
+	}
+	reply = admission.EventAdmit(ctx, &nauthz.EventRequest{Event: nauthz.Event{Pubkey: quiet, Kind: 1}})
+	if reply.Decision != nauthz.DecisionPermit {
+		t.Errorf("expected the quiet member permitted, got %+v", reply)
+	}
+}
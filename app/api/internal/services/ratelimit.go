@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Rate limit route groups.
+const (
+	RateGroupSignup = "signup"
+	RateGroupPublic = "public"
+	RateGroupAPI    = "api"
+	RateGroupEvents = "events"
+)
+
+// IP ban sources.
+const (
+	IPBanManual = "manual"
+	IPBanAuto   = "auto"
+)
+
+// bucketIdleTimeout is how long an unused bucket is kept; a bucket idle this
+// long is full again anyway.
+const bucketIdleTimeout = 10 * time.Minute
+
+// RateDecision is the outcome of checking a request.
+type RateDecision struct {
+	Allowed    bool
+	Banned     bool
+	RetryAfter time.Duration // Set when rate limited
+}
+
+// RateLimitService applies per-IP token bucket rate limits to the API and
+// enforces IP bans. Bans are kept in memory and persisted, and clients that
+// keep exceeding the public limits are banned automatically.
+type RateLimitService struct {
+	db       *db.DB
+	interval time.Duration
+	now      func() time.Time
+
+	mu         sync.Mutex
+	settings   db.RateLimitSettings
+	buckets    map[string]*tokenBucket // group + address -> bucket
+	violations map[netip.Addr]*violationWindow
+	bans       []activeBan
+
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running bool
+	runMu   sync.Mutex
+}
+
+// tokenBucket holds the tokens left for one client and route group.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// violationWindow counts rejected requests in the current minute.
+type violationWindow struct {
+	start time.Time
+	count int
+}
+
+// activeBan is a ban parsed for matching.
+type activeBan struct {
+	prefix    netip.Prefix
+	expiresAt time.Time // zero for permanent bans
+}
+
+// NewRateLimitService creates a new rate limit service with the default
+// settings; Start loads the saved settings and bans.
+func NewRateLimitService(database *db.DB) *RateLimitService {
+	return &RateLimitService{
+		db:         database,
+		interval:   time.Minute,
+		now:        time.Now,
+		settings:   db.DefaultRateLimitSettings,
+		buckets:    make(map[string]*tokenBucket),
+		violations: make(map[netip.Addr]*violationWindow),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start loads the settings and bans and begins pruning expired state.
+func (s *RateLimitService) Start() {
+	s.runMu.Lock()
+	if s.running {
+		s.runMu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.runMu.Unlock()
+
+	if err := s.Reload(context.Background()); err != nil {
+		slog.Error("Failed to load rate limits and IP bans", "error", err)
+	}
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the pruning loop.
+func (s *RateLimitService) Stop() {
+	s.runMu.Lock()
+	if !s.running {
+		s.runMu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.runMu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *RateLimitService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.prune(context.Background())
+		}
+	}
+}
+
+// Reload reads the settings and bans from the database.
+func (s *RateLimitService) Reload(ctx context.Context) error {
+	settings, err := s.db.GetRateLimitSettings(ctx)
+	if err != nil {
+		return err
+	}
+	bans, err := s.db.GetIPBans(ctx, s.now())
+	if err != nil {
+		return err
+	}
+
+	active := make([]activeBan, 0, len(bans))
+	for _, ban := range bans {
+		prefix, err := ParseIPBan(ban.IP)
+		if err != nil {
+			slog.Warn("Ignoring invalid IP ban", "ip", ban.IP, "error", err)
+			continue
+		}
+		b := activeBan{prefix: prefix}
+		if ban.ExpiresAt != nil {
+			b.expiresAt = *ban.ExpiresAt
+		}
+		active = append(active, b)
+	}
+
+	s.mu.Lock()
+	s.settings = *settings
+	s.bans = active
+	s.mu.Unlock()
+	return nil
+}
+
+// Check decides whether a request from addr to a route group may proceed,
+// taking a token when it may. Banned addresses are always refused; an empty
+// group and loopback addresses are not rate limited.
+func (s *RateLimitService) Check(ctx context.Context, addr netip.Addr, group string) RateDecision {
+	addr = addr.Unmap()
+	now := s.now()
+
+	s.mu.Lock()
+	if s.isBannedLocked(addr, now) {
+		s.mu.Unlock()
+		return RateDecision{Banned: true}
+	}
+
+	limit := s.limitLocked(group)
+	if !s.settings.Enabled || limit.PerMinute <= 0 || addr.IsLoopback() {
+		s.mu.Unlock()
+		return RateDecision{Allowed: true}
+	}
+
+	ok, retryAfter := s.takeLocked(group+" "+addr.String(), limit, now)
+	if ok {
+		s.mu.Unlock()
+		return RateDecision{Allowed: true}
+	}
+
+	// Only abuse of the public routes bans; the admin API is the operator's
+	ban := group != RateGroupAPI && s.violationLocked(addr, now)
+	settings := s.settings
+	s.mu.Unlock()
+
+	if ban {
+		expires := now.Add(time.Duration(settings.AutoBanMinutes) * time.Minute)
+		reason := fmt.Sprintf("Exceeded the %s rate limit %d times in a minute", group, settings.AutoBanViolations)
+		if err := s.Ban(ctx, addr.String(), reason, IPBanAuto, &expires); err != nil {
+			slog.ErrorContext(ctx, "Failed to ban IP", "ip", addr, "error", err)
+		} else {
+			slog.WarnContext(ctx, "Banned IP for exceeding rate limits", "ip", addr, "group", group, "until", expires)
+			s.db.AddAuditLog(ctx, "ip_banned", map[string]interface{}{
+				"ip":         addr.String(),
+				"reason":     reason,
+				"source":     IPBanAuto,
+				"expires_at": expires,
+			}, "")
+		}
+	}
+	return RateDecision{RetryAfter: retryAfter}
+}
+
+// CheckAuthor decides whether an author may publish another event, taking
+// a token when they may. Authors are not banned for exceeding the limit.
+func (s *RateLimitService) CheckAuthor(pubkey string) RateDecision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := s.settings.Events
+	if !s.settings.Enabled || limit.PerMinute <= 0 {
+		return RateDecision{Allowed: true}
+	}
+	ok, retryAfter := s.takeLocked(RateGroupEvents+" "+pubkey, limit, s.now())
+	return RateDecision{Allowed: ok, RetryAfter: retryAfter}
+}
+
+// IsBanned reports whether addr is banned.
+func (s *RateLimitService) IsBanned(addr netip.Addr) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isBannedLocked(addr.Unmap(), s.now())
+}
+
+// limitLocked returns the limit for a route group.
+func (s *RateLimitService) limitLocked(group string) db.RateLimit {
+	switch group {
+	case RateGroupSignup:
+		return s.settings.Signup
+	case RateGroupPublic:
+		return s.settings.Public
+	case RateGroupAPI:
+		return s.settings.API
+	case RateGroupEvents:
+		return s.settings.Events
+	}
+	return db.RateLimit{}
+}
+
+// takeLocked takes a token from the bucket, returning how long until one is
+// available when it is empty.
+func (s *RateLimitService) takeLocked(key string, limit db.RateLimit, now time.Time) (bool, time.Duration) {
+	burst := float64(max(limit.Burst, 1))
+	rate := float64(limit.PerMinute) / 60 // tokens per second
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// violationLocked counts a rejected request and reports whether the address
+// has now earned an automatic ban.
+func (s *RateLimitService) violationLocked(addr netip.Addr, now time.Time) bool {
+	if s.settings.AutoBanViolations <= 0 || s.settings.AutoBanMinutes <= 0 {
+		return false
+	}
+	v, ok := s.violations[addr]
+	if !ok || now.Sub(v.start) >= time.Minute {
+		v = &violationWindow{start: now}
+		s.violations[addr] = v
+	}
+	v.count++
+	if v.count < s.settings.AutoBanViolations {
+		return false
+	}
+	delete(s.violations, addr)
+	return true
+}
+
+// isBannedLocked reports whether a ban in effect covers addr.
+func (s *RateLimitService) isBannedLocked(addr netip.Addr, now time.Time) bool {
+	for _, ban := range s.bans {
+		if ban.prefix.Contains(addr) && (ban.expiresAt.IsZero() || now.Before(ban.expiresAt)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ban bans an address or CIDR range until expiresAt, or permanently when nil.
+func (s *RateLimitService) Ban(ctx context.Context, ip, reason, source string, expiresAt *time.Time) error {
+	prefix, err := ParseIPBan(ip)
+	if err != nil {
+		return err
+	}
+	if err := s.db.AddIPBan(ctx, &db.IPBan{IP: FormatIPBan(prefix), Reason: reason, Source: source, ExpiresAt: expiresAt}); err != nil {
+		return err
+	}
+	return s.Reload(ctx)
+}
+
+// Unban lifts a ban, reporting whether there was one.
+func (s *RateLimitService) Unban(ctx context.Context, ip string) (bool, error) {
+	prefix, err := ParseIPBan(ip)
+	if err != nil {
+		return false, err
+	}
+	removed, err := s.db.RemoveIPBan(ctx, FormatIPBan(prefix))
+	if err != nil {
+		return false, err
+	}
+	return removed, s.Reload(ctx)
+}
+
+// prune drops idle buckets, stale violation counts and expired bans.
+func (s *RateLimitService) prune(ctx context.Context) {
+	now := s.now()
+
+	s.mu.Lock()
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > bucketIdleTimeout {
+			delete(s.buckets, key)
+		}
+	}
+	for addr, v := range s.violations {
+		if now.Sub(v.start) >= time.Minute {
+			delete(s.violations, addr)
+		}
+	}
+	expired := false
+	for _, ban := range s.bans {
+		if !ban.expiresAt.IsZero() && !now.Before(ban.expiresAt) {
+			expired = true
+		}
+	}
+	s.mu.Unlock()
+
+	if !expired {
+		return
+	}
+	if _, err := s.db.DeleteExpiredIPBans(ctx, now); err != nil {
+		slog.Error("Failed to delete expired IP bans", "error", err)
+		return
+	}
+	if err := s.Reload(ctx); err != nil {
+		slog.Error("Failed to reload IP bans", "error", err)
+	}
+}
+
+// ParseIPBan parses an address or CIDR range to ban.
+func ParseIPBan(ip string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(ip)
+	if err != nil {
+		return netip.Prefix{}, errors.New("ip must be an IP address or CIDR range")
+	}
+	return prefix.Masked(), nil
+}
+
+// FormatIPBan formats a ban's prefix as stored: a bare address for a single
+// host, CIDR notation otherwise.
+func FormatIPBan(prefix netip.Prefix) string {
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+// ValidateRateLimitSettings checks that limits and ban settings are not
+// negative and that enabled limits allow at least one request at once.
+func ValidateRateLimitSettings(settings *db.RateLimitSettings) error {
+	limits := map[string]db.RateLimit{
+		RateGroupSignup: settings.Signup,
+		RateGroupPublic: settings.Public,
+		RateGroupAPI:    settings.API,
+		RateGroupEvents: settings.Events,
+	}
+	for group, limit := range limits {
+		if limit.PerMinute < 0 || limit.Burst < 0 {
+			return fmt.Errorf("%s limits cannot be negative", group)
+		}
+		if limit.PerMinute > 0 && limit.Burst < 1 {
+			return fmt.Errorf("%s burst must be at least 1", group)
+		}
+	}
+	if settings.AutoBanViolations < 0 || settings.AutoBanMinutes < 0 {
+		return errors.New("auto-ban settings cannot be negative")
+	}
+	return nil
+}
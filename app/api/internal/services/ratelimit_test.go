@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestRateLimitService_TokenBucket(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	database.SetRateLimitSettings(ctx, &db.RateLimitSettings{
+		Enabled: true,
+		Signup:  db.RateLimit{PerMinute: 6, Burst: 2},
+		API:     db.RateLimit{PerMinute: 0},
+	})
+
+	svc := NewRateLimitService(database)
+	now := time.Unix(1700000000, 0)
+	svc.now = func() time.Time { return now }
+	if err := svc.Reload(ctx); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	client := netip.MustParseAddr("203.0.113.7")
+	for i := 0; i < 2; i++ {
+		if d := svc.Check(ctx, client, RateGroupSignup); !d.Allowed {
+			t.Fatalf("expected request %d within the burst to be allowed", i+1)
+		}
+	}
+	d := svc.Check(ctx, client, RateGroupSignup)
+	if d.Allowed || d.Banned || d.RetryAfter != 10*time.Second {
+		t.Fatalf("expected a 10s wait after the burst, got %+v", d)
+	}
+
+	// Another client has its own bucket
+	if d := svc.Check(ctx, netip.MustParseAddr("203.0.113.8"), RateGroupSignup); !d.Allowed {
+		t.Error("expected another client to be allowed")
+	}
+	// Loopback, unlimited groups and routes outside any group are not limited
+	if d := svc.Check(ctx, netip.MustParseAddr("127.0.0.1"), RateGroupSignup); !d.Allowed {
+		t.Error("expected loopback to be allowed")
+	}
+	if d := svc.Check(ctx, client, RateGroupAPI); !d.Allowed {
+		t.Error("expected a disabled limit to allow")
+	}
+
+	now = now.Add(10 * time.Second)
+	if d := svc.Check(ctx, client, RateGroupSignup); !d.Allowed {
+		t.Error("expected a token to refill after 10s")
+	}
+}
+
+func TestRateLimitService_Bans(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	database.SetRateLimitSettings(ctx, &db.RateLimitSettings{
+		Enabled:           true,
+		Public:            db.RateLimit{PerMinute: 60, Burst: 1},
+		API:               db.RateLimit{PerMinute: 60, Burst: 1},
+		AutoBanViolations: 3,
+		AutoBanMinutes:    30,
+	})
+
+	svc := NewRateLimitService(database)
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+	svc.Reload(ctx)
+
+	// A range ban covers every address in it
+	if err := svc.Ban(ctx, "198.51.100.99/24", "scanner", IPBanManual, nil); err != nil {
+		t.Fatalf("Ban failed: %v", err)
+	}
+	if d := svc.Check(ctx, netip.MustParseAddr("198.51.100.4"), ""); !d.Banned {
+		t.Error("expected an address in the range to be banned")
+	}
+	bans, _ := database.GetIPBans(ctx, now)
+	if len(bans) != 1 || bans[0].IP != "198.51.100.0/24" || bans[0].ExpiresAt != nil {
+		t.Errorf("expected the range stored masked and permanent, got %+v", bans)
+	}
+
+	// Abusing the admin API is only rate limited
+	admin := netip.MustParseAddr("192.0.2.1")
+	for i := 0; i < 10; i++ {
+		svc.Check(ctx, admin, RateGroupAPI)
+	}
+	if d := svc.Check(ctx, admin, ""); d.Banned {
+		t.Error("expected the admin API not to auto-ban")
+	}
+
+	// Abusing the public routes bans
+	abuser := netip.MustParseAddr("192.0.2.2")
+	svc.Check(ctx, abuser, RateGroupPublic)
+	for i := 0; i < 3; i++ {
+		if d := svc.Check(ctx, abuser, RateGroupPublic); d.Allowed {
+			t.Fatalf("expected request %d to be limited", i+2)
+		}
+	}
+	if d := svc.Check(ctx, abuser, RateGroupPublic); !d.Banned {
+		t.Fatal("expected the abuser to be banned")
+	}
+	bans, _ = database.GetIPBans(ctx, now)
+	if len(bans) != 2 || bans[0].IP != "192.0.2.2" || bans[0].Source != IPBanAuto || bans[0].ExpiresAt == nil {
+		t.Errorf("expected an expiring auto ban, got %+v", bans)
+	}
+
+	// Expired bans are lifted and pruned
+	now = now.Add(31 * time.Minute)
+	if d := svc.Check(ctx, abuser, ""); d.Banned {
+		t.Error("expected the auto ban to expire")
+	}
+	svc.prune(ctx)
+	if bans, _ := database.GetIPBans(ctx, time.Unix(0, 0)); len(bans) != 1 {
+		t.Errorf("expected the expired ban deleted, got %+v", bans)
+	}
+
+	if removed, err := svc.Unban(ctx, "198.51.100.0/24"); err != nil || !removed {
+		t.Fatalf("expected the range unbanned, got %v (%v)", removed, err)
+	}
+	if d := svc.Check(ctx, netip.MustParseAddr("198.51.100.4"), ""); d.Banned {
+		t.Error("expected the range to be unbanned")
+	}
+}
+
+func TestParseIPBan(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.7":        "203.0.113.7",
+		"::ffff:203.0.113.7": "203.0.113.7",
+		"203.0.113.7/24":     "203.0.113.0/24",
+		"2001:db8::1/32":     "2001:db8::/32",
+	}
+	for input, want := range tests {
+		prefix, err := ParseIPBan(input)
+		if err != nil || FormatIPBan(prefix) != want {
+			t.Errorf("ParseIPBan(%q) = %v (%v), want %s", input, prefix, err, want)
+		}
+	}
+	if _, err := ParseIPBan("example.com"); err == nil {
+		t.Error("expected a hostname to be rejected")
+	}
+}
+
+func TestValidateRateLimitSettings(t *testing.T) {
+	valid := db.DefaultRateLimitSettings
+	if err := ValidateRateLimitSettings(&valid); err != nil {
+		t.Errorf("expected defaults to be valid, got %v", err)
+	}
+	invalid := []db.RateLimitSettings{
+		{Signup: db.RateLimit{PerMinute: -1}},
+		{Public: db.RateLimit{PerMinute: 10, Burst: 0}},
+		{AutoBanMinutes: -5},
+	}
+	for _, settings := range invalid {
+		if err := ValidateRateLimitSettings(&settings); err == nil {
+			t.Errorf("expected %+v to be invalid", settings)
+		}
+	}
+}
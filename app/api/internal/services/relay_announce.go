@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+const (
+	// RelayDiscoveryKind is the NIP-66 relay discovery kind. It is
+	// addressable by the relay URL, so relays keep only the latest listing.
+	RelayDiscoveryKind = 30166
+
+	// RelayListKind is the NIP-65 relay list kind. It is replaceable, so a
+	// new list replaces the operator's whole list.
+	RelayListKind = 10002
+
+	relayAnnouncementTimeout = 15 * time.Second
+)
+
+// ErrNoRelayURL is returned when the relay's public URL is not known.
+var ErrNoRelayURL = errors.New("relay URL not set: set relay_url in config.toml or the announcement settings")
+
+// RelayAnnouncement is the discovery listing of the relay: its NIP-11
+// document and the NIP-66 tags that let clients filter relays by network,
+// policy and location.
+type RelayAnnouncement struct {
+	RelayURL string             `json:"relay_url"`
+	Tags     [][]string         `json:"tags"`
+	Info     *RelayInfoDocument `json:"info"`
+}
+
+// hash returns a digest of the announcement, so an unchanged listing is
+// only republished when it is due.
+func (a *RelayAnnouncement) hash() string {
+	data, _ := json.Marshal(a)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RelayAnnouncementService publishes the relay's discovery listing and adds
+// the relay to the operator's relay list, signed as the operator, and
+// refreshes them so the listing does not go stale.
+type RelayAnnouncementService struct {
+	db             *db.DB
+	configMgr      *relay.ConfigManager
+	signer         *Signer
+	publish        func(ctx context.Context, url string, event *nostr.SyncEvent) error
+	fetchRelayList func(ctx context.Context, pubkey string, relays []string) (*nostr.SyncEvent, error)
+	interval       time.Duration
+	wakeCh         chan struct{}
+	stopCh         chan struct{}
+	wg             sync.WaitGroup
+	running        bool
+	mu             sync.Mutex
+	publishMu      sync.Mutex
+}
+
+// NewRelayAnnouncementService creates a new relay announcement service.
+func NewRelayAnnouncementService(database *db.DB, configMgr *relay.ConfigManager, signer *Signer) *RelayAnnouncementService {
+	return &RelayAnnouncementService{
+		db:             database,
+		configMgr:      configMgr,
+		signer:         signer,
+		publish:        publishToRelay,
+		fetchRelayList: fetchRelayList,
+		interval:       time.Hour,
+		wakeCh:         make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// Start begins the background announcement worker.
+func (s *RelayAnnouncementService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the announcement worker.
+func (s *RelayAnnouncementService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to check for changes now, e.g. after settings change.
+func (s *RelayAnnouncementService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// run republishes the announcement when enabled and it changed or the
+// refresh interval has passed since the last one.
+func (s *RelayAnnouncementService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.publishIfDue()
+		case <-s.wakeCh:
+			s.publishIfDue()
+		}
+	}
+}
+
+func (s *RelayAnnouncementService) publishIfDue() {
+	ctx := context.Background()
+
+	settings, err := s.db.GetRelayAnnouncementSettings(ctx)
+	if err != nil || !settings.Enabled {
+		return
+	}
+
+	announcement, err := s.Build(ctx, settings)
+	if err != nil {
+		slog.Warn("Failed to build relay announcement", "error", err)
+		return
+	}
+
+	last, _ := s.db.GetLastRelayAnnouncement(ctx)
+	interval := time.Duration(settings.IntervalHours) * time.Hour
+	if last != nil && last.Hash == announcement.hash() && time.Since(last.At) < interval {
+		return
+	}
+
+	if _, err := s.publishAnnouncement(ctx, settings, announcement); err != nil {
+		slog.Error("Relay announcement failed", "error", err)
+	}
+}
+
+// Build generates the announcement from the relay config, the NIP-11
+// settings and the announcement settings.
+func (s *RelayAnnouncementService) Build(ctx context.Context, settings *db.RelayAnnouncementSettings) (*RelayAnnouncement, error) {
+	var cfg *relay.Config
+	if s.configMgr != nil {
+		if c, err := s.configMgr.Read(); err == nil {
+			cfg = c
+		}
+	}
+
+	relayURL := settings.RelayURL
+	if relayURL == "" && cfg != nil {
+		relayURL = cfg.Info.RelayURL
+	}
+	relayURL = strings.TrimRight(strings.TrimSpace(relayURL), "/")
+	u, err := url.Parse(relayURL)
+	if relayURL == "" || err != nil || (u.Scheme != "wss" && u.Scheme != "ws") || u.Hostname() == "" {
+		return nil, ErrNoRelayURL
+	}
+
+	info, err := BuildRelayInfo(ctx, s.db, cfg)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := s.db.GetExcludedKinds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := [][]string{
+		{"d", relayURL},
+		{"n", relayNetwork(u.Hostname())},
+	}
+	for _, nip := range info.SupportedNIPs {
+		tags = append(tags, []string{"N", strconv.Itoa(nip)})
+	}
+	tags = append(tags,
+		[]string{"R", requirement("auth", info.Limitation.AuthRequired || (cfg != nil && cfg.Authorization.NIP42Auth))},
+		[]string{"R", requirement("payment", info.Limitation.PaymentRequired)},
+		[]string{"R", requirement("writes", info.Limitation.RestrictedWrites)},
+		[]string{"R", requirement("pow", info.Limitation.MinPowDifficulty > 0)},
+	)
+	for _, kind := range excluded {
+		tags = append(tags, []string{"k", "!" + strconv.Itoa(kind)})
+	}
+	for _, lang := range info.LanguageTags {
+		tags = append(tags, []string{"l", lang, "ISO-639-1"})
+	}
+	for _, country := range info.RelayCountries {
+		tags = append(tags, []string{"l", country, "ISO-3166-1"})
+	}
+	for _, topic := range info.Tags {
+		tags = append(tags, []string{"t", topic})
+	}
+	if settings.Geohash != "" {
+		tags = append(tags, []string{"g", settings.Geohash})
+	}
+
+	return &RelayAnnouncement{RelayURL: relayURL, Tags: tags, Info: info}, nil
+}
+
+// relayNetwork returns the NIP-66 network of a relay host.
+func relayNetwork(host string) string {
+	switch {
+	case strings.HasSuffix(host, ".onion"):
+		return "tor"
+	case strings.HasSuffix(host, ".i2p"):
+		return "i2p"
+	}
+	return "clearnet"
+}
+
+// requirement returns a NIP-66 requirement, negated with "!" when it does
+// not apply.
+func requirement(name string, required bool) string {
+	if required {
+		return name
+	}
+	return "!" + name
+}
+
+// Publish builds and publishes the announcement now, whether or not it
+// changed or is enabled.
+func (s *RelayAnnouncementService) Publish(ctx context.Context) (*db.RelayAnnouncementRecord, error) {
+	settings, err := s.db.GetRelayAnnouncementSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	announcement, err := s.Build(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	return s.publishAnnouncement(ctx, settings, announcement)
+}
+
+// publishAnnouncement signs the announcement as the operator and sends it
+// to each relay, then adds the relay to the operator's relay list if
+// enabled. It fails only if no relay accepted the announcement.
+func (s *RelayAnnouncementService) publishAnnouncement(ctx context.Context, settings *db.RelayAnnouncementSettings, announcement *RelayAnnouncement) (*db.RelayAnnouncementRecord, error) {
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+
+	content, err := json.Marshal(announcement.Info)
+	if err != nil {
+		return nil, err
+	}
+	event := nostr.NewEvent(RelayDiscoveryKind, announcement.Tags, string(content))
+	if err := s.signer.Sign(ctx, event); err != nil {
+		return nil, err
+	}
+
+	relays := settings.Relays
+	if len(relays) == 0 {
+		relays = SyncRelayURLs(ctx, s.db)
+	}
+
+	record := db.RelayAnnouncementRecord{
+		At:             time.Now(),
+		RelayURL:       announcement.RelayURL,
+		AnnouncementID: event.ID,
+		Hash:           announcement.hash(),
+	}
+	published := 0
+	for _, url := range relays {
+		result := db.RelayAnnouncementResult{URL: url, OK: true}
+		if err := s.publish(ctx, url, event); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+			slog.Warn("Failed to publish relay announcement", "relay", url, "error", err)
+		} else {
+			published++
+		}
+		record.Relays = append(record.Relays, result)
+	}
+	if published == 0 {
+		return &record, fmt.Errorf("announcement was not published to any of %d relays", len(relays))
+	}
+
+	if settings.RelayList {
+		listID, err := s.addToRelayList(ctx, announcement.RelayURL, relays)
+		if err != nil {
+			slog.Warn("Failed to update the operator's relay list", "error", err)
+			record.RelayListError = err.Error()
+		}
+		record.RelayListID = listID
+	}
+
+	if err := s.db.SetLastRelayAnnouncement(ctx, record); err != nil {
+		return nil, err
+	}
+	s.db.AddAuditLog(ctx, "relay_announced", map[string]interface{}{
+		"event_id":      event.ID,
+		"relay_url":     announcement.RelayURL,
+		"relays":        published,
+		"relay_list_id": record.RelayListID,
+	}, "")
+
+	slog.Info("Published relay announcement", "event_id", event.ID, "relay_url", announcement.RelayURL, "relays", published)
+	return &record, nil
+}
+
+// addToRelayList adds the relay to the operator's NIP-65 relay list,
+// keeping the relays already on it, and returns the new list's event ID. It
+// returns "" if the relay is already listed. Because a new list replaces
+// the old one, nothing is published unless the current list could be read.
+func (s *RelayAnnouncementService) addToRelayList(ctx context.Context, relayURL string, relays []string) (string, error) {
+	pubkey, err := s.signer.Pubkey(ctx)
+	if err != nil {
+		return "", err
+	}
+	current, err := s.fetchRelayList(ctx, pubkey, relays)
+	if err != nil {
+		return "", err
+	}
+
+	tags := [][]string{}
+	content := ""
+	if current != nil {
+		for _, tag := range current.Tags {
+			if len(tag) >= 2 && tag[0] == "r" && strings.TrimRight(tag[1], "/") == relayURL {
+				return "", nil
+			}
+			tags = append(tags, tag)
+		}
+		content = current.Content
+	}
+	tags = append(tags, []string{"r", relayURL})
+
+	event := nostr.NewEvent(RelayListKind, tags, content)
+	if current != nil && event.CreatedAt <= current.CreatedAt {
+		event.CreatedAt = current.CreatedAt + 1
+	}
+	if err := s.signer.Sign(ctx, event); err != nil {
+		return "", err
+	}
+
+	published := 0
+	for _, url := range relays {
+		if err := s.publish(ctx, url, event); err != nil {
+			slog.Warn("Failed to publish relay list", "relay", url, "error", err)
+			continue
+		}
+		published++
+	}
+	if published == 0 {
+		return "", fmt.Errorf("relay list was not published to any of %d relays", len(relays))
+	}
+	return event.ID, nil
+}
+
+// fetchRelayList returns the newest relay list of pubkey on the given
+// relays, or nil if it has none. It fails if no relay could be asked.
+func fetchRelayList(ctx context.Context, pubkey string, relays []string) (*nostr.SyncEvent, error) {
+	filter := nostr.Filter{
+		Kinds:   []int{RelayListKind},
+		Authors: []string{pubkey},
+		Limit:   1,
+	}
+
+	var latest *nostr.SyncEvent
+	answered := 0
+	for _, url := range relays {
+		err := func() error {
+			ctx, cancel := context.WithTimeout(ctx, relayAnnouncementTimeout)
+			defer cancel()
+
+			client := nostr.NewClient(url)
+			if err := client.Connect(ctx); err != nil {
+				return err
+			}
+			defer client.Close()
+
+			return client.Subscribe(ctx, filter, func(event *nostr.SyncEvent) error {
+				if event.Kind != RelayListKind || event.Pubkey != pubkey || event.Verify() != nil {
+					return nil
+				}
+				if latest == nil || event.CreatedAt > latest.CreatedAt {
+					latest = event
+				}
+				return nil
+			})
+		}()
+		if err != nil {
+			slog.Warn("Failed to fetch relay list", "relay", url, "error", err)
+			continue
+		}
+		answered++
+	}
+
+	if answered == 0 {
+		return nil, errors.New("could not read the current relay list from any relay")
+	}
+	return latest, nil
+}
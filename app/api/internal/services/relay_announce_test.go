@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// hasTag reports whether the event has a tag with the given values.
+func hasTag(event *nostr.SyncEvent, values ...string) bool {
+	for _, tag := range event.Tags {
+		if len(tag) < len(values) {
+			continue
+		}
+		match := true
+		for i, v := range values {
+			if tag[i] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRelayAnnouncementService_Publish(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	svc := NewRelayAnnouncementService(database, nil, NewSigner(database))
+
+	var published []*nostr.SyncEvent
+	svc.publish = func(ctx context.Context, url string, event *nostr.SyncEvent) error {
+		published = append(published, event)
+		return nil
+	}
+
+	settings := &db.RelayAnnouncementSettings{
+		Enabled:       true,
+		Relays:        []string{"wss://nos.lol"},
+		Geohash:       "u4pruyd",
+		RelayList:     true,
+		IntervalHours: 24,
+	}
+	database.SetRelayAnnouncementSettings(ctx, settings)
+	database.SetAccessMode(ctx, "paid")
+	database.SetExcludedKinds(ctx, []int{4})
+
+	if _, err := svc.Publish(ctx); !errors.Is(err, ErrNoRelayURL) {
+		t.Fatalf("expected ErrNoRelayURL without a relay URL, got %v", err)
+	}
+	settings.RelayURL = "wss://relay.example.com"
+	database.SetRelayAnnouncementSettings(ctx, settings)
+	if _, err := svc.Publish(ctx); !errors.Is(err, ErrNoSigner) {
+		t.Fatalf("expected ErrNoSigner without a way to sign, got %v", err)
+	}
+
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	operator, _ := nostr.GetPublicKey(operatorSecret)
+	database.SetOperatorSigningKey(ctx, operatorSecret)
+
+	// The operator's existing relay list is kept
+	existing := nostr.NewEvent(RelayListKind, [][]string{{"r", "wss://relay.damus.io", "read"}}, "")
+	existing.Sign(operatorSecret)
+	svc.fetchRelayList = func(ctx context.Context, pubkey string, relays []string) (*nostr.SyncEvent, error) {
+		if pubkey != operator {
+			t.Errorf("relay list fetched for %s, want the operator", pubkey)
+		}
+		return existing, nil
+	}
+
+	record, err := svc.Publish(ctx)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if len(published) != 2 {
+		t.Fatalf("expected the announcement and relay list to be published, got %d events", len(published))
+	}
+
+	announcement := published[0]
+	if announcement.Kind != RelayDiscoveryKind || announcement.Pubkey != operator || announcement.Verify() != nil {
+		t.Errorf("unexpected announcement event: %+v", announcement)
+	}
+	for _, tag := range [][]string{
+		{"d", "wss://relay.example.com"},
+		{"n", "clearnet"},
+		{"N", "11"},
+		{"R", "payment"},
+		{"R", "writes"},
+		{"R", "!auth"},
+		{"k", "!4"},
+		{"g", "u4pruyd"},
+	} {
+		if !hasTag(announcement, tag...) {
+			t.Errorf("announcement is missing tag %v: %v", tag, announcement.Tags)
+		}
+	}
+
+	list := published[1]
+	if list.Kind != RelayListKind || list.Pubkey != operator || list.CreatedAt <= existing.CreatedAt {
+		t.Errorf("unexpected relay list event: %+v", list)
+	}
+	if !hasTag(list, "r", "wss://relay.damus.io", "read") || !hasTag(list, "r", "wss://relay.example.com") {
+		t.Errorf("expected the relay added to the existing list, got %v", list.Tags)
+	}
+	if record.AnnouncementID != announcement.ID || record.RelayListID != list.ID || len(record.Relays) != 1 {
+		t.Errorf("unexpected record: %+v", record)
+	}
+
+	// A relay already on the list is not added again
+	existing = list
+	published = nil
+	record, err = svc.Publish(ctx)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if len(published) != 1 || record.RelayListID != "" {
+		t.Errorf("expected only the announcement to be published, got %d events and %+v", len(published), record)
+	}
+
+	// An unchanged announcement is refreshed only when due
+	published = nil
+	svc.publishIfDue()
+	if len(published) != 0 {
+		t.Errorf("expected an unchanged announcement not to be republished, got %d events", len(published))
+	}
+	last, _ := database.GetLastRelayAnnouncement(ctx)
+	last.At = time.Now().Add(-25 * time.Hour)
+	database.SetLastRelayAnnouncement(ctx, *last)
+	svc.publishIfDue()
+	if len(published) == 0 {
+		t.Error("expected a stale announcement to be refreshed")
+	}
+}
+
+func TestRelayAnnouncementService_RelayListUnreadable(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	svc := NewRelayAnnouncementService(database, nil, NewSigner(database))
+
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	database.SetOperatorSigningKey(ctx, operatorSecret)
+	database.SetRelayAnnouncementSettings(ctx, &db.RelayAnnouncementSettings{
+		RelayURL:  "ws://abcdefghijklmnop.onion",
+		Relays:    []string{"wss://nos.lol"},
+		RelayList: true,
+	})
+
+	var published []*nostr.SyncEvent
+	svc.publish = func(ctx context.Context, url string, event *nostr.SyncEvent) error {
+		published = append(published, event)
+		return nil
+	}
+	svc.fetchRelayList = func(ctx context.Context, pubkey string, relays []string) (*nostr.SyncEvent, error) {
+		return nil, errors.New("could not read the current relay list from any relay")
+	}
+
+	record, err := svc.Publish(ctx)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	// The list is not replaced when the current one could not be read
+	if len(published) != 1 || record.RelayListID != "" || record.RelayListError == "" {
+		t.Errorf("expected only the announcement to be published, got %d events and %+v", len(published), record)
+	}
+	if !hasTag(published[0], "n", "tor") {
+		t.Errorf("expected an onion relay to be announced on tor, got %v", published[0].Tags)
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+const (
+	// relayIndexSyncInterval is how often new events are copied from a relay
+	// backend into the relay index.
+	relayIndexSyncInterval = time.Minute
+
+	// relayIndexFullSyncInterval is how often the whole index is rebuilt to
+	// drop events the relay deleted on its own.
+	relayIndexFullSyncInterval = 24 * time.Hour
+)
+
+// ErrNoRelayIndex is returned when syncing the relay index of a relay that
+// stores events in the relay database itself.
+var ErrNoRelayIndex = errors.New("relay database is the relay's own; there is no index to sync")
+
+// RelayIndexService keeps the relay index up to date for relays that do not
+// use the nostr-rs-relay database, such as strfry. It does nothing when no
+// relay backend is set.
+type RelayIndexService struct {
+	db       *db.DB
+	syncMu   sync.Mutex // serializes syncs
+	mu       sync.Mutex
+	last     *RelayIndexStatus
+	lastFull time.Time
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+}
+
+// RelayIndexStatus reports the most recent relay index sync.
+type RelayIndexStatus struct {
+	db.RelayIndexSyncResult
+	Full     bool      `json:"full"`
+	SyncedAt time.Time `json:"synced_at"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// NewRelayIndexService creates a new relay index service.
+func NewRelayIndexService(database *db.DB) *RelayIndexService {
+	return &RelayIndexService{
+		db:     database,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start begins syncing the relay index in the background.
+func (s *RelayIndexService) Start() {
+	if s.db.RelayBackend() == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the background sync.
+func (s *RelayIndexService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run is the main loop for the relay index sync.
+func (s *RelayIndexService) run() {
+	defer s.wg.Done()
+
+	slog.Info("Relay index service started", "relay_type", s.db.RelayType())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	// Fill the index at once so the dashboard has data
+	s.syncLogged(ctx, true)
+
+	ticker := time.NewTicker(relayIndexSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			slog.Info("Relay index service stopped")
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			full := time.Since(s.lastFull) >= relayIndexFullSyncInterval
+			s.mu.Unlock()
+			s.syncLogged(ctx, full)
+		}
+	}
+}
+
+// syncLogged runs a sync and logs its outcome.
+func (s *RelayIndexService) syncLogged(ctx context.Context, full bool) {
+	result, err := s.Sync(ctx, full)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("Relay index sync failed", "full", full, "error", err)
+		}
+		return
+	}
+	if result.Added > 0 || result.Removed > 0 {
+		slog.Info("Relay index synced", "full", full, "added", result.Added, "removed", result.Removed)
+	}
+}
+
+// Sync copies new events from the relay backend into the relay index. A
+// full sync also drops indexed events the relay no longer has.
+func (s *RelayIndexService) Sync(ctx context.Context, full bool) (*RelayIndexStatus, error) {
+	if s.db.RelayBackend() == nil {
+		return nil, ErrNoRelayIndex
+	}
+
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	result, err := s.db.SyncRelayIndex(ctx, full)
+	status := &RelayIndexStatus{Full: full, SyncedAt: time.Now()}
+	if result != nil {
+		status.RelayIndexSyncResult = *result
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.last = status
+	if full && err == nil {
+		s.lastFull = status.SyncedAt
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return status, err
+	}
+	return status, nil
+}
+
+// Status returns the most recent sync, or nil before the first.
+func (s *RelayIndexService) Status() *RelayIndexStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// relaySupportedNIPs are the NIPs nostr-rs-relay implements. NIP-42 is added
+// when AUTH is enabled.
+var relaySupportedNIPs = []int{1, 2, 9, 11, 12, 15, 16, 20, 22, 33, 40}
+
+// RelaySoftware identifies Roostr in the NIP-11 document.
+const RelaySoftware = "https://github.com/rdoiron/roostr"
+
+// relayName returns the relay's configured name, or fallback if it has
+// none or the config cannot be read.
+func relayName(configMgr *relay.ConfigManager, fallback string) string {
+	if configMgr != nil {
+		if cfg, err := configMgr.Read(); err == nil && cfg.Info.Name != "" {
+			return cfg.Info.Name
+		}
+	}
+	return fallback
+}
+
+// RelayInfoDocument is a NIP-11 relay information document.
+type RelayInfoDocument struct {
+	Name           string          `json:"name,omitempty"`
+	Description    string          `json:"description,omitempty"`
+	Banner         string          `json:"banner,omitempty"`
+	Icon           string          `json:"icon,omitempty"`
+	Pubkey         string          `json:"pubkey,omitempty"`
+	Contact        string          `json:"contact,omitempty"`
+	SupportedNIPs  []int           `json:"supported_nips"`
+	Software       string          `json:"software"`
+	Limitation     RelayLimitation `json:"limitation"`
+	RelayCountries []string        `json:"relay_countries,omitempty"`
+	LanguageTags   []string        `json:"language_tags,omitempty"`
+	Tags           []string        `json:"tags,omitempty"`
+	PostingPolicy  string          `json:"posting_policy,omitempty"`
+	PrivacyPolicy  string          `json:"privacy_policy,omitempty"`
+	TermsOfService string          `json:"terms_of_service,omitempty"`
+	PaymentsURL    string          `json:"payments_url,omitempty"`
+	Fees           *RelayFees      `json:"fees,omitempty"`
+}
+
+// RelayLimitation is the limitation object of a NIP-11 document.
+type RelayLimitation struct {
+	MaxMessageLength int  `json:"max_message_length,omitempty"`
+	MaxSubscriptions int  `json:"max_subscriptions,omitempty"`
+	MinPowDifficulty int  `json:"min_pow_difficulty,omitempty"`
+	AuthRequired     bool `json:"auth_required"`
+	PaymentRequired  bool `json:"payment_required"`
+	RestrictedWrites bool `json:"restricted_writes"`
+}
+
+// RelayFees is the fees object of a NIP-11 document.
+type RelayFees struct {
+	Admission    []RelayFee `json:"admission,omitempty"`
+	Subscription []RelayFee `json:"subscription,omitempty"`
+}
+
+// RelayFee is one fee of a NIP-11 document. Period is in seconds.
+type RelayFee struct {
+	Amount int64  `json:"amount"`
+	Unit   string `json:"unit"`
+	Period int64  `json:"period,omitempty"`
+}
+
+// BuildRelayInfo generates the NIP-11 document from the relay config, the
+// access mode and pricing tiers, and the extra fields set by the operator.
+// It is built on every call, so edits to config.toml show up immediately.
+func BuildRelayInfo(ctx context.Context, database *db.DB, cfg *relay.Config) (*RelayInfoDocument, error) {
+	settings, err := database.GetRelayInfoSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accessMode, err := database.GetAccessMode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &RelayInfoDocument{
+		Banner:         settings.Banner,
+		Software:       RelaySoftware,
+		RelayCountries: settings.RelayCountries,
+		LanguageTags:   settings.LanguageTags,
+		Tags:           settings.Tags,
+		PostingPolicy:  settings.PostingPolicy,
+		PrivacyPolicy:  settings.PrivacyPolicy,
+		TermsOfService: settings.TermsOfService,
+		PaymentsURL:    settings.PaymentsURL,
+		Limitation: RelayLimitation{
+			PaymentRequired:  accessMode == "paid",
+			RestrictedWrites: accessMode != "open",
+		},
+	}
+
+	nips := append([]int(nil), relaySupportedNIPs...)
+	if cfg != nil {
+		doc.Name = cfg.Info.Name
+		doc.Description = cfg.Info.Description
+		doc.Icon = cfg.Info.RelayIcon
+		doc.Pubkey = cfg.Info.Pubkey
+		doc.Contact = cfg.Info.Contact
+		doc.Limitation.MaxMessageLength = cfg.Limits.MaxWSMessageBytes
+		doc.Limitation.MaxSubscriptions = cfg.Limits.MaxSubsPerConn
+		doc.Limitation.MinPowDifficulty = cfg.Limits.MinPowDifficulty
+		if cfg.Authorization.NIP42Auth {
+			nips = append(nips, 42)
+		}
+	}
+	if len(settings.SupportedNIPs) > 0 {
+		nips = append([]int(nil), settings.SupportedNIPs...)
+	}
+	sort.Ints(nips)
+	doc.SupportedNIPs = nips
+
+	if accessMode == "paid" {
+		tiers, err := database.GetPricingTiers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		doc.Fees = relayFees(tiers)
+	}
+	return doc, nil
+}
+
+// relayFees lists enabled pricing tiers as NIP-11 fees: tiers with a
+// duration as subscriptions, lifetime tiers as admission.
+func relayFees(tiers []db.PricingTier) *RelayFees {
+	fees := &RelayFees{}
+	for _, t := range tiers {
+		if !t.Enabled {
+			continue
+		}
+		fee := RelayFee{Amount: t.AmountSats * 1000, Unit: "msats"}
+		if t.DurationDays == nil {
+			fees.Admission = append(fees.Admission, fee)
+			continue
+		}
+		fee.Period = int64(*t.DurationDays) * 24 * 60 * 60
+		fees.Subscription = append(fees.Subscription, fee)
+	}
+	if len(fees.Admission) == 0 && len(fees.Subscription) == 0 {
+		return nil
+	}
+	return fees
+}
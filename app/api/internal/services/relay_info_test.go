@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+func TestBuildRelayInfo(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	cfg := &relay.Config{
+		Info: relay.InfoConfig{
+			Name:        "Home Relay",
+			Description: "Family notes",
+			Pubkey:      "bb00000000000000000000000000000000000000000000000000000000000004",
+			Contact:     "mailto:admin@example.com",
+			RelayIcon:   "https://example.com/icon.png",
+		},
+		Limits:        relay.LimitsConfig{MaxWSMessageBytes: 131072, MaxSubsPerConn: 20, MinPowDifficulty: 8},
+		Authorization: relay.AuthorizationConfig{NIP42Auth: true},
+	}
+
+	t.Run("whitelist", func(t *testing.T) {
+		doc, err := BuildRelayInfo(ctx, database, cfg)
+		if err != nil {
+			t.Fatalf("BuildRelayInfo failed: %v", err)
+		}
+		if doc.Name != "Home Relay" || doc.Icon != "https://example.com/icon.png" || doc.Pubkey != cfg.Info.Pubkey {
+			t.Errorf("expected the config's info, got %+v", doc)
+		}
+		if doc.SupportedNIPs[len(doc.SupportedNIPs)-1] != 42 {
+			t.Errorf("expected NIP-42 with auth enabled, got %v", doc.SupportedNIPs)
+		}
+		want := RelayLimitation{MaxMessageLength: 131072, MaxSubscriptions: 20, MinPowDifficulty: 8, RestrictedWrites: true}
+		if doc.Limitation != want {
+			t.Errorf("expected %+v, got %+v", want, doc.Limitation)
+		}
+		if doc.Fees != nil {
+			t.Errorf("expected no fees outside paid mode, got %+v", doc.Fees)
+		}
+	})
+
+	t.Run("paid", func(t *testing.T) {
+		database.SetAccessMode(ctx, "paid")
+		year := 365
+		database.UpdatePricingTier(ctx, db.PricingTier{ID: "yearly", Name: "Yearly", AmountSats: 50000, DurationDays: &year, Enabled: false, SortOrder: 2})
+		database.SetRelayInfoSettings(ctx, &db.RelayInfoSettings{
+			Banner:        "https://example.com/banner.png",
+			PaymentsURL:   "https://relay.example.com/signup",
+			SupportedNIPs: []int{50, 1, 11},
+		})
+
+		doc, err := BuildRelayInfo(ctx, database, cfg)
+		if err != nil {
+			t.Fatalf("BuildRelayInfo failed: %v", err)
+		}
+		if !doc.Limitation.PaymentRequired || doc.Banner == "" || doc.PaymentsURL == "" {
+			t.Errorf("expected paid access and the stored fields, got %+v", doc)
+		}
+		if !reflect.DeepEqual(doc.SupportedNIPs, []int{1, 11, 50}) {
+			t.Errorf("expected the overridden NIPs, got %v", doc.SupportedNIPs)
+		}
+		want := &RelayFees{
+			Admission:    []RelayFee{{Amount: 100000000, Unit: "msats"}},
+			Subscription: []RelayFee{{Amount: 5000000, Unit: "msats", Period: 30 * 86400}},
+		}
+		if !reflect.DeepEqual(doc.Fees, want) {
+			t.Errorf("expected %+v, got %+v", want, doc.Fees)
+		}
+	})
+
+	t.Run("without_config", func(t *testing.T) {
+		database.SetAccessMode(ctx, "open")
+		doc, err := BuildRelayInfo(ctx, database, nil)
+		if err != nil {
+			t.Fatalf("BuildRelayInfo failed: %v", err)
+		}
+		if doc.Name != "" || doc.Limitation.RestrictedWrites || len(doc.SupportedNIPs) == 0 {
+			t.Errorf("expected a bare document, got %+v", doc)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// ErrNoRenewalTier is returned when a paid user's tier no longer exists, is
+// disabled or never expires, so there is nothing to renew.
+var ErrNoRenewalTier = errors.New("subscription tier cannot be renewed")
+
+// Renewal invoices stay payable until the subscription expires, within these
+// bounds.
+const (
+	minRenewalInvoiceExpiry = time.Hour
+	maxRenewalInvoiceExpiry = 30 * 24 * time.Hour
+)
+
+// RenewalService creates renewal invoices for paid users nearing expiry and
+// optionally sends them by Nostr DM. Each user is reminded once per
+// subscription period; paying the invoice extends their expiry.
+type RenewalService struct {
+	db        *db.DB
+	lightning *LightningService
+	notifier  *Notifier
+	interval  time.Duration
+	now       func() time.Time
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+}
+
+// NewRenewalService creates a new renewal service.
+func NewRenewalService(database *db.DB, lightning *LightningService, notifier *Notifier) *RenewalService {
+	return &RenewalService{
+		db:        database,
+		lightning: lightning,
+		notifier:  notifier,
+		interval:  time.Hour,
+		now:       time.Now,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins the background renewal job.
+func (s *RenewalService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the background renewal job.
+func (s *RenewalService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *RenewalService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			_, err := s.RunNow(context.Background())
+			if err != nil && !errors.Is(err, ErrLNDNotConfigured) {
+				slog.Error("Failed to send renewal invoices", "error", err)
+			}
+		}
+	}
+}
+
+// RunNow sends renewal invoices to the paid users that are due one and
+// returns how many were sent.
+func (s *RenewalService) RunNow(ctx context.Context) (int, error) {
+	settings, err := s.db.GetRenewalSettings(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !settings.Enabled {
+		return 0, nil
+	}
+	if !s.lightning.IsConfigured() {
+		return 0, ErrLNDNotConfigured
+	}
+
+	users, err := s.db.GetPaidUsersDueForRenewal(ctx, s.now().AddDate(0, 0, settings.DaysBefore))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range users {
+		user := &users[i]
+		invoice, err := s.Invoice(ctx, user)
+		if errors.Is(err, ErrNoRenewalTier) {
+			slog.DebugContext(ctx, "Skipping renewal for unrenewable tier", "pubkey", user.Pubkey, "tier", user.Tier)
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to create renewal invoice", "pubkey", user.Pubkey, "error", err)
+			continue
+		}
+
+		dmSent := false
+		if settings.SendDM && s.notifier != nil {
+			message := renewalMessage(settings, user, invoice)
+			if err := s.notifier.SendDM(ctx, user.Pubkey, message); err != nil {
+				slog.WarnContext(ctx, "Failed to send renewal DM", "pubkey", user.Pubkey, "error", err)
+			} else {
+				dmSent = true
+			}
+		}
+
+		if err := s.db.AddRenewalReminder(ctx, user.Pubkey, *user.ExpiresAt, invoice.PaymentHash, dmSent); err != nil {
+			slog.WarnContext(ctx, "Failed to record renewal reminder", "pubkey", user.Pubkey, "error", err)
+			continue
+		}
+		sent++
+	}
+
+	if sent > 0 {
+		s.db.AddAuditLog(ctx, "renewal_invoices_sent", map[string]int{"count": sent}, "")
+		slog.InfoContext(ctx, "Sent renewal invoices", "count", sent)
+	}
+	return sent, nil
+}
+
+// Invoice returns a payable renewal invoice for a paid user, reusing the
+// newest open one for their tier or creating one that stays payable until
+// the subscription expires.
+func (s *RenewalService) Invoice(ctx context.Context, user *db.PaidUser) (*db.PendingInvoice, error) {
+	tier, err := s.renewalTier(ctx, user.Tier)
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := s.db.GetOpenPendingInvoice(ctx, user.Pubkey, tier.ID)
+	if err != nil || open != nil {
+		return open, err
+	}
+
+	invoice, err := s.lightning.CreateAccessInvoice(ctx, AccessInvoiceRequest{
+		Pubkey: user.Pubkey,
+		Npub:   user.Npub,
+		TierID: tier.ID,
+		Expiry: renewalInvoiceExpiry(user.ExpiresAt, s.now()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.db.GetPendingInvoice(ctx, invoice.PaymentHash)
+}
+
+// renewalTier finds the enabled, expiring pricing tier a paid user is on.
+// Paid users store the tier name, or the tier ID if the tier was missing
+// when they paid.
+func (s *RenewalService) renewalTier(ctx context.Context, name string) (*db.PricingTier, error) {
+	tiers, err := s.db.GetPricingTiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tiers {
+		if t.Name != name && t.ID != name {
+			continue
+		}
+		if !t.Enabled || t.DurationDays == nil {
+			return nil, ErrNoRenewalTier
+		}
+		return &t, nil
+	}
+	return nil, ErrNoRenewalTier
+}
+
+// renewalInvoiceExpiry keeps a renewal invoice payable until the subscription
+// expires.
+func renewalInvoiceExpiry(expiresAt *time.Time, now time.Time) time.Duration {
+	expiry := minRenewalInvoiceExpiry
+	if expiresAt != nil {
+		expiry = expiresAt.Sub(now)
+	}
+	if expiry < minRenewalInvoiceExpiry {
+		return minRenewalInvoiceExpiry
+	}
+	if expiry > maxRenewalInvoiceExpiry {
+		return maxRenewalInvoiceExpiry
+	}
+	return expiry
+}
+
+// renewalMessage is the DM sent with a renewal invoice.
+func renewalMessage(settings *db.RenewalSettings, user *db.PaidUser, invoice *db.PendingInvoice) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your %s access to this relay expires on %s. Renew for %d sats",
+		user.Tier, user.ExpiresAt.UTC().Format("2006-01-02"), invoice.AmountSats)
+	if settings.RenewURL != "" {
+		fmt.Fprintf(&b, " at %s/public/renew/%s", strings.TrimRight(settings.RenewURL, "/"), user.Npub)
+	}
+	fmt.Fprintf(&b, " or pay this invoice:\n\n%s", invoice.PaymentRequest)
+	return b.String()
+}
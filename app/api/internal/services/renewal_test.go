@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestRenewalService(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	lightning := NewLightningService(database)
+	lightning.Configure(&LNDConfig{NodeType: NodeTypeMock, Host: "settle_after=-1"})
+	renewal := NewRenewalService(database, lightning, nil)
+
+	expiresAt := time.Now().Add(3 * 24 * time.Hour).Truncate(time.Second)
+	users := []db.PaidUser{
+		{Pubkey: "aa01", Npub: "npub1due", Tier: "Monthly", AmountSats: 5000, Status: "active", ExpiresAt: &expiresAt},
+		{Pubkey: "aa02", Npub: "npub1lifetime", Tier: "Lifetime", AmountSats: 100000, Status: "active"},
+	}
+	later := time.Now().Add(20 * 24 * time.Hour)
+	users = append(users, db.PaidUser{Pubkey: "aa03", Npub: "npub1later", Tier: "Monthly", AmountSats: 5000, Status: "active", ExpiresAt: &later})
+	for _, u := range users {
+		if err := database.AddPaidUser(ctx, u); err != nil {
+			t.Fatalf("AddPaidUser() error = %v", err)
+		}
+	}
+
+	sent, err := renewal.RunNow(ctx)
+	if err != nil || sent != 1 {
+		t.Fatalf("RunNow() = %d, %v; want 1 reminder", sent, err)
+	}
+	if sent, _ := renewal.RunNow(ctx); sent != 0 {
+		t.Errorf("expected one reminder per period, sent %d more", sent)
+	}
+
+	user, _ := database.GetPaidUserByPubkey(ctx, "aa01")
+	invoice, err := renewal.Invoice(ctx, user)
+	if err != nil {
+		t.Fatalf("Invoice() error = %v", err)
+	}
+	if invoice.TierID != "monthly" || invoice.AmountSats != 5000 {
+		t.Errorf("unexpected renewal invoice %+v", invoice)
+	}
+	if again, _ := renewal.Invoice(ctx, user); again.PaymentHash != invoice.PaymentHash {
+		t.Error("expected the open renewal invoice to be reused")
+	}
+	if !invoice.ExpiresAt.After(time.Now().Add(2 * 24 * time.Hour)) {
+		t.Errorf("expected invoice to stay payable until expiry, expires %v", invoice.ExpiresAt)
+	}
+
+	lifetime, _ := database.GetPaidUserByPubkey(ctx, "aa02")
+	if _, err := renewal.Invoice(ctx, lifetime); !errors.Is(err, ErrNoRenewalTier) {
+		t.Errorf("expected ErrNoRenewalTier for lifetime tier, got %v", err)
+	}
+
+	// Paying extends the current period instead of adding a second row
+	monitor := NewInvoiceMonitorService(database, lightning, nil, nil)
+	if err := monitor.ProcessPayment(ctx, invoice.PaymentHash); err != nil {
+		t.Fatalf("ProcessPayment() error = %v", err)
+	}
+	renewed, _ := database.GetPaidUserByPubkey(ctx, "aa01")
+	want := expiresAt.AddDate(0, 0, 30)
+	if renewed.ExpiresAt == nil || !renewed.ExpiresAt.Equal(want) {
+		t.Errorf("expected expiry extended to %v, got %v", want, renewed.ExpiresAt)
+	}
+	all, _ := database.GetPaidUsers(ctx)
+	if len(all) != len(users) {
+		t.Errorf("expected %d paid users, got %d", len(users), len(all))
+	}
+
+	// The renewed period gets its own reminder when it is due
+	renewal.now = func() time.Time { return want.Add(-24 * time.Hour) }
+	if sent, _ := renewal.RunNow(ctx); sent != 2 {
+		t.Errorf("expected reminders for the renewed and later users, sent %d", sent)
+	}
+}
+
+func TestRenewalInvoiceExpiry(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		expiresAt *time.Time
+		want      time.Duration
+	}{
+		{nil, minRenewalInvoiceExpiry},
+		{ptrTime(now.Add(-time.Hour)), minRenewalInvoiceExpiry},
+		{ptrTime(now.Add(48 * time.Hour)), 48 * time.Hour},
+		{ptrTime(now.Add(90 * 24 * time.Hour)), maxRenewalInvoiceExpiry},
+	}
+	for _, tt := range tests {
+		if got := renewalInvoiceExpiry(tt.expiresAt, now); got != tt.want {
+			t.Errorf("renewalInvoiceExpiry(%v) = %v, want %v", tt.expiresAt, got, tt.want)
+		}
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// Report frequencies.
+const (
+	ReportFrequencyWeekly  = "weekly"
+	ReportFrequencyMonthly = "monthly"
+)
+
+// reportTopAuthors is how many of the most active authors a report lists.
+const reportTopAuthors = 5
+
+// ReportAuthor is one of a report's most active authors.
+type ReportAuthor struct {
+	Pubkey     string `json:"pubkey"`
+	Npub       string `json:"npub"`
+	Nickname   string `json:"nickname,omitempty"`
+	EventCount int64  `json:"event_count"`
+}
+
+// Report summarizes the relay's activity over one week or month.
+type Report struct {
+	RelayName   string    `json:"relay_name"`
+	Frequency   string    `json:"frequency"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	Events      int64          `json:"events"`       // Created during the period
+	TotalEvents int64          `json:"total_events"` // Stored now
+	TopAuthors  []ReportAuthor `json:"top_authors"`
+
+	NewMembers     int64 `json:"new_members"`      // Whitelisted during the period
+	NewPaidMembers int64 `json:"new_paid_members"` // Paid signups during the period
+	RevenueSats    int64 `json:"revenue_sats"`
+	Payments       int64 `json:"payments"`
+
+	DatabaseSize  int64  `json:"database_size"`
+	StorageGrowth *int64 `json:"storage_growth"` // Since the last report; nil for the first
+
+	Uptime db.UptimeSummary `json:"uptime"`
+}
+
+// ReportService sends the operator a weekly or monthly summary of the
+// relay's activity, as a DM or an HTML email. Each report covers the last
+// complete calendar week (from Monday, UTC) or month.
+type ReportService struct {
+	db        *db.DB
+	notifier  *Notifier
+	configMgr *relay.ConfigManager
+	interval  time.Duration
+	now       func() time.Time
+	wakeCh    chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+	sendMu    sync.Mutex
+}
+
+// NewReportService creates a new report service.
+func NewReportService(database *db.DB, notifier *Notifier, configMgr *relay.ConfigManager) *ReportService {
+	return &ReportService{
+		db:        database,
+		notifier:  notifier,
+		configMgr: configMgr,
+		interval:  time.Hour,
+		now:       time.Now,
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins the background report worker.
+func (s *ReportService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the report worker.
+func (s *ReportService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake asks the worker to check whether a report is due, e.g. after the
+// schedule changes.
+func (s *ReportService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ReportService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		case <-s.wakeCh:
+		}
+		if _, err := s.SendDue(context.Background()); err != nil {
+			slog.Error("Failed to send scheduled report", "error", err)
+		}
+	}
+}
+
+// ReportPeriod returns the last complete week or month before now.
+func ReportPeriod(frequency string, now time.Time) (start, end time.Time) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if frequency == ReportFrequencyMonthly {
+		end = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return end.AddDate(0, -1, 0), end
+	}
+	// Weeks start on Monday
+	end = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	return end.AddDate(0, 0, -7), end
+}
+
+// SendDue sends the report for the last complete period if the schedule is
+// enabled and it has not been sent yet. It returns the report sent, or nil.
+func (s *ReportService) SendDue(ctx context.Context) (*Report, error) {
+	schedule, err := s.db.GetReportSchedule(ctx)
+	if err != nil || !schedule.Enabled {
+		return nil, err
+	}
+	last, err := s.db.GetLastReport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, end := ReportPeriod(schedule.Frequency, s.now()); last != nil && !last.PeriodEnd.Before(end) {
+		return nil, nil
+	}
+	return s.Send(ctx)
+}
+
+// Send builds the report for the last complete period and delivers it on
+// the scheduled channel, whether or not it is due.
+func (s *ReportService) Send(ctx context.Context) (*Report, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	schedule, err := s.db.GetReportSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.Build(ctx, schedule.Frequency)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := fmt.Sprintf("%s %s report, %s", report.RelayName, schedule.Frequency, formatReportPeriod(report))
+	switch schedule.Channel {
+	case NotifyChannelEmail:
+		body, err := RenderReportHTML(report)
+		if err != nil {
+			return nil, err
+		}
+		err = s.notifier.SendHTMLEmail(ctx, schedule.Email, subject, body)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		operator, err := s.db.GetOperatorPubkey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if operator == "" {
+			return nil, ErrNoOperator
+		}
+		if err := s.notifier.SendDM(ctx, operator, subject+"\n\n"+RenderReportText(report)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.SetLastReport(ctx, db.ReportRecord{
+		At:           report.GeneratedAt,
+		PeriodStart:  report.PeriodStart,
+		PeriodEnd:    report.PeriodEnd,
+		Channel:      schedule.Channel,
+		DatabaseSize: report.DatabaseSize,
+	}); err != nil {
+		return report, err
+	}
+	slog.Info("Sent scheduled report", "frequency", schedule.Frequency, "channel", schedule.Channel)
+	return report, nil
+}
+
+// Build compiles the report for the last complete week or month.
+// Relay statistics are left at zero while the relay database is not
+// connected.
+func (s *ReportService) Build(ctx context.Context, frequency string) (*Report, error) {
+	now := s.now()
+	start, end := ReportPeriod(frequency, now)
+	report := &Report{
+		RelayName:   relayName(s.configMgr, "Roostr"),
+		Frequency:   frequency,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GeneratedAt: now.UTC(),
+		TopAuthors:  []ReportAuthor{},
+	}
+
+	var err error
+	if report.NewMembers, report.NewPaidMembers, err = s.db.CountNewMembers(ctx, start, end); err != nil {
+		return nil, fmt.Errorf("failed to count new members: %w", err)
+	}
+	if report.RevenueSats, report.Payments, err = s.db.GetRevenueInRange(ctx, start, end); err != nil {
+		return nil, fmt.Errorf("failed to get revenue: %w", err)
+	}
+	if report.Uptime, err = s.db.GetUptimeSummary(ctx, start); err != nil {
+		return nil, fmt.Errorf("failed to get uptime: %w", err)
+	}
+
+	if s.db.IsRelayDBConnected() {
+		if err := s.addRelayStats(ctx, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// addRelayStats fills in the event and storage figures of a report.
+func (s *ReportService) addRelayStats(ctx context.Context, report *Report) error {
+	var err error
+	// EventFilter.Until is inclusive
+	if report.Events, err = s.db.CountEvents(ctx, db.EventFilter{Since: report.PeriodStart, Until: report.PeriodEnd.Add(-time.Second)}); err != nil {
+		return fmt.Errorf("failed to count events: %w", err)
+	}
+	if report.TotalEvents, err = s.db.CountEvents(ctx, db.EventFilter{}); err != nil {
+		return fmt.Errorf("failed to count events: %w", err)
+	}
+
+	authors, err := s.db.GetTopAuthorsInRange(ctx, reportTopAuthors, report.PeriodStart, report.PeriodEnd.Add(-time.Second))
+	if err != nil {
+		return fmt.Errorf("failed to get top authors: %w", err)
+	}
+	names := map[string]string{}
+	members, _ := s.db.GetWhitelistMeta(ctx)
+	for _, m := range members {
+		names[m.Pubkey] = m.Nickname
+	}
+	for _, a := range authors {
+		npub, _ := nostr.EncodeNpub(a.Pubkey)
+		report.TopAuthors = append(report.TopAuthors, ReportAuthor{
+			Pubkey:     a.Pubkey,
+			Npub:       npub,
+			Nickname:   names[a.Pubkey],
+			EventCount: a.EventCount,
+		})
+	}
+
+	if report.DatabaseSize, err = s.db.GetRelayDatabaseSize(); err != nil {
+		return fmt.Errorf("failed to get database size: %w", err)
+	}
+	last, err := s.db.GetLastReport(ctx)
+	if err != nil {
+		return err
+	}
+	if last != nil && last.DatabaseSize > 0 {
+		growth := report.DatabaseSize - last.DatabaseSize
+		report.StorageGrowth = &growth
+	}
+	return nil
+}
+
+// formatReportPeriod describes a report's period, e.g. "Jan 8 - Jan 14, 2024".
+func formatReportPeriod(r *Report) string {
+	if r.Frequency == ReportFrequencyMonthly {
+		return r.PeriodStart.Format("January 2006")
+	}
+	last := r.PeriodEnd.AddDate(0, 0, -1)
+	return r.PeriodStart.Format("Jan 2") + " - " + last.Format("Jan 2, 2006")
+}
+
+// formatReportBytes formats a byte count in binary units.
+func formatReportBytes(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%s%d B", sign, n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%s%.1f %ciB", sign, float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// reportLines returns a report's figures as label and value pairs, shared
+// by the text and HTML renderings.
+func reportLines(r *Report) [][2]string {
+	growth := "n/a (first report)"
+	if r.StorageGrowth != nil {
+		growth = formatReportBytes(*r.StorageGrowth)
+		if *r.StorageGrowth >= 0 {
+			growth = "+" + growth
+		}
+	}
+	uptime := "n/a (no checks)"
+	if r.Uptime.Availability != nil {
+		uptime = fmt.Sprintf("%.2f%% of %d checks", *r.Uptime.Availability, r.Uptime.Checks)
+	}
+	return [][2]string{
+		{"Events stored", fmt.Sprintf("%d (%d in total)", r.Events, r.TotalEvents)},
+		{"New members", fmt.Sprintf("%d whitelisted, %d paid", r.NewMembers, r.NewPaidMembers)},
+		{"Revenue", fmt.Sprintf("%d sats from %d payments", r.RevenueSats, r.Payments)},
+		{"Storage", fmt.Sprintf("%s (%s)", formatReportBytes(r.DatabaseSize), growth)},
+		{"Uptime", uptime},
+	}
+}
+
+// reportAuthorLabel names an author by nickname, falling back to their npub.
+func reportAuthorLabel(a ReportAuthor) string {
+	if a.Nickname != "" {
+		return a.Nickname
+	}
+	return a.Npub
+}
+
+// RenderReportText renders a report as plain text for a DM.
+func RenderReportText(r *Report) string {
+	var b strings.Builder
+	for _, line := range reportLines(r) {
+		fmt.Fprintf(&b, "%s: %s\n", line[0], line[1])
+	}
+	if len(r.TopAuthors) > 0 {
+		b.WriteString("\nTop authors:\n")
+		for i, a := range r.TopAuthors {
+			fmt.Fprintf(&b, "%d. %s (%d events)\n", i+1, reportAuthorLabel(a), a.EventCount)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"author": reportAuthorLabel,
+}).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #222;">
+<h2>{{.Title}}</h2>
+<table cellpadding="4">
+{{range .Lines}}<tr><th align="left">{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{if .Report.TopAuthors}}<h3>Top authors</h3>
+<ol>
+{{range .Report.TopAuthors}}<li>{{author .}} ({{.EventCount}} events)</li>
+{{end}}</ol>
+{{end}}</body>
+</html>
+`))
+
+// RenderReportHTML renders a report as an HTML page for email.
+func RenderReportHTML(r *Report) (string, error) {
+	var buf bytes.Buffer
+	err := reportTemplate.Execute(&buf, map[string]interface{}{
+		"Title":  fmt.Sprintf("%s: %s", r.RelayName, formatReportPeriod(r)),
+		"Lines":  reportLines(r),
+		"Report": r,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render report: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestReportPeriod(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, 3, 13, 15, 0, 0, 0, time.UTC)
+
+	start, end := ReportPeriod(ReportFrequencyWeekly, now)
+	if !start.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekly period = %v - %v", start, end)
+	}
+	// A Monday reports the week that just ended
+	start, _ = ReportPeriod(ReportFrequencyWeekly, time.Date(2024, 3, 11, 0, 30, 0, 0, time.UTC))
+	if !start.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekly period on a Monday starts %v", start)
+	}
+
+	start, end = ReportPeriod(ReportFrequencyMonthly, now)
+	if !start.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("monthly period = %v - %v", start, end)
+	}
+}
+
+func TestReportService_SendDue(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	author := strings.Repeat("aa", 32)
+	database.AddWhitelistEntry(ctx, db.WhitelistEntry{Pubkey: author, Nickname: "alice"})
+	database.AddPaidUser(ctx, db.PaidUser{Pubkey: author, Tier: "monthly", AmountSats: 5000, Status: "active"})
+	database.AddPaymentHistory(ctx, author, "hash1", "monthly", 5000, "", db.PaymentTypeLightning)
+	database.AddPaymentHistory(ctx, author, "hash2", "monthly", 2500, "", db.PaymentTypeLightning)
+
+	now := time.Now()
+	for n := byte(1); n <= 3; n++ {
+		insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{
+			ID:        strings.Repeat(hex.EncodeToString([]byte{n}), 32),
+			Pubkey:    author,
+			Kind:      1,
+			CreatedAt: now.Unix(),
+		})
+	}
+
+	var sent []string
+	notifier := NewNotifier(database, nil)
+	notifier.ConfigureSMTP(SMTPConfig{Host: "mail.example.com", From: "relay@example.com"})
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	svc := NewReportService(database, notifier, nil)
+	// A week on, the last complete week is the current one
+	svc.now = func() time.Time { return now.AddDate(0, 0, 7) }
+
+	if report, err := svc.SendDue(ctx); err != nil || report != nil {
+		t.Fatalf("expected nothing sent while disabled, got %v, %v", report, err)
+	}
+
+	database.SetReportSchedule(ctx, &db.ReportSchedule{
+		Enabled: true, Frequency: ReportFrequencyWeekly, Channel: NotifyChannelEmail, Email: "op@example.com",
+	})
+	report, err := svc.SendDue(ctx)
+	if err != nil || report == nil {
+		t.Fatalf("SendDue = %v, %v", report, err)
+	}
+	if report.Events != 3 || report.TotalEvents != 3 {
+		t.Errorf("expected 3 events, got %d of %d", report.Events, report.TotalEvents)
+	}
+	if len(report.TopAuthors) != 1 || report.TopAuthors[0].Nickname != "alice" || report.TopAuthors[0].EventCount != 3 {
+		t.Errorf("unexpected top authors: %+v", report.TopAuthors)
+	}
+	if report.NewMembers != 1 || report.NewPaidMembers != 1 || report.RevenueSats != 7500 || report.Payments != 2 {
+		t.Errorf("expected 1 new member, 1 paid, and 7500 sats from 2 payments, got %d, %d, %d, %d", report.NewMembers, report.NewPaidMembers, report.RevenueSats, report.Payments)
+	}
+	if report.StorageGrowth != nil {
+		t.Errorf("expected no growth for the first report, got %d", *report.StorageGrowth)
+	}
+
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(sent))
+	}
+	if !strings.Contains(sent[0], "Content-Type: text/html") || !strings.Contains(sent[0], "<li>alice (3 events)</li>") {
+		t.Errorf("unexpected email:\n%s", sent[0])
+	}
+
+	// The period is only reported once
+	if report, err := svc.SendDue(ctx); err != nil || report != nil {
+		t.Errorf("expected the report not to be sent twice, got %v, %v", report, err)
+	}
+
+	last, err := database.GetLastReport(ctx)
+	if err != nil || last == nil {
+		t.Fatalf("GetLastReport = %v, %v", last, err)
+	}
+	if !last.PeriodEnd.Equal(report.PeriodEnd) || last.Channel != NotifyChannelEmail {
+		t.Errorf("unexpected last report: %+v", last)
+	}
+
+	// The next report has the growth since this one
+	report, err = svc.Build(ctx, ReportFrequencyWeekly)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if report.StorageGrowth == nil {
+		t.Error("expected growth since the last report")
+	}
+}
+
+func TestRenderReportText(t *testing.T) {
+	growth := int64(3 << 20)
+	availability := 99.5
+	report := &Report{
+		Events:        120,
+		TotalEvents:   5000,
+		NewMembers:    2,
+		RevenueSats:   21000,
+		Payments:      3,
+		DatabaseSize:  50 << 20,
+		StorageGrowth: &growth,
+		Uptime:        db.UptimeSummary{Checks: 200, Up: 199, Availability: &availability},
+		TopAuthors:    []ReportAuthor{{Npub: "npub1abc", EventCount: 40}},
+	}
+
+	text := RenderReportText(report)
+	for _, want := range []string{
+		"Events stored: 120 (5000 in total)",
+		"Revenue: 21000 sats from 3 payments",
+		"Storage: 50.0 MiB (+3.0 MiB)",
+		"Uptime: 99.50% of 200 checks",
+		"1. npub1abc (40 events)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+// ResidencyKindPresets are common groups of kinds operators exclude from storage.
+var ResidencyKindPresets = map[string][]int{
+	"direct_messages": {4, 14, 15}, // NIP-04 DMs, NIP-17 chat messages and file messages
+	"gift_wraps":      {13, 1059},  // NIP-59 seals and gift wraps
+}
+
+// ResidencyReport verifies that the relay database holds no excluded kinds.
+type ResidencyReport struct {
+	ExcludedKinds     []int              `json:"excluded_kinds"`
+	StoredByKind      map[int]int64      `json:"stored_by_kind"`
+	StoredTotal       int64              `json:"stored_total"`
+	Compliant         bool               `json:"compliant"`
+	RelayConfigSynced bool               `json:"relay_config_synced"`
+	CheckedAt         time.Time          `json:"checked_at"`
+	LastPurge         *db.ResidencyPurge `json:"last_purge,omitempty"`
+}
+
+// ResidencyService enforces the data residency policy: kinds the operator has
+// excluded are written to the relay's kind blacklist, refused by Roostr's own
+// writers (sync, import), and purged from the relay database if any slip in.
+type ResidencyService struct {
+	db        *db.DB
+	configMgr *relay.ConfigManager
+	relay     *relay.Relay
+	interval  time.Duration
+	wakeCh    chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+}
+
+// NewResidencyService creates a new residency service.
+func NewResidencyService(database *db.DB, configMgr *relay.ConfigManager, relayCtl *relay.Relay) *ResidencyService {
+	return &ResidencyService{
+		db:        database,
+		configMgr: configMgr,
+		relay:     relayCtl,
+		interval:  5 * time.Minute,
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins the background purge worker.
+func (s *ResidencyService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the purge worker.
+func (s *ResidencyService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run purges excluded kinds on start, periodically, and when woken.
+func (s *ResidencyService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.purgeAndLog()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.purgeAndLog()
+		case <-s.wakeCh:
+			s.purgeAndLog()
+		}
+	}
+}
+
+func (s *ResidencyService) purgeAndLog() {
+	if _, err := s.Purge(context.Background()); err != nil {
+		slog.Error("Residency purge failed", "error", err)
+	}
+}
+
+// SetExcludedKinds saves the policy, syncs it to the relay config and reloads
+// the relay, then wakes the worker to purge any stored events of those kinds.
+// A config sync failure is returned after the policy is saved; Roostr's writers
+// and the purge worker still enforce it.
+func (s *ResidencyService) SetExcludedKinds(ctx context.Context, kinds []int) error {
+	kinds = normalizeKinds(kinds)
+	if err := s.db.SetExcludedKinds(ctx, kinds); err != nil {
+		return err
+	}
+
+	var syncErr error
+	if s.configMgr != nil {
+		if err := s.configMgr.UpdateExcludedKinds(kinds); err != nil {
+			syncErr = err
+		} else if s.relay != nil {
+			if err := s.relay.Reload(); err != nil {
+				slog.WarnContext(ctx, "Failed to reload relay", "error", err)
+			}
+		}
+	}
+
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+
+	return syncErr
+}
+
+// Purge deletes stored events of excluded kinds. It returns nil when there was
+// nothing to delete.
+func (s *ResidencyService) Purge(ctx context.Context) (*db.ResidencyPurge, error) {
+	if !s.db.IsRelayDBConnected() {
+		return nil, nil
+	}
+
+	kinds, err := s.db.GetExcludedKinds(ctx)
+	if err != nil || len(kinds) == 0 {
+		return nil, err
+	}
+
+	// Check with the read-only connection first to avoid opening a writer
+	// every interval when the relay is already compliant.
+	counts, err := s.db.CountEventsByKinds(ctx, kinds)
+	if err != nil {
+		return nil, err
+	}
+	var stored []int
+	for kind, count := range counts {
+		if count > 0 {
+			stored = append(stored, kind)
+		}
+	}
+	if len(stored) == 0 {
+		return nil, nil
+	}
+	sort.Ints(stored)
+
+	writer, err := s.db.NewRelayWriter()
+	if err != nil {
+		return nil, err
+	}
+	defer writer.Close()
+
+	byKind, err := writer.DeleteEventsByKinds(ctx, stored)
+	purge := db.ResidencyPurge{At: time.Now(), ByKind: byKind}
+	for _, n := range byKind {
+		purge.Deleted += n
+	}
+	if purge.Deleted > 0 {
+		s.db.SetLastResidencyPurge(ctx, purge)
+		s.db.AddAuditLog(ctx, "residency_purge", map[string]interface{}{
+			"deleted": purge.Deleted,
+			"by_kind": byKind,
+		}, "")
+		slog.InfoContext(ctx, "Residency purge: deleted events of excluded kinds", "deleted", purge.Deleted)
+	}
+	return &purge, err
+}
+
+// Report counts stored events of each excluded kind and checks that the relay
+// config blacklist covers the policy.
+func (s *ResidencyService) Report(ctx context.Context) (*ResidencyReport, error) {
+	kinds, err := s.db.GetExcludedKinds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.db.CountEventsByKinds(ctx, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ResidencyReport{
+		ExcludedKinds: kinds,
+		StoredByKind:  counts,
+		CheckedAt:     time.Now(),
+	}
+	for _, count := range counts {
+		report.StoredTotal += count
+	}
+	report.Compliant = report.StoredTotal == 0
+	report.LastPurge, _ = s.db.GetLastResidencyPurge(ctx)
+
+	if s.configMgr != nil {
+		if blacklist, err := s.configMgr.GetExcludedKinds(); err == nil {
+			report.RelayConfigSynced = containsAllKinds(blacklist, kinds)
+		}
+	}
+
+	return report, nil
+}
+
+// normalizeKinds sorts kinds and removes duplicates.
+func normalizeKinds(kinds []int) []int {
+	seen := make(map[int]bool, len(kinds))
+	result := make([]int, 0, len(kinds))
+	for _, kind := range kinds {
+		if !seen[kind] {
+			seen[kind] = true
+			result = append(result, kind)
+		}
+	}
+	sort.Ints(result)
+	return result
+}
+
+// containsAllKinds reports whether every kind in want is in have.
+func containsAllKinds(have, want []int) bool {
+	set := make(map[int]bool, len(have))
+	for _, kind := range have {
+		set[kind] = true
+	}
+	for _, kind := range want {
+		if !set[kind] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestResidency_PurgeAndReport(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	insertExportTestEvent(t, relayDB, 1, 1)
+	insertExportTestEvent(t, relayDB, 2, 4)
+	insertExportTestEvent(t, relayDB, 3, 1059)
+	insertExportTestEvent(t, relayDB, 4, 1059)
+
+	svc := NewResidencyService(database, nil, nil)
+
+	t.Run("no_policy_purges_nothing", func(t *testing.T) {
+		purge, err := svc.Purge(ctx)
+		if err != nil || purge != nil {
+			t.Errorf("expected no purge without a policy, got %+v (%v)", purge, err)
+		}
+	})
+
+	if err := svc.SetExcludedKinds(ctx, []int{1059, 4, 4}); err != nil {
+		t.Fatalf("SetExcludedKinds failed: %v", err)
+	}
+
+	t.Run("report_before_purge", func(t *testing.T) {
+		report, err := svc.Report(ctx)
+		if err != nil {
+			t.Fatalf("Report failed: %v", err)
+		}
+		if len(report.ExcludedKinds) != 2 || report.ExcludedKinds[0] != 4 {
+			t.Errorf("expected normalized kinds [4 1059], got %v", report.ExcludedKinds)
+		}
+		if report.Compliant || report.StoredTotal != 3 || report.StoredByKind[1059] != 2 {
+			t.Errorf("unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("purge_deletes_only_excluded", func(t *testing.T) {
+		purge, err := svc.Purge(ctx)
+		if err != nil {
+			t.Fatalf("Purge failed: %v", err)
+		}
+		if purge == nil || purge.Deleted != 3 || purge.ByKind[4] != 1 {
+			t.Errorf("unexpected purge: %+v", purge)
+		}
+
+		report, _ := svc.Report(ctx)
+		if !report.Compliant || report.LastPurge == nil || report.LastPurge.Deleted != 3 {
+			t.Errorf("expected compliant report with last purge, got %+v", report)
+		}
+
+		var remaining int
+		relayDB.QueryRow(`SELECT COUNT(*) FROM event`).Scan(&remaining)
+		if remaining != 1 {
+			t.Errorf("expected the kind 1 event to remain, got %d events", remaining)
+		}
+	})
+
+	t.Run("writer_refuses_excluded_kinds", func(t *testing.T) {
+		writer, err := database.NewRelayWriter()
+		if err != nil {
+			t.Fatalf("NewRelayWriter failed: %v", err)
+		}
+		defer writer.Close()
+
+		event := &db.Event{
+			ID:        "ee00000000000000000000000000000000000000000000000000000000000000",
+			Pubkey:    "ab00000000000000000000000000000000000000000000000000000000000000",
+			CreatedAt: time.Now(),
+			Kind:      1059,
+			Tags:      [][]string{},
+		}
+		if _, err := writer.InsertEvent(ctx, event); !errors.Is(err, db.ErrKindExcluded) {
+			t.Errorf("expected ErrKindExcluded, got %v", err)
+		}
+
+		event.Kind = 1
+		if inserted, err := writer.InsertEvent(ctx, event); err != nil || !inserted {
+			t.Errorf("expected kind 1 to be stored, got %v (%v)", inserted, err)
+		}
+	})
+}
+
+func TestContainsAllKinds(t *testing.T) {
+	if !containsAllKinds([]int{4, 14, 1059}, []int{4, 1059}) {
+		t.Error("expected superset to contain all kinds")
+	}
+	if containsAllKinds([]int{4}, []int{4, 1059}) {
+		t.Error("expected missing kind to be detected")
+	}
+	if !containsAllKinds(nil, nil) {
+		t.Error("expected empty policy to be satisfied")
+	}
+}
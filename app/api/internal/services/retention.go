@@ -2,17 +2,27 @@ package services
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
 )
 
+// ErrInvalidRetentionRule is returned for a per-kind retention rule with a
+// negative kind or retention period, or a kind listed twice.
+var ErrInvalidRetentionRule = errors.New("invalid retention rule")
+
 // RetentionService handles automatic event retention and cleanup.
 type RetentionService struct {
 	db              *db.DB
 	deletionService *DeletionService
+	cleaner         *EventCleaner
+	media           *MediaService
 	interval        time.Duration
 	stopCh          chan struct{}
 	wg              sync.WaitGroup
@@ -25,6 +35,7 @@ func NewRetentionService(database *db.DB, deletionService *DeletionService) *Ret
 	return &RetentionService{
 		db:              database,
 		deletionService: deletionService,
+		cleaner:         NewEventCleaner(database),
 		interval:        24 * time.Hour, // Run daily
 		stopCh:          make(chan struct{}),
 	}
@@ -64,7 +75,7 @@ func (s *RetentionService) Stop() {
 func (s *RetentionService) run() {
 	defer s.wg.Done()
 
-	log.Println("Retention service started")
+	slog.Info("Retention service started")
 
 	// Calculate time until next midnight
 	now := time.Now()
@@ -78,7 +89,7 @@ func (s *RetentionService) run() {
 	for {
 		select {
 		case <-s.stopCh:
-			log.Println("Retention service stopped")
+			slog.Info("Retention service stopped")
 			return
 		case <-timer.C:
 			// Run the retention job
@@ -92,66 +103,11 @@ func (s *RetentionService) run() {
 
 // runRetention executes the retention policy.
 func (s *RetentionService) runRetention() {
-	ctx := context.Background()
-
-	log.Println("Starting retention job")
+	slog.Debug("Starting retention job")
 
-	// Get retention policy
-	policy, err := s.db.GetRetentionPolicy(ctx)
-	if err != nil {
-		log.Printf("Failed to get retention policy: %v", err)
-		return
-	}
-
-	// Process NIP-09 deletion requests first
-	if s.deletionService != nil && policy.HonorNIP09 {
-		result, err := s.deletionService.ProcessPendingDeletions(ctx)
-		if err != nil {
-			log.Printf("Failed to process deletion requests: %v", err)
-		} else if result.Processed > 0 {
-			log.Printf("Processed %d deletion requests, deleted %d events", result.Processed, result.EventsDeleted)
-		}
-	}
-
-	// Check if retention policy is enabled
-	if policy.RetentionDays <= 0 {
-		log.Println("Retention policy disabled (keep forever)")
-		s.db.SetLastRetentionRun(ctx, time.Now())
-		return
+	if _, err := s.execute(context.Background(), nil, false); err != nil {
+		slog.Error("Retention job failed", "error", err)
 	}
-
-	// Calculate cutoff date
-	cutoff := time.Now().AddDate(0, 0, -int(policy.RetentionDays))
-
-	// Get operator pubkey for exception handling
-	operatorPubkey, _ := s.db.GetOperatorPubkey(ctx)
-
-	// Open relay writer for deletion
-	writer, err := s.db.NewRelayWriter()
-	if err != nil {
-		log.Printf("Failed to open relay database for writing: %v", err)
-		return
-	}
-	defer writer.Close()
-
-	// Delete old events
-	deleted, err := writer.DeleteEventsBefore(ctx, cutoff, policy.Exceptions, operatorPubkey)
-	if err != nil {
-		log.Printf("Failed to delete old events: %v", err)
-		return
-	}
-
-	// Update last run timestamp
-	s.db.SetLastRetentionRun(ctx, time.Now())
-
-	// Add audit log
-	s.db.AddAuditLog(ctx, "retention_job_run", map[string]interface{}{
-		"retention_days": policy.RetentionDays,
-		"cutoff":         cutoff,
-		"deleted":        deleted,
-	}, "")
-
-	log.Printf("Retention job completed: deleted %d events older than %v", deleted, cutoff)
 }
 
 // RunNow forces an immediate execution of the retention policy.
@@ -167,13 +123,175 @@ type RetentionResult struct {
 	DeletionEventsDeleted int64   `json:"deletion_events_deleted"`
 	RetentionDays       int64     `json:"retention_days"`
 	Cutoff              time.Time `json:"cutoff,omitempty"`
+	Rules               []RetentionRuleResult `json:"rules,omitempty"`
 	Disabled            bool      `json:"disabled"`
+	MediaDeleted        int       `json:"media_deleted,omitempty"`
+	MediaBytesFreed     int64     `json:"media_bytes_freed,omitempty"`
+}
+
+// RetentionRuleResult is how many events one retention rule deleted, or
+// would delete in a preview.
+type RetentionRuleResult struct {
+	Rule          string    `json:"rule"` // "default" or "kind:N"
+	Kind          *int      `json:"kind,omitempty"`
+	RetentionDays int64     `json:"retention_days"`
+	Cutoff        time.Time `json:"cutoff"`
+	Events        int64     `json:"events"`
+	Bytes         int64     `json:"bytes"` // Counted in previews only
+}
+
+// RetentionPreview is what a retention policy would delete if it ran now.
+type RetentionPreview struct {
+	Rules       []RetentionRuleResult `json:"rules"`
+	TotalEvents int64                 `json:"total_events"`
+	TotalBytes  int64                 `json:"total_bytes"`
+}
+
+// retentionTarget pairs a retention rule with the events it selects.
+type retentionTarget struct {
+	rule  RetentionRuleResult
+	scope db.RetentionScope
+}
+
+// retentionTargets returns the rules of a policy that delete anything. Each
+// kind rule with a retention period applies to its kind, ignoring "kind:"
+// exceptions; the default retention_days applies to every other kind. Kind
+// rules of 0 days and "kind:" exceptions keep their kinds forever.
+func retentionTargets(policy *db.RetentionPolicy, now time.Time) []retentionTarget {
+	var pubkeyExceptions []string
+	for _, exc := range policy.Exceptions {
+		if strings.HasPrefix(exc, "pubkey:") {
+			pubkeyExceptions = append(pubkeyExceptions, exc)
+		}
+	}
+
+	rules := append([]db.RetentionRule(nil), policy.KindRules...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Kind < rules[j].Kind })
+
+	var targets []retentionTarget
+	var ruleKinds []int
+	for _, rule := range rules {
+		ruleKinds = append(ruleKinds, rule.Kind)
+		if rule.RetentionDays <= 0 {
+			continue
+		}
+		kind := rule.Kind
+		cutoff := now.AddDate(0, 0, -int(rule.RetentionDays))
+		targets = append(targets, retentionTarget{
+			rule: RetentionRuleResult{
+				Rule:          fmt.Sprintf("kind:%d", kind),
+				Kind:          &kind,
+				RetentionDays: rule.RetentionDays,
+				Cutoff:        cutoff,
+			},
+			scope: db.RetentionScope{Before: cutoff, Kinds: []int{kind}, Exceptions: pubkeyExceptions},
+		})
+	}
+
+	if policy.RetentionDays > 0 {
+		cutoff := now.AddDate(0, 0, -int(policy.RetentionDays))
+		targets = append(targets, retentionTarget{
+			rule: RetentionRuleResult{
+				Rule:          "default",
+				RetentionDays: policy.RetentionDays,
+				Cutoff:        cutoff,
+			},
+			scope: db.RetentionScope{Before: cutoff, ExcludeKinds: ruleKinds, Exceptions: policy.Exceptions},
+		})
+	}
+	return targets
+}
+
+// ValidateRetentionRules checks per-kind retention rules.
+func ValidateRetentionRules(rules []db.RetentionRule) error {
+	seen := make(map[int]bool, len(rules))
+	for _, rule := range rules {
+		if rule.Kind < 0 || rule.RetentionDays < 0 {
+			return fmt.Errorf("%w: kind and retention_days must be non-negative", ErrInvalidRetentionRule)
+		}
+		if seen[rule.Kind] {
+			return fmt.Errorf("%w: kind %d is listed twice", ErrInvalidRetentionRule, rule.Kind)
+		}
+		seen[rule.Kind] = true
+	}
+	return nil
+}
+
+// Preview counts the events and bytes each rule of a policy would delete,
+// without deleting anything. NIP-09 deletion requests are not included.
+func (s *RetentionService) Preview(ctx context.Context, policy *db.RetentionPolicy) (*RetentionPreview, error) {
+	operatorPubkey, _ := s.db.GetOperatorPubkey(ctx)
+
+	preview := &RetentionPreview{Rules: []RetentionRuleResult{}}
+	for _, target := range retentionTargets(policy, time.Now()) {
+		events, bytes, err := s.db.CountRetentionScope(ctx, target.scope, operatorPubkey)
+		if err != nil {
+			return nil, err
+		}
+		target.rule.Events = events
+		target.rule.Bytes = bytes
+		preview.Rules = append(preview.Rules, target.rule)
+		preview.TotalEvents += events
+		preview.TotalBytes += bytes
+	}
+	return preview, nil
+}
+
+// apply deletes the events each target selects in batches and returns the
+// per-rule results and the total deleted, including on failure or
+// cancellation. Progress is reported to run, if not nil, against the number
+// of events counted up front.
+func (s *RetentionService) apply(ctx context.Context, targets []retentionTarget, run *JobRun) ([]RetentionRuleResult, int64, error) {
+	// Get operator pubkey for exception handling
+	operatorPubkey, _ := s.db.GetOperatorPubkey(ctx)
+
+	var expected int64
+	if run != nil {
+		for _, target := range targets {
+			if n, _, err := s.db.CountRetentionScope(ctx, target.scope, operatorPubkey); err == nil {
+				expected += n
+			}
+		}
+		run.Progress(0, expected, "Applying retention policy")
+	}
+
+	var rules []RetentionRuleResult
+	var total int64
+	for _, target := range targets {
+		deleted, err := s.cleaner.DeleteScope(ctx, target.scope, operatorPubkey, func(deleted int64) {
+			if run != nil {
+				run.Progress(total+deleted, expected, fmt.Sprintf("Deleted %d events (%s)", total+deleted, target.rule.Rule))
+			}
+		})
+		target.rule.Events = deleted
+		rules = append(rules, target.rule)
+		total += deleted
+		if err != nil {
+			return rules, total, err
+		}
+	}
+	return rules, total, nil
 }
 
 // RunNowSync forces an immediate execution of the retention policy synchronously.
 // Returns the results of the retention run.
 func (s *RetentionService) RunNowSync(ctx context.Context) (*RetentionResult, error) {
-	log.Println("Starting retention job (manual trigger)")
+	return s.execute(ctx, nil, true)
+}
+
+// Run executes the retention policy as a job run, reporting how many events
+// have been deleted. A cancelled run stops between batches and returns what
+// it deleted so far.
+func (s *RetentionService) Run(ctx context.Context, run *JobRun) (*RetentionResult, error) {
+	return s.execute(ctx, run, true)
+}
+
+// execute processes pending NIP-09 deletion requests, then deletes the events
+// the retention policy no longer keeps.
+func (s *RetentionService) execute(ctx context.Context, run *JobRun, manual bool) (*RetentionResult, error) {
+	if manual {
+		slog.Info("Starting retention job (manual trigger)")
+	}
 
 	result := &RetentionResult{}
 
@@ -187,60 +305,134 @@ func (s *RetentionService) RunNowSync(ctx context.Context) (*RetentionResult, er
 
 	// Process NIP-09 deletion requests first
 	if s.deletionService != nil && policy.HonorNIP09 {
+		if run != nil {
+			run.Progress(0, 0, "Processing deletion requests")
+		}
 		delResult, err := s.deletionService.ProcessPendingDeletions(ctx)
-		if err != nil {
-			log.Printf("Failed to process deletion requests: %v", err)
-		} else if delResult.Processed > 0 {
+		if delResult != nil && delResult.Processed > 0 {
 			result.DeletionRequests = delResult.Processed
 			result.DeletionEventsDeleted = delResult.EventsDeleted
-			log.Printf("Processed %d deletion requests, deleted %d events", delResult.Processed, delResult.EventsDeleted)
+			slog.Info("Processed deletion requests", "processed", delResult.Processed, "deleted", delResult.EventsDeleted)
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if err != nil {
+			slog.Error("Failed to process deletion requests", "error", err)
 		}
 	}
 
 	// Check if retention policy is enabled
-	if policy.RetentionDays <= 0 {
-		log.Println("Retention policy disabled (keep forever)")
+	targets := retentionTargets(policy, time.Now())
+	if len(targets) == 0 {
+		slog.Debug("Retention policy disabled (keep forever)")
 		result.Disabled = true
 		s.db.SetLastRetentionRun(ctx, time.Now())
 		return result, nil
 	}
+	if policy.RetentionDays > 0 {
+		result.Cutoff = targets[len(targets)-1].rule.Cutoff
+	}
 
-	// Calculate cutoff date
-	cutoff := time.Now().AddDate(0, 0, -int(policy.RetentionDays))
-	result.Cutoff = cutoff
-
-	// Get operator pubkey for exception handling
-	operatorPubkey, _ := s.db.GetOperatorPubkey(ctx)
+	// Delete old events
+	rules, deleted, err := s.apply(ctx, targets, run)
+	result.EventsDeleted = deleted
+	result.Rules = rules
 
-	// Open relay writer for deletion
-	writer, err := s.db.NewRelayWriter()
+	// Add audit log
+	details := map[string]interface{}{
+		"retention_days": policy.RetentionDays,
+		"rules":          rules,
+		"deleted":        deleted,
+	}
+	if manual {
+		details["manual"] = true
+	}
 	if err != nil {
-		return nil, err
+		details["error"] = err.Error()
 	}
-	defer writer.Close()
+	s.db.AddAuditLog(context.Background(), "retention_job_run", details, "")
 
-	// Delete old events
-	deleted, err := writer.DeleteEventsBefore(ctx, cutoff, policy.Exceptions, operatorPubkey)
 	if err != nil {
-		return nil, err
+		slog.Warn("Retention job stopped", "deleted", deleted, "error", err)
+		return result, err
 	}
 
-	result.EventsDeleted = deleted
+	// Delete media that only the removed events linked to
+	if s.media != nil {
+		if settings, err := s.db.GetMediaSettings(ctx); err == nil && settings.Enabled && settings.DeleteWithRetention {
+			if run != nil {
+				run.Progress(0, 0, "Deleting unlinked media")
+			}
+			mediaDeleted, freed, err := s.media.SweepOrphans(ctx)
+			result.MediaDeleted = mediaDeleted
+			result.MediaBytesFreed = freed
+			if err != nil {
+				slog.Error("Failed to delete unlinked media", "error", err)
+			}
+		}
+	}
 
 	// Update last run timestamp
 	s.db.SetLastRetentionRun(ctx, time.Now())
 
-	// Add audit log
-	s.db.AddAuditLog(ctx, "retention_job_run", map[string]interface{}{
-		"retention_days": policy.RetentionDays,
-		"cutoff":         cutoff,
+	slog.Info("Retention job completed", "deleted", deleted, "rules", len(rules))
+
+	return result, nil
+}
+
+// RunTightened applies the retention policy keeping no event longer than
+// days, to free space under storage pressure. Exceptions and kinds kept
+// forever are still kept. NIP-09 deletion requests are not processed and
+// the last run time is not updated.
+func (s *RetentionService) RunTightened(ctx context.Context, days int64) (*RetentionResult, error) {
+	policy, err := s.db.GetRetentionPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policy = tightenRetention(policy, days)
+
+	result := &RetentionResult{RetentionDays: policy.RetentionDays}
+	targets := retentionTargets(policy, time.Now())
+	if len(targets) == 0 {
+		result.Disabled = true
+		return result, nil
+	}
+	result.Cutoff = targets[len(targets)-1].rule.Cutoff
+
+	rules, deleted, err := s.apply(ctx, targets, nil)
+	result.EventsDeleted = deleted
+	result.Rules = rules
+
+	details := map[string]interface{}{
+		"retention_days": days,
+		"rules":          rules,
 		"deleted":        deleted,
-		"manual":         true,
-	}, "")
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	s.db.AddAuditLog(context.Background(), "retention_pressure_run", details, "")
 
-	log.Printf("Retention job completed: deleted %d events older than %v", deleted, cutoff)
+	return result, err
+}
 
-	return result, nil
+// tightenRetention returns a copy of policy in which the default and every
+// kind rule keep events at most days. Kind rules of 0 days keep their kinds
+// forever and are left alone.
+func tightenRetention(policy *db.RetentionPolicy, days int64) *db.RetentionPolicy {
+	tightened := *policy
+	if tightened.RetentionDays <= 0 || tightened.RetentionDays > days {
+		tightened.RetentionDays = days
+	}
+	tightened.KindRules = make([]db.RetentionRule, len(policy.KindRules))
+	for i, rule := range policy.KindRules {
+		if rule.RetentionDays > days {
+			rule.RetentionDays = days
+		}
+		tightened.KindRules[i] = rule
+	}
+	return &tightened
 }
 
 // IsRunning returns whether the retention service is currently running.
@@ -2,10 +2,14 @@ package services
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -13,7 +17,7 @@ import (
 func TestRetentionService_Constructor(t *testing.T) {
 	t.Run("NewRetentionService_creates_service", func(t *testing.T) {
 		database := setupTestDB(t)
-		deletionSvc := NewDeletionService(database)
+		deletionSvc := NewDeletionService(database, NewSigner(database))
 		svc := NewRetentionService(database, deletionSvc)
 		if svc == nil {
 			t.Fatal("expected service to be created")
@@ -245,7 +249,7 @@ func TestRetentionService_Integration(t *testing.T) {
 			HonorNIP09:    true,
 		})
 
-		deletionSvc := NewDeletionService(database)
+		deletionSvc := NewDeletionService(database, NewSigner(database))
 		retentionSvc := NewRetentionService(database, deletionSvc)
 
 		// Add a deletion request (eventID, requestedBy, reason)
@@ -263,3 +267,146 @@ func TestRetentionService_Integration(t *testing.T) {
 		}
 	})
 }
+
+// TestRetentionTargets tests how kind rules split a policy into rules.
+func TestRetentionTargets(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	targets := retentionTargets(&db.RetentionPolicy{
+		RetentionDays: 30,
+		Exceptions:    []string{"kind:1", "pubkey:operator"},
+		KindRules: []db.RetentionRule{
+			{Kind: 4, RetentionDays: 365},
+			{Kind: 0, RetentionDays: 0},
+			{Kind: 1, RetentionDays: 90},
+		},
+	}, now)
+	if len(targets) != 3 {
+		t.Fatalf("expected 3 rules, got %d", len(targets))
+	}
+
+	kind1 := targets[0]
+	if kind1.rule.Rule != "kind:1" || kind1.scope.Before != now.AddDate(0, 0, -90) || len(kind1.scope.Kinds) != 1 {
+		t.Errorf("unexpected kind 1 rule: %+v", kind1)
+	}
+	if len(kind1.scope.Exceptions) != 1 || kind1.scope.Exceptions[0] != "pubkey:operator" {
+		t.Errorf("expected the kind rule to keep only pubkey exceptions, got %v", kind1.scope.Exceptions)
+	}
+	if targets[1].rule.Rule != "kind:4" {
+		t.Errorf("expected rules ordered by kind, got %q", targets[1].rule.Rule)
+	}
+
+	def := targets[2]
+	if def.rule.Rule != "default" || def.rule.Kind != nil || def.scope.Before != now.AddDate(0, 0, -30) {
+		t.Errorf("unexpected default rule: %+v", def)
+	}
+	if len(def.scope.ExcludeKinds) != 3 {
+		t.Errorf("expected the default rule to skip every kind with a rule, got %v", def.scope.ExcludeKinds)
+	}
+
+	if targets := retentionTargets(&db.RetentionPolicy{KindRules: []db.RetentionRule{{Kind: 3}}}, now); len(targets) != 0 {
+		t.Errorf("expected no rules when everything is kept forever, got %d", len(targets))
+	}
+}
+
+// TestValidateRetentionRules tests per-kind rule validation.
+func TestValidateRetentionRules(t *testing.T) {
+	if err := ValidateRetentionRules([]db.RetentionRule{{Kind: 0}, {Kind: 1, RetentionDays: 90}}); err != nil {
+		t.Errorf("expected valid rules, got %v", err)
+	}
+	for _, rules := range [][]db.RetentionRule{
+		{{Kind: -1, RetentionDays: 1}},
+		{{Kind: 1, RetentionDays: -1}},
+		{{Kind: 1, RetentionDays: 30}, {Kind: 1, RetentionDays: 90}},
+	} {
+		if err := ValidateRetentionRules(rules); !errors.Is(err, ErrInvalidRetentionRule) {
+			t.Errorf("expected ErrInvalidRetentionRule for %+v, got %v", rules, err)
+		}
+	}
+}
+
+// TestRetentionService_PreviewAndRun tests that a preview counts what a run
+// deletes.
+func TestRetentionService_PreviewAndRun(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	author := strings.Repeat("aa", 32)
+	daysAgo := func(days int) int64 { return time.Now().AddDate(0, 0, -days).Unix() }
+	n := byte(0)
+	event := func(kind int, createdAt int64) {
+		n++
+		insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{ID: strings.Repeat(hex.EncodeToString([]byte{n}), 32), Pubkey: author, Kind: kind, CreatedAt: createdAt})
+	}
+	event(0, daysAgo(1000)) // kept forever
+	event(1, daysAgo(100))  // past kind 1's 90 days
+	event(1, daysAgo(60))   // within kind 1's 90 days
+	event(4, daysAgo(400))  // past kind 4's 365 days
+	event(7, daysAgo(40))   // past the default 30 days
+	event(7, daysAgo(10))
+
+	database.SetRetentionPolicy(ctx, &db.RetentionPolicy{
+		RetentionDays: 30,
+		KindRules: []db.RetentionRule{
+			{Kind: 0},
+			{Kind: 1, RetentionDays: 90},
+			{Kind: 4, RetentionDays: 365},
+		},
+	})
+	policy, _ := database.GetRetentionPolicy(ctx)
+	svc := NewRetentionService(database, nil)
+
+	preview, err := svc.Preview(ctx, policy)
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if preview.TotalEvents != 3 || preview.TotalBytes == 0 || len(preview.Rules) != 3 {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	for _, rule := range preview.Rules {
+		if rule.Events != 1 || rule.Bytes == 0 {
+			t.Errorf("expected one event for rule %s, got %+v", rule.Rule, rule)
+		}
+	}
+	var count int
+	relayDB.QueryRow("SELECT COUNT(*) FROM event").Scan(&count)
+	if count != 6 {
+		t.Fatalf("expected the preview to delete nothing, got %d events", count)
+	}
+
+	result, err := svc.RunNowSync(ctx)
+	if err != nil {
+		t.Fatalf("RunNowSync failed: %v", err)
+	}
+	if result.EventsDeleted != preview.TotalEvents || len(result.Rules) != 3 {
+		t.Errorf("expected the run to delete what the preview counted, got %+v", result)
+	}
+	relayDB.QueryRow("SELECT COUNT(*) FROM event").Scan(&count)
+	if count != 3 {
+		t.Errorf("expected 3 events left, got %d", count)
+	}
+}
+
+func TestTightenRetention(t *testing.T) {
+	policy := &db.RetentionPolicy{
+		RetentionDays: 0,
+		KindRules: []db.RetentionRule{
+			{Kind: 0},
+			{Kind: 1, RetentionDays: 90},
+			{Kind: 7, RetentionDays: 7},
+		},
+	}
+	tightened := tightenRetention(policy, 30)
+	if tightened.RetentionDays != 30 {
+		t.Errorf("expected keep-forever default tightened to 30 days, got %d", tightened.RetentionDays)
+	}
+	want := []int64{0, 30, 7}
+	for i, rule := range tightened.KindRules {
+		if rule.RetentionDays != want[i] {
+			t.Errorf("kind %d: retention_days = %d, want %d", rule.Kind, rule.RetentionDays, want[i])
+		}
+	}
+	if policy.KindRules[1].RetentionDays != 90 {
+		t.Error("expected the policy itself left unchanged")
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+const (
+	// searchIndexInterval is how often new events are added to the full-text
+	// search index.
+	searchIndexInterval = time.Minute
+
+	// searchPruneInterval is how often events deleted from the relay are
+	// removed from the index. Searches skip them in the meantime.
+	searchPruneInterval = 24 * time.Hour
+)
+
+// SearchIndexService keeps the full-text search index up to date with the
+// relay database. It does nothing for a PostgreSQL relay database, whose
+// events have no row IDs to index by.
+type SearchIndexService struct {
+	db        *db.DB
+	mu        sync.Mutex
+	lastPrune time.Time
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+}
+
+// NewSearchIndexService creates a new search index service.
+func NewSearchIndexService(database *db.DB) *SearchIndexService {
+	return &SearchIndexService{
+		db:     database,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start begins indexing in the background.
+func (s *SearchIndexService) Start() {
+	if s.db.IsRelayPostgres() {
+		slog.Info("Full-text search index disabled for a PostgreSQL relay database")
+		return
+	}
+
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops background indexing.
+func (s *SearchIndexService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run is the main loop for the search indexer.
+func (s *SearchIndexService) run() {
+	defer s.wg.Done()
+
+	slog.Info("Search index service started")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	s.update(ctx)
+
+	ticker := time.NewTicker(searchIndexInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			slog.Info("Search index service stopped")
+			return
+		case <-ticker.C:
+			s.update(ctx)
+		}
+	}
+}
+
+// update indexes new events and, once a day, prunes deleted ones.
+func (s *SearchIndexService) update(ctx context.Context) {
+	if !s.db.IsRelayDBConnected() {
+		return
+	}
+
+	added, err := s.db.UpdateSearchIndex(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("Search index update failed", "error", err)
+		}
+		return
+	}
+	if added > 0 {
+		slog.Debug("Search index updated", "added", added)
+	}
+
+	s.mu.Lock()
+	prune := time.Since(s.lastPrune) >= searchPruneInterval
+	s.mu.Unlock()
+	if !prune {
+		return
+	}
+
+	removed, err := s.db.PruneSearchIndex(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("Search index prune failed", "error", err)
+		}
+		return
+	}
+	if removed > 0 {
+		slog.Info("Search index pruned", "removed", removed)
+	}
+	s.mu.Lock()
+	s.lastPrune = time.Now()
+	s.mu.Unlock()
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/secrets"
+)
+
+// UnlockSecretsFromFile unlocks the secrets vault with the passphrase in the
+// file at path, setting the passphrase when none is set yet, and encrypts
+// any credentials still stored in plaintext.
+func UnlockSecretsFromFile(ctx context.Context, database *db.DB, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	passphrase := strings.TrimRight(string(data), "\r\n")
+
+	err = database.Secrets().Unlock(passphrase)
+	if errors.Is(err, secrets.ErrNotConfigured) {
+		_, err = database.SetupSecrets(ctx, passphrase)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = database.EncryptStoredSecrets(ctx)
+	return err
+}
@@ -9,45 +9,284 @@ import (
 
 // Services holds all application services.
 type Services struct {
+	db             *db.DB
+	Hardware       *HardwareService
+	Jobs           *JobService
 	Deletion       *DeletionService
 	Retention      *RetentionService
 	Sync           *SyncService
+	Migration      *MigrationService
 	Lightning      *LightningService
 	InvoiceMonitor *InvoiceMonitorService
 	Expiry         *ExpiryService
+	Renewal        *RenewalService
+	Bandwidth      *BandwidthService
+	Webhooks       *WebhookService
+	Exports        *ExportService
+	Residency      *ResidencyService
+	NostrBackup    *NostrBackupService
+	Notifier       *Notifier
+	Mentions       *MentionService
+	Profiles       *ProfileService
+	Coverage       *CoverageService
+	Broadcast      *BroadcastService
+	Maintenance    *StorageMaintenanceService
+	Backup         *BackupService
+	RelayIndex     *RelayIndexService
+	SearchIndex    *SearchIndexService
+	Moderation     *ModerationService
+	RateLimit      *RateLimitService
+	Coupons        *CouponService
+	Zaps           *ZapService
+	Cashu          *CashuService
+	Breakdown      *StorageBreakdownService
+	Pressure       *StoragePressureService
+	ConfigDrift    *ConfigDriftService
+	Audit          *AuditService
+	KindPolicies   *KindPolicyService
+	Expiration     *ExpirationService
+	Reports        *ReportService
+	Admission      *AdmissionService
+	SystemStats    *SystemStatsService
+	Uptime         *UptimeService
+	StatsCache     *StatsCacheService
+	Signer         *Signer
+	Announcement   *RelayAnnouncementService
+	Media          *MediaService
+	FollowAccess   *FollowAccessService
+	Quotas         *QuotaService
+	Bench          *BenchService
 }
 
 // New creates a new Services instance with all services initialized.
 // The configMgr and relayCtl parameters are used by InvoiceMonitorService
 // to sync the whitelist and reload the relay when payments are confirmed.
 func New(database *db.DB, configMgr *relay.ConfigManager, relayCtl *relay.Relay) *Services {
-	deletion := NewDeletionService(database)
+	hardware := NewHardwareService(database)
+	jobs := NewJobService(database)
+	webhooks := NewWebhookService(database)
+	signer := NewSigner(database)
+	deletion := NewDeletionService(database, signer)
 	retention := NewRetentionService(database, deletion)
 	sync := NewSyncService(database)
+	migration := NewMigrationService(database)
 	lightning := NewLightningService(database)
 	invoiceMonitor := NewInvoiceMonitorService(database, lightning, configMgr, relayCtl)
 	expiry := NewExpiryService(database, configMgr, relayCtl)
+	bandwidth := NewBandwidthService(database)
+	exports := NewExportService(database)
+	residency := NewResidencyService(database, configMgr, relayCtl)
+	nostrBackup := NewNostrBackupService(database, configMgr)
+	notifier := NewNotifier(database, signer)
+	mentions := NewMentionService(database, notifier, configMgr)
+	renewal := NewRenewalService(database, lightning, notifier)
+	profiles := NewProfileService(database)
+	coverage := NewCoverageService(database)
+	broadcast := NewBroadcastService(database)
+	maintenance := NewStorageMaintenanceService(database, notifier)
+	backup := NewBackupService(database)
+	relayIndex := NewRelayIndexService(database)
+	searchIndex := NewSearchIndexService(database)
+	moderation := NewModerationService(database, configMgr, relayCtl)
+	rateLimit := NewRateLimitService(database)
+	coupons := NewCouponService(database)
+	zaps := NewZapService(database, lightning, notifier)
+	cashu := NewCashuService(database, lightning, invoiceMonitor)
+	breakdown := NewStorageBreakdownService(database)
+	pressure := NewStoragePressureService(database, retention, sync, notifier)
+	configDrift := NewConfigDriftService(database, configMgr, relayCtl)
+	audit := NewAuditService(database)
+	kindPolicies := NewKindPolicyService(database)
+	expiration := NewExpirationService(database)
+	reports := NewReportService(database, notifier, configMgr)
+	admission := NewAdmissionService(database, rateLimit)
+	systemStats := NewSystemStatsService(database, relayCtl)
+	uptime := NewUptimeService(database, bandwidth)
+	statsCache := NewStatsCacheService(database)
+	announcement := NewRelayAnnouncementService(database, configMgr, signer)
+	media := NewMediaService(database, signer)
+	followAccess := NewFollowAccessService(database, configMgr, relayCtl)
+	quotas := NewQuotaService(database, notifier, configMgr, relayCtl)
+	bench := NewBenchService(database, hardware)
+
+	// Services that run as jobs
+	sync.jobs = jobs
+	migration.jobs = jobs
+	broadcast.jobs = jobs
+	maintenance.jobs = jobs
+
+	// Services tuned to the hardware
+	sync.hardware = hardware
+	profiles.hardware = hardware
+	coverage.hardware = hardware
+	breakdown.hardware = hardware
+
+	// Services that emit webhook events
+	sync.webhooks = webhooks
+	invoiceMonitor.webhooks = webhooks
+	expiry.webhooks = webhooks
+	quotas.webhooks = webhooks
+	maintenance.webhooks = webhooks
+
+	// Services that message users
+	expiry.notifier = notifier
+
+	// Media no stored event links to is deleted after retention runs
+	retention.media = media
+
+	// Syncs and migrations stop while the disk is nearly full
+	sync.pressure = pressure
+	migration.pressure = pressure
+
+	// Migrations wait for syncs writing to the relay
+	migration.sync = sync
+
+	// Zap receipts are published once zap invoices are paid
+	invoiceMonitor.zaps = zaps
+
+	// Follow grants and quotas take effect at the admission server too
+	followAccess.admission = admission
+	quotas.admission = admission
+	admission.quotas = quotas
+
+	// The relay database can come and go while Roostr runs
+	database.OnRelayDBChange(func(change string) {
+		webhooks.Emit(WebhookEventRelayDatabase, map[string]interface{}{
+			"change":    change,
+			"path":      database.GetRelayPath(),
+			"connected": database.IsRelayDBConnected(),
+		})
+		statsCache.Clear()
+	})
 
 	return &Services{
+		db:             database,
+		Hardware:       hardware,
+		Jobs:           jobs,
 		Deletion:       deletion,
 		Retention:      retention,
 		Sync:           sync,
+		Migration:      migration,
 		Lightning:      lightning,
 		InvoiceMonitor: invoiceMonitor,
 		Expiry:         expiry,
+		Renewal:        renewal,
+		Bandwidth:      bandwidth,
+		Webhooks:       webhooks,
+		Exports:        exports,
+		Residency:      residency,
+		NostrBackup:    nostrBackup,
+		Notifier:       notifier,
+		Mentions:       mentions,
+		Profiles:       profiles,
+		Coverage:       coverage,
+		Broadcast:      broadcast,
+		Maintenance:    maintenance,
+		Backup:         backup,
+		RelayIndex:     relayIndex,
+		SearchIndex:    searchIndex,
+		Moderation:     moderation,
+		RateLimit:      rateLimit,
+		Coupons:        coupons,
+		Zaps:           zaps,
+		Cashu:          cashu,
+		Breakdown:      breakdown,
+		Pressure:       pressure,
+		ConfigDrift:    configDrift,
+		Audit:          audit,
+		KindPolicies:   kindPolicies,
+		Expiration:     expiration,
+		Reports:        reports,
+		Admission:      admission,
+		SystemStats:    systemStats,
+		Uptime:         uptime,
+		StatsCache:     statsCache,
+		Signer:         signer,
+		Announcement:   announcement,
+		Media:          media,
+		FollowAccess:   followAccess,
+		Quotas:         quotas,
+		Bench:          bench,
 	}
 }
 
 // Start starts all background services.
 func (s *Services) Start() {
+	s.RateLimit.Start()
+	s.Hardware.Start()
+	s.RelayIndex.Start()
+	s.SearchIndex.Start()
+	s.Jobs.Start()
+	s.Broadcast.Start()
+	s.Sync.Start()
+	s.Webhooks.Start()
 	s.Retention.Start()
+	s.Maintenance.Start()
+	s.Backup.Start()
 	s.InvoiceMonitor.Start()
 	s.Expiry.Start()
+	s.Renewal.Start()
+	s.Bandwidth.Start()
+	s.Exports.Start()
+	s.Residency.Start()
+	s.NostrBackup.Start()
+	s.Mentions.Start()
+	s.Moderation.Start()
+	s.Profiles.Start()
+	s.Coverage.Start()
+	s.Breakdown.Start()
+	s.Pressure.Start()
+	s.ConfigDrift.Start()
+	s.Audit.Start()
+	s.KindPolicies.Start()
+	s.Expiration.Start()
+	s.Reports.Start()
+	s.Admission.Start()
+	s.SystemStats.Start()
+	s.Uptime.Start()
+	s.StatsCache.Start()
+	s.Announcement.Start()
+	s.Media.Start()
+	s.FollowAccess.Start()
+	s.Quotas.Start()
 }
 
 // Stop stops all background services gracefully.
 func (s *Services) Stop() {
+	// Cancel jobs first so they finish while the services they use are up
+	s.Jobs.Stop()
+	s.Quotas.Stop()
+	s.FollowAccess.Stop()
+	s.Media.Stop()
+	s.Announcement.Stop()
+	s.Signer.Stop()
+	s.StatsCache.Stop()
+	s.Uptime.Stop()
+	s.SystemStats.Stop()
+	s.Admission.Stop()
+	s.Reports.Stop()
+	s.Expiration.Stop()
+	s.KindPolicies.Stop()
+	s.Audit.Stop()
+	s.ConfigDrift.Stop()
+	s.Pressure.Stop()
+	s.Breakdown.Stop()
+	s.Coverage.Stop()
+	s.Profiles.Stop()
+	s.Moderation.Stop()
+	s.Mentions.Stop()
+	s.NostrBackup.Stop()
+	s.Residency.Stop()
+	s.Exports.Stop()
+	s.Bandwidth.Stop()
+	s.Renewal.Stop()
 	s.Expiry.Stop()
 	s.InvoiceMonitor.Stop()
+	s.Backup.Stop()
+	s.Maintenance.Stop()
 	s.Retention.Stop()
+	s.Webhooks.Stop()
+	s.SearchIndex.Stop()
+	s.RelayIndex.Stop()
+	s.RateLimit.Stop()
 }
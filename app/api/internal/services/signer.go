@@ -0,0 +1,575 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// nip46Kind is the kind of NIP-46 remote signing requests and responses.
+const nip46Kind = 24133
+
+const (
+	// signerTimeout bounds a request to the remote signer, which may wait
+	// for the operator to approve it on their phone.
+	signerTimeout = time.Minute
+
+	// signerPairingTimeout is how long a nostrconnect:// pairing waits for
+	// the operator to scan it.
+	signerPairingTimeout = 10 * time.Minute
+)
+
+// DefaultSignerRelays are used for nostrconnect:// pairings when the
+// operator does not choose relays.
+var DefaultSignerRelays = []string{"wss://relay.nsec.app"}
+
+// Signing modes reported by Signer.Status.
+const (
+	SignerModeRemote = "remote" // A paired NIP-46 remote signer
+	SignerModeKey    = "key"    // The secret key delegated for deletions
+	SignerModeNone   = "none"
+)
+
+// Signer errors
+var (
+	ErrNoSigner          = errors.New("no operator signer is configured")
+	ErrInvalidBunkerURI  = errors.New("invalid bunker URI")
+	ErrSignerMismatch    = errors.New("remote signer does not sign as the operator")
+	ErrSignerUnreachable = errors.New("remote signer did not respond")
+	ErrSignerRejected    = errors.New("remote signer rejected the request")
+)
+
+// BunkerConnection holds the parts of a bunker:// connection string.
+type BunkerConnection struct {
+	SignerPubkey string
+	Relays       []string
+	Secret       string
+}
+
+// ParseBunkerURI parses a NIP-46 connection string of the form
+// bunker://<remote signer pubkey>?relay=<wss url>&relay=...&secret=<value>.
+func ParseBunkerURI(uri string) (*BunkerConnection, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBunkerURI, err)
+	}
+	if u.Scheme != "bunker" {
+		return nil, fmt.Errorf("%w: unexpected scheme %q", ErrInvalidBunkerURI, u.Scheme)
+	}
+
+	pubkey := u.Host
+	if pubkey == "" {
+		pubkey = u.Opaque
+	}
+	pubkey = strings.ToLower(pubkey)
+	if !nostr.IsValidHexPubkey(pubkey) {
+		return nil, fmt.Errorf("%w: invalid signer pubkey", ErrInvalidBunkerURI)
+	}
+
+	query := u.Query()
+	var relays []string
+	for _, relay := range query["relay"] {
+		if !strings.HasPrefix(relay, "wss://") && !strings.HasPrefix(relay, "ws://") {
+			return nil, fmt.Errorf("%w: invalid relay %q", ErrInvalidBunkerURI, relay)
+		}
+		relays = append(relays, relay)
+	}
+	if len(relays) == 0 {
+		return nil, fmt.Errorf("%w: missing relay", ErrInvalidBunkerURI)
+	}
+
+	return &BunkerConnection{
+		SignerPubkey: pubkey,
+		Relays:       relays,
+		Secret:       query.Get("secret"),
+	}, nil
+}
+
+// SignerPairing is a nostrconnect:// pairing waiting for the operator's
+// signer to connect.
+type SignerPairing struct {
+	URI       string    `json:"uri"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Error     string    `json:"error,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// SignerStatus describes how Roostr signs events as the operator.
+type SignerStatus struct {
+	Mode         string         `json:"mode"`
+	Pubkey       string         `json:"pubkey,omitempty"`
+	SignerPubkey string         `json:"signer_pubkey,omitempty"`
+	Relays       []string       `json:"relays,omitempty"`
+	PairedAt     *time.Time     `json:"paired_at,omitempty"`
+	Pairing      *SignerPairing `json:"pairing,omitempty"`
+}
+
+// nip46Response is the decrypted content of a NIP-46 response event.
+type nip46Response struct {
+	ID     string `json:"id"`
+	Result string `json:"result"`
+	Error  string `json:"error"`
+}
+
+// Signer signs events as the operator: deletions, relay announcements,
+// notification DMs and other events published on their behalf. It asks the operator's NIP-46
+// remote signer (nsecbunker, Amber) when one is paired, so the operator's
+// secret key never reaches Roostr, and otherwise uses the key delegated in
+// the tombstone settings.
+//
+// Requests to the remote signer are encrypted with NIP-04, which remote
+// signers still accept alongside NIP-44.
+type Signer struct {
+	db      *db.DB
+	mu      sync.Mutex
+	pairing *SignerPairing
+}
+
+// NewSigner creates a new operator signer.
+func NewSigner(database *db.DB) *Signer {
+	return &Signer{db: database}
+}
+
+// Stop abandons a pairing still waiting for the remote signer.
+func (s *Signer) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pairing != nil {
+		s.pairing.cancel()
+		s.pairing = nil
+	}
+}
+
+// Status reports how events are signed as the operator and any pairing in
+// progress.
+func (s *Signer) Status(ctx context.Context) (*SignerStatus, error) {
+	status := &SignerStatus{Mode: SignerModeNone}
+
+	// Held while reading the pairing, so a pairing that just completed is
+	// not reported alongside the signer it saved
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pairing != nil {
+		pairing := *s.pairing
+		status.Pairing = &pairing
+	}
+
+	remote, err := s.db.GetRemoteSigner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if remote != nil {
+		status.Mode = SignerModeRemote
+		status.Pubkey = remote.Pubkey
+		status.SignerPubkey = remote.SignerPubkey
+		status.Relays = remote.Relays
+		status.PairedAt = &remote.PairedAt
+		return status, nil
+	}
+
+	secret, err := s.db.GetOperatorSigningKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if secret != "" {
+		status.Mode = SignerModeKey
+		status.Pubkey, _ = nostr.GetPublicKey(secret)
+	}
+	return status, nil
+}
+
+// Pubkey returns the pubkey events are signed as.
+func (s *Signer) Pubkey(ctx context.Context) (string, error) {
+	status, err := s.Status(ctx)
+	if err != nil {
+		return "", err
+	}
+	if status.Mode == SignerModeNone {
+		return "", ErrNoSigner
+	}
+	return status.Pubkey, nil
+}
+
+// Sign signs the event as the operator, setting its pubkey, ID and
+// signature. It returns ErrNoSigner if there is no way to sign.
+func (s *Signer) Sign(ctx context.Context, event *nostr.SyncEvent) error {
+	remote, err := s.db.GetRemoteSigner(ctx)
+	if err != nil {
+		return err
+	}
+	if remote != nil {
+		return s.signRemote(ctx, remote, event)
+	}
+
+	secret, err := s.db.GetOperatorSigningKey(ctx)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return ErrNoSigner
+	}
+	return event.Sign(secret)
+}
+
+// Encrypt encrypts plaintext for recipient with NIP-04 as the operator, for
+// direct messages signed with Sign. It returns ErrNoSigner if there is no
+// way to sign.
+func (s *Signer) Encrypt(ctx context.Context, recipient, plaintext string) (string, error) {
+	remote, err := s.db.GetRemoteSigner(ctx)
+	if err != nil {
+		return "", err
+	}
+	if remote != nil {
+		return s.call(ctx, remote, "nip04_encrypt", recipient, plaintext)
+	}
+
+	secret, err := s.db.GetOperatorSigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", ErrNoSigner
+	}
+	return nostr.NIP04Encrypt(secret, recipient, plaintext)
+}
+
+// signRemote asks the remote signer to sign the event and checks that the
+// signed event is the one requested.
+func (s *Signer) signRemote(ctx context.Context, remote *db.RemoteSigner, event *nostr.SyncEvent) error {
+	if event.Tags == nil {
+		event.Tags = [][]string{}
+	}
+	event.Pubkey = remote.Pubkey
+	id, err := event.ComputeID()
+	if err != nil {
+		return err
+	}
+	unsigned, _ := json.Marshal(map[string]interface{}{
+		"pubkey":     event.Pubkey,
+		"created_at": event.CreatedAt,
+		"kind":       event.Kind,
+		"tags":       event.Tags,
+		"content":    event.Content,
+	})
+
+	result, err := s.call(ctx, remote, "sign_event", string(unsigned))
+	if err != nil {
+		return err
+	}
+
+	var signed nostr.SyncEvent
+	if err := json.Unmarshal([]byte(result), &signed); err != nil {
+		return fmt.Errorf("failed to decode signed event: %w", err)
+	}
+	if err := signed.Verify(); err != nil {
+		return fmt.Errorf("remote signer returned an invalid event: %w", err)
+	}
+	if signed.ID != id {
+		return errors.New("remote signer returned a different event")
+	}
+
+	event.ID = signed.ID
+	event.Sig = signed.Sig
+	return nil
+}
+
+// Ping checks that the paired remote signer answers.
+func (s *Signer) Ping(ctx context.Context) error {
+	remote, err := s.db.GetRemoteSigner(ctx)
+	if err != nil {
+		return err
+	}
+	if remote == nil {
+		return ErrNoSigner
+	}
+	_, err = s.call(ctx, remote, "ping")
+	return err
+}
+
+// Pair connects to the remote signer of a bunker:// URI and saves the
+// pairing. The signer must sign as the operator, if one is set.
+func (s *Signer) Pair(ctx context.Context, uri string) (*db.RemoteSigner, error) {
+	conn, err := ParseBunkerURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := nostr.GenerateSecretKey()
+	if err != nil {
+		return nil, err
+	}
+
+	remote := &db.RemoteSigner{
+		SignerPubkey: conn.SignerPubkey,
+		Relays:       conn.Relays,
+		ClientSecret: clientSecret,
+	}
+	params := []string{conn.SignerPubkey}
+	if conn.Secret != "" {
+		params = append(params, conn.Secret)
+	}
+	if _, err := s.call(ctx, remote, "connect", params...); err != nil {
+		return nil, err
+	}
+	if err := s.identify(ctx, remote); err != nil {
+		return nil, err
+	}
+	if err := s.db.SetRemoteSigner(ctx, remote); err != nil {
+		return nil, err
+	}
+	return remote, nil
+}
+
+// StartPairing returns a nostrconnect:// URI for the operator to scan with
+// their signer app and waits in the background for the signer to connect,
+// replacing any pairing already waiting. Status reports the outcome.
+func (s *Signer) StartPairing(relays []string) (*SignerPairing, error) {
+	if len(relays) == 0 {
+		relays = DefaultSignerRelays
+	}
+	clientSecret, err := nostr.GenerateSecretKey()
+	if err != nil {
+		return nil, err
+	}
+	clientPubkey, _ := nostr.GetPublicKey(clientSecret)
+	secretBytes := make([]byte, 16)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, err
+	}
+	secret := hex.EncodeToString(secretBytes)
+
+	query := url.Values{"relay": relays, "secret": {secret}, "name": {"Roostr"}}
+	ctx, cancel := context.WithTimeout(context.Background(), signerPairingTimeout)
+	pairing := &SignerPairing{
+		URI:       "nostrconnect://" + clientPubkey + "?" + query.Encode(),
+		ExpiresAt: time.Now().Add(signerPairingTimeout),
+		cancel:    cancel,
+	}
+
+	s.mu.Lock()
+	if s.pairing != nil {
+		s.pairing.cancel()
+	}
+	s.pairing = pairing
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		remote, err := s.awaitPairing(ctx, clientSecret, relays, secret)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pairing != pairing {
+			return // Replaced or stopped
+		}
+		if err == nil {
+			err = s.db.SetRemoteSigner(ctx, remote)
+		}
+		if err != nil {
+			slog.Warn("Remote signer pairing failed", "error", err)
+			pairing.Error = err.Error()
+			return
+		}
+		s.pairing = nil
+	}()
+
+	result := *pairing
+	return &result, nil
+}
+
+// awaitPairing waits for a signer to answer a nostrconnect:// URI with its
+// secret on any of the relays, and returns the pairing to save.
+func (s *Signer) awaitPairing(ctx context.Context, clientSecret string, relays []string, secret string) (*db.RemoteSigner, error) {
+	clientPubkey, _ := nostr.GetPublicKey(clientSecret)
+	since := time.Now().Add(-time.Minute).Unix()
+	filter := nostr.Filter{
+		Kinds: []int{nip46Kind},
+		PTags: []string{clientPubkey},
+		Since: &since,
+	}
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	connected := make(chan string, len(relays))
+	for _, relayURL := range relays {
+		go func(relayURL string) {
+			client := nostr.NewClient(relayURL)
+			if err := client.Connect(listenCtx); err != nil {
+				slog.Warn("Failed to connect to signer relay", "relay", relayURL, "error", err)
+				return
+			}
+			defer client.Close()
+
+			client.Request(listenCtx, filter, nil, func(event *nostr.SyncEvent) error {
+				if event.Verify() != nil {
+					return nil
+				}
+				plaintext, err := nostr.NIP04Decrypt(clientSecret, event.Pubkey, event.Content)
+				if err != nil {
+					return nil
+				}
+				var resp nip46Response
+				if json.Unmarshal([]byte(plaintext), &resp) != nil || resp.Result != secret {
+					return nil
+				}
+				connected <- event.Pubkey
+				return nostr.ErrStopSubscription
+			})
+		}(relayURL)
+	}
+
+	select {
+	case signerPubkey := <-connected:
+		cancel()
+		remote := &db.RemoteSigner{
+			SignerPubkey: signerPubkey,
+			Relays:       relays,
+			ClientSecret: clientSecret,
+		}
+		if err := s.identify(ctx, remote); err != nil {
+			return nil, err
+		}
+		return remote, nil
+	case <-ctx.Done():
+		return nil, ErrSignerUnreachable
+	}
+}
+
+// identify asks the connected signer which pubkey it signs as and checks
+// that it is the operator's.
+func (s *Signer) identify(ctx context.Context, remote *db.RemoteSigner) error {
+	pubkey, err := s.call(ctx, remote, "get_public_key")
+	if err != nil {
+		return err
+	}
+	pubkey = strings.ToLower(pubkey)
+	if !nostr.IsValidHexPubkey(pubkey) {
+		return fmt.Errorf("remote signer returned an invalid pubkey %q", pubkey)
+	}
+	operator, err := s.db.GetOperatorPubkey(ctx)
+	if err != nil {
+		return err
+	}
+	if operator != "" && pubkey != operator {
+		return ErrSignerMismatch
+	}
+
+	remote.Pubkey = pubkey
+	remote.PairedAt = time.Now().UTC().Truncate(time.Second)
+	return nil
+}
+
+// Unpair forgets the remote signer. Roostr's key for talking to it is
+// deleted, so the operator can also revoke it in their signer app.
+func (s *Signer) Unpair(ctx context.Context) error {
+	return s.db.SetRemoteSigner(ctx, nil)
+}
+
+// call sends an encrypted NIP-46 request to the remote signer and returns
+// its result, trying each of the signer's relays until one answers.
+func (s *Signer) call(ctx context.Context, remote *db.RemoteSigner, method string, params ...string) (string, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(idBytes)
+	if params == nil {
+		params = []string{}
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":     id,
+		"method": method,
+		"params": params,
+	})
+	content, err := nostr.NIP04Encrypt(remote.ClientSecret, remote.SignerPubkey, string(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt signer request: %w", err)
+	}
+	request := nostr.NewEvent(nip46Kind, [][]string{{"p", remote.SignerPubkey}}, content)
+	if err := request.Sign(remote.ClientSecret); err != nil {
+		return "", fmt.Errorf("failed to sign signer request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, signerTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, relayURL := range remote.Relays {
+		result, err := s.request(ctx, relayURL, remote, request, id)
+		if err == nil || errors.Is(err, ErrSignerRejected) {
+			return result, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", relayURL, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", fmt.Errorf("%w: %v", ErrSignerUnreachable, errors.Join(errs...))
+}
+
+// request publishes a NIP-46 request on one relay and waits for the
+// response with its ID. A signer that first needs the operator's approval
+// answers with an auth_url, and the real response follows once approved.
+func (s *Signer) request(ctx context.Context, relayURL string, remote *db.RemoteSigner, request *nostr.SyncEvent, id string) (string, error) {
+	client := nostr.NewClient(relayURL)
+	if err := client.Connect(ctx); err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	since := request.CreatedAt - 10
+	filter := nostr.Filter{
+		Kinds:   []int{nip46Kind},
+		Authors: []string{remote.SignerPubkey},
+		PTags:   []string{request.Pubkey},
+		Since:   &since,
+	}
+
+	var resp *nip46Response
+	authURL := ""
+	err := client.Request(ctx, filter, request, func(event *nostr.SyncEvent) error {
+		if event.Pubkey != remote.SignerPubkey || event.Verify() != nil {
+			return nil // Ignore anything not signed by the signer
+		}
+		plaintext, err := nostr.NIP04Decrypt(remote.ClientSecret, remote.SignerPubkey, event.Content)
+		if err != nil {
+			return nil
+		}
+		var r nip46Response
+		if json.Unmarshal([]byte(plaintext), &r) != nil || r.ID != id {
+			return nil
+		}
+		if r.Result == "auth_url" {
+			authURL = r.Error
+			slog.Info("Remote signer is waiting for approval", "url", authURL)
+			return nil
+		}
+		resp = &r
+		return nostr.ErrStopSubscription
+	})
+	if resp == nil {
+		if authURL != "" {
+			return "", fmt.Errorf("request was not approved at %s", authURL)
+		}
+		if err == nil {
+			err = errors.New("no response")
+		}
+		return "", err
+	}
+	if resp.Error != "" {
+		return "", fmt.Errorf("%w: %s", ErrSignerRejected, resp.Error)
+	}
+	return resp.Result, nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// fakeBunker is a relay with a NIP-46 remote signer behind it. It answers
+// requests on the connection they arrive on. Once Roostr listens for a
+// nostrconnect:// pairing, scan answers it like a signer app would.
+type fakeBunker struct {
+	userSecret    string // The key the signer signs with
+	signerSecret  string // The signer's own key for NIP-46 messages
+	connectSecret string
+	listening     chan struct{}
+
+	mu   sync.Mutex
+	scan func(secret string)
+}
+
+func newFakeBunker(t *testing.T, userSecret string) (*fakeBunker, string) {
+	t.Helper()
+	signerSecret, _ := nostr.GenerateSecretKey()
+	bunker := &fakeBunker{
+		userSecret:    userSecret,
+		signerSecret:  signerSecret,
+		connectSecret: "s3cret",
+		listening:     make(chan struct{}, 1),
+	}
+	server := httptest.NewServer(http.HandlerFunc(bunker.serve))
+	t.Cleanup(server.Close)
+	return bunker, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func (f *fakeBunker) signerPubkey() string {
+	pubkey, _ := nostr.GetPublicKey(f.signerSecret)
+	return pubkey
+}
+
+func (f *fakeBunker) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := nostr.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	respond := func(subID, clientPubkey string, resp nip46Response) {
+		payload, _ := json.Marshal(resp)
+		content, _ := nostr.NIP04Encrypt(f.signerSecret, clientPubkey, string(payload))
+		event := nostr.NewEvent(nip46Kind, [][]string{{"p", clientPubkey}}, content)
+		event.Sign(f.signerSecret)
+		conn.WriteJSON([]interface{}{"EVENT", subID, event})
+	}
+
+	subID := ""
+	for {
+		message, err := conn.ReadMessage(5 * time.Second)
+		if err != nil {
+			return
+		}
+		var raw []json.RawMessage
+		if json.Unmarshal(message, &raw) != nil || len(raw) < 2 {
+			continue
+		}
+		var msgType string
+		json.Unmarshal(raw[0], &msgType)
+
+		switch msgType {
+		case "REQ":
+			var filter nostr.Filter
+			json.Unmarshal(raw[1], &subID)
+			json.Unmarshal(raw[2], &filter)
+			conn.WriteJSON([]interface{}{"EOSE", subID})
+
+			if len(filter.Authors) == 0 && len(filter.PTags) == 1 {
+				pairingSub, clientPubkey := subID, filter.PTags[0]
+				f.mu.Lock()
+				f.scan = func(secret string) {
+					respond(pairingSub, clientPubkey, nip46Response{ID: "pair", Result: secret})
+				}
+				f.mu.Unlock()
+				f.listening <- struct{}{}
+			}
+		case "EVENT":
+			var event nostr.SyncEvent
+			json.Unmarshal(raw[1], &event)
+			plaintext, err := nostr.NIP04Decrypt(f.signerSecret, event.Pubkey, event.Content)
+			if err != nil {
+				continue
+			}
+			var req struct {
+				ID     string   `json:"id"`
+				Method string   `json:"method"`
+				Params []string `json:"params"`
+			}
+			json.Unmarshal([]byte(plaintext), &req)
+
+			resp := nip46Response{ID: req.ID}
+			switch req.Method {
+			case "connect":
+				if len(req.Params) < 2 || req.Params[0] != f.signerPubkey() || req.Params[1] != f.connectSecret {
+					resp.Error = "invalid secret"
+				} else {
+					resp.Result = "ack"
+				}
+			case "get_public_key":
+				resp.Result, _ = nostr.GetPublicKey(f.userSecret)
+			case "sign_event":
+				var unsigned nostr.SyncEvent
+				json.Unmarshal([]byte(req.Params[0]), &unsigned)
+				unsigned.Sign(f.userSecret)
+				signed, _ := json.Marshal(unsigned)
+				resp.Result = string(signed)
+			case "ping":
+				resp.Result = "pong"
+			default:
+				resp.Error = "unsupported method"
+			}
+			respond(subID, event.Pubkey, resp)
+		}
+	}
+}
+
+func TestParseBunkerURI(t *testing.T) {
+	pubkey := strings.Repeat("ab", 32)
+	tests := []struct {
+		name    string
+		uri     string
+		relays  int
+		secret  string
+		wantErr bool
+	}{
+		{"valid", "bunker://" + pubkey + "?relay=wss://relay.nsec.app&secret=abc", 1, "abc", false},
+		{"several relays", "bunker://" + pubkey + "?relay=wss%3A%2F%2Fa.example&relay=wss://b.example", 2, "", false},
+		{"wrong scheme", "nostrconnect://" + pubkey + "?relay=wss://relay.nsec.app", 0, "", true},
+		{"bad pubkey", "bunker://npub1xyz?relay=wss://relay.nsec.app", 0, "", true},
+		{"no relay", "bunker://" + pubkey, 0, "", true},
+		{"bad relay", "bunker://" + pubkey + "?relay=https://relay.nsec.app", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := ParseBunkerURI(tt.uri)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidBunkerURI) {
+					t.Errorf("expected ErrInvalidBunkerURI, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if conn.SignerPubkey != pubkey || len(conn.Relays) != tt.relays || conn.Secret != tt.secret {
+				t.Errorf("unexpected connection: %+v", conn)
+			}
+		})
+	}
+}
+
+func TestSigner_Pair(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	operator, _ := nostr.GetPublicKey(operatorSecret)
+	database.SetOperatorPubkey(ctx, operator)
+
+	bunker, relayURL := newFakeBunker(t, operatorSecret)
+	signer := NewSigner(database)
+	uri := "bunker://" + bunker.signerPubkey() + "?" + url.Values{"relay": {relayURL}, "secret": {"s3cret"}}.Encode()
+
+	if _, err := signer.Pair(ctx, strings.Replace(uri, "s3cret", "wrong", 1)); !errors.Is(err, ErrSignerRejected) {
+		t.Errorf("expected a wrong connect secret to be rejected, got %v", err)
+	}
+
+	remote, err := signer.Pair(ctx, uri)
+	if err != nil {
+		t.Fatalf("Pair failed: %v", err)
+	}
+	if remote.Pubkey != operator || remote.SignerPubkey != bunker.signerPubkey() {
+		t.Errorf("unexpected pairing: %+v", remote)
+	}
+	if status, _ := signer.Status(ctx); status.Mode != SignerModeRemote || status.Pubkey != operator {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	// Events are signed by the remote signer, which Roostr has no key for
+	event := nostr.NewEvent(DeletionKind, [][]string{{"e", strings.Repeat("1", 64)}}, "spam")
+	if err := signer.Sign(ctx, event); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if event.Pubkey != operator || event.Verify() != nil {
+		t.Errorf("expected an event signed by the operator, got %+v", event)
+	}
+	if err := signer.Ping(ctx); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+
+	// A signer for someone else's key is refused
+	signer.Unpair(ctx)
+	database.SetOperatorPubkey(ctx, strings.Repeat("cd", 32))
+	if _, err := signer.Pair(ctx, uri); !errors.Is(err, ErrSignerMismatch) {
+		t.Errorf("expected ErrSignerMismatch, got %v", err)
+	}
+	if err := signer.Sign(ctx, nostr.NewEvent(1, nil, "hi")); !errors.Is(err, ErrNoSigner) {
+		t.Errorf("expected ErrNoSigner after unpairing, got %v", err)
+	}
+
+	// Without a remote signer the delegated key is used
+	database.SetOperatorSigningKey(ctx, operatorSecret)
+	if status, _ := signer.Status(ctx); status.Mode != SignerModeKey || status.Pubkey != operator {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestSigner_StartPairing(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+	operatorSecret, _ := nostr.GenerateSecretKey()
+	operator, _ := nostr.GetPublicKey(operatorSecret)
+
+	bunker, relayURL := newFakeBunker(t, operatorSecret)
+	signer := NewSigner(database)
+	defer signer.Stop()
+
+	pairing, err := signer.StartPairing([]string{relayURL})
+	if err != nil {
+		t.Fatalf("StartPairing failed: %v", err)
+	}
+	u, err := url.Parse(pairing.URI)
+	if err != nil || u.Scheme != "nostrconnect" || u.Query().Get("relay") != relayURL {
+		t.Fatalf("unexpected pairing URI %q", pairing.URI)
+	}
+
+	if status, _ := signer.Status(ctx); status.Mode != SignerModeNone || status.Pairing == nil {
+		t.Errorf("expected a pairing in progress, got %+v", status)
+	}
+
+	// The operator scans the URI; their signer answers with its secret
+	select {
+	case <-bunker.listening:
+	case <-time.After(5 * time.Second):
+		t.Fatal("signer did not listen for the pairing")
+	}
+	bunker.mu.Lock()
+	scan := bunker.scan
+	bunker.mu.Unlock()
+	scan("not the secret")
+	scan(u.Query().Get("secret"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := signer.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if status.Mode == SignerModeRemote {
+			if status.Pubkey != operator || status.SignerPubkey != bunker.signerPubkey() || status.Pairing != nil {
+				t.Errorf("unexpected status: %+v", status)
+			}
+			break
+		}
+		if status.Pairing != nil && status.Pairing.Error != "" {
+			t.Fatalf("pairing failed: %s", status.Pairing.Error)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pairing did not complete")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+const (
+	// statsCacheInterval is how often cached statistics are recomputed.
+	statsCacheInterval = time.Minute
+
+	// statsCacheMaxAge is how old a cached value may be before a request
+	// recomputes it, in case the background refresh is behind or stopped.
+	statsCacheMaxAge = 5 * time.Minute
+
+	// statsCacheIdle is how long a statistic is kept fresh after it was
+	// last asked for.
+	statsCacheIdle = time.Hour
+
+	// statsKeyRelayStats is the cache key of the relay's totals.
+	statsKeyRelayStats = "relay-stats"
+)
+
+// StatsComputeFunc computes a cached statistic.
+type StatsComputeFunc func(ctx context.Context) (interface{}, error)
+
+// statsEntry is one cached statistic. Its mutex is held while the value is
+// computed, so concurrent requests for a missing value compute it once.
+type statsEntry struct {
+	compute  StatsComputeFunc
+	lastUsed time.Time // Guarded by StatsCacheService.mu
+
+	mu         sync.Mutex
+	value      interface{}
+	computedAt time.Time
+}
+
+// StatsCacheService keeps the dashboard's expensive aggregates in memory,
+// recomputing those asked for in the last hour every minute, so page loads
+// do not count over the whole relay database.
+type StatsCacheService struct {
+	db       *db.DB
+	interval time.Duration
+	now      func() time.Time
+
+	entries map[string]*statsEntry
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running bool
+	mu      sync.Mutex
+}
+
+// NewStatsCacheService creates a new statistics cache.
+func NewStatsCacheService(database *db.DB) *StatsCacheService {
+	return &StatsCacheService{
+		db:       database,
+		interval: statsCacheInterval,
+		now:      time.Now,
+		entries:  make(map[string]*statsEntry),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins refreshing cached statistics, starting with the relay's
+// totals so the first dashboard load is served from memory.
+func (s *StatsCacheService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the refresh.
+func (s *StatsCacheService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *StatsCacheService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	if s.db.IsRelayDBConnected() {
+		if _, _, err := s.RelayStats(ctx, false); err != nil && ctx.Err() == nil {
+			slog.Warn("Failed to precompute relay stats", "error", err)
+		}
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+// Get returns the cached statistic under key and when it was computed,
+// computing it first if it is missing, older than five minutes, or fresh
+// is set. The compute function is kept to refresh the value in the
+// background, so it must not depend on the request beyond its context.
+func (s *StatsCacheService) Get(ctx context.Context, key string, fresh bool, compute StatsComputeFunc) (interface{}, time.Time, error) {
+	s.mu.Lock()
+	e := s.entries[key]
+	if e == nil {
+		e = &statsEntry{}
+		s.entries[key] = e
+	}
+	e.compute = compute
+	e.lastUsed = s.now()
+	s.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !fresh && !e.computedAt.IsZero() && s.now().Sub(e.computedAt) < statsCacheMaxAge {
+		return e.value, e.computedAt, nil
+	}
+	if err := s.compute(ctx, e, compute); err != nil {
+		return nil, time.Time{}, err
+	}
+	return e.value, e.computedAt, nil
+}
+
+// RelayStats returns the relay's totals and when they were computed.
+func (s *StatsCacheService) RelayStats(ctx context.Context, fresh bool) (*db.RelayStats, time.Time, error) {
+	v, computedAt, err := s.Get(ctx, statsKeyRelayStats, fresh, func(ctx context.Context) (interface{}, error) {
+		return s.db.GetRelayStats(ctx)
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return v.(*db.RelayStats), computedAt, nil
+}
+
+// Clear drops every cached statistic, for when the relay database they
+// were computed from is replaced.
+func (s *StatsCacheService) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]*statsEntry)
+}
+
+// refresh recomputes the statistics asked for within the last hour and
+// drops the rest.
+func (s *StatsCacheService) refresh(ctx context.Context) {
+	s.mu.Lock()
+	now := s.now()
+	var due []*statsEntry
+	for key, e := range s.entries {
+		if now.Sub(e.lastUsed) >= statsCacheIdle {
+			delete(s.entries, key)
+			continue
+		}
+		due = append(due, e)
+	}
+	s.mu.Unlock()
+
+	if !s.db.IsRelayDBConnected() {
+		return
+	}
+	for _, e := range due {
+		s.mu.Lock()
+		compute := e.compute
+		s.mu.Unlock()
+
+		e.mu.Lock()
+		err := s.compute(ctx, e, compute)
+		e.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("Failed to refresh cached stats", "error", err)
+		}
+	}
+}
+
+// compute stores a newly computed value in e, whose mutex the caller holds.
+// On error the previous value is kept.
+func (s *StatsCacheService) compute(ctx context.Context, e *statsEntry, compute StatsComputeFunc) error {
+	value, err := compute(ctx)
+	if err != nil {
+		return err
+	}
+	e.value = value
+	e.computedAt = s.now()
+	return nil
+}
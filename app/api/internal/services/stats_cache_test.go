@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatsCacheService_Get(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	svc := NewStatsCacheService(database)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	calls := 0
+	compute := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	v, computedAt, err := svc.Get(ctx, "count", false, compute)
+	if err != nil || v.(int) != 1 || !computedAt.Equal(now) {
+		t.Fatalf("first Get = %v, %v, %v", v, computedAt, err)
+	}
+
+	now = now.Add(time.Minute)
+	if v, computedAt, _ := svc.Get(ctx, "count", false, compute); v.(int) != 1 || computedAt.Equal(now) {
+		t.Errorf("expected the cached value, got %v computed at %v", v, computedAt)
+	}
+	if v, _, _ := svc.Get(ctx, "count", true, compute); v.(int) != 2 {
+		t.Errorf("expected fresh to recompute, got %v", v)
+	}
+
+	// A value past its maximum age is recomputed even without a refresh
+	now = now.Add(statsCacheMaxAge)
+	if v, _, _ := svc.Get(ctx, "count", false, compute); v.(int) != 3 {
+		t.Errorf("expected a stale value to be recomputed, got %v", v)
+	}
+
+	// A failed computation is returned and the old value kept
+	failing := func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("database is locked")
+	}
+	if _, _, err := svc.Get(ctx, "count", true, failing); err == nil {
+		t.Error("expected the computation error")
+	}
+	if v, _, _ := svc.Get(ctx, "count", false, compute); v.(int) != 3 {
+		t.Errorf("expected the last good value, got %v", v)
+	}
+
+	svc.Clear()
+	if v, _, _ := svc.Get(ctx, "count", false, compute); v.(int) != 4 {
+		t.Errorf("expected Clear to drop the value, got %v", v)
+	}
+}
+
+func TestStatsCacheService_Refresh(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	svc := NewStatsCacheService(database)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	counts := map[string]int{}
+	computeFor := func(key string) StatsComputeFunc {
+		return func(ctx context.Context) (interface{}, error) {
+			counts[key]++
+			return counts[key], nil
+		}
+	}
+	svc.Get(ctx, "idle", false, computeFor("idle"))
+	now = now.Add(statsCacheIdle - time.Minute)
+	svc.Get(ctx, "used", false, computeFor("used"))
+
+	now = now.Add(time.Minute)
+	svc.refresh(ctx)
+	if counts["used"] != 2 {
+		t.Errorf("expected the used statistic to be refreshed, computed %d times", counts["used"])
+	}
+	if counts["idle"] != 1 {
+		t.Errorf("expected the idle statistic not to be refreshed, computed %d times", counts["idle"])
+	}
+	if _, ok := svc.entries["idle"]; ok {
+		t.Error("expected the idle statistic to be dropped")
+	}
+
+	if v, computedAt, _ := svc.Get(ctx, "used", false, computeFor("used")); v.(int) != 2 || !computedAt.Equal(now) {
+		t.Errorf("expected the refreshed value, got %v computed at %v", v, computedAt)
+	}
+}
+
+func TestStatsCacheService_RelayStats(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	svc := NewStatsCacheService(database)
+	ctx := context.Background()
+
+	stats, computedAt, err := svc.RelayStats(ctx, false)
+	if err != nil {
+		t.Fatalf("RelayStats failed: %v", err)
+	}
+	if stats == nil || computedAt.IsZero() {
+		t.Fatalf("unexpected relay stats: %+v at %v", stats, computedAt)
+	}
+	if again, _, _ := svc.RelayStats(ctx, false); again != stats {
+		t.Error("expected the cached relay stats")
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+const (
+	// storageBreakdownAge is how long a storage breakdown is served before
+	// the background worker computes it again.
+	storageBreakdownAge = 6 * time.Hour
+
+	// storageBreakdownTopN is how many of the largest authors a breakdown
+	// keeps.
+	storageBreakdownTopN = 100
+)
+
+// StorageBreakdownService answers "who is filling my disk?" by totalling
+// storage per author and per kind. Totalling scans every event, so the
+// result is saved and recomputed in the background every few hours.
+type StorageBreakdownService struct {
+	db        *db.DB
+	hardware  *HardwareService
+	interval  time.Duration
+	now       func() time.Time
+	refreshMu sync.Mutex // one scan at a time
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+}
+
+// NewStorageBreakdownService creates a new storage breakdown service.
+func NewStorageBreakdownService(database *db.DB) *StorageBreakdownService {
+	return &StorageBreakdownService{
+		db:       database,
+		interval: 30 * time.Minute,
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the background refresh worker.
+func (s *StorageBreakdownService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the refresh worker.
+func (s *StorageBreakdownService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *StorageBreakdownService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.refreshIfStale(ctx)
+		}
+	}
+}
+
+// refreshIfStale recomputes the breakdown when it is older than
+// storageBreakdownAge (longer in low-power mode) or was never computed.
+func (s *StorageBreakdownService) refreshIfStale(ctx context.Context) {
+	if !s.db.IsRelayDBConnected() {
+		return
+	}
+	cached, err := s.db.GetCachedStorageBreakdown(ctx)
+	if err == nil && cached != nil &&
+		cached.ComputedAt.After(s.now().Add(-s.hardware.Tuning().refreshAge(storageBreakdownAge))) {
+		return
+	}
+	if _, err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+		slog.Warn("Failed to compute storage breakdown", "error", err)
+	}
+}
+
+// Get returns the saved breakdown, computing it first if there is none.
+func (s *StorageBreakdownService) Get(ctx context.Context) (*db.StorageBreakdown, error) {
+	cached, err := s.db.GetCachedStorageBreakdown(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		return cached, nil
+	}
+	return s.Refresh(ctx)
+}
+
+// Refresh computes and saves the breakdown. A caller that waited for
+// another scan to finish gets that scan's result.
+func (s *StorageBreakdownService) Refresh(ctx context.Context) (*db.StorageBreakdown, error) {
+	requested := s.now()
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	if cached, err := s.db.GetCachedStorageBreakdown(ctx); err == nil && cached != nil && !cached.ComputedAt.Before(requested) {
+		return cached, nil
+	}
+
+	breakdown, err := s.db.GetStorageBreakdown(ctx, storageBreakdownTopN)
+	if err != nil {
+		return nil, err
+	}
+	breakdown.ComputedAt = s.now()
+	if err := s.db.SetCachedStorageBreakdown(ctx, breakdown); err != nil {
+		return nil, err
+	}
+	return breakdown, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestStorageBreakdownService(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	member := strings.Repeat("aa", 32)
+	insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{ID: strings.Repeat("01", 32), Pubkey: member, Kind: 1, CreatedAt: 1700000000})
+
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	s := NewStorageBreakdownService(database)
+	s.now = func() time.Time { return now }
+
+	// The first request computes the breakdown
+	breakdown, err := s.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if breakdown.TotalEvents != 1 || len(breakdown.TopAuthors) != 1 || breakdown.TopAuthors[0].Pubkey != member {
+		t.Errorf("unexpected breakdown %+v", breakdown)
+	}
+
+	// Later requests are served from the saved breakdown until it is stale
+	insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{ID: hex.EncodeToString([]byte(strings.Repeat("b", 32))), Pubkey: member, Kind: 7, CreatedAt: 1700000001})
+	now = now.Add(time.Hour)
+	s.refreshIfStale(ctx)
+	if breakdown, _ = s.Get(ctx); breakdown.TotalEvents != 1 {
+		t.Errorf("expected the saved breakdown, got %d events", breakdown.TotalEvents)
+	}
+
+	now = now.Add(storageBreakdownAge)
+	s.refreshIfStale(ctx)
+	if breakdown, _ = s.Get(ctx); breakdown.TotalEvents != 2 || !breakdown.ComputedAt.Equal(now) {
+		t.Errorf("expected a recomputed breakdown, got %+v", breakdown)
+	}
+
+	insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{ID: strings.Repeat("03", 32), Pubkey: member, Kind: 0, CreatedAt: 1700000002})
+	now = now.Add(time.Minute)
+	if breakdown, err = s.Refresh(ctx); err != nil || breakdown.TotalEvents != 3 {
+		t.Errorf("expected Refresh() to recompute, got %+v, %v", breakdown, err)
+	}
+}
@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Maintenance triggers recorded in the maintenance history.
+const (
+	MaintenanceTriggerScheduled = "scheduled"
+	MaintenanceTriggerManual    = "manual"
+)
+
+const (
+	// maintenanceCheckInterval is how often the scheduler looks for due tasks.
+	maintenanceCheckInterval = 15 * time.Minute
+
+	// maintenanceIntervalSlack lets a task run a little early, so finishing
+	// a few minutes into the window does not push next week's run later.
+	maintenanceIntervalSlack = time.Hour
+
+	// maxMaintenanceIntervalDays bounds the schedule's intervals.
+	maxMaintenanceIntervalDays = 365
+)
+
+// ErrInvalidMaintenanceSchedule is returned for a schedule with an interval
+// or window hour out of range.
+var ErrInvalidMaintenanceSchedule = errors.New("invalid maintenance schedule")
+
+// StorageMaintenanceService runs VACUUM and integrity checks on the app and
+// relay databases, by hand or on a schedule inside a quiet window. Each run
+// records one maintenance_history row per database; failures emit a
+// maintenance.failed webhook and DM the operator.
+type StorageMaintenanceService struct {
+	db       *db.DB
+	jobs     *JobService
+	webhooks *WebhookService
+	notifier *Notifier
+	now      func() time.Time
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// NewStorageMaintenanceService creates a new storage maintenance service.
+// notifier may be nil to skip operator DMs.
+func NewStorageMaintenanceService(database *db.DB, notifier *Notifier) *StorageMaintenanceService {
+	return &StorageMaintenanceService{
+		db:       database,
+		jobs:     NewJobService(database),
+		notifier: notifier,
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the background scheduler.
+func (s *StorageMaintenanceService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the background scheduler.
+func (s *StorageMaintenanceService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run is the main loop for the scheduler.
+func (s *StorageMaintenanceService) run() {
+	defer s.wg.Done()
+
+	slog.Info("Storage maintenance scheduler started")
+
+	ticker := time.NewTicker(maintenanceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			slog.Info("Storage maintenance scheduler stopped")
+			return
+		case <-ticker.C:
+			s.runDue()
+		}
+	}
+}
+
+// runDue starts the tasks that are due, if the schedule is on and the local
+// time is inside its window.
+func (s *StorageMaintenanceService) runDue() {
+	ctx := context.Background()
+
+	schedule, err := s.db.GetStorageMaintenanceSchedule(ctx)
+	if err != nil {
+		slog.Error("Failed to load storage maintenance schedule", "error", err)
+		return
+	}
+	now := s.now()
+	if !schedule.Enabled || !inMaintenanceWindow(now, schedule.WindowStartHour, schedule.WindowEndHour) {
+		return
+	}
+
+	params := map[string]interface{}{"trigger": MaintenanceTriggerScheduled}
+
+	last, _ := s.db.GetLastVacuumRun(ctx)
+	if maintenanceDue(last, schedule.VacuumIntervalDays, now) {
+		_, err := s.jobs.Run(JobTypeVacuum, params, func(ctx context.Context, run *JobRun) (interface{}, error) {
+			return s.Vacuum(ctx, run, MaintenanceTriggerScheduled)
+		})
+		if err != nil && !errors.Is(err, ErrJobAlreadyRunning) {
+			slog.Error("Failed to start scheduled vacuum", "error", err)
+		}
+	}
+
+	last, _ = s.db.GetLastIntegrityCheck(ctx)
+	if maintenanceDue(last, schedule.IntegrityIntervalDays, now) {
+		_, err := s.jobs.Run(JobTypeIntegrityCheck, params, func(ctx context.Context, run *JobRun) (interface{}, error) {
+			return s.IntegrityCheck(ctx, run, MaintenanceTriggerScheduled)
+		})
+		if err != nil && !errors.Is(err, ErrJobAlreadyRunning) {
+			slog.Error("Failed to start scheduled integrity check", "error", err)
+		}
+	}
+}
+
+// inMaintenanceWindow reports whether t's local hour is in [start, end). A
+// window that ends before it starts wraps past midnight; equal hours mean
+// all day.
+func inMaintenanceWindow(t time.Time, start, end int) bool {
+	hour := t.Hour()
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return hour >= start && hour < end
+	default:
+		return hour >= start || hour < end
+	}
+}
+
+// maintenanceDue reports whether a task last run at last is due again. An
+// interval of 0 turns the task off.
+func maintenanceDue(last *time.Time, intervalDays int, now time.Time) bool {
+	if intervalDays <= 0 {
+		return false
+	}
+	if last == nil {
+		return true
+	}
+	interval := time.Duration(intervalDays) * 24 * time.Hour
+	return now.Sub(*last) >= interval-maintenanceIntervalSlack
+}
+
+// ValidateMaintenanceSchedule checks a schedule's intervals and window hours.
+func ValidateMaintenanceSchedule(schedule *db.StorageMaintenanceSchedule) error {
+	if schedule.VacuumIntervalDays < 0 || schedule.VacuumIntervalDays > maxMaintenanceIntervalDays ||
+		schedule.IntegrityIntervalDays < 0 || schedule.IntegrityIntervalDays > maxMaintenanceIntervalDays {
+		return fmt.Errorf("%w: intervals must be between 0 and %d days", ErrInvalidMaintenanceSchedule, maxMaintenanceIntervalDays)
+	}
+	if schedule.WindowStartHour < 0 || schedule.WindowStartHour > 23 ||
+		schedule.WindowEndHour < 0 || schedule.WindowEndHour > 23 {
+		return fmt.Errorf("%w: window hours must be between 0 and 23", ErrInvalidMaintenanceSchedule)
+	}
+	return nil
+}
+
+// Vacuum runs VACUUM on both databases as a job run and returns the space
+// reclaimed.
+func (s *StorageMaintenanceService) Vacuum(ctx context.Context, run *JobRun, trigger string) (map[string]interface{}, error) {
+	startTime := time.Now()
+
+	// Get sizes before vacuum
+	relayDBSizeBefore, _ := s.db.GetRelayDatabaseSize()
+	appDBSizeBefore, _ := s.db.GetAppDatabaseSize()
+
+	if run != nil {
+		run.Progress(0, 2, "Vacuuming app database")
+	}
+	dbStart := time.Now()
+	err := s.db.RunAppVacuum(ctx)
+	appDBSizeAfter, _ := s.db.GetAppDatabaseSize()
+	s.record(ctx, JobTypeVacuum, "app", trigger, dbStart, err == nil, errorText(err), appDBSizeBefore-appDBSizeAfter)
+	if err != nil {
+		return nil, s.failed(JobTypeVacuum, "app", trigger, fmt.Errorf("failed to vacuum app database: %w", err))
+	}
+
+	if run != nil {
+		run.Progress(1, 2, "Vacuuming relay database")
+	}
+	dbStart = time.Now()
+	writer, err := s.db.NewRelayWriter()
+	if err != nil {
+		err = fmt.Errorf("failed to open relay database for vacuum: %w", err)
+	} else {
+		defer writer.Close()
+		if err = writer.RunVacuum(ctx); err != nil {
+			err = fmt.Errorf("failed to vacuum relay database: %w", err)
+		}
+	}
+	relayDBSizeAfter, _ := s.db.GetRelayDatabaseSize()
+	s.record(ctx, JobTypeVacuum, "relay", trigger, dbStart, err == nil, errorText(err), relayDBSizeBefore-relayDBSizeAfter)
+	if err != nil {
+		return nil, s.failed(JobTypeVacuum, "relay", trigger, err)
+	}
+	if run != nil {
+		run.Progress(2, 2, "")
+	}
+
+	spaceReclaimed := (relayDBSizeBefore - relayDBSizeAfter) + (appDBSizeBefore - appDBSizeAfter)
+	duration := time.Since(startTime)
+
+	// Update last vacuum timestamp
+	s.db.SetLastVacuumRun(ctx, time.Now())
+
+	s.db.AddAuditLog(ctx, "vacuum_run", map[string]interface{}{
+		"space_reclaimed": spaceReclaimed,
+		"duration_ms":     duration.Milliseconds(),
+		"trigger":         trigger,
+	}, "")
+
+	return map[string]interface{}{
+		"space_reclaimed": spaceReclaimed,
+		"duration_ms":     duration.Milliseconds(),
+	}, nil
+}
+
+// IntegrityCheck runs PRAGMA integrity_check on both databases as a job run.
+// A database that fails the check is reported in the result, not as an
+// error.
+func (s *StorageMaintenanceService) IntegrityCheck(ctx context.Context, run *JobRun, trigger string) (map[string]interface{}, error) {
+	startTime := time.Now()
+
+	if run != nil {
+		run.Progress(0, 2, "Checking app database")
+	}
+	dbStart := time.Now()
+	appOK, appResult, err := s.db.RunAppIntegrityCheck(ctx)
+	if err != nil {
+		s.record(ctx, JobTypeIntegrityCheck, "app", trigger, dbStart, false, err.Error(), 0)
+		return nil, s.failed(JobTypeIntegrityCheck, "app", trigger, fmt.Errorf("failed to check app database integrity: %w", err))
+	}
+	s.record(ctx, JobTypeIntegrityCheck, "app", trigger, dbStart, appOK, appResult, 0)
+
+	if run != nil {
+		run.Progress(1, 2, "Checking relay database")
+	}
+	var relayOK bool
+	var relayResult string
+
+	dbStart = time.Now()
+	writer, err := s.db.NewRelayWriter()
+	if err != nil {
+		relayOK = false
+		relayResult = "Failed to open database"
+	} else {
+		defer writer.Close()
+		relayOK, relayResult, err = writer.RunIntegrityCheck(ctx)
+		if err != nil {
+			relayOK = false
+			relayResult = err.Error()
+		}
+	}
+	s.record(ctx, JobTypeIntegrityCheck, "relay", trigger, dbStart, relayOK, relayResult, 0)
+	if run != nil {
+		run.Progress(2, 2, "")
+	}
+
+	duration := time.Since(startTime)
+
+	// Update last integrity check timestamp
+	s.db.SetLastIntegrityCheck(ctx, time.Now())
+
+	s.db.AddAuditLog(ctx, "integrity_check", map[string]interface{}{
+		"app_ok":      appOK,
+		"relay_ok":    relayOK,
+		"duration_ms": duration.Milliseconds(),
+		"trigger":     trigger,
+	}, "")
+
+	if !appOK {
+		s.failed(JobTypeIntegrityCheck, "app", trigger, errors.New(appResult))
+	}
+	if !relayOK {
+		s.failed(JobTypeIntegrityCheck, "relay", trigger, errors.New(relayResult))
+	}
+
+	return map[string]interface{}{
+		"success":     appOK && relayOK,
+		"app_db":      map[string]interface{}{"ok": appOK, "result": appResult},
+		"relay_db":    map[string]interface{}{"ok": relayOK, "result": relayResult},
+		"duration_ms": duration.Milliseconds(),
+	}, nil
+}
+
+// History returns maintenance runs, newest first, optionally of one task.
+func (s *StorageMaintenanceService) History(ctx context.Context, task string, limit, offset int) ([]db.MaintenanceRun, int64, error) {
+	return s.db.GetMaintenanceHistory(ctx, task, limit, offset)
+}
+
+// record saves one database's result to the maintenance history.
+func (s *StorageMaintenanceService) record(ctx context.Context, task, database, trigger string, started time.Time, ok bool, result string, reclaimed int64) {
+	status := "ok"
+	if !ok {
+		status = "failed"
+	}
+	if reclaimed < 0 {
+		reclaimed = 0
+	}
+	completed := time.Now()
+	run := &db.MaintenanceRun{
+		Task:           task,
+		Database:       database,
+		Trigger:        trigger,
+		Status:         status,
+		Result:         result,
+		SpaceReclaimed: reclaimed,
+		DurationMs:     completed.Sub(started).Milliseconds(),
+		StartedAt:      started,
+		CompletedAt:    completed,
+	}
+	// Record even when the job was cancelled
+	if err := s.db.AddMaintenanceRun(context.WithoutCancel(ctx), run); err != nil {
+		slog.Error("Failed to record maintenance run", "task", task, "database", database, "error", err)
+	}
+}
+
+// failed reports a failed task through webhooks and an operator alert and
+// returns err.
+func (s *StorageMaintenanceService) failed(task, database, trigger string, err error) error {
+	slog.Error("Storage maintenance failed", "task", task, "database", database, "trigger", trigger, "error", err)
+
+	s.webhooks.Emit(WebhookEventMaintenanceFailed, map[string]interface{}{
+		"task":     task,
+		"database": database,
+		"trigger":  trigger,
+		"error":    err.Error(),
+	})
+
+	if s.notifier != nil {
+		message := fmt.Sprintf("Roostr: %s of the %s database failed (%s): %v", task, database, trigger, err)
+		if notifyErr := s.notifier.NotifyOperator(context.Background(), "Roostr: "+task+" failed", message); notifyErr != nil {
+			slog.Warn("Failed to notify operator of maintenance failure", "error", notifyErr)
+		}
+	}
+	return err
+}
+
+// errorText returns err's message, or "" for nil.
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
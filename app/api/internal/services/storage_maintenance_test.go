@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestInMaintenanceWindow(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2026, 3, 1, hour, 30, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name       string
+		hour       int
+		start, end int
+		want       bool
+	}{
+		{"inside", 3, 3, 5, true},
+		{"at end", 5, 3, 5, false},
+		{"before", 2, 3, 5, false},
+		{"wraps late", 23, 22, 2, true},
+		{"wraps early", 1, 22, 2, true},
+		{"outside wrap", 12, 22, 2, false},
+		{"all day", 12, 4, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inMaintenanceWindow(at(tt.hour), tt.start, tt.end); got != tt.want {
+				t.Errorf("inMaintenanceWindow(%d, %d, %d) = %v, want %v", tt.hour, tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaintenanceDue(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+
+	if !maintenanceDue(nil, 7, now) {
+		t.Error("expected a task that never ran to be due")
+	}
+	if maintenanceDue(nil, 0, now) {
+		t.Error("expected an interval of 0 to turn the task off")
+	}
+	if maintenanceDue(ago(3*24*time.Hour), 7, now) {
+		t.Error("expected a task run 3 days ago not to be due weekly")
+	}
+	if !maintenanceDue(ago(7*24*time.Hour-10*time.Minute), 7, now) {
+		t.Error("expected a task run a week ago, give or take minutes, to be due")
+	}
+}
+
+func TestValidateMaintenanceSchedule(t *testing.T) {
+	valid := db.DefaultStorageMaintenanceSchedule
+	if err := ValidateMaintenanceSchedule(&valid); err != nil {
+		t.Errorf("expected the default schedule to be valid, got %v", err)
+	}
+
+	for _, schedule := range []db.StorageMaintenanceSchedule{
+		{VacuumIntervalDays: -1},
+		{IntegrityIntervalDays: 400},
+		{WindowStartHour: 24},
+		{WindowEndHour: -1},
+	} {
+		if err := ValidateMaintenanceSchedule(&schedule); !errors.Is(err, ErrInvalidMaintenanceSchedule) {
+			t.Errorf("expected ErrInvalidMaintenanceSchedule for %+v, got %v", schedule, err)
+		}
+	}
+}
+
+func TestStorageMaintenanceRecordsHistory(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	ctx := context.Background()
+	svc := NewStorageMaintenanceService(database, nil)
+
+	if _, err := svc.Vacuum(ctx, nil, MaintenanceTriggerManual); err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	result, err := svc.IntegrityCheck(ctx, nil, MaintenanceTriggerManual)
+	if err != nil {
+		t.Fatalf("IntegrityCheck failed: %v", err)
+	}
+	if result["success"] != true {
+		t.Errorf("expected both databases to pass, got %+v", result)
+	}
+
+	runs, total, err := svc.History(ctx, "", 10, 0)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if total != 4 {
+		t.Fatalf("expected a run per task and database, got %d", total)
+	}
+	for _, run := range runs {
+		if run.Status != "ok" || run.Trigger != MaintenanceTriggerManual {
+			t.Errorf("unexpected run %+v", run)
+		}
+	}
+	if last, _ := database.GetLastVacuumRun(ctx); last == nil {
+		t.Error("expected the last vacuum time to be saved")
+	}
+}
+
+func TestStorageMaintenanceFailure(t *testing.T) {
+	// No relay database, so the relay step fails
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	wh := &db.Webhook{URL: "http://127.0.0.1:1", Secret: "x", Events: []string{WebhookEventMaintenanceFailed}, Enabled: true}
+	if err := database.CreateWebhook(ctx, wh); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	svc := NewStorageMaintenanceService(database, nil)
+	svc.webhooks = NewWebhookService(database)
+
+	if _, err := svc.Vacuum(ctx, nil, MaintenanceTriggerScheduled); err == nil {
+		t.Fatal("expected the relay vacuum to fail")
+	}
+
+	runs, _, err := svc.History(ctx, JobTypeVacuum, 10, 0)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Database != "relay" || runs[0].Status != "failed" || runs[0].Result == "" {
+		t.Fatalf("expected a failed relay run after the app run, got %+v", runs)
+	}
+	if runs[1].Database != "app" || runs[1].Status != "ok" {
+		t.Errorf("expected the app vacuum to succeed, got %+v", runs[1])
+	}
+
+	deliveries, _ := database.GetWebhookDeliveries(ctx, wh.ID, 10)
+	if len(deliveries) != 1 || deliveries[0].Event != WebhookEventMaintenanceFailed {
+		t.Errorf("expected one maintenance.failed delivery, got %+v", deliveries)
+	}
+}
+
+func TestStorageMaintenanceRunDue(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	svc := NewStorageMaintenanceService(database, nil)
+	svc.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local) }
+
+	// Outside the default 03:00-05:00 window nothing runs
+	svc.runDue()
+	if _, total, _ := svc.jobs.List(ctx, db.JobFilter{Limit: 10}); total != 0 {
+		t.Fatalf("expected no jobs outside the window, got %d", total)
+	}
+
+	schedule := db.DefaultStorageMaintenanceSchedule
+	schedule.WindowStartHour, schedule.WindowEndHour = 11, 13
+	schedule.IntegrityIntervalDays = 0
+	database.SetStorageMaintenanceSchedule(ctx, &schedule)
+
+	svc.runDue()
+	jobs, total, err := svc.jobs.List(ctx, db.JobFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if total != 1 || jobs[0].Type != JobTypeVacuum {
+		t.Fatalf("expected one vacuum job, got %+v", jobs)
+	}
+	if job := waitJob(t, svc.jobs, jobs[0].ID); job.Status != db.JobStatusCompleted {
+		t.Fatalf("expected the vacuum to complete, got %s: %s", job.Status, job.Error)
+	}
+
+	runs, _, _ := svc.History(ctx, "", 10, 0)
+	if len(runs) != 2 || runs[0].Trigger != MaintenanceTriggerScheduled {
+		t.Errorf("expected two scheduled vacuum runs, got %+v", runs)
+	}
+
+	// Vacuumed just now, so not due again
+	svc.runDue()
+	if _, total, _ := svc.jobs.List(ctx, db.JobFilter{Limit: 10}); total != 1 {
+		t.Errorf("expected no new job, got %d jobs", total)
+	}
+}
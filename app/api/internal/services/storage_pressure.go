@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Storage levels reported by GET /storage/status, by disk usage.
+const (
+	StorageLevelHealthy  = "healthy"
+	StorageLevelWarning  = "warning"  // 80%
+	StorageLevelLow      = "low"      // 90%
+	StorageLevelCritical = "critical" // 95%
+)
+
+const (
+	// storagePressureRetentionInterval is how often tightened retention
+	// runs again while usage stays above its threshold.
+	storagePressureRetentionInterval = 6 * time.Hour
+
+	// storagePressureHysteresis is how far below the pause threshold usage
+	// must fall before syncs and imports are allowed again, so hovering at
+	// the threshold does not pause and resume every check.
+	storagePressureHysteresis = 2.0
+)
+
+// ErrStoragePressure is returned when starting a sync or import while the
+// storage pressure policy has paused them.
+var ErrStoragePressure = errors.New("disk is nearly full; syncs and imports are paused")
+
+// StorageLevel returns the storage level for a disk usage percentage.
+func StorageLevel(usagePercent float64) string {
+	switch {
+	case usagePercent >= 95:
+		return StorageLevelCritical
+	case usagePercent >= 90:
+		return StorageLevelLow
+	case usagePercent >= 80:
+		return StorageLevelWarning
+	}
+	return StorageLevelHealthy
+}
+
+// StoragePressureState is what the storage pressure policy has done.
+type StoragePressureState struct {
+	UsagePercent         float64    `json:"usage_percent"`
+	Level                string     `json:"level"`
+	IngestPaused         bool       `json:"ingest_paused"`
+	PausedSyncJob        int64      `json:"paused_sync_job,omitempty"` // sync paused by the policy, resumed with ingest
+	LastRetention        *time.Time `json:"last_retention,omitempty"`
+	LastRetentionDeleted int64      `json:"last_retention_deleted"`
+	CheckedAt            *time.Time `json:"checked_at,omitempty"`
+}
+
+// StoragePressureService acts on disk usage instead of only reporting it.
+// Following the operator's StoragePressurePolicy, it runs retention with a
+// tighter window at one threshold and pauses syncs and rejects imports at
+// another, messaging the operator about each action.
+type StoragePressureService struct {
+	db        *db.DB
+	retention *RetentionService
+	sync      *SyncService
+	notifier  *Notifier
+	interval  time.Duration
+	now       func() time.Time
+	usage     func() (float64, error)
+	state     StoragePressureState
+	stateMu   sync.RWMutex
+	checkMu   sync.Mutex // one check at a time
+	wakeCh    chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	running   bool
+	mu        sync.Mutex
+}
+
+// NewStoragePressureService creates a new storage pressure service. notifier
+// may be nil to skip operator DMs.
+func NewStoragePressureService(database *db.DB, retention *RetentionService, syncService *SyncService, notifier *Notifier) *StoragePressureService {
+	return &StoragePressureService{
+		db:        database,
+		retention: retention,
+		sync:      syncService,
+		notifier:  notifier,
+		interval:  5 * time.Minute,
+		now:       time.Now,
+		usage: func() (float64, error) {
+			return diskUsagePercent(database)
+		},
+		state:  StoragePressureState{Level: StorageLevelHealthy},
+		wakeCh: make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// diskUsagePercent returns how full the disk holding the databases is.
+func diskUsagePercent(database *db.DB) (float64, error) {
+	total, err := database.GetTotalDiskSpace()
+	if err != nil {
+		return 0, err
+	}
+	if total <= 0 {
+		return 0, errors.New("disk size unknown")
+	}
+	available, err := database.GetAvailableDiskSpace()
+	if err != nil {
+		return 0, err
+	}
+	return float64(total-available) / float64(total) * 100, nil
+}
+
+// Start begins the background usage checks.
+func (s *StoragePressureService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the usage checks.
+func (s *StoragePressureService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Wake checks usage now, e.g. after the policy changes.
+func (s *StoragePressureService) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *StoragePressureService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.Check(ctx)
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.Check(ctx)
+		case <-s.wakeCh:
+			s.Check(ctx)
+		}
+	}
+}
+
+// State returns what the policy has done so far.
+func (s *StoragePressureService) State() StoragePressureState {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.state
+}
+
+// IngestPaused reports whether syncs and imports are paused. A nil service
+// never pauses them.
+func (s *StoragePressureService) IngestPaused() bool {
+	if s == nil {
+		return false
+	}
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.state.IngestPaused
+}
+
+// Check measures disk usage and applies the policy: pausing ingest at the
+// pause threshold and lifting the pause once usage falls back, and running
+// tightened retention at the retention threshold, at most every
+// storagePressureRetentionInterval.
+func (s *StoragePressureService) Check(ctx context.Context) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	policy, err := s.db.GetStoragePressurePolicy(ctx)
+	if err != nil {
+		slog.Error("Failed to get storage pressure policy", "error", err)
+		return
+	}
+	usage, err := s.usage()
+	if err != nil {
+		slog.Debug("Failed to measure disk usage", "error", err)
+		return
+	}
+
+	now := s.now()
+	s.stateMu.Lock()
+	s.state.UsagePercent = usage
+	s.state.Level = StorageLevel(usage)
+	s.state.CheckedAt = &now
+	paused := s.state.IngestPaused
+	lastRetention := s.state.LastRetention
+	s.stateMu.Unlock()
+
+	pause := policy.Enabled && policy.PausePercent > 0 && usage >= policy.PausePercent
+	resume := !policy.Enabled || policy.PausePercent <= 0 || usage < policy.PausePercent-storagePressureHysteresis
+	switch {
+	case pause && !paused:
+		s.pauseIngest(ctx, usage)
+	case resume && paused:
+		s.resumeIngest(ctx, usage)
+	}
+
+	if policy.Enabled && policy.RetentionPercent > 0 && policy.RetentionDays > 0 && usage >= policy.RetentionPercent &&
+		(lastRetention == nil || now.Sub(*lastRetention) >= storagePressureRetentionInterval) {
+		s.runRetention(ctx, usage, policy.RetentionDays)
+	}
+}
+
+// pauseIngest rejects new syncs and imports and pauses the running sync.
+func (s *StoragePressureService) pauseIngest(ctx context.Context, usage float64) {
+	s.stateMu.Lock()
+	s.state.IngestPaused = true
+	s.stateMu.Unlock()
+
+	var jobID int64
+	if s.sync != nil && s.sync.IsRunning() {
+		jobID = s.sync.GetCurrentJobID()
+		if err := s.sync.PauseSync(jobID); err != nil {
+			jobID = 0
+		}
+	}
+	s.stateMu.Lock()
+	s.state.PausedSyncJob = jobID
+	s.stateMu.Unlock()
+
+	slog.Warn("Disk nearly full; pausing syncs and imports", "usage_percent", usage, "sync_job", jobID)
+	s.db.AddAuditLog(ctx, "storage_pressure_paused", map[string]interface{}{
+		"usage_percent": usage,
+		"sync_job_id":   jobID,
+	}, "")
+	s.notify(ctx, fmt.Sprintf("Roostr: disk is %.1f%% full. Syncs and imports are paused until space is freed.", usage))
+}
+
+// resumeIngest lifts the pause and resumes the sync it paused.
+func (s *StoragePressureService) resumeIngest(ctx context.Context, usage float64) {
+	s.stateMu.Lock()
+	s.state.IngestPaused = false
+	jobID := s.state.PausedSyncJob
+	s.state.PausedSyncJob = 0
+	s.stateMu.Unlock()
+
+	if jobID != 0 && s.sync != nil {
+		if _, err := s.sync.ResumeSync(ctx, jobID); err != nil {
+			slog.Warn("Failed to resume sync paused for storage pressure", "job_id", jobID, "error", err)
+		}
+	}
+
+	slog.Info("Disk usage back down; syncs and imports allowed", "usage_percent", usage)
+	s.db.AddAuditLog(ctx, "storage_pressure_resumed", map[string]interface{}{
+		"usage_percent": usage,
+		"sync_job_id":   jobID,
+	}, "")
+	s.notify(ctx, fmt.Sprintf("Roostr: disk is %.1f%% full. Syncs and imports are allowed again.", usage))
+}
+
+// runRetention deletes events older than days.
+func (s *StoragePressureService) runRetention(ctx context.Context, usage float64, days int64) {
+	if s.retention == nil || !s.db.IsRelayDBConnected() {
+		return
+	}
+
+	now := s.now()
+	s.stateMu.Lock()
+	s.state.LastRetention = &now
+	s.stateMu.Unlock()
+
+	result, err := s.retention.RunTightened(ctx, days)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("Storage pressure retention failed", "error", err)
+		s.notify(ctx, fmt.Sprintf("Roostr: disk is %.1f%% full and retention with a %d-day window failed: %v", usage, days, err))
+		return
+	}
+
+	s.stateMu.Lock()
+	s.state.LastRetentionDeleted = result.EventsDeleted
+	s.stateMu.Unlock()
+
+	slog.Warn("Disk nearly full; ran tightened retention", "usage_percent", usage, "retention_days", days, "deleted", result.EventsDeleted)
+	s.notify(ctx, fmt.Sprintf("Roostr: disk is %.1f%% full. Deleted %d events older than %d days; run a vacuum to reclaim the space.",
+		usage, result.EventsDeleted, days))
+}
+
+// notify alerts the operator, logging failures.
+func (s *StoragePressureService) notify(ctx context.Context, message string) {
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.NotifyOperator(ctx, "Roostr: disk nearly full", message); err != nil {
+		slog.Warn("Failed to notify operator of storage pressure", "error", err)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestStorageLevel(t *testing.T) {
+	tests := map[float64]string{0: "healthy", 79.9: "healthy", 80: "warning", 90: "low", 94.9: "low", 95: "critical", 100: "critical"}
+	for usage, want := range tests {
+		if got := StorageLevel(usage); got != want {
+			t.Errorf("StorageLevel(%v) = %q, want %q", usage, got, want)
+		}
+	}
+}
+
+func TestStoragePressureService(t *testing.T) {
+	database, relayDB := setupTestDBWithRelay(t)
+	ctx := context.Background()
+
+	author := strings.Repeat("aa", 32)
+	n := byte(0)
+	event := func(kind int, daysAgo int) {
+		n++
+		insertCoverageTestEvent(t, relayDB, &nostr.SyncEvent{ID: strings.Repeat(hex.EncodeToString([]byte{n}), 32), Pubkey: author, Kind: kind,
+			CreatedAt: time.Now().AddDate(0, 0, -daysAgo).Unix()})
+	}
+	count := func() int {
+		var c int
+		relayDB.QueryRow("SELECT COUNT(*) FROM event").Scan(&c)
+		return c
+	}
+	event(1, 100)
+	event(0, 100) // kept forever by its kind rule
+	event(1, 5)
+	database.SetRetentionPolicy(ctx, &db.RetentionPolicy{RetentionDays: 365, KindRules: []db.RetentionRule{{Kind: 0}}})
+
+	syncService := NewSyncService(database)
+	pressure := NewStoragePressureService(database, NewRetentionService(database, nil), syncService, nil)
+	syncService.pressure = pressure
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	usage := 97.0
+	pressure.now = func() time.Time { return now }
+	pressure.usage = func() (float64, error) { return usage, nil }
+
+	// Nothing happens until the policy is enabled
+	pressure.Check(ctx)
+	if state := pressure.State(); state.IngestPaused || state.LastRetention != nil || state.Level != StorageLevelCritical {
+		t.Fatalf("expected no action with the policy off, got %+v", state)
+	}
+
+	policy := db.DefaultStoragePressurePolicy()
+	policy.Enabled = true
+	database.SetStoragePressurePolicy(ctx, &policy)
+
+	usage = 92
+	pressure.Check(ctx)
+	if state := pressure.State(); state.IngestPaused || state.LastRetentionDeleted != 1 {
+		t.Errorf("expected tightened retention only, got %+v", state)
+	}
+	if c := count(); c != 2 {
+		t.Errorf("expected the 100-day-old note deleted, %d events left", c)
+	}
+
+	// Retention waits for its interval; ingest pauses at the pause threshold
+	event(1, 200)
+	usage = 96
+	now = now.Add(time.Hour)
+	pressure.Check(ctx)
+	if !pressure.IngestPaused() || count() != 3 {
+		t.Errorf("expected ingest paused without another retention run, got %+v with %d events", pressure.State(), count())
+	}
+	if _, err := syncService.StartSync(ctx, SyncRequest{Pubkeys: []string{author}}); !errors.Is(err, ErrStoragePressure) {
+		t.Errorf("expected ErrStoragePressure starting a sync, got %v", err)
+	}
+
+	// The pause lifts once usage falls clearly below the threshold
+	usage = 94
+	pressure.Check(ctx)
+	if !pressure.IngestPaused() {
+		t.Error("expected ingest to stay paused just below the threshold")
+	}
+	usage = 90
+	now = now.Add(storagePressureRetentionInterval)
+	pressure.Check(ctx)
+	if pressure.IngestPaused() || count() != 2 {
+		t.Errorf("expected ingest allowed and retention run again, got %+v with %d events", pressure.State(), count())
+	}
+}
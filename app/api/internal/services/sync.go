@@ -2,8 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -21,18 +22,87 @@ var DefaultSyncRelays = []string{
 	"wss://relay.snort.social",
 }
 
+// syncPageSize is how many events a sync requests per page. Each relay and
+// pubkey pair is paged back in time until the relay has nothing older.
+const syncPageSize = 500
+
+var (
+	// ErrSyncAlreadyRunning is returned when starting a sync while one runs.
+	ErrSyncAlreadyRunning = errors.New("a sync job is already running")
+
+	// ErrSyncNotRunning is returned when pausing a sync that is not running.
+	ErrSyncNotRunning = errors.New("sync job is not running")
+
+	// ErrSyncNotPaused is returned when resuming a sync that is not paused.
+	ErrSyncNotPaused = errors.New("sync job is not paused")
+)
+
+// SyncRelayURLs returns the configured sync relays, or the defaults if none.
+func SyncRelayURLs(ctx context.Context, database *db.DB) []string {
+	syncRelays, _ := database.GetSyncRelays(ctx)
+	relays := make([]string, 0, len(syncRelays))
+	for _, r := range syncRelays {
+		relays = append(relays, r.URL)
+	}
+	if len(relays) == 0 {
+		return DefaultSyncRelays
+	}
+	return relays
+}
+
 // SyncService handles syncing events from public relays.
+// Each sync runs as a job of type "sync"; its sync_jobs record keeps the
+// event counts shown in the sync history. A sync that is paused, or still
+// running when the server stops, keeps a cursor per relay and pubkey and
+// can be resumed where it left off.
 type SyncService struct {
 	db       *db.DB
+	hardware *HardwareService
+	jobs     *JobService
+	webhooks *WebhookService
+	pressure *StoragePressureService
 	mu       sync.Mutex
-	cancelFn context.CancelFunc
-	jobID    int64
+	jobID    int64         // sync_jobs ID of the running sync
+	runID    int64         // jobs ID of the running sync
+	progress *syncProgress // progress of the running or last sync
 	running  bool
+	pausing  bool // the running sync is stopping to be resumed later
 }
 
 // NewSyncService creates a new sync service.
 func NewSyncService(database *db.DB) *SyncService {
-	return &SyncService{db: database}
+	return &SyncService{db: database, jobs: NewJobService(database)}
+}
+
+// Relay connection states reported in sync progress.
+const (
+	SyncRelayPending    = "pending"
+	SyncRelayConnecting = "connecting"
+	SyncRelaySyncing    = "syncing"
+	SyncRelayDone       = "done"
+	SyncRelayFailed     = "failed"
+)
+
+// SyncRelayStatus is one relay's state in a sync.
+type SyncRelayStatus struct {
+	URL           string `json:"url"`
+	Status        string `json:"status"`
+	PubkeysDone   int    `json:"pubkeys_done"`
+	EventsFetched int64  `json:"events_fetched"`
+	Error         string `json:"error,omitempty"`
+}
+
+// SyncProgress is a snapshot of a sync's event counts and relay states.
+type SyncProgress struct {
+	JobID          int64             `json:"job_id"`
+	Status         string            `json:"status"`
+	EventsFetched  int64             `json:"events_fetched"`
+	EventsStored   int64             `json:"events_stored"`
+	EventsSkipped  int64             `json:"events_skipped"`
+	EventsRejected int64             `json:"events_rejected"`
+	EventsReplaced int64             `json:"events_replaced"`
+	Relays         []SyncRelayStatus `json:"relays"`
+	ErrorMessage   string            `json:"error_message,omitempty"`
 }
 
 // SyncRequest contains parameters for starting a sync job.
@@ -41,21 +111,49 @@ type SyncRequest struct {
 	Relays         []string `json:"relays"`
 	EventKinds     []int    `json:"event_kinds,omitempty"`
 	SinceTimestamp *int64   `json:"since_timestamp,omitempty"`
+
+	// SkipVerification stores events without checking their IDs and
+	// signatures, for relays the operator trusts.
+	SkipVerification bool `json:"skip_verification,omitempty"`
 }
 
 // StartSync begins a new sync job.
 // Returns the job ID and an error if a sync is already in progress.
 func (s *SyncService) StartSync(ctx context.Context, req SyncRequest) (int64, error) {
+	jobID, _, err := s.start(ctx, req)
+	return jobID, err
+}
+
+// StartSyncJob begins a new sync job and returns it as a generic job.
+func (s *SyncService) StartSyncJob(ctx context.Context, req SyncRequest) (*db.Job, error) {
+	_, job, err := s.start(ctx, req)
+	return job, err
+}
+
+// Start marks syncs left running by a previous process as paused, so they
+// can be resumed.
+func (s *SyncService) Start() {
+	if n, err := s.db.PauseInterruptedSyncJobs(context.Background()); err != nil {
+		slog.Error("Failed to mark interrupted syncs", "error", err)
+	} else if n > 0 {
+		slog.Warn("Marked interrupted syncs as paused", "count", n)
+	}
+}
+
+func (s *SyncService) start(ctx context.Context, req SyncRequest) (int64, *db.Job, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.running {
-		return 0, fmt.Errorf("a sync job is already running")
+		return 0, nil, ErrSyncAlreadyRunning
+	}
+	if s.pressure.IngestPaused() {
+		return 0, nil, ErrStoragePressure
 	}
 
 	// Validate request
 	if len(req.Pubkeys) == 0 {
-		return 0, fmt.Errorf("at least one pubkey is required")
+		return 0, nil, fmt.Errorf("at least one pubkey is required")
 	}
 	if len(req.Relays) == 0 {
 		req.Relays = DefaultSyncRelays
@@ -63,9 +161,10 @@ func (s *SyncService) StartSync(ctx context.Context, req SyncRequest) (int64, er
 
 	// Create job record
 	job := db.SyncJob{
-		Pubkeys:    req.Pubkeys,
-		Relays:     req.Relays,
-		EventKinds: req.EventKinds,
+		Pubkeys:          req.Pubkeys,
+		Relays:           req.Relays,
+		EventKinds:       req.EventKinds,
+		SkipVerification: req.SkipVerification,
 	}
 	if req.SinceTimestamp != nil {
 		t := time.Unix(*req.SinceTimestamp, 0)
@@ -74,162 +173,510 @@ func (s *SyncService) StartSync(ctx context.Context, req SyncRequest) (int64, er
 
 	jobID, err := s.db.CreateSyncJob(ctx, job)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create sync job: %w", err)
+		return 0, nil, fmt.Errorf("failed to create sync job: %w", err)
+	}
+
+	// Start background sync
+	syncRun, err := s.launch(jobID, req, newSyncProgress(jobID, req))
+	if err != nil {
+		s.db.CompleteSyncJob(ctx, jobID, "failed", err.Error())
+		return 0, nil, err
+	}
+
+	return jobID, syncRun, nil
+}
+
+// ResumeSync resumes a paused sync where it left off, as a new job run.
+// It returns nil if the sync does not exist.
+func (s *SyncService) ResumeSync(ctx context.Context, jobID int64) (*db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.db.GetSyncJob(ctx, jobID)
+	if err != nil || job == nil {
+		return nil, err
+	}
+	if job.Status != "paused" {
+		return nil, ErrSyncNotPaused
+	}
+	if s.running {
+		return nil, ErrSyncAlreadyRunning
+	}
+	if s.pressure.IngestPaused() {
+		return nil, ErrStoragePressure
+	}
+
+	cursors, err := s.db.GetSyncCursors(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync cursors: %w", err)
+	}
+
+	req := SyncRequest{
+		Pubkeys:          job.Pubkeys,
+		Relays:           job.Relays,
+		EventKinds:       job.EventKinds,
+		SkipVerification: job.SkipVerification,
+	}
+	if job.SinceTimestamp != nil {
+		since := job.SinceTimestamp.Unix()
+		req.SinceTimestamp = &since
+	}
+	progress := newSyncProgress(jobID, req)
+	progress.resume(job, cursors)
+
+	if err := s.db.ResumeSyncJob(ctx, jobID); err != nil {
+		return nil, fmt.Errorf("failed to resume sync job: %w", err)
+	}
+	syncRun, err := s.launch(jobID, req, progress)
+	if err != nil {
+		s.db.PauseSyncJob(ctx, jobID, err.Error())
+		return nil, err
+	}
+
+	slog.Info("Sync job resumed", "job_id", jobID, "run_id", syncRun.ID)
+	return syncRun, nil
+}
+
+// PauseSync stops the running sync so it can be resumed later.
+func (s *SyncService) PauseSync(jobID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running || s.jobID != jobID {
+		return ErrSyncNotRunning
+	}
+	s.pausing = true
+	return s.jobs.Cancel(s.runID)
+}
+
+// launch runs a sync as a job. The caller must hold s.mu.
+func (s *SyncService) launch(jobID int64, req SyncRequest, progress *syncProgress) (*db.Job, error) {
+	params := map[string]interface{}{"sync_job_id": jobID, "request": req}
+	syncRun, err := s.jobs.Run(JobTypeSync, params, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return s.runSync(ctx, jobID, req, run, progress)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Create cancellable context for the job
-	jobCtx, cancel := context.WithCancel(context.Background())
-	s.cancelFn = cancel
 	s.jobID = jobID
+	s.runID = syncRun.ID
+	s.progress = progress
 	s.running = true
+	s.pausing = false
 
-	// Start background sync
-	go s.runSync(jobCtx, jobID, req)
-
-	return jobID, nil
+	return syncRun, nil
 }
 
-// runSync is the background goroutine that performs the actual sync.
-func (s *SyncService) runSync(ctx context.Context, jobID int64, req SyncRequest) {
+// runSync performs the sync as the job run. It returns the event counts and
+// an error if the sync failed. Up to the tuning's sync concurrency relays
+// are fetched from at once. A sync stopped by PauseSync or by the server
+// shutting down is saved as paused instead of cancelled.
+func (s *SyncService) runSync(ctx context.Context, jobID int64, req SyncRequest, run *JobRun, progress *syncProgress) (map[string]interface{}, error) {
 	defer func() {
 		s.mu.Lock()
 		s.running = false
-		s.cancelFn = nil
+		s.pausing = false
 		s.jobID = 0
+		s.runID = 0
 		s.mu.Unlock()
 	}()
 
-	var totalFetched, totalStored, totalSkipped int64
-	var lastError string
-	finalStatus := "completed"
-
 	// Open relay writer for insertions
 	writer, err := s.db.NewRelayWriter()
 	if err != nil {
-		log.Printf("Sync job %d: failed to open relay writer: %v", jobID, err)
-		s.db.CompleteSyncJob(ctx, jobID, "failed", fmt.Sprintf("failed to open relay writer: %v", err))
-		return
+		slog.Error("Sync job: failed to open relay writer", "job_id", jobID, "error", err)
+		err = fmt.Errorf("failed to open relay writer: %w", err)
+		progress.finish("failed", err.Error())
+		s.db.CompleteSyncJob(context.Background(), jobID, "failed", err.Error())
+		return nil, err
 	}
 	defer writer.Close()
 
-	// Progress update helper
+	// Progress is counted in relay/pubkey pairs
 	updateProgress := func() {
-		s.db.UpdateSyncJobProgress(ctx, jobID, totalFetched, totalStored, totalSkipped)
+		fetched, stored, skipped, rejected, step, _ := progress.snapshot()
+		s.db.UpdateSyncJobProgress(context.Background(), jobID, fetched, stored, skipped, rejected)
+		run.Progress(step, progress.steps, fmt.Sprintf("%d events fetched, %d stored", fetched, stored))
 	}
 
+	concurrency := s.hardware.Tuning().SyncConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
 	// For each relay
+relays:
 	for _, relayURL := range req.Relays {
-		// Check cancellation
 		select {
 		case <-ctx.Done():
-			finalStatus = "cancelled"
-			goto done
-		default:
+			break relays
+		case sem <- struct{}{}:
 		}
 
-		log.Printf("Sync job %d: connecting to %s", jobID, relayURL)
+		wg.Add(1)
+		go func(relayURL string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.syncRelay(ctx, jobID, relayURL, req, writer, progress, updateProgress)
+		}(relayURL)
+	}
+	wg.Wait()
+
+	// Final progress update
+	updateProgress()
+
+	totalFetched, totalStored, totalSkipped, totalRejected, _, lastError := progress.snapshot()
+	totalReplaced := progress.Progress().EventsReplaced
+
+	result := map[string]interface{}{
+		"sync_job_id": jobID,
+		"fetched":     totalFetched,
+		"stored":      totalStored,
+		"skipped":     totalSkipped,
+		"rejected":    totalRejected,
+		"replaced":    totalReplaced,
+	}
+
+	// Complete the job
+	finalStatus := "completed"
+	if ctx.Err() != nil {
+		s.mu.Lock()
+		pausing := s.pausing
+		s.mu.Unlock()
+
+		finalStatus = "cancelled"
+		if pausing || errors.Is(context.Cause(ctx), ErrJobsStopping) {
+			finalStatus = "paused"
+		}
+	} else if lastError != "" && totalStored == 0 && totalFetched == 0 {
+		finalStatus = "failed"
+	}
+	progress.finish(finalStatus, lastError)
+
+	if finalStatus == "paused" {
+		s.db.PauseSyncJob(context.Background(), jobID, "")
+		slog.Info("Sync job paused", "job_id", jobID,
+			"fetched", totalFetched, "stored", totalStored, "skipped", totalSkipped, "rejected", totalRejected)
+		result["status"] = finalStatus
+		return result, nil
+	}
+
+	s.db.CompleteSyncJob(context.Background(), jobID, finalStatus, lastError)
+	if err := s.db.DeleteSyncCursors(context.Background(), jobID); err != nil {
+		slog.Warn("Failed to delete sync cursors", "job_id", jobID, "error", err)
+	}
+
+	slog.Info("Sync job finished", "job_id", jobID, "status", finalStatus,
+		"fetched", totalFetched, "stored", totalStored, "skipped", totalSkipped, "rejected", totalRejected)
+
+	s.webhooks.Emit(WebhookEventSyncCompleted, map[string]interface{}{
+		"job_id":   jobID,
+		"status":   finalStatus,
+		"fetched":  totalFetched,
+		"stored":   totalStored,
+		"skipped":  totalSkipped,
+		"rejected": totalRejected,
+		"replaced": totalReplaced,
+		"error":    lastError,
+	})
+
+	if finalStatus == "failed" {
+		return result, errors.New(lastError)
+	}
+	return result, nil
+}
+
+// syncProgress holds a sync's event counts and relay states, shared by the
+// relays being fetched from at once.
+type syncProgress struct {
+	mu        sync.Mutex
+	jobID     int64
+	status    string
+	steps     int64
+	step      int64
+	fetched   int64
+	stored    int64
+	skipped   int64
+	rejected  int64 // failed verification
+	replaced  int64 // older versions of replaceable events deleted
+	lastError string
+	relays    []*SyncRelayStatus
+	byURL     map[string]*SyncRelayStatus
+	cursors   map[[2]string]db.SyncCursor // by relay and pubkey
+}
 
-		// Connect to relay
-		client := nostr.NewClient(relayURL)
-		if err := client.Connect(ctx); err != nil {
-			log.Printf("Sync job %d: failed to connect to %s: %v", jobID, relayURL, err)
-			lastError = fmt.Sprintf("failed to connect to %s: %v", relayURL, err)
+func newSyncProgress(jobID int64, req SyncRequest) *syncProgress {
+	p := &syncProgress{
+		jobID:   jobID,
+		status:  "running",
+		steps:   int64(len(req.Relays) * len(req.Pubkeys)),
+		byURL:   make(map[string]*SyncRelayStatus, len(req.Relays)),
+		cursors: make(map[[2]string]db.SyncCursor),
+	}
+	for _, url := range req.Relays {
+		if _, ok := p.byURL[url]; ok {
 			continue
 		}
+		relay := &SyncRelayStatus{URL: url, Status: SyncRelayPending}
+		p.relays = append(p.relays, relay)
+		p.byURL[url] = relay
+	}
+	return p
+}
 
-		// For each pubkey
-		for _, pubkey := range req.Pubkeys {
-			// Check cancellation
-			select {
-			case <-ctx.Done():
-				client.Close()
-				finalStatus = "cancelled"
-				goto done
-			default:
-			}
+// resume carries over a paused sync's counts and cursors.
+func (p *syncProgress) resume(job *db.SyncJob, cursors []db.SyncCursor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fetched = job.EventsFetched
+	p.stored = job.EventsStored
+	p.skipped = job.EventsSkipped
+	p.rejected = job.EventsRejected
+	for _, c := range cursors {
+		p.cursors[[2]string{c.Relay, c.Pubkey}] = c
+		if !c.Done {
+			continue
+		}
+		p.step++
+		if relay := p.byURL[c.Relay]; relay != nil {
+			relay.PubkeysDone++
+		}
+	}
+}
 
-			log.Printf("Sync job %d: syncing pubkey %s from %s", jobID, pubkey[:16], relayURL)
+// cursor returns where a relay and pubkey pair left off.
+func (p *syncProgress) cursor(relay, pubkey string) db.SyncCursor {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.cursors[[2]string{relay, pubkey}]; ok {
+		return c
+	}
+	return db.SyncCursor{Relay: relay, Pubkey: pubkey}
+}
 
-			// Build filter
-			filter := nostr.Filter{
-				Authors: []string{pubkey},
-			}
-			if len(req.EventKinds) > 0 {
-				filter.Kinds = req.EventKinds
-			}
-			if req.SinceTimestamp != nil {
-				filter.Since = req.SinceTimestamp
-			}
+func (p *syncProgress) setCursor(c db.SyncCursor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cursors[[2]string{c.Relay, c.Pubkey}] = c
+}
 
-			// Subscribe and receive events
-			err := client.Subscribe(ctx, filter, func(event *nostr.SyncEvent) error {
-				totalFetched++
+func (p *syncProgress) snapshot() (fetched, stored, skipped, rejected, step int64, lastError string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.fetched, p.stored, p.skipped, p.rejected, p.step, p.lastError
+}
 
-				// Verify event signature
-				if err := event.Verify(); err != nil {
-					log.Printf("Sync job %d: skipping invalid event %s: %v", jobID, event.ID[:16], err)
-					totalSkipped++
-					return nil
-				}
+// setRelay changes a relay's state; a non-empty errMsg is kept as its error.
+func (p *syncProgress) setRelay(url, status, errMsg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if relay := p.byURL[url]; relay != nil {
+		relay.Status = status
+		if errMsg != "" {
+			relay.Error = errMsg
+		}
+	}
+}
 
-				// Convert to db.Event
-				dbEvent := &db.Event{
-					ID:        event.ID,
-					Pubkey:    event.Pubkey,
-					CreatedAt: time.Unix(event.CreatedAt, 0),
-					Kind:      event.Kind,
-					Tags:      event.Tags,
-					Content:   event.Content,
-					Sig:       event.Sig,
-				}
+// finish records the sync's final status. Relays that never finished are
+// left as they were when it stopped.
+func (p *syncProgress) finish(status, errMsg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = status
+	p.lastError = errMsg
+}
 
-				// Insert event
-				inserted, err := writer.InsertEvent(ctx, dbEvent)
-				if err != nil {
-					log.Printf("Sync job %d: failed to insert event %s: %v", jobID, event.ID[:16], err)
-					return nil // Continue despite errors
-				}
+// Progress returns the sync's counts and relay states.
+func (p *syncProgress) Progress() SyncProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-				if inserted {
-					totalStored++
-				} else {
-					totalSkipped++
-				}
+	relays := make([]SyncRelayStatus, len(p.relays))
+	for i, relay := range p.relays {
+		relays[i] = *relay
+	}
+	progress := SyncProgress{
+		JobID:          p.jobID,
+		Status:         p.status,
+		EventsFetched:  p.fetched,
+		EventsStored:   p.stored,
+		EventsSkipped:  p.skipped,
+		EventsRejected: p.rejected,
+		EventsReplaced: p.replaced,
+		Relays:         relays,
+	}
+	if p.status != "running" {
+		progress.ErrorMessage = p.lastError
+	}
+	return progress
+}
 
-				// Periodic progress update (every 100 events)
-				if totalFetched%100 == 0 {
-					updateProgress()
-				}
+// syncRelay fetches every requested pubkey's events from one relay.
+func (s *SyncService) syncRelay(ctx context.Context, jobID int64, relayURL string, req SyncRequest, writer *db.RelayWriter, progress *syncProgress, updateProgress func()) {
+	slog.Info("Sync job: connecting", "job_id", jobID, "relay", relayURL)
+	progress.setRelay(relayURL, SyncRelayConnecting, "")
+	updateProgress()
 
-				return nil
-			})
+	// Connect to relay
+	client := nostr.NewClient(relayURL)
+	if err := client.Connect(ctx); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("Sync job: failed to connect", "job_id", jobID, "relay", relayURL, "error", err)
+		progress.mu.Lock()
+		progress.lastError = fmt.Sprintf("failed to connect to %s: %v", relayURL, err)
+		progress.step += int64(len(req.Pubkeys) - progress.byURL[relayURL].PubkeysDone)
+		progress.mu.Unlock()
+		progress.setRelay(relayURL, SyncRelayFailed, err.Error())
+		updateProgress()
+		return
+	}
+	defer client.Close()
 
-			if err != nil {
-				if ctx.Err() != nil {
-					// Context was cancelled
-					client.Close()
-					finalStatus = "cancelled"
-					goto done
-				}
-				log.Printf("Sync job %d: error syncing %s from %s: %v", jobID, pubkey[:16], relayURL, err)
+	progress.setRelay(relayURL, SyncRelaySyncing, "")
+	updateProgress()
+	relay := progress.byURL[relayURL]
+
+	// For each pubkey
+	for _, pubkey := range req.Pubkeys {
+		// Check cancellation
+		if ctx.Err() != nil {
+			return
+		}
+
+		cursor := progress.cursor(relayURL, pubkey)
+		if cursor.Done {
+			continue
+		}
+
+		slog.Debug("Sync job: syncing pubkey", "job_id", jobID, "pubkey", pubkey[:16], "relay", relayURL)
+		if err := s.syncPubkey(ctx, jobID, client, cursor, req, writer, progress, updateProgress); err != nil {
+			if ctx.Err() != nil {
+				// Context was cancelled
+				return
 			}
+			slog.Warn("Sync job: error syncing pubkey", "job_id", jobID, "pubkey", pubkey[:16], "relay", relayURL, "error", err)
+			progress.mu.Lock()
+			relay.Error = err.Error()
+			progress.mu.Unlock()
 		}
 
-		client.Close()
+		progress.mu.Lock()
+		progress.step++
+		relay.PubkeysDone++
+		progress.mu.Unlock()
+		updateProgress()
 	}
 
-done:
-	// Final progress update
+	progress.setRelay(relayURL, SyncRelayDone, "")
 	updateProgress()
+}
 
-	// Complete the job
-	if lastError != "" && finalStatus == "completed" && totalStored == 0 && totalFetched == 0 {
-		finalStatus = "failed"
-	}
-	s.db.CompleteSyncJob(ctx, jobID, finalStatus, lastError)
+// syncPubkey fetches one pubkey's events from a relay a page at a time,
+// newest first, starting below the cursor. The cursor is saved after each
+// page so a paused sync resumes with the next page; it is marked done once
+// the relay returns nothing older.
+func (s *SyncService) syncPubkey(ctx context.Context, jobID int64, client *nostr.Client, cursor db.SyncCursor, req SyncRequest, writer *db.RelayWriter, progress *syncProgress, updateProgress func()) error {
+	relay := progress.byURL[cursor.Relay]
+
+	for !cursor.Done {
+		// Build filter
+		filter := nostr.Filter{
+			Authors: []string{cursor.Pubkey},
+			Until:   cursor.Until,
+			Limit:   syncPageSize,
+		}
+		if len(req.EventKinds) > 0 {
+			filter.Kinds = req.EventKinds
+		}
+		if req.SinceTimestamp != nil {
+			filter.Since = req.SinceTimestamp
+		}
 
-	log.Printf("Sync job %d %s: fetched=%d, stored=%d, skipped=%d",
-		jobID, finalStatus, totalFetched, totalStored, totalSkipped)
+		// Subscribe and receive events
+		var oldest *int64
+		err := client.Subscribe(ctx, filter, func(event *nostr.SyncEvent) error {
+			if oldest == nil || event.CreatedAt < *oldest {
+				createdAt := event.CreatedAt
+				oldest = &createdAt
+			}
+
+			// Relays send whatever they have stored, so check the ID and
+			// signature unless the operator trusts them
+			if !req.SkipVerification {
+				if err := event.Verify(); err != nil {
+					slog.Debug("Sync job: rejecting invalid event", "job_id", jobID, "event_id", event.ID, "error", err)
+					progress.mu.Lock()
+					progress.fetched++
+					relay.EventsFetched++
+					progress.rejected++
+					progress.mu.Unlock()
+					return nil
+				}
+			}
+
+			// Convert to db.Event
+			dbEvent := &db.Event{
+				ID:        event.ID,
+				Pubkey:    event.Pubkey,
+				CreatedAt: time.Unix(event.CreatedAt, 0),
+				Kind:      event.Kind,
+				Tags:      event.Tags,
+				Content:   event.Content,
+				Sig:       event.Sig,
+			}
+
+			// Insert event, replacing older versions of replaceable events
+			stored, err := writer.StoreEvent(ctx, dbEvent)
+
+			progress.mu.Lock()
+			progress.fetched++
+			relay.EventsFetched++
+			progress.replaced += stored.Replaced
+			switch {
+			case errors.Is(err, db.ErrKindExcluded), errors.Is(err, db.ErrTombstoned), errors.Is(err, db.ErrSuperseded),
+				errors.Is(err, db.ErrEphemeral), errors.Is(err, db.ErrExpired):
+				progress.skipped++
+			case err != nil:
+				slog.Warn("Sync job: failed to insert event", "job_id", jobID, "event_id", event.ID, "error", err)
+			case stored.Inserted:
+				progress.stored++
+			default:
+				progress.skipped++
+			}
+			fetched := progress.fetched
+			progress.mu.Unlock()
+
+			// Periodic progress update (every 100 events)
+			if fetched%100 == 0 {
+				updateProgress()
+			}
+
+			return nil // Continue despite errors
+		})
+		if err != nil {
+			return err
+		}
+
+		// The next page starts at the oldest event seen. Events sharing that
+		// second are fetched again and skipped as duplicates.
+		if oldest == nil || (cursor.Until != nil && *oldest >= *cursor.Until) {
+			cursor.Done = true
+		} else {
+			cursor.Until = oldest
+		}
+		progress.setCursor(cursor)
+		if err := s.db.SaveSyncCursor(context.Background(), jobID, cursor); err != nil {
+			slog.Warn("Sync job: failed to save cursor", "job_id", jobID, "relay", cursor.Relay, "error", err)
+		}
+	}
+	return nil
 }
 
 // CancelSync cancels the currently running sync job.
@@ -237,12 +684,30 @@ func (s *SyncService) CancelSync() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.running || s.cancelFn == nil {
+	if !s.running {
 		return fmt.Errorf("no sync job is running")
 	}
 
-	s.cancelFn()
-	return nil
+	s.pausing = false
+	return s.jobs.Cancel(s.runID)
+}
+
+// Progress returns the progress of the running or most recent sync, and the
+// jobs ID of the run if it is still running. It returns false if the sync
+// with this sync_jobs ID is neither.
+func (s *SyncService) Progress(jobID int64) (SyncProgress, int64, bool) {
+	s.mu.Lock()
+	progress := s.progress
+	runID := s.runID
+	if !s.running || s.jobID != jobID {
+		runID = 0
+	}
+	s.mu.Unlock()
+
+	if progress == nil || progress.jobID != jobID {
+		return SyncProgress{}, 0, false
+	}
+	return progress.Progress(), runID, true
 }
 
 // GetCurrentJobID returns the ID of the currently running job, or 0 if none.
@@ -2,10 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -167,7 +169,7 @@ func TestSyncService_SyncJobDatabase(t *testing.T) {
 		id, _ := database.CreateSyncJob(ctx, job)
 
 		// Update progress
-		err := database.UpdateSyncJobProgress(ctx, id, 100, 50, 10)
+		err := database.UpdateSyncJobProgress(ctx, id, 100, 50, 10, 0)
 		if err != nil {
 			t.Fatalf("failed to update progress: %v", err)
 		}
@@ -397,3 +399,178 @@ func TestSyncRequest(t *testing.T) {
 		}
 	})
 }
+
+// TestSyncProgress tests the relay states and counts reported while syncing.
+func TestSyncProgress(t *testing.T) {
+	progress := newSyncProgress(7, SyncRequest{
+		Pubkeys: []string{"aa", "bb"},
+		Relays:  []string{"wss://one", "wss://two", "wss://one"},
+	})
+
+	got := progress.Progress()
+	if got.JobID != 7 || got.Status != "running" || len(got.Relays) != 2 || got.Relays[0].Status != SyncRelayPending {
+		t.Fatalf("unexpected initial progress: %+v", got)
+	}
+
+	progress.setRelay("wss://one", SyncRelaySyncing, "")
+	progress.setRelay("wss://two", SyncRelayFailed, "connection refused")
+	progress.mu.Lock()
+	progress.fetched, progress.stored = 10, 8
+	progress.lastError = "failed to connect to wss://two"
+	progress.mu.Unlock()
+
+	got = progress.Progress()
+	if got.EventsFetched != 10 || got.EventsStored != 8 || got.ErrorMessage != "" {
+		t.Errorf("expected counts without an error while running, got %+v", got)
+	}
+	if got.Relays[0].Status != SyncRelaySyncing || got.Relays[1].Status != SyncRelayFailed || got.Relays[1].Error != "connection refused" {
+		t.Errorf("unexpected relay states: %+v", got.Relays)
+	}
+
+	progress.finish("completed", "failed to connect to wss://two")
+	if got := progress.Progress(); got.Status != "completed" || got.ErrorMessage != "failed to connect to wss://two" {
+		t.Errorf("unexpected final progress: %+v", got)
+	}
+}
+
+// TestSyncService_Progress tests that a sync's relay states outlive it.
+func TestSyncService_Progress(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	svc := NewSyncService(database)
+	ctx := context.Background()
+
+	if _, _, ok := svc.Progress(1); ok {
+		t.Error("expected no progress before any sync")
+	}
+
+	// Nothing listens on port 1, so the connection is refused
+	jobID, err := svc.StartSync(ctx, SyncRequest{
+		Pubkeys: []string{"aa00000000000000000000000000000000000000000000000000000000000000"},
+		Relays:  []string{"ws://127.0.0.1:1"},
+	})
+	if err != nil {
+		t.Fatalf("StartSync failed: %v", err)
+	}
+	_, runID, ok := svc.Progress(jobID)
+	if !ok || runID == 0 {
+		t.Fatalf("expected progress for the running sync, got run %d", runID)
+	}
+	waitJob(t, svc.jobs, runID)
+
+	progress, runID, ok := svc.Progress(jobID)
+	if !ok || runID != 0 {
+		t.Fatalf("expected progress for the finished sync without a run, got run %d", runID)
+	}
+	if progress.Status != "failed" || progress.ErrorMessage == "" {
+		t.Errorf("expected a failed sync, got %+v", progress)
+	}
+	if len(progress.Relays) != 1 || progress.Relays[0].Status != SyncRelayFailed || progress.Relays[0].Error == "" {
+		t.Errorf("expected the relay to have failed, got %+v", progress.Relays)
+	}
+	if _, _, ok := svc.Progress(jobID + 1); ok {
+		t.Error("expected no progress for another sync")
+	}
+}
+
+// TestSyncService_PauseResume tests that an interrupted sync is paused on
+// startup and resumes from its cursors.
+func TestSyncService_PauseResume(t *testing.T) {
+	database, _ := setupTestDBWithRelay(t)
+	svc := NewSyncService(database)
+	ctx := context.Background()
+
+	done := "aa00000000000000000000000000000000000000000000000000000000000000"
+	pending := "bb00000000000000000000000000000000000000000000000000000000000000"
+	jobID, err := database.CreateSyncJob(ctx, db.SyncJob{
+		Pubkeys: []string{done, pending},
+		Relays:  []string{"ws://127.0.0.1:1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSyncJob failed: %v", err)
+	}
+	database.UpdateSyncJobProgress(ctx, jobID, 40, 30, 10, 0)
+	until := int64(1700000000)
+	database.SaveSyncCursor(ctx, jobID, db.SyncCursor{Relay: "ws://127.0.0.1:1", Pubkey: done, Done: true})
+	database.SaveSyncCursor(ctx, jobID, db.SyncCursor{Relay: "ws://127.0.0.1:1", Pubkey: pending, Until: &until})
+
+	if _, err := svc.ResumeSync(ctx, jobID); !errors.Is(err, ErrSyncNotPaused) {
+		t.Errorf("expected ErrSyncNotPaused for a running job, got %v", err)
+	}
+
+	// The previous process stopped mid-sync
+	svc.Start()
+	job, _ := database.GetSyncJob(ctx, jobID)
+	if job.Status != "paused" {
+		t.Fatalf("expected the interrupted sync to be paused, got %q", job.Status)
+	}
+
+	if err := svc.PauseSync(jobID); !errors.Is(err, ErrSyncNotRunning) {
+		t.Errorf("expected ErrSyncNotRunning, got %v", err)
+	}
+	if run, err := svc.ResumeSync(ctx, jobID+1); run != nil || err != nil {
+		t.Errorf("expected nothing for an unknown sync, got %v, %v", run, err)
+	}
+
+	// Nothing listens on port 1, so the resumed sync fails to connect
+	run, err := svc.ResumeSync(ctx, jobID)
+	if err != nil {
+		t.Fatalf("ResumeSync failed: %v", err)
+	}
+	progress, _, ok := svc.Progress(jobID)
+	if !ok || progress.EventsFetched != 40 || progress.Relays[0].PubkeysDone != 1 {
+		t.Errorf("expected progress to carry over, got %+v", progress)
+	}
+	waitJob(t, svc.jobs, run.ID)
+
+	job, _ = database.GetSyncJob(ctx, jobID)
+	if job.Status != "completed" || job.EventsStored != 30 || job.ErrorMessage == "" {
+		t.Errorf("expected a completed sync keeping its counts and the error, got %+v", job)
+	}
+	if cursors, _ := database.GetSyncCursors(ctx, jobID); len(cursors) != 0 {
+		t.Errorf("expected cursors to be deleted, got %d", len(cursors))
+	}
+}
+
+// TestSyncService_VerifiesEvents tests that events failing verification are
+// rejected unless the relay is trusted.
+func TestSyncService_VerifiesEvents(t *testing.T) {
+	ctx := context.Background()
+	secret, _ := nostr.GenerateSecretKey()
+	pubkey, _ := nostr.GetPublicKey(secret)
+
+	fake, url := newFakeBackupRelay(t)
+	for i, content := range []string{"valid", "tampered"} {
+		event := &nostr.SyncEvent{Pubkey: pubkey, CreatedAt: int64(1700000000 + i), Kind: 1, Tags: [][]string{}, Content: content}
+		if err := event.Sign(secret); err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		if content == "tampered" {
+			event.Content = "changed after signing"
+		}
+		fake.events = append(fake.events, event)
+	}
+
+	for _, skip := range []bool{false, true} {
+		database, _ := setupTestDBWithRelay(t)
+		svc := NewSyncService(database)
+
+		run, err := svc.StartSyncJob(ctx, SyncRequest{Pubkeys: []string{pubkey}, Relays: []string{url}, SkipVerification: skip})
+		if err != nil {
+			t.Fatalf("StartSyncJob failed: %v", err)
+		}
+		waitJob(t, svc.jobs, run.ID)
+
+		job, _ := database.GetSyncJob(ctx, svc.progress.jobID)
+		if job.SkipVerification != skip {
+			t.Errorf("expected skip_verification %v to be saved, got %v", skip, job.SkipVerification)
+		}
+		// The fake relay ignores the filter, so each page returns the
+		// tampered event again
+		if skip && (job.EventsStored != 2 || job.EventsRejected != 0) {
+			t.Errorf("expected both events stored unverified, got stored %d, rejected %d", job.EventsStored, job.EventsRejected)
+		}
+		if !skip && (job.EventsStored != 1 || job.EventsRejected == 0) {
+			t.Errorf("expected the tampered event rejected, got stored %d, rejected %d", job.EventsStored, job.EventsRejected)
+		}
+	}
+}
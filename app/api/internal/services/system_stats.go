@@ -0,0 +1,387 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/relay"
+)
+
+const (
+	// SystemStatsInterval is how often a sample is taken.
+	SystemStatsInterval = time.Minute
+
+	// systemStatsSamples is how many samples are kept: a day's worth.
+	systemStatsSamples = int(24 * time.Hour / SystemStatsInterval)
+
+	// clockTicks is the kernel's USER_HZ, the unit of CPU times in /proc.
+	// It is 100 on every architecture Roostr runs on.
+	clockTicks = 100
+
+	// sectorSize is the unit of the sector counts in /proc/diskstats.
+	sectorSize = 512
+)
+
+// ProcessStats is the resource use of one process.
+type ProcessStats struct {
+	PID              int     `json:"pid"`
+	CPUPercent       float64 `json:"cpu_percent"` // Of one CPU, so up to 100 per CPU
+	MemoryBytes      int64   `json:"memory_bytes"`
+	OpenFDs          int     `json:"open_fds"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+}
+
+// SystemSample is the state of the machine and Roostr's processes at one
+// moment. Rates cover the time since the previous sample.
+type SystemSample struct {
+	Time                 time.Time     `json:"time"`
+	Load1                float64       `json:"load_1"`
+	Load5                float64       `json:"load_5"`
+	Load15               float64       `json:"load_15"`
+	CPUPercent           float64       `json:"cpu_percent"` // Of all CPUs
+	MemoryTotalBytes     int64         `json:"memory_total_bytes"`
+	MemoryAvailableBytes int64         `json:"memory_available_bytes"`
+	DiskReadBytesPerSec  float64       `json:"disk_read_bytes_per_sec"`
+	DiskWriteBytesPerSec float64       `json:"disk_write_bytes_per_sec"`
+	API                  ProcessStats  `json:"api"`
+	Relay                *ProcessStats `json:"relay,omitempty"` // nil while the relay is not running
+	RelayWALBytes        int64         `json:"relay_wal_bytes"`
+	AppWALBytes          int64         `json:"app_wal_bytes"`
+}
+
+// systemCounters are the cumulative counters rates are computed from.
+type systemCounters struct {
+	at        time.Time
+	cpuTotal  uint64
+	cpuIdle   uint64
+	diskRead  uint64
+	diskWrite uint64
+	procs     map[int]processCounters
+}
+
+type processCounters struct {
+	ticks uint64
+	read  uint64
+	write uint64
+}
+
+// SystemStatsService samples CPU, memory, disk I/O and the API and relay
+// processes once a minute from /proc, keeping the last day of samples in a
+// ring buffer for the dashboard's charts. Where /proc is missing the
+// samples are zero.
+type SystemStatsService struct {
+	db       *db.DB
+	relayPID func() int
+	procRoot string
+	sysRoot  string
+	now      func() time.Time
+
+	samplesMu sync.Mutex
+	samples   []SystemSample // ring buffer of systemStatsSamples
+	next      int            // where the next sample goes
+	count     int
+	prev      *systemCounters
+
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running bool
+	mu      sync.Mutex
+}
+
+// NewSystemStatsService creates a new system stats service.
+func NewSystemStatsService(database *db.DB, relayCtl *relay.Relay) *SystemStatsService {
+	s := &SystemStatsService{
+		db:       database,
+		relayPID: func() int { return 0 },
+		procRoot: "/proc",
+		sysRoot:  "/sys",
+		now:      time.Now,
+		samples:  make([]SystemSample, systemStatsSamples),
+		stopCh:   make(chan struct{}),
+	}
+	if relayCtl != nil {
+		s.relayPID = relayCtl.GetPID
+	}
+	return s
+}
+
+// Start begins sampling.
+func (s *SystemStatsService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops sampling.
+func (s *SystemStatsService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *SystemStatsService) run() {
+	defer s.wg.Done()
+
+	// The first sample only records the counters the next one's rates
+	// are computed from, so it is not kept
+	s.Sample()
+	s.samplesMu.Lock()
+	s.next, s.count = 0, 0
+	s.samplesMu.Unlock()
+
+	ticker := time.NewTicker(SystemStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.Sample()
+		}
+	}
+}
+
+// Samples returns the samples taken after since, oldest first.
+func (s *SystemStatsService) Samples(since time.Time) []SystemSample {
+	s.samplesMu.Lock()
+	defer s.samplesMu.Unlock()
+
+	samples := make([]SystemSample, 0, s.count)
+	start := (s.next - s.count + len(s.samples)) % len(s.samples)
+	for i := 0; i < s.count; i++ {
+		sample := s.samples[(start+i)%len(s.samples)]
+		if sample.Time.After(since) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// Latest returns the most recent sample, or nil before the first one.
+func (s *SystemStatsService) Latest() *SystemSample {
+	s.samplesMu.Lock()
+	defer s.samplesMu.Unlock()
+
+	if s.count == 0 {
+		return nil
+	}
+	sample := s.samples[(s.next-1+len(s.samples))%len(s.samples)]
+	return &sample
+}
+
+// Sample takes a sample, adds it to the buffer and returns it.
+func (s *SystemStatsService) Sample() SystemSample {
+	now := s.now()
+	sample := SystemSample{Time: now}
+	counters := &systemCounters{at: now, procs: make(map[int]processCounters)}
+
+	if data, err := os.ReadFile(filepath.Join(s.procRoot, "loadavg")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) >= 3 {
+			sample.Load1, _ = strconv.ParseFloat(fields[0], 64)
+			sample.Load5, _ = strconv.ParseFloat(fields[1], 64)
+			sample.Load15, _ = strconv.ParseFloat(fields[2], 64)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(s.procRoot, "meminfo")); err == nil {
+		info := parseKeyValues(data)
+		sample.MemoryTotalBytes = int64(info["MemTotal"]) * 1024
+		sample.MemoryAvailableBytes = int64(info["MemAvailable"]) * 1024
+	}
+	counters.cpuTotal, counters.cpuIdle = s.readCPUTimes()
+	counters.diskRead, counters.diskWrite = s.readDiskStats()
+	if s.db != nil {
+		sample.RelayWALBytes, sample.AppWALBytes = s.db.GetWALSizes()
+	}
+
+	s.samplesMu.Lock()
+	defer s.samplesMu.Unlock()
+
+	prev := s.prev
+	var elapsed float64
+	if prev != nil {
+		elapsed = now.Sub(prev.at).Seconds()
+	}
+	rate := func(cur, last uint64) float64 {
+		if elapsed <= 0 || cur < last {
+			return 0
+		}
+		return float64(cur-last) / elapsed
+	}
+
+	if prev != nil {
+		if counters.cpuTotal > prev.cpuTotal && counters.cpuIdle >= prev.cpuIdle {
+			total := counters.cpuTotal - prev.cpuTotal
+			idle := min(total, counters.cpuIdle-prev.cpuIdle)
+			sample.CPUPercent = 100 * float64(total-idle) / float64(total)
+		}
+		sample.DiskReadBytesPerSec = rate(counters.diskRead, prev.diskRead)
+		sample.DiskWriteBytesPerSec = rate(counters.diskWrite, prev.diskWrite)
+	}
+
+	process := func(pid int) *ProcessStats {
+		stats, cur, ok := s.readProcess(pid)
+		if !ok {
+			return nil
+		}
+		counters.procs[pid] = cur
+		if prev != nil {
+			if last, ok := prev.procs[pid]; ok {
+				stats.CPUPercent = 100 * rate(cur.ticks, last.ticks) / clockTicks
+				stats.ReadBytesPerSec = rate(cur.read, last.read)
+				stats.WriteBytesPerSec = rate(cur.write, last.write)
+			}
+		}
+		return stats
+	}
+	if api := process(os.Getpid()); api != nil {
+		sample.API = *api
+	}
+	if pid := s.relayPID(); pid > 0 {
+		sample.Relay = process(pid)
+	}
+
+	s.prev = counters
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+	if s.count < len(s.samples) {
+		s.count++
+	}
+	return sample
+}
+
+// readCPUTimes returns the total and idle CPU time of all CPUs, in ticks.
+func (s *SystemStatsService) readCPUTimes() (total, idle uint64) {
+	data, err := os.ReadFile(filepath.Join(s.procRoot, "stat"))
+	if err != nil {
+		return 0, 0
+	}
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0
+	}
+	// user nice system idle iowait irq softirq steal; guest time is
+	// already counted in user
+	for i, f := range fields[1:min(len(fields), 9)] {
+		n, _ := strconv.ParseUint(f, 10, 64)
+		total += n
+		if i == 3 || i == 4 {
+			idle += n
+		}
+	}
+	return total, idle
+}
+
+// readDiskStats returns the bytes read from and written to whole disks.
+// Partitions are skipped so that their I/O is not counted twice, as are
+// loop and RAM devices, which are not disks.
+func (s *SystemStatsService) readDiskStats() (read, write uint64) {
+	data, err := os.ReadFile(filepath.Join(s.procRoot, "diskstats"))
+	if err != nil {
+		return 0, 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		name := fields[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(s.sysRoot, "block", name)); err != nil {
+			continue
+		}
+		sectorsRead, _ := strconv.ParseUint(fields[5], 10, 64)
+		sectorsWritten, _ := strconv.ParseUint(fields[9], 10, 64)
+		read += sectorsRead * sectorSize
+		write += sectorsWritten * sectorSize
+	}
+	return read, write
+}
+
+// readProcess returns a process's memory and open files, and the counters
+// its CPU and I/O rates are computed from. It reports false if the process
+// does not exist.
+func (s *SystemStatsService) readProcess(pid int) (*ProcessStats, processCounters, bool) {
+	var counters processCounters
+	dir := filepath.Join(s.procRoot, strconv.Itoa(pid))
+
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, counters, false
+	}
+	stats := &ProcessStats{PID: pid}
+
+	// The command name may contain spaces, so fields are counted from
+	// after it; utime and stime are the 14th and 15th fields
+	if i := bytes.LastIndexByte(data, ')'); i >= 0 {
+		fields := strings.Fields(string(data[i+1:]))
+		if len(fields) >= 13 {
+			utime, _ := strconv.ParseUint(fields[11], 10, 64)
+			stime, _ := strconv.ParseUint(fields[12], 10, 64)
+			counters.ticks = utime + stime
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		stats.MemoryBytes = int64(parseKeyValues(data)["VmRSS"]) * 1024
+	}
+	if entries, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		stats.OpenFDs = len(entries)
+	}
+	// io is only readable by the process's owner or root
+	if data, err := os.ReadFile(filepath.Join(dir, "io")); err == nil {
+		io := parseKeyValues(data)
+		counters.read = io["read_bytes"]
+		counters.write = io["write_bytes"]
+	}
+	return stats, counters, true
+}
+
+// parseKeyValues parses the "Key: value [kB]" lines of files such as
+// /proc/meminfo, /proc/pid/status and /proc/pid/io. Values that are not
+// numbers are skipped.
+func parseKeyValues(data []byte) map[string]uint64 {
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			values[key] = n
+		}
+	}
+	return values
+}
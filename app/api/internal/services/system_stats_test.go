@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// writeProcTree writes the /proc and /sys files the system stats service
+// reads, with counters scaled by step.
+func writeProcTree(t *testing.T, proc, sys string, relayPID int, step uint64) {
+	t.Helper()
+	write := func(path, content string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(filepath.Join(proc, "loadavg"), "0.50 0.25 0.10 1/100 1234\n")
+	write(filepath.Join(proc, "meminfo"), "MemTotal:        2048000 kB\nMemFree:          512000 kB\nMemAvailable:    1024000 kB\n")
+	// 6000 ticks a step, of which 4500 idle: 25% busy
+	write(filepath.Join(proc, "stat"), fmt.Sprintf("cpu  %d 0 %d %d %d 0 0 0 0 0\ncpu0 1 2 3 4\n", 1000*step, 500*step, 4000*step, 500*step))
+	// sda is a disk and sda1 its partition; loop0 is skipped
+	write(filepath.Join(proc, "diskstats"), fmt.Sprintf(
+		"   8       0 sda 10 0 %d 0 20 0 %d 0 0 0 0\n   8       1 sda1 10 0 %d 0 20 0 %d 0 0 0 0\n   7       0 loop0 1 0 %d 0 1 0 %d 0 0 0 0\n",
+		1200*step, 2400*step, 1200*step, 2400*step, 99999*step, 99999*step))
+	if err := os.MkdirAll(filepath.Join(sys, "block", "sda"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(sys, "block", "loop0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pid := range []int{os.Getpid(), relayPID} {
+		dir := filepath.Join(proc, strconv.Itoa(pid))
+		// 3000 ticks a step over 60 seconds is half of one CPU
+		write(filepath.Join(dir, "stat"), fmt.Sprintf("%d (nostr rs) S 1 1 1 0 -1 0 0 0 0 0 %d %d 0 0 20 0 1 0\n", pid, 2000*step, 1000*step))
+		write(filepath.Join(dir, "status"), "Name:\tnostr-rs-relay\nVmRSS:\t   20480 kB\n")
+		write(filepath.Join(dir, "io"), fmt.Sprintf("rchar: 1\nread_bytes: %d\nwrite_bytes: %d\n", 6000*step, 12000*step))
+		for i := 0; i < 3; i++ {
+			write(filepath.Join(dir, "fd", strconv.Itoa(i)), "")
+		}
+	}
+}
+
+func TestSystemStatsService_Sample(t *testing.T) {
+	proc, sys := t.TempDir(), t.TempDir()
+	relayPID := os.Getpid() + 1
+
+	svc := NewSystemStatsService(nil, nil)
+	svc.procRoot, svc.sysRoot = proc, sys
+	svc.relayPID = func() int { return relayPID }
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	writeProcTree(t, proc, sys, relayPID, 1)
+	first := svc.Sample()
+	if first.Load1 != 0.5 || first.Load15 != 0.1 || first.MemoryTotalBytes != 2048000*1024 || first.MemoryAvailableBytes != 1024000*1024 {
+		t.Errorf("unexpected first sample: %+v", first)
+	}
+	if first.CPUPercent != 0 || first.API.CPUPercent != 0 {
+		t.Errorf("expected no rates without a previous sample, got %+v", first)
+	}
+	if first.Relay == nil || first.Relay.PID != relayPID || first.Relay.MemoryBytes != 20480*1024 || first.Relay.OpenFDs != 3 {
+		t.Errorf("unexpected relay stats: %+v", first.Relay)
+	}
+
+	writeProcTree(t, proc, sys, relayPID, 2)
+	now = now.Add(time.Minute)
+	second := svc.Sample()
+	if second.CPUPercent != 25 {
+		t.Errorf("CPUPercent = %v, want 25", second.CPUPercent)
+	}
+	// Only sda counts: 1200 sectors read and 2400 written in 60 seconds
+	if second.DiskReadBytesPerSec != 1200*512/60 || second.DiskWriteBytesPerSec != 2400*512/60 {
+		t.Errorf("unexpected disk rates: read %v, write %v", second.DiskReadBytesPerSec, second.DiskWriteBytesPerSec)
+	}
+	if second.Relay.CPUPercent != 50 || second.Relay.ReadBytesPerSec != 100 || second.Relay.WriteBytesPerSec != 200 {
+		t.Errorf("unexpected relay rates: %+v", second.Relay)
+	}
+
+	// A stopped relay is left out
+	relayPID = 0
+	now = now.Add(time.Minute)
+	if third := svc.Sample(); third.Relay != nil {
+		t.Errorf("expected no relay stats, got %+v", third.Relay)
+	}
+
+	if samples := svc.Samples(now.Add(-90 * time.Second)); len(samples) != 2 || !samples[0].Time.Before(samples[1].Time) {
+		t.Errorf("expected the last two samples oldest first, got %d", len(samples))
+	}
+	if latest := svc.Latest(); latest == nil || !latest.Time.Equal(now) {
+		t.Errorf("unexpected latest sample: %+v", latest)
+	}
+}
+
+func TestSystemStatsService_RingBuffer(t *testing.T) {
+	svc := NewSystemStatsService(nil, nil)
+	svc.procRoot, svc.sysRoot = t.TempDir(), t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	svc.now = func() time.Time { return now }
+
+	// A day and an hour of samples overwrites the first hour
+	for i := 0; i < systemStatsSamples+60; i++ {
+		svc.Sample()
+		now = now.Add(SystemStatsInterval)
+	}
+
+	samples := svc.Samples(time.Time{})
+	if len(samples) != systemStatsSamples {
+		t.Fatalf("expected %d samples, got %d", systemStatsSamples, len(samples))
+	}
+	if want := start.Add(time.Hour); !samples[0].Time.Equal(want) {
+		t.Errorf("oldest sample at %v, want %v", samples[0].Time, want)
+	}
+	if last := samples[len(samples)-1].Time; !last.Equal(now.Add(-SystemStatsInterval)) {
+		t.Errorf("newest sample at %v", last)
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+const (
+	// uptimeCheckInterval is how often the relay is checked.
+	uptimeCheckInterval = 5 * time.Minute
+
+	// uptimeCheckTimeout is how long a check may take before the relay
+	// counts as down.
+	uptimeCheckTimeout = 10 * time.Second
+
+	// uptimeRetention is how long checks are kept.
+	uptimeRetention = 90 * 24 * time.Hour
+)
+
+// UptimeService checks the relay the way a client would, connecting to its
+// WebSocket and timing a REQ until EOSE, and records each check so
+// operators can publish an availability figure.
+type UptimeService struct {
+	db        *db.DB
+	bandwidth *BandwidthService
+	interval  time.Duration
+	timeout   time.Duration
+	now       func() time.Time
+
+	relayURL   string
+	lastPruned time.Time
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+	running    bool
+	mu         sync.Mutex
+}
+
+// NewUptimeService creates a new uptime service. Active connections are
+// recorded while the bandwidth proxy, which counts them, is enabled.
+func NewUptimeService(database *db.DB, bandwidth *BandwidthService) *UptimeService {
+	return &UptimeService{
+		db:        database,
+		bandwidth: bandwidth,
+		interval:  uptimeCheckInterval,
+		timeout:   uptimeCheckTimeout,
+		now:       time.Now,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Configure sets the WebSocket URL the relay is checked at; empty disables
+// the checks.
+func (s *UptimeService) Configure(relayURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.relayURL = relayURL
+}
+
+// IsEnabled returns whether checks are configured.
+func (s *UptimeService) IsEnabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.relayURL != ""
+}
+
+// Start begins checking the relay.
+func (s *UptimeService) Start() {
+	s.mu.Lock()
+	if s.running || s.relayURL == "" {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the checks.
+func (s *UptimeService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *UptimeService) run() {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+
+	// The first check waits a full interval, so a relay still starting up
+	// alongside Roostr is not counted as down
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			if _, err := s.Check(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Uptime check failed", "error", err)
+			}
+		}
+	}
+}
+
+// Check checks the relay once and records the result. An unreachable relay
+// is a result, not an error; errors are from recording it.
+func (s *UptimeService) Check(ctx context.Context) (db.UptimeCheck, error) {
+	s.mu.Lock()
+	url := s.relayURL
+	s.mu.Unlock()
+
+	check := db.UptimeCheck{CheckedAt: s.now()}
+	if latency, err := s.probe(ctx, url); err != nil {
+		if ctx.Err() != nil {
+			return check, ctx.Err()
+		}
+		check.Error = err.Error()
+	} else {
+		ms := latency.Milliseconds()
+		check.Up = true
+		check.LatencyMs = &ms
+	}
+	if s.bandwidth != nil && s.bandwidth.IsEnabled() {
+		connections := s.bandwidth.ActiveConnections()
+		check.Connections = &connections
+	}
+
+	if err := s.db.AddUptimeCheck(ctx, check); err != nil {
+		return check, err
+	}
+	if !check.Up {
+		slog.Warn("Relay is not answering", "url", url, "error", check.Error)
+	}
+
+	if check.CheckedAt.Sub(s.lastPruned) >= 24*time.Hour {
+		if _, err := s.db.DeleteUptimeChecksBefore(ctx, check.CheckedAt.Add(-uptimeRetention)); err != nil {
+			slog.Warn("Failed to prune uptime checks", "error", err)
+		}
+		s.lastPruned = check.CheckedAt
+	}
+	return check, nil
+}
+
+// probe connects to the relay and returns how long a REQ took to be
+// answered with EOSE.
+func (s *UptimeService) probe(ctx context.Context, url string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	client := nostr.NewClient(url)
+	if err := client.Connect(ctx); err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	start := time.Now()
+	if err := client.Subscribe(ctx, nostr.Filter{Limit: 1}, func(*nostr.SyncEvent) error {
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
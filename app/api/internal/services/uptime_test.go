@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUptimeService_Check(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	_, url := newFakeBackupRelay(t)
+	svc := NewUptimeService(database, nil)
+	svc.Configure(url)
+
+	check, err := svc.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !check.Up || check.LatencyMs == nil || check.Error != "" || check.Connections != nil {
+		t.Errorf("expected the relay up, got %+v", check)
+	}
+
+	// Nothing listens on a closed listener's port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	listener.Close()
+	svc.Configure("ws://" + listener.Addr().String())
+	svc.timeout = 2 * time.Second
+
+	check, err = svc.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if check.Up || check.LatencyMs != nil || check.Error == "" {
+		t.Errorf("expected the relay down, got %+v", check)
+	}
+
+	latest, err := database.GetLatestUptimeCheck(ctx)
+	if err != nil || latest == nil || latest.Up {
+		t.Fatalf("expected the failed check to be the latest, got %+v (%v)", latest, err)
+	}
+
+	summary, err := database.GetUptimeSummary(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetUptimeSummary failed: %v", err)
+	}
+	if summary.Checks != 2 || summary.Up != 1 || summary.Availability == nil || *summary.Availability != 50 || summary.AvgLatencyMs == nil {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	days, err := database.GetUptimeDaily(ctx, time.Now().Add(-48*time.Hour))
+	if err != nil {
+		t.Fatalf("GetUptimeDaily failed: %v", err)
+	}
+	if len(days) != 1 || days[0].Date != time.Now().UTC().Format("2006-01-02") || days[0].Checks != 2 {
+		t.Errorf("unexpected daily totals: %+v", days)
+	}
+}
+
+func TestUptimeService_Prune(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	_, url := newFakeBackupRelay(t)
+	svc := NewUptimeService(database, nil)
+	svc.Configure(url)
+
+	now := time.Now().Add(-100 * 24 * time.Hour)
+	svc.now = func() time.Time { return now }
+	if _, err := svc.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	// The next check prunes the one past the retention period
+	now = time.Now()
+	if _, err := svc.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	summary, _ := database.GetUptimeSummary(ctx, time.Time{})
+	if summary.Checks != 1 {
+		t.Errorf("expected the old check pruned, %d checks left", summary.Checks)
+	}
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// Problems verification finds with a stored event.
+const (
+	// VerifyProblemUnparseable means the stored JSON is not a Nostr event.
+	VerifyProblemUnparseable = "unparseable_json"
+
+	// VerifyProblemRowMismatch means the JSON's id, pubkey, created_at or
+	// kind differ from the row's indexed columns.
+	VerifyProblemRowMismatch = "row_mismatch"
+
+	// VerifyProblemIDMismatch means the ID is not the hash of the event.
+	VerifyProblemIDMismatch = "id_mismatch"
+
+	// VerifyProblemInvalidSignature means the signature does not verify.
+	VerifyProblemInvalidSignature = "invalid_signature"
+)
+
+// maxVerifyFlagged bounds how many invalid events a verification job lists.
+// The counts cover every event.
+const maxVerifyFlagged = 1000
+
+// verifyProgressInterval is how many events pass between progress updates.
+const verifyProgressInterval = 1000
+
+// EventVerification is the outcome of verifying a stored event.
+type EventVerification struct {
+	ID         string   `json:"id"`
+	Pubkey     string   `json:"pubkey"`
+	Kind       int      `json:"kind"`
+	Valid      bool     `json:"valid"`
+	Problems   []string `json:"problems"`
+	ComputedID string   `json:"computed_id,omitempty"`
+}
+
+// VerifyEventsResult is the result of a verify_events job.
+type VerifyEventsResult struct {
+	Checked   int64                `json:"checked"`
+	Valid     int64                `json:"valid"`
+	Invalid   int64                `json:"invalid"`
+	ByProblem map[string]int64     `json:"by_problem"`
+	Flagged   []*EventVerification `json:"flagged"`
+	Truncated bool                 `json:"truncated"`
+}
+
+// VerifyStoredEvent recomputes a stored event's ID from its JSON and checks
+// its Schnorr signature, and that the JSON agrees with the row it is
+// stored in.
+func VerifyStoredEvent(stored db.StoredEvent) *EventVerification {
+	v := &EventVerification{
+		ID:       stored.ID,
+		Pubkey:   stored.Pubkey,
+		Kind:     stored.Kind,
+		Problems: []string{},
+	}
+
+	var event nostr.SyncEvent
+	if err := json.Unmarshal([]byte(stored.JSON), &event); err != nil {
+		v.Problems = append(v.Problems, VerifyProblemUnparseable)
+		return v
+	}
+
+	if event.ID != stored.ID || event.Pubkey != stored.Pubkey ||
+		event.CreatedAt != stored.CreatedAt || event.Kind != stored.Kind {
+		v.Problems = append(v.Problems, VerifyProblemRowMismatch)
+	}
+
+	computed, err := event.ComputeID()
+	if err != nil {
+		v.Problems = append(v.Problems, VerifyProblemUnparseable)
+		return v
+	}
+	v.ComputedID = computed
+	if computed != event.ID {
+		v.Problems = append(v.Problems, VerifyProblemIDMismatch)
+	}
+
+	// The signature is over the ID the event claims, so a valid signature
+	// with an ID mismatch means the content changed after signing.
+	if err := event.VerifySignature(); err != nil {
+		v.Problems = append(v.Problems, VerifyProblemInvalidSignature)
+	}
+
+	v.Valid = len(v.Problems) == 0
+	return v
+}
+
+// VerifyEvents verifies every event in the relay database, reporting
+// progress to run if it is not nil.
+func VerifyEvents(ctx context.Context, database *db.DB, run *JobRun) (*VerifyEventsResult, error) {
+	total, err := database.CountEvents(ctx, db.EventFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count events: %w", err)
+	}
+	if run != nil {
+		run.Progress(0, total, "Verifying events")
+	}
+
+	result := &VerifyEventsResult{
+		ByProblem: map[string]int64{},
+		Flagged:   []*EventVerification{},
+	}
+	err = database.StreamStoredEvents(ctx, func(stored db.StoredEvent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		v := VerifyStoredEvent(stored)
+		result.Checked++
+		if v.Valid {
+			result.Valid++
+		} else {
+			result.Invalid++
+			for _, problem := range v.Problems {
+				result.ByProblem[problem]++
+			}
+			if len(result.Flagged) < maxVerifyFlagged {
+				result.Flagged = append(result.Flagged, v)
+			} else {
+				result.Truncated = true
+			}
+		}
+
+		if run != nil && result.Checked%verifyProgressInterval == 0 {
+			run.Progress(result.Checked, total, fmt.Sprintf("Verified %d events, %d invalid", result.Checked, result.Invalid))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if run != nil {
+		run.Progress(result.Checked, total, fmt.Sprintf("Verified %d events, %d invalid", result.Checked, result.Invalid))
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/testutil"
+)
+
+func TestVerifyEvents(t *testing.T) {
+	ctx := context.Background()
+	fixture := testutil.NewRelayDB(t, testutil.RelayOptions{Authors: 5, Events: 300, Sign: true})
+
+	conn, err := sql.Open("sqlite3", fixture.Path)
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	rows, err := conn.Query(`SELECT lower(hex(event_hash)) FROM event ORDER BY id LIMIT 5`)
+	if err != nil {
+		t.Fatalf("failed to pick events: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	tamper := map[string]string{
+		VerifyProblemIDMismatch:       `UPDATE event SET content = json_set(content, '$.content', 'tampered') WHERE event_hash = unhex(?)`,
+		VerifyProblemInvalidSignature: `UPDATE event SET content = json_set(content, '$.sig', '` + strings.Repeat("00", 64) + `') WHERE event_hash = unhex(?)`,
+		VerifyProblemRowMismatch:      `UPDATE event SET kind = kind + 1 WHERE event_hash = unhex(?)`,
+		VerifyProblemUnparseable:      `UPDATE event SET content = 'not json' WHERE event_hash = unhex(?)`,
+	}
+	tampered := map[string]string{}
+	i := 0
+	for problem, query := range tamper {
+		if _, err := conn.Exec(query, ids[i]); err != nil {
+			t.Fatalf("failed to tamper with %s: %v", ids[i], err)
+		}
+		tampered[ids[i]] = problem
+		i++
+	}
+	conn.Close()
+
+	database, err := db.New(fixture.Path, filepath.Join(t.TempDir(), "roostr.db"))
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer database.Close()
+
+	for id, problem := range tampered {
+		stored, err := database.GetStoredEvent(ctx, id)
+		if err != nil || stored == nil {
+			t.Fatalf("GetStoredEvent(%s) = %v, %v", id, stored, err)
+		}
+		v := VerifyStoredEvent(*stored)
+		if v.Valid || len(v.Problems) != 1 || v.Problems[0] != problem {
+			t.Errorf("event tampered for %s: got valid=%v problems=%v", problem, v.Valid, v.Problems)
+		}
+	}
+	stored, err := database.GetStoredEvent(ctx, ids[4])
+	if err != nil || stored == nil {
+		t.Fatalf("GetStoredEvent(%s) = %v, %v", ids[4], stored, err)
+	}
+	if v := VerifyStoredEvent(*stored); !v.Valid || v.ComputedID != ids[4] {
+		t.Errorf("untouched event should verify, got %+v", v)
+	}
+	if stored, err := database.GetStoredEvent(ctx, strings.Repeat("ab", 32)); err != nil || stored != nil {
+		t.Errorf("expected no stored event for an unknown ID, got %v, %v", stored, err)
+	}
+
+	result, err := VerifyEvents(ctx, database, nil)
+	if err != nil {
+		t.Fatalf("VerifyEvents failed: %v", err)
+	}
+	if result.Checked != 300 || result.Invalid != 4 || result.Valid != 296 {
+		t.Errorf("checked %d, valid %d, invalid %d; want 300, 296, 4", result.Checked, result.Valid, result.Invalid)
+	}
+	for problem := range tamper {
+		if result.ByProblem[problem] != 1 {
+			t.Errorf("expected one %s, got %d", problem, result.ByProblem[problem])
+		}
+	}
+	if len(result.Flagged) != 4 || result.Truncated {
+		t.Errorf("expected 4 flagged events, got %d (truncated %v)", len(result.Flagged), result.Truncated)
+	}
+	for _, v := range result.Flagged {
+		if _, ok := tampered[v.ID]; !ok {
+			t.Errorf("flagged untouched event %s: %v", v.ID, v.Problems)
+		}
+	}
+}
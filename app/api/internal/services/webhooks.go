@@ -0,0 +1,372 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+// Webhook event names.
+const (
+	WebhookEventInvoicePaid       = "invoice.paid"
+	WebhookEventUserWhitelisted   = "user.whitelisted"
+	WebhookEventUserGrace         = "user.grace"
+	WebhookEventUserExpired       = "user.expired"
+	WebhookEventQuotaExceeded     = "user.quota_exceeded"
+	WebhookEventSyncCompleted     = "sync.completed"
+	WebhookEventStorageCritical   = "storage.critical"
+	WebhookEventMaintenanceFailed = "maintenance.failed"
+	WebhookEventRelayDatabase     = "relay.database"
+	WebhookEventTest              = "webhook.test"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to.
+var WebhookEvents = []string{
+	WebhookEventInvoicePaid,
+	WebhookEventUserWhitelisted,
+	WebhookEventUserGrace,
+	WebhookEventUserExpired,
+	WebhookEventQuotaExceeded,
+	WebhookEventSyncCompleted,
+	WebhookEventStorageCritical,
+	WebhookEventMaintenanceFailed,
+	WebhookEventRelayDatabase,
+}
+
+// Delivery retry settings
+const (
+	webhookMaxAttempts    = 8
+	webhookBaseBackoff    = 30 * time.Second
+	webhookMaxBackoff     = 6 * time.Hour
+	webhookRequestTimeout = 10 * time.Second
+	webhookBatchSize      = 50
+)
+
+// storageCriticalPercent matches the "critical" level of GET /storage/status.
+const storageCriticalPercent = 95.0
+
+// WebhookPayload is the JSON body POSTed to webhook endpoints.
+type WebhookPayload struct {
+	ID        string                 `json:"id"`
+	Event     string                 `json:"event"`
+	CreatedAt int64                  `json:"created_at"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// WebhookService queues webhook deliveries and POSTs them with retries.
+// It also watches disk usage to emit storage.critical.
+type WebhookService struct {
+	db              *db.DB
+	client          *http.Client
+	interval        time.Duration
+	storageInterval time.Duration
+	storageCritical bool
+	wakeCh          chan struct{}
+	stopCh          chan struct{}
+	wg              sync.WaitGroup
+	running         bool
+	mu              sync.Mutex
+}
+
+// NewWebhookService creates a new webhook service.
+func NewWebhookService(database *db.DB) *WebhookService {
+	return &WebhookService{
+		db:              database,
+		client:          &http.Client{Timeout: webhookRequestTimeout},
+		interval:        5 * time.Second,
+		storageInterval: 10 * time.Minute,
+		wakeCh:          make(chan struct{}, 1),
+		stopCh:          make(chan struct{}),
+	}
+}
+
+// Start begins the background delivery worker.
+func (s *WebhookService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop gracefully stops the delivery worker.
+func (s *WebhookService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run delivers due webhooks and periodically checks storage.
+func (s *WebhookService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	storageTicker := time.NewTicker(s.storageInterval)
+	defer storageTicker.Stop()
+
+	s.checkStorage()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.deliverDue()
+		case <-s.wakeCh:
+			s.deliverDue()
+		case <-storageTicker.C:
+			s.checkStorage()
+		}
+	}
+}
+
+// Emit queues event for every enabled webhook subscribed to it. It is safe
+// to call on a nil service.
+func (s *WebhookService) Emit(event string, data map[string]interface{}) {
+	if s == nil || s.db == nil {
+		return
+	}
+
+	ctx := context.Background()
+	webhooks, err := s.db.GetWebhooks(ctx)
+	if err != nil {
+		slog.Error("Webhooks: failed to load webhooks", "event", event, "error", err)
+		return
+	}
+
+	queued := false
+	for _, wh := range webhooks {
+		if !wh.Enabled || !webhookSubscribed(wh.Events, event) {
+			continue
+		}
+		if _, err := s.enqueue(ctx, wh.ID, event, data); err != nil {
+			slog.Error("Webhooks: failed to queue delivery", "event", event, "webhook_id", wh.ID, "error", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		s.wake()
+	}
+}
+
+// SendTest queues a webhook.test delivery for a single webhook.
+func (s *WebhookService) SendTest(ctx context.Context, webhookID int64) (int64, error) {
+	id, err := s.enqueue(ctx, webhookID, WebhookEventTest, map[string]interface{}{
+		"message": "Test delivery from roostr",
+	})
+	if err == nil {
+		s.wake()
+	}
+	return id, err
+}
+
+// enqueue stores a delivery with a freshly built payload.
+func (s *WebhookService) enqueue(ctx context.Context, webhookID int64, event string, data map[string]interface{}) (int64, error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	idBytes := make([]byte, 16)
+	rand.Read(idBytes)
+
+	payload, err := json.Marshal(WebhookPayload{
+		ID:        hex.EncodeToString(idBytes),
+		Event:     event,
+		CreatedAt: time.Now().Unix(),
+		Data:      data,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	return s.db.CreateWebhookDelivery(ctx, webhookID, event, string(payload))
+}
+
+// wake nudges the worker without blocking.
+func (s *WebhookService) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue attempts all deliveries whose next attempt is due.
+func (s *WebhookService) deliverDue() {
+	ctx := context.Background()
+
+	deliveries, err := s.db.GetDueWebhookDeliveries(ctx, time.Now(), webhookBatchSize)
+	if err != nil {
+		slog.Error("Webhooks: failed to get due deliveries", "error", err)
+		return
+	}
+
+	webhooks := make(map[int64]*db.Webhook)
+	for _, del := range deliveries {
+		wh, ok := webhooks[del.WebhookID]
+		if !ok {
+			wh, err = s.db.GetWebhook(ctx, del.WebhookID)
+			if err != nil {
+				slog.Error("Webhooks: failed to load webhook", "webhook_id", del.WebhookID, "error", err)
+				continue
+			}
+			webhooks[del.WebhookID] = wh
+		}
+
+		if wh == nil || !wh.Enabled {
+			s.db.RecordWebhookAttempt(ctx, del.ID, "failed", 0, "webhook disabled or deleted", time.Now())
+			continue
+		}
+
+		s.attempt(ctx, wh, del)
+	}
+}
+
+// attempt POSTs a delivery and records the outcome, scheduling a retry on failure.
+func (s *WebhookService) attempt(ctx context.Context, wh *db.Webhook, del db.WebhookDelivery) {
+	statusCode, err := s.post(ctx, wh, del)
+	if err == nil {
+		s.db.RecordWebhookAttempt(ctx, del.ID, "delivered", statusCode, "", time.Now())
+		return
+	}
+
+	attempts := del.Attempts + 1
+	if attempts >= webhookMaxAttempts {
+		slog.Warn("Webhooks: delivery failed permanently", "delivery_id", del.ID, "url", wh.URL, "error", err)
+		s.db.RecordWebhookAttempt(ctx, del.ID, "failed", statusCode, err.Error(), time.Now())
+		return
+	}
+
+	s.db.RecordWebhookAttempt(ctx, del.ID, "pending", statusCode, err.Error(), time.Now().Add(webhookBackoff(attempts)))
+}
+
+// post sends a signed delivery. Any 2xx response counts as delivered.
+func (s *WebhookService) post(ctx context.Context, wh *db.Webhook, del db.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", wh.URL, bytes.NewReader([]byte(del.Payload)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "roostr-webhooks/1")
+	req.Header.Set("X-Roostr-Event", del.Event)
+	req.Header.Set("X-Roostr-Delivery", strconv.FormatInt(del.ID, 10))
+	req.Header.Set("X-Roostr-Timestamp", timestamp)
+	req.Header.Set("X-Roostr-Signature", "sha256="+SignWebhookPayload(wh.Secret, timestamp, []byte(del.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// checkStorage emits storage.critical when disk usage crosses the critical
+// threshold, once per crossing.
+func (s *WebhookService) checkStorage() {
+	total, err := s.db.GetTotalDiskSpace()
+	if err != nil || total <= 0 {
+		return
+	}
+	available, err := s.db.GetAvailableDiskSpace()
+	if err != nil {
+		return
+	}
+
+	usagePercent := float64(total-available) / float64(total) * 100
+	critical := usagePercent >= storageCriticalPercent
+	if critical && !s.storageCritical {
+		s.Emit(WebhookEventStorageCritical, map[string]interface{}{
+			"usage_percent":   usagePercent,
+			"available_bytes": available,
+			"total_bytes":     total,
+		})
+	}
+	s.storageCritical = critical
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of "timestamp.body" keyed
+// by secret, as sent in X-Roostr-Signature.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateWebhookSecret returns a random signing secret.
+func GenerateWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// IsWebhookEvent reports whether event can be subscribed to ("*" means all).
+func IsWebhookEvent(event string) bool {
+	if event == "*" {
+		return true
+	}
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookSubscribed reports whether a subscription list includes event.
+func webhookSubscribed(events []string, event string) bool {
+	for _, e := range events {
+		if e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookBackoff returns the retry delay after the given number of attempts.
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+)
+
+func TestWebhookBackoff(t *testing.T) {
+	if got := webhookBackoff(1); got != 30*time.Second {
+		t.Errorf("expected 30s after first attempt, got %s", got)
+	}
+	if got := webhookBackoff(3); got != 2*time.Minute {
+		t.Errorf("expected 2m after third attempt, got %s", got)
+	}
+	if got := webhookBackoff(20); got != webhookMaxBackoff {
+		t.Errorf("expected backoff capped at %s, got %s", webhookMaxBackoff, got)
+	}
+}
+
+func TestWebhookSubscribed(t *testing.T) {
+	if !webhookSubscribed([]string{"*"}, WebhookEventInvoicePaid) {
+		t.Error("expected * to match every event")
+	}
+	if webhookSubscribed([]string{WebhookEventUserExpired}, WebhookEventInvoicePaid) {
+		t.Error("expected unrelated event not to match")
+	}
+	if !IsWebhookEvent(WebhookEventStorageCritical) || IsWebhookEvent("user.deleted") {
+		t.Error("unexpected IsWebhookEvent result")
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	var gotPayload WebhookPayload
+	var signatureOK bool
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		expected := "sha256=" + SignWebhookPayload("s3cret", r.Header.Get("X-Roostr-Timestamp"), body)
+		signatureOK = r.Header.Get("X-Roostr-Signature") == expected
+		json.Unmarshal(body, &gotPayload)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	wh := &db.Webhook{URL: server.URL, Secret: "s3cret", Events: []string{WebhookEventInvoicePaid}, Enabled: true}
+	if err := database.CreateWebhook(ctx, wh); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	other := &db.Webhook{URL: server.URL, Secret: "x", Events: []string{WebhookEventUserExpired}, Enabled: true}
+	database.CreateWebhook(ctx, other)
+
+	svc := NewWebhookService(database)
+
+	t.Run("delivered_and_signed", func(t *testing.T) {
+		svc.Emit(WebhookEventInvoicePaid, map[string]interface{}{"pubkey": "abc"})
+		svc.deliverDue()
+
+		if !signatureOK {
+			t.Error("expected valid signature")
+		}
+		if gotPayload.Event != WebhookEventInvoicePaid || gotPayload.Data["pubkey"] != "abc" {
+			t.Errorf("unexpected payload: %+v", gotPayload)
+		}
+
+		log, _ := database.GetWebhookDeliveries(ctx, wh.ID, 10)
+		if len(log) != 1 || log[0].Status != "delivered" {
+			t.Errorf("expected one delivered delivery, got %+v", log)
+		}
+		if otherLog, _ := database.GetWebhookDeliveries(ctx, other.ID, 10); len(otherLog) != 0 {
+			t.Error("expected unsubscribed webhook to receive nothing")
+		}
+	})
+
+	t.Run("failure_schedules_retry", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		svc.Emit(WebhookEventInvoicePaid, nil)
+		svc.deliverDue()
+
+		log, _ := database.GetWebhookDeliveries(ctx, wh.ID, 1)
+		if len(log) != 1 || log[0].Status != "pending" || log[0].Attempts != 1 || log[0].LastStatusCode != 500 {
+			t.Fatalf("expected pending retry, got %+v", log)
+		}
+		if !log[0].NextAttemptAt.After(time.Now()) {
+			t.Error("expected next attempt in the future")
+		}
+	})
+
+	t.Run("gives_up_after_max_attempts", func(t *testing.T) {
+		log, _ := database.GetWebhookDeliveries(ctx, wh.ID, 1)
+		svc.attempt(ctx, wh, db.WebhookDelivery{ID: log[0].ID, Event: log[0].Event, Payload: log[0].Payload, Attempts: webhookMaxAttempts - 1})
+
+		log, _ = database.GetWebhookDeliveries(ctx, wh.ID, 1)
+		if log[0].Status != "failed" {
+			t.Errorf("expected failed status, got %s", log[0].Status)
+		}
+	})
+}
+
+func TestWebhookEmitNilService(t *testing.T) {
+	var svc *WebhookService
+	svc.Emit(WebhookEventInvoicePaid, nil) // must not panic
+}
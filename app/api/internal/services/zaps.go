@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// Zap event kinds (NIP-57).
+const (
+	ZapRequestKind = 9734
+	ZapReceiptKind = 9735
+)
+
+// Zap invoices stay payable for 15 minutes, like signup invoices.
+const zapInvoiceExpirySecs = 900
+
+// maxZapReceiptRelays bounds how many of a zap request's relays the receipt
+// is published to.
+const maxZapReceiptRelays = 10
+
+// Errors returned when a zap cannot pay for access.
+var (
+	ErrZapsDisabled      = errors.New("no zappable Lightning address by this name")
+	ErrInvalidZapRequest = errors.New("invalid zap request")
+	ErrZapAmount         = errors.New("zap amount does not match the price of a pricing tier")
+)
+
+// LNURLPayParams is the LNURL-pay response for the relay's Lightning address,
+// with the NIP-57 fields that let wallets zap it.
+type LNURLPayParams struct {
+	Tag         string `json:"tag"`
+	Callback    string `json:"callback"`
+	MinSendable int64  `json:"minSendable"` // millisats
+	MaxSendable int64  `json:"maxSendable"` // millisats
+	Metadata    string `json:"metadata"`
+	AllowsNostr bool   `json:"allowsNostr"`
+	NostrPubkey string `json:"nostrPubkey"`
+}
+
+// ZapService lets users pay for access by zapping the relay's Lightning
+// address. A zap whose amount is a pricing tier's price whitelists the zap
+// sender for that tier once paid, and a zap receipt signed by the
+// notification key is published to the relays the zap request names.
+type ZapService struct {
+	db        *db.DB
+	lightning *LightningService
+	notifier  *Notifier
+	publish   func(ctx context.Context, url string, event *nostr.SyncEvent) error
+	now       func() time.Time
+}
+
+// NewZapService creates a new zap service.
+func NewZapService(database *db.DB, lightning *LightningService, notifier *Notifier) *ZapService {
+	return &ZapService{
+		db:        database,
+		lightning: lightning,
+		notifier:  notifier,
+		publish:   publishToRelay,
+		now:       time.Now,
+	}
+}
+
+// PayParams returns the LNURL-pay parameters for the Lightning address
+// name@host. Wallets request invoices from callback.
+func (s *ZapService) PayParams(ctx context.Context, name, host, callback string) (*LNURLPayParams, error) {
+	if err := s.checkAddress(ctx, name); err != nil {
+		return nil, err
+	}
+
+	tiers, err := s.tiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(tiers) == 0 {
+		return nil, ErrZapAmount
+	}
+	minSats, maxSats := tiers[0].AmountSats, tiers[0].AmountSats
+	for _, t := range tiers {
+		minSats = min(minSats, t.AmountSats)
+		maxSats = max(maxSats, t.AmountSats)
+	}
+
+	pubkey, err := s.notifier.Pubkey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata, _ := json.Marshal([][]string{
+		{"text/plain", "Relay access"},
+		{"text/identifier", name + "@" + host},
+	})
+
+	return &LNURLPayParams{
+		Tag:         "payRequest",
+		Callback:    callback,
+		MinSendable: minSats * 1000,
+		MaxSendable: maxSats * 1000,
+		Metadata:    string(metadata),
+		AllowsNostr: true,
+		NostrPubkey: pubkey,
+	}, nil
+}
+
+// Invoice checks a zap request for amountMsats and creates an invoice whose
+// description hash commits to it. The invoice is tracked as a pending access
+// invoice for the zap sender and the tier priced at the amount.
+func (s *ZapService) Invoice(ctx context.Context, name string, amountMsats int64, zapRequest string) (*Invoice, error) {
+	if err := s.checkAddress(ctx, name); err != nil {
+		return nil, err
+	}
+
+	recipient, err := s.notifier.Pubkey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	request, err := ParseZapRequest(zapRequest, recipient, amountMsats)
+	if err != nil {
+		return nil, err
+	}
+
+	tier, err := s.tierFor(ctx, amountMsats)
+	if err != nil {
+		return nil, err
+	}
+	npub, err := nostr.EncodeNpub(request.Pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidZapRequest, err)
+	}
+
+	invoice, err := s.lightning.CreateDescriptionInvoice(ctx, tier.AmountSats, zapRequest, zapInvoiceExpirySecs)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &db.PendingInvoice{
+		PaymentHash:    invoice.PaymentHash,
+		Pubkey:         request.Pubkey,
+		Npub:           npub,
+		TierID:         tier.ID,
+		AmountSats:     tier.AmountSats,
+		PaymentRequest: invoice.PaymentRequest,
+		Memo:           fmt.Sprintf("Roostr %s access zap", tier.Name),
+		ExpiresAt:      invoice.ExpiresAt,
+	}
+	if err := s.db.CreatePendingInvoice(ctx, pending); err != nil {
+		return nil, fmt.Errorf("failed to store pending invoice: %w", err)
+	}
+	if err := s.db.AddZapRequest(ctx, &db.ZapRequest{
+		PaymentHash:    invoice.PaymentHash,
+		Pubkey:         request.Pubkey,
+		ZapRequest:     zapRequest,
+		PaymentRequest: invoice.PaymentRequest,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store zap request: %w", err)
+	}
+
+	return invoice, nil
+}
+
+// PublishReceipt publishes the zap receipt for a paid invoice, if the invoice
+// was a zap whose receipt has not been published yet.
+func (s *ZapService) PublishReceipt(ctx context.Context, paymentHash string) error {
+	zap, err := s.db.GetZapRequest(ctx, paymentHash)
+	if err != nil || zap == nil || zap.ReceiptID != "" {
+		return err
+	}
+
+	var request nostr.SyncEvent
+	if err := json.Unmarshal([]byte(zap.ZapRequest), &request); err != nil {
+		return fmt.Errorf("failed to parse stored zap request: %w", err)
+	}
+
+	sender, err := s.notifier.sender(ctx)
+	if err != nil {
+		return err
+	}
+	receipt := nostr.NewEvent(ZapReceiptKind, zapReceiptTags(&request, zap), "")
+	receipt.CreatedAt = s.now().Unix()
+	if err := sender.sign(ctx, receipt); err != nil {
+		return err
+	}
+
+	relays := zapRelays(&request)
+	if len(relays) == 0 {
+		relays = SyncRelayURLs(ctx, s.db)
+	}
+	var errs []error
+	published := 0
+	for _, url := range relays {
+		if err := s.publish(ctx, url, receipt); err != nil {
+			slog.WarnContext(ctx, "Failed to publish zap receipt", "relay", url, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			continue
+		}
+		published++
+	}
+	if published == 0 {
+		return fmt.Errorf("zap receipt was not published: %w", errors.Join(errs...))
+	}
+
+	return s.db.SetZapReceipt(ctx, paymentHash, receipt.ID)
+}
+
+// ParseZapRequest parses and checks a zap request (kind 9734) as NIP-57
+// requires of the recipient's LNURL server: it must be signed, zap exactly
+// recipient and, if it states an amount, be for amountMsats.
+func ParseZapRequest(zapRequest, recipient string, amountMsats int64) (*nostr.SyncEvent, error) {
+	var event nostr.SyncEvent
+	if err := json.Unmarshal([]byte(zapRequest), &event); err != nil {
+		return nil, fmt.Errorf("%w: not a Nostr event", ErrInvalidZapRequest)
+	}
+	if event.Kind != ZapRequestKind {
+		return nil, fmt.Errorf("%w: kind must be %d", ErrInvalidZapRequest, ZapRequestKind)
+	}
+	if err := event.Verify(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidZapRequest, err)
+	}
+
+	var pTags, eTags []string
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "p":
+			pTags = append(pTags, tag[1])
+		case "e":
+			eTags = append(eTags, tag[1])
+		case "amount":
+			amount, err := strconv.ParseInt(tag[1], 10, 64)
+			if err != nil || amount != amountMsats {
+				return nil, fmt.Errorf("%w: amount tag does not match the amount paid", ErrInvalidZapRequest)
+			}
+		}
+	}
+	if len(pTags) != 1 || pTags[0] != recipient {
+		return nil, fmt.Errorf("%w: must have one p tag for the relay's zap key", ErrInvalidZapRequest)
+	}
+	if len(eTags) > 1 {
+		return nil, fmt.Errorf("%w: must have at most one e tag", ErrInvalidZapRequest)
+	}
+	return &event, nil
+}
+
+// zapReceiptTags returns the tags of the receipt for a paid zap request.
+func zapReceiptTags(request *nostr.SyncEvent, zap *db.ZapRequest) [][]string {
+	var tags [][]string
+	for _, tag := range request.Tags {
+		if len(tag) >= 2 && (tag[0] == "p" || tag[0] == "e" || tag[0] == "a") {
+			tags = append(tags, []string{tag[0], tag[1]})
+		}
+	}
+	return append(tags,
+		[]string{"P", request.Pubkey},
+		[]string{"bolt11", zap.PaymentRequest},
+		[]string{"description", zap.ZapRequest},
+	)
+}
+
+// zapRelays returns the relays a zap request asks for its receipt on.
+func zapRelays(request *nostr.SyncEvent) []string {
+	var relays []string
+	for _, tag := range request.Tags {
+		if len(tag) == 0 || tag[0] != "relays" {
+			continue
+		}
+		for _, url := range tag[1:] {
+			if (strings.HasPrefix(url, "wss://") || strings.HasPrefix(url, "ws://")) && len(relays) < maxZapReceiptRelays {
+				relays = append(relays, url)
+			}
+		}
+	}
+	return relays
+}
+
+// checkAddress returns ErrZapsDisabled unless zaps pay for access and name
+// is the relay's Lightning address.
+func (s *ZapService) checkAddress(ctx context.Context, name string) error {
+	accessMode, err := s.db.GetAccessMode(ctx)
+	if err != nil {
+		return err
+	}
+	settings, err := s.db.GetZapSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if accessMode != "paid" || !settings.Enabled || !strings.EqualFold(name, settings.Username) {
+		return ErrZapsDisabled
+	}
+	return nil
+}
+
+// tiers returns the enabled pricing tiers a zap can pay for.
+func (s *ZapService) tiers(ctx context.Context) ([]db.PricingTier, error) {
+	all, err := s.db.GetPricingTiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tiers []db.PricingTier
+	for _, t := range all {
+		if t.Enabled && t.AmountSats > 0 {
+			tiers = append(tiers, t)
+		}
+	}
+	return tiers, nil
+}
+
+// tierFor returns the tier priced at amountMsats.
+func (s *ZapService) tierFor(ctx context.Context, amountMsats int64) (*db.PricingTier, error) {
+	tiers, err := s.tiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tiers {
+		if t.AmountSats*1000 == amountMsats {
+			return &t, nil
+		}
+	}
+	return nil, ErrZapAmount
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/roostr/roostr/app/api/internal/db"
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// signedZapRequest returns a zap request JSON signed by a new key, and the
+// sender's pubkey.
+func signedZapRequest(t *testing.T, tags [][]string) (string, string) {
+	t.Helper()
+	secret, err := nostr.GenerateSecretKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretKey() error = %v", err)
+	}
+	event := nostr.NewEvent(ZapRequestKind, tags, "")
+	if err := event.Sign(secret); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	data, _ := json.Marshal(event)
+	return string(data), event.Pubkey
+}
+
+func TestParseZapRequest(t *testing.T) {
+	recipient := "aa00000000000000000000000000000000000000000000000000000000000001"
+	other := "bb00000000000000000000000000000000000000000000000000000000000002"
+
+	valid, _ := signedZapRequest(t, [][]string{{"p", recipient}, {"amount", "5000000"}, {"relays", "wss://relay.example"}})
+	if _, err := ParseZapRequest(valid, recipient, 5000000); err != nil {
+		t.Errorf("expected valid zap request, got %v", err)
+	}
+
+	tampered := map[string]interface{}{}
+	json.Unmarshal([]byte(valid), &tampered)
+	tampered["content"] = "changed"
+	tamperedJSON, _ := json.Marshal(tampered)
+
+	noAmount, _ := signedZapRequest(t, [][]string{{"p", recipient}})
+	wrongRecipient, _ := signedZapRequest(t, [][]string{{"p", other}})
+	twoRecipients, _ := signedZapRequest(t, [][]string{{"p", recipient}, {"p", other}})
+	twoEvents, _ := signedZapRequest(t, [][]string{{"p", recipient}, {"e", "01"}, {"e", "02"}})
+
+	tests := []struct {
+		name    string
+		request string
+		amount  int64
+		ok      bool
+	}{
+		{"amount tag is optional", noAmount, 1000, true},
+		{"amount mismatch", valid, 1000, false},
+		{"bad signature", string(tamperedJSON), 5000000, false},
+		{"not an event", "zap", 1000, false},
+		{"wrong recipient", wrongRecipient, 1000, false},
+		{"two p tags", twoRecipients, 1000, false},
+		{"two e tags", twoEvents, 1000, false},
+	}
+	for _, tt := range tests {
+		_, err := ParseZapRequest(tt.request, recipient, tt.amount)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidZapRequest) {
+			t.Errorf("%s: expected ErrInvalidZapRequest, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestZapService(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	lightning := NewLightningService(database)
+	lightning.Configure(&LNDConfig{NodeType: NodeTypeMock, Host: "settle_after=-1"})
+	notifier := NewNotifier(database, nil)
+	zaps := NewZapService(database, lightning, notifier)
+
+	var published []string
+	var receipt *nostr.SyncEvent
+	zaps.publish = func(ctx context.Context, url string, event *nostr.SyncEvent) error {
+		published = append(published, url)
+		receipt = event
+		return nil
+	}
+
+	if _, err := zaps.PayParams(ctx, "relay", "example.com", "https://example.com/public/lnurlp/relay"); !errors.Is(err, ErrZapsDisabled) {
+		t.Fatalf("expected ErrZapsDisabled before zaps are enabled, got %v", err)
+	}
+	database.SetAccessMode(ctx, "paid")
+	database.SetZapSettings(ctx, &db.ZapSettings{Enabled: true, Username: "relay"})
+
+	params, err := zaps.PayParams(ctx, "relay", "example.com", "https://example.com/public/lnurlp/relay")
+	if err != nil {
+		t.Fatalf("PayParams() error = %v", err)
+	}
+	recipient, _ := notifier.Pubkey(ctx)
+	if !params.AllowsNostr || params.NostrPubkey != recipient || params.Tag != "payRequest" || params.MinSendable > 5000000 {
+		t.Errorf("unexpected pay params %+v", params)
+	}
+	if _, err := zaps.PayParams(ctx, "someone", "example.com", ""); !errors.Is(err, ErrZapsDisabled) {
+		t.Errorf("expected ErrZapsDisabled for another name, got %v", err)
+	}
+
+	request, sender := signedZapRequest(t, [][]string{
+		{"p", recipient}, {"e", "0e"}, {"amount", "5000000"}, {"relays", "wss://one.example", "https://not.a.relay"},
+	})
+	if _, err := zaps.Invoice(ctx, "relay", 1234000, request); !errors.Is(err, ErrInvalidZapRequest) {
+		t.Errorf("expected ErrInvalidZapRequest for a mismatched amount, got %v", err)
+	}
+	invoice, err := zaps.Invoice(ctx, "relay", 5000000, request)
+	if err != nil {
+		t.Fatalf("Invoice() error = %v", err)
+	}
+
+	pending, _ := database.GetPendingInvoice(ctx, invoice.PaymentHash)
+	if pending == nil || pending.Pubkey != sender || pending.TierID != "monthly" || pending.AmountSats != 5000 {
+		t.Fatalf("unexpected pending invoice %+v", pending)
+	}
+
+	// Paying whitelists the zap sender like a signup payment
+	monitor := NewInvoiceMonitorService(database, lightning, nil, nil)
+	if err := monitor.ProcessPayment(ctx, invoice.PaymentHash); err != nil {
+		t.Fatalf("ProcessPayment() error = %v", err)
+	}
+	if user, _ := database.GetPaidUserByPubkey(ctx, sender); user == nil || user.Status != "active" {
+		t.Errorf("expected zap sender to be a paid user, got %+v", user)
+	}
+
+	if err := zaps.PublishReceipt(ctx, invoice.PaymentHash); err != nil {
+		t.Fatalf("PublishReceipt() error = %v", err)
+	}
+	if len(published) != 1 || published[0] != "wss://one.example" {
+		t.Errorf("expected receipt on the zap request's relay, published to %v", published)
+	}
+	if receipt.Kind != ZapReceiptKind || receipt.Pubkey != recipient || receipt.Verify() != nil {
+		t.Errorf("unexpected receipt %+v", receipt)
+	}
+	want := map[string]string{"p": recipient, "e": "0e", "P": sender, "bolt11": invoice.PaymentRequest, "description": request}
+	for _, tag := range receipt.Tags {
+		if want[tag[0]] == tag[1] {
+			delete(want, tag[0])
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("receipt is missing tags %v", want)
+	}
+
+	zap, _ := database.GetZapRequest(ctx, invoice.PaymentHash)
+	if zap == nil || zap.ReceiptID != receipt.ID {
+		t.Errorf("expected receipt ID recorded, got %+v", zap)
+	}
+	if err := zaps.PublishReceipt(ctx, invoice.PaymentHash); err != nil || len(published) != 1 {
+		t.Errorf("expected the receipt to be published once, got %v after %d publishes", err, len(published))
+	}
+}
@@ -0,0 +1,562 @@
+// Package testutil generates realistic relay databases for integration
+// tests, benchmarks and local development.
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+// FixtureEventsEnv overrides the default number of events NewRelayDB
+// generates, to run the same tests and benchmarks against a large dataset.
+const FixtureEventsEnv = "ROOSTR_FIXTURE_EVENTS"
+
+// relaySchema is nostr-rs-relay's event and tag tables with their indexes.
+const relaySchema = `
+CREATE TABLE IF NOT EXISTS event (
+	id INTEGER PRIMARY KEY,
+	event_hash BLOB NOT NULL,
+	first_seen INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER,
+	author BLOB NOT NULL,
+	delegated_by BLOB,
+	kind INTEGER NOT NULL,
+	hidden INTEGER DEFAULT 0,
+	content TEXT NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS event_hash_index ON event(event_hash);
+CREATE INDEX IF NOT EXISTS author_index ON event(author);
+CREATE INDEX IF NOT EXISTS kind_index ON event(kind);
+CREATE INDEX IF NOT EXISTS created_at_index ON event(created_at);
+CREATE INDEX IF NOT EXISTS kind_author_index ON event(kind, author);
+CREATE INDEX IF NOT EXISTS kind_created_at_index ON event(kind, created_at);
+CREATE INDEX IF NOT EXISTS author_created_at_index ON event(author, created_at);
+CREATE INDEX IF NOT EXISTS event_expiration ON event(expires_at);
+CREATE TABLE IF NOT EXISTS tag (
+	id INTEGER PRIMARY KEY,
+	event_id INTEGER NOT NULL,
+	name TEXT,
+	value TEXT,
+	value_hex BLOB
+);
+CREATE INDEX IF NOT EXISTS tag_val_index ON tag(value);
+CREATE INDEX IF NOT EXISTS tag_val_hex_index ON tag(value_hex);
+CREATE INDEX IF NOT EXISTS tag_composite_index ON tag(event_id, name, value, value_hex);
+`
+
+// insertBatch is how many events are written per transaction.
+const insertBatch = 1000
+
+// RelayOptions shapes a generated relay database. Zero values get the
+// defaults noted on each field.
+type RelayOptions struct {
+	Authors int       // Distinct authors; default 100
+	Events  int       // Total events; default 10000, or FixtureEventsEnv for NewRelayDB
+	Days    int       // How far back events go from Now; default 30
+	Seed    int64     // Seed for the generator; the same options give the same database. Default 1
+	Now     time.Time // Newest possible created_at; default the current time
+	Sign    bool      // Sign events with the authors' keys; slower, and sigs are random otherwise
+}
+
+func (o RelayOptions) withDefaults() RelayOptions {
+	if o.Authors <= 0 {
+		o.Authors = 100
+	}
+	if o.Events <= 0 {
+		o.Events = 10000
+	}
+	if o.Days <= 0 {
+		o.Days = 30
+	}
+	if o.Seed == 0 {
+		o.Seed = 1
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	return o
+}
+
+// RelayFixture describes a generated relay database.
+type RelayFixture struct {
+	Path     string
+	Authors  []string       // Hex pubkeys, most active first
+	Secrets  []string       // Hex secret keys, matching Authors
+	Events   int            // Events written
+	Kinds    map[int]int    // Events per kind
+	ByAuthor map[string]int // Events per author
+	Replies  int            // Kind 1 notes replying to another note
+	Tags     int            // Rows in the tag table
+	Oldest   time.Time
+	Newest   time.Time
+}
+
+// NewRelayDB writes a relay database in a temporary directory that is
+// removed when the test ends. With opts.Events unset, FixtureEventsEnv
+// picks the size.
+func NewRelayDB(tb testing.TB, opts RelayOptions) *RelayFixture {
+	tb.Helper()
+
+	if opts.Events <= 0 {
+		if n, err := strconv.Atoi(os.Getenv(FixtureEventsEnv)); err == nil && n > 0 {
+			opts.Events = n
+		}
+	}
+	fixture, err := WriteRelayDB(context.Background(), filepath.Join(tb.TempDir(), "nostr.db"), opts)
+	if err != nil {
+		tb.Fatalf("failed to generate relay database: %v", err)
+	}
+	return fixture
+}
+
+// WriteRelayDB generates a relay database at path, which must not exist.
+// Every author has a profile and a follow list; the other events are a mix
+// of notes, reply threads, reactions, reposts, DMs, gift wraps, zaps,
+// long-form articles, reports and deletions, with a few authors posting most
+// of them.
+func WriteRelayDB(ctx context.Context, path string, opts RelayOptions) (*RelayFixture, error) {
+	opts = opts.withDefaults()
+
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	conn, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_synchronous=OFF")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open relay database: %w", err)
+	}
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+	if _, err := conn.ExecContext(ctx, relaySchema); err != nil {
+		return nil, fmt.Errorf("failed to create relay schema: %w", err)
+	}
+
+	g := newGenerator(opts)
+	w := &relayWriter{conn: conn, firstSeen: opts.Now.Unix()}
+	for i := 0; i < opts.Events; i++ {
+		if err := w.write(ctx, g.next(i)); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.flush(ctx); err != nil {
+		return nil, err
+	}
+
+	g.fixture.Path = path
+	g.fixture.Tags = w.tags
+	return g.fixture, nil
+}
+
+// author is a generated identity.
+type author struct {
+	pubkey string
+	secret *btcec.PrivateKey
+}
+
+// note is a kind 1 event that later events can reply to or react to.
+type note struct {
+	id     string
+	pubkey string
+	root   string // Thread root, or "" if the note starts a thread
+}
+
+// generator produces the events of a fixture in created_at order.
+type generator struct {
+	opts    RelayOptions
+	rng     *rand.Rand
+	authors []author
+	zapper  author
+	pick    *rand.Zipf
+	notes   []note // Recent notes, oldest first
+	start   int64
+	span    int64
+	fixture *RelayFixture
+}
+
+func newGenerator(opts RelayOptions) *generator {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	g := &generator{
+		opts: opts,
+		rng:  rng,
+		pick: rand.NewZipf(rng, 1.3, 2, uint64(opts.Authors-1)),
+		fixture: &RelayFixture{
+			Kinds:    make(map[int]int),
+			ByAuthor: make(map[string]int),
+		},
+	}
+	g.start = opts.Now.Add(-time.Duration(opts.Days) * 24 * time.Hour).Unix()
+	g.span = opts.Now.Unix() - g.start
+
+	for i := 0; i <= opts.Authors; i++ {
+		secret := make([]byte, 32)
+		rng.Read(secret)
+		priv, _ := btcec.PrivKeyFromBytes(secret)
+		a := author{pubkey: hex.EncodeToString(schnorr.SerializePubKey(priv.PubKey())), secret: priv}
+		if i == opts.Authors {
+			// Zap receipts come from a wallet service, not a member
+			g.zapper = a
+			continue
+		}
+		g.authors = append(g.authors, a)
+		g.fixture.Authors = append(g.fixture.Authors, a.pubkey)
+		g.fixture.Secrets = append(g.fixture.Secrets, hex.EncodeToString(secret))
+	}
+	return g
+}
+
+// next returns the i-th event. The first events are each author's profile
+// and follow list; the rest are spread evenly over the time window.
+func (g *generator) next(i int) *nostr.SyncEvent {
+	var e *nostr.SyncEvent
+	var a author
+	switch {
+	case i < len(g.authors):
+		a = g.authors[i]
+		e = g.profile(i)
+	case i < 2*len(g.authors):
+		a = g.authors[i-len(g.authors)]
+		e = g.followList()
+	default:
+		a = g.authors[g.pick.Uint64()]
+		e = g.activity(a)
+		if e.Kind == 9735 {
+			a = g.zapper
+		}
+	}
+
+	e.CreatedAt = g.start + g.span*int64(i)/int64(g.opts.Events) + g.rng.Int63n(60)
+	if e.CreatedAt > g.opts.Now.Unix() {
+		e.CreatedAt = g.opts.Now.Unix()
+	}
+	g.finish(e, a)
+
+	if e.Kind == 1 {
+		n := note{id: e.ID, pubkey: e.Pubkey}
+		for _, tag := range e.Tags {
+			if len(tag) >= 4 && tag[0] == "e" && tag[3] == "root" {
+				n.root = tag[1]
+				g.fixture.Replies++
+			}
+		}
+		g.notes = append(g.notes, n)
+		if len(g.notes) > 2000 {
+			g.notes = g.notes[1000:]
+		}
+	}
+
+	created := time.Unix(e.CreatedAt, 0)
+	if g.fixture.Oldest.IsZero() || created.Before(g.fixture.Oldest) {
+		g.fixture.Oldest = created
+	}
+	if created.After(g.fixture.Newest) {
+		g.fixture.Newest = created
+	}
+	g.fixture.Events++
+	g.fixture.Kinds[e.Kind]++
+	g.fixture.ByAuthor[e.Pubkey]++
+	return e
+}
+
+// activity returns an event of a kind picked by how common it is on a
+// typical community relay.
+func (g *generator) activity(a author) *nostr.SyncEvent {
+	roll := g.rng.Intn(100)
+	target, haveTarget := g.recentNote()
+	switch {
+	case roll < 40 || !haveTarget:
+		if haveTarget && g.rng.Intn(100) < 35 {
+			return g.reply(target)
+		}
+		return g.textNote()
+	case roll < 70:
+		return nostr.NewEvent(7, [][]string{{"e", target.id}, {"p", target.pubkey}}, g.oneOf("+", "+", "🤙", "❤️", "😂"))
+	case roll < 76:
+		return nostr.NewEvent(6, [][]string{{"e", target.id}, {"p", target.pubkey}}, "")
+	case roll < 81:
+		return nostr.NewEvent(4, [][]string{{"p", g.otherAuthor(a)}}, g.ciphertext()+"?iv="+g.ciphertext()[:24])
+	case roll < 83:
+		return nostr.NewEvent(1059, [][]string{{"p", g.otherAuthor(a)}}, g.ciphertext())
+	case roll < 87:
+		return g.zapReceipt(a, target)
+	case roll < 90:
+		return nostr.NewEvent(30023, [][]string{
+			{"d", "article-" + strconv.Itoa(g.rng.Intn(1000000))},
+			{"title", g.sentence(5)},
+			{"t", g.oneOf(hashtags...)},
+		}, g.paragraphs(4))
+	case roll < 92:
+		reason := g.oneOf("spam", "nudity", "impersonation", "other")
+		return nostr.NewEvent(1984, [][]string{{"p", target.pubkey, reason}, {"e", target.id, reason}}, "")
+	case roll < 93:
+		// Authors only delete their own notes
+		if own, ok := g.ownNote(a); ok {
+			return nostr.NewEvent(5, [][]string{{"e", own.id}, {"k", "1"}}, "")
+		}
+		return g.textNote()
+	default:
+		return nostr.NewEvent(1111, [][]string{
+			{"E", target.id, "", target.pubkey},
+			{"K", "1"},
+			{"P", target.pubkey},
+			{"e", target.id, "", target.pubkey},
+			{"k", "1"},
+			{"p", target.pubkey},
+		}, g.sentence(8))
+	}
+}
+
+func (g *generator) profile(i int) *nostr.SyncEvent {
+	name := g.oneOf(words...) + strconv.Itoa(i)
+	content, _ := json.Marshal(map[string]string{
+		"name":    name,
+		"about":   g.sentence(10),
+		"picture": "https://example.com/avatars/" + name + ".png",
+		"nip05":   name + "@example.com",
+	})
+	return nostr.NewEvent(0, nil, string(content))
+}
+
+func (g *generator) followList() *nostr.SyncEvent {
+	follows := 5 + g.rng.Intn(40)
+	if follows > len(g.authors) {
+		follows = len(g.authors)
+	}
+	var tags [][]string
+	for _, j := range g.rng.Perm(len(g.authors))[:follows] {
+		tags = append(tags, []string{"p", g.authors[j].pubkey})
+	}
+	return nostr.NewEvent(3, tags, "")
+}
+
+func (g *generator) textNote() *nostr.SyncEvent {
+	var tags [][]string
+	content := g.sentence(4 + g.rng.Intn(20))
+	if g.rng.Intn(5) == 0 {
+		tag := g.oneOf(hashtags...)
+		tags = append(tags, []string{"t", tag})
+		content += " #" + tag
+	}
+	if g.rng.Intn(10) == 0 {
+		tags = append(tags, []string{"p", g.authors[g.rng.Intn(len(g.authors))].pubkey})
+	}
+	return nostr.NewEvent(1, tags, content)
+}
+
+// reply answers target with NIP-10 marked "e" tags.
+func (g *generator) reply(target note) *nostr.SyncEvent {
+	root := target.root
+	if root == "" {
+		root = target.id
+	}
+	tags := [][]string{{"e", root, "", "root"}}
+	if root != target.id {
+		tags = append(tags, []string{"e", target.id, "", "reply"})
+	}
+	tags = append(tags, []string{"p", target.pubkey})
+	return nostr.NewEvent(1, tags, g.sentence(3+g.rng.Intn(12)))
+}
+
+func (g *generator) zapReceipt(sender author, target note) *nostr.SyncEvent {
+	sats := []int{21, 100, 500, 1000, 5000}[g.rng.Intn(5)]
+	request, _ := json.Marshal(map[string]interface{}{
+		"kind":   9734,
+		"pubkey": sender.pubkey,
+		"tags":   [][]string{{"p", target.pubkey}, {"e", target.id}, {"amount", strconv.Itoa(sats * 1000)}},
+	})
+	return nostr.NewEvent(9735, [][]string{
+		{"p", target.pubkey},
+		{"e", target.id},
+		{"P", sender.pubkey},
+		{"bolt11", fmt.Sprintf("lnbc%dn1p%s", sats*10, g.ciphertext()[:40])},
+		{"description", string(request)},
+	}, "")
+}
+
+// recentNote picks a note to react to, favoring recent ones.
+func (g *generator) recentNote() (note, bool) {
+	if len(g.notes) == 0 {
+		return note{}, false
+	}
+	n := len(g.notes)
+	window := 200
+	if window > n {
+		window = n
+	}
+	return g.notes[n-1-g.rng.Intn(window)], true
+}
+
+// ownNote picks the author's most recent note, if they have a recent one.
+func (g *generator) ownNote(a author) (note, bool) {
+	for i := len(g.notes) - 1; i >= 0; i-- {
+		if g.notes[i].pubkey == a.pubkey {
+			return g.notes[i], true
+		}
+	}
+	return note{}, false
+}
+
+func (g *generator) otherAuthor(a author) string {
+	for {
+		other := g.authors[g.rng.Intn(len(g.authors))]
+		if other.pubkey != a.pubkey || len(g.authors) == 1 {
+			return other.pubkey
+		}
+	}
+}
+
+// finish sets the author, ID and signature.
+func (g *generator) finish(e *nostr.SyncEvent, a author) {
+	e.Pubkey = a.pubkey
+	e.ID, _ = e.ComputeID()
+	if g.opts.Sign {
+		idBytes, _ := hex.DecodeString(e.ID)
+		if sig, err := schnorr.Sign(a.secret, idBytes); err == nil {
+			e.Sig = hex.EncodeToString(sig.Serialize())
+			return
+		}
+	}
+	sig := make([]byte, 64)
+	g.rng.Read(sig)
+	e.Sig = hex.EncodeToString(sig)
+}
+
+func (g *generator) oneOf(choices ...string) string {
+	return choices[g.rng.Intn(len(choices))]
+}
+
+func (g *generator) sentence(n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = words[g.rng.Intn(len(words))]
+	}
+	s := strings.Join(parts, " ")
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+func (g *generator) paragraphs(n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		sentences := make([]string, 3+g.rng.Intn(4))
+		for j := range sentences {
+			sentences[j] = g.sentence(8 + g.rng.Intn(12))
+		}
+		parts[i] = strings.Join(sentences, " ")
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (g *generator) ciphertext() string {
+	b := make([]byte, 48+g.rng.Intn(200))
+	g.rng.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// relayWriter inserts events and their single-letter tags the way
+// nostr-rs-relay stores them, in batches.
+type relayWriter struct {
+	conn      *sql.DB
+	firstSeen int64
+	pending   []*nostr.SyncEvent
+	tags      int
+}
+
+func (w *relayWriter) write(ctx context.Context, e *nostr.SyncEvent) error {
+	w.pending = append(w.pending, e)
+	if len(w.pending) >= insertBatch {
+		return w.flush(ctx)
+	}
+	return nil
+}
+
+func (w *relayWriter) flush(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	tx, err := w.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insertEvent, err := tx.PrepareContext(ctx, `
+		INSERT INTO event (event_hash, first_seen, created_at, author, kind, hidden, content)
+		VALUES (?, ?, ?, ?, ?, 0, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare event insert: %w", err)
+	}
+	defer insertEvent.Close()
+	insertTag, err := tx.PrepareContext(ctx, `INSERT INTO tag (event_id, name, value, value_hex) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare tag insert: %w", err)
+	}
+	defer insertTag.Close()
+
+	for _, e := range w.pending {
+		idBytes, _ := hex.DecodeString(e.ID)
+		authorBytes, _ := hex.DecodeString(e.Pubkey)
+		content, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to serialize event: %w", err)
+		}
+		result, err := insertEvent.ExecContext(ctx, idBytes, w.firstSeen, e.CreatedAt, authorBytes, e.Kind, string(content))
+		if err != nil {
+			return fmt.Errorf("failed to insert event: %w", err)
+		}
+		rowID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to insert event: %w", err)
+		}
+
+		for _, tag := range e.Tags {
+			if len(tag) < 2 || len(tag[0]) != 1 {
+				continue
+			}
+			// Lowercase hex values are stored as bytes, everything else as text
+			var value, valueHex interface{} = tag[1], nil
+			if b, err := hex.DecodeString(tag[1]); err == nil && tag[1] != "" && tag[1] == strings.ToLower(tag[1]) {
+				value, valueHex = nil, b
+			}
+			if _, err := insertTag.ExecContext(ctx, rowID, tag[0], value, valueHex); err != nil {
+				return fmt.Errorf("failed to insert tag: %w", err)
+			}
+			w.tags++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit events: %w", err)
+	}
+	w.pending = w.pending[:0]
+	return nil
+}
+
+var hashtags = []string{"nostr", "bitcoin", "zapathon", "photography", "plebchain", "grownostr", "music", "art", "foodstr", "introductions"}
+
+var words = []string{
+	"relay", "note", "zap", "sats", "lightning", "node", "key", "signal", "coffee", "morning",
+	"build", "ship", "open", "source", "freedom", "protocol", "client", "garden", "river", "mountain",
+	"music", "photo", "community", "friend", "weekend", "market", "block", "wallet", "privacy", "censorship",
+	"simple", "fast", "quiet", "bright", "today", "tomorrow", "really", "never", "always", "maybe",
+	"great", "small", "first", "last", "new", "old", "good", "better", "think", "love",
+}
@@ -0,0 +1,98 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/roostr/roostr/app/api/internal/nostr"
+)
+
+func TestWriteRelayDB(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	opts := RelayOptions{Authors: 20, Events: 2000, Days: 7, Now: now}
+	fixture := NewRelayDB(t, opts)
+
+	if fixture.Events != 2000 || len(fixture.Authors) != 20 {
+		t.Fatalf("unexpected fixture: %d events, %d authors", fixture.Events, len(fixture.Authors))
+	}
+	if fixture.Kinds[0] != 20 || fixture.Kinds[3] != 20 {
+		t.Errorf("expected a profile and follow list per author, got %v", fixture.Kinds)
+	}
+	for _, kind := range []int{1, 7, 6, 4, 1059, 9735, 30023, 1984} {
+		if fixture.Kinds[kind] == 0 {
+			t.Errorf("expected events of kind %d, got %v", kind, fixture.Kinds)
+		}
+	}
+	if fixture.Replies == 0 || fixture.Tags == 0 {
+		t.Errorf("expected threads and tags, got %d replies and %d tags", fixture.Replies, fixture.Tags)
+	}
+	if fixture.ByAuthor[fixture.Authors[0]] <= fixture.ByAuthor[fixture.Authors[19]] {
+		t.Errorf("expected the first author most active, got %v", fixture.ByAuthor)
+	}
+	if fixture.Newest.After(now) || fixture.Oldest.Before(now.Add(-7*24*time.Hour)) {
+		t.Errorf("events outside the window: %v to %v", fixture.Oldest, fixture.Newest)
+	}
+
+	conn, err := sql.Open("sqlite3", fixture.Path)
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer conn.Close()
+	var events, tags int
+	conn.QueryRow(`SELECT COUNT(*) FROM event`).Scan(&events)
+	conn.QueryRow(`SELECT COUNT(*) FROM tag`).Scan(&tags)
+	if events != fixture.Events || tags != fixture.Tags {
+		t.Errorf("database has %d events and %d tags, fixture says %d and %d", events, tags, fixture.Events, fixture.Tags)
+	}
+
+	// Stored events have valid IDs
+	var content string
+	conn.QueryRow(`SELECT content FROM event WHERE kind = 1 LIMIT 1`).Scan(&content)
+	var event nostr.SyncEvent
+	if err := json.Unmarshal([]byte(content), &event); err != nil {
+		t.Fatalf("failed to parse stored event: %v", err)
+	}
+	if err := event.VerifyID(); err != nil {
+		t.Errorf("stored event ID invalid: %v", err)
+	}
+
+	// The same options give the same database
+	again, err := WriteRelayDB(context.Background(), filepath.Join(t.TempDir(), "nostr.db"), opts)
+	if err != nil {
+		t.Fatalf("WriteRelayDB failed: %v", err)
+	}
+	if again.Authors[0] != fixture.Authors[0] || again.Replies != fixture.Replies || again.Tags != fixture.Tags {
+		t.Error("expected the same seed to generate the same database")
+	}
+	if _, err := WriteRelayDB(context.Background(), fixture.Path, opts); err == nil {
+		t.Error("expected an existing database not to be overwritten")
+	}
+}
+
+func TestWriteRelayDBSigned(t *testing.T) {
+	fixture := NewRelayDB(t, RelayOptions{Authors: 3, Events: 50, Sign: true})
+
+	conn, err := sql.Open("sqlite3", fixture.Path)
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer conn.Close()
+	rows, err := conn.Query(`SELECT content FROM event`)
+	if err != nil {
+		t.Fatalf("failed to read events: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var content string
+		rows.Scan(&content)
+		var event nostr.SyncEvent
+		json.Unmarshal([]byte(content), &event)
+		if err := event.Verify(); err != nil {
+			t.Errorf("event %s (kind %d) does not verify: %v", event.ID, event.Kind, err)
+		}
+	}
+}
@@ -9,11 +9,12 @@ const API_BASE = '/api/v1';
  * Custom API error with code.
  */
 export class ApiError extends Error {
-	constructor(message, code, status) {
+	constructor(message, code, status, details) {
 		super(message);
 		this.name = 'ApiError';
 		this.code = code;
 		this.status = status;
+		this.details = details;
 	}
 }
 
@@ -23,7 +24,7 @@ export class ApiError extends Error {
 async function parseError(res) {
 	try {
 		const data = await res.json();
-		return new ApiError(data.error || `HTTP ${res.status}`, data.code, res.status);
+		return new ApiError(data.error || `HTTP ${res.status}`, data.code, res.status, data.details);
 	} catch {
 		return new ApiError(`HTTP ${res.status}`, 'UNKNOWN', res.status);
 	}
@@ -40,15 +41,57 @@ export async function get(path) {
 	return res.json();
 }
 
+let csrfToken = null;
+let csrfExpiresAt = 0;
+
 /**
- * Make a POST request to the API.
+ * Get a CSRF token for state-changing requests, fetching a new one when
+ * none is cached or the cached one is about to expire.
+ * @param {boolean} [refresh] - Fetch a new token even if one is cached
+ * @returns {Promise<string>} CSRF token
+ */
+async function getCsrfToken(refresh = false) {
+	if (!refresh && csrfToken && Date.now() < csrfExpiresAt - 60_000) {
+		return csrfToken;
+	}
+	const data = await get('/csrf');
+	csrfToken = data.token;
+	csrfExpiresAt = new Date(data.expires_at).getTime();
+	return csrfToken;
+}
+
+/**
+ * fetch() for state-changing API requests. Adds the X-CSRF-Token header and
+ * retries once with a new token if the server rejects the cached one.
+ * @param {string} url - Request URL
+ * @param {RequestInit} init - fetch options
+ * @returns {Promise<Response>} Response
+ */
+export async function csrfFetch(url, init = {}) {
+	const send = async (refresh) => {
+		const headers = new Headers(init.headers);
+		headers.set('X-CSRF-Token', await getCsrfToken(refresh));
+		return fetch(url, { ...init, headers });
+	};
+
+	const res = await send(false);
+	if (res.status !== 403) return res;
+
+	const data = await res.clone().json().catch(() => null);
+	if (data?.code !== 'CSRF_INVALID') return res;
+	return send(true);
+}
+
+/**
+ * Make a request with a JSON body to the API.
+ * @param {string} method - HTTP method
  * @param {string} path - API path (without base)
  * @param {any} data - Request body
  * @returns {Promise<any>} Response data
  */
-export async function post(path, data) {
-	const res = await fetch(`${API_BASE}${path}`, {
-		method: 'POST',
+async function send(method, path, data) {
+	const res = await csrfFetch(`${API_BASE}${path}`, {
+		method,
 		headers: { 'Content-Type': 'application/json' },
 		body: JSON.stringify(data)
 	});
@@ -56,6 +99,16 @@ export async function post(path, data) {
 	return res.json();
 }
 
+/**
+ * Make a POST request to the API.
+ * @param {string} path - API path (without base)
+ * @param {any} data - Request body
+ * @returns {Promise<any>} Response data
+ */
+export async function post(path, data) {
+	return send('POST', path, data);
+}
+
 /**
  * Make a PUT request to the API.
  * @param {string} path - API path (without base)
@@ -63,13 +116,7 @@ export async function post(path, data) {
  * @returns {Promise<any>} Response data
  */
 export async function put(path, data) {
-	const res = await fetch(`${API_BASE}${path}`, {
-		method: 'PUT',
-		headers: { 'Content-Type': 'application/json' },
-		body: JSON.stringify(data)
-	});
-	if (!res.ok) throw await parseError(res);
-	return res.json();
+	return send('PUT', path, data);
 }
 
 /**
@@ -79,13 +126,7 @@ export async function put(path, data) {
  * @returns {Promise<any>} Response data
  */
 export async function patch(path, data) {
-	const res = await fetch(`${API_BASE}${path}`, {
-		method: 'PATCH',
-		headers: { 'Content-Type': 'application/json' },
-		body: JSON.stringify(data)
-	});
-	if (!res.ok) throw await parseError(res);
-	return res.json();
+	return send('PATCH', path, data);
 }
 
 /**
@@ -94,7 +135,7 @@ export async function patch(path, data) {
  * @returns {Promise<any>} Response data
  */
 export async function del(path) {
-	const res = await fetch(`${API_BASE}${path}`, { method: 'DELETE' });
+	const res = await csrfFetch(`${API_BASE}${path}`, { method: 'DELETE' });
 	if (!res.ok) throw await parseError(res);
 	return res.json();
 }
@@ -109,6 +150,7 @@ export const setup = {
 export const access = {
 	getMode: () => get('/access/mode'),
 	setMode: (mode) => put('/access/mode', { mode }),
+	simulate: (change, limit = 100) => post(`/access/simulate?limit=${limit}`, change),
 	getWhitelist: () => get('/access/whitelist'),
 	addToWhitelist: (data) => post('/access/whitelist', data),
 	bulkAddToWhitelist: (entries) => post('/access/whitelist/bulk', { entries }),
@@ -117,18 +159,27 @@ export const access = {
 	getBlacklist: () => get('/access/blacklist'),
 	addToBlacklist: (data) => post('/access/blacklist', data),
 	removeFromBlacklist: (pubkey) => del(`/access/blacklist/${pubkey}`),
+	getPolicies: () => get('/access/policies'),
+	updatePolicy: (tier, policy) => put(`/access/policies/${encodeURIComponent(tier)}`, policy),
+	deletePolicy: (tier) => del(`/access/policies/${encodeURIComponent(tier)}`),
+	getAdmission: () => get('/access/admission'),
 	resolveNip05: (identifier) => get(`/nip05/${encodeURIComponent(identifier)}`)
 };
 
+export const dashboard = {
+	get: (timezone = '') => get(`/dashboard${timezone ? `?timezone=${encodeURIComponent(timezone)}` : ''}`)
+};
+
 export const stats = {
 	getSummary: (timezone = '') => {
 		let url = '/stats/summary';
 		if (timezone) url += `?timezone=${encodeURIComponent(timezone)}`;
 		return get(url);
 	},
-	getEventsOverTime: (timeRange = '7days', timezone = '') => {
+	getEventsOverTime: (timeRange = '7days', timezone = '', granularity = '') => {
 		let url = `/stats/events-over-time?time_range=${timeRange}`;
 		if (timezone) url += `&timezone=${encodeURIComponent(timezone)}`;
+		if (granularity) url += `&granularity=${granularity}`;
 		return get(url);
 	},
 	getEventsByKind: (timeRange = 'alltime', timezone = '') => {
@@ -136,11 +187,28 @@ export const stats = {
 		if (timezone) url += `&timezone=${encodeURIComponent(timezone)}`;
 		return get(url);
 	},
+	getKindTrends: (timeRange = '30days', granularity = '', top = 5, timezone = '') => {
+		let url = `/analytics/kind-trends?time_range=${timeRange}&top=${top}`;
+		if (granularity) url += `&granularity=${granularity}`;
+		if (timezone) url += `&timezone=${encodeURIComponent(timezone)}`;
+		return get(url);
+	},
+	getAuthorGrowth: (timeRange = 'alltime', granularity = 'week', timezone = '') => {
+		let url = `/analytics/author-growth?time_range=${timeRange}&granularity=${granularity}`;
+		if (timezone) url += `&timezone=${encodeURIComponent(timezone)}`;
+		return get(url);
+	},
+	getInteractions: (pubkey, timeRange = '30days', limit = 20, timezone = '') => {
+		let url = `/analytics/interactions/${pubkey}?time_range=${timeRange}&limit=${limit}`;
+		if (timezone) url += `&timezone=${encodeURIComponent(timezone)}`;
+		return get(url);
+	},
 	getTopAuthors: (timeRange = 'alltime', limit = 10, timezone = '') => {
 		let url = `/stats/top-authors?time_range=${timeRange}&limit=${limit}`;
 		if (timezone) url += `&timezone=${encodeURIComponent(timezone)}`;
 		return get(url);
-	}
+	},
+	getSystem: (hours = 24) => get(`/system/stats?hours=${hours}`)
 };
 
 export const events = {
@@ -149,6 +217,7 @@ export const events = {
 		return get(`/events${query ? '?' + query : ''}`);
 	},
 	get: (id) => get(`/events/${id}`),
+	getThread: (id) => get(`/events/${id}/thread`),
 	delete: (id) => del(`/events/${id}`),
 	getRecent: () => get('/events/recent')
 };
@@ -156,6 +225,7 @@ export const events = {
 export const relay = {
 	getStatus: () => get('/relay/status'),
 	getURLs: () => get('/relay/urls'),
+	getUptime: (days = 30) => get(`/relay/uptime?days=${days}`),
 	reload: () => post('/relay/reload', {}),
 	restart: () => post('/relay/restart', {}),
 	getLogs: (limit = 100) => get(`/relay/logs?limit=${limit}`)
@@ -164,7 +234,15 @@ export const relay = {
 export const config = {
 	get: () => get('/config'),
 	update: (data) => patch('/config', data),
-	reload: () => post('/config/reload', {})
+	reload: () => post('/config/reload', {}),
+	getDrift: () => get('/config/drift'),
+	reconcile: (direction) => post('/config/reconcile', { direction }),
+	getHistory: (limit = 20, offset = 0) => get(`/config/history?limit=${limit}&offset=${offset}`),
+	getVersion: (id) => get(`/config/history/${id}`),
+	rollback: (id) => post(`/config/history/${id}/rollback`, {}),
+	getPresets: () => get('/config/presets'),
+	applyPreset: (preset) => post('/config/presets', { preset }),
+	getPresetRecommendation: () => get('/config/presets/recommendation')
 };
 
 export const storage = {
@@ -179,6 +257,10 @@ export const storage = {
 		if (applyExceptions) url += '&apply_exceptions=true';
 		return get(url);
 	},
+	getBreakdown: (limit = 20, refresh = false) =>
+		get(`/storage/breakdown?limit=${limit}${refresh ? '&refresh=true' : ''}`),
+	getPressure: () => get('/storage/pressure'),
+	updatePressure: (policy) => put('/storage/pressure', policy),
 	integrityCheck: () => post('/storage/integrity-check', {}),
 	runRetentionNow: () => post('/storage/retention/run', {})
 };
@@ -204,7 +286,7 @@ export const exportApi = {
 
 export const importApi = {
 	importEvents: async (formData) => {
-		const response = await fetch(`${API_BASE}/events/import`, {
+		const response = await csrfFetch(`${API_BASE}/events/import`, {
 			method: 'POST',
 			body: formData
 			// Note: Don't set Content-Type header - browser will set it with boundary for multipart/form-data
@@ -221,6 +303,9 @@ export const sync = {
 	start: (data) => post('/sync/start', data),
 	getStatus: (id) => get(`/sync/status${id ? `?id=${id}` : ''}`),
 	cancel: () => post('/sync/cancel', {}),
+	// Relay migration
+	startMigration: (data) => post('/sync/migrate', data),
+	getMigrationReport: (jobId) => get(`/sync/migrations/${jobId}/report`),
 	getHistory: (params = {}) => {
 		const query = new URLSearchParams();
 		if (params.limit) query.set('limit', params.limit.toString());
@@ -242,9 +327,24 @@ export const support = {
 	getConfig: () => get('/support/config')
 };
 
+export const audit = {
+	list: (params = {}) => {
+		const query = new URLSearchParams(params).toString();
+		return get(`/audit${query ? '?' + query : ''}`);
+	},
+	exportUrl: (params = {}) => {
+		const query = new URLSearchParams(params).toString();
+		return `${API_BASE}/audit.csv${query ? '?' + query : ''}`;
+	},
+	getSettings: () => get('/audit/settings'),
+	updateSettings: (data) => put('/audit/settings', data)
+};
+
 export const settings = {
 	getTimezone: () => get('/settings/timezone'),
-	setTimezone: (timezone) => put('/settings/timezone', { timezone })
+	setTimezone: (timezone) => put('/settings/timezone', { timezone }),
+	getCors: () => get('/settings/cors'),
+	setCors: (allowedOrigins) => put('/settings/cors', { allowed_origins: allowedOrigins })
 };
 
 export const pricing = {
@@ -255,7 +355,15 @@ export const pricing = {
 export const lightning = {
 	getStatus: () => get('/lightning/status'),
 	updateConfig: (config) => put('/lightning/config', config),
-	test: (config) => post('/lightning/test', config)
+	test: (config) => post('/lightning/test', config),
+	validatePermissions: (config) => post('/lightning/validate-permissions', config)
+};
+
+export const secrets = {
+	getStatus: () => get('/secrets/status'),
+	setup: (passphrase) => post('/secrets/setup', { passphrase }),
+	unlock: (passphrase) => post('/secrets/unlock', { passphrase }),
+	lock: () => post('/secrets/lock', {})
 };
 
 export const paidUsers = {
@@ -264,7 +372,35 @@ export const paidUsers = {
 		return get(`/access/paid-users${query ? '?' + query : ''}`);
 	},
 	revoke: (pubkey) => del(`/access/paid-users/${pubkey}`),
-	getRevenue: () => get('/access/revenue')
+	getRevenue: () => get('/access/revenue'),
+	getRenewalSettings: () => get('/access/renewals'),
+	setRenewalSettings: (settings) => put('/access/renewals', settings),
+	runRenewals: () => post('/access/renewals/run', {}),
+	getExpirySettings: () => get('/access/expiry'),
+	setExpirySettings: (settings) => put('/access/expiry', settings)
+};
+
+export const coupons = {
+	list: () => get('/access/coupons'),
+	create: (coupon) => post('/access/coupons', coupon),
+	update: (code, changes) => patch(`/access/coupons/${encodeURIComponent(code)}`, changes),
+	delete: (code) => del(`/access/coupons/${encodeURIComponent(code)}`),
+	getRedemptions: (code) => get(`/access/coupons/${encodeURIComponent(code)}/redemptions`)
+};
+
+export const zaps = {
+	getSettings: () => get('/access/zaps'),
+	updateSettings: (settings) => put('/access/zaps', settings)
+};
+
+export const cashu = {
+	getSettings: () => get('/access/cashu'),
+	updateSettings: (settings) => put('/access/cashu', settings)
+};
+
+export const accessStatus = {
+	getSettings: () => get('/access/status-lookup'),
+	updateSettings: (settings) => put('/access/status-lookup', settings)
 };
 
 // Public signup API (no /api/v1 prefix)
@@ -274,6 +410,16 @@ export const signup = {
 		if (!res.ok) throw await parseError(res);
 		return res.json();
 	},
+	getAccessStatus: async (pubkey) => {
+		const res = await fetch(`/public/access-status/${encodeURIComponent(pubkey)}`);
+		if (!res.ok) throw await parseError(res);
+		return res.json();
+	},
+	checkIdentity: async (input) => {
+		const res = await fetch(`/public/signup-check?input=${encodeURIComponent(input)}`);
+		if (!res.ok) throw await parseError(res);
+		return res.json();
+	},
 	createInvoice: async (data) => {
 		const res = await fetch('/public/create-invoice', {
 			method: 'POST',
@@ -283,9 +429,33 @@ export const signup = {
 		if (!res.ok) throw await parseError(res);
 		return res.json();
 	},
+	reissueInvoice: async (hash) => {
+		const res = await fetch(`/public/invoice-reissue/${hash}`, { method: 'POST' });
+		if (!res.ok) throw await parseError(res);
+		return res.json();
+	},
+	cancelInvoice: async (hash) => {
+		const res = await fetch(`/public/invoice-cancel/${hash}`, { method: 'POST' });
+		if (!res.ok) throw await parseError(res);
+		return res.json();
+	},
+	payWithCashu: async (data) => {
+		const res = await fetch('/public/cashu-payment', {
+			method: 'POST',
+			headers: { 'Content-Type': 'application/json' },
+			body: JSON.stringify(data)
+		});
+		if (!res.ok) throw await parseError(res);
+		return res.json();
+	},
 	checkInvoice: async (hash) => {
 		const res = await fetch(`/public/invoice-status/${hash}`);
 		if (!res.ok) throw await parseError(res);
 		return res.json();
+	},
+	getRenewal: async (npub) => {
+		const res = await fetch(`/public/renew/${npub}`);
+		if (!res.ok) throw await parseError(res);
+		return res.json();
 	}
 };
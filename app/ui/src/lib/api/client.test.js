@@ -16,7 +16,8 @@ import {
 	sync,
 	pricing,
 	lightning,
-	paidUsers
+	paidUsers,
+	audit
 } from './client.js';
 
 describe('ApiError', () => {
@@ -200,6 +201,16 @@ describe('API function groups', () => {
 			expect(fetch).toHaveBeenCalledWith('/api/v1/access/mode');
 		});
 
+		it('updatePolicy puts policy for tier', async () => {
+			const policy = { allow_kinds: [0, 1, 3, 7], deny_kinds: [] };
+			await access.updatePolicy('whitelist', policy);
+			expect(fetch).toHaveBeenCalledWith('/api/v1/access/policies/whitelist', {
+				method: 'PUT',
+				headers: { 'Content-Type': 'application/json' },
+				body: JSON.stringify(policy)
+			});
+		});
+
 		it('setMode calls PUT with mode', async () => {
 			await access.setMode('private');
 			expect(fetch).toHaveBeenCalledWith('/api/v1/access/mode', {
@@ -253,6 +264,11 @@ describe('API function groups', () => {
 			);
 		});
 
+		it('getEventsOverTime accepts a granularity', async () => {
+			await stats.getEventsOverTime('alltime', '', 'month');
+			expect(fetch).toHaveBeenCalledWith('/api/v1/stats/events-over-time?time_range=alltime&granularity=month');
+		});
+
 		it('getEventsByKind uses default time range', async () => {
 			await stats.getEventsByKind();
 			expect(fetch).toHaveBeenCalledWith('/api/v1/stats/events-by-kind?time_range=alltime');
@@ -267,6 +283,11 @@ describe('API function groups', () => {
 			await stats.getTopAuthors('7days', 20);
 			expect(fetch).toHaveBeenCalledWith('/api/v1/stats/top-authors?time_range=7days&limit=20');
 		});
+
+		it('getSystem requests a day of samples by default', async () => {
+			await stats.getSystem();
+			expect(fetch).toHaveBeenCalledWith('/api/v1/system/stats?hours=24');
+		});
 	});
 
 	describe('events', () => {
@@ -285,6 +306,11 @@ describe('API function groups', () => {
 			expect(fetch).toHaveBeenCalledWith('/api/v1/events/abc123');
 		});
 
+		it('getThread fetches thread context by id', async () => {
+			await events.getThread('abc123');
+			expect(fetch).toHaveBeenCalledWith('/api/v1/events/abc123/thread');
+		});
+
 		it('delete removes by id', async () => {
 			await events.delete('abc123');
 			expect(fetch).toHaveBeenCalledWith('/api/v1/events/abc123', { method: 'DELETE' });
@@ -307,6 +333,11 @@ describe('API function groups', () => {
 			expect(fetch).toHaveBeenCalledWith('/api/v1/relay/urls');
 		});
 
+		it('getUptime requests 30 days by default', async () => {
+			await relay.getUptime();
+			expect(fetch).toHaveBeenCalledWith('/api/v1/relay/uptime?days=30');
+		});
+
 		it('reload posts to correct endpoint', async () => {
 			await relay.reload();
 			expect(fetch).toHaveBeenCalledWith('/api/v1/relay/reload', {
@@ -360,6 +391,38 @@ describe('API function groups', () => {
 				body: '{}'
 			});
 		});
+
+		it('reconcile posts the direction', async () => {
+			await config.reconcile('adopt');
+			expect(fetch).toHaveBeenCalledWith('/api/v1/config/reconcile', {
+				method: 'POST',
+				headers: { 'Content-Type': 'application/json' },
+				body: JSON.stringify({ direction: 'adopt' })
+			});
+		});
+
+		it('getHistory passes limit and offset', async () => {
+			await config.getHistory(10, 20);
+			expect(fetch).toHaveBeenCalledWith('/api/v1/config/history?limit=10&offset=20');
+		});
+
+		it('rollback posts to the version', async () => {
+			await config.rollback(41);
+			expect(fetch).toHaveBeenCalledWith('/api/v1/config/history/41/rollback', {
+				method: 'POST',
+				headers: { 'Content-Type': 'application/json' },
+				body: '{}'
+			});
+		});
+
+		it('applyPreset posts the preset', async () => {
+			await config.applyPreset('family');
+			expect(fetch).toHaveBeenCalledWith('/api/v1/config/presets', {
+				method: 'POST',
+				headers: { 'Content-Type': 'application/json' },
+				body: JSON.stringify({ preset: 'family' })
+			});
+		});
 	});
 
 	describe('storage', () => {
@@ -414,6 +477,43 @@ describe('API function groups', () => {
 			await storage.getEstimate('2024-01-01');
 			expect(fetch).toHaveBeenCalledWith('/api/v1/storage/estimate?before_date=2024-01-01');
 		});
+
+		it('getBreakdown passes limit and refresh', async () => {
+			await storage.getBreakdown(10, true);
+			expect(fetch).toHaveBeenCalledWith('/api/v1/storage/breakdown?limit=10&refresh=true');
+		});
+
+		it('updatePressure puts the policy', async () => {
+			const policy = { enabled: true, retention_percent: 90, retention_days: 30, pause_percent: 95 };
+			await storage.updatePressure(policy);
+			expect(fetch).toHaveBeenCalledWith('/api/v1/storage/pressure', {
+				method: 'PUT',
+				headers: { 'Content-Type': 'application/json' },
+				body: JSON.stringify(policy)
+			});
+		});
+	});
+
+	describe('audit', () => {
+		it('list builds query string', async () => {
+			await audit.list({ action: 'whitelist_add', limit: 20 });
+			expect(fetch).toHaveBeenCalledWith('/api/v1/audit?action=whitelist_add&limit=20');
+		});
+
+		it('exportUrl builds CSV url', () => {
+			expect(audit.exportUrl({ q: 'alice' })).toBe('/api/v1/audit.csv?q=alice');
+			expect(audit.exportUrl()).toBe('/api/v1/audit.csv');
+		});
+
+		it('updateSettings puts data', async () => {
+			const data = { retention_days: 90 };
+			await audit.updateSettings(data);
+			expect(fetch).toHaveBeenCalledWith('/api/v1/audit/settings', {
+				method: 'PUT',
+				headers: { 'Content-Type': 'application/json' },
+				body: JSON.stringify(data)
+			});
+		});
 	});
 
 	describe('sync', () => {
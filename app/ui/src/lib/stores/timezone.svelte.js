@@ -5,6 +5,7 @@
  */
 
 import { browser } from '$app/environment';
+import { csrfFetch } from '$lib/api/client.js';
 
 const STORAGE_KEY = 'roostr-timezone';
 
@@ -79,7 +80,7 @@ export async function setTimezone(timezone) {
 
 	// Persist to backend (fire and forget - don't block UI)
 	try {
-		const res = await fetch('/api/v1/settings/timezone', {
+		const res = await csrfFetch('/api/v1/settings/timezone', {
 			method: 'PUT',
 			headers: { 'Content-Type': 'application/json' },
 			body: JSON.stringify({ timezone })
@@ -2,7 +2,7 @@
 set -e
 
 # Roostr Start9 Entrypoint
-# Starts nostr-rs-relay in background, then runs the Roostr API
+# Runs the Roostr API, which starts and supervises nostr-rs-relay
 
 CONFIG_PATH="${CONFIG_PATH:-/data/config.toml}"
 RELAY_PORT="${RELAY_PORT:-7000}"
@@ -46,36 +46,42 @@ event_persist_buffer = 4096
 EOF
 fi
 
-# Function to handle shutdown
-cleanup() {
-    echo "Shutting down..."
-    # Send SIGTERM to relay process
-    if [ -n "$RELAY_PID" ] && kill -0 "$RELAY_PID" 2>/dev/null; then
-        kill -TERM "$RELAY_PID"
-        wait "$RELAY_PID" 2>/dev/null || true
+# The API runs nostr-rs-relay as a child process and restarts it with
+# backoff if it crashes. Set RELAY_SUPERVISE=false to start it here instead.
+export RELAY_SUPERVISE="${RELAY_SUPERVISE:-true}"
+
+if [ "$RELAY_SUPERVISE" != "true" ]; then
+    # Function to handle shutdown
+    cleanup() {
+        echo "Shutting down..."
+        # Send SIGTERM to relay process
+        if [ -n "$RELAY_PID" ] && kill -0 "$RELAY_PID" 2>/dev/null; then
+            kill -TERM "$RELAY_PID"
+            wait "$RELAY_PID" 2>/dev/null || true
+        fi
+        exit 0
+    }
+
+    trap cleanup SIGTERM SIGINT
+
+    # Start nostr-rs-relay in background
+    echo "Starting nostr-rs-relay on port ${RELAY_PORT}..."
+    /usr/local/bin/nostr-rs-relay --config "$CONFIG_PATH" &
+    RELAY_PID=$!
+
+    # Wait for relay to be ready
+    echo "Waiting for relay to start..."
+    sleep 2
+
+    # Check if relay is still running
+    if ! kill -0 "$RELAY_PID" 2>/dev/null; then
+        echo "ERROR: Relay failed to start"
+        exit 1
     fi
-    exit 0
-}
 
-trap cleanup SIGTERM SIGINT
-
-# Start nostr-rs-relay in background
-echo "Starting nostr-rs-relay on port ${RELAY_PORT}..."
-/usr/local/bin/nostr-rs-relay --config "$CONFIG_PATH" &
-RELAY_PID=$!
-
-# Wait for relay to be ready
-echo "Waiting for relay to start..."
-sleep 2
-
-# Check if relay is still running
-if ! kill -0 "$RELAY_PID" 2>/dev/null; then
-    echo "ERROR: Relay failed to start"
-    exit 1
+    echo "Relay started with PID $RELAY_PID"
 fi
 
-echo "Relay started with PID $RELAY_PID"
-
 # Start Roostr API (serves UI + API)
 echo "Starting Roostr API on port ${PORT:-8080}..."
 exec /usr/local/bin/roostr-api
@@ -2,7 +2,7 @@
 set -e
 
 # Roostr Umbrel Entrypoint
-# Handles permission setup, starts nostr-rs-relay in background, then runs the Roostr API
+# Handles permission setup, then runs the Roostr API, which starts and supervises nostr-rs-relay
 
 CONFIG_PATH="${CONFIG_PATH:-/data/config.toml}"
 RELAY_PORT="${RELAY_PORT:-7000}"
@@ -64,43 +64,49 @@ file_prefix = "relay"
 EOF
 fi
 
-# Function to handle shutdown
-cleanup() {
-    echo "Shutting down..."
-    # Send SIGTERM to relay process
-    if [ -n "$RELAY_PID" ] && kill -0 "$RELAY_PID" 2>/dev/null; then
-        kill -TERM "$RELAY_PID"
-        wait "$RELAY_PID" 2>/dev/null || true
-    fi
-    exit 0
-}
-
-trap cleanup SIGTERM SIGINT
-
 # Create log directory for relay logs
 LOG_DIR="/data/logs"
 mkdir -p "$LOG_DIR"
 
-# Truncate log file on restart to prevent unbounded growth
-> "$LOG_DIR/relay.log"
-
-# Start nostr-rs-relay in background, capturing logs to file and stdout
-echo "Starting nostr-rs-relay on port ${RELAY_PORT}..."
-/usr/local/bin/nostr-rs-relay --config "$CONFIG_PATH" 2>&1 | tee -a "$LOG_DIR/relay.log" &
-RELAY_PID=$!
-
-# Wait for relay to be ready
-echo "Waiting for relay to start..."
-sleep 2
+# The API runs nostr-rs-relay as a child process and restarts it with
+# backoff if it crashes. Set RELAY_SUPERVISE=false to start it here instead.
+export RELAY_SUPERVISE="${RELAY_SUPERVISE:-true}"
+
+if [ "$RELAY_SUPERVISE" != "true" ]; then
+    # Function to handle shutdown
+    cleanup() {
+        echo "Shutting down..."
+        # Send SIGTERM to relay process
+        if [ -n "$RELAY_PID" ] && kill -0 "$RELAY_PID" 2>/dev/null; then
+            kill -TERM "$RELAY_PID"
+            wait "$RELAY_PID" 2>/dev/null || true
+        fi
+        exit 0
+    }
+
+    trap cleanup SIGTERM SIGINT
+
+    # Truncate log file on restart to prevent unbounded growth
+    > "$LOG_DIR/relay.log"
+
+    # Start nostr-rs-relay in background, capturing logs to file and stdout
+    echo "Starting nostr-rs-relay on port ${RELAY_PORT}..."
+    /usr/local/bin/nostr-rs-relay --config "$CONFIG_PATH" 2>&1 | tee -a "$LOG_DIR/relay.log" &
+    RELAY_PID=$!
+
+    # Wait for relay to be ready
+    echo "Waiting for relay to start..."
+    sleep 2
+
+    # Check if relay is still running
+    if ! kill -0 "$RELAY_PID" 2>/dev/null; then
+        echo "ERROR: Relay failed to start"
+        exit 1
+    fi
 
-# Check if relay is still running
-if ! kill -0 "$RELAY_PID" 2>/dev/null; then
-    echo "ERROR: Relay failed to start"
-    exit 1
+    echo "Relay started with PID $RELAY_PID"
 fi
 
-echo "Relay started with PID $RELAY_PID"
-
 # Start Roostr API (serves UI + API)
 echo "Starting Roostr API on port ${PORT:-8080}..."
 exec /usr/local/bin/roostr-api